@@ -0,0 +1,135 @@
+// Package activity builds a user's activity feed from the outbox: matches
+// they received or started, cancelled referrals they owned or were matched
+// to, and agreements taking effect or disputes opened on agreements they
+// are a party to. Delivered outbox messages are kept until an admin purges
+// them, so the feed covers recent history rather than everything.
+package activity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+)
+
+// Type classifies a feed item.
+type Type string
+
+const (
+	// TypeMatchReceived is a match invitation or a marketplace application,
+	// seen from either side.
+	TypeMatchReceived Type = "match_received"
+	// TypeReferralCancelled is a referral the user owned or was matched to
+	// being cancelled.
+	TypeReferralCancelled Type = "referral_cancelled"
+	// TypeAgreementSigned is an agreement of the user's taking effect.
+	TypeAgreementSigned Type = "agreement_signed"
+	// TypeDisputeOpened is a dispute opened on an agreement of the user's.
+	TypeDisputeOpened Type = "dispute_opened"
+)
+
+// Types lists every feed item type in display order.
+func Types() []Type {
+	return []Type{TypeMatchReceived, TypeReferralCancelled, TypeAgreementSigned, TypeDisputeOpened}
+}
+
+// topics maps each type to the outbox topics it is built from.
+var topics = map[Type][]string{
+	TypeMatchReceived:     {referral.OutboxTopicMatchInvited, referral.OutboxTopicMatchApplied},
+	TypeReferralCancelled: {referral.OutboxTopicReferralCancelled},
+	TypeAgreementSigned:   {agreement.OutboxTopicAgreementEffective},
+	TypeDisputeOpened:     {dispute.OutboxTopicDisputeOpened},
+}
+
+// typeOf returns the feed type of an outbox topic.
+func typeOf(topic string) (Type, bool) {
+	for t, ts := range topics {
+		for _, name := range ts {
+			if name == topic {
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
+
+var ErrUnknownType = errors.New("activity: unknown activity type")
+
+// ParseTypes reads type filters, each of which may be a comma-separated
+// list. Duplicates are dropped; no filters means every type.
+func ParseTypes(values []string) ([]Type, error) {
+	var out []Type
+	seen := map[Type]bool{}
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			t := Type(strings.TrimSpace(part))
+			if t == "" {
+				continue
+			}
+			if _, ok := topics[t]; !ok {
+				return nil, ErrUnknownType
+			}
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Item is one feed entry. Own is true when the user caused it: they sent
+// the invitation or application, cancelled their own referral or opened
+// the dispute. The ids are those the underlying outbox payload carries.
+type Item struct {
+	ID          string
+	Type        Type
+	Topic       string
+	Own         bool
+	ReferralID  string
+	MatchID     string
+	AgreementID string
+	DisputeID   string
+	At          time.Time
+}
+
+type Repository interface {
+	// List returns one page of the feed of userID built from topics, newest
+	// first, with the total number of items.
+	List(ctx context.Context, userID string, topics []string, page, pageSize int) ([]Item, int, error)
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// List returns one page of the user's feed restricted to types, or of every
+// type when types is empty.
+func (s *Service) List(ctx context.Context, userID string, types []Type, page, pageSize int) ([]Item, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	if len(types) == 0 {
+		types = Types()
+	}
+	var names []string
+	for _, t := range types {
+		ts, ok := topics[t]
+		if !ok {
+			return nil, 0, ErrUnknownType
+		}
+		names = append(names, ts...)
+	}
+	return s.repo.List(ctx, userID, names, page, pageSize)
+}
@@ -0,0 +1,60 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type stubRepo struct {
+	topics         []string
+	page, pageSize int
+}
+
+func (s *stubRepo) List(_ context.Context, userID string, topics []string, page, pageSize int) ([]Item, int, error) {
+	s.topics, s.page, s.pageSize = topics, page, pageSize
+	return nil, 0, nil
+}
+
+func TestParseTypes(t *testing.T) {
+	got, err := ParseTypes([]string{"dispute_opened, match_received", "dispute_opened", ""})
+	if err != nil || !slices.Equal(got, []Type{TypeDisputeOpened, TypeMatchReceived}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := ParseTypes(nil); err != nil || got != nil {
+		t.Fatalf("no filters: got %v, %v", got, err)
+	}
+	if _, err := ParseTypes([]string{"match_received,review_submitted"}); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("unknown type: got %v", err)
+	}
+}
+
+func TestServiceListTopics(t *testing.T) {
+	repo := &stubRepo{}
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	if _, _, err := svc.List(ctx, "user-1", nil, 0, 500); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.topics) != 5 || repo.page != 1 || repo.pageSize != 20 {
+		t.Fatalf("all types: topics %v page %d size %d", repo.topics, repo.page, repo.pageSize)
+	}
+	for _, topic := range repo.topics {
+		if _, ok := typeOf(topic); !ok {
+			t.Errorf("topic %q has no type", topic)
+		}
+	}
+
+	if _, _, err := svc.List(ctx, "user-1", []Type{TypeMatchReceived}, 2, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repo.topics, []string{"match.invited", "match.applied"}) || repo.page != 2 || repo.pageSize != 10 {
+		t.Fatalf("match filter: topics %v page %d size %d", repo.topics, repo.page, repo.pageSize)
+	}
+
+	if _, _, err := svc.List(ctx, "user-1", []Type{"bogus"}, 1, 10); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("bogus type: got %v", err)
+	}
+}
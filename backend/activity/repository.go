@@ -0,0 +1,115 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	reader db.Reader
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{reader: pool}
+}
+
+// WithReader reads the activity feed and its count from reader.
+func (r *PGRepository) WithReader(reader db.Reader) *PGRepository {
+	r.reader = reader
+	return r
+}
+
+// feedSQL selects the outbox messages on topics $2 that concern user $1.
+// Match payloads name both sides. A cancelled referral concerns its owner
+// and every candidate matched to it. Agreement and dispute messages concern
+// the referral owner and the accepted candidate from the receiving broker,
+// the parties review and email notifications use.
+const feedSQL = `
+	WITH my_referrals AS (
+		SELECT id, true AS own FROM referral_requests WHERE created_by_user_id = $1::text::uuid
+		UNION
+		SELECT request_id, false FROM referral_matches WHERE candidate_user_id = $1::text::uuid
+	), my_agreements AS (
+		SELECT a.id
+		FROM agreements a
+		JOIN referral_requests rr ON rr.id = a.referral_id
+		WHERE rr.created_by_user_id = $1::text::uuid
+		   OR EXISTS (
+			SELECT 1 FROM referral_matches m
+			JOIN users u ON u.id = m.candidate_user_id
+			WHERE m.request_id = a.referral_id AND m.state = 'accepted'
+			  AND m.candidate_user_id = $1::text::uuid AND u.broker_id = a.to_broker_id
+		   )
+	), feed AS (
+		SELECT o.id, o.topic, o.payload, o.created_at,
+			(o.topic = $3 AND o.payload->>'owner_id' = $1::text) OR (o.topic = $4 AND o.payload->>'candidate_id' = $1::text) AS own
+		FROM outbox o
+		WHERE o.topic = ANY ($2) AND o.topic IN ($3, $4)
+		  AND $1::text IN (o.payload->>'candidate_id', o.payload->>'owner_id')
+		UNION ALL
+		SELECT o.id, o.topic, o.payload, o.created_at, bool_or(r.own)
+		FROM outbox o
+		JOIN my_referrals r ON r.id::text = o.payload->>'referral_id'
+		WHERE o.topic = ANY ($2) AND o.topic = $5
+		GROUP BY o.id
+		UNION ALL
+		SELECT o.id, o.topic, o.payload, o.created_at, o.topic = $7 AND o.payload->>'opened_by' = $1::text
+		FROM outbox o
+		JOIN my_agreements a ON a.id::text = o.payload->>'agreement_id'
+		WHERE o.topic = ANY ($2) AND o.topic IN ($6, $7)
+	)`
+
+func (r *PGRepository) List(ctx context.Context, userID string, topics []string, page, pageSize int) ([]Item, int, error) {
+	args := []any{userID, topics,
+		referral.OutboxTopicMatchInvited, referral.OutboxTopicMatchApplied,
+		referral.OutboxTopicReferralCancelled,
+		agreement.OutboxTopicAgreementEffective, dispute.OutboxTopicDisputeOpened,
+	}
+	var total int
+	if err := r.reader.QueryRow(ctx, feedSQL+` SELECT COUNT(*) FROM feed`, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("activity: count feed: %w", err)
+	}
+	rows, err := r.reader.Query(ctx, feedSQL+`
+		SELECT id::text, topic, COALESCE(payload, '{}'::jsonb), created_at, own
+		FROM feed
+		ORDER BY created_at DESC, id
+		LIMIT $8 OFFSET $9
+	`, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("activity: list feed: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Item, error) {
+		var (
+			it      Item
+			payload []byte
+		)
+		if err := row.Scan(&it.ID, &it.Topic, &payload, &it.At, &it.Own); err != nil {
+			return Item{}, err
+		}
+		it.Type, _ = typeOf(it.Topic)
+		var ids struct {
+			ReferralID  string `json:"referral_id"`
+			MatchID     string `json:"match_id"`
+			AgreementID string `json:"agreement_id"`
+			DisputeID   string `json:"dispute_id"`
+		}
+		// Producers own the payload shape; a malformed one still shows up in
+		// the feed, just without links.
+		_ = json.Unmarshal(payload, &ids)
+		it.ReferralID, it.MatchID, it.AgreementID, it.DisputeID = ids.ReferralID, ids.MatchID, ids.AgreementID, ids.DisputeID
+		return it, nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("activity: scan feed: %w", err)
+	}
+	return items, total, nil
+}
@@ -0,0 +1,98 @@
+package agentprofile
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Limits on profile list sizes.
+const (
+	MaxTerms    = 50
+	MaxLicenses = 20
+)
+
+var (
+	ErrNotFound          = errors.New("agentprofile: profile not found")
+	ErrTooManyTerms      = errors.New("agentprofile: too many regions, languages or property types")
+	ErrInvalidPriceRange = errors.New("agentprofile: price specialty requires 0 < priceMin <= priceMax")
+	ErrInvalidLicense    = errors.New("agentprofile: license requires jurisdiction and number")
+	ErrTooManyLicenses   = errors.New("agentprofile: too many licenses")
+	ErrReferralNotFound  = errors.New("agentprofile: referral not found")
+)
+
+// License is a real estate license held by the agent.
+type License struct {
+	Jurisdiction string `json:"jurisdiction"`
+	Number       string `json:"number"`
+}
+
+// Profile describes what an agent covers. Regions, Languages and
+// PropertyTypes are stored lower-cased; a nil price bound means the agent has
+// no specialty on that side.
+type Profile struct {
+	UserID        string
+	Regions       []string
+	Languages     []string
+	PriceMin      *int64
+	PriceMax      *int64
+	PropertyTypes []string
+	Licenses      []License
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// normalize trims and lower-cases terms, drops blanks and duplicates, and
+// trims license fields.
+func (p Profile) normalize() Profile {
+	p.Regions = normalizeTerms(p.Regions)
+	p.Languages = normalizeTerms(p.Languages)
+	p.PropertyTypes = normalizeTerms(p.PropertyTypes)
+	licenses := make([]License, 0, len(p.Licenses))
+	seen := make(map[License]bool, len(p.Licenses))
+	for _, l := range p.Licenses {
+		l = License{Jurisdiction: strings.ToUpper(strings.TrimSpace(l.Jurisdiction)), Number: strings.TrimSpace(l.Number)}
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		licenses = append(licenses, l)
+	}
+	p.Licenses = licenses
+	return p
+}
+
+// Validate mirrors the CHECK constraints on agent_profiles and bounds list
+// sizes.
+func (p Profile) Validate() error {
+	if len(p.Regions) > MaxTerms || len(p.Languages) > MaxTerms || len(p.PropertyTypes) > MaxTerms {
+		return ErrTooManyTerms
+	}
+	if (p.PriceMin != nil && *p.PriceMin <= 0) || (p.PriceMax != nil && *p.PriceMax <= 0) ||
+		(p.PriceMin != nil && p.PriceMax != nil && *p.PriceMin > *p.PriceMax) {
+		return ErrInvalidPriceRange
+	}
+	if len(p.Licenses) > MaxLicenses {
+		return ErrTooManyLicenses
+	}
+	for _, l := range p.Licenses {
+		if l.Jurisdiction == "" || l.Number == "" {
+			return ErrInvalidLicense
+		}
+	}
+	return nil
+}
+
+func normalizeTerms(terms []string) []string {
+	out := make([]string, 0, len(terms))
+	seen := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package agentprofile
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func ptr(v int64) *int64 { return &v }
+
+func manyLicenses(n int) []License {
+	out := make([]License, n)
+	for i := range out {
+		out[i] = License{Jurisdiction: "TX", Number: strconv.Itoa(i)}
+	}
+	return out
+}
+
+type fakeStore struct {
+	profile  Profile
+	criteria Criteria
+	getErr   error
+	critErr  error
+}
+
+func (f *fakeStore) Get(context.Context, string) (Profile, error) { return f.profile, f.getErr }
+
+func (f *fakeStore) Save(_ context.Context, p Profile) (Profile, error) {
+	f.profile = p
+	return p, nil
+}
+
+func (f *fakeStore) Delete(context.Context, string) error { return nil }
+
+func (f *fakeStore) Criteria(context.Context, string) (Criteria, error) { return f.criteria, f.critErr }
+
+func TestService_SaveNormalizes(t *testing.T) {
+	store := &fakeStore{}
+	svc := NewService(store)
+
+	_, err := svc.Save(context.Background(), Profile{
+		UserID:        "agent-1",
+		Regions:       []string{" Austin", "austin", ""},
+		Languages:     []string{"English"},
+		PropertyTypes: []string{"Condo "},
+		Licenses:      []License{{Jurisdiction: " tx", Number: " 0123 "}, {Jurisdiction: "TX", Number: "0123"}},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !reflect.DeepEqual(store.profile.Regions, []string{"austin"}) || !reflect.DeepEqual(store.profile.PropertyTypes, []string{"condo"}) {
+		t.Fatalf("unexpected terms %+v", store.profile)
+	}
+	if !reflect.DeepEqual(store.profile.Licenses, []License{{Jurisdiction: "TX", Number: "0123"}}) {
+		t.Fatalf("unexpected licenses %+v", store.profile.Licenses)
+	}
+}
+
+func TestService_SaveValidates(t *testing.T) {
+	cases := []struct {
+		name string
+		p    Profile
+		want error
+	}{
+		{"inverted price", Profile{PriceMin: ptr(500), PriceMax: ptr(100)}, ErrInvalidPriceRange},
+		{"zero price", Profile{PriceMin: ptr(0)}, ErrInvalidPriceRange},
+		{"license without number", Profile{Licenses: []License{{Jurisdiction: "TX"}}}, ErrInvalidLicense},
+		{"too many licenses", Profile{Licenses: manyLicenses(MaxLicenses + 1)}, ErrTooManyLicenses},
+		{"open price ceiling", Profile{PriceMin: ptr(100)}, nil},
+	}
+	for _, tc := range cases {
+		_, err := NewService(&fakeStore{}).Save(context.Background(), tc.p)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	referral := Criteria{Regions: []string{"Austin"}, Languages: []string{"Spanish"}, PriceMin: 300, PriceMax: 600, PropertyType: "Condo"}
+	cases := []struct {
+		name string
+		p    Profile
+		want float64
+	}{
+		{"perfect fit", Profile{Regions: []string{"austin"}, Languages: []string{"spanish"}, PropertyTypes: []string{"condo"}, PriceMin: ptr(200), PriceMax: ptr(400)}, 1},
+		{"other region", Profile{Regions: []string{"dallas"}, Languages: []string{"spanish"}}, 0},
+		{"open profile", Profile{Regions: []string{"austin"}}, 0.7},
+		{"mismatches", Profile{Regions: []string{"austin"}, Languages: []string{"english"}, PropertyTypes: []string{"land"}, PriceMin: ptr(700)}, 0.4},
+	}
+	for _, tc := range cases {
+		if got := Score(tc.p, referral); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestService_ScoreCandidateWithoutProfile(t *testing.T) {
+	svc := NewService(&fakeStore{getErr: ErrNotFound, criteria: Criteria{Regions: []string{"austin"}}})
+	score, err := svc.ScoreCandidate(context.Background(), "agent-1", "r1")
+	if err != nil || score != 0 {
+		t.Fatalf("expected 0 without a profile, got %v, %v", score, err)
+	}
+
+	svc = NewService(&fakeStore{critErr: ErrReferralNotFound})
+	if _, err := svc.ScoreCandidate(context.Background(), "agent-1", "r1"); err != nil {
+		t.Fatalf("missing referral should score 0, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package agentprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository persists agent profiles in agent_profiles.
+type Repository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+const profileColumns = `user_id, regions, languages, price_min, price_max, property_types, licenses, created_at, updated_at`
+
+func scanProfile(row pgx.Row) (Profile, error) {
+	var p Profile
+	err := row.Scan(&p.UserID, &p.Regions, &p.Languages, &p.PriceMin, &p.PriceMax, &p.PropertyTypes, &p.Licenses, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+func (r *Repository) Get(ctx context.Context, userID string) (Profile, error) {
+	p, err := scanProfile(r.pool.QueryRow(ctx, `SELECT `+profileColumns+` FROM agent_profiles WHERE user_id = $1`, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Profile{}, ErrNotFound
+		}
+		return Profile{}, fmt.Errorf("agentprofile: get profile: %w", err)
+	}
+	return p, nil
+}
+
+// Save inserts or replaces the profile of p.UserID.
+func (r *Repository) Save(ctx context.Context, p Profile) (Profile, error) {
+	const query = `
+		INSERT INTO agent_profiles (user_id, regions, languages, price_min, price_max, property_types, licenses)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			regions = EXCLUDED.regions,
+			languages = EXCLUDED.languages,
+			price_min = EXCLUDED.price_min,
+			price_max = EXCLUDED.price_max,
+			property_types = EXCLUDED.property_types,
+			licenses = EXCLUDED.licenses,
+			updated_at = get_tx_timestamp()
+		RETURNING ` + profileColumns
+	saved, err := scanProfile(r.pool.QueryRow(ctx, query, p.UserID, p.Regions, p.Languages, p.PriceMin, p.PriceMax, p.PropertyTypes, p.Licenses))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return Profile{}, ErrNotFound
+		}
+		return Profile{}, fmt.Errorf("agentprofile: save profile: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, userID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM agent_profiles WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("agentprofile: delete profile: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Criteria reads the terms of a referral request for scoring. Regions are
+// widened with region_expand to every region containing or within the
+// referral's, so an agent serving New York scores for a Brooklyn referral
+// and the other way round.
+func (r *Repository) Criteria(ctx context.Context, requestID string) (Criteria, error) {
+	const query = `
+		SELECT region_expand(region), languages, price_min, price_max, property_type
+		FROM referral_requests
+		WHERE id = $1
+	`
+	var c Criteria
+	if err := r.pool.QueryRow(ctx, query, requestID).Scan(&c.Regions, &c.Languages, &c.PriceMin, &c.PriceMax, &c.PropertyType); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Criteria{}, ErrReferralNotFound
+		}
+		return Criteria{}, fmt.Errorf("agentprofile: load referral criteria: %w", err)
+	}
+	return c, nil
+}
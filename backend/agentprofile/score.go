@@ -0,0 +1,72 @@
+package agentprofile
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// Criteria are the referral terms a profile is scored against.
+type Criteria struct {
+	// Regions holds the referral's regions together with every region
+	// containing or within them.
+	Regions      []string
+	Languages    []string
+	PriceMin     int64
+	PriceMax     int64
+	PropertyType string
+}
+
+// Weights of each criterion in Score; they sum to 1.
+const (
+	regionWeight       = 0.4
+	languageWeight     = 0.2
+	propertyTypeWeight = 0.2
+	priceWeight        = 0.2
+)
+
+// Score rates how well p fits c, from 0 to 1 in steps of 0.01 to fit
+// referral_matches.score. An agent who serves none of the referral's regions
+// scores 0. Otherwise each criterion counts in full when it matches and half
+// when the profile leaves it open; a referral without languages matches any
+// agent.
+func Score(p Profile, c Criteria) float64 {
+	if !overlaps(p.Regions, c.Regions) {
+		return 0
+	}
+	score := regionWeight
+
+	switch {
+	case len(c.Languages) == 0 || overlaps(p.Languages, c.Languages):
+		score += languageWeight
+	case len(p.Languages) == 0:
+		score += languageWeight / 2
+	}
+
+	switch {
+	case len(p.PropertyTypes) == 0:
+		score += propertyTypeWeight / 2
+	case slices.Contains(p.PropertyTypes, strings.ToLower(strings.TrimSpace(c.PropertyType))):
+		score += propertyTypeWeight
+	}
+
+	switch {
+	case p.PriceMin == nil && p.PriceMax == nil:
+		score += priceWeight / 2
+	case (p.PriceMin == nil || *p.PriceMin <= c.PriceMax) && (p.PriceMax == nil || *p.PriceMax >= c.PriceMin):
+		score += priceWeight
+	}
+
+	return math.Round(score*100) / 100
+}
+
+// overlaps reports whether any of want appears in have, ignoring case; have
+// is already lower-cased.
+func overlaps(have, want []string) bool {
+	for _, w := range want {
+		if slices.Contains(have, strings.ToLower(strings.TrimSpace(w))) {
+			return true
+		}
+	}
+	return false
+}
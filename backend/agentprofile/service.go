@@ -0,0 +1,83 @@
+package agentprofile
+
+import (
+	"context"
+	"errors"
+)
+
+// Store abstracts persistence of agent profiles and the referral terms they
+// are scored against.
+type Store interface {
+	Get(ctx context.Context, userID string) (Profile, error)
+	Save(ctx context.Context, p Profile) (Profile, error)
+	Delete(ctx context.Context, userID string) error
+	Criteria(ctx context.Context, requestID string) (Criteria, error)
+}
+
+// RegionResolver maps region codes and aliases to canonical region codes;
+// region.Service implements it.
+type RegionResolver interface {
+	Resolve(ctx context.Context, values []string) ([]string, error)
+}
+
+// Service exposes profile CRUD and candidate scoring.
+type Service struct {
+	store   Store
+	regions RegionResolver
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// WithRegions stores profile regions as canonical region codes and rejects
+// unknown regions.
+func (s *Service) WithRegions(regions RegionResolver) *Service {
+	s.regions = regions
+	return s
+}
+
+func (s *Service) Get(ctx context.Context, userID string) (Profile, error) {
+	return s.store.Get(ctx, userID)
+}
+
+// Save normalizes, validates and stores p, replacing any earlier profile.
+func (s *Service) Save(ctx context.Context, p Profile) (Profile, error) {
+	p = p.normalize()
+	if err := p.Validate(); err != nil {
+		return Profile{}, err
+	}
+	if s.regions != nil && len(p.Regions) > 0 {
+		codes, err := s.regions.Resolve(ctx, p.Regions)
+		if err != nil {
+			return Profile{}, err
+		}
+		p.Regions = codes
+	}
+	return s.store.Save(ctx, p)
+}
+
+func (s *Service) Delete(ctx context.Context, userID string) error {
+	return s.store.Delete(ctx, userID)
+}
+
+// ScoreCandidate scores candidateID's profile against a referral request.
+// Agents without a profile score 0, as does any agent for a referral that
+// does not exist; callers report the missing referral themselves.
+func (s *Service) ScoreCandidate(ctx context.Context, candidateID, requestID string) (float64, error) {
+	c, err := s.store.Criteria(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, ErrReferralNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	p, err := s.store.Get(ctx, candidateID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return Score(p, c), nil
+}
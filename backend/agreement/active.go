@@ -0,0 +1,57 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// activeStatuses are the statuses in which an agreement holds its referral:
+// a second agreement on the same referral would compete with it. Migration
+// 000001's agreements_one_active_per_referral index backs the signed part of
+// this (pending_signature, effective); drafts are only guarded here.
+const activeStatuses = `('draft','pending_signature','effective')`
+
+// lockReferralSQL serializes agreement creation per referral for the rest
+// of the transaction, so the existing-agreement check and the insert that
+// follows it cannot interleave with a concurrent creator.
+const lockReferralSQL = `SELECT pg_advisory_xact_lock(hashtext('agreement-referral:' || $1))`
+
+// activeAgreementSQL selects the referral's active agreement; run it after
+// lockReferralSQL.
+const activeAgreementSQL = `
+        SELECT id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, currency, effective_at, version, created_at, updated_at, status::text
+        FROM agreements
+        WHERE referral_id = $1 AND status IN ` + activeStatuses + `
+        ORDER BY created_at
+        LIMIT 1
+    `
+
+// lockReferral takes the lock of lockReferralSQL.
+func lockReferral(ctx context.Context, tx pgx.Tx, referralID string) error {
+	if _, err := tx.Exec(ctx, lockReferralSQL, referralID); err != nil {
+		return fmt.Errorf("agreement: lock referral: %w", err)
+	}
+	return nil
+}
+
+// activeAgreement returns the referral's active agreement, or ok=false when
+// there is none. Call it after lockReferral.
+func activeAgreement(ctx context.Context, tx pgx.Tx, referralID string) (rec Record, status string, ok bool, err error) {
+	return scanActiveAgreement(tx.QueryRow(ctx, activeAgreementSQL, referralID))
+}
+
+func scanActiveAgreement(row pgx.Row) (rec Record, status string, ok bool, err error) {
+	err = row.Scan(&rec.ID, &rec.RequestID, &rec.ReferrerBrokerID, &rec.RefereeBrokerID, &rec.FeeRate, &rec.ProtectDays,
+		&rec.Currency, &rec.EffectiveAt, &rec.Version, &rec.CreatedAt, &rec.UpdatedAt, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, "", false, nil
+	}
+	if err != nil {
+		return Record{}, "", false, fmt.Errorf("agreement: check active agreement: %w", err)
+	}
+	rec.Status = status
+	return rec, status, true, nil
+}
@@ -0,0 +1,65 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+// lockTx records the statements lockReferral and activeAgreement send.
+type lockTx struct {
+	fakeTx
+	execSQL  string
+	execArgs []any
+	querySQL string
+	rowErr   error
+}
+
+func (t *lockTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	t.execSQL, t.execArgs = sql, args
+	return pgconn.CommandTag{}, nil
+}
+
+func (t *lockTx) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	t.querySQL = sql
+	return errRow{t.rowErr}
+}
+
+func TestLockReferral_TakesTransactionLock(t *testing.T) {
+	tx := &lockTx{}
+	if err := lockReferral(context.Background(), tx, "ref-1"); err != nil {
+		t.Fatalf("lockReferral: %v", err)
+	}
+	if !strings.Contains(tx.execSQL, "pg_advisory_xact_lock") {
+		t.Fatalf("expected a transaction-scoped advisory lock, got %q", tx.execSQL)
+	}
+	if len(tx.execArgs) != 1 || tx.execArgs[0] != "ref-1" {
+		t.Fatalf("expected the referral id as the only argument, got %v", tx.execArgs)
+	}
+}
+
+func TestActiveAgreement(t *testing.T) {
+	tx := &lockTx{rowErr: pgx.ErrNoRows}
+	_, _, ok, err := activeAgreement(context.Background(), tx, "ref-1")
+	if err != nil || ok {
+		t.Fatalf("expected no active agreement, got ok=%v err=%v", ok, err)
+	}
+	// Drafts count: two drafts on one referral would compete once signed.
+	if !strings.Contains(tx.querySQL, "'draft'") {
+		t.Fatalf("expected drafts to count as active, got %q", tx.querySQL)
+	}
+
+	boom := errors.New("boom")
+	tx = &lockTx{rowErr: boom}
+	if _, _, _, err := activeAgreement(context.Background(), tx, "ref-1"); !errors.Is(err, boom) {
+		t.Fatalf("expected wrapped query error, got %v", err)
+	}
+}
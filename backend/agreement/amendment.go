@@ -0,0 +1,275 @@
+package agreement
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"brokerflow/broker"
+	"brokerflow/timeline"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AmendmentStatus is the lifecycle state of a proposed change of terms.
+type AmendmentStatus string
+
+const (
+	AmendmentProposed AmendmentStatus = "proposed"
+	AmendmentAccepted AmendmentStatus = "accepted"
+	AmendmentRejected AmendmentStatus = "rejected"
+)
+
+// Amendment is a proposal to change an agreement's fee rate and protect days.
+type Amendment struct {
+	ID                 string
+	AgreementID        string
+	ProposedByUserID   string
+	ProposedByBrokerID string
+	FeeRate            float64
+	ProtectDays        int
+	Note               *string
+	Status             AmendmentStatus
+	RespondedByUserID  *string
+	RespondedAt        *time.Time
+	CreatedAt          time.Time
+}
+
+type ProposeAmendmentParams struct {
+	AgreementID string
+	ActorID     string
+	FeeRate     float64
+	ProtectDays int
+	Note        *string
+}
+
+type RespondAmendmentParams struct {
+	AgreementID string
+	AmendmentID string
+	ActorID     string
+	Decision    AmendmentStatus
+}
+
+var (
+	ErrAmendmentNotFound       = errors.New("agreement: amendment not found")
+	ErrAmendmentNotNegotiable  = errors.New("agreement: terms can only be amended while pending signature")
+	ErrAmendmentOpen           = errors.New("agreement: another amendment is awaiting a response")
+	ErrAmendmentClosed         = errors.New("agreement: amendment already answered")
+	ErrAmendmentOwnProposal    = errors.New("agreement: the counterparty must answer the amendment")
+	ErrAmendmentInvalidTerms   = errors.New("agreement: fee rate must be in (0, 100] and protect days positive")
+	ErrAmendmentInvalidOutcome = errors.New("agreement: decision must be accepted or rejected")
+	ErrAmendmentOutOfBounds    = errors.New("agreement: terms are outside the referring broker's policy")
+)
+
+// AmendmentService runs the renegotiation flow. Each step appends a timeline
+// event and an outbox message in the same transaction as the state change.
+type AmendmentService struct {
+	pool TxBeginner
+}
+
+func NewAmendmentService(pool TxBeginner) *AmendmentService {
+	return &AmendmentService{pool: pool}
+}
+
+const amendmentColumns = `id::text, agreement_id::text, proposed_by_user_id::text, proposed_by_broker_id::text,
+       fee_rate, protect_days, note, status, responded_by_user_id::text, responded_at, created_at`
+
+func scanAmendment(row pgx.Row) (Amendment, error) {
+	var a Amendment
+	err := row.Scan(&a.ID, &a.AgreementID, &a.ProposedByUserID, &a.ProposedByBrokerID,
+		&a.FeeRate, &a.ProtectDays, &a.Note, &a.Status, &a.RespondedByUserID, &a.RespondedAt, &a.CreatedAt)
+	return a, err
+}
+
+// agreementParties locks the agreement and returns its status together with
+// the broker the actor acts for. ErrNotParty is returned when the actor
+// belongs to neither broker party.
+func agreementParties(ctx context.Context, tx pgx.Tx, agreementID, actorID string) (status, fromBroker, toBroker, actorBroker string, err error) {
+	var from, to sql.NullString
+	if err = tx.QueryRow(ctx, `SELECT status::text, from_broker_id::text, to_broker_id::text FROM agreements WHERE id = $1 FOR UPDATE`, agreementID).
+		Scan(&status, &from, &to); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", "", "", ErrAgreementNotFound
+		}
+		return "", "", "", "", fmt.Errorf("agreement: lock agreement: %w", err)
+	}
+	var own sql.NullString
+	if err = tx.QueryRow(ctx, `SELECT broker_id::text FROM users WHERE id = $1`, actorID).Scan(&own); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", "", "", fmt.Errorf("agreement: load actor broker: %w", err)
+	}
+	if !own.Valid || (own.String != from.String && own.String != to.String) {
+		return "", "", "", "", ErrNotParty
+	}
+	return status, from.String, to.String, own.String, nil
+}
+
+// Propose opens an amendment on a pending_signature agreement.
+func (s *AmendmentService) Propose(ctx context.Context, params ProposeAmendmentParams) (Amendment, error) {
+	if params.AgreementID == "" || params.ActorID == "" {
+		return Amendment{}, fmt.Errorf("agreement: amendment requires agreement and actor")
+	}
+	if params.FeeRate <= 0 || params.FeeRate > 100 || params.ProtectDays <= 0 {
+		return Amendment{}, ErrAmendmentInvalidTerms
+	}
+	var note *string
+	if params.Note != nil {
+		if trimmed := strings.TrimSpace(*params.Note); trimmed != "" {
+			note = &trimmed
+		}
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Amendment{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, from, _, actorBroker, err := agreementParties(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return Amendment{}, err
+	}
+	if status != "pending_signature" {
+		return Amendment{}, ErrAmendmentNotNegotiable
+	}
+	policy, err := broker.LoadSettings(ctx, tx, from)
+	if err != nil {
+		return Amendment{}, fmt.Errorf("agreement: load referral policy: %w", err)
+	}
+	if err := policy.Check(params.FeeRate, params.ProtectDays); err != nil {
+		return Amendment{}, fmt.Errorf("%w: %w", ErrAmendmentOutOfBounds, err)
+	}
+
+	am, err := scanAmendment(tx.QueryRow(ctx, `
+        INSERT INTO agreement_amendments (agreement_id, proposed_by_user_id, proposed_by_broker_id, fee_rate, protect_days, note)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+amendmentColumns,
+		params.AgreementID, params.ActorID, actorBroker, params.FeeRate, params.ProtectDays, note))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return Amendment{}, ErrAmendmentOpen
+		}
+		return Amendment{}, fmt.Errorf("agreement: insert amendment: %w", err)
+	}
+
+	if err := recordAmendmentStep(ctx, tx, am, params.ActorID, timeline.TypeAmendmentProposed, OutboxTopicAgreementAmendmentProposed); err != nil {
+		return Amendment{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Amendment{}, fmt.Errorf("agreement: commit amendment: %w", err)
+	}
+	return am, nil
+}
+
+// Respond accepts or rejects an open amendment. Only the broker that did not
+// propose it may answer; accepting applies the terms to the agreement.
+func (s *AmendmentService) Respond(ctx context.Context, params RespondAmendmentParams) (Amendment, error) {
+	if params.Decision != AmendmentAccepted && params.Decision != AmendmentRejected {
+		return Amendment{}, ErrAmendmentInvalidOutcome
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Amendment{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, _, _, actorBroker, err := agreementParties(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return Amendment{}, err
+	}
+
+	am, err := scanAmendment(tx.QueryRow(ctx, `SELECT `+amendmentColumns+` FROM agreement_amendments WHERE id = $1 AND agreement_id = $2 FOR UPDATE`,
+		params.AmendmentID, params.AgreementID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Amendment{}, ErrAmendmentNotFound
+		}
+		return Amendment{}, fmt.Errorf("agreement: load amendment: %w", err)
+	}
+	if am.Status != AmendmentProposed {
+		return Amendment{}, ErrAmendmentClosed
+	}
+	if am.ProposedByBrokerID == actorBroker {
+		return Amendment{}, ErrAmendmentOwnProposal
+	}
+
+	am, err = scanAmendment(tx.QueryRow(ctx, `
+        UPDATE agreement_amendments
+        SET status = $2, responded_by_user_id = $3, responded_at = get_tx_timestamp()
+        WHERE id = $1
+        RETURNING `+amendmentColumns,
+		am.ID, params.Decision, params.ActorID))
+	if err != nil {
+		return Amendment{}, fmt.Errorf("agreement: answer amendment: %w", err)
+	}
+
+	eventType, topic := timeline.TypeAmendmentRejected, OutboxTopicAgreementAmendmentRejected
+	if params.Decision == AmendmentAccepted {
+		eventType, topic = timeline.TypeAmendmentAccepted, OutboxTopicAgreementAmendmentAccepted
+		// Signatures were given to the old terms.
+		if _, err := tx.Exec(ctx, `
+        UPDATE agreements
+        SET fee_rate = $2, protect_days = $3,
+            referrer_signed_at = NULL, referrer_signed_by = NULL, referee_signed_at = NULL, referee_signed_by = NULL
+        WHERE id = $1`,
+			am.AgreementID, am.FeeRate, am.ProtectDays); err != nil {
+			return Amendment{}, fmt.Errorf("agreement: apply amendment: %w", err)
+		}
+	}
+	if err := recordAmendmentStep(ctx, tx, am, params.ActorID, eventType, topic); err != nil {
+		return Amendment{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Amendment{}, fmt.Errorf("agreement: commit amendment response: %w", err)
+	}
+	return am, nil
+}
+
+// List returns the agreement's amendments, newest first.
+func (s *AmendmentService) List(ctx context.Context, agreementID, actorID string) ([]Amendment, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, _, _, _, err := agreementParties(ctx, tx, agreementID, actorID); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, `SELECT `+amendmentColumns+` FROM agreement_amendments WHERE agreement_id = $1 ORDER BY created_at DESC, id`, agreementID)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: list amendments: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Amendment, error) { return scanAmendment(row) })
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan amendments: %w", err)
+	}
+	return out, nil
+}
+
+func recordAmendmentStep(ctx context.Context, tx pgx.Tx, am Amendment, actorID, eventType, topic string) error {
+	payload := map[string]any{
+		"amendment_id": am.ID,
+		"fee_rate":     am.FeeRate,
+		"protect_days": am.ProtectDays,
+		"status":       am.Status,
+	}
+	if am.Note != nil {
+		payload["note"] = *am.Note
+	}
+	if err := insertTimelineEvent(ctx, tx, am.AgreementID, eventType, actorID, payload); err != nil {
+		return err
+	}
+	return enqueueOutbox(ctx, tx, topic, map[string]any{
+		"agreement_id": am.AgreementID,
+		"amendment_id": am.ID,
+		"status":       am.Status,
+		"fee_rate":     am.FeeRate,
+		"protect_days": am.ProtectDays,
+		"actor_id":     actorID,
+	})
+}
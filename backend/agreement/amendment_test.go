@@ -0,0 +1,38 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAmendmentPropose_RejectsInvalidTermsBeforeTx(t *testing.T) {
+	cases := []ProposeAmendmentParams{
+		{AgreementID: "a", ActorID: "u", FeeRate: 0, ProtectDays: 30},
+		{AgreementID: "a", ActorID: "u", FeeRate: 100.5, ProtectDays: 30},
+		{AgreementID: "a", ActorID: "u", FeeRate: 25, ProtectDays: 0},
+	}
+	for _, params := range cases {
+		pool := &fakePool{}
+		_, err := NewAmendmentService(pool).Propose(context.Background(), params)
+		if !errors.Is(err, ErrAmendmentInvalidTerms) {
+			t.Errorf("%+v: expected ErrAmendmentInvalidTerms, got %v", params, err)
+		}
+		if pool.tx != nil {
+			t.Errorf("%+v: expected no transaction for invalid terms", params)
+		}
+	}
+}
+
+func TestAmendmentRespond_RejectsUnknownDecision(t *testing.T) {
+	pool := &fakePool{}
+	_, err := NewAmendmentService(pool).Respond(context.Background(), RespondAmendmentParams{
+		AgreementID: "a", AmendmentID: "m", ActorID: "u", Decision: AmendmentProposed,
+	})
+	if !errors.Is(err, ErrAmendmentInvalidOutcome) {
+		t.Fatalf("expected ErrAmendmentInvalidOutcome, got %v", err)
+	}
+	if pool.tx != nil {
+		t.Fatal("expected no transaction for an invalid decision")
+	}
+}
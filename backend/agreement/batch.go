@@ -0,0 +1,119 @@
+package agreement
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"brokerflow/timeline"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// batch queues statements that do not need each other's results in Go, so
+// they reach the server in a single round trip. Like pgx.Batch, but each
+// result is read in order when the batch is sent, and the first failing
+// statement or reader stops the rest.
+type batch struct {
+	pgx.Batch
+	reads []func(pgx.BatchResults) error
+}
+
+// queuedStatement is a statement queued on a batch.
+type queuedStatement struct {
+	b *batch
+	i int
+}
+
+// Queue queues sql; only its error is checked.
+func (b *batch) Queue(sql string, args ...any) queuedStatement {
+	b.Batch.Queue(sql, args...)
+	b.reads = append(b.reads, func(br pgx.BatchResults) error {
+		_, err := br.Exec()
+		return err
+	})
+	return queuedStatement{b: b, i: len(b.reads) - 1}
+}
+
+// QueryRow reads the statement's row with fn.
+func (q queuedStatement) QueryRow(fn func(row pgx.Row) error) {
+	q.b.reads[q.i] = func(br pgx.BatchResults) error { return fn(br.QueryRow()) }
+}
+
+// send runs b on tx, naming op in the error of the statement that failed.
+func (b *batch) send(ctx context.Context, tx pgx.Tx, op string) error {
+	br := tx.SendBatch(ctx, &b.Batch)
+	for _, read := range b.reads {
+		if err := read(br); err != nil {
+			br.Close()
+			return fmt.Errorf("agreement: %s: %w", op, err)
+		}
+	}
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("agreement: %s: %w", op, err)
+	}
+	return nil
+}
+
+// insertTimelineSQL appends an event at the seq claimSlotSQL just claimed,
+// which the agreement row holds until the transaction ends.
+const insertTimelineSQL = `
+        INSERT INTO timeline_events (agreement_id, seq, type, payload, payload_version, actor_id, actor_broker_id)
+        SELECT id, event_seq, $2::event_type, $3::jsonb, $4, $5::uuid, NULLIF(current_setting('app.broker_id', true), '')::uuid
+        FROM agreements
+        WHERE id = $1
+    `
+
+const insertOutboxSQL = `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`
+
+// insertTimelineEvent appends an event to the agreement's timeline in one
+// round trip.
+func insertTimelineEvent(ctx context.Context, tx pgx.Tx, agreementID string, eventType string, actorID string, payload map[string]any) error {
+	var b batch
+	if err := queueTimelineEvent(&b, agreementID, eventType, actorID, payload); err != nil {
+		return err
+	}
+	return b.send(ctx, tx, "append timeline event")
+}
+
+// queueTimelineEvent queues claiming the agreement's next timeline slot and
+// the event insert on b. A claim that finds no agreement or no broker to
+// record the event under fails with ErrAgreementNotFound or
+// ErrBrokerContextMissing before the insert runs.
+func queueTimelineEvent(b *batch, agreementID string, eventType string, actorID string, payload map[string]any) error {
+	body, version, err := timeline.Encode(eventType, payload)
+	if err != nil {
+		return fmt.Errorf("agreement: encode timeline payload: %w", err)
+	}
+	var actor any
+	if actorID != "" {
+		actor = actorID
+	}
+	b.Queue(claimSlotSQL, agreementID, actorID).QueryRow(func(row pgx.Row) error {
+		_, err := scanTimelineSlot(row, agreementID)
+		return err
+	})
+	b.Queue(insertTimelineSQL, agreementID, eventType, body, version, actor)
+	return nil
+}
+
+func enqueueOutbox(ctx context.Context, tx pgx.Tx, topic string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("agreement: marshal outbox payload: %w", err)
+	}
+	if _, err := tx.Exec(ctx, insertOutboxSQL, topic, body); err != nil {
+		return fmt.Errorf("agreement: enqueue outbox: %w", err)
+	}
+	return nil
+}
+
+// queueOutbox queues an outbox message on b.
+func queueOutbox(b *batch, topic string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("agreement: marshal outbox payload: %w", err)
+	}
+	b.Queue(insertOutboxSQL, topic, body)
+	return nil
+}
@@ -0,0 +1,228 @@
+package agreement
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/money"
+	"brokerflow/timeline"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error { return f(dest...) }
+
+// values scans vals into a row's destinations, allocating pointer
+// destinations as needed; a nil value leaves the destination zero.
+func values(vals ...any) scanFunc {
+	return func(dest ...any) error {
+		for i, d := range dest {
+			v := reflect.ValueOf(d).Elem()
+			if vals[i] == nil {
+				v.Set(reflect.Zero(v.Type()))
+				continue
+			}
+			val := reflect.ValueOf(vals[i])
+			if v.Kind() == reflect.Pointer && val.Kind() != reflect.Pointer {
+				p := reflect.New(v.Type().Elem())
+				p.Elem().Set(val)
+				val = p
+			}
+			v.Set(val.Convert(v.Type()))
+		}
+		return nil
+	}
+}
+
+// scriptTx answers each statement with the row registered under a fragment
+// of its SQL (no rows when none matches) and counts round trips: a statement
+// sent on its own and a whole batch count one each.
+type scriptTx struct {
+	fakeTx
+	rows       map[string]scanFunc
+	roundTrips int
+	statements int
+	// cancelOn, when it matches a statement, calls cancel once that
+	// statement is answered; later statements are recorded in afterCancel.
+	cancelOn    string
+	cancel      context.CancelFunc
+	cancelled   bool
+	afterCancel []string
+}
+
+func (t *scriptTx) answer(sql string) pgx.Row {
+	t.statements++
+	if t.cancelled {
+		t.afterCancel = append(t.afterCancel, sql)
+	}
+	if t.cancelOn != "" && strings.Contains(sql, t.cancelOn) {
+		t.cancel()
+		t.cancelled = true
+	}
+	for fragment, row := range t.rows {
+		if strings.Contains(sql, fragment) {
+			return row
+		}
+	}
+	return errRow{pgx.ErrNoRows}
+}
+
+func (t *scriptTx) Begin(context.Context) (pgx.Tx, error) { return t, nil }
+
+func (t *scriptTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	t.roundTrips++
+	t.answer(sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (t *scriptTx) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	t.roundTrips++
+	return t.answer(sql)
+}
+
+func (t *scriptTx) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	t.roundTrips++
+	return &scriptBatch{tx: t, queued: b.QueuedQueries}
+}
+
+type scriptBatch struct {
+	tx     *scriptTx
+	queued []*pgx.QueuedQuery
+	next   int
+}
+
+func (b *scriptBatch) pop() string {
+	sql := b.queued[b.next].SQL
+	b.next++
+	return sql
+}
+
+func (b *scriptBatch) Exec() (pgconn.CommandTag, error) {
+	b.tx.answer(b.pop())
+	return pgconn.CommandTag{}, nil
+}
+
+func (b *scriptBatch) Query() (pgx.Rows, error) { panic("not implemented") }
+
+func (b *scriptBatch) QueryRow() pgx.Row { return b.tx.answer(b.pop()) }
+
+func (b *scriptBatch) Close() error { return nil }
+
+func acceptanceTx() *scriptTx {
+	now := time.Now()
+	return &scriptTx{rows: map[string]scanFunc{
+		"FROM referral_matches":           values("ref-1", "cand-1", "accepted"),
+		"candidate.broker_id":             values("owner-1", "b1", "b2", "open", now),
+		"INSERT INTO agreements":          values("a-1", "ref-1", "b1", "b2", 25.0, 90, money.Currency("USD"), nil, StatusPendingSignature, now, nil, 1, now, now),
+		"UPDATE agreements SET event_seq": values(int64(1), "b2"),
+	}}
+}
+
+var acceptance = MatchAcceptanceParams{MatchID: "m-1", RequestID: "ref-1", CandidateUserID: "cand-1", AcceptedByUserID: "cand-1"}
+
+func TestCreateFromMatch_TwoRoundTrips(t *testing.T) {
+	tx := acceptanceTx()
+	rec, err := NewRepository().CreateFromMatch(context.Background(), tx, acceptance)
+	if err != nil {
+		t.Fatalf("CreateFromMatch: %v", err)
+	}
+	if rec.ID != "a-1" || rec.RefereeBrokerID != "b2" || rec.Status != StatusPendingSignature || rec.StatusUpdatedAt.IsZero() {
+		t.Fatalf("unexpected agreement %+v", rec)
+	}
+	if tx.roundTrips != 2 {
+		t.Fatalf("expected a read and a write round trip, got %d for %d statements", tx.roundTrips, tx.statements)
+	}
+}
+
+func TestCreateFromMatch_ChecksBeforeWriting(t *testing.T) {
+	tx := acceptanceTx()
+	tx.rows["FROM referral_matches"] = values("ref-1", "cand-1", "invited")
+	if _, err := NewRepository().CreateFromMatch(context.Background(), tx, acceptance); err == nil {
+		t.Fatal("expected an unaccepted match to be refused")
+	}
+	if tx.roundTrips != 1 {
+		t.Fatalf("expected to stop after the reads, got %d round trips", tx.roundTrips)
+	}
+
+	tx = acceptanceTx()
+	tx.rows["status IN"] = values("a-0", "ref-1", "b1", "b3", 25.0, 90, money.Currency("USD"), nil, 1, time.Now(), time.Now(), "pending_signature")
+	if _, err := NewRepository().CreateFromMatch(context.Background(), tx, acceptance); !errors.Is(err, ErrActiveAgreementExists) {
+		t.Fatalf("expected ErrActiveAgreementExists, got %v", err)
+	}
+}
+
+func TestInsertTimelineEvent_BrokerContextMissing(t *testing.T) {
+	tx := &scriptTx{rows: map[string]scanFunc{"UPDATE agreements SET event_seq": values(int64(4), "")}}
+	err := insertTimelineEvent(context.Background(), tx, "a-1", timeline.TypeAgreementStatusChanged, "u-9", map[string]any{"previous_status": StatusDraft, "next_status": StatusPendingSignature})
+	if !errors.Is(err, ErrBrokerContextMissing) {
+		t.Fatalf("expected ErrBrokerContextMissing, got %v", err)
+	}
+	if tx.roundTrips != 1 {
+		t.Fatalf("expected one round trip, got %d", tx.roundTrips)
+	}
+}
+
+func transitionTx() *scriptTx {
+	return &scriptTx{rows: map[string]scanFunc{
+		"FOR UPDATE":                      values(StatusPendingSignature, 3, sql.NullString{String: "b1", Valid: true}, sql.NullString{String: "b2", Valid: true}, true),
+		"RETURNING version":               values(4),
+		"UPDATE agreements SET event_seq": values(int64(2), "b1"),
+	}}
+}
+
+func TestTransition_BatchesItsWrites(t *testing.T) {
+	tx := transitionTx()
+	version, err := NewStatusService(tx).Transition(context.Background(), TransitionParams{
+		AgreementID: "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70",
+		ActorID:     "u-1",
+		NextStatus:  StatusEffective,
+	})
+	if err != nil || version != 4 {
+		t.Fatalf("got version %d, %v", version, err)
+	}
+	// SET TRANSACTION, the status read, then every write in one batch.
+	if tx.roundTrips != 3 || !tx.committed {
+		t.Fatalf("expected 3 round trips and a commit, got %d (committed=%v)", tx.roundTrips, tx.committed)
+	}
+}
+
+// The benchmarks report round trips against statements per call: the gap
+// is the latency batching saves, one network round trip per statement.
+
+func BenchmarkCreateFromMatch(b *testing.B) {
+	repo := NewRepository()
+	var trips, statements int
+	for i := 0; i < b.N; i++ {
+		tx := acceptanceTx()
+		if _, err := repo.CreateFromMatch(context.Background(), tx, acceptance); err != nil {
+			b.Fatal(err)
+		}
+		trips += tx.roundTrips
+		statements += tx.statements
+	}
+	b.ReportMetric(float64(trips)/float64(b.N), "roundtrips/op")
+	b.ReportMetric(float64(statements)/float64(b.N), "statements/op")
+}
+
+func BenchmarkTransition(b *testing.B) {
+	params := TransitionParams{AgreementID: "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70", ActorID: "u-1", NextStatus: StatusEffective}
+	var trips, statements int
+	for i := 0; i < b.N; i++ {
+		tx := transitionTx()
+		if _, err := NewStatusService(tx).Transition(context.Background(), params); err != nil {
+			b.Fatal(err)
+		}
+		trips += tx.roundTrips
+		statements += tx.statements
+	}
+	b.ReportMetric(float64(trips)/float64(b.N), "roundtrips/op")
+	b.ReportMetric(float64(statements)/float64(b.N), "statements/op")
+}
@@ -0,0 +1,139 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxCancelReasonLength bounds the free-text reason given when cancelling.
+const MaxCancelReasonLength = 1000
+
+var (
+	ErrCancelReasonRequired = errors.New("agreement: a cancellation reason is required")
+	ErrCancelReasonTooLong  = errors.New("agreement: cancellation reason is too long")
+	// ErrNotCancellable is returned once the agreement is effective or
+	// already ended; only draft and pending_signature agreements cancel.
+	ErrNotCancellable = errors.New("agreement: only agreements awaiting signature can be cancelled")
+	// ErrCancelViaTransition is returned by StatusService.Transition, which
+	// cannot record the reason a cancellation requires.
+	ErrCancelViaTransition = errors.New("agreement: cancel agreements through the cancel endpoint")
+)
+
+type CancelParams struct {
+	AgreementID string
+	ActorID     string
+	Reason      string
+}
+
+// Cancellation is the outcome of a successful cancel.
+type Cancellation struct {
+	AgreementID    string
+	ReferralID     string
+	PreviousStatus string
+	Reason         string
+	CancelledBy    string
+	CancelledAt    time.Time
+	// ReferralReopened is true when the referral went from matched back to
+	// open and can be matched again.
+	ReferralReopened bool
+}
+
+// CancelService backs out of agreements that have not taken effect. Like
+// the other agreement flows it writes the status change, a timeline event
+// and an outbox message in one transaction.
+type CancelService struct {
+	pool     TxBeginner
+	observer TransitionObserver
+	machine  *StateMachine
+}
+
+func NewCancelService(pool TxBeginner) *CancelService {
+	return &CancelService{pool: pool, observer: noopObserver{}, machine: DefaultStateMachine()}
+}
+
+// WithObserver registers a hook notified after each committed cancellation.
+func (s *CancelService) WithObserver(o TransitionObserver) *CancelService {
+	s.observer = observerOrNoop(o)
+	return s
+}
+
+// Cancel moves a draft or pending_signature agreement to cancelled on behalf
+// of a user at either broker party, and re-opens the underlying referral.
+func (s *CancelService) Cancel(ctx context.Context, params CancelParams) (Cancellation, error) {
+	reason := strings.TrimSpace(params.Reason)
+	if reason == "" {
+		return Cancellation{}, ErrCancelReasonRequired
+	}
+	if len([]rune(reason)) > MaxCancelReasonLength {
+		return Cancellation{}, ErrCancelReasonTooLong
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Cancellation{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, _, _, _, err := agreementParties(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return Cancellation{}, err
+	}
+	effect, err := s.machine.Effect(status, StatusCancelled)
+	if err != nil || status == StatusCancelled {
+		return Cancellation{}, ErrNotCancellable
+	}
+
+	c := Cancellation{AgreementID: params.AgreementID, PreviousStatus: status, Reason: reason, CancelledBy: params.ActorID}
+	if err := tx.QueryRow(ctx, `
+        UPDATE agreements
+        SET status = 'cancelled',
+            cancel_reason = $2,
+            cancelled_at = get_tx_timestamp(),
+            cancelled_by = $3::uuid,
+            status_updated_at = get_tx_timestamp(),
+            status_updated_by = $3::uuid,
+            updated_at = get_tx_timestamp()
+        WHERE id = $1
+        RETURNING referral_id::text, cancelled_at
+    `, params.AgreementID, reason, params.ActorID).Scan(&c.ReferralID, &c.CancelledAt); err != nil {
+		return Cancellation{}, fmt.Errorf("agreement: cancel: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+        UPDATE referral_requests
+        SET status = 'open', updated_at = get_tx_timestamp()
+        WHERE id = $1 AND status = 'matched'
+    `, c.ReferralID)
+	if err != nil {
+		return Cancellation{}, fmt.Errorf("agreement: reopen referral: %w", err)
+	}
+	c.ReferralReopened = tag.RowsAffected() > 0
+
+	if err := insertTimelineEvent(ctx, tx, c.AgreementID, effect.TimelineType, params.ActorID, map[string]any{
+		"previous_status":   status,
+		"reason":            reason,
+		"referral_reopened": c.ReferralReopened,
+	}); err != nil {
+		return Cancellation{}, err
+	}
+	if err := enqueueOutbox(ctx, tx, effect.OutboxTopic, map[string]any{
+		"agreement_id":      c.AgreementID,
+		"referral_id":       c.ReferralID,
+		"previous":          status,
+		"reason":            reason,
+		"cancelled_by":      params.ActorID,
+		"cancelled_at":      c.CancelledAt.UTC(),
+		"referral_reopened": c.ReferralReopened,
+	}); err != nil {
+		return Cancellation{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Cancellation{}, fmt.Errorf("agreement: commit cancel: %w", err)
+	}
+	s.observer.ObserveTransition(status, StatusCancelled)
+	return c, nil
+}
@@ -0,0 +1,36 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCancel_ValidatesReasonBeforeTx(t *testing.T) {
+	cases := []struct {
+		reason string
+		want   error
+	}{
+		{"", ErrCancelReasonRequired},
+		{"   \n\t", ErrCancelReasonRequired},
+		{strings.Repeat("x", MaxCancelReasonLength+1), ErrCancelReasonTooLong},
+	}
+	for _, tc := range cases {
+		pool := &fakePool{}
+		_, err := NewCancelService(pool).Cancel(context.Background(), CancelParams{AgreementID: "a", ActorID: "u", Reason: tc.reason})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("reason %q: expected %v, got %v", tc.reason, tc.want, err)
+		}
+		if pool.tx != nil {
+			t.Errorf("reason %q: expected no transaction", tc.reason)
+		}
+	}
+}
+
+func TestTransition_RefusesCancelledStatus(t *testing.T) {
+	_, err := NewStatusService(nil).Transition(context.Background(), TransitionParams{AgreementID: "a", ActorID: "u", NextStatus: StatusCancelled})
+	if !errors.Is(err, ErrCancelViaTransition) {
+		t.Fatalf("expected ErrCancelViaTransition, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// The transactions below cancel the request's context while answering its
+// first read, as a client hanging up mid-transaction would.
+
+func TestCreateFromMatch_StopsOnceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tx := acceptanceTx()
+	tx.cancelOn, tx.cancel = "FROM referral_matches", cancel
+
+	_, err := NewRepository().CreateFromMatch(ctx, tx, acceptance)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if tx.roundTrips != 1 {
+		t.Fatalf("expected no round trip after the cancelled reads, got %d", tx.roundTrips)
+	}
+}
+
+func TestTransition_StopsOnceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tx := transitionTx()
+	tx.cancelOn, tx.cancel = "FOR UPDATE", cancel
+
+	_, err := NewStatusService(tx).Transition(ctx, TransitionParams{
+		AgreementID: "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70",
+		ActorID:     "u-1",
+		NextStatus:  StatusEffective,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(tx.afterCancel) != 0 {
+		t.Fatalf("expected the transition to stop after reading the status, ran %q", tx.afterCancel)
+	}
+	if tx.committed {
+		t.Fatal("expected the cancelled transition to roll back")
+	}
+}
@@ -0,0 +1,326 @@
+package agreement
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxClientFieldLength bounds each field of a client record.
+const MaxClientFieldLength = 200
+
+var (
+	ErrClientNameRequired    = errors.New("agreement: a client name is required")
+	ErrClientContactRequired = errors.New("agreement: a client email or phone is required")
+	ErrClientInvalidEmail    = errors.New("agreement: the client email is not a valid address")
+	ErrClientFieldTooLong    = errors.New("agreement: a client field is too long")
+	// ErrClientRecordConflict is returned when an attachment names both an
+	// existing record and new details, or neither.
+	ErrClientRecordConflict = errors.New("agreement: attach either an existing client record or new client details")
+	// ErrClientRecordNotFound is returned for a record the caller may not
+	// reuse and for a referral with no client attached.
+	ErrClientRecordNotFound = errors.New("agreement: client record not found")
+	// ErrClientAlreadyAttached is returned when the referral has a client.
+	ErrClientAlreadyAttached = errors.New("agreement: the referral already has a client record")
+	ErrReferralCancelled     = errors.New("agreement: the referral is cancelled")
+)
+
+// clientVisibleStatuses are the statuses in which an agreement opens the
+// client record of its referral to the counterparty.
+var clientVisibleStatuses = []string{StatusEffective, StatusSuccess, StatusDisputed}
+
+type AttachClientParams struct {
+	ReferralID string
+	ActorID    string
+	// Scope is whose referrals the actor may attach clients to.
+	Scope tenancy.Scope
+	// RecordID reuses a record of the actor's brokerage; otherwise Name and
+	// Email or Phone create one.
+	RecordID string
+	Name     string
+	Email    string
+	Phone    string
+}
+
+// ClientAttachment is a referral's link to its client record.
+type ClientAttachment struct {
+	ReferralID string
+	RecordID   string
+	AttachedBy string
+	AttachedAt time.Time
+	// Agreements are those the CLIENT_RECORD_ATTACHED event was appended to.
+	Agreements []string
+}
+
+// ClientRecord is a referral's client as its caller may see it. Name, Email
+// and Phone are only set when Visible.
+type ClientRecord struct {
+	ClientAttachment
+	Visible bool
+	Name    string
+	Email   string
+	Phone   string
+}
+
+// ClientService links referrals to the end client they are for. Client
+// details live in client_records, which only SECURITY DEFINER functions
+// reach; the referring brokerage always sees them, the counterparty only
+// once an agreement on the referral is in effect, and every read is
+// audited.
+type ClientService struct {
+	pool TxBeginner
+}
+
+func NewClientService(pool TxBeginner) *ClientService {
+	return &ClientService{pool: pool}
+}
+
+func (p *AttachClientParams) normalize() error {
+	p.RecordID = strings.TrimSpace(p.RecordID)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Email = strings.ToLower(strings.TrimSpace(p.Email))
+	p.Phone = strings.TrimSpace(p.Phone)
+	details := p.Name != "" || p.Email != "" || p.Phone != ""
+	if (p.RecordID == "") == !details {
+		return ErrClientRecordConflict
+	}
+	if p.RecordID != "" {
+		if _, err := uuid.Parse(p.RecordID); err != nil {
+			return ErrClientRecordNotFound
+		}
+		return nil
+	}
+	if p.Name == "" {
+		return ErrClientNameRequired
+	}
+	if p.Email == "" && p.Phone == "" {
+		return ErrClientContactRequired
+	}
+	for _, f := range []string{p.Name, p.Email, p.Phone} {
+		if len([]rune(f)) > MaxClientFieldLength {
+			return ErrClientFieldTooLong
+		}
+	}
+	if p.Email != "" {
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil || addr.Address != p.Email || addr.Name != "" {
+			return ErrClientInvalidEmail
+		}
+	}
+	return nil
+}
+
+// Attach links a referral in params.Scope to a new or reused client record
+// and appends CLIENT_RECORD_ATTACHED, which carries no client details, to
+// each of the referral's agreements that is not cancelled or void. It
+// fails with ErrClientRecordConflict, ErrClientNameRequired,
+// ErrClientContactRequired, ErrClientInvalidEmail, ErrClientFieldTooLong,
+// ErrReferralNotFound, ErrReferralCancelled, ErrClientAlreadyAttached or
+// ErrClientRecordNotFound.
+func (s *ClientService) Attach(ctx context.Context, params AttachClientParams) (ClientAttachment, error) {
+	if err := params.normalize(); err != nil {
+		return ClientAttachment{}, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Agreements created after this lock is released see the attachment on
+	// the referral; those created before it get the event.
+	if err := lockReferral(ctx, tx, params.ReferralID); err != nil {
+		return ClientAttachment{}, err
+	}
+	owned, arg := params.Scope.OwnedBy("rr.created_by_user_id", 2)
+	var (
+		status   string
+		attached sql.NullString
+	)
+	err = tx.QueryRow(ctx, `
+        SELECT rr.status, rr.client_record_id::text
+        FROM referral_requests rr
+        WHERE rr.id = $1 AND `+owned+`
+        FOR UPDATE
+    `, params.ReferralID, arg).Scan(&status, &attached)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ClientAttachment{}, ErrReferralNotFound
+	}
+	if err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: lock referral: %w", err)
+	}
+	if status == "cancelled" {
+		return ClientAttachment{}, ErrReferralCancelled
+	}
+	if attached.Valid {
+		return ClientAttachment{}, ErrClientAlreadyAttached
+	}
+
+	a := ClientAttachment{ReferralID: params.ReferralID, RecordID: params.RecordID, AttachedBy: params.ActorID}
+	if a.RecordID != "" {
+		var reusable bool
+		if err := tx.QueryRow(ctx, `SELECT client_record_reusable($1::uuid, $2::uuid)`, a.RecordID, params.ActorID).Scan(&reusable); err != nil {
+			return ClientAttachment{}, fmt.Errorf("agreement: check client record: %w", err)
+		}
+		if !reusable {
+			return ClientAttachment{}, ErrClientRecordNotFound
+		}
+	} else if err := tx.QueryRow(ctx, `SELECT create_client_record($1::uuid, $2, NULLIF($3, ''), NULLIF($4, ''))::text`,
+		params.ActorID, params.Name, params.Email, params.Phone).Scan(&a.RecordID); err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: create client record: %w", err)
+	}
+
+	if err := tx.QueryRow(ctx, `
+        UPDATE referral_requests
+        SET client_record_id = $2::uuid,
+            client_attached_at = get_tx_timestamp(),
+            client_attached_by = $3::uuid
+        WHERE id = $1
+        RETURNING client_attached_at
+    `, a.ReferralID, a.RecordID, params.ActorID).Scan(&a.AttachedAt); err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: attach client record: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT id::text FROM agreements
+        WHERE referral_id = $1 AND status NOT IN ('cancelled', 'void')
+        ORDER BY created_at
+    `, a.ReferralID)
+	if err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: list referral agreements: %w", err)
+	}
+	if a.Agreements, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: scan referral agreements: %w", err)
+	}
+	for _, id := range a.Agreements {
+		if err := insertTimelineEvent(ctx, tx, id, timeline.TypeClientRecordAttached, params.ActorID, map[string]any{
+			"referral_id":      a.ReferralID,
+			"client_record_id": a.RecordID,
+			"reused":           params.RecordID != "",
+		}); err != nil {
+			return ClientAttachment{}, err
+		}
+	}
+
+	metadata, err := json.Marshal(map[string]any{
+		"referral_id":      a.ReferralID,
+		"client_record_id": a.RecordID,
+		"reused":           params.RecordID != "",
+	})
+	if err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: marshal client audit: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO audit_logs (actor_id, action, metadata)
+        VALUES ($1::uuid, 'CLIENT_RECORD_ATTACHED', $2::jsonb)
+    `, params.ActorID, metadata); err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: write client audit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ClientAttachment{}, fmt.Errorf("agreement: commit client record: %w", err)
+	}
+	return a, nil
+}
+
+// Get returns the client record of a referral. Callers in scope of the
+// referral's creator see the details; a user of the other broker party of
+// one of its agreements sees them only once that agreement is in effect,
+// and the attachment alone before. Reading the details writes a PII_READ
+// audit entry. It fails with ErrReferralNotFound for anyone else and with
+// ErrClientRecordNotFound when no client is attached.
+func (s *ClientService) Get(ctx context.Context, scope tenancy.Scope, referralID, actorID string) (ClientRecord, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return ClientRecord{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	owned, arg := scope.OwnedBy("rr.created_by_user_id", 2)
+	var (
+		referring            bool
+		recordID, attachedBy sql.NullString
+		attachedAt           *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+        SELECT `+owned+`, rr.client_record_id::text, rr.client_attached_by::text, rr.client_attached_at
+        FROM referral_requests rr
+        WHERE rr.id = $1
+    `, referralID, arg).Scan(&referring, &recordID, &attachedBy, &attachedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ClientRecord{}, ErrReferralNotFound
+	}
+	if err != nil {
+		return ClientRecord{}, fmt.Errorf("agreement: load referral client: %w", err)
+	}
+
+	visible := referring
+	if !referring {
+		var party bool
+		rows, err := tx.Query(ctx, `
+            SELECT a.status::text, a.effective_at IS NOT NULL AND a.effective_at <= get_tx_timestamp()
+            FROM agreements a
+            JOIN users u ON u.id = $2 AND u.broker_id IN (a.from_broker_id, a.to_broker_id)
+            WHERE a.referral_id = $1
+        `, referralID, actorID)
+		if err != nil {
+			return ClientRecord{}, fmt.Errorf("agreement: load referral agreements: %w", err)
+		}
+		for rows.Next() {
+			var (
+				status string
+				begun  bool
+			)
+			if err := rows.Scan(&status, &begun); err != nil {
+				rows.Close()
+				return ClientRecord{}, fmt.Errorf("agreement: scan referral agreement: %w", err)
+			}
+			party = true
+			visible = visible || (begun && slices.Contains(clientVisibleStatuses, status))
+		}
+		if err := rows.Err(); err != nil {
+			return ClientRecord{}, fmt.Errorf("agreement: load referral agreements: %w", err)
+		}
+		if !party {
+			return ClientRecord{}, ErrReferralNotFound
+		}
+	}
+	if !recordID.Valid {
+		return ClientRecord{}, ErrClientRecordNotFound
+	}
+
+	c := ClientRecord{ClientAttachment: ClientAttachment{
+		ReferralID: referralID,
+		RecordID:   recordID.String,
+		AttachedBy: attachedBy.String,
+	}, Visible: visible}
+	if attachedAt != nil {
+		c.AttachedAt = *attachedAt
+	}
+	if !visible {
+		return c, nil
+	}
+	var email, phone sql.NullString
+	if err := tx.QueryRow(ctx, `SELECT client_name, client_email, client_phone FROM get_client_record($1::uuid, $2::uuid)`, referralID, actorID).
+		Scan(&c.Name, &email, &phone); err != nil {
+		return ClientRecord{}, fmt.Errorf("agreement: read client record: %w", err)
+	}
+	c.Email, c.Phone = email.String, phone.String
+	if err := tx.Commit(ctx); err != nil {
+		return ClientRecord{}, fmt.Errorf("agreement: commit client read: %w", err)
+	}
+	return c, nil
+}
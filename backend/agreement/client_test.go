@@ -0,0 +1,47 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"brokerflow/tenancy"
+)
+
+func TestAttachClient_ValidatesBeforeTx(t *testing.T) {
+	cases := []struct {
+		name   string
+		params AttachClientParams
+		want   error
+	}{
+		{"neither", AttachClientParams{}, ErrClientRecordConflict},
+		{"both", AttachClientParams{RecordID: "7b0e4c1a-3f59-4d2e-9a61-0c8d5b2f4e17", Name: "Dana"}, ErrClientRecordConflict},
+		{"bad record id", AttachClientParams{RecordID: "c1"}, ErrClientRecordNotFound},
+		{"blank name", AttachClientParams{Name: "  ", Email: "dana@example.com"}, ErrClientNameRequired},
+		{"no contact", AttachClientParams{Name: "Dana"}, ErrClientContactRequired},
+		{"bad email", AttachClientParams{Name: "Dana", Email: "Dana <dana@example.com>"}, ErrClientInvalidEmail},
+		{"long name", AttachClientParams{Name: strings.Repeat("x", MaxClientFieldLength+1), Phone: "555"}, ErrClientFieldTooLong},
+	}
+	for _, tc := range cases {
+		pool := &fakePool{}
+		tc.params.ReferralID, tc.params.ActorID, tc.params.Scope = "r", "u", tenancy.User("u")
+		_, err := NewClientService(pool).Attach(context.Background(), tc.params)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+		if pool.tx != nil {
+			t.Errorf("%s: expected no transaction", tc.name)
+		}
+	}
+}
+
+func TestAttachClientParams_Normalize(t *testing.T) {
+	p := AttachClientParams{Name: " Dana Client ", Email: " Dana@Example.com ", Phone: " "}
+	if err := p.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Dana Client" || p.Email != "dana@example.com" || p.Phone != "" {
+		t.Fatalf("unexpected normalized params %+v", p)
+	}
+}
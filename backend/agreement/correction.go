@@ -0,0 +1,231 @@
+package agreement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+// MaxJustificationLength bounds the justification of a status correction.
+const MaxJustificationLength = 2000
+
+var (
+	ErrJustificationRequired = errors.New("agreement: a justification is required")
+	ErrJustificationTooLong  = errors.New("agreement: justification is too long")
+	// ErrNotCorrectable is returned when the event named for correction is
+	// not the agreement's latest status change. Later changes must be
+	// corrected first, newest first.
+	ErrNotCorrectable = errors.New("agreement: only the latest status change of an agreement can be corrected")
+	// ErrCorrectionNoop is returned for a correction to the status the
+	// agreement is already in.
+	ErrCorrectionNoop = errors.New("agreement: the agreement already has that status")
+)
+
+type CorrectionParams struct {
+	AgreementID string
+	ActorID     string
+	// EventID is the timeline event that set the wrong status.
+	EventID       int64
+	Status        string
+	Justification string
+}
+
+// Correction is the outcome of a successful status correction.
+type Correction struct {
+	AgreementID string
+	// EventID is the AGREEMENT_STATUS_CORRECTED event appended.
+	EventID         int64
+	CorrectsEventID int64
+	PreviousStatus  string
+	Status          string
+	EffectiveAt     *time.Time
+	Justification   string
+	CorrectedBy     string
+	CorrectedAt     time.Time
+}
+
+// CorrectionService lets broker admins repair an agreement moved to the
+// wrong status. History is never rewritten: the correction is a
+// compensating AGREEMENT_STATUS_CORRECTED event naming the erroneous one,
+// and the status and effective_at are then replayed from the timeline the
+// way Rebuilder derives them. The target status need not be reachable in
+// the StateMachine, which is what makes it a correction.
+type CorrectionService struct {
+	pool     TxBeginner
+	observer TransitionObserver
+	machine  *StateMachine
+}
+
+func NewCorrectionService(pool TxBeginner) *CorrectionService {
+	return &CorrectionService{pool: pool, observer: noopObserver{}, machine: DefaultStateMachine()}
+}
+
+// WithObserver registers a hook notified after each committed correction.
+func (s *CorrectionService) WithObserver(o TransitionObserver) *CorrectionService {
+	s.observer = observerOrNoop(o)
+	return s
+}
+
+// Correct appends a correction of params.EventID, the agreement's latest
+// status change, on behalf of a broker admin at either broker party,
+// re-derives the agreement's status from its timeline and records the
+// justification in audit_logs, all in one transaction. It fails with
+// ErrJustificationRequired, ErrJustificationTooLong, ErrUnknownStatus,
+// ErrCancelViaTransition, ErrAgreementNotFound, ErrNotParty,
+// ErrNotCorrectable, ErrCorrectionNoop or ErrSignaturesMissing.
+func (s *CorrectionService) Correct(ctx context.Context, params CorrectionParams) (Correction, error) {
+	justification := strings.TrimSpace(params.Justification)
+	if justification == "" {
+		return Correction{}, ErrJustificationRequired
+	}
+	if len([]rune(justification)) > MaxJustificationLength {
+		return Correction{}, ErrJustificationTooLong
+	}
+	if !s.machine.Known(params.Status) {
+		return Correction{}, fmt.Errorf("%w %q", ErrUnknownStatus, params.Status)
+	}
+	if params.Status == StatusCancelled {
+		// Cancelling records a reason and re-opens the referral.
+		return Correction{}, ErrCancelViaTransition
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Correction{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current, _, _, _, err := agreementParties(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return Correction{}, err
+	}
+	events, err := loadTimeline(ctx, tx, params.AgreementID)
+	if err != nil {
+		return Correction{}, err
+	}
+	wrong, err := latestStatusChange(events)
+	if err != nil {
+		return Correction{}, err
+	}
+	if wrong == nil || wrong.ID != params.EventID {
+		return Correction{}, ErrNotCorrectable
+	}
+	if params.Status == current {
+		return Correction{}, ErrCorrectionNoop
+	}
+	if slices.Contains(statusesWithEffectiveAt, params.Status) {
+		var signed bool
+		if err := tx.QueryRow(ctx, `
+            SELECT referrer_signed_at IS NOT NULL AND referee_signed_at IS NOT NULL
+            FROM agreements WHERE id = $1
+        `, params.AgreementID).Scan(&signed); err != nil {
+			return Correction{}, fmt.Errorf("agreement: load signatures: %w", err)
+		}
+		if !signed {
+			return Correction{}, ErrSignaturesMissing
+		}
+	}
+
+	if err := insertTimelineEvent(ctx, tx, params.AgreementID, timeline.TypeStatusCorrected, params.ActorID, map[string]any{
+		"corrects_event_id": wrong.ID,
+		"corrects_type":     wrong.Type,
+		"previous_status":   current,
+		"next_status":       params.Status,
+		"justification":     justification,
+	}); err != nil {
+		return Correction{}, err
+	}
+	if events, err = loadTimeline(ctx, tx, params.AgreementID); err != nil {
+		return Correction{}, err
+	}
+	replayed, err := replayProjection(events)
+	if err != nil {
+		return Correction{}, fmt.Errorf("agreement %s: %w", params.AgreementID, err)
+	}
+	appended := events[len(events)-1]
+
+	c := Correction{
+		AgreementID:     params.AgreementID,
+		EventID:         appended.ID,
+		CorrectsEventID: wrong.ID,
+		PreviousStatus:  current,
+		Status:          replayed.Status,
+		EffectiveAt:     replayed.EffectiveAt,
+		Justification:   justification,
+		CorrectedBy:     params.ActorID,
+		CorrectedAt:     appended.At,
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE agreements
+        SET status = $2::agreement_status,
+            effective_at = $3,
+            status_updated_at = get_tx_timestamp(),
+            status_updated_by = $4::uuid,
+            updated_at = get_tx_timestamp()
+        WHERE id = $1
+    `, c.AgreementID, c.Status, c.EffectiveAt, params.ActorID); err != nil {
+		return Correction{}, fmt.Errorf("agreement: correct status: %w", err)
+	}
+	// Referrals only move forward, so a correction backwards leaves the
+	// referral where the erroneous change put it.
+	next, ok := ReferralProjector{}.ForStatus(c.Status)
+	if err := projectReferral(ctx, tx, c.AgreementID, next, ok); err != nil {
+		return Correction{}, err
+	}
+	if err := enqueueOutbox(ctx, tx, OutboxTopicAgreementStatusCorrected, map[string]any{
+		"agreement_id":      c.AgreementID,
+		"previous":          c.PreviousStatus,
+		"next":              c.Status,
+		"corrects_event_id": c.CorrectsEventID,
+		"justification":     c.Justification,
+		"corrected_by":      c.CorrectedBy,
+		"corrected_at":      c.CorrectedAt.UTC(),
+	}); err != nil {
+		return Correction{}, err
+	}
+	metadata, err := json.Marshal(map[string]any{
+		"agreement_id":      c.AgreementID,
+		"event_id":          c.EventID,
+		"corrects_event_id": c.CorrectsEventID,
+		"corrects_type":     wrong.Type,
+		"previous_status":   c.PreviousStatus,
+		"next_status":       c.Status,
+		"justification":     c.Justification,
+	})
+	if err != nil {
+		return Correction{}, fmt.Errorf("agreement: marshal correction audit: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO audit_logs (actor_id, action, metadata)
+        VALUES ($1::uuid, 'AGREEMENT_STATUS_CORRECTED', $2::jsonb)
+    `, params.ActorID, metadata); err != nil {
+		return Correction{}, fmt.Errorf("agreement: write correction audit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Correction{}, fmt.Errorf("agreement: commit correction: %w", err)
+	}
+	s.observer.ObserveTransition(c.PreviousStatus, c.Status)
+	return c, nil
+}
+
+// latestStatusChange returns the last event in events that set the
+// agreement's status, or nil when none did.
+func latestStatusChange(events []timeline.Event) (*timeline.Event, error) {
+	for i := len(events) - 1; i >= 0; i-- {
+		status, err := enteredStatus(events[i])
+		if err != nil {
+			return nil, fmt.Errorf("agreement: replay event %d: %w", events[i].ID, err)
+		}
+		if status != "" {
+			return &events[i], nil
+		}
+	}
+	return nil, nil
+}
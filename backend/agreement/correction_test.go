@@ -0,0 +1,80 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+func TestCorrect_ValidatesBeforeTx(t *testing.T) {
+	cases := []struct {
+		name          string
+		status        string
+		justification string
+		want          error
+	}{
+		{"blank justification", StatusEffective, "  \n", ErrJustificationRequired},
+		{"long justification", StatusEffective, strings.Repeat("x", MaxJustificationLength+1), ErrJustificationTooLong},
+		{"unknown status", "signed", "typo", ErrUnknownStatus},
+		{"cancelled", StatusCancelled, "typo", ErrCancelViaTransition},
+	}
+	for _, tc := range cases {
+		pool := &fakePool{}
+		_, err := NewCorrectionService(pool).Correct(context.Background(), CorrectionParams{
+			AgreementID: "a", ActorID: "u", EventID: 1, Status: tc.status, Justification: tc.justification,
+		})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+		if pool.tx != nil {
+			t.Errorf("%s: expected no transaction", tc.name)
+		}
+	}
+}
+
+func TestReplayProjection_AppliesCorrections(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	signedAt := t0.Add(26 * time.Hour)
+	events := []timeline.Event{
+		historyEvent(t, 1, timeline.TypeAgreementCreated, t0, map[string]any{"referral_id": "r1", "fee_rate": 25, "protect_days": 30}),
+		historyEvent(t, 2, timeline.TypeAgreementStatusChanged, t0.Add(time.Hour), map[string]any{"previous_status": "draft", "next_status": "pending_signature"}),
+		historyEvent(t, 3, timeline.TypeEsignCompleted, t0.Add(27*time.Hour), map[string]any{"agreement_id": "a1", "effective_at": signedAt}),
+		historyEvent(t, 4, timeline.TypeAgreementStatusChanged, t0.Add(40*time.Hour), map[string]any{"previous_status": "effective", "next_status": "void"}),
+		historyEvent(t, 5, timeline.TypeOfferMade, t0.Add(41*time.Hour), nil),
+	}
+
+	wrong, err := latestStatusChange(events)
+	if err != nil {
+		t.Fatalf("latest status change: %v", err)
+	}
+	if wrong == nil || wrong.ID != 4 {
+		t.Fatalf("expected event 4 as the latest status change, got %+v", wrong)
+	}
+
+	events = append(events, historyEvent(t, 6, timeline.TypeStatusCorrected, t0.Add(42*time.Hour), map[string]any{
+		"corrects_event_id": 4,
+		"corrects_type":     timeline.TypeAgreementStatusChanged,
+		"previous_status":   "void",
+		"next_status":       "effective",
+		"justification":     "voided the wrong agreement",
+	}))
+	got, err := replayProjection(events)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if got.Status != StatusEffective {
+		t.Fatalf("expected the correction to restore effective, got %q", got.Status)
+	}
+	// Voiding cleared effective_at, so it restarts at the correction the
+	// way any later transition back into effective would.
+	if correctedAt := t0.Add(42 * time.Hour); got.EffectiveAt == nil || !got.EffectiveAt.Equal(correctedAt) {
+		t.Fatalf("expected effective_at at the correction time %s, got %v", correctedAt, got.EffectiveAt)
+	}
+	if wrong, _ := latestStatusChange(events); wrong == nil || wrong.ID != 6 {
+		t.Fatalf("expected the correction itself to be correctable next, got %+v", wrong)
+	}
+}
@@ -3,10 +3,18 @@ package agreement
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"brokerflow/broker"
+	"brokerflow/db"
+	"brokerflow/money"
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type Record struct {
@@ -16,9 +24,27 @@ type Record struct {
 	RefereeBrokerID  string
 	FeeRate          float64
 	ProtectDays      int
-	EffectiveAt      *time.Time
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	// Currency is the referral's when the agreement was created; fees are
+	// settled in it.
+	Currency    money.Currency
+	EffectiveAt *time.Time
+	// ReferrerSignedAt and RefereeSignedAt are set as each broker party
+	// signs; the signers are nil for agreements signed before signatures
+	// were tracked.
+	ReferrerSignedAt *time.Time
+	ReferrerSignedBy *string
+	RefereeSignedAt  *time.Time
+	RefereeSignedBy  *string
+	// Status is one of the Status constants. StatusUpdatedBy is nil when
+	// the system, such as expiry, made the last change.
+	Status          string
+	StatusUpdatedAt time.Time
+	StatusUpdatedBy *string
+	// Version increases with every status, term or signature change; the
+	// API exposes it as the agreement's ETag.
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type CreateParams struct {
@@ -29,32 +55,107 @@ type CreateParams struct {
 	ProtectDays      int
 }
 
+// validate checks the fields Create needs before it touches the database.
+func (p CreateParams) validate() error {
+	switch {
+	case p.RequestID == "":
+		return fmt.Errorf("%w: request id required", ErrInvalidParams)
+	case p.ReferrerBrokerID == "" || p.RefereeBrokerID == "":
+		return fmt.Errorf("%w: broker ids required", ErrInvalidParams)
+	case p.FeeRate < 0:
+		return fmt.Errorf("%w: invalid fee rate", ErrInvalidParams)
+	case p.ProtectDays < 0:
+		return fmt.Errorf("%w: invalid protect days", ErrInvalidParams)
+	}
+	return nil
+}
+
 type ListFilters struct {
-	CreatorUserID string
+	// Scope limits results to agreements on the caller's referrals, or to
+	// every agreement the admin's brokerage is a party to.
+	Scope tenancy.Scope
+	// Statuses keeps agreements in any of the given statuses.
+	Statuses []string
+	// CounterpartyBrokerID keeps agreements the brokerage is a party to,
+	// which within the scope makes it the other side.
+	CounterpartyBrokerID string
+	ReferralID           string
+	// CreatedFrom and CreatedTo bound created_at to [CreatedFrom, CreatedTo);
+	// EffectiveFrom and EffectiveTo bound effective_at likewise, leaving out
+	// agreements that never took effect. A zero bound is open.
+	CreatedFrom   time.Time
+	CreatedTo     time.Time
+	EffectiveFrom time.Time
+	EffectiveTo   time.Time
 	Page          int
 	PageSize      int
 }
 
+// Validate rejects unknown statuses (ErrUnknownStatus), and ids that are not
+// UUIDs and empty date ranges (ErrInvalidParams). List calls it.
+func (f ListFilters) Validate() error {
+	machine := DefaultStateMachine()
+	for _, status := range f.Statuses {
+		if !machine.Known(status) {
+			return fmt.Errorf("%w %q", ErrUnknownStatus, status)
+		}
+	}
+	if _, err := uuid.Parse(f.CounterpartyBrokerID); f.CounterpartyBrokerID != "" && err != nil {
+		return fmt.Errorf("%w: invalid counterparty broker id", ErrInvalidParams)
+	}
+	if _, err := uuid.Parse(f.ReferralID); f.ReferralID != "" && err != nil {
+		return fmt.Errorf("%w: invalid referral id", ErrInvalidParams)
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return fmt.Errorf("%w: created range must end after it starts", ErrInvalidParams)
+	}
+	if !f.EffectiveFrom.IsZero() && !f.EffectiveTo.IsZero() && !f.EffectiveFrom.Before(f.EffectiveTo) {
+		return fmt.Errorf("%w: effective range must end after it starts", ErrInvalidParams)
+	}
+	return nil
+}
+
+// querier is the read side shared by DB and db.Reader.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type CRUDService struct {
-	pool *pgxpool.Pool
+	pool    DB
+	reader  querier
+	counter *db.Counter
+}
+
+func NewCRUDService(pool DB) *CRUDService {
+	return &CRUDService{pool: pool, reader: pool}
+}
+
+// WithReader runs List, GetMany and ActiveForReferrals on reader.
+func (s *CRUDService) WithReader(reader db.Reader) *CRUDService {
+	s.reader = reader
+	return s
 }
 
-func NewCRUDService(pool *pgxpool.Pool) *CRUDService {
-	return &CRUDService{pool: pool}
+// WithCounter totals lists through counter instead of an exact COUNT(*).
+func (s *CRUDService) WithCounter(counter *db.Counter) *CRUDService {
+	s.counter = counter
+	return s
 }
 
+// Create inserts a draft agreement on the caller's referral. It fails with
+// ErrInvalidParams for missing or negative fields, ErrReferralNotFound or
+// ErrNotOwner when the referral is missing or someone else's,
+// ErrReferrerBrokerMismatch when the referrer broker is not the owner's,
+// ErrActiveAgreementExists while another agreement holds the referral, and
+// with a *broker.PolicyViolation when the terms fall outside the referring
+// broker's policy.
 func (s *CRUDService) Create(ctx context.Context, userID string, params CreateParams) (Record, error) {
-	if params.RequestID == "" {
-		return Record{}, fmt.Errorf("agreement: request id required")
-	}
-	if params.ReferrerBrokerID == "" || params.RefereeBrokerID == "" {
-		return Record{}, fmt.Errorf("agreement: broker ids required")
-	}
-	if params.FeeRate < 0 {
-		return Record{}, fmt.Errorf("agreement: invalid fee rate")
+	if err := params.validate(); err != nil {
+		return Record{}, err
 	}
-	if params.ProtectDays < 0 {
-		return Record{}, fmt.Errorf("agreement: invalid protect days")
+	if _, err := uuid.Parse(params.RequestID); err != nil {
+		return Record{}, ErrReferralNotFound
 	}
 
 	tx, err := s.pool.Begin(ctx)
@@ -64,19 +165,52 @@ func (s *CRUDService) Create(ctx context.Context, userID string, params CreatePa
 	defer tx.Rollback(ctx)
 
 	var owner string
-	err = tx.QueryRow(ctx, `SELECT created_by_user_id FROM referral_requests WHERE id=$1`, params.RequestID).Scan(&owner)
+	var ownerBroker *string
+	err = tx.QueryRow(ctx, `
+        SELECT rr.created_by_user_id, u.broker_id
+        FROM referral_requests rr
+        JOIN users u ON u.id = rr.created_by_user_id
+        WHERE rr.id=$1
+    `, params.RequestID).Scan(&owner, &ownerBroker)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, ErrReferralNotFound
+	}
 	if err != nil {
 		return Record{}, fmt.Errorf("agreement: ensure referral: %w", err)
 	}
 	if owner != userID {
-		return Record{}, fmt.Errorf("agreement: referral does not belong to user")
+		return Record{}, ErrNotOwner
+	}
+	// The policy is the referrer broker's, so it must be the owner's own
+	// brokerage rather than whatever the request names.
+	if ownerBroker == nil || *ownerBroker != params.ReferrerBrokerID {
+		return Record{}, ErrReferrerBrokerMismatch
+	}
+	policy, err := broker.LoadSettings(ctx, tx, params.ReferrerBrokerID)
+	if err != nil {
+		return Record{}, fmt.Errorf("agreement: load referral policy: %w", err)
+	}
+	if err := policy.Check(params.FeeRate, params.ProtectDays); err != nil {
+		return Record{}, err
+	}
+	if err := lockReferral(ctx, tx, params.RequestID); err != nil {
+		return Record{}, err
+	}
+	_, _, exists, err := activeAgreement(ctx, tx, params.RequestID)
+	if err != nil {
+		return Record{}, err
+	}
+	if exists {
+		return Record{}, ErrActiveAgreementExists
 	}
 
 	var rec Record
 	insertSQL := `
         INSERT INTO agreements (referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, status)
         VALUES ($1,$2,$3,$4,$5,'draft')
-        RETURNING id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, effective_at, created_at, updated_at
+        RETURNING id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, currency, effective_at,
+            referrer_signed_at, referrer_signed_by::text, referee_signed_at, referee_signed_by::text, status::text, status_updated_at,
+            status_updated_by::text, version, created_at, updated_at
     `
 	if err := tx.QueryRow(ctx, insertSQL,
 		params.RequestID,
@@ -84,28 +218,27 @@ func (s *CRUDService) Create(ctx context.Context, userID string, params CreatePa
 		params.RefereeBrokerID,
 		params.FeeRate,
 		params.ProtectDays,
-	).Scan(&rec.ID, &rec.RequestID, &rec.ReferrerBrokerID, &rec.RefereeBrokerID, &rec.FeeRate, &rec.ProtectDays, &rec.EffectiveAt, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+	).Scan(&rec.ID, &rec.RequestID, &rec.ReferrerBrokerID, &rec.RefereeBrokerID, &rec.FeeRate, &rec.ProtectDays, &rec.Currency, &rec.EffectiveAt,
+		&rec.ReferrerSignedAt, &rec.ReferrerSignedBy, &rec.RefereeSignedAt, &rec.RefereeSignedBy, &rec.Status, &rec.StatusUpdatedAt,
+		&rec.StatusUpdatedBy, &rec.Version, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
 		return Record{}, fmt.Errorf("agreement: insert: %w", err)
 	}
 
-	if err := setTimelineBroker(ctx, tx, params.ReferrerBrokerID, params.RefereeBrokerID, nil); err != nil {
-		return Record{}, err
-	}
 	payload := map[string]any{
 		"referral_id":  params.RequestID,
 		"fee_rate":     params.FeeRate,
 		"protect_days": params.ProtectDays,
 	}
 
-	if _, err := tx.Exec(ctx, `INSERT INTO timeline_events (agreement_id, type, payload) VALUES ($1,'AGREEMENT_CREATED',$2::jsonb)`, rec.ID, mustJSON(payload)); err != nil {
-		return Record{}, fmt.Errorf("agreement: timeline insert: %w", err)
+	if err := insertTimelineEvent(ctx, tx, rec.ID, timeline.TypeAgreementCreated, "", payload); err != nil {
+		return Record{}, err
 	}
 
 	outboxPayload := map[string]any{
 		"agreement_id": rec.ID,
 		"referral_id":  rec.RequestID,
 	}
-	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ('agreement.created',$1::jsonb)`, mustJSON(outboxPayload)); err != nil {
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1,$2::jsonb)`, OutboxTopicAgreementCreated, mustJSON(outboxPayload)); err != nil {
 		return Record{}, fmt.Errorf("agreement: outbox insert: %w", err)
 	}
 
@@ -116,45 +249,129 @@ func (s *CRUDService) Create(ctx context.Context, userID string, params CreatePa
 	return rec, nil
 }
 
-func (s *CRUDService) List(ctx context.Context, filters ListFilters) ([]Record, int, error) {
+func (s *CRUDService) List(ctx context.Context, filters ListFilters) ([]Record, db.Page, error) {
 	if filters.Page <= 0 {
 		filters.Page = 1
 	}
 	if filters.PageSize <= 0 || filters.PageSize > 100 {
 		filters.PageSize = 20
 	}
+	if err := filters.Validate(); err != nil {
+		return nil, db.Page{}, err
+	}
 
-	query := `
-        SELECT a.id, a.referral_id, a.from_broker_id, a.to_broker_id, a.fee_rate, a.protect_days, a.effective_at, a.created_at, a.updated_at
-        FROM agreements a
-        JOIN referral_requests r ON r.id = a.referral_id
-        WHERE r.created_by_user_id = $1
-        ORDER BY a.created_at DESC
-        LIMIT $2 OFFSET $3
-    `
+	q := db.Select(recordColumns).
+		From("agreements a JOIN referral_requests r ON r.id = a.referral_id").
+		Where(filters.Scope.Parties("r.created_by_user_id", "a.from_broker_id", "a.to_broker_id"))
+	if len(filters.Statuses) > 0 {
+		q.Where("a.status = ANY(?::agreement_status[])", filters.Statuses)
+	}
+	if filters.CounterpartyBrokerID != "" {
+		q.Where("? IN (a.from_broker_id, a.to_broker_id)", filters.CounterpartyBrokerID)
+	}
+	if filters.ReferralID != "" {
+		q.Where("a.referral_id = ?", filters.ReferralID)
+	}
+	for _, bound := range []struct {
+		pred string
+		at   time.Time
+	}{
+		{"a.created_at >= ?", filters.CreatedFrom},
+		{"a.created_at < ?", filters.CreatedTo},
+		{"a.effective_at >= ?", filters.EffectiveFrom},
+		{"a.effective_at < ?", filters.EffectiveTo},
+	} {
+		if !bound.at.IsZero() {
+			q.Where(bound.pred, bound.at)
+		}
+	}
 
-	rows, err := s.pool.Query(ctx, query, filters.CreatorUserID, filters.PageSize, (filters.Page-1)*filters.PageSize)
+	// One row past the page tells whether another page follows.
+	offset := (filters.Page - 1) * filters.PageSize
+	q.OrderBy("a.created_at DESC").Limit(filters.PageSize + 1).Offset(offset)
+
+	query, args := q.SQL()
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("agreement: list: %w", err)
+		return nil, db.Page{}, fmt.Errorf("agreement: list: %w", err)
 	}
 	defer rows.Close()
 
 	records := []Record{}
 	for rows.Next() {
-		var rec Record
-		if err := rows.Scan(&rec.ID, &rec.RequestID, &rec.ReferrerBrokerID, &rec.RefereeBrokerID, &rec.FeeRate, &rec.ProtectDays, &rec.EffectiveAt, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
-			return nil, 0, err
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, db.Page{}, err
 		}
 		records = append(records, rec)
 	}
 
-	countQuery := `SELECT COUNT(*) FROM agreements a JOIN referral_requests r ON r.id=a.referral_id WHERE r.created_by_user_id=$1`
-	var total int
-	if err := s.pool.QueryRow(ctx, countQuery, filters.CreatorUserID).Scan(&total); err != nil {
-		return nil, 0, err
+	return db.Paginate(ctx, s.counter, s.reader, q.Count(), records, offset, filters.PageSize)
+}
+
+// recordColumns are the columns of agreements a that scanRecord reads, in
+// order.
+const recordColumns = `a.id, a.referral_id, a.from_broker_id, a.to_broker_id, a.fee_rate, a.protect_days, a.currency, a.effective_at,
+            a.referrer_signed_at, a.referrer_signed_by::text, a.referee_signed_at, a.referee_signed_by::text, a.status::text, a.status_updated_at,
+            a.status_updated_by::text, a.version, a.created_at, a.updated_at`
+
+func scanRecord(row pgx.Row) (Record, error) {
+	var rec Record
+	err := row.Scan(&rec.ID, &rec.RequestID, &rec.ReferrerBrokerID, &rec.RefereeBrokerID, &rec.FeeRate, &rec.ProtectDays, &rec.Currency, &rec.EffectiveAt,
+		&rec.ReferrerSignedAt, &rec.ReferrerSignedBy, &rec.RefereeSignedAt, &rec.RefereeSignedBy, &rec.Status, &rec.StatusUpdatedAt,
+		&rec.StatusUpdatedBy, &rec.Version, &rec.CreatedAt, &rec.UpdatedAt)
+	return rec, err
+}
+
+// GetMany returns the agreements among ids that scope can see, as List
+// decides, in no particular order. Ids outside the scope or unknown are
+// left out.
+func (s *CRUDService) GetMany(ctx context.Context, scope tenancy.Scope, ids []string) ([]Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.find(ctx, db.Select(recordColumns).
+		From("agreements a JOIN referral_requests r ON r.id = a.referral_id").
+		Where(scope.Parties("r.created_by_user_id", "a.from_broker_id", "a.to_broker_id")).
+		Where("a.id = ANY(?::uuid[])", ids))
+}
+
+// ActiveForReferrals returns the active agreement (draft, pending_signature
+// or effective) of each referral among referralIDs that has one scope can
+// see.
+func (s *CRUDService) ActiveForReferrals(ctx context.Context, scope tenancy.Scope, referralIDs []string) ([]Record, error) {
+	if len(referralIDs) == 0 {
+		return nil, nil
+	}
+	return s.find(ctx, db.Select("DISTINCT ON (a.referral_id) "+recordColumns).
+		From("agreements a JOIN referral_requests r ON r.id = a.referral_id").
+		Where(scope.Parties("r.created_by_user_id", "a.from_broker_id", "a.to_broker_id")).
+		Where("a.referral_id = ANY(?::uuid[])", referralIDs).
+		Where("a.status IN "+activeStatuses).
+		OrderBy("a.referral_id", "a.created_at"))
+}
+
+// find runs q, which selects recordColumns, unpaged.
+func (s *CRUDService) find(ctx context.Context, q *db.Query) ([]Record, error) {
+	query, args := q.SQL()
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: find: %w", err)
 	}
+	defer rows.Close()
 
-	return records, total, nil
+	var records []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("agreement: scan: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("agreement: iterate: %w", err)
+	}
+	return records, nil
 }
 
 func mustJSON(payload map[string]any) string {
@@ -164,3 +381,37 @@ func mustJSON(payload map[string]any) string {
 	}
 	return string(b)
 }
+
+// ParticipantUserIDs lists the users entitled to follow an agreement: the
+// referral owner and every user of either broker party.
+func (s *CRUDService) ParticipantUserIDs(ctx context.Context, agreementID string) ([]string, error) {
+	const query = `
+        SELECT rr.created_by_user_id::text
+        FROM agreements a
+        JOIN referral_requests rr ON rr.id = a.referral_id
+        WHERE a.id = $1
+        UNION
+        SELECT u.id::text
+        FROM agreements a
+        JOIN users u ON u.broker_id IN (a.from_broker_id, a.to_broker_id)
+        WHERE a.id = $1
+    `
+	rows, err := s.pool.Query(ctx, query, agreementID)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: list participants: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("agreement: scan participant: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("agreement: iterate participants: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,28 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreate_RefusesAnotherReferrerBroker(t *testing.T) {
+	params := CreateParams{
+		RequestID:        "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70",
+		ReferrerBrokerID: "b-other",
+		RefereeBrokerID:  "b2",
+		FeeRate:          99,
+		ProtectDays:      3000,
+	}
+	for name, ownerBroker := range map[string]any{"other brokerage": "b1", "no brokerage": nil} {
+		tx := &scriptTx{rows: map[string]scanFunc{"FROM referral_requests rr": values("u-1", ownerBroker)}}
+		_, err := NewCRUDService(tx).Create(context.Background(), "u-1", params)
+		if !errors.Is(err, ErrReferrerBrokerMismatch) {
+			t.Fatalf("%s: expected ErrReferrerBrokerMismatch, got %v", name, err)
+		}
+		// Refused before the policy is loaded or anything is written.
+		if tx.statements != 1 {
+			t.Fatalf("%s: expected only the referral read, got %d statements", name, tx.statements)
+		}
+	}
+}
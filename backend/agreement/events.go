@@ -0,0 +1,169 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+// Deal lifecycle event types, in the order a deal progresses.
+const (
+	EventOfferMade     = timeline.TypeOfferMade
+	EventUnderContract = timeline.TypeUnderContract
+	EventDealClosed    = timeline.TypeDealClosed
+)
+
+var dealStages = map[string]int{
+	EventOfferMade:     1,
+	EventUnderContract: 2,
+	EventDealClosed:    3,
+}
+
+var (
+	ErrUnknownDealEvent  = errors.New("agreement: event type must be OFFER_MADE, UNDER_CONTRACT or DEAL_CLOSED")
+	ErrDealNotEffective  = errors.New("agreement: deal events require an effective agreement")
+	ErrDealEventOutOfSeq = errors.New("agreement: deal event is out of order")
+	ErrProtectExpired    = errors.New("agreement: protect period has ended")
+)
+
+// DealEvent is a deal lifecycle event appended to an agreement's timeline.
+type DealEvent struct {
+	ID          int64
+	AgreementID string
+	Seq         int64
+	Type        string
+	At          time.Time
+	Payload     map[string]any
+	ActorBroker string
+}
+
+type RecordDealEventParams struct {
+	AgreementID string
+	ActorID     string
+	Type        string
+	Payload     map[string]any
+}
+
+// checkDealOrder reports whether next may follow the furthest stage already
+// recorded (0 when none). Offers may repeat until a contract is signed; each
+// later stage must directly follow the previous one and happens once.
+func checkDealOrder(current int, next string) error {
+	stage, ok := dealStages[next]
+	if !ok {
+		return ErrUnknownDealEvent
+	}
+	if stage == dealStages[EventOfferMade] && current <= stage {
+		return nil
+	}
+	if stage == current+1 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s after stage %d", ErrDealEventOutOfSeq, next, current)
+}
+
+// EventsService records deal lifecycle events. It enforces the same temporal
+// rule as oracle O2 (only on effective agreements) plus stage ordering, and
+// writes the outbox message in the same transaction as the event.
+type EventsService struct {
+	pool TxBeginner
+}
+
+func NewEventsService(pool TxBeginner) *EventsService {
+	return &EventsService{pool: pool}
+}
+
+// Record appends a deal event. The agreement row is locked for the duration
+// so concurrent writers observe each other's stages, and seq is assigned by
+// the timeline_seq trigger under that lock.
+func (s *EventsService) Record(ctx context.Context, params RecordDealEventParams) (DealEvent, error) {
+	if _, ok := dealStages[params.Type]; !ok {
+		return DealEvent{}, ErrUnknownDealEvent
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, _, _, _, err := agreementParties(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return DealEvent{}, err
+	}
+	if status == "expired" {
+		return DealEvent{}, ErrProtectExpired
+	}
+	if status != "effective" {
+		return DealEvent{}, ErrDealNotEffective
+	}
+	// The expiry job runs periodically; refuse milestones in the gap between
+	// the period ending and the agreement being marked expired.
+	var lapsed bool
+	if err := tx.QueryRow(ctx, `
+        SELECT protect_days > 0 AND get_tx_timestamp() > effective_at + make_interval(days => protect_days)
+        FROM agreements WHERE id = $1
+    `, params.AgreementID).Scan(&lapsed); err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: check protect period: %w", err)
+	}
+	if lapsed {
+		return DealEvent{}, ErrProtectExpired
+	}
+
+	var current int
+	if err := tx.QueryRow(ctx, `
+        SELECT COALESCE(MAX(CASE type::text WHEN $2 THEN 1 WHEN $3 THEN 2 WHEN $4 THEN 3 END), 0)
+        FROM timeline_events
+        WHERE agreement_id = $1
+    `, params.AgreementID, EventOfferMade, EventUnderContract, EventDealClosed).Scan(&current); err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: load deal stage: %w", err)
+	}
+	if err := checkDealOrder(current, params.Type); err != nil {
+		return DealEvent{}, err
+	}
+
+	payload := params.Payload
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	body, version, err := timeline.Encode(params.Type, payload)
+	if err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: encode timeline payload: %w", err)
+	}
+	ev := DealEvent{AgreementID: params.AgreementID, Type: params.Type, Payload: payload}
+	slot, err := claimTimelineSlot(ctx, tx, params.AgreementID, params.ActorID)
+	if err != nil {
+		return DealEvent{}, err
+	}
+	ev.Seq = slot.seq
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO timeline_events (agreement_id, seq, type, payload, payload_version, actor_id, actor_broker_id)
+        VALUES ($1, $2, $3::event_type, $4::jsonb, $5, $6::uuid, $7::uuid)
+        RETURNING id, ts, actor_broker_id::text
+    `, params.AgreementID, ev.Seq, params.Type, body, version, params.ActorID, slot.actorBroker).Scan(&ev.ID, &ev.At, &ev.ActorBroker); err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: insert deal event: %w", err)
+	}
+	next, ok := ReferralProjector{}.ForEvent(params.Type)
+	if err := projectReferral(ctx, tx, params.AgreementID, next, ok); err != nil {
+		return DealEvent{}, err
+	}
+
+	if err := enqueueOutbox(ctx, tx, OutboxTopicAgreementDealEvent, map[string]any{
+		"agreement_id":    ev.AgreementID,
+		"event_id":        ev.ID,
+		"seq":             ev.Seq,
+		"type":            ev.Type,
+		"actor_id":        params.ActorID,
+		"at":              ev.At.UTC(),
+		"payload":         payload,
+		"payload_version": version,
+	}); err != nil {
+		return DealEvent{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return DealEvent{}, fmt.Errorf("agreement: commit deal event: %w", err)
+	}
+	return ev, nil
+}
@@ -0,0 +1,48 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckDealOrder(t *testing.T) {
+	cases := []struct {
+		current int
+		next    string
+		ok      bool
+	}{
+		{0, EventOfferMade, true},
+		{1, EventOfferMade, true},
+		{1, EventUnderContract, true},
+		{2, EventDealClosed, true},
+		{0, EventUnderContract, false},
+		{0, EventDealClosed, false},
+		{1, EventDealClosed, false},
+		{2, EventOfferMade, false},
+		{2, EventUnderContract, false},
+		{3, EventDealClosed, false},
+	}
+	for _, tc := range cases {
+		err := checkDealOrder(tc.current, tc.next)
+		if tc.ok && err != nil {
+			t.Errorf("stage %d -> %s: expected ok, got %v", tc.current, tc.next, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrDealEventOutOfSeq) {
+			t.Errorf("stage %d -> %s: expected ErrDealEventOutOfSeq, got %v", tc.current, tc.next, err)
+		}
+	}
+}
+
+func TestEventsRecord_RejectsUnknownTypeBeforeTx(t *testing.T) {
+	pool := &fakePool{}
+	_, err := NewEventsService(pool).Record(context.Background(), RecordDealEventParams{
+		AgreementID: "a", ActorID: "u", Type: "ESIGN_COMPLETED",
+	})
+	if !errors.Is(err, ErrUnknownDealEvent) {
+		t.Fatalf("expected ErrUnknownDealEvent, got %v", err)
+	}
+	if pool.tx != nil {
+		t.Fatal("expected no transaction for an unknown event type")
+	}
+}
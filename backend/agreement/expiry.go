@@ -0,0 +1,155 @@
+package agreement
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/timeline"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultExpiryBatchSize = 100
+
+// ExpiredAgreement describes an agreement whose protect period lapsed.
+type ExpiredAgreement struct {
+	AgreementID string
+	EffectiveAt time.Time
+	ProtectDays int
+	ExpiredAt   time.Time
+}
+
+// ExpiryService moves effective agreements to expired once effective_at +
+// protect_days has passed without a DEAL_CLOSED event. Agreements with
+// protect_days = 0 have no protect period and are never expired.
+type ExpiryService struct {
+	pool      TxBeginner
+	observer  TransitionObserver
+	clock     clock.Clock
+	batchSize int
+}
+
+func NewExpiryService(pool TxBeginner) *ExpiryService {
+	return &ExpiryService{pool: pool, observer: noopObserver{}, clock: clock.New(), batchSize: defaultExpiryBatchSize}
+}
+
+// WithObserver registers a hook notified for each expired agreement.
+func (s *ExpiryService) WithObserver(o TransitionObserver) *ExpiryService {
+	s.observer = observerOrNoop(o)
+	return s
+}
+
+func (s *ExpiryService) WithClock(c clock.Clock) *ExpiryService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Run expires due agreements every interval until ctx is cancelled.
+func (s *ExpiryService) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			expired, err := s.ExpireDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("protect expiry: %v", err)
+				}
+				break
+			}
+			if len(expired) < s.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+// ExpireDue expires up to one batch of lapsed agreements in a single
+// transaction, recording a PROTECT_EXPIRED timeline event and an
+// agreement.expired outbox message for each. Rows locked by another writer
+// are skipped and picked up on a later run.
+func (s *ExpiryService) ExpireDue(ctx context.Context) ([]ExpiredAgreement, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: begin expiry: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	type due struct {
+		id          string
+		effectiveAt time.Time
+		protectDays int
+	}
+	rows, err := tx.Query(ctx, `
+        SELECT a.id::text, a.effective_at, a.protect_days
+        FROM agreements a
+        WHERE a.status = 'effective'
+          AND a.protect_days > 0
+          AND a.effective_at + make_interval(days => a.protect_days) <= get_tx_timestamp()
+          AND NOT EXISTS (
+              SELECT 1 FROM timeline_events e
+              WHERE e.agreement_id = a.id AND e.type = 'DEAL_CLOSED'
+          )
+        ORDER BY a.effective_at
+        LIMIT $1
+        FOR UPDATE OF a SKIP LOCKED
+    `, s.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: select lapsed agreements: %w", err)
+	}
+	lapsed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var d due
+		err := row.Scan(&d.id, &d.effectiveAt, &d.protectDays)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan lapsed agreements: %w", err)
+	}
+
+	out := make([]ExpiredAgreement, 0, len(lapsed))
+	for _, d := range lapsed {
+		var expiredAt time.Time
+		if err := tx.QueryRow(ctx, `
+            UPDATE agreements
+            SET status = 'expired',
+                status_updated_at = get_tx_timestamp(),
+                status_updated_by = NULL,
+                updated_at = get_tx_timestamp()
+            WHERE id = $1
+            RETURNING status_updated_at
+        `, d.id).Scan(&expiredAt); err != nil {
+			return nil, fmt.Errorf("agreement: expire %s: %w", d.id, err)
+		}
+		deadline := d.effectiveAt.AddDate(0, 0, d.protectDays).UTC()
+		if err := insertTimelineEvent(ctx, tx, d.id, timeline.TypeProtectExpired, "", map[string]any{
+			"effective_at":    d.effectiveAt.UTC(),
+			"protect_days":    d.protectDays,
+			"protect_ends":    deadline,
+			"previous_status": "effective",
+		}); err != nil {
+			return nil, err
+		}
+		if err := enqueueOutbox(ctx, tx, OutboxTopicAgreementExpired, map[string]any{
+			"agreement_id": d.id,
+			"effective_at": d.effectiveAt.UTC(),
+			"protect_days": d.protectDays,
+			"expired_at":   expiredAt.UTC(),
+		}); err != nil {
+			return nil, err
+		}
+		out = append(out, ExpiredAgreement{AgreementID: d.id, EffectiveAt: d.effectiveAt, ProtectDays: d.protectDays, ExpiredAt: expiredAt})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("agreement: commit expiry: %w", err)
+	}
+	for range out {
+		s.observer.ObserveTransition("effective", "expired")
+	}
+	return out, nil
+}
@@ -0,0 +1,328 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/timeline"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	historyProjection          = "agreement_status_history"
+	defaultHistoryBatchSize    = 500
+	defaultHistorySettleWindow = 5 * time.Second
+)
+
+// statusEventTypes are the timeline events that move an agreement into a
+// status; the projector ignores the rest.
+var statusEventTypes = []string{
+	timeline.TypeAgreementCreated,
+	timeline.TypeAgreementStatusChanged,
+	timeline.TypeEsignCompleted,
+	timeline.TypeProtectExpired,
+	timeline.TypeAgreementCancelled,
+	timeline.TypeStatusCorrected,
+}
+
+// StatusPeriod is one stay of an agreement in a status. ExitedAt and
+// Duration are nil for the status the agreement is in now.
+type StatusPeriod struct {
+	Status    string
+	EnteredAt time.Time
+	ExitedAt  *time.Time
+	Duration  *time.Duration
+}
+
+type historyRow struct {
+	eventID int64
+	period  StatusPeriod
+}
+
+// enteredStatus returns the status ev moves the agreement into, or "" for
+// events that leave the status unchanged.
+func enteredStatus(ev timeline.Event) (string, error) {
+	switch ev.Type {
+	case timeline.TypeAgreementCreated:
+		var p timeline.AgreementCreatedPayload
+		if err := timeline.DecodeInto(ev.Type, ev.PayloadVersion, ev.Payload, &p); err != nil {
+			return "", err
+		}
+		if p.Source == "match_acceptance" {
+			return "pending_signature", nil
+		}
+		return "draft", nil
+	case timeline.TypeAgreementStatusChanged:
+		var p timeline.StatusChangedPayload
+		if err := timeline.DecodeInto(ev.Type, ev.PayloadVersion, ev.Payload, &p); err != nil {
+			return "", err
+		}
+		return p.NextStatus, nil
+	case timeline.TypeEsignCompleted:
+		return "effective", nil
+	case timeline.TypeProtectExpired:
+		return "expired", nil
+	case timeline.TypeAgreementCancelled:
+		return StatusCancelled, nil
+	case timeline.TypeStatusCorrected:
+		var p timeline.StatusCorrectedPayload
+		if err := timeline.DecodeInto(ev.Type, ev.PayloadVersion, ev.Payload, &p); err != nil {
+			return "", err
+		}
+		return p.NextStatus, nil
+	}
+	return "", nil
+}
+
+// projectHistory folds an agreement's timeline, in order, into status
+// periods. Repeated events for the current status do not open a new period.
+func projectHistory(events []timeline.Event) ([]historyRow, error) {
+	var rows []historyRow
+	for _, ev := range events {
+		status, err := enteredStatus(ev)
+		if err != nil {
+			return nil, fmt.Errorf("agreement: project event %d: %w", ev.ID, err)
+		}
+		if status == "" {
+			continue
+		}
+		if n := len(rows); n > 0 {
+			last := &rows[n-1].period
+			if last.Status == status {
+				continue
+			}
+			exited := ev.At
+			duration := exited.Sub(last.EnteredAt)
+			last.ExitedAt = &exited
+			last.Duration = &duration
+		}
+		rows = append(rows, historyRow{eventID: ev.ID, period: StatusPeriod{Status: status, EnteredAt: ev.At}})
+	}
+	return rows, nil
+}
+
+// HistoryProjector keeps agreement_status_history in step with
+// timeline_events. It reads events past its stored offset and rebuilds the
+// history of every agreement they touch, so replays and events committed out
+// of id order converge on the same rows. Events younger than the settle
+// window are left for the next batch: ids are assigned at insert, and a
+// transaction still open when a later id was read would otherwise be
+// skipped. The offset row is locked for the batch, so several processes may
+// run the projector.
+type HistoryProjector struct {
+	pool      TxBeginner
+	clock     clock.Clock
+	batchSize int
+	settle    time.Duration
+}
+
+func NewHistoryProjector(pool TxBeginner) *HistoryProjector {
+	return &HistoryProjector{
+		pool:      pool,
+		clock:     clock.New(),
+		batchSize: defaultHistoryBatchSize,
+		settle:    defaultHistorySettleWindow,
+	}
+}
+
+func (p *HistoryProjector) WithClock(c clock.Clock) *HistoryProjector {
+	p.clock = clock.OrReal(c)
+	return p
+}
+
+// Run projects new events every interval until ctx is cancelled.
+func (p *HistoryProjector) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			n, err := p.ProjectBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("status history projection: %v", err)
+				}
+				break
+			}
+			if n < p.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.clock.After(interval):
+		}
+	}
+}
+
+// ProjectBatch consumes up to one batch of timeline events and returns how
+// many it read.
+func (p *HistoryProjector) ProjectBatch(ctx context.Context) (int, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("agreement: begin projection: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `INSERT INTO projection_offsets (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, historyProjection); err != nil {
+		return 0, fmt.Errorf("agreement: init projection offset: %w", err)
+	}
+	var offset int64
+	if err := tx.QueryRow(ctx, `SELECT last_event_id FROM projection_offsets WHERE name = $1 FOR UPDATE`, historyProjection).Scan(&offset); err != nil {
+		return 0, fmt.Errorf("agreement: lock projection offset: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT id, agreement_id::text, type::text
+        FROM timeline_events
+        WHERE id > $1 AND ts <= get_tx_timestamp() - make_interval(secs => $2)
+        ORDER BY id
+        LIMIT $3
+    `, offset, p.settle.Seconds(), p.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("agreement: read timeline: %w", err)
+	}
+	var (
+		n       int
+		touched []string
+		seen    = make(map[string]bool)
+	)
+	for rows.Next() {
+		var (
+			id          int64
+			agreementID string
+			eventType   string
+		)
+		if err := rows.Scan(&id, &agreementID, &eventType); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("agreement: scan timeline: %w", err)
+		}
+		n++
+		offset = id
+		if !seen[agreementID] && slices.Contains(statusEventTypes, eventType) {
+			seen[agreementID] = true
+			touched = append(touched, agreementID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("agreement: read timeline: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	for _, agreementID := range touched {
+		if err := rebuildHistory(ctx, tx, agreementID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE projection_offsets SET last_event_id = $2, updated_at = get_tx_timestamp() WHERE name = $1`, historyProjection, offset); err != nil {
+		return 0, fmt.Errorf("agreement: advance projection offset: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("agreement: commit projection: %w", err)
+	}
+	return n, nil
+}
+
+func rebuildHistory(ctx context.Context, tx pgx.Tx, agreementID string) error {
+	rows, err := tx.Query(ctx, `
+        SELECT id, type::text, ts, payload, payload_version
+        FROM timeline_events
+        WHERE agreement_id = $1 AND type::text = ANY($2)
+        ORDER BY seq, id
+    `, agreementID, statusEventTypes)
+	if err != nil {
+		return fmt.Errorf("agreement: load status events: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (timeline.Event, error) {
+		ev := timeline.Event{AgreementID: agreementID}
+		err := row.Scan(&ev.ID, &ev.Type, &ev.At, &ev.Payload, &ev.PayloadVersion)
+		return ev, err
+	})
+	if err != nil {
+		return fmt.Errorf("agreement: scan status events: %w", err)
+	}
+	history, err := projectHistory(events)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM agreement_status_history WHERE agreement_id = $1`, agreementID); err != nil {
+		return fmt.Errorf("agreement: clear status history: %w", err)
+	}
+	for _, h := range history {
+		if _, err := tx.Exec(ctx, `
+            INSERT INTO agreement_status_history (agreement_id, event_id, status, entered_at, exited_at)
+            VALUES ($1, $2, $3, $4, $5)
+        `, agreementID, h.eventID, h.period.Status, h.period.EnteredAt, h.period.ExitedAt); err != nil {
+			return fmt.Errorf("agreement: write status history: %w", err)
+		}
+	}
+	return nil
+}
+
+// HistoryService reads the projected status history.
+type HistoryService struct {
+	pool DB
+}
+
+func NewHistoryService(pool DB) *HistoryService {
+	return &HistoryService{pool: pool}
+}
+
+// List returns the agreement's status periods, oldest first. Like the
+// timeline, it is visible to the referral owner and to users of either
+// broker party; anyone else gets ErrNotParty. The projection trails the
+// timeline by the projector's interval.
+func (s *HistoryService) List(ctx context.Context, agreementID, userID string) ([]StatusPeriod, error) {
+	var visible bool
+	if err := s.pool.QueryRow(ctx, `
+        SELECT rr.created_by_user_id = $2::uuid
+            OR EXISTS (SELECT 1 FROM users u WHERE u.id = $2::uuid AND u.broker_id IN (a.from_broker_id, a.to_broker_id))
+        FROM agreements a
+        JOIN referral_requests rr ON rr.id = a.referral_id
+        WHERE a.id = $1::uuid
+    `, agreementID, userID).Scan(&visible); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAgreementNotFound
+		}
+		return nil, fmt.Errorf("agreement: check history access: %w", err)
+	}
+	if !visible {
+		return nil, ErrNotParty
+	}
+
+	rows, err := s.pool.Query(ctx, `
+        SELECT status, entered_at, exited_at, EXTRACT(EPOCH FROM duration)::float8
+        FROM agreement_status_history
+        WHERE agreement_id = $1::uuid
+        ORDER BY entered_at, event_id
+    `, agreementID)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: list status history: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (StatusPeriod, error) {
+		var (
+			p       StatusPeriod
+			seconds *float64
+		)
+		if err := row.Scan(&p.Status, &p.EnteredAt, &p.ExitedAt, &seconds); err != nil {
+			return StatusPeriod{}, err
+		}
+		if seconds != nil {
+			d := time.Duration(*seconds * float64(time.Second))
+			p.Duration = &d
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan status history: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package agreement
+
+import (
+	"testing"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+func historyEvent(t *testing.T, id int64, eventType string, at time.Time, payload any) timeline.Event {
+	t.Helper()
+	body, version, err := timeline.Encode(eventType, payload)
+	if err != nil {
+		t.Fatalf("encode %s: %v", eventType, err)
+	}
+	return timeline.Event{ID: id, Type: eventType, At: at, Payload: body, PayloadVersion: version}
+}
+
+func TestProjectHistory_PeriodsAndDurations(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	events := []timeline.Event{
+		historyEvent(t, 1, timeline.TypeAgreementCreated, t0, map[string]any{"referral_id": "r1", "fee_rate": 25, "protect_days": 30}),
+		historyEvent(t, 2, timeline.TypeAgreementStatusChanged, t0.Add(time.Hour), map[string]any{"previous_status": "draft", "next_status": "pending_signature"}),
+		historyEvent(t, 3, timeline.TypeAgreementStatusChanged, t0.Add(2*time.Hour), map[string]any{"previous_status": "pending_signature", "next_status": "pending_signature"}),
+		historyEvent(t, 4, timeline.TypeOfferMade, t0.Add(3*time.Hour), nil),
+		historyEvent(t, 5, timeline.TypeEsignCompleted, t0.Add(26*time.Hour), map[string]any{"agreement_id": "a1", "effective_at": t0.Add(26 * time.Hour)}),
+	}
+
+	rows, err := projectHistory(events)
+	if err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	want := []struct {
+		status   string
+		eventID  int64
+		duration time.Duration
+	}{
+		{"draft", 1, time.Hour},
+		{"pending_signature", 2, 25 * time.Hour},
+		{"effective", 5, 0},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d periods, got %+v", len(want), rows)
+	}
+	for i, w := range want {
+		got := rows[i]
+		if got.period.Status != w.status || got.eventID != w.eventID {
+			t.Fatalf("period %d: expected %s from event %d, got %+v", i, w.status, w.eventID, got)
+		}
+		if i == len(want)-1 {
+			if got.period.ExitedAt != nil || got.period.Duration != nil {
+				t.Fatalf("expected the current status to stay open, got %+v", got.period)
+			}
+			continue
+		}
+		if got.period.Duration == nil || *got.period.Duration != w.duration {
+			t.Fatalf("period %d: expected duration %s, got %+v", i, w.duration, got.period)
+		}
+	}
+}
+
+func TestProjectHistory_MatchAcceptanceAndLegacyPayloads(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	events := []timeline.Event{
+		historyEvent(t, 1, timeline.TypeAgreementCreated, t0, map[string]any{"source": "match_acceptance", "match_id": "m1"}),
+		historyEvent(t, 2, timeline.TypeEsignCompleted, t0.Add(time.Hour), map[string]any{"agreement_id": "a1", "effective_at": t0}),
+		// Written before PROTECT_EXPIRED payloads were versioned.
+		{ID: 3, Type: timeline.TypeProtectExpired, At: t0.Add(48 * time.Hour), PayloadVersion: 1,
+			Payload: []byte(`{"effective_at":"2026-05-01T10:00:00Z","protect_days":1,"protect_ends":"2026-05-02T10:00:00Z","previous_state":"effective"}`)},
+	}
+
+	rows, err := projectHistory(events)
+	if err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	var statuses []string
+	for _, r := range rows {
+		statuses = append(statuses, r.period.Status)
+	}
+	if len(statuses) != 3 || statuses[0] != "pending_signature" || statuses[1] != "effective" || statuses[2] != "expired" {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+}
+
+func TestProjectHistory_Cancelled(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	rows, err := projectHistory([]timeline.Event{
+		historyEvent(t, 1, timeline.TypeAgreementCreated, t0, nil),
+		historyEvent(t, 2, timeline.TypeAgreementCancelled, t0.Add(time.Minute), map[string]any{"previous_status": "draft", "reason": "duplicate", "referral_reopened": true}),
+	})
+	if err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	if len(rows) != 2 || rows[1].period.Status != StatusCancelled || *rows[0].period.Duration != time.Minute {
+		t.Fatalf("unexpected periods %+v", rows)
+	}
+}
@@ -0,0 +1,86 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var errListed = errors.New("listed")
+
+// listReader records the list query and stops there.
+type listReader struct {
+	sql  string
+	args []any
+}
+
+func (r *listReader) Query(_ context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r.sql, r.args = sql, args
+	return nil, errListed
+}
+
+func (r *listReader) QueryRow(context.Context, string, ...any) pgx.Row { return errRow{errListed} }
+
+func TestList_FiltersInSQL(t *testing.T) {
+	reader := &listReader{}
+	svc := &CRUDService{reader: reader}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 3, 0)
+	_, _, err := svc.List(context.Background(), ListFilters{
+		Scope:                tenancy.User("u-1"),
+		Statuses:             []string{StatusEffective, StatusDisputed},
+		CounterpartyBrokerID: "5f0c6c1e-6a2b-4c1d-9e3f-0a1b2c3d4e5f",
+		ReferralID:           "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70",
+		CreatedFrom:          from,
+		EffectiveTo:          to,
+	})
+	if !errors.Is(err, errListed) {
+		t.Fatalf("expected the list query to run, got %v", err)
+	}
+	for _, want := range []string{
+		"r.created_by_user_id = $1",
+		"a.status = ANY($2::agreement_status[])",
+		"$3 IN (a.from_broker_id, a.to_broker_id)",
+		"a.referral_id = $4",
+		"a.created_at >= $5",
+		"a.effective_at < $6",
+		"ORDER BY a.created_at DESC LIMIT $7 OFFSET $8",
+	} {
+		if !strings.Contains(reader.sql, want) {
+			t.Fatalf("expected %q in %s", want, reader.sql)
+		}
+	}
+	if len(reader.args) != 8 || reader.args[4] != from || reader.args[5] != to {
+		t.Fatalf("unexpected args %v", reader.args)
+	}
+}
+
+func TestList_RejectsInvalidFilters(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		filters ListFilters
+		want    error
+	}{
+		"unknown status":     {ListFilters{Statuses: []string{"archived"}}, ErrUnknownStatus},
+		"malformed referral": {ListFilters{ReferralID: "ref-1"}, ErrInvalidParams},
+		"malformed broker":   {ListFilters{CounterpartyBrokerID: "b2"}, ErrInvalidParams},
+		"empty created":      {ListFilters{CreatedFrom: day, CreatedTo: day}, ErrInvalidParams},
+		"reversed effective": {ListFilters{EffectiveFrom: day, EffectiveTo: day.AddDate(0, 0, -1)}, ErrInvalidParams},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reader := &listReader{}
+			if _, _, err := (&CRUDService{reader: reader}).List(context.Background(), tc.filters); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+			if reader.sql != "" {
+				t.Fatalf("expected no query, ran %s", reader.sql)
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package agreement
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"brokerflow/broker"
+	"brokerflow/db"
+	"brokerflow/timeline"
+
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -19,6 +23,17 @@ type MatchAcceptanceParams struct {
 	CandidateUserID  string
 	AcceptedByUserID string
 	AcceptedAt       time.Time
+	// Terms replaces the referring broker's default terms, e.g. with a
+	// counter-offer the referral owner accepted. They must still fall within
+	// that broker's policy.
+	Terms *MatchTerms
+}
+
+// MatchTerms are the fee rate and protection period an agreement made from a
+// match starts with.
+type MatchTerms struct {
+	FeeRate     float64
+	ProtectDays int
 }
 
 var (
@@ -28,15 +43,16 @@ var (
 	errOwnerBrokerMissing     = errors.New("agreement: referral owner has no broker")
 )
 
-const (
-	defaultMatchFeeRate    = 30.0
-	defaultMatchProtectDay = 90
-)
-
 // CreateFromMatch materialises a new agreement for an accepted match. It is
 // designed to be invoked inside the caller's transaction so we leverage the
 // surrounding locks to uphold the partial uniqueness guarantees (Axiom P1) and
 // append-only timeline/outbox behaviour (Axioms P3–P5).
+//
+// It takes two round trips: one batch locks and reads the match, the
+// referral, any active agreement and the referring broker's policy, and a
+// second writes the agreement, the referral status, the timeline event and
+// the outbox message. A failed check in the first batch leaves the locks it
+// took to the caller's rollback.
 func (r *Repository) CreateFromMatch(ctx context.Context, tx pgx.Tx, params MatchAcceptanceParams) (Record, error) {
 	if params.MatchID == "" {
 		return Record{}, fmt.Errorf("agreement: match acceptance missing match id")
@@ -49,194 +65,198 @@ func (r *Repository) CreateFromMatch(ctx context.Context, tx pgx.Tx, params Matc
 	}
 
 	var (
-		matchRequestID string
-		matchCandidate string
-		matchState     string
+		reads           batch
+		ownerUserID     string
+		ownerBrokerID   string
+		candidateBroker string
+		currentStatus   string
+		acceptedAt      time.Time
+		existing        Record
+		existingStatus  string
+		exists          bool
+		policy          broker.Settings
 	)
-	const matchSQL = `
+	reads.Queue(`
 SELECT request_id::text, candidate_user_id::text, state::text
 FROM referral_matches
 WHERE id = $1
 FOR UPDATE
-`
-	if err := tx.QueryRow(ctx, matchSQL, params.MatchID).Scan(&matchRequestID, &matchCandidate, &matchState); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return Record{}, fmt.Errorf("agreement: match %s not found", params.MatchID)
+`, params.MatchID).QueryRow(func(row pgx.Row) error {
+		var matchRequestID, matchCandidate, matchState string
+		if err := row.Scan(&matchRequestID, &matchCandidate, &matchState); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("agreement: match %s not found", params.MatchID)
+			}
+			return fmt.Errorf("agreement: load match: %w", err)
 		}
-		return Record{}, fmt.Errorf("agreement: load match: %w", err)
-	}
-	if matchRequestID != params.RequestID {
-		return Record{}, errMatchRequestMismatch
-	}
-	if matchCandidate != params.CandidateUserID {
-		return Record{}, errMatchCandidateMismatch
-	}
-	if matchState != "accepted" {
-		return Record{}, fmt.Errorf("agreement: match %s is not accepted (state=%s)", params.MatchID, matchState)
-	}
-
-	const requestSQL = `
+		if matchRequestID != params.RequestID {
+			return errMatchRequestMismatch
+		}
+		if matchCandidate != params.CandidateUserID {
+			return errMatchCandidateMismatch
+		}
+		if matchState != "accepted" {
+			return fmt.Errorf("agreement: match %s is not accepted (state=%s)", params.MatchID, matchState)
+		}
+		return nil
+	})
+	reads.Queue(`
 SELECT rr.created_by_user_id::text,
        owner.broker_id::text,
        candidate.broker_id::text,
-       rr.status
+       rr.status,
+       get_tx_timestamp()
 FROM referral_requests rr
 JOIN users owner ON owner.id = rr.created_by_user_id
 JOIN users candidate ON candidate.id = $2
 WHERE rr.id = $1
 FOR UPDATE
-`
-	var (
-		ownerUserID     string
-		ownerBrokerID   *string
-		candidateBroker *string
-		currentStatus   string
-	)
-	if err := tx.QueryRow(ctx, requestSQL, params.RequestID, params.CandidateUserID).Scan(&ownerUserID, &ownerBrokerID, &candidateBroker, &currentStatus); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return Record{}, fmt.Errorf("agreement: referral request %s not found", params.RequestID)
+`, params.RequestID, params.CandidateUserID).QueryRow(func(row pgx.Row) error {
+		var ownerBroker, candidate *string
+		if err := row.Scan(&ownerUserID, &ownerBroker, &candidate, &currentStatus, &acceptedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("agreement: referral request %s not found", params.RequestID)
+			}
+			return fmt.Errorf("agreement: load referral request: %w", err)
+		}
+		if ownerBroker == nil || *ownerBroker == "" {
+			return errOwnerBrokerMissing
 		}
-		return Record{}, fmt.Errorf("agreement: load referral request: %w", err)
+		if candidate == nil || *candidate == "" {
+			return errCandidateBrokerMissing
+		}
+		ownerBrokerID, candidateBroker = *ownerBroker, *candidate
+		return nil
+	})
+	// Idempotency: a retried acceptance gets back the agreement already made
+	// with the candidate's brokerage.
+	// Any other active agreement on the referral, a draft included, blocks
+	// this one. The lock keeps concurrent acceptances from both passing the
+	// check before either inserts.
+	reads.Queue(lockReferralSQL, params.RequestID)
+	reads.Queue(activeAgreementSQL, params.RequestID).QueryRow(func(row pgx.Row) error {
+		var err error
+		existing, existingStatus, exists, err = scanActiveAgreement(row)
+		return err
+	})
+	// The referring broker's policy sets the initial terms unless the match
+	// carries accepted ones; the parties may renegotiate them through an
+	// amendment before signing.
+	reads.Queue(`
+SELECT `+broker.SettingsColumns+`
+FROM broker_settings
+WHERE broker_id = (
+    SELECT owner.broker_id
+    FROM referral_requests rr
+    JOIN users owner ON owner.id = rr.created_by_user_id
+    WHERE rr.id = $1
+)
+`, params.RequestID).QueryRow(func(row pgx.Row) error {
+		var err error
+		if policy, err = broker.ScanSettings(row, ownerBrokerID); err != nil {
+			return fmt.Errorf("agreement: load referral terms: %w", err)
+		}
+		return nil
+	})
+	if err := reads.send(ctx, tx, "check match acceptance"); err != nil {
+		return Record{}, err
 	}
-	if ownerBrokerID == nil || *ownerBrokerID == "" {
-		return Record{}, errOwnerBrokerMissing
+
+	if exists {
+		if existingStatus != "draft" && existing.RefereeBrokerID == candidateBroker {
+			return existing, nil
+		}
+		return Record{}, ErrActiveAgreementExists
 	}
-	if candidateBroker == nil || *candidateBroker == "" {
-		return Record{}, errCandidateBrokerMissing
+	terms := MatchTerms{FeeRate: policy.DefaultFeeRate, ProtectDays: policy.DefaultProtectDays}
+	if params.Terms != nil {
+		if err := policy.Check(params.Terms.FeeRate, params.Terms.ProtectDays); err != nil {
+			return Record{}, fmt.Errorf("%w: %w", ErrAmendmentOutOfBounds, err)
+		}
+		terms = *params.Terms
 	}
-
-	// Idempotency: return existing active agreement if present. We prefer to do
-	// this before mutating state to tolerate retries from the caller.
-	const existingSQL = `
-SELECT id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, effective_at, created_at, updated_at
-FROM agreements
-WHERE referral_id = $1
-  AND status IN ('pending_signature','effective')
-LIMIT 1
-`
-	var existing Record
-	switch err := tx.QueryRow(ctx, existingSQL, params.RequestID).Scan(
-		&existing.ID,
-		&existing.RequestID,
-		&existing.ReferrerBrokerID,
-		&existing.RefereeBrokerID,
-		&existing.FeeRate,
-		&existing.ProtectDays,
-		&existing.EffectiveAt,
-		&existing.CreatedAt,
-		&existing.UpdatedAt,
-	); {
-	case err == nil:
-		return existing, nil
-	case errors.Is(err, pgx.ErrNoRows):
-		// continue with insert
-	default:
-		return Record{}, fmt.Errorf("agreement: check existing agreement: %w", err)
+	if err := db.CheckContext(ctx, "inserting the agreement"); err != nil {
+		return Record{}, err
 	}
 
-	const insertSQL = `
-INSERT INTO agreements (referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, status)
-VALUES ($1, $2, $3, $4, $5, 'pending_signature')
-RETURNING id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, effective_at, created_at, updated_at
-`
-
-	var rec Record
-	if err := tx.QueryRow(ctx, insertSQL,
+	var (
+		writes batch
+		rec    Record
+	)
+	agreementID := uuid.NewString()
+	writes.Queue(`
+INSERT INTO agreements (id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, status)
+VALUES ($1, $2, $3, $4, $5, $6, 'pending_signature')
+RETURNING id, referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, currency, effective_at,
+    status::text, status_updated_at, status_updated_by::text, version, created_at, updated_at
+`,
+		agreementID,
 		params.RequestID,
-		*ownerBrokerID,
-		*candidateBroker,
-		defaultMatchFeeRate,
-		defaultMatchProtectDay,
-	).Scan(
-		&rec.ID,
-		&rec.RequestID,
-		&rec.ReferrerBrokerID,
-		&rec.RefereeBrokerID,
-		&rec.FeeRate,
-		&rec.ProtectDays,
-		&rec.EffectiveAt,
-		&rec.CreatedAt,
-		&rec.UpdatedAt,
-	); err != nil {
-		return Record{}, fmt.Errorf("agreement: insert from match: %w", err)
-	}
+		ownerBrokerID,
+		candidateBroker,
+		terms.FeeRate,
+		terms.ProtectDays,
+	).QueryRow(func(row pgx.Row) error {
+		if err := row.Scan(
+			&rec.ID,
+			&rec.RequestID,
+			&rec.ReferrerBrokerID,
+			&rec.RefereeBrokerID,
+			&rec.FeeRate,
+			&rec.ProtectDays,
+			&rec.Currency,
+			&rec.EffectiveAt,
+			&rec.Status,
+			&rec.StatusUpdatedAt,
+			&rec.StatusUpdatedBy,
+			&rec.Version,
+			&rec.CreatedAt,
+			&rec.UpdatedAt,
+		); err != nil {
+			return fmt.Errorf("agreement: insert from match: %w", err)
+		}
+		return nil
+	})
 
 	// Update the referral to matched if still open.
 	if currentStatus == "open" {
-		if _, err := tx.Exec(ctx, `
+		writes.Queue(`
 UPDATE referral_requests
 SET status = 'matched',
     updated_at = get_tx_timestamp()
 WHERE id = $1 AND status = 'open'
-`, params.RequestID); err != nil {
-			return Record{}, fmt.Errorf("agreement: tag referral matched: %w", err)
-		}
+`, params.RequestID)
 	}
 
 	// Append a creation timeline event.
-	var acceptedAt time.Time
-	if err := tx.QueryRow(ctx, `SELECT get_tx_timestamp()`).Scan(&acceptedAt); err != nil {
-		return Record{}, fmt.Errorf("agreement: fetch accepted timestamp: %w", err)
-	}
-
-	if err := setTimelineBroker(ctx, tx, *ownerBrokerID, *candidateBroker, &params.AcceptedByUserID); err != nil {
-		return Record{}, err
-	}
-
 	timelinePayload := map[string]any{
 		"source":              "match_acceptance",
 		"match_id":            params.MatchID,
 		"accepted_at":         acceptedAt.UTC(),
 		"accepted_by_user_id": params.AcceptedByUserID,
 		"referral_owner_id":   ownerUserID,
+		"fee_rate":            terms.FeeRate,
+		"protect_days":        terms.ProtectDays,
 	}
-	if err := insertTimelineEvent(ctx, tx, rec.ID, "AGREEMENT_CREATED", params.AcceptedByUserID, timelinePayload); err != nil {
+	if err := queueTimelineEvent(&writes, agreementID, timeline.TypeAgreementCreated, params.AcceptedByUserID, timelinePayload); err != nil {
 		return Record{}, err
 	}
 
 	// Emit an outbox message for downstream delivery.
 	outboxPayload := map[string]any{
-		"agreement_id": rec.ID,
-		"referral_id":  rec.RequestID,
+		"agreement_id": agreementID,
+		"referral_id":  params.RequestID,
 		"match_id":     params.MatchID,
 		"candidate_id": params.CandidateUserID,
 		"status":       "pending_signature",
 		"owner_id":     ownerUserID,
 	}
-	if err := enqueueOutbox(ctx, tx, "agreement.created", outboxPayload); err != nil {
+	if err := queueOutbox(&writes, OutboxTopicAgreementCreated, outboxPayload); err != nil {
 		return Record{}, err
 	}
-
-	return rec, nil
-}
-
-func insertTimelineEvent(ctx context.Context, tx pgx.Tx, agreementID string, eventType string, actorID string, payload map[string]any) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("agreement: marshal timeline payload: %w", err)
-	}
-	var actor any
-	if actorID != "" {
-		actor = actorID
-	}
-	const q = `
-INSERT INTO timeline_events (agreement_id, type, payload, actor_id)
-VALUES ($1, $2::event_type, $3::jsonb, $4::uuid)
-`
-	if _, err := tx.Exec(ctx, q, agreementID, eventType, body, actor); err != nil {
-		return fmt.Errorf("agreement: insert timeline event: %w", err)
+	if err := writes.send(ctx, tx, "create from match"); err != nil {
+		return Record{}, err
 	}
-	return nil
-}
 
-func enqueueOutbox(ctx context.Context, tx pgx.Tx, topic string, payload map[string]any) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("agreement: marshal outbox payload: %w", err)
-	}
-	const q = `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`
-	if _, err := tx.Exec(ctx, q, topic, body); err != nil {
-		return fmt.Errorf("agreement: enqueue outbox: %w", err)
-	}
-	return nil
+	return rec, nil
 }
@@ -43,6 +43,24 @@ type ExecuteEsignCompletionParams struct {
 }
 
 const (
+	// OutboxTopicAgreementCreated is published when an agreement row is inserted.
+	OutboxTopicAgreementCreated = "agreement.created"
+	// OutboxTopicAgreementStatusChanged is published for every explicit status transition.
+	OutboxTopicAgreementStatusChanged = "agreement.status_changed"
 	// OutboxTopicAgreementEffective is published whenever an agreement becomes effective.
 	OutboxTopicAgreementEffective = "agreement.effective"
+	// OutboxTopicAgreementAmendmentProposed is published when a party proposes new terms.
+	OutboxTopicAgreementAmendmentProposed = "agreement.amendment_proposed"
+	// OutboxTopicAgreementAmendmentAccepted is published when the counterparty accepts proposed terms.
+	OutboxTopicAgreementAmendmentAccepted = "agreement.amendment_accepted"
+	// OutboxTopicAgreementAmendmentRejected is published when the counterparty rejects proposed terms.
+	OutboxTopicAgreementAmendmentRejected = "agreement.amendment_rejected"
+	// OutboxTopicAgreementDealEvent is published for each recorded deal lifecycle event.
+	OutboxTopicAgreementDealEvent = "agreement.deal_event"
+	// OutboxTopicAgreementExpired is published when an agreement's protect period lapses without a closed deal.
+	OutboxTopicAgreementExpired = "agreement.expired"
+	// OutboxTopicAgreementCancelled is published when a broker party cancels an agreement before it took effect.
+	OutboxTopicAgreementCancelled = "agreement.cancelled"
+	// OutboxTopicAgreementStatusCorrected is published when a broker admin corrects an erroneous status change.
+	OutboxTopicAgreementStatusCorrected = "agreement.status_corrected"
 )
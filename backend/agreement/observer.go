@@ -0,0 +1,18 @@
+package agreement
+
+// TransitionObserver is notified after an agreement status change commits.
+// from is empty when the previous status is not known to the caller.
+type TransitionObserver interface {
+	ObserveTransition(from, to string)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) ObserveTransition(string, string) {}
+
+func observerOrNoop(o TransitionObserver) TransitionObserver {
+	if o == nil {
+		return noopObserver{}
+	}
+	return o
+}
@@ -0,0 +1,286 @@
+package agreement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"brokerflow/timeline"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Projection is the part of an agreements row derived from its timeline.
+type Projection struct {
+	Status      string
+	EffectiveAt *time.Time
+	EventSeq    int64
+}
+
+// Divergence is an agreement whose stored columns disagree with its
+// replayed timeline. Fields names the columns that differ.
+type Divergence struct {
+	AgreementID string
+	Stored      Projection
+	Replayed    Projection
+	Fields      []string
+}
+
+// RebuildParams selects what Rebuild replays. An empty AgreementID replays
+// every agreement. Without Apply, divergences are only reported.
+type RebuildParams struct {
+	AgreementID string
+	Apply       bool
+}
+
+// RebuildReport summarises a rebuild. Skipped lists agreements without any
+// timeline event, which have nothing to replay.
+type RebuildReport struct {
+	Checked     int
+	Skipped     []string
+	Divergences []Divergence
+	Applied     int
+}
+
+// statusesWithEffectiveAt are the statuses chk_agreement_effective_at_pair
+// requires an effective_at for.
+var statusesWithEffectiveAt = []string{StatusEffective, StatusSuccess, StatusDisputed, StatusExpired}
+
+// replayProjection folds an agreement's timeline, in seq order, into the
+// columns the write paths maintain alongside it. effective_at follows the
+// same rules as the writes: e-sign records the time it carries, later
+// statuses that need one keep it or take the event's time, and any other
+// status clears it.
+func replayProjection(events []timeline.Event) (Projection, error) {
+	var p Projection
+	for _, ev := range events {
+		p.EventSeq = max(p.EventSeq, ev.Seq)
+		status, err := enteredStatus(ev)
+		if err != nil {
+			return Projection{}, fmt.Errorf("agreement: replay event %d: %w", ev.ID, err)
+		}
+		if status == "" {
+			continue
+		}
+		p.Status = status
+		if !slices.Contains(statusesWithEffectiveAt, status) {
+			p.EffectiveAt = nil
+			continue
+		}
+		if p.EffectiveAt != nil {
+			continue
+		}
+		at := ev.At
+		if ev.Type == timeline.TypeEsignCompleted {
+			var payload timeline.EsignCompletedPayload
+			if err := timeline.DecodeInto(ev.Type, ev.PayloadVersion, ev.Payload, &payload); err != nil {
+				return Projection{}, fmt.Errorf("agreement: replay event %d: %w", ev.ID, err)
+			}
+			if !payload.EffectiveAt.IsZero() {
+				at = payload.EffectiveAt
+			}
+		}
+		at = at.UTC()
+		p.EffectiveAt = &at
+	}
+	return p, nil
+}
+
+// diffProjection returns the columns that differ between stored and
+// replayed. Timestamps are compared to the microsecond Postgres keeps.
+func diffProjection(stored, replayed Projection) []string {
+	var fields []string
+	if stored.Status != replayed.Status {
+		fields = append(fields, "status")
+	}
+	switch {
+	case (stored.EffectiveAt == nil) != (replayed.EffectiveAt == nil):
+		fields = append(fields, "effective_at")
+	case stored.EffectiveAt != nil &&
+		!stored.EffectiveAt.Truncate(time.Microsecond).Equal(replayed.EffectiveAt.Truncate(time.Microsecond)):
+		fields = append(fields, "effective_at")
+	}
+	if stored.EventSeq != replayed.EventSeq {
+		fields = append(fields, "event_seq")
+	}
+	return fields
+}
+
+// Rebuilder replays timeline_events to check, and optionally repair, the
+// status, effective_at and event_seq of agreements. The timeline is
+// append-only, so after an incident it is the record the columns are
+// rebuilt from.
+type Rebuilder struct {
+	pool TxBeginner
+}
+
+func NewRebuilder(pool TxBeginner) *Rebuilder {
+	return &Rebuilder{pool: pool}
+}
+
+// Rebuild replays the selected agreements one transaction each, so a long
+// run holds no lock for longer than one agreement takes. With Apply, each
+// divergent row is corrected and the correction written to audit_logs.
+func (r *Rebuilder) Rebuild(ctx context.Context, params RebuildParams) (RebuildReport, error) {
+	ids := []string{params.AgreementID}
+	if params.AgreementID == "" {
+		var err error
+		if ids, err = r.agreementIDs(ctx); err != nil {
+			return RebuildReport{}, err
+		}
+	}
+
+	var report RebuildReport
+	for _, id := range ids {
+		div, replayed, err := r.rebuildOne(ctx, id, params.Apply)
+		if err != nil {
+			return report, err
+		}
+		report.Checked++
+		if !replayed {
+			report.Skipped = append(report.Skipped, id)
+			continue
+		}
+		if div != nil {
+			report.Divergences = append(report.Divergences, *div)
+			if params.Apply {
+				report.Applied++
+			}
+		}
+	}
+	return report, nil
+}
+
+func (r *Rebuilder) agreementIDs(ctx context.Context) ([]string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: begin rebuild: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id::text FROM agreements ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: list agreements: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan agreements: %w", err)
+	}
+	return ids, nil
+}
+
+// rebuildOne replays one agreement. It reports false when the agreement has
+// no timeline to replay.
+func (r *Rebuilder) rebuildOne(ctx context.Context, agreementID string, apply bool) (*Divergence, bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("agreement: begin rebuild: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the row keeps writers from appending events between the
+	// replay and the correction.
+	var stored Projection
+	if err := tx.QueryRow(ctx, `
+        SELECT status::text, effective_at, event_seq
+        FROM agreements
+        WHERE id = $1::uuid
+        FOR UPDATE
+    `, agreementID).Scan(&stored.Status, &stored.EffectiveAt, &stored.EventSeq); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, ErrAgreementNotFound
+		}
+		return nil, false, fmt.Errorf("agreement: load agreement %s: %w", agreementID, err)
+	}
+
+	events, err := loadTimeline(ctx, tx, agreementID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(events) == 0 {
+		return nil, false, nil
+	}
+	replayed, err := replayProjection(events)
+	if err != nil {
+		return nil, false, fmt.Errorf("agreement %s: %w", agreementID, err)
+	}
+	if replayed.Status == "" {
+		// Only events that leave the status alone: the creation event is
+		// missing, and the stored status is the only record of it.
+		replayed.Status = stored.Status
+		replayed.EffectiveAt = stored.EffectiveAt
+	}
+
+	fields := diffProjection(stored, replayed)
+	if len(fields) == 0 {
+		return nil, true, nil
+	}
+	div := &Divergence{AgreementID: agreementID, Stored: stored, Replayed: replayed, Fields: fields}
+	if !apply {
+		return div, true, nil
+	}
+
+	// A replayed ESIGN_COMPLETED implies both signatures; stamp any the row
+	// lacks so the effective_at it restores is allowed.
+	if _, err := tx.Exec(ctx, `
+        UPDATE agreements
+        SET status = $2::agreement_status, effective_at = $3, event_seq = $4,
+            referrer_signed_at = CASE WHEN $3::timestamptz IS NULL THEN referrer_signed_at ELSE COALESCE(referrer_signed_at, $3) END,
+            referee_signed_at = CASE WHEN $3::timestamptz IS NULL THEN referee_signed_at ELSE COALESCE(referee_signed_at, $3) END
+        WHERE id = $1::uuid
+    `, agreementID, replayed.Status, replayed.EffectiveAt, replayed.EventSeq); err != nil {
+		return nil, false, fmt.Errorf("agreement: apply rebuild of %s: %w", agreementID, err)
+	}
+	metadata, err := json.Marshal(map[string]any{
+		"agreement_id": agreementID,
+		"fields":       fields,
+		"stored":       projectionMetadata(stored),
+		"replayed":     projectionMetadata(replayed),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("agreement: marshal rebuild audit: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO audit_logs (actor_id, action, metadata)
+        VALUES (NULL, 'AGREEMENT_PROJECTION_REBUILT', $1::jsonb)
+    `, metadata); err != nil {
+		return nil, false, fmt.Errorf("agreement: write rebuild audit: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("agreement: commit rebuild: %w", err)
+	}
+	return div, true, nil
+}
+
+// loadTimeline reads an agreement's events in seq order.
+func loadTimeline(ctx context.Context, tx pgx.Tx, agreementID string) ([]timeline.Event, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT id, seq, type::text, ts, payload, payload_version
+        FROM timeline_events
+        WHERE agreement_id = $1::uuid
+        ORDER BY seq, id
+    `, agreementID)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: load timeline: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (timeline.Event, error) {
+		ev := timeline.Event{AgreementID: agreementID}
+		err := row.Scan(&ev.ID, &ev.Seq, &ev.Type, &ev.At, &ev.Payload, &ev.PayloadVersion)
+		return ev, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan timeline: %w", err)
+	}
+	return events, nil
+}
+
+func projectionMetadata(p Projection) map[string]any {
+	m := map[string]any{"status": p.Status, "event_seq": p.EventSeq, "effective_at": nil}
+	if p.EffectiveAt != nil {
+		m["effective_at"] = p.EffectiveAt.UTC()
+	}
+	return m
+}
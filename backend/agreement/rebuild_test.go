@@ -0,0 +1,59 @@
+package agreement
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+func TestReplayProjection_FollowsWriteRules(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	signedAt := t0.Add(26 * time.Hour)
+	seqEvent := func(ev timeline.Event, seq int64) timeline.Event {
+		ev.Seq = seq
+		return ev
+	}
+	events := []timeline.Event{
+		seqEvent(historyEvent(t, 1, timeline.TypeAgreementCreated, t0, map[string]any{"referral_id": "r1", "fee_rate": 25, "protect_days": 30}), 1),
+		seqEvent(historyEvent(t, 2, timeline.TypeAgreementStatusChanged, t0.Add(time.Hour), map[string]any{"previous_status": "draft", "next_status": "pending_signature"}), 2),
+		seqEvent(historyEvent(t, 3, timeline.TypeEsignCompleted, t0.Add(27*time.Hour), map[string]any{"agreement_id": "a1", "effective_at": signedAt}), 3),
+		seqEvent(historyEvent(t, 4, timeline.TypeOfferMade, t0.Add(30*time.Hour), nil), 4),
+		seqEvent(historyEvent(t, 5, timeline.TypeAgreementStatusChanged, t0.Add(40*time.Hour), map[string]any{"previous_status": "effective", "next_status": "disputed"}), 5),
+	}
+
+	got, err := replayProjection(events)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if got.Status != StatusDisputed || got.EventSeq != 5 {
+		t.Fatalf("expected disputed at seq 5, got %+v", got)
+	}
+	if got.EffectiveAt == nil || !got.EffectiveAt.Equal(signedAt) {
+		t.Fatalf("expected effective_at to stay at the e-sign time %s, got %v", signedAt, got.EffectiveAt)
+	}
+
+	events = append(events, seqEvent(historyEvent(t, 6, timeline.TypeAgreementStatusChanged, t0.Add(50*time.Hour), map[string]any{"previous_status": "disputed", "next_status": "void"}), 6))
+	got, err = replayProjection(events)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if got.Status != StatusVoid || got.EffectiveAt != nil {
+		t.Fatalf("expected void without effective_at, got %+v", got)
+	}
+}
+
+func TestDiffProjection(t *testing.T) {
+	at := time.Date(2026, 5, 2, 11, 0, 0, 0, time.UTC)
+	nanos := at.Add(300 * time.Nanosecond)
+	stored := Projection{Status: StatusEffective, EffectiveAt: &at, EventSeq: 3}
+
+	if fields := diffProjection(stored, Projection{Status: StatusEffective, EffectiveAt: &nanos, EventSeq: 3}); len(fields) != 0 {
+		t.Fatalf("expected sub-microsecond differences to be ignored, got %v", fields)
+	}
+	fields := diffProjection(stored, Projection{Status: StatusDisputed, EventSeq: 4})
+	if !slices.Equal(fields, []string{"status", "effective_at", "event_seq"}) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+}
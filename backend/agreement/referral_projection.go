@@ -0,0 +1,117 @@
+package agreement
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"brokerflow/timeline"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Referral statuses the agreement lifecycle moves a referral through, the
+// values of referral.Status, which imports this package.
+const (
+	ReferralOpen       = "open"
+	ReferralMatched    = "matched"
+	ReferralSigned     = "signed"
+	ReferralInProgress = "in_progress"
+	ReferralClosed     = "closed"
+	ReferralDisputed   = "disputed"
+)
+
+// referralReplaces lists, for each status a projection sets, the referral
+// statuses it may overwrite. Closed, disputed and cancelled are absent from
+// every list: a referral never leaves them because of a later agreement
+// moment.
+var referralReplaces = map[string][]string{
+	ReferralSigned:     {ReferralOpen, ReferralMatched},
+	ReferralInProgress: {ReferralOpen, ReferralMatched, ReferralSigned},
+	ReferralClosed:     {ReferralOpen, ReferralMatched, ReferralSigned, ReferralInProgress},
+	ReferralDisputed:   {ReferralOpen, ReferralMatched, ReferralSigned, ReferralInProgress},
+}
+
+// ReferralProjector derives a referral's status from the lifecycle of its
+// agreement: effective -> signed, OFFER_MADE and UNDER_CONTRACT ->
+// in_progress, DEAL_CLOSED -> closed, and a dispute -> disputed. Referrals
+// only move forward, so replaying a moment or recording one out of order
+// leaves a referral that is already further along alone.
+type ReferralProjector struct{}
+
+// ForStatus returns the referral status an agreement entering
+// agreementStatus implies.
+func (ReferralProjector) ForStatus(agreementStatus string) (string, bool) {
+	switch agreementStatus {
+	case StatusEffective:
+		return ReferralSigned, true
+	case StatusDisputed:
+		return ReferralDisputed, true
+	}
+	return "", false
+}
+
+// ForEvent returns the referral status a timeline event implies.
+func (ReferralProjector) ForEvent(eventType string) (string, bool) {
+	switch eventType {
+	case timeline.TypeOfferMade, timeline.TypeUnderContract:
+		return ReferralInProgress, true
+	case timeline.TypeDealClosed:
+		return ReferralClosed, true
+	}
+	return "", false
+}
+
+// ForDisputeOpened returns the referral status a newly opened dispute
+// implies.
+func (ReferralProjector) ForDisputeOpened() string {
+	return ReferralDisputed
+}
+
+// Advances reports whether a referral in current moves to next.
+func (ReferralProjector) Advances(current, next string) bool {
+	return slices.Contains(referralReplaces[next], current)
+}
+
+// projectReferralSQL moves the referral of agreement $1 to $2 when it is in
+// one of $3.
+const projectReferralSQL = `
+        UPDATE referral_requests rr
+        SET status = $2, updated_at = get_tx_timestamp()
+        FROM agreements a
+        WHERE a.id = $1 AND rr.id = a.referral_id AND rr.status = ANY($3)
+    `
+
+// Apply moves the referral of agreementID to next inside tx when Advances
+// allows it, and reports whether it did.
+func (p ReferralProjector) Apply(ctx context.Context, tx pgx.Tx, agreementID, next string) (bool, error) {
+	from, ok := referralReplaces[next]
+	if !ok {
+		return false, fmt.Errorf("agreement: no projection to referral status %q", next)
+	}
+	tag, err := tx.Exec(ctx, projectReferralSQL, agreementID, next, from)
+	if err != nil {
+		return false, fmt.Errorf("agreement: project referral status: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// queue queues Apply's update on b.
+func (p ReferralProjector) queue(b *batch, agreementID, next string) error {
+	from, ok := referralReplaces[next]
+	if !ok {
+		return fmt.Errorf("agreement: no projection to referral status %q", next)
+	}
+	b.Queue(projectReferralSQL, agreementID, next, from)
+	return nil
+}
+
+// projectReferral applies a For* result inside tx; ok false means the
+// moment does not touch the referral.
+func projectReferral(ctx context.Context, tx pgx.Tx, agreementID, next string, ok bool) error {
+	if !ok {
+		return nil
+	}
+	_, err := ReferralProjector{}.Apply(ctx, tx, agreementID, next)
+	return err
+}
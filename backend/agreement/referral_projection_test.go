@@ -0,0 +1,67 @@
+package agreement
+
+import (
+	"testing"
+
+	"brokerflow/timeline"
+)
+
+func TestReferralProjectorMapsLifecycle(t *testing.T) {
+	var p ReferralProjector
+	statuses := map[string]string{
+		StatusEffective: ReferralSigned,
+		StatusDisputed:  ReferralDisputed,
+	}
+	for _, status := range []string{StatusDraft, StatusPendingSignature, StatusEffective, StatusSuccess, StatusDisputed, StatusVoid, StatusClosed, StatusExpired, StatusCancelled} {
+		got, ok := p.ForStatus(status)
+		want, mapped := statuses[status]
+		if ok != mapped || got != want {
+			t.Errorf("ForStatus(%s) = %q, %v; want %q, %v", status, got, ok, want, mapped)
+		}
+	}
+
+	events := map[string]string{
+		timeline.TypeOfferMade:     ReferralInProgress,
+		timeline.TypeUnderContract: ReferralInProgress,
+		timeline.TypeDealClosed:    ReferralClosed,
+	}
+	for _, eventType := range []string{timeline.TypeOfferMade, timeline.TypeUnderContract, timeline.TypeDealClosed, timeline.TypeEsignCompleted, timeline.TypeAgreementStatusChanged, timeline.TypeProtectExpired} {
+		got, ok := p.ForEvent(eventType)
+		want, mapped := events[eventType]
+		if ok != mapped || got != want {
+			t.Errorf("ForEvent(%s) = %q, %v; want %q, %v", eventType, got, ok, want, mapped)
+		}
+	}
+
+	if got := p.ForDisputeOpened(); got != ReferralDisputed {
+		t.Errorf("ForDisputeOpened() = %q, want disputed", got)
+	}
+}
+
+func TestReferralProjectorOnlyAdvances(t *testing.T) {
+	var p ReferralProjector
+	cases := []struct {
+		current, next string
+		want          bool
+	}{
+		{ReferralOpen, ReferralSigned, true},
+		{ReferralMatched, ReferralSigned, true},
+		{ReferralSigned, ReferralSigned, false},
+		{ReferralInProgress, ReferralSigned, false},
+		{ReferralSigned, ReferralInProgress, true},
+		{ReferralInProgress, ReferralClosed, true},
+		{ReferralMatched, ReferralClosed, true},
+		{ReferralClosed, ReferralInProgress, false},
+		{ReferralSigned, ReferralDisputed, true},
+		{ReferralDisputed, ReferralClosed, false},
+		{ReferralClosed, ReferralDisputed, false},
+		{"cancelled", ReferralSigned, false},
+		{"cancelled", ReferralDisputed, false},
+		{ReferralOpen, ReferralOpen, false},
+	}
+	for _, tc := range cases {
+		if got := p.Advances(tc.current, tc.next); got != tc.want {
+			t.Errorf("Advances(%s, %s) = %v, want %v", tc.current, tc.next, got, tc.want)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"time"
 
+	"brokerflow/timeline"
+
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -17,6 +19,23 @@ var (
 	ErrDuplicateIdempotencyKey = errors.New("agreement: duplicate idempotency key")
 	// ErrAgreementNotFound is returned when no agreement row exists for the provided identifier.
 	ErrAgreementNotFound = errors.New("agreement: not found")
+	// ErrNotParty is returned when the caller belongs to neither broker on the agreement.
+	ErrNotParty = errors.New("agreement: caller is not a party to the agreement")
+	// ErrActiveAgreementExists is returned when the referral already has a
+	// draft, pending_signature or effective agreement.
+	ErrActiveAgreementExists = errors.New("agreement: referral already has an active agreement")
+	// ErrReferralNotFound is returned when an agreement names a referral
+	// that does not exist.
+	ErrReferralNotFound = errors.New("agreement: referral not found")
+	// ErrNotOwner is returned when the caller did not create the referral
+	// the agreement is for.
+	ErrNotOwner = errors.New("agreement: referral does not belong to user")
+	// ErrReferrerBrokerMismatch is returned when an agreement names a
+	// referrer broker other than the referral owner's brokerage.
+	ErrReferrerBrokerMismatch = errors.New("agreement: referrer broker is not the referral owner's brokerage")
+	// ErrInvalidParams wraps a missing or out-of-range field of a request;
+	// the wrapping message names the field.
+	ErrInvalidParams = errors.New("agreement: invalid parameters")
 )
 
 type Repository struct{}
@@ -49,12 +68,12 @@ func (r *Repository) ExecuteEsignCompletionTx(ctx context.Context, tx pgx.Tx, pa
 		return fmt.Errorf("agreement: missing agreement id")
 	}
 
-	effTime, fromBrokerID, toBrokerID, err := r.markAgreementEffective(ctx, tx, params.AgreementID)
+	effTime, err := r.markAgreementEffective(ctx, tx, params.AgreementID)
 	if err != nil {
 		return err
 	}
 
-	if err := r.appendTimelineEvent(ctx, tx, params, effTime, fromBrokerID, toBrokerID); err != nil {
+	if err := r.appendTimelineEvent(ctx, tx, params, effTime); err != nil {
 		return err
 	}
 
@@ -62,14 +81,20 @@ func (r *Repository) ExecuteEsignCompletionTx(ctx context.Context, tx pgx.Tx, pa
 		return err
 	}
 
-	return nil
+	next, ok := ReferralProjector{}.ForStatus(StatusEffective)
+	return projectReferral(ctx, tx, params.AgreementID, next, ok)
 }
 
-func (r *Repository) markAgreementEffective(ctx context.Context, tx pgx.Tx, agreementID string) (time.Time, string, string, error) {
+// markAgreementEffective sets the agreement effective. The e-sign provider
+// reports completion once both parties signed, so signatures not recorded
+// through SignatureService are stamped now.
+func (r *Repository) markAgreementEffective(ctx context.Context, tx pgx.Tx, agreementID string) (time.Time, error) {
 	const updateSQL = `
 UPDATE agreements
 SET status = 'effective',
-    effective_at = COALESCE(effective_at, get_tx_timestamp())
+    effective_at = COALESCE(effective_at, get_tx_timestamp()),
+    referrer_signed_at = COALESCE(referrer_signed_at, get_tx_timestamp()),
+    referee_signed_at = COALESCE(referee_signed_at, get_tx_timestamp())
 WHERE id = $1
 RETURNING effective_at, from_broker_id::text, to_broker_id::text;
 `
@@ -81,23 +106,19 @@ RETURNING effective_at, from_broker_id::text, to_broker_id::text;
 	)
 	if err := tx.QueryRow(ctx, updateSQL, agreementID).Scan(&effTime, &fromBrokerID, &toBrokerID); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return time.Time{}, "", "", ErrAgreementNotFound
+			return time.Time{}, ErrAgreementNotFound
 		}
-		return time.Time{}, "", "", fmt.Errorf("agreement: update effective: %w", err)
+		return time.Time{}, fmt.Errorf("agreement: update effective: %w", err)
 	}
 
 	if !fromBrokerID.Valid || !toBrokerID.Valid {
-		return time.Time{}, "", "", fmt.Errorf("agreement: broker linkage missing")
+		return time.Time{}, fmt.Errorf("agreement: broker linkage missing")
 	}
 
-	return effTime, fromBrokerID.String, toBrokerID.String, nil
+	return effTime, nil
 }
 
-func (r *Repository) appendTimelineEvent(ctx context.Context, tx pgx.Tx, params ExecuteEsignCompletionParams, effTime time.Time, fromBrokerID, toBrokerID string) error {
-	if err := setTimelineBroker(ctx, tx, fromBrokerID, toBrokerID, params.ActorID); err != nil {
-		return err
-	}
-
+func (r *Repository) appendTimelineEvent(ctx context.Context, tx pgx.Tx, params ExecuteEsignCompletionParams, effTime time.Time) error {
 	payload := params.TimelinePayload
 	if payload == nil {
 		payload = make(map[string]any, 3)
@@ -105,26 +126,11 @@ func (r *Repository) appendTimelineEvent(ctx context.Context, tx pgx.Tx, params
 	payload["agreement_id"] = params.AgreementID
 	payload["effective_at"] = effTime.UTC()
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("agreement: marshal timeline payload: %w", err)
-	}
-
-	var actorID any
+	var actorID string
 	if params.ActorID != nil {
 		actorID = *params.ActorID
 	}
-
-	const insertSQL = `
-INSERT INTO timeline_events (agreement_id, type, payload, actor_id)
-VALUES ($1, 'ESIGN_COMPLETED', $2, $3);
-`
-
-	if _, err := tx.Exec(ctx, insertSQL, params.AgreementID, payloadBytes, actorID); err != nil {
-		return fmt.Errorf("agreement: insert timeline event: %w", err)
-	}
-
-	return nil
+	return insertTimelineEvent(ctx, tx, params.AgreementID, timeline.TypeEsignCompleted, actorID, payload)
 }
 
 func (r *Repository) enqueueOutbox(ctx context.Context, tx pgx.Tx, params ExecuteEsignCompletionParams, effTime time.Time) error {
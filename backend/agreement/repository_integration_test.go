@@ -165,6 +165,23 @@ func TestEsignCompletion_Integration(t *testing.T) {
 		t.Fatalf("expected outbox messages to remain 1 after idempotent replay, got %d", outCount)
 	}
 
+	// The seq was claimed from agreements.event_seq, leaving no gap.
+	gaps, err := NewSequenceChecker(pool).Check(ctx, agreementID)
+	if err != nil {
+		t.Fatalf("check seqs: %v", err)
+	}
+	if len(gaps) != 0 {
+		t.Fatalf("expected no seq gaps, got %+v", gaps)
+	}
+
+	var unattributed int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM timeline_events WHERE agreement_id = $1 AND actor_broker_id IS NULL`, agreementID).Scan(&unattributed); err != nil {
+		t.Fatalf("count unattributed events: %v", err)
+	}
+	if unattributed != 0 {
+		t.Fatalf("expected every event to carry actor_broker_id, %d do not", unattributed)
+	}
+
 	// Cleanup idempotency key explicitly to avoid buildup
 	_, _ = pool.Exec(ctx, `DELETE FROM idempotency WHERE key = $1`, idemKey)
 }
@@ -0,0 +1,53 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/db/dbtest"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRepositoryInsertIdempotencyKey_MapsUniqueViolation(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectExec("INSERT INTO idempotency (key) VALUES ($1)").WithArgs("k1").WillReturnTag("INSERT 0 1")
+	mock.ExpectExec("INSERT INTO idempotency").WithArgs("k1").WillReturnError(&pgconn.PgError{Code: "23505"})
+	broken := &pgconn.PgError{Code: "40001"}
+	mock.ExpectExec("INSERT INTO idempotency").WithArgs("k2").WillReturnError(broken)
+
+	repo, tx := NewRepository(), mock.Tx()
+	ctx := context.Background()
+	if err := repo.InsertIdempotencyKey(ctx, tx, "k1"); err != nil {
+		t.Fatalf("first insert: %v", err)
+	}
+	if err := repo.InsertIdempotencyKey(ctx, tx, "k1"); !errors.Is(err, ErrDuplicateIdempotencyKey) {
+		t.Fatalf("expected ErrDuplicateIdempotencyKey, got %v", err)
+	}
+	if err := repo.InsertIdempotencyKey(ctx, tx, "k2"); !errors.Is(err, broken) || errors.Is(err, ErrDuplicateIdempotencyKey) {
+		t.Fatalf("expected the serialization failure wrapped, got %v", err)
+	}
+	if err := repo.InsertIdempotencyKey(ctx, tx, ""); err == nil {
+		t.Fatal("expected an empty key to be refused before any statement")
+	}
+}
+
+func TestRepositoryExecuteEsignCompletionTx_MapsMarkEffectiveErrors(t *testing.T) {
+	mock := dbtest.New(t)
+	columns := []string{"effective_at", "from_broker_id", "to_broker_id"}
+	mock.ExpectQuery("UPDATE agreements SET status = 'effective'").WithArgs("missing")
+	mock.ExpectQuery("RETURNING effective_at, from_broker_id::text, to_broker_id::text").WithArgs("a1").
+		WillReturnRows(dbtest.NewRows(columns...).AddRow(time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC), "b1", nil))
+
+	repo, tx := NewRepository(), mock.Tx()
+	ctx := context.Background()
+	if err := repo.ExecuteEsignCompletionTx(ctx, tx, ExecuteEsignCompletionParams{AgreementID: "missing"}); !errors.Is(err, ErrAgreementNotFound) {
+		t.Fatalf("expected ErrAgreementNotFound, got %v", err)
+	}
+	if err := repo.ExecuteEsignCompletionTx(ctx, tx, ExecuteEsignCompletionParams{AgreementID: "a1"}); err == nil || !strings.Contains(err.Error(), "broker linkage missing") {
+		t.Fatalf("expected the missing referee broker refused, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package agreement
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxReportedGaps caps the missing seqs listed per agreement; an agreement
+// missing more is broken in a way the first hundred already show.
+const maxReportedGaps = 100
+
+// SequenceGap is an agreement whose timeline seqs are not 1..EventSeq
+// without holes. Missing lists up to 100 absent seqs; EventSeq differs from
+// MaxSeq when agreements.event_seq moved without an event or the other way
+// round.
+type SequenceGap struct {
+	AgreementID string
+	EventSeq    int64
+	MaxSeq      int64
+	Events      int64
+	Missing     []int64
+}
+
+// SequenceChecker reports agreements whose timeline seqs have gaps, the
+// counterpart of oracle O3 for production data. The timeline is
+// append-only, so gaps are reported rather than repaired; cmd/rebuild can
+// realign event_seq with the timeline.
+type SequenceChecker struct {
+	pool querier
+}
+
+func NewSequenceChecker(pool querier) *SequenceChecker {
+	return &SequenceChecker{pool: pool}
+}
+
+// Check returns the gaps of one agreement, or of every agreement when
+// agreementID is empty, ordered by agreement.
+func (c *SequenceChecker) Check(ctx context.Context, agreementID string) ([]SequenceGap, error) {
+	rows, err := c.pool.Query(ctx, `
+        WITH stats AS (
+            SELECT a.id, a.event_seq, COALESCE(MAX(e.seq), 0) AS max_seq, COUNT(e.id) AS events
+            FROM agreements a
+            LEFT JOIN timeline_events e ON e.agreement_id = a.id
+            WHERE $1::text = '' OR a.id::text = $1
+            GROUP BY a.id, a.event_seq
+        )
+        SELECT s.id::text, s.event_seq, s.max_seq, s.events,
+               ARRAY(
+                   SELECT g FROM generate_series(1, s.max_seq) g
+                   WHERE NOT EXISTS (SELECT 1 FROM timeline_events e WHERE e.agreement_id = s.id AND e.seq = g)
+                   ORDER BY g
+                   LIMIT $2
+               )
+        FROM stats s
+        WHERE s.events <> s.max_seq OR s.event_seq <> s.max_seq
+        ORDER BY s.id
+    `, agreementID, maxReportedGaps)
+	if err != nil {
+		return nil, fmt.Errorf("agreement: check event seqs: %w", err)
+	}
+	gaps, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (SequenceGap, error) {
+		var g SequenceGap
+		err := row.Scan(&g.AgreementID, &g.EventSeq, &g.MaxSeq, &g.Events, &g.Missing)
+		return g, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agreement: scan event seq gaps: %w", err)
+	}
+	return gaps, nil
+}
@@ -23,6 +23,14 @@ type TxBeginner interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
+// DB is the part of pgxpool.Pool used by services that also read outside a
+// transaction.
+type DB interface {
+	TxBeginner
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 // EsignRepository defines the data access required by the service.
 type EsignRepository interface {
 	InsertIdempotencyKey(ctx context.Context, tx pgx.Tx, key string) error
@@ -30,8 +38,9 @@ type EsignRepository interface {
 }
 
 type Service struct {
-	pool TxBeginner
-	repo EsignRepository
+	pool     TxBeginner
+	repo     EsignRepository
+	observer TransitionObserver
 }
 
 func NewService(pool TxBeginner, repo EsignRepository) *Service {
@@ -39,11 +48,18 @@ func NewService(pool TxBeginner, repo EsignRepository) *Service {
 		repo = NewRepository()
 	}
 	return &Service{
-		pool: pool,
-		repo: repo,
+		pool:     pool,
+		repo:     repo,
+		observer: noopObserver{},
 	}
 }
 
+// WithObserver registers a hook notified when e-sign completion commits.
+func (s *Service) WithObserver(o TransitionObserver) *Service {
+	s.observer = observerOrNoop(o)
+	return s
+}
+
 // HandleEsignCompletionWebhook applies the full esign-completion transaction (Axioms A5 & A6).
 func (s *Service) HandleEsignCompletionWebhook(ctx context.Context, req EsignCompletionRequest) error {
 	if req.IdempotencyKey == "" {
@@ -81,6 +97,7 @@ func (s *Service) HandleEsignCompletionWebhook(ctx context.Context, req EsignCom
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("agreement: commit tx: %w", err)
 	}
+	s.observer.ObserveTransition("pending_signature", "effective")
 
 	return nil
 }
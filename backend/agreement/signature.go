@@ -0,0 +1,172 @@
+package agreement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/timeline"
+	"github.com/jackc/pgx/v5"
+)
+
+// Signing parties. The referring broker (from_broker_id) signs first and
+// the receiving broker (to_broker_id) countersigns.
+const (
+	PartyReferrer = "referrer"
+	PartyReferee  = "referee"
+)
+
+var (
+	// ErrNotSignable is returned for agreements that are not pending
+	// signature.
+	ErrNotSignable = errors.New("agreement: only agreements pending signature can be signed")
+	// ErrAlreadySigned is returned when the caller's side has signed, or
+	// the caller signed for the other side already.
+	ErrAlreadySigned = errors.New("agreement: already signed by the caller's side")
+	// ErrSignatureOutOfOrder is returned when the receiving broker signs
+	// before the referring broker.
+	ErrSignatureOutOfOrder = errors.New("agreement: the referring broker signs first")
+	// ErrSignaturesMissing is returned by StatusService.Transition for an
+	// agreement that is not signed by both parties yet.
+	ErrSignaturesMissing = errors.New("agreement: both parties must sign before the agreement takes effect")
+)
+
+// Signatures is the signing state of an agreement after a signature.
+type Signatures struct {
+	AgreementID      string
+	Status           string
+	ReferrerSignedAt *time.Time
+	ReferrerSignedBy *string
+	RefereeSignedAt  *time.Time
+	RefereeSignedBy  *string
+	EffectiveAt      *time.Time
+	Version          int
+}
+
+// SignatureService records per-party signatures. Each signature appends an
+// AGREEMENT_SIGNED timeline event; the second one also makes the agreement
+// effective exactly as e-sign completion does, with ESIGN_COMPLETED and
+// agreement.effective in the same transaction.
+type SignatureService struct {
+	pool     TxBeginner
+	repo     EsignRepository
+	observer TransitionObserver
+}
+
+func NewSignatureService(pool TxBeginner) *SignatureService {
+	return &SignatureService{pool: pool, repo: NewRepository(), observer: noopObserver{}}
+}
+
+// WithObserver registers a hook notified when a signature makes an
+// agreement effective.
+func (s *SignatureService) WithObserver(o TransitionObserver) *SignatureService {
+	s.observer = observerOrNoop(o)
+	return s
+}
+
+const signatureColumns = `id::text, status::text, referrer_signed_at, referrer_signed_by::text,
+       referee_signed_at, referee_signed_by::text, effective_at, version`
+
+func scanSignatures(row pgx.Row) (Signatures, error) {
+	var sig Signatures
+	err := row.Scan(&sig.AgreementID, &sig.Status, &sig.ReferrerSignedAt, &sig.ReferrerSignedBy,
+		&sig.RefereeSignedAt, &sig.RefereeSignedBy, &sig.EffectiveAt, &sig.Version)
+	return sig, err
+}
+
+// Sign records actorID's signature for the broker party they belong to.
+func (s *SignatureService) Sign(ctx context.Context, agreementID, actorID string) (Signatures, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Signatures{}, fmt.Errorf("agreement: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, from, to, actorBroker, err := agreementParties(ctx, tx, agreementID, actorID)
+	if err != nil {
+		return Signatures{}, err
+	}
+	if status != StatusPendingSignature {
+		return Signatures{}, ErrNotSignable
+	}
+	before, err := scanSignatures(tx.QueryRow(ctx, `SELECT `+signatureColumns+` FROM agreements WHERE id = $1`, agreementID))
+	if err != nil {
+		return Signatures{}, fmt.Errorf("agreement: load signatures: %w", err)
+	}
+	var open bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM agreement_amendments WHERE agreement_id = $1 AND status = 'proposed')`, agreementID).
+		Scan(&open); err != nil {
+		return Signatures{}, fmt.Errorf("agreement: check open amendments: %w", err)
+	}
+	if open {
+		return Signatures{}, ErrAmendmentOpen
+	}
+
+	party, partyBroker, err := signingParty(before, actorID, actorBroker, from, to)
+	if err != nil {
+		return Signatures{}, err
+	}
+
+	sig, err := scanSignatures(tx.QueryRow(ctx, `
+        UPDATE agreements
+        SET referrer_signed_at = CASE WHEN $2 = 'referrer' THEN get_tx_timestamp() ELSE referrer_signed_at END,
+            referrer_signed_by = CASE WHEN $2 = 'referrer' THEN $3::uuid ELSE referrer_signed_by END,
+            referee_signed_at = CASE WHEN $2 = 'referee' THEN get_tx_timestamp() ELSE referee_signed_at END,
+            referee_signed_by = CASE WHEN $2 = 'referee' THEN $3::uuid ELSE referee_signed_by END,
+            updated_at = get_tx_timestamp()
+        WHERE id = $1
+        RETURNING `+signatureColumns,
+		agreementID, party, actorID))
+	if err != nil {
+		return Signatures{}, fmt.Errorf("agreement: record signature: %w", err)
+	}
+	signedAt := sig.ReferrerSignedAt
+	if party == PartyReferee {
+		signedAt = sig.RefereeSignedAt
+	}
+	if err := insertTimelineEvent(ctx, tx, agreementID, timeline.TypeAgreementSigned, actorID, map[string]any{
+		"party":     party,
+		"broker_id": partyBroker,
+		"signed_at": signedAt.UTC(),
+	}); err != nil {
+		return Signatures{}, err
+	}
+
+	effective := party == PartyReferee
+	if effective {
+		if err := s.repo.ExecuteEsignCompletionTx(ctx, tx, ExecuteEsignCompletionParams{
+			AgreementID: agreementID,
+			ActorID:     &actorID,
+		}); err != nil {
+			return Signatures{}, err
+		}
+		if sig, err = scanSignatures(tx.QueryRow(ctx, `SELECT `+signatureColumns+` FROM agreements WHERE id = $1`, agreementID)); err != nil {
+			return Signatures{}, fmt.Errorf("agreement: load signatures: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Signatures{}, fmt.Errorf("agreement: commit signature: %w", err)
+	}
+	if effective {
+		s.observer.ObserveTransition(StatusPendingSignature, StatusEffective)
+	}
+	return sig, nil
+}
+
+// signingParty picks the side actorID signs for on an agreement pending
+// signature, given the broker they belong to. When both brokers are the
+// same the user signs for whichever side is next, but one user cannot sign
+// for both.
+func signingParty(sig Signatures, actorID, actorBroker, from, to string) (party, broker string, err error) {
+	switch {
+	case sig.ReferrerSignedAt == nil && actorBroker == from:
+		return PartyReferrer, from, nil
+	case sig.ReferrerSignedAt == nil:
+		return "", "", ErrSignatureOutOfOrder
+	case actorBroker == to && (sig.ReferrerSignedBy == nil || *sig.ReferrerSignedBy != actorID):
+		return PartyReferee, to, nil
+	}
+	return "", "", ErrAlreadySigned
+}
@@ -0,0 +1,41 @@
+package agreement
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSigningParty(t *testing.T) {
+	signedAt := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	alice := "user-alice"
+	referrerSigned := Signatures{ReferrerSignedAt: &signedAt, ReferrerSignedBy: &alice}
+
+	cases := []struct {
+		name        string
+		sig         Signatures
+		actor       string
+		actorBroker string
+		from, to    string
+		party       string
+		err         error
+	}{
+		{"referrer first", Signatures{}, alice, "b1", "b1", "b2", PartyReferrer, nil},
+		{"referee before referrer", Signatures{}, "user-bob", "b2", "b1", "b2", "", ErrSignatureOutOfOrder},
+		{"referee countersigns", referrerSigned, "user-bob", "b2", "b1", "b2", PartyReferee, nil},
+		{"referrer again", referrerSigned, "user-carol", "b1", "b1", "b2", "", ErrAlreadySigned},
+		{"same broker, second user", referrerSigned, "user-bob", "b1", "b1", "b1", PartyReferee, nil},
+		{"same broker, same user", referrerSigned, alice, "b1", "b1", "b1", "", ErrAlreadySigned},
+		{"legacy signature without signer", Signatures{ReferrerSignedAt: &signedAt}, alice, "b2", "b1", "b2", PartyReferee, nil},
+	}
+	for _, tc := range cases {
+		party, broker, err := signingParty(tc.sig, tc.actor, tc.actorBroker, tc.from, tc.to)
+		if !errors.Is(err, tc.err) || party != tc.party {
+			t.Errorf("%s: got %q, %v; want %q, %v", tc.name, party, err, tc.party, tc.err)
+			continue
+		}
+		if err == nil && broker != tc.actorBroker {
+			t.Errorf("%s: signed for broker %q, want %q", tc.name, broker, tc.actorBroker)
+		}
+	}
+}
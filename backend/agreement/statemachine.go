@@ -0,0 +1,131 @@
+package agreement
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"brokerflow/timeline"
+)
+
+// Agreement statuses, the values of the agreement_status enum.
+const (
+	StatusDraft            = "draft"
+	StatusPendingSignature = "pending_signature"
+	StatusEffective        = "effective"
+	StatusSuccess          = "success"
+	StatusDisputed         = "disputed"
+	StatusVoid             = "void"
+	StatusClosed           = "closed"
+	StatusExpired          = "expired"
+	// StatusCancelled is the terminal status of an agreement backed out of
+	// before it took effect.
+	StatusCancelled = "cancelled"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("agreement: unknown status")
+	ErrInvalidTransition = errors.New("agreement: invalid transition")
+)
+
+// Effect is what a transition records besides the status change: the
+// timeline event type and the outbox topic.
+type Effect struct {
+	TimelineType string
+	OutboxTopic  string
+}
+
+// StateMachine holds the statuses an agreement may take and the transitions
+// between them with their effects. Staying in the same status is always
+// allowed and records nothing new about the status.
+type StateMachine struct {
+	edges map[string]map[string]Effect
+}
+
+// NewStateMachine builds a machine with no statuses.
+func NewStateMachine() *StateMachine {
+	return &StateMachine{edges: make(map[string]map[string]Effect)}
+}
+
+// Allow permits from -> each of to, recording effect.
+func (m *StateMachine) Allow(effect Effect, from string, to ...string) *StateMachine {
+	m.addStatus(from)
+	for _, next := range to {
+		m.addStatus(next)
+		m.edges[from][next] = effect
+	}
+	return m
+}
+
+func (m *StateMachine) addStatus(status string) {
+	if _, ok := m.edges[status]; !ok {
+		m.edges[status] = make(map[string]Effect)
+	}
+}
+
+// Known reports whether status belongs to the machine.
+func (m *StateMachine) Known(status string) bool {
+	_, ok := m.edges[status]
+	return ok
+}
+
+// Can reports whether an agreement in from may move to to.
+func (m *StateMachine) Can(from, to string) bool {
+	_, err := m.Effect(from, to)
+	return err == nil
+}
+
+// Effect returns what moving from -> to records. It fails with
+// ErrUnknownStatus or ErrInvalidTransition; a transition to the same status
+// has a zero Effect.
+func (m *StateMachine) Effect(from, to string) (Effect, error) {
+	for _, status := range []string{from, to} {
+		if !m.Known(status) {
+			return Effect{}, fmt.Errorf("%w %q", ErrUnknownStatus, status)
+		}
+	}
+	if from == to {
+		return Effect{}, nil
+	}
+	effect, ok := m.edges[from][to]
+	if !ok {
+		return Effect{}, fmt.Errorf("%w %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return effect, nil
+}
+
+// Next lists the statuses reachable from status in one transition, sorted.
+func (m *StateMachine) Next(status string) []string {
+	next := make([]string, 0, len(m.edges[status]))
+	for to := range m.edges[status] {
+		next = append(next, to)
+	}
+	sort.Strings(next)
+	return next
+}
+
+// Terminal reports whether no transition leaves status.
+func (m *StateMachine) Terminal(status string) bool {
+	return m.Known(status) && len(m.edges[status]) == 0
+}
+
+// DefaultStateMachine is the agreement lifecycle enforced by
+// agreement_validate_transition (migration 000026). Explicit transitions
+// record AGREEMENT_STATUS_CHANGED and agreement.status_changed; cancelling
+// records AGREEMENT_CANCELLED and agreement.cancelled. The protect expiry
+// job writes its own PROTECT_EXPIRED event and agreement.expired message
+// for effective -> expired.
+func DefaultStateMachine() *StateMachine {
+	changed := Effect{TimelineType: timeline.TypeAgreementStatusChanged, OutboxTopic: OutboxTopicAgreementStatusChanged}
+	cancelled := Effect{TimelineType: timeline.TypeAgreementCancelled, OutboxTopic: OutboxTopicAgreementCancelled}
+	return NewStateMachine().
+		Allow(changed, StatusDraft, StatusPendingSignature, StatusVoid).
+		Allow(changed, StatusPendingSignature, StatusEffective, StatusVoid).
+		Allow(changed, StatusEffective, StatusSuccess, StatusDisputed, StatusVoid, StatusClosed, StatusExpired).
+		Allow(changed, StatusDisputed, StatusVoid, StatusClosed).
+		Allow(changed, StatusSuccess, StatusClosed).
+		Allow(changed, StatusVoid, StatusClosed).
+		Allow(changed, StatusExpired, StatusClosed).
+		Allow(cancelled, StatusDraft, StatusCancelled).
+		Allow(cancelled, StatusPendingSignature, StatusCancelled)
+}
@@ -0,0 +1,137 @@
+package agreement
+
+import (
+	"errors"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"brokerflow/db"
+	"brokerflow/timeline"
+)
+
+// sqlTransitions parses the latest definition of agreement_validate_transition
+// from the migrations into from -> set of to.
+func sqlTransitions(t *testing.T) map[string]map[string]bool {
+	t.Helper()
+	files, err := fs.Glob(db.Migrations, "*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("list migrations: %v", err)
+	}
+	slices.Sort(files)
+	var body string
+	for _, f := range files {
+		raw, err := fs.ReadFile(db.Migrations, f)
+		if err != nil {
+			t.Fatalf("read %s: %v", f, err)
+		}
+		s := string(raw)
+		if i := strings.Index(s, "FUNCTION agreement_validate_transition(prev"); i >= 0 {
+			end := strings.Index(s[i:], "$$;")
+			body = s[i : i+end]
+		}
+	}
+	if body == "" {
+		t.Fatal("agreement_validate_transition not found in migrations")
+	}
+
+	list := func(s string) []string {
+		var out []string
+		for _, item := range strings.Split(s, ",") {
+			out = append(out, strings.Trim(strings.TrimSpace(item), "'"))
+		}
+		return out
+	}
+	edges := map[string]map[string]bool{}
+	add := func(froms, tos []string) {
+		for _, from := range froms {
+			if edges[from] == nil {
+				edges[from] = map[string]bool{}
+			}
+			for _, to := range tos {
+				edges[from][to] = true
+			}
+		}
+	}
+	rule := regexp.MustCompile(`IF prev (= '\w+'|IN \([^)]*\)) AND next (= '\w+'|IN \([^)]*\)) THEN`)
+	operand := func(s string) []string {
+		if v, ok := strings.CutPrefix(s, "= "); ok {
+			return list(v)
+		}
+		return list(strings.TrimSuffix(strings.TrimPrefix(s, "IN ("), ")"))
+	}
+	for _, m := range rule.FindAllStringSubmatch(body, -1) {
+		add(operand(m[1]), operand(m[2]))
+	}
+	return edges
+}
+
+func TestDefaultStateMachine_MatchesSQL(t *testing.T) {
+	want := sqlTransitions(t)
+	m := DefaultStateMachine()
+	statuses := []string{StatusDraft, StatusPendingSignature, StatusEffective, StatusSuccess, StatusDisputed,
+		StatusVoid, StatusClosed, StatusExpired, StatusCancelled}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if from == to {
+				continue
+			}
+			if got := m.Can(from, to); got != want[from][to] {
+				t.Errorf("%s -> %s: state machine allows=%v, SQL allows=%v", from, to, got, want[from][to])
+			}
+		}
+	}
+}
+
+func TestDefaultStateMachine_Effects(t *testing.T) {
+	m := DefaultStateMachine()
+	cases := []struct {
+		from, to string
+		want     Effect
+	}{
+		{StatusPendingSignature, StatusEffective, Effect{timeline.TypeAgreementStatusChanged, OutboxTopicAgreementStatusChanged}},
+		{StatusEffective, StatusExpired, Effect{timeline.TypeAgreementStatusChanged, OutboxTopicAgreementStatusChanged}},
+		{StatusDraft, StatusCancelled, Effect{timeline.TypeAgreementCancelled, OutboxTopicAgreementCancelled}},
+		{StatusPendingSignature, StatusCancelled, Effect{timeline.TypeAgreementCancelled, OutboxTopicAgreementCancelled}},
+		{StatusEffective, StatusEffective, Effect{}},
+	}
+	for _, tc := range cases {
+		got, err := m.Effect(tc.from, tc.to)
+		if err != nil || got != tc.want {
+			t.Errorf("%s -> %s: got %+v, %v; want %+v", tc.from, tc.to, got, err, tc.want)
+		}
+	}
+}
+
+func TestStateMachine_Rejects(t *testing.T) {
+	m := DefaultStateMachine()
+	if _, err := m.Effect(StatusEffective, StatusCancelled); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := m.Effect(StatusClosed, StatusDraft); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := m.Effect(StatusDraft, "archived"); !errors.Is(err, ErrUnknownStatus) {
+		t.Fatalf("expected ErrUnknownStatus, got %v", err)
+	}
+	if m.Can("archived", "archived") {
+		t.Fatal("expected an unknown status to be rejected even without a change")
+	}
+}
+
+func TestStateMachine_NextAndTerminal(t *testing.T) {
+	m := DefaultStateMachine()
+	if got := m.Next(StatusPendingSignature); !slices.Equal(got, []string{StatusCancelled, StatusEffective, StatusVoid}) {
+		t.Fatalf("unexpected next statuses %v", got)
+	}
+	for _, status := range []string{StatusClosed, StatusCancelled} {
+		if !m.Terminal(status) {
+			t.Errorf("expected %s to be terminal", status)
+		}
+	}
+	if m.Terminal(StatusEffective) || m.Terminal("archived") {
+		t.Fatal("expected effective and unknown statuses not to be terminal")
+	}
+}
@@ -3,20 +3,39 @@ package agreement
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"brokerflow/db"
+	"brokerflow/timeline"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 // StatusService handles status transitions on agreements ensuring timeline and
 // outbox writes are captured in the same transaction.
 type StatusService struct {
-	pool *pgxpool.Pool
+	tx       db.TxRunner
+	observer TransitionObserver
+	machine  *StateMachine
+}
+
+func NewStatusService(pool TxBeginner) *StatusService {
+	return &StatusService{tx: db.NewUnitOfWork(pool).WithIsolation(pgx.Serializable), observer: noopObserver{}, machine: DefaultStateMachine()}
+}
+
+// WithStateMachine replaces the DefaultStateMachine that validates
+// transitions and picks their timeline type and outbox topic.
+func (s *StatusService) WithStateMachine(m *StateMachine) *StatusService {
+	s.machine = m
+	return s
 }
 
-func NewStatusService(pool *pgxpool.Pool) *StatusService {
-	return &StatusService{pool: pool}
+// WithObserver registers a hook notified after each committed transition.
+func (s *StatusService) WithObserver(o TransitionObserver) *StatusService {
+	s.observer = observerOrNoop(o)
+	return s
 }
 
 type TransitionParams struct {
@@ -24,96 +43,139 @@ type TransitionParams struct {
 	ActorID     string
 	NextStatus  string
 	Payload     map[string]any
+	// IfVersion, when non-zero, must equal the agreement's current version.
+	IfVersion int
 }
 
-func (s *StatusService) Transition(ctx context.Context, params TransitionParams) error {
-	tx, err := s.pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
+// ErrVersionConflict is matched by VersionConflictError.
+var ErrVersionConflict = errors.New("agreement: version conflict")
 
-	var (
-		current      string
-		fromBrokerID sql.NullString
-		toBrokerID   sql.NullString
-	)
-	if err := tx.QueryRow(ctx, `SELECT status, from_broker_id::text, to_broker_id::text FROM agreements WHERE id=$1 FOR UPDATE`, params.AgreementID).
-		Scan(&current, &fromBrokerID, &toBrokerID); err != nil {
-		return fmt.Errorf("agreement: fetch current status: %w", err)
-	}
-	if !fromBrokerID.Valid || !toBrokerID.Valid {
-		return fmt.Errorf("agreement: broker linkage missing")
-	}
+// VersionConflictError reports a transition requested against a stale
+// version, with the agreement's current status and version.
+type VersionConflictError struct {
+	Status  string
+	Version int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("agreement: version conflict: agreement is %s at version %d", e.Status, e.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool { return target == ErrVersionConflict }
 
-	var ok bool
-	if err := tx.QueryRow(ctx, `SELECT agreement_validate_transition($1::agreement_status,$2::agreement_status)`, current, params.NextStatus).Scan(&ok); err != nil {
-		return fmt.Errorf("agreement: validate transition: %w", err)
+// Transition moves the agreement to params.NextStatus and returns its new
+// version. It fails with ErrAgreementNotFound, ErrUnknownStatus,
+// ErrInvalidTransition, ErrSignaturesMissing or a *VersionConflictError.
+func (s *StatusService) Transition(ctx context.Context, params TransitionParams) (int, error) {
+	if params.NextStatus == StatusCancelled {
+		return 0, ErrCancelViaTransition
 	}
-	if !ok {
-		return fmt.Errorf("agreement: invalid transition %s -> %s", current, params.NextStatus)
+	if _, err := uuid.Parse(params.AgreementID); err != nil {
+		return 0, ErrAgreementNotFound
 	}
-
-	if _, err := tx.Exec(ctx, `
+	// Concurrent transitions of one agreement queue on its row lock; at
+	// serializable isolation the later one then fails instead of acting on
+	// the status it read, and runs again against the committed one.
+	var (
+		current string
+		version int
+	)
+	err := db.Retry(ctx, db.DefaultRetryPolicy, func(ctx context.Context) error {
+		return s.tx.InTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+			var (
+				fromBrokerID sql.NullString
+				toBrokerID   sql.NullString
+				signed       bool
+			)
+			err := tx.QueryRow(ctx, `
+        SELECT status, version, from_broker_id::text, to_broker_id::text,
+               referrer_signed_at IS NOT NULL AND referee_signed_at IS NOT NULL
+        FROM agreements WHERE id=$1 FOR UPDATE`, params.AgreementID).
+				Scan(&current, &version, &fromBrokerID, &toBrokerID, &signed)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAgreementNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("agreement: fetch current status: %w", err)
+			}
+			if params.IfVersion != 0 && params.IfVersion != version {
+				return &VersionConflictError{Status: current, Version: version}
+			}
+			if !fromBrokerID.Valid || !toBrokerID.Valid {
+				return fmt.Errorf("agreement: broker linkage missing")
+			}
+
+			effect, err := s.machine.Effect(current, params.NextStatus)
+			if err != nil {
+				return err
+			}
+			if params.NextStatus == StatusEffective && current != StatusEffective && !signed {
+				return ErrSignaturesMissing
+			}
+			if effect == (Effect{}) {
+				// Re-asserting the current status still records the change event.
+				effect = Effect{TimelineType: timeline.TypeAgreementStatusChanged, OutboxTopic: OutboxTopicAgreementStatusChanged}
+			}
+			if err := db.CheckContext(ctx, "updating the status"); err != nil {
+				return err
+			}
+
+			// The update, its timeline event, the outbox message and the
+			// referral projection go to the server in one round trip.
+			var b batch
+			b.Queue(`
         UPDATE agreements
         SET status=$1::agreement_status,
+            effective_at=CASE
+                WHEN $1::agreement_status IN ('effective','success','disputed','expired') THEN COALESCE(effective_at, get_tx_timestamp())
+                ELSE NULL
+            END,
             status_updated_at=get_tx_timestamp(),
             status_updated_by=$2::uuid,
             updated_at=get_tx_timestamp()
         WHERE id=$3
-    `, params.NextStatus, params.ActorID, params.AgreementID); err != nil {
-		return fmt.Errorf("agreement: update status: %w", err)
-	}
-
-	var actorPtr *string
-	if params.ActorID != "" {
-		actorPtr = &params.ActorID
-	}
-	if err := setTimelineBroker(ctx, tx, fromBrokerID.String, toBrokerID.String, actorPtr); err != nil {
-		return err
-	}
-
-	payload := map[string]any{
-		"previous_status": current,
-		"next_status":     params.NextStatus,
-	}
-	for k, v := range params.Payload {
-		payload[k] = v
-	}
-	if params.ActorID != "" {
-		payload["actor_id"] = params.ActorID
-	}
-
-	if _, err := tx.Exec(ctx, `
-        INSERT INTO timeline_events (agreement_id, type, payload, actor_id)
-        VALUES ($1,'AGREEMENT_STATUS_CHANGED',$2::jsonb,$3::uuid)
-    `, params.AgreementID, toJSON(payload), actorPtr); err != nil {
-		return fmt.Errorf("agreement: insert timeline: %w", err)
-	}
-
-	outboxPayload := map[string]any{
-		"agreement_id": params.AgreementID,
-		"previous":     current,
-		"next":         params.NextStatus,
-	}
-	if _, err := tx.Exec(ctx, `
-        INSERT INTO outbox (topic, payload)
-        VALUES ('agreement.status_changed',$1::jsonb)
-    `, toJSON(outboxPayload)); err != nil {
-		return fmt.Errorf("agreement: enqueue outbox: %w", err)
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("agreement: commit transition: %w", err)
-	}
-
-	return nil
-}
-
-func toJSON(m map[string]any) string {
-	b, err := json.Marshal(m)
+        RETURNING version
+    `, params.NextStatus, params.ActorID, params.AgreementID).QueryRow(func(row pgx.Row) error {
+				if err := row.Scan(&version); err != nil {
+					return fmt.Errorf("agreement: update status: %w", err)
+				}
+				return nil
+			})
+
+			payload := map[string]any{
+				"previous_status": current,
+				"next_status":     params.NextStatus,
+			}
+			for k, v := range params.Payload {
+				payload[k] = v
+			}
+			if params.ActorID != "" {
+				payload["actor_id"] = params.ActorID
+			}
+			if err := queueTimelineEvent(&b, params.AgreementID, effect.TimelineType, params.ActorID, payload); err != nil {
+				return err
+			}
+
+			outboxPayload := map[string]any{
+				"agreement_id": params.AgreementID,
+				"previous":     current,
+				"next":         params.NextStatus,
+			}
+			if err := queueOutbox(&b, effect.OutboxTopic, outboxPayload); err != nil {
+				return err
+			}
+			if next, ok := (ReferralProjector{}).ForStatus(params.NextStatus); ok {
+				if err := (ReferralProjector{}).queue(&b, params.AgreementID, next); err != nil {
+					return err
+				}
+			}
+			return b.send(ctx, tx, "record transition")
+		})
+	})
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	return string(b)
+	s.observer.ObserveTransition(current, params.NextStatus)
+
+	return version, nil
 }
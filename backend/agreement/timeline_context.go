@@ -2,29 +2,64 @@ package agreement
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
 )
 
-func setTimelineBroker(ctx context.Context, tx pgx.Tx, fromBrokerID, toBrokerID string, actorID *string) error {
-	brokerID := fromBrokerID
-	if actorID != nil && *actorID != "" {
-		var actorBroker sql.NullString
-		if err := tx.QueryRow(ctx, `SELECT broker_id::text FROM users WHERE id = $1`, *actorID).Scan(&actorBroker); err == nil && actorBroker.Valid {
-			if actorBroker.String == fromBrokerID || actorBroker.String == toBrokerID {
-				brokerID = actorBroker.String
-			}
-		}
-	}
+// ErrBrokerContextMissing is returned when a timeline event cannot be
+// attributed to a broker party: the agreement has no from-broker and the
+// actor belongs to neither party. trg_guard_timeline_writer would reject
+// the insert, and oracle O8 requires every event to name its broker.
+var ErrBrokerContextMissing = errors.New("agreement: timeline broker context missing")
 
-	if brokerID == "" {
-		return fmt.Errorf("agreement: timeline broker context missing")
-	}
+// timelineSlot is where the next event of an agreement's timeline goes.
+type timelineSlot struct {
+	seq         int64
+	actorBroker string
+}
+
+// claimSlotSQL claims the agreement's next timeline seq and resolves the
+// broker the event is recorded under: the actor's broker when it is a
+// party, otherwise the from-broker. It also sets app.broker_id for
+// trg_guard_timeline_writer, to the empty string when no broker qualifies.
+// The seq increment locks the agreement row until the transaction ends, so
+// concurrent writers queue instead of racing for MAX(seq)+1, and a rollback
+// returns the number.
+const claimSlotSQL = `
+        WITH slot AS (
+            UPDATE agreements SET event_seq = event_seq + 1
+            WHERE id = $1
+            RETURNING event_seq, from_broker_id, to_broker_id
+        ), broker AS (
+            SELECT COALESCE((
+                SELECT u.broker_id FROM users u
+                WHERE u.id = NULLIF($2, '')::uuid AND u.broker_id IN (slot.from_broker_id, slot.to_broker_id)
+            ), slot.from_broker_id)::text AS id
+            FROM slot
+        )
+        SELECT slot.event_seq, set_config('app.broker_id', COALESCE(broker.id, ''), true)
+        FROM slot, broker
+    `
 
-	if _, err := tx.Exec(ctx, `SET LOCAL app.broker_id = $1`, brokerID); err != nil {
-		return fmt.Errorf("agreement: set timeline broker: %w", err)
+// claimTimelineSlot runs claimSlotSQL for an event actorID records on
+// agreementID.
+func claimTimelineSlot(ctx context.Context, tx pgx.Tx, agreementID, actorID string) (timelineSlot, error) {
+	return scanTimelineSlot(tx.QueryRow(ctx, claimSlotSQL, agreementID, actorID), agreementID)
+}
+
+func scanTimelineSlot(row pgx.Row, agreementID string) (timelineSlot, error) {
+	var slot timelineSlot
+	err := row.Scan(&slot.seq, &slot.actorBroker)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return timelineSlot{}, ErrAgreementNotFound
+	}
+	if err != nil {
+		return timelineSlot{}, fmt.Errorf("agreement: claim event seq: %w", err)
+	}
+	if slot.actorBroker == "" {
+		return timelineSlot{}, fmt.Errorf("%w for agreement %s", ErrBrokerContextMissing, agreementID)
 	}
-	return nil
+	return slot, nil
 }
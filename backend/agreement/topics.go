@@ -0,0 +1,153 @@
+package agreement
+
+import (
+	"time"
+
+	"brokerflow/outbox"
+)
+
+// AgreementCreatedPayload is published on agreement.created. Agreements
+// created from an accepted match carry the match, candidate and owner fields.
+type AgreementCreatedPayload struct {
+	AgreementID string `json:"agreement_id"`
+	ReferralID  string `json:"referral_id"`
+	MatchID     string `json:"match_id,omitempty"`
+	CandidateID string `json:"candidate_id,omitempty"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	Status      string `json:"status,omitempty" doc:"Initial status, pending_signature for match acceptance"`
+}
+
+// AgreementStatusChangedPayload is published on agreement.status_changed.
+type AgreementStatusChangedPayload struct {
+	AgreementID string `json:"agreement_id"`
+	Previous    string `json:"previous"`
+	Next        string `json:"next"`
+}
+
+// AgreementEffectivePayload is published on agreement.effective. Webhook
+// callers may attach extra fields, which are passed through untouched.
+type AgreementEffectivePayload struct {
+	AgreementID string    `json:"agreement_id"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// AgreementAmendmentPayload is published on the agreement.amendment_* topics.
+type AgreementAmendmentPayload struct {
+	AgreementID string  `json:"agreement_id"`
+	AmendmentID string  `json:"amendment_id"`
+	Status      string  `json:"status" doc:"proposed, accepted or rejected"`
+	FeeRate     float64 `json:"fee_rate"`
+	ProtectDays int     `json:"protect_days"`
+	ActorID     string  `json:"actor_id" doc:"User who proposed or answered"`
+}
+
+// AgreementDealEventPayload is published on agreement.deal_event.
+type AgreementDealEventPayload struct {
+	AgreementID string    `json:"agreement_id"`
+	EventID     int64     `json:"event_id" doc:"timeline_events.id"`
+	Seq         int64     `json:"seq" doc:"Per-agreement timeline sequence number"`
+	Type        string    `json:"type" doc:"OFFER_MADE, UNDER_CONTRACT or DEAL_CLOSED"`
+	ActorID     string    `json:"actor_id"`
+	At          time.Time `json:"at"`
+	// Payload is the event's timeline payload as written; consumers pass it
+	// through timeline.Decode with PayloadVersion.
+	Payload        map[string]any `json:"payload,omitempty" doc:"Timeline payload supplied by the recording broker"`
+	PayloadVersion int            `json:"payload_version,omitempty" doc:"timeline_events.payload_version of Payload"`
+}
+
+// AgreementExpiredPayload is published on agreement.expired.
+type AgreementExpiredPayload struct {
+	AgreementID string    `json:"agreement_id"`
+	EffectiveAt time.Time `json:"effective_at"`
+	ProtectDays int       `json:"protect_days"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+// AgreementCancelledPayload is published on agreement.cancelled.
+type AgreementCancelledPayload struct {
+	AgreementID      string    `json:"agreement_id"`
+	ReferralID       string    `json:"referral_id"`
+	Previous         string    `json:"previous" doc:"draft or pending_signature"`
+	Reason           string    `json:"reason"`
+	CancelledBy      string    `json:"cancelled_by"`
+	CancelledAt      time.Time `json:"cancelled_at"`
+	ReferralReopened bool      `json:"referral_reopened" doc:"The referral went from matched back to open"`
+}
+
+// AgreementStatusCorrectedPayload is published on
+// agreement.status_corrected.
+type AgreementStatusCorrectedPayload struct {
+	AgreementID     string    `json:"agreement_id"`
+	Previous        string    `json:"previous"`
+	Next            string    `json:"next"`
+	CorrectsEventID int64     `json:"corrects_event_id" doc:"The timeline event that set the wrong status"`
+	Justification   string    `json:"justification"`
+	CorrectedBy     string    `json:"corrected_by"`
+	CorrectedAt     time.Time `json:"corrected_at"`
+}
+
+// OutboxTopics declares the topics this package enqueues.
+func OutboxTopics() []outbox.Topic {
+	return []outbox.Topic{
+		{
+			Name:        OutboxTopicAgreementCreated,
+			Producer:    "agreement",
+			Description: "An agreement was created, either directly or by accepting a referral match. Emitted once per agreement in the creating transaction.",
+			Payload:     AgreementCreatedPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementStatusChanged,
+			Producer:    "agreement",
+			Description: "An agreement moved between statuses via an explicit transition. Emitted once per committed transition, in order per agreement.",
+			Payload:     AgreementStatusChangedPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementEffective,
+			Producer:    "agreement",
+			Description: "E-sign completed and the agreement became effective. Deduplicated by webhook idempotency key, so emitted at most once per signature.",
+			Payload:     AgreementEffectivePayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementAmendmentProposed,
+			Producer:    "agreement",
+			Description: "A broker party proposed new fee rate and protect days on a pending_signature agreement.",
+			Payload:     AgreementAmendmentPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementAmendmentAccepted,
+			Producer:    "agreement",
+			Description: "The counterparty accepted an amendment; the agreement now carries the proposed terms.",
+			Payload:     AgreementAmendmentPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementAmendmentRejected,
+			Producer:    "agreement",
+			Description: "The counterparty rejected an amendment; the agreement terms are unchanged.",
+			Payload:     AgreementAmendmentPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementDealEvent,
+			Producer:    "agreement",
+			Description: "A deal milestone (offer made, under contract, closed) was recorded on an effective agreement. Emitted once per event, in seq order per agreement.",
+			Payload:     AgreementDealEventPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementExpired,
+			Producer:    "agreement",
+			Description: "The protect period (effective_at + protect_days) ended without a DEAL_CLOSED event and the expiry job moved the agreement to expired. Emitted once per agreement.",
+			Payload:     AgreementExpiredPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementCancelled,
+			Producer:    "agreement",
+			Description: "A broker party cancelled an agreement awaiting signature, giving a reason; the referral is re-opened if it was matched. Emitted once per agreement.",
+			Payload:     AgreementCancelledPayload{},
+		},
+		{
+			Name:        OutboxTopicAgreementStatusCorrected,
+			Producer:    "agreement",
+			Description: "A broker admin overrode the status an erroneous timeline event set, outside the status machine, giving a justification. Emitted once per correction.",
+			Payload:     AgreementStatusCorrectedPayload{},
+		},
+	}
+}
@@ -0,0 +1,363 @@
+package apidoc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BearerAuth is the security scheme name used for JWT-protected routes.
+const BearerAuth = "bearerAuth"
+
+// APIKeyAuth is the security scheme name for routes that also accept an API
+// key; see Builder.WithAPIKeyHeader.
+const APIKeyAuth = "apiKeyAuth"
+
+// Route declares one documented operation. Request and response bodies are
+// sample values (usually zero values of the handler's types); their schemas are
+// derived via reflection from the `json` struct tags so the document cannot
+// drift from the wire format.
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Tags    []string
+	Auth    bool
+	// APIKeyScope, when set on an Auth route, documents that an API key
+	// granted this scope may call the route instead of a bearer token.
+	APIKeyScope string
+	Params      []Parameter
+	Request     any
+	// RequestContentType defaults to application/json; routes taking an
+	// upload set it to e.g. text/csv and a string Request.
+	RequestContentType string
+	Responses          []Reply
+	OperationID        string
+}
+
+// Reply documents one status code for a Route. Body may be nil for responses
+// without a JSON payload. ContentType defaults to application/json; streaming
+// routes set it to e.g. text/event-stream and describe Body as one message.
+type Reply struct {
+	Status      int
+	Description string
+	Body        any
+	ContentType string
+}
+
+// Builder accumulates routes into a Document.
+type Builder struct {
+	doc   Document
+	names map[reflect.Type]string
+}
+
+// NewBuilder starts an empty document with bearer authentication declared.
+func NewBuilder(title, version, description string) *Builder {
+	return &Builder{
+		doc: Document{
+			OpenAPI: "3.0.3",
+			Info: Info{
+				Title:       title,
+				Version:     version,
+				Description: description,
+			},
+			Paths: make(map[string]PathItem),
+			Components: Components{
+				Schemas: make(map[string]*Schema),
+				SecuritySchemes: map[string]SecurityScheme{
+					BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// WithAPIKeyHeader declares API key authentication passed in header.
+func (b *Builder) WithAPIKeyHeader(header string) *Builder {
+	b.doc.Components.SecuritySchemes[APIKeyAuth] = SecurityScheme{Type: "apiKey", In: "header", Name: header}
+	return b
+}
+
+// PathParam is a convenience constructor for a required string path parameter.
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// QueryParam is a convenience constructor for an optional query parameter.
+func QueryParam(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+// HeaderParam is a convenience constructor for a required string header.
+func HeaderParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Required: true, Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// Add registers a route. Adding the same method and path twice replaces the
+// earlier declaration.
+func (b *Builder) Add(route Route) {
+	method := strings.ToLower(route.Method)
+	item, ok := b.doc.Paths[route.Path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[route.Path] = item
+	}
+
+	op := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Parameters:  route.Params,
+		Responses:   make(map[string]Response, len(route.Responses)),
+	}
+	if op.OperationID == "" {
+		op.OperationID = operationID(method, route.Path)
+	}
+	if route.Auth {
+		op.Security = []map[string][]string{{BearerAuth: {}}}
+		if route.APIKeyScope != "" {
+			op.Security = append(op.Security, map[string][]string{APIKeyAuth: {}})
+			op.Description = "API keys need the `" + route.APIKeyScope + "` scope."
+		}
+	}
+	if route.Request != nil {
+		contentType := route.RequestContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentType: {Schema: b.SchemaFor(route.Request)}},
+		}
+	}
+	for _, reply := range route.Responses {
+		resp := Response{Description: reply.Description}
+		if resp.Description == "" {
+			resp.Description = defaultDescription(reply.Status)
+		}
+		if reply.Body != nil {
+			contentType := reply.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			resp.Content = map[string]MediaType{contentType: {Schema: b.SchemaFor(reply.Body)}}
+		}
+		op.Responses[strconv.Itoa(reply.Status)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = Response{Description: "Unspecified response"}
+	}
+
+	item[method] = op
+}
+
+// Document returns the accumulated document.
+func (b *Builder) Document() Document {
+	return b.doc
+}
+
+// SchemaFor returns a schema for the value's type, registering named struct
+// types under components/schemas and returning a $ref to them.
+func (b *Builder) SchemaFor(v any) *Schema {
+	return b.schemaForType(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *Builder) schemaForType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			s = b.structSchema(t)
+		} else {
+			s = &Schema{Ref: "#/components/schemas/" + b.register(t)}
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = &Schema{Type: "string", Format: "byte"}
+		} else {
+			s = &Schema{Type: "array", Items: b.schemaForType(t.Elem())}
+		}
+	case t.Kind() == reflect.Map:
+		var additional any = true
+		if t.Elem().Kind() != reflect.Interface {
+			additional = b.schemaForType(t.Elem())
+		}
+		s = &Schema{Type: "object", AdditionalProperties: additional}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		s = &Schema{Type: "integer", Format: intFormat(t.Kind())}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer", Format: intFormat(t.Kind())}
+	case t.Kind() == reflect.Float32:
+		s = &Schema{Type: "number", Format: "float"}
+	case t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number", Format: "double"}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	default:
+		s = &Schema{}
+	}
+
+	if nullable {
+		if s.Ref != "" {
+			// $ref siblings are ignored in OpenAPI 3.0, so the nullable flag
+			// is intentionally dropped for referenced schemas.
+			return s
+		}
+		s.Nullable = true
+	}
+	return s
+}
+
+func (b *Builder) register(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := exportedName(t.Name())
+	if _, taken := b.doc.Components.Schemas[name]; taken {
+		name = fmt.Sprintf("%s_%s", exportedName(lastPathSegment(t.PkgPath())), name)
+	}
+	b.names[t] = name
+	// Reserve the slot before recursing so self-referencing types terminate.
+	b.doc.Components.Schemas[name] = &Schema{Type: "object"}
+	b.doc.Components.Schemas[name] = b.structSchema(t)
+	return name
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// encoding/json promotes the fields of embedded structs even when the
+		// embedded type itself is unexported.
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			embedded := b.structSchema(field.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		prop := b.schemaForType(field.Type)
+		if desc := field.Tag.Get("doc"); desc != "" {
+			if prop.Ref != "" {
+				prop = &Schema{Ref: prop.Ref}
+			} else {
+				prop.Description = desc
+			}
+		}
+		s.Properties[name] = prop
+		if !omitempty && field.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+func jsonName(field reflect.StructField) (name string, omitempty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+func intFormat(k reflect.Kind) string {
+	switch k {
+	case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint:
+		return "int64"
+	default:
+		return "int32"
+	}
+}
+
+func exportedName(name string) string {
+	if i := strings.IndexRune(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func lastPathSegment(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/"); i >= 0 {
+		return pkgPath[i+1:]
+	}
+	return pkgPath
+}
+
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(method)
+	for _, seg := range strings.Split(path, "/") {
+		seg = strings.Trim(seg, "{}")
+		if seg == "" || seg == "api" {
+			continue
+		}
+		sb.WriteString(exportedName(seg))
+	}
+	return sb.String()
+}
+
+func defaultDescription(status int) string {
+	switch {
+	case status >= 200 && status < 300:
+		return "Success"
+	case status == 400:
+		return "Invalid request"
+	case status == 401:
+		return "Missing or invalid credentials"
+	case status == 403:
+		return "Insufficient permissions"
+	case status == 404:
+		return "Resource not found"
+	case status == 409:
+		return "Conflict with current state"
+	default:
+		return "Unexpected error"
+	}
+}
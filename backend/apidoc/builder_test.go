@@ -0,0 +1,137 @@
+package apidoc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sampleChild struct {
+	Name string `json:"name"`
+}
+
+type sampleBody struct {
+	ID        string         `json:"id"`
+	Count     int            `json:"count"`
+	Ratio     float64        `json:"ratio,omitempty"`
+	Tags      []string       `json:"tags"`
+	Note      *string        `json:"note,omitempty"`
+	At        time.Time      `json:"at"`
+	Child     sampleChild    `json:"child"`
+	Children  []sampleChild  `json:"children"`
+	Extra     map[string]any `json:"extra,omitempty"`
+	Ignored   string         `json:"-"`
+	unexposed string
+}
+
+func TestBuilder_SchemaFromStructTags(t *testing.T) {
+	b := NewBuilder("Test", "1.0.0", "")
+	b.Add(Route{
+		Method:    http.MethodPost,
+		Path:      "/api/samples/{id}",
+		Auth:      true,
+		Params:    []Parameter{PathParam("id", "Sample id")},
+		Request:   sampleBody{},
+		Responses: []Reply{{Status: http.StatusCreated, Body: sampleBody{}}},
+	})
+	doc := b.Document()
+
+	op := doc.Paths["/api/samples/{id}"]["post"]
+	if op == nil {
+		t.Fatalf("expected post operation to be registered")
+	}
+	if op.OperationID != "postSamplesId" {
+		t.Fatalf("unexpected operation id %q", op.OperationID)
+	}
+	if len(op.Security) != 1 {
+		t.Fatalf("expected bearer security on authenticated route")
+	}
+	if ref := op.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/SampleBody" {
+		t.Fatalf("unexpected request ref %q", ref)
+	}
+	if _, ok := op.Responses["201"]; !ok {
+		t.Fatalf("expected 201 response, got %v", op.Responses)
+	}
+
+	schema := doc.Components.Schemas["SampleBody"]
+	if schema == nil {
+		t.Fatalf("expected SampleBody component")
+	}
+	if _, ok := schema.Properties["Ignored"]; ok {
+		t.Fatalf("json:\"-\" fields must be skipped")
+	}
+	if _, ok := schema.Properties["unexposed"]; ok {
+		t.Fatalf("unexported fields must be skipped")
+	}
+	if got := schema.Properties["at"]; got.Type != "string" || got.Format != "date-time" {
+		t.Fatalf("expected date-time string, got %+v", got)
+	}
+	if got := schema.Properties["note"]; !got.Nullable {
+		t.Fatalf("expected pointer field to be nullable")
+	}
+	if got := schema.Properties["children"]; got.Type != "array" || got.Items.Ref != "#/components/schemas/SampleChild" {
+		t.Fatalf("unexpected array schema %+v", got)
+	}
+	want := []string{"at", "child", "children", "count", "id", "tags"}
+	if len(schema.Required) != len(want) {
+		t.Fatalf("expected required %v, got %v", want, schema.Required)
+	}
+	for i := range want {
+		if schema.Required[i] != want[i] {
+			t.Fatalf("expected required %v, got %v", want, schema.Required)
+		}
+	}
+}
+
+func TestBuilder_APIKeyScope(t *testing.T) {
+	b := NewBuilder("Test", "1.0.0", "").WithAPIKeyHeader("X-API-Key")
+	b.Add(Route{Method: http.MethodGet, Path: "/api/samples", Auth: true, APIKeyScope: "samples:read"})
+	doc := b.Document()
+
+	if scheme := doc.Components.SecuritySchemes[APIKeyAuth]; scheme.Type != "apiKey" || scheme.In != "header" || scheme.Name != "X-API-Key" {
+		t.Fatalf("unexpected api key scheme %+v", scheme)
+	}
+	op := doc.Paths["/api/samples"]["get"]
+	if len(op.Security) != 2 {
+		t.Fatalf("expected bearer and api key alternatives, got %v", op.Security)
+	}
+	if _, ok := op.Security[1][APIKeyAuth]; !ok {
+		t.Fatalf("expected api key requirement, got %v", op.Security)
+	}
+	if !strings.Contains(op.Description, "samples:read") {
+		t.Fatalf("expected scope in description, got %q", op.Description)
+	}
+}
+
+func TestJSONHandler_ServesDocument(t *testing.T) {
+	b := NewBuilder("Test", "1.0.0", "")
+	b.Add(Route{Method: http.MethodGet, Path: "/healthz"})
+
+	h, err := JSONHandler(b.Document())
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths["/healthz"]["get"] == nil {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyTag = "bfk"
+
+// APIKeyScope names a resource and the access an API key has to it.
+type APIKeyScope string
+
+const (
+	ScopeReferralsRead   APIKeyScope = "referrals:read"
+	ScopeReferralsWrite  APIKeyScope = "referrals:write"
+	ScopeAgreementsRead  APIKeyScope = "agreements:read"
+	ScopeAgreementsWrite APIKeyScope = "agreements:write"
+	ScopeDisputesRead    APIKeyScope = "disputes:read"
+	ScopeDisputesWrite   APIKeyScope = "disputes:write"
+	ScopeBrokersRead     APIKeyScope = "brokers:read"
+	ScopeBrokersWrite    APIKeyScope = "brokers:write"
+	// ScopeUsersRead and ScopeUsersWrite admit a broker_admin's key to the
+	// SCIM provisioning endpoints for the admin's brokerage.
+	ScopeUsersRead  APIKeyScope = "users:read"
+	ScopeUsersWrite APIKeyScope = "users:write"
+)
+
+// APIKeyScopes lists every scope a key may be granted.
+func APIKeyScopes() []APIKeyScope {
+	return []APIKeyScope{
+		ScopeReferralsRead, ScopeReferralsWrite,
+		ScopeAgreementsRead, ScopeAgreementsWrite,
+		ScopeDisputesRead, ScopeDisputesWrite,
+		ScopeBrokersRead, ScopeBrokersWrite,
+		ScopeUsersRead, ScopeUsersWrite,
+	}
+}
+
+var (
+	// ErrInvalidAPIKey signals an unknown, malformed or revoked key.
+	ErrInvalidAPIKey = errors.New("auth: invalid api key")
+	// ErrAPIKeyNotFound signals that the caller owns no key with that id.
+	ErrAPIKeyNotFound = errors.New("auth: api key not found")
+	// ErrInvalidAPIKeyScope signals a missing or unknown scope.
+	ErrInvalidAPIKeyScope = errors.New("auth: api key needs at least one known scope")
+	// ErrAPIKeyNameRequired signals a key created without a name.
+	ErrAPIKeyNameRequired = errors.New("auth: api key name is required")
+)
+
+// APIKey is a credential that authenticates as its owner, limited to Scopes.
+// The secret itself is never stored; only its hash.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	Scopes     []APIKeyScope
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Allows reports whether the key was granted scope.
+func (k APIKey) Allows(scope APIKeyScope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// CreateAPIKeyParams contains write parameters for storing a key.
+type CreateAPIKeyParams struct {
+	UserID  string
+	Name    string
+	Prefix  string
+	KeyHash string
+	Scopes  []APIKeyScope
+}
+
+// APIKeyRepository handles data access for API keys.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, params CreateAPIKeyParams) (APIKey, error)
+	// UseAPIKey returns the active key with the given hash and records its use.
+	UseAPIKey(ctx context.Context, keyHash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID string) (APIKey, error)
+}
+
+// APIKeyService issues and verifies API keys for server-to-server callers.
+type APIKeyService struct {
+	keys  APIKeyRepository
+	users Repository
+}
+
+// NewAPIKeyService creates an API key service.
+func NewAPIKeyService(keys APIKeyRepository, users Repository) *APIKeyService {
+	return &APIKeyService{keys: keys, users: users}
+}
+
+// Create issues a key for userID. The returned secret is shown to the caller
+// once and cannot be recovered afterwards.
+func (s *APIKeyService) Create(ctx context.Context, userID, name string, scopes []APIKeyScope) (APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKey{}, "", ErrAPIKeyNameRequired
+	}
+	granted, err := normalizeScopes(scopes)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return APIKey{}, "", err
+	}
+
+	secret, prefix, err := generateAPIKey()
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("auth: generate api key: %w", err)
+	}
+	key, err := s.keys.CreateAPIKey(ctx, CreateAPIKeyParams{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hashAPIKey(secret),
+		Scopes:  granted,
+	})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+// Authenticate resolves a presented key to its active record and owner.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (APIKey, User, error) {
+	if !strings.HasPrefix(secret, apiKeyTag+"_") {
+		return APIKey{}, User{}, ErrInvalidAPIKey
+	}
+	key, err := s.keys.UseAPIKey(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return APIKey{}, User{}, ErrInvalidAPIKey
+		}
+		return APIKey{}, User{}, err
+	}
+	user, err := s.users.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return APIKey{}, User{}, ErrInvalidAPIKey
+		}
+		return APIKey{}, User{}, err
+	}
+	return key, user, nil
+}
+
+// List returns userID's keys, newest first, including revoked ones.
+func (s *APIKeyService) List(ctx context.Context, userID string) ([]APIKey, error) {
+	return s.keys.ListAPIKeys(ctx, userID)
+}
+
+// Revoke disables one of userID's keys. Revoking twice is not an error.
+func (s *APIKeyService) Revoke(ctx context.Context, userID, keyID string) (APIKey, error) {
+	return s.keys.RevokeAPIKey(ctx, userID, keyID)
+}
+
+func normalizeScopes(scopes []APIKeyScope) ([]APIKeyScope, error) {
+	known := APIKeyScopes()
+	out := make([]APIKeyScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(known, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAPIKeyScope, scope)
+		}
+		if !slices.Contains(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	return out, nil
+}
+
+// generateAPIKey returns a key of the form bfk_<prefix>_<secret>, where the
+// prefix identifies the key in listings and the secret carries 256 bits.
+func generateAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyTag + "_" + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// hashAPIKey digests a key for storage. Keys are random and high-entropy, so
+// a fast hash suffices and allows lookup by hash.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, created_at, last_used_at, revoked_at`
+
+// CreateAPIKey stores a hashed key.
+func (r *PGRepository) CreateAPIKey(ctx context.Context, params CreateAPIKeyParams) (APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+apiKeyColumns,
+		params.UserID, params.Name, params.Prefix, params.KeyHash, scopeStrings(params.Scopes)))
+	if err != nil {
+		return APIKey{}, fmt.Errorf("auth: create api key: %w", err)
+	}
+	return key, nil
+}
+
+// UseAPIKey looks up an unrevoked key by hash and stamps last_used_at.
+func (r *PGRepository) UseAPIKey(ctx context.Context, keyHash string) (APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, `
+		UPDATE api_keys
+		SET last_used_at = get_tx_timestamp()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, fmt.Errorf("auth: use api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns a user's keys, newest first.
+func (r *PGRepository) ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("auth: list api keys: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (APIKey, error) { return scanAPIKey(row) })
+	if err != nil {
+		return nil, fmt.Errorf("auth: scan api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey marks a user's key revoked, keeping the first revocation time.
+func (r *PGRepository) RevokeAPIKey(ctx context.Context, userID, keyID string) (APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, get_tx_timestamp())
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+apiKeyColumns, keyID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, fmt.Errorf("auth: revoke api key: %w", err)
+	}
+	return key, nil
+}
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var (
+		key    APIKey
+		scopes []string
+	)
+	if err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+		return APIKey{}, err
+	}
+	key.Scopes = make([]APIKeyScope, len(scopes))
+	for i, s := range scopes {
+		key.Scopes[i] = APIKeyScope(s)
+	}
+	return key, nil
+}
+
+func scopeStrings(scopes []APIKeyScope) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		out[i] = string(s)
+	}
+	return out
+}
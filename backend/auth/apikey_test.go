@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAPIKeyRepository struct {
+	byHash map[string]APIKey
+	nextID int
+}
+
+func newFakeAPIKeyRepository() *fakeAPIKeyRepository {
+	return &fakeAPIKeyRepository{byHash: make(map[string]APIKey), nextID: 1}
+}
+
+func (f *fakeAPIKeyRepository) CreateAPIKey(_ context.Context, params CreateAPIKeyParams) (APIKey, error) {
+	key := APIKey{
+		ID:        fmt.Sprintf("key-%d", f.nextID),
+		UserID:    params.UserID,
+		Name:      params.Name,
+		Prefix:    params.Prefix,
+		Scopes:    params.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	f.nextID++
+	f.byHash[params.KeyHash] = key
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepository) UseAPIKey(_ context.Context, keyHash string) (APIKey, error) {
+	key, ok := f.byHash[keyHash]
+	if !ok || key.RevokedAt != nil {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	now := time.Now().UTC()
+	key.LastUsedAt = &now
+	f.byHash[keyHash] = key
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepository) ListAPIKeys(_ context.Context, userID string) ([]APIKey, error) {
+	var out []APIKey
+	for _, key := range f.byHash {
+		if key.UserID == userID {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeAPIKeyRepository) RevokeAPIKey(_ context.Context, userID, keyID string) (APIKey, error) {
+	for hash, key := range f.byHash {
+		if key.ID == keyID && key.UserID == userID {
+			if key.RevokedAt == nil {
+				now := time.Now().UTC()
+				key.RevokedAt = &now
+				f.byHash[hash] = key
+			}
+			return key, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+func TestAPIKeyService_CreateAuthenticateRevoke(t *testing.T) {
+	ctx := context.Background()
+	users := newFakeRepository()
+	owner, err := users.CreateUser(ctx, CreateUserParams{Email: "crm@example.com", FullName: "CRM", Role: RoleBrokerAdmin})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	keys := newFakeAPIKeyRepository()
+	svc := NewAPIKeyService(keys, users)
+
+	key, secret, err := svc.Create(ctx, owner.ID, "  CRM sync ", []APIKeyScope{ScopeReferralsRead, ScopeReferralsRead, ScopeAgreementsRead})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if key.Name != "CRM sync" || len(key.Scopes) != 2 {
+		t.Fatalf("unexpected key: %+v", key)
+	}
+	if !strings.HasPrefix(secret, key.Prefix+"_") {
+		t.Fatalf("secret %q does not start with prefix %q", secret, key.Prefix)
+	}
+	for hash := range keys.byHash {
+		if strings.Contains(hash, secret) || hash == secret {
+			t.Fatal("secret stored in clear")
+		}
+	}
+
+	got, user, err := svc.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if got.ID != key.ID || user.ID != owner.ID || user.Role != RoleBrokerAdmin {
+		t.Fatalf("unexpected identity: key=%+v user=%+v", got, user)
+	}
+	if !got.Allows(ScopeAgreementsRead) || got.Allows(ScopeAgreementsWrite) {
+		t.Fatalf("unexpected scopes: %v", got.Scopes)
+	}
+
+	if _, err := svc.Revoke(ctx, "someone-else", key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("revoke by other user: expected ErrAPIKeyNotFound, got %v", err)
+	}
+	if _, err := svc.Revoke(ctx, owner.ID, key.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, _, err := svc.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("revoked key: expected ErrInvalidAPIKey, got %v", err)
+	}
+}
+
+func TestAPIKeyService_CreateValidation(t *testing.T) {
+	ctx := context.Background()
+	users := newFakeRepository()
+	owner, _ := users.CreateUser(ctx, CreateUserParams{Email: "a@example.com", FullName: "A"})
+	svc := NewAPIKeyService(newFakeAPIKeyRepository(), users)
+
+	if _, _, err := svc.Create(ctx, owner.ID, " ", []APIKeyScope{ScopeReferralsRead}); !errors.Is(err, ErrAPIKeyNameRequired) {
+		t.Fatalf("blank name: got %v", err)
+	}
+	if _, _, err := svc.Create(ctx, owner.ID, "k", nil); !errors.Is(err, ErrInvalidAPIKeyScope) {
+		t.Fatalf("no scopes: got %v", err)
+	}
+	if _, _, err := svc.Create(ctx, owner.ID, "k", []APIKeyScope{"admin:*"}); !errors.Is(err, ErrInvalidAPIKeyScope) {
+		t.Fatalf("unknown scope: got %v", err)
+	}
+}
+
+func TestAPIKeyService_AuthenticateRejectsUnknownKeys(t *testing.T) {
+	svc := NewAPIKeyService(newFakeAPIKeyRepository(), newFakeRepository())
+	for _, secret := range []string{"", "not-a-key", "bfk_deadbeef_nope"} {
+		if _, _, err := svc.Authenticate(context.Background(), secret); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Fatalf("%q: expected ErrInvalidAPIKey, got %v", secret, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrErasureUnavailable signals a service built without WithErasure.
+var ErrErasureUnavailable = errors.New("auth: account erasure is not configured")
+
+// DefaultPIIRetention is how long client contacts supplied by an erased user
+// are kept, e.g. to settle open commission claims, before they are purged.
+const DefaultPIIRetention = 30 * 24 * time.Hour
+
+// ErasedUserName replaces the full name of an erased user.
+const ErasedUserName = "Deleted user"
+
+// ErasedEmail is the placeholder email of an erased user. It is unique per
+// user and uses the reserved .invalid TLD so it can never be delivered to.
+func ErasedEmail(userID string) string {
+	return "deleted-" + userID + "@erased.invalid"
+}
+
+// Erasure describes an erased account and when its client contacts go.
+type Erasure struct {
+	UserID     string
+	DeletedAt  time.Time
+	PurgeAfter time.Time
+}
+
+// ErasureRepository handles data access for account erasure.
+type ErasureRepository interface {
+	// EraseUser marks userID deleted, overwrites its PII columns with
+	// ErasedEmail and ErasedUserName, drops its credentials (API keys,
+	// sessions, second factor, lockout state) and schedules its client
+	// contacts for purging at purgeAfter. Already erased users yield
+	// ErrUserNotFound.
+	EraseUser(ctx context.Context, userID string, purgeAfter time.Time) (Erasure, error)
+}
+
+// WithErasure enables DeleteAccount; client contacts are purged retention
+// after the account is deleted.
+func (s *Service) WithErasure(repo ErasureRepository, retention time.Duration) *Service {
+	s.erasure = repo
+	s.piiRetention = retention
+	return s
+}
+
+// DeleteAccount soft-deletes userID and anonymizes it. Rows referencing the
+// user (agreements, timeline events, audit logs) are left intact.
+func (s *Service) DeleteAccount(ctx context.Context, userID string) (Erasure, error) {
+	if s.erasure == nil {
+		return Erasure{}, ErrErasureUnavailable
+	}
+	erasure, err := s.erasure.EraseUser(ctx, userID, s.clock.Now().Add(s.piiRetention))
+	if err != nil {
+		return Erasure{}, err
+	}
+	s.InvalidateUser(ctx, userID)
+	return erasure, nil
+}
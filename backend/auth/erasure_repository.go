@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// EraseUser soft-deletes and anonymizes a user in one transaction. Login
+// attempts keep their rows for the IP throttle window but lose the email and
+// address; an ACCOUNT_ERASED audit entry records the request.
+func (r *PGRepository) EraseUser(ctx context.Context, userID string, purgeAfter time.Time) (Erasure, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Erasure{}, fmt.Errorf("auth: begin erase user: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	e := Erasure{UserID: userID}
+	if err := tx.QueryRow(ctx, `
+		UPDATE users
+		SET email = $2,
+			full_name = $3,
+			phone = NULL,
+			password_hash = NULL,
+			languages = '{}'::text[],
+			external_id = NULL,
+			deleted_at = get_tx_timestamp()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at
+	`, userID, ErasedEmail(userID), ErasedUserName).Scan(&e.DeletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Erasure{}, ErrUserNotFound
+		}
+		return Erasure{}, fmt.Errorf("auth: anonymize user: %w", err)
+	}
+
+	for _, stmt := range []struct{ sql, what string }{
+		{`UPDATE api_keys SET revoked_at = get_tx_timestamp() WHERE user_id = $1 AND revoked_at IS NULL`, "revoke api keys"},
+		{`DELETE FROM user_recovery_codes WHERE user_id = $1`, "delete recovery codes"},
+		{`DELETE FROM user_totp WHERE user_id = $1`, "delete totp"},
+		{`DELETE FROM user_identities WHERE user_id = $1`, "unlink sign-in identities"},
+		{`DELETE FROM account_lockouts WHERE user_id = $1`, "delete lockout"},
+		{`DELETE FROM marketplace_subscriptions WHERE user_id = $1`, "delete marketplace subscription"},
+		{`DELETE FROM agent_profiles WHERE user_id = $1`, "delete agent profile"},
+		{`UPDATE reviews SET comment = NULL WHERE reviewer_id = $1`, "clear review comments"},
+		{`UPDATE login_attempts SET email = '', ip = NULL WHERE user_id = $1`, "anonymize login attempts"},
+		{`UPDATE user_sessions SET revoked_at = COALESCE(revoked_at, get_tx_timestamp()), user_agent = '', ip = NULL WHERE user_id = $1`, "revoke sessions"},
+	} {
+		if _, err := tx.Exec(ctx, stmt.sql, userID); err != nil {
+			return Erasure{}, fmt.Errorf("auth: %s: %w", stmt.what, err)
+		}
+	}
+
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO pii_erasures (user_id, purge_after)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET purge_after = pii_erasures.purge_after
+		RETURNING purge_after
+	`, userID, purgeAfter).Scan(&e.PurgeAfter); err != nil {
+		return Erasure{}, fmt.Errorf("auth: schedule pii purge: %w", err)
+	}
+	if err := insertAuditLog(ctx, tx, userID, "ACCOUNT_ERASED", map[string]any{
+		"user_id":     userID,
+		"purge_after": e.PurgeAfter.UTC(),
+	}); err != nil {
+		return Erasure{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Erasure{}, fmt.Errorf("auth: commit erase user: %w", err)
+	}
+	return e, nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/cache"
+	"brokerflow/clock"
+)
+
+// fakeErasureRepository erases users from the wrapped fakeRepository so
+// lookups behave like the deleted_at filter in PGRepository.
+type fakeErasureRepository struct {
+	users  *fakeRepository
+	erased map[string]Erasure
+	now    func() time.Time
+}
+
+func (f *fakeErasureRepository) EraseUser(ctx context.Context, userID string, purgeAfter time.Time) (Erasure, error) {
+	user, ok := f.users.usersByID[userID]
+	if !ok {
+		return Erasure{}, ErrUserNotFound
+	}
+	delete(f.users.usersByID, userID)
+	delete(f.users.usersByEmail, strings.ToLower(user.Email))
+	e := Erasure{UserID: userID, DeletedAt: f.now(), PurgeAfter: purgeAfter}
+	f.erased[userID] = e
+	return e, nil
+}
+
+func TestService_DeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	repo := newFakeRepository()
+	erasure := &fakeErasureRepository{users: repo, erased: map[string]Erasure{}, now: clk.Now}
+	svc := NewService(repo, "test-secret").WithClock(clk).WithErasure(erasure, 7*24*time.Hour)
+
+	user, err := svc.Register(ctx, RegisterRequest{Email: "alice@example.com", Password: "supersafe", FullName: "Alice Agent"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	e, err := svc.DeleteAccount(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("delete account: %v", err)
+	}
+	if want := clk.Now().Add(7 * 24 * time.Hour); !e.PurgeAfter.Equal(want) {
+		t.Fatalf("expected purge after %s, got %s", want, e.PurgeAfter)
+	}
+
+	if _, err := svc.Login(ctx, LoginRequest{Email: "alice@example.com", Password: "supersafe"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected erased user to be unable to log in, got %v", err)
+	}
+	if _, err := svc.GetUserByID(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected erased user to be hidden, got %v", err)
+	}
+	if _, err := svc.DeleteAccount(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected second erasure to report ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestService_DeleteAccountInvalidatesCachedUser(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	repo := newFakeRepository()
+	erasure := &fakeErasureRepository{users: repo, erased: map[string]Erasure{}, now: clk.Now}
+	svc := NewService(repo, "test-secret").WithClock(clk).
+		WithErasure(erasure, time.Hour).
+		WithCache(cache.NewMemory(), time.Hour)
+
+	user, err := svc.Register(ctx, RegisterRequest{Email: "carol@example.com", Password: "supersafe", FullName: "Carol"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := svc.GetUserByID(ctx, user.ID); err != nil {
+		t.Fatalf("warm cache: %v", err)
+	}
+	if _, err := svc.DeleteAccount(ctx, user.ID); err != nil {
+		t.Fatalf("delete account: %v", err)
+	}
+	if _, err := svc.GetUserByID(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected the erased user to leave the cache, got %v", err)
+	}
+}
+
+func TestService_DeleteAccountUnavailable(t *testing.T) {
+	svc := NewService(newFakeRepository(), "test-secret")
+	if _, err := svc.DeleteAccount(context.Background(), "user-1"); !errors.Is(err, ErrErasureUnavailable) {
+		t.Fatalf("expected ErrErasureUnavailable, got %v", err)
+	}
+}
+
+func TestErasedEmail_UniquePerUser(t *testing.T) {
+	a, b := ErasedEmail("user-1"), ErasedEmail("user-2")
+	if a == b || !strings.HasSuffix(a, ".invalid") {
+		t.Fatalf("unexpected erased emails %q, %q", a, b)
+	}
+}
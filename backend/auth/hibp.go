@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultHIBPURL is the Pwned Passwords range API.
+const DefaultHIBPURL = "https://api.pwnedpasswords.com/range/"
+
+const hibpRequestTimeout = 5 * time.Second
+
+// HIBPChecker looks passwords up in the Pwned Passwords range API using
+// k-anonymity: only the first five hex characters of the password's SHA-1
+// leave the process, and the matching suffixes are compared locally.
+// Responses are padded so their size does not reveal the prefix either.
+type HIBPChecker struct {
+	base   string
+	client *http.Client
+}
+
+// NewHIBPChecker takes the range endpoint, usually DefaultHIBPURL; the hash
+// prefix is appended to it.
+func NewHIBPChecker(rawURL string) (*HIBPChecker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("auth: invalid breach check url %q", rawURL)
+	}
+	return &HIBPChecker{
+		base:   strings.TrimRight(rawURL, "/") + "/",
+		client: &http.Client{Timeout: hibpRequestTimeout},
+	}, nil
+}
+
+func (c *HIBPChecker) WithClient(client *http.Client) *HIBPChecker {
+	c.client = client
+	return c
+}
+
+func (c *HIBPChecker) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+prefix, nil)
+	if err != nil {
+		return false, fmt.Errorf("auth: build breach check request: %w", err)
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("auth: breach check: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return false, fmt.Errorf("auth: breach check returned %d", resp.StatusCode)
+	}
+
+	// Each line is "<hash suffix>:<count>"; padding lines have count 0.
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("auth: read breach check response: %w", err)
+	}
+	return false, nil
+}
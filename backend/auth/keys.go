@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKeyID names the key built from a bare secret, as NewService does.
+// Tokens issued before key IDs existed carry no kid and are verified with it.
+const DefaultKeyID = "default"
+
+var (
+	ErrUnknownKey     = errors.New("auth: unknown signing key")
+	ErrInvalidKeySpec = errors.New("auth: invalid signing key")
+)
+
+// SigningKey is a key tokens are signed or verified with: an HS256 secret
+// or an RS256 key pair. Only RS256 keys are published in the JWKS.
+type SigningKey struct {
+	ID      string
+	method  jwt.SigningMethod
+	secret  []byte
+	private *rsa.PrivateKey
+}
+
+// NewHMACKey returns an HS256 key.
+func NewHMACKey(id string, secret []byte) SigningKey {
+	return SigningKey{ID: id, method: jwt.SigningMethodHS256, secret: secret}
+}
+
+// NewRSAKey returns an RS256 key.
+func NewRSAKey(id string, private *rsa.PrivateKey) SigningKey {
+	return SigningKey{ID: id, method: jwt.SigningMethodRS256, private: private}
+}
+
+// ParseRSAKey reads a PEM-encoded PKCS #1 or PKCS #8 RSA private key.
+func ParseRSAKey(id string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("%w: %s: no PEM block", ErrInvalidKeySpec, id)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewRSAKey(id, key), nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%w: %s: %v", ErrInvalidKeySpec, id, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w: %s: not an RSA key", ErrInvalidKeySpec, id)
+	}
+	return NewRSAKey(id, key), nil
+}
+
+// ParseKeys reads a comma-separated list of keys such as
+// "2026-10=hs256:<secret>,2026-11=rs256:/etc/brokerflow/jwt.pem". RS256 keys
+// name a PEM file, read with readFile.
+func ParseKeys(spec string, readFile func(string) ([]byte, error)) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(item, "=")
+		alg, material, ok2 := strings.Cut(value, ":")
+		id = strings.TrimSpace(id)
+		if !ok || !ok2 || id == "" || material == "" {
+			return nil, fmt.Errorf("%w: %q is not KID=ALG:VALUE", ErrInvalidKeySpec, item)
+		}
+		switch strings.ToLower(alg) {
+		case "hs256":
+			keys = append(keys, NewHMACKey(id, []byte(material)))
+		case "rs256":
+			data, err := readFile(material)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKeySpec, id, err)
+			}
+			key, err := ParseRSAKey(id, data)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("%w: %s: unsupported algorithm %q", ErrInvalidKeySpec, id, alg)
+		}
+	}
+	return keys, nil
+}
+
+// Algorithm is the JWT alg the key signs with.
+func (k SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+func (k SigningKey) signingKey() any {
+	if k.private != nil {
+		return k.private
+	}
+	return k.secret
+}
+
+func (k SigningKey) verificationKey() any {
+	if k.private != nil {
+		return &k.private.PublicKey
+	}
+	return k.secret
+}
+
+// KeySet signs with its active key and verifies with any of its keys, so a
+// new key can be rolled out while tokens signed with the old one are still
+// live.
+type KeySet struct {
+	active string
+	keys   map[string]SigningKey
+}
+
+// NewKeySet builds a key set signing with the key named active.
+func NewKeySet(active string, keys ...SigningKey) (*KeySet, error) {
+	ks := &KeySet{active: active, keys: make(map[string]SigningKey, len(keys))}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("%w: empty key ID", ErrInvalidKeySpec)
+		}
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("%w: duplicate key ID %q", ErrInvalidKeySpec, k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+	if _, ok := ks.keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownKey, active)
+	}
+	return ks, nil
+}
+
+// ActiveKeyID names the key new tokens are signed with.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	key := ks.keys[ks.active]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey())
+}
+
+// keyFunc finds the verification key named by the token's kid, requiring
+// the token's alg to be the key's so an RS256 public key can never be
+// used as an HMAC secret.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = DefaultKeyID
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != key.Algorithm() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verificationKey(), nil
+}
+
+// JWK is the public half of an RS256 key as published at
+// /.well-known/jwks.json (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JWKS returns the public keys of the set's RS256 keys in key ID order.
+// HS256 secrets are never published; services verifying those tokens must
+// share the secret.
+func (ks *KeySet) JWKS() []JWK {
+	out := []JWK{}
+	for _, k := range ks.keys {
+		if k.private == nil {
+			continue
+		}
+		pub := k.private.PublicKey
+		out = append(out, JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Algorithm(),
+			Modulus:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyID < out[j].KeyID })
+	return out
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeySet_RotationKeepsOldTokensValid(t *testing.T) {
+	svc := NewService(newFakeRepository(), "old-secret")
+	old, err := svc.generateToken(User{ID: "u1", Role: RoleAgent}, "")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// Tokens from before key IDs carry no kid and use the default key.
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": "u1", "role": "agent"}).SignedString([]byte("old-secret"))
+	if err != nil {
+		t.Fatalf("sign legacy: %v", err)
+	}
+
+	rotated, err := NewKeySet("k2", NewHMACKey("k2", []byte("new-secret")), NewHMACKey(DefaultKeyID, []byte("old-secret")))
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+	svc.WithKeys(rotated)
+	for name, token := range map[string]string{"old": old, "legacy": legacy} {
+		if _, err := svc.VerifyToken(context.Background(), token); err != nil {
+			t.Fatalf("%s token after rotation: %v", name, err)
+		}
+	}
+	fresh, err := svc.generateToken(User{ID: "u1", Role: RoleAgent}, "")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(fresh, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != "k2" {
+		t.Fatalf("expected new tokens under k2, got %v (%v)", parsed.Header["kid"], err)
+	}
+
+	retired, err := NewKeySet("k2", NewHMACKey("k2", []byte("new-secret")))
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+	svc.WithKeys(retired)
+	if _, err := svc.VerifyToken(context.Background(), old); err == nil {
+		t.Fatal("expected tokens of a retired key to be rejected")
+	}
+	if _, err := svc.VerifyToken(context.Background(), fresh); err != nil {
+		t.Fatalf("fresh token: %v", err)
+	}
+}
+
+func TestKeySet_RS256AndJWKS(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	ks, err := NewKeySet("rsa-1", NewRSAKey("rsa-1", private), NewHMACKey("hmac-1", []byte("secret")))
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+	svc := NewService(newFakeRepository(), "").WithKeys(ks)
+	token, err := svc.generateToken(User{ID: "u1", Role: RoleBrokerAdmin}, "")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	jwks := svc.JWKS()
+	if len(jwks) != 1 || jwks[0].KeyID != "rsa-1" || jwks[0].Algorithm != "RS256" {
+		t.Fatalf("expected only the RSA key to be published, got %+v", jwks)
+	}
+	// A verifier holding only the published key accepts the token.
+	n, _ := base64.RawURLEncoding.DecodeString(jwks[0].Modulus)
+	e, _ := base64.RawURLEncoding.DecodeString(jwks[0].Exponent)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if _, err := jwt.Parse(token, func(*jwt.Token) (any, error) { return public, nil }, jwt.WithValidMethods([]string{"RS256"})); err != nil {
+		t.Fatalf("verify with JWKS key: %v", err)
+	}
+
+	// An HS256 token naming the RSA key must not verify against it.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": "u1", "role": "agent"})
+	forged.Header["kid"] = "rsa-1"
+	signed, err := forged.SignedString(x509.MarshalPKCS1PublicKey(public))
+	if err != nil {
+		t.Fatalf("sign forged: %v", err)
+	}
+	if _, err := svc.VerifyToken(context.Background(), signed); err == nil {
+		t.Fatal("expected an algorithm mismatch to be rejected")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	files := map[string][]byte{
+		"/keys/rsa.pem": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}),
+		"/keys/bad.pem": []byte("not a key"),
+	}
+	readFile := func(name string) ([]byte, error) {
+		if data, ok := files[name]; ok {
+			return data, nil
+		}
+		return nil, errors.New("no such file")
+	}
+
+	keys, err := ParseKeys(" a=hs256:s3cret, b=RS256:/keys/rsa.pem ", readFile)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "a" || keys[0].Algorithm() != "HS256" || keys[1].ID != "b" || keys[1].Algorithm() != "RS256" {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+
+	for _, spec := range []string{"a", "a=hs256", "=hs256:x", "a=es256:x", "a=rs256:/keys/missing.pem", "a=rs256:/keys/bad.pem"} {
+		if _, err := ParseKeys(spec, readFile); !errors.Is(err, ErrInvalidKeySpec) {
+			t.Errorf("ParseKeys(%q): expected ErrInvalidKeySpec, got %v", spec, err)
+		}
+	}
+	if _, err := NewKeySet("missing", keys...); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey for an unknown active key, got %v", err)
+	}
+	if _, err := NewKeySet("a", keys[0], keys[0]); !errors.Is(err, ErrInvalidKeySpec) {
+		t.Fatalf("expected duplicate key IDs to be rejected, got %v", err)
+	}
+}
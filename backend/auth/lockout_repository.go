@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordLoginAttempt appends to login_attempts.
+func (r *PGRepository) RecordLoginAttempt(ctx context.Context, a LoginAttempt) error {
+	if _, err := r.pool.Exec(ctx, `
+		INSERT INTO login_attempts (user_id, email, ip, succeeded, reason)
+		VALUES (NULLIF($1, '')::uuid, $2, NULLIF($3, ''), $4, NULLIF($5, ''))
+	`, a.UserID, a.Email, a.IP, a.Succeeded, a.Reason); err != nil {
+		return fmt.Errorf("auth: record login attempt: %w", err)
+	}
+	return nil
+}
+
+// CountIPFailures counts failed attempts from ip since the given time.
+func (r *PGRepository) CountIPFailures(ctx context.Context, ip string, since time.Time) (int, error) {
+	var n int
+	if err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM login_attempts
+		WHERE ip = $1 AND NOT succeeded AND attempted_at >= $2
+	`, ip, since).Scan(&n); err != nil {
+		return 0, fmt.Errorf("auth: count ip failures: %w", err)
+	}
+	return n, nil
+}
+
+const lockoutColumns = `user_id::text, failed_count, lock_count, locked_until`
+
+func scanLockout(row pgx.Row) (Lockout, error) {
+	var lo Lockout
+	err := row.Scan(&lo.UserID, &lo.FailedCount, &lo.LockCount, &lo.LockedUntil)
+	return lo, err
+}
+
+// GetLockout loads an account's failed-login state.
+func (r *PGRepository) GetLockout(ctx context.Context, userID string) (Lockout, error) {
+	lo, err := scanLockout(r.pool.QueryRow(ctx, `SELECT `+lockoutColumns+` FROM account_lockouts WHERE user_id = $1`, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Lockout{UserID: userID}, nil
+		}
+		return Lockout{}, fmt.Errorf("auth: get lockout: %w", err)
+	}
+	return lo, nil
+}
+
+// AddLoginFailure increments the failure count atomically.
+func (r *PGRepository) AddLoginFailure(ctx context.Context, userID string) (Lockout, error) {
+	lo, err := scanLockout(r.pool.QueryRow(ctx, `
+		INSERT INTO account_lockouts (user_id, failed_count)
+		VALUES ($1, 1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET failed_count = account_lockouts.failed_count + 1, updated_at = get_tx_timestamp()
+		RETURNING `+lockoutColumns, userID))
+	if err != nil {
+		return Lockout{}, fmt.Errorf("auth: add login failure: %w", err)
+	}
+	return lo, nil
+}
+
+// LockAccount applies a lock and audits it in one transaction.
+func (r *PGRepository) LockAccount(ctx context.Context, userID string, until time.Time, ip string) (Lockout, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Lockout{}, fmt.Errorf("auth: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	lo, err := scanLockout(tx.QueryRow(ctx, `
+		UPDATE account_lockouts
+		SET locked_until = $2, lock_count = lock_count + 1, failed_count = 0, updated_at = get_tx_timestamp()
+		WHERE user_id = $1
+		RETURNING `+lockoutColumns, userID, until))
+	if err != nil {
+		return Lockout{}, fmt.Errorf("auth: lock account: %w", err)
+	}
+	if err := insertAuditLog(ctx, tx, userID, "ACCOUNT_LOCKED", map[string]any{
+		"user_id":      userID,
+		"ip":           ip,
+		"locked_until": until.UTC(),
+		"lock_count":   lo.LockCount,
+	}); err != nil {
+		return Lockout{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Lockout{}, fmt.Errorf("auth: commit lock: %w", err)
+	}
+	return lo, nil
+}
+
+// ResetLoginFailures clears the counters after a successful login.
+func (r *PGRepository) ResetLoginFailures(ctx context.Context, userID string) error {
+	if _, err := r.pool.Exec(ctx, `
+		UPDATE account_lockouts
+		SET failed_count = 0, lock_count = 0, locked_until = NULL, updated_at = get_tx_timestamp()
+		WHERE user_id = $1 AND (failed_count > 0 OR lock_count > 0 OR locked_until IS NOT NULL)
+	`, userID); err != nil {
+		return fmt.Errorf("auth: reset login failures: %w", err)
+	}
+	return nil
+}
+
+// UnlockAccount lifts a lock and audits who lifted it.
+func (r *PGRepository) UnlockAccount(ctx context.Context, userID, actorID string) (Lockout, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Lockout{}, fmt.Errorf("auth: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	lo, err := scanLockout(tx.QueryRow(ctx, `
+		INSERT INTO account_lockouts (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET failed_count = 0, lock_count = 0, locked_until = NULL, updated_at = get_tx_timestamp()
+		RETURNING `+lockoutColumns, userID))
+	if err != nil {
+		return Lockout{}, fmt.Errorf("auth: unlock account: %w", err)
+	}
+	if err := insertAuditLog(ctx, tx, actorID, "ACCOUNT_UNLOCKED", map[string]any{"user_id": userID}); err != nil {
+		return Lockout{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Lockout{}, fmt.Errorf("auth: commit unlock: %w", err)
+	}
+	return lo, nil
+}
+
+func insertAuditLog(ctx context.Context, tx pgx.Tx, actorID, action string, metadata map[string]any) error {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("auth: marshal audit metadata: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO audit_logs (actor_id, action, metadata)
+		VALUES (NULLIF($1, '')::uuid, $2, $3::jsonb)
+	`, actorID, action, body); err != nil {
+		return fmt.Errorf("auth: write audit log: %w", err)
+	}
+	return nil
+}
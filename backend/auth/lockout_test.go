@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeLockoutRepository struct {
+	attempts []LoginAttempt
+	lockouts map[string]Lockout
+	audit    []string
+}
+
+func newFakeLockoutRepository() *fakeLockoutRepository {
+	return &fakeLockoutRepository{lockouts: make(map[string]Lockout)}
+}
+
+func (f *fakeLockoutRepository) RecordLoginAttempt(_ context.Context, a LoginAttempt) error {
+	f.attempts = append(f.attempts, a)
+	return nil
+}
+
+func (f *fakeLockoutRepository) CountIPFailures(_ context.Context, ip string, _ time.Time) (int, error) {
+	n := 0
+	for _, a := range f.attempts {
+		if a.IP == ip && !a.Succeeded {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeLockoutRepository) GetLockout(_ context.Context, userID string) (Lockout, error) {
+	lo, ok := f.lockouts[userID]
+	if !ok {
+		return Lockout{UserID: userID}, nil
+	}
+	return lo, nil
+}
+
+func (f *fakeLockoutRepository) AddLoginFailure(_ context.Context, userID string) (Lockout, error) {
+	lo := f.lockouts[userID]
+	lo.UserID = userID
+	lo.FailedCount++
+	f.lockouts[userID] = lo
+	return lo, nil
+}
+
+func (f *fakeLockoutRepository) LockAccount(_ context.Context, userID string, until time.Time, _ string) (Lockout, error) {
+	lo := f.lockouts[userID]
+	lo.LockedUntil = &until
+	lo.LockCount++
+	lo.FailedCount = 0
+	f.lockouts[userID] = lo
+	f.audit = append(f.audit, "ACCOUNT_LOCKED")
+	return lo, nil
+}
+
+func (f *fakeLockoutRepository) ResetLoginFailures(_ context.Context, userID string) error {
+	delete(f.lockouts, userID)
+	return nil
+}
+
+func (f *fakeLockoutRepository) UnlockAccount(_ context.Context, userID, _ string) (Lockout, error) {
+	delete(f.lockouts, userID)
+	f.audit = append(f.audit, "ACCOUNT_UNLOCKED")
+	return Lockout{UserID: userID}, nil
+}
+
+func TestLockoutPolicy_LockDuration(t *testing.T) {
+	p := DefaultLockoutPolicy()
+	cases := map[int]time.Duration{0: time.Minute, 1: 2 * time.Minute, 3: 8 * time.Minute, 20: 24 * time.Hour}
+	for prior, want := range cases {
+		if got := p.LockDuration(prior); got != want {
+			t.Errorf("prior=%d: got %s, want %s", prior, got, want)
+		}
+	}
+}
+
+func newLockoutFixture(t *testing.T) (*Service, *fakeLockoutRepository, *clock.Fake, User) {
+	t.Helper()
+	users := newFakeRepository()
+	hash, err := bcrypt.GenerateFromPassword([]byte("supersafe"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user, err := users.CreateUser(context.Background(), CreateUserParams{Email: "a@example.com", FullName: "A", PasswordHash: string(hash)})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	repo := newFakeLockoutRepository()
+	clk := clock.NewFake(time.Now())
+	svc := NewService(users, "test-secret").WithClock(clk).WithLockout(repo, DefaultLockoutPolicy())
+	return svc, repo, clk, user
+}
+
+func TestLogin_LocksAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, clk, user := newLockoutFixture(t)
+	bad := LoginRequest{Email: user.Email, Password: "wrong-pass", RemoteIP: "203.0.113.7"}
+	good := LoginRequest{Email: user.Email, Password: "supersafe", RemoteIP: "203.0.113.7"}
+
+	for i := 0; i < 5; i++ {
+		if _, err := svc.Login(ctx, bad); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+	if len(repo.audit) != 1 || repo.audit[0] != "ACCOUNT_LOCKED" {
+		t.Fatalf("expected one lock audit entry, got %v", repo.audit)
+	}
+
+	// The right password is refused while locked.
+	_, err := svc.Login(ctx, good)
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected LockedError, got %v", err)
+	}
+	if want := clk.Now().Add(time.Minute); !locked.Until.Equal(want) {
+		t.Fatalf("expected lock until %s, got %s", want, locked.Until)
+	}
+
+	// The next lock is twice as long.
+	clk.Advance(time.Minute)
+	for i := 0; i < 5; i++ {
+		svc.Login(ctx, bad)
+	}
+	if _, err := svc.Login(ctx, good); !errors.As(err, &locked) || !locked.Until.Equal(clk.Now().Add(2*time.Minute)) {
+		t.Fatalf("expected doubled lock, got %v", err)
+	}
+
+	// An admin unlock lets the user in and success resets the counters.
+	if _, err := svc.Unlock(ctx, user.ID, "admin-1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if res, err := svc.Login(ctx, good); err != nil || res.Token == "" {
+		t.Fatalf("login after unlock: %+v %v", res, err)
+	}
+	if _, ok := repo.lockouts[user.ID]; ok {
+		t.Fatal("expected counters reset after successful login")
+	}
+	if last := repo.attempts[len(repo.attempts)-1]; !last.Succeeded || last.IP != "203.0.113.7" {
+		t.Fatalf("expected successful attempt recorded, got %+v", last)
+	}
+}
+
+func TestLogin_ThrottlesIP(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, _, _ := newLockoutFixture(t)
+	for i := 0; i < DefaultLockoutPolicy().MaxIPFailures; i++ {
+		repo.attempts = append(repo.attempts, LoginAttempt{Email: "x@example.com", IP: "198.51.100.1"})
+	}
+
+	if _, err := svc.Login(ctx, LoginRequest{Email: "a@example.com", Password: "supersafe", RemoteIP: "198.51.100.1"}); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if _, err := svc.Login(ctx, LoginRequest{Email: "a@example.com", Password: "supersafe", RemoteIP: "198.51.100.2"}); err != nil {
+		t.Fatalf("other IP: %v", err)
+	}
+}
+
+func TestLogin_RecordsUnknownEmail(t *testing.T) {
+	svc, repo, _, _ := newLockoutFixture(t)
+	if _, err := svc.Login(context.Background(), LoginRequest{Email: "nobody@example.com", Password: "x", RemoteIP: "192.0.2.1"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].UserID != "" || repo.attempts[0].Reason != "unknown_email" {
+		t.Fatalf("unexpected attempts: %+v", repo.attempts)
+	}
+}
@@ -21,10 +21,12 @@ type User struct {
 	Phone        *string
 	Languages    []string
 	BrokerID     *string
-	Rating       float64
-	Role         Role
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// OfficeID is the office of BrokerID the user is assigned to, if any.
+	OfficeID  *string
+	Rating    float64
+	Role      Role
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 // RegisterRequest contains user registration data supplied by callers.
@@ -39,4 +41,9 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// RemoteIP is the caller's address, set by the transport for attempt
+	// tracking.
+	RemoteIP string `json:"-"`
+	// UserAgent describes the device for the login's session.
+	UserAgent string `json:"-"`
 }
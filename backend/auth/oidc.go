@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOIDCUnknownProvider signals a provider name that is not configured.
+	ErrOIDCUnknownProvider = errors.New("auth: unknown sign-in provider")
+	// ErrOIDCInvalidState signals a callback whose state is unknown, expired,
+	// already used or issued for another provider.
+	ErrOIDCInvalidState = errors.New("auth: sign-in request expired or is invalid; start again")
+	// ErrOIDCLoginFailed signals a code the provider refused or an ID token
+	// that failed verification.
+	ErrOIDCLoginFailed = errors.New("auth: single sign-on failed")
+	// ErrOIDCEmailNotVerified signals a first sign-in whose email the provider
+	// has not verified, so it cannot be linked or provisioned.
+	ErrOIDCEmailNotVerified = errors.New("auth: the provider has not verified this email address")
+	// ErrOIDCNoAccount signals a first sign-in that matches no user and no
+	// role rule.
+	ErrOIDCNoAccount = errors.New("auth: no account matches this sign-in; ask your broker admin for an invitation")
+)
+
+// oidcStateTTL bounds how long a user may take at the provider.
+const oidcStateTTL = 10 * time.Minute
+
+// UnusablePasswordHash is stored for users provisioned by single sign-on or
+// SCIM. It is not a bcrypt hash, so password login always fails for them.
+const UnusablePasswordHash = "!"
+
+// OIDCProvider configures one OpenID Connect provider. Issuer is the
+// provider's issuer URL, whose discovery document names its endpoints;
+// RedirectURL is the registered callback, a frontend page that posts the
+// code and state to /auth/oidc/{provider}/callback.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested besides openid; nil means email and profile.
+	Scopes []string
+	// RoleRules provision users on their first sign-in.
+	RoleRules []RoleRule
+}
+
+// RoleRule provisions a user whose verified email matches. Match is either a
+// full address or "@domain"; an address rule wins over a domain rule.
+type RoleRule struct {
+	Match    string
+	BrokerID string
+	Role     Role
+}
+
+// ParseRoleRules reads comma-separated "<match>=<broker id>:<role>" rules,
+// e.g. "@acme.com=<broker id>:agent,boss@acme.com=<broker id>:broker_admin".
+func ParseRoleRules(spec string) ([]RoleRule, error) {
+	var rules []RoleRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		match, target, ok := strings.Cut(part, "=")
+		brokerID, role, ok2 := strings.Cut(target, ":")
+		match = strings.ToLower(strings.TrimSpace(match))
+		rule := RoleRule{Match: match, BrokerID: strings.TrimSpace(brokerID), Role: Role(strings.TrimSpace(role))}
+		if !ok || !ok2 || !strings.Contains(match, "@") || rule.BrokerID == "" || !isValidRole(rule.Role) {
+			return nil, fmt.Errorf("auth: invalid role rule %q", part)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func matchRoleRule(rules []RoleRule, email string) (RoleRule, bool) {
+	email = strings.ToLower(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return RoleRule{}, false
+	}
+	var domainRule *RoleRule
+	for i, rule := range rules {
+		if rule.Match == email {
+			return rule, true
+		}
+		if rule.Match == email[at:] && domainRule == nil {
+			domainRule = &rules[i]
+		}
+	}
+	if domainRule != nil {
+		return *domainRule, true
+	}
+	return RoleRule{}, false
+}
+
+// OIDCState is an authorization request in flight.
+type OIDCState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// OIDCIdentity links a provider's subject to a user.
+type OIDCIdentity struct {
+	Provider string
+	Subject  string
+	UserID   string
+	Email    string
+}
+
+// OIDCRepository handles data access for single sign-on.
+type OIDCRepository interface {
+	// SaveOIDCState stores a new authorization request and drops expired
+	// ones.
+	SaveOIDCState(ctx context.Context, state OIDCState) error
+	// TakeOIDCState deletes and returns the request for state, failing with
+	// ErrOIDCInvalidState when it is unknown or expired at now.
+	TakeOIDCState(ctx context.Context, state string, now time.Time) (OIDCState, error)
+	// GetUserByIdentity returns ErrUserNotFound for an unlinked subject.
+	GetUserByIdentity(ctx context.Context, provider, subject string) (User, error)
+	// GetUserByEmailFold matches email case-insensitively and returns
+	// ErrUserNotFound when no live user has it.
+	GetUserByEmailFold(ctx context.Context, email string) (User, error)
+	// LinkIdentity records identity and stamps its last login.
+	LinkIdentity(ctx context.Context, identity OIDCIdentity) error
+	// CreateOIDCUser creates the user and links identity to it in one
+	// transaction.
+	CreateOIDCUser(ctx context.Context, params CreateUserParams, identity OIDCIdentity) (User, error)
+}
+
+// WithOIDC enables single sign-on through providers.
+func (s *Service) WithOIDC(repo OIDCRepository, providers ...OIDCProvider) *Service {
+	s.oidc = repo
+	s.oidcProviders = make(map[string]*oidcProvider, len(providers))
+	for _, p := range providers {
+		s.oidcProviders[p.Name] = newOIDCProvider(p)
+	}
+	return s
+}
+
+// OIDCProviders names the configured providers, sorted.
+func (s *Service) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OIDCAuthorizeURL starts a sign-in with provider and returns the URL to send
+// the browser to. The request uses PKCE and a nonce bound to the stored
+// state.
+func (s *Service) OIDCAuthorizeURL(ctx context.Context, provider string) (string, error) {
+	p, ok := s.oidcProviders[provider]
+	if !ok {
+		return "", ErrOIDCUnknownProvider
+	}
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	state := OIDCState{Provider: provider, ExpiresAt: s.clock.Now().Add(oidcStateTTL)}
+	for _, v := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		if *v, err = randomToken(); err != nil {
+			return "", fmt.Errorf("auth: generate oidc state: %w", err)
+		}
+	}
+	if err := s.oidc.SaveOIDCState(ctx, state); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(state.CodeVerifier))
+	scopes := p.config.Scopes
+	if scopes == nil {
+		scopes = []string{"email", "profile"}
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return endpoints.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// OIDCLoginRequest is the provider's callback, relayed by the frontend.
+type OIDCLoginRequest struct {
+	Provider  string
+	Code      string
+	State     string
+	RemoteIP  string
+	UserAgent string
+}
+
+// OIDCLogin completes a sign-in: it exchanges the code, verifies the ID
+// token and resolves the user by linked identity, then by verified email,
+// then by the provider's role rules. The result is the same as Login's,
+// including the two-factor challenge.
+func (s *Service) OIDCLogin(ctx context.Context, req OIDCLoginRequest) (LoginResult, error) {
+	p, ok := s.oidcProviders[req.Provider]
+	if !ok {
+		return LoginResult{}, ErrOIDCUnknownProvider
+	}
+	if err := s.checkIPThrottle(ctx, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
+	state, err := s.oidc.TakeOIDCState(ctx, req.State, s.clock.Now())
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if state.Provider != req.Provider {
+		return LoginResult{}, ErrOIDCInvalidState
+	}
+	claims, err := p.exchange(ctx, req.Code, state, s.clock.Now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	user, err := s.resolveOIDCUser(ctx, p.config, claims)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := s.checkLocked(ctx, user, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
+	required, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if required {
+		challenge, err := s.generateChallenge(user.ID)
+		if err != nil {
+			return LoginResult{}, fmt.Errorf("auth: generate challenge: %w", err)
+		}
+		return LoginResult{ChallengeToken: challenge, User: user}, nil
+	}
+	if err := s.registerSuccess(ctx, user, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
+	token, err := s.issueToken(ctx, user, req.RemoteIP, req.UserAgent)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("auth: generate token: %w", err)
+	}
+	return LoginResult{Token: token, User: user}, nil
+}
+
+func (s *Service) resolveOIDCUser(ctx context.Context, p OIDCProvider, claims idTokenClaims) (User, error) {
+	identity := OIDCIdentity{Provider: p.Name, Subject: claims.Subject, Email: strings.ToLower(claims.Email)}
+	user, err := s.oidc.GetUserByIdentity(ctx, p.Name, claims.Subject)
+	if err == nil {
+		identity.UserID = user.ID
+		return user, s.oidc.LinkIdentity(ctx, identity)
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return User{}, err
+	}
+
+	if identity.Email == "" || !claims.EmailVerified {
+		return User{}, ErrOIDCEmailNotVerified
+	}
+	user, err = s.oidc.GetUserByEmailFold(ctx, identity.Email)
+	if err == nil {
+		identity.UserID = user.ID
+		return user, s.oidc.LinkIdentity(ctx, identity)
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return User{}, err
+	}
+
+	rule, ok := matchRoleRule(p.RoleRules, identity.Email)
+	if !ok {
+		return User{}, ErrOIDCNoAccount
+	}
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = identity.Email[:strings.LastIndex(identity.Email, "@")]
+	}
+	brokerID := rule.BrokerID
+	user, err = s.oidc.CreateOIDCUser(ctx, CreateUserParams{
+		Email:        identity.Email,
+		FullName:     name,
+		PasswordHash: UnusablePasswordHash,
+		Role:         rule.Role,
+		BrokerID:     &brokerID,
+	}, identity)
+	if errors.Is(err, ErrDuplicateEmail) {
+		// A deactivated account holds the address.
+		return User{}, ErrOIDCNoAccount
+	}
+	return user, err
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcRequestTimeout = 10 * time.Second
+	// oidcKeysMinRefresh limits how often an unknown key id refetches the
+	// provider's JWKS.
+	oidcKeysMinRefresh = time.Minute
+)
+
+// oidcEndpoints is the part of a discovery document sign-in needs.
+type oidcEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims sign-in reads.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   jsonBool `json:"email_verified"`
+	Name            string   `json:"name"`
+	AuthorizedParty string   `json:"azp"`
+}
+
+// jsonBool accepts true and "true"; some providers send email_verified as a
+// string.
+type jsonBool bool
+
+func (b *jsonBool) UnmarshalJSON(data []byte) error {
+	*b = strings.Trim(string(data), `"`) == "true"
+	return nil
+}
+
+// oidcProvider talks to one provider. Its discovery document is fetched once
+// and its signing keys are cached until a token names an unknown key.
+type oidcProvider struct {
+	config OIDCProvider
+	client *http.Client
+
+	mu          sync.Mutex
+	endpoints   *oidcEndpoints
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func newOIDCProvider(config OIDCProvider) *oidcProvider {
+	return &oidcProvider{config: config, client: &http.Client{Timeout: oidcRequestTimeout}}
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (oidcEndpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.endpoints != nil {
+		return *p.endpoints, nil
+	}
+	var doc oidcEndpoints
+	if err := p.getJSON(ctx, strings.TrimRight(p.config.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return oidcEndpoints{}, fmt.Errorf("auth: oidc discovery for %s: %w", p.config.Name, err)
+	}
+	if doc.Issuer != p.config.Issuer {
+		return oidcEndpoints{}, fmt.Errorf("auth: oidc discovery for %s: issuer %q does not match %q", p.config.Name, doc.Issuer, p.config.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return oidcEndpoints{}, fmt.Errorf("auth: oidc discovery for %s: missing endpoints", p.config.Name)
+	}
+	p.endpoints = &doc
+	return doc, nil
+}
+
+// exchange redeems code at the token endpoint and returns the verified ID
+// token's claims.
+func (p *oidcProvider) exchange(ctx context.Context, code string, state OIDCState, now func() time.Time) (idTokenClaims, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return idTokenClaims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {state.CodeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("auth: build oidc token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("auth: oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("auth: read oidc token response: %w", err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		// invalid_grant and friends: the code is wrong, used or expired.
+		return idTokenClaims{}, ErrOIDCLoginFailed
+	}
+	if resp.StatusCode != http.StatusOK {
+		return idTokenClaims{}, fmt.Errorf("auth: oidc token endpoint returned %d", resp.StatusCode)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return idTokenClaims{}, ErrOIDCLoginFailed
+	}
+	return p.verify(ctx, tokens.IDToken, state.Nonce, now)
+}
+
+// verify checks the ID token's RS256 signature, issuer, audience, expiry and
+// nonce.
+func (p *oidcProvider) verify(ctx context.Context, raw, nonce string, now func() time.Time) (idTokenClaims, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithTimeFunc(now),
+	)
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	if claims.Nonce != nonce || claims.Subject == "" {
+		return idTokenClaims{}, ErrOIDCLoginFailed
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return idTokenClaims{}, ErrOIDCLoginFailed
+	}
+	return claims, nil
+}
+
+// key returns the provider's signing key kid, refetching the JWKS when kid
+// is unknown and the keys were not fetched within oidcKeysMinRefresh.
+func (p *oidcProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < oidcKeysMinRefresh {
+		return nil, fmt.Errorf("auth: unknown oidc signing key %q", kid)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, endpoints.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("auth: fetch oidc keys for %s: %w", p.config.Name, err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("auth: unknown oidc signing key %q", kid)
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", rawURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SaveOIDCState stores an authorization request and drops expired ones.
+func (r *PGRepository) SaveOIDCState(ctx context.Context, state OIDCState) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM oidc_states WHERE expires_at < get_tx_timestamp()`); err != nil {
+		return fmt.Errorf("auth: prune oidc states: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, `
+		INSERT INTO oidc_states (state, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, state.State, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt); err != nil {
+		return fmt.Errorf("auth: save oidc state: %w", err)
+	}
+	return nil
+}
+
+// TakeOIDCState consumes the request for state.
+func (r *PGRepository) TakeOIDCState(ctx context.Context, state string, now time.Time) (OIDCState, error) {
+	s := OIDCState{State: state}
+	err := r.pool.QueryRow(ctx, `
+		DELETE FROM oidc_states WHERE state = $1
+		RETURNING provider, nonce, code_verifier, expires_at
+	`, state).Scan(&s.Provider, &s.Nonce, &s.CodeVerifier, &s.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return OIDCState{}, ErrOIDCInvalidState
+	}
+	if err != nil {
+		return OIDCState{}, fmt.Errorf("auth: take oidc state: %w", err)
+	}
+	if !now.Before(s.ExpiresAt) {
+		return OIDCState{}, ErrOIDCInvalidState
+	}
+	return s, nil
+}
+
+// GetUserByIdentity retrieves the live user linked to a provider subject.
+func (r *PGRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (User, error) {
+	user, err := scanUser(r.pool.QueryRow(ctx, `
+		SELECT u.id, u.email, u.full_name, u.password_hash, u.phone, u.languages, u.broker_id, u.office_id, u.rating, u.role, u.created_at, u.updated_at
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL AND u.deactivated_at IS NULL
+	`, provider, subject))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("auth: get user by identity: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByEmailFold retrieves a live user by email, ignoring case.
+func (r *PGRepository) GetUserByEmailFold(ctx context.Context, email string) (User, error) {
+	user, err := scanUser(r.pool.QueryRow(ctx, `
+		SELECT id, email, full_name, password_hash, phone, languages, broker_id, office_id, rating, role, created_at, updated_at
+		FROM users
+		WHERE lower(email) = lower($1) AND deleted_at IS NULL AND deactivated_at IS NULL
+		ORDER BY created_at
+		LIMIT 1
+	`, email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("auth: get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// LinkIdentity records identity, or refreshes its email and last login.
+func (r *PGRepository) LinkIdentity(ctx context.Context, identity OIDCIdentity) error {
+	return linkIdentity(ctx, r.pool, identity)
+}
+
+// CreateOIDCUser creates a user provisioned by single sign-on and links its
+// identity in one transaction.
+func (r *PGRepository) CreateOIDCUser(ctx context.Context, params CreateUserParams, identity OIDCIdentity) (User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("auth: begin create oidc user: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := createUser(ctx, tx, params)
+	if err != nil {
+		return User{}, err
+	}
+	identity.UserID = user.ID
+	if err := linkIdentity(ctx, tx, identity); err != nil {
+		return User{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, fmt.Errorf("auth: commit create oidc user: %w", err)
+	}
+	return user, nil
+}
+
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func linkIdentity(ctx context.Context, db execer, identity OIDCIdentity) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO user_identities (provider, subject, user_id, email, last_login_at)
+		VALUES ($1, $2, $3, $4, get_tx_timestamp())
+		ON CONFLICT (provider, subject) DO UPDATE
+		SET email = EXCLUDED.email, last_login_at = EXCLUDED.last_login_at
+	`, identity.Provider, identity.Subject, identity.UserID, identity.Email); err != nil {
+		return fmt.Errorf("auth: link identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type fakeOIDCRepository struct {
+	*fakeRepository
+	states     map[string]OIDCState
+	identities map[string]string
+}
+
+func newFakeOIDCRepository() *fakeOIDCRepository {
+	return &fakeOIDCRepository{
+		fakeRepository: newFakeRepository(),
+		states:         map[string]OIDCState{},
+		identities:     map[string]string{},
+	}
+}
+
+func (f *fakeOIDCRepository) SaveOIDCState(_ context.Context, state OIDCState) error {
+	f.states[state.State] = state
+	return nil
+}
+
+func (f *fakeOIDCRepository) TakeOIDCState(_ context.Context, state string, now time.Time) (OIDCState, error) {
+	s, ok := f.states[state]
+	delete(f.states, state)
+	if !ok || !now.Before(s.ExpiresAt) {
+		return OIDCState{}, ErrOIDCInvalidState
+	}
+	return s, nil
+}
+
+func (f *fakeOIDCRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (User, error) {
+	id, ok := f.identities[provider+"/"+subject]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return f.GetUserByID(ctx, id)
+}
+
+func (f *fakeOIDCRepository) GetUserByEmailFold(ctx context.Context, email string) (User, error) {
+	return f.GetUserByEmail(ctx, email)
+}
+
+func (f *fakeOIDCRepository) LinkIdentity(_ context.Context, identity OIDCIdentity) error {
+	f.identities[identity.Provider+"/"+identity.Subject] = identity.UserID
+	return nil
+}
+
+func (f *fakeOIDCRepository) CreateOIDCUser(ctx context.Context, params CreateUserParams, identity OIDCIdentity) (User, error) {
+	user, err := f.CreateUser(ctx, params)
+	if err != nil {
+		return User{}, err
+	}
+	user.BrokerID = params.BrokerID
+	f.usersByID[user.ID] = user
+	identity.UserID = user.ID
+	return user, f.LinkIdentity(ctx, identity)
+}
+
+// fakeIdP is an OpenID provider whose token endpoint answers every code with
+// an ID token carrying claims and the nonce of the last authorization.
+type fakeIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	nonce  string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "idp-1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" || r.FormValue("client_secret") != "shh" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": idp.URL, "aud": "client-1", "nonce": idp.nonce,
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "idp-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "at"})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize starts a sign-in and returns its state, recording the nonce the
+// provider will echo.
+func (idp *fakeIdP) authorize(t *testing.T, svc *Service) string {
+	t.Helper()
+	target, err := svc.OIDCAuthorizeURL(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "client-1" || q.Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected authorization url %s", target)
+	}
+	idp.nonce = q.Get("nonce")
+	return q.Get("state")
+}
+
+func newOIDCTestService(idp *fakeIdP, repo *fakeOIDCRepository) *Service {
+	return NewService(repo, "secret").WithOIDC(repo, OIDCProvider{
+		Name: "acme", Issuer: idp.URL, ClientID: "client-1", ClientSecret: "shh",
+		RedirectURL: "https://app.example.com/sso/callback",
+		RoleRules:   []RoleRule{{Match: "@acme.com", BrokerID: "broker-1", Role: RoleAgent}},
+	})
+}
+
+func TestOIDCLoginLinksExistingUserByVerifiedEmail(t *testing.T) {
+	idp := newFakeIdP(t)
+	repo := newFakeOIDCRepository()
+	existing, _ := repo.CreateUser(context.Background(), CreateUserParams{Email: "ann@example.com", FullName: "Ann", Role: RoleBrokerAdmin})
+	svc := newOIDCTestService(idp, repo)
+	ctx := context.Background()
+
+	idp.claims = jwt.MapClaims{"sub": "s-1", "email": "Ann@Example.com", "email_verified": false}
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: idp.authorize(t, svc)}); !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Fatalf("unverified email: got %v", err)
+	}
+
+	idp.claims["email_verified"] = "true"
+	res, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: idp.authorize(t, svc)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User.ID != existing.ID || res.User.Role != RoleBrokerAdmin || res.Token == "" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if id, err := svc.VerifyToken(ctx, res.Token); err != nil || id.UserID != existing.ID || id.Role != RoleBrokerAdmin {
+		t.Fatalf("VerifyToken = %+v, %v", id, err)
+	}
+
+	// Once linked, the subject signs in even without an email claim.
+	idp.claims = jwt.MapClaims{"sub": "s-1"}
+	if res, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: idp.authorize(t, svc)}); err != nil || res.User.ID != existing.ID {
+		t.Fatalf("linked login: %+v, %v", res.User, err)
+	}
+}
+
+func TestOIDCLoginProvisionsFromRoleRules(t *testing.T) {
+	idp := newFakeIdP(t)
+	repo := newFakeOIDCRepository()
+	svc := newOIDCTestService(idp, repo)
+	ctx := context.Background()
+
+	idp.claims = jwt.MapClaims{"sub": "s-2", "email": "bo@acme.com", "email_verified": true, "name": "Bo Agent"}
+	res, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: idp.authorize(t, svc)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User.Role != RoleAgent || res.User.BrokerID == nil || *res.User.BrokerID != "broker-1" || res.User.FullName != "Bo Agent" {
+		t.Fatalf("unexpected user %+v", res.User)
+	}
+	if _, err := svc.Login(ctx, LoginRequest{Email: "bo@acme.com", Password: UnusablePasswordHash}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("password login for provisioned user: got %v", err)
+	}
+
+	idp.claims = jwt.MapClaims{"sub": "s-3", "email": "cy@elsewhere.com", "email_verified": true}
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: idp.authorize(t, svc)}); !errors.Is(err, ErrOIDCNoAccount) {
+		t.Fatalf("no matching rule: got %v", err)
+	}
+}
+
+func TestOIDCLoginRejectsReplayAndForgery(t *testing.T) {
+	idp := newFakeIdP(t)
+	repo := newFakeOIDCRepository()
+	svc := newOIDCTestService(idp, repo)
+	ctx := context.Background()
+	idp.claims = jwt.MapClaims{"sub": "s-4", "email": "di@acme.com", "email_verified": true}
+
+	state := idp.authorize(t, svc)
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "bad-code", State: state}); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("refused code: got %v", err)
+	}
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: state}); !errors.Is(err, ErrOIDCInvalidState) {
+		t.Fatalf("reused state: got %v", err)
+	}
+
+	state = idp.authorize(t, svc)
+	idp.nonce = "someone-elses-nonce"
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: state}); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("wrong nonce: got %v", err)
+	}
+
+	state = idp.authorize(t, svc)
+	idp.claims["aud"] = "another-client"
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "acme", Code: "good-code", State: state}); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("wrong audience: got %v", err)
+	}
+
+	if _, err := svc.OIDCLogin(ctx, OIDCLoginRequest{Provider: "nope", Code: "good-code", State: state}); !errors.Is(err, ErrOIDCUnknownProvider) {
+		t.Fatalf("unknown provider: got %v", err)
+	}
+}
+
+func TestParseRoleRules(t *testing.T) {
+	rules, err := ParseRoleRules(" @Acme.com=b1:agent, boss@acme.com=b1:broker_admin ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule, ok := matchRoleRule(rules, "BOSS@acme.com"); !ok || rule.Role != RoleBrokerAdmin {
+		t.Fatalf("address rule: %+v, %v", rule, ok)
+	}
+	if rule, ok := matchRoleRule(rules, "ann@acme.com"); !ok || rule.Role != RoleAgent || rule.BrokerID != "b1" {
+		t.Fatalf("domain rule: %+v, %v", rule, ok)
+	}
+	if _, ok := matchRoleRule(rules, "ann@sub.acme.com"); ok {
+		t.Fatal("subdomain matched a domain rule")
+	}
+	for _, bad := range []string{"acme.com=b1:agent", "@acme.com=b1", "@acme.com=:agent", "@acme.com=b1:owner"} {
+		if _, err := ParseRoleRules(bad); err == nil {
+			t.Errorf("ParseRoleRules(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the longest password bcrypt hashes; it refuses longer
+// input rather than truncating it.
+const bcryptMaxBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// PasswordError reports why a password was refused. It unwraps to
+// ErrWeakPassword and its message is written for the client.
+type PasswordError struct {
+	Reason string
+}
+
+func (e *PasswordError) Error() string {
+	return "auth: password " + e.Reason
+}
+
+func (e *PasswordError) Unwrap() error { return ErrWeakPassword }
+
+// PasswordPolicy configures which passwords registrations accept. A password
+// needs MinLength characters and at least MinClasses of lowercase letters,
+// uppercase letters, digits and symbols, and may not be in Denylist, compared
+// case-insensitively.
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+	Denylist   map[string]struct{}
+}
+
+// DefaultPasswordPolicy requires 8 characters and refuses the built-in list
+// of common passwords.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 8, Denylist: CommonPasswords()}
+}
+
+// CommonPasswords returns the built-in denylist.
+func CommonPasswords() map[string]struct{} {
+	words, _ := ReadDenylist(strings.NewReader(commonPasswordList))
+	return words
+}
+
+// ReadDenylist reads one password per line, skipping blank lines and lines
+// starting with #.
+func ReadDenylist(r io.Reader) (map[string]struct{}, error) {
+	words := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("auth: read password denylist: %w", err)
+	}
+	return words, nil
+}
+
+// Check returns a *PasswordError when password breaks the policy.
+func (p PasswordPolicy) Check(password string) error {
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return &PasswordError{Reason: fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > bcryptMaxBytes {
+		return &PasswordError{Reason: fmt.Sprintf("must be at most %d bytes", bcryptMaxBytes)}
+	}
+	if p.MinClasses > 0 && characterClasses(password) < p.MinClasses {
+		return &PasswordError{Reason: fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)}
+	}
+	if _, ok := p.Denylist[strings.ToLower(password)]; ok {
+		return &PasswordError{Reason: "is too common"}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// BreachChecker looks a password up in known data breaches.
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// WithPasswordPolicy replaces DefaultPasswordPolicy.
+func (s *Service) WithPasswordPolicy(policy PasswordPolicy) *Service {
+	s.passwordPolicy = policy
+	return s
+}
+
+// WithBreachChecker refuses passwords found in data breaches. A failed lookup
+// is logged and the password accepted, so an outage of the breach service
+// does not block registrations.
+func (s *Service) WithBreachChecker(checker BreachChecker) *Service {
+	s.breaches = checker
+	return s
+}
+
+// CheckPassword applies the password policy and, when configured, the breach
+// check. Every path that sets a password goes through it.
+func (s *Service) CheckPassword(ctx context.Context, password string) error {
+	if err := s.passwordPolicy.Check(password); err != nil {
+		return err
+	}
+	if s.breaches == nil {
+		return nil
+	}
+	breached, err := s.breaches.Breached(ctx, password)
+	if err != nil {
+		log.Printf("auth: breach check: %v", err)
+		return nil
+	}
+	if breached {
+		return &PasswordError{Reason: "has appeared in a data breach; choose another"}
+	}
+	return nil
+}
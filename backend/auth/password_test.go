@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.MinLength = 10
+	policy.MinClasses = 3
+
+	for password, want := range map[string]string{
+		"Sh0rt!":                "at least 10 characters",
+		strings.Repeat("a", 73): "at most 72 bytes",
+		"alllowercase1":         "at least 3 of",
+		"P@ssw0rd":              "at least 10 characters",
+		"Qwerty12345":           "too common",
+		"Tr0ub4dor&3x":          "",
+		"Ünïcödé-Päss1":         "",
+	} {
+		err := policy.Check(password)
+		if want == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %v, want nil", password, err)
+			}
+			continue
+		}
+		var perr *PasswordError
+		if !errors.As(err, &perr) || !errors.Is(err, ErrWeakPassword) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Check(%q) = %v, want a PasswordError containing %q", password, err, want)
+		}
+	}
+}
+
+func TestReadDenylistSkipsCommentsAndFoldsCase(t *testing.T) {
+	words, err := ReadDenylist(strings.NewReader("# comment\n\n  Acme2026  \nhunter2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 2 {
+		t.Fatalf("unexpected words %v", words)
+	}
+	if err := (PasswordPolicy{MinLength: 1, Denylist: words}).Check("ACME2026"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("denylisted password accepted: %v", err)
+	}
+}
+
+func TestHIBPCheckerSendsOnlyPrefix(t *testing.T) {
+	sum := sha1.Sum([]byte("correct horse"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var gotPath, gotPadding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotPadding = r.URL.Path, r.Header.Get("Add-Padding")
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n%s:3\r\n00D4F6E8FA6EECAD2A3AA415EEC418D38EC:0\r\n", strings.ToLower(hash[5:]))
+	}))
+	defer srv.Close()
+
+	checker, err := NewHIBPChecker(srv.URL + "/range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	breached, err := checker.Breached(context.Background(), "correct horse")
+	if err != nil || !breached {
+		t.Fatalf("Breached = %v, %v", breached, err)
+	}
+	if gotPath != "/range/"+hash[:5] || gotPadding != "true" {
+		t.Fatalf("request path %q, Add-Padding %q", gotPath, gotPadding)
+	}
+	if breached, err := checker.Breached(context.Background(), "a different password"); err != nil || breached {
+		t.Fatalf("unlisted password: Breached = %v, %v", breached, err)
+	}
+}
+
+type stubBreaches struct {
+	breached bool
+	err      error
+}
+
+func (s stubBreaches) Breached(context.Context, string) (bool, error) { return s.breached, s.err }
+
+func TestRegisterAppliesBreachCheck(t *testing.T) {
+	ctx := context.Background()
+	req := RegisterRequest{Email: "a@example.com", Password: "supersafe", FullName: "A"}
+
+	svc := NewService(newFakeRepository(), "secret").WithBreachChecker(stubBreaches{breached: true})
+	if _, err := svc.Register(ctx, req); !errors.Is(err, ErrWeakPassword) || !strings.Contains(err.Error(), "data breach") {
+		t.Fatalf("breached password: got %v", err)
+	}
+
+	svc = NewService(newFakeRepository(), "secret").WithBreachChecker(stubBreaches{err: errors.New("unreachable")})
+	if _, err := svc.Register(ctx, req); err != nil {
+		t.Fatalf("breach check outage should not block registration: %v", err)
+	}
+}
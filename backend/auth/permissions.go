@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RefreshedTokenHeader carries a re-minted token when the one a request
+// presented no longer matches its user's role or broker. Clients replace
+// their token with it.
+const RefreshedTokenHeader = "X-Refreshed-Token"
+
+// Permission is a power a token grants, minted from the user's role.
+type Permission string
+
+const (
+	// PermReferralsWrite covers working referrals: creating and cancelling
+	// them, inviting and answering matches, the marketplace, signing and
+	// cancelling agreements and uploading files.
+	PermReferralsWrite Permission = "referrals:write"
+	// PermBrokerRead widens list and report endpoints to the user's whole
+	// brokerage (or their office when it scopes its admins).
+	PermBrokerRead Permission = "broker:read"
+	// PermBrokerManage covers the brokerage's settings, offices,
+	// invitations and webhooks.
+	PermBrokerManage Permission = "broker:manage"
+	// PermUsersUnlock lets the holder lift login lockouts.
+	PermUsersUnlock Permission = "users:unlock"
+	// PermOutboxAdmin covers the outbox admin endpoints and topic catalogue.
+	PermOutboxAdmin Permission = "outbox:admin"
+	// PermAgreementsCorrect lets the holder override the status an
+	// erroneous event gave an agreement of their brokerage.
+	PermAgreementsCorrect Permission = "agreements:correct"
+	// PermClientPortal lets a client read their own referrals' progress.
+	PermClientPortal Permission = "client_portal:read"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAgent: {PermReferralsWrite},
+	RoleBrokerAdmin: {
+		PermReferralsWrite, PermBrokerRead, PermBrokerManage,
+		PermUsersUnlock, PermOutboxAdmin, PermAgreementsCorrect,
+	},
+	RoleClient: {PermClientPortal},
+}
+
+// RolePermissions returns the permissions tokens of role carry.
+func RolePermissions(role Role) []Permission {
+	return slices.Clone(rolePermissions[role])
+}
+
+// Can reports whether id holds p.
+func (id Identity) Can(p Permission) bool {
+	return slices.Contains(id.Permissions, p)
+}
+
+// identityFor is the identity a fresh token for user carries.
+func identityFor(user User, sessionID string) Identity {
+	id := Identity{UserID: user.ID, Role: user.Role, SessionID: sessionID, Permissions: RolePermissions(user.Role)}
+	if user.BrokerID != nil {
+		id.BrokerID = *user.BrokerID
+	}
+	return id
+}
+
+// stale reports whether id's claims no longer match user.
+func (id Identity) stale(user User) bool {
+	current := identityFor(user, id.SessionID)
+	return id.Role != current.Role || id.BrokerID != current.BrokerID || !slices.Equal(id.Permissions, current.Permissions)
+}
+
+// Refresh checks a verified identity against its user's current role and
+// broker, read through the user cache that role and membership changes
+// invalidate. A stale identity is replaced by the current one and re-minted
+// into a token with the same session and expiry; token is empty when id
+// was current.
+func (s *Service) Refresh(ctx context.Context, id Identity) (current Identity, token string, err error) {
+	user, err := s.GetUserByID(ctx, id.UserID)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	if !id.stale(*user) {
+		return id, "", nil
+	}
+	current = identityFor(*user, id.SessionID)
+	current.ExpiresAt = id.ExpiresAt
+	if current.ExpiresAt.IsZero() {
+		// Tokens from before expiry claims get the usual lifetime.
+		current.ExpiresAt = s.clock.Now().Add(TokenTTL)
+	}
+	token, err = s.signIdentity(current, current.ExpiresAt)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	return current, token, nil
+}
+
+// signIdentity mints a token carrying id's claims that expires at exp.
+func (s *Service) signIdentity(id Identity, exp time.Time) (string, error) {
+	perms := make([]string, len(id.Permissions))
+	for i, p := range id.Permissions {
+		perms[i] = string(p)
+	}
+	claims := jwt.MapClaims{
+		"user_id": id.UserID,
+		"role":    id.Role,
+		"perms":   perms,
+		"exp":     exp.Unix(),
+		"iat":     s.clock.Now().Unix(),
+	}
+	if id.BrokerID != "" {
+		claims["broker_id"] = id.BrokerID
+	}
+	if id.SessionID != "" {
+		claims["sid"] = id.SessionID
+	}
+	return s.keys.sign(claims)
+}
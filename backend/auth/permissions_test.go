@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoginMintsScopedClaims(t *testing.T) {
+	repo := newFakeRepository()
+	svc := NewService(repo, "test-secret")
+	ctx := context.Background()
+	user, err := svc.Register(ctx, RegisterRequest{Email: "admin@example.com", Password: "supersafe", FullName: "Ada Admin", Role: RoleBrokerAdmin})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	broker := "broker-1"
+	stored := repo.usersByID[user.ID]
+	stored.BrokerID = &broker
+	repo.usersByID[user.ID] = stored
+	repo.usersByEmail[stored.Email] = stored
+
+	res, err := svc.Login(ctx, LoginRequest{Email: "admin@example.com", Password: "supersafe"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	id, err := svc.VerifyToken(ctx, res.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if id.BrokerID != broker || !slices.Equal(id.Permissions, RolePermissions(RoleBrokerAdmin)) || !id.Can(PermBrokerManage) {
+		t.Fatalf("unexpected claims %+v", id)
+	}
+	if current, token, err := svc.Refresh(ctx, id); err != nil || token != "" || current.Role != RoleBrokerAdmin {
+		t.Fatalf("current identity refreshed: %+v, %q, %v", current, token, err)
+	}
+}
+
+func TestRefreshReMintsAfterDemotion(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	clk := clock.NewFake(now)
+	repo := newFakeRepository()
+	svc := NewService(repo, "test-secret").WithClock(clk)
+	ctx := context.Background()
+	user, err := svc.Register(ctx, RegisterRequest{Email: "admin@example.com", Password: "supersafe", FullName: "Ada Admin", Role: RoleBrokerAdmin})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	res, err := svc.Login(ctx, LoginRequest{Email: "admin@example.com", Password: "supersafe"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	id, err := svc.VerifyToken(ctx, res.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	demoted := repo.usersByID[user.ID]
+	demoted.Role = RoleAgent
+	repo.usersByID[user.ID] = demoted
+	clk.Advance(time.Hour)
+
+	current, token, err := svc.Refresh(ctx, id)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if token == "" || current.Role != RoleAgent || current.Can(PermBrokerManage) || !current.Can(PermReferralsWrite) {
+		t.Fatalf("expected demoted identity and new token, got %+v, %q", current, token)
+	}
+	reminted, err := svc.VerifyToken(ctx, token)
+	if err != nil {
+		t.Fatalf("verify re-minted token: %v", err)
+	}
+	if reminted.Role != RoleAgent || !reminted.ExpiresAt.Equal(id.ExpiresAt) || !reminted.ExpiresAt.Equal(now.Add(TokenTTL)) {
+		t.Fatalf("re-minted token should keep the login expiry, got %+v", reminted)
+	}
+}
+
+func TestVerifyTokenLegacyClaimsFallBackToRole(t *testing.T) {
+	svc := NewService(newFakeRepository(), "test-secret")
+	token, err := svc.keys.sign(jwt.MapClaims{"user_id": "u1", "role": "broker_admin"})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	id, err := svc.VerifyToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !slices.Equal(id.Permissions, RolePermissions(RoleBrokerAdmin)) || id.BrokerID != "" {
+		t.Fatalf("unexpected legacy identity %+v", id)
+	}
+}
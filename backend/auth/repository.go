@@ -20,6 +20,8 @@ var (
 // Repository handles data access for authentication.
 type Repository interface {
 	CreateUser(ctx context.Context, params CreateUserParams) (User, error)
+	// GetUserByEmail and GetUserByID return ErrUserNotFound for erased and
+	// deactivated users.
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, userID string) (User, error)
 }
@@ -30,6 +32,9 @@ type CreateUserParams struct {
 	FullName     string
 	PasswordHash string
 	Role         Role
+	// BrokerID binds the user to a brokerage from the start; registrations
+	// through an invitation set it.
+	BrokerID *string
 }
 
 // PGRepository implements Repository backed by PostgreSQL.
@@ -44,13 +49,28 @@ func NewRepository(pool *pgxpool.Pool) *PGRepository {
 
 // CreateUser inserts a new user with hashed password.
 func (r *PGRepository) CreateUser(ctx context.Context, params CreateUserParams) (User, error) {
+	return createUser(ctx, r.pool, params)
+}
+
+// CreateUserTx inserts the user inside tx, for work that must commit with
+// the new account, such as accepting an invitation.
+func (r *PGRepository) CreateUserTx(ctx context.Context, tx pgx.Tx, params CreateUserParams) (User, error) {
+	return createUser(ctx, tx, params)
+}
+
+// rowQuerier is satisfied by both the pool and a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func createUser(ctx context.Context, q rowQuerier, params CreateUserParams) (User, error) {
 	const insertSQL = `
-		INSERT INTO users (email, full_name, password_hash, role)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, email, full_name, password_hash, phone, languages, broker_id, rating, role, created_at, updated_at
+		INSERT INTO users (email, full_name, password_hash, role, broker_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, email, full_name, password_hash, phone, languages, broker_id, office_id, rating, role, created_at, updated_at
 	`
 
-	user, err := scanUser(r.pool.QueryRow(ctx, insertSQL, params.Email, params.FullName, params.PasswordHash, params.Role))
+	user, err := scanUser(q.QueryRow(ctx, insertSQL, params.Email, params.FullName, params.PasswordHash, params.Role, params.BrokerID))
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -65,9 +85,9 @@ func (r *PGRepository) CreateUser(ctx context.Context, params CreateUserParams)
 // GetUserByEmail retrieves a user by email address.
 func (r *PGRepository) GetUserByEmail(ctx context.Context, email string) (User, error) {
 	const selectSQL = `
-		SELECT id, email, full_name, password_hash, phone, languages, broker_id, rating, role, created_at, updated_at
+		SELECT id, email, full_name, password_hash, phone, languages, broker_id, office_id, rating, role, created_at, updated_at
 		FROM users
-		WHERE email = $1
+		WHERE email = $1 AND deleted_at IS NULL AND deactivated_at IS NULL
 	`
 
 	user, err := scanUser(r.pool.QueryRow(ctx, selectSQL, email))
@@ -84,9 +104,9 @@ func (r *PGRepository) GetUserByEmail(ctx context.Context, email string) (User,
 // GetUserByID retrieves a user by ID.
 func (r *PGRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
 	const selectSQL = `
-		SELECT id, email, full_name, password_hash, phone, languages, broker_id, rating, role, created_at, updated_at
+		SELECT id, email, full_name, password_hash, phone, languages, broker_id, office_id, rating, role, created_at, updated_at
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL AND deactivated_at IS NULL
 	`
 
 	user, err := scanUser(r.pool.QueryRow(ctx, selectSQL, userID))
@@ -115,6 +135,7 @@ func scanUser(row pgx.Row) (User, error) {
 		&phone,
 		&languages,
 		&brokerID,
+		&user.OfficeID,
 		&user.Rating,
 		&user.Role,
 		&user.CreatedAt,
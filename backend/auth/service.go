@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
+	"brokerflow/cache"
+	"brokerflow/clock"
+
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,89 +18,170 @@ import (
 var (
 	// ErrInvalidCredentials signals wrong email or password.
 	ErrInvalidCredentials = errors.New("auth: invalid credentials")
-	// ErrWeakPassword signals password doesn't meet requirements.
-	ErrWeakPassword = errors.New("auth: password must be at least 8 characters")
+	// ErrWeakPassword signals a password the password policy refuses; the
+	// error is a *PasswordError naming the reason.
+	ErrWeakPassword = errors.New("auth: password does not meet the password policy")
 )
 
 // Service handles authentication business logic.
 type Service struct {
-	repo      Repository
-	jwtSecret []byte
+	repo          Repository
+	twoFactor     TwoFactorRepository
+	lockout       LockoutRepository
+	lockoutPolicy LockoutPolicy
+	erasure       ErasureRepository
+	piiRetention  time.Duration
+	keys          *KeySet
+	sessions      SessionRepository
+	clock         clock.Clock
+	cache         cache.Cache
+	cacheTTL      time.Duration
+
+	passwordPolicy PasswordPolicy
+	breaches       BreachChecker
+
+	oidc          OIDCRepository
+	oidcProviders map[string]*oidcProvider
 }
 
 // LoginResult bundles the token and domain user returned after a successful login.
+// When the user has two-factor authentication enabled, Token is empty and
+// ChallengeToken must be exchanged via VerifyTwoFactor.
 type LoginResult struct {
-	Token string
-	User  User
+	Token          string
+	ChallengeToken string
+	User           User
 }
 
-// NewService creates a new authentication service.
+// NewService creates a new authentication service signing tokens with
+// jwtSecret under DefaultKeyID.
 func NewService(repo Repository, jwtSecret string) *Service {
+	keys, _ := NewKeySet(DefaultKeyID, NewHMACKey(DefaultKeyID, []byte(jwtSecret)))
 	return &Service{
-		repo:      repo,
-		jwtSecret: []byte(jwtSecret),
+		repo:           repo,
+		keys:           keys,
+		clock:          clock.New(),
+		passwordPolicy: DefaultPasswordPolicy(),
 	}
 }
 
+// WithKeys replaces the signing keys, e.g. to rotate them or sign with RS256.
+func (s *Service) WithKeys(keys *KeySet) *Service {
+	s.keys = keys
+	return s
+}
+
+// JWKS returns the public keys other services verify tokens with.
+func (s *Service) JWKS() []JWK {
+	return s.keys.JWKS()
+}
+
+// WithClock overrides the time source used for token issuance.
+func (s *Service) WithClock(c clock.Clock) *Service {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
 // Register creates a new user account.
 func (s *Service) Register(ctx context.Context, req RegisterRequest) (*User, error) {
+	params, err := s.PrepareUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.CreateUser(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// PrepareUser validates a registration and hashes its password, returning
+// the row to insert. Register uses it, as do registrations that create the
+// user inside another unit of work.
+func (s *Service) PrepareUser(ctx context.Context, req RegisterRequest) (CreateUserParams, error) {
 	// Validate password strength
-	if len(req.Password) < 8 {
-		return nil, ErrWeakPassword
+	if err := s.CheckPassword(ctx, req.Password); err != nil {
+		return CreateUserParams{}, err
 	}
 
 	// Validate required fields
 	if req.Email == "" || req.FullName == "" {
-		return nil, fmt.Errorf("auth: email and full_name are required")
+		return CreateUserParams{}, fmt.Errorf("auth: email and full_name are required")
 	}
 
 	// Hash password
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, fmt.Errorf("auth: hash password: %w", err)
+		return CreateUserParams{}, fmt.Errorf("auth: hash password: %w", err)
 	}
 
-	// Create user
 	role := Role(strings.TrimSpace(string(req.Role)))
 	if role == "" {
 		role = RoleAgent
 	}
 	if !isValidRole(role) {
-		return nil, fmt.Errorf("auth: invalid role %q", role)
+		return CreateUserParams{}, fmt.Errorf("auth: invalid role %q", role)
 	}
 
-	user, err := s.repo.CreateUser(ctx, CreateUserParams{
+	return CreateUserParams{
 		Email:        req.Email,
 		FullName:     req.FullName,
 		PasswordHash: string(passwordHash),
 		Role:         role,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	}, nil
 }
 
 // Login authenticates a user and returns a JWT token.
 func (s *Service) Login(ctx context.Context, req LoginRequest) (LoginResult, error) {
+	if err := s.checkIPThrottle(ctx, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
+
 	// Get user by email
 	user, err := s.repo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, ErrUserNotFound) {
+			if err := s.recordAttempt(ctx, "", req.Email, req.RemoteIP, false, "unknown_email"); err != nil {
+				return LoginResult{}, err
+			}
 			return LoginResult{}, ErrInvalidCredentials
 		}
 		return LoginResult{}, err
 	}
+	if err := s.checkLocked(ctx, user, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
+		if err := s.registerFailure(ctx, user, req.RemoteIP, "bad_password"); err != nil {
+			return LoginResult{}, err
+		}
 		return LoginResult{}, ErrInvalidCredentials
 	}
 
+	// Stop at a challenge when a second factor is required; counters are
+	// only reset once the second factor has been verified too.
+	required, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if required {
+		challenge, err := s.generateChallenge(user.ID)
+		if err != nil {
+			return LoginResult{}, fmt.Errorf("auth: generate challenge: %w", err)
+		}
+		return LoginResult{ChallengeToken: challenge, User: user}, nil
+	}
+	if err := s.registerSuccess(ctx, user, req.RemoteIP); err != nil {
+		return LoginResult{}, err
+	}
+
 	// Generate JWT token
-	token, err := s.generateToken(user.ID, user.Role)
+	token, err := s.issueToken(ctx, user, req.RemoteIP, req.UserAgent)
 	if err != nil {
 		return LoginResult{}, fmt.Errorf("auth: generate token: %w", err)
 	}
@@ -107,64 +192,136 @@ func (s *Service) Login(ctx context.Context, req LoginRequest) (LoginResult, err
 	}, nil
 }
 
-// GetUserByID retrieves user information by ID.
+// WithCache serves GetUserByID from c, caching users for ttl. Whatever
+// changes a users row outside this service must call InvalidateUser.
+func (s *Service) WithCache(c cache.Cache, ttl time.Duration) *Service {
+	s.cache = c
+	s.cacheTTL = ttl
+	return s
+}
+
+func userCacheKey(userID string) string {
+	return "auth:user:" + userID
+}
+
+// GetUserByID retrieves user information by ID. The password hash is left
+// out: it is only needed by Login, and must not end up in the cache.
 func (s *Service) GetUserByID(ctx context.Context, userID string) (*User, error) {
-	user, err := s.repo.GetUserByID(ctx, userID)
+	if s.cache == nil {
+		user, err := s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = ""
+		return &user, nil
+	}
+	key := userCacheKey(userID)
+	user, ok, err := cache.GetJSON[User](ctx, s.cache, key)
+	if err != nil {
+		log.Printf("auth: read user cache: %v", err)
+	}
+	if ok {
+		return &user, nil
+	}
+	user, err = s.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	user.PasswordHash = ""
+	if err := cache.SetJSON(ctx, s.cache, key, user, s.cacheTTL); err != nil {
+		log.Printf("auth: write user cache: %v", err)
+	}
 	return &user, nil
 }
 
-// VerifyToken validates a JWT token and returns the user ID.
-func (s *Service) VerifyToken(tokenString string) (string, Role, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.jwtSecret, nil
-	})
+// UserInvalidator drops cached copies of a user. Packages that change users
+// rows themselves take one; *Service satisfies it.
+type UserInvalidator interface {
+	InvalidateUser(ctx context.Context, userID string)
+}
+
+// InvalidateUser drops the cached copy of userID.
+func (s *Service) InvalidateUser(ctx context.Context, userID string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, userCacheKey(userID)); err != nil {
+		log.Printf("auth: invalidate user cache: %v", err)
+	}
+}
+
+// VerifyToken validates a JWT token and returns who it speaks for. With
+// sessions enabled, tokens of revoked or expired sessions fail with
+// ErrSessionRevoked; tokens issued before sessions existed carry no sid and
+// are accepted until they expire.
+func (s *Service) VerifyToken(ctx context.Context, tokenString string) (Identity, error) {
+	token, err := jwt.Parse(tokenString, s.keys.keyFunc)
 
 	if err != nil {
-		return "", "", fmt.Errorf("auth: parse token: %w", err)
+		return Identity{}, fmt.Errorf("auth: parse token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			return "", "", fmt.Errorf("auth: invalid user_id in token")
-		}
-		roleStr, ok := claims["role"].(string)
-		if !ok {
-			return "", "", fmt.Errorf("auth: invalid role in token")
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return Identity{}, fmt.Errorf("auth: invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("auth: invalid user_id in token")
+	}
+	roleStr, ok := claims["role"].(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("auth: invalid role in token")
+	}
+	role := Role(roleStr)
+	if !isValidRole(role) {
+		return Identity{}, fmt.Errorf("auth: invalid role %q in token", roleStr)
+	}
+	sessionID, _ := claims["sid"].(string)
+	brokerID, _ := claims["broker_id"].(string)
+	var expiresAt time.Time
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+	if sessionID != "" && s.sessions != nil {
+		live, err := s.sessions.TouchSession(ctx, sessionID, userID, s.clock.Now())
+		if err != nil {
+			return Identity{}, err
 		}
-		role := Role(roleStr)
-		if !isValidRole(role) {
-			return "", "", fmt.Errorf("auth: invalid role %q in token", roleStr)
+		if !live {
+			return Identity{}, ErrSessionRevoked
 		}
-		return userID, role, nil
 	}
+	return Identity{
+		UserID:      userID,
+		Role:        role,
+		SessionID:   sessionID,
+		BrokerID:    brokerID,
+		Permissions: permissionsClaim(claims, role),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
 
-	return "", "", fmt.Errorf("auth: invalid token")
+// generateToken mints a token for user carrying its role, broker and
+// permissions, naming sessionID in the sid claim when it is set.
+func (s *Service) generateToken(user User, sessionID string) (string, error) {
+	return s.signIdentity(identityFor(user, sessionID), s.clock.Now().Add(TokenTTL))
 }
 
-// generateToken creates a JWT token for the user.
-func (s *Service) generateToken(userID string, role Role) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
-		"iat":     time.Now().Unix(),
+// permissionsClaim reads the perms claim. Tokens minted before it existed
+// fall back to the role's permissions.
+func permissionsClaim(claims jwt.MapClaims, role Role) []Permission {
+	raw, ok := claims["perms"].([]any)
+	if !ok {
+		return RolePermissions(role)
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
-	if err != nil {
-		return "", err
+	perms := make([]Permission, 0, len(raw))
+	for _, p := range raw {
+		if name, ok := p.(string); ok {
+			perms = append(perms, Permission(name))
+		}
 	}
-
-	return tokenString, nil
+	return perms
 }
 
 func isValidRole(role Role) bool {
@@ -7,6 +7,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"brokerflow/cache"
+	"brokerflow/clock"
 )
 
 func TestService_RegisterAndLogin(t *testing.T) {
@@ -46,15 +49,15 @@ func TestService_RegisterAndLogin(t *testing.T) {
 		t.Fatalf("login: expected role %s got %s", RoleAgent, resp.User.Role)
 	}
 
-	tokenUserID, tokenRole, err := svc.VerifyToken(resp.Token)
+	identity, err := svc.VerifyToken(ctx, resp.Token)
 	if err != nil {
 		t.Fatalf("verify token: %v", err)
 	}
-	if tokenUserID != user.ID {
-		t.Fatalf("verify token: expected %q got %q", user.ID, tokenUserID)
+	if identity.UserID != user.ID {
+		t.Fatalf("verify token: expected %q got %q", user.ID, identity.UserID)
 	}
-	if tokenRole != RoleAgent {
-		t.Fatalf("verify token: expected role %s got %s", RoleAgent, tokenRole)
+	if identity.Role != RoleAgent {
+		t.Fatalf("verify token: expected role %s got %s", RoleAgent, identity.Role)
 	}
 }
 
@@ -111,6 +114,25 @@ func TestService_LoginInvalidCredentials(t *testing.T) {
 	}
 }
 
+func TestService_TokenExpiryFollowsClock(t *testing.T) {
+	repo := newFakeRepository()
+	issued := clock.NewFake(time.Now().Add(-48 * time.Hour))
+	svc := NewService(repo, "test-secret").WithClock(issued)
+
+	req := RegisterRequest{Email: "bob@example.com", Password: "strongpassword", FullName: "Bob Broker"}
+	if _, err := svc.Register(context.Background(), req); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	resp, err := svc.Login(context.Background(), LoginRequest{Email: req.Email, Password: req.Password})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if _, err := svc.VerifyToken(context.Background(), resp.Token); err == nil {
+		t.Fatal("expected token issued 48h ago by the injected clock to be expired")
+	}
+}
+
 type fakeRepository struct {
 	usersByEmail map[string]User
 	usersByID    map[string]User
@@ -169,3 +191,47 @@ func (f *fakeRepository) GetUserByID(ctx context.Context, userID string) (User,
 	}
 	return user, nil
 }
+
+// countingRepository counts GetUserByID calls on a fakeRepository.
+type countingRepository struct {
+	*fakeRepository
+	gets int
+}
+
+func (c *countingRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
+	c.gets++
+	return c.fakeRepository.GetUserByID(ctx, userID)
+}
+
+func TestService_GetUserByIDCachesWithoutPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepository{fakeRepository: newFakeRepository()}
+	c := cache.NewMemory()
+	svc := NewService(repo, "test-secret").WithCache(c, time.Minute)
+
+	registered, err := svc.Register(ctx, RegisterRequest{Email: "bob@example.com", Password: "supersafe", FullName: "Bob"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	for range 2 {
+		user, err := svc.GetUserByID(ctx, registered.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if user.Email != "bob@example.com" || user.PasswordHash != "" {
+			t.Fatalf("unexpected user %+v", user)
+		}
+	}
+	if repo.gets != 1 {
+		t.Fatalf("expected one repository read, got %d", repo.gets)
+	}
+	raw, ok, _ := c.Get(ctx, userCacheKey(registered.ID))
+	if !ok || strings.Contains(string(raw), "$2a$") {
+		t.Fatalf("expected a cached user without the hash, got %q", raw)
+	}
+
+	svc.InvalidateUser(ctx, registered.ID)
+	if _, err := svc.GetUserByID(ctx, registered.ID); err != nil || repo.gets != 2 {
+		t.Fatalf("expected a fresh read after invalidation, got %v after %d reads", err, repo.gets)
+	}
+}
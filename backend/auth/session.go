@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSessionNotFound signals a session that does not exist or belongs
+	// to another user.
+	ErrSessionNotFound = errors.New("auth: session not found")
+	// ErrSessionRevoked signals a token whose session was revoked or has
+	// expired.
+	ErrSessionRevoked = errors.New("auth: session revoked")
+)
+
+// TokenTTL is how long a session token, and its session, is valid.
+const TokenTTL = 24 * time.Hour
+
+// maxUserAgent bounds the stored User-Agent.
+const maxUserAgent = 512
+
+// Session is one login: the device and address it came from and when its
+// token was last used.
+type Session struct {
+	ID         string
+	UserID     string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// Identity is who a verified session token speaks for. SessionID is empty
+// for tokens issued without sessions. BrokerID and Permissions are the
+// scoped claims minted at login; tokens minted before they existed get the
+// role's permissions and no broker, so Refresh re-mints them.
+type Identity struct {
+	UserID      string
+	Role        Role
+	SessionID   string
+	BrokerID    string
+	Permissions []Permission
+	ExpiresAt   time.Time
+}
+
+// SessionRepository handles data access for login sessions.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s Session) (Session, error)
+	// TouchSession reports whether userID's session id is neither revoked
+	// nor expired at now, recording now as its last use.
+	TouchSession(ctx context.Context, id, userID string, now time.Time) (bool, error)
+	// ListSessions returns userID's live sessions, most recently used first.
+	ListSessions(ctx context.Context, userID string, now time.Time) ([]Session, error)
+	// RevokeSession revokes userID's live session id; ErrSessionNotFound
+	// when there is none.
+	RevokeSession(ctx context.Context, id, userID string) error
+}
+
+// WithSessions records a session for every login and makes VerifyToken
+// reject tokens whose session was revoked.
+func (s *Service) WithSessions(repo SessionRepository) *Service {
+	s.sessions = repo
+	return s
+}
+
+// ListSessions returns userID's live sessions.
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	if s.sessions == nil {
+		return []Session{}, nil
+	}
+	return s.sessions.ListSessions(ctx, userID, s.clock.Now())
+}
+
+// RevokeSession logs userID out of one of its sessions; its token is
+// refused from then on.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if s.sessions == nil {
+		return ErrSessionNotFound
+	}
+	return s.sessions.RevokeSession(ctx, sessionID, userID)
+}
+
+// issueToken signs a session token for user, recording the session when
+// sessions are enabled.
+func (s *Service) issueToken(ctx context.Context, user User, ip, userAgent string) (string, error) {
+	now := s.clock.Now()
+	sessionID := ""
+	if s.sessions != nil {
+		if len(userAgent) > maxUserAgent {
+			userAgent = userAgent[:maxUserAgent]
+		}
+		session, err := s.sessions.CreateSession(ctx, Session{
+			UserID:    user.ID,
+			UserAgent: userAgent,
+			IP:        ip,
+			ExpiresAt: now.Add(TokenTTL),
+		})
+		if err != nil {
+			return "", err
+		}
+		sessionID = session.ID
+	}
+	return s.generateToken(user, sessionID)
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sessionTouchInterval throttles last_seen_at writes so verifying a token
+// does not write on every request.
+const sessionTouchInterval = time.Minute
+
+const sessionColumns = `id::text, user_id::text, user_agent, COALESCE(ip, ''), created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	return s, err
+}
+
+// CreateSession inserts a session.
+func (r *PGRepository) CreateSession(ctx context.Context, s Session) (Session, error) {
+	created, err := scanSession(r.pool.QueryRow(ctx, `
+		INSERT INTO user_sessions (user_id, user_agent, ip, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING `+sessionColumns,
+		s.UserID, s.UserAgent, s.IP, s.ExpiresAt))
+	if err != nil {
+		return Session{}, fmt.Errorf("auth: create session: %w", err)
+	}
+	return created, nil
+}
+
+// TouchSession checks the session and bumps last_seen_at when it is older
+// than sessionTouchInterval.
+func (r *PGRepository) TouchSession(ctx context.Context, id, userID string, now time.Time) (bool, error) {
+	var live bool
+	err := r.pool.QueryRow(ctx, `
+		WITH touched AS (
+			UPDATE user_sessions
+			SET last_seen_at = $3
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > $3
+			  AND last_seen_at < $3 - make_interval(secs => $4)
+		)
+		SELECT EXISTS (
+			SELECT 1 FROM user_sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > $3
+		)
+	`, id, userID, now, sessionTouchInterval.Seconds()).Scan(&live)
+	if err != nil {
+		return false, fmt.Errorf("auth: touch session: %w", err)
+	}
+	return live, nil
+}
+
+// ListSessions returns live sessions, most recently used first.
+func (r *PGRepository) ListSessions(ctx context.Context, userID string, now time.Time) ([]Session, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC, id
+	`, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("auth: list sessions: %w", err)
+	}
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Session, error) { return scanSession(row) })
+	if err != nil {
+		return nil, fmt.Errorf("auth: scan sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes a live session of userID.
+func (r *PGRepository) RevokeSession(ctx context.Context, id, userID string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_sessions
+		SET revoked_at = get_tx_timestamp()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > get_tx_timestamp()
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("auth: revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
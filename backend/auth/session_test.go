@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+)
+
+type fakeSessionRepository struct {
+	sessions map[string]Session
+	nextID   int
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{sessions: make(map[string]Session)}
+}
+
+func (f *fakeSessionRepository) CreateSession(_ context.Context, s Session) (Session, error) {
+	f.nextID++
+	s.ID = fmt.Sprintf("session-%d", f.nextID)
+	f.sessions[s.ID] = s
+	return s, nil
+}
+
+func (f *fakeSessionRepository) TouchSession(_ context.Context, id, userID string, now time.Time) (bool, error) {
+	s, ok := f.sessions[id]
+	if !ok || s.UserID != userID || s.RevokedAt != nil || !s.ExpiresAt.After(now) {
+		return false, nil
+	}
+	s.LastSeenAt = now
+	f.sessions[id] = s
+	return true, nil
+}
+
+func (f *fakeSessionRepository) ListSessions(_ context.Context, userID string, now time.Time) ([]Session, error) {
+	out := []Session{}
+	for _, s := range f.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && s.ExpiresAt.After(now) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (f *fakeSessionRepository) RevokeSession(_ context.Context, id, userID string) error {
+	s, ok := f.sessions[id]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	now := time.Now()
+	s.RevokedAt = &now
+	f.sessions[id] = s
+	return nil
+}
+
+func TestSessions_LoginRecordsDeviceAndRevocationRejectsToken(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	sessions := newFakeSessionRepository()
+	clk := clock.NewFake(time.Now())
+	svc := NewService(repo, "test-secret").WithClock(clk).WithSessions(sessions)
+	user, err := svc.Register(ctx, RegisterRequest{Email: "a@example.com", Password: "supersafe", FullName: "A"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	login := func(agent string) string {
+		t.Helper()
+		res, err := svc.Login(ctx, LoginRequest{Email: user.Email, Password: "supersafe", RemoteIP: "203.0.113.7", UserAgent: agent})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		return res.Token
+	}
+	laptop, phone := login("Firefox"), login("iPhone")
+
+	identity, err := svc.VerifyToken(ctx, laptop)
+	if err != nil || identity.SessionID == "" || identity.UserID != user.ID {
+		t.Fatalf("verify: %+v %v", identity, err)
+	}
+	listed, err := svc.ListSessions(ctx, user.ID)
+	if err != nil || len(listed) != 2 || listed[0].UserAgent != "Firefox" || listed[0].IP != "203.0.113.7" {
+		t.Fatalf("unexpected sessions %+v (%v)", listed, err)
+	}
+
+	if err := svc.RevokeSession(ctx, "someone-else", identity.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected another user's revoke to find nothing, got %v", err)
+	}
+	if err := svc.RevokeSession(ctx, user.ID, identity.SessionID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := svc.VerifyToken(ctx, laptop); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected the revoked token to be refused, got %v", err)
+	}
+	if _, err := svc.VerifyToken(ctx, phone); err != nil {
+		t.Fatalf("other sessions stay valid: %v", err)
+	}
+	if listed, _ := svc.ListSessions(ctx, user.ID); len(listed) != 1 || listed[0].UserAgent != "iPhone" {
+		t.Fatalf("expected only the phone session, got %+v", listed)
+	}
+
+	// Tokens from before sessions carry no sid and are not checked.
+	legacy, err := svc.generateToken(User{ID: user.ID, Role: RoleAgent}, "")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if identity, err := svc.VerifyToken(ctx, legacy); err != nil || identity.SessionID != "" {
+		t.Fatalf("legacy token: %+v %v", identity, err)
+	}
+}
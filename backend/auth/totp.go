@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	totpIssuer         = "BrokerFlow"
+	totpPeriod         = 30
+	totpDigits         = 6
+	totpSkew           = 1
+	totpSecretBytes    = 20
+	recoveryCodeCount  = 10
+	challengeTTL       = 5 * time.Minute
+	challengeTokenType = "2fa_challenge"
+)
+
+var (
+	// ErrTwoFactorNotAllowed signals a role that may not enroll a second factor.
+	ErrTwoFactorNotAllowed = errors.New("auth: two-factor authentication is available to broker admins")
+	// ErrTwoFactorEnabled signals an enrollment attempt while TOTP is active.
+	ErrTwoFactorEnabled = errors.New("auth: two-factor authentication already enabled")
+	// ErrTwoFactorNotEnrolled signals a confirmation without a pending secret.
+	ErrTwoFactorNotEnrolled = errors.New("auth: no two-factor enrollment in progress")
+	// ErrInvalidTwoFactorCode signals a wrong, expired or replayed code.
+	ErrInvalidTwoFactorCode = errors.New("auth: invalid two-factor code")
+	// ErrInvalidChallenge signals an unknown or expired login challenge token.
+	ErrInvalidChallenge = errors.New("auth: invalid or expired login challenge")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is a user's TOTP secret. EnabledAt is nil until the first
+// code has been confirmed.
+type TOTPEnrollment struct {
+	UserID       string
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+}
+
+// TOTPSetup is returned when enrolling: the base32 secret and an otpauth://
+// URI that authenticator apps read from a QR code.
+type TOTPSetup struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// TwoFactorRepository handles data access for TOTP and recovery codes.
+type TwoFactorRepository interface {
+	// GetTOTP returns ErrTwoFactorNotEnrolled when the user has no secret.
+	GetTOTP(ctx context.Context, userID string) (TOTPEnrollment, error)
+	// SaveTOTPSecret replaces a pending secret; ErrTwoFactorEnabled if active.
+	SaveTOTPSecret(ctx context.Context, userID, secret string) error
+	// EnableTOTP activates the pending secret and replaces the recovery codes.
+	EnableTOTP(ctx context.Context, userID string, step int64, recoveryHashes []string) error
+	// UseTOTPStep records step as used, reporting false if it is not newer
+	// than the last accepted one.
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode consumes an unused code, reporting whether one matched.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+}
+
+// WithTwoFactor enables TOTP enrollment and the login challenge step.
+func (s *Service) WithTwoFactor(repo TwoFactorRepository) *Service {
+	s.twoFactor = repo
+	return s
+}
+
+// EnrollTOTP starts (or restarts) enrollment for a broker admin. The secret
+// is not enforced until ConfirmTOTP succeeds.
+func (s *Service) EnrollTOTP(ctx context.Context, userID string) (TOTPSetup, error) {
+	if s.twoFactor == nil {
+		return TOTPSetup{}, ErrTwoFactorNotAllowed
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+	if user.Role != RoleBrokerAdmin {
+		return TOTPSetup{}, ErrTwoFactorNotAllowed
+	}
+
+	raw := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return TOTPSetup{}, fmt.Errorf("auth: generate totp secret: %w", err)
+	}
+	secret := totpEncoding.EncodeToString(raw)
+	if err := s.twoFactor.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		return TOTPSetup{}, err
+	}
+	return TOTPSetup{Secret: secret, ProvisioningURI: provisioningURI(user.Email, secret)}, nil
+}
+
+// ConfirmTOTP activates the pending secret once the user proves possession
+// with a current code, and returns single-use recovery codes in clear. They
+// cannot be retrieved again.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if s.twoFactor == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	enrollment, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.EnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := matchTOTP(enrollment.Secret, code, s.clock.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return nil, fmt.Errorf("auth: generate recovery code: %w", err)
+		}
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := s.twoFactor.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactor completes a login started by Login with a TOTP or recovery
+// code and returns the session token. Wrong codes count towards the account
+// lockout like wrong passwords; ip and userAgent describe the caller.
+func (s *Service) VerifyTwoFactor(ctx context.Context, challengeToken, code, ip, userAgent string) (LoginResult, error) {
+	if s.twoFactor == nil {
+		return LoginResult{}, ErrInvalidChallenge
+	}
+	userID, err := s.parseChallenge(challengeToken)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return LoginResult{}, ErrInvalidChallenge
+		}
+		return LoginResult{}, err
+	}
+	enrollment, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotEnrolled) {
+			return LoginResult{}, ErrInvalidChallenge
+		}
+		return LoginResult{}, err
+	}
+	if enrollment.EnabledAt == nil {
+		return LoginResult{}, ErrInvalidChallenge
+	}
+	if err := s.checkLocked(ctx, user, ip); err != nil {
+		return LoginResult{}, err
+	}
+
+	var accepted bool
+	if step, ok := matchTOTP(enrollment.Secret, code, s.clock.Now()); ok {
+		accepted, err = s.twoFactor.UseTOTPStep(ctx, userID, step)
+	} else {
+		accepted, err = s.twoFactor.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if !accepted {
+		if err := s.registerFailure(ctx, user, ip, "bad_second_factor"); err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{}, ErrInvalidTwoFactorCode
+	}
+	if err := s.registerSuccess(ctx, user, ip); err != nil {
+		return LoginResult{}, err
+	}
+
+	token, err := s.issueToken(ctx, user, ip, userAgent)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("auth: generate token: %w", err)
+	}
+	return LoginResult{Token: token, User: user}, nil
+}
+
+// twoFactorEnabled reports whether login must stop at a challenge.
+func (s *Service) twoFactorEnabled(ctx context.Context, userID string) (bool, error) {
+	if s.twoFactor == nil {
+		return false, nil
+	}
+	enrollment, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return enrollment.EnabledAt != nil, nil
+}
+
+// generateChallenge issues the short-lived token exchanged for a session
+// token by VerifyTwoFactor. It carries no role, so VerifyToken rejects it.
+func (s *Service) generateChallenge(userID string) (string, error) {
+	now := s.clock.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"typ":     challengeTokenType,
+		"exp":     now.Add(challengeTTL).Unix(),
+		"iat":     now.Unix(),
+	}
+	return s.keys.sign(claims)
+}
+
+func (s *Service) parseChallenge(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, s.keys.keyFunc)
+	if err != nil || !token.Valid {
+		return "", ErrInvalidChallenge
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != challengeTokenType {
+		return "", ErrInvalidChallenge
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", ErrInvalidChallenge
+	}
+	return userID, nil
+}
+
+func provisioningURI(account, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpCode computes the RFC 6238 code for a time step.
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// matchTOTP checks code against the current step and one step either side
+// to allow for clock drift, returning the step it matched.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCode returns a code such as "k3j9f-q2m8x".
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 7)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// hashRecoveryCode digests a recovery code ignoring case, spaces and dashes.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetTOTP loads a user's TOTP enrollment.
+func (r *PGRepository) GetTOTP(ctx context.Context, userID string) (TOTPEnrollment, error) {
+	e := TOTPEnrollment{UserID: userID}
+	err := r.pool.QueryRow(ctx, `SELECT secret, enabled_at, last_used_step FROM user_totp WHERE user_id = $1`, userID).
+		Scan(&e.Secret, &e.EnabledAt, &e.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return TOTPEnrollment{}, ErrTwoFactorNotEnrolled
+		}
+		return TOTPEnrollment{}, fmt.Errorf("auth: get totp: %w", err)
+	}
+	return e, nil
+}
+
+// SaveTOTPSecret stores a pending secret unless TOTP is already enabled.
+func (r *PGRepository) SaveTOTPSecret(ctx context.Context, userID, secret string) error {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = get_tx_timestamp()
+		WHERE user_totp.enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("auth: save totp secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTOTP activates the pending secret and replaces the recovery codes in
+// one transaction.
+func (r *PGRepository) EnableTOTP(ctx context.Context, userID string, step int64, recoveryHashes []string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("auth: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_totp
+		SET enabled_at = get_tx_timestamp(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("auth: enable totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorEnabled
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("auth: clear recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])
+	`, userID, recoveryHashes); err != nil {
+		return fmt.Errorf("auth: store recovery codes: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("auth: commit totp: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep advances last_used_step so each code is accepted once.
+func (r *PGRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_totp SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("auth: use totp step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used.
+func (r *PGRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_recovery_codes SET used_at = get_tx_timestamp()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("auth: use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeTwoFactorRepository struct {
+	totp     map[string]TOTPEnrollment
+	recovery map[string]map[string]bool
+}
+
+func newFakeTwoFactorRepository() *fakeTwoFactorRepository {
+	return &fakeTwoFactorRepository{totp: make(map[string]TOTPEnrollment), recovery: make(map[string]map[string]bool)}
+}
+
+func (f *fakeTwoFactorRepository) GetTOTP(_ context.Context, userID string) (TOTPEnrollment, error) {
+	e, ok := f.totp[userID]
+	if !ok {
+		return TOTPEnrollment{}, ErrTwoFactorNotEnrolled
+	}
+	return e, nil
+}
+
+func (f *fakeTwoFactorRepository) SaveTOTPSecret(_ context.Context, userID, secret string) error {
+	if e, ok := f.totp[userID]; ok && e.EnabledAt != nil {
+		return ErrTwoFactorEnabled
+	}
+	f.totp[userID] = TOTPEnrollment{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) EnableTOTP(_ context.Context, userID string, step int64, hashes []string) error {
+	e := f.totp[userID]
+	now := time.Now()
+	e.EnabledAt, e.LastUsedStep = &now, step
+	f.totp[userID] = e
+	f.recovery[userID] = make(map[string]bool)
+	for _, h := range hashes {
+		f.recovery[userID][h] = false
+	}
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) UseTOTPStep(_ context.Context, userID string, step int64) (bool, error) {
+	e := f.totp[userID]
+	if step <= e.LastUsedStep {
+		return false, nil
+	}
+	e.LastUsedStep = step
+	f.totp[userID] = e
+	return true, nil
+}
+
+func (f *fakeTwoFactorRepository) UseRecoveryCode(_ context.Context, userID, hash string) (bool, error) {
+	used, ok := f.recovery[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	f.recovery[userID][hash] = true
+	return true, nil
+}
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1 seed, truncated to six digits.
+	secret := []byte("12345678901234567890")
+	cases := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range cases {
+		if got := totpCode(secret, unix/totpPeriod); got != want {
+			t.Errorf("t=%d: got %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestMatchTOTP_AllowsOneStepOfDrift(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+	key := []byte("12345678901234567890")
+	step := now.Unix() / totpPeriod
+
+	for _, s := range []int64{step - 1, step, step + 1} {
+		if got, ok := matchTOTP(secret, totpCode(key, s), now); !ok || got != s {
+			t.Fatalf("step %d: got (%d, %v)", s, got, ok)
+		}
+	}
+	if _, ok := matchTOTP(secret, totpCode(key, step+2), now); ok {
+		t.Fatal("code two steps ahead must be rejected")
+	}
+}
+
+func newTwoFactorFixture(t *testing.T, role Role) (*Service, *clock.Fake, User) {
+	t.Helper()
+	repo := newFakeRepository()
+	hash, err := bcrypt.GenerateFromPassword([]byte("supersafe"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user, err := repo.CreateUser(context.Background(), CreateUserParams{Email: "admin@example.com", FullName: "Admin", PasswordHash: string(hash), Role: role})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	clk := clock.NewFake(time.Now())
+	svc := NewService(repo, "test-secret").WithClock(clk).WithTwoFactor(newFakeTwoFactorRepository())
+	return svc, clk, user
+}
+
+func currentCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	return totpCode(key, now.Unix()/totpPeriod)
+}
+
+func TestTwoFactor_EnrollConfirmAndLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, clk, user := newTwoFactorFixture(t, RoleBrokerAdmin)
+
+	setup, err := svc.EnrollTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if setup.Secret == "" || setup.ProvisioningURI == "" {
+		t.Fatalf("unexpected setup: %+v", setup)
+	}
+
+	// Pending enrollment does not change login.
+	res, err := svc.Login(ctx, LoginRequest{Email: user.Email, Password: "supersafe"})
+	if err != nil || res.Token == "" || res.ChallengeToken != "" {
+		t.Fatalf("login before confirm: %+v %v", res, err)
+	}
+
+	if _, err := svc.ConfirmTOTP(ctx, user.ID, "12345"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("confirm with malformed code: got %v", err)
+	}
+	codes, err := svc.ConfirmTOTP(ctx, user.ID, currentCode(t, setup.Secret, clk.Now()))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	if _, err := svc.EnrollTOTP(ctx, user.ID); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Fatalf("re-enroll: expected ErrTwoFactorEnabled, got %v", err)
+	}
+
+	res, err = svc.Login(ctx, LoginRequest{Email: user.Email, Password: "supersafe"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if res.Token != "" || res.ChallengeToken == "" {
+		t.Fatalf("expected challenge only, got %+v", res)
+	}
+	if _, err := svc.VerifyToken(context.Background(), res.ChallengeToken); err == nil {
+		t.Fatal("challenge token must not authenticate API calls")
+	}
+
+	// The code used to confirm cannot be replayed within its window.
+	if _, err := svc.VerifyTwoFactor(ctx, res.ChallengeToken, currentCode(t, setup.Secret, clk.Now()), "", ""); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed code: expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+	clk.Advance(totpPeriod * time.Second)
+	done, err := svc.VerifyTwoFactor(ctx, res.ChallengeToken, currentCode(t, setup.Secret, clk.Now()), "", "")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := svc.VerifyToken(context.Background(), done.Token); err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+
+	// Recovery codes work once, in any case and with or without the dash.
+	if _, err := svc.VerifyTwoFactor(ctx, res.ChallengeToken, " "+codes[0]+" ", "", ""); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if _, err := svc.VerifyTwoFactor(ctx, res.ChallengeToken, codes[0], "", ""); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("reused recovery code: expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+}
+
+func TestTwoFactor_OnlyBrokerAdminsEnroll(t *testing.T) {
+	svc, _, user := newTwoFactorFixture(t, RoleAgent)
+	if _, err := svc.EnrollTOTP(context.Background(), user.ID); !errors.Is(err, ErrTwoFactorNotAllowed) {
+		t.Fatalf("expected ErrTwoFactorNotAllowed, got %v", err)
+	}
+}
+
+func TestTwoFactor_RejectsForeignChallenge(t *testing.T) {
+	svc, _, _ := newTwoFactorFixture(t, RoleBrokerAdmin)
+	session, err := svc.generateToken(User{ID: "user-1", Role: RoleBrokerAdmin}, "")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	for _, token := range []string{"", "garbage", session} {
+		if _, err := svc.VerifyTwoFactor(context.Background(), token, "123456", "", ""); !errors.Is(err, ErrInvalidChallenge) {
+			t.Fatalf("%q: expected ErrInvalidChallenge, got %v", token, err)
+		}
+	}
+}
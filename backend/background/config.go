@@ -0,0 +1,35 @@
+// Package background runs the work that happens outside requests: outbox
+// delivery and the scheduled sweeps (protect and invitation expiry,
+// dispute escalation, status history projection, webhook delivery,
+// referral archiving and PII purging). cmd/api runs it in process by
+// default; cmd/worker runs it on its own so the API scales separately.
+// Both read its settings with config.FromEnv.
+package background
+
+import (
+	"fmt"
+	"os"
+
+	"brokerflow/config"
+	"brokerflow/invitation"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WorkerID names this process in outbox_worker_heartbeats.
+func WorkerID(fallback string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = fallback
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// InvitationService builds the invitation service, signing tokens with
+// cfg.Secret and keeping invitations open for cfg.TTL. prepare may be nil
+// in a process that only emails invitations and never accepts one.
+func InvitationService(pool *pgxpool.Pool, cfg config.Invitations, users invitation.UserCreator, prepare invitation.UserPreparer) *invitation.Service {
+	tokens := invitation.NewTokens([]byte(cfg.Secret))
+	return invitation.NewService(invitation.NewRepository(pool, users), prepare, tokens).
+		WithTTL(cfg.TTL)
+}
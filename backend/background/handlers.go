@@ -0,0 +1,46 @@
+package background
+
+import (
+	"fmt"
+
+	"brokerflow/config"
+	"brokerflow/email"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+	"brokerflow/search"
+	"brokerflow/webhook"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OutboxHandler assembles what the outbox worker delivers to: extra first,
+// then partner webhooks, the bus of cfg.Bus, the search index when index
+// is non-nil, and lifecycle emails when cfg.Email names a sender.
+// invitations resolves the invitation emails link to.
+func OutboxHandler(pool *pgxpool.Pool, cfg config.Background, index *search.Client, invitations email.InvitationSource, extra ...outbox.Handler) (outbox.Handler, error) {
+	handlers := append(append([]outbox.Handler(nil), extra...), webhook.NewDispatcher(pool))
+	bus, err := cfg.Bus.Handler()
+	if err != nil {
+		return nil, fmt.Errorf("configure outbox bus: %w", err)
+	}
+	if bus != nil {
+		handlers = append(handlers, bus)
+	}
+	if index != nil {
+		handlers = append(handlers, search.NewIndexer(index, search.NewPGSource(pool)))
+	}
+	sender, err := cfg.Email.Sender()
+	if err != nil {
+		return nil, fmt.Errorf("configure email sender: %w", err)
+	}
+	if sender != nil {
+		templates, err := email.ParseTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("parse email templates: %w", err)
+		}
+		handlers = append(handlers, email.NewNotifier(email.NewRepository(pool), sender, templates, cfg.AppBaseURL).
+			WithWatchers(referral.NewSavedFilterRepository(pool)).
+			WithInvitations(invitations))
+	}
+	return outbox.Handlers(handlers...), nil
+}
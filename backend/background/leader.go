@@ -0,0 +1,111 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultLeaderCheck is how often a candidate retries the lock, and how
+// often the leader checks that the connection holding it is alive.
+const DefaultLeaderCheck = 10 * time.Second
+
+// Leader elects one instance among those campaigning under the same name.
+// The winner holds a session advisory lock on a connection of its own for
+// as long as it leads; if the process or its connection dies, PostgreSQL
+// releases the lock and another instance takes over on its next try.
+type Leader struct {
+	pool  *pgxpool.Pool
+	name  string
+	check time.Duration
+	clock clock.Clock
+}
+
+func NewLeader(pool *pgxpool.Pool, name string) *Leader {
+	return &Leader{pool: pool, name: name, check: DefaultLeaderCheck, clock: clock.New()}
+}
+
+// WithCheck sets how often the lock is retried and the leader's connection
+// checked.
+func (l *Leader) WithCheck(d time.Duration) *Leader {
+	if d > 0 {
+		l.check = d
+	}
+	return l
+}
+
+func (l *Leader) WithClock(c clock.Clock) *Leader {
+	l.clock = clock.OrReal(c)
+	return l
+}
+
+// Run campaigns until ctx is done. Each time this instance wins, lead runs
+// with a context that is cancelled when leadership is lost; Run waits for
+// lead to return before giving up the lock.
+func (l *Leader) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	for {
+		if err := l.campaign(ctx, lead); err != nil && ctx.Err() == nil {
+			log.Printf("leader %s: %v", l.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(l.check):
+		}
+	}
+}
+
+// campaign tries the lock once and, if it wins, leads until ctx is done,
+// lead returns or the connection holding the lock fails.
+func (l *Leader) campaign(ctx context.Context, lead func(ctx context.Context)) error {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	var won bool
+	if err := pooled.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, l.name).Scan(&won); err != nil {
+		pooled.Release()
+		return fmt.Errorf("try lock: %w", err)
+	}
+	if !won {
+		pooled.Release()
+		return nil
+	}
+	// The lock belongs to the session, so the connection must not go back to
+	// the pool; closing it releases the lock.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+	log.Printf("leader %s: elected", l.name)
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	ticker := l.clock.NewTicker(l.check)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			cancel()
+			<-done
+			return nil
+		case <-ticker.C():
+			if err := conn.Ping(ctx); err != nil && ctx.Err() == nil {
+				cancel()
+				<-done
+				return fmt.Errorf("lost leadership: %w", err)
+			}
+		}
+	}
+}
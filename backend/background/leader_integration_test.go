@@ -0,0 +1,78 @@
+package background
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestLeader_Integration campaigns two candidates against a real PostgreSQL
+// via DATABASE_URL: only one leads at a time, and the other takes over once
+// the first stops.
+func TestLeader_Integration(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is empty; set it to a live PostgreSQL to run integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect pool: %v", err)
+	}
+	defer pool.Close()
+
+	name := "leader-test-" + time.Now().Format(time.RFC3339Nano)
+	var leading, maxLeading atomic.Int32
+	elected := make(chan int, 2)
+	campaign := func(ctx context.Context, id int) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = NewLeader(pool, name).WithCheck(50*time.Millisecond).Run(ctx, func(ctx context.Context) {
+				n := leading.Add(1)
+				defer leading.Add(-1)
+				for {
+					m := maxLeading.Load()
+					if n <= m || maxLeading.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				elected <- id
+				<-ctx.Done()
+			})
+		}()
+		return done
+	}
+
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	firstDone := campaign(firstCtx, 1)
+	if got := <-elected; got != 1 {
+		t.Fatalf("expected the sole candidate elected, got %d", got)
+	}
+	secondCtx, stopSecond := context.WithCancel(ctx)
+	defer stopSecond()
+	campaign(secondCtx, 2)
+
+	time.Sleep(300 * time.Millisecond)
+	stopFirst()
+	<-firstDone
+
+	select {
+	case got := <-elected:
+		if got != 2 {
+			t.Fatalf("expected the second candidate to take over, got %d", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("the second candidate never took over")
+	}
+	if got := maxLeading.Load(); got != 1 {
+		t.Fatalf("expected one leader at a time, saw %d", got)
+	}
+}
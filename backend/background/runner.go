@@ -0,0 +1,158 @@
+package background
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/clock"
+	"brokerflow/config"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/outbox"
+	"brokerflow/privacy"
+	"brokerflow/referral"
+	"brokerflow/webhook"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Runner starts the outbox worker and the scheduled jobs a config.Background
+// enables.
+type Runner struct {
+	pool     *pgxpool.Pool
+	cfg      config.Background
+	clock    clock.Clock
+	observer agreement.TransitionObserver
+	handler  outbox.Handler
+	workerID string
+	leader   *Leader
+}
+
+func NewRunner(pool *pgxpool.Pool, cfg config.Background) *Runner {
+	return &Runner{pool: pool, cfg: cfg, clock: clock.New(), workerID: WorkerID("worker")}
+}
+
+func (r *Runner) WithClock(c clock.Clock) *Runner {
+	r.clock = clock.OrReal(c)
+	return r
+}
+
+// WithObserver reports the transitions of protect expiry to o.
+func (r *Runner) WithObserver(o agreement.TransitionObserver) *Runner {
+	r.observer = o
+	return r
+}
+
+// WithOutboxHandler sets what the outbox worker hands messages to. Without
+// one the outbox is not drained.
+func (r *Runner) WithOutboxHandler(h outbox.Handler) *Runner {
+	r.handler = h
+	return r
+}
+
+// WithWorkerID names the outbox worker in outbox_worker_heartbeats.
+func (r *Runner) WithWorkerID(id string) *Runner {
+	r.workerID = id
+	return r
+}
+
+// WithLeader runs the scheduled jobs only while l is elected, so a fleet of
+// instances runs each sweep once rather than racing through it.
+func (r *Runner) WithLeader(l *Leader) *Runner {
+	r.leader = l
+	return r
+}
+
+// Start launches the subsystems in goroutines that stop with ctx. The outbox
+// worker runs on every instance, claiming messages alongside its peers.
+func (r *Runner) Start(ctx context.Context) {
+	if r.cfg.Outbox && r.handler != nil {
+		worker := outbox.NewWorker(r.pool, r.handler, outbox.WorkerConfig{ID: r.workerID}).
+			WithClock(r.clock)
+		go run(ctx, "outbox worker", worker.Run)
+	}
+
+	jobs := r.jobs()
+	if len(jobs) == 0 {
+		return
+	}
+	if r.leader == nil {
+		go runJobs(ctx, jobs)
+		return
+	}
+	go run(ctx, "leader election", func(ctx context.Context) error {
+		return r.leader.Run(ctx, func(ctx context.Context) { runJobs(ctx, jobs) })
+	})
+}
+
+// job is a scheduled sweep run every interval.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context, interval time.Duration) error
+}
+
+func (r *Runner) jobs() []job {
+	// Jobs that write agreements or timelines go through the write-once
+	// guard, as the API's services do.
+	writer := db.GuardWORM(r.pool)
+	var jobs []job
+	if r.cfg.ProtectExpiry > 0 {
+		expiry := agreement.NewExpiryService(writer).
+			WithObserver(r.observer).
+			WithClock(r.clock)
+		jobs = append(jobs, job{"protect expiry job", r.cfg.ProtectExpiry, expiry.Run})
+	}
+	if r.cfg.DisputeEscalation > 0 {
+		escalation := dispute.NewEscalationService(writer).
+			WithSLA(r.cfg.DisputeSLA).
+			WithClock(r.clock)
+		jobs = append(jobs, job{"dispute escalation job", r.cfg.DisputeEscalation, escalation.Run})
+	}
+	if r.cfg.StatusHistory > 0 {
+		projector := agreement.NewHistoryProjector(r.pool).WithClock(r.clock)
+		jobs = append(jobs, job{"status history projection", r.cfg.StatusHistory, projector.Run})
+	}
+	if r.cfg.WebhookDelivery > 0 {
+		deliverer := webhook.NewDeliverer(r.pool).WithClock(r.clock)
+		jobs = append(jobs, job{"webhook delivery", r.cfg.WebhookDelivery, deliverer.Run})
+	}
+	if r.cfg.MatchExpiry > 0 {
+		matchExpiry := referral.NewMatchExpiryService(r.pool).WithClock(r.clock)
+		jobs = append(jobs, job{"match expiry job", r.cfg.MatchExpiry, matchExpiry.Run})
+	}
+	if r.cfg.ReferralArchive > 0 {
+		archive := referral.NewArchiveService(r.pool).
+			WithRetention(r.cfg.ReferralArchiveAfter).
+			WithClock(r.clock)
+		jobs = append(jobs, job{"referral archive job", r.cfg.ReferralArchive, archive.Run})
+	}
+	if r.cfg.PIIPurge > 0 {
+		purge := privacy.NewPurgeService(r.pool).WithClock(r.clock)
+		jobs = append(jobs, job{"pii purge job", r.cfg.PIIPurge, purge.Run})
+	}
+	return jobs
+}
+
+// runJobs runs every job until ctx is done and waits for them to stop.
+func runJobs(ctx context.Context, jobs []job) {
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx, j.name, func(ctx context.Context) error { return j.run(ctx, j.interval) })
+		}()
+	}
+	wg.Wait()
+}
+
+// run logs fn's exit unless ctx ended it.
+func run(ctx context.Context, name string, fn func(context.Context) error) {
+	if err := fn(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("%s exited: %v", name, err)
+	}
+}
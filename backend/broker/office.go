@@ -0,0 +1,283 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MaxOfficeNameLength mirrors the CHECK on broker_offices.name.
+const MaxOfficeNameLength = 200
+
+var (
+	ErrOfficeNotFound    = errors.New("broker: office not found")
+	ErrInvalidOfficeName = fmt.Errorf("broker: office name must be 1-%d characters", MaxOfficeNameLength)
+	ErrOfficeNameTaken   = errors.New("broker: the broker already has an office with that name")
+	ErrUserNotInBroker   = errors.New("broker: user does not belong to the broker")
+)
+
+// Office is a branch of a brokerage. With ScopeAdmins set, broker admins
+// assigned to the office see only the referrals of its agents.
+type Office struct {
+	ID          string
+	BrokerID    string
+	Name        string
+	ScopeAdmins bool
+	// Agents counts the users assigned to the office.
+	Agents    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OfficeParams creates an office of BrokerID.
+type OfficeParams struct {
+	BrokerID    string
+	Name        string
+	ScopeAdmins bool
+}
+
+// OfficeUpdate changes the fields that are set.
+type OfficeUpdate struct {
+	Name        *string
+	ScopeAdmins *bool
+}
+
+func normalizeOfficeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > MaxOfficeNameLength {
+		return "", ErrInvalidOfficeName
+	}
+	return name, nil
+}
+
+// OfficeStore persists offices and office assignments.
+type OfficeStore interface {
+	CreateOffice(ctx context.Context, params OfficeParams) (Office, error)
+	// ListOffices returns brokerID's offices ordered by name.
+	ListOffices(ctx context.Context, brokerID string) ([]Office, error)
+	GetOffice(ctx context.Context, brokerID, id string) (Office, error)
+	UpdateOffice(ctx context.Context, brokerID, id string, update OfficeUpdate) (Office, error)
+	// DeleteOffice removes the office and returns the users it was assigned.
+	DeleteOffice(ctx context.Context, brokerID, id string) ([]string, error)
+	// AssignOffice moves userID, a user of brokerID, to officeID, or out of
+	// any office when officeID is empty.
+	AssignOffice(ctx context.Context, brokerID, userID, officeID string) error
+}
+
+// OfficeService manages the offices of a brokerage and which of its users
+// work from each.
+type OfficeService struct {
+	store OfficeStore
+	users auth.UserInvalidator
+}
+
+func NewOfficeService(store OfficeStore) *OfficeService {
+	return &OfficeService{store: store}
+}
+
+// WithUserInvalidator is told about each user whose office changed, since
+// users carry their office.
+func (s *OfficeService) WithUserInvalidator(users auth.UserInvalidator) *OfficeService {
+	s.users = users
+	return s
+}
+
+func (s *OfficeService) Create(ctx context.Context, params OfficeParams) (Office, error) {
+	name, err := normalizeOfficeName(params.Name)
+	if err != nil {
+		return Office{}, err
+	}
+	params.Name = name
+	return s.store.CreateOffice(ctx, params)
+}
+
+func (s *OfficeService) List(ctx context.Context, brokerID string) ([]Office, error) {
+	return s.store.ListOffices(ctx, brokerID)
+}
+
+func (s *OfficeService) Get(ctx context.Context, brokerID, id string) (Office, error) {
+	return s.store.GetOffice(ctx, brokerID, id)
+}
+
+func (s *OfficeService) Update(ctx context.Context, brokerID, id string, update OfficeUpdate) (Office, error) {
+	if update.Name != nil {
+		name, err := normalizeOfficeName(*update.Name)
+		if err != nil {
+			return Office{}, err
+		}
+		update.Name = &name
+	}
+	return s.store.UpdateOffice(ctx, brokerID, id, update)
+}
+
+// Delete removes the office; its users stay with the broker without an
+// office.
+func (s *OfficeService) Delete(ctx context.Context, brokerID, id string) error {
+	members, err := s.store.DeleteOffice(ctx, brokerID, id)
+	if err != nil {
+		return err
+	}
+	for _, userID := range members {
+		s.invalidate(ctx, userID)
+	}
+	return nil
+}
+
+// Assign moves userID to officeID; an empty officeID unassigns the user.
+func (s *OfficeService) Assign(ctx context.Context, brokerID, userID, officeID string) error {
+	if err := s.store.AssignOffice(ctx, brokerID, userID, officeID); err != nil {
+		return err
+	}
+	s.invalidate(ctx, userID)
+	return nil
+}
+
+func (s *OfficeService) invalidate(ctx context.Context, userID string) {
+	if s.users != nil {
+		s.users.InvalidateUser(ctx, userID)
+	}
+}
+
+const officeColumns = `o.id::text, o.broker_id::text, o.name, o.scope_admins, o.created_at, o.updated_at,
+		(SELECT COUNT(*) FROM users u WHERE u.office_id = o.id AND u.deleted_at IS NULL)`
+
+func scanOffice(row pgx.Row) (Office, error) {
+	var o Office
+	err := row.Scan(&o.ID, &o.BrokerID, &o.Name, &o.ScopeAdmins, &o.CreatedAt, &o.UpdatedAt, &o.Agents)
+	return o, err
+}
+
+// officeError maps missing rows, malformed ids and constraint violations
+// of broker_offices queries to the package errors.
+func officeError(op string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return ErrOfficeNameTaken
+		case "23503":
+			return ErrNotFound
+		case "22P02":
+			return ErrOfficeNotFound
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrOfficeNotFound
+	}
+	return fmt.Errorf("broker: %s office: %w", op, err)
+}
+
+func (r *Repository) CreateOffice(ctx context.Context, params OfficeParams) (Office, error) {
+	o, err := scanOffice(r.pool.QueryRow(ctx, `
+		INSERT INTO broker_offices AS o (broker_id, name, scope_admins)
+		VALUES ($1, $2, $3)
+		RETURNING `+officeColumns, params.BrokerID, params.Name, params.ScopeAdmins))
+	if err != nil {
+		return Office{}, officeError("create", err)
+	}
+	return o, nil
+}
+
+func (r *Repository) ListOffices(ctx context.Context, brokerID string) ([]Office, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+officeColumns+`
+		FROM broker_offices o
+		WHERE o.broker_id = $1
+		ORDER BY lower(o.name)
+	`, brokerID)
+	if err != nil {
+		return nil, fmt.Errorf("broker: list offices: %w", err)
+	}
+	offices, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Office, error) { return scanOffice(row) })
+	if err != nil {
+		return nil, fmt.Errorf("broker: scan offices: %w", err)
+	}
+	return offices, nil
+}
+
+func (r *Repository) GetOffice(ctx context.Context, brokerID, id string) (Office, error) {
+	o, err := scanOffice(r.pool.QueryRow(ctx, `
+		SELECT `+officeColumns+`
+		FROM broker_offices o
+		WHERE o.id = $2 AND o.broker_id = $1
+	`, brokerID, id))
+	if err != nil {
+		return Office{}, officeError("get", err)
+	}
+	return o, nil
+}
+
+func (r *Repository) UpdateOffice(ctx context.Context, brokerID, id string, update OfficeUpdate) (Office, error) {
+	o, err := scanOffice(r.pool.QueryRow(ctx, `
+		UPDATE broker_offices o
+		SET name = COALESCE($3, o.name),
+		    scope_admins = COALESCE($4, o.scope_admins)
+		WHERE o.id = $2 AND o.broker_id = $1
+		RETURNING `+officeColumns, brokerID, id, update.Name, update.ScopeAdmins))
+	if err != nil {
+		return Office{}, officeError("update", err)
+	}
+	return o, nil
+}
+
+func (r *Repository) DeleteOffice(ctx context.Context, brokerID, id string) ([]string, error) {
+	var (
+		deleted int
+		members []string
+	)
+	err := r.pool.QueryRow(ctx, `
+		WITH members AS (
+			SELECT u.id FROM users u
+			JOIN broker_offices o ON o.id = u.office_id
+			WHERE o.id = $2 AND o.broker_id = $1
+		), gone AS (
+			DELETE FROM broker_offices WHERE id = $2 AND broker_id = $1 RETURNING id
+		)
+		SELECT (SELECT COUNT(*) FROM gone), ARRAY(SELECT id::text FROM members)
+	`, brokerID, id).Scan(&deleted, &members)
+	if err != nil {
+		return nil, officeError("delete", err)
+	}
+	if deleted == 0 {
+		return nil, ErrOfficeNotFound
+	}
+	return members, nil
+}
+
+func (r *Repository) AssignOffice(ctx context.Context, brokerID, userID, officeID string) error {
+	if officeID != "" {
+		if _, err := r.GetOffice(ctx, brokerID, officeID); err != nil {
+			return err
+		}
+	}
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE users SET office_id = NULLIF($3, '')::uuid
+		WHERE id = $2 AND broker_id = $1 AND deleted_at IS NULL
+	`, brokerID, userID, officeID)
+	if err != nil {
+		// The office may have been deleted since it was checked, or the user
+		// moved to another broker; the users_office_same_broker trigger and
+		// the foreign key catch both.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "22P02":
+				return ErrUserNotInBroker
+			case "23503", "23514":
+				return ErrOfficeNotFound
+			}
+		}
+		return fmt.Errorf("broker: assign office: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotInBroker
+	}
+	return nil
+}
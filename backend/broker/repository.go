@@ -5,8 +5,9 @@ import (
 	"errors"
 	"fmt"
 
+	"brokerflow/db"
+
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ErrNotFound signals the requested broker does not exist.
@@ -14,11 +15,11 @@ var ErrNotFound = errors.New("broker: not found")
 
 // Repository provides read access to broker profiles.
 type Repository struct {
-	pool *pgxpool.Pool
+	pool db.Writer
 }
 
-// NewRepository wires a pgxpool-backed repository implementation.
-func NewRepository(pool *pgxpool.Pool) *Repository {
+// NewRepository wires a repository over pool, typically a *pgxpool.Pool.
+func NewRepository(pool db.Writer) *Repository {
 	return &Repository{pool: pool}
 }
 
@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/db/dbtest"
+)
+
+var profileColumns = []string{"id", "name", "fein", "verified", "created_at"}
+
+func TestRepositoryGetByID_ScansTheProfile(t *testing.T) {
+	mock := dbtest.New(t)
+	created := time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("FROM brokers WHERE id = $1").WithArgs("b1").
+		WillReturnRows(dbtest.NewRows(profileColumns...).AddRow("b1", "Metro Realty", "12-3456789", true, created))
+
+	got, err := NewRepository(mock).GetByID(context.Background(), "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Profile{ID: "b1", Name: "Metro Realty", Fein: "12-3456789", Verified: true, CreatedAt: created}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRepositoryGetByID_MapsErrors(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectQuery("FROM brokers").WithArgs("missing")
+	broken := errors.New("connection reset")
+	mock.ExpectQuery("FROM brokers").WithArgs("b1").WillReturnError(broken)
+
+	repo := NewRepository(mock)
+	if _, err := repo.GetByID(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.GetByID(context.Background(), "b1"); !errors.Is(err, broken) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the query error wrapped, got %v", err)
+	}
+}
+
+func TestRepositoryList_ClampsTheLimit(t *testing.T) {
+	mock := dbtest.New(t)
+	created := time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("ORDER BY name ASC LIMIT $1").WithArgs(100).
+		WillReturnRows(dbtest.NewRows(profileColumns...).
+			AddRow("b2", "Lakeside Homes", "", false, created).
+			AddRow("b1", "Metro Realty", "12-3456789", true, created))
+	mock.ExpectQuery("LIMIT $1").WithArgs(5)
+
+	repo := NewRepository(mock)
+	profiles, err := repo.List(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0].Name != "Lakeside Homes" || !profiles[1].Verified {
+		t.Fatalf("unexpected profiles %+v", profiles)
+	}
+	if profiles, err := repo.List(context.Background(), 5); err != nil || len(profiles) != 0 {
+		t.Fatalf("expected no profiles, got %+v, %v", profiles, err)
+	}
+}
+
+func TestRepositoryList_ReportsIterationErrors(t *testing.T) {
+	mock := dbtest.New(t)
+	broken := errors.New("connection lost")
+	mock.ExpectQuery("FROM brokers").WillReturnRows(dbtest.NewRows(profileColumns...).RowError(broken))
+
+	if _, err := NewRepository(mock).List(context.Background(), 10); !errors.Is(err, broken) {
+		t.Fatalf("expected the iteration error, got %v", err)
+	}
+}
@@ -1,6 +1,13 @@
 package broker
 
-import "context"
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"brokerflow/cache"
+)
 
 // ProfileReader abstracts repository operations for the service.
 type ProfileReader interface {
@@ -8,9 +15,18 @@ type ProfileReader interface {
 	List(ctx context.Context, limit int) ([]Profile, error)
 }
 
+// SettingsStore abstracts persistence of broker referral policies.
+type SettingsStore interface {
+	GetSettings(ctx context.Context, brokerID string) (Settings, error)
+	SaveSettings(ctx context.Context, s Settings, actorID string) (Settings, error)
+}
+
 // Service exposes business-level broker operations.
 type Service struct {
-	repo ProfileReader
+	repo     ProfileReader
+	settings SettingsStore
+	cache    cache.Cache
+	cacheTTL time.Duration
 }
 
 // NewService builds a Service using the provided repository.
@@ -18,12 +34,81 @@ func NewService(repo ProfileReader) *Service {
 	return &Service{repo: repo}
 }
 
+// WithSettings enables the referral policy operations.
+func (s *Service) WithSettings(store SettingsStore) *Service {
+	s.settings = store
+	return s
+}
+
+// WithCache serves GetByID from c, caching profiles for ttl. Whatever
+// changes a brokers row must call InvalidateProfile.
+func (s *Service) WithCache(c cache.Cache, ttl time.Duration) *Service {
+	s.cache = c
+	s.cacheTTL = ttl
+	return s
+}
+
+func profileCacheKey(id string) string {
+	return "broker:profile:" + id
+}
+
 // GetByID returns the broker profile for the given identifier.
 func (s *Service) GetByID(ctx context.Context, id string) (Profile, error) {
-	return s.repo.GetByID(ctx, id)
+	if s.cache == nil {
+		return s.repo.GetByID(ctx, id)
+	}
+	key := profileCacheKey(id)
+	profile, ok, err := cache.GetJSON[Profile](ctx, s.cache, key)
+	if err != nil {
+		log.Printf("broker: read profile cache: %v", err)
+	}
+	if ok {
+		return profile, nil
+	}
+	profile, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Profile{}, err
+	}
+	if err := cache.SetJSON(ctx, s.cache, key, profile, s.cacheTTL); err != nil {
+		log.Printf("broker: write profile cache: %v", err)
+	}
+	return profile, nil
+}
+
+// InvalidateProfile drops the cached profile of broker id.
+func (s *Service) InvalidateProfile(ctx context.Context, id string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, profileCacheKey(id)); err != nil {
+		log.Printf("broker: invalidate profile cache: %v", err)
+	}
 }
 
 // List returns up to limit broker profiles.
 func (s *Service) List(ctx context.Context, limit int) ([]Profile, error) {
 	return s.repo.List(ctx, limit)
 }
+
+// GetSettings returns the broker's referral policy, or the defaults when it
+// has not configured one.
+func (s *Service) GetSettings(ctx context.Context, brokerID string) (Settings, error) {
+	if s.settings == nil {
+		if _, err := s.GetByID(ctx, brokerID); err != nil {
+			return Settings{}, err
+		}
+		return DefaultSettings(brokerID), nil
+	}
+	return s.settings.GetSettings(ctx, brokerID)
+}
+
+// UpdateSettings validates and stores the broker's referral policy.
+func (s *Service) UpdateSettings(ctx context.Context, settings Settings, actorID string) (Settings, error) {
+	if err := settings.Validate(); err != nil {
+		return Settings{}, err
+	}
+	if s.settings == nil {
+		return Settings{}, errors.New("broker: settings store not configured")
+	}
+	return s.settings.SaveSettings(ctx, settings, actorID)
+}
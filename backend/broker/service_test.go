@@ -0,0 +1,70 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/cache"
+)
+
+type countingProfiles struct {
+	profiles map[string]Profile
+	gets     int
+}
+
+func (c *countingProfiles) GetByID(_ context.Context, id string) (Profile, error) {
+	c.gets++
+	p, ok := c.profiles[id]
+	if !ok {
+		return Profile{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (c *countingProfiles) List(context.Context, int) ([]Profile, error) {
+	return nil, nil
+}
+
+func TestService_GetByIDCachesProfiles(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingProfiles{profiles: map[string]Profile{
+		"b1": {ID: "b1", Name: "Acme Realty", Verified: true, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}}
+	svc := NewService(repo).WithCache(cache.NewMemory(), time.Minute)
+
+	for range 3 {
+		got, err := svc.GetByID(ctx, "b1")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if got != repo.profiles["b1"] {
+			t.Fatalf("unexpected profile %+v", got)
+		}
+	}
+	if repo.gets != 1 {
+		t.Fatalf("expected one repository read, got %d", repo.gets)
+	}
+
+	repo.profiles["b1"] = Profile{ID: "b1", Name: "Acme Realty Group"}
+	svc.InvalidateProfile(ctx, "b1")
+	if got, _ := svc.GetByID(ctx, "b1"); got.Name != "Acme Realty Group" || repo.gets != 2 {
+		t.Fatalf("expected a fresh read after invalidation, got %+v after %d reads", got, repo.gets)
+	}
+}
+
+func TestService_GetByIDDoesNotCacheMisses(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingProfiles{profiles: map[string]Profile{}}
+	svc := NewService(repo).WithCache(cache.NewMemory(), time.Minute)
+
+	for range 2 {
+		if _, err := svc.GetByID(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if repo.gets != 2 {
+		t.Fatalf("expected every miss to reach the repository, got %d reads", repo.gets)
+	}
+}
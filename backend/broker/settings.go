@@ -0,0 +1,201 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Application-wide referral terms used by brokers without a broker_settings row.
+const (
+	DefaultFeeRate        = 30.0
+	DefaultMinFeeRate     = 1.0
+	DefaultMaxFeeRate     = 100.0
+	DefaultProtectDays    = 90
+	DefaultMinProtectDays = 1
+	DefaultMaxProtectDays = 3650
+)
+
+// ErrInvalidSettings signals bounds that do not contain the defaults.
+var ErrInvalidSettings = errors.New("broker: settings require 0 < min <= default <= max (fee rate <= 100)")
+
+// Settings is a broker's referral policy: the terms applied to agreements it
+// refers out and the range its agents may negotiate within.
+type Settings struct {
+	BrokerID           string
+	DefaultFeeRate     float64
+	MinFeeRate         float64
+	MaxFeeRate         float64
+	DefaultProtectDays int
+	MinProtectDays     int
+	MaxProtectDays     int
+	UpdatedAt          *time.Time
+}
+
+// DefaultSettings returns the application defaults for brokerID.
+func DefaultSettings(brokerID string) Settings {
+	return Settings{
+		BrokerID:           brokerID,
+		DefaultFeeRate:     DefaultFeeRate,
+		MinFeeRate:         DefaultMinFeeRate,
+		MaxFeeRate:         DefaultMaxFeeRate,
+		DefaultProtectDays: DefaultProtectDays,
+		MinProtectDays:     DefaultMinProtectDays,
+		MaxProtectDays:     DefaultMaxProtectDays,
+	}
+}
+
+// Validate mirrors the CHECK constraints on broker_settings.
+func (s Settings) Validate() error {
+	if s.MinFeeRate <= 0 || s.MinFeeRate > s.DefaultFeeRate || s.DefaultFeeRate > s.MaxFeeRate || s.MaxFeeRate > 100 {
+		return ErrInvalidSettings
+	}
+	if s.MinProtectDays <= 0 || s.MinProtectDays > s.DefaultProtectDays || s.DefaultProtectDays > s.MaxProtectDays {
+		return ErrInvalidSettings
+	}
+	return nil
+}
+
+// ErrOutsidePolicy matches every *PolicyViolation.
+var ErrOutsidePolicy = errors.New("broker: terms are outside the referral policy")
+
+// PolicyTerm names an agreement term bounded by Settings.
+type PolicyTerm string
+
+const (
+	TermFeeRate     PolicyTerm = "feeRate"
+	TermProtectDays PolicyTerm = "protectDays"
+)
+
+// PolicyBound says which end of a term's range was crossed.
+type PolicyBound string
+
+const (
+	BoundMin PolicyBound = "min"
+	BoundMax PolicyBound = "max"
+)
+
+// PolicyViolation reports the first bound of a broker's policy that
+// proposed terms break: Value crossed Limit on the Bound side of Term.
+type PolicyViolation struct {
+	BrokerID string
+	Term     PolicyTerm
+	Bound    PolicyBound
+	Limit    float64
+	Value    float64
+}
+
+func (v *PolicyViolation) Error() string {
+	side := "below the minimum"
+	if v.Bound == BoundMax {
+		side = "above the maximum"
+	}
+	return fmt.Sprintf("broker: %s %v is %s %v allowed by broker %s", v.Term, v.Value, side, v.Limit, v.BrokerID)
+}
+
+func (v *PolicyViolation) Is(target error) bool { return target == ErrOutsidePolicy }
+
+// Check returns a *PolicyViolation for the first bound feeRate or
+// protectDays falls outside, checking the fee rate first, or nil.
+func (s Settings) Check(feeRate float64, protectDays int) error {
+	violation := func(term PolicyTerm, bound PolicyBound, limit, value float64) error {
+		return &PolicyViolation{BrokerID: s.BrokerID, Term: term, Bound: bound, Limit: limit, Value: value}
+	}
+	switch {
+	case feeRate < s.MinFeeRate:
+		return violation(TermFeeRate, BoundMin, s.MinFeeRate, feeRate)
+	case feeRate > s.MaxFeeRate:
+		return violation(TermFeeRate, BoundMax, s.MaxFeeRate, feeRate)
+	case protectDays < s.MinProtectDays:
+		return violation(TermProtectDays, BoundMin, float64(s.MinProtectDays), float64(protectDays))
+	case protectDays > s.MaxProtectDays:
+		return violation(TermProtectDays, BoundMax, float64(s.MaxProtectDays), float64(protectDays))
+	}
+	return nil
+}
+
+// Allows reports whether feeRate and protectDays fall within the bounds.
+func (s Settings) Allows(feeRate float64, protectDays int) bool {
+	return s.Check(feeRate, protectDays) == nil
+}
+
+// RowQuerier is satisfied by both *pgxpool.Pool and pgx.Tx, so settings can
+// be read inside another package's transaction.
+type RowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// SettingsColumns are the broker_settings columns ScanSettings reads, for
+// callers that fold the policy lookup into their own query or batch.
+const SettingsColumns = `default_fee_rate, min_fee_rate, max_fee_rate, default_protect_days, min_protect_days, max_protect_days, updated_at`
+
+// LoadSettings reads brokerID's policy, falling back to DefaultSettings when
+// the broker has not configured one. It does not check that the broker exists.
+func LoadSettings(ctx context.Context, q RowQuerier, brokerID string) (Settings, error) {
+	row := q.QueryRow(ctx, `SELECT `+SettingsColumns+` FROM broker_settings WHERE broker_id = $1`, brokerID)
+	return ScanSettings(row, brokerID)
+}
+
+// ScanSettings reads a row of SettingsColumns as brokerID's policy. No row
+// means the broker has not configured one and gets DefaultSettings.
+func ScanSettings(row pgx.Row, brokerID string) (Settings, error) {
+	s := Settings{BrokerID: brokerID}
+	err := row.Scan(
+		&s.DefaultFeeRate,
+		&s.MinFeeRate,
+		&s.MaxFeeRate,
+		&s.DefaultProtectDays,
+		&s.MinProtectDays,
+		&s.MaxProtectDays,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DefaultSettings(brokerID), nil
+		}
+		return Settings{}, fmt.Errorf("broker: load settings: %w", err)
+	}
+	return s, nil
+}
+
+// GetSettings returns the policy of an existing broker.
+func (r *Repository) GetSettings(ctx context.Context, brokerID string) (Settings, error) {
+	if _, err := r.GetByID(ctx, brokerID); err != nil {
+		return Settings{}, err
+	}
+	return LoadSettings(ctx, r.pool, brokerID)
+}
+
+// SaveSettings inserts or replaces the broker's policy.
+func (r *Repository) SaveSettings(ctx context.Context, s Settings, actorID string) (Settings, error) {
+	const query = `
+		INSERT INTO broker_settings (broker_id, default_fee_rate, min_fee_rate, max_fee_rate,
+		                             default_protect_days, min_protect_days, max_protect_days, updated_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (broker_id) DO UPDATE SET
+			default_fee_rate = EXCLUDED.default_fee_rate,
+			min_fee_rate = EXCLUDED.min_fee_rate,
+			max_fee_rate = EXCLUDED.max_fee_rate,
+			default_protect_days = EXCLUDED.default_protect_days,
+			min_protect_days = EXCLUDED.min_protect_days,
+			max_protect_days = EXCLUDED.max_protect_days,
+			updated_by_user_id = EXCLUDED.updated_by_user_id,
+			updated_at = get_tx_timestamp()
+		RETURNING updated_at
+	`
+	var updatedAt time.Time
+	if err := r.pool.QueryRow(ctx, query, s.BrokerID, s.DefaultFeeRate, s.MinFeeRate, s.MaxFeeRate,
+		s.DefaultProtectDays, s.MinProtectDays, s.MaxProtectDays, actorID).Scan(&updatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return Settings{}, ErrNotFound
+		}
+		return Settings{}, fmt.Errorf("broker: save settings: %w", err)
+	}
+	s.UpdatedAt = &updatedAt
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaultSettingsAreValid(t *testing.T) {
+	if err := DefaultSettings("b1").Validate(); err != nil {
+		t.Fatalf("defaults should validate: %v", err)
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	base := Settings{DefaultFeeRate: 25, MinFeeRate: 10, MaxFeeRate: 40, DefaultProtectDays: 90, MinProtectDays: 30, MaxProtectDays: 180}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("expected valid settings, got %v", err)
+	}
+
+	cases := map[string]func(*Settings){
+		"default below min": func(s *Settings) { s.DefaultFeeRate = 5 },
+		"max above 100":     func(s *Settings) { s.MaxFeeRate = 120 },
+		"zero min fee":      func(s *Settings) { s.MinFeeRate = 0 },
+		"days above max":    func(s *Settings) { s.DefaultProtectDays = 200 },
+		"non-positive days": func(s *Settings) { s.MinProtectDays = 0 },
+	}
+	for name, mutate := range cases {
+		s := base
+		mutate(&s)
+		if err := s.Validate(); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("%s: expected ErrInvalidSettings, got %v", name, err)
+		}
+	}
+}
+
+func TestSettingsAllows(t *testing.T) {
+	s := Settings{MinFeeRate: 10, MaxFeeRate: 40, MinProtectDays: 30, MaxProtectDays: 180}
+	if !s.Allows(40, 30) {
+		t.Fatal("bounds should be inclusive")
+	}
+	if s.Allows(45, 90) || s.Allows(20, 365) {
+		t.Fatal("terms outside the bounds should be rejected")
+	}
+}
+
+func TestSettingsCheckReportsViolatedBound(t *testing.T) {
+	s := Settings{BrokerID: "b1", MinFeeRate: 10, MaxFeeRate: 40, MinProtectDays: 30, MaxProtectDays: 180}
+	cases := []struct {
+		fee  float64
+		days int
+		want PolicyViolation
+	}{
+		{fee: 5, days: 90, want: PolicyViolation{BrokerID: "b1", Term: TermFeeRate, Bound: BoundMin, Limit: 10, Value: 5}},
+		{fee: 45, days: 7, want: PolicyViolation{BrokerID: "b1", Term: TermFeeRate, Bound: BoundMax, Limit: 40, Value: 45}},
+		{fee: 20, days: 7, want: PolicyViolation{BrokerID: "b1", Term: TermProtectDays, Bound: BoundMin, Limit: 30, Value: 7}},
+		{fee: 20, days: 365, want: PolicyViolation{BrokerID: "b1", Term: TermProtectDays, Bound: BoundMax, Limit: 180, Value: 365}},
+	}
+	for _, tc := range cases {
+		err := s.Check(tc.fee, tc.days)
+		var v *PolicyViolation
+		if !errors.As(err, &v) || !errors.Is(err, ErrOutsidePolicy) {
+			t.Fatalf("Check(%v, %d): expected a policy violation, got %v", tc.fee, tc.days, err)
+		}
+		if *v != tc.want {
+			t.Errorf("Check(%v, %d) = %+v, want %+v", tc.fee, tc.days, *v, tc.want)
+		}
+	}
+	if err := s.Check(40, 180); err != nil {
+		t.Fatalf("bounds should be inclusive, got %v", err)
+	}
+}
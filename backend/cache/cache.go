@@ -0,0 +1,61 @@
+// Package cache provides the key-value stores behind the cache-aside lookups
+// of broker profiles and users: Redis when several API instances must share
+// one cache, memory for a single instance. Callers treat the cache as
+// optional: a failing Get or Set is logged and the lookup goes to the
+// database, so a Redis outage slows requests down but does not fail them.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cache stores opaque values under string keys.
+type Cache interface {
+	// Get returns the value under key; ok is false on a miss.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl; a zero ttl never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys; missing keys are not an error.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// KindMemory selects the in-memory cache in New.
+const KindMemory = "memory"
+
+// New returns the cache named by rawURL: "memory" for an in-process cache,
+// or a redis:// or rediss:// URL (see NewRedis).
+func New(rawURL string) (Cache, error) {
+	switch {
+	case rawURL == KindMemory:
+		return NewMemory(), nil
+	case strings.HasPrefix(rawURL, "redis://"), strings.HasPrefix(rawURL, "rediss://"):
+		return NewRedis(rawURL)
+	}
+	return nil, fmt.Errorf("cache: unsupported cache %q, want %s or a redis:// url", rawURL, KindMemory)
+}
+
+// GetJSON loads the JSON value under key into a T.
+func GetJSON[T any](ctx context.Context, c Cache, key string) (T, bool, error) {
+	var v T
+	raw, ok, err := c.Get(ctx, key)
+	if err != nil || !ok {
+		return v, false, err
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return v, false, fmt.Errorf("cache: decode %s: %w", key, err)
+	}
+	return v, true, nil
+}
+
+// SetJSON stores v under key as JSON.
+func SetJSON(ctx context.Context, c Cache, key string, v any, ttl time.Duration) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cache: encode %s: %w", key, err)
+	}
+	return c.Set(ctx, key, raw, ttl)
+}
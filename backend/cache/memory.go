@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"brokerflow/clock"
+)
+
+// Memory is a Cache held in process memory. Expired entries are dropped
+// when read; it suits a single API instance, since invalidations do not
+// reach other processes.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	clock   clock.Clock
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+var _ Cache = (*Memory)(nil)
+
+// NewMemory builds an empty in-memory cache.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry), clock: clock.New()}
+}
+
+// WithClock overrides the time source used for expiry.
+func (m *Memory) WithClock(c clock.Clock) *Memory {
+	m.clock = clock.OrReal(c)
+	return m
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !e.expiresAt.IsZero() && !m.clock.Now().Before(e.expiresAt) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return append([]byte(nil), e.value...), true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := memoryEntry{value: append([]byte(nil), value...)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ttl > 0 {
+		e.expiresAt = m.clock.Now().Add(ttl)
+	}
+	m.entries[key] = e
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
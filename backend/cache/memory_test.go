@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+)
+
+func TestMemory_ExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC))
+	m := NewMemory().WithClock(clk)
+
+	if err := m.Set(ctx, "short", []byte("a"), time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := m.Set(ctx, "forever", []byte("b"), 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if v, ok, _ := m.Get(ctx, "short"); !ok || string(v) != "a" {
+		t.Fatalf("expected a hit, got %q %v", v, ok)
+	}
+
+	clk.Advance(time.Minute)
+	if _, ok, _ := m.Get(ctx, "short"); ok {
+		t.Fatal("expected the entry to expire after its ttl")
+	}
+	if _, ok, _ := m.Get(ctx, "forever"); !ok {
+		t.Fatal("expected a zero ttl entry to stay")
+	}
+
+	if err := m.Delete(ctx, "forever", "missing"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok, _ := m.Get(ctx, "forever"); ok {
+		t.Fatal("expected the entry to be deleted")
+	}
+}
+
+func TestJSONHelpers(t *testing.T) {
+	type profile struct {
+		Name     string
+		Verified bool
+	}
+	ctx := context.Background()
+	m := NewMemory()
+
+	if _, ok, err := GetJSON[profile](ctx, m, "p"); ok || err != nil {
+		t.Fatalf("expected a clean miss, got %v %v", ok, err)
+	}
+	if err := SetJSON(ctx, m, "p", profile{Name: "Acme", Verified: true}, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got, ok, err := GetJSON[profile](ctx, m, "p")
+	if err != nil || !ok || got != (profile{Name: "Acme", Verified: true}) {
+		t.Fatalf("unexpected round trip: %+v %v %v", got, ok, err)
+	}
+
+	_ = m.Set(ctx, "bad", []byte("{"), 0)
+	if _, ok, err := GetJSON[profile](ctx, m, "bad"); ok || err == nil {
+		t.Fatalf("expected a decode error, got %v %v", ok, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if c, err := New(KindMemory); err != nil || c == nil {
+		t.Fatalf("memory: %v", err)
+	}
+	if _, err := New("redis://localhost:6379/1"); err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+	for _, bad := range []string{"", "memcached://x", "redis://localhost/abc"} {
+		if _, err := New(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
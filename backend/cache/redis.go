@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisDefaultTimeout = 2 * time.Second
+
+// redisError is an error reply from the server; the connection stays usable.
+type redisError struct {
+	msg string
+}
+
+func (e *redisError) Error() string {
+	return "cache: redis: " + e.msg
+}
+
+// Redis is a Cache backed by a Redis server, speaking RESP directly so the
+// service needs no Redis client library. It holds one connection, redialled
+// after any I/O error; commands are serialized.
+type Redis struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+var _ Cache = (*Redis)(nil)
+
+// NewRedis parses rawURL (redis://[[user]:pass@]host[:port][/db], or
+// rediss:// for TLS) without connecting.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "redis" && u.Scheme != "rediss") {
+		return nil, fmt.Errorf("cache: invalid redis url %q", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	c := &Redis{addr: addr, useTLS: u.Scheme == "rediss", timeout: redisDefaultTimeout}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if c.db, err = strconv.Atoi(path); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("cache: invalid redis database %q", path)
+		}
+	}
+	return c, nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("cache: redis GET %s: unexpected reply %v", key, reply)
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the connection, if any.
+func (c *Redis) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.close()
+	return nil
+}
+
+func (c *Redis) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	c.conn, c.r = nil, nil
+}
+
+func (c *Redis) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(c.timeout)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+func (c *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(ctx, args...)
+	if err != nil {
+		var replyErr *redisError
+		if !errors.As(err, &replyErr) {
+			// The connection is in an unknown state; start afresh next time.
+			c.close()
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *Redis) dial(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("cache: dial redis: %w", err)
+	}
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("cache: redis TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.roundTrip(ctx, auth...); err != nil {
+			c.close()
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.roundTrip(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			c.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Redis) roundTrip(ctx context.Context, args ...string) (any, error) {
+	_ = c.conn.SetDeadline(c.deadline(ctx))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("cache: redis %s: %w", args[0], err)
+	}
+	reply, err := readReply(c.r)
+	if err != nil {
+		var replyErr *redisError
+		if errors.As(err, &replyErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cache: redis %s: %w", args[0], err)
+	}
+	return reply, nil
+}
+
+// readReply reads one RESP2 reply: a string for simple strings, int64 for
+// integers, []byte for bulk strings, []any for arrays and nil for null.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("malformed reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &redisError{msg: line[1:]}
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", line)
+}
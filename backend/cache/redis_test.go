@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves GET, SET, DEL, AUTH and SELECT from a map and records
+// every command it receives.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+	conns    int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, data: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) url(auth string) string {
+	return "redis://" + auth + f.ln.Addr().String() + "/3"
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = string(item.([]byte))
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		var out string
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				out = "+OK\r\n"
+			} else {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			out = "+OK\r\n"
+		case args[0] == "GET":
+			if v, ok := f.data[args[1]]; ok {
+				out = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				out = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			f.data[args[1]] = args[2]
+			out = "+OK\r\n"
+		case args[0] == "DEL":
+			n := 0
+			for _, key := range args[1:] {
+				if _, ok := f.data[key]; ok {
+					delete(f.data, key)
+					n++
+				}
+			}
+			out = fmt.Sprintf(":%d\r\n", n)
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func TestRedis_GetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedis(t, "s3cret")
+	c, err := NewRedis(srv.url(":s3cret@"))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer c.Close()
+
+	if _, ok, err := c.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("expected a miss, got %v %v", ok, err)
+	}
+	if err := c.Set(ctx, "k", []byte("line1\r\nline2"), 1500*time.Millisecond); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	v, ok, err := c.Get(ctx, "k")
+	if err != nil || !ok || string(v) != "line1\r\nline2" {
+		t.Fatalf("unexpected value %q %v %v", v, ok, err)
+	}
+	if err := c.Delete(ctx, "k"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "k"); ok {
+		t.Fatal("expected the key to be deleted")
+	}
+
+	want := []string{"AUTH s3cret", "SELECT 3", "GET k", "SET k line1\r\nline2 PX 1500", "GET k", "DEL k", "GET k"}
+	if got := srv.log(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected commands:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestRedis_ErrorReplyKeepsConnection(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeRedis(t, "s3cret")
+	c, err := NewRedis("redis://" + srv.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer c.Close()
+
+	for range 2 {
+		if _, _, err := c.Get(ctx, "k"); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+			t.Fatalf("expected NOAUTH, got %v", err)
+		}
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conns != 1 {
+		t.Fatalf("expected the connection to be reused, got %d dials", srv.conns)
+	}
+}
+
+func TestRedis_DialFailure(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c, err := NewRedis("redis://" + addr)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "k"); err == nil {
+		t.Fatal("expected a dial error")
+	}
+}
@@ -0,0 +1,226 @@
+// Package clientportal serves the read-only views a client (auth.RoleClient)
+// has of the referral an agent created on their behalf. Everything it
+// returns passes an allowlist: referral and agreement states are collapsed
+// into client-facing stages, and fees, protect periods, disputes and timeline
+// payloads never leave the package.
+package clientportal
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"brokerflow/agentprofile"
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+)
+
+// MaxReferrals bounds the list a client sees, newest first.
+const MaxReferrals = 100
+
+var (
+	ErrReferralNotFound = errors.New("clientportal: referral not found")
+	ErrNotClient        = errors.New("clientportal: user is not a client")
+)
+
+// Referral is the client's view of a referral. Status is the client-facing
+// status (see ReferralStatus) once it has passed through the Service.
+type Referral struct {
+	ID           string
+	Status       string
+	Region       []string
+	PropertyType string
+	DealType     string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Agent is the public profile of the agent serving a referral: the accepted
+// candidate, preferring one from the receiving broker of its agreement.
+type Agent struct {
+	UserID      string
+	FullName    string
+	BrokerName  string
+	Languages   []string
+	Regions     []string
+	Licenses    []agentprofile.License
+	Rating      float64
+	ReviewCount int
+}
+
+// Agreement is the raw state of the referral's current agreement as the
+// Store reads it; the Service reduces it to a Progress.
+type Agreement struct {
+	Status      string
+	EffectiveAt *time.Time
+	Events      []Event
+}
+
+// Event is one timeline event, without its payload.
+type Event struct {
+	Type string
+	At   time.Time
+}
+
+// Milestone is a deal milestone shown to the client.
+type Milestone struct {
+	Name string
+	At   time.Time
+}
+
+// Progress is the client's view of the agreement: a coarse stage and the
+// milestones reached so far, oldest first.
+type Progress struct {
+	Stage      string
+	SignedAt   *time.Time
+	Milestones []Milestone
+}
+
+// Overview is everything a client sees about one referral.
+type Overview struct {
+	Referral Referral
+	Agent    *Agent
+	Progress *Progress
+}
+
+// Client-facing referral statuses. A disputed referral is shown as in
+// progress: disputes are between the brokers.
+const (
+	ReferralOpen       = "open"
+	ReferralMatched    = "matched"
+	ReferralSigned     = "signed"
+	ReferralInProgress = "in_progress"
+	ReferralClosed     = "closed"
+	ReferralCancelled  = "cancelled"
+)
+
+var referralStatuses = map[string]string{
+	"open":        ReferralOpen,
+	"matched":     ReferralMatched,
+	"signed":      ReferralSigned,
+	"in_progress": ReferralInProgress,
+	"disputed":    ReferralInProgress,
+	"closed":      ReferralClosed,
+	"cancelled":   ReferralCancelled,
+}
+
+// ReferralStatus maps a referral status to the one a client sees; unknown
+// statuses read as in progress.
+func ReferralStatus(status string) string {
+	if s, ok := referralStatuses[status]; ok {
+		return s
+	}
+	return ReferralInProgress
+}
+
+// Agreement stages shown to clients.
+const (
+	StagePreparing = "preparing"
+	StageActive    = "active"
+	StageCompleted = "completed"
+	StageEnded     = "ended"
+)
+
+var agreementStages = map[string]string{
+	"draft":             StagePreparing,
+	"pending_signature": StagePreparing,
+	"effective":         StageActive,
+	"disputed":          StageActive,
+	"success":           StageCompleted,
+	"closed":            StageCompleted,
+	"void":              StageEnded,
+	"expired":           StageEnded,
+	"cancelled":         StageEnded,
+}
+
+// milestones names the timeline events a client may see. Fee amendments,
+// status changes and protect expiry stay between the brokers.
+var milestones = map[string]string{
+	timeline.TypeEsignCompleted: "agreement_signed",
+	timeline.TypeOfferMade:      "offer_made",
+	timeline.TypeUnderContract:  "under_contract",
+	timeline.TypeDealClosed:     "deal_closed",
+}
+
+// progress reduces an agreement to what the client may see.
+func progress(a Agreement) Progress {
+	stage, ok := agreementStages[a.Status]
+	if !ok {
+		stage = StagePreparing
+	}
+	p := Progress{Stage: stage, SignedAt: a.EffectiveAt, Milestones: []Milestone{}}
+	for _, e := range a.Events {
+		if name, ok := milestones[e.Type]; ok {
+			p.Milestones = append(p.Milestones, Milestone{Name: name, At: e.At})
+		}
+	}
+	sort.SliceStable(p.Milestones, func(i, j int) bool { return p.Milestones[i].At.Before(p.Milestones[j].At) })
+	return p
+}
+
+type Store interface {
+	// Referrals returns up to limit referrals linked to clientID, newest
+	// first.
+	Referrals(ctx context.Context, clientID string, limit int) ([]Referral, error)
+	// Referral returns one referral linked to clientID, or
+	// ErrReferralNotFound.
+	Referral(ctx context.Context, clientID, id string) (Referral, error)
+	// AssignedAgent returns nil when no candidate has accepted.
+	AssignedAgent(ctx context.Context, referralID string) (*Agent, error)
+	// Agreement returns the referral's current agreement, preferring one
+	// that is not void or cancelled, or nil when there is none.
+	Agreement(ctx context.Context, referralID string) (*Agreement, error)
+	// LinkClient sets or, with a nil clientID, clears the client of a
+	// referral within scope.
+	LinkClient(ctx context.Context, scope tenancy.Scope, referralID string, clientID *string) error
+}
+
+type Service struct {
+	store Store
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// List returns the client's referrals with client-facing statuses.
+func (s *Service) List(ctx context.Context, clientID string) ([]Referral, error) {
+	refs, err := s.store.Referrals(ctx, clientID, MaxReferrals)
+	if err != nil {
+		return nil, err
+	}
+	for i := range refs {
+		refs[i].Status = ReferralStatus(refs[i].Status)
+	}
+	return refs, nil
+}
+
+// Get returns one of the client's referrals with its agent and progress.
+func (s *Service) Get(ctx context.Context, clientID, referralID string) (Overview, error) {
+	ref, err := s.store.Referral(ctx, clientID, referralID)
+	if err != nil {
+		return Overview{}, err
+	}
+	ref.Status = ReferralStatus(ref.Status)
+	agent, err := s.store.AssignedAgent(ctx, ref.ID)
+	if err != nil {
+		return Overview{}, err
+	}
+	out := Overview{Referral: ref, Agent: agent}
+	a, err := s.store.Agreement(ctx, ref.ID)
+	if err != nil {
+		return Overview{}, err
+	}
+	if a != nil {
+		p := progress(*a)
+		out.Progress = &p
+	}
+	return out, nil
+}
+
+// LinkClient makes clientID the client of a referral in scope, so they see
+// it in the portal; nil unlinks it.
+func (s *Service) LinkClient(ctx context.Context, scope tenancy.Scope, referralID string, clientID *string) error {
+	return s.store.LinkClient(ctx, scope, referralID, clientID)
+}
@@ -0,0 +1,115 @@
+package clientportal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+)
+
+type fakeStore struct {
+	referrals map[string]Referral
+	clients   map[string]string
+	agent     *Agent
+	agreement *Agreement
+}
+
+func (s *fakeStore) Referrals(_ context.Context, clientID string, limit int) ([]Referral, error) {
+	var out []Referral
+	for id, ref := range s.referrals {
+		if s.clients[id] == clientID && len(out) < limit {
+			out = append(out, ref)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) Referral(_ context.Context, clientID, id string) (Referral, error) {
+	ref, ok := s.referrals[id]
+	if !ok || s.clients[id] != clientID {
+		return Referral{}, ErrReferralNotFound
+	}
+	return ref, nil
+}
+
+func (s *fakeStore) AssignedAgent(context.Context, string) (*Agent, error) { return s.agent, nil }
+
+func (s *fakeStore) Agreement(context.Context, string) (*Agreement, error) { return s.agreement, nil }
+
+func (s *fakeStore) LinkClient(_ context.Context, _ tenancy.Scope, referralID string, clientID *string) error {
+	if _, ok := s.referrals[referralID]; !ok {
+		return ErrReferralNotFound
+	}
+	if clientID == nil {
+		delete(s.clients, referralID)
+		return nil
+	}
+	s.clients[referralID] = *clientID
+	return nil
+}
+
+func TestGetCollapsesBrokerOnlyState(t *testing.T) {
+	effective := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		referrals: map[string]Referral{"r1": {ID: "r1", Status: "disputed"}},
+		clients:   map[string]string{},
+		agent:     &Agent{UserID: "a1", FullName: "Ana Agent"},
+		agreement: &Agreement{Status: "disputed", EffectiveAt: &effective, Events: []Event{
+			{Type: timeline.TypeAgreementCreated, At: effective.Add(-time.Hour)},
+			{Type: timeline.TypeEsignCompleted, At: effective},
+			{Type: timeline.TypeAmendmentProposed, At: effective.Add(time.Hour)},
+			{Type: timeline.TypeUnderContract, At: effective.Add(3 * time.Hour)},
+			{Type: timeline.TypeOfferMade, At: effective.Add(2 * time.Hour)},
+			{Type: timeline.TypeProtectExpired, At: effective.Add(4 * time.Hour)},
+		}},
+	}
+	svc := NewService(store)
+	ctx := context.Background()
+
+	if _, err := svc.Get(ctx, "c1", "r1"); !errors.Is(err, ErrReferralNotFound) {
+		t.Fatalf("unlinked client: got %v", err)
+	}
+	client := "c1"
+	if err := svc.LinkClient(ctx, tenancy.User("owner"), "r1", &client); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Get(ctx, "c1", "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Referral.Status != ReferralInProgress || got.Agent == nil || got.Agent.UserID != "a1" {
+		t.Fatalf("unexpected overview %+v", got)
+	}
+	want := Progress{Stage: StageActive, SignedAt: &effective, Milestones: []Milestone{
+		{Name: "agreement_signed", At: effective},
+		{Name: "offer_made", At: effective.Add(2 * time.Hour)},
+		{Name: "under_contract", At: effective.Add(3 * time.Hour)},
+	}}
+	if got.Progress == nil || !reflect.DeepEqual(*got.Progress, want) {
+		t.Fatalf("progress = %+v, want %+v", got.Progress, want)
+	}
+
+	if err := svc.LinkClient(ctx, tenancy.User("owner"), "r1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := svc.List(ctx, "c1"); len(refs) != 0 {
+		t.Fatalf("unlinked referral still listed: %+v", refs)
+	}
+}
+
+func TestStatusMappings(t *testing.T) {
+	for status, want := range map[string]string{"open": ReferralOpen, "disputed": ReferralInProgress, "closed": ReferralClosed, "unknown": ReferralInProgress} {
+		if got := ReferralStatus(status); got != want {
+			t.Errorf("ReferralStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+	for status, want := range map[string]string{"pending_signature": StagePreparing, "disputed": StageActive, "success": StageCompleted, "cancelled": StageEnded} {
+		if got := progress(Agreement{Status: status}).Stage; got != want {
+			t.Errorf("stage(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
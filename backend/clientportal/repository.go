@@ -0,0 +1,169 @@
+package clientportal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"brokerflow/agentprofile"
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool}
+}
+
+// referralColumns is the allowlist of referral_requests columns the portal
+// reads; prices, SLA and match settings are left out.
+const referralColumns = `rr.id::text, rr.status::text, rr.region, rr.property_type, rr.deal_type, rr.created_at, rr.updated_at`
+
+func scanReferral(row pgx.Row) (Referral, error) {
+	var ref Referral
+	err := row.Scan(&ref.ID, &ref.Status, &ref.Region, &ref.PropertyType, &ref.DealType, &ref.CreatedAt, &ref.UpdatedAt)
+	return ref, err
+}
+
+func (r *PGRepository) Referrals(ctx context.Context, clientID string, limit int) ([]Referral, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+referralColumns+`
+		FROM referral_requests rr
+		WHERE rr.client_user_id = $1
+		ORDER BY rr.created_at DESC, rr.id
+		LIMIT $2
+	`, clientID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: list referrals: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Referral, error) { return scanReferral(row) })
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: scan referrals: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PGRepository) Referral(ctx context.Context, clientID, id string) (Referral, error) {
+	ref, err := scanReferral(r.pool.QueryRow(ctx, `
+		SELECT `+referralColumns+`
+		FROM referral_requests rr
+		WHERE rr.id = $1 AND rr.client_user_id = $2
+	`, id, clientID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Referral{}, ErrReferralNotFound
+	}
+	if err != nil {
+		return Referral{}, fmt.Errorf("clientportal: get referral: %w", err)
+	}
+	return ref, nil
+}
+
+func (r *PGRepository) AssignedAgent(ctx context.Context, referralID string) (*Agent, error) {
+	var (
+		agent    Agent
+		licenses []byte
+	)
+	err := r.pool.QueryRow(ctx, `
+		SELECT u.id::text, u.full_name, COALESCE(b.name, ''),
+			COALESCE(NULLIF(ap.languages, '{}'), u.languages), COALESCE(ap.regions, '{}'),
+			COALESCE(ap.licenses, '[]'::jsonb), u.rating::float8, u.review_count
+		FROM referral_matches m
+		JOIN users u ON u.id = m.candidate_user_id AND u.deleted_at IS NULL
+		LEFT JOIN brokers b ON b.id = u.broker_id
+		LEFT JOIN agent_profiles ap ON ap.user_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT a.to_broker_id FROM agreements a
+			WHERE a.referral_id = m.request_id AND a.status NOT IN ('void', 'cancelled')
+			ORDER BY a.created_at DESC
+			LIMIT 1
+		) a ON true
+		WHERE m.request_id = $1 AND m.state = 'accepted'
+		ORDER BY (u.broker_id IS NOT DISTINCT FROM a.to_broker_id) DESC, m.created_at
+		LIMIT 1
+	`, referralID).Scan(&agent.UserID, &agent.FullName, &agent.BrokerName,
+		&agent.Languages, &agent.Regions, &licenses, &agent.Rating, &agent.ReviewCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: assigned agent: %w", err)
+	}
+	agent.Licenses = []agentprofile.License{}
+	if err := json.Unmarshal(licenses, &agent.Licenses); err != nil {
+		return nil, fmt.Errorf("clientportal: decode licenses: %w", err)
+	}
+	return &agent, nil
+}
+
+func (r *PGRepository) Agreement(ctx context.Context, referralID string) (*Agreement, error) {
+	var (
+		id string
+		a  Agreement
+	)
+	err := r.pool.QueryRow(ctx, `
+		SELECT a.id::text, a.status::text, a.effective_at
+		FROM agreements a
+		WHERE a.referral_id = $1
+		ORDER BY (a.status IN ('void', 'cancelled')), a.created_at DESC
+		LIMIT 1
+	`, referralID).Scan(&id, &a.Status, &a.EffectiveAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: agreement: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT type::text, ts
+		FROM timeline_events
+		WHERE agreement_id = $1
+		ORDER BY seq
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: timeline: %w", err)
+	}
+	a.Events, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var e Event
+		err := row.Scan(&e.Type, &e.At)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("clientportal: scan timeline: %w", err)
+	}
+	return &a, nil
+}
+
+// LinkClient checks that clientID names a client account before linking it,
+// so a referral cannot be exposed to an agent or admin through the portal.
+func (r *PGRepository) LinkClient(ctx context.Context, scope tenancy.Scope, referralID string, clientID *string) error {
+	if clientID != nil {
+		var isClient bool
+		if err := r.pool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND role = 'client' AND deleted_at IS NULL)
+		`, *clientID).Scan(&isClient); err != nil {
+			return fmt.Errorf("clientportal: check client: %w", err)
+		}
+		if !isClient {
+			return ErrNotClient
+		}
+	}
+	owned, arg := scope.OwnedBy("rr.created_by_user_id", 1)
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE referral_requests rr
+		SET client_user_id = $3
+		WHERE rr.id = $2 AND `+owned, arg, referralID, clientID)
+	if err != nil {
+		return fmt.Errorf("clientportal: link client: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReferralNotFound
+	}
+	return nil
+}
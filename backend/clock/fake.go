@@ -0,0 +1,208 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a manually advanced Clock. Timers and tickers fire synchronously
+// from Advance/Set, which makes SLA and scheduling logic testable without
+// sleeping and lets the simulation mode fast-forward days in milliseconds.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed *sync.Cond
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	period   time.Duration // zero for one-shot timers
+	ch       chan time.Time
+}
+
+// NewFake returns a Fake clock reading start.
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake current time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After returns a channel that receives once the clock has advanced by d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer creates a one-shot timer firing once the clock advances by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{deadline: f.now.Add(d), ch: make(chan time.Time, 1)}
+	f.addLocked(w)
+	return &fakeTimer{clock: f, w: w}
+}
+
+// NewTicker creates a ticker firing every d of fake time.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{deadline: f.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.addLocked(w)
+	return &fakeTicker{clock: f, w: w}
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker whose
+// deadline falls inside the window in deadline order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	f.mu.Unlock()
+	f.Set(target)
+}
+
+// Set moves the clock to t. Moving backwards only changes Now.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for {
+		w := f.nextDueLocked(t)
+		if w == nil {
+			break
+		}
+		f.now = w.deadline
+		select {
+		case w.ch <- w.deadline:
+		default:
+			// Like time.Ticker, drop ticks nobody is reading.
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			f.removeLocked(w)
+		}
+	}
+	f.now = t
+}
+
+// Waiters reports how many timers and tickers are currently armed.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// Next returns the earliest deadline among the armed timers and tickers;
+// ok is false when none is armed.
+func (f *Fake) Next() (deadline time.Time, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, w := range f.waiters {
+		if i == 0 || w.deadline.Before(deadline) {
+			deadline = w.deadline
+		}
+	}
+	return deadline, len(f.waiters) > 0
+}
+
+// BlockUntil waits until at least n timers or tickers are armed. Tests use it
+// to make sure a worker goroutine is parked before advancing the clock.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.changed.Wait()
+	}
+}
+
+func (f *Fake) nextDueLocked(limit time.Time) *fakeWaiter {
+	if len(f.waiters) == 0 {
+		return nil
+	}
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+	})
+	if w := f.waiters[0]; !w.deadline.After(limit) {
+		return w
+	}
+	return nil
+}
+
+func (f *Fake) addLocked(w *fakeWaiter) {
+	f.waiters = append(f.waiters, w)
+	f.changed.Broadcast()
+}
+
+func (f *Fake) removeLocked(w *fakeWaiter) bool {
+	for i, cur := range f.waiters {
+		if cur == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.clock.removeLocked(t.w)
+	t.w.deadline = t.clock.now.Add(d)
+	t.clock.addLocked(t.w)
+	return wasActive
+}
+
+type fakeTicker struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.removeLocked(t.w)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.removeLocked(t.w)
+	t.w.period = d
+	t.w.deadline = t.clock.now.Add(d)
+	t.clock.addLocked(t.w)
+}
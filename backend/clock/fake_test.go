@@ -0,0 +1,112 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+func TestFake_TimerFiresOnAdvance(t *testing.T) {
+	c := NewFake(epoch)
+	timer := c.NewTimer(time.Hour)
+
+	c.Advance(59 * time.Minute)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(epoch.Add(time.Hour)) {
+			t.Fatalf("expected fire time %v, got %v", epoch.Add(time.Hour), at)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if c.Waiters() != 0 {
+		t.Fatalf("expected fired timer to be disarmed, %d waiters left", c.Waiters())
+	}
+}
+
+func TestFake_TimerStopAndReset(t *testing.T) {
+	c := NewFake(epoch)
+	timer := c.NewTimer(time.Minute)
+	if !timer.Stop() {
+		t.Fatal("expected Stop to report an active timer")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	timer.Reset(time.Minute)
+	c.Advance(time.Minute)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestFake_TickerFiresPerPeriod(t *testing.T) {
+	c := NewFake(epoch)
+	ticker := c.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	var ticks []time.Time
+	for i := 0; i < 3; i++ {
+		c.Advance(10 * time.Minute)
+		select {
+		case at := <-ticker.C():
+			ticks = append(ticks, at)
+		default:
+			t.Fatalf("tick %d missing", i)
+		}
+	}
+	if !ticks[2].Equal(epoch.Add(30 * time.Minute)) {
+		t.Fatalf("unexpected tick times %v", ticks)
+	}
+	if !c.Now().Equal(epoch.Add(30 * time.Minute)) {
+		t.Fatalf("unexpected now %v", c.Now())
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	c := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		<-c.After(time.Second)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine waiting on fake After was not released")
+	}
+}
+
+func TestFake_Next(t *testing.T) {
+	c := NewFake(epoch)
+	if _, ok := c.Next(); ok {
+		t.Fatal("expected no deadline without timers")
+	}
+	c.NewTimer(time.Hour)
+	ticker := c.NewTicker(10 * time.Minute)
+	if next, ok := c.Next(); !ok || !next.Equal(epoch.Add(10*time.Minute)) {
+		t.Fatalf("expected the ticker's deadline first, got %v %t", next, ok)
+	}
+	ticker.Stop()
+	if next, _ := c.Next(); !next.Equal(epoch.Add(time.Hour)) {
+		t.Fatalf("expected the timer's deadline once the ticker stopped, got %v", next)
+	}
+}
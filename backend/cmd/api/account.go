@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/auth"
+)
+
+type accountErasureResponse struct {
+	UserID        string `json:"userId"`
+	DeletedAt     string `json:"deletedAt"`
+	PIIPurgeAfter string `json:"piiPurgeAfter"`
+}
+
+// handleDeleteMe erases the caller's account. The user row stays so
+// agreements and timeline events keep their references, but its PII is
+// overwritten and the account can no longer sign in. Tokens already issued
+// remain valid until they expire.
+func (s *Server) handleDeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	e, err := s.authService.DeleteAccount(ctx, userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	respondJSON(w, http.StatusOK, accountErasureResponse{
+		UserID:        e.UserID,
+		DeletedAt:     e.DeletedAt.UTC().Format(time.RFC3339),
+		PIIPurgeAfter: e.PurgeAfter.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+)
+
+// stubErasureRepo erases users from the wrapped stubUserRepo.
+type stubErasureRepo struct {
+	users *stubUserRepo
+}
+
+func (s *stubErasureRepo) EraseUser(_ context.Context, userID string, purgeAfter time.Time) (auth.Erasure, error) {
+	if _, ok := s.users.users[userID]; !ok {
+		return auth.Erasure{}, auth.ErrUserNotFound
+	}
+	delete(s.users.users, userID)
+	return auth.Erasure{UserID: userID, DeletedAt: time.Now(), PurgeAfter: purgeAfter}, nil
+}
+
+func TestHandleDeleteMe(t *testing.T) {
+	users := &stubUserRepo{users: map[string]auth.User{"user-1": {ID: "user-1", Role: auth.RoleAgent}}}
+	server := &Server{authService: auth.NewService(users, "secret").
+		WithErasure(&stubErasureRepo{users: users}, time.Hour)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/me", server.handleMe)
+	mux.HandleFunc("DELETE /api/me", server.handleDeleteMe)
+
+	do := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/me", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "user-1"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodDelete)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp accountErasureResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.UserID != "user-1" || resp.PIIPurgeAfter == "" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	if rec := do(http.MethodGet); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected erased profile to be gone, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 on second delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/activity"
+)
+
+type activityService interface {
+	List(ctx context.Context, userID string, types []activity.Type, page, pageSize int) ([]activity.Item, int, error)
+}
+
+type activityItemResponse struct {
+	ID          string `json:"id"`
+	Type        string `json:"type" doc:"match_received, referral_cancelled, agreement_signed or dispute_opened"`
+	Topic       string `json:"topic" doc:"Outbox topic the item was built from"`
+	Own         bool   `json:"own" doc:"The caller caused the event, e.g. sent the invitation or opened the dispute"`
+	ReferralID  string `json:"referralId,omitempty"`
+	MatchID     string `json:"matchId,omitempty"`
+	AgreementID string `json:"agreementId,omitempty"`
+	DisputeID   string `json:"disputeId,omitempty"`
+	At          string `json:"at"`
+}
+
+type paginatedActivity struct {
+	Items    []activityItemResponse `json:"items"`
+	Total    int                    `json:"total"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"pageSize"`
+}
+
+func newActivityItemResponse(it activity.Item) activityItemResponse {
+	return activityItemResponse{
+		ID:          it.ID,
+		Type:        string(it.Type),
+		Topic:       it.Topic,
+		Own:         it.Own,
+		ReferralID:  it.ReferralID,
+		MatchID:     it.MatchID,
+		AgreementID: it.AgreementID,
+		DisputeID:   it.DisputeID,
+		At:          it.At.UTC().Format(time.RFC3339),
+	}
+}
+
+// handleListActivity pages through the caller's activity feed, newest
+// first, optionally narrowed by repeated or comma-separated type filters.
+func (s *Server) handleListActivity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	query := r.URL.Query()
+	types, err := activity.ParseTypes(query["type"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Unknown activity type")
+		return
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	ctx := r.Context()
+
+	items, total, err := s.activity.List(ctx, userID, types, page, pageSize)
+	if err != nil {
+		if errors.Is(err, activity.ErrUnknownType) {
+			respondError(w, http.StatusBadRequest, "Unknown activity type")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load activity")
+		return
+	}
+	resp := paginatedActivity{Items: make([]activityItemResponse, 0, len(items)), Total: total, Page: page, PageSize: pageSize}
+	for _, it := range items {
+		resp.Items = append(resp.Items, newActivityItemResponse(it))
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"brokerflow/activity"
+	"brokerflow/auth"
+)
+
+type stubActivity struct {
+	userID         string
+	types          []activity.Type
+	page, pageSize int
+	items          []activity.Item
+	err            error
+}
+
+func (s *stubActivity) List(_ context.Context, userID string, types []activity.Type, page, pageSize int) ([]activity.Item, int, error) {
+	s.userID, s.types, s.page, s.pageSize = userID, types, page, pageSize
+	return s.items, len(s.items), s.err
+}
+
+func TestHandleListActivity(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	stub := &stubActivity{items: []activity.Item{{
+		ID: "msg-1", Type: activity.TypeDisputeOpened, Topic: "dispute.opened", Own: true,
+		AgreementID: "agr-1", DisputeID: "dsp-1", At: at,
+	}}}
+	server := &Server{activity: stub}
+	rec := httptest.NewRecorder()
+
+	server.handleListActivity(rec, agentRequest(http.MethodGet, "/api/me/activity?type=dispute_opened,match_received&type=agreement_signed&page=2&pageSize=5", "", auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := []activity.Type{activity.TypeDisputeOpened, activity.TypeMatchReceived, activity.TypeAgreementSigned}
+	if stub.userID != "agent-1" || !slices.Equal(stub.types, want) || stub.page != 2 || stub.pageSize != 5 {
+		t.Fatalf("unexpected list call %+v", stub)
+	}
+	var resp paginatedActivity
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Items) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if it := resp.Items[0]; it.Type != "dispute_opened" || !it.Own || it.DisputeID != "dsp-1" || it.ReferralID != "" || it.At != "2026-03-01T12:00:00Z" {
+		t.Fatalf("unexpected item %+v", it)
+	}
+}
+
+func TestHandleListActivity_Errors(t *testing.T) {
+	stub := &stubActivity{}
+	server := &Server{activity: stub}
+
+	rec := httptest.NewRecorder()
+	server.handleListActivity(rec, agentRequest(http.MethodGet, "/api/me/activity?type=review_submitted", "", auth.RoleAgent))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown type, got %d", rec.Code)
+	}
+
+	stub.err = errors.New("boom")
+	rec = httptest.NewRecorder()
+	server.handleListActivity(rec, agentRequest(http.MethodGet, "/api/me/activity", "", auth.RoleClient))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if stub.types != nil || stub.page != 1 || stub.pageSize != 20 {
+		t.Fatalf("defaults not applied: %+v", stub)
+	}
+}
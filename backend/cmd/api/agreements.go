@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/broker"
+	"brokerflow/money"
+)
+
+type createAgreementRequest struct {
+	RequestID        string  `json:"requestId"`
+	ReferrerBrokerID string  `json:"referrerBrokerId"`
+	RefereeBrokerID  string  `json:"refereeBrokerId"`
+	FeeRate          float64 `json:"feeRate"`
+	ProtectDays      int     `json:"protectDays"`
+}
+
+func (s *Server) handleCreateAgreement(w http.ResponseWriter, r *http.Request) {
+	var req createAgreementRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	record, err := s.agreementCRUD.Create(ctx, userID, agreement.CreateParams{
+		RequestID:        req.RequestID,
+		ReferrerBrokerID: req.ReferrerBrokerID,
+		RefereeBrokerID:  req.RefereeBrokerID,
+		FeeRate:          req.FeeRate,
+		ProtectDays:      req.ProtectDays,
+	})
+	if err != nil {
+		respondServiceError(w, err, "Failed to create agreement")
+		return
+	}
+
+	setETag(w, record.Version)
+	respondJSON(w, http.StatusCreated, newAgreementResponse(record))
+}
+
+// policyViolationResponse is a 400 for terms outside the referring broker's
+// policy; term, bound and limit let the UI say which limit was broken.
+type policyViolationResponse struct {
+	Message  string  `json:"message"`
+	Code     string  `json:"code" doc:"Always outside_policy"`
+	BrokerID string  `json:"brokerId"`
+	Term     string  `json:"term" doc:"feeRate or protectDays"`
+	Bound    string  `json:"bound" doc:"min or max"`
+	Limit    float64 `json:"limit"`
+	Value    float64 `json:"value"`
+}
+
+const errCodeOutsidePolicy = "outside_policy"
+
+func respondPolicyViolation(w http.ResponseWriter, v *broker.PolicyViolation) {
+	message := localizedMessage(w, errCodeOutsidePolicy, v.Error(),
+		"term", string(v.Term), "value", strconv.FormatFloat(v.Value, 'f', -1, 64),
+		"bound", string(v.Bound), "limit", strconv.FormatFloat(v.Limit, 'f', -1, 64), "broker", v.BrokerID)
+	respondJSON(w, http.StatusBadRequest, policyViolationResponse{
+		Message:  message,
+		Code:     errCodeOutsidePolicy,
+		BrokerID: v.BrokerID,
+		Term:     string(v.Term),
+		Bound:    string(v.Bound),
+		Limit:    v.Limit,
+		Value:    v.Value,
+	})
+}
+
+func (s *Server) handleListAgreements(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+
+	ctx := r.Context()
+
+	scope, err := s.listScope(ctx, userID, query.Get("officeId"))
+	if err != nil {
+		respondServiceError(w, err, "Failed to resolve access scope")
+		return
+	}
+
+	filters := agreement.ListFilters{
+		Scope:                scope,
+		CounterpartyBrokerID: query.Get("counterpartyBrokerId"),
+		ReferralID:           query.Get("referralId"),
+		Page:                 page,
+		PageSize:             pageSize,
+	}
+	for _, raw := range query["status"] {
+		for _, status := range strings.Split(raw, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				filters.Statuses = append(filters.Statuses, status)
+			}
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"createdFrom", &filters.CreatedFrom}, {"createdTo", &filters.CreatedTo},
+		{"effectiveFrom", &filters.EffectiveFrom}, {"effectiveTo", &filters.EffectiveTo},
+	} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid "+p.name+": use YYYY-MM-DD or RFC 3339")
+			return
+		}
+		*p.dst = t
+	}
+
+	items, listPage, err := s.agreementCRUD.List(ctx, filters)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load agreements")
+		return
+	}
+
+	responses := make([]agreementResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, newAgreementResponse(item))
+	}
+
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+
+	respondJSON(w, http.StatusOK, paginatedAgreements{
+		Items:      responses,
+		Total:      listPage.Total,
+		TotalExact: listPage.TotalExact,
+		HasMore:    listPage.HasMore,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+	})
+}
+
+type agreementResponse struct {
+	ID               string  `json:"id"`
+	RequestID        string  `json:"requestId"`
+	ReferrerBrokerID string  `json:"referrerBrokerId"`
+	RefereeBrokerID  string  `json:"refereeBrokerId"`
+	FeeRate          float64 `json:"feeRate"`
+	ProtectDays      int     `json:"protectDays"`
+	Currency         string  `json:"currency" doc:"ISO 4217 code the referral fee is settled in"`
+	EffectiveAt      string  `json:"effectiveAt,omitempty"`
+	ReferrerSignedAt string  `json:"referrerSignedAt,omitempty"`
+	ReferrerSignedBy *string `json:"referrerSignedBy,omitempty" doc:"Absent for agreements signed before signatures were tracked"`
+	RefereeSignedAt  string  `json:"refereeSignedAt,omitempty"`
+	RefereeSignedBy  *string `json:"refereeSignedBy,omitempty"`
+	Status           string  `json:"status" doc:"The agreement's lifecycle status, such as draft, pending_signature or effective"`
+	StatusUpdatedAt  string  `json:"statusUpdatedAt,omitempty"`
+	StatusUpdatedBy  *string `json:"statusUpdatedBy,omitempty" doc:"Absent when the system, such as expiry, made the last change"`
+	Version          int     `json:"version" doc:"Send as If-Match, quoted, to change the agreement's status"`
+	CreatedAt        string  `json:"createdAt"`
+	UpdatedAt        string  `json:"updatedAt"`
+}
+
+type paginatedAgreements struct {
+	Items      []agreementResponse `json:"items"`
+	Total      int                 `json:"total"`
+	TotalExact bool                `json:"totalExact" doc:"False when total is an estimate or a cached count; page with hasMore instead"`
+	HasMore    bool                `json:"hasMore" doc:"Whether another page follows this one"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"pageSize"`
+}
+
+func newAgreementResponse(rec agreement.Record) agreementResponse {
+	var effective string
+	if rec.EffectiveAt != nil {
+		effective = rec.EffectiveAt.UTC().Format(time.RFC3339)
+	}
+	var statusUpdated string
+	if !rec.StatusUpdatedAt.IsZero() {
+		statusUpdated = rec.StatusUpdatedAt.UTC().Format(time.RFC3339)
+	}
+	currency := rec.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	return agreementResponse{
+		ID:               rec.ID,
+		RequestID:        rec.RequestID,
+		ReferrerBrokerID: rec.ReferrerBrokerID,
+		RefereeBrokerID:  rec.RefereeBrokerID,
+		FeeRate:          rec.FeeRate,
+		ProtectDays:      rec.ProtectDays,
+		Currency:         string(currency),
+		EffectiveAt:      effective,
+		ReferrerSignedAt: formatOptionalTime(rec.ReferrerSignedAt),
+		ReferrerSignedBy: rec.ReferrerSignedBy,
+		RefereeSignedAt:  formatOptionalTime(rec.RefereeSignedAt),
+		RefereeSignedBy:  rec.RefereeSignedBy,
+		Status:           rec.Status,
+		StatusUpdatedAt:  statusUpdated,
+		StatusUpdatedBy:  rec.StatusUpdatedBy,
+		Version:          rec.Version,
+		CreatedAt:        rec.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:        rec.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+type updateAgreementStatusRequest struct {
+	AgreementID string         `json:"agreementId"`
+	NextStatus  string         `json:"nextStatus"`
+	Payload     map[string]any `json:"payload,omitempty"`
+}
+
+type agreementStatusResponse struct {
+	AgreementID string `json:"agreementId"`
+	NextStatus  string `json:"nextStatus"`
+	Version     int    `json:"version"`
+}
+
+// agreementConflictResponse is the 412 body: the agreement's status and
+// version as they are now.
+type agreementConflictResponse struct {
+	AgreementID string `json:"agreementId"`
+	Status      string `json:"status"`
+	Version     int    `json:"version"`
+}
+
+func (s *Server) handleUpdateAgreementStatus(w http.ResponseWriter, r *http.Request) {
+	var req updateAgreementStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	// The agreement's ETag, from the list or a previous transition, must
+	// match so that two admins cannot unknowingly overwrite each other.
+	ifVersion, ok := ifMatchVersion(r)
+	if !ok {
+		respondError(w, http.StatusPreconditionRequired, "If-Match header required")
+		return
+	}
+
+	ctx := r.Context()
+
+	version, err := s.agreementStatus.Transition(ctx, agreement.TransitionParams{
+		AgreementID: req.AgreementID,
+		ActorID:     userID,
+		NextStatus:  req.NextStatus,
+		Payload:     req.Payload,
+		IfVersion:   ifVersion,
+	})
+	if err != nil {
+		var conflict *agreement.VersionConflictError
+		if errors.As(err, &conflict) {
+			setETag(w, conflict.Version)
+			respondJSON(w, http.StatusPreconditionFailed, agreementConflictResponse{
+				AgreementID: req.AgreementID,
+				Status:      conflict.Status,
+				Version:     conflict.Version,
+			})
+			return
+		}
+		respondServiceError(w, err, "Failed to update agreement status")
+		return
+	}
+
+	setETag(w, version)
+	respondJSON(w, http.StatusOK, agreementStatusResponse{
+		AgreementID: req.AgreementID,
+		NextStatus:  req.NextStatus,
+		Version:     version,
+	})
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+)
+
+func newAgreementTestServer(t *testing.T) (*Server, *testsupport.Users, *testsupport.Referrals, *testsupport.Agreements) {
+	t.Helper()
+	users := testsupport.NewUsers()
+	referrals := testsupport.NewReferrals(users)
+	agreements := testsupport.NewAgreements(referrals, users)
+	server := &Server{
+		authService:     auth.NewService(users, "secret"),
+		referralService: referral.NewService(&testsupport.TxBeginner{}, referrals, nil, nil),
+		agreementCRUD:   agreements,
+		agreementStatus: agreements,
+	}
+	return server, users, referrals, agreements
+}
+
+func TestHandleCreateAgreement_ConflictOnActiveAgreement(t *testing.T) {
+	server, users, referrals, _ := newAgreementTestServer(t)
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent, BrokerID: &brokerID})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "agent-1", Status: referral.StatusOpen}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+	body := `{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":25,"protectDays":30}`
+
+	rec := httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements", body, auth.RoleAgent))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements", body, auth.RoleAgent))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a second active agreement, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleCreateAgreement_RefusesAnotherReferrerBroker(t *testing.T) {
+	server, users, referrals, _ := newAgreementTestServer(t)
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent, BrokerID: &brokerID})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "agent-1", Status: referral.StatusOpen}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+
+	// b3 has no policy of its own, so its defaults would allow any terms.
+	rec := httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements",
+		`{"requestId":"ref-1","referrerBrokerId":"b3","refereeBrokerId":"b2","feeRate":99,"protectDays":3000}`, auth.RoleAgent))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleCreateAgreement_ReportsViolatedPolicyBound(t *testing.T) {
+	server, users, referrals, agreements := newAgreementTestServer(t)
+	brokers := testsupport.NewBrokers()
+	brokers.Add(broker.Profile{ID: "b1", Name: "Referrer"})
+	policy := broker.DefaultSettings("b1")
+	policy.MinFeeRate, policy.MaxFeeRate = 20, 35
+	if _, err := brokers.SaveSettings(context.Background(), policy, "admin-1"); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	agreements.WithBrokers(brokers)
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent, BrokerID: &brokerID})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "agent-1", Status: referral.StatusOpen}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements",
+		`{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":40,"protectDays":30}`, auth.RoleAgent))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got policyViolationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Code != errCodeOutsidePolicy || got.Term != "feeRate" || got.Bound != "max" || got.Limit != 35 || got.Value != 40 {
+		t.Fatalf("unexpected violation: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements",
+		`{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":30,"protectDays":30}`, auth.RoleAgent))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected terms within the policy to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleListAgreements_BrokerAdminSeesPartyAgreements(t *testing.T) {
+	server, users, referrals, agreements := newAgreementTestServer(t)
+	brokerID := "b2"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID})
+	users.Add(auth.User{ID: "other", Role: auth.RoleAgent})
+	ctx := context.Background()
+	for _, id := range []string{"ref-1", "ref-2"} {
+		if _, err := referrals.Create(ctx, nil, referral.Request{ID: id, CreatorUserID: "other"}); err != nil {
+			t.Fatalf("seed referral: %v", err)
+		}
+	}
+	party := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: brokerID}, "draft")
+	agreements.Add(agreement.Record{RequestID: "ref-2", ReferrerBrokerID: "b1", RefereeBrokerID: "b3"}, "draft")
+
+	rec := httptest.NewRecorder()
+	server.handleListAgreements(rec, agentRequest(http.MethodGet, "/api/agreements", "", auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp paginatedAgreements
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Items) != 1 || resp.Items[0].ID != party.ID {
+		t.Fatalf("expected only the agreement b2 is party to, got %+v", resp)
+	}
+}
+
+func TestHandleListAgreements_ReportsHasMore(t *testing.T) {
+	server, users, referrals, agreements := newAgreementTestServer(t)
+	brokerID := "b2"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "agent-1"}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+	agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: brokerID, RefereeBrokerID: "b3"}, "draft")
+	agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: brokerID, RefereeBrokerID: "b4"}, "draft")
+
+	list := func(page string) paginatedAgreements {
+		rec := httptest.NewRecorder()
+		server.handleListAgreements(rec, agentRequest(http.MethodGet, "/api/agreements?pageSize=1&page="+page, "", auth.RoleBrokerAdmin))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp paginatedAgreements
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+	if first := list("1"); !first.HasMore || !first.TotalExact || first.Total != 2 {
+		t.Fatalf("expected a second page after the first, got %+v", first)
+	}
+	if last := list("2"); last.HasMore || len(last.Items) != 1 {
+		t.Fatalf("expected the second page to be the last, got %+v", last)
+	}
+}
+
+func TestHandleListAgreements_Filters(t *testing.T) {
+	server, users, referrals, agreements := newAgreementTestServer(t)
+	const (
+		own   = "0b6a5c1e-1111-4c1d-9e3f-0a1b2c3d4e5f"
+		other = "0b6a5c1e-2222-4c1d-9e3f-0a1b2c3d4e5f"
+		ref1  = "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f70"
+		ref2  = "6f1c7d1e-8a4b-4c1e-9f1a-2b3c4d5e6f71"
+	)
+	brokerID := own
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID})
+	for _, id := range []string{ref1, ref2} {
+		if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: id, CreatorUserID: "agent-1"}); err != nil {
+			t.Fatalf("seed referral: %v", err)
+		}
+	}
+	effectiveAt := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	effective := agreements.Add(agreement.Record{RequestID: ref1, ReferrerBrokerID: own, RefereeBrokerID: other, EffectiveAt: &effectiveAt}, "effective")
+	agreements.Add(agreement.Record{RequestID: ref2, ReferrerBrokerID: own, RefereeBrokerID: "0b6a5c1e-3333-4c1d-9e3f-0a1b2c3d4e5f"}, "draft")
+
+	list := func(query string, want int) paginatedAgreements {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		server.handleListAgreements(rec, agentRequest(http.MethodGet, "/api/agreements?"+query, "", auth.RoleBrokerAdmin))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", query, want, rec.Code, rec.Body.String())
+		}
+		var resp paginatedAgreements
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+	for _, query := range []string{
+		"status=effective,disputed",
+		"counterpartyBrokerId=" + other,
+		"referralId=" + ref1,
+		"effectiveFrom=2026-02-01&effectiveTo=2026-03-01",
+	} {
+		resp := list(query, http.StatusOK)
+		if len(resp.Items) != 1 || resp.Items[0].ID != effective.ID || resp.Items[0].Status != "effective" {
+			t.Fatalf("%s: expected only the effective agreement, got %+v", query, resp.Items)
+		}
+	}
+	if resp := list("effectiveTo=2026-02-01", http.StatusOK); len(resp.Items) != 0 {
+		t.Fatalf("expected nothing effective before February, got %+v", resp.Items)
+	}
+
+	for _, query := range []string{"status=archived", "createdFrom=yesterday", "referralId=ref-1", "createdFrom=2026-03-01&createdTo=2026-02-01"} {
+		list(query, http.StatusBadRequest)
+	}
+}
+
+func TestHandleAgreements_ReportStatus(t *testing.T) {
+	server, users, referrals, _ := newAgreementTestServer(t)
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent, BrokerID: &brokerID})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "agent-1", Status: referral.StatusOpen}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements",
+		`{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":25,"protectDays":30}`, auth.RoleAgent))
+	var created agreementResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.Status != "draft" || created.StatusUpdatedAt == "" || created.StatusUpdatedBy != nil {
+		t.Fatalf("expected a draft set by the system, got %+v", created)
+	}
+
+	req := agentRequest(http.MethodPatch, "/api/agreements", `{"agreementId":"`+created.ID+`","nextStatus":"pending_signature"}`, auth.RoleAgent)
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	server.handleUpdateAgreementStatus(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleListAgreements(rec, agentRequest(http.MethodGet, "/api/agreements", "", auth.RoleAgent))
+	var resp paginatedAgreements
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Items) != 1 {
+		t.Fatalf("expected one agreement, got %+v", resp)
+	}
+	if got := resp.Items[0]; got.Status != "pending_signature" || got.StatusUpdatedBy == nil || *got.StatusUpdatedBy != "agent-1" {
+		t.Fatalf("expected the transition and its actor, got %+v", got)
+	}
+}
+
+func TestHandleUpdateAgreementStatus_RequiresCurrentIfMatch(t *testing.T) {
+	server, _, _, agreements := newAgreementTestServer(t)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "draft")
+	body := `{"agreementId":"` + rec.ID + `","nextStatus":"pending_signature"}`
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		req := agentRequest(http.MethodPatch, "/api/agreements", body, auth.RoleBrokerAdmin)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		server.handleUpdateAgreementStatus(w, req)
+		return w
+	}
+
+	if w := patch(""); w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428 without If-Match, got %d", w.Code)
+	}
+
+	w := patch(`"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	// A second admin still holding version 1 must not overwrite the first.
+	w = patch(`"1"`)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 412 with ETag \"2\", got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+	var conflict agreementConflictResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if conflict.Status != "pending_signature" || conflict.Version != 2 {
+		t.Fatalf("expected the current status and version, got %+v", conflict)
+	}
+	if w := patch(`W/"2"`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected weak ETags never to match, got %d", w.Code)
+	}
+}
+
+func TestHandleCreateAgreement_MapsReferralErrors(t *testing.T) {
+	server, users, referrals, _ := newAgreementTestServer(t)
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{ID: "ref-1", CreatorUserID: "someone-else", Status: referral.StatusOpen}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.handleCreateAgreement(rec, agentRequest(http.MethodPost, "/api/agreements", body, auth.RoleAgent))
+		return rec
+	}
+
+	if rec := create(`{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":25,"protectDays":30}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 on someone else's referral, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := create(`{"requestId":"ref-9","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":25,"protectDays":30}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown referral, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := create(`{"requestId":"ref-1","referrerBrokerId":"b1","refereeBrokerId":"b2","feeRate":-1,"protectDays":30}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative fee rate, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleUpdateAgreementStatus_MapsTransitionErrors(t *testing.T) {
+	server, _, _, agreements := newAgreementTestServer(t)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "draft")
+	patch := func(agreementID, next string) *httptest.ResponseRecorder {
+		req := agentRequest(http.MethodPatch, "/api/agreements", `{"agreementId":"`+agreementID+`","nextStatus":"`+next+`"}`, auth.RoleBrokerAdmin)
+		req.Header.Set("If-Match", `"1"`)
+		w := httptest.NewRecorder()
+		server.handleUpdateAgreementStatus(w, req)
+		return w
+	}
+
+	if w := patch(rec.ID, "closed"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for draft -> closed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patch(rec.ID, "bogus"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown status, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patch("missing", "pending_signature"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown agreement, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"github.com/google/uuid"
+)
+
+type amendmentService interface {
+	Propose(ctx context.Context, params agreement.ProposeAmendmentParams) (agreement.Amendment, error)
+	Respond(ctx context.Context, params agreement.RespondAmendmentParams) (agreement.Amendment, error)
+	List(ctx context.Context, agreementID, actorID string) ([]agreement.Amendment, error)
+}
+
+type proposeAmendmentRequest struct {
+	FeeRate     float64 `json:"feeRate"`
+	ProtectDays int     `json:"protectDays"`
+	Note        *string `json:"note,omitempty"`
+}
+
+type respondAmendmentRequest struct {
+	Status string `json:"status"`
+}
+
+type amendmentResponse struct {
+	ID                 string  `json:"id"`
+	AgreementID        string  `json:"agreementId"`
+	ProposedByUserID   string  `json:"proposedByUserId"`
+	ProposedByBrokerID string  `json:"proposedByBrokerId"`
+	FeeRate            float64 `json:"feeRate"`
+	ProtectDays        int     `json:"protectDays"`
+	Note               *string `json:"note,omitempty"`
+	Status             string  `json:"status"`
+	RespondedByUserID  *string `json:"respondedByUserId,omitempty"`
+	RespondedAt        string  `json:"respondedAt,omitempty"`
+	CreatedAt          string  `json:"createdAt"`
+}
+
+type amendmentListResponse struct {
+	Items []amendmentResponse `json:"items"`
+}
+
+func newAmendmentResponse(a agreement.Amendment) amendmentResponse {
+	var responded string
+	if a.RespondedAt != nil {
+		responded = a.RespondedAt.UTC().Format(time.RFC3339)
+	}
+	return amendmentResponse{
+		ID:                 a.ID,
+		AgreementID:        a.AgreementID,
+		ProposedByUserID:   a.ProposedByUserID,
+		ProposedByBrokerID: a.ProposedByBrokerID,
+		FeeRate:            a.FeeRate,
+		ProtectDays:        a.ProtectDays,
+		Note:               a.Note,
+		Status:             string(a.Status),
+		RespondedByUserID:  a.RespondedByUserID,
+		RespondedAt:        responded,
+		CreatedAt:          a.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// respondAmendmentError maps amendment service errors to HTTP statuses.
+// Callers outside the agreement get 404 so its existence is not revealed.
+func respondAmendmentError(w http.ResponseWriter, err error) {
+	respondServiceError(w, err, "Failed to process amendment")
+}
+
+// handleListAmendments returns every amendment proposed on an agreement.
+func (s *Server) handleListAmendments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	items, err := s.amendmentService.List(ctx, agreementID, userID)
+	if err != nil {
+		respondAmendmentError(w, err)
+		return
+	}
+	out := make([]amendmentResponse, 0, len(items))
+	for _, item := range items {
+		out = append(out, newAmendmentResponse(item))
+	}
+	respondJSON(w, http.StatusOK, amendmentListResponse{Items: out})
+}
+
+// handleProposeAmendment opens new terms on a pending_signature agreement.
+func (s *Server) handleProposeAmendment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	var req proposeAmendmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	am, err := s.amendmentService.Propose(ctx, agreement.ProposeAmendmentParams{
+		AgreementID: agreementID,
+		ActorID:     userID,
+		FeeRate:     req.FeeRate,
+		ProtectDays: req.ProtectDays,
+		Note:        req.Note,
+	})
+	if err != nil {
+		respondAmendmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, newAmendmentResponse(am))
+}
+
+// handleRespondAmendment lets the counterparty accept or reject an amendment.
+func (s *Server) handleRespondAmendment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID, amendmentID := r.PathValue("id"), r.PathValue("amendmentId")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	if _, err := uuid.Parse(amendmentID); err != nil {
+		respondError(w, http.StatusNotFound, "Amendment not found")
+		return
+	}
+	var req respondAmendmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	am, err := s.amendmentService.Respond(ctx, agreement.RespondAmendmentParams{
+		AgreementID: agreementID,
+		AmendmentID: amendmentID,
+		ActorID:     userID,
+		Decision:    agreement.AmendmentStatus(req.Status),
+	})
+	if err != nil {
+		respondAmendmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, newAmendmentResponse(am))
+}
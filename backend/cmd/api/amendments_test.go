@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+)
+
+const (
+	testAgreementID = "8a3c1c1e-5d8f-4c55-9b52-0c8c6f7f2a01"
+	testAmendmentID = "0f6c7a52-2f44-4a5e-9a0e-6a8b1d2c3e4f"
+)
+
+type stubAmendmentService struct {
+	proposed  agreement.ProposeAmendmentParams
+	responded agreement.RespondAmendmentParams
+	err       error
+}
+
+func (s *stubAmendmentService) Propose(_ context.Context, params agreement.ProposeAmendmentParams) (agreement.Amendment, error) {
+	s.proposed = params
+	if s.err != nil {
+		return agreement.Amendment{}, s.err
+	}
+	return agreement.Amendment{ID: testAmendmentID, AgreementID: params.AgreementID, ProposedByUserID: params.ActorID,
+		FeeRate: params.FeeRate, ProtectDays: params.ProtectDays, Status: agreement.AmendmentProposed, CreatedAt: time.Now()}, nil
+}
+
+func (s *stubAmendmentService) Respond(_ context.Context, params agreement.RespondAmendmentParams) (agreement.Amendment, error) {
+	s.responded = params
+	if s.err != nil {
+		return agreement.Amendment{}, s.err
+	}
+	return agreement.Amendment{ID: params.AmendmentID, AgreementID: params.AgreementID, Status: params.Decision, CreatedAt: time.Now()}, nil
+}
+
+func (s *stubAmendmentService) List(_ context.Context, _, _ string) ([]agreement.Amendment, error) {
+	return nil, s.err
+}
+
+func serveAmendments(server *Server, method, path, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/agreements/{id}/amendments", server.handleListAmendments)
+	mux.HandleFunc("POST /api/agreements/{id}/amendments", server.handleProposeAmendment)
+	mux.HandleFunc("PATCH /api/agreements/{id}/amendments/{amendmentId}", server.handleRespondAmendment)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleProposeAmendment_Created(t *testing.T) {
+	svc := &stubAmendmentService{}
+	rec := serveAmendments(&Server{amendmentService: svc}, http.MethodPost,
+		"/api/agreements/"+testAgreementID+"/amendments", `{"feeRate":25,"protectDays":120}`)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.proposed.AgreementID != testAgreementID || svc.proposed.ActorID != "agent-1" || svc.proposed.FeeRate != 25 || svc.proposed.ProtectDays != 120 {
+		t.Fatalf("unexpected params: %+v", svc.proposed)
+	}
+	var resp amendmentResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != "proposed" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleProposeAmendment_MapsServiceErrors(t *testing.T) {
+	cases := map[error]int{
+		agreement.ErrNotParty:               http.StatusNotFound,
+		agreement.ErrAmendmentInvalidTerms:  http.StatusBadRequest,
+		agreement.ErrAmendmentNotNegotiable: http.StatusConflict,
+		agreement.ErrAmendmentOpen:          http.StatusConflict,
+	}
+	for err, want := range cases {
+		rec := serveAmendments(&Server{amendmentService: &stubAmendmentService{err: err}}, http.MethodPost,
+			"/api/agreements/"+testAgreementID+"/amendments", `{"feeRate":25,"protectDays":120}`)
+		if rec.Code != want {
+			t.Errorf("%v: expected %d, got %d", err, want, rec.Code)
+		}
+	}
+}
+
+func TestHandleRespondAmendment_PassesDecision(t *testing.T) {
+	svc := &stubAmendmentService{}
+	rec := serveAmendments(&Server{amendmentService: svc}, http.MethodPatch,
+		"/api/agreements/"+testAgreementID+"/amendments/"+testAmendmentID, `{"status":"accepted"}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.responded.AmendmentID != testAmendmentID || svc.responded.Decision != agreement.AmendmentAccepted {
+		t.Fatalf("unexpected params: %+v", svc.responded)
+	}
+}
+
+func TestHandleRespondAmendment_OwnProposalForbidden(t *testing.T) {
+	rec := serveAmendments(&Server{amendmentService: &stubAmendmentService{err: agreement.ErrAmendmentOwnProposal}}, http.MethodPatch,
+		"/api/agreements/"+testAgreementID+"/amendments/"+testAmendmentID, `{"status":"accepted"}`)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestHandleListAmendments_InvalidAgreementID(t *testing.T) {
+	rec := serveAmendments(&Server{amendmentService: &stubAmendmentService{}}, http.MethodGet, "/api/agreements/not-a-uuid/amendments", "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
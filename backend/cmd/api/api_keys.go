@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+type apiKeyService interface {
+	Create(ctx context.Context, userID, name string, scopes []auth.APIKeyScope) (auth.APIKey, string, error)
+	Authenticate(ctx context.Context, secret string) (auth.APIKey, auth.User, error)
+	List(ctx context.Context, userID string) ([]auth.APIKey, error)
+	Revoke(ctx context.Context, userID, keyID string) (auth.APIKey, error)
+}
+
+// apiKeyResources maps the first path segment under /api/ to the resource
+// half of the scope an API key needs. Anything not listed, such as /api/me,
+// key management and admin routes, cannot be called with an API key.
+var apiKeyResources = map[string]string{
+	"referrals":   "referrals",
+	"matches":     "referrals",
+	"marketplace": "referrals",
+	"agreements":  "agreements",
+	"events":      "agreements",
+	"disputes":    "disputes",
+	"brokers":     "brokers",
+}
+
+// apiKeyScopeFor returns the scope an API key needs to call method on path:
+// reads need <resource>:read, everything else <resource>:write.
+func apiKeyScopeFor(method, path string) (auth.APIKeyScope, bool) {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return "", false
+	}
+	segment, _, _ := strings.Cut(rest, "/")
+	resource, ok := apiKeyResources[segment]
+	if !ok {
+		return "", false
+	}
+	access := "write"
+	if method == http.MethodGet || method == http.MethodHead {
+		access = "read"
+	}
+	return auth.APIKeyScope(resource + ":" + access), true
+}
+
+// graphQLKeyScopes are what an API key needs for POST /api/graphql, whose
+// queries read referrals, agreements and disputes alike.
+var graphQLKeyScopes = []auth.APIKeyScope{auth.ScopeReferralsRead, auth.ScopeAgreementsRead, auth.ScopeDisputesRead}
+
+// apiKeyScopesFor is apiKeyScopeFor for routes that may need several scopes.
+func apiKeyScopesFor(method, path string) ([]auth.APIKeyScope, bool) {
+	if path == "/api/graphql" {
+		return graphQLKeyScopes, true
+	}
+	scope, ok := apiKeyScopeFor(method, path)
+	if !ok {
+		return nil, false
+	}
+	return []auth.APIKeyScope{scope}, true
+}
+
+// apiKeyAuth authenticates a request carrying X-API-Key as the key's owner,
+// provided the key holds the scopes the route requires.
+func (s *Server) apiKeyAuth(w http.ResponseWriter, r *http.Request, secret string, next http.HandlerFunc) {
+	scopes, ok := apiKeyScopesFor(r.Method, r.URL.Path)
+	if !ok {
+		respondError(w, http.StatusForbidden, "Endpoint not available to API keys")
+		return
+	}
+	if s.apiKeys == nil {
+		respondError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.For(routeDefault))
+	key, user, err := s.apiKeys.Authenticate(ctx, secret)
+	cancel()
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			respondError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to verify API key")
+		return
+	}
+	for _, scope := range scopes {
+		if !key.Allows(scope) {
+			respondError(w, http.StatusForbidden, "API key lacks scope "+string(scope))
+			return
+		}
+	}
+
+	rctx := context.WithValue(r.Context(), ctxKeyUserID, user.ID)
+	rctx = context.WithValue(rctx, ctxKeyRole, user.Role)
+	rctx = context.WithValue(rctx, ctxKeyPermissions, auth.RolePermissions(user.Role))
+	if user.BrokerID != nil {
+		rctx = context.WithValue(rctx, ctxKeyBrokerID, *user.BrokerID)
+	}
+	next(w, r.WithContext(rctx))
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type apiKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"createdAt"`
+	LastUsedAt *string  `json:"lastUsedAt,omitempty"`
+	RevokedAt  *string  `json:"revokedAt,omitempty"`
+}
+
+// createdAPIKeyResponse carries the secret, which is only ever returned here.
+type createdAPIKeyResponse struct {
+	apiKeyResponse
+	Key string `json:"key"`
+}
+
+type apiKeyListResponse struct {
+	Items []apiKeyResponse `json:"items"`
+}
+
+func newAPIKeyResponse(k auth.APIKey) apiKeyResponse {
+	resp := apiKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    make([]string, len(k.Scopes)),
+		CreatedAt: k.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for i, scope := range k.Scopes {
+		resp.Scopes[i] = string(scope)
+	}
+	if k.LastUsedAt != nil {
+		val := k.LastUsedAt.UTC().Format(time.RFC3339)
+		resp.LastUsedAt = &val
+	}
+	if k.RevokedAt != nil {
+		val := k.RevokedAt.UTC().Format(time.RFC3339)
+		resp.RevokedAt = &val
+	}
+	return resp
+}
+
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	keys, err := s.apiKeys.List(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load API keys")
+		return
+	}
+	items := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, newAPIKeyResponse(k))
+	}
+	respondJSON(w, http.StatusOK, apiKeyListResponse{Items: items})
+}
+
+// handleCreateAPIKey issues a key acting as the caller. The secret is in the
+// response body and cannot be retrieved again.
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req createAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	scopes := make([]auth.APIKeyScope, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopes[i] = auth.APIKeyScope(scope)
+	}
+
+	ctx := r.Context()
+
+	key, secret, err := s.apiKeys.Create(ctx, userID, req.Name, scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrAPIKeyNameRequired), errors.Is(err, auth.ErrInvalidAPIKeyScope):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to create API key")
+		}
+		return
+	}
+	respondJSON(w, http.StatusCreated, createdAPIKeyResponse{apiKeyResponse: newAPIKeyResponse(key), Key: secret})
+}
+
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	keyID := r.PathValue("id")
+	if _, err := uuid.Parse(keyID); err != nil {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	key, err := s.apiKeys.Revoke(ctx, userID, keyID)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			respondError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	respondJSON(w, http.StatusOK, newAPIKeyResponse(key))
+}
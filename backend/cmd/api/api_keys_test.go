@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+)
+
+type stubAPIKeyService struct {
+	key       auth.APIKey
+	user      auth.User
+	secret    string
+	authErr   error
+	createErr error
+	revokeErr error
+}
+
+func (s *stubAPIKeyService) Create(_ context.Context, userID, name string, scopes []auth.APIKeyScope) (auth.APIKey, string, error) {
+	if s.createErr != nil {
+		return auth.APIKey{}, "", s.createErr
+	}
+	return auth.APIKey{ID: "key-1", UserID: userID, Name: name, Prefix: "bfk_0000", Scopes: scopes, CreatedAt: time.Now()}, s.secret, nil
+}
+
+func (s *stubAPIKeyService) Authenticate(_ context.Context, secret string) (auth.APIKey, auth.User, error) {
+	if s.authErr != nil || secret != s.secret {
+		return auth.APIKey{}, auth.User{}, auth.ErrInvalidAPIKey
+	}
+	return s.key, s.user, nil
+}
+
+func (s *stubAPIKeyService) List(_ context.Context, _ string) ([]auth.APIKey, error) {
+	return []auth.APIKey{s.key}, nil
+}
+
+func (s *stubAPIKeyService) Revoke(_ context.Context, _, _ string) (auth.APIKey, error) {
+	return s.key, s.revokeErr
+}
+
+func TestAPIKeyScopeFor(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         auth.APIKeyScope
+		ok           bool
+	}{
+		{http.MethodGet, "/api/referrals", auth.ScopeReferralsRead, true},
+		{http.MethodPost, "/api/referrals/abc/matches", auth.ScopeReferralsWrite, true},
+		{http.MethodGet, "/api/matches", auth.ScopeReferralsRead, true},
+		{http.MethodPost, "/api/marketplace/referrals/r1/apply", auth.ScopeReferralsWrite, true},
+		{http.MethodPost, "/api/agreements/{id}/events", auth.ScopeAgreementsWrite, true},
+		{http.MethodGet, "/api/events", auth.ScopeAgreementsRead, true},
+		{http.MethodPatch, "/api/disputes/d1", auth.ScopeDisputesWrite, true},
+		{http.MethodGet, "/api/brokers/b1/settings", auth.ScopeBrokersRead, true},
+		{http.MethodGet, "/api/me", "", false},
+		{http.MethodDelete, "/api/me", "", false},
+		{http.MethodPost, "/api/api-keys", "", false},
+		{http.MethodGet, "/api/admin/topics", "", false},
+		{http.MethodGet, "/ws", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := apiKeyScopeFor(tc.method, tc.path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s %s: got (%q, %v), want (%q, %v)", tc.method, tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	keys := &stubAPIKeyService{
+		secret: "bfk_0000_secret",
+		key:    auth.APIKey{ID: "key-1", UserID: "user-1", Scopes: []auth.APIKeyScope{auth.ScopeReferralsRead}},
+		user:   auth.User{ID: "user-1", Role: auth.RoleBrokerAdmin},
+	}
+	server := &Server{apiKeys: keys}
+
+	var gotUser string
+	var gotRole auth.Role
+	handler := server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = r.Context().Value(ctxKeyUserID).(string)
+		gotRole, _ = r.Context().Value(ctxKeyRole).(auth.Role)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name, method, path, key string
+		want                    int
+	}{
+		{"scoped read", http.MethodGet, "/api/referrals", keys.secret, http.StatusNoContent},
+		{"missing scope", http.MethodPost, "/api/referrals", keys.secret, http.StatusForbidden},
+		{"endpoint not exposed", http.MethodGet, "/api/me", keys.secret, http.StatusForbidden},
+		{"graphql without agreement and dispute scopes", http.MethodPost, "/api/graphql", keys.secret, http.StatusForbidden},
+		{"unknown key", http.MethodGet, "/api/referrals", "bfk_0000_wrong", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotUser, gotRole = "", ""
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(auth.APIKeyHeader, tc.key)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			if tc.want == http.StatusNoContent && (gotUser != "user-1" || gotRole != auth.RoleBrokerAdmin) {
+				t.Fatalf("expected key owner in context, got %q/%q", gotUser, gotRole)
+			}
+		})
+	}
+}
+
+func TestHandleCreateAPIKey(t *testing.T) {
+	server := &Server{apiKeys: &stubAPIKeyService{secret: "bfk_0000_secret"}}
+
+	body := `{"name":"CRM","scopes":["referrals:read"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/api-keys", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "user-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleCreateAPIKey(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp createdAPIKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Key != "bfk_0000_secret" || resp.Name != "CRM" || len(resp.Scopes) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleRevokeAPIKey_NotFound(t *testing.T) {
+	server := &Server{apiKeys: &stubAPIKeyService{revokeErr: auth.ErrAPIKeyNotFound}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /api/api-keys/{id}", server.handleRevokeAPIKey)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/api-keys/"+testAgreementID, nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "user-1"))
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+)
+
+// handleRegister 处理用户注册
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req auth.RegisterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := s.authService.Register(ctx, req)
+	if err != nil {
+		log.Printf("Register error: %v", err)
+		if err == auth.ErrDuplicateEmail {
+			respondError(w, http.StatusConflict, "Email already exists")
+			return
+		}
+		if errors.Is(err, auth.ErrWeakPassword) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Registration failed")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, registerResponse{
+		User: newAgentResponse(*user),
+	})
+}
+
+// handleLogin 处理用户登录
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req auth.LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.RemoteIP = clientIP(r)
+	req.UserAgent = r.UserAgent()
+
+	ctx := r.Context()
+
+	resp, err := s.authService.Login(ctx, req)
+	if err != nil {
+		if respondLockout(w, err) {
+			return
+		}
+		if err == auth.ErrInvalidCredentials {
+			respondError(w, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+
+	if resp.ChallengeToken != "" {
+		respondJSON(w, http.StatusAccepted, twoFactorChallengeResponse{TwoFactorRequired: true, ChallengeToken: resp.ChallengeToken})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, loginResponse{
+		Token: resp.Token,
+		User:  newAgentResponse(resp.User),
+	})
+}
+
+// handleMe 获取当前用户信息
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, newAgentResponse(*user))
+}
+
+// authMiddleware JWT 认证中间件；未携带 Authorization 时接受 X-API-Key（按 scope 限制可调用的接口）
+func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if key := r.Header.Get(auth.APIKeyHeader); key != "" && authHeader == "" {
+			s.apiKeyAuth(w, r, key, next)
+			return
+		}
+		if authHeader == "" {
+			respondError(w, http.StatusUnauthorized, "Missing authorization header")
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			respondError(w, http.StatusUnauthorized, "Invalid authorization header")
+			return
+		}
+
+		token := parts[1]
+		identity, err := s.authService.VerifyToken(r.Context(), token)
+		if err != nil {
+			respondError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		// 角色或所属经纪公司变更后按当前权限处理请求，并通过响应头下发新 token
+		identity, refreshed, err := s.authService.Refresh(r.Context(), identity)
+		if err != nil {
+			if errors.Is(err, auth.ErrUserNotFound) {
+				respondError(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to verify token")
+			return
+		}
+		if refreshed != "" {
+			w.Header().Set(auth.RefreshedTokenHeader, refreshed)
+		}
+
+		ctx := context.WithValue(r.Context(), ctxKeyUserID, identity.UserID)
+		ctx = context.WithValue(ctx, ctxKeyRole, identity.Role)
+		ctx = context.WithValue(ctx, ctxKeySessionID, identity.SessionID)
+		ctx = context.WithValue(ctx, ctxKeyBrokerID, identity.BrokerID)
+		ctx = context.WithValue(ctx, ctxKeyPermissions, identity.Permissions)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// can reports whether the caller holds p: the permissions the auth
+// middleware put in ctx, or the role's for contexts built without them.
+func can(ctx context.Context, p auth.Permission) bool {
+	perms, ok := ctx.Value(ctxKeyPermissions).([]auth.Permission)
+	if !ok {
+		role, _ := ctx.Value(ctxKeyRole).(auth.Role)
+		perms = auth.RolePermissions(role)
+	}
+	return slices.Contains(perms, p)
+}
+
+type registerResponse struct {
+	User agentResponse `json:"user"`
+}
+
+type loginResponse struct {
+	Token string        `json:"token"`
+	User  agentResponse `json:"user"`
+}
+
+type agentResponse struct {
+	ID        string    `json:"id"`
+	FullName  string    `json:"fullName"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Languages []string  `json:"languages"`
+	BrokerID  string    `json:"brokerId"`
+	Rating    float64   `json:"rating"`
+	Role      auth.Role `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func newAgentResponse(u auth.User) agentResponse {
+	phone := ""
+	if u.Phone != nil {
+		phone = *u.Phone
+	}
+	brokerID := ""
+	if u.BrokerID != nil {
+		brokerID = *u.BrokerID
+	}
+
+	return agentResponse{
+		ID:        u.ID,
+		FullName:  u.FullName,
+		Email:     u.Email,
+		Phone:     phone,
+		Languages: append([]string(nil), u.Languages...),
+		BrokerID:  brokerID,
+		Rating:    u.Rating,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthMiddleware_DemotedAdminLosesPowers(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("supersafe"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	brokerID := "broker-1"
+	users := &stubUserRepo{users: map[string]auth.User{
+		"admin-1": {ID: "admin-1", Email: "ada@example.com", PasswordHash: string(hash), Role: auth.RoleBrokerAdmin, BrokerID: &brokerID},
+	}}
+	server := &Server{authService: auth.NewService(users, "secret")}
+	login, err := server.authService.Login(context.Background(), auth.LoginRequest{Email: "ada@example.com", Password: "supersafe"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	var manage, write bool
+	var claimedBroker string
+	handler := server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		manage = can(r.Context(), auth.PermBrokerManage)
+		write = can(r.Context(), auth.PermReferralsWrite)
+		claimedBroker, _ = r.Context().Value(ctxKeyBrokerID).(string)
+	})
+	call := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := call(login.Token)
+	if rec.Code != http.StatusOK || !manage || claimedBroker != brokerID || rec.Header().Get(auth.RefreshedTokenHeader) != "" {
+		t.Fatalf("admin token: code %d manage %v broker %q refreshed %q", rec.Code, manage, claimedBroker, rec.Header().Get(auth.RefreshedTokenHeader))
+	}
+
+	demoted := users.users["admin-1"]
+	demoted.Role = auth.RoleAgent
+	users.users["admin-1"] = demoted
+
+	rec = call(login.Token)
+	refreshed := rec.Header().Get(auth.RefreshedTokenHeader)
+	if rec.Code != http.StatusOK || manage || !write || refreshed == "" {
+		t.Fatalf("demoted with old token: code %d manage %v write %v refreshed %q", rec.Code, manage, write, refreshed)
+	}
+	rec = call(refreshed)
+	if rec.Code != http.StatusOK || manage || rec.Header().Get(auth.RefreshedTokenHeader) != "" {
+		t.Fatalf("re-minted token: code %d manage %v refreshed %q", rec.Code, manage, rec.Header().Get(auth.RefreshedTokenHeader))
+	}
+
+	delete(users.users, "admin-1")
+	if rec = call(refreshed); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("deleted user: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestCanFallsBackToRole(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKeyRole, auth.RoleBrokerAdmin)
+	if !can(ctx, auth.PermOutboxAdmin) {
+		t.Fatal("broker_admin role without permissions in context should hold outbox:admin")
+	}
+	ctx = context.WithValue(ctx, ctxKeyPermissions, []auth.Permission{auth.PermReferralsWrite})
+	if can(ctx, auth.PermOutboxAdmin) {
+		t.Fatal("permissions in context must win over the role")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultMaxBodyBytes caps the body of routes missing from
+	// routeBodyLimits. The JSON bodies clients send are a few kilobytes.
+	defaultMaxBodyBytes int64 = 64 << 10
+	// authMaxBodyBytes caps the bodies anyone can send without signing in.
+	authMaxBodyBytes int64 = 8 << 10
+
+	// errCodeBodyTooLarge marks the 413 returned for a body over its
+	// route's limit.
+	errCodeBodyTooLarge = "request_body_too_large"
+	// errCodeUnknownField and errCodeInvalidField mark a 400 about one
+	// field of a JSON body, named in the response's field.
+	errCodeUnknownField = "unknown_field"
+	errCodeInvalidField = "invalid_field"
+)
+
+// routeBodyLimits lists the mux patterns whose body limit is not
+// defaultMaxBodyBytes.
+var routeBodyLimits = map[string]int64{
+	"POST /auth/register":                   authMaxBodyBytes,
+	"POST /auth/register/invitation":        authMaxBodyBytes,
+	"POST /auth/login":                      authMaxBodyBytes,
+	"POST /auth/login/2fa":                  authMaxBodyBytes,
+	"POST /auth/oidc/{provider}/callback":   authMaxBodyBytes,
+	"POST /api/referrals/import":            maxImportBytes,
+	"POST /api/referrals/{id}/matches/bulk": 256 << 10,
+	"POST /api/referrals/{id}/files":        maxUploadBytes,
+	"POST /api/agreements/{id}/files":       maxUploadBytes,
+	"POST /api/disputes/{id}/files":         maxUploadBytes,
+}
+
+func maxBodyBytes(pattern string) int64 {
+	if limit, ok := routeBodyLimits[pattern]; ok {
+		return limit
+	}
+	return defaultMaxBodyBytes
+}
+
+// bodyLimitMiddleware caps each request body at its route's limit. A body
+// whose Content-Length is already over the limit is refused with a 413
+// before the handler runs; a longer body without one fails the handler's
+// read, which decodeJSON turns into the same 413.
+func bodyLimitMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		limit := maxBodyBytes(pattern)
+		if r.ContentLength > limit {
+			respondBodyTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the request body into dst, refusing fields dst does
+// not have and anything after the first JSON value, so a misspelt field is
+// an error instead of being silently dropped. On failure it writes a 400 or
+// 413 and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := readJSON(r.Body, dst); err != nil {
+		respondBodyError(w, err)
+		return false
+	}
+	return true
+}
+
+// readJSON is decodeJSON without the response, for handlers whose body is
+// optional: it returns io.EOF for an empty body.
+func readJSON(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		if err == nil {
+			err = errors.New("json: data after the request body")
+		}
+		return err
+	}
+	return nil
+}
+
+// respondBodyError maps a readJSON error to its response.
+func respondBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var badType *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondBodyTooLarge(w, tooLarge.Limit)
+	case errors.As(err, &badType) && badType.Field != "":
+		respondFieldError(w, errCodeInvalidField, "Invalid value for field "+badType.Field, badType.Field)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields.
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		respondFieldError(w, errCodeUnknownField, "Unknown field "+field, field)
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+	}
+}
+
+func respondBodyTooLarge(w http.ResponseWriter, limit int64) {
+	size := strconv.FormatInt(limit, 10)
+	message := fmt.Sprintf("Request body exceeds %s bytes", size)
+	log.Printf("HTTP error: status=%d message=%s", http.StatusRequestEntityTooLarge, message)
+	respondJSON(w, http.StatusRequestEntityTooLarge, errorResponse{
+		Message: localizedMessage(w, errCodeBodyTooLarge, message, "limit", size),
+		Code:    errCodeBodyTooLarge,
+	})
+}
+
+func respondFieldError(w http.ResponseWriter, code, message, field string) {
+	log.Printf("HTTP error: status=%d message=%s", http.StatusBadRequest, message)
+	respondJSON(w, http.StatusBadRequest, errorResponse{
+		Message: localizedMessage(w, code, message, "field", field),
+		Code:    code,
+		Field:   field,
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON_Strict(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	cases := []struct {
+		name  string
+		body  string
+		code  string
+		field string
+		want  int
+	}{
+		{"valid", `{"name":"a","count":2}`, "", "", http.StatusNoContent},
+		{"unknown field", `{"name":"a","cuont":2}`, errCodeUnknownField, "cuont", http.StatusBadRequest},
+		{"wrong type", `{"count":"two"}`, errCodeInvalidField, "count", http.StatusBadRequest},
+		{"trailing data", `{"name":"a"}{"name":"b"}`, "invalid_request_body", "", http.StatusBadRequest},
+		{"empty", ``, "invalid_request_body", "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)))
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			if tc.want == http.StatusNoContent {
+				return
+			}
+			var resp errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Code != tc.code || resp.Field != tc.field {
+				t.Fatalf("unexpected error %+v", resp)
+			}
+		})
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := localeMiddleware(bodyLimitMiddleware(mux, mux))
+	oversized := `{"email":"` + strings.Repeat("a", int(authMaxBodyBytes)) + `"}`
+
+	// Declared length over the limit: refused before the handler runs.
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(oversized))
+	req.Header.Set("Accept-Language", "fr")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var resp errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rec.Code != http.StatusRequestEntityTooLarge || resp.Code != errCodeBodyTooLarge || resp.Message != "Le corps de la requête dépasse 8192 octets" {
+		t.Fatalf("unexpected response %d %+v", rec.Code, resp)
+	}
+
+	// No declared length: the handler's read hits the limit.
+	req = httptest.NewRequest(http.MethodPost, "/auth/login", io.MultiReader(strings.NewReader(oversized)))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a streamed body, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"a@example.com"}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a small body through, got %d", rec.Code)
+	}
+
+	if maxBodyBytes("POST /api/referrals") != defaultMaxBodyBytes || maxBodyBytes("POST /api/referrals/import") != maxImportBytes {
+		t.Fatal("unexpected route limits")
+	}
+}
+
+func TestRouteBodyLimits_NameRegisteredRoutes(t *testing.T) {
+	mux := newRouteMux()
+	for pattern := range routeBodyLimits {
+		method, path, _ := strings.Cut(pattern, " ")
+		_, got := mux.Handler(httptest.NewRequest(method, path, nil))
+		if got != pattern {
+			t.Errorf("%s: no such route (matched %q)", pattern, got)
+		}
+	}
+}
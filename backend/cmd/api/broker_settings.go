@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/broker"
+)
+
+type brokerSettingsRequest struct {
+	DefaultFeeRate     float64 `json:"defaultFeeRate"`
+	MinFeeRate         float64 `json:"minFeeRate"`
+	MaxFeeRate         float64 `json:"maxFeeRate"`
+	DefaultProtectDays int     `json:"defaultProtectDays"`
+	MinProtectDays     int     `json:"minProtectDays"`
+	MaxProtectDays     int     `json:"maxProtectDays"`
+}
+
+type brokerSettingsResponse struct {
+	BrokerID           string  `json:"brokerId"`
+	DefaultFeeRate     float64 `json:"defaultFeeRate"`
+	MinFeeRate         float64 `json:"minFeeRate"`
+	MaxFeeRate         float64 `json:"maxFeeRate"`
+	DefaultProtectDays int     `json:"defaultProtectDays"`
+	MinProtectDays     int     `json:"minProtectDays"`
+	MaxProtectDays     int     `json:"maxProtectDays"`
+	UpdatedAt          string  `json:"updatedAt,omitempty"`
+}
+
+func newBrokerSettingsResponse(s broker.Settings) brokerSettingsResponse {
+	var updated string
+	if s.UpdatedAt != nil {
+		updated = s.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return brokerSettingsResponse{
+		BrokerID:           s.BrokerID,
+		DefaultFeeRate:     s.DefaultFeeRate,
+		MinFeeRate:         s.MinFeeRate,
+		MaxFeeRate:         s.MaxFeeRate,
+		DefaultProtectDays: s.DefaultProtectDays,
+		MinProtectDays:     s.MinProtectDays,
+		MaxProtectDays:     s.MaxProtectDays,
+		UpdatedAt:          updated,
+	}
+}
+
+// handleGetBrokerSettings returns a broker's referral policy. Brokers that
+// have not configured one report the application defaults.
+func (s *Server) handleGetBrokerSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := s.brokerService.GetSettings(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, broker.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Broker not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load broker settings")
+		return
+	}
+	respondJSON(w, http.StatusOK, newBrokerSettingsResponse(settings))
+}
+
+// handleUpdateBrokerSettings replaces a broker's referral policy. Only a
+// broker_admin belonging to that broker may change it.
+func (s *Server) handleUpdateBrokerSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermBrokerManage) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	var req brokerSettingsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	settings := broker.Settings{
+		BrokerID:           r.PathValue("id"),
+		DefaultFeeRate:     req.DefaultFeeRate,
+		MinFeeRate:         req.MinFeeRate,
+		MaxFeeRate:         req.MaxFeeRate,
+		DefaultProtectDays: req.DefaultProtectDays,
+		MinProtectDays:     req.MinProtectDays,
+		MaxProtectDays:     req.MaxProtectDays,
+	}
+	if err := settings.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	if user.BrokerID == nil || *user.BrokerID != settings.BrokerID {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	saved, err := s.brokerService.UpdateSettings(ctx, settings, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, broker.ErrNotFound):
+			respondError(w, http.StatusNotFound, "Broker not found")
+		case errors.Is(err, broker.ErrInvalidSettings):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to save broker settings")
+		}
+		return
+	}
+	respondJSON(w, http.StatusOK, newBrokerSettingsResponse(saved))
+}
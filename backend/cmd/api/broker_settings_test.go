@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/broker"
+)
+
+func TestHandleGetBrokerSettings_DefaultsWhenUnset(t *testing.T) {
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{profile: broker.Profile{ID: "b1"}}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers/b1/settings", nil)
+	req.SetPathValue("id", "b1")
+	rec := httptest.NewRecorder()
+
+	server.handleGetBrokerSettings(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp brokerSettingsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.BrokerID != "b1" || resp.DefaultFeeRate != broker.DefaultFeeRate || resp.DefaultProtectDays != broker.DefaultProtectDays {
+		t.Fatalf("unexpected settings: %+v", resp)
+	}
+}
+
+func TestHandleGetBrokerSettings_NotFound(t *testing.T) {
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{err: broker.ErrNotFound}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers/missing/settings", nil)
+	req.SetPathValue("id", "missing")
+	rec := httptest.NewRecorder()
+
+	server.handleGetBrokerSettings(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleUpdateBrokerSettings_RequiresBrokerAdmin(t *testing.T) {
+	server := &Server{}
+	req := httptest.NewRequest(http.MethodPut, "/api/brokers/b1/settings", strings.NewReader(`{}`))
+	req.SetPathValue("id", "b1")
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "agent-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleAgent)
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateBrokerSettings(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestHandleUpdateBrokerSettings_RejectsDefaultOutsideBounds(t *testing.T) {
+	server := &Server{}
+	body := strings.NewReader(`{"defaultFeeRate":40,"minFeeRate":10,"maxFeeRate":35,"defaultProtectDays":90,"minProtectDays":30,"maxProtectDays":180}`)
+	req := httptest.NewRequest(http.MethodPut, "/api/brokers/b1/settings", body)
+	req.SetPathValue("id", "b1")
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "admin-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleBrokerAdmin)
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateBrokerSettings(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/broker"
+)
+
+func (s *Server) handleBrokers(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if val, err := strconv.Atoi(raw); err == nil && val > 0 {
+			limit = val
+		}
+	}
+
+	ctx := r.Context()
+
+	profiles, err := s.brokerService.List(ctx, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load brokers")
+		return
+	}
+
+	items := make([]brokerResponse, 0, len(profiles))
+	for _, profile := range profiles {
+		items = append(items, newBrokerResponse(profile))
+	}
+
+	respondJSON(w, http.StatusOK, brokerListResponse{
+		Items: items,
+		Total: len(items),
+	})
+}
+
+func (s *Server) handleBroker(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	ctx := r.Context()
+
+	profile, err := s.brokerService.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, broker.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Broker not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load broker")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, newBrokerResponse(profile))
+}
+
+type brokerResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Fein      string `json:"fein"`
+	Verified  bool   `json:"verified"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type brokerListResponse struct {
+	Items []brokerResponse `json:"items"`
+	Total int              `json:"total"`
+}
+
+func newBrokerResponse(profile broker.Profile) brokerResponse {
+	return brokerResponse{
+		ID:        profile.ID,
+		Name:      profile.Name,
+		Fein:      profile.Fein,
+		Verified:  profile.Verified,
+		CreatedAt: profile.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
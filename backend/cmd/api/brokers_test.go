@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/broker"
+)
+
+type stubBrokerRepo struct {
+	profile  broker.Profile
+	profiles []broker.Profile
+	err      error
+}
+
+func (s *stubBrokerRepo) GetByID(_ context.Context, _ string) (broker.Profile, error) {
+	return s.profile, s.err
+}
+
+func (s *stubBrokerRepo) List(_ context.Context, limit int) ([]broker.Profile, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if limit <= 0 || limit > len(s.profiles) {
+		limit = len(s.profiles)
+	}
+	out := make([]broker.Profile, limit)
+	copy(out, s.profiles[:limit])
+	return out, nil
+}
+
+func TestHandleBroker_Success(t *testing.T) {
+	now := time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{
+			profile: broker.Profile{
+				ID:        "b1",
+				Name:      "Metro Realty",
+				Fein:      "12-3456789",
+				Verified:  true,
+				CreatedAt: now,
+			},
+		}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers/b1", nil)
+	req.SetPathValue("id", "b1")
+	rec := httptest.NewRecorder()
+
+	server.handleBroker(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp brokerResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	if resp.ID != "b1" || resp.Name != "Metro Realty" || !resp.Verified {
+		t.Fatalf("unexpected response payload: %+v", resp)
+	}
+	if resp.CreatedAt != now.Format(time.RFC3339) {
+		t.Fatalf("expected createdAt %s, got %s", now.Format(time.RFC3339), resp.CreatedAt)
+	}
+}
+
+func TestHandleBroker_NotFound(t *testing.T) {
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{
+			err: broker.ErrNotFound,
+		}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers/missing", nil)
+	req.SetPathValue("id", "missing")
+	rec := httptest.NewRecorder()
+
+	server.handleBroker(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleBroker_UnexpectedError(t *testing.T) {
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{
+			err: errors.New("boom"),
+		}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers/b1", nil)
+	req.SetPathValue("id", "b1")
+	rec := httptest.NewRecorder()
+
+	server.handleBroker(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+}
+
+func TestHandleBrokers_List(t *testing.T) {
+	now := time.Now().UTC()
+	server := &Server{
+		brokerService: broker.NewService(&stubBrokerRepo{
+			profiles: []broker.Profile{
+				{ID: "b1", Name: "Alpha Realty", Fein: "11-1111111", Verified: true, CreatedAt: now},
+				{ID: "b2", Name: "Beta Realty", Fein: "22-2222222", Verified: false, CreatedAt: now},
+			},
+		}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/brokers?limit=1", nil)
+	rec := httptest.NewRecorder()
+
+	server.handleBrokers(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var payload struct {
+		Items []brokerResponse `json:"items"`
+		Total int              `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode list response: %v", err)
+	}
+	if len(payload.Items) != 1 || payload.Total != 1 || payload.Items[0].ID != "b1" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
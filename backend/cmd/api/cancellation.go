@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+type cancellationService interface {
+	Cancel(ctx context.Context, params agreement.CancelParams) (agreement.Cancellation, error)
+}
+
+type cancelAgreementRequest struct {
+	Reason string `json:"reason" doc:"Required; at most 1000 characters"`
+}
+
+type cancelAgreementResponse struct {
+	AgreementID      string `json:"agreementId"`
+	ReferralID       string `json:"referralId"`
+	Status           string `json:"status"`
+	PreviousStatus   string `json:"previousStatus"`
+	Reason           string `json:"reason"`
+	CancelledBy      string `json:"cancelledBy"`
+	CancelledAt      string `json:"cancelledAt"`
+	ReferralReopened bool   `json:"referralReopened" doc:"The referral went from matched back to open"`
+}
+
+// handleCancelAgreement lets an agent or broker admin at either broker party
+// back out of an agreement that has not taken effect.
+func (s *Server) handleCancelAgreement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	var req cancelAgreementRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	c, err := s.cancellations.Cancel(ctx, agreement.CancelParams{
+		AgreementID: agreementID,
+		ActorID:     userID,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		// Callers outside the agreement get 404 so its existence is not revealed.
+		respondServiceError(w, err, "Failed to cancel agreement")
+		return
+	}
+	respondJSON(w, http.StatusOK, cancelAgreementResponse{
+		AgreementID:      c.AgreementID,
+		ReferralID:       c.ReferralID,
+		Status:           agreement.StatusCancelled,
+		PreviousStatus:   c.PreviousStatus,
+		Reason:           c.Reason,
+		CancelledBy:      c.CancelledBy,
+		CancelledAt:      c.CancelledAt.UTC().Format(time.RFC3339),
+		ReferralReopened: c.ReferralReopened,
+	})
+}
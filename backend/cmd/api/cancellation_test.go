@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+)
+
+type stubCancellations struct {
+	params agreement.CancelParams
+	err    error
+}
+
+func (s *stubCancellations) Cancel(_ context.Context, params agreement.CancelParams) (agreement.Cancellation, error) {
+	s.params = params
+	if s.err != nil {
+		return agreement.Cancellation{}, s.err
+	}
+	return agreement.Cancellation{
+		AgreementID: params.AgreementID, ReferralID: "r1", PreviousStatus: "pending_signature",
+		Reason: params.Reason, CancelledBy: params.ActorID, CancelledAt: time.Now(), ReferralReopened: true,
+	}, nil
+}
+
+func serveCancel(server *Server, id, body string, role auth.Role) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/agreements/{id}/cancel", server.handleCancelAgreement)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, agentRequest(http.MethodPost, "/api/agreements/"+id+"/cancel", body, role))
+	return rec
+}
+
+func TestHandleCancelAgreement(t *testing.T) {
+	stub := &stubCancellations{}
+	server := &Server{cancellations: stub}
+
+	rec := serveCancel(server, testAgreementID, `{"reason":"Client withdrew"}`, auth.RoleBrokerAdmin)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.params.AgreementID != testAgreementID || stub.params.ActorID != "agent-1" || stub.params.Reason != "Client withdrew" {
+		t.Fatalf("unexpected params %+v", stub.params)
+	}
+	var resp cancelAgreementResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Status != "cancelled" || !resp.ReferralReopened || resp.PreviousStatus != "pending_signature" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleCancelAgreement_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+		role auth.Role
+		err  error
+		want int
+	}{
+		{"client", testAgreementID, auth.RoleClient, nil, http.StatusForbidden},
+		{"bad id", "nope", auth.RoleAgent, nil, http.StatusNotFound},
+		{"not party", testAgreementID, auth.RoleAgent, agreement.ErrNotParty, http.StatusNotFound},
+		{"no reason", testAgreementID, auth.RoleAgent, agreement.ErrCancelReasonRequired, http.StatusBadRequest},
+		{"effective", testAgreementID, auth.RoleAgent, agreement.ErrNotCancellable, http.StatusConflict},
+		{"db", testAgreementID, auth.RoleAgent, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{cancellations: &stubCancellations{err: tc.err}}
+			rec := serveCancel(server, tc.id, `{"reason":"x"}`, tc.role)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
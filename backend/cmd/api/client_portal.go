@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/clientportal"
+	"brokerflow/tenancy"
+	"github.com/google/uuid"
+)
+
+type clientPortalService interface {
+	List(ctx context.Context, clientID string) ([]clientportal.Referral, error)
+	Get(ctx context.Context, clientID, referralID string) (clientportal.Overview, error)
+	LinkClient(ctx context.Context, scope tenancy.Scope, referralID string, clientID *string) error
+}
+
+type linkClientRequest struct {
+	ClientUserID *string `json:"clientUserId" doc:"A user with the client role; null unlinks the current client"`
+}
+
+// The client* response types are the portal's field allowlist: add a field
+// here only if a client may see it.
+type clientReferralResponse struct {
+	ID           string   `json:"id"`
+	Status       string   `json:"status" doc:"open, matched, signed, in_progress, closed or cancelled"`
+	Region       []string `json:"region"`
+	PropertyType string   `json:"propertyType"`
+	DealType     string   `json:"dealType"`
+	CreatedAt    string   `json:"createdAt"`
+	UpdatedAt    string   `json:"updatedAt"`
+}
+
+type clientReferralListResponse struct {
+	Items []clientReferralResponse `json:"items"`
+}
+
+type clientLicenseResponse struct {
+	Jurisdiction string `json:"jurisdiction"`
+	Number       string `json:"number"`
+}
+
+type clientAgentResponse struct {
+	ID          string                  `json:"id"`
+	FullName    string                  `json:"fullName"`
+	BrokerName  string                  `json:"brokerName"`
+	Languages   []string                `json:"languages"`
+	Regions     []string                `json:"regions"`
+	Licenses    []clientLicenseResponse `json:"licenses"`
+	Rating      float64                 `json:"rating"`
+	ReviewCount int                     `json:"reviewCount"`
+}
+
+type clientMilestoneResponse struct {
+	Name string `json:"name" doc:"agreement_signed, offer_made, under_contract or deal_closed"`
+	At   string `json:"at"`
+}
+
+type clientProgressResponse struct {
+	Stage      string                    `json:"stage" doc:"preparing, active, completed or ended"`
+	SignedAt   *string                   `json:"signedAt,omitempty"`
+	Milestones []clientMilestoneResponse `json:"milestones"`
+}
+
+type clientOverviewResponse struct {
+	clientReferralResponse
+	Agent    *clientAgentResponse    `json:"agent" doc:"Null until an agent accepts the referral"`
+	Progress *clientProgressResponse `json:"progress" doc:"Null until the brokers draw up an agreement"`
+}
+
+func newClientReferralResponse(ref clientportal.Referral) clientReferralResponse {
+	return clientReferralResponse{
+		ID:           ref.ID,
+		Status:       ref.Status,
+		Region:       ref.Region,
+		PropertyType: ref.PropertyType,
+		DealType:     ref.DealType,
+		CreatedAt:    ref.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:    ref.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func newClientOverviewResponse(o clientportal.Overview) clientOverviewResponse {
+	resp := clientOverviewResponse{clientReferralResponse: newClientReferralResponse(o.Referral)}
+	if a := o.Agent; a != nil {
+		licenses := make([]clientLicenseResponse, 0, len(a.Licenses))
+		for _, l := range a.Licenses {
+			licenses = append(licenses, clientLicenseResponse{Jurisdiction: l.Jurisdiction, Number: l.Number})
+		}
+		resp.Agent = &clientAgentResponse{
+			ID:          a.UserID,
+			FullName:    a.FullName,
+			BrokerName:  a.BrokerName,
+			Languages:   a.Languages,
+			Regions:     a.Regions,
+			Licenses:    licenses,
+			Rating:      a.Rating,
+			ReviewCount: a.ReviewCount,
+		}
+	}
+	if p := o.Progress; p != nil {
+		progress := &clientProgressResponse{Stage: p.Stage, Milestones: make([]clientMilestoneResponse, 0, len(p.Milestones))}
+		if p.SignedAt != nil {
+			signed := p.SignedAt.UTC().Format(time.RFC3339)
+			progress.SignedAt = &signed
+		}
+		for _, m := range p.Milestones {
+			progress.Milestones = append(progress.Milestones, clientMilestoneResponse{Name: m.Name, At: m.At.UTC().Format(time.RFC3339)})
+		}
+		resp.Progress = progress
+	}
+	return resp
+}
+
+// clientOf returns the caller's user ID when they are a client, answering
+// the request otherwise.
+func clientOf(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return "", false
+	}
+	if !can(r.Context(), auth.PermClientPortal) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return "", false
+	}
+	return userID, true
+}
+
+// handleListClientReferrals lists the referrals created on the calling
+// client's behalf, newest first.
+func (s *Server) handleListClientReferrals(w http.ResponseWriter, r *http.Request) {
+	clientID, ok := clientOf(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	refs, err := s.clientPortal.List(ctx, clientID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load referrals")
+		return
+	}
+	items := make([]clientReferralResponse, 0, len(refs))
+	for _, ref := range refs {
+		items = append(items, newClientReferralResponse(ref))
+	}
+	respondJSON(w, http.StatusOK, clientReferralListResponse{Items: items})
+}
+
+// handleGetClientReferral shows one of the caller's referrals with the
+// assigned agent's public profile and the deal's milestones.
+func (s *Server) handleGetClientReferral(w http.ResponseWriter, r *http.Request) {
+	clientID, ok := clientOf(w, r)
+	if !ok {
+		return
+	}
+	referralID := r.PathValue("id")
+	if _, err := uuid.Parse(referralID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	overview, err := s.clientPortal.Get(ctx, clientID, referralID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load referral")
+		return
+	}
+	respondJSON(w, http.StatusOK, newClientOverviewResponse(overview))
+}
+
+// handleLinkReferralClient links the referral to the client it was created
+// for, or unlinks it.
+func (s *Server) handleLinkReferralClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	referralID := r.PathValue("id")
+	if _, err := uuid.Parse(referralID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+	var req linkClientRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ClientUserID != nil {
+		if _, err := uuid.Parse(*req.ClientUserID); err != nil {
+			respondError(w, http.StatusBadRequest, clientportal.ErrNotClient.Error())
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+	if err := s.clientPortal.LinkClient(ctx, scope, referralID, req.ClientUserID); err != nil {
+		respondServiceError(w, err, "Failed to link client")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/clientportal"
+	"brokerflow/tenancy"
+)
+
+type stubClientPortal struct {
+	overview  clientportal.Overview
+	getErr    error
+	linkScope tenancy.Scope
+	linked    *string
+}
+
+func (s *stubClientPortal) List(context.Context, string) ([]clientportal.Referral, error) {
+	return []clientportal.Referral{s.overview.Referral}, nil
+}
+
+func (s *stubClientPortal) Get(context.Context, string, string) (clientportal.Overview, error) {
+	return s.overview, s.getErr
+}
+
+func (s *stubClientPortal) LinkClient(_ context.Context, scope tenancy.Scope, _ string, clientID *string) error {
+	s.linkScope, s.linked = scope, clientID
+	return nil
+}
+
+const portalReferralID = "6f1c1c3e-2f0a-4a36-9b7e-0d7a8f6c2b11"
+
+func TestHandleGetClientReferral(t *testing.T) {
+	signed := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	stub := &stubClientPortal{overview: clientportal.Overview{
+		Referral: clientportal.Referral{ID: portalReferralID, Status: clientportal.ReferralSigned, Region: []string{"us-ny"}},
+		Agent:    &clientportal.Agent{UserID: "a1", FullName: "Ana Agent", BrokerName: "Acme Realty"},
+		Progress: &clientportal.Progress{Stage: clientportal.StageActive, SignedAt: &signed,
+			Milestones: []clientportal.Milestone{{Name: "agreement_signed", At: signed}}},
+	}}
+	server := &Server{clientPortal: stub}
+
+	req := agentRequest(http.MethodGet, "/api/client/referrals/"+portalReferralID, "", auth.RoleClient)
+	req.SetPathValue("id", portalReferralID)
+	rec := httptest.NewRecorder()
+	server.handleGetClientReferral(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"priceMin", "priceMax", "feeRate", "protectDays", "createdByUserId", "version"} {
+		if _, ok := body[leak]; ok {
+			t.Errorf("response exposes %s", leak)
+		}
+	}
+	progress, _ := body["progress"].(map[string]any)
+	if body["status"] != "signed" || progress["stage"] != "active" || progress["signedAt"] != "2026-03-01T00:00:00Z" {
+		t.Fatalf("unexpected body %s", rec.Body.String())
+	}
+
+	for _, role := range []auth.Role{auth.RoleAgent, auth.RoleBrokerAdmin} {
+		rec = httptest.NewRecorder()
+		server.handleGetClientReferral(rec, agentRequest(http.MethodGet, "/api/client/referrals/"+portalReferralID, "", role))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", role, rec.Code)
+		}
+	}
+
+	stub.getErr = clientportal.ErrReferralNotFound
+	rec = httptest.NewRecorder()
+	server.handleGetClientReferral(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unlinked referral: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleLinkReferralClient(t *testing.T) {
+	stub := &stubClientPortal{}
+	server := &Server{clientPortal: stub}
+
+	body := `{"clientUserId":"` + portalReferralID + `"}`
+	req := agentRequest(http.MethodPut, "/api/referrals/"+portalReferralID+"/client", body, auth.RoleAgent)
+	req.SetPathValue("id", portalReferralID)
+	rec := httptest.NewRecorder()
+	server.handleLinkReferralClient(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.linked == nil || *stub.linked != portalReferralID || stub.linkScope != tenancy.User("agent-1") {
+		t.Fatalf("linked %v in %+v", stub.linked, stub.linkScope)
+	}
+
+	req = agentRequest(http.MethodPut, "/api/referrals/"+portalReferralID+"/client", body, auth.RoleClient)
+	req.SetPathValue("id", portalReferralID)
+	rec = httptest.NewRecorder()
+	server.handleLinkReferralClient(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("client linking: expected 403, got %d", rec.Code)
+	}
+
+	req = agentRequest(http.MethodPut, "/api/referrals/"+portalReferralID+"/client", `{"clientUserId":"nope"}`, auth.RoleAgent)
+	req.SetPathValue("id", portalReferralID)
+	rec = httptest.NewRecorder()
+	server.handleLinkReferralClient(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "not a client") {
+		t.Fatalf("malformed client id: got %d %s", rec.Code, rec.Body.String())
+	}
+}
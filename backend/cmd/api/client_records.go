@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/tenancy"
+	"github.com/google/uuid"
+)
+
+type clientRecordService interface {
+	Attach(ctx context.Context, params agreement.AttachClientParams) (agreement.ClientAttachment, error)
+	Get(ctx context.Context, scope tenancy.Scope, referralID, actorID string) (agreement.ClientRecord, error)
+}
+
+type attachClientRecordRequest struct {
+	ClientRecordID string `json:"clientRecordId,omitempty" doc:"Reuse a client record of the caller's brokerage instead of giving details"`
+	Name           string `json:"name,omitempty" doc:"Required for a new record; at most 200 characters"`
+	Email          string `json:"email,omitempty" doc:"Email or phone is required for a new record"`
+	Phone          string `json:"phone,omitempty"`
+}
+
+type clientAttachmentResponse struct {
+	ReferralID     string   `json:"referralId"`
+	ClientRecordID string   `json:"clientRecordId"`
+	AttachedBy     string   `json:"attachedBy"`
+	AttachedAt     string   `json:"attachedAt"`
+	Agreements     []string `json:"agreements" doc:"Agreements the CLIENT_RECORD_ATTACHED event was appended to"`
+}
+
+type clientRecordResponse struct {
+	ReferralID     string `json:"referralId"`
+	ClientRecordID string `json:"clientRecordId"`
+	AttachedBy     string `json:"attachedBy"`
+	AttachedAt     string `json:"attachedAt"`
+	Visible        bool   `json:"visible" doc:"False for the counterparty until an agreement on the referral is in effect; the details are then omitted"`
+	Name           string `json:"name,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+}
+
+// handleAttachClientRecord attaches the end client to a referral of the
+// caller's scope, as new details or an existing record of their brokerage.
+func (s *Server) handleAttachClientRecord(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	referralID := r.PathValue("id")
+	if _, err := uuid.Parse(referralID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+	var req attachClientRecordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+	a, err := s.clientRecords.Attach(ctx, agreement.AttachClientParams{
+		ReferralID: referralID,
+		ActorID:    userID,
+		Scope:      scope,
+		RecordID:   req.ClientRecordID,
+		Name:       req.Name,
+		Email:      req.Email,
+		Phone:      req.Phone,
+	})
+	if err != nil {
+		respondServiceError(w, err, "Failed to attach client record")
+		return
+	}
+	agreements := a.Agreements
+	if agreements == nil {
+		agreements = []string{}
+	}
+	respondJSON(w, http.StatusCreated, clientAttachmentResponse{
+		ReferralID:     a.ReferralID,
+		ClientRecordID: a.RecordID,
+		AttachedBy:     a.AttachedBy,
+		AttachedAt:     a.AttachedAt.UTC().Format(time.RFC3339),
+		Agreements:     agreements,
+	})
+}
+
+// handleGetClientRecord returns a referral's client record. The details are
+// withheld from the counterparty until an agreement on the referral is in
+// effect.
+func (s *Server) handleGetClientRecord(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	referralID := r.PathValue("id")
+	if _, err := uuid.Parse(referralID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+	c, err := s.clientRecords.Get(ctx, scope, referralID, userID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load client record")
+		return
+	}
+	respondJSON(w, http.StatusOK, clientRecordResponse{
+		ReferralID:     c.ReferralID,
+		ClientRecordID: c.RecordID,
+		AttachedBy:     c.AttachedBy,
+		AttachedAt:     c.AttachedAt.UTC().Format(time.RFC3339),
+		Visible:        c.Visible,
+		Name:           c.Name,
+		Email:          c.Email,
+		Phone:          c.Phone,
+	})
+}
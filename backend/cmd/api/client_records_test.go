@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/tenancy"
+)
+
+const testReferralID = "7b0e4c1a-3f59-4d2e-9a61-0c8d5b2f4e17"
+
+type stubClientRecords struct {
+	attach agreement.AttachClientParams
+	scope  tenancy.Scope
+	record agreement.ClientRecord
+	err    error
+}
+
+func (s *stubClientRecords) Attach(_ context.Context, params agreement.AttachClientParams) (agreement.ClientAttachment, error) {
+	s.attach = params
+	if s.err != nil {
+		return agreement.ClientAttachment{}, s.err
+	}
+	return agreement.ClientAttachment{
+		ReferralID: params.ReferralID, RecordID: "c1", AttachedBy: params.ActorID, AttachedAt: time.Now(),
+	}, nil
+}
+
+func (s *stubClientRecords) Get(_ context.Context, scope tenancy.Scope, referralID, _ string) (agreement.ClientRecord, error) {
+	s.scope = scope
+	if s.err != nil {
+		return agreement.ClientRecord{}, s.err
+	}
+	r := s.record
+	r.ReferralID = referralID
+	return r, nil
+}
+
+func serveClientRecord(server *Server, method, id, body string, role auth.Role) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/referrals/{id}/client-record", server.handleGetClientRecord)
+	mux.HandleFunc("POST /api/referrals/{id}/client-record", server.handleAttachClientRecord)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, agentRequest(method, "/api/referrals/"+id+"/client-record", body, role))
+	return rec
+}
+
+func TestHandleAttachClientRecord(t *testing.T) {
+	stub := &stubClientRecords{}
+	server := &Server{clientRecords: stub}
+
+	rec := serveClientRecord(server, http.MethodPost, testReferralID, `{"name":"Dana Client","email":"dana@example.com"}`, auth.RoleAgent)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := agreement.AttachClientParams{
+		ReferralID: testReferralID, ActorID: "agent-1", Scope: tenancy.User("agent-1"),
+		Name: "Dana Client", Email: "dana@example.com",
+	}
+	if stub.attach != want {
+		t.Fatalf("unexpected params %+v", stub.attach)
+	}
+	var resp clientAttachmentResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.ClientRecordID != "c1" || resp.Agreements == nil {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if strings.Contains(rec.Body.String(), "dana@example.com") {
+		t.Fatalf("the attachment must not echo client details: %s", rec.Body.String())
+	}
+}
+
+func TestHandleGetClientRecord_WithholdsDetailsUntilVisible(t *testing.T) {
+	stub := &stubClientRecords{record: agreement.ClientRecord{
+		ClientAttachment: agreement.ClientAttachment{RecordID: "c1", AttachedBy: "u1", AttachedAt: time.Now()},
+	}}
+	server := &Server{clientRecords: stub}
+
+	rec := serveClientRecord(server, http.MethodGet, testReferralID, "", auth.RoleAgent)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, field := range []string{`"name"`, `"email"`, `"phone"`} {
+		if strings.Contains(rec.Body.String(), field) {
+			t.Fatalf("expected no %s before the record is visible: %s", field, rec.Body.String())
+		}
+	}
+
+	stub.record.Visible, stub.record.Name, stub.record.Email = true, "Dana Client", "dana@example.com"
+	rec = serveClientRecord(server, http.MethodGet, testReferralID, "", auth.RoleAgent)
+	var resp clientRecordResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.Visible || resp.Name != "Dana Client" || resp.Email != "dana@example.com" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleClientRecord_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		id     string
+		role   auth.Role
+		err    error
+		want   int
+	}{
+		{"client role", http.MethodPost, testReferralID, auth.RoleClient, nil, http.StatusForbidden},
+		{"client role read", http.MethodGet, testReferralID, auth.RoleClient, nil, http.StatusForbidden},
+		{"bad id", http.MethodGet, "nope", auth.RoleAgent, nil, http.StatusNotFound},
+		{"not in reach", http.MethodGet, testReferralID, auth.RoleAgent, agreement.ErrReferralNotFound, http.StatusNotFound},
+		{"none attached", http.MethodGet, testReferralID, auth.RoleAgent, agreement.ErrClientRecordNotFound, http.StatusNotFound},
+		{"no contact", http.MethodPost, testReferralID, auth.RoleAgent, agreement.ErrClientContactRequired, http.StatusBadRequest},
+		{"both or neither", http.MethodPost, testReferralID, auth.RoleAgent, agreement.ErrClientRecordConflict, http.StatusBadRequest},
+		{"attached", http.MethodPost, testReferralID, auth.RoleAgent, agreement.ErrClientAlreadyAttached, http.StatusConflict},
+		{"cancelled", http.MethodPost, testReferralID, auth.RoleAgent, agreement.ErrReferralCancelled, http.StatusConflict},
+		{"db", http.MethodGet, testReferralID, auth.RoleAgent, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{clientRecords: &stubClientRecords{err: tc.err}}
+			body := ""
+			if tc.method == http.MethodPost {
+				body = `{"name":"x","phone":"1"}`
+			}
+			rec := serveClientRecord(server, tc.method, tc.id, body, tc.role)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+type correctionService interface {
+	Correct(ctx context.Context, params agreement.CorrectionParams) (agreement.Correction, error)
+}
+
+type correctAgreementStatusRequest struct {
+	EventID       int64  `json:"eventId" doc:"The timeline event that set the wrong status; must be the agreement's latest status change"`
+	Status        string `json:"status" doc:"The status the agreement should have; cancelling goes through /cancel"`
+	Justification string `json:"justification" doc:"Required; at most 2000 characters, stored in the audit log"`
+}
+
+type correctAgreementStatusResponse struct {
+	AgreementID     string  `json:"agreementId"`
+	EventID         int64   `json:"eventId" doc:"The AGREEMENT_STATUS_CORRECTED timeline event"`
+	CorrectsEventID int64   `json:"correctsEventId"`
+	PreviousStatus  string  `json:"previousStatus"`
+	Status          string  `json:"status"`
+	EffectiveAt     *string `json:"effectiveAt"`
+	Justification   string  `json:"justification"`
+	CorrectedBy     string  `json:"correctedBy"`
+	CorrectedAt     string  `json:"correctedAt"`
+}
+
+// handleCorrectAgreementStatus lets a broker admin at either broker party
+// undo an erroneous status change. The erroneous event stays in the
+// timeline; the correction is appended after it.
+func (s *Server) handleCorrectAgreementStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermAgreementsCorrect) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	var req correctAgreementStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	c, err := s.corrections.Correct(ctx, agreement.CorrectionParams{
+		AgreementID:   agreementID,
+		ActorID:       userID,
+		EventID:       req.EventID,
+		Status:        req.Status,
+		Justification: req.Justification,
+	})
+	if err != nil {
+		// Admins of other brokerages get 404 so the agreement is not revealed.
+		respondServiceError(w, err, "Failed to correct agreement status")
+		return
+	}
+	resp := correctAgreementStatusResponse{
+		AgreementID:     c.AgreementID,
+		EventID:         c.EventID,
+		CorrectsEventID: c.CorrectsEventID,
+		PreviousStatus:  c.PreviousStatus,
+		Status:          c.Status,
+		Justification:   c.Justification,
+		CorrectedBy:     c.CorrectedBy,
+		CorrectedAt:     c.CorrectedAt.UTC().Format(time.RFC3339),
+	}
+	if c.EffectiveAt != nil {
+		at := c.EffectiveAt.UTC().Format(time.RFC3339)
+		resp.EffectiveAt = &at
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
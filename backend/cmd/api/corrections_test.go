@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+)
+
+type stubCorrections struct {
+	params agreement.CorrectionParams
+	err    error
+}
+
+func (s *stubCorrections) Correct(_ context.Context, params agreement.CorrectionParams) (agreement.Correction, error) {
+	s.params = params
+	if s.err != nil {
+		return agreement.Correction{}, s.err
+	}
+	effectiveAt := time.Date(2026, 5, 2, 11, 0, 0, 0, time.UTC)
+	return agreement.Correction{
+		AgreementID: params.AgreementID, EventID: 12, CorrectsEventID: params.EventID,
+		PreviousStatus: "void", Status: params.Status, EffectiveAt: &effectiveAt,
+		Justification: params.Justification, CorrectedBy: params.ActorID, CorrectedAt: time.Now(),
+	}, nil
+}
+
+func serveCorrection(server *Server, id, body string, role auth.Role) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/admin/agreements/{id}/corrections", server.handleCorrectAgreementStatus)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, agentRequest(http.MethodPost, "/api/admin/agreements/"+id+"/corrections", body, role))
+	return rec
+}
+
+func TestHandleCorrectAgreementStatus(t *testing.T) {
+	stub := &stubCorrections{}
+	server := &Server{corrections: stub}
+
+	rec := serveCorrection(server, testAgreementID, `{"eventId":7,"status":"effective","justification":"Voided the wrong agreement"}`, auth.RoleBrokerAdmin)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := agreement.CorrectionParams{AgreementID: testAgreementID, ActorID: "agent-1", EventID: 7, Status: "effective", Justification: "Voided the wrong agreement"}
+	if stub.params != want {
+		t.Fatalf("unexpected params %+v", stub.params)
+	}
+	var resp correctAgreementStatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.EventID != 12 || resp.CorrectsEventID != 7 || resp.PreviousStatus != "void" || resp.Status != "effective" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.EffectiveAt == nil || *resp.EffectiveAt != "2026-05-02T11:00:00Z" {
+		t.Fatalf("unexpected effectiveAt %v", resp.EffectiveAt)
+	}
+}
+
+func TestHandleCorrectAgreementStatus_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+		role auth.Role
+		err  error
+		want int
+	}{
+		{"agent", testAgreementID, auth.RoleAgent, nil, http.StatusForbidden},
+		{"bad id", "nope", auth.RoleBrokerAdmin, nil, http.StatusNotFound},
+		{"not party", testAgreementID, auth.RoleBrokerAdmin, agreement.ErrNotParty, http.StatusNotFound},
+		{"no justification", testAgreementID, auth.RoleBrokerAdmin, agreement.ErrJustificationRequired, http.StatusBadRequest},
+		{"unknown status", testAgreementID, auth.RoleBrokerAdmin, agreement.ErrUnknownStatus, http.StatusBadRequest},
+		{"stale event", testAgreementID, auth.RoleBrokerAdmin, agreement.ErrNotCorrectable, http.StatusConflict},
+		{"noop", testAgreementID, auth.RoleBrokerAdmin, agreement.ErrCorrectionNoop, http.StatusConflict},
+		{"db", testAgreementID, auth.RoleBrokerAdmin, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{corrections: &stubCorrections{err: tc.err}}
+			rec := serveCorrection(server, tc.id, `{"eventId":7,"status":"effective","justification":"x"}`, tc.role)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"brokerflow/agreement"
+	"github.com/google/uuid"
+)
+
+type dealEventRecorder interface {
+	Record(ctx context.Context, params agreement.RecordDealEventParams) (agreement.DealEvent, error)
+}
+
+type recordDealEventRequest struct {
+	Type    string         `json:"type" doc:"OFFER_MADE, UNDER_CONTRACT or DEAL_CLOSED"`
+	Payload map[string]any `json:"payload,omitempty"`
+}
+
+// handleRecordDealEvent appends a deal lifecycle event to an effective
+// agreement's timeline.
+func (s *Server) handleRecordDealEvent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	var req recordDealEventRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	ev, err := s.dealEvents.Record(ctx, agreement.RecordDealEventParams{
+		AgreementID: agreementID,
+		ActorID:     userID,
+		Type:        req.Type,
+		Payload:     req.Payload,
+	})
+	if err != nil {
+		respondServiceError(w, err, "Failed to record event")
+		return
+	}
+
+	actorBroker := ev.ActorBroker
+	respondJSON(w, http.StatusCreated, timelineEvent{
+		ID:          strconv.FormatInt(ev.ID, 10),
+		AgreementID: ev.AgreementID,
+		Seq:         ev.Seq,
+		Type:        ev.Type,
+		At:          ev.At.UTC(),
+		Payload:     ev.Payload,
+		ActorBroker: &actorBroker,
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+)
+
+type stubDealEvents struct {
+	params agreement.RecordDealEventParams
+	err    error
+}
+
+func (s *stubDealEvents) Record(_ context.Context, params agreement.RecordDealEventParams) (agreement.DealEvent, error) {
+	s.params = params
+	if s.err != nil {
+		return agreement.DealEvent{}, s.err
+	}
+	return agreement.DealEvent{ID: 7, AgreementID: params.AgreementID, Seq: 4, Type: params.Type, At: time.Now(), ActorBroker: "b1"}, nil
+}
+
+func postDealEvent(server *Server, agreementID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/agreements/"+agreementID+"/events", strings.NewReader(body))
+	req.SetPathValue("id", agreementID)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	rec := httptest.NewRecorder()
+	server.handleRecordDealEvent(rec, req)
+	return rec
+}
+
+func TestHandleRecordDealEvent_Created(t *testing.T) {
+	svc := &stubDealEvents{}
+	rec := postDealEvent(&Server{dealEvents: svc}, testAgreementID, `{"type":"OFFER_MADE","payload":{"price":500000}}`)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.params.Type != agreement.EventOfferMade || svc.params.ActorID != "agent-1" || svc.params.Payload["price"] == nil {
+		t.Fatalf("unexpected params: %+v", svc.params)
+	}
+	var resp timelineEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.ID != "7" || resp.Seq != 4 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleRecordDealEvent_MapsServiceErrors(t *testing.T) {
+	cases := map[error]int{
+		agreement.ErrNotParty:          http.StatusNotFound,
+		agreement.ErrUnknownDealEvent:  http.StatusBadRequest,
+		agreement.ErrDealNotEffective:  http.StatusConflict,
+		agreement.ErrDealEventOutOfSeq: http.StatusConflict,
+	}
+	for err, want := range cases {
+		rec := postDealEvent(&Server{dealEvents: &stubDealEvents{err: err}}, testAgreementID, `{"type":"DEAL_CLOSED"}`)
+		if rec.Code != want {
+			t.Errorf("%v: expected %d, got %d", err, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"brokerflow/dispute"
+)
+
+type createDisputeRequest struct {
+	AgreementID string `json:"agreementId"`
+	// Reason is one of non_payment, protect_period_violation,
+	// misrepresentation or other; Detail explains it for reviewers.
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+type resolveDisputeRequest struct {
+	Status string `json:"status"`
+}
+
+func (s *Server) handleListDisputes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	agreementID := r.URL.Query().Get("agreementId")
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+
+	records, err := s.disputeService.List(ctx, scope, agreementID)
+	if err != nil {
+		if errors.Is(err, dispute.ErrForbidden) {
+			respondError(w, http.StatusNotFound, "Disputes not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load disputes")
+		return
+	}
+
+	resp := make([]disputeResponse, 0, len(records))
+	for _, rec := range records {
+		resp = append(resp, newDisputeResponse(rec))
+	}
+
+	respondJSON(w, http.StatusOK, disputeListResponse{Items: resp})
+}
+
+func (s *Server) handleCreateDispute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req createDisputeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.AgreementID) == "" {
+		respondError(w, http.StatusBadRequest, "agreementId is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Either party may open a dispute: the referrer side as the caller's
+	// scope sees it, or any user of the referee broker.
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+
+	record, err := s.disputeService.Create(ctx, scope, dispute.CreateParams{
+		AgreementID: req.AgreementID,
+		Reason:      dispute.Reason(req.Reason),
+		Detail:      req.Detail,
+	})
+	if err != nil {
+		if errors.Is(err, dispute.ErrForbidden) {
+			respondError(w, http.StatusNotFound, "Agreement not found")
+			return
+		}
+		respondServiceError(w, err, "Failed to create dispute")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, newDisputeResponse(record))
+}
+
+func (s *Server) handleResolveDispute(w http.ResponseWriter, r *http.Request) {
+	disputeID := r.PathValue("id")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req resolveDisputeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Status != "resolved" {
+		respondError(w, http.StatusBadRequest, "status must be 'resolved'")
+		return
+	}
+
+	ctx := r.Context()
+
+	record, err := s.disputeService.Resolve(ctx, userID, disputeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, dispute.ErrForbidden):
+			respondError(w, http.StatusNotFound, "Dispute not found")
+		case errors.Is(err, dispute.ErrBadStatus):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to resolve dispute")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, newDisputeResponse(record))
+}
+
+type disputeResponse struct {
+	ID                 string  `json:"id"`
+	AgreementID        string  `json:"agreementId"`
+	Status             string  `json:"status"`
+	CreatedAt          string  `json:"createdAt"`
+	UpdatedAt          string  `json:"updatedAt"`
+	ResolvedAt         *string `json:"resolvedAt,omitempty"`
+	ReviewDeadline     *string `json:"reviewDeadline,omitempty"`
+	EscalationTier     int     `json:"escalationTier"`
+	EscalatedAt        *string `json:"escalatedAt,omitempty"`
+	AssignedReviewerID *string `json:"assignedReviewerId,omitempty"`
+	OpenedBy           *string `json:"openedBy,omitempty"`
+	OpenedByRole       string  `json:"openedByRole"`
+	Reason             string  `json:"reason,omitempty"`
+	Detail             string  `json:"detail,omitempty"`
+}
+
+type disputeListResponse struct {
+	Items []disputeResponse `json:"items"`
+}
+
+func newDisputeResponse(d dispute.Record) disputeResponse {
+	resp := disputeResponse{
+		ID:          d.ID,
+		AgreementID: d.AgreementID,
+		Status:      string(d.Status),
+		CreatedAt:   d.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   d.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if d.ResolvedAt != nil {
+		val := d.ResolvedAt.UTC().Format(time.RFC3339)
+		resp.ResolvedAt = &val
+	}
+	if d.ReviewDeadline != nil {
+		val := d.ReviewDeadline.UTC().Format(time.RFC3339)
+		resp.ReviewDeadline = &val
+	}
+	if d.EscalatedAt != nil {
+		val := d.EscalatedAt.UTC().Format(time.RFC3339)
+		resp.EscalatedAt = &val
+	}
+	resp.EscalationTier = d.EscalationTier
+	resp.AssignedReviewerID = d.AssignedReviewerID
+	resp.OpenedBy = d.OpenedBy
+	resp.OpenedByRole = string(d.OpenedByRole)
+	resp.Reason = string(d.Reason)
+	resp.Detail = d.Detail
+	return resp
+}
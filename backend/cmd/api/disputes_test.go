@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/dispute"
+	"brokerflow/tenancy"
+)
+
+type stubDisputeService struct {
+	listScope     tenancy.Scope
+	listRecords   []dispute.Record
+	listErr       error
+	createScope   tenancy.Scope
+	createParams  dispute.CreateParams
+	createRecord  dispute.Record
+	createErr     error
+	resolveRecord dispute.Record
+	resolveErr    error
+}
+
+func (s *stubDisputeService) List(_ context.Context, scope tenancy.Scope, _ string) ([]dispute.Record, error) {
+	s.listScope = scope
+	return s.listRecords, s.listErr
+}
+
+func (s *stubDisputeService) Create(_ context.Context, scope tenancy.Scope, p dispute.CreateParams) (dispute.Record, error) {
+	s.createScope = scope
+	s.createParams = p
+	return s.createRecord, s.createErr
+}
+
+func (s *stubDisputeService) Resolve(_ context.Context, _ string, _ string) (dispute.Record, error) {
+	return s.resolveRecord, s.resolveErr
+}
+
+func TestHandleListDisputes_Success(t *testing.T) {
+	now := time.Now().UTC()
+	server := &Server{
+		disputeService: &stubDisputeService{
+			listRecords: []dispute.Record{{ID: "d1", AgreementID: "ag1", Status: dispute.StatusUnderReview, CreatedAt: now, UpdatedAt: now}},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/disputes", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleListDisputes(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var payload struct {
+		Items []disputeResponse `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Items) != 1 || payload.Items[0].ID != "d1" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestHandleCreateDispute_NotFound(t *testing.T) {
+	server := &Server{
+		disputeService: &stubDisputeService{
+			createErr: dispute.ErrForbidden,
+		},
+	}
+
+	body := strings.NewReader(`{"agreementId":"ag1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/disputes", body)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleCreateDispute(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleCreateDispute_RefereeOpens(t *testing.T) {
+	now := time.Now().UTC()
+	opener := "referee-agent"
+	disputes := &stubDisputeService{
+		createRecord: dispute.Record{ID: "d1", AgreementID: "ag1", Status: dispute.StatusUnderReview, CreatedAt: now, UpdatedAt: now,
+			OpenedBy: &opener, OpenedByRole: dispute.RoleReferee},
+	}
+	server := &Server{disputeService: disputes}
+
+	body := `{"agreementId":"ag1","reason":"non_payment","detail":"Fee unpaid 30 days after closing"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/disputes", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, opener))
+	rec := httptest.NewRecorder()
+
+	server.handleCreateDispute(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if disputes.createScope != tenancy.User(opener) {
+		t.Fatalf("expected the caller's scope, got %+v", disputes.createScope)
+	}
+	want := dispute.CreateParams{AgreementID: "ag1", Reason: dispute.ReasonNonPayment, Detail: "Fee unpaid 30 days after closing"}
+	if disputes.createParams != want {
+		t.Fatalf("got params %+v, want %+v", disputes.createParams, want)
+	}
+	var resp disputeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.OpenedByRole != "referee" || resp.OpenedBy == nil || *resp.OpenedBy != opener {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleCreateDispute_InvalidReason(t *testing.T) {
+	for _, err := range []error{dispute.ErrInvalidReason, dispute.ErrDetailRequired, dispute.ErrDetailTooLong} {
+		server := &Server{disputeService: &stubDisputeService{createErr: err}}
+		req := httptest.NewRequest(http.MethodPost, "/api/disputes", strings.NewReader(`{"agreementId":"ag1","reason":"late"}`))
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+		rec := httptest.NewRecorder()
+
+		server.handleCreateDispute(rec, req)
+
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), err.Error()) {
+			t.Fatalf("%v: expected 400 with its message, got %d: %s", err, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleResolveDispute_BadStatus(t *testing.T) {
+	server := &Server{
+		disputeService: &stubDisputeService{
+			resolveErr: dispute.ErrBadStatus,
+		},
+	}
+
+	body := strings.NewReader(`{"status":"resolved"}`)
+	req := httptest.NewRequest(http.MethodPatch, "/api/disputes/d1", body)
+	req.SetPathValue("id", "d1")
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleResolveDispute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestNewDisputeResponse_Escalated(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	deadline := now.Add(48 * time.Hour)
+	reviewer := "admin-2"
+	resp := newDisputeResponse(dispute.Record{
+		ID: "d1", AgreementID: "ag1", Status: dispute.StatusEscalated, CreatedAt: now, UpdatedAt: now,
+		ReviewDeadline: &deadline, EscalationTier: 1, EscalatedAt: &now, AssignedReviewerID: &reviewer,
+	})
+	if resp.Status != "escalated" || resp.EscalationTier != 1 || resp.AssignedReviewerID == nil || *resp.AssignedReviewerID != reviewer {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.ReviewDeadline == nil || *resp.ReviewDeadline != "2026-03-03T12:00:00Z" {
+		t.Fatalf("unexpected review deadline: %v", resp.ReviewDeadline)
+	}
+	if resp.EscalatedAt == nil || *resp.EscalatedAt != "2026-03-01T12:00:00Z" {
+		t.Fatalf("unexpected escalatedAt: %v", resp.EscalatedAt)
+	}
+}
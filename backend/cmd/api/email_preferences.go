@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"brokerflow/email"
+)
+
+type emailPreferenceService interface {
+	GetPreferences(ctx context.Context, userID string) (email.Preferences, error)
+	SavePreferences(ctx context.Context, userID string, p email.Preferences) (email.Preferences, error)
+}
+
+type emailPreferencesBody struct {
+	MatchInvited         bool `json:"matchInvited" doc:"A referral owner invited you to a referral"`
+	AgreementReadyToSign bool `json:"agreementReadyToSign" doc:"An agreement you are party to awaits signatures"`
+	AgreementEffective   bool `json:"agreementEffective" doc:"An agreement you are party to took effect"`
+	DisputeOpened        bool `json:"disputeOpened" doc:"The other party opened a dispute on your agreement"`
+	NewReferralMatch     bool `json:"newReferralMatch" doc:"A new referral matches one of your saved filters with notify set"`
+}
+
+func newEmailPreferencesBody(p email.Preferences) emailPreferencesBody {
+	return emailPreferencesBody{
+		MatchInvited:         p.MatchInvited,
+		AgreementReadyToSign: p.AgreementReadyToSign,
+		AgreementEffective:   p.AgreementEffective,
+		DisputeOpened:        p.DisputeOpened,
+		NewReferralMatch:     p.NewReferralMatch,
+	}
+}
+
+func (b emailPreferencesBody) preferences() email.Preferences {
+	return email.Preferences{
+		MatchInvited:         b.MatchInvited,
+		AgreementReadyToSign: b.AgreementReadyToSign,
+		AgreementEffective:   b.AgreementEffective,
+		DisputeOpened:        b.DisputeOpened,
+		NewReferralMatch:     b.NewReferralMatch,
+	}
+}
+
+func (s *Server) handleGetEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := s.emailPreferences.GetPreferences(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load email preferences")
+		return
+	}
+	respondJSON(w, http.StatusOK, newEmailPreferencesBody(p))
+}
+
+// handlePutEmailPreferences stores the caller's email preferences. Flags
+// missing from the body keep their current value.
+func (s *Server) handlePutEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := s.emailPreferences.GetPreferences(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load email preferences")
+		return
+	}
+	req := newEmailPreferencesBody(current)
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	p, err := s.emailPreferences.SavePreferences(ctx, userID, req.preferences())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save email preferences")
+		return
+	}
+	respondJSON(w, http.StatusOK, newEmailPreferencesBody(p))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/email"
+)
+
+type stubEmailPreferences struct {
+	current email.Preferences
+	saved   *email.Preferences
+}
+
+func (s *stubEmailPreferences) GetPreferences(context.Context, string) (email.Preferences, error) {
+	return s.current, nil
+}
+
+func (s *stubEmailPreferences) SavePreferences(_ context.Context, _ string, p email.Preferences) (email.Preferences, error) {
+	s.saved = &p
+	return p, nil
+}
+
+func TestHandlePutEmailPreferences_KeepsOmittedFlags(t *testing.T) {
+	stub := &stubEmailPreferences{current: email.DefaultPreferences()}
+	server := &Server{emailPreferences: stub}
+	rec := httptest.NewRecorder()
+
+	server.handlePutEmailPreferences(rec, agentRequest(http.MethodPut, "/api/me/email-preferences", `{"disputeOpened":false}`, auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := email.DefaultPreferences()
+	want.DisputeOpened = false
+	if stub.saved == nil || *stub.saved != want {
+		t.Fatalf("saved %+v, want %+v", stub.saved, want)
+	}
+	var resp emailPreferencesBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.DisputeOpened || !resp.MatchInvited {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleEmailPreferences_Errors(t *testing.T) {
+	server := &Server{emailPreferences: &stubEmailPreferences{current: email.DefaultPreferences()}}
+
+	rec := httptest.NewRecorder()
+	server.handlePutEmailPreferences(rec, agentRequest(http.MethodPut, "/api/me/email-preferences", `{`, auth.RoleAgent))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed body, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleGetEmailPreferences(rec, httptest.NewRequest(http.MethodGet, "/api/me/email-preferences", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a caller, got %d", rec.Code)
+	}
+}
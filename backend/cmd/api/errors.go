@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/clientportal"
+	"brokerflow/dispute"
+	"brokerflow/files"
+	"brokerflow/invitation"
+	"brokerflow/money"
+	"brokerflow/referral"
+	"brokerflow/region"
+	"brokerflow/timeline"
+)
+
+// domainError is the response a handler gives a service error. An empty
+// message sends the error's own text, which for these sentinels is written
+// for the client.
+type domainError struct {
+	err     error
+	status  int
+	message string
+}
+
+// domainErrors maps the service errors handlers expect to their responses.
+// The first entry the error matches with errors.Is wins, so wrapped errors
+// keep their mapping.
+var domainErrors = []domainError{
+	{agreement.ErrAgreementNotFound, http.StatusNotFound, "Agreement not found"},
+	{agreement.ErrNotParty, http.StatusNotFound, "Agreement not found"},
+	{agreement.ErrReferralNotFound, http.StatusNotFound, "Referral not found"},
+	{agreement.ErrAmendmentNotFound, http.StatusNotFound, "Amendment not found"},
+	{agreement.ErrClientRecordNotFound, http.StatusNotFound, "Client record not found"},
+	{agreement.ErrNotOwner, http.StatusForbidden, "Referral does not belong to you"},
+	{agreement.ErrReferrerBrokerMismatch, http.StatusForbidden, "Referrer broker must be your brokerage"},
+	{agreement.ErrAmendmentOwnProposal, http.StatusForbidden, ""},
+	{agreement.ErrInvalidParams, http.StatusBadRequest, ""},
+	{agreement.ErrUnknownStatus, http.StatusBadRequest, ""},
+	{agreement.ErrCancelViaTransition, http.StatusBadRequest, ""},
+	{agreement.ErrCancelReasonRequired, http.StatusBadRequest, ""},
+	{agreement.ErrCancelReasonTooLong, http.StatusBadRequest, ""},
+	{agreement.ErrJustificationRequired, http.StatusBadRequest, ""},
+	{agreement.ErrJustificationTooLong, http.StatusBadRequest, ""},
+	{agreement.ErrClientRecordConflict, http.StatusBadRequest, ""},
+	{agreement.ErrClientNameRequired, http.StatusBadRequest, ""},
+	{agreement.ErrClientContactRequired, http.StatusBadRequest, ""},
+	{agreement.ErrClientInvalidEmail, http.StatusBadRequest, ""},
+	{agreement.ErrClientFieldTooLong, http.StatusBadRequest, ""},
+	{agreement.ErrAmendmentInvalidTerms, http.StatusBadRequest, ""},
+	{agreement.ErrAmendmentInvalidOutcome, http.StatusBadRequest, ""},
+	{agreement.ErrAmendmentOutOfBounds, http.StatusBadRequest, ""},
+	{agreement.ErrUnknownDealEvent, http.StatusBadRequest, ""},
+	{agreement.ErrInvalidTransition, http.StatusConflict, ""},
+	{agreement.ErrActiveAgreementExists, http.StatusConflict, ""},
+	{agreement.ErrNotCancellable, http.StatusConflict, ""},
+	{agreement.ErrAmendmentNotNegotiable, http.StatusConflict, ""},
+	{agreement.ErrAmendmentOpen, http.StatusConflict, ""},
+	{agreement.ErrAmendmentClosed, http.StatusConflict, ""},
+	{agreement.ErrNotSignable, http.StatusConflict, ""},
+	{agreement.ErrAlreadySigned, http.StatusConflict, ""},
+	{agreement.ErrSignatureOutOfOrder, http.StatusConflict, ""},
+	{agreement.ErrSignaturesMissing, http.StatusConflict, ""},
+	{agreement.ErrDealNotEffective, http.StatusConflict, ""},
+	{agreement.ErrDealEventOutOfSeq, http.StatusConflict, ""},
+	{agreement.ErrProtectExpired, http.StatusConflict, ""},
+	{agreement.ErrNotCorrectable, http.StatusConflict, ""},
+	{agreement.ErrCorrectionNoop, http.StatusConflict, ""},
+	{agreement.ErrClientAlreadyAttached, http.StatusConflict, ""},
+	{agreement.ErrReferralCancelled, http.StatusConflict, ""},
+
+	{referral.ErrNotFound, http.StatusNotFound, "Referral not found"},
+	{referral.ErrCreatorRequired, http.StatusBadRequest, ""},
+	{referral.ErrRegionRequired, http.StatusBadRequest, ""},
+	{referral.ErrInvalidPriceRange, http.StatusBadRequest, ""},
+	{referral.ErrInvalidSLAHours, http.StatusBadRequest, ""},
+	{referral.ErrInvalidMatchTTL, http.StatusBadRequest, ""},
+	{referral.ErrUpdateInvalidState, http.StatusBadRequest, ""},
+	{region.ErrUnknownRegion, http.StatusBadRequest, ""},
+	{money.ErrUnsupportedCurrency, http.StatusBadRequest, ""},
+
+	{broker.ErrOfficeNotFound, http.StatusNotFound, "Office not found"},
+	{broker.ErrUserNotInBroker, http.StatusNotFound, "User not found"},
+	{broker.ErrNotFound, http.StatusNotFound, "Broker not found"},
+	{broker.ErrInvalidOfficeName, http.StatusBadRequest, ""},
+	{broker.ErrOfficeNameTaken, http.StatusConflict, ""},
+	{errOfficeFilterForbidden, http.StatusForbidden, "Insufficient permissions"},
+
+	{files.ErrNotFound, http.StatusNotFound, "File not found"},
+	{files.ErrInvalidLink, http.StatusForbidden, ""},
+	{files.ErrNotUploader, http.StatusForbidden, ""},
+	{files.ErrInvalidName, http.StatusBadRequest, ""},
+	{files.ErrEmpty, http.StatusBadRequest, ""},
+	{files.ErrTooLarge, http.StatusRequestEntityTooLarge, ""},
+	{files.ErrUnsupportedType, http.StatusUnsupportedMediaType, ""},
+	{files.ErrInfected, http.StatusUnprocessableEntity, ""},
+
+	{dispute.ErrInvalidReason, http.StatusBadRequest, ""},
+	{dispute.ErrDetailRequired, http.StatusBadRequest, ""},
+	{dispute.ErrDetailTooLong, http.StatusBadRequest, ""},
+
+	{timeline.ErrUnknownType, http.StatusBadRequest, ""},
+	{timeline.ErrInvalidFilter, http.StatusBadRequest, ""},
+
+	{clientportal.ErrReferralNotFound, http.StatusNotFound, "Referral not found"},
+	{clientportal.ErrNotClient, http.StatusBadRequest, ""},
+
+	{invitation.ErrNotFound, http.StatusNotFound, "Invitation not found"},
+	{invitation.ErrInvalidEmail, http.StatusBadRequest, ""},
+	{invitation.ErrInvalidRole, http.StatusBadRequest, ""},
+	{invitation.ErrInvalidToken, http.StatusBadRequest, ""},
+	{invitation.ErrEmailRegistered, http.StatusConflict, ""},
+	{invitation.ErrNotPending, http.StatusConflict, ""},
+	{auth.ErrDuplicateEmail, http.StatusConflict, "Email already exists"},
+	{auth.ErrWeakPassword, http.StatusBadRequest, ""},
+
+	{auth.ErrOIDCUnknownProvider, http.StatusNotFound, ""},
+	{auth.ErrOIDCInvalidState, http.StatusBadRequest, ""},
+	{auth.ErrOIDCLoginFailed, http.StatusUnauthorized, ""},
+	{auth.ErrOIDCEmailNotVerified, http.StatusForbidden, ""},
+	{auth.ErrOIDCNoAccount, http.StatusForbidden, ""},
+}
+
+// respondServiceError answers err with its domainErrors response. A
+// *broker.PolicyViolation gets its own body. Anything else is logged and
+// answered with a 500 carrying fallback, so SQL and other internal messages
+// never reach the client.
+func respondServiceError(w http.ResponseWriter, err error, fallback string) {
+	var violation *broker.PolicyViolation
+	if errors.As(err, &violation) {
+		respondPolicyViolation(w, violation)
+		return
+	}
+	for _, d := range domainErrors {
+		if errors.Is(err, d.err) {
+			message := d.message
+			if message == "" {
+				message = err.Error()
+			}
+			respondError(w, d.status, message)
+			return
+		}
+	}
+	log.Printf("unmapped service error: %v", err)
+	respondError(w, http.StatusInternalServerError, fallback)
+}
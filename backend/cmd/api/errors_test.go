@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/agreement"
+)
+
+func TestRespondServiceError(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"wrapped sentinel", fmt.Errorf("%w draft -> closed", agreement.ErrInvalidTransition), http.StatusConflict, "agreement: invalid transition draft -> closed"},
+		{"fixed message", agreement.ErrNotParty, http.StatusNotFound, "Agreement not found"},
+		{"not owner", agreement.ErrNotOwner, http.StatusForbidden, "Referral does not belong to you"},
+		{"internal", errors.New(`ERROR: invalid input syntax for type uuid: "x" (SQLSTATE 22P02)`), http.StatusInternalServerError, "Failed to do it"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		respondServiceError(rec, tc.err, "Failed to do it")
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.status, rec.Code)
+		}
+		var body errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if body.Message != tc.message || strings.Contains(rec.Body.String(), "SQLSTATE") {
+			t.Fatalf("%s: unexpected body %s", tc.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/config"
+	"brokerflow/files"
+	"brokerflow/tenancy"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxUploadBytes bounds an upload request: one files.MaxSize file plus the
+// multipart framing around it.
+const maxUploadBytes = files.MaxSize + 64<<10
+
+type fileService interface {
+	Upload(ctx context.Context, params files.UploadParams) (files.File, error)
+	List(ctx context.Context, scope tenancy.Scope, target files.Target) ([]files.File, error)
+	Delete(ctx context.Context, scope tenancy.Scope, id string) error
+	Link(f files.File) (string, time.Time)
+	Open(ctx context.Context, id, expires, signature string) (files.File, io.ReadCloser, error)
+}
+
+// newFileService builds the attachment service cfg describes.
+func newFileService(pool *pgxpool.Pool, cfg config.Files) (*files.Service, error) {
+	var storage files.Storage
+	switch cfg.Storage {
+	case config.FileStorageS3:
+		s3, err := files.NewS3Storage(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		storage = s3
+	default:
+		disk, err := files.NewDiskStorage(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		storage = disk
+	}
+
+	links := files.NewLinks([]byte(cfg.LinkSecret), cfg.LinkTTL)
+	service := files.NewService(files.NewRepository(pool), storage, links)
+	if cfg.ClamdAddr != "" {
+		service.WithScanner(files.NewClamdScanner(cfg.ClamdAddr))
+	}
+	return service, nil
+}
+
+// uploadFileForm documents the multipart form upload routes accept.
+type uploadFileForm struct {
+	File string `json:"file" doc:"PDF, PNG, JPEG, GIF, WebP or plain text, at most 20 MiB"`
+}
+
+type fileResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType" doc:"Detected from the content, not the client's claim"`
+	Size        int64  `json:"size" doc:"Bytes"`
+	SHA256      string `json:"sha256"`
+	ScanStatus  string `json:"scanStatus" doc:"clean, or unscanned when no virus scanner is configured"`
+	UploadedBy  string `json:"uploadedBy"`
+	CreatedAt   string `json:"createdAt"`
+	DownloadURL string `json:"downloadUrl" doc:"Signed API path that downloads the file without authentication until downloadExpiresAt"`
+	ExpiresAt   string `json:"downloadExpiresAt"`
+}
+
+type fileListResponse struct {
+	Items []fileResponse `json:"items"`
+}
+
+func (s *Server) newFileResponse(f files.File) fileResponse {
+	link, expires := s.files.Link(f)
+	return fileResponse{
+		ID:          f.ID,
+		Name:        f.Name,
+		ContentType: f.ContentType,
+		Size:        f.Size,
+		SHA256:      f.SHA256,
+		ScanStatus:  f.ScanStatus,
+		UploadedBy:  f.UploadedBy,
+		CreatedAt:   f.CreatedAt.UTC().Format(time.RFC3339),
+		DownloadURL: link,
+		ExpiresAt:   expires.UTC().Format(time.RFC3339),
+	}
+}
+
+// targetNotFound is the 404 message for a target the caller cannot see,
+// worded like the target's own endpoints.
+var targetNotFound = map[files.TargetType]string{
+	files.TargetReferral:  "Referral not found",
+	files.TargetAgreement: "Agreement not found",
+	files.TargetDispute:   "Dispute not found",
+}
+
+func respondFileError(w http.ResponseWriter, target files.TargetType, err error, fallback string) {
+	if errors.Is(err, files.ErrTargetNotFound) {
+		respondError(w, http.StatusNotFound, targetNotFound[target])
+		return
+	}
+	respondServiceError(w, err, fallback)
+}
+
+// handleUploadFile attaches the "file" field of a multipart form to the
+// referral, agreement or dispute named by the path. The caller must be able
+// to see the target.
+func (s *Server) handleUploadFile(target files.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(ctxKeyUserID).(string)
+		if !ok || userID == "" {
+			respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+			return
+		}
+		if !can(r.Context(), auth.PermReferralsWrite) {
+			respondError(w, http.StatusForbidden, "Insufficient permissions")
+			return
+		}
+
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+			respondError(w, http.StatusBadRequest, "Missing file")
+			return
+		}
+		part, header, err := r.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(w, http.StatusRequestEntityTooLarge, files.ErrTooLarge.Error())
+				return
+			}
+			respondError(w, http.StatusBadRequest, "Missing file")
+			return
+		}
+		defer part.Close()
+		data, err := io.ReadAll(io.LimitReader(part, files.MaxSize+1))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Missing file")
+			return
+		}
+
+		ctx := r.Context()
+
+		scope, err := s.callerScope(ctx, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+			return
+		}
+		f, err := s.files.Upload(ctx, files.UploadParams{
+			Scope:  scope,
+			Target: files.Target{Type: target, ID: r.PathValue("id")},
+			Name:   header.Filename,
+			Data:   data,
+		})
+		if err != nil {
+			respondFileError(w, target, err, "Failed to upload file")
+			return
+		}
+		respondJSON(w, http.StatusCreated, s.newFileResponse(f))
+	}
+}
+
+// handleListFiles lists the files attached to the path's target, each with
+// a fresh download link.
+func (s *Server) handleListFiles(target files.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(ctxKeyUserID).(string)
+		if !ok || userID == "" {
+			respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+			return
+		}
+
+		ctx := r.Context()
+
+		scope, err := s.callerScope(ctx, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+			return
+		}
+		list, err := s.files.List(ctx, scope, files.Target{Type: target, ID: r.PathValue("id")})
+		if err != nil {
+			respondFileError(w, target, err, "Failed to load files")
+			return
+		}
+		items := make([]fileResponse, 0, len(list))
+		for _, f := range list {
+			items = append(items, s.newFileResponse(f))
+		}
+		respondJSON(w, http.StatusOK, fileListResponse{Items: items})
+	}
+}
+
+// handleDeleteFile removes a file the caller uploaded.
+func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+	if err := s.files.Delete(ctx, scope, r.PathValue("id")); err != nil {
+		respondServiceError(w, err, "Failed to delete file")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDownloadFile serves a file through a signed link; the signature
+// takes the place of authentication. Content is always sent as an
+// attachment with its detected type, never rendered inline.
+func (s *Server) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	ctx := r.Context()
+
+	f, content, err := s.files.Open(ctx, r.PathValue("id"), query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		respondServiceError(w, err, "Failed to load file")
+		return
+	}
+	defer content.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", f.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("download file %s: %v", f.ID, err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/files"
+	"brokerflow/tenancy"
+)
+
+type stubFiles struct {
+	uploaded  files.UploadParams
+	uploadErr error
+	listErr   error
+	openErr   error
+	content   string
+}
+
+func (s *stubFiles) Upload(_ context.Context, params files.UploadParams) (files.File, error) {
+	s.uploaded = params
+	if s.uploadErr != nil {
+		return files.File{}, s.uploadErr
+	}
+	return files.File{ID: "f1", Target: params.Target, Name: params.Name, ContentType: "application/pdf", Size: int64(len(params.Data)), UploadedBy: params.Scope.UserID, CreatedAt: time.Now()}, nil
+}
+
+func (s *stubFiles) List(context.Context, tenancy.Scope, files.Target) ([]files.File, error) {
+	return nil, s.listErr
+}
+
+func (s *stubFiles) Delete(context.Context, tenancy.Scope, string) error { return nil }
+
+func (s *stubFiles) Link(f files.File) (string, time.Time) {
+	return "/api/files/" + f.ID + "/download?expires=1&signature=x", time.Unix(1, 0)
+}
+
+func (s *stubFiles) Open(_ context.Context, id, _, _ string) (files.File, io.ReadCloser, error) {
+	if s.openErr != nil {
+		return files.File{}, nil, s.openErr
+	}
+	return files.File{ID: id, Name: "offer sheet.pdf", ContentType: "application/pdf", Size: int64(len(s.content))},
+		io.NopCloser(bytes.NewReader([]byte(s.content))), nil
+}
+
+func uploadRequest(t *testing.T, path, name string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write(content)
+	form.Close()
+	req := agentRequest(http.MethodPost, path, body.String(), auth.RoleAgent)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetPathValue("id", "r1")
+	return req
+}
+
+func TestHandleUploadFile(t *testing.T) {
+	stub := &stubFiles{}
+	server := &Server{files: stub}
+	rec := httptest.NewRecorder()
+
+	server.handleUploadFile(files.TargetReferral)(rec, uploadRequest(t, "/api/referrals/r1/files", "sheet.pdf", []byte("%PDF-1.7")))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := files.Target{Type: files.TargetReferral, ID: "r1"}
+	if stub.uploaded.Target != want || stub.uploaded.Name != "sheet.pdf" || stub.uploaded.Scope != tenancy.User("agent-1") ||
+		string(stub.uploaded.Data) != "%PDF-1.7" {
+		t.Fatalf("unexpected upload %+v", stub.uploaded)
+	}
+	var resp fileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.DownloadURL != "/api/files/f1/download?expires=1&signature=x" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleFileErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+		msg  string
+	}{
+		{"hidden target", files.ErrTargetNotFound, http.StatusNotFound, "Agreement not found"},
+		{"unsupported", files.ErrUnsupportedType, http.StatusUnsupportedMediaType, files.ErrUnsupportedType.Error()},
+		{"infected", files.ErrInfected, http.StatusUnprocessableEntity, files.ErrInfected.Error()},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{files: &stubFiles{uploadErr: tc.err}}
+			rec := httptest.NewRecorder()
+			server.handleUploadFile(files.TargetAgreement)(rec, uploadRequest(t, "/api/agreements/r1/files", "x.pdf", []byte("x")))
+			var body errorResponse
+			_ = json.Unmarshal(rec.Body.Bytes(), &body)
+			if rec.Code != tc.want || body.Message != tc.msg {
+				t.Fatalf("got %d %q, want %d %q", rec.Code, body.Message, tc.want, tc.msg)
+			}
+		})
+	}
+
+	server := &Server{files: &stubFiles{}}
+	rec := httptest.NewRecorder()
+	req := agentRequest(http.MethodPost, "/api/referrals/r1/files", `{"file":"x"}`, auth.RoleAgent)
+	req.Header.Set("Content-Type", "application/json")
+	server.handleUploadFile(files.TargetReferral)(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("non-multipart body: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleDownloadFile(t *testing.T) {
+	server := &Server{files: &stubFiles{content: "%PDF-1.7"}}
+	req := httptest.NewRequest(http.MethodGet, "/api/files/f1/download?expires=1&signature=x", nil)
+	req.SetPathValue("id", "f1")
+	rec := httptest.NewRecorder()
+
+	server.handleDownloadFile(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "%PDF-1.7" {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+	h := rec.Header()
+	if h.Get("Content-Type") != "application/pdf" || h.Get("X-Content-Type-Options") != "nosniff" ||
+		h.Get("Content-Disposition") != `attachment; filename="offer sheet.pdf"` {
+		t.Fatalf("unexpected headers %v", h)
+	}
+
+	server = &Server{files: &stubFiles{openErr: files.ErrInvalidLink}}
+	rec = httptest.NewRecorder()
+	server.handleDownloadFile(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("invalid link: expected 403, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+
+	"brokerflow/graph"
+)
+
+// graphQLResponse documents what handleGraphQL writes; graph.Schema.Exec
+// builds the actual response.
+type graphQLResponse struct {
+	Data   map[string]any   `json:"data,omitempty"`
+	Errors []map[string]any `json:"errors,omitempty"`
+}
+
+// handleGraphQL runs a read-only GraphQL query in the caller's tenancy
+// scope. Like other GraphQL servers it answers 200 whenever the body is a
+// request, with query and resolver errors listed under errors.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req graph.Request
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Query == "" {
+		respondError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to resolve access scope")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s.graph.Exec(ctx, scope, req))
+}
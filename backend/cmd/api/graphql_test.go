@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/db"
+	"brokerflow/graph"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+)
+
+type stubGraphReferrals struct {
+	listed referral.Filters
+}
+
+func (s *stubGraphReferrals) List(_ context.Context, filters referral.Filters) ([]referral.Request, db.Page, error) {
+	s.listed = filters
+	return []referral.Request{{ID: "r1", Status: referral.StatusOpen}}, db.Page{Total: 1, TotalExact: true}, nil
+}
+
+func (s *stubGraphReferrals) GetMany(context.Context, []string, tenancy.Scope) ([]referral.Request, error) {
+	return nil, nil
+}
+
+func TestHandleGraphQL_RunsQueryInCallerScope(t *testing.T) {
+	referrals := &stubGraphReferrals{}
+	schema, err := graph.NewSchema(graph.Sources{Referrals: referrals})
+	if err != nil {
+		t.Fatalf("NewSchema: %v", err)
+	}
+	server := &Server{graph: schema}
+
+	rec := httptest.NewRecorder()
+	server.handleGraphQL(rec, agentRequest(http.MethodPost, "/api/graphql",
+		`{"query":"query($size: Int) { referrals(status: \"open\", pageSize: $size) { total items { id status } } }","variables":{"size":5}}`, auth.RoleAgent))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := referrals.listed; got.Scope != tenancy.User("agent-1") || got.Status != referral.StatusOpen || got.PageSize != 5 || got.Page != 1 {
+		t.Fatalf("unexpected filters %+v", got)
+	}
+
+	var resp struct {
+		Data struct {
+			Referrals struct {
+				Total int
+				Items []struct{ ID, Status string }
+			}
+		}
+		Errors []any
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Errors) > 0 || resp.Data.Referrals.Total != 1 || len(resp.Data.Referrals.Items) != 1 || resp.Data.Referrals.Items[0].ID != "r1" {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+}
+
+func TestHandleGraphQL_RequiresQuery(t *testing.T) {
+	server := &Server{}
+	rec := httptest.NewRecorder()
+	server.handleGraphQL(rec, agentRequest(http.MethodPost, "/api/graphql", `{"variables":{}}`, auth.RoleAgent))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/config"
+	"brokerflow/dispute"
+	"brokerflow/observability"
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+)
+
+// apiHarness serves the real router and middleware chain (Server.handler)
+// over the testsupport fakes, so tests exercise routing, middleware order
+// and authentication the way clients see them. Only the services the flows
+// below use are wired; handlers of other routes would hit nil services
+// unless a test wires them with newAPIHarness's options.
+type apiHarness struct {
+	t          *testing.T
+	url        string
+	server     *Server
+	users      *testsupport.Users
+	referrals  *testsupport.Referrals
+	agreements *testsupport.Agreements
+}
+
+const harnessPassword = "Harness-Passw0rd!"
+
+var harnessCORS = config.CORS{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{"GET", "POST", "PATCH"},
+	AllowedHeaders: []string{"Authorization", "Content-Type", "If-Match"},
+}
+
+func newAPIHarness(t *testing.T, wire ...func(*Server)) *apiHarness {
+	t.Helper()
+	users := testsupport.NewUsers()
+	referrals := testsupport.NewReferrals(users)
+	agreements := testsupport.NewAgreements(referrals, users)
+	server := &Server{
+		authService:     auth.NewService(users, "secret"),
+		referralService: referral.NewService(&testsupport.TxBeginner{}, referrals, nil, nil),
+		matchService:    referral.NewMatchService(testsupport.NewMatches(referrals, users)),
+		agreementCRUD:   agreements,
+		agreementStatus: agreements,
+		disputeService:  testsupport.NewDisputes(agreements).WithSLA(dispute.DefaultSLA()),
+	}
+	for _, w := range wire {
+		w(server)
+	}
+	srv := httptest.NewServer(server.handler(http.NewServeMux(), harnessCORS, observability.NewMetrics()))
+	t.Cleanup(srv.Close)
+	return &apiHarness{t: t, url: srv.URL, server: server, users: users, referrals: referrals, agreements: agreements}
+}
+
+// call sends body (JSON-encoded unless it is a string) with token as the
+// bearer, plus header name/value pairs, and decodes a JSON answer into out
+// when out is non-nil. It returns the response with its body consumed.
+func (h *apiHarness) call(method, path, token string, body any, out any, header ...string) *http.Response {
+	h.t.Helper()
+	var payload io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		payload = strings.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatalf("encode %s %s: %v", method, path, err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, h.url+path, payload)
+	if err != nil {
+		h.t.Fatalf("build %s %s: %v", method, path, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			h.t.Fatalf("%s %s: decode %s: %v", method, path, raw, err)
+		}
+	}
+	return resp
+}
+
+// expect fails the test unless resp has status.
+func (h *apiHarness) expect(resp *http.Response, status int) {
+	h.t.Helper()
+	if resp.StatusCode != status {
+		h.t.Fatalf("%s %s: expected %d, got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+	}
+}
+
+// signUp registers name through the API, attaches them to brokerID (as
+// onboarding by a broker admin would) and logs them in. It returns the
+// user id and a bearer token.
+func (h *apiHarness) signUp(name string, role auth.Role, brokerID string) (string, string) {
+	h.t.Helper()
+	email := strings.ToLower(strings.ReplaceAll(name, " ", ".")) + "@example.com"
+	var registered registerResponse
+	h.expect(h.call(http.MethodPost, "/auth/register", "", map[string]any{
+		"email": email, "password": harnessPassword, "full_name": name, "role": role,
+	}, &registered), http.StatusCreated)
+
+	if brokerID != "" {
+		user, err := h.users.GetUserByID(context.Background(), registered.User.ID)
+		if err != nil {
+			h.t.Fatalf("load %s: %v", name, err)
+		}
+		user.BrokerID = &brokerID
+		h.users.Add(user)
+	}
+
+	var login loginResponse
+	h.expect(h.call(http.MethodPost, "/auth/login", "", map[string]string{"email": email, "password": harnessPassword}, &login), http.StatusOK)
+	if login.Token == "" {
+		h.t.Fatalf("login of %s returned no token", name)
+	}
+	return registered.User.ID, login.Token
+}
+
+// createReferral creates an open referral as token's user and returns its id.
+func (h *apiHarness) createReferral(token string) string {
+	h.t.Helper()
+	var created referralResponse
+	h.expect(h.call(http.MethodPost, "/api/referrals", token, map[string]any{
+		"region": []string{"Austin"}, "priceMin": 400000, "priceMax": 600000,
+		"propertyType": "condo", "dealType": "buy", "slaHours": 48,
+	}, &created), http.StatusCreated)
+	return created.ID
+}
+
+func TestAPI_AuthFlow(t *testing.T) {
+	h := newAPIHarness(t)
+	userID, token := h.signUp("Ada Agent", auth.RoleAgent, "")
+
+	var me agentResponse
+	h.expect(h.call(http.MethodGet, "/api/me", token, nil, &me), http.StatusOK)
+	if me.ID != userID || me.Email != "ada.agent@example.com" || me.Role != auth.RoleAgent {
+		t.Fatalf("unexpected /api/me: %+v", me)
+	}
+
+	h.expect(h.call(http.MethodPost, "/auth/register", "", map[string]any{
+		"email": "ada.agent@example.com", "password": harnessPassword, "full_name": "Ada Again",
+	}, nil), http.StatusConflict)
+	h.expect(h.call(http.MethodPost, "/auth/register", "", map[string]any{
+		"email": "weak@example.com", "password": "short", "full_name": "Weak",
+	}, nil), http.StatusBadRequest)
+	h.expect(h.call(http.MethodPost, "/auth/login", "", map[string]string{
+		"email": "ada.agent@example.com", "password": "Wrong-Passw0rd!",
+	}, nil), http.StatusUnauthorized)
+
+	for name, header := range map[string][]string{
+		"missing":   nil,
+		"malformed": {"Authorization", "Token " + token},
+		"forged":    {"Authorization", "Bearer " + token + "x"},
+	} {
+		var body errorResponse
+		resp := h.call(http.MethodGet, "/api/me", "", nil, &body, header...)
+		if resp.StatusCode != http.StatusUnauthorized || body.Message == "" {
+			t.Errorf("%s authorization: expected 401 with a message, got %d %+v", name, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestAPI_MiddlewareOrder(t *testing.T) {
+	h := newAPIHarness(t)
+
+	// CORS answers preflights before authentication, which browsers never
+	// send credentials to.
+	resp := h.call(http.MethodOptions, "/api/me", "", nil, nil,
+		"Origin", "https://app.example.com", "Access-Control-Request-Method", "GET")
+	h.expect(resp, http.StatusNoContent)
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("expected the origin to be allowed, got %q", got)
+	}
+
+	// Errors from the authentication wrapper still carry the CORS and
+	// locale headers of the outer middleware.
+	var body errorResponse
+	resp = h.call(http.MethodGet, "/api/me", "", nil, &body, "Origin", "https://app.example.com", "Accept-Language", "zh-CN")
+	h.expect(resp, http.StatusUnauthorized)
+	if resp.Header.Get("Access-Control-Allow-Origin") == "" || !strings.Contains(strings.Join(resp.Header.Values("Vary"), ","), "Accept-Language") {
+		t.Fatalf("expected CORS and Vary headers on the 401, got %v", resp.Header)
+	}
+
+	// The body limit applies before the route's handler, and authentication.
+	big := fmt.Sprintf(`{"email":"%s"}`, strings.Repeat("a", int(authMaxBodyBytes)))
+	h.expect(h.call(http.MethodPost, "/auth/login", "", big, nil), http.StatusRequestEntityTooLarge)
+
+	// Unknown methods and paths are answered by the mux, not a handler.
+	h.expect(h.call(http.MethodPut, "/api/me", "", nil, nil), http.StatusMethodNotAllowed)
+	h.expect(h.call(http.MethodGet, "/api/nowhere", "", nil, nil), http.StatusNotFound)
+}
+
+func TestAPI_ReferralToDisputeHappyPath(t *testing.T) {
+	h := newAPIHarness(t)
+	_, referrer := h.signUp("Rita Referrer", auth.RoleAgent, "broker-a")
+	refereeID, referee := h.signUp("Leo Referee", auth.RoleAgent, "broker-b")
+
+	referralID := h.createReferral(referrer)
+
+	var invited matchResponse
+	h.expect(h.call(http.MethodPost, "/api/referrals/"+referralID+"/matches", referrer,
+		map[string]any{"candidateAgentId": refereeID, "score": 0.8}, &invited), http.StatusCreated)
+	if invited.State != string(referral.MatchStateInvited) || invited.ExpiresAt == nil {
+		t.Fatalf("expected a live invitation, got %+v", invited)
+	}
+
+	var inbox matchListResponse
+	h.expect(h.call(http.MethodGet, "/api/matches", referee, nil, &inbox), http.StatusOK)
+	if len(inbox.Items) != 1 || inbox.Items[0].ID != invited.ID {
+		t.Fatalf("expected the referee to see the invitation, got %+v", inbox.Items)
+	}
+	var accepted matchResponse
+	h.expect(h.call(http.MethodPatch, "/api/referrals/"+referralID+"/matches/"+invited.ID, referee,
+		map[string]string{"state": "accepted"}, &accepted), http.StatusOK)
+	if accepted.State != string(referral.MatchStateAccepted) {
+		t.Fatalf("expected the match accepted, got %+v", accepted)
+	}
+
+	var created agreementResponse
+	h.expect(h.call(http.MethodPost, "/api/agreements", referrer, map[string]any{
+		"requestId": referralID, "referrerBrokerId": "broker-a", "refereeBrokerId": "broker-b", "feeRate": 25, "protectDays": 90,
+	}, &created), http.StatusCreated)
+	if created.Status != "draft" {
+		t.Fatalf("expected a draft agreement, got %+v", created)
+	}
+
+	etag := `"1"`
+	for _, next := range []string{"pending_signature", "effective"} {
+		var moved agreementStatusResponse
+		resp := h.call(http.MethodPatch, "/api/agreements", referrer,
+			map[string]string{"agreementId": created.ID, "nextStatus": next}, &moved, "If-Match", etag)
+		h.expect(resp, http.StatusOK)
+		etag = resp.Header.Get("ETag")
+		if moved.NextStatus != next || etag != fmt.Sprintf(`"%d"`, moved.Version) {
+			t.Fatalf("moving to %s: got %+v with ETag %s", next, moved, etag)
+		}
+	}
+
+	var listed paginatedAgreements
+	h.expect(h.call(http.MethodGet, "/api/agreements", referrer, nil, &listed), http.StatusOK)
+	if len(listed.Items) != 1 || listed.Items[0].Status != "effective" || listed.Items[0].EffectiveAt == "" {
+		t.Fatalf("expected the effective agreement, got %+v", listed.Items)
+	}
+
+	var opened disputeResponse
+	h.expect(h.call(http.MethodPost, "/api/disputes", referee, map[string]string{
+		"agreementId": created.ID, "reason": "non_payment", "detail": "Fee not paid after closing.",
+	}, &opened), http.StatusCreated)
+	if opened.Status != string(dispute.StatusUnderReview) || opened.OpenedByRole != string(dispute.RoleReferee) {
+		t.Fatalf("expected an under_review dispute opened by the referee, got %+v", opened)
+	}
+
+	var resolved disputeResponse
+	h.expect(h.call(http.MethodPatch, "/api/disputes/"+opened.ID, referrer,
+		map[string]string{"status": "resolved"}, &resolved), http.StatusOK)
+	if resolved.Status != string(dispute.StatusResolved) || resolved.ResolvedAt == nil {
+		t.Fatalf("expected the dispute resolved, got %+v", resolved)
+	}
+
+	var referrals paginatedReferrals
+	h.expect(h.call(http.MethodGet, "/api/referrals", referrer, nil, &referrals), http.StatusOK)
+	if len(referrals.Items) != 1 || referrals.Items[0].Status != string(referral.StatusDisputed) {
+		t.Fatalf("expected the referral projected to disputed, got %+v", referrals.Items)
+	}
+}
+
+func TestAPI_AuthorizationFailures(t *testing.T) {
+	h := newAPIHarness(t)
+	_, referrer := h.signUp("Rita Referrer", auth.RoleAgent, "broker-a")
+	refereeID, referee := h.signUp("Leo Referee", auth.RoleAgent, "broker-b")
+	outsiderID, outsider := h.signUp("Otto Outsider", auth.RoleAgent, "broker-c")
+	_, client := h.signUp("Cleo Client", auth.RoleClient, "")
+
+	// Clients read their own referrals only; they cannot create any.
+	h.expect(h.call(http.MethodPost, "/api/referrals", client, map[string]any{
+		"region": []string{"Austin"}, "priceMin": 1, "priceMax": 2, "propertyType": "condo", "dealType": "buy", "slaHours": 1,
+	}, nil), http.StatusForbidden)
+
+	referralID := h.createReferral(referrer)
+
+	// Only the owner invites; to anyone else the referral does not exist.
+	h.expect(h.call(http.MethodPost, "/api/referrals/"+referralID+"/matches", outsider,
+		map[string]any{"candidateAgentId": outsiderID}, nil), http.StatusNotFound)
+
+	var invited matchResponse
+	h.expect(h.call(http.MethodPost, "/api/referrals/"+referralID+"/matches", referrer,
+		map[string]any{"candidateAgentId": refereeID}, &invited), http.StatusCreated)
+	// Only the candidate answers an invitation.
+	h.expect(h.call(http.MethodPatch, "/api/referrals/"+referralID+"/matches/"+invited.ID, outsider,
+		map[string]string{"state": "accepted"}, nil), http.StatusForbidden)
+
+	// Only the referral owner creates its agreement.
+	agreementBody := map[string]any{"requestId": referralID, "referrerBrokerId": "broker-a", "refereeBrokerId": "broker-b", "feeRate": 25, "protectDays": 90}
+	h.expect(h.call(http.MethodPost, "/api/agreements", referee, agreementBody, nil), http.StatusForbidden)
+	var created agreementResponse
+	h.expect(h.call(http.MethodPost, "/api/agreements", referrer, agreementBody, &created), http.StatusCreated)
+
+	// Transitions need the ETag, and a stale one is refused with the
+	// current version.
+	move := map[string]string{"agreementId": created.ID, "nextStatus": "pending_signature"}
+	h.expect(h.call(http.MethodPatch, "/api/agreements", referrer, move, nil), http.StatusPreconditionRequired)
+	var conflict agreementConflictResponse
+	h.expect(h.call(http.MethodPatch, "/api/agreements", referrer, move, &conflict, "If-Match", `"7"`), http.StatusPreconditionFailed)
+	if conflict.Version != 1 || conflict.Status != "draft" {
+		t.Fatalf("expected the current version in the conflict, got %+v", conflict)
+	}
+
+	// Outsiders neither see the agreement nor dispute it.
+	var listed paginatedAgreements
+	h.expect(h.call(http.MethodGet, "/api/agreements", outsider, nil, &listed), http.StatusOK)
+	if len(listed.Items) != 0 {
+		t.Fatalf("expected no agreements for an outsider, got %+v", listed.Items)
+	}
+	disputeBody := map[string]string{"agreementId": created.ID, "reason": "other", "detail": "Not my deal."}
+	h.expect(h.call(http.MethodPost, "/api/disputes", outsider, disputeBody, nil), http.StatusNotFound)
+
+	// The referee opens a dispute but only the referral owner resolves it.
+	var opened disputeResponse
+	h.expect(h.call(http.MethodPost, "/api/disputes", referee, disputeBody, &opened), http.StatusCreated)
+	for _, token := range []string{referee, outsider} {
+		h.expect(h.call(http.MethodPatch, "/api/disputes/"+opened.ID, token, map[string]string{"status": "resolved"}, nil), http.StatusNotFound)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"brokerflow/cache"
+	"brokerflow/config"
+	"brokerflow/db"
+	"brokerflow/health"
+	"brokerflow/outbox"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readinessTimeout bounds each /readyz check.
+const readinessTimeout = 2 * time.Second
+
+// newLookupCache builds the broker and user cache named by CACHE_URL. It
+// returns nil, which disables caching, when CACHE_URL is unset.
+func newLookupCache(cfg config.Cache) (cache.Cache, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	return cache.New(cfg.URL)
+}
+
+// readinessChecks wires the dependencies /readyz verifies.
+func readinessChecks(pool *pgxpool.Pool, migrations fs.FS, cfg config.Readiness) []health.Check {
+	heartbeats := outbox.NewHeartbeatRepository(pool)
+
+	return []health.Check{
+		{Name: "database", Run: func(ctx context.Context) error {
+			var one int
+			return pool.QueryRow(ctx, "SELECT 1").Scan(&one)
+		}},
+		{Name: "migrations", Run: func(ctx context.Context) error {
+			return checkMigrations(ctx, pool, migrations)
+		}},
+		{Name: "outbox_worker", Run: func(ctx context.Context) error {
+			age, ok, err := heartbeats.LatestAge(ctx)
+			if err != nil {
+				return err
+			}
+			return checkHeartbeat(age, ok, cfg.HeartbeatMaxAge, cfg.OutboxWorkerRequired)
+		}},
+	}
+}
+
+func checkMigrations(ctx context.Context, pool *pgxpool.Pool, migrations fs.FS) error {
+	shipped, err := db.MigrationVersions(migrations)
+	if err != nil {
+		return err
+	}
+	rows, err := pool.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("load applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]struct{})
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return fmt.Errorf("scan applied migration: %w", err)
+		}
+		applied[version] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate applied migrations: %w", err)
+	}
+
+	var pending []string
+	for _, name := range shipped {
+		if _, ok := applied[name]; !ok {
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// checkHeartbeat judges the freshest outbox worker heartbeat. Without
+// OUTBOX_WORKER_REQUIRED a deployment that has never run a worker is
+// reported as skipped rather than unready.
+func checkHeartbeat(age time.Duration, seen bool, maxAge time.Duration, required bool) error {
+	if !seen {
+		if required {
+			return fmt.Errorf("no outbox worker heartbeat recorded")
+		}
+		return fmt.Errorf("no outbox worker has reported: %w", health.ErrSkipped)
+	}
+	if age > maxAge {
+		return fmt.Errorf("outbox worker heartbeat is %s old (max %s)", age.Round(time.Second), maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/health"
+)
+
+func TestCheckHeartbeat(t *testing.T) {
+	cases := []struct {
+		name     string
+		age      time.Duration
+		seen     bool
+		required bool
+		wantErr  bool
+		skipped  bool
+	}{
+		{name: "fresh", age: 5 * time.Second, seen: true},
+		{name: "stale", age: 2 * time.Minute, seen: true, wantErr: true},
+		{name: "never seen optional", skipped: true, wantErr: true},
+		{name: "never seen required", required: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkHeartbeat(tc.age, tc.seen, time.Minute, tc.required)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if errors.Is(err, health.ErrSkipped) != tc.skipped {
+				t.Fatalf("expected skipped=%t, got %v", tc.skipped, err)
+			}
+		})
+	}
+}
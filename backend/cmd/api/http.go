@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/config"
+	"brokerflow/i18n"
+	"brokerflow/money"
+)
+
+// errorMessages 是错误信息的翻译目录；内嵌文件由测试保证可解析
+var errorMessages = mustLoadMessages()
+
+func mustLoadMessages() *i18n.Catalog {
+	c, err := i18n.Load()
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// corsMiddleware 按 config.CORS 的白名单回应跨域请求：只对允许的 Origin 回显
+// 该 Origin（白名单为 "*" 且不带凭据时回 "*"），预检请求直接返回 204。
+// 响应随 Origin 变化，因此总是带 Vary: Origin，避免缓存把一个站点的结果给另一个
+func corsMiddleware(cfg config.CORS) func(http.Handler) http.Handler {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	wildcard := !cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			h := w.Header()
+			if !wildcard {
+				h.Add("Vary", "Origin")
+			}
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin != "" && cfg.AllowsOrigin(origin) {
+				if wildcard {
+					h.Set("Access-Control-Allow-Origin", "*")
+				} else {
+					h.Set("Access-Control-Allow-Origin", origin)
+				}
+				if cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					h.Set("Access-Control-Allow-Methods", methods)
+					h.Set("Access-Control-Allow-Headers", headers)
+					h.Set("Access-Control-Max-Age", maxAge)
+				} else {
+					h.Set("Access-Control-Expose-Headers", "ETag, Retry-After, "+auth.RefreshedTokenHeader)
+				}
+			}
+
+			// 不允许的 Origin 也回 204，只是不带 CORS 头，由浏览器拒绝
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondJSON 返回 JSON 响应
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// respondError 返回错误响应。目录中有的英文信息附上错误码，并按
+// localeMiddleware 选定的语言翻译；日志始终记录英文原文
+func respondError(w http.ResponseWriter, status int, message string) {
+	log.Printf("HTTP error: status=%d message=%s", status, message)
+	resp := errorResponse{Message: message}
+	if code, ok := errorMessages.Code(message); ok {
+		resp.Code = code
+		resp.Message = localizedMessage(w, code, message)
+	}
+	respondJSON(w, status, resp)
+}
+
+// localizedMessage 返回 code 在响应语言下的信息，args 为占位符的名值对；
+// 默认语言或目录中没有 code 时返回 fallback
+func localizedMessage(w http.ResponseWriter, code, fallback string, args ...string) string {
+	lang := responseLanguage(w)
+	if lang == i18n.DefaultLanguage {
+		return fallback
+	}
+	if message, ok := errorMessages.Message(lang, code, args...); ok {
+		return message
+	}
+	return fallback
+}
+
+// localeMiddleware 按 Accept-Language 选定错误信息的语言
+func localeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+		lang := errorMessages.Negotiate(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(&localeWriter{ResponseWriter: w, lang: lang}, r)
+	})
+}
+
+// localeWriter 携带 localeMiddleware 选定的语言
+type localeWriter struct {
+	http.ResponseWriter
+	lang string
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (lw *localeWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// responseLanguage 沿 Unwrap 链查找 localeWriter；不经过该中间件时为默认语言
+func responseLanguage(w http.ResponseWriter) string {
+	for {
+		switch v := w.(type) {
+		case *localeWriter:
+			return v.lang
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return i18n.DefaultLanguage
+		}
+	}
+}
+
+// requestLocale 取 Accept-Language 的首选语言，用于格式化金额
+func requestLocale(r *http.Request) money.Locale {
+	return money.FromAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// setETag 以行版本号作为强 ETag
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatchVersion 解析 If-Match 头。缺失时 ok 为 false；"*" 返回 0（不校验）；
+// 弱标签或无法解析的值返回 -1，永远不会匹配。
+func ifMatchVersion(r *http.Request) (version int, ok bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return 0, false
+	}
+	if value == "*" {
+		return 0, true
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return -1, true
+	}
+	n, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || n <= 0 {
+		return -1, true
+	}
+	return n, true
+}
+
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(lrw, r)
+		duration := time.Since(start)
+		log.Printf("HTTP %s %s -> %d (%s)", r.Method, r.URL.Path, lrw.statusCode, duration)
+	})
+}
+
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (lrw *loggingResponseWriter) WriteHeader(code int) {
+	lrw.statusCode = code
+	lrw.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming handlers working behind the middleware.
+func (lrw *loggingResponseWriter) Flush() {
+	if f, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+	// Code identifies errors clients handle programmatically, such as
+	// deadline_exceeded.
+	Code string `json:"code,omitempty"`
+	// Field names the request body field an unknown_field or invalid_field
+	// error is about.
+	Field string `json:"field,omitempty"`
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/config"
+)
+
+func TestLocaleMiddleware_TranslatesErrorsByCode(t *testing.T) {
+	s := &Server{timeouts: requestTimeouts{Default: 10 * time.Millisecond}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/referrals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusNotFound, "Referral not found")
+	})
+	mux.HandleFunc("GET /api/brokers", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		respondError(w, http.StatusInternalServerError, "Failed to load brokers")
+	})
+	mux.HandleFunc("GET /api/agreements", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusInternalServerError, "Failed to load agreements")
+	})
+	handler := localeMiddleware(s.deadlineMiddleware(mux))
+
+	cases := []struct {
+		path, lang        string
+		wantCode, wantMsg string
+	}{
+		{"/api/referrals/1", "", "referral_not_found", "Referral not found"},
+		{"/api/referrals/1", "fr-CA,en;q=0.5", "referral_not_found", "Recommandation introuvable"},
+		{"/api/referrals/1", "de, zh;q=0.8", "referral_not_found", "referral 不存在"},
+		{"/api/brokers", "zh-CN", errCodeDeadlineExceeded, "请求超过了 10ms 的时限"},
+		// Messages outside the catalog pass through untranslated and uncoded.
+		{"/api/agreements", "fr", "", "Failed to load agreements"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.lang != "" {
+			req.Header.Set("Accept-Language", tc.lang)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var body errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %q: decode: %v (%s)", tc.path, tc.lang, err, rec.Body.String())
+		}
+		if body.Code != tc.wantCode || body.Message != tc.wantMsg {
+			t.Errorf("%s %q: got %+v, want code %q message %q", tc.path, tc.lang, body, tc.wantCode, tc.wantMsg)
+		}
+		if rec.Header().Get("Vary") != "Accept-Language" {
+			t.Errorf("%s %q: expected Vary: Accept-Language", tc.path, tc.lang)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cors := config.CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	reached := false
+	handler := corsMiddleware(cors)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/referrals", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || reached {
+		t.Fatalf("expected the preflight to be answered directly, got %d", rec.Code)
+	}
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, POST" || h.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+		h.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("unexpected preflight headers %v", h)
+	}
+	if vary := h.Values("Vary"); len(vary) != 3 || vary[0] != "Origin" {
+		t.Fatalf("unexpected Vary %v", vary)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/referrals", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "Origin" {
+		t.Fatalf("expected no CORS grant for an unlisted origin, got %v", rec.Header())
+	}
+
+	public := corsMiddleware(config.CORS{AllowedOrigins: []string{"*"}})(handler)
+	rec = httptest.NewRecorder()
+	req.Header.Set("Origin", "https://anyone.example")
+	public.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("expected a wildcard grant without credentials, got %v", rec.Header())
+	}
+}
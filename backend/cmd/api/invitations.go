@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/invitation"
+	"github.com/google/uuid"
+)
+
+type invitationService interface {
+	Create(ctx context.Context, params invitation.CreateParams) (invitation.Invitation, string, error)
+	List(ctx context.Context, brokerID string) ([]invitation.Invitation, error)
+	Revoke(ctx context.Context, brokerID, id string) error
+	Accept(ctx context.Context, params invitation.AcceptParams) (auth.User, error)
+	Now() time.Time
+}
+
+type createInvitationRequest struct {
+	Email string    `json:"email"`
+	Role  auth.Role `json:"role,omitempty" doc:"agent (default) or broker_admin"`
+}
+
+// acceptInvitationRequest follows the field names of auth.RegisterRequest.
+type acceptInvitationRequest struct {
+	Token    string `json:"token" doc:"Token from the invitation email"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+}
+
+type invitationResponse struct {
+	ID         string  `json:"id"`
+	BrokerID   string  `json:"brokerId"`
+	Email      string  `json:"email"`
+	Role       string  `json:"role"`
+	Status     string  `json:"status" doc:"pending, accepted, revoked or expired"`
+	InvitedBy  string  `json:"invitedBy"`
+	ExpiresAt  string  `json:"expiresAt"`
+	AcceptedAt *string `json:"acceptedAt,omitempty"`
+	AcceptedBy *string `json:"acceptedBy,omitempty"`
+	RevokedAt  *string `json:"revokedAt,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+	Token      string  `json:"token,omitempty" doc:"Registration token, also emailed to the invitee; returned only when the invitation is created"`
+}
+
+type invitationListResponse struct {
+	Items []invitationResponse `json:"items"`
+}
+
+func newInvitationResponse(inv invitation.Invitation, now time.Time) invitationResponse {
+	resp := invitationResponse{
+		ID:         inv.ID,
+		BrokerID:   inv.BrokerID,
+		Email:      inv.Email,
+		Role:       string(inv.Role),
+		Status:     inv.Status(now),
+		InvitedBy:  inv.InvitedBy,
+		ExpiresAt:  inv.ExpiresAt.UTC().Format(time.RFC3339),
+		AcceptedBy: inv.AcceptedBy,
+		CreatedAt:  inv.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if inv.AcceptedAt != nil {
+		val := inv.AcceptedAt.UTC().Format(time.RFC3339)
+		resp.AcceptedAt = &val
+	}
+	if inv.RevokedAt != nil {
+		val := inv.RevokedAt.UTC().Format(time.RFC3339)
+		resp.RevokedAt = &val
+	}
+	return resp
+}
+
+// handleCreateInvitation invites an email address to the caller's broker.
+// The invitee is emailed a registration link; the response carries the same
+// token so the admin can share it another way.
+func (s *Server) handleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	var req createInvitationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	inv, token, err := s.invitations.Create(ctx, invitation.CreateParams{
+		BrokerID:  brokerID,
+		InvitedBy: userID,
+		Email:     req.Email,
+		Role:      req.Role,
+	})
+	if err != nil {
+		respondServiceError(w, err, "Failed to create invitation")
+		return
+	}
+	resp := newInvitationResponse(inv, s.invitations.Now())
+	resp.Token = token
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleListInvitations(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	list, err := s.invitations.List(ctx, brokerID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load invitations")
+		return
+	}
+	now := s.invitations.Now()
+	items := make([]invitationResponse, 0, len(list))
+	for _, inv := range list {
+		items = append(items, newInvitationResponse(inv, now))
+	}
+	respondJSON(w, http.StatusOK, invitationListResponse{Items: items})
+}
+
+// handleRevokeInvitation withdraws a pending invitation; its link stops
+// working.
+func (s *Server) handleRevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	invitationID := r.PathValue("invitationId")
+	if _, err := uuid.Parse(invitationID); err != nil {
+		respondError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.invitations.Revoke(ctx, brokerID, invitationID); err != nil {
+		respondServiceError(w, err, "Failed to revoke invitation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAcceptInvitation registers the invitee of a valid token. The
+// account takes the invitation's email and role and belongs to its broker
+// from the start.
+func (s *Server) handleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req acceptInvitationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := s.invitations.Accept(ctx, invitation.AcceptParams{
+		Token:    req.Token,
+		FullName: req.FullName,
+		Password: req.Password,
+	})
+	if err != nil {
+		respondServiceError(w, err, "Registration failed")
+		return
+	}
+	respondJSON(w, http.StatusCreated, registerResponse{User: newAgentResponse(user)})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/invitation"
+)
+
+type stubInvitations struct {
+	created   invitation.CreateParams
+	createErr error
+	accepted  invitation.AcceptParams
+	acceptErr error
+}
+
+var invitationNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func (s *stubInvitations) Create(_ context.Context, params invitation.CreateParams) (invitation.Invitation, string, error) {
+	s.created = params
+	if s.createErr != nil {
+		return invitation.Invitation{}, "", s.createErr
+	}
+	return invitation.Invitation{
+		ID: "inv-1", BrokerID: params.BrokerID, Email: params.Email, Role: auth.RoleAgent,
+		InvitedBy: params.InvitedBy, ExpiresAt: invitationNow.Add(invitation.DefaultTTL), CreatedAt: invitationNow,
+	}, "inv-1.123.sig", nil
+}
+
+func (s *stubInvitations) List(context.Context, string) ([]invitation.Invitation, error) {
+	return []invitation.Invitation{{ID: "inv-1", ExpiresAt: invitationNow.Add(-time.Hour)}}, nil
+}
+
+func (s *stubInvitations) Revoke(context.Context, string, string) error { return nil }
+
+func (s *stubInvitations) Accept(_ context.Context, params invitation.AcceptParams) (auth.User, error) {
+	s.accepted = params
+	if s.acceptErr != nil {
+		return auth.User{}, s.acceptErr
+	}
+	broker := "broker-1"
+	return auth.User{ID: "user-1", Email: "ana@example.com", FullName: params.FullName, Role: auth.RoleAgent, BrokerID: &broker}, nil
+}
+
+func (s *stubInvitations) Now() time.Time { return invitationNow }
+
+func invitationTestServer(stub *stubInvitations) *Server {
+	server := webhookTestServer(&stubWebhooks{})
+	server.invitations = stub
+	return server
+}
+
+func TestHandleCreateInvitation(t *testing.T) {
+	stub := &stubInvitations{}
+	server := invitationTestServer(stub)
+
+	rec := httptest.NewRecorder()
+	server.handleCreateInvitation(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/invitations",
+		`{"email":"ana@example.com"}`, "broker-1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp invitationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token != "inv-1.123.sig" || resp.Status != invitation.StatusPending || resp.ExpiresAt != "2026-05-08T12:00:00Z" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if stub.created.BrokerID != "broker-1" || stub.created.InvitedBy != "admin-1" {
+		t.Fatalf("unexpected params %+v", stub.created)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleCreateInvitation(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-2/invitations",
+		`{"email":"ana@example.com"}`, "broker-2"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("other broker: expected 403, got %d", rec.Code)
+	}
+
+	stub.createErr = invitation.ErrEmailRegistered
+	rec = httptest.NewRecorder()
+	server.handleCreateInvitation(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/invitations",
+		`{"email":"ana@example.com"}`, "broker-1"))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("registered email: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestHandleListInvitationsOmitsToken(t *testing.T) {
+	server := invitationTestServer(&stubInvitations{})
+
+	rec := httptest.NewRecorder()
+	server.handleListInvitations(rec, brokerAdminRequest(http.MethodGet, "/api/brokers/broker-1/invitations", "", "broker-1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), `"token"`) || !strings.Contains(rec.Body.String(), `"status":"expired"`) {
+		t.Fatalf("unexpected body %s", rec.Body.String())
+	}
+}
+
+func TestHandleAcceptInvitation(t *testing.T) {
+	stub := &stubInvitations{}
+	server := &Server{invitations: stub}
+
+	body := `{"token":"inv-1.123.sig","password":"correct horse","full_name":"Ana Agent"}`
+	rec := httptest.NewRecorder()
+	server.handleAcceptInvitation(rec, httptest.NewRequest(http.MethodPost, "/auth/register/invitation", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.accepted.Token != "inv-1.123.sig" || stub.accepted.FullName != "Ana Agent" {
+		t.Fatalf("unexpected params %+v", stub.accepted)
+	}
+
+	stub.acceptErr = invitation.ErrInvalidToken
+	rec = httptest.NewRecorder()
+	server.handleAcceptInvitation(rec, httptest.NewRequest(http.MethodPost, "/auth/register/invitation", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid token: expected 400, got %d", rec.Code)
+	}
+}
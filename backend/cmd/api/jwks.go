@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+
+	"brokerflow/auth"
+)
+
+// jwksMaxAge is how long verifiers may cache the key set. Publish a new key
+// at least this long before making it the active one.
+const jwksMaxAge = "300"
+
+type jwksResponse struct {
+	Keys []auth.JWK `json:"keys" doc:"RS256 public keys; HS256 keys are never published"`
+}
+
+// handleJWKS publishes the public keys other services verify tokens with.
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age="+jwksMaxAge)
+	respondJSON(w, http.StatusOK, jwksResponse{Keys: s.authService.JWKS()})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+// clientIP is the peer address used for login attempt tracking. Forwarded
+// headers are ignored because clients can set them to dodge IP throttling.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// respondLockout answers a login refused by brute-force protection and
+// reports whether err was such a refusal.
+func respondLockout(w http.ResponseWriter, err error) bool {
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		retry := int(math.Ceil(time.Until(locked.Until).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+		respondError(w, http.StatusTooManyRequests, "Account temporarily locked after repeated failed logins")
+		return true
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		respondError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return true
+	}
+	return false
+}
+
+type lockoutResponse struct {
+	UserID      string  `json:"userId"`
+	FailedCount int     `json:"failedCount"`
+	LockedUntil *string `json:"lockedUntil,omitempty"`
+}
+
+func newLockoutResponse(lo auth.Lockout) lockoutResponse {
+	resp := lockoutResponse{UserID: lo.UserID, FailedCount: lo.FailedCount}
+	if lo.LockedUntil != nil {
+		val := lo.LockedUntil.UTC().Format(time.RFC3339)
+		resp.LockedUntil = &val
+	}
+	return resp
+}
+
+// handleUnlockUser lifts a login lock early. Broker admins may unlock users
+// of their own brokerage only; others get 404 so accounts cannot be probed.
+func (s *Server) handleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermUsersUnlock) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	targetID := r.PathValue("id")
+	if _, err := uuid.Parse(targetID); err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	admin, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	target, err := s.authService.GetUserByID(ctx, targetID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	if admin.BrokerID == nil || target.BrokerID == nil || *admin.BrokerID != *target.BrokerID {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	lo, err := s.authService.Unlock(ctx, targetID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unlock user")
+		return
+	}
+	respondJSON(w, http.StatusOK, newLockoutResponse(lo))
+}
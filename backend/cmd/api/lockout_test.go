@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+)
+
+func TestRespondLockout(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if !respondLockout(rec, &auth.LockedError{Until: time.Now().Add(90 * time.Second)}) {
+		t.Fatal("expected locked error to be handled")
+	}
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if respondLockout(httptest.NewRecorder(), errors.New("other")) {
+		t.Fatal("unrelated errors must fall through")
+	}
+}
+
+func TestHandleUnlockUser_OtherBrokerage(t *testing.T) {
+	own, other := "broker-1", "broker-2"
+	const targetID = "11111111-1111-1111-1111-111111111111"
+	server := &Server{authService: auth.NewService(&stubUserRepo{users: map[string]auth.User{
+		"admin-1": {ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &own},
+		targetID:  {ID: targetID, Role: auth.RoleAgent, BrokerID: &other},
+	}}, "secret")}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/admin/users/{id}/unlock", server.handleUnlockUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+targetID+"/unlock", nil)
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "admin-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleBrokerAdmin)
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another brokerage's user, got %d", rec.Code)
+	}
+
+	own2 := own
+	server.authService = auth.NewService(&stubUserRepo{users: map[string]auth.User{
+		"admin-1": {ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &own},
+		targetID:  {ID: targetID, Role: auth.RoleAgent, BrokerID: &own2},
+	}}, "secret")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for own brokerage's user, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
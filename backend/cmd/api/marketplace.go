@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+	"brokerflow/region"
+	"github.com/google/uuid"
+)
+
+type marketplaceService interface {
+	Subscribe(ctx context.Context, userID string, regions, languages []string) (referral.MarketplaceSubscription, error)
+	Unsubscribe(ctx context.Context, userID string) error
+	List(ctx context.Context, userID string, page, pageSize int) ([]referral.Listing, int, error)
+	Apply(ctx context.Context, userID, requestID string) (referral.Match, error)
+}
+
+type marketplaceSubscriptionRequest struct {
+	Regions   []string `json:"regions"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+type marketplaceSubscriptionResponse struct {
+	Regions   []string `json:"regions"`
+	Languages []string `json:"languages"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// listingResponse is a referral as shown in the marketplace; it deliberately
+// omits the creator.
+type listingResponse struct {
+	ID           string   `json:"id"`
+	Region       []string `json:"region"`
+	PriceMin     int64    `json:"priceMin"`
+	PriceMax     int64    `json:"priceMax"`
+	Currency     string   `json:"currency" doc:"ISO 4217 code of priceMin and priceMax"`
+	PropertyType string   `json:"propertyType"`
+	DealType     string   `json:"dealType"`
+	Languages    []string `json:"languages"`
+	SLAHours     int      `json:"slaHours"`
+	CreatedAt    string   `json:"createdAt"`
+}
+
+type paginatedListings struct {
+	Items    []listingResponse `json:"items"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+}
+
+func newListingResponse(l referral.Listing) listingResponse {
+	region := append([]string{}, l.Region...)
+	languages := append([]string{}, l.Languages...)
+	return listingResponse{
+		ID:           l.ID,
+		Region:       region,
+		PriceMin:     l.PriceMin,
+		PriceMax:     l.PriceMax,
+		Currency:     string(l.Currency),
+		PropertyType: l.PropertyType,
+		DealType:     l.DealType,
+		Languages:    languages,
+		SLAHours:     l.SLAHours,
+		CreatedAt:    l.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// agentCaller returns the calling agent or broker admin, or writes the error
+// response for anyone else.
+func agentCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return "", false
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return "", false
+	}
+	return userID, true
+}
+
+// handleSubscribeMarketplace opts the caller in to the marketplace feed, or
+// replaces the regions and languages of an existing subscription.
+func (s *Server) handleSubscribeMarketplace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+	var req marketplaceSubscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	sub, err := s.marketplace.Subscribe(ctx, userID, req.Regions, req.Languages)
+	if err != nil {
+		if errors.Is(err, referral.ErrMarketplaceRegions) || errors.Is(err, region.ErrUnknownRegion) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to save marketplace subscription")
+		return
+	}
+	respondJSON(w, http.StatusOK, marketplaceSubscriptionResponse{
+		Regions:   append([]string{}, sub.Regions...),
+		Languages: append([]string{}, sub.Languages...),
+		CreatedAt: sub.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: sub.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// handleUnsubscribeMarketplace opts the caller out. Applications already
+// made are kept.
+func (s *Server) handleUnsubscribeMarketplace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.marketplace.Unsubscribe(ctx, userID); err != nil {
+		if errors.Is(err, referral.ErrMarketplaceNotSubscribed) {
+			respondError(w, http.StatusNotFound, "Not subscribed to the marketplace")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete marketplace subscription")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListMarketplace lists open referrals matching the caller's
+// subscription, newest first.
+func (s *Server) handleListMarketplace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	ctx := r.Context()
+
+	listings, total, err := s.marketplace.List(ctx, userID, page, pageSize)
+	if err != nil {
+		if errors.Is(err, referral.ErrMarketplaceNotSubscribed) {
+			respondError(w, http.StatusForbidden, "Subscribe to the marketplace first")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load marketplace")
+		return
+	}
+
+	items := make([]listingResponse, 0, len(listings))
+	for _, l := range listings {
+		items = append(items, newListingResponse(l))
+	}
+	respondJSON(w, http.StatusOK, paginatedListings{Items: items, Total: total, Page: page, PageSize: pageSize})
+}
+
+// handleApplyMarketplace applies to a listing. The resulting match is in
+// state applied until the referral owner invites the caller.
+func (s *Server) handleApplyMarketplace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+	requestID := r.PathValue("id")
+	if _, err := uuid.Parse(requestID); err != nil {
+		respondError(w, http.StatusNotFound, "Listing not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	match, err := s.marketplace.Apply(ctx, userID, requestID)
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrMarketplaceNotSubscribed):
+			respondError(w, http.StatusForbidden, "Subscribe to the marketplace first")
+		case errors.Is(err, referral.ErrListingNotFound):
+			respondError(w, http.StatusNotFound, "Listing not found")
+		case errors.Is(err, referral.ErrMatchDuplicate):
+			respondError(w, http.StatusConflict, "Already matched to this referral")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to apply")
+		}
+		return
+	}
+	respondJSON(w, http.StatusCreated, newMatchResponse(match))
+}
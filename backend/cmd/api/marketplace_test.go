@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+)
+
+type stubMarketplace struct {
+	regions, languages []string
+	listings           []referral.Listing
+	total              int
+	listErr            error
+	applied            referral.Match
+	applyErr           error
+}
+
+func (s *stubMarketplace) Subscribe(_ context.Context, userID string, regions, languages []string) (referral.MarketplaceSubscription, error) {
+	s.regions, s.languages = regions, languages
+	return referral.MarketplaceSubscription{UserID: userID, Regions: regions, Languages: languages}, nil
+}
+
+func (s *stubMarketplace) Unsubscribe(context.Context, string) error { return nil }
+
+func (s *stubMarketplace) List(context.Context, string, int, int) ([]referral.Listing, int, error) {
+	return s.listings, s.total, s.listErr
+}
+
+func (s *stubMarketplace) Apply(context.Context, string, string) (referral.Match, error) {
+	return s.applied, s.applyErr
+}
+
+func agentRequest(method, path, body string, role auth.Role) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "agent-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, role)
+	return req.WithContext(ctx)
+}
+
+func TestHandleListMarketplace_OmitsCreator(t *testing.T) {
+	stub := &stubMarketplace{total: 1, listings: []referral.Listing{{
+		ID: "r1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, PropertyType: "condo", DealType: "buy", SLAHours: 24, CreatedAt: time.Now(),
+	}}}
+	server := &Server{marketplace: stub}
+	rec := httptest.NewRecorder()
+
+	server.handleListMarketplace(rec, agentRequest(http.MethodGet, "/api/marketplace/referrals", "", auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "creator") {
+		t.Fatalf("listing must not identify its creator: %s", rec.Body.String())
+	}
+	var resp paginatedListings
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Items) != 1 || resp.Items[0].ID != "r1" || resp.Items[0].Languages == nil {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleListMarketplace_Errors(t *testing.T) {
+	server := &Server{marketplace: &stubMarketplace{listErr: referral.ErrMarketplaceNotSubscribed}}
+	rec := httptest.NewRecorder()
+	server.handleListMarketplace(rec, agentRequest(http.MethodGet, "/api/marketplace/referrals", "", auth.RoleAgent))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 when not subscribed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleListMarketplace(rec, agentRequest(http.MethodGet, "/api/marketplace/referrals", "", auth.RoleClient))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for clients, got %d", rec.Code)
+	}
+}
+
+func TestHandleSubscribeMarketplace(t *testing.T) {
+	stub := &stubMarketplace{}
+	server := &Server{marketplace: stub}
+	rec := httptest.NewRecorder()
+
+	server.handleSubscribeMarketplace(rec, agentRequest(http.MethodPut, "/api/marketplace/subscription", `{"regions":["Austin"],"languages":["Spanish"]}`, auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(stub.regions) != 1 || stub.regions[0] != "Austin" || len(stub.languages) != 1 {
+		t.Fatalf("unexpected subscription %v %v", stub.regions, stub.languages)
+	}
+}
+
+func TestHandleApplyMarketplace(t *testing.T) {
+	const requestID = "33333333-3333-3333-3333-333333333333"
+	cases := []struct {
+		name, path string
+		err        error
+		want       int
+	}{
+		{"applied", "/api/marketplace/referrals/" + requestID + "/apply", nil, http.StatusCreated},
+		{"not subscribed", "/api/marketplace/referrals/" + requestID + "/apply", referral.ErrMarketplaceNotSubscribed, http.StatusForbidden},
+		{"not visible", "/api/marketplace/referrals/" + requestID + "/apply", referral.ErrListingNotFound, http.StatusNotFound},
+		{"already matched", "/api/marketplace/referrals/" + requestID + "/apply", referral.ErrMatchDuplicate, http.StatusConflict},
+		{"bad id", "/api/marketplace/referrals/nope/apply", nil, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		stub := &stubMarketplace{
+			applied:  referral.Match{ID: "m1", RequestID: requestID, CandidateAgentID: "agent-1", State: referral.MatchStateApplied},
+			applyErr: tc.err,
+		}
+		server := &Server{marketplace: stub}
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/marketplace/referrals/{id}/apply", server.handleApplyMarketplace)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, agentRequest(http.MethodPost, tc.path, "", auth.RoleAgent))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+		if tc.want == http.StatusCreated && !strings.Contains(rec.Body.String(), `"state":"applied"`) {
+			t.Errorf("%s: expected applied match, got %s", tc.name, rec.Body.String())
+		}
+	}
+}
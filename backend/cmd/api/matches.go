@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/referral"
+)
+
+type createMatchRequest struct {
+	CandidateAgentID string  `json:"candidateAgentId"`
+	Score            float64 `json:"score,omitempty"`
+	State            string  `json:"state,omitempty"`
+}
+
+type updateMatchRequest struct {
+	State         string `json:"state"`
+	DeclineReason string `json:"declineReason,omitempty"`
+	DeclineNote   string `json:"declineNote,omitempty"`
+	// FeeRate and ProtectDays are the terms of a counter-offer.
+	FeeRate     float64 `json:"feeRate,omitempty"`
+	ProtectDays int     `json:"protectDays,omitempty"`
+}
+
+type respondCounterRequest struct {
+	Status string `json:"status" doc:"accepted or rejected"`
+}
+
+func (s *Server) handleListMatches(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+
+	matches, err := s.matchService.List(ctx, requestID, scope)
+	if err != nil {
+		if errors.Is(err, referral.ErrReferralNotOwned) {
+			respondError(w, http.StatusNotFound, "Referral not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load matches")
+		return
+	}
+
+	resp := make([]matchResponse, 0, len(matches))
+	for _, m := range matches {
+		resp = append(resp, newMatchResponse(m))
+	}
+
+	respondJSON(w, http.StatusOK, matchListResponse{Items: resp})
+}
+
+func (s *Server) handleCreateMatch(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req createMatchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	match, err := s.matchService.Create(ctx, referral.CreateMatchParams{
+		RequestID:        requestID,
+		OwnerUserID:      userID,
+		CandidateAgentID: req.CandidateAgentID,
+		Score:            req.Score,
+		State:            referral.MatchState(req.State),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrCandidateMandatory), errors.Is(err, referral.ErrMatchInvalidScore), errors.Is(err, referral.ErrMatchInvalidState):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, referral.ErrReferralNotOwned):
+			respondError(w, http.StatusNotFound, "Referral not found")
+		case errors.Is(err, referral.ErrMatchDuplicate):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to create match")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, newMatchResponse(match))
+}
+
+func (s *Server) handleUpdateMatch(w http.ResponseWriter, r *http.Request) {
+	requestID, matchID := r.PathValue("id"), r.PathValue("matchId")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	var req updateMatchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	state := referral.MatchState(strings.ToLower(strings.TrimSpace(req.State)))
+	if state != referral.MatchStateAccepted && state != referral.MatchStateDeclined && state != referral.MatchStateCountered {
+		respondError(w, http.StatusBadRequest, "state must be 'accepted', 'declined' or 'countered'")
+		return
+	}
+
+	ctx := r.Context()
+
+	result, err := s.matchService.UpdateState(ctx, referral.UpdateMatchParams{
+		MatchID:     matchID,
+		CandidateID: userID,
+		NewState:    state,
+		Decline:     referral.Decline{Reason: referral.DeclineReason(req.DeclineReason), Note: req.DeclineNote},
+		Counter:     referral.CounterTerms{FeeRate: req.FeeRate, ProtectDays: req.ProtectDays},
+	})
+	if err != nil {
+		var violation *broker.PolicyViolation
+		switch {
+		case errors.As(err, &violation):
+			respondPolicyViolation(w, violation)
+		case errors.Is(err, referral.ErrMatchNotFound):
+			respondError(w, http.StatusNotFound, "Match not found")
+		case errors.Is(err, referral.ErrMatchForbidden):
+			respondError(w, http.StatusForbidden, "Insufficient permissions")
+		case errors.Is(err, referral.ErrMatchInvalidTransition),
+			errors.Is(err, referral.ErrInvalidDeclineReason),
+			errors.Is(err, referral.ErrDeclineNoteRequired),
+			errors.Is(err, referral.ErrDeclineNoteTooLong),
+			errors.Is(err, referral.ErrDeclineNotDeclining),
+			errors.Is(err, referral.ErrCounterInvalidTerms),
+			errors.Is(err, referral.ErrCounterNotCountering):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, referral.ErrMatchExpired), errors.Is(err, agreement.ErrActiveAgreementExists):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to update match")
+		}
+		return
+	}
+	if result.Match.RequestID != requestID {
+		respondError(w, http.StatusNotFound, "Match not found")
+		return
+	}
+
+	resp := newMatchResponse(result.Match)
+	if result.Agreement != nil {
+		ar := newAgreementResponse(*result.Agreement)
+		resp.Agreement = &ar
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handleRespondToCounter lets the referral owner accept or reject a
+// candidate's counter-offer. Accepting creates the agreement on the
+// countered terms; rejecting re-opens the invitation.
+func (s *Server) handleRespondToCounter(w http.ResponseWriter, r *http.Request) {
+	requestID, matchID := r.PathValue("id"), r.PathValue("matchId")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	var req respondCounterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if status != "accepted" && status != "rejected" {
+		respondError(w, http.StatusBadRequest, "status must be 'accepted' or 'rejected'")
+		return
+	}
+
+	ctx := r.Context()
+
+	result, err := s.matchService.RespondToCounter(ctx, referral.RespondCounterParams{
+		MatchID:     matchID,
+		OwnerUserID: userID,
+		Accept:      status == "accepted",
+	})
+	if err != nil {
+		var violation *broker.PolicyViolation
+		switch {
+		case errors.As(err, &violation):
+			respondPolicyViolation(w, violation)
+		case errors.Is(err, referral.ErrMatchNotFound), errors.Is(err, referral.ErrReferralNotOwned):
+			respondError(w, http.StatusNotFound, "Match not found")
+		case errors.Is(err, referral.ErrCounterNotPending), errors.Is(err, agreement.ErrActiveAgreementExists):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to answer counter-offer")
+		}
+		return
+	}
+	if result.Match.RequestID != requestID {
+		respondError(w, http.StatusNotFound, "Match not found")
+		return
+	}
+
+	resp := newMatchResponse(result.Match)
+	if result.Agreement != nil {
+		ar := newAgreementResponse(*result.Agreement)
+		resp.Agreement = &ar
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCandidateMatches(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	ctx := r.Context()
+
+	matches, err := s.matchService.ListForCandidate(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load matches")
+		return
+	}
+
+	resp := make([]matchResponse, 0, len(matches))
+	for _, m := range matches {
+		resp = append(resp, newMatchResponse(m))
+	}
+
+	respondJSON(w, http.StatusOK, matchListResponse{Items: resp})
+}
+
+type matchResponse struct {
+	ID               string  `json:"id"`
+	CandidateAgentID string  `json:"candidateAgentId"`
+	State            string  `json:"state"`
+	Score            float64 `json:"score"`
+	CreatedAt        string  `json:"createdAt"`
+	ExpiresAt        *string `json:"expiresAt,omitempty"`
+	DeclineReason    *string `json:"declineReason,omitempty"`
+	DeclineNote      *string `json:"declineNote,omitempty"`
+	// CounterFeeRate and CounterProtectDays are the candidate's counter-offer.
+	CounterFeeRate     *float64           `json:"counterFeeRate,omitempty"`
+	CounterProtectDays *int               `json:"counterProtectDays,omitempty"`
+	Agreement          *agreementResponse `json:"agreement,omitempty"`
+}
+
+type matchListResponse struct {
+	Items []matchResponse `json:"items"`
+}
+
+func newMatchResponse(m referral.Match) matchResponse {
+	resp := matchResponse{
+		ID:               m.ID,
+		CandidateAgentID: m.CandidateAgentID,
+		State:            string(m.State),
+		Score:            m.Score,
+		CreatedAt:        m.CreatedAt.UTC().Format(time.RFC3339),
+		Agreement:        nil,
+	}
+	if m.ExpiresAt != nil {
+		val := m.ExpiresAt.UTC().Format(time.RFC3339)
+		resp.ExpiresAt = &val
+	}
+	if m.DeclineReason != nil {
+		val := string(*m.DeclineReason)
+		resp.DeclineReason = &val
+		resp.DeclineNote = m.DeclineNote
+	}
+	resp.CounterFeeRate = m.CounterFeeRate
+	resp.CounterProtectDays = m.CounterProtectDays
+	return resp
+}
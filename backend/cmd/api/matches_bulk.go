@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"brokerflow/referral"
+	"github.com/google/uuid"
+)
+
+type bulkCreateMatchesRequest struct {
+	Candidates []createMatchRequest `json:"candidates"`
+}
+
+type bulkMatchItemResponse struct {
+	CandidateAgentID string         `json:"candidateAgentId"`
+	Status           string         `json:"status"`
+	Match            *matchResponse `json:"match,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+type bulkMatchResponse struct {
+	Items   []bulkMatchItemResponse `json:"items"`
+	Created int                     `json:"created"`
+}
+
+// handleBulkCreateMatches invites several candidates at once. Items are
+// reported in request order with status created, duplicate or invalid; an
+// invalid item does not prevent the others from being created.
+func (s *Server) handleBulkCreateMatches(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	requestID := r.PathValue("id")
+	if _, err := uuid.Parse(requestID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+
+	var req bulkCreateMatchesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	candidates := make([]referral.BulkMatchCandidate, 0, len(req.Candidates))
+	for _, c := range req.Candidates {
+		candidates = append(candidates, referral.BulkMatchCandidate{
+			CandidateAgentID: c.CandidateAgentID,
+			Score:            c.Score,
+			State:            referral.MatchState(c.State),
+		})
+	}
+
+	ctx := r.Context()
+
+	results, err := s.matchService.CreateBulk(ctx, referral.BulkCreateMatchParams{
+		RequestID:   requestID,
+		OwnerUserID: userID,
+		Candidates:  candidates,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrBulkMatchesEmpty), errors.Is(err, referral.ErrBulkMatchesTooMany):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, referral.ErrReferralNotOwned):
+			respondError(w, http.StatusNotFound, "Referral not found")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to create matches")
+		}
+		return
+	}
+
+	resp := bulkMatchResponse{Items: make([]bulkMatchItemResponse, 0, len(results))}
+	for _, res := range results {
+		item := bulkMatchItemResponse{CandidateAgentID: res.CandidateAgentID, Status: string(res.Status)}
+		if res.Match != nil {
+			m := newMatchResponse(*res.Match)
+			item.Match = &m
+			resp.Created++
+		}
+		if res.Err != nil {
+			item.Error = res.Err.Error()
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
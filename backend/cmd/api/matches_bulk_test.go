@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/referral"
+)
+
+func TestHandleBulkCreateMatches(t *testing.T) {
+	const requestID = "33333333-3333-3333-3333-333333333333"
+	created := referral.Match{ID: "m1", RequestID: requestID, CandidateAgentID: "c1", State: referral.MatchStateInvited, CreatedAt: time.Now()}
+	stub := &stubMatchService{bulkResults: []referral.BulkMatchResult{
+		{CandidateAgentID: "c1", Status: referral.BulkMatchCreated, Match: &created},
+		{CandidateAgentID: "c2", Status: referral.BulkMatchDuplicate},
+		{CandidateAgentID: "", Status: referral.BulkMatchInvalid, Err: referral.ErrCandidateMandatory},
+	}}
+	server := &Server{matchService: stub}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/referrals/{id}/matches/bulk", server.handleBulkCreateMatches)
+
+	body := `{"candidates":[{"candidateAgentId":"c1","score":0.4},{"candidateAgentId":"c2"},{"candidateAgentId":""}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/referrals/"+requestID+"/matches/bulk", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.bulkParams.RequestID != requestID || stub.bulkParams.OwnerUserID != "owner-1" || len(stub.bulkParams.Candidates) != 3 {
+		t.Fatalf("unexpected params %+v", stub.bulkParams)
+	}
+	if stub.bulkParams.Candidates[0].Score != 0.4 {
+		t.Fatalf("expected score to be passed through, got %v", stub.bulkParams.Candidates[0].Score)
+	}
+
+	var resp bulkMatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Created != 1 || len(resp.Items) != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.Items[0].Match == nil || resp.Items[0].Match.ID != "m1" {
+		t.Fatalf("expected created match in first item, got %+v", resp.Items[0])
+	}
+	if resp.Items[1].Status != "duplicate" || resp.Items[2].Status != "invalid" || resp.Items[2].Error == "" {
+		t.Fatalf("unexpected item statuses %+v", resp.Items)
+	}
+}
+
+func TestHandleBulkCreateMatches_Errors(t *testing.T) {
+	const requestID = "33333333-3333-3333-3333-333333333333"
+	cases := []struct {
+		name, path string
+		err        error
+		want       int
+	}{
+		{"empty", "/api/referrals/" + requestID + "/matches/bulk", referral.ErrBulkMatchesEmpty, http.StatusBadRequest},
+		{"not owned", "/api/referrals/" + requestID + "/matches/bulk", referral.ErrReferralNotOwned, http.StatusNotFound},
+		{"bad id", "/api/referrals/nope/matches/bulk", nil, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		server := &Server{matchService: &stubMatchService{bulkErr: tc.err}}
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/referrals/{id}/matches/bulk", server.handleBulkCreateMatches)
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{"candidates":[]}`))
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
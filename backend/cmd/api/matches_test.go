@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+)
+
+type stubMatchService struct {
+	listMatches      []referral.Match
+	listErr          error
+	createMatch      referral.Match
+	createErr        error
+	bulkParams       referral.BulkCreateMatchParams
+	bulkResults      []referral.BulkMatchResult
+	bulkErr          error
+	candidateMatches []referral.Match
+	candidateErr     error
+	updateParams     referral.UpdateMatchParams
+	updateResult     referral.MatchUpdateResult
+	updateErr        error
+	counterParams    referral.RespondCounterParams
+	counterResult    referral.MatchUpdateResult
+	counterErr       error
+}
+
+func (s *stubMatchService) List(_ context.Context, _ string, _ tenancy.Scope) ([]referral.Match, error) {
+	return s.listMatches, s.listErr
+}
+
+func (s *stubMatchService) Create(_ context.Context, _ referral.CreateMatchParams) (referral.Match, error) {
+	return s.createMatch, s.createErr
+}
+
+func (s *stubMatchService) CreateBulk(_ context.Context, params referral.BulkCreateMatchParams) ([]referral.BulkMatchResult, error) {
+	s.bulkParams = params
+	return s.bulkResults, s.bulkErr
+}
+
+func (s *stubMatchService) ListForCandidate(_ context.Context, _ string) ([]referral.Match, error) {
+	return s.candidateMatches, s.candidateErr
+}
+
+func (s *stubMatchService) UpdateState(_ context.Context, params referral.UpdateMatchParams) (referral.MatchUpdateResult, error) {
+	s.updateParams = params
+	return s.updateResult, s.updateErr
+}
+
+func (s *stubMatchService) RespondToCounter(_ context.Context, params referral.RespondCounterParams) (referral.MatchUpdateResult, error) {
+	s.counterParams = params
+	return s.counterResult, s.counterErr
+}
+
+func TestHandleListMatches_Success(t *testing.T) {
+	now := time.Now().UTC()
+	server := &Server{
+		matchService: &stubMatchService{
+			listMatches: []referral.Match{
+				{ID: "m1", CandidateAgentID: "agent-1", State: referral.MatchStateAccepted, Score: 0.9, CreatedAt: now},
+			},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/referrals/req-1/matches", nil)
+	req.SetPathValue("id", "req-1")
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleListMatches(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var payload struct {
+		Items []matchResponse `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Items) != 1 || payload.Items[0].ID != "m1" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestHandleCreateMatch_ValidationError(t *testing.T) {
+	server := &Server{
+		matchService: &stubMatchService{
+			createErr: referral.ErrCandidateMandatory,
+		},
+	}
+
+	body := strings.NewReader(`{"score":0.8}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/referrals/req-1/matches", body)
+	req.SetPathValue("id", "req-1")
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "owner-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleCreateMatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleCandidateMatches_Success(t *testing.T) {
+	server := &Server{
+		matchService: &stubMatchService{
+			candidateMatches: []referral.Match{{ID: "m1", RequestID: "r1", CandidateAgentID: "agent-1", State: referral.MatchStateInvited}},
+		},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/matches", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	rec := httptest.NewRecorder()
+
+	server.handleCandidateMatches(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var payload struct {
+		Items []matchResponse `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Items) != 1 || payload.Items[0].ID != "m1" {
+		t.Fatalf("unexpected matches payload: %+v", payload)
+	}
+}
+
+func TestHandleUpdateMatch_InvalidState(t *testing.T) {
+	server := &Server{
+		matchService: &stubMatchService{},
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(`{"state":"pending"}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleUpdateMatch_Expired(t *testing.T) {
+	server := &Server{
+		matchService: &stubMatchService{updateErr: referral.ErrMatchExpired},
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(`{"state":"accepted"}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+}
+
+func TestHandleUpdateMatch_DeclineReason(t *testing.T) {
+	reason := referral.DeclineNoCapacity
+	note := "Fully booked until spring"
+	stub := &stubMatchService{updateResult: referral.MatchUpdateResult{Match: referral.Match{
+		ID: "m1", RequestID: "r1", State: referral.MatchStateDeclined, DeclineReason: &reason, DeclineNote: &note,
+	}}}
+	server := &Server{matchService: stub}
+
+	body := `{"state":"declined","declineReason":"no_capacity","declineNote":"Fully booked until spring"}`
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.updateParams.Decline != (referral.Decline{Reason: reason, Note: note}) {
+		t.Fatalf("unexpected decline params %+v", stub.updateParams.Decline)
+	}
+	var resp matchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.DeclineReason == nil || *resp.DeclineReason != "no_capacity" || resp.DeclineNote == nil || *resp.DeclineNote != note {
+		t.Fatalf("expected decline reason in response, got %+v", resp)
+	}
+}
+
+func TestHandleUpdateMatch_InvalidDeclineReason(t *testing.T) {
+	server := &Server{
+		matchService: &stubMatchService{updateErr: referral.ErrInvalidDeclineReason},
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(`{"state":"declined","declineReason":"bored"}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleUpdateMatch_Counter(t *testing.T) {
+	fee, days := 22.5, 120
+	stub := &stubMatchService{updateResult: referral.MatchUpdateResult{Match: referral.Match{
+		ID: "m1", RequestID: "r1", State: referral.MatchStateCountered, CounterFeeRate: &fee, CounterProtectDays: &days,
+	}}}
+	server := &Server{matchService: stub}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(`{"state":"countered","feeRate":22.5,"protectDays":120}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.updateParams.NewState != referral.MatchStateCountered || stub.updateParams.Counter != (referral.CounterTerms{FeeRate: 22.5, ProtectDays: 120}) {
+		t.Fatalf("unexpected update params %+v", stub.updateParams)
+	}
+	var resp matchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.State != "countered" || resp.CounterFeeRate == nil || *resp.CounterFeeRate != 22.5 || resp.CounterProtectDays == nil || *resp.CounterProtectDays != 120 {
+		t.Fatalf("expected counter terms in response, got %+v", resp)
+	}
+}
+
+func TestHandleUpdateMatch_CounterOutsidePolicy(t *testing.T) {
+	violation := &broker.PolicyViolation{BrokerID: "b1", Term: broker.TermFeeRate, Bound: broker.BoundMax, Limit: 40, Value: 55}
+	server := &Server{matchService: &stubMatchService{updateErr: fmt.Errorf("%w: %w", referral.ErrCounterOutOfBounds, violation)}}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/referrals/r1/matches/m1", strings.NewReader(`{"state":"countered","feeRate":55,"protectDays":90}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "agent-1"))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleUpdateMatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "outside_policy") {
+		t.Fatalf("expected 400 outside_policy, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleRespondToCounter(t *testing.T) {
+	stub := &stubMatchService{counterResult: referral.MatchUpdateResult{
+		Match:     referral.Match{ID: "m1", RequestID: "r1", State: referral.MatchStateAccepted},
+		Agreement: &agreement.Record{ID: "agr-1", FeeRate: 22.5, ProtectDays: 120},
+	}}
+	server := &Server{matchService: stub}
+
+	req := agentRequest(http.MethodPost, "/api/referrals/r1/matches/m1/counter", `{"status":"accepted"}`, auth.RoleAgent)
+	req.SetPathValue("id", "r1")
+	req.SetPathValue("matchId", "m1")
+	rec := httptest.NewRecorder()
+
+	server.handleRespondToCounter(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.counterParams != (referral.RespondCounterParams{MatchID: "m1", OwnerUserID: "agent-1", Accept: true}) {
+		t.Fatalf("unexpected counter params %+v", stub.counterParams)
+	}
+	var resp matchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Agreement == nil || resp.Agreement.ID != "agr-1" {
+		t.Fatalf("expected agreement in response, got %+v", resp)
+	}
+}
+
+func TestHandleRespondToCounter_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"bad status", `{"status":"maybe"}`, nil, http.StatusBadRequest},
+		{"not owner", `{"status":"rejected"}`, referral.ErrReferralNotOwned, http.StatusNotFound},
+		{"not countered", `{"status":"rejected"}`, referral.ErrCounterNotPending, http.StatusConflict},
+		{"active agreement", `{"status":"accepted"}`, agreement.ErrActiveAgreementExists, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		server := &Server{matchService: &stubMatchService{counterErr: tc.err}}
+		req := agentRequest(http.MethodPost, "/api/referrals/r1/matches/m1/counter", tc.body, auth.RoleAgent)
+		req.SetPathValue("id", "r1")
+		req.SetPathValue("matchId", "m1")
+		rec := httptest.NewRecorder()
+
+		server.handleRespondToCounter(rec, req)
+
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
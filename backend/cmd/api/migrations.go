@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ensureSchema brings the database up to the migrations in fsys, first
+// repairing columns that databases created before the migrations existed
+// may lack.
+func ensureSchema(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) error {
+	const checkSQL = `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = current_schema()
+			  AND table_name = 'referral_requests'
+		)
+	`
+	var exists bool
+	if err := pool.QueryRow(ctx, checkSQL).Scan(&exists); err != nil {
+		return fmt.Errorf("check schema: %w", err)
+	}
+	if exists {
+		if err := ensureColumn(ctx, pool, "users", "role",
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'agent'`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "referral_requests", "cancel_reason",
+			`ALTER TABLE referral_requests ADD COLUMN IF NOT EXISTS cancel_reason TEXT`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "created_at",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "updated_at",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "fee_rate",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS fee_rate NUMERIC(5,2) NOT NULL DEFAULT 0`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "protect_days",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS protect_days INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "status_updated_at",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "status_updated_by",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS status_updated_by UUID`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "effective_at",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS effective_at TIMESTAMPTZ`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "pii_first_access_time",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS pii_first_access_time TIMESTAMPTZ`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "agreements", "event_seq",
+			`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS event_seq BIGINT NOT NULL DEFAULT 0`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "timeline_events", "payload",
+			`ALTER TABLE timeline_events ADD COLUMN IF NOT EXISTS payload JSONB NOT NULL DEFAULT '{}'::jsonb`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "timeline_events", "payload_version",
+			`ALTER TABLE timeline_events ADD COLUMN IF NOT EXISTS payload_version SMALLINT NOT NULL DEFAULT 1`); err != nil {
+			return err
+		}
+		if err := ensureColumn(ctx, pool, "timeline_events", "actor_broker_id",
+			`ALTER TABLE timeline_events ADD COLUMN IF NOT EXISTS actor_broker_id UUID`); err != nil {
+			return err
+		}
+		return db.Migrate(ctx, pool, fsys)
+	}
+
+	if err := ensurePgcrypto(ctx, pool); err != nil {
+		return err
+	}
+
+	return db.Migrate(ctx, pool, fsys)
+}
+
+func ensurePgcrypto(ctx context.Context, pool *pgxpool.Pool) error {
+	const q = `SELECT EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'gen_random_uuid')`
+	var exists bool
+	if err := pool.QueryRow(ctx, q).Scan(&exists); err != nil {
+		return fmt.Errorf("check pgcrypto: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS pgcrypto"); err != nil {
+		return fmt.Errorf("install pgcrypto: %w", err)
+	}
+	return nil
+}
+
+func ensureColumn(ctx context.Context, pool *pgxpool.Pool, table, column, ddl string) error {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema()
+			  AND table_name = $1
+			  AND column_name = $2
+		)
+	`
+	var exists bool
+	if err := pool.QueryRow(ctx, query, table, column).Scan(&exists); err != nil {
+		return fmt.Errorf("check column %s.%s: %w", table, column, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
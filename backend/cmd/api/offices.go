@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/broker"
+)
+
+type officeService interface {
+	Create(ctx context.Context, params broker.OfficeParams) (broker.Office, error)
+	List(ctx context.Context, brokerID string) ([]broker.Office, error)
+	Get(ctx context.Context, brokerID, id string) (broker.Office, error)
+	Update(ctx context.Context, brokerID, id string, update broker.OfficeUpdate) (broker.Office, error)
+	Delete(ctx context.Context, brokerID, id string) error
+	Assign(ctx context.Context, brokerID, userID, officeID string) error
+}
+
+type createOfficeRequest struct {
+	Name        string `json:"name" doc:"Unique within the broker, case-insensitively; at most 200 characters"`
+	ScopeAdmins bool   `json:"scopeAdmins" doc:"Broker admins assigned to the office see only its agents' referrals"`
+}
+
+type updateOfficeRequest struct {
+	Name        *string `json:"name,omitempty"`
+	ScopeAdmins *bool   `json:"scopeAdmins,omitempty"`
+}
+
+type assignOfficeRequest struct {
+	OfficeID *string `json:"officeId" doc:"Office of the same broker; null removes the user from their office"`
+}
+
+type officeResponse struct {
+	ID          string `json:"id"`
+	BrokerID    string `json:"brokerId"`
+	Name        string `json:"name"`
+	ScopeAdmins bool   `json:"scopeAdmins"`
+	Agents      int    `json:"agents" doc:"Users assigned to the office"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+type officeListResponse struct {
+	Items []officeResponse `json:"items"`
+}
+
+func newOfficeResponse(o broker.Office) officeResponse {
+	return officeResponse{
+		ID:          o.ID,
+		BrokerID:    o.BrokerID,
+		Name:        o.Name,
+		ScopeAdmins: o.ScopeAdmins,
+		Agents:      o.Agents,
+		CreatedAt:   o.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   o.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *Server) handleListOffices(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	offices, err := s.offices.List(ctx, brokerID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load offices")
+		return
+	}
+	items := make([]officeResponse, 0, len(offices))
+	for _, o := range offices {
+		items = append(items, newOfficeResponse(o))
+	}
+	respondJSON(w, http.StatusOK, officeListResponse{Items: items})
+}
+
+func (s *Server) handleCreateOffice(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	var req createOfficeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	office, err := s.offices.Create(ctx, broker.OfficeParams{BrokerID: brokerID, Name: req.Name, ScopeAdmins: req.ScopeAdmins})
+	if err != nil {
+		respondServiceError(w, err, "Failed to create office")
+		return
+	}
+	respondJSON(w, http.StatusCreated, newOfficeResponse(office))
+}
+
+// handleUpdateOffice renames an office or switches its admin scoping;
+// omitted fields keep their value.
+func (s *Server) handleUpdateOffice(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	var req updateOfficeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	office, err := s.offices.Update(ctx, brokerID, r.PathValue("officeId"), broker.OfficeUpdate{Name: req.Name, ScopeAdmins: req.ScopeAdmins})
+	if err != nil {
+		respondServiceError(w, err, "Failed to update office")
+		return
+	}
+	respondJSON(w, http.StatusOK, newOfficeResponse(office))
+}
+
+// handleDeleteOffice removes an office; its users stay with the broker
+// without an office.
+func (s *Server) handleDeleteOffice(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.offices.Delete(ctx, brokerID, r.PathValue("officeId")); err != nil {
+		respondServiceError(w, err, "Failed to delete office")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAssignOffice moves a user of the broker into an office, or out of
+// their office when officeId is null.
+func (s *Server) handleAssignOffice(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	var req assignOfficeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var officeID string
+	if req.OfficeID != nil {
+		officeID = *req.OfficeID
+	}
+
+	ctx := r.Context()
+
+	if err := s.offices.Assign(ctx, brokerID, r.PathValue("userId"), officeID); err != nil {
+		respondServiceError(w, err, "Failed to assign office")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
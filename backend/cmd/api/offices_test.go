@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+)
+
+type officeFixture struct {
+	server  *Server
+	users   *testsupport.Users
+	offices *testsupport.Offices
+	agentID string
+}
+
+func newOfficeFixture() officeFixture {
+	brokerID := "broker-1"
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID})
+	agent := users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &brokerID})
+	authService := auth.NewService(users, "secret")
+	offices := testsupport.NewOffices(users)
+	return officeFixture{
+		server: &Server{
+			authService: authService,
+			offices:     broker.NewOfficeService(offices).WithUserInvalidator(authService),
+		},
+		users:   users,
+		offices: offices,
+		agentID: agent.ID,
+	}
+}
+
+func (f officeFixture) create(t *testing.T, body string) officeResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	f.server.handleCreateOffice(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/offices", body, "broker-1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp officeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestOfficeHandlers(t *testing.T) {
+	f := newOfficeFixture()
+	office := f.create(t, `{"name":"  Downtown "}`)
+	if office.Name != "Downtown" || office.BrokerID != "broker-1" || office.ScopeAdmins {
+		t.Fatalf("unexpected office %+v", office)
+	}
+
+	rec := httptest.NewRecorder()
+	f.server.handleCreateOffice(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/offices", `{"name":"downtown"}`, "broker-1"))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate name: expected 409, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	f.server.handleCreateOffice(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/offices", `{"name":" "}`, "broker-1"))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("blank name: expected 400, got %d", rec.Code)
+	}
+
+	req := brokerAdminRequest(http.MethodPut, "/api/brokers/broker-1/users/"+f.agentID+"/office", `{"officeId":"`+office.ID+`"}`, "broker-1")
+	req.SetPathValue("userId", f.agentID)
+	rec = httptest.NewRecorder()
+	f.server.handleAssignOffice(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("assign: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := f.users.OfficeOf(f.agentID); got != office.ID {
+		t.Fatalf("agent office = %q, want %q", got, office.ID)
+	}
+
+	rec = httptest.NewRecorder()
+	f.server.handleListOffices(rec, brokerAdminRequest(http.MethodGet, "/api/brokers/broker-1/offices", "", "broker-1"))
+	var list officeListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Agents != 1 {
+		t.Fatalf("unexpected list %+v", list)
+	}
+
+	req = brokerAdminRequest(http.MethodPatch, "/api/brokers/broker-1/offices/"+office.ID, `{"scopeAdmins":true}`, "broker-1")
+	req.SetPathValue("officeId", office.ID)
+	rec = httptest.NewRecorder()
+	f.server.handleUpdateOffice(rec, req)
+	var updated officeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rec.Code, rec.Body.String())
+	}
+	if !updated.ScopeAdmins || updated.Name != "Downtown" {
+		t.Fatalf("update changed omitted fields: %+v", updated)
+	}
+
+	req = brokerAdminRequest(http.MethodDelete, "/api/brokers/broker-1/offices/"+office.ID, "", "broker-1")
+	req.SetPathValue("officeId", office.ID)
+	rec = httptest.NewRecorder()
+	f.server.handleDeleteOffice(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rec.Code)
+	}
+	if got := f.users.OfficeOf(f.agentID); got != "" {
+		t.Fatalf("agent kept deleted office %q", got)
+	}
+}
+
+func TestHandleAssignOffice_RejectsOtherBrokers(t *testing.T) {
+	f := newOfficeFixture()
+	otherBroker := "broker-2"
+	outsider := f.users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &otherBroker})
+	office := f.create(t, `{"name":"Uptown"}`)
+	foreign, err := f.offices.CreateOffice(context.Background(), broker.OfficeParams{BrokerID: otherBroker, Name: "Elsewhere"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name, userID, officeID string
+	}{
+		{"user of another broker", outsider.ID, office.ID},
+		{"office of another broker", f.agentID, foreign.ID},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := brokerAdminRequest(http.MethodPut, "/api/brokers/broker-1/users/"+tc.userID+"/office", `{"officeId":"`+tc.officeID+`"}`, "broker-1")
+			req.SetPathValue("userId", tc.userID)
+			rec := httptest.NewRecorder()
+			f.server.handleAssignOffice(rec, req)
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestListScope_OfficeFilter(t *testing.T) {
+	f := newOfficeFixture()
+	office := f.create(t, `{"name":"Harbour"}`)
+	scoped := f.create(t, `{"name":"Airport","scopeAdmins":true}`)
+	brokerID := "broker-1"
+	f.users.Add(auth.User{ID: "admin-2", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID, OfficeID: &scoped.ID})
+	adminCtx := context.WithValue(context.Background(), ctxKeyRole, auth.RoleBrokerAdmin)
+	agentCtx := context.WithValue(context.Background(), ctxKeyRole, auth.RoleAgent)
+
+	got, err := f.server.callerScope(adminCtx, "admin-2")
+	if err != nil || got != tenancy.Office("admin-2", brokerID, scoped.ID) {
+		t.Fatalf("office-scoped admin: got %+v, %v", got, err)
+	}
+
+	got, err = f.server.listScope(adminCtx, "admin-1", office.ID)
+	if err != nil || got != tenancy.Office("admin-1", brokerID, office.ID) {
+		t.Fatalf("office filter: got %+v, %v", got, err)
+	}
+	if _, err := f.server.listScope(adminCtx, "admin-1", "missing"); !errors.Is(err, broker.ErrOfficeNotFound) {
+		t.Fatalf("unknown office: got %v", err)
+	}
+	if _, err := f.server.listScope(agentCtx, f.agentID, office.ID); !errors.Is(err, errOfficeFilterForbidden) {
+		t.Fatalf("agent filter: got %v", err)
+	}
+	if _, err := f.server.listScope(adminCtx, "admin-2", office.ID); !errors.Is(err, errOfficeFilterForbidden) {
+		t.Fatalf("office-scoped admin filtering another office: got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"brokerflow/auth"
+)
+
+type oidcService interface {
+	OIDCProviders() []string
+	OIDCAuthorizeURL(ctx context.Context, provider string) (string, error)
+	OIDCLogin(ctx context.Context, req auth.OIDCLoginRequest) (auth.LoginResult, error)
+}
+
+type oidcProvidersResponse struct {
+	Providers []string `json:"providers" doc:"Names usable in /auth/oidc/{provider}/authorize"`
+}
+
+// oidcCallbackRequest carries the query parameters the provider sent to the
+// frontend's redirect page.
+type oidcCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// handleOIDCProviders lists the single sign-on providers, for login buttons.
+func (s *Server) handleOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, oidcProvidersResponse{Providers: s.oidc.OIDCProviders()})
+}
+
+// handleOIDCAuthorize starts a single sign-on by redirecting the browser to
+// the provider.
+func (s *Server) handleOIDCAuthorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	target, err := s.oidc.OIDCAuthorizeURL(ctx, r.PathValue("provider"))
+	if err != nil {
+		respondServiceError(w, err, "Failed to start sign-in")
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleOIDCCallback completes a single sign-on and answers like
+// handleLogin: a token, or a two-factor challenge.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	var req oidcCallbackRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	resp, err := s.oidc.OIDCLogin(ctx, auth.OIDCLoginRequest{
+		Provider:  r.PathValue("provider"),
+		Code:      req.Code,
+		State:     req.State,
+		RemoteIP:  clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		if respondLockout(w, err) {
+			return
+		}
+		respondServiceError(w, err, "Login failed")
+		return
+	}
+
+	if resp.ChallengeToken != "" {
+		respondJSON(w, http.StatusAccepted, twoFactorChallengeResponse{TwoFactorRequired: true, ChallengeToken: resp.ChallengeToken})
+		return
+	}
+	respondJSON(w, http.StatusOK, loginResponse{
+		Token: resp.Token,
+		User:  newAgentResponse(resp.User),
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/auth"
+)
+
+type stubOIDC struct {
+	login    auth.OIDCLoginRequest
+	result   auth.LoginResult
+	loginErr error
+}
+
+func (s *stubOIDC) OIDCProviders() []string { return []string{"google"} }
+
+func (s *stubOIDC) OIDCAuthorizeURL(_ context.Context, provider string) (string, error) {
+	if provider != "google" {
+		return "", auth.ErrOIDCUnknownProvider
+	}
+	return "https://accounts.example.com/auth?state=abc", nil
+}
+
+func (s *stubOIDC) OIDCLogin(_ context.Context, req auth.OIDCLoginRequest) (auth.LoginResult, error) {
+	s.login = req
+	return s.result, s.loginErr
+}
+
+func TestHandleOIDCAuthorize(t *testing.T) {
+	server := &Server{oidc: &stubOIDC{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/google/authorize", nil)
+	req.SetPathValue("provider", "google")
+	rec := httptest.NewRecorder()
+	server.handleOIDCAuthorize(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://accounts.example.com/auth?state=abc" {
+		t.Fatalf("got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	req.SetPathValue("provider", "nope")
+	rec = httptest.NewRecorder()
+	server.handleOIDCAuthorize(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown provider: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleOIDCCallback(t *testing.T) {
+	stub := &stubOIDC{result: auth.LoginResult{Token: "jwt", User: auth.User{ID: "user-1", Role: auth.RoleAgent}}}
+	server := &Server{oidc: stub}
+	callback := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/oidc/google/callback", strings.NewReader(`{"code":"c","state":"s"}`))
+		req.SetPathValue("provider", "google")
+		req.RemoteAddr = "203.0.113.7:5555"
+		rec := httptest.NewRecorder()
+		server.handleOIDCCallback(rec, req)
+		return rec
+	}
+
+	rec := callback()
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"token":"jwt"`) {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.login.Provider != "google" || stub.login.Code != "c" || stub.login.State != "s" || stub.login.RemoteIP != "203.0.113.7" {
+		t.Fatalf("unexpected login request %+v", stub.login)
+	}
+
+	stub.result = auth.LoginResult{ChallengeToken: "challenge"}
+	if rec := callback(); rec.Code != http.StatusAccepted {
+		t.Fatalf("two-factor user: expected 202, got %d", rec.Code)
+	}
+
+	for err, want := range map[error]int{
+		auth.ErrOIDCInvalidState:     http.StatusBadRequest,
+		auth.ErrOIDCLoginFailed:      http.StatusUnauthorized,
+		auth.ErrOIDCNoAccount:        http.StatusForbidden,
+		auth.ErrOIDCEmailNotVerified: http.StatusForbidden,
+		auth.ErrTooManyAttempts:      http.StatusTooManyRequests,
+	} {
+		stub.loginErr = err
+		if rec := callback(); rec.Code != want {
+			t.Errorf("%v: expected %d, got %d", err, want, rec.Code)
+		}
+	}
+}
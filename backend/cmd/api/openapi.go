@@ -0,0 +1,875 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"brokerflow/apidoc"
+	"brokerflow/auth"
+	"brokerflow/graph"
+)
+
+const apiVersion = "1.0.0"
+
+// buildOpenAPI declares every public route against the request/response types
+// the handlers actually encode, so the published schema follows the code.
+// Register new handlers here alongside their mux entry.
+func buildOpenAPI() apidoc.Document {
+	b := apidoc.NewBuilder("BrokerFlow API", apiVersion,
+		"Referral marketplace API: referrals, matches, agreements, timeline events, brokers and disputes.").
+		WithAPIKeyHeader(auth.APIKeyHeader)
+	// add documents the API key scope authMiddleware enforces on each route.
+	add := func(route apidoc.Route) {
+		if scope, ok := apiKeyScopeFor(route.Method, route.Path); ok && route.Auth {
+			route.APIKeyScope = string(scope)
+		}
+		// bodyLimitMiddleware caps every body, so each route taking one can 413.
+		if route.Request != nil && !slices.ContainsFunc(route.Responses, func(r apidoc.Reply) bool { return r.Status == http.StatusRequestEntityTooLarge }) {
+			route.Responses = append(route.Responses, apidoc.Reply{
+				Status: http.StatusRequestEntityTooLarge, Description: "Body over the route's size limit; code request_body_too_large", Body: errorResponse{},
+			})
+		}
+		b.Add(route)
+	}
+
+	errReply := func(status int) apidoc.Reply {
+		return apidoc.Reply{Status: status, Body: errorResponse{}}
+	}
+	pageParams := []apidoc.Parameter{
+		apidoc.QueryParam("page", "integer", "1-based page number"),
+		apidoc.QueryParam("pageSize", "integer", "Page size (1-100, default 20)"),
+	}
+	officeParam := apidoc.QueryParam("officeId", "string", "Narrow a broker admin's view to one office of the brokerage")
+	policyViolationReply := apidoc.Reply{
+		Status:      http.StatusBadRequest,
+		Description: "Invalid terms; terms outside the referring broker's policy carry code outside_policy and the violated bound",
+		Body:        policyViolationResponse{},
+	}
+	ifMatchParam := apidoc.HeaderParam("If-Match", `ETag of the version being changed, e.g. "3"; * skips the check`)
+
+	// Auth
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/auth/register", Summary: "Register a new user", Tags: []string{"auth"},
+		Request: auth.RegisterRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: registerResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/auth/register/invitation", Summary: "Register through an invitation; the account takes the invitation's email, role and broker", Tags: []string{"auth"},
+		Request: acceptInvitationRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: registerResponse{}},
+			{Status: http.StatusBadRequest, Description: "Weak password, or the token is invalid, expired, revoked or already used", Body: errorResponse{}},
+			errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/auth/login", Summary: "Exchange credentials for a JWT", Tags: []string{"auth"},
+		Request: auth.LoginRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: loginResponse{}},
+			{Status: http.StatusAccepted, Description: "Two-factor authentication required; submit the challenge to /auth/login/2fa", Body: twoFactorChallengeResponse{}},
+			errReply(http.StatusUnauthorized),
+			{Status: http.StatusTooManyRequests, Description: "Account locked or IP throttled after repeated failures; see Retry-After", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/auth/login/2fa", Summary: "Complete a login challenge with a TOTP or recovery code", Tags: []string{"auth"},
+		Request: verifyTwoFactorRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: loginResponse{}},
+			errReply(http.StatusUnauthorized), errReply(http.StatusTooManyRequests),
+		},
+	})
+	providerParam := apidoc.PathParam("provider", "Provider name from /auth/oidc/providers, e.g. google")
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/auth/oidc/providers", Summary: "List the configured single sign-on providers", Tags: []string{"auth"},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: oidcProvidersResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/auth/oidc/{provider}/authorize", Summary: "Start single sign-on; redirects the browser to the provider with state, nonce and PKCE", Tags: []string{"auth"},
+		Params: []apidoc.Parameter{providerParam},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusFound, Description: "Redirect to the provider's authorization endpoint"},
+			errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/auth/oidc/{provider}/callback", Summary: "Complete single sign-on with the code and state the provider returned; answers like /auth/login", Tags: []string{"auth"},
+		Params:  []apidoc.Parameter{providerParam},
+		Request: oidcCallbackRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: loginResponse{}},
+			{Status: http.StatusAccepted, Description: "Two-factor authentication required; submit the challenge to /auth/login/2fa", Body: twoFactorChallengeResponse{}},
+			{Status: http.StatusBadRequest, Description: "State unknown, expired or already used", Body: errorResponse{}},
+			{Status: http.StatusUnauthorized, Description: "The provider refused the code or the ID token failed verification", Body: errorResponse{}},
+			{Status: http.StatusForbidden, Description: "Email not verified by the provider, or no account and no role rule matches it", Body: errorResponse{}},
+			errReply(http.StatusNotFound), errReply(http.StatusTooManyRequests),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/.well-known/jwks.json", Summary: "Public keys for verifying RS256 tokens, by kid", Tags: []string{"auth"},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: jwksResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me", Summary: "Current user profile", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: agentResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/me", Summary: "Delete and anonymize the caller's account; client contacts are purged after the retention window", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: accountErasureResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me/profile", Summary: "Get the caller's agent coverage profile", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: agentProfileResponse{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/me/profile", Summary: "Create or replace the caller's agent coverage profile", Tags: []string{"auth"}, Auth: true,
+		Request: agentProfileRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: agentProfileResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/me/profile", Summary: "Delete the caller's agent coverage profile", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Deleted"}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me/email-preferences", Summary: "Get which lifecycle emails the caller receives", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: emailPreferencesBody{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/me/email-preferences", Summary: "Update which lifecycle emails the caller receives; omitted flags are unchanged", Tags: []string{"auth"}, Auth: true,
+		Request:   emailPreferencesBody{},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: emailPreferencesBody{}}, errReply(http.StatusBadRequest)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me/filters", Summary: "List the caller's saved referral filters", Tags: []string{"referrals"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: savedFilterListResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/me/filters", Summary: "Save a named referral filter, optionally notifying on new matching referrals", Tags: []string{"referrals"}, Auth: true,
+		Request: savedFilterRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: savedFilterResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/me/filters/{id}", Summary: "Delete a saved referral filter", Tags: []string{"referrals"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Saved filter id")},
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Deleted"}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me/activity", Summary: "List the caller's activity feed: matches, cancelled referrals, agreements taking effect and disputes", Tags: []string{"auth"}, Auth: true,
+		Params: append([]apidoc.Parameter{
+			apidoc.QueryParam("type", "string", "Repeatable or comma-separated: match_received, referral_cancelled, agreement_signed, dispute_opened"),
+		}, pageParams...),
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: paginatedActivity{}}, errReply(http.StatusBadRequest)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/me/2fa/totp", Summary: "Start TOTP enrollment (broker admins); returns the secret and provisioning URI", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: totpEnrollmentResponse{}},
+			errReply(http.StatusForbidden), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/me/2fa/totp/confirm", Summary: "Enable TOTP with a current code; returns recovery codes once", Tags: []string{"auth"}, Auth: true,
+		Request: confirmTOTPRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: recoveryCodesResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/me/sessions", Summary: "List the caller's active login sessions", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: sessionListResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/me/sessions/{id}", Summary: "Revoke a login session; its token stops working immediately", Tags: []string{"auth"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Session id")},
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Revoked"}, errReply(http.StatusNotFound)},
+	})
+
+	// Regions
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/regions", Summary: "List canonical regions for referrals, profiles and marketplace subscriptions", Tags: []string{"regions"}, Auth: true,
+		Params: []apidoc.Parameter{
+			apidoc.QueryParam("parent", "string", "Only direct children of this region code"),
+			apidoc.QueryParam("within", "string", "Only this region and the regions inside it"),
+			apidoc.QueryParam("q", "string", "Prefix of a region's code, name or alias"),
+			apidoc.QueryParam("limit", "integer", "Maximum results (1-500, default 100)"),
+		},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: regionListResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/regions/{code}", Summary: "Fetch a region with its ancestry and boundary", Tags: []string{"regions"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("code", "Region code, e.g. us-ny-brooklyn")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: regionResponse{}}, errReply(http.StatusNotFound)},
+	})
+
+	// Referrals
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals", Summary: "Create a referral request; rejects likely duplicates of the caller's recent referrals unless force=true", Tags: []string{"referrals"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.QueryParam("force", "boolean", "Create the referral even if it looks like a duplicate")},
+		Request: createReferralRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: referralResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden),
+			{Status: http.StatusConflict, Description: "Same deal type, overlapping region and price range as a recent referral", Body: duplicateReferralResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/referrals", Summary: "List referrals created by the caller", Tags: []string{"referrals"}, Auth: true,
+		Params: append([]apidoc.Parameter{
+			apidoc.QueryParam("status", "string", "Filter by referral status"),
+			apidoc.QueryParam("region", "string", "Filter by region"),
+			apidoc.QueryParam("dealType", "string", "Filter by deal type"),
+			apidoc.QueryParam("includeArchived", "boolean", "Include archived referrals"),
+			apidoc.QueryParam("sortKey", "string", "createdAt, updatedAt, priceMin, priceMax, propertyType, dealType, slaHours or status"),
+			apidoc.QueryParam("sortOrder", "string", "asc or desc"),
+			officeParam,
+		}, pageParams...),
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: paginatedReferrals{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/import", Summary: "Create referrals from a CSV file (body or multipart field \"file\"); rows are validated individually and committed in batches", Tags: []string{"referrals"}, Auth: true,
+		Request: "region,price_min,price_max,property_type,deal_type,languages,sla_hours,match_ttl_hours", RequestContentType: "text/csv",
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "Per-row results in file order", Body: importSummaryResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusRequestEntityTooLarge),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/referrals/{id}", Summary: "Edit an open referral; requires If-Match with its current ETag", Tags: []string{"referrals"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id"), ifMatchParam},
+		Request: updateReferralRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: referralResponse{}},
+			{Status: http.StatusPreconditionFailed, Description: "The referral changed since the ETag was read; the body is its current state", Body: referralResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusPreconditionRequired),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/cancel", Summary: "Cancel an open or matched referral", Tags: []string{"referrals"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Request: cancelReferralRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: referralResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/archive", Summary: "Archive a closed or cancelled referral; archived referrals are listed only with includeArchived=true", Tags: []string{"referrals"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: referralResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/referrals/{id}/client", Summary: "Link the client the referral was created for, who can then follow it in the client portal", Tags: []string{"referrals"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Request: linkClientRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusNoContent, Description: "Linked"},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/referrals/{id}/client-record", Summary: "Get the referral's client record; the other broker party sees the details only once an agreement on it is in effect, and every read of them is audited", Tags: []string{"referrals"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: clientRecordResponse{}},
+			errReply(http.StatusForbidden),
+			{Status: http.StatusNotFound, Description: "No such referral in reach, or no client record attached", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/client-record", Summary: "Attach the end client to a referral, as new details or a record of the caller's brokerage, appending CLIENT_RECORD_ATTACHED to its agreements", Tags: []string{"referrals"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Request: attachClientRecordRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: clientAttachmentResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+			{Status: http.StatusConflict, Description: "The referral already has a client record or is cancelled", Body: errorResponse{}},
+		},
+	})
+
+	// Matches
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/referrals/{id}/matches", Summary: "List candidate matches for a referral", Tags: []string{"matches"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: matchListResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/matches", Summary: "Invite a candidate agent", Tags: []string{"matches"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Request: createMatchRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: matchResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/matches/bulk", Summary: "Invite up to 50 candidate agents in one transaction; existing matches are skipped", Tags: []string{"matches"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Request: bulkCreateMatchesRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "Per-candidate results in request order", Body: bulkMatchResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/referrals/{id}/matches/{matchId}", Summary: "Accept, decline or counter an invitation", Tags: []string{"matches"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id"), apidoc.PathParam("matchId", "Match id")},
+		Request: updateMatchRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "Updated match; includes the agreement when accepted", Body: matchResponse{}},
+			{Status: http.StatusBadRequest, Description: "Invalid state, decline reason or counter terms, or terms outside the referring broker's policy", Body: errorResponse{}},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/referrals/{id}/matches/{matchId}/counter", Summary: "Accept or reject a candidate's counter-offer", Tags: []string{"matches"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Referral id"), apidoc.PathParam("matchId", "Match id")},
+		Request: respondCounterRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "Answered match; includes the agreement on the countered terms when accepted", Body: matchResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+			{Status: http.StatusConflict, Description: "No counter-offer awaits an answer, or the referral already has an active agreement", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/matches", Summary: "List invitations addressed to the caller", Tags: []string{"matches"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: matchListResponse{}}, errReply(http.StatusForbidden)},
+	})
+
+	// Marketplace
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/marketplace/subscription", Summary: "Opt in to the marketplace feed for the given regions and languages", Tags: []string{"marketplace"}, Auth: true,
+		Request: marketplaceSubscriptionRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: marketplaceSubscriptionResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/marketplace/subscription", Summary: "Opt out of the marketplace feed", Tags: []string{"marketplace"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Unsubscribed"}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/marketplace/referrals", Summary: "List anonymized open referrals matching the caller's subscription", Tags: []string{"marketplace"}, Auth: true,
+		Params:    pageParams,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: paginatedListings{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/marketplace/referrals/{id}/apply", Summary: "Apply to a listing; the match stays applied until the owner invites the caller", Tags: []string{"marketplace"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: matchResponse{}},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+
+	// Agreements
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements", Summary: "Create a draft agreement", Tags: []string{"agreements"}, Auth: true,
+		Request: createAgreementRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: agreementResponse{}},
+			policyViolationReply,
+			{Status: http.StatusForbidden, Description: "The referral belongs to another user", Body: errorResponse{}},
+			errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/agreements", Summary: "List agreements on the caller's referrals", Tags: []string{"agreements"}, Auth: true,
+		Params: append([]apidoc.Parameter{
+			officeParam,
+			apidoc.QueryParam("status", "string", "Comma-separated statuses to keep"),
+			apidoc.QueryParam("counterpartyBrokerId", "string", "Keep agreements with this brokerage as a party"),
+			apidoc.QueryParam("referralId", "string", "Keep agreements on this referral"),
+			apidoc.QueryParam("createdFrom", "string", "Created at or after (YYYY-MM-DD or RFC 3339)"),
+			apidoc.QueryParam("createdTo", "string", "Created before (YYYY-MM-DD or RFC 3339)"),
+			apidoc.QueryParam("effectiveFrom", "string", "Took effect at or after (YYYY-MM-DD or RFC 3339)"),
+			apidoc.QueryParam("effectiveTo", "string", "Took effect before (YYYY-MM-DD or RFC 3339)"),
+		}, pageParams...),
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: paginatedAgreements{}},
+			{Status: http.StatusBadRequest, Description: "Unknown status, malformed id or date, or an empty date range", Body: errorResponse{}},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/agreements", Summary: "Transition an agreement's status; requires If-Match with its current ETag", Tags: []string{"agreements"}, Auth: true,
+		Params:  []apidoc.Parameter{ifMatchParam},
+		Request: updateAgreementStatusRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: agreementStatusResponse{}},
+			{Status: http.StatusPreconditionFailed, Description: "The agreement changed since the ETag was read; the body is its current status", Body: agreementConflictResponse{}},
+			{Status: http.StatusConflict, Description: "The state machine does not allow the transition", Body: errorResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound), errReply(http.StatusPreconditionRequired),
+		},
+	})
+
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements/{id}/cancel", Summary: "Cancel an agreement awaiting signature (either broker party) and re-open its referral", Tags: []string{"agreements"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Request: cancelAgreementRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: cancelAgreementResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements/{id}/sign", Summary: "Sign for the caller's broker party; the referring broker signs first and the second signature makes the agreement effective", Tags: []string{"agreements"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: agreementSignaturesResponse{}},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+			{Status: http.StatusConflict, Description: "Not pending signature, already signed, out of order, or an amendment awaits a response", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/agreements/{id}/history", Summary: "Time spent in each status, projected from the timeline", Tags: []string{"agreements"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: statusHistoryResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/agreements/{id}/amendments", Summary: "List proposed changes of terms", Tags: []string{"agreements"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: amendmentListResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements/{id}/amendments", Summary: "Propose new fee rate and protect days on a pending_signature agreement", Tags: []string{"agreements"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Request: proposeAmendmentRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: amendmentResponse{}},
+			policyViolationReply, errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/agreements/{id}/amendments/{amendmentId}", Summary: "Accept or reject an amendment (counterparty only)", Tags: []string{"agreements"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id"), apidoc.PathParam("amendmentId", "Amendment id")},
+		Request: respondAmendmentRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: amendmentResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+
+	// Reviews
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements/{id}/reviews", Summary: "Rate the other party after DEAL_CLOSED", Tags: []string{"reviews"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Request: submitReviewRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: reviewResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/agents/{id}/reviews", Summary: "List an agent's reviews with their average rating", Tags: []string{"reviews"}, Auth: true,
+		Params:    append([]apidoc.Parameter{apidoc.PathParam("id", "Agent user id")}, pageParams...),
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: reviewListResponse{}}, errReply(http.StatusNotFound)},
+	})
+
+	// Timeline
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/events", Summary: "Page through the timeline events of agreements the caller can view, newest first", Tags: []string{"timeline"}, Auth: true,
+		Params: append([]apidoc.Parameter{
+			apidoc.QueryParam("agreementId", "string", "Keep events of this agreement"),
+			apidoc.QueryParam("type", "string", "Comma-separated event types to keep; repeatable"),
+			apidoc.QueryParam("actorBrokerId", "string", "Keep events recorded by this brokerage"),
+			apidoc.QueryParam("since", "string", "At or after (YYYY-MM-DD or RFC 3339)"),
+			apidoc.QueryParam("until", "string", "Before (YYYY-MM-DD or RFC 3339)"),
+		}, pageParams...),
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: paginatedTimelineEvents{}},
+			{Status: http.StatusBadRequest, Description: "Unknown event type, malformed id or time, or an empty time range", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/graphql", Summary: "Run a read-only GraphQL query over referrals, matches, agreements, timelines and disputes; API keys need referrals:read, agreements:read and disputes:read", Tags: []string{"graphql"}, Auth: true,
+		Request: graph.Request{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "Query result; query and resolver errors are listed under errors", Body: graphQLResponse{}},
+			{Status: http.StatusBadRequest, Description: "Missing query", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/agreements/{id}/events", Summary: "Record a deal milestone (OFFER_MADE, UNDER_CONTRACT, DEAL_CLOSED) on an effective agreement", Tags: []string{"timeline"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Request: recordDealEventRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: timelineEvent{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/agreements/{id}/events/stream", Summary: "Stream an agreement's timeline events (Server-Sent Events)", Tags: []string{"timeline"}, Auth: true,
+		Params: []apidoc.Parameter{
+			apidoc.PathParam("id", "Agreement id"),
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event id", Schema: &apidoc.Schema{Type: "string"}},
+			apidoc.QueryParam("lastEventId", "string", "Same as Last-Event-ID, for clients that cannot set headers"),
+			apidoc.QueryParam("access_token", "string", "Bearer token, for EventSource clients that cannot set headers"),
+		},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "text/event-stream; each `timeline` event carries one event as JSON", Body: timelineEvent{}, ContentType: "text/event-stream"},
+			errReply(http.StatusNotFound),
+		},
+	})
+
+	// Notifications
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/ws", Summary: "WebSocket push of match.invited, match.accepted, agreement.status_changed and agreement.expired", Tags: []string{"notifications"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.QueryParam("access_token", "string", "Bearer token, for browser clients that cannot set headers")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusSwitchingProtocols, Description: "Upgraded to WebSocket; each text frame is one notification", Body: notification{}},
+			errReply(http.StatusUnauthorized),
+		},
+	})
+
+	// Brokers
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers", Summary: "List brokers", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.QueryParam("limit", "integer", "Maximum number of brokers (default 50)")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: brokerListResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}", Summary: "Fetch a broker profile", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: brokerResponse{}}, errReply(http.StatusNotFound)},
+	})
+
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}/settings", Summary: "Fetch a broker's referral policy (defaults when unset)", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: brokerSettingsResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/brokers/{id}/settings", Summary: "Replace a broker's referral policy (broker_admin of that broker)", Tags: []string{"brokers"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Request: brokerSettingsRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: brokerSettingsResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+
+	// Webhooks
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/brokers/{id}/webhooks", Summary: "Register a partner webhook (broker_admin of that broker); the response carries the signing secret once", Tags: []string{"webhooks"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Request: createWebhookRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: webhookResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}/webhooks", Summary: "List a broker's webhooks", Tags: []string{"webhooks"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: webhookListResponse{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/brokers/{id}/webhooks/{webhookId}", Summary: "Delete a webhook and cancel its pending deliveries", Tags: []string{"webhooks"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("webhookId", "Webhook id")},
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Deleted"}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}/webhooks/{webhookId}/deliveries", Summary: "Page through a webhook's delivery attempts", Tags: []string{"webhooks"}, Auth: true,
+		Params:    append([]apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("webhookId", "Webhook id")}, pageParams...),
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: paginatedWebhookDeliveries{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+
+	// Offices
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}/offices", Summary: "List a broker's offices with their agent counts", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: officeListResponse{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/brokers/{id}/offices", Summary: "Create an office (broker_admin of that broker)", Tags: []string{"brokers"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Request: createOfficeRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: officeResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/brokers/{id}/offices/{officeId}", Summary: "Rename an office or change whether it scopes its admins", Tags: []string{"brokers"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("officeId", "Office id")},
+		Request: updateOfficeRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: officeResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound), errReply(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/brokers/{id}/offices/{officeId}", Summary: "Delete an office; its users stay with the broker without an office", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("officeId", "Office id")},
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Deleted"}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/api/brokers/{id}/users/{userId}/office", Summary: "Assign a user of the broker to an office, or remove them from theirs", Tags: []string{"brokers"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("userId", "User id")},
+		Request: assignOfficeRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusNoContent, Description: "Assigned"},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+
+	// Invitations
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/brokers/{id}/invitations", Summary: "Invite an agent or admin to the broker (broker_admin of that broker); the invitee is emailed a registration link", Tags: []string{"brokers"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Request: createInvitationRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: invitationResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden),
+			{Status: http.StatusConflict, Description: "The email already has an account", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/brokers/{id}/invitations", Summary: "List a broker's invitations, newest first", Tags: []string{"brokers"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Broker id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: invitationListResponse{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/brokers/{id}/invitations/{invitationId}", Summary: "Revoke a pending invitation", Tags: []string{"brokers"}, Auth: true,
+		Params: []apidoc.Parameter{apidoc.PathParam("id", "Broker id"), apidoc.PathParam("invitationId", "Invitation id")},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusNoContent, Description: "Revoked"},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+			{Status: http.StatusConflict, Description: "The invitation was already accepted or revoked", Body: errorResponse{}},
+		},
+	})
+
+	// Disputes
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/disputes", Summary: "List disputes on the caller's agreements", Tags: []string{"disputes"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.QueryParam("agreementId", "string", "Restrict to one agreement")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: disputeListResponse{}}, errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/disputes", Summary: "Open a dispute on an agreement (referral owner's side or the referee broker)", Tags: []string{"disputes"}, Auth: true,
+		Request: createDisputeRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: disputeResponse{}},
+			{Status: http.StatusBadRequest, Description: "Missing agreementId, unknown reason, or missing or overlong detail", Body: errorResponse{}},
+			errReply(http.StatusNotFound),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/api/disputes/{id}", Summary: "Resolve a dispute (referral owner, or the assigned reviewer once escalated)", Tags: []string{"disputes"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Dispute id")},
+		Request: resolveDisputeRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: disputeResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusNotFound),
+		},
+	})
+
+	// Files
+	for _, target := range []struct{ path, name string }{
+		{"/api/referrals/{id}/files", "referral"},
+		{"/api/agreements/{id}/files", "agreement"},
+		{"/api/disputes/{id}/files", "dispute"},
+	} {
+		add(apidoc.Route{
+			Method: http.MethodPost, Path: target.path, Summary: "Attach a file (multipart field \"file\") to a " + target.name + " the caller can see; content is type-checked and virus-scanned before it is stored", Tags: []string{"files"}, Auth: true,
+			Params:  []apidoc.Parameter{apidoc.PathParam("id", "Target id")},
+			Request: uploadFileForm{}, RequestContentType: "multipart/form-data",
+			Responses: []apidoc.Reply{
+				{Status: http.StatusCreated, Body: fileResponse{}},
+				errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+				errReply(http.StatusRequestEntityTooLarge), errReply(http.StatusUnsupportedMediaType), errReply(http.StatusUnprocessableEntity),
+			},
+		})
+		add(apidoc.Route{
+			Method: http.MethodGet, Path: target.path, Summary: "List a " + target.name + "'s files with signed download links", Tags: []string{"files"}, Auth: true,
+			Params:    []apidoc.Parameter{apidoc.PathParam("id", "Target id")},
+			Responses: []apidoc.Reply{{Status: http.StatusOK, Body: fileListResponse{}}, errReply(http.StatusNotFound)},
+		})
+	}
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/files/{id}", Summary: "Delete a file the caller uploaded", Tags: []string{"files"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "File id")},
+		Responses: []apidoc.Reply{{Status: http.StatusNoContent, Description: "Deleted"}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/files/{id}/download", Summary: "Download a file through the signed link from downloadUrl; needs no authentication", Tags: []string{"files"},
+		Params: []apidoc.Parameter{
+			apidoc.PathParam("id", "File id"),
+			apidoc.QueryParam("expires", "integer", "Unix time the link expires"),
+			apidoc.QueryParam("signature", "string", "Link signature"),
+		},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Description: "The file, as an attachment", Body: "", ContentType: "application/octet-stream"},
+			errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+		},
+	})
+
+	// Client portal
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/client/referrals", Summary: "List the referrals created on the calling client's behalf", Tags: []string{"client"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: clientReferralListResponse{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/client/referrals/{id}", Summary: "Show one of the caller's referrals with the assigned agent's public profile and deal milestones", Tags: []string{"client"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Referral id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: clientOverviewResponse{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+
+	// Reports
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/reports/summary", Summary: "Referral, match and agreement aggregates over a window, scoped to the caller or their brokerage", Tags: []string{"reports"}, Auth: true,
+		Params: []apidoc.Parameter{
+			apidoc.QueryParam("from", "string", "Inclusive start, YYYY-MM-DD or RFC 3339 (default: one year before to)"),
+			apidoc.QueryParam("to", "string", "Exclusive end, YYYY-MM-DD or RFC 3339 (default: now)"),
+			officeParam,
+		},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: reportSummaryResponse{}}, errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+
+	// API keys
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/api-keys", Summary: "List the caller's API keys", Tags: []string{"auth"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: apiKeyListResponse{}}},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/api-keys", Summary: "Issue an API key acting as the caller; the key is returned only once", Tags: []string{"auth"}, Auth: true,
+		Request: createAPIKeyRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusCreated, Body: createdAPIKeyResponse{}},
+			errReply(http.StatusBadRequest),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/api/api-keys/{id}", Summary: "Revoke one of the caller's API keys", Tags: []string{"auth"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "API key id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: apiKeyResponse{}}, errReply(http.StatusNotFound)},
+	})
+
+	// SCIM provisioning: the bearer token is an API key of a broker_admin,
+	// and every call acts on that admin's brokerage.
+	scimReply := func(status int, body any) apidoc.Reply {
+		return apidoc.Reply{Status: status, Body: body, ContentType: scimContentType}
+	}
+	scimErr := func(status int) apidoc.Reply { return scimReply(status, scimErrorResponse{}) }
+	scimUserParam := []apidoc.Parameter{apidoc.PathParam("id", "User id")}
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/scim/v2/Users", Summary: "List the brokerage's users, deactivated ones included", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersRead),
+		Params: []apidoc.Parameter{
+			apidoc.QueryParam("filter", "string", `userName eq "..." or externalId eq "..."`),
+			apidoc.QueryParam("startIndex", "integer", "1-based index of the first result"),
+			apidoc.QueryParam("count", "integer", "Page size (1-200, default 100)"),
+		},
+		Responses: []apidoc.Reply{scimReply(http.StatusOK, scimListResponse{}), scimErr(http.StatusBadRequest), scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/scim/v2/Users", Summary: "Provision a user in the brokerage; the user signs in through single sign-on", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersWrite),
+		Request:     scimUserResource{}, RequestContentType: scimContentType,
+		Responses: []apidoc.Reply{
+			scimReply(http.StatusCreated, scimUserResource{}),
+			scimErr(http.StatusBadRequest), scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden),
+			{Status: http.StatusConflict, Description: "userName or externalId already in use; scimType uniqueness", Body: scimErrorResponse{}, ContentType: scimContentType},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/scim/v2/Users/{id}", Summary: "Get one of the brokerage's users", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersRead),
+		Params:      scimUserParam,
+		Responses:   []apidoc.Reply{scimReply(http.StatusOK, scimUserResource{}), scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden), scimErr(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPut, Path: "/scim/v2/Users/{id}", Summary: "Replace a user's name, externalId, role and active flag", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersWrite),
+		Params:      scimUserParam,
+		Request:     scimUserResource{}, RequestContentType: scimContentType,
+		Responses: []apidoc.Reply{
+			scimReply(http.StatusOK, scimUserResource{}),
+			scimErr(http.StatusBadRequest), scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden), scimErr(http.StatusNotFound), scimErr(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPatch, Path: "/scim/v2/Users/{id}", Summary: "Apply SCIM PATCH operations, e.g. replace active with false to deactivate", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersWrite),
+		Params:      scimUserParam,
+		Request:     scimPatchRequest{}, RequestContentType: scimContentType,
+		Responses: []apidoc.Reply{
+			scimReply(http.StatusOK, scimUserResource{}),
+			scimErr(http.StatusBadRequest), scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden), scimErr(http.StatusNotFound), scimErr(http.StatusConflict),
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodDelete, Path: "/scim/v2/Users/{id}", Summary: "Deprovision a user: deactivate it and remove it from the brokerage", Tags: []string{"scim"}, Auth: true,
+		APIKeyScope: string(auth.ScopeUsersWrite),
+		Params:      scimUserParam,
+		Responses: []apidoc.Reply{
+			{Status: http.StatusNoContent, Description: "Deprovisioned"},
+			scimErr(http.StatusUnauthorized), scimErr(http.StatusForbidden), scimErr(http.StatusNotFound),
+		},
+	})
+
+	// Admin
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/admin/topics", Summary: "List outbox topics with publication statistics", Tags: []string{"admin"}, Auth: true,
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: topicListResponse{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/admin/users/{id}/unlock", Summary: "Lift a login lockout on a user of the caller's brokerage (broker_admin)", Tags: []string{"admin"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "User id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: lockoutResponse{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/admin/agreements/{id}/corrections", Summary: "Override the status set by an agreement's latest status change with a justified compensating timeline event (broker_admin of either broker party)", Tags: []string{"admin"}, Auth: true,
+		Params:  []apidoc.Parameter{apidoc.PathParam("id", "Agreement id")},
+		Request: correctAgreementStatusRequest{},
+		Responses: []apidoc.Reply{
+			{Status: http.StatusOK, Body: correctAgreementStatusResponse{}},
+			errReply(http.StatusBadRequest), errReply(http.StatusForbidden), errReply(http.StatusNotFound),
+			{Status: http.StatusConflict, Description: "The event is not the latest status change, the agreement already has the status, or signatures are missing", Body: errorResponse{}},
+		},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/admin/outbox/dead-letters", Summary: "List outbox messages the worker gave up on, most recently attempted first", Tags: []string{"admin"}, Auth: true,
+		Params:    append([]apidoc.Parameter{apidoc.QueryParam("topic", "string", "Only messages on this topic")}, pageParams...),
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: paginatedOutboxMessages{}}, errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodGet, Path: "/api/admin/outbox/messages/{id}", Summary: "Inspect an outbox message and its payload", Tags: []string{"admin"}, Auth: true,
+		Params:    []apidoc.Parameter{apidoc.PathParam("id", "Outbox message id")},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: outboxMessageResponse{}}, errReply(http.StatusForbidden), errReply(http.StatusNotFound)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/admin/outbox/requeue", Summary: "Make dead-lettered outbox messages pending again with a fresh attempt budget", Tags: []string{"admin"}, Auth: true,
+		Request:   requeueOutboxRequest{},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: requeueOutboxResponse{}}, errReply(http.StatusBadRequest), errReply(http.StatusForbidden)},
+	})
+	add(apidoc.Route{
+		Method: http.MethodPost, Path: "/api/admin/outbox/purge", Summary: "Delete delivered outbox messages older than the retention window", Tags: []string{"admin"}, Auth: true,
+		Request:   purgeOutboxRequest{},
+		Responses: []apidoc.Reply{{Status: http.StatusOK, Body: purgeOutboxResponse{}}, errReply(http.StatusBadRequest), errReply(http.StatusForbidden)},
+	})
+	// Outbox payloads are not request/response bodies; register them as
+	// components so the schemaRef returned by /api/admin/topics resolves.
+	for _, t := range newTopicRegistry().Topics() {
+		if t.Payload != nil {
+			b.SchemaFor(t.Payload)
+		}
+	}
+
+	return b.Document()
+}
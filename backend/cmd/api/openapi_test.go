@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildOpenAPI_DocumentsHandlerTypes(t *testing.T) {
+	doc := buildOpenAPI()
+
+	if doc.Paths["/api/referrals"]["post"] == nil || doc.Paths["/api/referrals/{id}/matches/{matchId}"]["patch"] == nil {
+		t.Fatalf("expected referral and match routes to be documented")
+	}
+	schema := doc.Components.Schemas["ReferralResponse"]
+	if schema == nil {
+		t.Fatalf("expected ReferralResponse schema, got %v", doc.Components.Schemas)
+	}
+	for _, field := range []string{"id", "creatorAgentId", "priceMin", "status", "cancelReason"} {
+		if _, ok := schema.Properties[field]; !ok {
+			t.Fatalf("expected property %q on ReferralResponse", field)
+		}
+	}
+}
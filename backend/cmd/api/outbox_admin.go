@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/outbox"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxRequeueIDs caps the messages one requeue request names.
+	maxRequeueIDs = 100
+	// defaultOutboxRetention is how old delivered messages must be for a
+	// purge that does not say.
+	defaultOutboxRetention = 30 * 24 * time.Hour
+	// minOutboxRetention keeps a purge from deleting messages delivered so
+	// recently that an operator may still be looking into them.
+	minOutboxRetention = time.Hour
+)
+
+type outboxMessageResponse struct {
+	ID          string          `json:"id"`
+	Topic       string          `json:"topic"`
+	Status      string          `json:"status" doc:"pending, delivered or failed"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   string          `json:"createdAt"`
+	LastAttempt *string         `json:"lastAttemptAt,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty" doc:"Only returned for a single message"`
+}
+
+func newOutboxMessageResponse(m outbox.StoredMessage) outboxMessageResponse {
+	resp := outboxMessageResponse{
+		ID:        m.ID,
+		Topic:     m.Topic,
+		Status:    m.Status,
+		Attempts:  m.Attempts,
+		CreatedAt: m.CreatedAt.UTC().Format(time.RFC3339),
+		Payload:   m.Payload,
+	}
+	if m.LastAttempt != nil {
+		ts := m.LastAttempt.UTC().Format(time.RFC3339)
+		resp.LastAttempt = &ts
+	}
+	return resp
+}
+
+type paginatedOutboxMessages struct {
+	Items    []outboxMessageResponse `json:"items"`
+	Total    int                     `json:"total"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"pageSize"`
+}
+
+type requeueOutboxRequest struct {
+	IDs []string `json:"ids" doc:"Up to 100 outbox message IDs"`
+}
+
+type requeueOutboxResponse struct {
+	Requeued []string `json:"requeued" doc:"Dead letters made pending again"`
+	Skipped  []string `json:"skipped" doc:"IDs that are not dead letters, including unknown ones"`
+}
+
+type purgeOutboxRequest struct {
+	OlderThan string `json:"olderThan,omitempty" doc:"Go duration such as 720h, at least 1h; defaults to 720h"`
+}
+
+type purgeOutboxResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// outboxOperator returns the caller's ID if they may manage the outbox.
+func (s *Server) outboxOperator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return "", false
+	}
+	if !can(r.Context(), auth.PermOutboxAdmin) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return "", false
+	}
+	return userID, true
+}
+
+// handleListDeadLetters pages through messages the outbox worker gave up
+// on, most recently attempted first.
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.outboxOperator(w, r); !ok {
+		return
+	}
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	msgs, total, err := s.outboxAdmin.ListDeadLetters(r.Context(), query.Get("topic"), page, pageSize)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load outbox messages")
+		return
+	}
+	items := make([]outboxMessageResponse, 0, len(msgs))
+	for _, m := range msgs {
+		item := newOutboxMessageResponse(m)
+		item.Payload = nil
+		items = append(items, item)
+	}
+	respondJSON(w, http.StatusOK, paginatedOutboxMessages{Items: items, Total: total, Page: page, PageSize: pageSize})
+}
+
+// handleGetOutboxMessage returns one outbox message with its payload.
+func (s *Server) handleGetOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.outboxOperator(w, r); !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondError(w, http.StatusNotFound, "Outbox message not found")
+		return
+	}
+
+	msg, err := s.outboxAdmin.GetMessage(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, outbox.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Outbox message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load outbox message")
+		return
+	}
+	respondJSON(w, http.StatusOK, newOutboxMessageResponse(msg))
+}
+
+// handleRequeueOutbox hands dead letters back to the worker. IDs that are
+// not dead letters are reported as skipped rather than failing the request,
+// so a retried requeue is harmless.
+func (s *Server) handleRequeueOutbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.outboxOperator(w, r)
+	if !ok {
+		return
+	}
+	var req requeueOutboxRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxRequeueIDs {
+		respondError(w, http.StatusBadRequest, "ids must list 1 to 100 message IDs")
+		return
+	}
+	for _, id := range req.IDs {
+		if _, err := uuid.Parse(id); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+	}
+
+	requeued, err := s.outboxAdmin.Requeue(r.Context(), userID, req.IDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to requeue outbox messages")
+		return
+	}
+	resp := requeueOutboxResponse{Requeued: requeued, Skipped: []string{}}
+	if resp.Requeued == nil {
+		resp.Requeued = []string{}
+	}
+	for _, id := range req.IDs {
+		if !slices.Contains(requeued, id) && !slices.Contains(resp.Skipped, id) {
+			resp.Skipped = append(resp.Skipped, id)
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handlePurgeOutbox deletes delivered messages past the retention window.
+// Pending and failed messages are never purged.
+func (s *Server) handlePurgeOutbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.outboxOperator(w, r)
+	if !ok {
+		return
+	}
+	var req purgeOutboxRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	olderThan := defaultOutboxRetention
+	if req.OlderThan != "" {
+		d, err := time.ParseDuration(req.OlderThan)
+		if err != nil || d < minOutboxRetention {
+			respondError(w, http.StatusBadRequest, "olderThan must be a duration of at least 1h")
+			return
+		}
+		olderThan = d
+	}
+
+	purged, err := s.outboxAdmin.PurgeDelivered(r.Context(), userID, olderThan)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to purge outbox messages")
+		return
+	}
+	respondJSON(w, http.StatusOK, purgeOutboxResponse{Purged: purged})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/outbox"
+)
+
+type stubOutboxAdmin struct {
+	messages    map[string]outbox.StoredMessage
+	listTopic   string
+	actor       string
+	purgeWindow time.Duration
+}
+
+func (s *stubOutboxAdmin) ListDeadLetters(_ context.Context, topic string, _, _ int) ([]outbox.StoredMessage, int, error) {
+	s.listTopic = topic
+	var out []outbox.StoredMessage
+	for _, m := range s.messages {
+		if m.Status == outbox.StatusFailed {
+			out = append(out, m)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (s *stubOutboxAdmin) GetMessage(_ context.Context, id string) (outbox.StoredMessage, error) {
+	m, ok := s.messages[id]
+	if !ok {
+		return outbox.StoredMessage{}, outbox.ErrMessageNotFound
+	}
+	return m, nil
+}
+
+func (s *stubOutboxAdmin) Requeue(_ context.Context, actorID string, ids []string) ([]string, error) {
+	s.actor = actorID
+	var requeued []string
+	for _, id := range ids {
+		if m, ok := s.messages[id]; ok && m.Status == outbox.StatusFailed {
+			m.Status, m.Attempts = outbox.StatusPending, 0
+			s.messages[id] = m
+			requeued = append(requeued, id)
+		}
+	}
+	return requeued, nil
+}
+
+func (s *stubOutboxAdmin) PurgeDelivered(_ context.Context, actorID string, olderThan time.Duration) (int64, error) {
+	s.actor, s.purgeWindow = actorID, olderThan
+	return 3, nil
+}
+
+const (
+	deadLetterID = "0b4f3c9e-1d2a-4b6c-8e7f-9a0b1c2d3e4f"
+	deliveredID  = "5e6f7a8b-9c0d-4e1f-a2b3-c4d5e6f7a8b9"
+	unknownID    = "c0ffee00-0000-4000-8000-000000000000"
+)
+
+func newOutboxAdminFixture() (*Server, *stubOutboxAdmin) {
+	now := time.Now()
+	stub := &stubOutboxAdmin{messages: map[string]outbox.StoredMessage{
+		deadLetterID: {Message: outbox.Message{ID: deadLetterID, Topic: "agreement.created", Payload: json.RawMessage(`{"id":"ag-1"}`), Attempts: 10, CreatedAt: now}, Status: outbox.StatusFailed, LastAttempt: &now},
+		deliveredID:  {Message: outbox.Message{ID: deliveredID, Topic: "agreement.created", Attempts: 1, CreatedAt: now}, Status: outbox.StatusDelivered, LastAttempt: &now},
+	}}
+	return &Server{outboxAdmin: stub}, stub
+}
+
+func outboxAdminRequest(method, target, body string, role auth.Role) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "admin-1")
+	return req.WithContext(context.WithValue(ctx, ctxKeyRole, role))
+}
+
+func TestHandleListDeadLetters(t *testing.T) {
+	server, stub := newOutboxAdminFixture()
+
+	rec := httptest.NewRecorder()
+	server.handleListDeadLetters(rec, outboxAdminRequest(http.MethodGet, "/api/admin/outbox/dead-letters?topic=agreement.created", "", auth.RoleAgent))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an agent, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleListDeadLetters(rec, outboxAdminRequest(http.MethodGet, "/api/admin/outbox/dead-letters?topic=agreement.created", "", auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var payload paginatedOutboxMessages
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.Total != 1 || len(payload.Items) != 1 || payload.Items[0].ID != deadLetterID || payload.Items[0].Payload != nil || stub.listTopic != "agreement.created" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestHandleGetOutboxMessage(t *testing.T) {
+	server, _ := newOutboxAdminFixture()
+
+	req := outboxAdminRequest(http.MethodGet, "/api/admin/outbox/messages/"+deadLetterID, "", auth.RoleBrokerAdmin)
+	req.SetPathValue("id", deadLetterID)
+	rec := httptest.NewRecorder()
+	server.handleGetOutboxMessage(rec, req)
+	var msg outboxMessageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || string(msg.Payload) != `{"id":"ag-1"}` || msg.Status != outbox.StatusFailed {
+		t.Fatalf("unexpected response %d %+v", rec.Code, msg)
+	}
+
+	for _, id := range []string{unknownID, "not-a-uuid"} {
+		req := outboxAdminRequest(http.MethodGet, "/api/admin/outbox/messages/"+id, "", auth.RoleBrokerAdmin)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		server.handleGetOutboxMessage(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", id, rec.Code)
+		}
+	}
+}
+
+func TestHandleRequeueOutbox(t *testing.T) {
+	server, stub := newOutboxAdminFixture()
+
+	body := `{"ids":["` + deadLetterID + `","` + deliveredID + `","` + unknownID + `"]}`
+	rec := httptest.NewRecorder()
+	server.handleRequeueOutbox(rec, outboxAdminRequest(http.MethodPost, "/api/admin/outbox/requeue", body, auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp requeueOutboxResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Requeued) != 1 || resp.Requeued[0] != deadLetterID || len(resp.Skipped) != 2 || stub.actor != "admin-1" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if stub.messages[deadLetterID].Status != outbox.StatusPending {
+		t.Fatal("expected the dead letter to be pending again")
+	}
+
+	for name, body := range map[string]string{
+		"no ids":      `{"ids":[]}`,
+		"bad id":      `{"ids":["nope"]}`,
+		"unknown key": `{"id":["` + deadLetterID + `"]}`,
+	} {
+		rec := httptest.NewRecorder()
+		server.handleRequeueOutbox(rec, outboxAdminRequest(http.MethodPost, "/api/admin/outbox/requeue", body, auth.RoleBrokerAdmin))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestHandlePurgeOutbox(t *testing.T) {
+	server, stub := newOutboxAdminFixture()
+
+	rec := httptest.NewRecorder()
+	server.handlePurgeOutbox(rec, outboxAdminRequest(http.MethodPost, "/api/admin/outbox/purge", `{}`, auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusOK || stub.purgeWindow != defaultOutboxRetention || !strings.Contains(rec.Body.String(), `"purged":3`) {
+		t.Fatalf("unexpected default purge: %d %s (window %s)", rec.Code, rec.Body.String(), stub.purgeWindow)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handlePurgeOutbox(rec, outboxAdminRequest(http.MethodPost, "/api/admin/outbox/purge", `{"olderThan":"168h"}`, auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusOK || stub.purgeWindow != 7*24*time.Hour {
+		t.Fatalf("expected a one-week window, got %d (window %s)", rec.Code, stub.purgeWindow)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handlePurgeOutbox(rec, outboxAdminRequest(http.MethodPost, "/api/admin/outbox/purge", `{"olderThan":"5m"}`, auth.RoleBrokerAdmin))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 below the minimum window, got %d", rec.Code)
+	}
+}
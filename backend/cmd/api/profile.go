@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/agentprofile"
+	"brokerflow/region"
+)
+
+type profileService interface {
+	Get(ctx context.Context, userID string) (agentprofile.Profile, error)
+	Save(ctx context.Context, p agentprofile.Profile) (agentprofile.Profile, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+type licenseBody struct {
+	Jurisdiction string `json:"jurisdiction"`
+	Number       string `json:"number"`
+}
+
+type agentProfileRequest struct {
+	Regions       []string      `json:"regions"`
+	Languages     []string      `json:"languages"`
+	PriceMin      *int64        `json:"priceMin,omitempty"`
+	PriceMax      *int64        `json:"priceMax,omitempty"`
+	PropertyTypes []string      `json:"propertyTypes"`
+	Licenses      []licenseBody `json:"licenses"`
+}
+
+type agentProfileResponse struct {
+	UserID        string        `json:"userId"`
+	Regions       []string      `json:"regions"`
+	Languages     []string      `json:"languages"`
+	PriceMin      *int64        `json:"priceMin,omitempty"`
+	PriceMax      *int64        `json:"priceMax,omitempty"`
+	PropertyTypes []string      `json:"propertyTypes"`
+	Licenses      []licenseBody `json:"licenses"`
+	CreatedAt     string        `json:"createdAt"`
+	UpdatedAt     string        `json:"updatedAt"`
+}
+
+func newAgentProfileResponse(p agentprofile.Profile) agentProfileResponse {
+	licenses := make([]licenseBody, 0, len(p.Licenses))
+	for _, l := range p.Licenses {
+		licenses = append(licenses, licenseBody{Jurisdiction: l.Jurisdiction, Number: l.Number})
+	}
+	return agentProfileResponse{
+		UserID:        p.UserID,
+		Regions:       append([]string{}, p.Regions...),
+		Languages:     append([]string{}, p.Languages...),
+		PriceMin:      p.PriceMin,
+		PriceMax:      p.PriceMax,
+		PropertyTypes: append([]string{}, p.PropertyTypes...),
+		Licenses:      licenses,
+		CreatedAt:     p.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:     p.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *Server) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := s.profiles.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, agentprofile.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Profile not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load profile")
+		return
+	}
+	respondJSON(w, http.StatusOK, newAgentProfileResponse(p))
+}
+
+// handlePutProfile creates the caller's profile or replaces it entirely.
+func (s *Server) handlePutProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+	var req agentProfileRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	licenses := make([]agentprofile.License, 0, len(req.Licenses))
+	for _, l := range req.Licenses {
+		licenses = append(licenses, agentprofile.License{Jurisdiction: l.Jurisdiction, Number: l.Number})
+	}
+
+	ctx := r.Context()
+
+	p, err := s.profiles.Save(ctx, agentprofile.Profile{
+		UserID:        userID,
+		Regions:       req.Regions,
+		Languages:     req.Languages,
+		PriceMin:      req.PriceMin,
+		PriceMax:      req.PriceMax,
+		PropertyTypes: req.PropertyTypes,
+		Licenses:      licenses,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, agentprofile.ErrTooManyTerms), errors.Is(err, agentprofile.ErrInvalidPriceRange),
+			errors.Is(err, agentprofile.ErrInvalidLicense), errors.Is(err, agentprofile.ErrTooManyLicenses),
+			errors.Is(err, region.ErrUnknownRegion):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, agentprofile.ErrNotFound):
+			respondError(w, http.StatusNotFound, "User not found")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to save profile")
+		}
+		return
+	}
+	respondJSON(w, http.StatusOK, newAgentProfileResponse(p))
+}
+
+func (s *Server) handleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := agentCaller(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.profiles.Delete(ctx, userID); err != nil {
+		if errors.Is(err, agentprofile.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Profile not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete profile")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/agentprofile"
+	"brokerflow/auth"
+)
+
+type stubProfiles struct {
+	profile agentprofile.Profile
+	saved   agentprofile.Profile
+	err     error
+}
+
+func (s *stubProfiles) Get(context.Context, string) (agentprofile.Profile, error) {
+	return s.profile, s.err
+}
+
+func (s *stubProfiles) Save(_ context.Context, p agentprofile.Profile) (agentprofile.Profile, error) {
+	s.saved = p
+	return p, s.err
+}
+
+func (s *stubProfiles) Delete(context.Context, string) error { return s.err }
+
+func TestHandlePutProfile(t *testing.T) {
+	stub := &stubProfiles{}
+	server := &Server{profiles: stub}
+	body := `{"regions":["Austin"],"languages":["Spanish"],"priceMin":300000,"propertyTypes":["condo"],"licenses":[{"jurisdiction":"TX","number":"0123"}]}`
+	rec := httptest.NewRecorder()
+
+	server.handlePutProfile(rec, agentRequest(http.MethodPut, "/api/me/profile", body, auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.saved.UserID != "agent-1" || stub.saved.PriceMin == nil || *stub.saved.PriceMin != 300000 || stub.saved.PriceMax != nil {
+		t.Fatalf("unexpected saved profile %+v", stub.saved)
+	}
+	var resp agentProfileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Licenses) != 1 || resp.Licenses[0].Number != "0123" {
+		t.Fatalf("unexpected licenses %+v", resp.Licenses)
+	}
+}
+
+func TestHandleProfile_Errors(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler func(*Server) http.HandlerFunc
+		method  string
+		role    auth.Role
+		err     error
+		want    int
+	}{
+		{"invalid price", func(s *Server) http.HandlerFunc { return s.handlePutProfile }, http.MethodPut, auth.RoleAgent, agentprofile.ErrInvalidPriceRange, http.StatusBadRequest},
+		{"missing", func(s *Server) http.HandlerFunc { return s.handleGetProfile }, http.MethodGet, auth.RoleAgent, agentprofile.ErrNotFound, http.StatusNotFound},
+		{"delete missing", func(s *Server) http.HandlerFunc { return s.handleDeleteProfile }, http.MethodDelete, auth.RoleAgent, agentprofile.ErrNotFound, http.StatusNotFound},
+		{"deleted", func(s *Server) http.HandlerFunc { return s.handleDeleteProfile }, http.MethodDelete, auth.RoleBrokerAdmin, nil, http.StatusNoContent},
+		{"client", func(s *Server) http.HandlerFunc { return s.handleGetProfile }, http.MethodGet, auth.RoleClient, nil, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		server := &Server{profiles: &stubProfiles{err: tc.err}}
+		rec := httptest.NewRecorder()
+		tc.handler(server)(rec, agentRequest(tc.method, "/api/me/profile", `{}`, tc.role))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+)
+
+// maxImportBytes bounds a CSV upload; MaxImportRows rows fit comfortably.
+const maxImportBytes = 5 << 20
+
+type referralImporter interface {
+	Import(ctx context.Context, creatorUserID string, r io.Reader) (referral.ImportSummary, error)
+}
+
+type importRowResponse struct {
+	Line       int    `json:"line" doc:"Line in the file; the header is line 1"`
+	Status     string `json:"status" doc:"created, invalid or failed"`
+	ReferralID string `json:"referralId,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type importSummaryResponse struct {
+	Total   int                 `json:"total"`
+	Created int                 `json:"created"`
+	Invalid int                 `json:"invalid"`
+	Failed  int                 `json:"failed"`
+	Rows    []importRowResponse `json:"rows"`
+}
+
+// handleImportReferrals creates the caller's referrals from a CSV file sent
+// either as the request body or as the "file" field of a multipart form.
+// Rows are reported in file order; invalid rows do not prevent the others
+// from being created.
+func (s *Server) handleImportReferrals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions to create referral")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var file io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		part, _, err := r.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(w, http.StatusRequestEntityTooLarge, "CSV file too large")
+				return
+			}
+			respondError(w, http.StatusBadRequest, "Missing CSV file")
+			return
+		}
+		defer part.Close()
+		file = part
+	}
+
+	// A bulk route: a full import commits MaxImportRows /
+	// DefaultImportBatchSize transactions within BULK_REQUEST_TIMEOUT.
+	ctx := r.Context()
+	summary, err := s.referralImport.Import(ctx, userID, file)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			respondError(w, http.StatusRequestEntityTooLarge, "CSV file too large")
+		case errors.Is(err, referral.ErrImportEmpty), errors.Is(err, referral.ErrImportTooMany),
+			errors.Is(err, referral.ErrImportHeader), errors.Is(err, referral.ErrImportMalformed):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to import referrals")
+		}
+		return
+	}
+
+	resp := importSummaryResponse{
+		Total:   len(summary.Rows),
+		Created: summary.Created,
+		Invalid: summary.Invalid,
+		Failed:  summary.Failed,
+		Rows:    make([]importRowResponse, 0, len(summary.Rows)),
+	}
+	for _, row := range summary.Rows {
+		item := importRowResponse{Line: row.Line, Status: string(row.Status)}
+		if row.Request != nil {
+			item.ReferralID = row.Request.ID
+		}
+		if row.Err != nil {
+			item.Error = row.Err.Error()
+		}
+		resp.Rows = append(resp.Rows, item)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+)
+
+type stubImporter struct {
+	got     string
+	summary referral.ImportSummary
+	err     error
+}
+
+func (s *stubImporter) Import(_ context.Context, _ string, r io.Reader) (referral.ImportSummary, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return referral.ImportSummary{}, err
+	}
+	s.got = string(body)
+	return s.summary, s.err
+}
+
+func TestHandleImportReferrals_Multipart(t *testing.T) {
+	stub := &stubImporter{summary: referral.ImportSummary{
+		Rows: []referral.ImportRowResult{
+			{Line: 2, Status: referral.ImportRowCreated, Request: &referral.Request{ID: "ref-1"}},
+			{Line: 3, Status: referral.ImportRowInvalid, Err: referral.ErrInvalidPriceRange},
+		},
+		Created: 1, Invalid: 1,
+	}}
+	server := &Server{referralImport: stub}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "referrals.csv")
+	part.Write([]byte("region,price_min,price_max,sla_hours\n"))
+	form.Close()
+	req := agentRequest(http.MethodPost, "/api/referrals/import", body.String(), auth.RoleAgent)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	server.handleImportReferrals(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.got != "region,price_min,price_max,sla_hours\n" {
+		t.Fatalf("expected the uploaded file, got %q", stub.got)
+	}
+	var resp importSummaryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 2 || resp.Created != 1 || resp.Rows[0].ReferralID != "ref-1" || resp.Rows[1].Error == "" {
+		t.Fatalf("unexpected summary %+v", resp)
+	}
+}
+
+func TestHandleImportReferrals_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		role auth.Role
+		err  error
+		want int
+	}{
+		{"bad header", auth.RoleAgent, referral.ErrImportHeader, http.StatusBadRequest},
+		{"not an agent", auth.Role("viewer"), nil, http.StatusForbidden},
+		{"store failure", auth.RoleAgent, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		server := &Server{referralImport: &stubImporter{err: tc.err}}
+		req := agentRequest(http.MethodPost, "/api/referrals/import", "region\n", tc.role)
+		req.Header.Set("Content-Type", "text/csv")
+		rec := httptest.NewRecorder()
+		server.handleImportReferrals(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/money"
+	"brokerflow/referral"
+	"github.com/google/uuid"
+)
+
+type createReferralRequest struct {
+	Region        []string `json:"region"`
+	PriceMin      int64    `json:"priceMin"`
+	PriceMax      int64    `json:"priceMax"`
+	Currency      string   `json:"currency,omitempty" doc:"ISO 4217 code, USD or CAD; defaults to USD"`
+	PropertyType  string   `json:"propertyType"`
+	DealType      string   `json:"dealType"`
+	Languages     []string `json:"languages"`
+	SLAHours      int      `json:"slaHours"`
+	MatchTTLHours int      `json:"matchTtlHours,omitempty"`
+}
+
+// duplicateReferralResponse is the 409 for a referral that looks like one
+// the caller created recently; resending with force=true creates it anyway.
+type duplicateReferralResponse struct {
+	Message     string `json:"message"`
+	Code        string `json:"code" doc:"Always duplicate_referral"`
+	DuplicateID string `json:"duplicateId"`
+}
+
+const errCodeDuplicateReferral = "duplicate_referral"
+
+// updateReferralRequest edits an open referral; omitted fields are kept.
+type updateReferralRequest struct {
+	Region        *[]string `json:"region,omitempty"`
+	PriceMin      *int64    `json:"priceMin,omitempty"`
+	PriceMax      *int64    `json:"priceMax,omitempty"`
+	Currency      *string   `json:"currency,omitempty"`
+	PropertyType  *string   `json:"propertyType,omitempty"`
+	DealType      *string   `json:"dealType,omitempty"`
+	Languages     *[]string `json:"languages,omitempty"`
+	SLAHours      *int      `json:"slaHours,omitempty"`
+	MatchTTLHours *int      `json:"matchTtlHours,omitempty"`
+}
+
+type cancelReferralRequest struct {
+	Reason *string `json:"reason"`
+}
+
+func (s *Server) handleCreateReferral(w http.ResponseWriter, r *http.Request) {
+	var req createReferralRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions to create referral")
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	ctx := r.Context()
+
+	created, err := s.referralService.Create(ctx, referral.CreateParams{
+		CreatorUserID: userID,
+		Region:        req.Region,
+		PriceMin:      req.PriceMin,
+		PriceMax:      req.PriceMax,
+		Currency:      money.Currency(req.Currency),
+		PropertyType:  req.PropertyType,
+		DealType:      req.DealType,
+		Languages:     req.Languages,
+		SLAHours:      req.SLAHours,
+		MatchTTLHours: req.MatchTTLHours,
+		Force:         force,
+	})
+	if err != nil {
+		var dup *referral.DuplicateError
+		if errors.As(err, &dup) {
+			respondJSON(w, http.StatusConflict, duplicateReferralResponse{
+				Message:     localizedMessage(w, errCodeDuplicateReferral, "A similar referral was created recently; retry with force=true to create it anyway"),
+				Code:        errCodeDuplicateReferral,
+				DuplicateID: dup.Existing.ID,
+			})
+			return
+		}
+		respondServiceError(w, err, "Failed to create referral")
+		return
+	}
+
+	setETag(w, created.Version)
+	respondJSON(w, http.StatusCreated, newReferralResponse(created, requestLocale(r)))
+}
+
+func (s *Server) handleListReferrals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+
+	ctx := r.Context()
+
+	scope, err := s.listScope(ctx, userID, query.Get("officeId"))
+	if err != nil {
+		respondServiceError(w, err, "Failed to resolve access scope")
+		return
+	}
+
+	includeArchived, _ := strconv.ParseBool(query.Get("includeArchived"))
+
+	filters := referral.Filters{
+		Scope:           scope,
+		Status:          referral.Status(query.Get("status")),
+		Region:          query.Get("region"),
+		DealType:        query.Get("dealType"),
+		IncludeArchived: includeArchived,
+		Page:            page,
+		PageSize:        pageSize,
+		SortKey:         query.Get("sortKey"),
+		SortOrder:       query.Get("sortOrder"),
+	}
+
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+
+	result, err := s.referralService.List(ctx, filters)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load referrals")
+		return
+	}
+
+	items := make([]referralResponse, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, newReferralResponse(item, requestLocale(r)))
+	}
+
+	respondJSON(w, http.StatusOK, paginatedReferrals{
+		Items:      items,
+		Total:      result.Total,
+		TotalExact: result.TotalExact,
+		HasMore:    result.HasMore,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+	})
+}
+
+func (s *Server) handleCancelReferral(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	role, _ := r.Context().Value(ctxKeyRole).(auth.Role)
+	var payload cancelReferralRequest
+	if err := readJSON(r.Body, &payload); err != nil && err != io.EOF {
+		respondBodyError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	updated, err := s.referralService.Cancel(ctx, referral.CancelParams{
+		RequestID: requestID,
+		ActorID:   userID,
+		ActorRole: string(role),
+		Reason:    payload.Reason,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrNotFound):
+			respondError(w, http.StatusNotFound, "Referral not found")
+		case errors.Is(err, referral.ErrCancelForbidden):
+			respondError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, referral.ErrCancelInvalidState):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to cancel referral")
+		}
+		return
+	}
+
+	setETag(w, updated.Version)
+	respondJSON(w, http.StatusOK, newReferralResponse(updated, requestLocale(r)))
+}
+
+// handleArchiveReferral archives a closed or cancelled referral in the
+// caller's scope, hiding it from the default referral list.
+func (s *Server) handleArchiveReferral(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions to archive referral")
+		return
+	}
+	requestID := r.PathValue("id")
+	if _, err := uuid.Parse(requestID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+
+	archived, err := s.referralService.Archive(ctx, referral.ArchiveParams{RequestID: requestID, Scope: scope})
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrNotFound):
+			respondError(w, http.StatusNotFound, "Referral not found")
+		case errors.Is(err, referral.ErrArchiveInvalidState):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to archive referral")
+		}
+		return
+	}
+
+	setETag(w, archived.Version)
+	respondJSON(w, http.StatusOK, newReferralResponse(archived, requestLocale(r)))
+}
+
+// handleUpdateReferral edits an open referral in the caller's scope. The
+// client must send the ETag it last saw as If-Match; when someone else has
+// changed the referral since, the update is refused with 412 and the current
+// referral so the client can merge and retry.
+func (s *Server) handleUpdateReferral(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions to edit referral")
+		return
+	}
+	requestID := r.PathValue("id")
+	if _, err := uuid.Parse(requestID); err != nil {
+		respondError(w, http.StatusNotFound, "Referral not found")
+		return
+	}
+	ifVersion, ok := ifMatchVersion(r)
+	if !ok {
+		respondError(w, http.StatusPreconditionRequired, "If-Match header required")
+		return
+	}
+	var req updateReferralRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to resolve access scope")
+		return
+	}
+
+	updated, err := s.referralService.Update(ctx, referral.UpdateParams{
+		RequestID:     requestID,
+		Scope:         scope,
+		IfVersion:     ifVersion,
+		Region:        req.Region,
+		PriceMin:      req.PriceMin,
+		PriceMax:      req.PriceMax,
+		Currency:      (*money.Currency)(req.Currency),
+		PropertyType:  req.PropertyType,
+		DealType:      req.DealType,
+		Languages:     req.Languages,
+		SLAHours:      req.SLAHours,
+		MatchTTLHours: req.MatchTTLHours,
+	})
+	if err != nil {
+		var conflict *referral.VersionConflictError
+		switch {
+		case errors.As(err, &conflict):
+			setETag(w, conflict.Current.Version)
+			respondJSON(w, http.StatusPreconditionFailed, newReferralResponse(conflict.Current, requestLocale(r)))
+		default:
+			respondServiceError(w, err, "Failed to update referral")
+		}
+		return
+	}
+
+	setETag(w, updated.Version)
+	respondJSON(w, http.StatusOK, newReferralResponse(updated, requestLocale(r)))
+}
+
+type referralResponse struct {
+	ID             string   `json:"id"`
+	CreatorAgentID string   `json:"creatorAgentId"`
+	Region         []string `json:"region"`
+	PriceMin       int64    `json:"priceMin"`
+	PriceMax       int64    `json:"priceMax"`
+	Currency       string   `json:"currency" doc:"ISO 4217 code of priceMin and priceMax"`
+	PriceRange     string   `json:"priceRange" doc:"Price band formatted for the Accept-Language locale"`
+	PropertyType   string   `json:"propertyType"`
+	DealType       string   `json:"dealType"`
+	Languages      []string `json:"languages"`
+	SLAHours       int      `json:"slaHours"`
+	MatchTTLHours  int      `json:"matchTtlHours"`
+	Status         string   `json:"status"`
+	CancelReason   *string  `json:"cancelReason,omitempty"`
+	Version        int      `json:"version" doc:"Send as If-Match, quoted, to edit the referral"`
+	CreatedAt      string   `json:"createdAt"`
+	UpdatedAt      string   `json:"updatedAt"`
+	ArchivedAt     *string  `json:"archivedAt,omitempty"`
+}
+
+type paginatedReferrals struct {
+	Items      []referralResponse `json:"items"`
+	Total      int                `json:"total"`
+	TotalExact bool               `json:"totalExact" doc:"False when total is an estimate or a cached count; page with hasMore instead"`
+	HasMore    bool               `json:"hasMore" doc:"Whether another page follows this one"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+}
+
+func newReferralResponse(r referral.Request, locale money.Locale) referralResponse {
+	region := append([]string{}, r.Region...)
+	languages := append([]string{}, r.Languages...)
+	if region == nil {
+		region = []string{}
+	}
+	if languages == nil {
+		languages = []string{}
+	}
+	currency := r.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	resp := referralResponse{
+		ID:             r.ID,
+		CreatorAgentID: r.CreatorUserID,
+		Region:         region,
+		PriceMin:       r.PriceMin,
+		PriceMax:       r.PriceMax,
+		Currency:       string(currency),
+		PriceRange:     money.FormatRange(money.New(r.PriceMin, currency), money.New(r.PriceMax, currency), locale),
+		PropertyType:   r.PropertyType,
+		DealType:       r.DealType,
+		Languages:      languages,
+		SLAHours:       r.SLAHours,
+		MatchTTLHours:  r.MatchTTLHours,
+		Status:         string(r.Status),
+		CancelReason:   r.CancelReason,
+		Version:        r.Version,
+		CreatedAt:      r.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:      r.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if r.ArchivedAt != nil {
+		val := r.ArchivedAt.UTC().Format(time.RFC3339)
+		resp.ArchivedAt = &val
+	}
+	return resp
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+)
+
+func TestHandleCreateReferral_ForbidClientRole(t *testing.T) {
+	server := &Server{}
+	body := strings.NewReader(`{"region":["us"],"priceMin":100,"priceMax":200,"propertyType":"condo","dealType":"buy","languages":["English"],"slaHours":24}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/referrals", body)
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "user-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleClient)
+	rec := httptest.NewRecorder()
+
+	server.handleCreateReferral(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestHandleCreateReferral_DuplicateConflictsUntilForced(t *testing.T) {
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	server := &Server{
+		referralService: referral.NewService(&testsupport.TxBeginner{}, testsupport.NewReferrals(users), nil, nil),
+	}
+	const body = `{"region":["Austin"],"priceMin":100,"priceMax":200,"propertyType":"condo","dealType":"buy","languages":["English"],"slaHours":24}`
+	create := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.handleCreateReferral(rec, agentRequest(http.MethodPost, path, body, auth.RoleAgent))
+		return rec
+	}
+
+	first := create("/api/referrals")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	var created referralResponse
+	if err := json.Unmarshal(first.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	rec := create("/api/referrals")
+	var dup duplicateReferralResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &dup); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusConflict || dup.Code != errCodeDuplicateReferral || dup.DuplicateID != created.ID {
+		t.Fatalf("expected 409 naming %s, got %d: %s", created.ID, rec.Code, rec.Body.String())
+	}
+
+	if rec := create("/api/referrals?force=true"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected force=true to create, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleUpdateReferral_StaleIfMatchReturnsCurrent(t *testing.T) {
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	referrals := testsupport.NewReferrals(users)
+	server := &Server{
+		authService:     auth.NewService(users, "secret"),
+		referralService: referral.NewService(&testsupport.TxBeginner{}, referrals, nil, nil),
+	}
+	const id = "0b5f7a52-8d0e-4c1e-9a55-3f7e2f3c9a10"
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{
+		ID: id, CreatorUserID: "agent-1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, SLAHours: 24, MatchTTLHours: 72, Status: referral.StatusOpen,
+	}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+	patch := func(body, ifMatch string) *httptest.ResponseRecorder {
+		req := agentRequest(http.MethodPatch, "/api/referrals/"+id, body, auth.RoleAgent)
+		req.SetPathValue("id", id)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		server.handleUpdateReferral(rec, req)
+		return rec
+	}
+
+	if rec := patch(`{"priceMax":300}`, ""); rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428 without If-Match, got %d", rec.Code)
+	}
+	rec := patch(`{"priceMax":300}`, `"1"`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+
+	rec = patch(`{"slaHours":48}`, `"1"`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale ETag, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var current referralResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &current); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if current.Version != 2 || current.PriceMax != 300 || current.SLAHours != 24 || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected the latest referral, got %+v (ETag %q)", current, rec.Header().Get("ETag"))
+	}
+}
+
+func TestHandleArchiveReferral(t *testing.T) {
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	referrals := testsupport.NewReferrals(users)
+	server := &Server{
+		authService:     auth.NewService(users, "secret"),
+		referralService: referral.NewService(&testsupport.TxBeginner{}, referrals, nil, nil),
+	}
+	const id = "6a3c1f0e-2b7d-4d8e-9f41-0c5e8b7a2d13"
+	if _, err := referrals.Create(context.Background(), nil, referral.Request{
+		ID: id, CreatorUserID: "agent-1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, SLAHours: 24, Status: referral.StatusOpen,
+	}); err != nil {
+		t.Fatalf("seed referral: %v", err)
+	}
+	archive := func() *httptest.ResponseRecorder {
+		req := agentRequest(http.MethodPost, "/api/referrals/"+id+"/archive", "", auth.RoleAgent)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		server.handleArchiveReferral(rec, req)
+		return rec
+	}
+
+	if rec := archive(); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an open referral, got %d", rec.Code)
+	}
+	if _, err := referrals.UpdateStatus(context.Background(), nil, id, referral.StatusCancelled, nil); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	rec := archive()
+	var got referralResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusOK || got.ArchivedAt == nil {
+		t.Fatalf("expected an archived referral, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"brokerflow/region"
+)
+
+type regionService interface {
+	Get(ctx context.Context, code string) (region.Region, error)
+	List(ctx context.Context, f region.Filter) ([]region.Region, error)
+}
+
+type regionResponse struct {
+	Code       string          `json:"code"`
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind" doc:"country, state, county, city or district"`
+	ParentCode *string         `json:"parentCode,omitempty"`
+	Path       []string        `json:"path" doc:"Ancestor codes and the region's own, root first"`
+	Aliases    []string        `json:"aliases"`
+	Boundary   json.RawMessage `json:"boundary,omitempty" doc:"GeoJSON polygon, when known"`
+}
+
+type regionListResponse struct {
+	Items []regionResponse `json:"items"`
+}
+
+func newRegionResponse(r region.Region) regionResponse {
+	return regionResponse{
+		Code:       r.Code,
+		Name:       r.Name,
+		Kind:       string(r.Kind),
+		ParentCode: r.ParentCode,
+		Path:       append([]string{}, r.Path...),
+		Aliases:    append([]string{}, r.Aliases...),
+		Boundary:   r.Boundary,
+	}
+}
+
+func (s *Server) handleListRegions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	regions, err := s.regions.List(r.Context(), region.Filter{
+		Parent: query.Get("parent"),
+		Within: query.Get("within"),
+		Query:  query.Get("q"),
+		Limit:  limit,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to list regions")
+		return
+	}
+	items := make([]regionResponse, 0, len(regions))
+	for _, reg := range regions {
+		items = append(items, newRegionResponse(reg))
+	}
+	respondJSON(w, http.StatusOK, regionListResponse{Items: items})
+}
+
+func (s *Server) handleGetRegion(w http.ResponseWriter, r *http.Request) {
+	reg, err := s.regions.Get(r.Context(), r.PathValue("code"))
+	if err != nil {
+		if errors.Is(err, region.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Region not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load region")
+		return
+	}
+	respondJSON(w, http.StatusOK, newRegionResponse(reg))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/region"
+	"brokerflow/testsupport"
+)
+
+func TestHandleRegions_ListAndGet(t *testing.T) {
+	store := testsupport.NewRegions()
+	store.Add(region.Region{Code: "us-ny", Name: "New York", Kind: region.KindState, Aliases: []string{"ny"}})
+	parent := "us-ny"
+	store.Add(region.Region{Code: "us-ny-brooklyn", Name: "Brooklyn", Kind: region.KindDistrict, ParentCode: &parent, Aliases: []string{"brooklyn"},
+		Boundary: json.RawMessage(`{"type":"Polygon","coordinates":[]}`)})
+	server := &Server{regions: region.NewService(store)}
+
+	rec := httptest.NewRecorder()
+	server.handleListRegions(rec, agentRequest(http.MethodGet, "/api/regions?within=us-ny&q=brook", "", auth.RoleAgent))
+	var list regionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Items) != 1 || list.Items[0].Code != "us-ny-brooklyn" || list.Items[0].Boundary != nil {
+		t.Fatalf("expected Brooklyn without its boundary, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req := agentRequest(http.MethodGet, "/api/regions/US-NY-Brooklyn", "", auth.RoleAgent)
+	req.SetPathValue("code", "US-NY-Brooklyn")
+	rec = httptest.NewRecorder()
+	server.handleGetRegion(rec, req)
+	var got regionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(got.Path) != 2 || got.Path[0] != "us-ny" || got.Boundary == nil {
+		t.Fatalf("expected Brooklyn with path and boundary, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = agentRequest(http.MethodGet, "/api/regions/atlantis", "", auth.RoleAgent)
+	req.SetPathValue("code", "atlantis")
+	rec = httptest.NewRecorder()
+	server.handleGetRegion(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
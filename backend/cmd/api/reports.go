@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/money"
+	"brokerflow/report"
+)
+
+type reportService interface {
+	Summary(ctx context.Context, params report.Params) (report.Summary, error)
+}
+
+type monthCountResponse struct {
+	Month string `json:"month" doc:"YYYY-MM (UTC)"`
+	Count int    `json:"count"`
+}
+
+type moneyResponse struct {
+	Amount    int64  `json:"amount" doc:"Whole units of currency"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted" doc:"Amount formatted for the Accept-Language locale"`
+}
+
+func newMoneyResponse(m money.Money, locale money.Locale) moneyResponse {
+	return moneyResponse{Amount: m.Amount, Currency: string(m.Currency), Formatted: m.Format(locale)}
+}
+
+type reportSummaryResponse struct {
+	From                  time.Time            `json:"from"`
+	To                    time.Time            `json:"to" doc:"Exclusive"`
+	ReferralsPerMonth     []monthCountResponse `json:"referralsPerMonth"`
+	ReferralsCreated      int                  `json:"referralsCreated"`
+	ReferralValue         []moneyResponse      `json:"referralValue" doc:"Sum of price band midpoints, per currency"`
+	TotalReferralValue    moneyResponse        `json:"totalReferralValue" doc:"referralValue converted into the requested currency"`
+	MatchesAnswered       int                  `json:"matchesAnswered" doc:"Invitations accepted, declined or expired"`
+	MatchesAccepted       int                  `json:"matchesAccepted"`
+	AcceptanceRate        *float64             `json:"acceptanceRate" doc:"matchesAccepted / matchesAnswered; null when none were answered"`
+	AvgAcceptedScore      *float64             `json:"avgAcceptedScore" doc:"Mean match score of accepted candidates"`
+	Agreements            int                  `json:"agreements"`
+	Disputed              int                  `json:"disputed"`
+	DisputeRate           *float64             `json:"disputeRate" doc:"disputed / agreements; null when there were none"`
+	MedianDaysToEffective *float64             `json:"medianDaysToEffective" doc:"From agreement creation to effective"`
+}
+
+// parseTimeParam accepts a date (midnight UTC) or an RFC 3339 timestamp.
+func parseTimeParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// handleReportSummary aggregates the caller's activity over [from, to):
+// their own referrals for agents, the whole brokerage for broker admins.
+// Money totals are converted into currency, USD by default.
+func (s *Server) handleReportSummary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var params report.Params
+	query := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &params.From}, {"to", &params.To}} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid "+p.name+": use YYYY-MM-DD or RFC 3339")
+			return
+		}
+		*p.dst = t
+	}
+	if raw := query.Get("currency"); raw != "" {
+		currency, err := money.ParseCurrency(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid currency")
+			return
+		}
+		params.Currency = currency
+	}
+
+	ctx := r.Context()
+
+	scope, err := s.listScope(ctx, userID, query.Get("officeId"))
+	if err != nil {
+		respondServiceError(w, err, "Failed to resolve access scope")
+		return
+	}
+	params.Scope = scope
+
+	summary, err := s.reports.Summary(ctx, params)
+	if err != nil {
+		if errors.Is(err, report.ErrInvalidRange) || errors.Is(err, report.ErrRangeTooLong) ||
+			errors.Is(err, money.ErrNoRate) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	months := make([]monthCountResponse, 0, len(summary.ReferralsPerMonth))
+	for _, m := range summary.ReferralsPerMonth {
+		months = append(months, monthCountResponse{Month: m.Month.UTC().Format("2006-01"), Count: m.Count})
+	}
+	locale := requestLocale(r)
+	value := make([]moneyResponse, 0, len(summary.ReferralValue))
+	for _, v := range summary.ReferralValue {
+		value = append(value, newMoneyResponse(v, locale))
+	}
+	respondJSON(w, http.StatusOK, reportSummaryResponse{
+		From:                  summary.From,
+		To:                    summary.To,
+		ReferralsPerMonth:     months,
+		ReferralsCreated:      summary.ReferralsCreated,
+		ReferralValue:         value,
+		TotalReferralValue:    newMoneyResponse(summary.TotalReferralValue, locale),
+		MatchesAnswered:       summary.MatchesAnswered,
+		MatchesAccepted:       summary.MatchesAccepted,
+		AcceptanceRate:        summary.AcceptanceRate,
+		AvgAcceptedScore:      summary.AvgAcceptedScore,
+		Agreements:            summary.Agreements,
+		Disputed:              summary.Disputed,
+		DisputeRate:           summary.DisputeRate,
+		MedianDaysToEffective: summary.MedianDaysToEffective,
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/report"
+)
+
+type stubReports struct {
+	params  report.Params
+	summary report.Summary
+	err     error
+}
+
+func (s *stubReports) Summary(_ context.Context, params report.Params) (report.Summary, error) {
+	s.params = params
+	return s.summary, s.err
+}
+
+func TestHandleReportSummary(t *testing.T) {
+	rate := 0.5
+	stub := &stubReports{summary: report.Summary{
+		Counts: report.Counts{
+			ReferralsPerMonth: []report.MonthCount{{Month: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Count: 2}},
+			ReferralsCreated:  2,
+		},
+		AcceptanceRate: &rate,
+	}}
+	server := &Server{reports: stub}
+
+	req := agentRequest(http.MethodGet, "/api/reports/summary?from=2026-01-01&to=2026-04-01T00:00:00Z", "", auth.RoleAgent)
+	rec := httptest.NewRecorder()
+	server.handleReportSummary(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !stub.params.From.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !stub.params.To.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected window %+v", stub.params)
+	}
+	if stub.params.Scope.UserID != "agent-1" || stub.params.Scope.Brokerwide() {
+		t.Fatalf("expected the agent's own scope, got %+v", stub.params.Scope)
+	}
+	var resp reportSummaryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.ReferralsPerMonth) != 1 || resp.ReferralsPerMonth[0].Month != "2026-03" || resp.AcceptanceRate == nil || *resp.AcceptanceRate != 0.5 {
+		t.Fatalf("unexpected summary %+v", resp)
+	}
+}
+
+func TestHandleReportSummary_BadInput(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{"?from=yesterday", nil},
+		{"?from=2026-05-01&to=2026-04-01", report.ErrInvalidRange},
+	} {
+		server := &Server{reports: &stubReports{err: tc.err}}
+		req := agentRequest(http.MethodGet, "/api/reports/summary"+tc.query, "", auth.RoleAgent)
+		rec := httptest.NewRecorder()
+		server.handleReportSummary(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", tc.query, rec.Code)
+		}
+	}
+}
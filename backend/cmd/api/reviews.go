@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/review"
+	"github.com/google/uuid"
+)
+
+type reviewService interface {
+	Submit(ctx context.Context, params review.SubmitParams) (review.Review, error)
+	List(ctx context.Context, revieweeID string, page, pageSize int) ([]review.Review, review.Summary, error)
+}
+
+type submitReviewRequest struct {
+	Rating  int     `json:"rating" doc:"1 to 5"`
+	Comment *string `json:"comment,omitempty"`
+}
+
+type reviewResponse struct {
+	ID          string  `json:"id"`
+	AgreementID string  `json:"agreementId"`
+	ReviewerID  string  `json:"reviewerId"`
+	RevieweeID  string  `json:"revieweeId"`
+	Rating      int     `json:"rating"`
+	Comment     *string `json:"comment,omitempty"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
+type reviewListResponse struct {
+	Items       []reviewResponse `json:"items"`
+	Rating      float64          `json:"rating" doc:"Average rating across all reviews, 0 when there are none"`
+	ReviewCount int              `json:"reviewCount"`
+	Page        int              `json:"page"`
+	PageSize    int              `json:"pageSize"`
+}
+
+func newReviewResponse(rev review.Review) reviewResponse {
+	return reviewResponse{
+		ID:          rev.ID,
+		AgreementID: rev.AgreementID,
+		ReviewerID:  rev.ReviewerID,
+		RevieweeID:  rev.RevieweeID,
+		Rating:      rev.Rating,
+		Comment:     rev.Comment,
+		CreatedAt:   rev.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// handleSubmitReview lets the referral owner or the receiving agent review
+// the other once the deal has closed.
+func (s *Server) handleSubmitReview(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+	var req submitReviewRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	rev, err := s.reviews.Submit(ctx, review.SubmitParams{
+		AgreementID: agreementID,
+		ReviewerID:  userID,
+		Rating:      req.Rating,
+		Comment:     req.Comment,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, review.ErrInvalidRating), errors.Is(err, review.ErrCommentTooLong):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, review.ErrAgreementMissing), errors.Is(err, review.ErrNotParty):
+			respondError(w, http.StatusNotFound, "Agreement not found")
+		case errors.Is(err, review.ErrDealNotClosed), errors.Is(err, review.ErrNoCounterparty),
+			errors.Is(err, review.ErrAlreadyReviewed):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to submit review")
+		}
+		return
+	}
+	respondJSON(w, http.StatusCreated, newReviewResponse(rev))
+}
+
+// handleListAgentReviews lists the reviews an agent has received, newest
+// first, with their aggregate rating.
+func (s *Server) handleListAgentReviews(w http.ResponseWriter, r *http.Request) {
+	if userID, ok := r.Context().Value(ctxKeyUserID).(string); !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agentID := r.PathValue("id")
+	if _, err := uuid.Parse(agentID); err != nil {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	ctx := r.Context()
+
+	reviews, summary, err := s.reviews.List(ctx, agentID, page, pageSize)
+	if err != nil {
+		if errors.Is(err, review.ErrAgentNotFound) {
+			respondError(w, http.StatusNotFound, "Agent not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load reviews")
+		return
+	}
+
+	items := make([]reviewResponse, 0, len(reviews))
+	for _, rev := range reviews {
+		items = append(items, newReviewResponse(rev))
+	}
+	respondJSON(w, http.StatusOK, reviewListResponse{
+		Items:       items,
+		Rating:      summary.Rating,
+		ReviewCount: summary.Count,
+		Page:        page,
+		PageSize:    pageSize,
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/review"
+)
+
+type stubReviews struct {
+	submitted review.SubmitParams
+	submitErr error
+	reviews   []review.Review
+	summary   review.Summary
+	listErr   error
+}
+
+func (s *stubReviews) Submit(_ context.Context, params review.SubmitParams) (review.Review, error) {
+	s.submitted = params
+	if s.submitErr != nil {
+		return review.Review{}, s.submitErr
+	}
+	return review.Review{
+		ID: "rev-1", AgreementID: params.AgreementID, ReviewerID: params.ReviewerID, RevieweeID: "agent-2",
+		Rating: params.Rating, Comment: params.Comment, CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *stubReviews) List(context.Context, string, int, int) ([]review.Review, review.Summary, error) {
+	return s.reviews, s.summary, s.listErr
+}
+
+func TestHandleSubmitReview(t *testing.T) {
+	stub := &stubReviews{}
+	server := &Server{reviews: stub}
+	req := agentRequest(http.MethodPost, "/api/agreements/"+testAgreementID+"/reviews", `{"rating":4,"comment":"Responsive"}`, auth.RoleAgent)
+	req.SetPathValue("id", testAgreementID)
+	rec := httptest.NewRecorder()
+
+	server.handleSubmitReview(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.submitted.ReviewerID != "agent-1" || stub.submitted.AgreementID != testAgreementID || stub.submitted.Rating != 4 {
+		t.Fatalf("unexpected submit params %+v", stub.submitted)
+	}
+	var resp reviewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.RevieweeID != "agent-2" || resp.Comment == nil || *resp.Comment != "Responsive" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleSubmitReview_Errors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{review.ErrInvalidRating, http.StatusBadRequest},
+		{review.ErrCommentTooLong, http.StatusBadRequest},
+		{review.ErrAgreementMissing, http.StatusNotFound},
+		{review.ErrNotParty, http.StatusNotFound},
+		{review.ErrDealNotClosed, http.StatusConflict},
+		{review.ErrNoCounterparty, http.StatusConflict},
+		{review.ErrAlreadyReviewed, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		server := &Server{reviews: &stubReviews{submitErr: tc.err}}
+		req := agentRequest(http.MethodPost, "/api/agreements/"+testAgreementID+"/reviews", `{"rating":5}`, auth.RoleAgent)
+		req.SetPathValue("id", testAgreementID)
+		rec := httptest.NewRecorder()
+
+		server.handleSubmitReview(rec, req)
+
+		if rec.Code != tc.want {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestHandleListAgentReviews(t *testing.T) {
+	stub := &stubReviews{
+		reviews: []review.Review{{ID: "rev-1", RevieweeID: testAgreementID, Rating: 5, CreatedAt: time.Now()}},
+		summary: review.Summary{UserID: testAgreementID, Rating: 4.5, Count: 2},
+	}
+	server := &Server{reviews: stub}
+	req := agentRequest(http.MethodGet, "/api/agents/"+testAgreementID+"/reviews?page=1&pageSize=1", "", auth.RoleClient)
+	req.SetPathValue("id", testAgreementID)
+	rec := httptest.NewRecorder()
+
+	server.handleListAgentReviews(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp reviewListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Rating != 4.5 || resp.ReviewCount != 2 || len(resp.Items) != 1 || resp.PageSize != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	server = &Server{reviews: &stubReviews{listErr: review.ErrAgentNotFound}}
+	req = agentRequest(http.MethodGet, "/api/agents/"+testAgreementID+"/reviews", "", auth.RoleClient)
+	req.SetPathValue("id", testAgreementID)
+	rec = httptest.NewRecorder()
+	server.handleListAgentReviews(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown agent, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+
+	"brokerflow/config"
+	"brokerflow/files"
+	"brokerflow/observability"
+)
+
+// handler registers the API endpoints on mux and wraps it in the middleware
+// every request passes through, outermost first: metrics, logging, CORS,
+// locale, per-route body limits and per-route deadlines. Routes added to mux
+// afterwards are served through the same chain.
+func (s *Server) handler(mux *http.ServeMux, cors config.CORS, metrics *observability.Metrics) http.Handler {
+	s.routes(mux)
+	return metrics.Middleware(loggingMiddleware(corsMiddleware(cors)(localeMiddleware(bodyLimitMiddleware(mux, s.deadlineMiddleware(mux))))))
+}
+
+// routes registers the API endpoints on mux. Every pattern names its method,
+// so the mux answers 405 with an Allow header for a known path requested
+// with another method, and path parameters are read with r.PathValue.
+// Document new routes in buildOpenAPI as well.
+func (s *Server) routes(mux *http.ServeMux) {
+	authed := s.authMiddleware
+
+	// 认证接口（匹配前端路径）
+	mux.HandleFunc("POST /auth/register", s.handleRegister)
+	mux.HandleFunc("POST /auth/register/invitation", s.handleAcceptInvitation)
+	mux.HandleFunc("POST /auth/login", s.handleLogin)
+	mux.HandleFunc("POST /auth/login/2fa", s.handleVerifyTwoFactor)
+	mux.HandleFunc("GET /auth/oidc/providers", s.handleOIDCProviders)
+	mux.HandleFunc("GET /auth/oidc/{provider}/authorize", s.handleOIDCAuthorize)
+	mux.HandleFunc("POST /auth/oidc/{provider}/callback", s.handleOIDCCallback)
+	mux.HandleFunc("GET /.well-known/jwks.json", s.handleJWKS)
+
+	// 当前用户
+	mux.HandleFunc("GET /api/me", authed(s.handleMe))
+	mux.HandleFunc("DELETE /api/me", authed(s.handleDeleteMe))
+	mux.HandleFunc("GET /api/me/profile", authed(s.handleGetProfile))
+	mux.HandleFunc("PUT /api/me/profile", authed(s.handlePutProfile))
+	mux.HandleFunc("DELETE /api/me/profile", authed(s.handleDeleteProfile))
+	mux.HandleFunc("GET /api/me/email-preferences", authed(s.handleGetEmailPreferences))
+	mux.HandleFunc("PUT /api/me/email-preferences", authed(s.handlePutEmailPreferences))
+	mux.HandleFunc("GET /api/me/filters", authed(s.handleListSavedFilters))
+	mux.HandleFunc("POST /api/me/filters", authed(s.handleCreateSavedFilter))
+	mux.HandleFunc("DELETE /api/me/filters/{id}", authed(s.handleDeleteSavedFilter))
+	mux.HandleFunc("GET /api/me/activity", authed(s.handleListActivity))
+	mux.HandleFunc("POST /api/me/2fa/totp", authed(s.handleEnrollTOTP))
+	mux.HandleFunc("POST /api/me/2fa/totp/confirm", authed(s.handleConfirmTOTP))
+	mux.HandleFunc("GET /api/me/sessions", authed(s.handleListSessions))
+	mux.HandleFunc("DELETE /api/me/sessions/{id}", authed(s.handleRevokeSession))
+
+	// 区域
+	mux.HandleFunc("GET /api/regions", authed(s.handleListRegions))
+	mux.HandleFunc("GET /api/regions/{code}", authed(s.handleGetRegion))
+
+	// 转介与匹配
+	mux.HandleFunc("POST /api/referrals", authed(s.handleCreateReferral))
+	mux.HandleFunc("GET /api/referrals", authed(s.handleListReferrals))
+	mux.HandleFunc("POST /api/referrals/import", authed(s.handleImportReferrals))
+	mux.HandleFunc("PATCH /api/referrals/{id}", authed(s.handleUpdateReferral))
+	mux.HandleFunc("POST /api/referrals/{id}/cancel", authed(s.handleCancelReferral))
+	mux.HandleFunc("POST /api/referrals/{id}/archive", authed(s.handleArchiveReferral))
+	mux.HandleFunc("PUT /api/referrals/{id}/client", authed(s.handleLinkReferralClient))
+	mux.HandleFunc("GET /api/referrals/{id}/client-record", authed(s.handleGetClientRecord))
+	mux.HandleFunc("POST /api/referrals/{id}/client-record", authed(s.handleAttachClientRecord))
+	mux.HandleFunc("GET /api/referrals/{id}/matches", authed(s.handleListMatches))
+	mux.HandleFunc("POST /api/referrals/{id}/matches", authed(s.handleCreateMatch))
+	mux.HandleFunc("POST /api/referrals/{id}/matches/bulk", authed(s.handleBulkCreateMatches))
+	mux.HandleFunc("PATCH /api/referrals/{id}/matches/{matchId}", authed(s.handleUpdateMatch))
+	mux.HandleFunc("POST /api/referrals/{id}/matches/{matchId}/counter", authed(s.handleRespondToCounter))
+	mux.HandleFunc("GET /api/matches", authed(s.handleCandidateMatches))
+	mux.HandleFunc("PUT /api/marketplace/subscription", authed(s.handleSubscribeMarketplace))
+	mux.HandleFunc("DELETE /api/marketplace/subscription", authed(s.handleUnsubscribeMarketplace))
+	mux.HandleFunc("GET /api/marketplace/referrals", authed(s.handleListMarketplace))
+	mux.HandleFunc("POST /api/marketplace/referrals/{id}/apply", authed(s.handleApplyMarketplace))
+
+	// 协议
+	mux.HandleFunc("POST /api/agreements", authed(s.handleCreateAgreement))
+	mux.HandleFunc("GET /api/agreements", authed(s.handleListAgreements))
+	mux.HandleFunc("PATCH /api/agreements", authed(s.handleUpdateAgreementStatus))
+	mux.HandleFunc("POST /api/agreements/{id}/cancel", authed(s.handleCancelAgreement))
+	mux.HandleFunc("POST /api/agreements/{id}/sign", authed(s.handleSignAgreement))
+	mux.HandleFunc("GET /api/agreements/{id}/history", authed(s.handleAgreementHistory))
+	mux.HandleFunc("GET /api/agreements/{id}/amendments", authed(s.handleListAmendments))
+	mux.HandleFunc("POST /api/agreements/{id}/amendments", authed(s.handleProposeAmendment))
+	mux.HandleFunc("PATCH /api/agreements/{id}/amendments/{amendmentId}", authed(s.handleRespondAmendment))
+	mux.HandleFunc("POST /api/agreements/{id}/reviews", authed(s.handleSubmitReview))
+	mux.HandleFunc("GET /api/agents/{id}/reviews", authed(s.handleListAgentReviews))
+
+	// 时间线与推送
+	mux.HandleFunc("GET /api/events", authed(s.handleTimelineEvents))
+	mux.HandleFunc("POST /api/graphql", authed(s.handleGraphQL))
+	mux.HandleFunc("POST /api/agreements/{id}/events", authed(s.handleRecordDealEvent))
+	mux.HandleFunc("GET /api/agreements/{id}/events/stream", queryTokenAuth(authed(s.handleTimelineStream)))
+	mux.HandleFunc("GET /ws", queryTokenAuth(authed(s.handleWebSocket)))
+
+	// 经纪公司
+	mux.HandleFunc("GET /api/brokers", authed(s.handleBrokers))
+	mux.HandleFunc("GET /api/brokers/{id}", authed(s.handleBroker))
+	mux.HandleFunc("GET /api/brokers/{id}/settings", authed(s.handleGetBrokerSettings))
+	mux.HandleFunc("PUT /api/brokers/{id}/settings", authed(s.handleUpdateBrokerSettings))
+	mux.HandleFunc("POST /api/brokers/{id}/webhooks", authed(s.handleCreateWebhook))
+	mux.HandleFunc("GET /api/brokers/{id}/webhooks", authed(s.handleListWebhooks))
+	mux.HandleFunc("DELETE /api/brokers/{id}/webhooks/{webhookId}", authed(s.handleDeleteWebhook))
+	mux.HandleFunc("GET /api/brokers/{id}/webhooks/{webhookId}/deliveries", authed(s.handleListWebhookDeliveries))
+	mux.HandleFunc("GET /api/brokers/{id}/offices", authed(s.handleListOffices))
+	mux.HandleFunc("POST /api/brokers/{id}/offices", authed(s.handleCreateOffice))
+	mux.HandleFunc("PATCH /api/brokers/{id}/offices/{officeId}", authed(s.handleUpdateOffice))
+	mux.HandleFunc("DELETE /api/brokers/{id}/offices/{officeId}", authed(s.handleDeleteOffice))
+	mux.HandleFunc("PUT /api/brokers/{id}/users/{userId}/office", authed(s.handleAssignOffice))
+	mux.HandleFunc("POST /api/brokers/{id}/invitations", authed(s.handleCreateInvitation))
+	mux.HandleFunc("GET /api/brokers/{id}/invitations", authed(s.handleListInvitations))
+	mux.HandleFunc("DELETE /api/brokers/{id}/invitations/{invitationId}", authed(s.handleRevokeInvitation))
+
+	// 争议
+	mux.HandleFunc("GET /api/disputes", authed(s.handleListDisputes))
+	mux.HandleFunc("POST /api/disputes", authed(s.handleCreateDispute))
+	mux.HandleFunc("PATCH /api/disputes/{id}", authed(s.handleResolveDispute))
+
+	// 附件（下载链接自带签名，无需认证）
+	mux.HandleFunc("GET /api/referrals/{id}/files", authed(s.handleListFiles(files.TargetReferral)))
+	mux.HandleFunc("POST /api/referrals/{id}/files", authed(s.handleUploadFile(files.TargetReferral)))
+	mux.HandleFunc("GET /api/agreements/{id}/files", authed(s.handleListFiles(files.TargetAgreement)))
+	mux.HandleFunc("POST /api/agreements/{id}/files", authed(s.handleUploadFile(files.TargetAgreement)))
+	mux.HandleFunc("GET /api/disputes/{id}/files", authed(s.handleListFiles(files.TargetDispute)))
+	mux.HandleFunc("POST /api/disputes/{id}/files", authed(s.handleUploadFile(files.TargetDispute)))
+	mux.HandleFunc("DELETE /api/files/{id}", authed(s.handleDeleteFile))
+	mux.HandleFunc("GET /api/files/{id}/download", s.handleDownloadFile)
+
+	// 客户门户（仅 client 角色，只读）
+	mux.HandleFunc("GET /api/client/referrals", authed(s.handleListClientReferrals))
+	mux.HandleFunc("GET /api/client/referrals/{id}", authed(s.handleGetClientReferral))
+
+	// 报表
+	mux.HandleFunc("GET /api/reports/summary", authed(s.handleReportSummary))
+
+	// 管理与 API key
+	mux.HandleFunc("GET /api/admin/topics", authed(s.handleAdminTopics))
+	mux.HandleFunc("POST /api/admin/users/{id}/unlock", authed(s.handleUnlockUser))
+	mux.HandleFunc("POST /api/admin/agreements/{id}/corrections", authed(s.handleCorrectAgreementStatus))
+	mux.HandleFunc("GET /api/admin/outbox/dead-letters", authed(s.handleListDeadLetters))
+	mux.HandleFunc("GET /api/admin/outbox/messages/{id}", authed(s.handleGetOutboxMessage))
+	mux.HandleFunc("POST /api/admin/outbox/requeue", authed(s.handleRequeueOutbox))
+	mux.HandleFunc("POST /api/admin/outbox/purge", authed(s.handlePurgeOutbox))
+	mux.HandleFunc("GET /api/api-keys", authed(s.handleListAPIKeys))
+	mux.HandleFunc("POST /api/api-keys", authed(s.handleCreateAPIKey))
+	mux.HandleFunc("DELETE /api/api-keys/{id}", authed(s.handleRevokeAPIKey))
+
+	// SCIM 用户同步（broker_admin 的 API key 作为 Bearer token）
+	mux.HandleFunc("GET /scim/v2/Users", s.scimAuth(s.handleSCIMListUsers))
+	mux.HandleFunc("POST /scim/v2/Users", s.scimAuth(s.handleSCIMCreateUser))
+	mux.HandleFunc("GET /scim/v2/Users/{id}", s.scimAuth(s.handleSCIMGetUser))
+	mux.HandleFunc("PUT /scim/v2/Users/{id}", s.scimAuth(s.handleSCIMReplaceUser))
+	mux.HandleFunc("PATCH /scim/v2/Users/{id}", s.scimAuth(s.handleSCIMPatchUser))
+	mux.HandleFunc("DELETE /scim/v2/Users/{id}", s.scimAuth(s.handleSCIMDeleteUser))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newRouteMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	(&Server{}).routes(mux)
+	return mux
+}
+
+func TestRoutes_DocumentedRoutesAreRegistered(t *testing.T) {
+	mux := newRouteMux()
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, item := range buildOpenAPI().Paths {
+		for method := range item {
+			req := httptest.NewRequest(strings.ToUpper(method), param.ReplaceAllString(path, "x1"), nil)
+			_, pattern := mux.Handler(req)
+			if want := strings.ToUpper(method) + " " + path; pattern != want {
+				t.Errorf("%s %s: routed to %q", strings.ToUpper(method), path, pattern)
+			}
+		}
+	}
+}
+
+func TestRoutes_WrongMethod(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouteMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/brokers/b1", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); !strings.Contains(allow, http.MethodGet) {
+		t.Fatalf("expected Allow to list GET, got %q", allow)
+	}
+}
+
+func TestRoutes_UnknownPath(t *testing.T) {
+	for _, path := range []string{"/api/brokers/", "/api/referrals/r1/extra", "/api/referrals/r1/matches/m1/extra", "/api/disputes/d1/x"} {
+		rec := httptest.NewRecorder()
+		newRouteMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
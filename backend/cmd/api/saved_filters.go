@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/referral"
+	"github.com/google/uuid"
+)
+
+type savedFilterService interface {
+	List(ctx context.Context, userID string) ([]referral.SavedFilter, error)
+	Create(ctx context.Context, f referral.SavedFilter) (referral.SavedFilter, error)
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type savedFilterRequest struct {
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+	Region    string `json:"region,omitempty"`
+	DealType  string `json:"dealType,omitempty"`
+	SortKey   string `json:"sortKey,omitempty"`
+	SortOrder string `json:"sortOrder,omitempty"`
+	Notify    bool   `json:"notify,omitempty" doc:"Email me when a new referral I can see matches status, region and dealType"`
+}
+
+type savedFilterResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	DealType  string    `json:"dealType,omitempty"`
+	SortKey   string    `json:"sortKey,omitempty"`
+	SortOrder string    `json:"sortOrder,omitempty"`
+	Notify    bool      `json:"notify"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type savedFilterListResponse struct {
+	Items []savedFilterResponse `json:"items"`
+}
+
+func newSavedFilterResponse(f referral.SavedFilter) savedFilterResponse {
+	return savedFilterResponse{
+		ID:        f.ID,
+		Name:      f.Name,
+		Status:    string(f.Status),
+		Region:    f.Region,
+		DealType:  f.DealType,
+		SortKey:   f.SortKey,
+		SortOrder: f.SortOrder,
+		Notify:    f.Notify,
+		CreatedAt: f.CreatedAt.UTC(),
+	}
+}
+
+func (s *Server) handleListSavedFilters(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	filters, err := s.savedFilters.List(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load saved filters")
+		return
+	}
+	resp := savedFilterListResponse{Items: make([]savedFilterResponse, 0, len(filters))}
+	for _, f := range filters {
+		resp.Items = append(resp.Items, newSavedFilterResponse(f))
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCreateSavedFilter(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req savedFilterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	created, err := s.savedFilters.Create(ctx, referral.SavedFilter{
+		UserID:    userID,
+		Name:      req.Name,
+		Status:    referral.Status(req.Status),
+		Region:    req.Region,
+		DealType:  req.DealType,
+		SortKey:   req.SortKey,
+		SortOrder: req.SortOrder,
+		Notify:    req.Notify,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrSavedFilterName), errors.Is(err, referral.ErrSavedFilterInvalid),
+			errors.Is(err, referral.ErrTooManySavedFilters):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, referral.ErrSavedFilterNameTaken):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to save filter")
+		}
+		return
+	}
+	respondJSON(w, http.StatusCreated, newSavedFilterResponse(created))
+}
+
+func (s *Server) handleDeleteSavedFilter(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	filterID := r.PathValue("id")
+	if _, err := uuid.Parse(filterID); err != nil {
+		respondError(w, http.StatusNotFound, "Saved filter not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.savedFilters.Delete(ctx, userID, filterID); err != nil {
+		if errors.Is(err, referral.ErrSavedFilterNotFound) {
+			respondError(w, http.StatusNotFound, "Saved filter not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete saved filter")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
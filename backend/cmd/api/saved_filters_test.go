@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+)
+
+type stubSavedFilters struct {
+	created referral.SavedFilter
+	err     error
+}
+
+func (s *stubSavedFilters) List(context.Context, string) ([]referral.SavedFilter, error) {
+	return []referral.SavedFilter{s.created}, s.err
+}
+
+func (s *stubSavedFilters) Create(_ context.Context, f referral.SavedFilter) (referral.SavedFilter, error) {
+	f.ID = "11111111-1111-1111-1111-111111111111"
+	s.created = f
+	return f, s.err
+}
+
+func (s *stubSavedFilters) Delete(context.Context, string, string) error {
+	return s.err
+}
+
+func TestHandleCreateSavedFilter(t *testing.T) {
+	stub := &stubSavedFilters{}
+	server := &Server{savedFilters: stub}
+	body := `{"name":"Austin buyers","region":"Austin","dealType":"buy","notify":true}`
+	rec := httptest.NewRecorder()
+	server.handleCreateSavedFilter(rec, agentRequest(http.MethodPost, "/api/me/filters", body, auth.RoleAgent))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.created.UserID != "agent-1" || !stub.created.Notify || stub.created.Region != "Austin" {
+		t.Fatalf("unexpected filter %+v", stub.created)
+	}
+	var resp savedFilterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Name != "Austin buyers" || !resp.Notify {
+		t.Fatalf("unexpected response %s (%v)", rec.Body.String(), err)
+	}
+}
+
+func TestHandleSavedFilters_Errors(t *testing.T) {
+	server := &Server{savedFilters: &stubSavedFilters{err: referral.ErrSavedFilterNameTaken}}
+	rec := httptest.NewRecorder()
+	server.handleCreateSavedFilter(rec, agentRequest(http.MethodPost, "/api/me/filters", `{"name":"dup"}`, auth.RoleAgent))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+
+	server = &Server{savedFilters: &stubSavedFilters{err: referral.ErrSavedFilterNotFound}}
+	req := agentRequest(http.MethodDelete, "/api/me/filters/"+testAgreementID, "", auth.RoleAgent)
+	req.SetPathValue("id", testAgreementID)
+	rec = httptest.NewRecorder()
+	server.handleDeleteSavedFilter(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/scim"
+)
+
+type scimService interface {
+	List(ctx context.Context, brokerID string, filter scim.Filter, offset, limit int) ([]scim.User, int, error)
+	Get(ctx context.Context, brokerID, id string) (scim.User, error)
+	Create(ctx context.Context, brokerID string, params scim.CreateParams) (scim.User, error)
+	Update(ctx context.Context, brokerID, id string, update scim.Update) (scim.User, error)
+	Delete(ctx context.Context, brokerID, id string) error
+}
+
+const (
+	scimContentType = "application/scim+json"
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+	// scimDefaultCount is the page size when the client sends no count.
+	scimDefaultCount = 100
+)
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// fullName is the formatted name, or the given and family names joined.
+func (n *scimName) fullName() string {
+	if n == nil {
+		return ""
+	}
+	if f := strings.TrimSpace(n.Formatted); f != "" {
+		return f
+	}
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+type scimMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created"`
+	LastModified string `json:"lastModified"`
+	Location     string `json:"location"`
+}
+
+// scimUserResource is a SCIM User. Requests may carry attributes this API
+// does not store, such as phone numbers or the enterprise extension; they
+// are ignored. roles holds agent or broker_admin.
+type scimUserResource struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName" doc:"The user's email address"`
+	Name        *scimName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []scimMultiValue `json:"emails,omitempty"`
+	Roles       []scimMultiValue `json:"roles,omitempty" doc:"agent (default) or broker_admin"`
+	Active      *bool            `json:"active,omitempty"`
+	Meta        *scimMeta        `json:"meta,omitempty"`
+}
+
+// fullName is displayName, else the name.
+func (u scimUserResource) fullName() string {
+	if d := strings.TrimSpace(u.DisplayName); d != "" {
+		return d
+	}
+	return u.Name.fullName()
+}
+
+// role is the primary role, else the first.
+func (u scimUserResource) role() auth.Role {
+	return scimRole(u.Roles)
+}
+
+func scimRole(roles []scimMultiValue) auth.Role {
+	for _, r := range roles {
+		if r.Primary {
+			return auth.Role(r.Value)
+		}
+	}
+	if len(roles) > 0 {
+		return auth.Role(roles[0].Value)
+	}
+	return ""
+}
+
+func newSCIMUserResource(u scim.User) scimUserResource {
+	active := u.Active
+	return scimUserResource{
+		Schemas:     []string{scimUserSchema},
+		ID:          u.ID,
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		Name:        &scimName{Formatted: u.FullName},
+		DisplayName: u.FullName,
+		Emails:      []scimMultiValue{{Value: u.UserName, Type: "work", Primary: true}},
+		Roles:       []scimMultiValue{{Value: string(u.Role), Primary: true}},
+		Active:      &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt.UTC().Format(time.RFC3339),
+			LastModified: u.UpdatedAt.UTC().Format(time.RFC3339),
+			Location:     "/scim/v2/Users/" + u.ID,
+		},
+	}
+}
+
+type scimListResponse struct {
+	Schemas      []string           `json:"schemas"`
+	TotalResults int                `json:"totalResults"`
+	StartIndex   int                `json:"startIndex"`
+	ItemsPerPage int                `json:"itemsPerPage"`
+	Resources    []scimUserResource `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// scimPatchOperation is one PATCH operation. Without a path, value is an
+// object of attributes.
+type scimPatchOperation struct {
+	Op    string          `json:"op" doc:"add, replace or remove"`
+	Path  string          `json:"path,omitempty" doc:"active, displayName, name, name.formatted, externalId, roles or userName"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type scimErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// errSCIMInvalidValue signals a PATCH value of the wrong type.
+var errSCIMInvalidValue = errors.New("scim: invalid value")
+
+// scimErrors maps the scim package errors to their status and scimType.
+var scimErrors = []struct {
+	err      error
+	status   int
+	scimType string
+}{
+	{scim.ErrNotFound, http.StatusNotFound, ""},
+	{scim.ErrInvalidUserName, http.StatusBadRequest, "invalidValue"},
+	{scim.ErrInvalidRole, http.StatusBadRequest, "invalidValue"},
+	{scim.ErrInvalidFilter, http.StatusBadRequest, "invalidFilter"},
+	{scim.ErrUserNameImmutable, http.StatusBadRequest, "mutability"},
+	{scim.ErrUserNameTaken, http.StatusConflict, "uniqueness"},
+	{scim.ErrExternalIDTaken, http.StatusConflict, "uniqueness"},
+	{errSCIMInvalidValue, http.StatusBadRequest, "invalidValue"},
+}
+
+func respondSCIM(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// respondSCIMError answers in the SCIM error format, which identity
+// providers parse instead of errorResponse.
+func respondSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	log.Printf("HTTP error: status=%d message=%s", status, detail)
+	respondSCIM(w, status, scimErrorResponse{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// respondSCIMServiceError is respondServiceError for the SCIM endpoints.
+func respondSCIMServiceError(w http.ResponseWriter, err error, fallback string) {
+	for _, e := range scimErrors {
+		if errors.Is(err, e.err) {
+			respondSCIMError(w, e.status, e.scimType, err.Error())
+			return
+		}
+	}
+	log.Printf("unmapped service error: %v", err)
+	respondSCIMError(w, http.StatusInternalServerError, "", fallback)
+}
+
+// decodeSCIM reads a SCIM body. Unlike decodeJSON it ignores unknown
+// attributes, which identity providers routinely send.
+func decodeSCIM(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondSCIMError(w, http.StatusRequestEntityTooLarge, "", "Request body too large")
+			return false
+		}
+		respondSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// scimAuth authenticates an identity provider. SCIM clients send a bearer
+// token, which here is an API key of a broker_admin holding users:read, or
+// users:write for changes; the call acts on the admin's brokerage.
+func (s *Server) scimAuth(next func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.apiKeys == nil {
+			respondSCIMError(w, http.StatusUnauthorized, "", "Invalid API key")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.For(routeDefault))
+		key, user, err := s.apiKeys.Authenticate(ctx, secret)
+		cancel()
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidAPIKey) {
+				respondSCIMError(w, http.StatusUnauthorized, "", "Invalid API key")
+				return
+			}
+			respondSCIMError(w, http.StatusInternalServerError, "", "Failed to verify API key")
+			return
+		}
+		scope := auth.ScopeUsersWrite
+		if r.Method == http.MethodGet {
+			scope = auth.ScopeUsersRead
+		}
+		if !key.Allows(scope) {
+			respondSCIMError(w, http.StatusForbidden, "", "API key lacks scope "+string(scope))
+			return
+		}
+		if user.Role != auth.RoleBrokerAdmin || user.BrokerID == nil {
+			respondSCIMError(w, http.StatusForbidden, "", "API key must belong to a broker admin")
+			return
+		}
+		next(w, r, *user.BrokerID)
+	}
+}
+
+// handleSCIMListUsers lists the brokerage's users, one page at a time;
+// startIndex is 1-based.
+func (s *Server) handleSCIMListUsers(w http.ResponseWriter, r *http.Request, brokerID string) {
+	query := r.URL.Query()
+	filter, err := scim.ParseFilter(query.Get("filter"))
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to list users")
+		return
+	}
+	startIndex, count := 1, scimDefaultCount
+	if v := query.Get("startIndex"); v != "" {
+		if startIndex, err = strconv.Atoi(v); err != nil {
+			respondSCIMError(w, http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+			return
+		}
+		startIndex = max(startIndex, 1)
+	}
+	if v := query.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			respondSCIMError(w, http.StatusBadRequest, "invalidValue", "count must be an integer")
+			return
+		}
+		count = min(max(count, 1), scim.MaxCount)
+	}
+
+	users, total, err := s.scimUsers.List(r.Context(), brokerID, filter, startIndex-1, count)
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to list users")
+		return
+	}
+	resources := make([]scimUserResource, 0, len(users))
+	for _, u := range users {
+		resources = append(resources, newSCIMUserResource(u))
+	}
+	respondSCIM(w, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (s *Server) handleSCIMGetUser(w http.ResponseWriter, r *http.Request, brokerID string) {
+	user, err := s.scimUsers.Get(r.Context(), brokerID, r.PathValue("id"))
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to load user")
+		return
+	}
+	respondSCIM(w, http.StatusOK, newSCIMUserResource(user))
+}
+
+// handleSCIMCreateUser provisions a user; active defaults to true.
+func (s *Server) handleSCIMCreateUser(w http.ResponseWriter, r *http.Request, brokerID string) {
+	var req scimUserResource
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	active := req.Active == nil || *req.Active
+
+	user, err := s.scimUsers.Create(r.Context(), brokerID, scim.CreateParams{
+		UserName:   req.UserName,
+		FullName:   req.fullName(),
+		ExternalID: req.ExternalID,
+		Role:       req.role(),
+		Active:     active,
+	})
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to create user")
+		return
+	}
+	w.Header().Set("Location", "/scim/v2/Users/"+user.ID)
+	respondSCIM(w, http.StatusCreated, newSCIMUserResource(user))
+}
+
+// handleSCIMReplaceUser replaces the user's name, externalId, role and
+// active flag. Omitted roles and active keep their current values.
+func (s *Server) handleSCIMReplaceUser(w http.ResponseWriter, r *http.Request, brokerID string) {
+	var req scimUserResource
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	name, externalID := req.fullName(), req.ExternalID
+	update := scim.Update{UserName: &req.UserName, FullName: &name, ExternalID: &externalID, Active: req.Active}
+	if role := req.role(); role != "" {
+		update.Role = &role
+	}
+
+	user, err := s.scimUsers.Update(r.Context(), brokerID, r.PathValue("id"), update)
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to update user")
+		return
+	}
+	respondSCIM(w, http.StatusOK, newSCIMUserResource(user))
+}
+
+// handleSCIMPatchUser applies PATCH operations, typically
+// {"op":"replace","path":"active","value":false} to deactivate a user.
+func (s *Server) handleSCIMPatchUser(w http.ResponseWriter, r *http.Request, brokerID string) {
+	var req scimPatchRequest
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	update, err := scimPatchUpdate(req.Operations)
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to update user")
+		return
+	}
+
+	user, err := s.scimUsers.Update(r.Context(), brokerID, r.PathValue("id"), update)
+	if err != nil {
+		respondSCIMServiceError(w, err, "Failed to update user")
+		return
+	}
+	respondSCIM(w, http.StatusOK, newSCIMUserResource(user))
+}
+
+// handleSCIMDeleteUser deprovisions the user: it is deactivated and leaves
+// the brokerage.
+func (s *Server) handleSCIMDeleteUser(w http.ResponseWriter, r *http.Request, brokerID string) {
+	if err := s.scimUsers.Delete(r.Context(), brokerID, r.PathValue("id")); err != nil {
+		respondSCIMServiceError(w, err, "Failed to delete user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimPatchUpdate folds PATCH operations into one update. Attributes this
+// API does not store are skipped, as are removals of anything but
+// externalId.
+func scimPatchUpdate(ops []scimPatchOperation) (scim.Update, error) {
+	var update scim.Update
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := setSCIMAttribute(&update, op.Path, op.Value); err != nil {
+					return scim.Update{}, err
+				}
+				continue
+			}
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return scim.Update{}, fmt.Errorf("%w: value without a path must be an object", errSCIMInvalidValue)
+			}
+			// displayName goes last so that, as on create, it wins over name.
+			var displayName json.RawMessage
+			for path, value := range attrs {
+				if strings.EqualFold(path, "displayName") {
+					displayName = value
+					continue
+				}
+				if err := setSCIMAttribute(&update, path, value); err != nil {
+					return scim.Update{}, err
+				}
+			}
+			if displayName != nil {
+				if err := setSCIMAttribute(&update, "displayName", displayName); err != nil {
+					return scim.Update{}, err
+				}
+			}
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				empty := ""
+				update.ExternalID = &empty
+			}
+		default:
+			return scim.Update{}, fmt.Errorf("%w: unsupported op %q", errSCIMInvalidValue, op.Op)
+		}
+	}
+	return update, nil
+}
+
+func setSCIMAttribute(update *scim.Update, path string, value json.RawMessage) error {
+	invalid := fmt.Errorf("%w: %s", errSCIMInvalidValue, path)
+	switch strings.ToLower(path) {
+	case "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return invalid
+		}
+		update.Active = &active
+	case "displayname", "name.formatted", "username", "externalid":
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return invalid
+		}
+		switch strings.ToLower(path) {
+		case "username":
+			update.UserName = &s
+		case "externalid":
+			update.ExternalID = &s
+		default:
+			update.FullName = &s
+		}
+	case "name":
+		var name scimName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return invalid
+		}
+		if full := name.fullName(); full != "" {
+			update.FullName = &full
+		}
+	case "roles":
+		var roles []scimMultiValue
+		if err := json.Unmarshal(value, &roles); err != nil {
+			return invalid
+		}
+		if role := scimRole(roles); role != "" {
+			update.Role = &role
+		}
+	}
+	return nil
+}
+
+// scimBool reads true or false, or the strings "True" and "False" some
+// identity providers send.
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/scim"
+)
+
+type stubSCIM struct {
+	brokerID string
+	created  scim.CreateParams
+	update   scim.Update
+	filter   scim.Filter
+	offset   int
+	limit    int
+	err      error
+}
+
+func (s *stubSCIM) List(_ context.Context, brokerID string, filter scim.Filter, offset, limit int) ([]scim.User, int, error) {
+	s.brokerID, s.filter, s.offset, s.limit = brokerID, filter, offset, limit
+	return []scim.User{{ID: "user-1", UserName: "ann@acme.com", Role: auth.RoleAgent, Active: true}}, 7, s.err
+}
+
+func (s *stubSCIM) Get(_ context.Context, brokerID, id string) (scim.User, error) {
+	s.brokerID = brokerID
+	return scim.User{ID: id, UserName: "ann@acme.com", Role: auth.RoleAgent, Active: true}, s.err
+}
+
+func (s *stubSCIM) Create(_ context.Context, brokerID string, params scim.CreateParams) (scim.User, error) {
+	s.brokerID, s.created = brokerID, params
+	return scim.User{ID: "user-2", UserName: params.UserName, FullName: params.FullName, Role: params.Role, Active: params.Active}, s.err
+}
+
+func (s *stubSCIM) Update(_ context.Context, brokerID, id string, update scim.Update) (scim.User, error) {
+	s.brokerID, s.update = brokerID, update
+	return scim.User{ID: id, UserName: "ann@acme.com", Role: auth.RoleAgent}, s.err
+}
+
+func (s *stubSCIM) Delete(_ context.Context, brokerID, id string) error {
+	s.brokerID = brokerID
+	return s.err
+}
+
+func scimTestServer(scopes ...auth.APIKeyScope) (*Server, *stubSCIM) {
+	brokerID := "broker-1"
+	users := &stubSCIM{}
+	return &Server{
+		apiKeys: &stubAPIKeyService{
+			secret: "bfk_0000_secret",
+			key:    auth.APIKey{ID: "key-1", UserID: "admin-1", Scopes: scopes},
+			user:   auth.User{ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID},
+		},
+		scimUsers: users,
+	}, users
+}
+
+func scimRequest(server *Server, method, path, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	server.routes(mux)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer bfk_0000_secret")
+	req.Header.Set("Content-Type", scimContentType)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSCIMAuth(t *testing.T) {
+	server, _ := scimTestServer(auth.ScopeUsersRead)
+
+	if rec := scimRequest(server, http.MethodGet, "/scim/v2/Users", ""); rec.Code != http.StatusOK {
+		t.Fatalf("read with users:read: got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := scimRequest(server, http.MethodDelete, "/scim/v2/Users/user-1", "")
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != scimContentType {
+		t.Fatalf("write with users:read: got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body scimErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Status != "403" || body.Schemas[0] != scimErrorSchema {
+		t.Fatalf("error body %+v, %v", body, err)
+	}
+
+	server.apiKeys.(*stubAPIKeyService).user.Role = auth.RoleAgent
+	if rec := scimRequest(server, http.MethodGet, "/scim/v2/Users", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("agent's key: got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer nope")
+	rec = httptest.NewRecorder()
+	server.scimAuth(server.handleSCIMListUsers)(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad key: got %d", rec.Code)
+	}
+}
+
+func TestSCIMListUsers(t *testing.T) {
+	server, users := scimTestServer(auth.ScopeUsersRead)
+
+	rec := scimRequest(server, http.MethodGet, `/scim/v2/Users?filter=userName+eq+%22Ann%40acme.com%22&startIndex=3&count=2`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	if users.brokerID != "broker-1" || users.filter.UserName != "ann@acme.com" || users.offset != 2 || users.limit != 2 {
+		t.Fatalf("unexpected list call %+v", users)
+	}
+	var body scimListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.TotalResults != 7 || body.StartIndex != 3 || body.ItemsPerPage != 1 || body.Resources[0].UserName != "ann@acme.com" || !*body.Resources[0].Active {
+		t.Fatalf("unexpected body %+v", body)
+	}
+
+	rec = scimRequest(server, http.MethodGet, `/scim/v2/Users?filter=title+eq+%22x%22`, "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"scimType":"invalidFilter"`) {
+		t.Fatalf("unsupported filter: got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSCIMCreateUser(t *testing.T) {
+	server, users := scimTestServer(auth.ScopeUsersWrite)
+
+	rec := scimRequest(server, http.MethodPost, "/scim/v2/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "bo@acme.com",
+		"name": {"givenName": "Bo", "familyName": "Chen"},
+		"roles": [{"value": "agent"}, {"value": "broker_admin", "primary": true}],
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Sales"}
+	}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/scim/v2/Users/user-2" {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	if users.created.FullName != "Bo Chen" || users.created.Role != auth.RoleBrokerAdmin || !users.created.Active {
+		t.Fatalf("unexpected params %+v", users.created)
+	}
+
+	users.err = scim.ErrUserNameTaken
+	rec = scimRequest(server, http.MethodPost, "/scim/v2/Users", `{"userName": "bo@acme.com"}`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"scimType":"uniqueness"`) {
+		t.Fatalf("taken: got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSCIMPatchUser(t *testing.T) {
+	server, users := scimTestServer(auth.ScopeUsersWrite)
+
+	rec := scimRequest(server, http.MethodPatch, "/scim/v2/Users/user-1", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "value": {"displayName": "Ann B", "name.givenName": "Ann", "title": "Agent"}},
+			{"op": "remove", "path": "externalId"}
+		]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	u := users.update
+	if u.Active == nil || *u.Active || u.FullName == nil || *u.FullName != "Ann B" || u.ExternalID == nil || *u.ExternalID != "" || u.Role != nil {
+		t.Fatalf("unexpected update %+v", u)
+	}
+
+	rec = scimRequest(server, http.MethodPatch, "/scim/v2/Users/user-1", `{"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"scimType":"invalidValue"`) {
+		t.Fatalf("bad value: got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	users.err = scim.ErrNotFound
+	if rec := scimRequest(server, http.MethodDelete, "/scim/v2/Users/user-9", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("delete missing: got %d", rec.Code)
+	}
+}
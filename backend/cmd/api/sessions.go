@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+type sessionService interface {
+	ListSessions(ctx context.Context, userID string) ([]auth.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+}
+
+type sessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent" doc:"User-Agent of the login"`
+	IP         string    `json:"ip" doc:"Address the login came from"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" doc:"Last use of the session's token, to the minute"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current" doc:"Whether this is the session of the calling token"`
+}
+
+type sessionListResponse struct {
+	Items []sessionResponse `json:"items"`
+}
+
+// handleListSessions lists the caller's live login sessions.
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	current, _ := r.Context().Value(ctxKeySessionID).(string)
+
+	sessions, err := s.sessions.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+	items := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, sessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.UTC(),
+			LastSeenAt: session.LastSeenAt.UTC(),
+			ExpiresAt:  session.ExpiresAt.UTC(),
+			Current:    session.ID == current,
+		})
+	}
+	respondJSON(w, http.StatusOK, sessionListResponse{Items: items})
+}
+
+// handleRevokeSession logs the caller out of one session, which may be the
+// current one; its token is refused from then on.
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	sessionID := r.PathValue("id")
+	if _, err := uuid.Parse(sessionID); err != nil {
+		respondError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := s.sessions.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			respondError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+)
+
+type stubSessionService struct {
+	sessions    []auth.Session
+	revokedUser string
+	revokedID   string
+	revokeErr   error
+}
+
+func (s *stubSessionService) ListSessions(_ context.Context, _ string) ([]auth.Session, error) {
+	return s.sessions, nil
+}
+
+func (s *stubSessionService) RevokeSession(_ context.Context, userID, sessionID string) error {
+	s.revokedUser, s.revokedID = userID, sessionID
+	return s.revokeErr
+}
+
+const (
+	laptopSession = "3f2b6c1e-0d4a-4e8b-9a5c-7e1f2d3c4b5a"
+	phoneSession  = "8a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c5d"
+)
+
+func TestHandleListSessions_MarksCurrent(t *testing.T) {
+	now := time.Now().UTC()
+	server := &Server{sessions: &stubSessionService{sessions: []auth.Session{
+		{ID: laptopSession, UserID: "user-1", UserAgent: "Firefox", IP: "203.0.113.7", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(auth.TokenTTL)},
+		{ID: phoneSession, UserID: "user-1", UserAgent: "iPhone", IP: "198.51.100.2", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(auth.TokenTTL)},
+	}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me/sessions", nil)
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "user-1")
+	req = req.WithContext(context.WithValue(ctx, ctxKeySessionID, phoneSession))
+	rec := httptest.NewRecorder()
+	server.handleListSessions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var payload sessionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Items) != 2 || payload.Items[0].Current || !payload.Items[1].Current || payload.Items[0].UserAgent != "Firefox" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestHandleRevokeSession(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+		err  error
+		want int
+	}{
+		{"revoked", laptopSession, nil, http.StatusNoContent},
+		{"unknown", laptopSession, auth.ErrSessionNotFound, http.StatusNotFound},
+		{"malformed id", "not-a-uuid", nil, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &stubSessionService{revokeErr: tc.err}
+			server := &Server{sessions: stub}
+			req := httptest.NewRequest(http.MethodDelete, "/api/me/sessions/"+tc.id, nil)
+			req.SetPathValue("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "user-1"))
+			rec := httptest.NewRecorder()
+			server.handleRevokeSession(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			if tc.want == http.StatusNoContent && (stub.revokedUser != "user-1" || stub.revokedID != tc.id) {
+				t.Fatalf("revoked %q for %q", stub.revokedID, stub.revokedUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"github.com/google/uuid"
+)
+
+type signatureService interface {
+	Sign(ctx context.Context, agreementID, actorID string) (agreement.Signatures, error)
+}
+
+type agreementSignaturesResponse struct {
+	AgreementID      string  `json:"agreementId"`
+	Status           string  `json:"status" doc:"effective once both parties signed"`
+	ReferrerSignedAt string  `json:"referrerSignedAt,omitempty"`
+	ReferrerSignedBy *string `json:"referrerSignedBy,omitempty"`
+	RefereeSignedAt  string  `json:"refereeSignedAt,omitempty"`
+	RefereeSignedBy  *string `json:"refereeSignedBy,omitempty"`
+	EffectiveAt      string  `json:"effectiveAt,omitempty"`
+	Version          int     `json:"version"`
+}
+
+// formatOptionalTime renders t as RFC 3339, or "" when nil.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// handleSignAgreement records the caller's signature for their broker's side
+// of an agreement pending signature. The referring broker signs first; the
+// receiving broker's signature makes the agreement effective.
+func (s *Server) handleSignAgreement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	if !can(r.Context(), auth.PermReferralsWrite) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	sig, err := s.signatures.Sign(ctx, agreementID, userID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to sign agreement")
+		return
+	}
+	setETag(w, sig.Version)
+	respondJSON(w, http.StatusOK, agreementSignaturesResponse{
+		AgreementID:      sig.AgreementID,
+		Status:           sig.Status,
+		ReferrerSignedAt: formatOptionalTime(sig.ReferrerSignedAt),
+		ReferrerSignedBy: sig.ReferrerSignedBy,
+		RefereeSignedAt:  formatOptionalTime(sig.RefereeSignedAt),
+		RefereeSignedBy:  sig.RefereeSignedBy,
+		EffectiveAt:      formatOptionalTime(sig.EffectiveAt),
+		Version:          sig.Version,
+	})
+}
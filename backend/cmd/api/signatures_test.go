@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+)
+
+const signAgreementID = "6f1c2a0e-4d3b-4f6a-9c1e-2b7d8e9f0a1b"
+
+type stubSignatures struct {
+	agreementID, actorID string
+	sig                  agreement.Signatures
+	err                  error
+}
+
+func (s *stubSignatures) Sign(_ context.Context, agreementID, actorID string) (agreement.Signatures, error) {
+	s.agreementID, s.actorID = agreementID, actorID
+	return s.sig, s.err
+}
+
+func signRequest(id string, role auth.Role) *http.Request {
+	req := agentRequest(http.MethodPost, "/api/agreements/"+id+"/sign", "", role)
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestHandleSignAgreement(t *testing.T) {
+	signedAt := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	signer := "agent-1"
+	stub := &stubSignatures{sig: agreement.Signatures{
+		AgreementID: signAgreementID, Status: agreement.StatusPendingSignature,
+		ReferrerSignedAt: &signedAt, ReferrerSignedBy: &signer, Version: 3,
+	}}
+	server := &Server{signatures: stub}
+	rec := httptest.NewRecorder()
+
+	server.handleSignAgreement(rec, signRequest(signAgreementID, auth.RoleAgent))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.agreementID != signAgreementID || stub.actorID != "agent-1" {
+		t.Fatalf("unexpected sign call %+v", stub)
+	}
+	if etag := rec.Header().Get("ETag"); etag == "" {
+		t.Fatal("missing ETag")
+	}
+	var resp agreementSignaturesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.ReferrerSignedAt != "2026-04-01T09:00:00Z" || resp.ReferrerSignedBy == nil || *resp.ReferrerSignedBy != "agent-1" ||
+		resp.RefereeSignedAt != "" || resp.RefereeSignedBy != nil || resp.EffectiveAt != "" || resp.Version != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleSignAgreement_Errors(t *testing.T) {
+	server := &Server{signatures: &stubSignatures{}}
+
+	rec := httptest.NewRecorder()
+	server.handleSignAgreement(rec, signRequest(signAgreementID, auth.RoleClient))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for client, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleSignAgreement(rec, signRequest("not-a-uuid", auth.RoleAgent))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for bad id, got %d", rec.Code)
+	}
+
+	for err, want := range map[error]int{
+		agreement.ErrSignatureOutOfOrder: http.StatusConflict,
+		agreement.ErrAlreadySigned:       http.StatusConflict,
+		agreement.ErrNotSignable:         http.StatusConflict,
+		agreement.ErrNotParty:            http.StatusNotFound,
+	} {
+		server.signatures = &stubSignatures{err: err}
+		rec = httptest.NewRecorder()
+		server.handleSignAgreement(rec, signRequest(signAgreementID, auth.RoleBrokerAdmin))
+		if rec.Code != want {
+			t.Errorf("%v: expected %d, got %d", err, want, rec.Code)
+		}
+	}
+}
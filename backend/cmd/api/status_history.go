@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"brokerflow/agreement"
+	"github.com/google/uuid"
+)
+
+type statusHistoryReader interface {
+	List(ctx context.Context, agreementID, userID string) ([]agreement.StatusPeriod, error)
+}
+
+type statusPeriodResponse struct {
+	Status          string     `json:"status"`
+	EnteredAt       time.Time  `json:"enteredAt"`
+	ExitedAt        *time.Time `json:"exitedAt,omitempty" doc:"Absent for the current status"`
+	DurationSeconds *float64   `json:"durationSeconds,omitempty" doc:"exitedAt - enteredAt; absent for the current status"`
+}
+
+type statusHistoryResponse struct {
+	Items []statusPeriodResponse `json:"items"`
+}
+
+// handleAgreementHistory returns how long the agreement spent in each
+// status, oldest first.
+func (s *Server) handleAgreementHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	periods, err := s.statusHistory.List(ctx, agreementID, userID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load status history")
+		return
+	}
+	out := make([]statusPeriodResponse, 0, len(periods))
+	for _, p := range periods {
+		item := statusPeriodResponse{Status: p.Status, EnteredAt: p.EnteredAt.UTC()}
+		if p.ExitedAt != nil {
+			exited := p.ExitedAt.UTC()
+			item.ExitedAt = &exited
+		}
+		if p.Duration != nil {
+			seconds := p.Duration.Seconds()
+			item.DurationSeconds = &seconds
+		}
+		out = append(out, item)
+	}
+	respondJSON(w, http.StatusOK, statusHistoryResponse{Items: out})
+}
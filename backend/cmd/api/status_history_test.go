@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+)
+
+type stubStatusHistory struct {
+	periods []agreement.StatusPeriod
+	err     error
+}
+
+func (s *stubStatusHistory) List(context.Context, string, string) ([]agreement.StatusPeriod, error) {
+	return s.periods, s.err
+}
+
+func TestHandleAgreementHistory(t *testing.T) {
+	entered := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	exited := entered.Add(90 * time.Minute)
+	took := exited.Sub(entered)
+	server := &Server{statusHistory: &stubStatusHistory{periods: []agreement.StatusPeriod{
+		{Status: "pending_signature", EnteredAt: entered, ExitedAt: &exited, Duration: &took},
+		{Status: "effective", EnteredAt: exited},
+	}}}
+
+	req := agentRequest(http.MethodGet, "/api/agreements/"+testAgreementID+"/history", "", auth.RoleAgent)
+	req.SetPathValue("id", testAgreementID)
+	rec := httptest.NewRecorder()
+	server.handleAgreementHistory(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp statusHistoryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Items) != 2 || resp.Items[0].DurationSeconds == nil || *resp.Items[0].DurationSeconds != 5400 {
+		t.Fatalf("unexpected history %+v", resp.Items)
+	}
+	if resp.Items[1].ExitedAt != nil || resp.Items[1].DurationSeconds != nil {
+		t.Fatalf("expected the current status to stay open, got %+v", resp.Items[1])
+	}
+}
+
+func TestHandleAgreementHistory_HidesOtherAgreements(t *testing.T) {
+	server := &Server{statusHistory: &stubStatusHistory{err: agreement.ErrNotParty}}
+	req := agentRequest(http.MethodGet, "/api/agreements/"+testAgreementID+"/history", "", auth.RoleAgent)
+	req.SetPathValue("id", testAgreementID)
+	rec := httptest.NewRecorder()
+	server.handleAgreementHistory(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/db"
+	"brokerflow/timeline"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamBatchSize         = 100
+	streamRetryMillis       = 3000
+)
+
+type timelineReader interface {
+	CanView(ctx context.Context, agreementID, userID string) (bool, error)
+	ListAfter(ctx context.Context, agreementID string, afterID int64, limit int) ([]timeline.Event, error)
+	LatestID(ctx context.Context, agreementID string) (int64, error)
+	List(ctx context.Context, filters timeline.ListFilters) ([]timeline.Event, db.Page, error)
+}
+
+// queryTokenAuth lets EventSource clients, which cannot set headers, pass the
+// bearer token as ?access_token=. Only routes that stream are wrapped.
+func queryTokenAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			if token := r.URL.Query().Get("access_token"); token != "" {
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handleTimelineStream pushes an agreement's timeline events as Server-Sent
+// Events. Clients resume with Last-Event-ID (or ?lastEventId=); without it
+// only events inserted after the connection opened are sent.
+func (s *Server) handleTimelineStream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	agreementID := r.PathValue("id")
+	if _, err := uuid.Parse(agreementID); err != nil {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+
+	lastID, err := streamResumeID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.For(routeDefault))
+	allowed, err := s.timelineReader.CanView(ctx, agreementID, userID)
+	if err == nil && allowed && lastID < 0 {
+		lastID, err = s.timelineReader.LatestID(ctx, agreementID)
+	}
+	cancel()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to open timeline stream")
+		return
+	}
+	if !allowed {
+		respondError(w, http.StatusNotFound, "Agreement not found")
+		return
+	}
+
+	// Subscribe before the first read so nothing inserted in between is missed.
+	sub := s.timelineHub.Subscribe(agreementID)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+	if err := rc.Flush(); err != nil {
+		log.Printf("timeline stream: flush unsupported: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		if err := s.writeTimelineEvents(r.Context(), w, agreementID, &lastID); err != nil {
+			if r.Context().Err() == nil {
+				log.Printf("timeline stream %s: %v", agreementID, err)
+			}
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.C():
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeTimelineEvents sends every event after *lastID and advances it.
+func (s *Server) writeTimelineEvents(ctx context.Context, w http.ResponseWriter, agreementID string, lastID *int64) error {
+	for {
+		queryCtx, cancel := context.WithTimeout(ctx, s.timeouts.For(routeDefault))
+		events, err := s.timelineReader.ListAfter(queryCtx, agreementID, *lastID, streamBatchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			data, err := json.Marshal(newTimelineEvent(ev))
+			if err != nil {
+				return fmt.Errorf("encode event %d: %w", ev.ID, err)
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: timeline\ndata: %s\n\n", ev.ID, data); err != nil {
+				return err
+			}
+			*lastID = ev.ID
+		}
+		if len(events) < streamBatchSize {
+			return nil
+		}
+	}
+}
+
+// streamResumeID returns the event id to resume after, or -1 when the client
+// did not ask to resume.
+func streamResumeID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	if raw == "" {
+		return -1, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid event id %q", raw)
+	}
+	return id, nil
+}
+
+func newTimelineEvent(ev timeline.Event) timelineEvent {
+	return timelineEvent{
+		ID:          strconv.FormatInt(ev.ID, 10),
+		AgreementID: ev.AgreementID,
+		Seq:         ev.Seq,
+		Type:        ev.Type,
+		At:          ev.At.UTC(),
+		Payload:     decodeTimelinePayload(ev.Type, ev.PayloadVersion, ev.Payload),
+		ActorBroker: ev.ActorBroker,
+	}
+}
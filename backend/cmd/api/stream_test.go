@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"brokerflow/db"
+	"brokerflow/timeline"
+)
+
+const streamAgreementID = "6f1c1c3e-2f0a-4a36-9b7e-0d7a8f6c2b11"
+
+type stubTimelineReader struct {
+	mu      sync.Mutex
+	allowed bool
+	events  []timeline.Event
+	// listed is the filters of the last List call.
+	listed timeline.ListFilters
+}
+
+func (s *stubTimelineReader) CanView(_ context.Context, _, _ string) (bool, error) {
+	return s.allowed, nil
+}
+
+func (s *stubTimelineReader) ListAfter(_ context.Context, _ string, afterID int64, limit int) ([]timeline.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []timeline.Event
+	for _, ev := range s.events {
+		if ev.ID > afterID && len(out) < limit {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubTimelineReader) LatestID(_ context.Context, _ string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) == 0 {
+		return 0, nil
+	}
+	return s.events[len(s.events)-1].ID, nil
+}
+
+// List returns every event whatever the filters, newest first, once they
+// validate.
+func (s *stubTimelineReader) List(_ context.Context, filters timeline.ListFilters) ([]timeline.Event, db.Page, error) {
+	if err := filters.Validate(); err != nil {
+		return nil, db.Page{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listed = filters
+	out := slices.Clone(s.events)
+	slices.Reverse(out)
+	return out, db.Page{Total: len(out), TotalExact: true}, nil
+}
+
+func (s *stubTimelineReader) append(ev timeline.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+}
+
+func newStreamTestServer(t *testing.T, server *Server) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/agreements/{id}/events/stream", func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), ctxKeyUserID, "agent-1"))
+		server.handleTimelineStream(w, r)
+	})
+	ts := httptest.NewServer(loggingMiddleware(mux))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func readSSEEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if _, ok := fields["data"]; ok {
+				return fields
+			}
+			continue
+		}
+		if key, val, ok := strings.Cut(line, ": "); ok {
+			fields[key] = val
+		}
+	}
+}
+
+func TestHandleTimelineStream_ResumesAndPushes(t *testing.T) {
+	at := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	reader := &stubTimelineReader{allowed: true, events: []timeline.Event{
+		{ID: 1, AgreementID: streamAgreementID, Type: "AGREEMENT_CREATED", At: at, Payload: []byte(`{}`)},
+		{ID: 2, AgreementID: streamAgreementID, Type: "ESIGN_COMPLETED", At: at, Payload: []byte(`{"effective_at":"x"}`)},
+	}}
+	server := &Server{timelineReader: reader, timelineHub: timeline.NewHub()}
+	ts := newStreamTestServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/agreements/"+streamAgreementID+"/events/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body := bufio.NewReader(resp.Body)
+
+	replayed := readSSEEvent(t, body)
+	if replayed["id"] != "2" || replayed["event"] != "timeline" || !strings.Contains(replayed["data"], "ESIGN_COMPLETED") {
+		t.Fatalf("unexpected replayed event: %v", replayed)
+	}
+
+	reader.append(timeline.Event{ID: 3, AgreementID: streamAgreementID, Type: "OFFER_MADE", At: at})
+	server.timelineHub.Publish(streamAgreementID)
+
+	pushed := readSSEEvent(t, body)
+	if pushed["id"] != "3" || !strings.Contains(pushed["data"], "OFFER_MADE") {
+		t.Fatalf("unexpected pushed event: %v", pushed)
+	}
+}
+
+func TestHandleTimelineStream_HidesForeignAgreement(t *testing.T) {
+	server := &Server{timelineReader: &stubTimelineReader{allowed: false}, timelineHub: timeline.NewHub()}
+	ts := newStreamTestServer(t, server)
+
+	resp, err := http.Get(ts.URL + "/api/agreements/" + streamAgreementID + "/events/stream")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestQueryTokenAuth_LiftsAccessToken(t *testing.T) {
+	var got string
+	h := queryTokenAuth(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/agreements/x/events/stream?access_token=abc", nil))
+	if got != "Bearer abc" {
+		t.Fatalf("expected bearer header from query token, got %q", got)
+	}
+}
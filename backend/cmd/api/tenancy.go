@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"brokerflow/auth"
+	"brokerflow/broker"
+	"brokerflow/tenancy"
+)
+
+// errOfficeFilterForbidden rejects an office filter outside the caller's
+// scope: only broker admins filter by office, and an office-scoped admin
+// only by their own office.
+var errOfficeFilterForbidden = errors.New("office filter outside the caller's scope")
+
+// callerScope resolves which rows list and read endpoints return for the
+// authenticated user. Broker admins see their whole brokerage, or only their
+// office when it scopes its admins; everyone else, including an admin not
+// attached to a broker, sees only their own rows.
+func (s *Server) callerScope(ctx context.Context, userID string) (tenancy.Scope, error) {
+	if !can(ctx, auth.PermBrokerRead) {
+		return tenancy.User(userID), nil
+	}
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		return tenancy.Scope{}, err
+	}
+	if user.BrokerID == nil || *user.BrokerID == "" {
+		return tenancy.User(userID), nil
+	}
+	if user.OfficeID != nil && s.offices != nil {
+		office, err := s.offices.Get(ctx, *user.BrokerID, *user.OfficeID)
+		switch {
+		case err == nil && office.ScopeAdmins:
+			return tenancy.Office(userID, *user.BrokerID, office.ID), nil
+		case err != nil && !errors.Is(err, broker.ErrOfficeNotFound):
+			return tenancy.Scope{}, err
+		}
+	}
+	return tenancy.Broker(userID, *user.BrokerID), nil
+}
+
+// listScope is callerScope narrowed to officeID, the officeId filter of
+// list and report endpoints; an empty officeID leaves it unchanged.
+func (s *Server) listScope(ctx context.Context, userID, officeID string) (tenancy.Scope, error) {
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil || officeID == "" {
+		return scope, err
+	}
+	if scope.Officewide() && scope.OfficeID == officeID {
+		return scope, nil
+	}
+	if !scope.Brokerwide() || s.offices == nil {
+		return tenancy.Scope{}, errOfficeFilterForbidden
+	}
+	if _, err := s.offices.Get(ctx, scope.BrokerID, officeID); err != nil {
+		return tenancy.Scope{}, err
+	}
+	return scope.InOffice(officeID), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/tenancy"
+)
+
+type stubUserRepo struct {
+	users map[string]auth.User
+}
+
+func (s *stubUserRepo) CreateUser(_ context.Context, _ auth.CreateUserParams) (auth.User, error) {
+	return auth.User{}, nil
+}
+
+func (s *stubUserRepo) GetUserByEmail(_ context.Context, email string) (auth.User, error) {
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return auth.User{}, auth.ErrUserNotFound
+}
+
+func (s *stubUserRepo) GetUserByID(_ context.Context, id string) (auth.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return auth.User{}, auth.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func TestCallerScope(t *testing.T) {
+	brokerID := "broker-1"
+	server := &Server{authService: auth.NewService(&stubUserRepo{users: map[string]auth.User{
+		"admin-1": {ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID},
+		"admin-2": {ID: "admin-2", Role: auth.RoleBrokerAdmin},
+	}}, "secret")}
+
+	cases := []struct {
+		name   string
+		userID string
+		role   auth.Role
+		want   tenancy.Scope
+	}{
+		{"agent", "agent-1", auth.RoleAgent, tenancy.User("agent-1")},
+		{"broker admin", "admin-1", auth.RoleBrokerAdmin, tenancy.Broker("admin-1", brokerID)},
+		{"admin without broker", "admin-2", auth.RoleBrokerAdmin, tenancy.User("admin-2")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ctxKeyRole, tc.role)
+			got, err := server.callerScope(ctx, tc.userID)
+			if err != nil {
+				t.Fatalf("callerScope: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKeyRole, auth.RoleBrokerAdmin)
+	if _, err := server.callerScope(ctx, "missing"); err == nil {
+		t.Fatal("expected error for unknown admin")
+	}
+}
+
+func TestHandleListDisputes_BrokerAdminScope(t *testing.T) {
+	brokerID := "broker-1"
+	disputes := &stubDisputeService{}
+	server := &Server{
+		authService: auth.NewService(&stubUserRepo{users: map[string]auth.User{
+			"admin-1": {ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID},
+		}}, "secret"),
+		disputeService: disputes,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/disputes", nil)
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "admin-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleBrokerAdmin)
+	rec := httptest.NewRecorder()
+
+	server.handleListDisputes(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !disputes.listScope.Brokerwide() || disputes.listScope.BrokerID != brokerID {
+		t.Fatalf("expected brokerage scope, got %+v", disputes.listScope)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/timeline"
+)
+
+type timelineEvent struct {
+	ID          string         `json:"id"`
+	AgreementID string         `json:"agreementId"`
+	Seq         int64          `json:"seq,omitempty"`
+	Type        string         `json:"type"`
+	At          time.Time      `json:"at"`
+	Payload     map[string]any `json:"payload,omitempty"`
+	ActorBroker *string        `json:"actorBrokerId,omitempty"`
+}
+
+type paginatedTimelineEvents struct {
+	Items      []timelineEvent `json:"items"`
+	Total      int             `json:"total"`
+	TotalExact bool            `json:"totalExact" doc:"False when total is an estimate or a cached count; page with hasMore instead"`
+	HasMore    bool            `json:"hasMore" doc:"Whether another page follows this one"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"pageSize"`
+}
+
+// handleTimelineEvents pages through the events of agreements the caller
+// can view, newest first, narrowed by agreementId, repeated or
+// comma-separated type, actorBrokerId, and a since/until time range.
+func (s *Server) handleTimelineEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	scope, err := s.callerScope(ctx, userID)
+	if err != nil {
+		respondServiceError(w, err, "Failed to resolve access scope")
+		return
+	}
+	filters := timeline.ListFilters{
+		Scope:         scope,
+		AgreementID:   query.Get("agreementId"),
+		Types:         timeline.ParseTypes(query["type"]),
+		ActorBrokerID: query.Get("actorBrokerId"),
+		Page:          page,
+		PageSize:      pageSize,
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filters.Since}, {"until", &filters.Until}} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid "+p.name+": use YYYY-MM-DD or RFC 3339")
+			return
+		}
+		*p.dst = t
+	}
+
+	items, listPage, err := s.timelineReader.List(ctx, filters)
+	if err != nil {
+		respondServiceError(w, err, "Failed to load timeline events")
+		return
+	}
+
+	events := make([]timelineEvent, 0, len(items))
+	for _, ev := range items {
+		events = append(events, timelineEvent{
+			ID:          strconv.FormatInt(ev.ID, 10),
+			AgreementID: ev.AgreementID,
+			Seq:         ev.Seq,
+			Type:        ev.Type,
+			At:          ev.At.UTC(),
+			Payload:     decodeTimelinePayload(ev.Type, ev.PayloadVersion, ev.Payload),
+			ActorBroker: ev.ActorBroker,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, paginatedTimelineEvents{
+		Items:      events,
+		Total:      listPage.Total,
+		TotalExact: listPage.TotalExact,
+		HasMore:    listPage.HasMore,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
+
+// decodeTimelinePayload parses a JSONB payload column and upgrades it to the
+// current version of its event type; payloads that cannot be decoded are
+// returned verbatim under "raw".
+func decodeTimelinePayload(eventType string, version int, raw []byte) map[string]any {
+	payload, err := timeline.Decode(eventType, version, raw)
+	if err != nil {
+		return map[string]any{"raw": string(raw)}
+	}
+	return payload
+}
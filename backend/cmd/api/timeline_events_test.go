@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+)
+
+func TestHandleTimelineEvents_PassesFilters(t *testing.T) {
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	broker := "9b2e4c1a-5d3f-4e6a-8b7c-1d2e3f4a5b6c"
+	reader := &stubTimelineReader{events: []timeline.Event{
+		{ID: 7, AgreementID: streamAgreementID, Seq: 3, Type: timeline.TypeOfferMade, At: at, Payload: []byte(`{"price":1}`), ActorBroker: &broker},
+	}}
+	server := &Server{timelineReader: reader}
+
+	rec := httptest.NewRecorder()
+	server.handleTimelineEvents(rec, agentRequest(http.MethodGet, "/api/events?agreementId="+streamAgreementID+
+		"&type=OFFER_MADE,DEAL_CLOSED&type=OFFER_MADE&actorBrokerId="+broker+"&since=2026-03-01&until=2026-04-01&pageSize=5", "", auth.RoleAgent))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	got := reader.listed
+	if got.Scope != tenancy.User("agent-1") || got.AgreementID != streamAgreementID || got.ActorBrokerID != broker || got.PageSize != 5 {
+		t.Fatalf("unexpected filters %+v", got)
+	}
+	if !slices.Equal(got.Types, []string{timeline.TypeOfferMade, timeline.TypeDealClosed}) {
+		t.Fatalf("expected deduplicated types, got %v", got.Types)
+	}
+	if !got.Since.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || !got.Until.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected range %v to %v", got.Since, got.Until)
+	}
+
+	var resp paginatedTimelineEvents
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].ID != "7" || resp.Items[0].Seq != 3 || resp.Items[0].Payload["price"] != 1.0 || !resp.TotalExact {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleTimelineEvents_RejectsInvalidFilters(t *testing.T) {
+	server := &Server{timelineReader: &stubTimelineReader{}}
+	for name, query := range map[string]string{
+		"unknown type":        "type=NOT_A_TYPE",
+		"agreement id":        "agreementId=nope",
+		"actor broker id":     "actorBrokerId=nope",
+		"unparseable since":   "since=yesterday",
+		"range ending early":  "since=2026-03-02&until=2026-03-01",
+		"range with no width": "since=2026-03-01&until=2026-03-01",
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.handleTimelineEvents(rec, agentRequest(http.MethodGet, "/api/events?"+query, "", auth.RoleAgent))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
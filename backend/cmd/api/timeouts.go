@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"brokerflow/config"
+)
+
+const (
+	defaultRequestTimeout     = 5 * time.Second
+	defaultListRequestTimeout = 30 * time.Second
+	defaultBulkRequestTimeout = 2 * time.Minute
+
+	// errCodeDeadlineExceeded marks the 504 returned when a request runs out
+	// of time.
+	errCodeDeadlineExceeded = "deadline_exceeded"
+)
+
+// routeClass groups routes by how long their handlers may run.
+type routeClass int
+
+const (
+	routeDefault routeClass = iota
+	// routeList covers list, history and report queries whose cost grows
+	// with the data they scan.
+	routeList
+	// routeBulk covers imports, other batch writes and file transfers.
+	routeBulk
+	// routeStream covers SSE and WebSocket connections, which stay open
+	// and bound each query themselves.
+	routeStream
+)
+
+// routeClasses lists the mux patterns outside routeDefault.
+var routeClasses = map[string]routeClass{
+	"GET /api/referrals":                                    routeList,
+	"GET /api/matches":                                      routeList,
+	"GET /api/marketplace/referrals":                        routeList,
+	"GET /api/agreements":                                   routeList,
+	"GET /api/agreements/{id}/history":                      routeList,
+	"GET /api/events":                                       routeList,
+	"GET /api/disputes":                                     routeList,
+	"GET /api/reports/summary":                              routeList,
+	"GET /api/agents/{id}/reviews":                          routeList,
+	"GET /api/brokers/{id}/webhooks/{webhookId}/deliveries": routeList,
+	"GET /api/admin/outbox/dead-letters":                    routeList,
+	"POST /api/referrals/import":                            routeBulk,
+	"POST /api/referrals/{id}/matches/bulk":                 routeBulk,
+	"POST /api/referrals/{id}/files":                        routeBulk,
+	"POST /api/agreements/{id}/files":                       routeBulk,
+	"POST /api/disputes/{id}/files":                         routeBulk,
+	"GET /api/files/{id}/download":                          routeBulk,
+	"GET /api/agreements/{id}/events/stream":                routeStream,
+	"GET /ws":                                               routeStream,
+}
+
+// requestTimeouts holds the deadline of each route class, as
+// config.RequestTimeouts reads them. Zero fields fall back to the defaults,
+// so a zero value is usable.
+type requestTimeouts config.RequestTimeouts
+
+// For returns the deadline of class; routeStream has none.
+func (t requestTimeouts) For(class routeClass) time.Duration {
+	pick := func(d, def time.Duration) time.Duration {
+		if d > 0 {
+			return d
+		}
+		return def
+	}
+	switch class {
+	case routeList:
+		return pick(t.List, defaultListRequestTimeout)
+	case routeBulk:
+		return pick(t.Bulk, defaultBulkRequestTimeout)
+	case routeStream:
+		return 0
+	default:
+		return pick(t.Default, defaultRequestTimeout)
+	}
+}
+
+// deadlineMiddleware gives each request the deadline of its route's class.
+// Handlers and the services and repositories below them work on the
+// request context, so the deadline reaches every query. A handler that
+// fails after the deadline has passed answers 504 with a deadline_exceeded
+// error instead of whatever status it mapped the context error to.
+func (s *Server) deadlineMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		// The mux records the pattern on the copy made by WithContext;
+		// outer middleware such as the metrics label reads the original.
+		r.Pattern = pattern
+		timeout := s.timeouts.For(routeClasses[pattern])
+		if timeout <= 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		mux.ServeHTTP(&deadlineWriter{ResponseWriter: w, ctx: ctx, timeout: timeout}, r.WithContext(ctx))
+	})
+}
+
+// deadlineWriter turns an error response written after the request deadline
+// into a 504.
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	timeout     time.Duration
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (dw *deadlineWriter) WriteHeader(code int) {
+	if dw.wroteHeader {
+		return
+	}
+	dw.wroteHeader = true
+	if code < http.StatusBadRequest || !errors.Is(dw.ctx.Err(), context.DeadlineExceeded) {
+		dw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	dw.timedOut = true
+	message := fmt.Sprintf("Request exceeded its %s deadline", dw.timeout)
+	log.Printf("HTTP error: status=%d message=%s", http.StatusGatewayTimeout, message)
+	dw.ResponseWriter.Header().Set("Content-Type", "application/json")
+	dw.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	message = localizedMessage(dw.ResponseWriter, errCodeDeadlineExceeded, message, "timeout", dw.timeout.String())
+	json.NewEncoder(dw.ResponseWriter).Encode(errorResponse{Message: message, Code: errCodeDeadlineExceeded})
+}
+
+// Write drops the handler's own body once it has been replaced by the 504.
+func (dw *deadlineWriter) Write(b []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	if dw.timedOut {
+		return len(b), nil
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/config"
+)
+
+func TestDeadlineMiddleware_TimedOutErrorBecomes504(t *testing.T) {
+	s := &Server{timeouts: requestTimeouts{Default: 10 * time.Millisecond}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/brokers", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		respondError(w, http.StatusInternalServerError, r.Context().Err().Error())
+	})
+
+	rec := httptest.NewRecorder()
+	s.deadlineMiddleware(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/brokers", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+	}
+	if body.Code != errCodeDeadlineExceeded || body.Message == "" {
+		t.Fatalf("expected a deadline_exceeded error, got %+v", body)
+	}
+}
+
+func TestDeadlineMiddleware_DeadlinePerRouteClass(t *testing.T) {
+	s := &Server{timeouts: requestTimeouts{Default: time.Second, List: time.Hour}}
+	remaining := map[string]time.Duration{}
+	mux := http.NewServeMux()
+	record := func(w http.ResponseWriter, r *http.Request) {
+		if deadline, ok := r.Context().Deadline(); ok {
+			remaining[r.Pattern] = time.Until(deadline)
+		}
+		respondError(w, http.StatusBadRequest, "bad input")
+	}
+	for _, pattern := range []string{"GET /api/brokers", "GET /api/referrals", "GET /ws"} {
+		mux.HandleFunc(pattern, record)
+	}
+
+	for _, path := range []string{"/api/brokers", "/api/referrals", "/ws"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		s.deadlineMiddleware(mux).ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s: expected errors within the deadline to pass through, got %d", path, rec.Code)
+		}
+		if req.Pattern != "GET "+path {
+			t.Fatalf("GET %s: expected the matched pattern on the request for metrics, got %q", path, req.Pattern)
+		}
+	}
+	if d := remaining["GET /api/brokers"]; d <= 0 || d > time.Second {
+		t.Fatalf("expected the default deadline, got %s", d)
+	}
+	if d := remaining["GET /api/referrals"]; d <= time.Second || d > time.Hour {
+		t.Fatalf("expected the list deadline, got %s", d)
+	}
+	if _, ok := remaining["GET /ws"]; ok {
+		t.Fatalf("expected streams to have no deadline")
+	}
+}
+
+func TestRequestTimeouts_ZeroValueUsesDefaults(t *testing.T) {
+	var zero requestTimeouts
+	if zero.For(routeDefault) != defaultRequestTimeout || zero.For(routeList) != defaultListRequestTimeout ||
+		zero.For(routeBulk) != defaultBulkRequestTimeout || zero.For(routeStream) != 0 {
+		t.Fatalf("unexpected zero-value timeouts")
+	}
+	cfg, err := config.FromEnv(func(key string) string {
+		if key == config.EnvListRequestTimeout {
+			return "90s"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := requestTimeouts(cfg.RequestTimeouts).For(routeList); got != 90*time.Second {
+		t.Fatalf("expected LIST_REQUEST_TIMEOUT to apply, got %s", got)
+	}
+}
+
+func TestRouteClasses_NameRegisteredRoutes(t *testing.T) {
+	mux := newRouteMux()
+	for pattern := range routeClasses {
+		method, path, _ := strings.Cut(pattern, " ")
+		_, got := mux.Handler(httptest.NewRequest(method, path, nil))
+		if got != pattern {
+			t.Errorf("%s: no such route (matched %q)", pattern, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/apidoc"
+	"brokerflow/auth"
+	"brokerflow/dispute"
+	"brokerflow/invitation"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+	"brokerflow/review"
+)
+
+// newTopicRegistry collects the outbox topics declared by every producer
+// package. New producers must add their OutboxTopics here.
+func newTopicRegistry() *outbox.Registry {
+	reg := outbox.NewRegistry()
+	reg.MustRegister(agreement.OutboxTopics()...)
+	reg.MustRegister(dispute.OutboxTopics()...)
+	reg.MustRegister(invitation.OutboxTopics()...)
+	reg.MustRegister(referral.OutboxTopics()...)
+	reg.MustRegister(review.OutboxTopics()...)
+	return reg
+}
+
+// topicSchemaRef points at the payload schema published in /openapi.json.
+// buildOpenAPI registers every topic payload, so the component names match.
+func topicSchemaRef(t outbox.Topic) string {
+	if t.Payload == nil {
+		return ""
+	}
+	s := apidoc.NewBuilder("", "", "").SchemaFor(t.Payload)
+	if s.Ref == "" {
+		return ""
+	}
+	return "/openapi.json" + s.Ref
+}
+
+type topicResponse struct {
+	Name            string  `json:"name"`
+	Producer        string  `json:"producer"`
+	Description     string  `json:"description"`
+	SchemaRef       string  `json:"schemaRef,omitempty" doc:"JSON pointer to the payload schema in /openapi.json"`
+	LastPublishedAt *string `json:"lastPublishedAt,omitempty"`
+	Total           int64   `json:"total" doc:"Messages ever enqueued on the topic"`
+	Pending         int64   `json:"pending" doc:"Messages not yet delivered"`
+	Last24h         int64   `json:"last24h" doc:"Messages enqueued in the last 24 hours"`
+}
+
+type topicListResponse struct {
+	Items []topicResponse `json:"items"`
+}
+
+func (s *Server) handleAdminTopics(w http.ResponseWriter, r *http.Request) {
+	if !can(r.Context(), auth.PermOutboxAdmin) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return
+	}
+
+	ctx := r.Context()
+
+	stats, err := s.topicStats.TopicStats(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load topic statistics")
+		return
+	}
+
+	topics := s.topics.Topics()
+	items := make([]topicResponse, 0, len(topics))
+	for _, t := range topics {
+		item := topicResponse{
+			Name:        t.Name,
+			Producer:    t.Producer,
+			Description: t.Description,
+			SchemaRef:   topicSchemaRef(t),
+		}
+		if st, ok := stats[t.Name]; ok {
+			item.applyStats(st)
+		}
+		items = append(items, item)
+	}
+	// Rows whose topic no producer declares are surfaced too, so drift
+	// between code and data is visible rather than silently hidden.
+	for name, st := range stats {
+		if _, ok := s.topics.Lookup(name); ok {
+			continue
+		}
+		item := topicResponse{
+			Name:        name,
+			Producer:    "undeclared",
+			Description: "Published to the outbox but not declared by any producer.",
+		}
+		item.applyStats(st)
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	respondJSON(w, http.StatusOK, topicListResponse{Items: items})
+}
+
+func (t *topicResponse) applyStats(st outbox.TopicStats) {
+	t.Total = st.Total
+	t.Pending = st.Pending
+	t.Last24h = st.Last24h
+	if st.LastPublishedAt != nil {
+		ts := st.LastPublishedAt.UTC().Format(time.RFC3339)
+		t.LastPublishedAt = &ts
+	}
+}
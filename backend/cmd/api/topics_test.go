@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+)
+
+type stubTopicStats struct {
+	stats map[string]outbox.TopicStats
+	err   error
+}
+
+func (s *stubTopicStats) TopicStats(_ context.Context) (map[string]outbox.TopicStats, error) {
+	return s.stats, s.err
+}
+
+func TestHandleAdminTopics_ListsDeclaredAndUndeclared(t *testing.T) {
+	published := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := &Server{
+		topics: newTopicRegistry(),
+		topicStats: &stubTopicStats{stats: map[string]outbox.TopicStats{
+			agreement.OutboxTopicAgreementCreated: {Topic: agreement.OutboxTopicAgreementCreated, LastPublishedAt: &published, Total: 5, Pending: 1, Last24h: 2},
+			"legacy.topic":                        {Topic: "legacy.topic", Total: 3},
+		}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/topics", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleBrokerAdmin))
+	rec := httptest.NewRecorder()
+
+	server.handleAdminTopics(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var payload topicListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	byName := make(map[string]topicResponse, len(payload.Items))
+	for _, item := range payload.Items {
+		byName[item.Name] = item
+	}
+	created, ok := byName[agreement.OutboxTopicAgreementCreated]
+	if !ok || created.Total != 5 || created.LastPublishedAt == nil || *created.LastPublishedAt != "2025-03-01T12:00:00Z" {
+		t.Fatalf("unexpected agreement.created entry: %+v", created)
+	}
+	if created.SchemaRef != "/openapi.json#/components/schemas/AgreementCreatedPayload" {
+		t.Fatalf("unexpected schema ref %q", created.SchemaRef)
+	}
+	if _, ok := buildOpenAPI().Components.Schemas["AgreementCreatedPayload"]; !ok {
+		t.Fatal("expected topic payload schema to be published in the OpenAPI document")
+	}
+	if _, ok := byName[referral.OutboxTopicReferralCancelled]; !ok {
+		t.Fatal("expected declared topic without traffic to be listed")
+	}
+	if legacy := byName["legacy.topic"]; legacy.Producer != "undeclared" || legacy.Total != 3 {
+		t.Fatalf("unexpected undeclared entry: %+v", legacy)
+	}
+}
+
+func TestHandleAdminTopics_RequiresBrokerAdmin(t *testing.T) {
+	server := &Server{topics: newTopicRegistry(), topicStats: &stubTopicStats{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/topics", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyRole, auth.RoleAgent))
+	rec := httptest.NewRecorder()
+
+	server.handleAdminTopics(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
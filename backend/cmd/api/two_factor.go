@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"brokerflow/auth"
+)
+
+// twoFactorChallengeResponse is returned by /auth/login instead of a token
+// when the account has TOTP enabled.
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+}
+
+type verifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code" doc:"Current TOTP code or an unused recovery code"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri" doc:"otpauth:// URI to render as a QR code"`
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes" doc:"Single-use codes, shown only once"`
+}
+
+// handleVerifyTwoFactor exchanges a login challenge and a TOTP or recovery
+// code for a session token.
+func (s *Server) handleVerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req verifyTwoFactorRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	resp, err := s.authService.VerifyTwoFactor(ctx, req.ChallengeToken, req.Code, clientIP(r), r.UserAgent())
+	if err != nil {
+		if respondLockout(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrInvalidChallenge):
+			respondError(w, http.StatusUnauthorized, "Login challenge expired, sign in again")
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			respondError(w, http.StatusUnauthorized, "Invalid verification code")
+		default:
+			respondError(w, http.StatusInternalServerError, "Login failed")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, loginResponse{
+		Token: resp.Token,
+		User:  newAgentResponse(resp.User),
+	})
+}
+
+// handleEnrollTOTP creates a pending TOTP secret for the caller. It takes
+// effect once confirmed with a code.
+func (s *Server) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	ctx := r.Context()
+
+	setup, err := s.authService.EnrollTOTP(ctx, userID)
+	if err != nil {
+		respondTwoFactorError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, totpEnrollmentResponse{Secret: setup.Secret, ProvisioningURI: setup.ProvisioningURI})
+}
+
+// handleConfirmTOTP enables TOTP and returns the recovery codes.
+func (s *Server) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	var req confirmTOTPRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	codes, err := s.authService.ConfirmTOTP(ctx, userID, req.Code)
+	if err != nil {
+		respondTwoFactorError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func respondTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorNotAllowed):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		respondError(w, http.StatusBadRequest, "Invalid verification code")
+	case errors.Is(err, auth.ErrTwoFactorEnabled), errors.Is(err, auth.ErrTwoFactorNotEnrolled):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, auth.ErrUserNotFound):
+		respondError(w, http.StatusNotFound, "User not found")
+	default:
+		respondError(w, http.StatusInternalServerError, "Two-factor setup failed")
+	}
+}
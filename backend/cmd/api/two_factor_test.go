@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// stubTwoFactorRepo reports TOTP as enabled for every user and accepts no codes.
+type stubTwoFactorRepo struct{}
+
+func (stubTwoFactorRepo) GetTOTP(_ context.Context, userID string) (auth.TOTPEnrollment, error) {
+	enabled := time.Now()
+	return auth.TOTPEnrollment{UserID: userID, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", EnabledAt: &enabled}, nil
+}
+
+func (stubTwoFactorRepo) SaveTOTPSecret(context.Context, string, string) error {
+	return auth.ErrTwoFactorEnabled
+}
+
+func (stubTwoFactorRepo) EnableTOTP(context.Context, string, int64, []string) error {
+	return auth.ErrTwoFactorEnabled
+}
+
+func (stubTwoFactorRepo) UseTOTPStep(context.Context, string, int64) (bool, error) {
+	return false, nil
+}
+
+func (stubTwoFactorRepo) UseRecoveryCode(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
+func newTwoFactorServer(t *testing.T) *Server {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("supersafe"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	users := &stubUserRepo{users: map[string]auth.User{
+		"admin-1": {ID: "admin-1", Email: "admin@example.com", PasswordHash: string(hash), Role: auth.RoleBrokerAdmin},
+	}}
+	return &Server{authService: auth.NewService(users, "secret").WithTwoFactor(stubTwoFactorRepo{})}
+}
+
+func TestHandleLogin_TwoFactorChallenge(t *testing.T) {
+	server := newTwoFactorServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"admin@example.com","password":"supersafe"}`))
+	rec := httptest.NewRecorder()
+
+	server.handleLogin(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp twoFactorChallengeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.TwoFactorRequired || resp.ChallengeToken == "" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if strings.Contains(rec.Body.String(), `"token"`) {
+		t.Fatal("session token must not be issued before the second factor")
+	}
+
+	body := `{"challengeToken":"` + resp.ChallengeToken + `","code":"wrong-code"}`
+	rec = httptest.NewRecorder()
+	server.handleVerifyTwoFactor(rec, httptest.NewRequest(http.MethodPost, "/auth/login/2fa", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong code: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestHandleEnrollTOTP_AlreadyEnabled(t *testing.T) {
+	server := newTwoFactorServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/me/2fa/totp", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyUserID, "admin-1"))
+	rec := httptest.NewRecorder()
+
+	server.handleEnrollTOTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
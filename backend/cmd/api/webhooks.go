@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/webhook"
+	"github.com/google/uuid"
+)
+
+type webhookService interface {
+	Create(ctx context.Context, params webhook.CreateParams) (webhook.Webhook, error)
+	List(ctx context.Context, brokerID string) ([]webhook.Webhook, error)
+	Delete(ctx context.Context, brokerID, id string) error
+	Deliveries(ctx context.Context, brokerID, id string, page, pageSize int) ([]webhook.Delivery, int, error)
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" doc:"Absolute https URL"`
+	Topics []string `json:"topics" doc:"Outbox topics to deliver, see GET /api/admin/topics"`
+}
+
+type webhookResponse struct {
+	ID        string   `json:"id"`
+	BrokerID  string   `json:"brokerId"`
+	URL       string   `json:"url"`
+	Topics    []string `json:"topics"`
+	Secret    string   `json:"secret,omitempty" doc:"HMAC signing key; returned only when the webhook is created"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type webhookListResponse struct {
+	Items []webhookResponse `json:"items"`
+}
+
+type webhookDeliveryResponse struct {
+	MessageID      string  `json:"messageId" doc:"Outbox message id, also sent as BrokerFlow-Delivery"`
+	Topic          string  `json:"topic"`
+	Status         string  `json:"status" doc:"pending, completed, failed or cancelled"`
+	Attempts       int     `json:"attempts"`
+	ResponseCode   *int    `json:"responseCode,omitempty"`
+	Error          *string `json:"error,omitempty"`
+	FirstAttemptAt string  `json:"firstAttemptAt"`
+	LastAttemptAt  string  `json:"lastAttemptAt"`
+	NextAttemptAt  *string `json:"nextAttemptAt,omitempty"`
+}
+
+type paginatedWebhookDeliveries struct {
+	Items    []webhookDeliveryResponse `json:"items"`
+	Total    int                       `json:"total"`
+	Page     int                       `json:"page"`
+	PageSize int                       `json:"pageSize"`
+}
+
+func newWebhookResponse(w webhook.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        w.ID,
+		BrokerID:  w.BrokerID,
+		URL:       w.URL,
+		Topics:    append([]string{}, w.Topics...),
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// brokerAdminOf returns the caller and the broker in the path when the
+// caller is a broker_admin of that broker, or writes the error response.
+func (s *Server) brokerAdminOf(w http.ResponseWriter, r *http.Request) (userID, brokerID string, ok bool) {
+	userID, ok = r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return "", "", false
+	}
+	if !can(r.Context(), auth.PermBrokerManage) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return "", "", false
+	}
+	brokerID = r.PathValue("id")
+	if claimed, ok := r.Context().Value(ctxKeyBrokerID).(string); ok {
+		if claimed != brokerID {
+			respondError(w, http.StatusForbidden, "Insufficient permissions")
+			return "", "", false
+		}
+		return userID, brokerID, true
+	}
+	user, err := s.authService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load user")
+		return "", "", false
+	}
+	if user.BrokerID == nil || *user.BrokerID != brokerID {
+		respondError(w, http.StatusForbidden, "Insufficient permissions")
+		return "", "", false
+	}
+	return userID, brokerID, true
+}
+
+// handleCreateWebhook registers a callback URL for the caller's broker. The
+// response carries the signing secret, which is not shown again.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	var req createWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	hook, err := s.webhooks.Create(ctx, webhook.CreateParams{
+		BrokerID:  brokerID,
+		URL:       req.URL,
+		Topics:    req.Topics,
+		CreatedBy: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrNoTopics),
+			errors.Is(err, webhook.ErrTooManyTopics), errors.Is(err, webhook.ErrUnknownTopic):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to create webhook")
+		}
+		return
+	}
+	respondJSON(w, http.StatusCreated, newWebhookResponse(hook))
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	hooks, err := s.webhooks.List(ctx, brokerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load webhooks")
+		return
+	}
+	items := make([]webhookResponse, 0, len(hooks))
+	for _, h := range hooks {
+		items = append(items, newWebhookResponse(h))
+	}
+	respondJSON(w, http.StatusOK, webhookListResponse{Items: items})
+}
+
+// handleDeleteWebhook removes a webhook; deliveries still pending are
+// cancelled.
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	webhookID := r.PathValue("webhookId")
+	if _, err := uuid.Parse(webhookID); err != nil {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.webhooks.Delete(ctx, brokerID, webhookID); err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListWebhookDeliveries pages through a webhook's delivery attempts,
+// newest first.
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, brokerID, ok := s.brokerAdminOf(w, r)
+	if !ok {
+		return
+	}
+	webhookID := r.PathValue("webhookId")
+	if _, err := uuid.Parse(webhookID); err != nil {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	ctx := r.Context()
+
+	deliveries, total, err := s.webhooks.Deliveries(ctx, brokerID, webhookID, page, pageSize)
+	if err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load deliveries")
+		return
+	}
+
+	items := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		item := webhookDeliveryResponse{
+			MessageID:      d.MessageID,
+			Topic:          d.Topic,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseCode:   d.ResponseCode,
+			Error:          d.Error,
+			FirstAttemptAt: d.FirstAttempt.UTC().Format(time.RFC3339),
+			LastAttemptAt:  d.LastAttempt.UTC().Format(time.RFC3339),
+		}
+		if d.NextAttemptAt != nil {
+			next := d.NextAttemptAt.UTC().Format(time.RFC3339)
+			item.NextAttemptAt = &next
+		}
+		items = append(items, item)
+	}
+	respondJSON(w, http.StatusOK, paginatedWebhookDeliveries{Items: items, Total: total, Page: page, PageSize: pageSize})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/webhook"
+)
+
+const testWebhookID = "3d9b5c1e-7a2f-4e8b-9c61-5f0a2b7d4e19"
+
+type stubWebhooks struct {
+	created   webhook.CreateParams
+	createErr error
+	deleteErr error
+}
+
+func (s *stubWebhooks) Create(_ context.Context, params webhook.CreateParams) (webhook.Webhook, error) {
+	s.created = params
+	if s.createErr != nil {
+		return webhook.Webhook{}, s.createErr
+	}
+	return webhook.Webhook{ID: "w1", BrokerID: params.BrokerID, URL: params.URL, Topics: params.Topics, Secret: "whsec_x", CreatedAt: time.Now()}, nil
+}
+
+func (s *stubWebhooks) List(context.Context, string) ([]webhook.Webhook, error) { return nil, nil }
+
+func (s *stubWebhooks) Delete(context.Context, string, string) error { return s.deleteErr }
+
+func (s *stubWebhooks) Deliveries(context.Context, string, string, int, int) ([]webhook.Delivery, int, error) {
+	return nil, 0, nil
+}
+
+func webhookTestServer(hooks *stubWebhooks) *Server {
+	brokerID := "broker-1"
+	return &Server{
+		webhooks: hooks,
+		authService: auth.NewService(&stubUserRepo{users: map[string]auth.User{
+			"admin-1": {ID: "admin-1", Role: auth.RoleBrokerAdmin, BrokerID: &brokerID},
+		}}, "secret"),
+	}
+}
+
+func brokerAdminRequest(method, path, body, brokerID string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetPathValue("id", brokerID)
+	ctx := context.WithValue(req.Context(), ctxKeyUserID, "admin-1")
+	ctx = context.WithValue(ctx, ctxKeyRole, auth.RoleBrokerAdmin)
+	return req.WithContext(ctx)
+}
+
+func TestHandleCreateWebhook(t *testing.T) {
+	hooks := &stubWebhooks{}
+	server := webhookTestServer(hooks)
+	rec := httptest.NewRecorder()
+
+	server.handleCreateWebhook(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/webhooks",
+		`{"url":"https://partner.example/hook","topics":["match.accepted"]}`, "broker-1"))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hooks.created.BrokerID != "broker-1" || hooks.created.CreatedBy != "admin-1" {
+		t.Fatalf("unexpected create params %+v", hooks.created)
+	}
+	var resp webhookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Secret != "whsec_x" {
+		t.Fatalf("expected secret in create response, got %+v", resp)
+	}
+}
+
+func TestHandleCreateWebhook_OtherBrokerForbidden(t *testing.T) {
+	hooks := &stubWebhooks{}
+	server := webhookTestServer(hooks)
+	rec := httptest.NewRecorder()
+
+	server.handleCreateWebhook(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-2/webhooks",
+		`{"url":"https://partner.example/hook","topics":["match.accepted"]}`, "broker-2"))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	if hooks.created.BrokerID != "" {
+		t.Fatal("webhook must not be created for another broker")
+	}
+}
+
+func TestHandleCreateWebhook_InvalidParams(t *testing.T) {
+	server := webhookTestServer(&stubWebhooks{createErr: webhook.ErrUnknownTopic})
+	rec := httptest.NewRecorder()
+
+	server.handleCreateWebhook(rec, brokerAdminRequest(http.MethodPost, "/api/brokers/broker-1/webhooks",
+		`{"url":"https://partner.example/hook","topics":["nope"]}`, "broker-1"))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleDeleteWebhook_NotFound(t *testing.T) {
+	server := webhookTestServer(&stubWebhooks{deleteErr: webhook.ErrNotFound})
+	req := brokerAdminRequest(http.MethodDelete, "/api/brokers/broker-1/webhooks/"+testWebhookID, "", "broker-1")
+	req.SetPathValue("webhookId", testWebhookID)
+	rec := httptest.NewRecorder()
+
+	server.handleDeleteWebhook(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
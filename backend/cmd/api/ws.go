@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+	"brokerflow/review"
+	"brokerflow/timeline"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = 50 * time.Second
+	wsSendBuffer   = 32
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Authentication is by bearer token, not cookies, so a foreign origin
+	// gains nothing it could not do with the token directly.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsTopics are the outbox topics pushed to connected users.
+var wsTopics = map[string]bool{
+	referral.OutboxTopicMatchInvited:              true,
+	referral.OutboxTopicMatchAccepted:             true,
+	referral.OutboxTopicMatchExpired:              true,
+	referral.OutboxTopicMatchApplied:              true,
+	referral.OutboxTopicMatchCountered:            true,
+	review.OutboxTopicReviewSubmitted:             true,
+	agreement.OutboxTopicAgreementStatusChanged:   true,
+	agreement.OutboxTopicAgreementExpired:         true,
+	agreement.OutboxTopicAgreementCancelled:       true,
+	agreement.OutboxTopicAgreementStatusCorrected: true,
+	agreement.OutboxTopicAgreementDealEvent:       true,
+	dispute.OutboxTopicDisputeOpened:              true,
+	dispute.OutboxTopicDisputeEscalated:           true,
+	dispute.OutboxTopicDisputeResolved:            true,
+}
+
+// wsTopicNames lists wsTopics, for relaying them from cmd/worker.
+func wsTopicNames() []string {
+	names := make([]string, 0, len(wsTopics))
+	for t := range wsTopics {
+		names = append(names, t)
+	}
+	return names
+}
+
+type agreementParticipants interface {
+	ParticipantUserIDs(ctx context.Context, agreementID string) ([]string, error)
+}
+
+// notification is the JSON frame sent to WebSocket clients.
+type notification struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+	At      time.Time       `json:"at"`
+}
+
+// wsHub tracks connected users and fans outbox messages out to them. It is
+// fed by the outbox worker through HandleOutbox.
+type wsHub struct {
+	participants agreementParticipants
+
+	mu      sync.RWMutex
+	clients map[string]map[*wsClient]struct{}
+}
+
+type wsClient struct {
+	userID string
+	send   chan []byte
+}
+
+func newWSHub(participants agreementParticipants) *wsHub {
+	return &wsHub{participants: participants, clients: make(map[string]map[*wsClient]struct{})}
+}
+
+func (h *wsHub) register(c *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	set, ok := h.clients[c.userID]
+	if !ok {
+		set = make(map[*wsClient]struct{})
+		h.clients[c.userID] = set
+	}
+	set[c] = struct{}{}
+}
+
+func (h *wsHub) unregister(c *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if set, ok := h.clients[c.userID]; ok {
+		if _, ok := set[c]; ok {
+			delete(set, c)
+			close(c.send)
+		}
+		if len(set) == 0 {
+			delete(h.clients, c.userID)
+		}
+	}
+}
+
+// sendTo queues frame for every connection of userIDs. A client whose buffer
+// is full is too slow to keep up and is disconnected.
+func (h *wsHub) sendTo(userIDs []string, frame []byte) {
+	var slow []*wsClient
+	h.mu.RLock()
+	for _, id := range userIDs {
+		for c := range h.clients[id] {
+			select {
+			case c.send <- frame:
+			default:
+				slow = append(slow, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range slow {
+		h.unregister(c)
+	}
+}
+
+// HandleOutbox implements outbox.Handler. Topics that are not pushed are
+// acknowledged without work.
+func (h *wsHub) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	if !wsTopics[msg.Topic] {
+		return nil
+	}
+	recipients, err := h.recipients(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	payload := msg.Payload
+	if msg.Topic == agreement.OutboxTopicAgreementDealEvent {
+		if payload, err = upgradeDealEvent(msg.Payload); err != nil {
+			return err
+		}
+	}
+	frame, err := json.Marshal(notification{Topic: msg.Topic, Payload: payload, At: msg.CreatedAt.UTC()})
+	if err != nil {
+		return fmt.Errorf("encode notification: %w", err)
+	}
+	h.sendTo(recipients, frame)
+	return nil
+}
+
+func (h *wsHub) recipients(ctx context.Context, msg outbox.Message) ([]string, error) {
+	switch msg.Topic {
+	case referral.OutboxTopicMatchInvited:
+		var p referral.MatchEventPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return []string{p.CandidateID}, nil
+	case referral.OutboxTopicMatchAccepted:
+		var p referral.MatchEventPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return []string{p.OwnerID, p.CandidateID}, nil
+	case referral.OutboxTopicMatchExpired, referral.OutboxTopicMatchApplied, referral.OutboxTopicMatchCountered:
+		var p referral.MatchEventPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return []string{p.OwnerID}, nil
+	case review.OutboxTopicReviewSubmitted:
+		var p review.ReviewSubmittedPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return []string{p.RevieweeID}, nil
+	case agreement.OutboxTopicAgreementStatusChanged:
+		var p agreement.AgreementStatusChangedPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	case agreement.OutboxTopicAgreementExpired:
+		var p agreement.AgreementExpiredPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	case agreement.OutboxTopicAgreementCancelled:
+		var p agreement.AgreementCancelledPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	case agreement.OutboxTopicAgreementStatusCorrected:
+		var p agreement.AgreementStatusCorrectedPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	case agreement.OutboxTopicAgreementDealEvent:
+		var p agreement.AgreementDealEventPayload
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	case dispute.OutboxTopicDisputeOpened, dispute.OutboxTopicDisputeEscalated, dispute.OutboxTopicDisputeResolved:
+		// Every dispute payload names its agreement; an assigned reviewer is
+		// a broker_admin of one of its brokers and so a participant.
+		var p struct {
+			AgreementID string `json:"agreement_id"`
+		}
+		if err := json.Unmarshal(msg.Payload, &p); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", msg.Topic, err)
+		}
+		return h.participants.ParticipantUserIDs(ctx, p.AgreementID)
+	}
+	return nil, nil
+}
+
+// upgradeDealEvent rewrites the timeline payload a deal event carries to the
+// current version of its event type, so clients see the shape the timeline
+// endpoints return.
+func upgradeDealEvent(raw json.RawMessage) (json.RawMessage, error) {
+	var p agreement.AgreementDealEventPayload
+	var embedded struct {
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", agreement.OutboxTopicAgreementDealEvent, err)
+	}
+	if err := json.Unmarshal(raw, &embedded); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", agreement.OutboxTopicAgreementDealEvent, err)
+	}
+	payload, err := timeline.Decode(p.Type, p.PayloadVersion, embedded.Payload)
+	if err != nil {
+		return nil, err
+	}
+	p.Payload = payload
+	if def, ok := timeline.Lookup(p.Type); ok {
+		p.PayloadVersion = def.CurrentVersion()
+	}
+	out, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s payload: %w", agreement.OutboxTopicAgreementDealEvent, err)
+	}
+	return out, nil
+}
+
+// handleWebSocket upgrades an authenticated request and streams notifications
+// addressed to the caller until either side closes the connection.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(string)
+	if !ok || userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid authentication context")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		log.Printf("websocket upgrade: %v", err)
+		return
+	}
+
+	client := &wsClient{userID: userID, send: make(chan []byte, wsSendBuffer)}
+	s.wsHub.register(client)
+	go wsWritePump(conn, client)
+	wsReadPump(conn)
+	s.wsHub.unregister(client)
+}
+
+// wsReadPump discards client frames; reading is needed to process pongs and
+// notice the peer going away.
+func wsReadPump(conn *websocket.Conn) {
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func wsWritePump(conn *websocket.Conn, client *wsClient) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+	for {
+		select {
+		case frame, ok := <-client.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+	"github.com/gorilla/websocket"
+)
+
+type stubParticipants struct {
+	ids []string
+}
+
+func (s *stubParticipants) ParticipantUserIDs(_ context.Context, _ string) ([]string, error) {
+	return s.ids, nil
+}
+
+func dialTestWS(t *testing.T, server *Server, userID string) *websocket.Conn {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handleWebSocket(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUserID, userID)))
+	}))
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		server.wsHub.mu.RLock()
+		n := len(server.wsHub.clients[userID])
+		server.wsHub.mu.RUnlock()
+		if n > 0 {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client was not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readNotification(t *testing.T, conn *websocket.Conn) notification {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var n notification
+	if err := conn.ReadJSON(&n); err != nil {
+		t.Fatalf("read notification: %v", err)
+	}
+	return n
+}
+
+func TestWSHub_PushesInvitationToCandidate(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{})}
+	conn := dialTestWS(t, server, "agent-2")
+
+	payload, _ := json.Marshal(referral.MatchEventPayload{MatchID: "m1", ReferralID: "r1", CandidateID: "agent-2", OwnerID: "agent-1", State: referral.MatchStateInvited})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o1", Topic: referral.OutboxTopicMatchInvited, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	n := readNotification(t, conn)
+	if n.Topic != referral.OutboxTopicMatchInvited || !strings.Contains(string(n.Payload), `"match_id":"m1"`) {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestWSHub_PushesExpiryToOwner(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{})}
+	owner := dialTestWS(t, server, "agent-1")
+
+	payload, _ := json.Marshal(referral.MatchEventPayload{MatchID: "m1", ReferralID: "r1", CandidateID: "agent-2", OwnerID: "agent-1", State: referral.MatchStateExpired})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o3", Topic: referral.OutboxTopicMatchExpired, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	if n := readNotification(t, owner); n.Topic != referral.OutboxTopicMatchExpired {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestWSHub_PushesApplicationToOwner(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{})}
+	owner := dialTestWS(t, server, "agent-1")
+
+	payload, _ := json.Marshal(referral.MatchEventPayload{MatchID: "m1", ReferralID: "r1", CandidateID: "agent-2", OwnerID: "agent-1", State: referral.MatchStateApplied})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o4", Topic: referral.OutboxTopicMatchApplied, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	if n := readNotification(t, owner); n.Topic != referral.OutboxTopicMatchApplied {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestWSHub_StatusChangeReachesParticipantsOnly(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{ids: []string{"owner-1"}})}
+	owner := dialTestWS(t, server, "owner-1")
+	outsider := dialTestWS(t, server, "outsider")
+
+	payload, _ := json.Marshal(agreement.AgreementStatusChangedPayload{AgreementID: "ag1", Previous: "draft", Next: "pending_signature"})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o2", Topic: agreement.OutboxTopicAgreementStatusChanged, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	if n := readNotification(t, owner); n.Topic != agreement.OutboxTopicAgreementStatusChanged {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	_ = outsider.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := outsider.ReadMessage(); err == nil {
+		t.Fatal("outsider should not receive the status change")
+	}
+}
+
+func TestWSHub_DealEventCarriesDecodedPayload(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{ids: []string{"owner-1"}})}
+	owner := dialTestWS(t, server, "owner-1")
+
+	payload, _ := json.Marshal(agreement.AgreementDealEventPayload{
+		AgreementID: "ag1", EventID: 9, Seq: 3, Type: agreement.EventOfferMade,
+		Payload: map[string]any{"price": 500000}, PayloadVersion: 1,
+	})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o5", Topic: agreement.OutboxTopicAgreementDealEvent, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	n := readNotification(t, owner)
+	var got agreement.AgreementDealEventPayload
+	if err := json.Unmarshal(n.Payload, &got); err != nil {
+		t.Fatalf("decode frame payload: %v", err)
+	}
+	if n.Topic != agreement.OutboxTopicAgreementDealEvent || got.Payload["price"] != float64(500000) || got.PayloadVersion != 1 {
+		t.Fatalf("unexpected notification: %s %+v", n.Topic, got)
+	}
+}
+
+func TestWSHub_PushesEscalationToParticipants(t *testing.T) {
+	server := &Server{wsHub: newWSHub(&stubParticipants{ids: []string{"owner-1", "admin-2"}})}
+	reviewer := dialTestWS(t, server, "admin-2")
+
+	payload, _ := json.Marshal(dispute.DisputeEscalatedPayload{DisputeID: "d1", AgreementID: "ag1", Tier: 1, ReviewerID: "admin-2"})
+	if err := server.wsHub.HandleOutbox(context.Background(), outbox.Message{ID: "o5", Topic: dispute.OutboxTopicDisputeEscalated, Payload: payload}); err != nil {
+		t.Fatalf("handle outbox: %v", err)
+	}
+
+	n := readNotification(t, reviewer)
+	if n.Topic != dispute.OutboxTopicDisputeEscalated || !strings.Contains(string(n.Payload), `"reviewer_id":"admin-2"`) {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestWSHub_IgnoresUnpushedTopics(t *testing.T) {
+	hub := newWSHub(&stubParticipants{})
+	if err := hub.HandleOutbox(context.Background(), outbox.Message{Topic: referral.OutboxTopicReferralCreated, Payload: []byte(`not json`)}); err != nil {
+		t.Fatalf("expected unpushed topic to be acknowledged, got %v", err)
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// scenario carries the identifiers produced by earlier steps into later ones.
+type scenario struct {
+	api  *client
+	pool *pgxpool.Pool
+
+	owner       actor
+	candidate   actor
+	referralID  string
+	matchID     string
+	agreementID string
+	disputeID   string
+}
+
+type actor struct {
+	email    string
+	token    string
+	userID   string
+	brokerID string
+}
+
+type step struct {
+	name string
+	run  func(ctx context.Context) error
+	// skip documents lifecycle stages the API does not expose yet. The step
+	// stays in the list so the specification reads end to end.
+	skip string
+}
+
+func (sc *scenario) run(ctx context.Context) error {
+	steps := []step{
+		{name: "register agents in two brokerages", run: sc.registerAgents},
+		{name: "owner creates referral", run: sc.createReferral},
+		{name: "owner invites candidate", run: sc.inviteCandidate},
+		{name: "candidate accepts invitation", run: sc.acceptInvitation},
+		{name: "e-sign completes", run: sc.completeEsign},
+		{name: "deal milestones recorded", run: sc.recordMilestones},
+		{name: "deal succeeds and closes", run: sc.closeAgreement},
+		{name: "invoice issued", skip: "no invoicing endpoint yet"},
+		{name: "owner opens dispute", run: sc.openDispute},
+		{name: "owner resolves dispute", run: sc.resolveDispute},
+	}
+
+	for i, st := range steps {
+		if st.skip != "" {
+			log.Printf("⏭️  [%d/%d] %s: skipped (%s)", i+1, len(steps), st.name, st.skip)
+			continue
+		}
+		start := time.Now()
+		if err := st.run(ctx); err != nil {
+			return fmt.Errorf("step %q: %w", st.name, err)
+		}
+		log.Printf("✔️  [%d/%d] %s (%s)", i+1, len(steps), st.name, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (sc *scenario) registerAgents(ctx context.Context) error {
+	suffix := time.Now().UnixNano()
+	sc.owner = actor{email: fmt.Sprintf("owner-%d@e2e.test", suffix)}
+	sc.candidate = actor{email: fmt.Sprintf("candidate-%d@e2e.test", suffix)}
+
+	for i, a := range []*actor{&sc.owner, &sc.candidate} {
+		body := map[string]any{
+			"email":     a.email,
+			"password":  "Passw0rd!e2e",
+			"full_name": fmt.Sprintf("E2E Agent %d", i+1),
+			"role":      "agent",
+		}
+		var registered struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		if err := sc.api.do(ctx, "", http.MethodPost, "/auth/register", body, http.StatusCreated, &registered); err != nil {
+			return err
+		}
+
+		var login struct {
+			Token string `json:"token"`
+			User  struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		creds := map[string]string{"email": a.email, "password": "Passw0rd!e2e"}
+		if err := sc.api.do(ctx, "", http.MethodPost, "/auth/login", creds, http.StatusOK, &login); err != nil {
+			return err
+		}
+		if login.Token == "" || login.User.ID != registered.User.ID {
+			return fmt.Errorf("login for %s returned token=%t user=%s", a.email, login.Token != "", login.User.ID)
+		}
+		a.token = login.Token
+		a.userID = login.User.ID
+
+		// Brokerage membership has no public endpoint; onboarding is an
+		// operator task, so the runner performs it directly.
+		if err := sc.pool.QueryRow(ctx, `
+			INSERT INTO brokers (name, fein, verified)
+			VALUES ($1, $2, TRUE)
+			RETURNING id::text
+		`, fmt.Sprintf("E2E Brokerage %d-%d", suffix, i), fmt.Sprintf("E2E-%d-%d", suffix, i)).Scan(&a.brokerID); err != nil {
+			return fmt.Errorf("seed broker: %w", err)
+		}
+		if _, err := sc.pool.Exec(ctx, `UPDATE users SET broker_id = $1 WHERE id = $2`, a.brokerID, a.userID); err != nil {
+			return fmt.Errorf("assign broker: %w", err)
+		}
+	}
+
+	var me struct {
+		ID       string `json:"id"`
+		BrokerID string `json:"brokerId"`
+	}
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodGet, "/api/me", nil, http.StatusOK, &me); err != nil {
+		return err
+	}
+	if me.ID != sc.owner.userID || me.BrokerID != sc.owner.brokerID {
+		return fmt.Errorf("/api/me returned id=%s broker=%s", me.ID, me.BrokerID)
+	}
+	return nil
+}
+
+func (sc *scenario) createReferral(ctx context.Context) error {
+	body := map[string]any{
+		"region":       []string{"US-CA-San Francisco"},
+		"priceMin":     800000,
+		"priceMax":     1200000,
+		"propertyType": "condo",
+		"dealType":     "buy",
+		"languages":    []string{"en"},
+		"slaHours":     48,
+	}
+	var created struct {
+		ID             string `json:"id"`
+		CreatorAgentID string `json:"creatorAgentId"`
+		Status         string `json:"status"`
+	}
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodPost, "/api/referrals", body, http.StatusCreated, &created); err != nil {
+		return err
+	}
+	if created.Status != "open" || created.CreatorAgentID != sc.owner.userID {
+		return fmt.Errorf("unexpected referral %+v", created)
+	}
+	sc.referralID = created.ID
+	return sc.expectReferralStatus(ctx, "open")
+}
+
+func (sc *scenario) inviteCandidate(ctx context.Context) error {
+	body := map[string]any{"candidateAgentId": sc.candidate.userID, "score": 0.9}
+	var match struct {
+		ID    string `json:"id"`
+		State string `json:"state"`
+	}
+	path := "/api/referrals/" + sc.referralID + "/matches"
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodPost, path, body, http.StatusCreated, &match); err != nil {
+		return err
+	}
+	if match.State != "invited" {
+		return fmt.Errorf("expected invited match, got %s", match.State)
+	}
+	sc.matchID = match.ID
+
+	var inbox struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := sc.api.do(ctx, sc.candidate.token, http.MethodGet, "/api/matches", nil, http.StatusOK, &inbox); err != nil {
+		return err
+	}
+	for _, item := range inbox.Items {
+		if item.ID == sc.matchID {
+			return nil
+		}
+	}
+	return fmt.Errorf("invitation %s missing from candidate inbox", sc.matchID)
+}
+
+func (sc *scenario) acceptInvitation(ctx context.Context) error {
+	var resp struct {
+		State     string `json:"state"`
+		Agreement *struct {
+			ID               string `json:"id"`
+			ReferrerBrokerID string `json:"referrerBrokerId"`
+			RefereeBrokerID  string `json:"refereeBrokerId"`
+		} `json:"agreement"`
+	}
+	path := "/api/referrals/" + sc.referralID + "/matches/" + sc.matchID
+	if err := sc.api.do(ctx, sc.candidate.token, http.MethodPatch, path, map[string]string{"state": "accepted"}, http.StatusOK, &resp); err != nil {
+		return err
+	}
+	if resp.State != "accepted" || resp.Agreement == nil {
+		return fmt.Errorf("expected accepted match with agreement, got state=%s agreement=%v", resp.State, resp.Agreement != nil)
+	}
+	if resp.Agreement.ReferrerBrokerID != sc.owner.brokerID || resp.Agreement.RefereeBrokerID != sc.candidate.brokerID {
+		return fmt.Errorf("agreement brokers %s -> %s do not match the parties", resp.Agreement.ReferrerBrokerID, resp.Agreement.RefereeBrokerID)
+	}
+	sc.agreementID = resp.Agreement.ID
+
+	if err := sc.expectReferralStatus(ctx, "matched"); err != nil {
+		return err
+	}
+	if err := sc.expectAgreementStatus(ctx, "pending_signature"); err != nil {
+		return err
+	}
+	if err := sc.expectTimeline(ctx, "AGREEMENT_CREATED"); err != nil {
+		return err
+	}
+	return sc.expectOutbox(ctx, "agreement.created")
+}
+
+// completeEsign drives pending_signature -> effective. The provider webhook
+// is not routed by cmd/api, so the runner uses the status API, which goes
+// through the same transition validation.
+func (sc *scenario) completeEsign(ctx context.Context) error {
+	stale, err := sc.agreementETag(ctx)
+	if err != nil {
+		return err
+	}
+	if err := sc.transition(ctx, "effective", map[string]any{"source": "e2e-esign"}); err != nil {
+		return err
+	}
+	// A second writer still holding the pre-signature ETag is refused.
+	body := map[string]any{"agreementId": sc.agreementID, "nextStatus": "success"}
+	if err := sc.api.doIfMatch(ctx, sc.owner.token, http.MethodPatch, "/api/agreements", stale, body, http.StatusPreconditionFailed, nil); err != nil {
+		return err
+	}
+	if err := sc.expectAgreementStatus(ctx, "effective"); err != nil {
+		return err
+	}
+	if err := sc.expectTimeline(ctx, "AGREEMENT_CREATED", "AGREEMENT_STATUS_CHANGED"); err != nil {
+		return err
+	}
+	return sc.expectOutbox(ctx, "agreement.created", "agreement.status_changed")
+}
+
+// recordMilestones walks the deal through offer, contract and closing, then
+// checks that an out-of-order milestone is refused.
+func (sc *scenario) recordMilestones(ctx context.Context) error {
+	path := "/api/agreements/" + sc.agreementID + "/events"
+	for _, typ := range []string{"OFFER_MADE", "UNDER_CONTRACT", "DEAL_CLOSED"} {
+		var ev struct {
+			Type string `json:"type"`
+			Seq  int64  `json:"seq"`
+		}
+		if err := sc.api.do(ctx, sc.candidate.token, http.MethodPost, path, map[string]any{"type": typ}, http.StatusCreated, &ev); err != nil {
+			return err
+		}
+		if ev.Type != typ || ev.Seq == 0 {
+			return fmt.Errorf("unexpected milestone %+v", ev)
+		}
+	}
+	if err := sc.expectTimeline(ctx, "AGREEMENT_CREATED", "AGREEMENT_STATUS_CHANGED",
+		"OFFER_MADE", "UNDER_CONTRACT", "DEAL_CLOSED"); err != nil {
+		return err
+	}
+	if err := sc.expectOutbox(ctx, "agreement.created", "agreement.status_changed",
+		"agreement.deal_event", "agreement.deal_event", "agreement.deal_event"); err != nil {
+		return err
+	}
+	return sc.api.do(ctx, sc.owner.token, http.MethodPost, path, map[string]any{"type": "OFFER_MADE"}, http.StatusConflict, nil)
+}
+
+func (sc *scenario) closeAgreement(ctx context.Context) error {
+	if err := sc.transition(ctx, "success", nil); err != nil {
+		return err
+	}
+	if err := sc.transition(ctx, "closed", nil); err != nil {
+		return err
+	}
+	if err := sc.expectAgreementStatus(ctx, "closed"); err != nil {
+		return err
+	}
+	if err := sc.expectTimeline(ctx, "AGREEMENT_CREATED", "AGREEMENT_STATUS_CHANGED",
+		"OFFER_MADE", "UNDER_CONTRACT", "DEAL_CLOSED",
+		"AGREEMENT_STATUS_CHANGED", "AGREEMENT_STATUS_CHANGED"); err != nil {
+		return err
+	}
+	if err := sc.expectOutbox(ctx, "agreement.created", "agreement.status_changed",
+		"agreement.deal_event", "agreement.deal_event", "agreement.deal_event",
+		"agreement.status_changed", "agreement.status_changed"); err != nil {
+		return err
+	}
+
+	// A closed agreement is terminal: reopening must be rejected.
+	etag, err := sc.agreementETag(ctx)
+	if err != nil {
+		return err
+	}
+	body := map[string]any{"agreementId": sc.agreementID, "nextStatus": "effective"}
+	return sc.api.doIfMatch(ctx, sc.owner.token, http.MethodPatch, "/api/agreements", etag, body, http.StatusBadRequest, nil)
+}
+
+func (sc *scenario) openDispute(ctx context.Context) error {
+	var created struct {
+		ID           string `json:"id"`
+		AgreementID  string `json:"agreementId"`
+		Status       string `json:"status"`
+		OpenedByRole string `json:"openedByRole"`
+	}
+	// The referee broker, owed the fee, may open a dispute as well as the
+	// referral owner.
+	body := map[string]string{"agreementId": sc.agreementID, "reason": "non_payment", "detail": "Referral fee unpaid after closing."}
+	if err := sc.api.do(ctx, sc.candidate.token, http.MethodPost, "/api/disputes", body, http.StatusCreated, &created); err != nil {
+		return err
+	}
+	if created.AgreementID != sc.agreementID || created.Status != "under_review" || created.OpenedByRole != "referee" {
+		return fmt.Errorf("unexpected dispute %+v", created)
+	}
+	sc.disputeID = created.ID
+
+	// The referral owner sees the referee's dispute.
+	var list struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodGet, "/api/disputes?agreementId="+sc.agreementID, nil, http.StatusOK, &list); err != nil {
+		return err
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != sc.disputeID {
+		return fmt.Errorf("expected the owner to see dispute %s, got %+v", sc.disputeID, list.Items)
+	}
+	return nil
+}
+
+func (sc *scenario) resolveDispute(ctx context.Context) error {
+	var resolved struct {
+		Status     string  `json:"status"`
+		ResolvedAt *string `json:"resolvedAt"`
+	}
+	path := "/api/disputes/" + sc.disputeID
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodPatch, path, map[string]string{"status": "resolved"}, http.StatusOK, &resolved); err != nil {
+		return err
+	}
+	if resolved.Status != "resolved" || resolved.ResolvedAt == nil {
+		return fmt.Errorf("unexpected resolved dispute %+v", resolved)
+	}
+
+	var list struct {
+		Items []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"items"`
+	}
+	if err := sc.api.do(ctx, sc.owner.token, http.MethodGet, "/api/disputes?agreementId="+sc.agreementID, nil, http.StatusOK, &list); err != nil {
+		return err
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != sc.disputeID || list.Items[0].Status != "resolved" {
+		return fmt.Errorf("unexpected dispute list %+v", list.Items)
+	}
+	return nil
+}
+
+func (sc *scenario) transition(ctx context.Context, next string, payload map[string]any) error {
+	body := map[string]any{"agreementId": sc.agreementID, "nextStatus": next}
+	if payload != nil {
+		body["payload"] = payload
+	}
+	etag, err := sc.agreementETag(ctx)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		NextStatus string `json:"nextStatus"`
+	}
+	if err := sc.api.doIfMatch(ctx, sc.owner.token, http.MethodPatch, "/api/agreements", etag, body, http.StatusOK, &resp); err != nil {
+		return err
+	}
+	if resp.NextStatus != next {
+		return fmt.Errorf("transition returned %s, want %s", resp.NextStatus, next)
+	}
+	return nil
+}
+
+// agreementETag reads the agreement's current version as the API's ETag.
+func (sc *scenario) agreementETag(ctx context.Context) (string, error) {
+	var version int
+	if err := sc.pool.QueryRow(ctx, `SELECT version FROM agreements WHERE id = $1`, sc.agreementID).Scan(&version); err != nil {
+		return "", fmt.Errorf("load agreement version: %w", err)
+	}
+	return `"` + strconv.Itoa(version) + `"`, nil
+}
+
+func (sc *scenario) expectReferralStatus(ctx context.Context, want string) error {
+	var got string
+	if err := sc.pool.QueryRow(ctx, `SELECT status FROM referral_requests WHERE id = $1`, sc.referralID).Scan(&got); err != nil {
+		return fmt.Errorf("load referral status: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("referral status %s, want %s", got, want)
+	}
+	return nil
+}
+
+func (sc *scenario) expectAgreementStatus(ctx context.Context, want string) error {
+	var got string
+	if err := sc.pool.QueryRow(ctx, `SELECT status::text FROM agreements WHERE id = $1`, sc.agreementID).Scan(&got); err != nil {
+		return fmt.Errorf("load agreement status: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("agreement status %s, want %s", got, want)
+	}
+	return nil
+}
+
+// expectTimeline asserts the agreement's timeline holds exactly want, in
+// sequence order.
+func (sc *scenario) expectTimeline(ctx context.Context, want ...string) error {
+	got, err := sc.strings(ctx, `
+		SELECT type::text FROM timeline_events
+		WHERE agreement_id = $1
+		ORDER BY seq
+	`, sc.agreementID)
+	if err != nil {
+		return fmt.Errorf("load timeline: %w", err)
+	}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("timeline %v, want %v", got, want)
+	}
+	return nil
+}
+
+// expectOutbox asserts the outbox messages referencing the agreement, in
+// enqueue order.
+func (sc *scenario) expectOutbox(ctx context.Context, want ...string) error {
+	got, err := sc.strings(ctx, `
+		SELECT topic FROM outbox
+		WHERE payload->>'agreement_id' = $1
+		ORDER BY created_at, id
+	`, sc.agreementID)
+	if err != nil {
+		return fmt.Errorf("load outbox: %w", err)
+	}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("outbox topics %v, want %v", got, want)
+	}
+	return nil
+}
+
+func (sc *scenario) strings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := sc.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
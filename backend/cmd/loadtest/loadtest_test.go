@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseMix_RejectsBadEntries(t *testing.T) {
+	for _, s := range []string{"", "me", "nope=1", "me=0", "me=x", "me=1,me=2"} {
+		if _, err := parseMix(s); err == nil {
+			t.Errorf("parseMix(%q): expected an error", s)
+		}
+	}
+	if _, err := parseMix(defaultMix); err != nil {
+		t.Fatalf("default mix: %v", err)
+	}
+}
+
+func TestMix_PicksInProportion(t *testing.T) {
+	m, err := parseMix("me=3, regions=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for range 4000 {
+		counts[m.pick(rnd).Name]++
+	}
+	if got := counts["me"]; got < 2800 || got > 3200 {
+		t.Fatalf("expected about 3000 picks of me, got %v", counts)
+	}
+}
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := parseSLOs("p99=1s, errors=0.5%, referrals.list:p95=150ms, me:errors=0.02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []slo{
+		{Metric: metricP99, Latency: time.Second},
+		{Metric: metricErrors, ErrorRate: 0.005},
+		{Endpoint: "referrals.list", Metric: metricP95, Latency: 150 * time.Millisecond},
+		{Endpoint: "me", Metric: metricErrors, ErrorRate: 0.02},
+	}
+	if len(slos) != len(want) {
+		t.Fatalf("got %+v", slos)
+	}
+	for i := range want {
+		if slos[i] != want[i] {
+			t.Errorf("slo %d: got %+v, want %+v", i, slos[i], want[i])
+		}
+	}
+	for _, s := range []string{"p99", "p98=1s", "nope:p99=1s", "p99=-1s", "errors=120%", "errors=x"} {
+		if _, err := parseSLOs(s); err == nil {
+			t.Errorf("parseSLOs(%q): expected an error", s)
+		}
+	}
+}
+
+func TestViolations(t *testing.T) {
+	report := Report{Endpoints: []EndpointReport{
+		{Name: "me", Requests: 100, Errors: 2, ErrorRate: 0.02, P95: millis(80 * time.Millisecond), P99: millis(300 * time.Millisecond)},
+		{Name: "referrals.list", Requests: 100, P95: millis(200 * time.Millisecond), P99: millis(400 * time.Millisecond)},
+		{Name: "regions"},
+	}}
+	slos, err := parseSLOs("p99=350ms,errors=1%,referrals.list:p95=150ms,regions:p50=1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := violations(report, slos)
+	want := []string{
+		"referrals.list p99 400ms over 350ms (100 requests)",
+		"me error rate 2% over 1% (2 of 100)",
+		"referrals.list p95 200ms over 150ms (100 requests)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunLoad_RecordsOutcomesPerEndpoint(t *testing.T) {
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/me":
+			w.WriteHeader(http.StatusOK)
+		case "/api/referrals":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL, time.Second)
+	c.token = "tok"
+	m, err := parseMix("me=2,referrals.create=1,regions=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := runLoad(context.Background(), c, m, loadConfig{RPS: 400, Warmup: 50 * time.Millisecond, Duration: 250 * time.Millisecond, MaxInFlight: 64, Seed: 7})
+
+	if got := auth.Load(); got != "Bearer tok" {
+		t.Fatalf("expected the token on every call, got %q", got)
+	}
+	var total uint64
+	for _, e := range report.Endpoints {
+		total += e.Requests
+		switch e.Name {
+		case "me", "referrals.create":
+			if e.Errors != 0 {
+				t.Errorf("%s: expected no errors, got %+v", e.Name, e)
+			}
+		case "regions":
+			if e.ErrorRate != 1 || e.Outcomes["500"] != e.Requests {
+				t.Errorf("regions: expected every call to fail with 500, got %+v", e)
+			}
+		default:
+			t.Errorf("unexpected endpoint %s", e.Name)
+		}
+		if e.P99 <= 0 || e.P50 > e.P99 {
+			t.Errorf("%s: implausible latencies %+v", e.Name, e)
+		}
+	}
+	// 100 requests are due in the measured 250ms; warmup ones are not counted
+	if total < 95 || total > 101 {
+		t.Fatalf("expected about 100 measured requests, got %d", total)
+	}
+}
+
+func TestRunLoad_DropsBeyondMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	m, err := parseMix("healthz=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		release <- struct{}{}
+	}()
+	report := runLoad(context.Background(), newClient(srv.URL, time.Second), m, loadConfig{RPS: 100, Duration: 100 * time.Millisecond, MaxInFlight: 1, Seed: 1})
+
+	if len(report.Endpoints) != 1 {
+		t.Fatalf("got %+v", report.Endpoints)
+	}
+	e := report.Endpoints[0]
+	if e.Outcomes[outcomeDropped] == 0 || e.Outcomes["200"] != 1 || e.Errors != e.Outcomes[outcomeDropped] {
+		t.Fatalf("expected one answered call and the rest dropped as errors, got %+v", e)
+	}
+}
@@ -0,0 +1,117 @@
+// Command loadtest replays a weighted mix of API calls at a target rate
+// against a running cmd/api, reports latency percentiles and error rates
+// per endpoint, and exits non-zero when a service level objective is
+// violated, so a release can be gated on it.
+//
+// The load is open loop: requests are due at fixed intervals whatever the
+// server's speed, and latency counts from when a request was due. Requests
+// that would exceed -max-inflight are dropped and count as errors.
+//
+// Calls are made as one user: -token, or -email and -password, or else a
+// freshly registered agent. Against a database loaded by cmd/seed, log in
+// as a fixture agent so the lists have rows to return.
+//
+// Usage (from backend/):
+//
+//	go run ./cmd/loadtest -url http://localhost:8080 -rps 200 -duration 2m \
+//	    -email daniel.okafor@seed.brokerflow.test -password brokerflow-demo-2024 \
+//	    -slo 'p99=500ms,errors=0.5%,referrals.list:p95=150ms'
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		baseURL     = flag.String("url", envOr("LOADTEST_URL", "http://localhost:8080"), "base URL of the server under test")
+		rps         = flag.Float64("rps", 50, "target requests per second")
+		duration    = flag.Duration("duration", time.Minute, "how long to measure")
+		warmup      = flag.Duration("warmup", 5*time.Second, "how long to send load before measuring")
+		mixFlag     = flag.String("mix", defaultMix, "endpoint=weight pairs, comma separated; endpoints: "+strings.Join(endpointNames(), ", "))
+		sloFlag     = flag.String("slo", "p99=1s,errors=1%", "[endpoint:]metric=limit objectives, comma separated; metrics: "+strings.Join(metrics, ", ")+"; without an endpoint an objective holds for every endpoint")
+		maxInFlight = flag.Int("max-inflight", 256, "requests awaiting an answer before further ones are dropped")
+		timeout     = flag.Duration("timeout", 10*time.Second, "per-request timeout")
+		seed        = flag.Int64("seed", time.Now().UnixNano(), "random seed of the endpoint picks")
+		token       = flag.String("token", os.Getenv("LOADTEST_TOKEN"), "bearer token to call with")
+		email       = flag.String("email", "", "log in as this user instead of registering one")
+		password    = flag.String("password", os.Getenv("LOADTEST_PASSWORD"), "password of -email")
+		reportPath  = flag.String("report", "", "also write the report as JSON to this file")
+	)
+	flag.Parse()
+
+	m, err := parseMix(*mixFlag)
+	if err != nil {
+		log.Fatalf("-mix: %v", err)
+	}
+	slos, err := parseSLOs(*sloFlag)
+	if err != nil {
+		log.Fatalf("-slo: %v", err)
+	}
+	if *rps <= 0 || *duration <= 0 || *maxInFlight <= 0 {
+		log.Fatalf("-rps, -duration and -max-inflight must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := newClient(*baseURL, *timeout)
+	switch {
+	case *token != "":
+		c.token = *token
+	case *email != "":
+		err = c.login(ctx, *email, *password)
+	default:
+		err = c.signUp(ctx)
+	}
+	if err != nil {
+		log.Fatalf("authenticate: %v", err)
+	}
+
+	log.Printf("loading %s at %.1f rps for %s after %s warmup (seed %d)", *baseURL, *rps, *duration, *warmup, *seed)
+	report := runLoad(ctx, c, m, loadConfig{RPS: *rps, Warmup: *warmup, Duration: *duration, MaxInFlight: *maxInFlight, Seed: *seed})
+	if ctx.Err() == nil {
+		report.Violations = violations(report, slos)
+	}
+
+	if err := writeTable(os.Stdout, report); err != nil {
+		log.Fatalf("write report: %v", err)
+	}
+	if *reportPath != "" {
+		if err := writeJSON(*reportPath, report); err != nil {
+			log.Fatalf("write report: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		log.Fatalf("❌ interrupted after %.1fs; objectives not evaluated", report.Seconds)
+	}
+	if len(report.Violations) > 0 {
+		for _, v := range report.Violations {
+			log.Printf("SLO violated: %s", v)
+		}
+		log.Fatalf("❌ %d objective(s) violated", len(report.Violations))
+	}
+	log.Printf("✅ all %d objective(s) met", len(slos))
+}
+
+func writeJSON(path string, r Report) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
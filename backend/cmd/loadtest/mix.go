@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// endpoint is one API call the load test can make.
+type endpoint struct {
+	Name   string
+	Method string
+	Path   string
+	// Body builds a fresh request body, or is nil for none.
+	Body func(rnd *rand.Rand) any
+	// Want lists the statuses that count as success.
+	Want []int
+}
+
+// endpoints is the catalog a mix picks from: the reads an agent's
+// dashboard makes and referral creation, all answerable by any agent.
+var endpoints = []endpoint{
+	{Name: "healthz", Method: http.MethodGet, Path: "/healthz", Want: []int{http.StatusOK}},
+	{Name: "me", Method: http.MethodGet, Path: "/api/me", Want: []int{http.StatusOK}},
+	{Name: "regions", Method: http.MethodGet, Path: "/api/regions", Want: []int{http.StatusOK}},
+	{Name: "referrals.list", Method: http.MethodGet, Path: "/api/referrals?page=1&pageSize=20", Want: []int{http.StatusOK}},
+	{Name: "referrals.create", Method: http.MethodPost, Path: "/api/referrals?force=true", Body: referralBody, Want: []int{http.StatusCreated}},
+	{Name: "matches.list", Method: http.MethodGet, Path: "/api/matches", Want: []int{http.StatusOK}},
+	{Name: "agreements.list", Method: http.MethodGet, Path: "/api/agreements?page=1&pageSize=20", Want: []int{http.StatusOK}},
+	{Name: "events.list", Method: http.MethodGet, Path: "/api/events?page=1&pageSize=20", Want: []int{http.StatusOK}},
+	{Name: "activity", Method: http.MethodGet, Path: "/api/me/activity", Want: []int{http.StatusOK}},
+}
+
+// defaultMix weighs reads over writes roughly as production traffic does.
+const defaultMix = "referrals.list=35,me=20,matches.list=15,agreements.list=10,events.list=10,referrals.create=5,regions=5"
+
+func referralBody(rnd *rand.Rand) any {
+	return map[string]any{
+		"region": []string{"us-tx"}, "priceMin": 300000 + rnd.Intn(1000)*100, "priceMax": 900000,
+		"propertyType": "condo", "dealType": "buy", "slaHours": 48,
+	}
+}
+
+func lookupEndpoint(name string) (endpoint, bool) {
+	i := slices.IndexFunc(endpoints, func(e endpoint) bool { return e.Name == name })
+	if i < 0 {
+		return endpoint{}, false
+	}
+	return endpoints[i], true
+}
+
+func endpointNames() []string {
+	names := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// mix picks endpoints at random in proportion to their weights.
+type mix struct {
+	endpoints []endpoint
+	// cumulative[i] is the total weight of endpoints[:i+1].
+	cumulative []int
+}
+
+// parseMix reads name=weight pairs, comma separated, such as
+// "referrals.list=3,me=1".
+func parseMix(s string) (*mix, error) {
+	m := &mix{}
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q: want endpoint=weight", part)
+		}
+		ep, ok := lookupEndpoint(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q: unknown endpoint (want one of %s)", part, strings.Join(endpointNames(), ", "))
+		}
+		if slices.ContainsFunc(m.endpoints, func(e endpoint) bool { return e.Name == ep.Name }) {
+			return nil, fmt.Errorf("mix entry %q: endpoint listed twice", part)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("mix entry %q: want a positive integer weight", part)
+		}
+		total += w
+		m.endpoints = append(m.endpoints, ep)
+		m.cumulative = append(m.cumulative, total)
+	}
+	if len(m.endpoints) == 0 {
+		return nil, fmt.Errorf("mix %q: no endpoints", s)
+	}
+	return m, nil
+}
+
+func (m *mix) pick(rnd *rand.Rand) endpoint {
+	n := rnd.Intn(m.cumulative[len(m.cumulative)-1])
+	i, _ := slices.BinarySearch(m.cumulative, n+1)
+	return m.endpoints[i]
+}
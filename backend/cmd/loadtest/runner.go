@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"brokerflow/test/actors"
+)
+
+// Outcomes recorded besides HTTP statuses.
+const (
+	outcomeTransport = "transport"
+	// outcomeDropped is a request never sent because -max-inflight requests
+	// were still waiting for an answer: the server fell behind the rate.
+	outcomeDropped = "dropped"
+)
+
+// client sends the mix's calls as one user.
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL string, timeout time.Duration) *client {
+	return &client{baseURL: strings.TrimSuffix(baseURL, "/"), http: &http.Client{Timeout: timeout}}
+}
+
+// do sends body as JSON and decodes the response into out when the status
+// is want. Load calls go through send instead.
+func (c *client) do(ctx context.Context, method, path string, body any, want int, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: read body: %w", method, path, err)
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s %s: expected %d, got %d: %s", method, path, want, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: encode body: %w", method, path, err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// call makes one load request and returns its outcome: the status, or
+// outcomeTransport when no answer came back.
+func (c *client) call(ctx context.Context, ep endpoint, body any) string {
+	resp, err := c.send(ctx, ep.Method, ep.Path, body)
+	if err != nil {
+		return outcomeTransport
+	}
+	defer resp.Body.Close()
+	// read the whole answer, as a real client would, before the clock stops
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return outcomeTransport
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
+
+// login signs in as email and authenticates later calls with the token.
+func (c *client) login(ctx context.Context, email, password string) error {
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/auth/login", map[string]string{"email": email, "password": password}, http.StatusOK, &out); err != nil {
+		return err
+	}
+	c.token = out.Token
+	return nil
+}
+
+// signUp registers a fresh agent and logs in as them.
+func (c *client) signUp(ctx context.Context) error {
+	email := fmt.Sprintf("loadtest-%d@example.com", time.Now().UnixNano())
+	const password = "Loadtest-Passw0rd!"
+	if err := c.do(ctx, http.MethodPost, "/auth/register", map[string]any{
+		"email": email, "password": password, "full_name": "Load Test Agent", "role": "agent",
+	}, http.StatusCreated, nil); err != nil {
+		return err
+	}
+	return c.login(ctx, email, password)
+}
+
+// loadConfig shapes a run.
+type loadConfig struct {
+	RPS float64
+	// Warmup runs at the target rate before Duration starts; its requests
+	// are not recorded.
+	Warmup      time.Duration
+	Duration    time.Duration
+	MaxInFlight int
+	Seed        int64
+}
+
+// runLoad sends requests picked from m at cfg.RPS, open loop: each request
+// is due at a fixed instant whether or not earlier ones were answered, and
+// its latency counts from that instant, so a slow server cannot slow the
+// test down and hide its own queueing. It stops early when ctx is done.
+func runLoad(ctx context.Context, c *client, m *mix, cfg loadConfig) Report {
+	var (
+		rnd      = rand.New(rand.NewSource(cfg.Seed))
+		rec      = newRecorder()
+		sem      = make(chan struct{}, cfg.MaxInFlight)
+		wg       sync.WaitGroup
+		interval = time.Duration(float64(time.Second) / cfg.RPS)
+		start    = time.Now()
+		measured = start.Add(cfg.Warmup)
+		end      = measured.Add(cfg.Duration)
+		timer    = time.NewTimer(0)
+	)
+	defer timer.Stop()
+dispatch:
+	for i := 0; ; i++ {
+		at := start.Add(time.Duration(i) * interval)
+		if !at.Before(end) {
+			break
+		}
+		if wait := time.Until(at); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				end = time.Now()
+				break dispatch
+			case <-timer.C:
+			}
+		}
+		ep := m.pick(rnd)
+		var body any
+		if ep.Body != nil {
+			body = ep.Body(rnd)
+		}
+		record := !at.Before(measured)
+		select {
+		case sem <- struct{}{}:
+		default:
+			if record {
+				rec.record(ep, outcomeDropped, 0)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			outcome := c.call(ctx, ep, body)
+			if record && ctx.Err() == nil {
+				rec.record(ep, outcome, time.Since(at))
+			}
+		}()
+	}
+	wg.Wait()
+	return rec.report(cfg.RPS, max(end.Sub(measured), 0))
+}
+
+// endpointStats accumulates one endpoint's outcomes.
+type endpointStats struct {
+	latency  actors.Histogram
+	requests uint64
+	errors   uint64
+	outcomes map[string]uint64
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+func newRecorder() *recorder {
+	return &recorder{endpoints: map[string]*endpointStats{}}
+}
+
+// record counts one request to ep. Answers outside ep.Want, transport
+// failures and dropped requests are errors; only answered requests have a
+// latency.
+func (r *recorder) record(ep endpoint, outcome string, latency time.Duration) {
+	r.mu.Lock()
+	s, ok := r.endpoints[ep.Name]
+	if !ok {
+		s = &endpointStats{outcomes: map[string]uint64{}}
+		r.endpoints[ep.Name] = s
+	}
+	s.requests++
+	s.outcomes[outcome]++
+	if !slices.ContainsFunc(ep.Want, func(status int) bool { return strconv.Itoa(status) == outcome }) {
+		s.errors++
+	}
+	r.mu.Unlock()
+	if outcome != outcomeDropped {
+		s.latency.Record(latency)
+	}
+}
+
+// Report is a run's result, as printed and written by -report.
+type Report struct {
+	TargetRPS   float64          `json:"targetRps"`
+	AchievedRPS float64          `json:"achievedRps"`
+	Seconds     float64          `json:"seconds"`
+	Endpoints   []EndpointReport `json:"endpoints"`
+	Violations  []string         `json:"violations,omitempty"`
+}
+
+// EndpointReport summarizes one endpoint; latencies are in milliseconds.
+type EndpointReport struct {
+	Name      string            `json:"endpoint"`
+	Requests  uint64            `json:"requests"`
+	Errors    uint64            `json:"errors"`
+	ErrorRate float64           `json:"errorRate"`
+	P50       millis            `json:"p50Ms"`
+	P95       millis            `json:"p95Ms"`
+	P99       millis            `json:"p99Ms"`
+	Max       millis            `json:"maxMs"`
+	Outcomes  map[string]uint64 `json:"outcomes"`
+}
+
+func (e EndpointReport) quantile(metric string) time.Duration {
+	switch metric {
+	case metricP50:
+		return time.Duration(e.P50)
+	case metricP95:
+		return time.Duration(e.P95)
+	default:
+		return time.Duration(e.P99)
+	}
+}
+
+// millis is a duration written to JSON as fractional milliseconds.
+type millis time.Duration
+
+func (m millis) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, float64(m)/float64(time.Millisecond), 'f', 3, 64), nil
+}
+
+func (r *recorder) report(target float64, elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := Report{TargetRPS: target, Seconds: elapsed.Seconds()}
+	var answered uint64
+	for name, s := range r.endpoints {
+		e := EndpointReport{
+			Name:     name,
+			Requests: s.requests,
+			Errors:   s.errors,
+			P50:      millis(s.latency.Quantile(0.50)),
+			P95:      millis(s.latency.Quantile(0.95)),
+			P99:      millis(s.latency.Quantile(0.99)),
+			Max:      millis(s.latency.Quantile(1)),
+			Outcomes: s.outcomes,
+		}
+		if s.requests > 0 {
+			e.ErrorRate = float64(s.errors) / float64(s.requests)
+		}
+		answered += s.requests - s.outcomes[outcomeDropped]
+		rep.Endpoints = append(rep.Endpoints, e)
+	}
+	slices.SortFunc(rep.Endpoints, func(a, b EndpointReport) int { return strings.Compare(a.Name, b.Name) })
+	if elapsed > 0 {
+		rep.AchievedRPS = float64(answered) / elapsed.Seconds()
+	}
+	return rep
+}
+
+// writeTable writes the report as an aligned table.
+func writeTable(w io.Writer, r Report) error {
+	fmt.Fprintf(w, "target %.1f rps, achieved %.1f rps over %.1fs\n", r.TargetRPS, r.AchievedRPS, r.Seconds)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\terrors\tp50\tp95\tp99\tmax\toutcomes\t")
+	for _, e := range r.Endpoints {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", e.Name, e.Requests, percent(e.ErrorRate),
+			round(e.P50), round(e.P95), round(e.P99), round(e.Max), outcomes(e.Outcomes))
+	}
+	return tw.Flush()
+}
+
+func round(m millis) time.Duration {
+	return time.Duration(m).Round(10 * time.Microsecond)
+}
+
+// outcomes writes counts as status=count in outcome order.
+func outcomes(counts map[string]uint64) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return strings.Join(parts, " ")
+}
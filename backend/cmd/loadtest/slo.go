@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SLO metrics.
+const (
+	metricP50    = "p50"
+	metricP95    = "p95"
+	metricP99    = "p99"
+	metricErrors = "errors"
+)
+
+var metrics = []string{metricP50, metricP95, metricP99, metricErrors}
+
+// slo bounds one metric of one endpoint, or of every endpoint when
+// Endpoint is empty.
+type slo struct {
+	Endpoint string
+	Metric   string
+	Latency  time.Duration
+	// ErrorRate is a fraction, 0.01 for 1%.
+	ErrorRate float64
+}
+
+// parseSLOs reads [endpoint:]metric=limit entries, comma separated, such
+// as "p99=1s,errors=1%,referrals.list:p95=200ms". Latency limits are
+// durations; error rates are percentages or fractions.
+func parseSLOs(s string) ([]slo, error) {
+	var slos []slo
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, limit, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("slo %q: want [endpoint:]metric=limit", part)
+		}
+		var o slo
+		o.Metric = strings.TrimSpace(key)
+		if name, metric, ok := strings.Cut(o.Metric, ":"); ok {
+			if _, known := lookupEndpoint(name); !known {
+				return nil, fmt.Errorf("slo %q: unknown endpoint (want one of %s)", part, strings.Join(endpointNames(), ", "))
+			}
+			o.Endpoint, o.Metric = name, metric
+		}
+		if !slices.Contains(metrics, o.Metric) {
+			return nil, fmt.Errorf("slo %q: unknown metric (want one of %s)", part, strings.Join(metrics, ", "))
+		}
+		limit = strings.TrimSpace(limit)
+		if o.Metric == metricErrors {
+			rate, err := parseRate(limit)
+			if err != nil {
+				return nil, fmt.Errorf("slo %q: %w", part, err)
+			}
+			o.ErrorRate = rate
+		} else {
+			d, err := time.ParseDuration(limit)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("slo %q: want a positive duration", part)
+			}
+			o.Latency = d
+		}
+		slos = append(slos, o)
+	}
+	return slos, nil
+}
+
+func parseRate(s string) (float64, error) {
+	pct := strings.HasSuffix(s, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if pct {
+		rate /= 100
+	}
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("want an error rate between 0 and 100%%")
+	}
+	return rate, nil
+}
+
+// percent writes rate as a percentage to two decimals at most.
+func percent(rate float64) string {
+	return strconv.FormatFloat(math.Round(rate*10000)/100, 'f', -1, 64) + "%"
+}
+
+// violations lists the SLOs the report breaks. Endpoints the run never
+// called pass.
+func violations(r Report, slos []slo) []string {
+	var out []string
+	for _, o := range slos {
+		for _, e := range r.Endpoints {
+			if o.Endpoint != "" && o.Endpoint != e.Name || e.Requests == 0 {
+				continue
+			}
+			if o.Metric == metricErrors {
+				if e.ErrorRate > o.ErrorRate {
+					out = append(out, fmt.Sprintf("%s error rate %s over %s (%d of %d)", e.Name, percent(e.ErrorRate), percent(o.ErrorRate), e.Errors, e.Requests))
+				}
+				continue
+			}
+			if got := e.quantile(o.Metric); got > o.Latency {
+				out = append(out, fmt.Sprintf("%s %s %s over %s (%d requests)", e.Name, o.Metric, got, o.Latency, e.Requests))
+			}
+		}
+	}
+	return out
+}
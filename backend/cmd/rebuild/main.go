@@ -0,0 +1,96 @@
+// Command rebuild replays timeline_events to recompute the status,
+// effective_at and event_seq of agreements and reports every agreement whose
+// stored columns disagree with its timeline. It is meant for after an
+// incident, when a write may have updated one side without the other. By
+// default nothing is written; -apply corrects the divergent rows and records
+// each correction in audit_logs. It also lists agreements whose timeline seqs
+// have gaps; the timeline is append-only, so those are only reported.
+//
+// Usage (from backend/):
+//
+//	go run ./cmd/rebuild [-agreement <id>] [-apply]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/db"
+)
+
+func main() {
+	var (
+		databaseURL = flag.String("database-url", os.Getenv("DATABASE_URL"), "connection string of the database to rebuild")
+		agreementID = flag.String("agreement", "", "replay one agreement; all agreements when empty")
+		apply       = flag.Bool("apply", false, "correct divergent rows instead of only reporting them")
+		timeout     = flag.Duration("timeout", 30*time.Minute, "overall rebuild timeout")
+	)
+	flag.Parse()
+	if *databaseURL == "" {
+		log.Fatalf("DATABASE_URL or -database-url is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	pool, err := db.NewPool(ctx, *databaseURL)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	defer pool.Close()
+
+	report, err := agreement.NewRebuilder(pool).Rebuild(ctx, agreement.RebuildParams{
+		AgreementID: *agreementID,
+		Apply:       *apply,
+	})
+	for _, d := range report.Divergences {
+		fmt.Printf("%s: %s\n", d.AgreementID, strings.Join(d.Fields, ", "))
+		fmt.Printf("  stored:   %s\n", formatProjection(d.Stored))
+		fmt.Printf("  replayed: %s\n", formatProjection(d.Replayed))
+	}
+	for _, id := range report.Skipped {
+		fmt.Printf("%s: no timeline events, skipped\n", id)
+	}
+	if err != nil {
+		log.Fatalf("rebuild stopped after %d agreements: %v", report.Checked, err)
+	}
+
+	gaps, err := agreement.NewSequenceChecker(pool).Check(ctx, *agreementID)
+	if err != nil {
+		log.Fatalf("check event seqs: %v", err)
+	}
+	for _, g := range gaps {
+		fmt.Printf("%s: seq gap, %d events up to seq %d, event_seq %d, missing %v\n", g.AgreementID, g.Events, g.MaxSeq, g.EventSeq, g.Missing)
+	}
+
+	failed := len(gaps) > 0
+	switch {
+	case len(report.Divergences) == 0:
+		log.Printf("checked %d agreements, no divergences", report.Checked)
+	case *apply:
+		log.Printf("checked %d agreements, corrected %d", report.Checked, report.Applied)
+	default:
+		log.Printf("checked %d agreements, %d diverge; rerun with -apply to correct them", report.Checked, len(report.Divergences))
+		failed = true
+	}
+	if len(gaps) > 0 {
+		log.Printf("%d agreements have timeline seq gaps", len(gaps))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatProjection(p agreement.Projection) string {
+	effectiveAt := "null"
+	if p.EffectiveAt != nil {
+		effectiveAt = p.EffectiveAt.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("status=%s effective_at=%s event_seq=%d", p.Status, effectiveAt, p.EventSeq)
+}
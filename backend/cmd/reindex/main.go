@@ -0,0 +1,62 @@
+// Command reindex rebuilds the OpenSearch documents of every referral and
+// agreement from the database. Run it once when search is first enabled,
+// and after an outage during which the outbox worker gave up on indexing
+// messages; documents are overwritten, so reruns are harmless.
+//
+// Usage (from backend/):
+//
+//	go run ./cmd/reindex [-search-url <url>] [-prefix brokerflow-]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"brokerflow/db"
+	"brokerflow/search"
+)
+
+func main() {
+	prefix := os.Getenv("SEARCH_INDEX_PREFIX")
+	if _, ok := os.LookupEnv("SEARCH_INDEX_PREFIX"); !ok {
+		prefix = "brokerflow-"
+	}
+	var (
+		databaseURL = flag.String("database-url", os.Getenv("DATABASE_URL"), "connection string of the database to index")
+		searchURL   = flag.String("search-url", os.Getenv("SEARCH_URL"), "OpenSearch cluster URL")
+		indexPrefix = flag.String("prefix", prefix, "prefix of the index names")
+		timeout     = flag.Duration("timeout", time.Hour, "overall reindex timeout")
+	)
+	flag.Parse()
+	if *databaseURL == "" {
+		log.Fatalf("DATABASE_URL or -database-url is required")
+	}
+	if *searchURL == "" {
+		log.Fatalf("SEARCH_URL or -search-url is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	pool, err := db.NewPool(ctx, *databaseURL)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	defer pool.Close()
+
+	client, err := search.NewClient(*searchURL, *indexPrefix)
+	if err != nil {
+		log.Fatalf("configure search: %v", err)
+	}
+	if err := search.EnsureIndexes(ctx, client); err != nil {
+		log.Fatalf("create indexes: %v", err)
+	}
+	n, err := search.NewIndexer(client, search.NewPGSource(pool)).Reindex(ctx)
+	if err != nil {
+		log.Fatalf("reindex stopped after %d documents: %v", n, err)
+	}
+	log.Printf("indexed %d documents", n)
+}
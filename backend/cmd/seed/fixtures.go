@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// seedNamespace derives fixture ids: the same key always yields the same
+// UUID, so reruns find the rows of earlier runs and QA can bookmark them.
+var seedNamespace = uuid.MustParse("6f1c9a52-3f0e-4d1b-9d7e-2b8f0c4e5a17")
+
+// emailDomain keeps fixture accounts apart from real ones; .test never
+// resolves, so no email reaches a person.
+const emailDomain = "seed.brokerflow.test"
+
+// fixtureID is the id of the fixture of kind with key.
+func fixtureID(kind, key string) string {
+	return uuid.NewSHA1(seedNamespace, []byte(kind+"/"+key)).String()
+}
+
+type brokerFixture struct {
+	Key      string
+	Name     string
+	FEIN     string
+	Verified bool
+}
+
+type userFixture struct {
+	Key       string
+	FullName  string
+	Role      string
+	Broker    string
+	Languages []string
+}
+
+func (u userFixture) Email() string { return u.Key + "@" + emailDomain }
+
+type referralFixture struct {
+	Key          string
+	Creator      string
+	Region       []string
+	PriceMin     int64
+	PriceMax     int64
+	PropertyType string
+	DealType     string
+	Languages    []string
+	SLAHours     int
+	Status       string
+	CancelReason string
+	Age          time.Duration
+}
+
+type matchFixture struct {
+	Referral  string
+	Candidate string
+	State     string
+	Score     float64
+}
+
+// agreementFixture is an agreement between the broker of the referral's
+// creator and the broker of Referee. Signed agreements carry both
+// signatures, an hour apart, before EffectiveAge.
+type agreementFixture struct {
+	Key          string
+	Referral     string
+	Referee      string
+	Status       string
+	FeeRate      float64
+	ProtectDays  int
+	Signed       int
+	EffectiveAge time.Duration
+}
+
+type disputeFixture struct {
+	Agreement string
+	OpenedBy  string
+	Role      string
+	Reason    string
+	Detail    string
+}
+
+type subscriptionFixture struct {
+	User      string
+	Regions   []string
+	Languages []string
+}
+
+// dataset is everything seed loads: three brokerages with an admin and an
+// agent each, referrals in every status, and agreements from draft through
+// success and dispute. Regions are codes from the regions migration.
+var dataset = struct {
+	Brokers       []brokerFixture
+	Users         []userFixture
+	Referrals     []referralFixture
+	Matches       []matchFixture
+	Agreements    []agreementFixture
+	Disputes      []disputeFixture
+	Subscriptions []subscriptionFixture
+}{
+	Brokers: []brokerFixture{
+		{Key: "harbor", Name: "Harbor Realty Group", FEIN: "90-1000001", Verified: true},
+		{Key: "lonestar", Name: "Lone Star Homes", FEIN: "90-1000002", Verified: true},
+		{Key: "pacific", Name: "Pacific Crest Realty", FEIN: "90-1000003"},
+	},
+	Users: []userFixture{
+		{Key: "maya.goldberg", FullName: "Maya Goldberg", Role: "broker_admin", Broker: "harbor", Languages: []string{"english"}},
+		{Key: "daniel.okafor", FullName: "Daniel Okafor", Role: "agent", Broker: "harbor", Languages: []string{"english"}},
+		{Key: "rosa.delgado", FullName: "Rosa Delgado", Role: "broker_admin", Broker: "lonestar", Languages: []string{"english", "spanish"}},
+		{Key: "luis.herrera", FullName: "Luis Herrera", Role: "agent", Broker: "lonestar", Languages: []string{"english", "spanish"}},
+		{Key: "grace.lin", FullName: "Grace Lin", Role: "broker_admin", Broker: "pacific", Languages: []string{"english", "mandarin"}},
+		{Key: "kenji.watanabe", FullName: "Kenji Watanabe", Role: "agent", Broker: "pacific", Languages: []string{"english", "japanese"}},
+	},
+	Referrals: []referralFixture{
+		{Key: "austin-buyer", Creator: "daniel.okafor", Region: []string{"us-tx-austin"}, PriceMin: 450000, PriceMax: 650000, PropertyType: "single_family", DealType: "buy", Languages: []string{"spanish"}, SLAHours: 48, Status: "open", Age: 2 * 24 * time.Hour},
+		{Key: "brooklyn-buyer", Creator: "luis.herrera", Region: []string{"us-ny-brooklyn"}, PriceMin: 800000, PriceMax: 1200000, PropertyType: "condo", DealType: "buy", SLAHours: 24, Status: "open", Age: 24 * time.Hour},
+		{Key: "los-angeles-seller", Creator: "daniel.okafor", Region: []string{"us-ca-los-angeles"}, PriceMin: 900000, PriceMax: 1400000, PropertyType: "single_family", DealType: "sell", SLAHours: 72, Status: "matched", Age: 10 * 24 * time.Hour},
+		{Key: "dallas-buyer", Creator: "kenji.watanabe", Region: []string{"us-tx-dallas"}, PriceMin: 300000, PriceMax: 420000, PropertyType: "townhouse", DealType: "buy", Languages: []string{"english"}, SLAHours: 48, Status: "matched", Age: 8 * 24 * time.Hour},
+		{Key: "san-francisco-buyer", Creator: "luis.herrera", Region: []string{"us-ca-san-francisco"}, PriceMin: 1100000, PriceMax: 1600000, PropertyType: "condo", DealType: "buy", SLAHours: 48, Status: "signed", Age: 30 * 24 * time.Hour},
+		{Key: "manhattan-seller", Creator: "kenji.watanabe", Region: []string{"us-ny-manhattan"}, PriceMin: 2000000, PriceMax: 2600000, PropertyType: "condo", DealType: "sell", SLAHours: 72, Status: "closed", Age: 120 * 24 * time.Hour},
+		{Key: "houston-buyer", Creator: "daniel.okafor", Region: []string{"us-tx-houston"}, PriceMin: 250000, PriceMax: 350000, PropertyType: "single_family", DealType: "buy", SLAHours: 48, Status: "disputed", Age: 90 * 24 * time.Hour},
+		{Key: "queens-renter", Creator: "luis.herrera", Region: []string{"us-ny-queens"}, PriceMin: 2500, PriceMax: 4000, PropertyType: "apartment", DealType: "rent", SLAHours: 24, Status: "cancelled", CancelReason: "client withdrew", Age: 14 * 24 * time.Hour},
+	},
+	Matches: []matchFixture{
+		{Referral: "austin-buyer", Candidate: "luis.herrera", State: "invited", Score: 0.9},
+		{Referral: "brooklyn-buyer", Candidate: "daniel.okafor", State: "applied", Score: 0.75},
+		{Referral: "los-angeles-seller", Candidate: "kenji.watanabe", State: "accepted", Score: 0.8},
+		{Referral: "dallas-buyer", Candidate: "luis.herrera", State: "accepted", Score: 0.85},
+		{Referral: "san-francisco-buyer", Candidate: "kenji.watanabe", State: "accepted", Score: 0.7},
+		{Referral: "manhattan-seller", Candidate: "daniel.okafor", State: "accepted", Score: 0.95},
+		{Referral: "houston-buyer", Candidate: "luis.herrera", State: "accepted", Score: 0.8},
+		{Referral: "houston-buyer", Candidate: "kenji.watanabe", State: "declined", Score: 0.4},
+	},
+	Agreements: []agreementFixture{
+		{Key: "los-angeles", Referral: "los-angeles-seller", Referee: "kenji.watanabe", Status: "draft", FeeRate: 25, ProtectDays: 90},
+		{Key: "dallas", Referral: "dallas-buyer", Referee: "luis.herrera", Status: "pending_signature", FeeRate: 30, ProtectDays: 120, Signed: 1},
+		{Key: "san-francisco", Referral: "san-francisco-buyer", Referee: "kenji.watanabe", Status: "effective", FeeRate: 25, ProtectDays: 180, Signed: 2, EffectiveAge: 20 * 24 * time.Hour},
+		{Key: "manhattan", Referral: "manhattan-seller", Referee: "daniel.okafor", Status: "success", FeeRate: 20, ProtectDays: 90, Signed: 2, EffectiveAge: 100 * 24 * time.Hour},
+		{Key: "houston", Referral: "houston-buyer", Referee: "luis.herrera", Status: "disputed", FeeRate: 30, ProtectDays: 90, Signed: 2, EffectiveAge: 80 * 24 * time.Hour},
+	},
+	Disputes: []disputeFixture{
+		{Agreement: "houston", OpenedBy: "luis.herrera", Role: "referee", Reason: "non_payment", Detail: "Closing was on the 3rd; the referral fee invoice is still unpaid."},
+	},
+	Subscriptions: []subscriptionFixture{
+		{User: "daniel.okafor", Regions: []string{"us-ny"}},
+		{User: "luis.herrera", Regions: []string{"us-tx"}, Languages: []string{"spanish"}},
+	},
+}
+
+// loadResult counts the rows load inserted and those left as earlier runs
+// (or testers) made them.
+type loadResult struct {
+	Inserted int
+	Existing int
+}
+
+// load inserts every dataset row that is not already present. Rows are
+// keyed by their fixture ids and never updated, so a rerun only fills in
+// what is missing. Timestamps are relative to now.
+func load(ctx context.Context, tx pgx.Tx, passwordHash string, now time.Time) (loadResult, error) {
+	var res loadResult
+	exec := func(what, sql string, args ...any) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("seed %s: %w", what, err)
+		}
+		if tag.RowsAffected() > 0 {
+			res.Inserted++
+		} else {
+			res.Existing++
+		}
+		return nil
+	}
+
+	for _, b := range dataset.Brokers {
+		if err := exec("broker "+b.Key, `
+			INSERT INTO brokers (id, name, fein, verified) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("broker", b.Key), b.Name, b.FEIN, b.Verified); err != nil {
+			return res, err
+		}
+	}
+	for _, u := range dataset.Users {
+		if err := exec("user "+u.Key, `
+			INSERT INTO users (id, email, full_name, password_hash, languages, role, broker_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("user", u.Key), u.Email(), u.FullName, passwordHash, nonNil(u.Languages), u.Role, fixtureID("broker", u.Broker)); err != nil {
+			return res, err
+		}
+	}
+	for _, r := range dataset.Referrals {
+		var cancelReason any
+		if r.CancelReason != "" {
+			cancelReason = r.CancelReason
+		}
+		created := now.Add(-r.Age)
+		if err := exec("referral "+r.Key, `
+			INSERT INTO referral_requests (id, created_by_user_id, region, price_min, price_max, property_type, deal_type,
+				languages, sla_hours, status, cancel_reason, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("referral", r.Key), fixtureID("user", r.Creator), r.Region, r.PriceMin, r.PriceMax, r.PropertyType, r.DealType,
+			nonNil(r.Languages), r.SLAHours, r.Status, cancelReason, created); err != nil {
+			return res, err
+		}
+	}
+	for _, m := range dataset.Matches {
+		if err := exec("match "+m.Referral+"/"+m.Candidate, `
+			INSERT INTO referral_matches (id, request_id, candidate_user_id, state, score)
+			VALUES ($1, $2, $3, $4::referral_match_state, $5)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("match", m.Referral+"/"+m.Candidate), fixtureID("referral", m.Referral), fixtureID("user", m.Candidate), m.State, m.Score); err != nil {
+			return res, err
+		}
+	}
+	for _, a := range dataset.Agreements {
+		r := referralByKey(a.Referral)
+		referrer, referee := userByKey(r.Creator), userByKey(a.Referee)
+		created := now.Add(-r.Age).Add(24 * time.Hour)
+		var effectiveAt, referrerSignedAt, referrerSignedBy, refereeSignedAt, refereeSignedBy any
+		if a.EffectiveAge > 0 {
+			effectiveAt = now.Add(-a.EffectiveAge)
+		}
+		if a.Signed >= 1 {
+			signed := created.Add(time.Hour)
+			if a.EffectiveAge > 0 {
+				signed = now.Add(-a.EffectiveAge).Add(-2 * time.Hour)
+			}
+			referrerSignedAt, referrerSignedBy = signed, fixtureID("user", referrer.Key)
+			if a.Signed == 2 {
+				refereeSignedAt, refereeSignedBy = signed.Add(time.Hour), fixtureID("user", referee.Key)
+			}
+		}
+		if err := exec("agreement "+a.Key, `
+			INSERT INTO agreements (id, referral_id, from_broker_id, to_broker_id, status, effective_at, fee_rate, protect_days,
+				referrer_signed_at, referrer_signed_by, referee_signed_at, referee_signed_by,
+				status_updated_at, status_updated_by, created_at)
+			VALUES ($1, $2, $3, $4, $5::agreement_status, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("agreement", a.Key), fixtureID("referral", r.Key), fixtureID("broker", referrer.Broker), fixtureID("broker", referee.Broker),
+			a.Status, effectiveAt, a.FeeRate, a.ProtectDays, referrerSignedAt, referrerSignedBy, refereeSignedAt, refereeSignedBy,
+			now.Add(-a.EffectiveAge/2), fixtureID("user", referrer.Key), created); err != nil {
+			return res, err
+		}
+	}
+	for _, d := range dataset.Disputes {
+		if err := exec("dispute "+d.Agreement, `
+			INSERT INTO disputes (id, agreement_id, status, review_deadline, opened_by_user_id, opened_by_role, reason, detail)
+			VALUES ($1, $2, 'under_review', $3, $4, $5, $6, $7)
+			ON CONFLICT (id) DO NOTHING
+		`, fixtureID("dispute", d.Agreement), fixtureID("agreement", d.Agreement), now.Add(72*time.Hour),
+			fixtureID("user", d.OpenedBy), d.Role, d.Reason, d.Detail); err != nil {
+			return res, err
+		}
+	}
+	for _, s := range dataset.Subscriptions {
+		if err := exec("marketplace subscription "+s.User, `
+			INSERT INTO marketplace_subscriptions (user_id, regions, languages) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO NOTHING
+		`, fixtureID("user", s.User), s.Regions, nonNil(s.Languages)); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func referralByKey(key string) referralFixture {
+	for _, r := range dataset.Referrals {
+		if r.Key == key {
+			return r
+		}
+	}
+	panic("seed: unknown referral fixture " + key)
+}
+
+func userByKey(key string) userFixture {
+	for _, u := range dataset.Users {
+		if u.Key == key {
+			return u
+		}
+	}
+	panic("seed: unknown user fixture " + key)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
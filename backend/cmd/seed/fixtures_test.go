@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/config"
+)
+
+func TestFixtureID_IsStable(t *testing.T) {
+	if got := fixtureID("user", "maya.goldberg"); got != fixtureID("user", "maya.goldberg") {
+		t.Fatalf("expected the same id on every call, got %s", got)
+	}
+	if fixtureID("user", "x") == fixtureID("broker", "x") {
+		t.Fatal("expected kinds to get distinct ids")
+	}
+}
+
+// TestDataset_IsConsistent checks what the schema would reject only halfway
+// through a seed: dangling keys, a second live agreement per referral, and
+// effective agreements without both signatures.
+func TestDataset_IsConsistent(t *testing.T) {
+	brokers := map[string]bool{}
+	for _, b := range dataset.Brokers {
+		brokers[b.Key] = true
+	}
+	users := map[string]bool{}
+	for _, u := range dataset.Users {
+		if !brokers[u.Broker] {
+			t.Errorf("user %s: unknown broker %s", u.Key, u.Broker)
+		}
+		users[u.Key] = true
+	}
+	referrals := map[string]bool{}
+	for _, r := range dataset.Referrals {
+		if !users[r.Creator] {
+			t.Errorf("referral %s: unknown creator %s", r.Key, r.Creator)
+		}
+		if (r.Status == "cancelled") != (r.CancelReason != "") {
+			t.Errorf("referral %s: cancel reason %q with status %s", r.Key, r.CancelReason, r.Status)
+		}
+		referrals[r.Key] = true
+	}
+	for _, m := range dataset.Matches {
+		if !referrals[m.Referral] || !users[m.Candidate] {
+			t.Errorf("match %s/%s: unknown referral or candidate", m.Referral, m.Candidate)
+		}
+	}
+	agreements := map[string]bool{}
+	live := map[string]bool{}
+	for _, a := range dataset.Agreements {
+		if !referrals[a.Referral] || !users[a.Referee] {
+			t.Errorf("agreement %s: unknown referral or referee", a.Key)
+		}
+		effective := a.Status == "effective" || a.Status == "success" || a.Status == "disputed"
+		if effective != (a.EffectiveAge > 0) || (effective && a.Signed != 2) {
+			t.Errorf("agreement %s: status %s with effective age %v and %d signatures", a.Key, a.Status, a.EffectiveAge, a.Signed)
+		}
+		if a.Status == "pending_signature" || a.Status == "effective" {
+			if live[a.Referral] {
+				t.Errorf("agreement %s: second live agreement on %s", a.Key, a.Referral)
+			}
+			live[a.Referral] = true
+		}
+		agreements[a.Key] = true
+	}
+	for _, d := range dataset.Disputes {
+		if !agreements[d.Agreement] || !users[d.OpenedBy] {
+			t.Errorf("dispute %s: unknown agreement or opener", d.Agreement)
+		}
+	}
+	for _, s := range dataset.Subscriptions {
+		if !users[s.User] || len(s.Regions) == 0 {
+			t.Errorf("subscription %s: unknown user or no regions", s.User)
+		}
+	}
+}
+
+func TestFixturePassword(t *testing.T) {
+	if pw, err := fixturePassword(config.Development, ""); err != nil || pw != devPassword {
+		t.Fatalf("expected the development password, got %q, %v", pw, err)
+	}
+	if err := auth.DefaultPasswordPolicy().Check(devPassword); err != nil {
+		t.Fatalf("development password breaks the policy: %v", err)
+	}
+	if _, err := fixturePassword(config.Staging, ""); err == nil {
+		t.Fatal("expected staging to require a password")
+	}
+	if _, err := fixturePassword(config.Staging, "short"); !errors.Is(err, auth.ErrWeakPassword) {
+		t.Fatalf("expected a weak staging password to be refused, got %v", err)
+	}
+	if pw, err := fixturePassword(config.Staging, "Qa-Seed-Accounts-7"); err != nil || pw != "Qa-Seed-Accounts-7" {
+		t.Fatalf("expected the given password, got %q, %v", pw, err)
+	}
+	if _, err := fixturePassword(config.Production, "Qa-Seed-Accounts-7"); err == nil {
+		t.Fatal("expected production to be refused")
+	}
+}
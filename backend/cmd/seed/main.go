@@ -0,0 +1,112 @@
+// Command seed loads demo and QA fixtures into a migrated database:
+// brokerages with admins and agents, referrals in every status, matches,
+// agreements from draft through success, a dispute and marketplace
+// subscriptions. Fixture ids are derived from fixed keys, so every
+// environment gets the same ids, and reruns insert only what is missing;
+// rows that already exist are left alone, edits included. Seeded rows carry
+// no timeline events or outbox messages.
+//
+// Fixture accounts are <name>@seed.brokerflow.test. In development they
+// share a well-known password; staging requires -password (or
+// SEED_PASSWORD). Production databases are refused.
+//
+// Usage (from backend/):
+//
+//	go run ./cmd/seed [-env development|staging] [-password <password>]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/config"
+	"brokerflow/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// devPassword is the password of fixture accounts in development.
+const devPassword = "brokerflow-demo-2024"
+
+func main() {
+	var (
+		databaseURL = flag.String("database-url", os.Getenv(config.EnvDatabaseURL), "connection string of the database to seed")
+		env         = flag.String("env", envOr(config.EnvAppEnv, string(config.Development)), "environment of the target database: development or staging")
+		password    = flag.String("password", os.Getenv("SEED_PASSWORD"), "password of the fixture accounts; required outside development")
+		timeout     = flag.Duration("timeout", time.Minute, "overall seed timeout")
+	)
+	flag.Parse()
+	if *databaseURL == "" {
+		log.Fatalf("DATABASE_URL or -database-url is required")
+	}
+	pw, err := fixturePassword(config.Environment(strings.ToLower(*env)), *password)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("hash password: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	pool, err := db.NewPool(ctx, *databaseURL)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	res, err := load(ctx, tx, string(hash), time.Now().UTC())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Fatalf("commit: %v", err)
+	}
+	log.Printf("seeded %d rows, %d already present", res.Inserted, res.Existing)
+	for _, u := range dataset.Users {
+		fmt.Printf("%-36s %-12s %s\n", fixtureID("user", u.Key), u.Role, u.Email())
+	}
+}
+
+// fixturePassword picks the fixture accounts' password for env. A known
+// password is fine on a developer's machine only; elsewhere it has to be
+// given and pass the registration policy.
+func fixturePassword(env config.Environment, password string) (string, error) {
+	switch env {
+	case config.Development:
+		if password == "" {
+			return devPassword, nil
+		}
+	case config.Staging:
+		if password == "" {
+			return "", fmt.Errorf("-password or SEED_PASSWORD is required in %s", env)
+		}
+	case config.Production:
+		return "", fmt.Errorf("refusing to seed a %s database", env)
+	default:
+		return "", fmt.Errorf("-env: want development or staging, got %q", env)
+	}
+	if err := auth.DefaultPasswordPolicy().Check(password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,116 @@
+// Command worker runs the background subsystems apart from the API: the
+// outbox worker, which delivers webhooks, bus messages, search updates and
+// emails, and the scheduled sweeps (protect and invitation expiry, dispute
+// escalation, status history projection, webhook delivery, referral
+// archiving and PII purging). It reads the same environment as cmd/api.
+//
+// Every instance drains the outbox alongside its peers and announces what
+// it delivered, so APIs started with BACKGROUND_IN_API=false still push
+// websocket notifications. The scheduled sweeps run on one instance at a
+// time, elected through a PostgreSQL advisory lock; when it stops or loses
+// its connection another takes over within WORKER_LEADER_CHECK.
+//
+// Migrations are left to cmd/api. The worker serves /healthz, /readyz and
+// /metrics on WORKER_PORT (default 8081).
+//
+// Usage (from backend/):
+//
+//	BACKGROUND_IN_API=false go run ./cmd/api &
+//	go run ./cmd/worker
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/background"
+	"brokerflow/clock"
+	"brokerflow/config"
+	"brokerflow/db"
+	"brokerflow/health"
+	"brokerflow/observability"
+	"brokerflow/outbox"
+)
+
+const (
+	// leaderLock names the advisory lock the scheduled sweeps run under.
+	leaderLock       = "brokerflow-scheduled-jobs"
+	readinessTimeout = 2 * time.Second
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("load configuration: %v", err)
+	}
+	pools, err := db.NewPools(ctx, cfg.Database, db.PoolConfig{})
+	if err != nil {
+		log.Fatalf("bootstrap database pool: %v", err)
+	}
+	defer pools.Close()
+	pool := pools.Primary
+	if err := db.CheckWORMGuards(ctx, pool); err != nil {
+		log.Fatalf("check write-once guards: %v", err)
+	}
+
+	clk := clock.New()
+	metrics := observability.NewMetrics()
+	metrics.RegisterPool(observability.PgxPoolStats(pool))
+	metrics.RegisterOutbox(observability.PoolOutboxStats(pool))
+
+	searchIndex, err := cfg.Background.Search.Client()
+	if err != nil {
+		log.Fatalf("configure search: %v", err)
+	}
+	// Invitations are only emailed here, never accepted.
+	invitations := background.InvitationService(pool, cfg.Background.Invitations, auth.NewRepository(pool), nil).WithClock(clk)
+	handler, err := background.OutboxHandler(pool, cfg.Background, searchIndex, invitations)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	leader := background.NewLeader(pool, leaderLock).
+		WithCheck(cfg.Worker.LeaderCheck).
+		WithClock(clk)
+	background.NewRunner(pool, cfg.Background).
+		WithClock(clk).
+		WithObserver(metrics).
+		WithOutboxHandler(outbox.Notifying(pool, handler)).
+		WithWorkerID(background.WorkerID("worker")).
+		WithLeader(leader).
+		Start(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /healthz", health.LivenessHandler())
+	mux.Handle("GET /readyz", health.ReadinessHandler(readinessTimeout, health.Check{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			var one int
+			return pool.QueryRow(ctx, "SELECT 1").Scan(&one)
+		},
+	}))
+
+	port := cfg.Worker.Port
+	srv := &http.Server{Addr: ":" + port, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("⚙️  Worker starting; probes and metrics on http://localhost:%s", port)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("server failed: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"brokerflow/auth"
+)
+
+const (
+	EnvJWTSecret            = "JWT_SECRET"
+	EnvJWTKeys              = "JWT_KEYS"
+	EnvJWTActiveKey         = "JWT_ACTIVE_KEY"
+	EnvOIDCProviders        = "OIDC_PROVIDERS"
+	EnvPasswordMinLength    = "PASSWORD_MIN_LENGTH"
+	EnvPasswordMinClasses   = "PASSWORD_MIN_CLASSES"
+	EnvPasswordDenylistFile = "PASSWORD_DENYLIST_FILE"
+	EnvPasswordBreachCheck  = "PASSWORD_BREACH_CHECK"
+	EnvPasswordBreachURL    = "PASSWORD_BREACH_URL"
+
+	// googleIssuer is the issuer of the google provider unless overridden.
+	googleIssuer = "https://accounts.google.com"
+)
+
+// JWT configures the keys tokens are signed and verified with.
+type JWT struct {
+	// Keys are JWT_KEYS (see auth.ParseKeys) plus JWT_SECRET under
+	// auth.DefaultKeyID, so tokens issued before key IDs stay valid.
+	// JWT_SECRET defaults to DevJWTSecret when JWT_KEYS is unset.
+	Keys []auth.SigningKey
+	// ActiveKey signs new tokens: JWT_ACTIVE_KEY, or else the first of Keys.
+	ActiveKey string
+}
+
+// Passwords configures what new passwords must satisfy.
+type Passwords struct {
+	// Policy requires PASSWORD_MIN_LENGTH characters (default 8) from
+	// PASSWORD_MIN_CLASSES of lowercase letters, uppercase letters, digits
+	// and symbols (default 0), and refuses the built-in common passwords and
+	// those listed one per line in PASSWORD_DENYLIST_FILE.
+	Policy auth.PasswordPolicy
+	// BreachURL is the Pwned Passwords API new passwords are looked up in:
+	// PASSWORD_BREACH_URL, defaulting to auth.DefaultHIBPURL. It is empty,
+	// and nothing is looked up, unless PASSWORD_BREACH_CHECK is true.
+	BreachURL string
+}
+
+// BreachChecker returns the lookup at BreachURL, or nil when the check is
+// off; FromEnv has checked the URL.
+func (c Passwords) BreachChecker() (auth.BreachChecker, error) {
+	if c.BreachURL == "" {
+		return nil, nil
+	}
+	return auth.NewHIBPChecker(c.BreachURL)
+}
+
+// KeySet builds the key set; FromEnv has checked that it can.
+func (j JWT) KeySet() (*auth.KeySet, error) {
+	return auth.NewKeySet(j.ActiveKey, j.Keys...)
+}
+
+func (p *parser) jwt() JWT {
+	keys, err := auth.ParseKeys(p.getenv(EnvJWTKeys), os.ReadFile)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvJWTKeys, err))
+		return JWT{}
+	}
+	secret := p.getenv(EnvJWTSecret)
+	if secret == "" && len(keys) == 0 {
+		secret = DevJWTSecret
+	}
+	hasDefault := slices.ContainsFunc(keys, func(k auth.SigningKey) bool { return k.ID == auth.DefaultKeyID })
+	if secret != "" && !hasDefault {
+		keys = append(keys, auth.NewHMACKey(auth.DefaultKeyID, []byte(secret)))
+	}
+	j := JWT{Keys: keys, ActiveKey: p.string(EnvJWTActiveKey, keys[0].ID)}
+	if _, err := j.KeySet(); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvJWTActiveKey, err))
+	}
+	return j
+}
+
+// oidcProviders reads the single sign-on providers named in OIDC_PROVIDERS,
+// e.g. "google,okta". Each name N is configured by OIDC_<N>_ISSUER (for
+// google it defaults to Google's), OIDC_<N>_CLIENT_ID,
+// OIDC_<N>_CLIENT_SECRET, OIDC_<N>_REDIRECT_URL, optional space-separated
+// OIDC_<N>_SCOPES and OIDC_<N>_ROLE_RULES (see auth.ParseRoleRules).
+func (p *parser) oidcProviders() []auth.OIDCProvider {
+	var providers []auth.OIDCProvider
+	for _, name := range p.list(EnvOIDCProviders, nil) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := auth.OIDCProvider{
+			Name:         name,
+			Issuer:       p.getenv(prefix + "ISSUER"),
+			ClientID:     p.getenv(prefix + "CLIENT_ID"),
+			ClientSecret: p.getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  p.getenv(prefix + "REDIRECT_URL"),
+		}
+		if provider.Issuer == "" && name == "google" {
+			provider.Issuer = googleIssuer
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.ClientSecret == "" || provider.RedirectURL == "" {
+			p.errs = append(p.errs, fmt.Errorf("config: %sISSUER, %[1]sCLIENT_ID, %[1]sCLIENT_SECRET and %[1]sREDIRECT_URL are required", prefix))
+			continue
+		}
+		if scopes := p.getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(scopes)
+		}
+		rules, err := auth.ParseRoleRules(p.getenv(prefix + "ROLE_RULES"))
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("config: %sROLE_RULES: %w", prefix, err))
+			continue
+		}
+		provider.RoleRules = rules
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+func (p *parser) passwords() Passwords {
+	c := Passwords{Policy: auth.DefaultPasswordPolicy()}
+	if n := p.int32(EnvPasswordMinLength); n > 0 {
+		c.Policy.MinLength = int(n)
+	}
+	if n := p.int32(EnvPasswordMinClasses); n <= 4 {
+		c.Policy.MinClasses = int(n)
+	} else {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: want 0 to 4, got %d", EnvPasswordMinClasses, n))
+	}
+	if path := p.getenv(EnvPasswordDenylistFile); path != "" {
+		extra, err := readDenylist(path)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvPasswordDenylistFile, err))
+		}
+		for word := range extra {
+			c.Policy.Denylist[word] = struct{}{}
+		}
+	}
+	if p.bool(EnvPasswordBreachCheck, false) {
+		c.BreachURL = p.string(EnvPasswordBreachURL, auth.DefaultHIBPURL)
+		if _, err := c.BreachChecker(); err != nil {
+			p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvPasswordBreachURL, err))
+		}
+	}
+	return c
+}
+
+func readDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return auth.ReadDenylist(f)
+}
@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"brokerflow/dispute"
+	"brokerflow/email"
+	"brokerflow/eventbus"
+	"brokerflow/invitation"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+	"brokerflow/search"
+)
+
+const (
+	EnvBackgroundInAPI           = "BACKGROUND_IN_API"
+	EnvOutboxWorkerEnabled       = "OUTBOX_WORKER_ENABLED"
+	EnvProtectExpiryInterval     = "PROTECT_EXPIRY_INTERVAL"
+	EnvMatchExpiryInterval       = "MATCH_EXPIRY_INTERVAL"
+	EnvDisputeEscalationInterval = "DISPUTE_ESCALATION_INTERVAL"
+	EnvStatusHistoryInterval     = "STATUS_HISTORY_INTERVAL"
+	EnvWebhookDeliveryInterval   = "WEBHOOK_DELIVERY_INTERVAL"
+	EnvReferralArchiveInterval   = "REFERRAL_ARCHIVE_INTERVAL"
+	EnvReferralArchiveAfter      = "REFERRAL_ARCHIVE_AFTER"
+	EnvPIIPurgeInterval          = "PII_PURGE_INTERVAL"
+	EnvDisputeReviewSLA          = "DISPUTE_REVIEW_SLA"
+	EnvDisputeEscalationSLA      = "DISPUTE_ESCALATION_SLA"
+	EnvDisputeEscalationTiers    = "DISPUTE_ESCALATION_TIERS"
+	EnvOutboxBus                 = "OUTBOX_BUS"
+	EnvOutboxBusURL              = "OUTBOX_BUS_URL"
+	EnvOutboxBusRoutes           = "OUTBOX_BUS_ROUTES"
+	EnvSearchURL                 = "SEARCH_URL"
+	EnvSearchIndexPrefix         = "SEARCH_INDEX_PREFIX"
+	EnvEmailSender               = "EMAIL_SENDER"
+	EnvEmailFrom                 = "EMAIL_FROM"
+	EnvSMTPAddr                  = "SMTP_ADDR"
+	EnvSMTPUsername              = "SMTP_USERNAME"
+	EnvSMTPPassword              = "SMTP_PASSWORD"
+	EnvSESRegion                 = "SES_REGION"
+	EnvAppBaseURL                = "APP_BASE_URL"
+	EnvInvitationSecret          = "INVITATION_SECRET"
+	EnvInvitationTTL             = "INVITATION_TTL"
+	EnvWorkerPort                = "WORKER_PORT"
+	EnvWorkerLeaderCheck         = "WORKER_LEADER_CHECK"
+
+	// How often each scheduled sweep runs unless configured.
+	DefaultProtectExpiryInterval     = 15 * time.Minute
+	DefaultMatchExpiryInterval       = 5 * time.Minute
+	DefaultDisputeEscalationInterval = 5 * time.Minute
+	DefaultStatusHistoryInterval     = 10 * time.Second
+	DefaultWebhookDeliveryInterval   = 10 * time.Second
+	DefaultReferralArchiveInterval   = time.Hour
+	DefaultPIIPurgeInterval          = time.Hour
+
+	// DefaultSearchIndexPrefix names the search indexes.
+	DefaultSearchIndexPrefix = "brokerflow-"
+	// DefaultAppBaseURL is the Vite dev server emails link to.
+	DefaultAppBaseURL = "http://localhost:5173"
+	// DefaultWorkerPort serves cmd/worker's probes and metrics.
+	DefaultWorkerPort = "8081"
+)
+
+// Background configures the outbox worker and the scheduled sweeps,
+// wherever they run. A zero interval disables its job, e.g. when it runs
+// elsewhere.
+type Background struct {
+	// InAPI is whether cmd/api runs the background subsystems itself. It
+	// does unless BACKGROUND_IN_API is false, when cmd/worker runs them.
+	InAPI bool
+	// Outbox drains the outbox. It is on unless OUTBOX_WORKER_ENABLED is
+	// false.
+	Outbox bool
+	// ProtectExpiry expires lapsed protect periods (PROTECT_EXPIRY_INTERVAL).
+	ProtectExpiry time.Duration
+	// MatchExpiry expires stale invitations (MATCH_EXPIRY_INTERVAL).
+	MatchExpiry time.Duration
+	// DisputeEscalation escalates disputes past their review deadline
+	// (DISPUTE_ESCALATION_INTERVAL).
+	DisputeEscalation time.Duration
+	// StatusHistory projects new timeline events into
+	// agreement_status_history (STATUS_HISTORY_INTERVAL).
+	StatusHistory time.Duration
+	// WebhookDelivery sends due partner webhook deliveries
+	// (WEBHOOK_DELIVERY_INTERVAL).
+	WebhookDelivery time.Duration
+	// ReferralArchive archives stale cancelled referrals
+	// (REFERRAL_ARCHIVE_INTERVAL) once ReferralArchiveAfter has passed.
+	ReferralArchive      time.Duration
+	ReferralArchiveAfter time.Duration
+	// PIIPurge purges client contacts of erased accounts
+	// (PII_PURGE_INTERVAL).
+	PIIPurge time.Duration
+	// DisputeSLA is the dispute review deadlines: DISPUTE_REVIEW_SLA after a
+	// dispute is opened, DISPUTE_ESCALATION_SLA per escalation tier, up to
+	// DISPUTE_ESCALATION_TIERS tiers.
+	DisputeSLA  dispute.SLA
+	Bus         Bus
+	Search      Search
+	Email       Email
+	Invitations Invitations
+	// AppBaseURL is the frontend origin linked from emails.
+	AppBaseURL string
+}
+
+// Bus configures the message bus outbox messages are forwarded to.
+type Bus struct {
+	// Kind is "nats" or "kafka" (OUTBOX_BUS); empty forwards nothing.
+	Kind string
+	// URL is the NATS server or Kafka REST Proxy (OUTBOX_BUS_URL).
+	URL string
+	// Routes map topics to subjects or Kafka topics (OUTBOX_BUS_ROUTES, see
+	// outbox.ParseRoutes).
+	Routes outbox.Routes
+}
+
+// Handler returns the handler publishing to the bus, or nil when Kind is
+// empty; FromEnv has checked that it can.
+func (b Bus) Handler() (outbox.Handler, error) {
+	if b.Kind == "" {
+		return nil, nil
+	}
+	publisher, err := eventbus.New(b.Kind, b.URL)
+	if err != nil {
+		return nil, err
+	}
+	return outbox.NewPublishHandler(publisher, b.Routes), nil
+}
+
+// Search configures the OpenSearch cluster the marketplace and its indexer
+// use.
+type Search struct {
+	// URL is the cluster (SEARCH_URL); empty keeps the marketplace on SQL.
+	URL string
+	// IndexPrefix names the indexes (SEARCH_INDEX_PREFIX).
+	IndexPrefix string
+}
+
+// Client returns the cluster's client, or nil when URL is empty; FromEnv
+// has checked the URL.
+func (s Search) Client() (*search.Client, error) {
+	if s.URL == "" {
+		return nil, nil
+	}
+	return search.NewClient(s.URL, s.IndexPrefix)
+}
+
+// Email configures transactional email.
+type Email struct {
+	// Kind is "smtp", which relays through SMTPAddr, or "ses", which calls
+	// Amazon SES in SESRegion with the standard AWS_* credentials
+	// (EMAIL_SENDER). Empty disables lifecycle emails.
+	Kind string
+	// From is the sender address (EMAIL_FROM).
+	From         string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	SESRegion    string
+	SES          email.SESCredentials
+}
+
+// Sender returns the configured sender, or nil when Kind is empty; FromEnv
+// has checked that it can.
+func (e Email) Sender() (email.Sender, error) {
+	switch e.Kind {
+	case "":
+		return nil, nil
+	case "smtp":
+		return email.NewSMTPSender(e.SMTPAddr, e.SMTPUsername, e.SMTPPassword, e.From)
+	case "ses":
+		return email.NewSESSender(e.SESRegion, e.SES, e.From)
+	default:
+		return nil, fmt.Errorf("unknown sender %q (want smtp or ses)", e.Kind)
+	}
+}
+
+// Invitations configures broker invitation links.
+type Invitations struct {
+	// Secret signs invitation tokens: INVITATION_SECRET, falling back to
+	// JWT_SECRET and then DevJWTSecret.
+	Secret string
+	// TTL is how long an invitation stays open (INVITATION_TTL).
+	TTL time.Duration
+}
+
+// Worker configures cmd/worker itself.
+type Worker struct {
+	// Port serves /healthz, /readyz and /metrics (WORKER_PORT).
+	Port string
+	// LeaderCheck is how often the lock of the scheduled sweeps is retried
+	// and held (WORKER_LEADER_CHECK); zero keeps
+	// background.DefaultLeaderCheck.
+	LeaderCheck time.Duration
+}
+
+func (p *parser) background() Background {
+	b := Background{
+		InAPI:                p.bool(EnvBackgroundInAPI, true),
+		Outbox:               p.bool(EnvOutboxWorkerEnabled, true),
+		ProtectExpiry:        p.duration(EnvProtectExpiryInterval, DefaultProtectExpiryInterval),
+		MatchExpiry:          p.duration(EnvMatchExpiryInterval, DefaultMatchExpiryInterval),
+		DisputeEscalation:    p.duration(EnvDisputeEscalationInterval, DefaultDisputeEscalationInterval),
+		StatusHistory:        p.duration(EnvStatusHistoryInterval, DefaultStatusHistoryInterval),
+		WebhookDelivery:      p.duration(EnvWebhookDeliveryInterval, DefaultWebhookDeliveryInterval),
+		ReferralArchive:      p.duration(EnvReferralArchiveInterval, DefaultReferralArchiveInterval),
+		ReferralArchiveAfter: p.duration(EnvReferralArchiveAfter, referral.DefaultArchiveAfter),
+		PIIPurge:             p.duration(EnvPIIPurgeInterval, DefaultPIIPurgeInterval),
+		Bus: Bus{
+			Kind: p.getenv(EnvOutboxBus),
+			URL:  p.getenv(EnvOutboxBusURL),
+		},
+		Search: Search{
+			URL:         p.getenv(EnvSearchURL),
+			IndexPrefix: p.string(EnvSearchIndexPrefix, DefaultSearchIndexPrefix),
+		},
+		Email: Email{
+			Kind:         p.getenv(EnvEmailSender),
+			From:         p.getenv(EnvEmailFrom),
+			SMTPAddr:     p.getenv(EnvSMTPAddr),
+			SMTPUsername: p.getenv(EnvSMTPUsername),
+			SMTPPassword: p.getenv(EnvSMTPPassword),
+			SESRegion:    p.getenv(EnvSESRegion),
+			SES: email.SESCredentials{
+				AccessKeyID:     p.getenv(EnvAWSAccessKeyID),
+				SecretAccessKey: p.getenv(EnvAWSSecretAccessKey),
+				SessionToken:    p.getenv(EnvAWSSessionToken),
+			},
+		},
+		Invitations: Invitations{
+			Secret: p.string(EnvInvitationSecret,
+				p.string(EnvJWTSecret, DevJWTSecret)),
+			TTL: p.positiveDuration(EnvInvitationTTL, invitation.DefaultTTL),
+		},
+		AppBaseURL: p.string(EnvAppBaseURL, DefaultAppBaseURL),
+	}
+
+	b.DisputeSLA = dispute.DefaultSLA()
+	b.DisputeSLA.Review = p.positiveDuration(EnvDisputeReviewSLA, b.DisputeSLA.Review)
+	b.DisputeSLA.Escalation = p.positiveDuration(EnvDisputeEscalationSLA, b.DisputeSLA.Escalation)
+	if v := p.getenv(EnvDisputeEscalationTiers); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			p.errs = append(p.errs, fmt.Errorf("config: %s: want a positive integer, got %q", EnvDisputeEscalationTiers, v))
+		} else {
+			b.DisputeSLA.MaxTier = n
+		}
+	}
+
+	if _, err := b.Search.Client(); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvSearchURL, err))
+	}
+
+	routes, err := outbox.ParseRoutes(p.getenv(EnvOutboxBusRoutes))
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvOutboxBusRoutes, err))
+	}
+	b.Bus.Routes = routes
+	if _, err := b.Bus.Handler(); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvOutboxBus, err))
+	}
+
+	if _, err := b.Email.Sender(); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: %w", EnvEmailSender, err))
+	}
+	return b
+}
+
+func (p *parser) worker() Worker {
+	w := Worker{
+		Port:        p.string(EnvWorkerPort, DefaultWorkerPort),
+		LeaderCheck: p.positiveDuration(EnvWorkerLeaderCheck, 0),
+	}
+	if n, err := strconv.Atoi(w.Port); err != nil || n < 1 || n > 65535 {
+		p.errs = append(p.errs, fmt.Errorf("config: %s: want a port number, got %q", EnvWorkerPort, w.Port))
+	}
+	return w
+}
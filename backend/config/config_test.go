@@ -0,0 +1,547 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/files"
+	"brokerflow/invitation"
+	"brokerflow/money"
+	"brokerflow/referral"
+)
+
+func envMap(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestFromEnv_Defaults(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Database != (db.PoolConfig{ConnString: DefaultDatabaseURL}) {
+		t.Fatalf("expected only the default URL, got %+v", cfg.Database)
+	}
+	if cfg.Cache != (Cache{TTL: DefaultCacheTTL}) {
+		t.Fatalf("expected caching off, got %+v", cfg.Cache)
+	}
+}
+
+func TestFromEnv_PoolSettings(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{
+		EnvDatabaseURL:             "postgres://db.internal/brokerflow",
+		EnvDBMaxConns:              "40",
+		EnvDBMinConns:              "5",
+		EnvDBMaxConnLifetime:       "30m",
+		EnvDBMaxConnLifetimeJitter: "1m",
+		EnvDBMaxConnIdleTime:       "5m",
+		EnvDBHealthCheckPeriod:     "15s",
+		EnvDBStatementCacheMode:    "cache_describe",
+		EnvDBStatementCacheSize:    "256",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := db.PoolConfig{
+		ConnString:             "postgres://db.internal/brokerflow",
+		MaxConns:               40,
+		MinConns:               5,
+		MaxConnLifetime:        30 * time.Minute,
+		MaxConnLifetimeJitter:  time.Minute,
+		MaxConnIdleTime:        5 * time.Minute,
+		HealthCheckPeriod:      15 * time.Second,
+		StatementCacheMode:     db.CacheDescribe,
+		StatementCacheCapacity: 256,
+	}
+	if cfg.Database != want {
+		t.Fatalf("got %+v, want %+v", cfg.Database, want)
+	}
+}
+
+func TestFromEnv_ReportsEveryMalformedSetting(t *testing.T) {
+	_, err := FromEnv(envMap(map[string]string{
+		EnvDBMaxConns:          "many",
+		EnvDBHealthCheckPeriod: "-1s",
+	}))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{EnvDBMaxConns, EnvDBHealthCheckPeriod} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected %s in %q", key, err)
+		}
+	}
+}
+
+func TestFromEnv_RejectsInvalidPool(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"unknown cache mode": {EnvDBStatementCacheMode: "prepare_everything"},
+		"min above max":      {EnvDBMaxConns: "2", EnvDBMinConns: "4"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); !errors.Is(err, db.ErrInvalidPoolConfig) {
+				t.Fatalf("expected ErrInvalidPoolConfig, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFromEnv_ReplicaSharesPoolSettings(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{
+		EnvDatabaseReplicaURL: "postgres://replica.internal/brokerflow",
+		EnvDBMaxConns:         "12",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := db.PoolConfig{ConnString: "postgres://replica.internal/brokerflow", MaxConns: 12}
+	if cfg.Replica != want {
+		t.Fatalf("got %+v, want %+v", cfg.Replica, want)
+	}
+}
+
+func TestFromEnv_StatementTimeouts(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{
+		EnvDatabaseReplicaURL:        "postgres://replica.internal/brokerflow",
+		EnvDBStatementTimeout:        "10s",
+		EnvDBReplicaStatementTimeout: "1m",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Database.StatementTimeout != 10*time.Second || cfg.Replica.StatementTimeout != time.Minute {
+		t.Fatalf("got primary %v, replica %v", cfg.Database.StatementTimeout, cfg.Replica.StatementTimeout)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvDatabaseReplicaURL: "postgres://replica.internal/brokerflow",
+		EnvDBStatementTimeout: "10s",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Replica.StatementTimeout != 10*time.Second {
+		t.Fatalf("expected the replica to share the primary's timeout, got %v", cfg.Replica.StatementTimeout)
+	}
+}
+
+func TestFromEnv_Cache(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{EnvCacheURL: "redis://cache.internal:6380/2", EnvCacheTTL: "90s"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Cache != (Cache{URL: "redis://cache.internal:6380/2", TTL: 90 * time.Second}) {
+		t.Fatalf("unexpected cache config %+v", cfg.Cache)
+	}
+
+	if _, err := FromEnv(envMap(map[string]string{EnvCacheURL: "memcached://cache"})); err == nil || !strings.Contains(err.Error(), EnvCacheURL) {
+		t.Fatalf("expected %s error, got %v", EnvCacheURL, err)
+	}
+}
+
+func TestFromEnv_ListCount(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ListCount != (ListCount{Mode: db.CountExact, CacheTTL: DefaultListCountCacheTTL}) {
+		t.Fatalf("expected exact counts, got %+v", cfg.ListCount)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{EnvListCountMode: "cached", EnvListCountCacheTTL: "30s"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ListCount != (ListCount{Mode: db.CountCached, CacheTTL: 30 * time.Second}) {
+		t.Fatalf("unexpected list count config %+v", cfg.ListCount)
+	}
+
+	if _, err := FromEnv(envMap(map[string]string{EnvListCountMode: "approximate"})); !errors.Is(err, db.ErrInvalidCountMode) {
+		t.Fatalf("expected ErrInvalidCountMode, got %v", err)
+	}
+}
+
+func TestFromEnv_CORSDefaultsPerEnvironment(t *testing.T) {
+	dev, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dev.Environment != Development || !dev.CORS.AllowCredentials || !dev.CORS.AllowsOrigin("http://localhost:5173") || dev.CORS.AllowsOrigin("https://evil.example") {
+		t.Fatalf("unexpected development CORS %+v", dev.CORS)
+	}
+
+	prod, err := FromEnv(envMap(map[string]string{EnvAppEnv: "Production"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prod.Environment != Production || prod.CORS.AllowCredentials || len(prod.CORS.AllowedOrigins) != 0 {
+		t.Fatalf("expected production to allow no origins by default, got %+v", prod.CORS)
+	}
+	if prod.CORS.MaxAge != DefaultCORSMaxAge || !slices.Contains(prod.CORS.AllowedHeaders, "Authorization") {
+		t.Fatalf("unexpected production CORS %+v", prod.CORS)
+	}
+}
+
+func TestFromEnv_CORSSettings(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{
+		EnvAppEnv:               "production",
+		EnvCORSAllowedOrigins:   "https://app.example.com, https://admin.example.com:8443",
+		EnvCORSAllowedMethods:   "GET,POST",
+		EnvCORSAllowedHeaders:   "Authorization",
+		EnvCORSAllowCredentials: "true",
+		EnvCORSMaxAge:           "1h",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := CORS{
+		AllowedOrigins:   []string{"https://app.example.com", "https://admin.example.com:8443"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+	if !slices.Equal(cfg.CORS.AllowedOrigins, want.AllowedOrigins) || !slices.Equal(cfg.CORS.AllowedMethods, want.AllowedMethods) ||
+		!slices.Equal(cfg.CORS.AllowedHeaders, want.AllowedHeaders) || cfg.CORS.AllowCredentials != want.AllowCredentials || cfg.CORS.MaxAge != want.MaxAge {
+		t.Fatalf("got %+v, want %+v", cfg.CORS, want)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"wildcard with credentials": {EnvCORSAllowedOrigins: "*", EnvCORSAllowCredentials: "true"},
+		"origin with a path":        {EnvCORSAllowedOrigins: "https://app.example.com/"},
+		"origin without scheme":     {EnvCORSAllowedOrigins: "app.example.com"},
+		"malformed credentials":     {EnvCORSAllowCredentials: "sometimes"},
+		"unknown environment":       {EnvAppEnv: "qa"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func keyIDs(keys []auth.SigningKey) []string {
+	var ids []string
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+	}
+	return ids
+}
+
+func TestFromEnv_JWT(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(keyIDs(cfg.JWT.Keys), []string{auth.DefaultKeyID}) || cfg.JWT.ActiveKey != auth.DefaultKeyID {
+		t.Fatalf("expected the development secret alone, got %+v", cfg.JWT)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvJWTKeys:      "2026-10=hs256:old,2026-11=hs256:new",
+		EnvJWTActiveKey: "2026-11",
+		EnvJWTSecret:    "legacy",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(keyIDs(cfg.JWT.Keys), []string{"2026-10", "2026-11", auth.DefaultKeyID}) || cfg.JWT.ActiveKey != "2026-11" {
+		t.Fatalf("expected the listed keys plus JWT_SECRET, got %+v", cfg.JWT)
+	}
+	if ks, err := cfg.JWT.KeySet(); err != nil || ks.ActiveKeyID() != "2026-11" {
+		t.Fatalf("expected a key set signing with 2026-11, got %v, %v", ks, err)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"malformed keys":     {EnvJWTKeys: "2026-10"},
+		"unknown active key": {EnvJWTKeys: "2026-10=hs256:old", EnvJWTActiveKey: "2026-12"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_Files(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{EnvJWTSecret: "jwt-secret"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (Files{Storage: FileStorageDisk, Dir: DefaultFileStorageDir, LinkSecret: "jwt-secret", LinkTTL: files.DefaultLinkTTL}); cfg.Files != want {
+		t.Fatalf("got %+v, want %+v", cfg.Files, want)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvFileStorage:        "s3",
+		EnvFileS3Bucket:       "attachments",
+		EnvFileS3Region:       "us-east-1",
+		EnvFileS3Endpoint:     "http://minio:9000",
+		EnvAWSAccessKeyID:     "AKID",
+		EnvAWSSecretAccessKey: "secret",
+		EnvFileLinkSecret:     "link-secret",
+		EnvFileLinkTTL:        "10m",
+		EnvClamdAddr:          "clamd:3310",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := cfg.Files
+	if got.Storage != FileStorageS3 || got.S3.Bucket != "attachments" || got.S3.Region != "us-east-1" || got.S3.Endpoint != "http://minio:9000" ||
+		got.S3.Credentials.AccessKeyID != "AKID" || got.LinkSecret != "link-secret" || got.LinkTTL != 10*time.Minute || got.ClamdAddr != "clamd:3310" {
+		t.Fatalf("unexpected settings %+v", got)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"unknown storage":    {EnvFileStorage: "ftp"},
+		"s3 without bucket":  {EnvFileStorage: "s3", EnvFileS3Region: "us-east-1", EnvAWSAccessKeyID: "AKID", EnvAWSSecretAccessKey: "secret"},
+		"malformed link TTL": {EnvFileLinkTTL: "soon"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_OIDC(t *testing.T) {
+	cfg, err := FromEnv(envMap(map[string]string{
+		EnvOIDCProviders:              "google, Azure-AD",
+		"OIDC_GOOGLE_CLIENT_ID":       "g-id",
+		"OIDC_GOOGLE_CLIENT_SECRET":   "g-secret",
+		"OIDC_GOOGLE_REDIRECT_URL":    "https://app.example.com/auth/oidc/google/callback",
+		"OIDC_AZURE_AD_ISSUER":        "https://login.microsoftonline.com/tenant/v2.0",
+		"OIDC_AZURE_AD_CLIENT_ID":     "a-id",
+		"OIDC_AZURE_AD_CLIENT_SECRET": "a-secret",
+		"OIDC_AZURE_AD_REDIRECT_URL":  "https://app.example.com/auth/oidc/azure-ad/callback",
+		"OIDC_AZURE_AD_SCOPES":        "email groups",
+		"OIDC_AZURE_AD_ROLE_RULES":    "@acme.com=b1:agent",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.OIDC) != 2 {
+		t.Fatalf("expected two providers, got %+v", cfg.OIDC)
+	}
+	google, azure := cfg.OIDC[0], cfg.OIDC[1]
+	if google.Name != "google" || google.Issuer != googleIssuer || google.ClientID != "g-id" || google.Scopes != nil {
+		t.Fatalf("unexpected google provider %+v", google)
+	}
+	if azure.Name != "azure-ad" || azure.ClientSecret != "a-secret" || !slices.Equal(azure.Scopes, []string{"email", "groups"}) ||
+		len(azure.RoleRules) != 1 || azure.RoleRules[0].BrokerID != "b1" {
+		t.Fatalf("unexpected azure-ad provider %+v", azure)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"missing client":      {EnvOIDCProviders: "okta", "OIDC_OKTA_ISSUER": "https://acme.okta.com"},
+		"malformed role rule": {EnvOIDCProviders: "google", "OIDC_GOOGLE_CLIENT_ID": "g", "OIDC_GOOGLE_CLIENT_SECRET": "s", "OIDC_GOOGLE_REDIRECT_URL": "https://app.example.com/cb", "OIDC_GOOGLE_ROLE_RULES": "nonsense"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_Passwords(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Passwords.Policy.MinLength != 8 || cfg.Passwords.Policy.MinClasses != 0 || cfg.Passwords.BreachURL != "" {
+		t.Fatalf("expected the default policy without a breach check, got %+v", cfg.Passwords)
+	}
+	if checker, err := cfg.Passwords.BreachChecker(); checker != nil || err != nil {
+		t.Fatalf("expected no breach checker, got %v, %v", checker, err)
+	}
+
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(denylist, []byte("# ours\nbrokerflow2026\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvPasswordMinLength:    "12",
+		EnvPasswordMinClasses:   "3",
+		EnvPasswordDenylistFile: denylist,
+		EnvPasswordBreachCheck:  "true",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	policy := cfg.Passwords.Policy
+	if _, listed := policy.Denylist["brokerflow2026"]; policy.MinLength != 12 || policy.MinClasses != 3 || !listed {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	if cfg.Passwords.BreachURL != auth.DefaultHIBPURL {
+		t.Fatalf("expected the default breach URL, got %q", cfg.Passwords.BreachURL)
+	}
+	if checker, err := cfg.Passwords.BreachChecker(); checker == nil || err != nil {
+		t.Fatalf("expected a breach checker, got %v, %v", checker, err)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"malformed length":     {EnvPasswordMinLength: "long"},
+		"too many classes":     {EnvPasswordMinClasses: "5"},
+		"missing denylist":     {EnvPasswordDenylistFile: filepath.Join(t.TempDir(), "missing.txt")},
+		"malformed breach URL": {EnvPasswordBreachCheck: "true", EnvPasswordBreachURL: "ftp://hibp"},
+		"malformed switch":     {EnvPasswordBreachCheck: "maybe"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_ErasureReferralsAndReports(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PIIRetention != auth.DefaultPIIRetention || cfg.ReferralDuplicateWindow != referral.DefaultDuplicateWindow {
+		t.Fatalf("expected the default windows, got %s and %s", cfg.PIIRetention, cfg.ReferralDuplicateWindow)
+	}
+	if cfg.Readiness != (Readiness{HeartbeatMaxAge: DefaultHeartbeatMaxAge}) {
+		t.Fatalf("expected the default readiness, got %+v", cfg.Readiness)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvPIIRetention:            "72h",
+		EnvReferralDuplicateWindow: "0",
+		EnvReportExchangeRates:     "CAD=0.73",
+		EnvOutboxHeartbeatMaxAge:   "5m",
+		EnvOutboxWorkerRequired:    "true",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PIIRetention != 72*time.Hour || cfg.ReferralDuplicateWindow != 0 {
+		t.Fatalf("unexpected windows %s and %s", cfg.PIIRetention, cfg.ReferralDuplicateWindow)
+	}
+	if cfg.Readiness != (Readiness{HeartbeatMaxAge: 5 * time.Minute, OutboxWorkerRequired: true}) {
+		t.Fatalf("unexpected readiness %+v", cfg.Readiness)
+	}
+	if _, err := cfg.ReportRates.Convert(money.New(100, money.CAD), money.USD); err != nil {
+		t.Fatalf("expected CAD to convert, got %v", err)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"malformed retention": {EnvPIIRetention: "a month"},
+		"negative window":     {EnvReferralDuplicateWindow: "-1h"},
+		"malformed rates":     {EnvReportExchangeRates: "CAD"},
+		"zero heartbeat age":  {EnvOutboxHeartbeatMaxAge: "0"},
+		"malformed required":  {EnvOutboxWorkerRequired: "sometimes"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_Background(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b := cfg.Background
+	if !b.InAPI || !b.Outbox || b.ProtectExpiry != DefaultProtectExpiryInterval || b.DisputeSLA != dispute.DefaultSLA() {
+		t.Fatalf("unexpected defaults %+v", b)
+	}
+	if b.Invitations.Secret != DevJWTSecret || b.Invitations.TTL != invitation.DefaultTTL {
+		t.Fatalf("unexpected invitation defaults %+v", b.Invitations)
+	}
+	if h, err := b.Bus.Handler(); h != nil || err != nil {
+		t.Fatalf("expected no bus when unset, got %v, %v", h, err)
+	}
+	if s, err := b.Email.Sender(); s != nil || err != nil {
+		t.Fatalf("expected no sender when unset, got %v, %v", s, err)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{
+		EnvBackgroundInAPI:        "false",
+		EnvProtectExpiryInterval:  "0",
+		EnvDisputeEscalationTiers: "5",
+		EnvOutboxBus:              "kafka",
+		EnvOutboxBusURL:           "http://kafka-rest:8082",
+		EnvOutboxBusRoutes:        "agreement.*=brokerflow.agreements",
+		EnvEmailSender:            "smtp",
+		EnvEmailFrom:              "BrokerFlow <no-reply@example.com>",
+		EnvSMTPAddr:               "smtp.example.com:587",
+		EnvJWTSecret:              "jwt-secret",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b = cfg.Background
+	if b.InAPI || b.ProtectExpiry != 0 || b.DisputeSLA.MaxTier != 5 || b.Invitations.Secret != "jwt-secret" {
+		t.Fatalf("unexpected settings %+v", b)
+	}
+	if h, err := b.Bus.Handler(); h == nil || err != nil {
+		t.Fatalf("expected kafka handler, got %v, %v", h, err)
+	}
+	if s, err := b.Email.Sender(); s == nil || err != nil {
+		t.Fatalf("expected smtp sender, got %v, %v", s, err)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"malformed interval":   {EnvProtectExpiryInterval: "garbage"},
+		"negative interval":    {EnvProtectExpiryInterval: "-1m"},
+		"malformed in api":     {EnvBackgroundInAPI: "sometimes"},
+		"zero review sla":      {EnvDisputeReviewSLA: "0"},
+		"zero tiers":           {EnvDisputeEscalationTiers: "0"},
+		"zero invitation ttl":  {EnvInvitationTTL: "0"},
+		"malformed routes":     {EnvOutboxBus: "kafka", EnvOutboxBusURL: "http://kafka-rest:8082", EnvOutboxBusRoutes: "agreement"},
+		"unknown bus":          {EnvOutboxBus: "rabbitmq"},
+		"unknown email sender": {EnvEmailSender: "sendgrid"},
+		"ses without keys":     {EnvEmailSender: "ses", EnvSESRegion: "us-east-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromEnv(envMap(env)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromEnv_Worker(t *testing.T) {
+	cfg, err := FromEnv(envMap(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Worker != (Worker{Port: DefaultWorkerPort}) {
+		t.Fatalf("unexpected defaults %+v", cfg.Worker)
+	}
+
+	cfg, err = FromEnv(envMap(map[string]string{EnvWorkerPort: "9090", EnvWorkerLeaderCheck: "30s"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Worker != (Worker{Port: "9090", LeaderCheck: 30 * time.Second}) {
+		t.Fatalf("unexpected settings %+v", cfg.Worker)
+	}
+
+	for _, env := range []map[string]string{
+		{EnvWorkerPort: "http"},
+		{EnvWorkerPort: "70000"},
+		{EnvWorkerLeaderCheck: "0"},
+	} {
+		if _, err := FromEnv(envMap(env)); err == nil {
+			t.Errorf("%v: expected an error", env)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"brokerflow/awssig"
+	"brokerflow/files"
+)
+
+const (
+	EnvFileStorage        = "FILE_STORAGE"
+	EnvFileStorageDir     = "FILE_STORAGE_DIR"
+	EnvFileS3Bucket       = "FILE_S3_BUCKET"
+	EnvFileS3Region       = "FILE_S3_REGION"
+	EnvFileS3Endpoint     = "FILE_S3_ENDPOINT"
+	EnvAWSAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	EnvAWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	EnvAWSSessionToken    = "AWS_SESSION_TOKEN"
+	EnvFileLinkSecret     = "FILE_LINK_SECRET"
+	EnvFileLinkTTL        = "FILE_LINK_TTL"
+	EnvClamdAddr          = "CLAMD_ADDR"
+
+	// DefaultFileStorageDir is where disk storage keeps attachments.
+	DefaultFileStorageDir = "data/files"
+)
+
+// FileStorage selects where attachment content is kept.
+type FileStorage string
+
+const (
+	FileStorageDisk FileStorage = "disk"
+	FileStorageS3   FileStorage = "s3"
+)
+
+// Files configures attachment storage, download links and scanning.
+type Files struct {
+	Storage FileStorage
+	// Dir is where disk storage keeps content.
+	Dir string
+	// S3 is the bucket S3 storage keeps content in, with the standard AWS_*
+	// credentials; Endpoint is set for S3-compatible stores.
+	S3 files.S3Config
+	// LinkSecret signs download links: FILE_LINK_SECRET, falling back to
+	// JWT_SECRET and then DevJWTSecret.
+	LinkSecret string
+	LinkTTL    time.Duration
+	// ClamdAddr is the clamd uploads are scanned by; empty skips scanning.
+	ClamdAddr string
+}
+
+func (p *parser) files() Files {
+	f := Files{
+		Storage:   FileStorage(p.string(EnvFileStorage, string(FileStorageDisk))),
+		Dir:       p.string(EnvFileStorageDir, DefaultFileStorageDir),
+		LinkTTL:   p.duration(EnvFileLinkTTL, 0),
+		ClamdAddr: p.getenv(EnvClamdAddr),
+		LinkSecret: p.string(EnvFileLinkSecret,
+			p.string(EnvJWTSecret, DevJWTSecret)),
+	}
+	if f.LinkTTL == 0 {
+		f.LinkTTL = files.DefaultLinkTTL
+	}
+	switch f.Storage {
+	case FileStorageDisk:
+	case FileStorageS3:
+		f.S3 = files.S3Config{
+			Bucket:   p.getenv(EnvFileS3Bucket),
+			Region:   p.getenv(EnvFileS3Region),
+			Endpoint: p.getenv(EnvFileS3Endpoint),
+			Credentials: awssig.Credentials{
+				AccessKeyID:     p.getenv(EnvAWSAccessKeyID),
+				SecretAccessKey: p.getenv(EnvAWSSecretAccessKey),
+				SessionToken:    p.getenv(EnvAWSSessionToken),
+			},
+		}
+		if _, err := files.NewS3Storage(f.S3); err != nil {
+			p.errs = append(p.errs, fmt.Errorf("config: %s, %s, %s and %s: %w", EnvFileS3Bucket, EnvFileS3Region, EnvAWSAccessKeyID, EnvAWSSecretAccessKey, err))
+		}
+	default:
+		p.errs = append(p.errs, fmt.Errorf("config: %s: want disk or s3, got %q", EnvFileStorage, f.Storage))
+	}
+	return f
+}
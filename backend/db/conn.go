@@ -2,21 +2,149 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrInvalidPoolConfig is returned for pool settings pgxpool cannot use.
+var ErrInvalidPoolConfig = errors.New("db: invalid pool config")
+
+// StatementCacheMode selects how pgx prepares and caches statements. The
+// values match the default_query_exec_mode connection string parameter.
+type StatementCacheMode string
+
+const (
+	// CacheStatement prepares each statement once per connection and reuses
+	// it. This is the pgx default.
+	CacheStatement StatementCacheMode = "cache_statement"
+	// CacheDescribe caches only the statement description, which survives
+	// schema changes that invalidate prepared statements.
+	CacheDescribe StatementCacheMode = "cache_describe"
+	// DescribeExec describes every statement before executing it.
+	DescribeExec StatementCacheMode = "describe_exec"
+	// Exec uses the extended protocol without preparing statements; use it
+	// behind PgBouncer in transaction pooling mode.
+	Exec StatementCacheMode = "exec"
+	// SimpleProtocol sends queries as text with client-side interpolation.
+	SimpleProtocol StatementCacheMode = "simple_protocol"
+)
+
+var statementCacheModes = map[StatementCacheMode]pgx.QueryExecMode{
+	CacheStatement: pgx.QueryExecModeCacheStatement,
+	CacheDescribe:  pgx.QueryExecModeCacheDescribe,
+	DescribeExec:   pgx.QueryExecModeDescribeExec,
+	Exec:           pgx.QueryExecModeExec,
+	SimpleProtocol: pgx.QueryExecModeSimpleProtocol,
+}
+
+// PoolConfig sizes and tunes the connection pool. Zero fields keep the value
+// from ConnString (e.g. pool_max_conns) or the pgxpool default.
+type PoolConfig struct {
+	ConnString string
+
+	MaxConns              int32
+	MinConns              int32
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	MaxConnIdleTime       time.Duration
+	HealthCheckPeriod     time.Duration
+
+	StatementCacheMode StatementCacheMode
+	// StatementCacheCapacity bounds the per-connection statement cache, or
+	// the description cache under CacheDescribe.
+	StatementCacheCapacity int
+
+	// StatementTimeout sets statement_timeout on every connection, so a
+	// query the client stopped waiting for cannot run on unbounded. Zero
+	// keeps the server's setting. PgBouncer must pass the startup parameter
+	// through (or list it in ignore_startup_parameters and set it per role).
+	StatementTimeout time.Duration
+}
+
 // NewPool constructs a pgx connection pool using the provided connection string.
 func NewPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
-	if connString == "" {
+	return NewPoolFromConfig(ctx, PoolConfig{ConnString: connString})
+}
+
+// NewPoolFromConfig constructs a pgx connection pool tuned by cfg.
+func NewPoolFromConfig(ctx context.Context, cfg PoolConfig) (*pgxpool.Pool, error) {
+	poolCfg, err := ParsePoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
+
+// ParsePoolConfig parses cfg.ConnString and applies the remaining settings.
+func ParsePoolConfig(cfg PoolConfig) (*pgxpool.Config, error) {
+	if cfg.ConnString == "" {
 		return nil, fmt.Errorf("db: empty connection string")
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
-	cfg, err := pgxpool.ParseConfig(connString)
+	poolCfg, err := pgxpool.ParseConfig(cfg.ConnString)
 	if err != nil {
 		return nil, fmt.Errorf("db: parse config: %w", err)
 	}
 
-	return pgxpool.NewWithConfig(ctx, cfg)
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if poolCfg.MinConns > poolCfg.MaxConns {
+		return nil, fmt.Errorf("%w: min conns %d exceed max conns %d", ErrInvalidPoolConfig, poolCfg.MinConns, poolCfg.MaxConns)
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnLifetimeJitter > 0 {
+		poolCfg.MaxConnLifetimeJitter = cfg.MaxConnLifetimeJitter
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
+	connCfg := poolCfg.ConnConfig
+	if cfg.StatementTimeout > 0 {
+		connCfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.StatementCacheMode != "" {
+		connCfg.DefaultQueryExecMode = statementCacheModes[cfg.StatementCacheMode]
+	}
+	if cfg.StatementCacheCapacity > 0 {
+		switch connCfg.DefaultQueryExecMode {
+		case pgx.QueryExecModeCacheStatement:
+			connCfg.StatementCacheCapacity = cfg.StatementCacheCapacity
+		case pgx.QueryExecModeCacheDescribe:
+			connCfg.DescriptionCacheCapacity = cfg.StatementCacheCapacity
+		}
+	}
+	return poolCfg, nil
+}
+
+func (cfg PoolConfig) validate() error {
+	switch {
+	case cfg.MaxConns < 0, cfg.MinConns < 0:
+		return fmt.Errorf("%w: connection counts must not be negative", ErrInvalidPoolConfig)
+	case cfg.MaxConnLifetime < 0, cfg.MaxConnLifetimeJitter < 0, cfg.MaxConnIdleTime < 0, cfg.HealthCheckPeriod < 0, cfg.StatementTimeout < 0:
+		return fmt.Errorf("%w: durations must not be negative", ErrInvalidPoolConfig)
+	case cfg.StatementCacheCapacity < 0:
+		return fmt.Errorf("%w: statement cache capacity must not be negative", ErrInvalidPoolConfig)
+	}
+	if _, ok := statementCacheModes[cfg.StatementCacheMode]; cfg.StatementCacheMode != "" && !ok {
+		return fmt.Errorf("%w: unknown statement cache mode %q", ErrInvalidPoolConfig, cfg.StatementCacheMode)
+	}
+	return nil
 }
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/cache"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CountMode selects how list endpoints total their rows.
+type CountMode string
+
+const (
+	// CountExact runs COUNT(*) on every request.
+	CountExact CountMode = "exact"
+	// CountEstimated reads the planner's row estimate instead: pg_class.reltuples
+	// for an unfiltered table, EXPLAIN for a filtered list.
+	CountEstimated CountMode = "estimated"
+	// CountCached runs COUNT(*) once per list and filter combination and
+	// reuses it until the cache TTL expires.
+	CountCached CountMode = "cached"
+)
+
+var ErrInvalidCountMode = errors.New("db: count mode must be exact, estimated or cached")
+
+// ParseCountMode parses s, defaulting to CountExact when it is empty.
+func ParseCountMode(s string) (CountMode, error) {
+	switch m := CountMode(s); m {
+	case "":
+		return CountExact, nil
+	case CountExact, CountEstimated, CountCached:
+		return m, nil
+	default:
+		return "", fmt.Errorf("%w, got %q", ErrInvalidCountMode, s)
+	}
+}
+
+// Page describes a page of a list beyond its rows.
+type Page struct {
+	Total int
+	// TotalExact is false when Total is an estimate or a cached count that
+	// may be stale; clients should page with HasMore instead.
+	TotalExact bool
+	// HasMore reports whether rows follow this page, whatever Total says.
+	HasMore bool
+}
+
+// RowQuerier runs single-row queries; Reader and pgx.Tx satisfy it.
+type RowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// CountQuery is the part of a list query that decides which rows it covers.
+type CountQuery struct {
+	// From is the FROM clause onward, e.g. "FROM referral_requests WHERE ...",
+	// with Args as its parameters.
+	From string
+	Args []any
+	// Table, when set, says From covers every row of Table, so an estimate
+	// can be read from pg_class without planning the query.
+	Table string
+}
+
+// Counter totals list queries in one CountMode. A nil *Counter counts
+// exactly.
+type Counter struct {
+	mode  CountMode
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCounter returns a counter for mode. CountCached falls back to exact
+// counts until WithCache provides a cache.
+func NewCounter(mode CountMode) *Counter {
+	return &Counter{mode: mode}
+}
+
+// WithCache stores cached counts in c for ttl.
+func (c *Counter) WithCache(cc cache.Cache, ttl time.Duration) *Counter {
+	c.cache = cc
+	c.ttl = ttl
+	return c
+}
+
+// Count totals the rows q covers, reporting whether the total is exact.
+func (c *Counter) Count(ctx context.Context, r RowQuerier, q CountQuery) (total int, exact bool, err error) {
+	switch {
+	case c == nil:
+	case c.mode == CountEstimated:
+		if n, ok, err := estimate(ctx, r, q); err != nil || ok {
+			return n, false, err
+		}
+	case c.mode == CountCached && c.cache != nil:
+		return c.cached(ctx, r, q)
+	}
+	n, err := countExact(ctx, r, q)
+	return n, err == nil, err
+}
+
+func countExact(ctx context.Context, r RowQuerier, q CountQuery) (int, error) {
+	var n int
+	if err := r.QueryRow(ctx, "SELECT COUNT(*) "+q.From, q.Args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("db: count: %w", err)
+	}
+	return n, nil
+}
+
+// estimate reads the planner's row estimate for q. ok is false when the
+// table has never been analyzed, which leaves no estimate to read.
+func estimate(ctx context.Context, r RowQuerier, q CountQuery) (n int, ok bool, err error) {
+	if q.Table != "" {
+		var tuples float64
+		if err := r.QueryRow(ctx, `SELECT reltuples FROM pg_class WHERE oid = $1::regclass`, q.Table).Scan(&tuples); err != nil {
+			return 0, false, fmt.Errorf("db: estimate count: %w", err)
+		}
+		if tuples < 0 {
+			return 0, false, nil
+		}
+		return int(tuples), true, nil
+	}
+
+	var plan []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	var raw []byte
+	if err := r.QueryRow(ctx, "EXPLAIN (FORMAT JSON) SELECT 1 "+q.From, q.Args...).Scan(&raw); err != nil {
+		return 0, false, fmt.Errorf("db: estimate count: %w", err)
+	}
+	if err := json.Unmarshal(raw, &plan); err != nil || len(plan) == 0 {
+		return 0, false, fmt.Errorf("db: read query plan: %w", err)
+	}
+	return int(plan[0].Plan.Rows), true, nil
+}
+
+// cached serves q's count from the cache, counting and storing it on a miss.
+// Cache failures fall back to counting; a fresh count is exact.
+func (c *Counter) cached(ctx context.Context, r RowQuerier, q CountQuery) (int, bool, error) {
+	key, err := countKey(q)
+	if err != nil {
+		n, err := countExact(ctx, r, q)
+		return n, err == nil, err
+	}
+	if n, ok, err := cache.GetJSON[int](ctx, c.cache, key); err == nil && ok {
+		return n, false, nil
+	}
+	n, err := countExact(ctx, r, q)
+	if err != nil {
+		return 0, false, err
+	}
+	_ = cache.SetJSON(ctx, c.cache, key, n, c.ttl)
+	return n, true, nil
+}
+
+func countKey(q CountQuery) (string, error) {
+	args, err := json.Marshal(q.Args)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(q.From+"\x00"), args...))
+	return "count:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Paginate trims rows, fetched with LIMIT pageSize+1 from offset, to the page
+// and totals the list through c. An estimated or stale total is raised to
+// at least the rows the page proves exist.
+func Paginate[T any](ctx context.Context, c *Counter, r RowQuerier, q CountQuery, rows []T, offset, pageSize int) ([]T, Page, error) {
+	page := Page{HasMore: len(rows) > pageSize}
+	if page.HasMore {
+		rows = rows[:pageSize]
+	}
+	total, exact, err := c.Count(ctx, r, q)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	seen := offset + len(rows)
+	if page.HasMore {
+		seen++
+	}
+	if total < seen {
+		total = seen
+	}
+	page.Total, page.TotalExact = total, exact
+	return rows, page, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/cache"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// countRows answers count queries by the statement they start with.
+type countRows struct {
+	count     int
+	reltuples float64
+	plan      string
+	queries   []string
+}
+
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error { return f(dest...) }
+
+func (c *countRows) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	c.queries = append(c.queries, sql)
+	return scanFunc(func(dest ...any) error {
+		switch {
+		case strings.HasPrefix(sql, "SELECT COUNT(*)"):
+			*dest[0].(*int) = c.count
+		case strings.Contains(sql, "pg_class"):
+			*dest[0].(*float64) = c.reltuples
+		case strings.HasPrefix(sql, "EXPLAIN"):
+			*dest[0].(*[]byte) = []byte(c.plan)
+		default:
+			return errors.New("unexpected query " + sql)
+		}
+		return nil
+	})
+}
+
+func TestParseCountMode(t *testing.T) {
+	for in, want := range map[string]CountMode{"": CountExact, "exact": CountExact, "estimated": CountEstimated, "cached": CountCached} {
+		if got, err := ParseCountMode(in); err != nil || got != want {
+			t.Fatalf("ParseCountMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseCountMode("approximate"); !errors.Is(err, ErrInvalidCountMode) {
+		t.Fatalf("expected ErrInvalidCountMode, got %v", err)
+	}
+}
+
+func TestCounter_NilCountsExactly(t *testing.T) {
+	rows := &countRows{count: 42}
+	var c *Counter
+	total, exact, err := c.Count(context.Background(), rows, CountQuery{From: "FROM referral_requests"})
+	if err != nil || total != 42 || !exact {
+		t.Fatalf("got %d, %v, %v; want 42 exact", total, exact, err)
+	}
+}
+
+func TestCounter_EstimatedUsesReltuplesForWholeTables(t *testing.T) {
+	rows := &countRows{reltuples: 1.2e6}
+	total, exact, err := NewCounter(CountEstimated).Count(context.Background(), rows, CountQuery{From: "FROM referral_requests WHERE 1=1", Table: "referral_requests"})
+	if err != nil || total != 1200000 || exact {
+		t.Fatalf("got %d, %v, %v; want an inexact 1200000", total, exact, err)
+	}
+	if len(rows.queries) != 1 || strings.Contains(rows.queries[0], "COUNT") {
+		t.Fatalf("expected only the pg_class lookup, ran %q", rows.queries)
+	}
+}
+
+func TestCounter_EstimatedPlansFilteredLists(t *testing.T) {
+	rows := &countRows{plan: `[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 3150}}]`}
+	total, exact, err := NewCounter(CountEstimated).Count(context.Background(), rows, CountQuery{From: "FROM referral_requests WHERE status=$1", Args: []any{"open"}})
+	if err != nil || total != 3150 || exact {
+		t.Fatalf("got %d, %v, %v; want an inexact 3150", total, exact, err)
+	}
+}
+
+func TestCounter_EstimatedCountsTablesNeverAnalyzed(t *testing.T) {
+	rows := &countRows{reltuples: -1, count: 7}
+	total, exact, err := NewCounter(CountEstimated).Count(context.Background(), rows, CountQuery{From: "FROM agreements", Table: "agreements"})
+	if err != nil || total != 7 || !exact {
+		t.Fatalf("got %d, %v, %v; want an exact 7", total, exact, err)
+	}
+}
+
+func TestCounter_CachedReusesCountsPerQuery(t *testing.T) {
+	ctx := context.Background()
+	rows := &countRows{count: 10}
+	c := NewCounter(CountCached).WithCache(cache.NewMemory(), time.Minute)
+	open := CountQuery{From: "FROM referral_requests WHERE status=$1", Args: []any{"open"}}
+
+	if total, exact, err := c.Count(ctx, rows, open); err != nil || total != 10 || !exact {
+		t.Fatalf("first count: got %d, %v, %v; want an exact 10", total, exact, err)
+	}
+	rows.count = 11
+	if total, exact, err := c.Count(ctx, rows, open); err != nil || total != 10 || exact {
+		t.Fatalf("second count: got %d, %v, %v; want the cached, inexact 10", total, exact, err)
+	}
+	closed := CountQuery{From: open.From, Args: []any{"closed"}}
+	if total, _, err := c.Count(ctx, rows, closed); err != nil || total != 11 {
+		t.Fatalf("other filter: got %d, %v; want a fresh 11", total, err)
+	}
+}
+
+func TestPaginate_ReportsHasMoreFromTheExtraRow(t *testing.T) {
+	rows := &countRows{count: 5}
+	items, page, err := Paginate(context.Background(), nil, rows, CountQuery{From: "FROM t"}, []int{1, 2, 3}, 0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || page != (Page{Total: 5, TotalExact: true, HasMore: true}) {
+		t.Fatalf("got %v, %+v", items, page)
+	}
+
+	items, page, err = Paginate(context.Background(), nil, rows, CountQuery{From: "FROM t"}, []int{5}, 4, 2)
+	if err != nil || len(items) != 1 || page.HasMore {
+		t.Fatalf("last page: got %v, %+v, %v", items, page, err)
+	}
+}
+
+func TestPaginate_RaisesEstimatesBelowTheRowsSeen(t *testing.T) {
+	rows := &countRows{plan: `[{"Plan": {"Plan Rows": 1}}]`}
+	_, page, err := Paginate(context.Background(), NewCounter(CountEstimated), rows, CountQuery{From: "FROM t WHERE x=$1", Args: []any{1}}, []int{1, 2, 3}, 40, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page != (Page{Total: 43, HasMore: true}) {
+		t.Fatalf("got %+v, want a total of at least 43", page)
+	}
+}
@@ -0,0 +1,485 @@
+// Package dbtest scripts the statements a repository sends to PostgreSQL,
+// so its argument binding, error mapping and scanning can be unit tested
+// without a database. A Mock stands in for the pool (it satisfies
+// db.Writer) and for the transactions it begins; tests list the statements
+// they expect, in order, with the rows or errors each answers.
+//
+//	mock := dbtest.New(t)
+//	mock.ExpectQuery("FROM brokers WHERE id = $1").WithArgs("b1").
+//		WillReturnRows(dbtest.NewRows("id", "name").AddRow("b1", "Metro Realty"))
+//
+// Statements match when the expected fragment occurs in their SQL, both with
+// runs of whitespace collapsed. Any statement out of order, and any
+// expectation left unmet when the test ends, fails the test. Batches, COPY
+// and prepared statements are not supported.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Any matches any argument in WithArgs.
+var Any = anyArg{}
+
+type anyArg struct{}
+
+// ErrUnexpected is returned for statements no expectation accounts for.
+var ErrUnexpected = errors.New("dbtest: unexpected statement")
+
+type kind string
+
+const (
+	kindBegin    kind = "Begin"
+	kindCommit   kind = "Commit"
+	kindRollback kind = "Rollback"
+	kindExec     kind = "Exec"
+	kindQuery    kind = "Query"
+)
+
+// Expectation is one scripted statement.
+type Expectation struct {
+	kind      kind
+	fragment  string
+	args      []any
+	checkArgs bool
+	rows      *Rows
+	tag       pgconn.CommandTag
+	err       error
+}
+
+// WithArgs requires the statement's arguments to equal args, compared with
+// reflect.DeepEqual; Any matches any single argument.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args, e.checkArgs = args, true
+	return e
+}
+
+// WillReturnRows answers a query with rows. A QueryRow over no rows gets
+// pgx.ErrNoRows, as from PostgreSQL.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnTag answers an Exec with a command tag such as "UPDATE 1".
+func (e *Expectation) WillReturnTag(tag string) *Expectation {
+	e.tag = pgconn.NewCommandTag(tag)
+	return e
+}
+
+// WillReturnError fails the statement with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.fragment == "" {
+		return string(e.kind)
+	}
+	return fmt.Sprintf("%s %q", e.kind, e.fragment)
+}
+
+// Mock is a scripted pool. It is safe for concurrent use, though the order
+// of concurrent statements is then up to the scheduler.
+type Mock struct {
+	t        testing.TB
+	mu       sync.Mutex
+	expected []*Expectation
+	next     int
+}
+
+var _ db.Writer = (*Mock)(nil)
+
+// New returns a Mock that fails t at cleanup unless every expectation was
+// met.
+func New(t testing.TB) *Mock {
+	m := &Mock{t: t}
+	t.Cleanup(func() {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return m
+}
+
+func (m *Mock) expect(k kind, fragment string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{kind: k, fragment: squash(fragment)}
+	m.expected = append(m.expected, e)
+	return e
+}
+
+// ExpectBegin expects a transaction to begin, on the pool or nested in one.
+func (m *Mock) ExpectBegin() *Expectation { return m.expect(kindBegin, "") }
+
+// ExpectCommit expects the open transaction to commit.
+func (m *Mock) ExpectCommit() *Expectation { return m.expect(kindCommit, "") }
+
+// ExpectRollback expects the open transaction to roll back. A Rollback
+// after Commit, as a deferred one is, needs no expectation.
+func (m *Mock) ExpectRollback() *Expectation { return m.expect(kindRollback, "") }
+
+// ExpectExec expects an Exec whose SQL contains fragment.
+func (m *Mock) ExpectExec(fragment string) *Expectation { return m.expect(kindExec, fragment) }
+
+// ExpectQuery expects a Query or QueryRow whose SQL contains fragment.
+func (m *Mock) ExpectQuery(fragment string) *Expectation { return m.expect(kindQuery, fragment) }
+
+// ExpectationsWereMet reports the first expectation no statement met.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.next < len(m.expected) {
+		return fmt.Errorf("dbtest: %d expectations unmet, the first %s", len(m.expected)-m.next, m.expected[m.next])
+	}
+	return nil
+}
+
+// take consumes the next expectation if the statement meets it.
+func (m *Mock) take(k kind, sql string, args []any) (*Expectation, error) {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sql = squash(sql)
+	if m.next >= len(m.expected) {
+		return nil, m.fail(fmt.Errorf("%w: %s %q with %v, none expected", ErrUnexpected, k, sql, args))
+	}
+	e := m.expected[m.next]
+	if e.kind != k || !strings.Contains(sql, e.fragment) {
+		return nil, m.fail(fmt.Errorf("%w: %s %q with %v, expected %s", ErrUnexpected, k, sql, args, e))
+	}
+	if e.checkArgs {
+		if err := matchArgs(e.args, args); err != nil {
+			return nil, m.fail(fmt.Errorf("%w: %s: %v", ErrUnexpected, e, err))
+		}
+	}
+	m.next++
+	return e, nil
+}
+
+func (m *Mock) fail(err error) error {
+	m.t.Helper()
+	m.t.Error(err)
+	return err
+}
+
+func matchArgs(want, got []any) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("got %d arguments %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if _, ok := want[i].(anyArg); ok {
+			continue
+		}
+		if !reflect.DeepEqual(want[i], got[i]) {
+			return fmt.Errorf("argument $%d is %#v, want %#v", i+1, got[i], want[i])
+		}
+	}
+	return nil
+}
+
+func squash(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+func (m *Mock) Begin(context.Context) (pgx.Tx, error) {
+	m.t.Helper()
+	e, err := m.take(kindBegin, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &Tx{mock: m}, nil
+}
+
+func (m *Mock) BeginTx(ctx context.Context, _ pgx.TxOptions) (pgx.Tx, error) {
+	return m.Begin(ctx)
+}
+
+func (m *Mock) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	m.t.Helper()
+	e, err := m.take(kindExec, sql, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return e.tag, e.err
+}
+
+func (m *Mock) Query(_ context.Context, sql string, args ...any) (pgx.Rows, error) {
+	m.t.Helper()
+	e, err := m.take(kindQuery, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	rows := e.rows
+	if rows == nil {
+		rows = NewRows()
+	}
+	return &cursor{rows: rows, at: -1}, nil
+}
+
+func (m *Mock) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	m.t.Helper()
+	rows, err := m.Query(ctx, sql, args...)
+	return row{rows: rows, err: err}
+}
+
+// Tx returns an open transaction on m without expecting a Begin, for
+// repository methods handed their transaction by the caller.
+func (m *Mock) Tx() *Tx {
+	return &Tx{mock: m}
+}
+
+// Tx is a transaction begun on a Mock. Its statements are checked against
+// the same script as the Mock's.
+type Tx struct {
+	mock   *Mock
+	closed bool
+}
+
+var _ pgx.Tx = (*Tx)(nil)
+
+func (t *Tx) Begin(ctx context.Context) (pgx.Tx, error) { return t.mock.Begin(ctx) }
+
+func (t *Tx) Commit(context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	e, err := t.mock.take(kindCommit, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+func (t *Tx) Rollback(context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	e, err := t.mock.take(kindRollback, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+func (t *Tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.mock.Exec(ctx, sql, args...)
+}
+
+func (t *Tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.mock.Query(ctx, sql, args...)
+}
+
+func (t *Tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.mock.QueryRow(ctx, sql, args...)
+}
+
+func (t *Tx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, t.mock.fail(fmt.Errorf("%w: CopyFrom is not supported", ErrUnexpected))
+}
+
+func (t *Tx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	panic("dbtest: SendBatch is not supported")
+}
+
+func (t *Tx) LargeObjects() pgx.LargeObjects { return pgx.LargeObjects{} }
+
+func (t *Tx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, t.mock.fail(fmt.Errorf("%w: Prepare is not supported", ErrUnexpected))
+}
+
+func (t *Tx) Conn() *pgx.Conn { return nil }
+
+// Rows are the rows a query answers.
+type Rows struct {
+	columns []string
+	values  [][]any
+	err     error
+}
+
+// NewRows starts a result with columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row, one value per column; nil is NULL.
+func (r *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("dbtest: row has %d values for %d columns", len(values), len(r.columns)))
+	}
+	r.values = append(r.values, values)
+	return r
+}
+
+// RowError makes iteration fail with err once the rows run out, as a
+// connection lost mid-result would.
+func (r *Rows) RowError(err error) *Rows {
+	r.err = err
+	return r
+}
+
+// cursor is the pgx.Rows iterating Rows.
+type cursor struct {
+	rows   *Rows
+	at     int
+	closed bool
+}
+
+func (c *cursor) Close() { c.closed = true }
+
+func (c *cursor) Err() error {
+	if c.at >= len(c.rows.values) {
+		return c.rows.err
+	}
+	return nil
+}
+
+func (c *cursor) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", len(c.rows.values)))
+}
+
+func (c *cursor) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(c.rows.columns))
+	for i, name := range c.rows.columns {
+		fields[i].Name = name
+	}
+	return fields
+}
+
+func (c *cursor) Next() bool {
+	if c.closed || c.at >= len(c.rows.values) {
+		return false
+	}
+	c.at++
+	if c.at == len(c.rows.values) {
+		c.closed = true
+		return false
+	}
+	return true
+}
+
+func (c *cursor) Scan(dest ...any) error {
+	if c.at < 0 || c.at >= len(c.rows.values) {
+		return errors.New("dbtest: Scan called without a current row")
+	}
+	values := c.rows.values[c.at]
+	if len(dest) != len(values) {
+		return fmt.Errorf("dbtest: Scan into %d destinations, row has %d columns", len(dest), len(values))
+	}
+	for i, d := range dest {
+		if err := assign(d, values[i]); err != nil {
+			return fmt.Errorf("dbtest: scan column %s: %w", c.rows.columns[i], err)
+		}
+	}
+	return nil
+}
+
+func (c *cursor) Values() ([]any, error) {
+	if c.at < 0 || c.at >= len(c.rows.values) {
+		return nil, errors.New("dbtest: Values called without a current row")
+	}
+	return c.rows.values[c.at], nil
+}
+
+func (c *cursor) RawValues() [][]byte { return nil }
+
+func (c *cursor) Conn() *pgx.Conn { return nil }
+
+// row is the pgx.Row of QueryRow: the first row, or pgx.ErrNoRows.
+type row struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// assign stores src in the pointer dest much as pgx would: sql.Scanner
+// destinations scan it themselves, NULL sets pointers, slices and maps to
+// nil, pointers are allocated for non-NULL values, and values convert
+// between types of the same basic kind (such as a string into a named
+// string type).
+func assign(dest, src any) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			dv.SetZero()
+			return nil
+		}
+		return fmt.Errorf("cannot scan NULL into %T", dest)
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	if dv.Kind() == reflect.Pointer {
+		p := reflect.New(dv.Type().Elem())
+		if err := assign(p.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(p)
+		return nil
+	}
+	if basic(sv.Kind()) == basic(dv.Kind()) && basic(sv.Kind()) != reflect.Invalid && sv.Type().ConvertibleTo(dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into %T", src, dest)
+}
+
+// basic groups kinds that convert into each other without losing meaning.
+func basic(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Int
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	case reflect.String, reflect.Bool, reflect.Slice:
+		return k
+	}
+	return reflect.Invalid
+}
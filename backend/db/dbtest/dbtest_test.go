@@ -0,0 +1,143 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// recorder is a testing.TB that keeps errors instead of failing.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper()           {}
+func (r *recorder) Cleanup(func())    {}
+func (r *recorder) Error(args ...any) { r.errs = append(r.errs, fmt.Sprint(args...)) }
+
+type status string
+
+func TestScanConvertsLikePgx(t *testing.T) {
+	mock := New(t)
+	at := time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows("status", "n", "at", "deadline", "reviewer", "name", "tags").
+		AddRow("open", int64(3), at, at, nil, nil, []string{"a"}))
+
+	var (
+		st       status
+		n        int
+		got      time.Time
+		deadline *time.Time
+		reviewer *string
+		name     sql.NullString
+		tags     []string
+	)
+	if err := mock.QueryRow(context.Background(), "SELECT 1").Scan(&st, &n, &got, &deadline, &reviewer, &name, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if st != "open" || n != 3 || !got.Equal(at) || deadline == nil || !deadline.Equal(at) || reviewer != nil || name.Valid || len(tags) != 1 {
+		t.Fatalf("scanned %q %d %s %v %v %v %v", st, n, got, deadline, reviewer, name, tags)
+	}
+}
+
+func TestScanRefusesNullIntoValues(t *testing.T) {
+	mock := New(t)
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows("name").AddRow(nil))
+	var name string
+	if err := mock.QueryRow(context.Background(), "SELECT name").Scan(&name); err == nil {
+		t.Fatal("expected NULL into a string to fail")
+	}
+}
+
+func TestQueryRowWithoutRowsIsErrNoRows(t *testing.T) {
+	mock := New(t)
+	mock.ExpectQuery("FROM brokers").WithArgs("b1")
+	var id string
+	if err := mock.QueryRow(context.Background(), "SELECT id\n  FROM brokers WHERE id = $1", "b1").Scan(&id); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected pgx.ErrNoRows, got %v", err)
+	}
+}
+
+func TestRowsIterateAndReportRowErrors(t *testing.T) {
+	mock := New(t)
+	broken := errors.New("connection lost")
+	mock.ExpectQuery("SELECT").WithArgs(Any, 10).WillReturnRows(NewRows("id").AddRow("a").AddRow("b").RowError(broken))
+	rows, err := mock.Query(context.Background(), "SELECT id", "ignored", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || !errors.Is(rows.Err(), broken) {
+		t.Fatalf("got %v, %v", ids, rows.Err())
+	}
+}
+
+func TestTransactionsFollowTheScript(t *testing.T) {
+	mock := New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("topic").WillReturnTag("INSERT 0 1")
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	tx, err := mock.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, "INSERT INTO outbox (topic) VALUES ($1)", "topic")
+	if err != nil || tag.RowsAffected() != 1 {
+		t.Fatalf("exec = %v, %v", tag, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the deferred Rollback after Commit needs no expectation
+}
+
+func TestMismatchesFailTheTest(t *testing.T) {
+	for name, run := range map[string]func(m *Mock) error{
+		"wrong statement": func(m *Mock) error {
+			m.ExpectExec("UPDATE disputes")
+			_, err := m.Exec(context.Background(), "DELETE FROM disputes")
+			return err
+		},
+		"wrong argument": func(m *Mock) error {
+			m.ExpectExec("UPDATE disputes").WithArgs("d1")
+			_, err := m.Exec(context.Background(), "UPDATE disputes", "d2")
+			return err
+		},
+		"unexpected statement": func(m *Mock) error {
+			_, err := m.Begin(context.Background())
+			return err
+		},
+		"unmet expectation": func(m *Mock) error {
+			m.ExpectCommit()
+			return m.ExpectationsWereMet()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{TB: t}
+			err := run(New(rec))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if name != "unmet expectation" && (len(rec.errs) != 1 || !errors.Is(err, ErrUnexpected)) {
+				t.Fatalf("expected one reported ErrUnexpected, got %v (reported %v)", err, rec.errs)
+			}
+		})
+	}
+}
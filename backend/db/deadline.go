@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ApplyDeadline bounds the rest of tx by ctx's deadline through a
+// transaction-local statement_timeout. pgx cancels a running query when ctx
+// expires, but that cancel request is best effort; the server-side timeout
+// makes sure PostgreSQL stops the work too. A shorter pool-wide timeout
+// (PoolConfig.StatementTimeout) is kept. It does nothing when ctx has no
+// deadline.
+func ApplyDeadline(ctx context.Context, tx pgx.Tx) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining <= 0 {
+		return fmt.Errorf("db: apply deadline: %w", context.DeadlineExceeded)
+	}
+	const q = `
+		SELECT set_config('statement_timeout',
+			CASE WHEN setting::bigint > 0 AND setting::bigint < $1::text::bigint THEN setting ELSE $1::text END, true)
+		FROM pg_settings WHERE name = 'statement_timeout'
+	`
+	if _, err := tx.Exec(ctx, q, strconv.FormatInt(remaining, 10)); err != nil {
+		return fmt.Errorf("db: apply deadline: %w", err)
+	}
+	return nil
+}
+
+// CheckContext returns ctx's error once the caller has gone away or its
+// deadline has passed. Work that runs several statements calls it between
+// them, so a cancelled request stops at the next step instead of running
+// the remaining statements only to roll them back.
+func CheckContext(ctx context.Context, step string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("db: stopped before %s: %w", step, err)
+	}
+	return nil
+}
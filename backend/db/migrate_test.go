@@ -0,0 +1,91 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"brokerflow/db"
+	"brokerflow/db/dbtest"
+)
+
+func TestMigrationVersions_SortsSQLFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_b.up.sql":      {Data: []byte("SELECT 2")},
+		"000001_a.up.sql":      {Data: []byte("SELECT 1")},
+		"embed.go":             {Data: []byte("package migrations")},
+		"nested/000003.up.sql": {Data: []byte("SELECT 3")},
+	}
+	got, err := db.MigrationVersions(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"000001_a.up.sql", "000002_b.up.sql"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestMigrations_AreEmbedded(t *testing.T) {
+	got, err := db.MigrationVersions(db.Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || got[0] != "000001_base.up.sql" {
+		t.Fatalf("expected the base migration first, got %v", got)
+	}
+}
+
+func TestMigrate_AppliesAndRecordsInOrder(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations")
+	mock.ExpectExec("CREATE TABLE a")
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs("000001_a.up.sql")
+	mock.ExpectExec("CREATE TABLE b")
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs("000002_b.up.sql")
+
+	err := db.Migrate(context.Background(), mock, fstest.MapFS{
+		"000002_b.up.sql": {Data: []byte("CREATE TABLE b ()")},
+		"000001_a.up.sql": {Data: []byte("CREATE TABLE a ()")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrate_StopsAtTheFailingMigration(t *testing.T) {
+	mock := dbtest.New(t)
+	broken := errors.New("syntax error")
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations")
+	mock.ExpectExec("CREATE TABLE a").WillReturnError(broken)
+
+	err := db.Migrate(context.Background(), mock, fstest.MapFS{
+		"000001_a.up.sql": {Data: []byte("CREATE TABLE a ()")},
+		"000002_b.up.sql": {Data: []byte("CREATE TABLE b ()")},
+	})
+	if !errors.Is(err, broken) {
+		t.Fatalf("expected the migration error wrapped, got %v", err)
+	}
+}
+
+// Migrations re-run on every boot, so dropping get_tx_timestamp() would strip
+// the column defaults of every table created by an earlier migration.
+func TestMigrations_NeverDropGetTxTimestamp(t *testing.T) {
+	names, err := db.MigrationVersions(db.Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := regexp.MustCompile(`(?i)DROP\s+FUNCTION\s+(IF\s+EXISTS\s+)?get_tx_timestamp\b`)
+	for _, name := range names {
+		data, err := fs.ReadFile(db.Migrations, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drop.Match(data) {
+			t.Errorf("%s drops get_tx_timestamp()", name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query builds a SELECT from fixed SQL fragments and bound values. Conditions
+// are written with ? placeholders, which SQL numbers $1, $2, ... in the order
+// the conditions were added, so values never reach the statement text and
+// positions cannot drift as filters come and go. Write ?? for a literal
+// question mark, such as the jsonb ? operator.
+//
+// Fragments passed to Select, From, Where and OrderBy are SQL: callers pass
+// constants or identifiers they have checked against an allow list, never
+// request input.
+type Query struct {
+	columns string
+	from    string
+	where   []string
+	args    []any
+	orderBy []string
+	limit   *int
+	offset  *int
+}
+
+// Select starts a query returning columns.
+func Select(columns ...string) *Query {
+	return &Query{columns: strings.Join(columns, ", ")}
+}
+
+// From sets the table, or tables and joins, the query reads.
+func (q *Query) From(from string) *Query {
+	q.from = from
+	return q
+}
+
+// Where adds a condition, ANDed with the others, binding args to its ?
+// placeholders in order; parenthesize conditions containing OR. A
+// placeholder count that does not match args is a programming error and
+// panics.
+func (q *Query) Where(pred string, args ...any) *Query {
+	if n := placeholders(pred); n != len(args) {
+		panic(fmt.Sprintf("db: %d placeholders in %q for %d args", n, pred, len(args)))
+	}
+	q.where = append(q.where, pred)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// OrderBy appends sort expressions, such as "created_at DESC".
+func (q *Query) OrderBy(exprs ...string) *Query {
+	q.orderBy = append(q.orderBy, exprs...)
+	return q
+}
+
+// Limit caps the rows returned; the value is bound, not spliced.
+func (q *Query) Limit(n int) *Query {
+	q.limit = &n
+	return q
+}
+
+// Offset skips the first n rows; the value is bound, not spliced.
+func (q *Query) Offset(n int) *Query {
+	q.offset = &n
+	return q
+}
+
+// SQL returns the statement and its arguments.
+func (q *Query) SQL() (string, []any) {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	b.WriteString(q.columns)
+	b.WriteString(" FROM ")
+	b.WriteString(q.from)
+	args := append([]any(nil), q.args...)
+	q.writeWhere(&b)
+	if len(q.orderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(strings.Join(q.orderBy, ", "))
+	}
+	if q.limit != nil {
+		b.WriteString(" LIMIT ?")
+		args = append(args, *q.limit)
+	}
+	if q.offset != nil {
+		b.WriteString(" OFFSET ?")
+		args = append(args, *q.offset)
+	}
+	return Numbered(b.String()), args
+}
+
+// Count returns the query's FROM and WHERE for a Counter. An unfiltered
+// query over a single table names it, letting estimated counts read
+// pg_class.
+func (q *Query) Count() CountQuery {
+	var b strings.Builder
+	b.WriteString("FROM ")
+	b.WriteString(q.from)
+	q.writeWhere(&b)
+	count := CountQuery{From: Numbered(b.String()), Args: append([]any(nil), q.args...)}
+	if len(q.where) == 0 && !strings.ContainsAny(strings.TrimSpace(q.from), " \t\n") {
+		count.Table = strings.TrimSpace(q.from)
+	}
+	return count
+}
+
+func (q *Query) writeWhere(b *strings.Builder) {
+	if len(q.where) == 0 {
+		return
+	}
+	b.WriteString(" WHERE ")
+	for i, pred := range q.where {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(pred)
+	}
+}
+
+func placeholders(pred string) int {
+	n := 0
+	for i := 0; i < len(pred); i++ {
+		if pred[i] != '?' {
+			continue
+		}
+		if i+1 < len(pred) && pred[i+1] == '?' {
+			i++
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// Numbered rewrites ? placeholders as $1, $2, ... and ?? as ?, for
+// statements Query does not build, such as INSERT ... SELECT with a scope
+// predicate spliced in.
+func Numbered(sql string) string {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(sql); i++ {
+		if sql[i] != '?' {
+			b.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == '?' {
+			b.WriteByte('?')
+			i++
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuery_NumbersPlaceholdersInOrder(t *testing.T) {
+	q := Select("id", "status").From("referral_requests").
+		Where("archived_at IS NULL").
+		Where("status = ?", "open").
+		Where("(code LIKE ? OR name LIKE ?)", "ny%", "ny%").
+		OrderBy("created_at DESC", "id").
+		Limit(21).
+		Offset(40)
+
+	sql, args := q.SQL()
+	want := "SELECT id, status FROM referral_requests WHERE archived_at IS NULL AND status = $1 AND (code LIKE $2 OR name LIKE $3) ORDER BY created_at DESC, id LIMIT $4 OFFSET $5"
+	if sql != want {
+		t.Fatalf("got  %s\nwant %s", sql, want)
+	}
+	if !reflect.DeepEqual(args, []any{"open", "ny%", "ny%", 21, 40}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	count := q.Count()
+	if count.From != "FROM referral_requests WHERE archived_at IS NULL AND status = $1 AND (code LIKE $2 OR name LIKE $3)" || len(count.Args) != 3 || count.Table != "" {
+		t.Fatalf("unexpected count %+v", count)
+	}
+}
+
+func TestQuery_LiteralQuestionMarks(t *testing.T) {
+	sql, args := Select("id").From("files").Where("metadata ?? ?", "sha256").SQL()
+	if sql != "SELECT id FROM files WHERE metadata ? $1" || len(args) != 1 {
+		t.Fatalf("got %s %v", sql, args)
+	}
+}
+
+func TestQuery_CountNamesUnfilteredTables(t *testing.T) {
+	if got := Select("id").From("agreements").Count(); got.Table != "agreements" || got.From != "FROM agreements" {
+		t.Fatalf("unexpected count %+v", got)
+	}
+	if got := Select("a.id").From("agreements a JOIN referral_requests r ON r.id = a.referral_id").Count(); got.Table != "" {
+		t.Fatalf("expected joins not to name a table, got %+v", got)
+	}
+}
+
+func TestQuery_WherePanicsOnArgMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	Select("id").From("t").Where("a = ? AND b = ?", 1)
+}
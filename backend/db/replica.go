@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reader runs queries that tolerate replication lag: lists, searches and
+// reports. *pgxpool.Pool satisfies it.
+type Reader interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+}
+
+// Writer runs statements that must reach the primary, and reads that must
+// see the caller's own writes. *pgxpool.Pool satisfies it.
+type Writer interface {
+	Reader
+	Beginner
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+var (
+	_ Reader = (*pgxpool.Pool)(nil)
+	_ Writer = (*pgxpool.Pool)(nil)
+)
+
+// Pools pairs the primary with an optional read replica.
+type Pools struct {
+	Primary *pgxpool.Pool
+	// Replica is nil when no replica is configured.
+	Replica *pgxpool.Pool
+}
+
+// NewPools opens the primary pool and, when replica.ConnString is set, the
+// replica pool. A replica that cannot be reached at boot is not fatal: the
+// pool dials lazily and reads fall back to the primary until it answers.
+func NewPools(ctx context.Context, primary, replica PoolConfig) (*Pools, error) {
+	p, err := NewPoolFromConfig(ctx, primary)
+	if err != nil {
+		return nil, err
+	}
+	pools := &Pools{Primary: p}
+	if replica.ConnString == "" {
+		return pools, nil
+	}
+	r, err := NewPoolFromConfig(ctx, replica)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("db: replica: %w", err)
+	}
+	pools.Replica = r
+	return pools, nil
+}
+
+// Writer returns the primary.
+func (p *Pools) Writer() Writer {
+	return p.Primary
+}
+
+// Reader returns the replica with fallback to the primary, or the primary
+// alone when no replica is configured. Repositories take it through their
+// WithReader for the queries that tolerate replication lag; everything
+// else, and whatever must see its own writes, stays on Writer.
+func (p *Pools) Reader() Reader {
+	if p.Replica == nil {
+		return p.Primary
+	}
+	return NewFallbackReader(p.Replica, p.Primary)
+}
+
+// Close closes both pools.
+func (p *Pools) Close() {
+	if p.Replica != nil {
+		p.Replica.Close()
+	}
+	p.Primary.Close()
+}
+
+// FallbackReader reads from a replica and retries on the primary when the
+// replica cannot be reached. Errors reported by the server, such as a
+// syntax error or no rows, are returned as they are: the primary would
+// answer the same.
+type FallbackReader struct {
+	replica Reader
+	primary Reader
+}
+
+var _ Reader = (*FallbackReader)(nil)
+
+// NewFallbackReader builds a Reader that prefers replica.
+func NewFallbackReader(replica, primary Reader) *FallbackReader {
+	return &FallbackReader{replica: replica, primary: primary}
+}
+
+func (f *FallbackReader) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := f.replica.Query(ctx, sql, args...)
+	if !shouldFallBack(ctx, err) {
+		return rows, err
+	}
+	logFallback(err)
+	return f.primary.Query(ctx, sql, args...)
+}
+
+func (f *FallbackReader) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &fallbackRow{ctx: ctx, f: f, sql: sql, args: args}
+}
+
+func (f *FallbackReader) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := f.replica.BeginTx(ctx, opts)
+	if !shouldFallBack(ctx, err) {
+		return tx, err
+	}
+	logFallback(err)
+	return f.primary.BeginTx(ctx, opts)
+}
+
+// fallbackRow defers the query to Scan, where pgx reports acquire errors.
+type fallbackRow struct {
+	ctx  context.Context
+	f    *FallbackReader
+	sql  string
+	args []any
+}
+
+func (r *fallbackRow) Scan(dest ...any) error {
+	err := r.f.replica.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if !shouldFallBack(r.ctx, err) {
+		return err
+	}
+	logFallback(err)
+	return r.f.primary.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+}
+
+// shouldFallBack reports whether err means the replica could not serve the
+// query, as opposed to the query itself failing or the caller giving up.
+func shouldFallBack(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	return !errors.As(err, &pgErr)
+}
+
+func logFallback(err error) {
+	log.Printf("db: replica unavailable, reading from primary: %v", err)
+}
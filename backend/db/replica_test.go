@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeReader struct {
+	name  string
+	err   error
+	calls int
+}
+
+type fakeRow struct {
+	name string
+	err  error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = r.name
+	return nil
+}
+
+func (f *fakeReader) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func (f *fakeReader) QueryRow(context.Context, string, ...any) pgx.Row {
+	f.calls++
+	return fakeRow{name: f.name, err: f.err}
+}
+
+func (f *fakeReader) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func TestFallbackReader_PrefersReplica(t *testing.T) {
+	replica, primary := &fakeReader{name: "replica"}, &fakeReader{name: "primary"}
+	r := NewFallbackReader(replica, primary)
+
+	var got string
+	if err := r.QueryRow(context.Background(), "SELECT 1").Scan(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "replica" || primary.calls != 0 {
+		t.Fatalf("expected the replica to answer alone, got %q with %d primary calls", got, primary.calls)
+	}
+}
+
+func TestFallbackReader_FallsBackWhenReplicaUnreachable(t *testing.T) {
+	replica := &fakeReader{err: errors.New("dial tcp: connection refused")}
+	primary := &fakeReader{name: "primary"}
+	r := NewFallbackReader(replica, primary)
+	ctx := context.Background()
+
+	var got string
+	if err := r.QueryRow(ctx, "SELECT 1").Scan(&got); err != nil || got != "primary" {
+		t.Fatalf("expected the primary to answer, got %q, %v", got, err)
+	}
+	if _, err := r.Query(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("expected Query to fall back, got %v", err)
+	}
+	if _, err := r.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+		t.Fatalf("expected BeginTx to fall back, got %v", err)
+	}
+	if primary.calls != 3 {
+		t.Fatalf("expected 3 primary calls, got %d", primary.calls)
+	}
+}
+
+func TestFallbackReader_KeepsQueryErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, tc := range map[string]struct {
+		ctx context.Context
+		err error
+	}{
+		"server error": {context.Background(), &pgconn.PgError{Code: "42P01"}},
+		"no rows":      {context.Background(), pgx.ErrNoRows},
+		"canceled":     {canceled, context.Canceled},
+	} {
+		t.Run(name, func(t *testing.T) {
+			primary := &fakeReader{name: "primary"}
+			r := NewFallbackReader(&fakeReader{err: tc.err}, primary)
+			var got string
+			if err := r.QueryRow(tc.ctx, "SELECT 1").Scan(&got); !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if primary.calls != 0 {
+				t.Fatalf("expected no fallback, got %d primary calls", primary.calls)
+			}
+		})
+	}
+}
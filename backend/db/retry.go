@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy bounds how often Retry runs a transaction and how long it
+// waits in between. The wait before attempt n+1 is drawn from
+// [d/2, d] with d = BaseDelay * 2^(n-1) capped at MaxDelay, so concurrent
+// losers of the same conflict do not collide again in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy suits request-path transactions: a conflict clears
+// within a few short waits or is reported.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 200 * time.Millisecond}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// after which PostgreSQL has rolled the transaction back and running it
+// again may succeed.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
+
+// Retry runs fn, which must run one whole transaction, until it returns nil,
+// an error IsRetryable rejects, or policy runs out of attempts. fn may run
+// more than once, so it must not keep state from an attempt that failed.
+// Inside an InTx call the transaction belongs to the caller and cannot be
+// restarted here, so fn runs once and the outermost Retry handles the
+// conflict.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	attempts := max(policy.MaxAttempts, 1)
+	delay := policy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt == attempts {
+			return fmt.Errorf("db: transaction still conflicting after %d attempts: %w", attempts, err)
+		}
+		if delay > 0 {
+			wait := delay/2 + rand.N(delay/2+1)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+			delay = min(delay*2, max(policy.MaxDelay, policy.BaseDelay))
+		}
+	}
+}
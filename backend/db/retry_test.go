@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Millisecond}
+
+func TestIsRetryable(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"serialization failure": {fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40001"}), true},
+		"deadlock":              {&pgconn.PgError{Code: "40P01"}, true},
+		"unique violation":      {&pgconn.PgError{Code: "23505"}, false},
+		"plain error":           {errors.New("boom"), false},
+	}
+	for name, tc := range cases {
+		if got := IsRetryable(tc.err); got != tc.want {
+			t.Fatalf("%s: expected %v, got %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestRetryRunsAgainAfterConflict(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), fastRetry, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), fastRetry, func(context.Context) error {
+		calls++
+		return &pgconn.PgError{Code: "40P01"}
+	})
+	if !IsRetryable(err) || calls != fastRetry.MaxAttempts {
+		t.Fatalf("expected the conflict after %d attempts, got %v after %d", fastRetry.MaxAttempts, err, calls)
+	}
+
+	calls = 0
+	boom := errors.New("boom")
+	err = Retry(context.Background(), fastRetry, func(context.Context) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Fatalf("expected other errors to stop at once, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryInsideTransactionRunsOnce(t *testing.T) {
+	calls := 0
+	err := NewUnitOfWork(&fakeBeginner{}).InTx(context.Background(), func(ctx context.Context, _ pgx.Tx) error {
+		return Retry(ctx, fastRetry, func(context.Context) error {
+			calls++
+			return &pgconn.PgError{Code: "40001"}
+		})
+	})
+	if !IsRetryable(err) || calls != 1 {
+		t.Fatalf("expected the conflict to reach the outer transaction, got %v after %d calls", err, calls)
+	}
+}
+
+func TestUnitOfWorkWithIsolation(t *testing.T) {
+	var got *fakeTx
+	err := NewUnitOfWork(&fakeBeginner{}).WithIsolation(pgx.Serializable).InTx(context.Background(), func(_ context.Context, tx pgx.Tx) error {
+		got = tx.(*fakeTx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if len(got.execs) != 1 || got.execs[0] != "SET TRANSACTION ISOLATION LEVEL serializable" {
+		t.Fatalf("expected the isolation level to be set first, got %q", got.execs)
+	}
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Beginner starts transactions. *pgxpool.Pool satisfies it.
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// TxRunner runs a unit of work inside one managed transaction: fn's error
+// rolls it back, a nil error commits it. Services take a TxRunner instead of
+// a pool so that work spanning packages (accepting a match, creating its
+// agreement, appending the timeline event and queueing the outbox message)
+// commits or fails as a whole.
+type TxRunner interface {
+	InTx(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) error) error
+}
+
+// UnitOfWork is the Beginner-backed TxRunner. The transaction travels in the
+// context handed to fn, so a nested InTx joins it instead of opening a
+// second one; only the outermost call commits. The transaction's statements
+// are bounded by the context deadline (see ApplyDeadline), and errors the
+// write-once guard triggers raise come back as *WORMError.
+type UnitOfWork struct {
+	db        Beginner
+	isolation pgx.TxIsoLevel
+}
+
+var _ TxRunner = (*UnitOfWork)(nil)
+
+// NewUnitOfWork builds a TxRunner that begins its transactions on db.
+func NewUnitOfWork(db Beginner) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// WithIsolation runs the transactions InTx begins at level instead of the
+// server default. At pgx.Serializable a conflicting transaction fails with
+// a serialization error instead of acting on stale reads; wrap the InTx call
+// in Retry to run it again.
+func (u *UnitOfWork) WithIsolation(level pgx.TxIsoLevel) *UnitOfWork {
+	u.isolation = level
+	return u
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction of the InTx call ctx descends from.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+func (u *UnitOfWork) InTx(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return fn(ctx, tx)
+	}
+
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if u.isolation != "" {
+		// SET TRANSACTION must precede every other statement.
+		if _, err := tx.Exec(ctx, "SET TRANSACTION ISOLATION LEVEL "+string(u.isolation)); err != nil {
+			return fmt.Errorf("db: set isolation level: %w", err)
+		}
+	}
+	if err := ApplyDeadline(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx), tx); err != nil {
+		return WORMViolation(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("db: commit tx: %w", err)
+	}
+	return nil
+}
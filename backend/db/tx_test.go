@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeBeginner struct {
+	begins    int
+	commits   int
+	rollbacks int
+	err       error
+}
+
+func (b *fakeBeginner) Begin(context.Context) (pgx.Tx, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.begins++
+	return &fakeTx{owner: b}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	owner *fakeBeginner
+	done  bool
+	execs []string
+	args  [][]any
+}
+
+func (t *fakeTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	t.execs = append(t.execs, sql)
+	t.args = append(t.args, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func (t *fakeTx) Commit(context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done = true
+	t.owner.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback(context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done = true
+	t.owner.rollbacks++
+	return nil
+}
+
+func TestUnitOfWorkCommitsOnSuccess(t *testing.T) {
+	b := &fakeBeginner{}
+	err := NewUnitOfWork(b).InTx(context.Background(), func(ctx context.Context, tx pgx.Tx) error {
+		if got, ok := TxFromContext(ctx); !ok || got != tx {
+			t.Fatalf("expected the context to carry the transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if b.commits != 1 || b.rollbacks != 0 {
+		t.Fatalf("expected one commit, got %d commits and %d rollbacks", b.commits, b.rollbacks)
+	}
+}
+
+func TestUnitOfWorkRollsBackOnError(t *testing.T) {
+	b := &fakeBeginner{}
+	boom := errors.New("boom")
+	err := NewUnitOfWork(b).InTx(context.Background(), func(context.Context, pgx.Tx) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn's error, got %v", err)
+	}
+	if b.commits != 0 || b.rollbacks != 1 {
+		t.Fatalf("expected one rollback, got %d commits and %d rollbacks", b.commits, b.rollbacks)
+	}
+}
+
+func TestUnitOfWorkNestedCallsShareTransaction(t *testing.T) {
+	b := &fakeBeginner{}
+	uow := NewUnitOfWork(b)
+	err := uow.InTx(context.Background(), func(ctx context.Context, outer pgx.Tx) error {
+		return uow.InTx(ctx, func(_ context.Context, inner pgx.Tx) error {
+			if inner != outer {
+				t.Fatalf("expected the nested call to join the outer transaction")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if b.begins != 1 || b.commits != 1 {
+		t.Fatalf("expected one transaction, got %d begins and %d commits", b.begins, b.commits)
+	}
+}
+
+func TestUnitOfWorkBeginError(t *testing.T) {
+	b := &fakeBeginner{err: errors.New("pool closed")}
+	called := false
+	err := NewUnitOfWork(b).InTx(context.Background(), func(context.Context, pgx.Tx) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Fatalf("expected a begin error without running fn, got %v (called=%v)", err, called)
+	}
+}
+
+func TestUnitOfWorkAppliesContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var got *fakeTx
+	err := NewUnitOfWork(&fakeBeginner{}).InTx(ctx, func(_ context.Context, tx pgx.Tx) error {
+		got = tx.(*fakeTx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if len(got.execs) != 1 {
+		t.Fatalf("expected one statement_timeout statement, got %q", got.execs)
+	}
+	ms, err := strconv.Atoi(got.args[0][0].(string))
+	if err != nil || ms <= 0 || ms > int(time.Minute.Milliseconds()) {
+		t.Fatalf("expected a timeout within the deadline, got %v", got.args[0])
+	}
+
+	err = NewUnitOfWork(&fakeBeginner{}).InTx(context.Background(), func(_ context.Context, tx pgx.Tx) error {
+		got = tx.(*fakeTx)
+		return nil
+	})
+	if err != nil || len(got.execs) != 0 {
+		t.Fatalf("expected no statement without a deadline, got %q (%v)", got.execs, err)
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := CheckContext(ctx, "insert"); err != nil {
+		t.Fatalf("expected a live context to pass, got %v", err)
+	}
+	cancel()
+	if err := CheckContext(ctx, "insert"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
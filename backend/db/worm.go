@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLStateWORMViolation is raised by the write-once guard triggers
+// (migration 000052).
+const SQLStateWORMViolation = "BFW01"
+
+var (
+	// ErrWORMViolation matches every *WORMError.
+	ErrWORMViolation = errors.New("db: write-once table")
+	// ErrWORMGuardMissing is returned by CheckWORMGuards when a guard
+	// trigger is missing or disabled.
+	ErrWORMGuardMissing = errors.New("db: write-once guard missing")
+)
+
+// WORMError is a forbidden UPDATE or DELETE of a write-once table, whether
+// refused by GuardWORM before it was sent or by a guard trigger.
+type WORMError struct {
+	Table string
+	Op    string
+	err   error
+}
+
+func (e *WORMError) Error() string {
+	return fmt.Sprintf("db: %s on %s is forbidden: the table is write-once", e.Op, e.Table)
+}
+
+// Unwrap returns the PostgreSQL error, if the database raised it.
+func (e *WORMError) Unwrap() error { return e.err }
+
+func (e *WORMError) Is(target error) bool { return target == ErrWORMViolation }
+
+// WORMGuard is a trigger that keeps Ops off the rows of Table.
+type WORMGuard struct {
+	Table   string
+	Trigger string
+	Ops     []string
+}
+
+// WORMGuards are the write-once guards. Timeline events are corrected by
+// appending events and agreements end in a terminal status, so neither is
+// rewritten in place.
+var WORMGuards = []WORMGuard{
+	{Table: "timeline_events", Trigger: "trg_prevent_event_mutation", Ops: []string{"UPDATE", "DELETE"}},
+	{Table: "agreements", Trigger: "no_delete_agreements", Ops: []string{"DELETE"}},
+}
+
+// tgtype bits of pg_trigger.
+var triggerTypeBits = map[string]int{"ROW": 1, "BEFORE": 2, "DELETE": 8, "UPDATE": 16}
+
+// mask is the tgtype bits a trigger guarding g must carry: a BEFORE ROW
+// trigger firing on each of g's operations.
+func (g WORMGuard) mask() int {
+	m := triggerTypeBits["ROW"] | triggerTypeBits["BEFORE"]
+	for _, op := range g.Ops {
+		m |= triggerTypeBits[op]
+	}
+	return m
+}
+
+// CheckWORMGuards verifies that every WORMGuards trigger exists in the
+// current schema, is enabled and fires before each guarded operation, so
+// a process can refuse to start on a database that would let history be
+// rewritten.
+func CheckWORMGuards(ctx context.Context, q RowQuerier) error {
+	var missing []string
+	for _, g := range WORMGuards {
+		var ok bool
+		if err := q.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM pg_trigger t
+				JOIN pg_class c ON c.oid = t.tgrelid
+				WHERE c.relnamespace = current_schema()::regnamespace
+				  AND c.relname = $1
+				  AND t.tgname = $2
+				  AND t.tgenabled <> 'D'
+				  AND (t.tgtype::int & $3::int) = $3::int
+			)
+		`, g.Table, g.Trigger, g.mask()).Scan(&ok); err != nil {
+			return fmt.Errorf("db: check write-once guard %s: %w", g.Trigger, err)
+		}
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s on %s)", g.Trigger, strings.Join(g.Ops, "/"), g.Table))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrWORMGuardMissing, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// WORMViolation returns err as a *WORMError when a guard trigger raised it,
+// and err unchanged otherwise.
+func WORMViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if err == nil || errors.As(err, new(*WORMError)) || !errors.As(err, &pgErr) || pgErr.Code != SQLStateWORMViolation {
+		return err
+	}
+	return &WORMError{Table: pgErr.TableName, Op: pgErr.Detail, err: err}
+}
+
+// wormStatement finds UPDATE and DELETE FROM targets anywhere in a
+// statement, data-modifying CTEs included.
+var wormStatement = regexp.MustCompile(`(?i)\b(UPDATE|DELETE\s+FROM)\s+(?:ONLY\s+)?(?:"?public"?\.)?"?([a-z_][a-z0-9_]*)"?`)
+
+// CheckWORM returns a *WORMError when sql updates or deletes rows of a
+// table WORMGuards protects against that operation.
+func CheckWORM(sql string) error {
+	for _, m := range wormStatement.FindAllStringSubmatch(sql, -1) {
+		op := strings.ToUpper(strings.Fields(m[1])[0])
+		table := strings.ToLower(m[2])
+		for _, g := range WORMGuards {
+			if g.Table == table && slices.Contains(g.Ops, op) {
+				return &WORMError{Table: table, Op: op}
+			}
+		}
+	}
+	return nil
+}
+
+// GuardWORM wraps w, and the transactions it begins, so that statements
+// CheckWORM rejects fail before reaching PostgreSQL and guard trigger
+// errors come back as *WORMError.
+func GuardWORM(w Writer) Writer {
+	return wormWriter{w}
+}
+
+type wormWriter struct{ Writer }
+
+func (w wormWriter) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := CheckWORM(sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	tag, err := w.Writer.Exec(ctx, sql, args...)
+	return tag, WORMViolation(err)
+}
+
+func (w wormWriter) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := CheckWORM(sql); err != nil {
+		return nil, err
+	}
+	rows, err := w.Writer.Query(ctx, sql, args...)
+	return rows, WORMViolation(err)
+}
+
+func (w wormWriter) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := CheckWORM(sql); err != nil {
+		return wormRow{err: err}
+	}
+	return wormRow{row: w.Writer.QueryRow(ctx, sql, args...)}
+}
+
+func (w wormWriter) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := w.Writer.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wormTx{tx}, nil
+}
+
+func (w wormWriter) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := w.Writer.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return wormTx{tx}, nil
+}
+
+type wormTx struct{ pgx.Tx }
+
+func (t wormTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wormTx{tx}, nil
+}
+
+func (t wormTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := CheckWORM(sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	tag, err := t.Tx.Exec(ctx, sql, args...)
+	return tag, WORMViolation(err)
+}
+
+func (t wormTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := CheckWORM(sql); err != nil {
+		return nil, err
+	}
+	rows, err := t.Tx.Query(ctx, sql, args...)
+	return rows, WORMViolation(err)
+}
+
+func (t wormTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := CheckWORM(sql); err != nil {
+		return wormRow{err: err}
+	}
+	return wormRow{row: t.Tx.QueryRow(ctx, sql, args...)}
+}
+
+// wormRow is a pgx.Row failing with a refused statement's error, or
+// translating the guard errors of row.
+type wormRow struct {
+	err error
+	row pgx.Row
+}
+
+func (r wormRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return WORMViolation(r.row.Scan(dest...))
+}
@@ -0,0 +1,135 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"brokerflow/db"
+	"brokerflow/db/dbtest"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestCheckWORM(t *testing.T) {
+	cases := []struct {
+		sql   string
+		table string
+		op    string
+	}{
+		{`DELETE FROM agreements WHERE id = $1`, "agreements", "DELETE"},
+		{`delete  from  ONLY public."agreements" WHERE id = $1`, "agreements", "DELETE"},
+		{`UPDATE timeline_events SET payload = $2 WHERE id = $1`, "timeline_events", "UPDATE"},
+		{`DELETE FROM timeline_events WHERE agreement_id = $1`, "timeline_events", "DELETE"},
+		{`WITH gone AS (DELETE FROM timeline_events RETURNING id) SELECT count(*) FROM gone`, "timeline_events", "DELETE"},
+		{`UPDATE agreements SET status = $2 WHERE id = $1`, "", ""},
+		{`SELECT * FROM agreements WHERE id = $1 FOR UPDATE`, "", ""},
+		{`INSERT INTO timeline_events (agreement_id) VALUES ($1) ON CONFLICT DO UPDATE SET ts = now()`, "", ""},
+		{`UPDATE agreements SET status_updated_at = now() WHERE id = $1`, "", ""},
+		{`DELETE FROM agreement_status_history WHERE agreement_id = $1`, "", ""},
+	}
+	for _, tc := range cases {
+		err := db.CheckWORM(tc.sql)
+		if tc.table == "" {
+			if err != nil {
+				t.Errorf("%q: expected no error, got %v", tc.sql, err)
+			}
+			continue
+		}
+		var wormErr *db.WORMError
+		if !errors.As(err, &wormErr) || !errors.Is(err, db.ErrWORMViolation) {
+			t.Errorf("%q: expected a WORMError, got %v", tc.sql, err)
+			continue
+		}
+		if wormErr.Table != tc.table || wormErr.Op != tc.op {
+			t.Errorf("%q: expected %s on %s, got %s on %s", tc.sql, tc.op, tc.table, wormErr.Op, wormErr.Table)
+		}
+	}
+}
+
+func TestWORMViolation_TranslatesGuardErrors(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: db.SQLStateWORMViolation, TableName: "timeline_events", Detail: "UPDATE", Message: "timeline_events are immutable"}
+	err := db.WORMViolation(fmt.Errorf("append event: %w", pgErr))
+
+	var wormErr *db.WORMError
+	if !errors.As(err, &wormErr) || wormErr.Table != "timeline_events" || wormErr.Op != "UPDATE" {
+		t.Fatalf("expected UPDATE on timeline_events, got %v", err)
+	}
+	if !errors.Is(err, pgErr) {
+		t.Fatal("expected the PostgreSQL error to stay in the chain")
+	}
+
+	other := &pgconn.PgError{Code: "23505"}
+	if got := db.WORMViolation(other); got != other {
+		t.Fatalf("expected other errors unchanged, got %v", got)
+	}
+	if db.WORMViolation(nil) != nil {
+		t.Fatal("expected nil to stay nil")
+	}
+}
+
+func TestCheckWORMGuards(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectQuery("FROM pg_trigger").WithArgs("timeline_events", "trg_prevent_event_mutation", 27).
+		WillReturnRows(dbtest.NewRows("exists").AddRow(true))
+	mock.ExpectQuery("FROM pg_trigger").WithArgs("agreements", "no_delete_agreements", 11).
+		WillReturnRows(dbtest.NewRows("exists").AddRow(false))
+
+	err := db.CheckWORMGuards(context.Background(), mock)
+	if !errors.Is(err, db.ErrWORMGuardMissing) || !strings.Contains(err.Error(), "no_delete_agreements") {
+		t.Fatalf("expected the agreements guard reported missing, got %v", err)
+	}
+}
+
+func TestGuardWORM_RefusesBeforeSending(t *testing.T) {
+	ctx := context.Background()
+	mock := dbtest.New(t)
+	mock.ExpectExec("UPDATE agreements").WillReturnTag("UPDATE 1")
+	mock.ExpectBegin()
+	mock.ExpectExec("purge_agreement").WillReturnError(&pgconn.PgError{Code: db.SQLStateWORMViolation, TableName: "agreements", Detail: "DELETE"})
+	mock.ExpectRollback()
+
+	w := db.GuardWORM(mock)
+	if _, err := w.Exec(ctx, `DELETE FROM agreements WHERE id = $1`, "a1"); !errors.Is(err, db.ErrWORMViolation) {
+		t.Fatalf("expected the delete refused, got %v", err)
+	}
+	if _, err := w.Exec(ctx, `UPDATE agreements SET status = $2 WHERE id = $1`, "a1", "void"); err != nil {
+		t.Fatalf("expected agreement updates through, got %v", err)
+	}
+
+	tx, err := w.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err := tx.QueryRow(ctx, `UPDATE timeline_events SET ts = now() RETURNING id`).Scan(&id); !errors.Is(err, db.ErrWORMViolation) {
+		t.Fatalf("expected the timeline update refused, got %v", err)
+	}
+	// A statement the check cannot see through still fails typed.
+	var wormErr *db.WORMError
+	if _, err := tx.Exec(ctx, `SELECT purge_agreement($1)`, "a1"); !errors.As(err, &wormErr) || wormErr.Op != "DELETE" {
+		t.Fatalf("expected the trigger's DELETE refusal, got %v", err)
+	}
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatal(err)
+	}
+}
+
+func TestInTx_ReturnsWORMErrors(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("purge_agreement").WillReturnError(&pgconn.PgError{Code: db.SQLStateWORMViolation, TableName: "agreements", Detail: "DELETE"})
+	mock.ExpectRollback()
+
+	err := db.NewUnitOfWork(mock).InTx(context.Background(), func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `SELECT purge_agreement($1)`, "a1")
+		return err
+	})
+	var wormErr *db.WORMError
+	if !errors.As(err, &wormErr) || wormErr.Table != "agreements" || wormErr.Op != "DELETE" {
+		t.Fatalf("expected DELETE on agreements, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package dispute
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultEscalationBatchSize = 100
+
+var ErrInvalidSLA = errors.New("dispute: invalid SLA")
+
+// SLA is how long a dispute may wait before it escalates: Review after it
+// is opened, then Escalation at each tier until MaxTier, where it stays with
+// its last reviewer and no further deadline.
+type SLA struct {
+	Review     time.Duration
+	Escalation time.Duration
+	MaxTier    int
+}
+
+// DefaultSLA gives the parties three days, then each reviewer two days,
+// across three tiers.
+func DefaultSLA() SLA {
+	return SLA{Review: 72 * time.Hour, Escalation: 48 * time.Hour, MaxTier: 3}
+}
+
+// Validate reports ErrInvalidSLA unless both deadlines are positive and
+// there is at least one tier.
+func (s SLA) Validate() error {
+	if s.Review <= 0 || s.Escalation <= 0 || s.MaxTier < 1 {
+		return fmt.Errorf("%w: review %s, escalation %s, %d tiers", ErrInvalidSLA, s.Review, s.Escalation, s.MaxTier)
+	}
+	return nil
+}
+
+// deadlineAfter is how long a dispute at tier has until it escalates again;
+// ok is false once the last tier is reached.
+func (s SLA) deadlineAfter(tier int) (d time.Duration, ok bool) {
+	switch {
+	case tier <= 0:
+		return s.Review, true
+	case tier < s.MaxTier:
+		return s.Escalation, true
+	}
+	return 0, false
+}
+
+// TxBeginner abstracts pgxpool.Pool for testability.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Escalation describes a dispute moved up a tier.
+type Escalation struct {
+	DisputeID      string
+	AgreementID    string
+	Tier           int
+	ReviewerID     *string
+	ReviewDeadline *time.Time
+}
+
+// EscalationService escalates disputes whose review deadline passed. Each
+// escalation assigns the least loaded broker_admin of the agreement's two
+// brokers, preferring one other than the previous reviewer.
+type EscalationService struct {
+	pool      TxBeginner
+	sla       SLA
+	clock     clock.Clock
+	batchSize int
+}
+
+func NewEscalationService(pool TxBeginner) *EscalationService {
+	return &EscalationService{pool: pool, sla: DefaultSLA(), clock: clock.New(), batchSize: defaultEscalationBatchSize}
+}
+
+// WithSLA sets the deadlines given to escalated disputes.
+func (s *EscalationService) WithSLA(sla SLA) *EscalationService {
+	s.sla = sla
+	return s
+}
+
+func (s *EscalationService) WithClock(c clock.Clock) *EscalationService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Run escalates overdue disputes every interval until ctx is cancelled.
+func (s *EscalationService) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			escalated, err := s.EscalateDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("dispute escalation: %v", err)
+				}
+				break
+			}
+			if len(escalated) < s.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+type overdueDispute struct {
+	id, agreementID          string
+	tier                     int
+	reviewerID               *string
+	fromBrokerID, toBrokerID *string
+}
+
+// EscalateDue escalates up to one batch of overdue disputes in a single
+// transaction and writes a dispute.escalated outbox message for each. Rows
+// locked by a concurrent resolve are skipped and retried later.
+func (s *EscalationService) EscalateDue(ctx context.Context) ([]Escalation, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dispute: begin escalation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        SELECT d.id, d.agreement_id, d.escalation_tier, d.assigned_reviewer_id, a.from_broker_id, a.to_broker_id
+        FROM disputes d
+        JOIN agreements a ON a.id = d.agreement_id
+        WHERE d.status IN ('under_review', 'escalated')
+          AND d.review_deadline <= get_tx_timestamp()
+        ORDER BY d.review_deadline
+        LIMIT $1
+        FOR UPDATE OF d SKIP LOCKED
+    `, s.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("dispute: select overdue disputes: %w", err)
+	}
+	overdue, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (overdueDispute, error) {
+		var d overdueDispute
+		err := row.Scan(&d.id, &d.agreementID, &d.tier, &d.reviewerID, &d.fromBrokerID, &d.toBrokerID)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dispute: scan overdue disputes: %w", err)
+	}
+
+	escalated := make([]Escalation, 0, len(overdue))
+	for _, d := range overdue {
+		e, err := s.escalate(ctx, tx, d)
+		if err != nil {
+			return nil, err
+		}
+		escalated = append(escalated, e)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("dispute: commit escalation: %w", err)
+	}
+	return escalated, nil
+}
+
+func (s *EscalationService) escalate(ctx context.Context, tx pgx.Tx, d overdueDispute) (Escalation, error) {
+	reviewerID := d.reviewerID
+	var next *string
+	err := tx.QueryRow(ctx, `
+        SELECT u.id
+        FROM users u
+        WHERE u.role = 'broker_admin' AND u.deleted_at IS NULL AND u.deactivated_at IS NULL
+          AND u.broker_id IN ($1, $2)
+        ORDER BY u.id IS NOT DISTINCT FROM $3::uuid,
+                 (SELECT count(*) FROM disputes o WHERE o.assigned_reviewer_id = u.id AND o.status = 'escalated'),
+                 u.id
+        LIMIT 1
+    `, d.fromBrokerID, d.toBrokerID, d.reviewerID).Scan(&next)
+	switch {
+	case err == nil:
+		reviewerID = next
+	case !errors.Is(err, pgx.ErrNoRows):
+		return Escalation{}, fmt.Errorf("dispute: pick reviewer for %s: %w", d.id, err)
+	}
+
+	tier := d.tier + 1
+	var secs *float64
+	if wait, ok := s.sla.deadlineAfter(tier); ok {
+		v := wait.Seconds()
+		secs = &v
+	}
+	e := Escalation{DisputeID: d.id, AgreementID: d.agreementID, Tier: tier, ReviewerID: reviewerID}
+	err = tx.QueryRow(ctx, `
+        UPDATE disputes
+        SET status = 'escalated',
+            escalation_tier = $2,
+            escalated_at = get_tx_timestamp(),
+            assigned_reviewer_id = $3,
+            review_deadline = get_tx_timestamp() + make_interval(secs => $4)
+        WHERE id = $1
+        RETURNING review_deadline
+    `, d.id, tier, reviewerID, secs).Scan(&e.ReviewDeadline)
+	if err != nil {
+		return Escalation{}, fmt.Errorf("dispute: escalate %s: %w", d.id, err)
+	}
+
+	p := DisputeEscalatedPayload{DisputeID: e.DisputeID, AgreementID: e.AgreementID, Tier: e.Tier, ReviewDeadline: e.ReviewDeadline}
+	if e.ReviewerID != nil {
+		p.ReviewerID = *e.ReviewerID
+	}
+	if err := enqueue(ctx, tx, OutboxTopicDisputeEscalated, p); err != nil {
+		return Escalation{}, err
+	}
+	return e, nil
+}
+
+// enqueue writes an outbox message in tx.
+func enqueue(ctx context.Context, tx pgx.Tx, topic string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("dispute: marshal %s payload: %w", topic, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`, topic, raw); err != nil {
+		return fmt.Errorf("dispute: enqueue %s: %w", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package dispute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type failingPool struct {
+	begins int
+}
+
+func (f *failingPool) Begin(context.Context) (pgx.Tx, error) {
+	f.begins++
+	return nil, errors.New("database unavailable")
+}
+
+func TestSLA_Validate(t *testing.T) {
+	if err := DefaultSLA().Validate(); err != nil {
+		t.Fatalf("default SLA rejected: %v", err)
+	}
+	for name, sla := range map[string]SLA{
+		"no review period":     {Escalation: time.Hour, MaxTier: 1},
+		"no escalation period": {Review: time.Hour, MaxTier: 1},
+		"no tiers":             {Review: time.Hour, Escalation: time.Hour},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := sla.Validate(); !errors.Is(err, ErrInvalidSLA) {
+				t.Fatalf("expected ErrInvalidSLA, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSLA_DeadlineAfter(t *testing.T) {
+	sla := SLA{Review: 72 * time.Hour, Escalation: 24 * time.Hour, MaxTier: 2}
+	for _, tc := range []struct {
+		tier int
+		want time.Duration
+		ok   bool
+	}{
+		{tier: 0, want: 72 * time.Hour, ok: true},
+		{tier: 1, want: 24 * time.Hour, ok: true},
+		{tier: 2},
+		{tier: 3},
+	} {
+		got, ok := sla.deadlineAfter(tc.tier)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("tier %d: got %s, %v; want %s, %v", tc.tier, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestEscalationRun_KeepsRunningAfterErrorsUntilCancelled(t *testing.T) {
+	pool := &failingPool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewEscalationService(pool).Run(ctx, time.Millisecond) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	if pool.begins < 2 {
+		t.Fatalf("expected repeated attempts after failures, got %d", pool.begins)
+	}
+}
+
+func TestEscalateDue_PropagatesBeginError(t *testing.T) {
+	if _, err := NewEscalationService(&failingPool{}).EscalateDue(context.Background()); err == nil {
+		t.Fatal("expected begin error")
+	}
+}
@@ -7,7 +7,20 @@ type Status string
 
 const (
 	StatusUnderReview Status = "under_review"
-	StatusResolved    Status = "resolved"
+	// StatusEscalated is a dispute whose review deadline passed; it has an
+	// assigned reviewer and a fresh deadline.
+	StatusEscalated Status = "escalated"
+	StatusResolved  Status = "resolved"
+)
+
+// Role is the side of the agreement that opened a dispute.
+type Role string
+
+const (
+	// RoleReferrer is the referral owner's side of the agreement.
+	RoleReferrer Role = "referrer"
+	// RoleReferee is the referee broker, which is owed the fee.
+	RoleReferee Role = "referee"
 )
 
 // Record mirrors the disputes table.
@@ -18,4 +31,17 @@ type Record struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	ResolvedAt  *time.Time
+	// ReviewDeadline is when the dispute escalates unless resolved; nil once
+	// resolved or after the last escalation tier.
+	ReviewDeadline     *time.Time
+	EscalationTier     int
+	EscalatedAt        *time.Time
+	AssignedReviewerID *string
+	// OpenedBy is the user who opened the dispute; nil once they are deleted.
+	OpenedBy     *string
+	OpenedByRole Role
+	// Reason and Detail are empty on disputes opened before reasons were
+	// recorded.
+	Reason Reason
+	Detail string
 }
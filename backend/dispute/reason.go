@@ -0,0 +1,61 @@
+package dispute
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reason is why a party opened a dispute.
+type Reason string
+
+const (
+	ReasonNonPayment             Reason = "non_payment"
+	ReasonProtectPeriodViolation Reason = "protect_period_violation"
+	ReasonMisrepresentation      Reason = "misrepresentation"
+	ReasonOther                  Reason = "other"
+)
+
+// MaxDetailLength bounds the free-text detail, in characters.
+const MaxDetailLength = 2000
+
+// Reasons lists the accepted dispute reasons.
+func Reasons() []Reason {
+	return []Reason{ReasonNonPayment, ReasonProtectPeriodViolation, ReasonMisrepresentation, ReasonOther}
+}
+
+var (
+	ErrInvalidReason  = errors.New("dispute: reason must be one of non_payment, protect_period_violation, misrepresentation, other")
+	ErrDetailRequired = errors.New("dispute: detail is required")
+	ErrDetailTooLong  = fmt.Errorf("dispute: detail exceeds %d characters", MaxDetailLength)
+)
+
+// CreateParams describes a new dispute: the agreement it is on, the reason
+// and the detail reviewers read to judge it.
+type CreateParams struct {
+	AgreementID string
+	Reason      Reason
+	Detail      string
+}
+
+// normalize trims p and checks that it has a known reason and a detail of
+// at most MaxDetailLength characters.
+func (p CreateParams) normalize() (CreateParams, error) {
+	p.Reason = Reason(strings.ToLower(strings.TrimSpace(string(p.Reason))))
+	p.Detail = strings.TrimSpace(p.Detail)
+	valid := false
+	for _, r := range Reasons() {
+		valid = valid || r == p.Reason
+	}
+	if !valid {
+		return CreateParams{}, ErrInvalidReason
+	}
+	if p.Detail == "" {
+		return CreateParams{}, ErrDetailRequired
+	}
+	if utf8.RuneCountInString(p.Detail) > MaxDetailLength {
+		return CreateParams{}, ErrDetailTooLong
+	}
+	return p, nil
+}
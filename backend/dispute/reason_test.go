@@ -0,0 +1,58 @@
+package dispute_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+)
+
+func TestServiceCreate_RequiresReasonAndDetail(t *testing.T) {
+	ctx := context.Background()
+	users := testsupport.NewUsers()
+	referrals := testsupport.NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	agreements := testsupport.NewAgreements(referrals, users)
+	ag := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "effective")
+	svc := dispute.NewService(testsupport.NewDisputes(agreements))
+	owner := tenancy.User("owner")
+
+	for name, tc := range map[string]struct {
+		params dispute.CreateParams
+		want   error
+	}{
+		"missing reason":  {dispute.CreateParams{Detail: "unpaid"}, dispute.ErrInvalidReason},
+		"unknown reason":  {dispute.CreateParams{Reason: "late", Detail: "unpaid"}, dispute.ErrInvalidReason},
+		"missing detail":  {dispute.CreateParams{Reason: dispute.ReasonNonPayment, Detail: "  "}, dispute.ErrDetailRequired},
+		"overlong detail": {dispute.CreateParams{Reason: dispute.ReasonOther, Detail: strings.Repeat("é", dispute.MaxDetailLength+1)}, dispute.ErrDetailTooLong},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.params.AgreementID = ag.ID
+			if _, err := svc.Create(ctx, owner, tc.params); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+
+	rec, err := svc.Create(ctx, owner, dispute.CreateParams{
+		AgreementID: ag.ID,
+		Reason:      " Protect_Period_Violation ",
+		Detail:      "  Buyer closed with the referee's client inside the protection period.  ",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if rec.Reason != dispute.ReasonProtectPeriodViolation || rec.Detail != "Buyer closed with the referee's client inside the protection period." {
+		t.Fatalf("expected a normalized reason and detail, got %q %q", rec.Reason, rec.Detail)
+	}
+	list, err := svc.List(ctx, owner, ag.ID)
+	if err != nil || len(list) != 1 || list[0].Reason != dispute.ReasonProtectPeriodViolation {
+		t.Fatalf("expected the reason in the list, got %+v, %v", list, err)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/tenancy"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
@@ -16,29 +18,65 @@ var (
 )
 
 type Repository struct {
-	pool *pgxpool.Pool
+	pool   db.Writer
+	reader db.Reader
+	sla    SLA
 }
 
-func NewRepository(pool *pgxpool.Pool) *Repository {
-	return &Repository{pool: pool}
+func NewRepository(pool db.Writer) *Repository {
+	return &Repository{pool: pool, reader: pool, sla: DefaultSLA()}
 }
 
-func (r *Repository) List(ctx context.Context, ownerID string, agreementID string) ([]Record, error) {
-	query := `
-		SELECT d.id, d.agreement_id, d.status::text, d.created_at, d.updated_at, d.resolved_at
-		FROM disputes d
-		JOIN agreements a ON a.id = d.agreement_id
-		JOIN referral_requests rr ON rr.id = a.referral_id
-		WHERE rr.created_by_user_id = $1
-	`
-	args := []any{ownerID}
+// WithReader lists the disputes of one or several agreements from reader.
+func (r *Repository) WithReader(reader db.Reader) *Repository {
+	r.reader = reader
+	return r
+}
+
+// WithSLA sets the review deadline given to new disputes.
+func (r *Repository) WithSLA(sla SLA) *Repository {
+	r.sla = sla
+	return r
+}
+
+const recordColumns = `d.id, d.agreement_id, d.status::text, d.created_at, d.updated_at, d.resolved_at,
+		d.review_deadline, d.escalation_tier, d.escalated_at, d.assigned_reviewer_id, d.opened_by_user_id::text, d.opened_by_role,
+		COALESCE(d.reason, ''), COALESCE(d.detail, '')`
+
+func scanRecord(row pgx.Row) (Record, error) {
+	var rec Record
+	err := row.Scan(&rec.ID, &rec.AgreementID, &rec.Status, &rec.CreatedAt, &rec.UpdatedAt, &rec.ResolvedAt,
+		&rec.ReviewDeadline, &rec.EscalationTier, &rec.EscalatedAt, &rec.AssignedReviewerID, &rec.OpenedBy, &rec.OpenedByRole,
+		&rec.Reason, &rec.Detail)
+	return rec, err
+}
+
+// refereeMember matches agreements whose referee broker the user bound to
+// its placeholder belongs to.
+const refereeMember = `EXISTS (SELECT 1 FROM users m WHERE m.id = ? AND m.broker_id = a.to_broker_id)`
+
+// partyTo returns the predicate and arguments restricting agreements a,
+// joined to their referral rr, to those scope may dispute: the referrer side
+// as tenancy.Scope.PartyTo sees it, plus every user of the referee broker.
+func partyTo(scope tenancy.Scope) (string, []any) {
+	pred, arg := scope.Parties("rr.created_by_user_id", "a.from_broker_id", "a.to_broker_id")
+	return "(" + pred + " OR " + refereeMember + ")", []any{arg, scope.UserID}
+}
+
+// List returns disputes visible within scope: those on the caller's
+// referrals, on agreements the caller's brokerage is the referee of, or on
+// any agreement the admin's brokerage is a party to.
+func (r *Repository) List(ctx context.Context, scope tenancy.Scope, agreementID string) ([]Record, error) {
+	pred, args := partyTo(scope)
+	q := db.Select(recordColumns).
+		From("disputes d JOIN agreements a ON a.id = d.agreement_id JOIN referral_requests rr ON rr.id = a.referral_id").
+		Where(pred, args...)
 	if agreementID != "" {
-		query += " AND d.agreement_id = $2"
-		args = append(args, agreementID)
+		q.Where("d.agreement_id = ?", agreementID)
 	}
-	query += " ORDER BY d.created_at DESC"
+	query, args := q.OrderBy("d.created_at DESC").SQL()
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := r.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("dispute: list: %w", err)
 	}
@@ -46,8 +84,42 @@ func (r *Repository) List(ctx context.Context, ownerID string, agreementID strin
 
 	out := make([]Record, 0, 8)
 	for rows.Next() {
-		var rec Record
-		if err := rows.Scan(&rec.ID, &rec.AgreementID, &rec.Status, &rec.CreatedAt, &rec.UpdatedAt, &rec.ResolvedAt); err != nil {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("dispute: scan: %w", err)
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("dispute: iterate: %w", err)
+	}
+	return out, nil
+}
+
+// ListForAgreements returns the disputes scope can see, as List decides,
+// on the agreements among agreementIDs, newest first.
+func (r *Repository) ListForAgreements(ctx context.Context, scope tenancy.Scope, agreementIDs []string) ([]Record, error) {
+	if len(agreementIDs) == 0 {
+		return nil, nil
+	}
+	pred, args := partyTo(scope)
+	query, args := db.Select(recordColumns).
+		From("disputes d JOIN agreements a ON a.id = d.agreement_id JOIN referral_requests rr ON rr.id = a.referral_id").
+		Where(pred, args...).
+		Where("d.agreement_id = ANY(?::uuid[])", agreementIDs).
+		OrderBy("d.created_at DESC").
+		SQL()
+
+	rows, err := r.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("dispute: list for agreements: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
 			return nil, fmt.Errorf("dispute: scan: %w", err)
 		}
 		out = append(out, rec)
@@ -58,45 +130,86 @@ func (r *Repository) List(ctx context.Context, ownerID string, agreementID strin
 	return out, nil
 }
 
-func (r *Repository) Create(ctx context.Context, ownerID, agreementID string) (Record, error) {
-	const query = `
-		INSERT INTO disputes (agreement_id, status)
-		SELECT $1, 'under_review'
+// Create opens a dispute for either party to the agreement with p's reason
+// and detail (validated by Service.Create) and a review deadline of the
+// SLA's Review period, and enqueues dispute.opened in one transaction; the referral moves to disputed alongside (see
+// agreement.ReferralProjector). The dispute is the referee's when the caller
+// belongs to the referee broker and does not own the referral, the
+// referrer's otherwise. Callers who are not party to the agreement, and
+// unknown agreements, get ErrForbidden.
+func (r *Repository) Create(ctx context.Context, scope tenancy.Scope, p CreateParams) (Record, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Record{}, fmt.Errorf("dispute: begin create: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	pred, args := partyTo(scope)
+	query := `
+		INSERT INTO disputes AS d (agreement_id, status, review_deadline, opened_by_user_id, opened_by_role, reason, detail)
+		SELECT a.id, 'under_review', get_tx_timestamp() + make_interval(secs => ?), ?::uuid,
+			CASE WHEN rr.created_by_user_id <> ?::uuid AND ` + refereeMember + ` THEN 'referee' ELSE 'referrer' END,
+			?, ?
 		FROM agreements a
 		JOIN referral_requests rr ON rr.id = a.referral_id
-		WHERE a.id = $1 AND rr.created_by_user_id = $2
-		RETURNING id, agreement_id, status::text, created_at, updated_at, resolved_at
-	`
+		WHERE a.id = ? AND ` + pred + `
+		RETURNING ` + recordColumns
+	args = append([]any{r.sla.Review.Seconds(), scope.UserID, scope.UserID, scope.UserID, string(p.Reason), p.Detail, p.AgreementID}, args...)
 
-	var rec Record
-	err := r.pool.QueryRow(ctx, query, agreementID, ownerID).
-		Scan(&rec.ID, &rec.AgreementID, &rec.Status, &rec.CreatedAt, &rec.UpdatedAt, &rec.ResolvedAt)
+	rec, err := scanRecord(tx.QueryRow(ctx, db.Numbered(query), args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Record{}, ErrForbidden
 		}
 		return Record{}, fmt.Errorf("dispute: create: %w", err)
 	}
+	var projector agreement.ReferralProjector
+	if _, err := projector.Apply(ctx, tx, rec.AgreementID, projector.ForDisputeOpened()); err != nil {
+		return Record{}, err
+	}
+
+	opened := DisputeOpenedPayload{DisputeID: rec.ID, AgreementID: rec.AgreementID, OpenedBy: scope.UserID, Role: rec.OpenedByRole, Reason: rec.Reason}
+	if rec.ReviewDeadline != nil {
+		opened.ReviewDeadline = *rec.ReviewDeadline
+	}
+	if err := enqueue(ctx, tx, OutboxTopicDisputeOpened, opened); err != nil {
+		return Record{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Record{}, fmt.Errorf("dispute: commit create: %w", err)
+	}
 	return rec, nil
 }
 
-func (r *Repository) Resolve(ctx context.Context, ownerID, disputeID string) (Record, error) {
-	const query = `
+// Resolve resolves a dispute on behalf of the referral owner or the
+// dispute's assigned reviewer and enqueues dispute.resolved.
+func (r *Repository) Resolve(ctx context.Context, actorID, disputeID string) (Record, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Record{}, fmt.Errorf("dispute: begin resolve: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
 		UPDATE disputes d
 		SET status = 'resolved'
 		FROM agreements a
 		JOIN referral_requests rr ON rr.id = a.referral_id
 		WHERE d.id = $1
 		  AND d.agreement_id = a.id
-		  AND rr.created_by_user_id = $2
+		  AND (rr.created_by_user_id = $2 OR d.assigned_reviewer_id = $2)
 		  AND d.status <> 'resolved'
-		RETURNING d.id, d.agreement_id, d.status::text, d.created_at, d.updated_at, d.resolved_at
-	`
+		RETURNING ` + recordColumns
 
-	var rec Record
-	err := r.pool.QueryRow(ctx, query, disputeID, ownerID).
-		Scan(&rec.ID, &rec.AgreementID, &rec.Status, &rec.CreatedAt, &rec.UpdatedAt, &rec.ResolvedAt)
+	rec, err := scanRecord(tx.QueryRow(ctx, query, disputeID, actorID))
 	if err == nil {
+		p := DisputeResolvedPayload{DisputeID: rec.ID, AgreementID: rec.AgreementID, ResolvedBy: actorID, Tier: rec.EscalationTier}
+		if err := enqueue(ctx, tx, OutboxTopicDisputeResolved, p); err != nil {
+			return Record{}, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return Record{}, fmt.Errorf("dispute: commit resolve: %w", err)
+		}
 		return rec, nil
 	}
 	if !errors.Is(err, pgx.ErrNoRows) {
@@ -108,10 +221,10 @@ func (r *Repository) Resolve(ctx context.Context, ownerID, disputeID string) (Re
 		FROM disputes d
 		JOIN agreements a ON a.id = d.agreement_id
 		JOIN referral_requests rr ON rr.id = a.referral_id
-		WHERE d.id = $1 AND rr.created_by_user_id = $2
+		WHERE d.id = $1 AND (rr.created_by_user_id = $2 OR d.assigned_reviewer_id = $2)
 	`
 	var status Status
-	if err := r.pool.QueryRow(ctx, check, disputeID, ownerID).Scan(&status); err != nil {
+	if err := tx.QueryRow(ctx, check, disputeID, actorID).Scan(&status); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Record{}, ErrForbidden
 		}
@@ -0,0 +1,85 @@
+package dispute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/db/dbtest"
+	"brokerflow/tenancy"
+)
+
+var disputeColumns = []string{"id", "agreement_id", "status", "created_at", "updated_at", "resolved_at", "review_deadline",
+	"escalation_tier", "escalated_at", "assigned_reviewer_id", "opened_by_user_id", "opened_by_role", "reason", "detail"}
+
+func TestRepositoryCreate_BindsArgumentsAndEnqueues(t *testing.T) {
+	mock := dbtest.New(t)
+	now := time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+	deadline := now.Add(72 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO disputes AS d").
+		WithArgs(float64(72*3600), "u1", "u1", "u1", "non_payment", "Fee not paid.", "a1", "u1", "u1").
+		WillReturnRows(dbtest.NewRows(disputeColumns...).
+			AddRow("d1", "a1", "under_review", now, now, nil, deadline, int32(0), nil, nil, "u1", "referee", "non_payment", "Fee not paid."))
+	mock.ExpectExec("UPDATE referral_requests rr").WithArgs("a1", "disputed", dbtest.Any).WillReturnTag("UPDATE 1")
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(OutboxTopicDisputeOpened, dbtest.Any).WillReturnTag("INSERT 0 1")
+	mock.ExpectCommit()
+
+	rec, err := NewRepository(mock).Create(context.Background(), tenancy.User("u1"),
+		CreateParams{AgreementID: "a1", Reason: ReasonNonPayment, Detail: "Fee not paid."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != "d1" || rec.Status != StatusUnderReview || rec.OpenedByRole != RoleReferee || rec.Reason != ReasonNonPayment ||
+		rec.ReviewDeadline == nil || !rec.ReviewDeadline.Equal(deadline) || rec.OpenedBy == nil || *rec.OpenedBy != "u1" || rec.ResolvedAt != nil {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
+
+func TestRepositoryCreate_MapsErrors(t *testing.T) {
+	params := CreateParams{AgreementID: "a1", Reason: ReasonOther, Detail: "Not my deal."}
+	t.Run("not a party", func(t *testing.T) {
+		mock := dbtest.New(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO disputes")
+		mock.ExpectRollback()
+		if _, err := NewRepository(mock).Create(context.Background(), tenancy.User("u9"), params); !errors.Is(err, ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+	t.Run("begin fails", func(t *testing.T) {
+		mock := dbtest.New(t)
+		down := errors.New("database unavailable")
+		mock.ExpectBegin().WillReturnError(down)
+		if _, err := NewRepository(mock).Create(context.Background(), tenancy.User("u1"), params); !errors.Is(err, down) {
+			t.Fatalf("expected the begin error wrapped, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryResolve_TellsResolvedFromForbidden(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status *string
+		want   error
+	}{
+		"already resolved": {status: ptr("resolved"), want: ErrBadStatus},
+		"not the owner":    {want: ErrForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mock := dbtest.New(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("UPDATE disputes d SET status = 'resolved'").WithArgs("d1", "u1")
+			check := mock.ExpectQuery("SELECT d.status::text").WithArgs("d1", "u1")
+			if tc.status != nil {
+				check.WillReturnRows(dbtest.NewRows("status").AddRow(*tc.status))
+			}
+			mock.ExpectRollback()
+			if _, err := NewRepository(mock).Resolve(context.Background(), "u1", "d1"); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
@@ -1,23 +1,45 @@
 package dispute
 
-import "context"
+import (
+	"context"
+
+	"brokerflow/tenancy"
+)
+
+// Store is the persistence the service needs; *Repository implements it
+// against PostgreSQL.
+type Store interface {
+	List(ctx context.Context, scope tenancy.Scope, agreementID string) ([]Record, error)
+	Create(ctx context.Context, scope tenancy.Scope, p CreateParams) (Record, error)
+	Resolve(ctx context.Context, actorID, disputeID string) (Record, error)
+}
 
 type Service struct {
-	repo *Repository
+	repo Store
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(repo Store) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) List(ctx context.Context, ownerID, agreementID string) ([]Record, error) {
-	return s.repo.List(ctx, ownerID, agreementID)
+func (s *Service) List(ctx context.Context, scope tenancy.Scope, agreementID string) ([]Record, error) {
+	return s.repo.List(ctx, scope, agreementID)
 }
 
-func (s *Service) Create(ctx context.Context, ownerID, agreementID string) (Record, error) {
-	return s.repo.Create(ctx, ownerID, agreementID)
+// Create opens a dispute for either party to the agreement: the referral
+// owner's side or the referee broker. p needs one of Reasons and a detail;
+// otherwise it answers ErrInvalidReason, ErrDetailRequired or
+// ErrDetailTooLong.
+func (s *Service) Create(ctx context.Context, scope tenancy.Scope, p CreateParams) (Record, error) {
+	p, err := p.normalize()
+	if err != nil {
+		return Record{}, err
+	}
+	return s.repo.Create(ctx, scope, p)
 }
 
-func (s *Service) Resolve(ctx context.Context, ownerID, disputeID string) (Record, error) {
-	return s.repo.Resolve(ctx, ownerID, disputeID)
+// Resolve resolves a dispute for the referral owner or, once escalated, its
+// assigned reviewer.
+func (s *Service) Resolve(ctx context.Context, actorID, disputeID string) (Record, error) {
+	return s.repo.Resolve(ctx, actorID, disputeID)
 }
@@ -0,0 +1,71 @@
+package dispute
+
+import (
+	"time"
+
+	"brokerflow/outbox"
+)
+
+const (
+	// OutboxTopicDisputeOpened is published when either party opens a
+	// dispute on an agreement.
+	OutboxTopicDisputeOpened = "dispute.opened"
+	// OutboxTopicDisputeEscalated is published each time a dispute misses
+	// its review deadline and moves up an escalation tier.
+	OutboxTopicDisputeEscalated = "dispute.escalated"
+	// OutboxTopicDisputeResolved is published when a dispute is resolved.
+	OutboxTopicDisputeResolved = "dispute.resolved"
+)
+
+// DisputeOpenedPayload is published on dispute.opened.
+type DisputeOpenedPayload struct {
+	DisputeID      string    `json:"dispute_id"`
+	AgreementID    string    `json:"agreement_id"`
+	OpenedBy       string    `json:"opened_by"`
+	Role           Role      `json:"role"`
+	Reason         Reason    `json:"reason"`
+	ReviewDeadline time.Time `json:"review_deadline"`
+}
+
+// DisputeEscalatedPayload is published on dispute.escalated. ReviewerID is
+// empty when neither broker has an admin to assign; ReviewDeadline is nil
+// after the last tier.
+type DisputeEscalatedPayload struct {
+	DisputeID      string     `json:"dispute_id"`
+	AgreementID    string     `json:"agreement_id"`
+	Tier           int        `json:"tier"`
+	ReviewerID     string     `json:"reviewer_id,omitempty"`
+	ReviewDeadline *time.Time `json:"review_deadline,omitempty"`
+}
+
+// DisputeResolvedPayload is published on dispute.resolved.
+type DisputeResolvedPayload struct {
+	DisputeID   string `json:"dispute_id"`
+	AgreementID string `json:"agreement_id"`
+	ResolvedBy  string `json:"resolved_by"`
+	Tier        int    `json:"tier"`
+}
+
+// OutboxTopics declares the topics this package enqueues.
+func OutboxTopics() []outbox.Topic {
+	return []outbox.Topic{
+		{
+			Name:        OutboxTopicDisputeOpened,
+			Producer:    "dispute",
+			Description: "A party to an agreement, the referrer side or the referee broker (see role), opened a dispute on it; the dispute starts under_review with a review deadline.",
+			Payload:     DisputeOpenedPayload{},
+		},
+		{
+			Name:        OutboxTopicDisputeEscalated,
+			Producer:    "dispute",
+			Description: "A dispute missed its review deadline and was escalated to the next tier with a broker_admin assigned as reviewer.",
+			Payload:     DisputeEscalatedPayload{},
+		},
+		{
+			Name:        OutboxTopicDisputeResolved,
+			Producer:    "dispute",
+			Description: "A dispute was resolved by the referral owner or its assigned reviewer.",
+			Payload:     DisputeResolvedPayload{},
+		},
+	}
+}
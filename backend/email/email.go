@@ -0,0 +1,99 @@
+// Package email sends transactional emails for lifecycle events. Notifier
+// consumes outbox messages, renders the matching template and hands it to a
+// Sender (SMTP or Amazon SES), honouring each recipient's preferences.
+package email
+
+import (
+	"context"
+	"errors"
+)
+
+// Kind identifies a templated email. Each kind can be switched off per user.
+type Kind string
+
+const (
+	KindMatchInvited         Kind = "match_invited"
+	KindAgreementReadyToSign Kind = "agreement_ready_to_sign"
+	KindAgreementEffective   Kind = "agreement_effective"
+	KindDisputeOpened        Kind = "dispute_opened"
+	KindNewReferralMatch     Kind = "new_referral_match"
+	// KindInvitation goes to people without an account yet, so it cannot
+	// be switched off.
+	KindInvitation Kind = "invitation"
+)
+
+// Kinds lists every kind in a stable order.
+var Kinds = []Kind{KindMatchInvited, KindAgreementReadyToSign, KindAgreementEffective, KindDisputeOpened, KindNewReferralMatch, KindInvitation}
+
+var ErrUnknownKind = errors.New("email: unknown template")
+
+// Message is a rendered email ready to send.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Sender delivers a message. Implementations return once the provider has
+// accepted it.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Preferences are a user's opt-ins, one flag per kind. Users who never set
+// them receive every kind.
+type Preferences struct {
+	MatchInvited         bool
+	AgreementReadyToSign bool
+	AgreementEffective   bool
+	DisputeOpened        bool
+	NewReferralMatch     bool
+}
+
+// DefaultPreferences enables every kind.
+func DefaultPreferences() Preferences {
+	return Preferences{MatchInvited: true, AgreementReadyToSign: true, AgreementEffective: true, DisputeOpened: true, NewReferralMatch: true}
+}
+
+// Allows reports whether the user wants emails of kind.
+func (p Preferences) Allows(kind Kind) bool {
+	switch kind {
+	case KindMatchInvited:
+		return p.MatchInvited
+	case KindAgreementReadyToSign:
+		return p.AgreementReadyToSign
+	case KindAgreementEffective:
+		return p.AgreementEffective
+	case KindDisputeOpened:
+		return p.DisputeOpened
+	case KindNewReferralMatch:
+		return p.NewReferralMatch
+	case KindInvitation:
+		return true
+	}
+	return false
+}
+
+// PreferenceStore persists preferences. Get returns DefaultPreferences for a
+// user who has none stored.
+type PreferenceStore interface {
+	GetPreferences(ctx context.Context, userID string) (Preferences, error)
+	SavePreferences(ctx context.Context, userID string, p Preferences) (Preferences, error)
+}
+
+// Service exposes a user's preferences to the API.
+type Service struct {
+	store PreferenceStore
+}
+
+func NewService(store PreferenceStore) *Service {
+	return &Service{store: store}
+}
+
+func (s *Service) GetPreferences(ctx context.Context, userID string) (Preferences, error) {
+	return s.store.GetPreferences(ctx, userID)
+}
+
+func (s *Service) SavePreferences(ctx context.Context, userID string, p Preferences) (Preferences, error) {
+	return s.store.SavePreferences(ctx, userID, p)
+}
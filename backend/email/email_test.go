@@ -0,0 +1,397 @@
+package email
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/invitation"
+	"brokerflow/outbox"
+)
+
+func TestRender_AllKinds(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, kind := range Kinds {
+		subject, body, err := tmpl.Render(kind, Data{RecipientName: "Ana", Link: "https://app.example.com/app/agreements"})
+		if err != nil {
+			t.Fatalf("%s: render: %v", kind, err)
+		}
+		if subject == "" || strings.Contains(subject, "\n") {
+			t.Fatalf("%s: bad subject %q", kind, subject)
+		}
+		if !strings.Contains(body, "Hi Ana,") || !strings.Contains(body, `href="https://app.example.com/app/agreements"`) {
+			t.Fatalf("%s: body missing greeting or link:\n%s", kind, body)
+		}
+	}
+	if _, _, err := tmpl.Render("weekly_digest", Data{}); !errors.Is(err, ErrUnknownKind) {
+		t.Fatalf("expected ErrUnknownKind, got %v", err)
+	}
+}
+
+func TestRender_EscapesData(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, body, err := tmpl.Render(KindAgreementEffective, Data{RecipientName: "<script>x</script>", Link: "javascript:alert(1)", EffectiveAt: "May 1, 2025"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if strings.Contains(body, "<script>") || strings.Contains(body, "javascript:") {
+		t.Fatalf("unescaped data in body:\n%s", body)
+	}
+	if !strings.Contains(body, "as of May 1, 2025") {
+		t.Fatalf("effective date missing:\n%s", body)
+	}
+}
+
+func TestPreferencesAllows(t *testing.T) {
+	p := DefaultPreferences()
+	for _, kind := range Kinds {
+		if !p.Allows(kind) {
+			t.Fatalf("default preferences should allow %s", kind)
+		}
+	}
+	p.DisputeOpened = false
+	if p.Allows(KindDisputeOpened) || !p.Allows(KindMatchInvited) {
+		t.Fatalf("unexpected Allows for %+v", p)
+	}
+	if p.Allows("unknown") {
+		t.Fatal("unknown kinds must not be allowed")
+	}
+}
+
+func TestBuildMIME(t *testing.T) {
+	from := mail.Address{Name: "BrokerFlow", Address: "no-reply@example.com"}
+	to := mail.Address{Address: "ana@example.com"}
+	raw, err := buildMIME(from, to, Message{Subject: "Référence prête", HTML: "<p>hello</p>"}, time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Référence prête" {
+		t.Fatalf("subject = %q, %v", subject, err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Fatalf("unexpected Message-ID %q", msg.Header.Get("Message-ID"))
+	}
+	body, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, msg.Body))
+	if string(body) != "<p>hello</p>" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	s, err := NewSMTPSender("smtp.example.com:587", "user", "pass", "BrokerFlow <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	var gotFrom string
+	var gotTo []string
+	s.send = func(_ string, a smtp.Auth, from string, to []string, _ []byte) error {
+		if a == nil {
+			t.Error("expected auth")
+		}
+		gotFrom, gotTo = from, to
+		return nil
+	}
+	if err := s.Send(context.Background(), Message{To: "Ana <ana@example.com>", Subject: "s", HTML: "b"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if gotFrom != "no-reply@example.com" || len(gotTo) != 1 || gotTo[0] != "ana@example.com" {
+		t.Fatalf("envelope from %q to %v", gotFrom, gotTo)
+	}
+	if err := s.Send(context.Background(), Message{To: "not an address"}); err == nil {
+		t.Fatal("expected invalid recipient to be rejected")
+	}
+}
+
+// TestSignV4 checks the signer against the worked example in the AWS
+// Signature Version 4 documentation (IAM ListUsers).
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := SESCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSESSender_Send(t *testing.T) {
+	var got struct {
+		FromEmailAddress string
+		Destination      struct{ ToAddresses []string }
+		Content          struct {
+			Simple struct {
+				Subject struct{ Data string }
+				Body    struct{ Html struct{ Data string } }
+			}
+		}
+	}
+	var auth, token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/email/outbound-emails" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		auth, token = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"MessageId":"m1"}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewSESSender("eu-west-1", SESCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "tok"}, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	s.WithEndpoint(srv.URL).WithClock(clock.NewFake(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)))
+
+	if err := s.Send(context.Background(), Message{To: "ana@example.com", Subject: "Hi", HTML: "<p>x</p>"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got.FromEmailAddress != "no-reply@example.com" || len(got.Destination.ToAddresses) != 1 ||
+		got.Content.Simple.Subject.Data != "Hi" || got.Content.Simple.Body.Html.Data != "<p>x</p>" {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20250501/eu-west-1/ses/aws4_request") ||
+		!strings.Contains(auth, "x-amz-security-token") || token != "tok" {
+		t.Fatalf("unexpected signing: %q token %q", auth, token)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message":"Email address is not verified."}`, http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	s.WithEndpoint(failing.URL)
+	if err := s.Send(context.Background(), Message{To: "ana@example.com"}); err == nil || !strings.Contains(err.Error(), "not verified") {
+		t.Fatalf("expected SES error, got %v", err)
+	}
+}
+
+type stubDirectory struct {
+	users        map[string]Recipient
+	participants []Recipient
+	sent         map[string]bool
+}
+
+func (d *stubDirectory) Users(_ context.Context, ids []string) ([]Recipient, error) {
+	var out []Recipient
+	for _, id := range ids {
+		if u, ok := d.users[id]; ok {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func (d *stubDirectory) AgreementParticipants(context.Context, string) ([]Recipient, error) {
+	return append([]Recipient(nil), d.participants...), nil
+}
+
+func (d *stubDirectory) AlreadySent(_ context.Context, messageID, userID string) (bool, error) {
+	return d.sent[messageID+"/"+userID], nil
+}
+
+func (d *stubDirectory) MarkSent(_ context.Context, messageID, userID string) error {
+	d.sent[messageID+"/"+userID] = true
+	return nil
+}
+
+type stubSender struct {
+	sent []Message
+	fail map[string]bool
+}
+
+func (s *stubSender) Send(_ context.Context, msg Message) error {
+	if s.fail[msg.To] {
+		return errors.New("relay unavailable")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, dir *stubDirectory, sender *stubSender) *Notifier {
+	t.Helper()
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return NewNotifier(dir, sender, tmpl, "https://app.example.com/")
+}
+
+func TestNotifier_MatchInvited(t *testing.T) {
+	dir := &stubDirectory{
+		users: map[string]Recipient{"cand": {UserID: "cand", Email: "cand@example.com", Name: "Cam", Preferences: DefaultPreferences()}},
+		sent:  map[string]bool{},
+	}
+	sender := &stubSender{}
+	n := newTestNotifier(t, dir, sender)
+	msg := outbox.Message{ID: "o1", Topic: "match.invited", Payload: json.RawMessage(`{"match_id":"m1","referral_id":"r1","candidate_id":"cand","owner_id":"own"}`)}
+
+	for i := 0; i < 2; i++ {
+		if err := n.HandleOutbox(context.Background(), msg); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected one email across retries, got %d", len(sender.sent))
+	}
+	if sender.sent[0].To != "cand@example.com" || !strings.Contains(sender.sent[0].HTML, "https://app.example.com/app/referrals/invitations") {
+		t.Fatalf("unexpected email %+v", sender.sent[0])
+	}
+}
+
+type stubInvitations map[string]invitation.Email
+
+func (s stubInvitations) InvitationEmail(_ context.Context, id string) (invitation.Email, bool, error) {
+	inv, ok := s[id]
+	return inv, ok, nil
+}
+
+func TestNotifier_InvitationCreated(t *testing.T) {
+	dir := &stubDirectory{sent: map[string]bool{}}
+	sender := &stubSender{}
+	n := newTestNotifier(t, dir, sender).WithInvitations(stubInvitations{
+		"i1": {To: "new@example.com", BrokerName: "Acme Realty", Token: "i1.1767225600.sig"},
+	})
+
+	for _, id := range []string{"i1", "i1", "revoked"} {
+		msg := outbox.Message{ID: "o-" + id, Topic: "invitation.created", Payload: json.RawMessage(`{"invitation_id":"` + id + `","broker_id":"b1"}`)}
+		if err := n.HandleOutbox(context.Background(), msg); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected one invitation email, got %+v", sender.sent)
+	}
+	got := sender.sent[0]
+	if got.To != "new@example.com" || got.Subject != "Join Acme Realty on BrokerFlow" ||
+		!strings.Contains(got.HTML, `href="https://app.example.com/app/join?token=i1.1767225600.sig"`) {
+		t.Fatalf("unexpected email %+v", got)
+	}
+}
+
+func TestNotifier_DisputeOpenedSkipsOpenerAndOptOuts(t *testing.T) {
+	optedOut := DefaultPreferences()
+	optedOut.DisputeOpened = false
+	dir := &stubDirectory{
+		participants: []Recipient{
+			{UserID: "owner", Email: "owner@example.com", Preferences: DefaultPreferences()},
+			{UserID: "agent", Email: "agent@example.com", Preferences: DefaultPreferences()},
+			{UserID: "admin", Email: "admin@example.com", Preferences: optedOut},
+		},
+		sent: map[string]bool{},
+	}
+	sender := &stubSender{}
+	n := newTestNotifier(t, dir, sender)
+	msg := outbox.Message{ID: "o2", Topic: "dispute.opened", Payload: json.RawMessage(`{"dispute_id":"d1","agreement_id":"a1","opened_by":"owner"}`)}
+
+	if err := n.HandleOutbox(context.Background(), msg); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "agent@example.com" {
+		t.Fatalf("expected only the other party, got %+v", sender.sent)
+	}
+}
+
+func TestNotifier_ReadyToSignRetriesOnlyFailures(t *testing.T) {
+	dir := &stubDirectory{
+		participants: []Recipient{
+			{UserID: "owner", Email: "owner@example.com", Preferences: DefaultPreferences()},
+			{UserID: "agent", Email: "agent@example.com", Preferences: DefaultPreferences()},
+		},
+		sent: map[string]bool{},
+	}
+	sender := &stubSender{fail: map[string]bool{"agent@example.com": true}}
+	n := newTestNotifier(t, dir, sender)
+	msg := outbox.Message{ID: "o3", Topic: "agreement.status_changed", Payload: json.RawMessage(`{"agreement_id":"a1","previous":"draft","next":"pending_signature"}`)}
+
+	if err := n.HandleOutbox(context.Background(), msg); err == nil {
+		t.Fatal("expected the failed send to be reported")
+	}
+	sender.fail = nil
+	if err := n.HandleOutbox(context.Background(), msg); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(sender.sent) != 2 || sender.sent[0].To != "owner@example.com" || sender.sent[1].To != "agent@example.com" {
+		t.Fatalf("unexpected sends %+v", sender.sent)
+	}
+}
+
+type stubWatchers map[string][]string
+
+func (w stubWatchers) ReferralWatchers(_ context.Context, referralID string) ([]string, error) {
+	return w[referralID], nil
+}
+
+func TestNotifier_NewReferralMatchesSavedFilters(t *testing.T) {
+	optedOut := DefaultPreferences()
+	optedOut.NewReferralMatch = false
+	dir := &stubDirectory{
+		users: map[string]Recipient{
+			"watcher": {UserID: "watcher", Email: "watcher@example.com", Preferences: DefaultPreferences()},
+			"muted":   {UserID: "muted", Email: "muted@example.com", Preferences: optedOut},
+		},
+		sent: map[string]bool{},
+	}
+	sender := &stubSender{}
+	msg := outbox.Message{ID: "o8", Topic: "referral.created", Payload: json.RawMessage(`{"referral_id":"r1","status":"open"}`)}
+
+	// Without a watcher source referral.created sends nothing.
+	if err := newTestNotifier(t, dir, sender).HandleOutbox(context.Background(), msg); err != nil || len(sender.sent) != 0 {
+		t.Fatalf("expected no email without watchers, got %v %+v", err, sender.sent)
+	}
+
+	n := newTestNotifier(t, dir, sender).WithWatchers(stubWatchers{"r1": {"watcher", "muted"}})
+	if err := n.HandleOutbox(context.Background(), msg); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "watcher@example.com" || !strings.Contains(sender.sent[0].HTML, "https://app.example.com/app/referrals") {
+		t.Fatalf("expected one email to the watcher, got %+v", sender.sent)
+	}
+}
+
+func TestNotifier_IgnoresOtherMessages(t *testing.T) {
+	dir := &stubDirectory{
+		participants: []Recipient{{UserID: "owner", Email: "owner@example.com", Preferences: DefaultPreferences()}},
+		sent:         map[string]bool{},
+	}
+	sender := &stubSender{}
+	n := newTestNotifier(t, dir, sender)
+	for _, msg := range []outbox.Message{
+		{ID: "o4", Topic: "agreement.status_changed", Payload: json.RawMessage(`{"agreement_id":"a1","previous":"effective","next":"success"}`)},
+		{ID: "o5", Topic: "agreement.created", Payload: json.RawMessage(`{"agreement_id":"a1","referral_id":"r1"}`)},
+		{ID: "o6", Topic: "review.submitted", Payload: json.RawMessage(`{"agreement_id":"a1"}`)},
+		{ID: "o7", Topic: "agreement.effective", Payload: json.RawMessage(`not json`)},
+	} {
+		if err := n.HandleOutbox(context.Background(), msg); err != nil {
+			t.Fatalf("%s: %v", msg.ID, err)
+		}
+	}
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected no emails, got %+v", sender.sent)
+	}
+}
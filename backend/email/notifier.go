@@ -0,0 +1,242 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/invitation"
+	"brokerflow/outbox"
+	"brokerflow/referral"
+)
+
+// Recipient is a user who may be emailed, with their preferences. UserID
+// keys the record of what was sent; invitees, who have no account yet, are
+// keyed by their invitation.
+type Recipient struct {
+	UserID      string
+	Email       string
+	Name        string
+	Preferences Preferences
+}
+
+// Directory resolves recipients and remembers who was emailed about which
+// outbox message. Erased users are never returned.
+type Directory interface {
+	Users(ctx context.Context, ids []string) ([]Recipient, error)
+	AgreementParticipants(ctx context.Context, agreementID string) ([]Recipient, error)
+	AlreadySent(ctx context.Context, messageID, userID string) (bool, error)
+	MarkSent(ctx context.Context, messageID, userID string) error
+}
+
+// WatcherSource finds the users whose saved filters ask to hear about a new
+// referral; referral.PGSavedFilterRepository implements it.
+type WatcherSource interface {
+	ReferralWatchers(ctx context.Context, referralID string) ([]string, error)
+}
+
+// InvitationSource resolves what to email for a brokerage invitation;
+// invitation.Service implements it.
+type InvitationSource interface {
+	InvitationEmail(ctx context.Context, id string) (invitation.Email, bool, error)
+}
+
+// App paths linked from emails, relative to the frontend base URL.
+const (
+	invitationsPath = "/app/referrals/invitations"
+	agreementsPath  = "/app/agreements"
+	referralsPath   = "/app/referrals"
+	joinPath        = "/app/join"
+)
+
+// statusPendingSignature is the agreement status awaiting both signatures.
+const statusPendingSignature = "pending_signature"
+
+// Notifier implements outbox.Handler: it emails the people a lifecycle event
+// concerns. Each recipient is emailed at most once per outbox message, so
+// when one send fails and the worker retries, the others are not repeated.
+type Notifier struct {
+	dir       Directory
+	sender    Sender
+	templates *Templates
+	appURL    string
+	watchers  WatcherSource
+	invites   InvitationSource
+}
+
+// NewNotifier builds a Notifier linking to the frontend at appURL.
+func NewNotifier(dir Directory, sender Sender, templates *Templates, appURL string) *Notifier {
+	return &Notifier{dir: dir, sender: sender, templates: templates, appURL: strings.TrimRight(appURL, "/")}
+}
+
+// WithWatchers enables referral.created emails to the owners of matching
+// saved filters.
+func (n *Notifier) WithWatchers(w WatcherSource) *Notifier {
+	n.watchers = w
+	return n
+}
+
+// WithInvitations enables invitation.created emails to invitees.
+func (n *Notifier) WithInvitations(src InvitationSource) *Notifier {
+	n.invites = src
+	return n
+}
+
+// notification is what a message asks for: one kind sent to recipients,
+// all sharing the same template data except the name.
+type notification struct {
+	kind       Kind
+	recipients []Recipient
+	data       Data
+}
+
+func (n *Notifier) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	note, err := n.resolve(ctx, msg)
+	if err != nil || note == nil {
+		return err
+	}
+
+	var errs []error
+	for _, rc := range note.recipients {
+		if !rc.Preferences.Allows(note.kind) || rc.Email == "" {
+			continue
+		}
+		if err := n.notify(ctx, msg.ID, *note, rc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) notify(ctx context.Context, messageID string, note notification, rc Recipient) error {
+	sent, err := n.dir.AlreadySent(ctx, messageID, rc.UserID)
+	if err != nil || sent {
+		return err
+	}
+	data := note.data
+	data.RecipientName = rc.Name
+	subject, body, err := n.templates.Render(note.kind, data)
+	if err != nil {
+		return err
+	}
+	if err := n.sender.Send(ctx, Message{To: rc.Email, Subject: subject, HTML: body}); err != nil {
+		return fmt.Errorf("email: send %s to %s: %w", note.kind, rc.UserID, err)
+	}
+	return n.dir.MarkSent(ctx, messageID, rc.UserID)
+}
+
+// resolve maps a message to a notification, or nil for topics that send no
+// email.
+func (n *Notifier) resolve(ctx context.Context, msg outbox.Message) (*notification, error) {
+	switch msg.Topic {
+	case referral.OutboxTopicMatchInvited:
+		var p referral.MatchEventPayload
+		if !decode(msg, &p) || p.CandidateID == "" {
+			return nil, nil
+		}
+		users, err := n.dir.Users(ctx, []string{p.CandidateID})
+		if err != nil {
+			return nil, err
+		}
+		return &notification{kind: KindMatchInvited, recipients: users, data: Data{Link: n.appURL + invitationsPath}}, nil
+
+	case referral.OutboxTopicReferralCreated:
+		var p referral.ReferralCreatedPayload
+		if n.watchers == nil || !decode(msg, &p) || p.ReferralID == "" {
+			return nil, nil
+		}
+		ids, err := n.watchers.ReferralWatchers(ctx, p.ReferralID)
+		if err != nil || len(ids) == 0 {
+			return nil, err
+		}
+		users, err := n.dir.Users(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		return &notification{kind: KindNewReferralMatch, recipients: users, data: Data{Link: n.appURL + referralsPath}}, nil
+
+	case invitation.OutboxTopicInvitationCreated:
+		var p invitation.InvitationCreatedPayload
+		if n.invites == nil || !decode(msg, &p) || p.InvitationID == "" {
+			return nil, nil
+		}
+		// Revoked, accepted or expired invitations are not sent.
+		inv, ok, err := n.invites.InvitationEmail(ctx, p.InvitationID)
+		if err != nil || !ok {
+			return nil, err
+		}
+		invitee := Recipient{UserID: "invitation:" + p.InvitationID, Email: inv.To, Name: inv.To, Preferences: DefaultPreferences()}
+		return &notification{kind: KindInvitation, recipients: []Recipient{invitee}, data: Data{
+			Link:       n.appURL + joinPath + "?" + url.Values{"token": {inv.Token}}.Encode(),
+			BrokerName: inv.BrokerName,
+		}}, nil
+
+	case agreement.OutboxTopicAgreementCreated:
+		var p agreement.AgreementCreatedPayload
+		if !decode(msg, &p) || p.Status != statusPendingSignature {
+			return nil, nil
+		}
+		return n.forAgreement(ctx, p.AgreementID, KindAgreementReadyToSign, Data{})
+
+	case agreement.OutboxTopicAgreementStatusChanged:
+		var p agreement.AgreementStatusChangedPayload
+		if !decode(msg, &p) || p.Next != statusPendingSignature {
+			return nil, nil
+		}
+		return n.forAgreement(ctx, p.AgreementID, KindAgreementReadyToSign, Data{})
+
+	case agreement.OutboxTopicAgreementEffective:
+		var p agreement.AgreementEffectivePayload
+		if !decode(msg, &p) {
+			return nil, nil
+		}
+		data := Data{}
+		if !p.EffectiveAt.IsZero() {
+			data.EffectiveAt = p.EffectiveAt.UTC().Format("January 2, 2006 15:04 MST")
+		}
+		return n.forAgreement(ctx, p.AgreementID, KindAgreementEffective, data)
+
+	case dispute.OutboxTopicDisputeOpened:
+		var p dispute.DisputeOpenedPayload
+		if !decode(msg, &p) {
+			return nil, nil
+		}
+		note, err := n.forAgreement(ctx, p.AgreementID, KindDisputeOpened, Data{})
+		if note != nil {
+			// Whoever opened the dispute knows about it already.
+			others := note.recipients[:0]
+			for _, rc := range note.recipients {
+				if rc.UserID != p.OpenedBy {
+					others = append(others, rc)
+				}
+			}
+			note.recipients = others
+		}
+		return note, err
+	}
+	return nil, nil
+}
+
+func (n *Notifier) forAgreement(ctx context.Context, agreementID string, kind Kind, data Data) (*notification, error) {
+	if agreementID == "" {
+		return nil, nil
+	}
+	users, err := n.dir.AgreementParticipants(ctx, agreementID)
+	if err != nil {
+		return nil, err
+	}
+	data.Link = n.appURL + agreementsPath
+	return &notification{kind: kind, recipients: users, data: data}, nil
+}
+
+// decode unmarshals the payload, reporting whether it could. A malformed
+// payload will not improve on retry, so it is skipped rather than failing
+// the message.
+func decode(msg outbox.Message, v any) bool {
+	return len(msg.Payload) > 0 && json.Unmarshal(msg.Payload, v) == nil
+}
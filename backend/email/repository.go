@@ -0,0 +1,144 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// routePrefix namespaces sent emails in edge_invocations.route.
+const routePrefix = "email:"
+
+type PGRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool}
+}
+
+func (r *PGRepository) GetPreferences(ctx context.Context, userID string) (Preferences, error) {
+	var p Preferences
+	err := r.pool.QueryRow(ctx, `
+		SELECT match_invited, agreement_ready_to_sign, agreement_effective, dispute_opened, new_referral_match
+		FROM email_preferences
+		WHERE user_id = $1
+	`, userID).Scan(&p.MatchInvited, &p.AgreementReadyToSign, &p.AgreementEffective, &p.DisputeOpened, &p.NewReferralMatch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultPreferences(), nil
+	}
+	if err != nil {
+		return Preferences{}, fmt.Errorf("email: load preferences: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PGRepository) SavePreferences(ctx context.Context, userID string, p Preferences) (Preferences, error) {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO email_preferences (user_id, match_invited, agreement_ready_to_sign, agreement_effective, dispute_opened, new_referral_match, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, get_tx_timestamp(), get_tx_timestamp())
+		ON CONFLICT (user_id) DO UPDATE SET
+			match_invited = EXCLUDED.match_invited,
+			agreement_ready_to_sign = EXCLUDED.agreement_ready_to_sign,
+			agreement_effective = EXCLUDED.agreement_effective,
+			dispute_opened = EXCLUDED.dispute_opened,
+			new_referral_match = EXCLUDED.new_referral_match,
+			updated_at = get_tx_timestamp()
+	`, userID, p.MatchInvited, p.AgreementReadyToSign, p.AgreementEffective, p.DisputeOpened, p.NewReferralMatch)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("email: save preferences: %w", err)
+	}
+	return p, nil
+}
+
+// recipientColumns selects a Recipient from users u joined to
+// email_preferences ep; a missing preferences row means every kind is on.
+const recipientColumns = `
+	u.id::text, u.email, u.full_name,
+	COALESCE(ep.match_invited, true), COALESCE(ep.agreement_ready_to_sign, true),
+	COALESCE(ep.agreement_effective, true), COALESCE(ep.dispute_opened, true),
+	COALESCE(ep.new_referral_match, true)`
+
+func (r *PGRepository) Users(ctx context.Context, ids []string) ([]Recipient, error) {
+	return r.recipients(ctx, `
+		SELECT `+recipientColumns+`
+		FROM users u
+		LEFT JOIN email_preferences ep ON ep.user_id = u.id
+		WHERE u.id::text = ANY ($1) AND u.deleted_at IS NULL AND u.deactivated_at IS NULL
+		ORDER BY u.id
+	`, ids)
+}
+
+// AgreementParticipants returns the referral owner and the receiving agent
+// (the accepted candidate in the receiving brokerage). Before a candidate
+// is known the receiving brokerage's admins stand in for the agent.
+func (r *PGRepository) AgreementParticipants(ctx context.Context, agreementID string) ([]Recipient, error) {
+	return r.recipients(ctx, `
+		WITH a AS (
+			SELECT a.id, a.referral_id, a.to_broker_id FROM agreements a WHERE a.id::text = $1
+		), candidate AS (
+			SELECT m.candidate_user_id AS user_id
+			FROM a
+			JOIN referral_matches m ON m.request_id = a.referral_id AND m.state = 'accepted'
+			JOIN users cu ON cu.id = m.candidate_user_id AND cu.broker_id = a.to_broker_id
+			ORDER BY m.created_at
+			LIMIT 1
+		), participants AS (
+			SELECT rr.created_by_user_id AS user_id FROM a JOIN referral_requests rr ON rr.id = a.referral_id
+			UNION
+			SELECT user_id FROM candidate
+			UNION
+			SELECT ua.id FROM a JOIN users ua ON ua.broker_id = a.to_broker_id AND ua.role = 'broker_admin'
+			WHERE NOT EXISTS (SELECT 1 FROM candidate)
+		)
+		SELECT `+recipientColumns+`
+		FROM participants p
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN email_preferences ep ON ep.user_id = u.id
+		WHERE u.deleted_at IS NULL AND u.deactivated_at IS NULL
+		ORDER BY u.id
+	`, agreementID)
+}
+
+func (r *PGRepository) recipients(ctx context.Context, sql string, arg any) ([]Recipient, error) {
+	rows, err := r.pool.Query(ctx, sql, arg)
+	if err != nil {
+		return nil, fmt.Errorf("email: load recipients: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Recipient, error) {
+		var rc Recipient
+		p := &rc.Preferences
+		err := row.Scan(&rc.UserID, &rc.Email, &rc.Name, &p.MatchInvited, &p.AgreementReadyToSign, &p.AgreementEffective, &p.DisputeOpened, &p.NewReferralMatch)
+		return rc, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("email: scan recipients: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PGRepository) AlreadySent(ctx context.Context, messageID, userID string) (bool, error) {
+	var sent bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM edge_invocations WHERE route = $1 AND key = $2 AND status = 'completed')
+	`, routePrefix+userID, messageID).Scan(&sent)
+	if err != nil {
+		return false, fmt.Errorf("email: check sent: %w", err)
+	}
+	return sent, nil
+}
+
+func (r *PGRepository) MarkSent(ctx context.Context, messageID, userID string) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO edge_invocations (key, route, status, first_attempt_at, last_attempt_at)
+		VALUES ($1, $2, 'completed', get_tx_timestamp(), get_tx_timestamp())
+		ON CONFLICT (route, key) DO UPDATE SET status = 'completed', last_attempt_at = get_tx_timestamp()
+	`, messageID, routePrefix+userID)
+	if err != nil {
+		return fmt.Errorf("email: record sent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"brokerflow/awssig"
+	"brokerflow/clock"
+)
+
+const sesRequestTimeout = 10 * time.Second
+
+// SESCredentials are AWS credentials; SessionToken is set for temporary
+// credentials only.
+type SESCredentials = awssig.Credentials
+
+// SESSender sends through the Amazon SES v2 SendEmail API, signing requests
+// with AWS Signature Version 4.
+type SESSender struct {
+	endpoint string
+	region   string
+	creds    SESCredentials
+	from     string
+	client   *http.Client
+	clock    clock.Clock
+}
+
+func NewSESSender(region string, creds SESCredentials, from string) (*SESSender, error) {
+	if region == "" || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("email: SES needs a region and credentials")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("email: invalid from address %q: %w", from, err)
+	}
+	return &SESSender{
+		endpoint: "https://email." + region + ".amazonaws.com",
+		region:   region,
+		creds:    creds,
+		from:     from,
+		client:   &http.Client{Timeout: sesRequestTimeout},
+		clock:    clock.New(),
+	}, nil
+}
+
+// WithEndpoint overrides the API base URL, e.g. for a VPC endpoint.
+func (s *SESSender) WithEndpoint(endpoint string) *SESSender {
+	s.endpoint = strings.TrimRight(endpoint, "/")
+	return s
+}
+
+func (s *SESSender) WithClient(c *http.Client) *SESSender {
+	s.client = c
+	return s
+}
+
+func (s *SESSender) WithClock(c clock.Clock) *SESSender {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+func (s *SESSender) Send(ctx context.Context, msg Message) error {
+	var req struct {
+		FromEmailAddress string `json:"FromEmailAddress"`
+		Destination      struct {
+			ToAddresses []string `json:"ToAddresses"`
+		} `json:"Destination"`
+		Content struct {
+			Simple struct {
+				Subject sesContent `json:"Subject"`
+				Body    struct {
+					HTML sesContent `json:"Html"`
+				} `json:"Body"`
+			} `json:"Simple"`
+		} `json:"Content"`
+	}
+	req.FromEmailAddress = s.from
+	req.Destination.ToAddresses = []string{msg.To}
+	req.Content.Simple.Subject = sesContent{Data: msg.Subject, Charset: "UTF-8"}
+	req.Content.Simple.Body.HTML = sesContent{Data: msg.HTML, Charset: "UTF-8"}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("email: encode SES request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("email: build SES request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	signV4(httpReq, body, s.creds, s.region, "ses", s.clock.Now())
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("email: SES send: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("email: SES returned %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// signV4 signs req for SES; see awssig.Sign.
+func signV4(req *http.Request, body []byte, creds SESCredentials, region, service string, now time.Time) {
+	awssig.Sign(req, awssig.PayloadHash(body), creds, region, service, now)
+}
@@ -0,0 +1,94 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends through an SMTP relay with smtp.SendMail, which upgrades
+// to STARTTLS when the server offers it. Credentials are only sent over TLS
+// or to localhost.
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from mail.Address
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPSender takes the relay as host:port, optional credentials and the
+// From address (e.g. "BrokerFlow <no-reply@example.com>").
+func NewSMTPSender(addr, username, password, from string) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid SMTP address %q: %w", addr, err)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid from address %q: %w", from, err)
+	}
+	s := &SMTPSender{addr: addr, from: *sender, send: smtp.SendMail}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s, nil
+}
+
+func (s *SMTPSender) Send(_ context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("email: invalid recipient %q: %w", msg.To, err)
+	}
+	body, err := buildMIME(s.from, *to, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := s.send(s.addr, s.auth, s.from.Address, []string{to.Address}, body); err != nil {
+		return fmt.Errorf("email: smtp send: %w", err)
+	}
+	return nil
+}
+
+// buildMIME renders an HTML-only message. The body is base64 encoded so
+// line length limits never apply.
+func buildMIME(from, to mail.Address, msg Message, now time.Time) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("email: message id: %w", err)
+	}
+	domain := "brokerflow.local"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/html; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "base64")
+	b.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.HTML))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// Data is what templates render. Link is the call to action; fields a kind
+// does not use are left empty.
+type Data struct {
+	RecipientName string
+	Link          string
+	EffectiveAt   string
+	BrokerName    string
+}
+
+// Templates holds the parsed template of every kind.
+type Templates struct {
+	byKind map[Kind]*template.Template
+}
+
+// ParseTemplates parses the embedded templates. Each kind's file defines
+// "subject", "action" and "body", wrapped by the shared "layout".
+func ParseTemplates() (*Templates, error) {
+	t := &Templates{byKind: make(map[Kind]*template.Template, len(Kinds))}
+	for _, kind := range Kinds {
+		tmpl, err := template.ParseFS(templateFS, "templates/layout.html", "templates/"+string(kind)+".html")
+		if err != nil {
+			return nil, fmt.Errorf("email: parse %s template: %w", kind, err)
+		}
+		t.byKind[kind] = tmpl
+	}
+	return t, nil
+}
+
+// Render returns the subject and HTML body of kind for data.
+func (t *Templates) Render(kind Kind, data Data) (subject, body string, err error) {
+	tmpl, ok := t.byKind[kind]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", fmt.Errorf("email: render %s subject: %w", kind, err)
+	}
+	// The subject is a header, not HTML: undo the escaping and fold it
+	// onto one line so nothing can inject further headers.
+	subject = strings.Join(strings.Fields(html.UnescapeString(buf.String())), " ")
+
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", fmt.Errorf("email: render %s body: %w", kind, err)
+	}
+	return subject, buf.String(), nil
+}
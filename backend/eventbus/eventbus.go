@@ -0,0 +1,27 @@
+// Package eventbus implements outbox.Publisher for the message buses larger
+// deployments feed from the outbox: NATS JetStream and Kafka.
+package eventbus
+
+import (
+	"fmt"
+
+	"brokerflow/outbox"
+)
+
+// Bus kinds accepted by New.
+const (
+	KindNATS  = "nats"
+	KindKafka = "kafka"
+)
+
+// New returns the publisher for kind, configured from rawURL: a NATS server
+// URL for KindNATS, a Kafka REST Proxy URL for KindKafka.
+func New(kind, rawURL string) (outbox.Publisher, error) {
+	switch kind {
+	case KindNATS:
+		return NewNATSPublisher(rawURL)
+	case KindKafka:
+		return NewKafkaRESTPublisher(rawURL)
+	}
+	return nil, fmt.Errorf("eventbus: unknown bus %q, want %s or %s", kind, KindNATS, KindKafka)
+}
@@ -0,0 +1,196 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/outbox"
+)
+
+// fakeNATS accepts one connection, answers the handshake and replies to
+// each HPUB with reply(subject, headers, payload).
+type fakeNATS struct {
+	ln      net.Listener
+	connect chan string
+	reply   func(subject, headers, payload string) string
+}
+
+func newFakeNATS(t *testing.T, reply func(subject, headers, payload string) string) *fakeNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeNATS{ln: ln, connect: make(chan string, 1), reply: reply}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeNATS) url() string { return "nats://tok@" + f.ln.Addr().String() }
+
+func (f *fakeNATS) serve() {
+	conn, err := f.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch verb {
+		case "CONNECT":
+			f.connect <- args
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "HPUB":
+			fields := strings.Fields(args)
+			hdrLen, _ := strconv.Atoi(fields[2])
+			total, _ := strconv.Atoi(fields[3])
+			buf := make([]byte, total+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			// Interleave a server PING the client must answer.
+			fmt.Fprint(conn, "PING\r\n")
+			fmt.Fprint(conn, f.reply(fields[1], string(buf[:hdrLen]), string(buf[hdrLen:total])))
+		}
+	}
+}
+
+func testBusMessage() outbox.BusMessage {
+	return outbox.BusMessage{
+		ID: "m1", Destination: "brokerflow.agreements", Key: "ag1", Topic: "agreement.created",
+		Payload: json.RawMessage(`{"agreement_id":"ag1"}`), CreatedAt: time.Unix(1700000000, 0),
+	}
+}
+
+func TestNATSPublisher_PublishesWithMsgID(t *testing.T) {
+	var gotHeaders, gotPayload string
+	fake := newFakeNATS(t, func(reply, headers, payload string) string {
+		gotHeaders, gotPayload = headers, payload
+		ack := `{"stream":"BROKERFLOW","seq":1}`
+		return fmt.Sprintf("MSG %s 1 %d\r\n%s\r\n", reply, len(ack), ack)
+	})
+	p, err := NewNATSPublisher(fake.url())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), testBusMessage()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if connect := <-fake.connect; !strings.Contains(connect, `"auth_token":"tok"`) || !strings.Contains(connect, `"headers":true`) {
+		t.Fatalf("unexpected CONNECT %s", connect)
+	}
+	for _, want := range []string{"Nats-Msg-Id: m1\r\n", "BrokerFlow-Topic: agreement.created\r\n", "BrokerFlow-Key: ag1\r\n"} {
+		if !strings.Contains(gotHeaders, want) {
+			t.Errorf("headers %q missing %q", gotHeaders, want)
+		}
+	}
+	if gotPayload != `{"agreement_id":"ag1"}` {
+		t.Fatalf("unexpected payload %q", gotPayload)
+	}
+}
+
+func TestNATSPublisher_NoStream(t *testing.T) {
+	fake := newFakeNATS(t, func(reply, _, _ string) string {
+		hdr := "NATS/1.0 503\r\n\r\n"
+		return fmt.Sprintf("HMSG %s 1 %d %d\r\n%s\r\n", reply, len(hdr), len(hdr), hdr)
+	})
+	p, _ := NewNATSPublisher(fake.url())
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), testBusMessage()); !errors.Is(err, ErrNoStream) {
+		t.Fatalf("expected ErrNoStream, got %v", err)
+	}
+}
+
+func TestNATSPublisher_AckError(t *testing.T) {
+	fake := newFakeNATS(t, func(reply, _, _ string) string {
+		ack := `{"error":{"code":400,"description":"maximum messages exceeded"}}`
+		return fmt.Sprintf("MSG %s 1 %d\r\n%s\r\n", reply, len(ack), ack)
+	})
+	p, _ := NewNATSPublisher(fake.url())
+	defer p.Close()
+
+	err := p.Publish(context.Background(), testBusMessage())
+	if err == nil || !strings.Contains(err.Error(), "maximum messages exceeded") {
+		t.Fatalf("expected ack error, got %v", err)
+	}
+}
+
+func TestKafkaRESTPublisher(t *testing.T) {
+	var gotPath, gotType string
+	var gotBody struct {
+		Records []struct {
+			Key   string           `json:"key"`
+			Value KafkaRecordValue `json:"value"`
+		} `json:"records"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewKafkaRESTPublisher(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := p.Publish(context.Background(), testBusMessage()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if gotPath != "/topics/brokerflow.agreements" || gotType != "application/vnd.kafka.json.v2+json" {
+		t.Fatalf("unexpected request %s (%s)", gotPath, gotType)
+	}
+	if len(gotBody.Records) != 1 || gotBody.Records[0].Key != "ag1" || gotBody.Records[0].Value.ID != "m1" ||
+		string(gotBody.Records[0].Value.Payload) != `{"agreement_id":"ag1"}` {
+		t.Fatalf("unexpected records %+v", gotBody.Records)
+	}
+}
+
+func TestKafkaRESTPublisher_RecordError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"timeout"}]}`)
+	}))
+	defer srv.Close()
+
+	p, _ := NewKafkaRESTPublisher(srv.URL)
+	if err := p.Publish(context.Background(), testBusMessage()); err == nil || !strings.Contains(err.Error(), "50003") {
+		t.Fatalf("expected record error, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(KindNATS, "nats://localhost"); err != nil {
+		t.Fatalf("nats: %v", err)
+	}
+	if _, err := New(KindKafka, "http://kafka-rest:8082"); err != nil {
+		t.Fatalf("kafka: %v", err)
+	}
+	if _, err := New("rabbit", "amqp://x"); err == nil {
+		t.Fatal("expected unknown bus error")
+	}
+	if _, err := New(KindNATS, "http://localhost"); err == nil {
+		t.Fatal("expected invalid NATS url error")
+	}
+}
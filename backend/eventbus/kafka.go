@@ -0,0 +1,116 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"brokerflow/outbox"
+)
+
+const kafkaRequestTimeout = 10 * time.Second
+
+// KafkaRecordValue is the JSON value of each record produced to Kafka. ID is
+// the outbox id; consumers deduplicate on it, since a message whose
+// acknowledgement was lost is produced again.
+type KafkaRecordValue struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	CreatedAt time.Time       `json:"created_at"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// KafkaRESTPublisher produces to Kafka through a Confluent-compatible REST
+// Proxy (API v2), so the service needs no native Kafka client. The record key
+// is BusMessage.Key, which keeps an agreement's messages on one partition.
+// The proxy answers only after the brokers acknowledged the record.
+type KafkaRESTPublisher struct {
+	base   string
+	client *http.Client
+}
+
+// NewKafkaRESTPublisher takes the proxy's base URL, e.g.
+// http://kafka-rest:8082.
+func NewKafkaRESTPublisher(rawURL string) (*KafkaRESTPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("eventbus: invalid Kafka REST Proxy url %q", rawURL)
+	}
+	return &KafkaRESTPublisher{
+		base:   strings.TrimRight(rawURL, "/"),
+		client: &http.Client{Timeout: kafkaRequestTimeout},
+	}, nil
+}
+
+func (p *KafkaRESTPublisher) WithClient(c *http.Client) *KafkaRESTPublisher {
+	p.client = c
+	return p
+}
+
+type kafkaRecord struct {
+	Key   string           `json:"key"`
+	Value KafkaRecordValue `json:"value"`
+}
+
+func (p *KafkaRESTPublisher) Publish(ctx context.Context, msg outbox.BusMessage) error {
+	payload := msg.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: []kafkaRecord{{
+		Key:   msg.Key,
+		Value: KafkaRecordValue{ID: msg.ID, Topic: msg.Topic, CreatedAt: msg.CreatedAt.UTC(), Payload: payload},
+	}}})
+	if err != nil {
+		return fmt.Errorf("eventbus: encode Kafka record: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+"/topics/"+url.PathEscape(msg.Destination), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("eventbus: build Kafka request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("eventbus: produce to Kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("eventbus: read Kafka response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("eventbus: Kafka REST Proxy returned %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int    `json:"error_code"`
+			Error     *string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("eventbus: decode Kafka response: %w", err)
+	}
+	if len(result.Offsets) != 1 {
+		return fmt.Errorf("eventbus: Kafka REST Proxy returned %d offsets for one record", len(result.Offsets))
+	}
+	if o := result.Offsets[0]; o.ErrorCode != nil {
+		detail := ""
+		if o.Error != nil {
+			detail = *o.Error
+		}
+		return fmt.Errorf("eventbus: Kafka rejected record: %d %s", *o.ErrorCode, detail)
+	}
+	return nil
+}
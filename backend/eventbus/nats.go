@@ -0,0 +1,316 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"brokerflow/outbox"
+)
+
+const natsDefaultTimeout = 5 * time.Second
+
+// ErrNoStream is returned when no JetStream stream captures the subject, so
+// the message would not be persisted.
+var ErrNoStream = errors.New("eventbus: no JetStream stream for subject")
+
+// NATSPublisher publishes to NATS JetStream and waits for the stream's
+// acknowledgement, speaking the client protocol directly. The outbox id is
+// sent as Nats-Msg-Id, so a retried message within the stream's duplicate
+// window is stored once. It holds one connection, redialled after any error;
+// Publish calls are serialized.
+type NATSPublisher struct {
+	addr    string
+	useTLS  bool
+	connect natsConnect
+	timeout time.Duration
+
+	mu    sync.Mutex
+	conn  net.Conn
+	r     *bufio.Reader
+	inbox string
+	seq   uint64
+}
+
+type natsConnect struct {
+	Verbose      bool   `json:"verbose"`
+	Pedantic     bool   `json:"pedantic"`
+	Headers      bool   `json:"headers"`
+	NoResponders bool   `json:"no_responders"`
+	Name         string `json:"name"`
+	Lang         string `json:"lang"`
+	Version      string `json:"version"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	AuthToken    string `json:"auth_token,omitempty"`
+}
+
+// NewNATSPublisher parses rawURL (nats://[user:pass@|token@]host[:port], or
+// tls:// for TLS) without connecting.
+func NewNATSPublisher(rawURL string) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "nats" && u.Scheme != "tls") {
+		return nil, fmt.Errorf("eventbus: invalid NATS url %q", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	p := &NATSPublisher{
+		addr:    addr,
+		useTLS:  u.Scheme == "tls",
+		timeout: natsDefaultTimeout,
+		connect: natsConnect{Headers: true, NoResponders: true, Name: "brokerflow-outbox", Lang: "go", Version: "1"},
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.connect.User, p.connect.Pass = u.User.Username(), pass
+		} else {
+			p.connect.AuthToken = u.User.Username()
+		}
+	}
+	return p, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msg outbox.BusMessage) error {
+	if msg.Destination == "" || strings.ContainsAny(msg.Destination, " \t\r\n") {
+		return fmt.Errorf("eventbus: invalid NATS subject %q", msg.Destination)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.dial(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.publish(ctx, msg); err != nil {
+		var ackErr *natsAckError
+		if !errors.As(err, &ackErr) && !errors.Is(err, ErrNoStream) {
+			// The connection is in an unknown state; start afresh next time.
+			p.close()
+		}
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection, if any.
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close()
+	return nil
+}
+
+func (p *NATSPublisher) close() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn, p.r = nil, nil
+}
+
+func (p *NATSPublisher) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(p.timeout)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+func (p *NATSPublisher) dial(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("eventbus: dial NATS: %w", err)
+	}
+	_ = conn.SetDeadline(p.deadline(ctx))
+	r := bufio.NewReader(conn)
+
+	// The server greets with INFO before anything else, TLS included.
+	line, err := readLine(r)
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("eventbus: NATS handshake: expected INFO, got %q: %v", line, err)
+	}
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("eventbus: NATS TLS handshake: %w", err)
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	connectJSON, err := json.Marshal(p.connect)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("eventbus: encode CONNECT: %w", err)
+	}
+	inbox := "_INBOX." + randomToken()
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\nSUB %s.* 1\r\n", connectJSON, inbox); err != nil {
+		conn.Close()
+		return fmt.Errorf("eventbus: NATS handshake: %w", err)
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("eventbus: NATS handshake: %w", err)
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return fmt.Errorf("eventbus: NATS rejected connection: %s", line)
+		}
+		if line == "PONG" {
+			break
+		}
+	}
+	p.conn, p.r, p.inbox = conn, r, inbox
+	return nil
+}
+
+type natsAckError struct {
+	code        int
+	description string
+}
+
+func (e *natsAckError) Error() string {
+	return fmt.Sprintf("eventbus: JetStream rejected message: %d %s", e.code, e.description)
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, msg outbox.BusMessage) error {
+	_ = p.conn.SetDeadline(p.deadline(ctx))
+	p.seq++
+	reply := p.inbox + "." + strconv.FormatUint(p.seq, 10)
+
+	headers := "NATS/1.0\r\n" +
+		"Nats-Msg-Id: " + headerValue(msg.ID) + "\r\n" +
+		"BrokerFlow-Topic: " + headerValue(msg.Topic) + "\r\n" +
+		"BrokerFlow-Key: " + headerValue(msg.Key) + "\r\n" +
+		"BrokerFlow-Created-At: " + msg.CreatedAt.UTC().Format(time.RFC3339Nano) + "\r\n\r\n"
+	frame := fmt.Sprintf("HPUB %s %s %d %d\r\n%s%s\r\n",
+		msg.Destination, reply, len(headers), len(headers)+len(msg.Payload), headers, msg.Payload)
+	if _, err := p.conn.Write([]byte(frame)); err != nil {
+		return fmt.Errorf("eventbus: write to NATS: %w", err)
+	}
+
+	for {
+		line, err := readLine(p.r)
+		if err != nil {
+			return fmt.Errorf("eventbus: await JetStream ack: %w", err)
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch verb {
+		case "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("eventbus: write to NATS: %w", err)
+			}
+		case "-ERR":
+			return fmt.Errorf("eventbus: NATS error: %s", args)
+		case "MSG", "HMSG":
+			subject, hdr, body, err := readMsg(p.r, verb == "HMSG", args)
+			if err != nil {
+				return err
+			}
+			if subject != reply {
+				// An ack for an earlier publish that timed out.
+				continue
+			}
+			return parseAck(hdr, body)
+		}
+	}
+}
+
+// readMsg reads the payload of a MSG or HMSG whose control line arguments
+// are args, returning the subject and the header and body parts.
+func readMsg(r *bufio.Reader, hasHeaders bool, args string) (subject, hdr string, body []byte, err error) {
+	fields := strings.Fields(args)
+	minFields := 3
+	if hasHeaders {
+		minFields = 4
+	}
+	if len(fields) < minFields {
+		return "", "", nil, fmt.Errorf("eventbus: malformed NATS message line %q", args)
+	}
+	total, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || total < 0 {
+		return "", "", nil, fmt.Errorf("eventbus: malformed NATS message line %q", args)
+	}
+	hdrLen := 0
+	if hasHeaders {
+		hdrLen, err = strconv.Atoi(fields[len(fields)-2])
+		if err != nil || hdrLen < 0 || hdrLen > total {
+			return "", "", nil, fmt.Errorf("eventbus: malformed NATS message line %q", args)
+		}
+	}
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", "", nil, fmt.Errorf("eventbus: read NATS message: %w", err)
+	}
+	return fields[0], string(buf[:hdrLen]), buf[hdrLen:total], nil
+}
+
+func parseAck(hdr string, body []byte) error {
+	// A status line such as "NATS/1.0 503" means no stream listens on the
+	// subject (no responders).
+	statusLine, _, _ := strings.Cut(hdr, "\r\n")
+	if fields := strings.Fields(statusLine); len(fields) > 1 {
+		switch fields[1] {
+		case "200":
+		case "503":
+			return ErrNoStream
+		default:
+			return fmt.Errorf("eventbus: NATS status %s", strings.Join(fields[1:], " "))
+		}
+	}
+	var ack struct {
+		Stream string `json:"stream"`
+		Error  *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &ack); err != nil {
+		return fmt.Errorf("eventbus: decode JetStream ack: %w", err)
+	}
+	if ack.Error != nil {
+		return &natsAckError{code: ack.Error.Code, description: ack.Error.Description}
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("eventbus: unexpected JetStream ack %s", body)
+	}
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// headerValue strips characters that would break the header block.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func randomToken() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
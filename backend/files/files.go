@@ -0,0 +1,297 @@
+// Package files stores attachments of referrals, agreements and disputes:
+// listing sheets, contracts and dispute evidence. Uploads are size-capped,
+// typed by their content rather than the client's claim, passed through an
+// optional virus scanner and written to a Storage (local disk or S3); the
+// metadata lives in the files table. Downloads go through short-lived
+// signed links so browsers can fetch them without an Authorization header.
+package files
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"brokerflow/clock"
+	"brokerflow/tenancy"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxSize bounds one uploaded file.
+	MaxSize = 20 << 20
+	// MaxNameLength mirrors the CHECK on files.name.
+	MaxNameLength = 255
+)
+
+var (
+	ErrNotFound        = errors.New("files: file not found")
+	ErrTargetNotFound  = errors.New("files: attachment target not found")
+	ErrInvalidTarget   = errors.New("files: unknown attachment target")
+	ErrInvalidName     = fmt.Errorf("files: file name must be 1-%d characters", MaxNameLength)
+	ErrEmpty           = errors.New("files: file is empty")
+	ErrTooLarge        = fmt.Errorf("files: file exceeds %d MiB", MaxSize>>20)
+	ErrUnsupportedType = errors.New("files: only PDF, PNG, JPEG, GIF, WebP and plain text files are accepted")
+	ErrInfected        = errors.New("files: the virus scanner rejected the file")
+	ErrNotUploader     = errors.New("files: only the uploader can delete a file")
+)
+
+// TargetType names what a file is attached to.
+type TargetType string
+
+const (
+	TargetReferral  TargetType = "referral"
+	TargetAgreement TargetType = "agreement"
+	TargetDispute   TargetType = "dispute"
+)
+
+func (t TargetType) valid() bool {
+	switch t {
+	case TargetReferral, TargetAgreement, TargetDispute:
+		return true
+	}
+	return false
+}
+
+// Target is the referral, agreement or dispute a file belongs to.
+type Target struct {
+	Type TargetType
+	ID   string
+}
+
+// Scan outcomes recorded in files.scan_status.
+const (
+	ScanClean = "clean"
+	// ScanSkipped marks files stored while no scanner was configured.
+	ScanSkipped = "unscanned"
+)
+
+// File is an attachment's metadata; the content is in Storage under
+// StorageKey.
+type File struct {
+	ID          string
+	Target      Target
+	Name        string
+	ContentType string
+	Size        int64
+	SHA256      string
+	StorageKey  string
+	ScanStatus  string
+	UploadedBy  string
+	CreatedAt   time.Time
+}
+
+// allowedTypes are the sniffed content types accepted for upload. Sniffing
+// keeps HTML and scripts out whatever extension they arrive with.
+var allowedTypes = map[string]bool{
+	"application/pdf":           true,
+	"image/png":                 true,
+	"image/jpeg":                true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"text/plain; charset=utf-8": true,
+}
+
+// Store persists file metadata and answers whether a caller may see a
+// target.
+type Store interface {
+	// Visible returns ErrTargetNotFound unless target exists and scope may
+	// read it, with the rules of the target's own list endpoint.
+	Visible(ctx context.Context, scope tenancy.Scope, target Target) error
+	Create(ctx context.Context, f File) (File, error)
+	// List returns target's files, oldest first.
+	List(ctx context.Context, target Target) ([]File, error)
+	Get(ctx context.Context, id string) (File, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// UploadParams attaches Data, named Name, to Target on behalf of Scope's
+// user.
+type UploadParams struct {
+	Scope  tenancy.Scope
+	Target Target
+	Name   string
+	Data   []byte
+}
+
+// Service uploads, lists, deletes and serves attachments.
+type Service struct {
+	store   Store
+	storage Storage
+	links   *Links
+	scanner Scanner
+	clock   clock.Clock
+}
+
+func NewService(store Store, storage Storage, links *Links) *Service {
+	return &Service{store: store, storage: storage, links: links, clock: clock.New()}
+}
+
+// WithScanner checks every upload with scanner before it is stored. Without
+// one, files are stored as ScanSkipped.
+func (s *Service) WithScanner(scanner Scanner) *Service {
+	s.scanner = scanner
+	return s
+}
+
+// WithClock overrides the time source for download links.
+func (s *Service) WithClock(c clock.Clock) *Service {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Upload validates, scans and stores a file, then records it.
+func (s *Service) Upload(ctx context.Context, params UploadParams) (File, error) {
+	if !params.Target.Type.valid() || params.Target.ID == "" {
+		return File{}, ErrInvalidTarget
+	}
+	name, err := normalizeName(params.Name)
+	if err != nil {
+		return File{}, err
+	}
+	switch {
+	case len(params.Data) == 0:
+		return File{}, ErrEmpty
+	case len(params.Data) > MaxSize:
+		return File{}, ErrTooLarge
+	}
+	contentType := http.DetectContentType(params.Data)
+	if !allowedTypes[contentType] {
+		return File{}, ErrUnsupportedType
+	}
+	if err := s.store.Visible(ctx, params.Scope, params.Target); err != nil {
+		return File{}, err
+	}
+
+	status := ScanSkipped
+	if s.scanner != nil {
+		verdict, err := s.scanner.Scan(ctx, params.Data)
+		if err != nil {
+			return File{}, fmt.Errorf("files: scan: %w", err)
+		}
+		if verdict.Infected {
+			return File{}, fmt.Errorf("%w (%s)", ErrInfected, verdict.Signature)
+		}
+		status = ScanClean
+	}
+
+	id := uuid.NewString()
+	f := File{
+		ID:          id,
+		Target:      params.Target,
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(params.Data)),
+		SHA256:      sha256Hex(params.Data),
+		StorageKey:  path.Join(string(params.Target.Type), params.Target.ID, id),
+		ScanStatus:  status,
+		UploadedBy:  params.Scope.UserID,
+	}
+	if err := s.storage.Put(ctx, f.StorageKey, params.Data, contentType); err != nil {
+		return File{}, fmt.Errorf("files: store content: %w", err)
+	}
+	created, err := s.store.Create(ctx, f)
+	if err != nil {
+		s.removeContent(ctx, f.StorageKey)
+		return File{}, err
+	}
+	return created, nil
+}
+
+// List returns the files attached to target.
+func (s *Service) List(ctx context.Context, scope tenancy.Scope, target Target) ([]File, error) {
+	if !target.Type.valid() || target.ID == "" {
+		return nil, ErrInvalidTarget
+	}
+	if err := s.store.Visible(ctx, scope, target); err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, target)
+}
+
+// Delete removes a file its caller uploaded. Files of targets the caller
+// can no longer see are reported as not found.
+func (s *Service) Delete(ctx context.Context, scope tenancy.Scope, id string) error {
+	f, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Visible(ctx, scope, f.Target); err != nil {
+		if errors.Is(err, ErrTargetNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if f.UploadedBy != scope.UserID {
+		return ErrNotUploader
+	}
+	if err := s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.removeContent(ctx, f.StorageKey)
+	return nil
+}
+
+// Link returns a signed download link for f and when it expires.
+func (s *Service) Link(f File) (string, time.Time) {
+	return s.links.URL(f.ID, s.clock.Now())
+}
+
+// Open checks a download link's signature and returns the file with its
+// content, which the caller closes.
+func (s *Service) Open(ctx context.Context, id, expires, signature string) (File, io.ReadCloser, error) {
+	if err := s.links.Verify(id, expires, signature, s.clock.Now()); err != nil {
+		return File{}, nil, err
+	}
+	f, err := s.store.Get(ctx, id)
+	if err != nil {
+		return File{}, nil, err
+	}
+	content, err := s.storage.Open(ctx, f.StorageKey)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return File{}, nil, ErrNotFound
+		}
+		return File{}, nil, fmt.Errorf("files: open content: %w", err)
+	}
+	return f, content, nil
+}
+
+// removeContent deletes stored content whose metadata is gone or was never
+// written. A failure leaves an unreferenced object behind, which is only
+// logged.
+func (s *Service) removeContent(ctx context.Context, key string) {
+	if err := s.storage.Delete(ctx, key); err != nil {
+		log.Printf("files: delete content %s: %v", key, err)
+	}
+}
+
+// normalizeName keeps the base name of a client-supplied path without
+// control characters.
+func normalizeName(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." || name == "/" || len([]rune(name)) > MaxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,273 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/awssig"
+	"brokerflow/clock"
+	"brokerflow/tenancy"
+)
+
+var pdf = []byte("%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\n")
+
+type memStore struct {
+	files   map[string]File
+	visible map[Target]bool
+}
+
+func newMemStore(visible ...Target) *memStore {
+	s := &memStore{files: map[string]File{}, visible: map[Target]bool{}}
+	for _, t := range visible {
+		s.visible[t] = true
+	}
+	return s
+}
+
+func (s *memStore) Visible(_ context.Context, _ tenancy.Scope, target Target) error {
+	if !s.visible[target] {
+		return ErrTargetNotFound
+	}
+	return nil
+}
+
+func (s *memStore) Create(_ context.Context, f File) (File, error) {
+	s.files[f.ID] = f
+	return f, nil
+}
+
+func (s *memStore) List(_ context.Context, target Target) ([]File, error) {
+	var out []File
+	for _, f := range s.files {
+		if f.Target == target {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) Get(_ context.Context, id string) (File, error) {
+	f, ok := s.files[id]
+	if !ok {
+		return File{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *memStore) Delete(_ context.Context, id string) error {
+	delete(s.files, id)
+	return nil
+}
+
+type stubScanner struct {
+	verdict Verdict
+	err     error
+}
+
+func (s stubScanner) Scan(context.Context, []byte) (Verdict, error) { return s.verdict, s.err }
+
+func newTestService(t *testing.T, visible ...Target) (*Service, *memStore, *DiskStorage) {
+	t.Helper()
+	disk, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newMemStore(visible...)
+	clk := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	return NewService(store, disk, NewLinks([]byte("secret"), time.Minute)).WithClock(clk), store, disk
+}
+
+func TestUploadStoresAndServesThroughLink(t *testing.T) {
+	referral := Target{Type: TargetReferral, ID: "r1"}
+	svc, _, _ := newTestService(t, referral)
+	ctx := context.Background()
+
+	f, err := svc.Upload(ctx, UploadParams{Scope: tenancy.User("u1"), Target: referral, Name: `C:\listings\sheet.pdf`, Data: pdf})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if f.Name != "sheet.pdf" || f.ContentType != "application/pdf" || f.UploadedBy != "u1" ||
+		f.ScanStatus != ScanSkipped || f.Size != int64(len(pdf)) || !strings.HasPrefix(f.StorageKey, "referral/r1/") {
+		t.Fatalf("unexpected file %+v", f)
+	}
+
+	link, _ := svc.Link(f)
+	u, _ := url.Parse(link)
+	q := u.Query()
+	got, content, err := svc.Open(ctx, f.ID, q.Get("expires"), q.Get("signature"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer content.Close()
+	data, _ := io.ReadAll(content)
+	if got.ID != f.ID || !bytes.Equal(data, pdf) {
+		t.Fatalf("served %q for %+v", data, got)
+	}
+}
+
+func TestUploadRejects(t *testing.T) {
+	referral := Target{Type: TargetReferral, ID: "r1"}
+	cases := []struct {
+		name    string
+		params  UploadParams
+		scanner Scanner
+		want    error
+	}{
+		{"html", UploadParams{Target: referral, Name: "x.pdf", Data: []byte("<html><script>alert(1)</script>")}, nil, ErrUnsupportedType},
+		{"empty", UploadParams{Target: referral, Name: "x.pdf"}, nil, ErrEmpty},
+		{"too large", UploadParams{Target: referral, Name: "x.pdf", Data: make([]byte, MaxSize+1)}, nil, ErrTooLarge},
+		{"no name", UploadParams{Target: referral, Name: " / ", Data: pdf}, nil, ErrInvalidName},
+		{"unknown target", UploadParams{Target: Target{Type: "invoice", ID: "i1"}, Name: "x.pdf", Data: pdf}, nil, ErrInvalidTarget},
+		{"invisible target", UploadParams{Target: Target{Type: TargetDispute, ID: "d1"}, Name: "x.pdf", Data: pdf}, nil, ErrTargetNotFound},
+		{"infected", UploadParams{Target: referral, Name: "x.pdf", Data: pdf}, stubScanner{verdict: Verdict{Infected: true, Signature: "Eicar"}}, ErrInfected},
+		{"scanner down", UploadParams{Target: referral, Name: "x.pdf", Data: pdf}, stubScanner{err: errors.New("connection refused")}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, store, _ := newTestService(t, referral)
+			if tc.scanner != nil {
+				svc.WithScanner(tc.scanner)
+			}
+			_, err := svc.Upload(context.Background(), tc.params)
+			if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+			if len(store.files) != 0 {
+				t.Fatalf("rejected upload was recorded: %+v", store.files)
+			}
+		})
+	}
+}
+
+func TestDeleteOnlyByUploader(t *testing.T) {
+	agreement := Target{Type: TargetAgreement, ID: "a1"}
+	svc, store, disk := newTestService(t, agreement)
+	svc.WithScanner(stubScanner{})
+	ctx := context.Background()
+	f, err := svc.Upload(ctx, UploadParams{Scope: tenancy.User("u1"), Target: agreement, Name: "notes.txt", Data: []byte("counter-offer accepted")})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if f.ScanStatus != ScanClean {
+		t.Fatalf("scan status = %q", f.ScanStatus)
+	}
+
+	if err := svc.Delete(ctx, tenancy.Broker("admin", "b1"), f.ID); !errors.Is(err, ErrNotUploader) {
+		t.Fatalf("other user: got %v", err)
+	}
+	delete(store.visible, agreement)
+	if err := svc.Delete(ctx, tenancy.User("u1"), f.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("invisible target: got %v", err)
+	}
+	store.visible[agreement] = true
+	if err := svc.Delete(ctx, tenancy.User("u1"), f.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := disk.Open(ctx, f.StorageKey); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("content survived delete: %v", err)
+	}
+}
+
+func TestLinksRejectTamperingAndExpiry(t *testing.T) {
+	links := NewLinks([]byte("secret"), time.Minute)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	link, expires := links.URL("f1", now)
+	u, _ := url.Parse(link)
+	q := u.Query()
+	if u.Path != "/api/files/f1/download" || !expires.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected link %s expiring %s", link, expires)
+	}
+
+	if err := links.Verify("f1", q.Get("expires"), q.Get("signature"), now); err != nil {
+		t.Fatalf("valid link: %v", err)
+	}
+	for name, check := range map[string]error{
+		"other file":     links.Verify("f2", q.Get("expires"), q.Get("signature"), now),
+		"extended":       links.Verify("f1", "9999999999", q.Get("signature"), now),
+		"expired":        links.Verify("f1", q.Get("expires"), q.Get("signature"), expires),
+		"other secret":   NewLinks([]byte("other"), time.Minute).Verify("f1", q.Get("expires"), q.Get("signature"), now),
+		"malformed time": links.Verify("f1", "soon", q.Get("signature"), now),
+	} {
+		if !errors.Is(check, ErrInvalidLink) {
+			t.Errorf("%s: got %v", name, check)
+		}
+	}
+}
+
+func TestDiskStorageRejectsEscapingKeys(t *testing.T) {
+	disk, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../outside", "/etc/passwd", "a/../../b"} {
+		if err := disk.Put(context.Background(), key, pdf, "application/pdf"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+	if err := disk.Delete(context.Background(), "referral/r1/missing"); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+}
+
+func TestS3StorageSignsPathStyleRequests(t *testing.T) {
+	var puts []*http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			puts = append(puts, r)
+			body, _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			if r.URL.Path != "/attachments/referral/r1/f1" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	s3, err := NewS3Storage(S3Config{
+		Bucket: "attachments", Region: "eu-west-1", Endpoint: srv.URL,
+		Credentials: awssig.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3.WithClock(clock.NewFake(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	if err := s3.Put(ctx, "referral/r1/f1", pdf, "application/pdf"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if len(puts) != 1 || puts[0].URL.Path != "/attachments/referral/r1/f1" ||
+		puts[0].Header.Get("X-Amz-Content-Sha256") != awssig.PayloadHash(pdf) ||
+		!strings.HasPrefix(puts[0].Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20260301/eu-west-1/s3/aws4_request") {
+		t.Fatalf("unexpected put %+v", puts)
+	}
+	content, err := s3.Open(ctx, "referral/r1/f1")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got, _ := io.ReadAll(content)
+	content.Close()
+	if !bytes.Equal(got, pdf) {
+		t.Fatalf("read back %q", got)
+	}
+	if _, err := s3.Open(ctx, "referral/r1/missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("missing object: got %v", err)
+	}
+	if err := s3.Delete(ctx, "referral/r1/f1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
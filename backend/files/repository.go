@@ -0,0 +1,168 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool}
+}
+
+// targetColumns are the files columns linking a row to each target type;
+// exactly one is set.
+var targetColumns = map[TargetType]string{
+	TargetReferral:  "referral_id",
+	TargetAgreement: "agreement_id",
+	TargetDispute:   "dispute_id",
+}
+
+const fileColumns = `id::text, referral_id::text, agreement_id::text, dispute_id::text, uploaded_by::text,
+		name, content_type, size_bytes, sha256, storage_key, scan_status, created_at`
+
+func scanFile(row pgx.Row) (File, error) {
+	var (
+		f                                  File
+		referralID, agreementID, disputeID *string
+	)
+	err := row.Scan(&f.ID, &referralID, &agreementID, &disputeID, &f.UploadedBy,
+		&f.Name, &f.ContentType, &f.Size, &f.SHA256, &f.StorageKey, &f.ScanStatus, &f.CreatedAt)
+	switch {
+	case referralID != nil:
+		f.Target = Target{Type: TargetReferral, ID: *referralID}
+	case agreementID != nil:
+		f.Target = Target{Type: TargetAgreement, ID: *agreementID}
+	case disputeID != nil:
+		f.Target = Target{Type: TargetDispute, ID: *disputeID}
+	}
+	return f, err
+}
+
+// Visible applies the scope of each target's list endpoint: referrals to
+// their owners, agreements and disputes to the parties of the agreement.
+// Disputes are also visible to every user of the referee broker, who may
+// open them (see dispute.Repository.List).
+func (r *PGRepository) Visible(ctx context.Context, scope tenancy.Scope, target Target) error {
+	var (
+		query    string
+		scopeArg any
+		extra    []any
+	)
+	switch target.Type {
+	case TargetReferral:
+		var owned string
+		owned, scopeArg = scope.OwnedBy("rr.created_by_user_id", 1)
+		query = `SELECT EXISTS (SELECT 1 FROM referral_requests rr WHERE rr.id = $2 AND ` + owned + `)`
+	case TargetAgreement:
+		var party string
+		party, scopeArg = scope.PartyTo("rr.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+		query = `SELECT EXISTS (
+			SELECT 1 FROM agreements a
+			JOIN referral_requests rr ON rr.id = a.referral_id
+			WHERE a.id = $2 AND ` + party + `)`
+	case TargetDispute:
+		var party string
+		party, scopeArg = scope.PartyTo("rr.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+		query = `SELECT EXISTS (
+			SELECT 1 FROM disputes d
+			JOIN agreements a ON a.id = d.agreement_id
+			JOIN referral_requests rr ON rr.id = a.referral_id
+			WHERE d.id = $2 AND (` + party + ` OR EXISTS (
+				SELECT 1 FROM users m WHERE m.id = $3 AND m.broker_id = a.to_broker_id)))`
+		extra = append(extra, scope.UserID)
+	default:
+		return ErrInvalidTarget
+	}
+
+	var visible bool
+	args := append([]any{scopeArg, target.ID}, extra...)
+	if err := r.pool.QueryRow(ctx, query, args...).Scan(&visible); err != nil {
+		if isInvalidUUID(err) {
+			return ErrTargetNotFound
+		}
+		return fmt.Errorf("files: check %s: %w", target.Type, err)
+	}
+	if !visible {
+		return ErrTargetNotFound
+	}
+	return nil
+}
+
+func (r *PGRepository) Create(ctx context.Context, f File) (File, error) {
+	column, ok := targetColumns[f.Target.Type]
+	if !ok {
+		return File{}, ErrInvalidTarget
+	}
+	created, err := scanFile(r.pool.QueryRow(ctx, `
+		INSERT INTO files (id, `+column+`, uploaded_by, name, content_type, size_bytes, sha256, storage_key, scan_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+fileColumns,
+		f.ID, f.Target.ID, f.UploadedBy, f.Name, f.ContentType, f.Size, f.SHA256, f.StorageKey, f.ScanStatus))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return File{}, ErrTargetNotFound
+		}
+		return File{}, fmt.Errorf("files: insert: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PGRepository) List(ctx context.Context, target Target) ([]File, error) {
+	column, ok := targetColumns[target.Type]
+	if !ok {
+		return nil, ErrInvalidTarget
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE `+column+` = $1
+		ORDER BY created_at, id
+	`, target.ID)
+	if err != nil {
+		return nil, fmt.Errorf("files: list: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (File, error) { return scanFile(row) })
+	if err != nil {
+		return nil, fmt.Errorf("files: scan: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PGRepository) Get(ctx context.Context, id string) (File, error) {
+	f, err := scanFile(r.pool.QueryRow(ctx, `SELECT `+fileColumns+` FROM files WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("files: get: %w", err)
+	}
+	return f, nil
+}
+
+func (r *PGRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM files WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("files: delete: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isInvalidUUID(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "22P02"
+}
@@ -0,0 +1,127 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"brokerflow/awssig"
+	"brokerflow/clock"
+)
+
+const s3RequestTimeout = time.Minute
+
+// S3Config locates a bucket. Endpoint is empty for AWS itself; set it for
+// S3-compatible stores such as MinIO, which are addressed path-style.
+type S3Config struct {
+	Bucket      string
+	Region      string
+	Endpoint    string
+	Credentials awssig.Credentials
+}
+
+// S3Storage keeps content in an S3 bucket through the REST API, signing
+// requests with Signature Version 4.
+type S3Storage struct {
+	base   string
+	region string
+	creds  awssig.Credentials
+	client *http.Client
+	clock  clock.Clock
+}
+
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" || cfg.Region == "" || cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("files: S3 needs a bucket, a region and credentials")
+	}
+	base := "https://" + cfg.Bucket + ".s3." + cfg.Region + ".amazonaws.com"
+	if cfg.Endpoint != "" {
+		base = strings.TrimRight(cfg.Endpoint, "/") + "/" + url.PathEscape(cfg.Bucket)
+	}
+	return &S3Storage{
+		base:   base,
+		region: cfg.Region,
+		creds:  cfg.Credentials,
+		client: &http.Client{Timeout: s3RequestTimeout},
+		clock:  clock.New(),
+	}, nil
+}
+
+func (s *S3Storage) WithClient(c *http.Client) *S3Storage {
+	s.client = c
+	return s
+}
+
+func (s *S3Storage) WithClock(c clock.Clock) *S3Storage {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = awssig.Escape(seg)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.base+"/"+strings.Join(segments, "/"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	hash := awssig.PayloadHash(body)
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+	awssig.Sign(req, hash, s.creds, s.region, "s3", s.clock.Now())
+	return s.client.Do(req)
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return fmt.Errorf("files: S3 put: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("files: S3 get: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("get", resp)
+	}
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return fmt.Errorf("files: S3 delete: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", resp)
+	}
+	return nil
+}
+
+func s3Error(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return fmt.Errorf("files: S3 %s returned %d: %s", op, resp.StatusCode, bytes.TrimSpace(body))
+}
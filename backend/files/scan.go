@@ -0,0 +1,94 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Verdict is a scanner's opinion of one file.
+type Verdict struct {
+	Infected bool
+	// Signature names what was found in an infected file.
+	Signature string
+}
+
+// Scanner checks uploads before they are stored. An error rejects the
+// upload, so a scanner outage does not let unscanned files in.
+type Scanner interface {
+	Scan(ctx context.Context, data []byte) (Verdict, error)
+}
+
+const (
+	clamdTimeout   = 30 * time.Second
+	clamdChunkSize = 64 << 10
+)
+
+// ClamdScanner streams files to a clamd daemon with the INSTREAM command.
+type ClamdScanner struct {
+	addr string
+}
+
+// NewClamdScanner talks to clamd at addr, "host:port" or a unix socket path.
+func NewClamdScanner(addr string) *ClamdScanner {
+	return &ClamdScanner{addr: addr}
+}
+
+func (c *ClamdScanner) Scan(ctx context.Context, data []byte) (Verdict, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.addr, "/") {
+		network = "unix"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, c.addr)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("clamd: dial: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(clamdTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetDeadline(deadline)
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), clamdChunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		w.Write(size[:])
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return Verdict{}, fmt.Errorf("clamd: send: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("clamd: read: %w", err)
+	}
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamdReply reads "stream: OK", "stream: <signature> FOUND" or
+// "<message> ERROR".
+func parseClamdReply(reply string) (Verdict, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return Verdict{}, fmt.Errorf("clamd: %s", result)
+	}
+}
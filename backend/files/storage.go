@@ -0,0 +1,92 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrObjectNotFound is returned by Storage.Open for a missing key.
+var ErrObjectNotFound = errors.New("files: stored object not found")
+
+// Storage holds file content under slash-separated keys.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns ErrObjectNotFound when key holds nothing.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds when key already holds nothing.
+	Delete(ctx context.Context, key string) error
+}
+
+// DiskStorage keeps content in files under a root directory, for
+// single-instance deployments and development.
+type DiskStorage struct {
+	root string
+}
+
+func NewDiskStorage(root string) (*DiskStorage, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("files: create %s: %w", root, err)
+	}
+	return &DiskStorage{root: root}, nil
+}
+
+func (d *DiskStorage) path(key string) (string, error) {
+	rel := filepath.FromSlash(key)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("files: invalid storage key %q", key)
+	}
+	return filepath.Join(d.root, rel), nil
+}
+
+// Put writes through a temporary file and renames it into place, so a
+// reader never sees partial content.
+func (d *DiskStorage) Put(_ context.Context, key string, data []byte, _ string) error {
+	dst, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (d *DiskStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (d *DiskStorage) Delete(_ context.Context, key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
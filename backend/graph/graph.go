@@ -0,0 +1,216 @@
+// Package graph serves the read-only GraphQL API. A query can fetch a
+// referral with its matches, active agreement, timeline and disputes in one
+// round trip; every level is loaded with one batched query however many
+// parents it has, and every query is limited to the caller's tenancy scope
+// exactly as the REST endpoints are.
+package graph
+
+import (
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+	"context"
+	_ "embed"
+	"errors"
+	"log"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// Limits applied to every query: how deeply fields may nest, how many
+// resolvers of one list run at once, and how long the query text may be.
+const (
+	MaxDepth       = 6
+	MaxParallelism = 10
+	MaxQueryLength = 8 << 10
+)
+
+// errInternal is what clients see in place of a source error; the error
+// itself is logged.
+var errInternal = errors.New("internal error")
+
+// Referrals lists and fetches referral requests; *referral.PGRepository
+// implements it.
+type Referrals interface {
+	List(ctx context.Context, filters referral.Filters) ([]referral.Request, db.Page, error)
+	GetMany(ctx context.Context, ids []string, scope tenancy.Scope) ([]referral.Request, error)
+}
+
+// Matches lists the matches of several referrals; *referral.PGMatchRepository
+// implements it.
+type Matches interface {
+	ListForRequests(ctx context.Context, requestIDs []string, scope tenancy.Scope) ([]referral.Match, error)
+}
+
+// Agreements fetches agreements by id and by referral;
+// *agreement.CRUDService implements it.
+type Agreements interface {
+	GetMany(ctx context.Context, scope tenancy.Scope, ids []string) ([]agreement.Record, error)
+	ActiveForReferrals(ctx context.Context, scope tenancy.Scope, referralIDs []string) ([]agreement.Record, error)
+}
+
+// Timeline lists the events of several agreements; *timeline.Repository
+// implements it.
+type Timeline interface {
+	ListForAgreements(ctx context.Context, scope tenancy.Scope, agreementIDs []string) ([]timeline.Event, error)
+}
+
+// Disputes lists the disputes of several agreements; *dispute.Repository
+// implements it.
+type Disputes interface {
+	ListForAgreements(ctx context.Context, scope tenancy.Scope, agreementIDs []string) ([]dispute.Record, error)
+}
+
+// Sources are where the schema reads from. All of them are required.
+type Sources struct {
+	Referrals  Referrals
+	Matches    Matches
+	Agreements Agreements
+	Timeline   Timeline
+	Disputes   Disputes
+}
+
+// Request is a GraphQL request as clients post it.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Schema executes queries against Sources.
+type Schema struct {
+	schema  *graphql.Schema
+	sources Sources
+}
+
+// NewSchema parses the schema and binds it to sources.
+func NewSchema(sources Sources) (*Schema, error) {
+	schema, err := graphql.ParseSchema(schemaSDL, &queryResolver{},
+		graphql.MaxDepth(MaxDepth),
+		graphql.MaxParallelism(MaxParallelism),
+		graphql.MaxQueryLength(MaxQueryLength),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{schema: schema, sources: sources}, nil
+}
+
+// Exec runs req on behalf of the caller scope describes. Errors in the
+// query and failures of a source are reported in the response, which is
+// always non-nil.
+func (s *Schema) Exec(ctx context.Context, scope tenancy.Scope, req Request) *graphql.Response {
+	ctx = context.WithValue(ctx, stateKey{}, newState(s.sources, scope))
+	return s.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
+
+type stateKey struct{}
+
+// state is what one request shares across its resolvers: the caller's scope
+// and a loader per kind of lookup, so each level of the query is fetched
+// once.
+type state struct {
+	src   Sources
+	scope tenancy.Scope
+
+	referrals  *loader[referral.Request]
+	matches    *loader[[]referral.Match]
+	agreements *loader[agreement.Record]
+	active     *loader[agreement.Record]
+	timeline   *loader[[]timeline.Event]
+	disputes   *loader[[]dispute.Record]
+}
+
+func newState(src Sources, scope tenancy.Scope) *state {
+	st := &state{src: src, scope: scope}
+	st.referrals = newLoader(func(ctx context.Context, ids []string) (map[string]referral.Request, error) {
+		list, err := src.Referrals.GetMany(ctx, ids, scope)
+		st.primeReferrals(list)
+		return index(list, err, func(r referral.Request) string { return r.ID })
+	})
+	st.matches = newLoader(func(ctx context.Context, ids []string) (map[string][]referral.Match, error) {
+		list, err := src.Matches.ListForRequests(ctx, ids, scope)
+		return group(list, err, func(m referral.Match) string { return m.RequestID })
+	})
+	st.agreements = newLoader(func(ctx context.Context, ids []string) (map[string]agreement.Record, error) {
+		list, err := src.Agreements.GetMany(ctx, scope, ids)
+		st.primeAgreements(list)
+		return index(list, err, func(a agreement.Record) string { return a.ID })
+	})
+	st.active = newLoader(func(ctx context.Context, ids []string) (map[string]agreement.Record, error) {
+		list, err := src.Agreements.ActiveForReferrals(ctx, scope, ids)
+		st.primeAgreements(list)
+		return index(list, err, func(a agreement.Record) string { return a.RequestID })
+	})
+	st.timeline = newLoader(func(ctx context.Context, ids []string) (map[string][]timeline.Event, error) {
+		list, err := src.Timeline.ListForAgreements(ctx, scope, ids)
+		return group(list, err, func(e timeline.Event) string { return e.AgreementID })
+	})
+	st.disputes = newLoader(func(ctx context.Context, ids []string) (map[string][]dispute.Record, error) {
+		list, err := src.Disputes.ListForAgreements(ctx, scope, ids)
+		return group(list, err, func(d dispute.Record) string { return d.AgreementID })
+	})
+	return st
+}
+
+// primeReferrals queues the lookups the fields of reqs make, so that each
+// is fetched for every referral of a level at once. Priming happens as soon
+// as a level is fetched, before any of its fields resolve.
+func (st *state) primeReferrals(reqs []referral.Request) {
+	for _, req := range reqs {
+		st.matches.prime(req.ID)
+		st.active.prime(req.ID)
+	}
+}
+
+// primeAgreements is primeReferrals for agreements.
+func (st *state) primeAgreements(recs []agreement.Record) {
+	for _, rec := range recs {
+		st.referrals.prime(rec.RequestID)
+		st.timeline.prime(rec.ID)
+		st.disputes.prime(rec.ID)
+	}
+}
+
+func stateFrom(ctx context.Context) *state {
+	return ctx.Value(stateKey{}).(*state)
+}
+
+// index maps each item to its key.
+func index[V any](list []V, err error, key func(V) string) (map[string]V, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]V, len(list))
+	for _, v := range list {
+		out[key(v)] = v
+	}
+	return out, nil
+}
+
+// group collects items by key, keeping their order.
+func group[V any](list []V, err error, key func(V) string) (map[string][]V, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]V)
+	for _, v := range list {
+		out[key(v)] = append(out[key(v)], v)
+	}
+	return out, nil
+}
+
+// internal logs err and hides it from the client.
+func internal(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	log.Printf("graphql: %v", err)
+	return errInternal
+}
@@ -0,0 +1,295 @@
+package graph
+
+import (
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/timeline"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	ref1 = "11111111-1111-4111-8111-111111111111"
+	ref2 = "22222222-2222-4222-8222-222222222222"
+	ref3 = "33333333-3333-4333-8333-333333333333"
+	agr1 = "aaaaaaaa-aaaa-4aaa-8aaa-aaaaaaaaaaaa"
+	agr2 = "bbbbbbbb-bbbb-4bbb-8bbb-bbbbbbbbbbbb"
+)
+
+// fakeSources serves fixed rows and records every call with the keys and
+// scope it got.
+type fakeSources struct {
+	mu     sync.Mutex
+	calls  map[string][][]string
+	scopes []tenancy.Scope
+	fail   string
+
+	referrals  []referral.Request
+	matches    []referral.Match
+	agreements []agreement.Record
+	events     []timeline.Event
+	disputes   []dispute.Record
+}
+
+func (f *fakeSources) record(method string, scope tenancy.Scope, keys []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = make(map[string][][]string)
+	}
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+	f.calls[method] = append(f.calls[method], sorted)
+	f.scopes = append(f.scopes, scope)
+	if method == f.fail {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func keep[V any](list []V, keys []string, key func(V) string) []V {
+	var out []V
+	for _, v := range list {
+		if slices.Contains(keys, key(v)) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (f *fakeSources) List(ctx context.Context, filters referral.Filters) ([]referral.Request, db.Page, error) {
+	if err := f.record("List", filters.Scope, nil); err != nil {
+		return nil, db.Page{}, err
+	}
+	return f.referrals, db.Page{Total: len(f.referrals), TotalExact: true}, nil
+}
+
+func (f *fakeSources) GetMany(ctx context.Context, ids []string, scope tenancy.Scope) ([]referral.Request, error) {
+	if err := f.record("Referrals.GetMany", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.referrals, ids, func(r referral.Request) string { return r.ID }), nil
+}
+
+func (f *fakeSources) ListForRequests(ctx context.Context, ids []string, scope tenancy.Scope) ([]referral.Match, error) {
+	if err := f.record("ListForRequests", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.matches, ids, func(m referral.Match) string { return m.RequestID }), nil
+}
+
+type fakeAgreements struct{ *fakeSources }
+
+func (f fakeAgreements) GetMany(ctx context.Context, scope tenancy.Scope, ids []string) ([]agreement.Record, error) {
+	if err := f.record("Agreements.GetMany", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.agreements, ids, func(a agreement.Record) string { return a.ID }), nil
+}
+
+func (f fakeAgreements) ActiveForReferrals(ctx context.Context, scope tenancy.Scope, ids []string) ([]agreement.Record, error) {
+	if err := f.record("ActiveForReferrals", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.agreements, ids, func(a agreement.Record) string { return a.RequestID }), nil
+}
+
+type fakeTimeline struct{ *fakeSources }
+
+func (f fakeTimeline) ListForAgreements(ctx context.Context, scope tenancy.Scope, ids []string) ([]timeline.Event, error) {
+	if err := f.record("Timeline.ListForAgreements", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.events, ids, func(e timeline.Event) string { return e.AgreementID }), nil
+}
+
+type fakeDisputes struct{ *fakeSources }
+
+func (f fakeDisputes) ListForAgreements(ctx context.Context, scope tenancy.Scope, ids []string) ([]dispute.Record, error) {
+	if err := f.record("Disputes.ListForAgreements", scope, ids); err != nil {
+		return nil, err
+	}
+	return keep(f.disputes, ids, func(d dispute.Record) string { return d.AgreementID }), nil
+}
+
+func newFakeSources() *fakeSources {
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	return &fakeSources{
+		referrals: []referral.Request{
+			{ID: ref1, Region: []string{"us-ny"}, Status: referral.StatusOpen, CreatedAt: at, UpdatedAt: at},
+			{ID: ref2, Region: []string{"us-nj"}, Status: referral.StatusOpen, CreatedAt: at, UpdatedAt: at},
+			{ID: ref3, Region: []string{"us-ct"}, Status: referral.StatusOpen, CreatedAt: at, UpdatedAt: at},
+		},
+		matches: []referral.Match{
+			{ID: "m1", RequestID: ref1, CandidateAgentID: "agent-2", State: "invited", Score: 0.9, CreatedAt: at},
+			{ID: "m2", RequestID: ref1, CandidateAgentID: "agent-3", State: "accepted", Score: 0.7, CreatedAt: at},
+			{ID: "m3", RequestID: ref2, CandidateAgentID: "agent-2", State: "invited", Score: 0.5, CreatedAt: at},
+		},
+		agreements: []agreement.Record{
+			{ID: agr1, RequestID: ref1, Status: agreement.StatusEffective, CreatedAt: at},
+			{ID: agr2, RequestID: ref2, Status: agreement.StatusDraft, CreatedAt: at},
+		},
+		events: []timeline.Event{
+			{ID: 1, AgreementID: agr1, Seq: 1, Type: timeline.TypeOfferMade, At: at, Payload: []byte(`{"price":1}`), PayloadVersion: 1},
+			{ID: 2, AgreementID: agr1, Seq: 2, Type: timeline.TypeOfferMade, At: at, Payload: []byte(`{"price":2}`), PayloadVersion: 1},
+			{ID: 3, AgreementID: agr2, Seq: 1, Type: timeline.TypeOfferMade, At: at, Payload: []byte(`{"price":3}`), PayloadVersion: 1},
+		},
+		disputes: []dispute.Record{
+			{ID: "d1", AgreementID: agr1, Status: dispute.StatusUnderReview, CreatedAt: at},
+		},
+	}
+}
+
+func newTestSchema(t *testing.T, f *fakeSources) *Schema {
+	t.Helper()
+	schema, err := NewSchema(Sources{
+		Referrals:  f,
+		Matches:    f,
+		Agreements: fakeAgreements{f},
+		Timeline:   fakeTimeline{f},
+		Disputes:   fakeDisputes{f},
+	})
+	if err != nil {
+		t.Fatalf("NewSchema: %v", err)
+	}
+	return schema
+}
+
+func TestExec_BatchesEachLevel(t *testing.T) {
+	f := newFakeSources()
+	schema := newTestSchema(t, f)
+	scope := tenancy.Broker("admin-1", "broker-1")
+
+	resp := schema.Exec(context.Background(), scope, Request{Query: `{
+		referrals {
+			total
+			items {
+				id
+				matches { id candidateAgentId }
+				activeAgreement {
+					id
+					status
+					referral { id }
+					timeline { seq payload }
+					disputes { id status }
+				}
+			}
+		}
+	}`})
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", resp.Errors)
+	}
+
+	want := map[string][][]string{
+		"List":                       {nil},
+		"ListForRequests":            {{ref1, ref2, ref3}},
+		"ActiveForReferrals":         {{ref1, ref2, ref3}},
+		"Referrals.GetMany":          {{ref1, ref2}},
+		"Timeline.ListForAgreements": {{agr1, agr2}},
+		"Disputes.ListForAgreements": {{agr1, agr2}},
+	}
+	for method, calls := range want {
+		if got := f.calls[method]; len(got) != 1 || !slices.Equal(got[0], calls[0]) {
+			t.Errorf("%s: expected one call with %v, got %v", method, calls[0], got)
+		}
+	}
+	for _, got := range f.scopes {
+		if got != scope {
+			t.Fatalf("expected every source to get the caller's scope, got %+v", got)
+		}
+	}
+
+	var data struct {
+		Referrals struct {
+			Total int
+			Items []struct {
+				ID              string
+				Matches         []struct{ ID string }
+				ActiveAgreement *struct {
+					ID       string
+					Referral struct{ ID string }
+					Timeline []struct {
+						Seq     int
+						Payload map[string]any
+					}
+					Disputes []struct{ ID string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	items := data.Referrals.Items
+	if data.Referrals.Total != 3 || len(items) != 3 {
+		t.Fatalf("unexpected page %+v", data.Referrals)
+	}
+	if len(items[0].Matches) != 2 || len(items[1].Matches) != 1 || len(items[2].Matches) != 0 {
+		t.Fatalf("matches grouped wrongly: %+v", items)
+	}
+	first := items[0].ActiveAgreement
+	if first == nil || first.ID != agr1 || first.Referral.ID != ref1 || len(first.Timeline) != 2 || len(first.Disputes) != 1 {
+		t.Fatalf("unexpected agreement %+v", first)
+	}
+	if first.Timeline[1].Payload["price"] != 2.0 {
+		t.Fatalf("expected decoded payload, got %v", first.Timeline[1].Payload)
+	}
+	if items[2].ActiveAgreement != nil {
+		t.Fatalf("expected no agreement for %s, got %+v", ref3, items[2].ActiveAgreement)
+	}
+}
+
+func TestExec_HidesWhatTheScopeCannotSee(t *testing.T) {
+	f := newFakeSources()
+	schema := newTestSchema(t, f)
+
+	resp := schema.Exec(context.Background(), tenancy.User("agent-1"), Request{
+		Query:     `query($id: ID!) { referral(id: $id) { id } agreement(id: "not-a-uuid") { id } }`,
+		Variables: map[string]any{"id": "44444444-4444-4444-8444-444444444444"},
+	})
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", resp.Errors)
+	}
+	if string(resp.Data) != `{"referral":null,"agreement":null}` {
+		t.Fatalf("unexpected data %s", resp.Data)
+	}
+	if _, ok := f.calls["Agreements.GetMany"]; ok {
+		t.Fatal("expected a malformed id not to reach the source")
+	}
+}
+
+func TestExec_HidesSourceErrors(t *testing.T) {
+	f := newFakeSources()
+	f.fail = "ListForRequests"
+	schema := newTestSchema(t, f)
+
+	resp := schema.Exec(context.Background(), tenancy.User("agent-1"), Request{
+		Query: `{ referral(id: "` + ref1 + `") { id matches { id } } }`,
+	})
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != errInternal.Error() {
+		t.Fatalf("expected one internal error, got %v", resp.Errors)
+	}
+}
+
+func TestExec_RejectsMutationsAndDeepQueries(t *testing.T) {
+	schema := newTestSchema(t, newFakeSources())
+	for name, query := range map[string]string{
+		"mutation": `mutation { referral(id: "x") { id } }`,
+		"too deep": `{ referral(id: "x") { activeAgreement { referral { activeAgreement { referral { activeAgreement { id } } } } } } }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := schema.Exec(context.Background(), tenancy.User("agent-1"), Request{Query: query})
+			if len(resp.Errors) == 0 {
+				t.Fatalf("expected the query to be rejected, got %s", resp.Data)
+			}
+		})
+	}
+}
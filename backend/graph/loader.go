@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// loader batches lookups by key for one request. Fetching a level primes
+// the loaders of the next with every key its rows will ask for, before any
+// of their fields resolve; the first load then fetches all primed keys in
+// one call, whichever order the executor runs the fields in, and later
+// loads wait for that call or hit its results.
+type loader[V any] struct {
+	fetch func(ctx context.Context, keys []string) (map[string]V, error)
+
+	mu      sync.Mutex
+	primed  []string
+	batches map[string]*batch[V]
+}
+
+type batch[V any] struct {
+	done   chan struct{}
+	values map[string]V
+	err    error
+}
+
+func newLoader[V any](fetch func(ctx context.Context, keys []string) (map[string]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, batches: make(map[string]*batch[V])}
+}
+
+// prime queues keys for the next fetch; keys already queued or fetched are
+// skipped.
+func (l *loader[V]) prime(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := l.batches[key]; !ok && !slices.Contains(l.primed, key) {
+			l.primed = append(l.primed, key)
+		}
+	}
+}
+
+// load returns the value fetched for key and whether there was one.
+func (l *loader[V]) load(ctx context.Context, key string) (V, bool, error) {
+	l.mu.Lock()
+	b, ok := l.batches[key]
+	if !ok {
+		keys := l.primed
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+		l.primed = nil
+		b = &batch[V]{done: make(chan struct{})}
+		for _, k := range keys {
+			l.batches[k] = b
+		}
+		l.mu.Unlock()
+		func() {
+			defer close(b.done)
+			b.values, b.err = l.fetch(ctx, keys)
+		}()
+	} else {
+		l.mu.Unlock()
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			var zero V
+			return zero, false, ctx.Err()
+		}
+	}
+	if b.err != nil {
+		var zero V
+		return zero, false, b.err
+	}
+	v, found := b.values[key]
+	return v, found, nil
+}
@@ -0,0 +1,285 @@
+package graph
+
+import (
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/timeline"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+type queryResolver struct{}
+
+func (*queryResolver) Referral(ctx context.Context, args struct{ ID graphql.ID }) (*referralResolver, error) {
+	id := string(args.ID)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+	st := stateFrom(ctx)
+	req, ok, err := st.referrals.load(ctx, id)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &referralResolver{st: st, req: req}, nil
+}
+
+type referralsArgs struct {
+	Status   *string
+	Region   *string
+	DealType *string
+	Page     *int32
+	PageSize *int32
+}
+
+func (*queryResolver) Referrals(ctx context.Context, args referralsArgs) (*referralPageResolver, error) {
+	st := stateFrom(ctx)
+	filters := referral.Filters{
+		Scope:    st.scope,
+		Status:   referral.Status(deref(args.Status)),
+		Region:   deref(args.Region),
+		DealType: deref(args.DealType),
+		Page:     int(deref(args.Page)),
+		PageSize: int(deref(args.PageSize)),
+	}
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+
+	items, page, err := st.src.Referrals.List(ctx, filters)
+	if err != nil {
+		return nil, internal(err)
+	}
+	st.primeReferrals(items)
+	return &referralPageResolver{
+		items:      wrapReferrals(st, items),
+		total:      page.Total,
+		totalExact: page.TotalExact,
+		hasMore:    page.HasMore,
+		page:       filters.Page,
+		pageSize:   filters.PageSize,
+	}, nil
+}
+
+func (*queryResolver) Agreement(ctx context.Context, args struct{ ID graphql.ID }) (*agreementResolver, error) {
+	id := string(args.ID)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+	st := stateFrom(ctx)
+	rec, ok, err := st.agreements.load(ctx, id)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &agreementResolver{st: st, rec: rec}, nil
+}
+
+func wrapReferrals(st *state, reqs []referral.Request) []*referralResolver {
+	out := make([]*referralResolver, len(reqs))
+	for i, req := range reqs {
+		out[i] = &referralResolver{st: st, req: req}
+	}
+	return out
+}
+
+type referralPageResolver struct {
+	items      []*referralResolver
+	total      int
+	totalExact bool
+	hasMore    bool
+	page       int
+	pageSize   int
+}
+
+func (p *referralPageResolver) Items() []*referralResolver { return p.items }
+func (p *referralPageResolver) Total() int32               { return int32(p.total) }
+func (p *referralPageResolver) TotalExact() bool           { return p.totalExact }
+func (p *referralPageResolver) HasMore() bool              { return p.hasMore }
+func (p *referralPageResolver) Page() int32                { return int32(p.page) }
+func (p *referralPageResolver) PageSize() int32            { return int32(p.pageSize) }
+
+type referralResolver struct {
+	st  *state
+	req referral.Request
+}
+
+func (r *referralResolver) ID() graphql.ID          { return graphql.ID(r.req.ID) }
+func (r *referralResolver) Region() []string        { return r.req.Region }
+func (r *referralResolver) PriceMin() float64       { return float64(r.req.PriceMin) }
+func (r *referralResolver) PriceMax() float64       { return float64(r.req.PriceMax) }
+func (r *referralResolver) Currency() string        { return string(r.req.Currency) }
+func (r *referralResolver) PropertyType() string    { return r.req.PropertyType }
+func (r *referralResolver) DealType() string        { return r.req.DealType }
+func (r *referralResolver) Languages() []string     { return r.req.Languages }
+func (r *referralResolver) Status() string          { return string(r.req.Status) }
+func (r *referralResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.req.CreatedAt} }
+func (r *referralResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.req.UpdatedAt} }
+
+func (r *referralResolver) Matches(ctx context.Context) ([]*matchResolver, error) {
+	matches, _, err := r.st.matches.load(ctx, r.req.ID)
+	if err != nil {
+		return nil, internal(err)
+	}
+	out := make([]*matchResolver, len(matches))
+	for i, m := range matches {
+		out[i] = &matchResolver{m: m}
+	}
+	return out, nil
+}
+
+func (r *referralResolver) ActiveAgreement(ctx context.Context) (*agreementResolver, error) {
+	rec, ok, err := r.st.active.load(ctx, r.req.ID)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &agreementResolver{st: r.st, rec: rec}, nil
+}
+
+type matchResolver struct {
+	m referral.Match
+}
+
+func (r *matchResolver) ID() graphql.ID               { return graphql.ID(r.m.ID) }
+func (r *matchResolver) CandidateAgentID() graphql.ID { return graphql.ID(r.m.CandidateAgentID) }
+func (r *matchResolver) State() string                { return string(r.m.State) }
+func (r *matchResolver) Score() float64               { return r.m.Score }
+func (r *matchResolver) CreatedAt() graphql.Time      { return graphql.Time{Time: r.m.CreatedAt} }
+func (r *matchResolver) ExpiresAt() *graphql.Time     { return timePtr(r.m.ExpiresAt) }
+
+type agreementResolver struct {
+	st  *state
+	rec agreement.Record
+}
+
+func (r *agreementResolver) ID() graphql.ID               { return graphql.ID(r.rec.ID) }
+func (r *agreementResolver) ReferralID() graphql.ID       { return graphql.ID(r.rec.RequestID) }
+func (r *agreementResolver) ReferrerBrokerID() graphql.ID { return graphql.ID(r.rec.ReferrerBrokerID) }
+func (r *agreementResolver) RefereeBrokerID() graphql.ID  { return graphql.ID(r.rec.RefereeBrokerID) }
+func (r *agreementResolver) Status() string               { return r.rec.Status }
+func (r *agreementResolver) FeeRate() float64             { return r.rec.FeeRate }
+func (r *agreementResolver) ProtectDays() int32           { return int32(r.rec.ProtectDays) }
+func (r *agreementResolver) Currency() string             { return string(r.rec.Currency) }
+func (r *agreementResolver) EffectiveAt() *graphql.Time   { return timePtr(r.rec.EffectiveAt) }
+func (r *agreementResolver) CreatedAt() graphql.Time      { return graphql.Time{Time: r.rec.CreatedAt} }
+
+func (r *agreementResolver) Referral(ctx context.Context) (*referralResolver, error) {
+	req, ok, err := r.st.referrals.load(ctx, r.rec.RequestID)
+	if err != nil {
+		return nil, internal(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &referralResolver{st: r.st, req: req}, nil
+}
+
+func (r *agreementResolver) Timeline(ctx context.Context) ([]*eventResolver, error) {
+	events, _, err := r.st.timeline.load(ctx, r.rec.ID)
+	if err != nil {
+		return nil, internal(err)
+	}
+	out := make([]*eventResolver, len(events))
+	for i, e := range events {
+		out[i] = &eventResolver{e: e}
+	}
+	return out, nil
+}
+
+func (r *agreementResolver) Disputes(ctx context.Context) ([]*disputeResolver, error) {
+	disputes, _, err := r.st.disputes.load(ctx, r.rec.ID)
+	if err != nil {
+		return nil, internal(err)
+	}
+	out := make([]*disputeResolver, len(disputes))
+	for i, d := range disputes {
+		out[i] = &disputeResolver{d: d}
+	}
+	return out, nil
+}
+
+type eventResolver struct {
+	e timeline.Event
+}
+
+func (r *eventResolver) ID() graphql.ID   { return graphql.ID(strconv.FormatInt(r.e.ID, 10)) }
+func (r *eventResolver) Seq() int32       { return int32(r.e.Seq) }
+func (r *eventResolver) Type() string     { return r.e.Type }
+func (r *eventResolver) At() graphql.Time { return graphql.Time{Time: r.e.At} }
+func (r *eventResolver) ActorBrokerID() *graphql.ID {
+	if r.e.ActorBroker == nil {
+		return nil
+	}
+	id := graphql.ID(*r.e.ActorBroker)
+	return &id
+}
+
+// Payload decodes the payload as GET /api/events does, falling back to the
+// raw text when it no longer decodes.
+func (r *eventResolver) Payload() *JSON {
+	payload, err := timeline.Decode(r.e.Type, r.e.PayloadVersion, r.e.Payload)
+	if err != nil {
+		payload = map[string]any{"raw": string(r.e.Payload)}
+	}
+	j := JSON(payload)
+	return &j
+}
+
+type disputeResolver struct {
+	d dispute.Record
+}
+
+func (r *disputeResolver) ID() graphql.ID                { return graphql.ID(r.d.ID) }
+func (r *disputeResolver) Status() string                { return string(r.d.Status) }
+func (r *disputeResolver) Reason() string                { return string(r.d.Reason) }
+func (r *disputeResolver) Detail() string                { return r.d.Detail }
+func (r *disputeResolver) OpenedByRole() string          { return string(r.d.OpenedByRole) }
+func (r *disputeResolver) EscalationTier() int32         { return int32(r.d.EscalationTier) }
+func (r *disputeResolver) ReviewDeadline() *graphql.Time { return timePtr(r.d.ReviewDeadline) }
+func (r *disputeResolver) CreatedAt() graphql.Time       { return graphql.Time{Time: r.d.CreatedAt} }
+func (r *disputeResolver) ResolvedAt() *graphql.Time     { return timePtr(r.d.ResolvedAt) }
+
+// JSON is the JSON scalar: any JSON object, written as is.
+type JSON map[string]any
+
+func (JSON) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+func (j *JSON) UnmarshalGraphQL(input any) error {
+	m, ok := input.(map[string]any)
+	if !ok {
+		return fmt.Errorf("graph: JSON must be an object, got %T", input)
+	}
+	*j = m
+	return nil
+}
+
+func timePtr(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
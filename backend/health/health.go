@@ -0,0 +1,108 @@
+// Package health implements liveness and readiness probes. Liveness only
+// proves the process is serving; readiness runs dependency checks so load
+// balancers stop routing to instances that cannot do useful work.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSkipped marks a check that is not applicable in the current
+// configuration. Skipped checks do not fail readiness.
+var ErrSkipped = errors.New("health: check skipped")
+
+// Check is one named readiness dependency.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result reports the outcome of a single check.
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+const (
+	StatusOK      = "ok"
+	StatusFail    = "fail"
+	StatusSkipped = "skipped"
+)
+
+// LivenessHandler answers 200 without touching any dependency.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	})
+}
+
+// ReadinessHandler runs checks concurrently, each bounded by timeout, and
+// answers 503 if any of them fails.
+func ReadinessHandler(timeout time.Duration, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), timeout, checks...)
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// Run executes checks concurrently and aggregates their results in
+// declaration order.
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Run(checkCtx)
+			res := Result{Name: c.Name, Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+			switch {
+			case errors.Is(err, ErrSkipped):
+				res.Status = StatusSkipped
+				res.Error = err.Error()
+			case err != nil:
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+			results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusFail {
+			report.Status = StatusFail
+			break
+		}
+	}
+	return report
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
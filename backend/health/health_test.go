@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadinessHandler_AllOK(t *testing.T) {
+	h := ReadinessHandler(time.Second,
+		Check{Name: "db", Run: func(context.Context) error { return nil }},
+		Check{Name: "worker", Run: func(context.Context) error { return fmt.Errorf("disabled: %w", ErrSkipped) }},
+	)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Checks[1].Status != StatusSkipped {
+		t.Fatalf("expected skipped worker check, got %+v", report.Checks[1])
+	}
+}
+
+func TestReadinessHandler_FailureReturns503(t *testing.T) {
+	h := ReadinessHandler(time.Second,
+		Check{Name: "db", Run: func(context.Context) error { return errors.New("connection refused") }},
+	)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestRun_TimeoutBoundsSlowCheck(t *testing.T) {
+	report := Run(context.Background(), 20*time.Millisecond, Check{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if report.Status != StatusFail || report.Checks[0].Status != StatusFail {
+		t.Fatalf("expected timed out check to fail, got %+v", report)
+	}
+}
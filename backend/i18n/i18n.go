@@ -0,0 +1,142 @@
+// Package i18n translates API error messages. Messages are keyed by error
+// code and loaded from the embedded messages/<language>.json files; English
+// is the source language and the fallback for codes a language lacks.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed messages/*.json
+var messageFS embed.FS
+
+// DefaultLanguage is the language of the messages handlers write and the
+// one used when no requested language is supported.
+const DefaultLanguage = "en"
+
+// Catalog holds the messages of every language.
+type Catalog struct {
+	messages map[string]map[string]string
+	// codes maps each English message back to its code, so handlers can keep
+	// writing English and have it translated.
+	codes map[string]string
+}
+
+// Load parses the embedded message files.
+func Load() (*Catalog, error) {
+	return LoadFS(messageFS, "messages")
+}
+
+// LoadFS parses every <language>.json file in dir of fsys. The file for
+// DefaultLanguage must exist.
+func LoadFS(fsys fs.FS, dir string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("i18n: list messages: %w", err)
+	}
+	c := &Catalog{messages: make(map[string]map[string]string, len(files)), codes: map[string]string{}}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("i18n: read %s: %w", name, err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("i18n: parse %s: %w", name, err)
+		}
+		c.messages[strings.TrimSuffix(path.Base(name), ".json")] = messages
+	}
+	english, ok := c.messages[DefaultLanguage]
+	if !ok {
+		return nil, fmt.Errorf("i18n: no %s messages in %s", DefaultLanguage, dir)
+	}
+	for code, message := range english {
+		c.codes[message] = code
+	}
+	return c, nil
+}
+
+// Languages lists the loaded languages in order.
+func (c *Catalog) Languages() []string {
+	out := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Codes lists the codes of the English messages in order.
+func (c *Catalog) Codes() []string {
+	out := make([]string, 0, len(c.codes))
+	for _, code := range c.codes {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Code returns the code of an English message.
+func (c *Catalog) Code(message string) (string, bool) {
+	code, ok := c.codes[message]
+	return code, ok
+}
+
+// Message returns code's message in lang, falling back to English, with
+// each {name} placeholder replaced from args, given as name, value pairs.
+func (c *Catalog) Message(lang, code string, args ...string) (string, bool) {
+	message, ok := c.messages[lang][code]
+	if !ok {
+		message, ok = c.messages[DefaultLanguage][code]
+	}
+	if !ok {
+		return "", false
+	}
+	if len(args) > 0 {
+		pairs := make([]string, 0, len(args))
+		for i := 0; i+1 < len(args); i += 2 {
+			pairs = append(pairs, "{"+args[i]+"}", args[i+1])
+		}
+		message = strings.NewReplacer(pairs...).Replace(message)
+	}
+	return message, true
+}
+
+// Negotiate picks the loaded language an Accept-Language header prefers
+// most, comparing primary subtags only ("fr-CA" selects "fr"). It returns
+// DefaultLanguage when nothing matches.
+func (c *Catalog) Negotiate(header string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		if _, ok := c.messages[primary]; ok && q > 0 {
+			choices = append(choices, choice{lang: primary, q: q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return DefaultLanguage
+	}
+	return choices[0].lang
+}
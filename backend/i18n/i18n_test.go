@@ -0,0 +1,84 @@
+package i18n_test
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"brokerflow/i18n"
+)
+
+var placeholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+// Every language must translate every code, with the same placeholders.
+func TestLoad_LanguagesCoverEveryCode(t *testing.T) {
+	c, err := i18n.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if langs := c.Languages(); !slices.Equal(langs, []string{"en", "fr", "zh"}) {
+		t.Fatalf("unexpected languages %v", langs)
+	}
+	for _, code := range c.Codes() {
+		english, _ := c.Message(i18n.DefaultLanguage, code)
+		want := placeholder.FindAllString(english, -1)
+		slices.Sort(want)
+		for _, lang := range c.Languages() {
+			got, ok := c.Message(lang, code)
+			if !ok || (lang != i18n.DefaultLanguage && got == english && len(want) == 0) {
+				t.Errorf("%s: %s is not translated", lang, code)
+			}
+			found := placeholder.FindAllString(got, -1)
+			slices.Sort(found)
+			if !slices.Equal(found, want) {
+				t.Errorf("%s: %s has placeholders %v, want %v", lang, code, found, want)
+			}
+		}
+	}
+}
+
+func TestMessage_FallsBackAndFillsPlaceholders(t *testing.T) {
+	c, err := i18n.LoadFS(fstest.MapFS{
+		"m/en.json": {Data: []byte(`{"not_found": "Not found", "slow": "Took {d}"}`)},
+		"m/fr.json": {Data: []byte(`{"slow": "A pris {d}"}`)},
+	}, "m")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if code, ok := c.Code("Not found"); !ok || code != "not_found" {
+		t.Fatalf("expected not_found, got %q", code)
+	}
+	if got, _ := c.Message("fr", "not_found"); got != "Not found" {
+		t.Fatalf("expected the English fallback, got %q", got)
+	}
+	if got, _ := c.Message("fr", "slow", "d", "5s"); got != "A pris 5s" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	if _, ok := c.Message("fr", "missing"); ok {
+		t.Fatal("expected no message for an unknown code")
+	}
+
+	if _, err := i18n.LoadFS(fstest.MapFS{"m/fr.json": {Data: []byte(`{}`)}}, "m"); err == nil {
+		t.Fatal("expected an error without English messages")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	c, err := i18n.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for header, want := range map[string]string{
+		"":                        "en",
+		"fr-CA,fr;q=0.9,en;q=0.8": "fr",
+		"de-DE,zh-Hans;q=0.5":     "zh",
+		"en;q=0.4,zh;q=0.6":       "zh",
+		"zh;q=0,fr;q=bad":         "en",
+		"*":                       "en",
+	} {
+		if got := c.Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
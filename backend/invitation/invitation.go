@@ -0,0 +1,236 @@
+// Package invitation lets broker admins invite people to their brokerage.
+// An invitation names an email address and a role; its signed token lets the
+// invitee register an account that is bound to the brokerage with that role.
+package invitation
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/clock"
+)
+
+// DefaultTTL is how long an invitation stays open when INVITATION_TTL is
+// not set.
+const DefaultTTL = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound        = errors.New("invitation: invitation not found")
+	ErrInvalidEmail    = errors.New("invitation: a valid email address is required")
+	ErrInvalidRole     = errors.New("invitation: role must be agent or broker_admin")
+	ErrEmailRegistered = errors.New("invitation: a user with this email already exists")
+	ErrNotPending      = errors.New("invitation: invitation was already accepted or revoked")
+	ErrInvalidToken    = errors.New("invitation: invitation is invalid, expired or already used")
+)
+
+// Status of an invitation, derived from its timestamps.
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"
+)
+
+type Invitation struct {
+	ID         string
+	BrokerID   string
+	Email      string
+	Role       auth.Role
+	InvitedBy  string
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	AcceptedBy *string
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Status reports where the invitation stands at now.
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return StatusAccepted
+	case i.RevokedAt != nil:
+		return StatusRevoked
+	case !now.Before(i.ExpiresAt):
+		return StatusExpired
+	}
+	return StatusPending
+}
+
+// Email is what the invitation email needs.
+type Email struct {
+	To         string
+	BrokerName string
+	Token      string
+}
+
+type Store interface {
+	// Create revokes any pending invitation of the same email to the same
+	// broker, inserts inv and enqueues invitation.created, in one
+	// transaction. It returns ErrEmailRegistered when the address already
+	// has an account.
+	Create(ctx context.Context, inv Invitation) (Invitation, error)
+	// List returns the broker's invitations, newest first.
+	List(ctx context.Context, brokerID string) ([]Invitation, error)
+	Get(ctx context.Context, id string) (Invitation, error)
+	// BrokerName names the brokerage for the invitation email.
+	BrokerName(ctx context.Context, brokerID string) (string, error)
+	// Revoke returns ErrNotFound for another broker's invitation and
+	// ErrNotPending once it has been accepted or revoked.
+	Revoke(ctx context.Context, brokerID, id string) error
+	// Accept creates the user and marks the invitation accepted in one
+	// transaction, failing with ErrInvalidToken unless it is still pending
+	// at now.
+	Accept(ctx context.Context, id string, user auth.CreateUserParams, now time.Time) (auth.User, error)
+}
+
+// UserPreparer validates a registration and hashes its password;
+// auth.Service satisfies it.
+type UserPreparer interface {
+	PrepareUser(ctx context.Context, req auth.RegisterRequest) (auth.CreateUserParams, error)
+}
+
+type Service struct {
+	store  Store
+	users  UserPreparer
+	tokens *Tokens
+	ttl    time.Duration
+	clock  clock.Clock
+}
+
+func NewService(store Store, users UserPreparer, tokens *Tokens) *Service {
+	return &Service{store: store, users: users, tokens: tokens, ttl: DefaultTTL, clock: clock.New()}
+}
+
+// WithTTL sets how long new invitations stay open.
+func (s *Service) WithTTL(ttl time.Duration) *Service {
+	if ttl > 0 {
+		s.ttl = ttl
+	}
+	return s
+}
+
+func (s *Service) WithClock(c clock.Clock) *Service {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Now is the service clock's time, for callers deriving Status.
+func (s *Service) Now() time.Time {
+	return s.clock.Now()
+}
+
+// CreateParams describe a new invitation.
+type CreateParams struct {
+	BrokerID  string
+	InvitedBy string
+	Email     string
+	Role      auth.Role
+}
+
+// Create invites params.Email to the broker and returns the invitation with
+// its token. Inviting an address again replaces its pending invitation.
+func (s *Service) Create(ctx context.Context, params CreateParams) (Invitation, string, error) {
+	addr, err := normalizeEmail(params.Email)
+	if err != nil {
+		return Invitation{}, "", err
+	}
+	role := params.Role
+	if role == "" {
+		role = auth.RoleAgent
+	}
+	if role != auth.RoleAgent && role != auth.RoleBrokerAdmin {
+		return Invitation{}, "", ErrInvalidRole
+	}
+	inv, err := s.store.Create(ctx, Invitation{
+		BrokerID:  params.BrokerID,
+		Email:     addr,
+		Role:      role,
+		InvitedBy: params.InvitedBy,
+		ExpiresAt: s.clock.Now().Add(s.ttl).Truncate(time.Second),
+	})
+	if err != nil {
+		return Invitation{}, "", err
+	}
+	return inv, s.tokens.Token(inv.ID, inv.ExpiresAt), nil
+}
+
+func (s *Service) List(ctx context.Context, brokerID string) ([]Invitation, error) {
+	return s.store.List(ctx, brokerID)
+}
+
+func (s *Service) Revoke(ctx context.Context, brokerID, id string) error {
+	return s.store.Revoke(ctx, brokerID, id)
+}
+
+// AcceptParams are what the invitee supplies; the email, role and broker
+// come from the invitation.
+type AcceptParams struct {
+	Token    string
+	FullName string
+	Password string
+}
+
+// Accept registers the invitee named by a valid token.
+func (s *Service) Accept(ctx context.Context, params AcceptParams) (auth.User, error) {
+	now := s.clock.Now()
+	id, err := s.tokens.Verify(params.Token, now)
+	if err != nil {
+		return auth.User{}, err
+	}
+	inv, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return auth.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	if inv.Status(now) != StatusPending {
+		return auth.User{}, ErrInvalidToken
+	}
+	user, err := s.users.PrepareUser(ctx, auth.RegisterRequest{
+		Email:    inv.Email,
+		Password: params.Password,
+		FullName: strings.TrimSpace(params.FullName),
+		Role:     inv.Role,
+	})
+	if err != nil {
+		return auth.User{}, err
+	}
+	user.BrokerID = &inv.BrokerID
+	return s.store.Accept(ctx, inv.ID, user, now)
+}
+
+// InvitationEmail returns what to email for invitation id, or false when it
+// is no longer pending.
+func (s *Service) InvitationEmail(ctx context.Context, id string) (Email, bool, error) {
+	inv, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return Email{}, false, nil
+	}
+	if err != nil {
+		return Email{}, false, err
+	}
+	if inv.Status(s.clock.Now()) != StatusPending {
+		return Email{}, false, nil
+	}
+	name, err := s.store.BrokerName(ctx, inv.BrokerID)
+	if err != nil {
+		return Email{}, false, err
+	}
+	return Email{To: inv.Email, BrokerName: name, Token: s.tokens.Token(inv.ID, inv.ExpiresAt)}, true, nil
+}
+
+// normalizeEmail trims and lower-cases a bare address.
+func normalizeEmail(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return s, nil
+}
@@ -0,0 +1,166 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/clock"
+)
+
+type memStore struct {
+	invitations map[string]Invitation
+	created     []auth.CreateUserParams
+}
+
+func newMemStore() *memStore {
+	return &memStore{invitations: map[string]Invitation{}}
+}
+
+func (m *memStore) Create(_ context.Context, inv Invitation) (Invitation, error) {
+	inv.ID = "inv-" + strconv.Itoa(len(m.invitations)+1)
+	m.invitations[inv.ID] = inv
+	return inv, nil
+}
+
+func (m *memStore) List(context.Context, string) ([]Invitation, error) { return nil, nil }
+
+func (m *memStore) Get(_ context.Context, id string) (Invitation, error) {
+	inv, ok := m.invitations[id]
+	if !ok {
+		return Invitation{}, ErrNotFound
+	}
+	return inv, nil
+}
+
+func (m *memStore) BrokerName(context.Context, string) (string, error) { return "Acme Realty", nil }
+
+func (m *memStore) Revoke(_ context.Context, _, id string) error {
+	inv := m.invitations[id]
+	now := time.Now()
+	inv.RevokedAt = &now
+	m.invitations[id] = inv
+	return nil
+}
+
+func (m *memStore) Accept(_ context.Context, id string, user auth.CreateUserParams, now time.Time) (auth.User, error) {
+	inv := m.invitations[id]
+	if inv.Status(now) != StatusPending {
+		return auth.User{}, ErrInvalidToken
+	}
+	userID := "user-" + id
+	inv.AcceptedAt, inv.AcceptedBy = &now, &userID
+	m.invitations[id] = inv
+	m.created = append(m.created, user)
+	return auth.User{ID: userID, Email: user.Email, Role: user.Role, BrokerID: user.BrokerID}, nil
+}
+
+func newTestService(store Store, clk clock.Clock) *Service {
+	return NewService(store, auth.NewService(nil, ""), NewTokens([]byte("secret"))).WithClock(clk)
+}
+
+func TestTokensRejectTamperingAndExpiry(t *testing.T) {
+	tokens := NewTokens([]byte("secret"))
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	token := tokens.Token("inv-1", now.Add(time.Hour))
+	signature := token[strings.LastIndex(token, ".")+1:]
+
+	if id, err := tokens.Verify(token, now); err != nil || id != "inv-1" {
+		t.Fatalf("Verify = %q, %v", id, err)
+	}
+	for name, bad := range map[string]string{
+		"other secret": NewTokens([]byte("other")).Token("inv-1", now.Add(time.Hour)),
+		"other id":     "inv-2" + strings.TrimPrefix(token, "inv-1"),
+		"extended":     "inv-1." + strconv.FormatInt(now.Add(48*time.Hour).Unix(), 10) + "." + signature,
+		"malformed":    "inv-1",
+	} {
+		if _, err := tokens.Verify(bad, now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+	if _, err := tokens.Verify(token, now.Add(time.Hour)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token: got %v", err)
+	}
+}
+
+func TestCreateValidatesEmailAndRole(t *testing.T) {
+	store := newMemStore()
+	svc := newTestService(store, clock.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	inv, token, err := svc.Create(ctx, CreateParams{BrokerID: "b1", InvitedBy: "admin", Email: "  Ana@Example.COM "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Email != "ana@example.com" || inv.Role != auth.RoleAgent || token == "" {
+		t.Fatalf("unexpected invitation %+v, token %q", inv, token)
+	}
+	if want := time.Date(2026, 5, 8, 12, 0, 0, 0, time.UTC); !inv.ExpiresAt.Equal(want) {
+		t.Fatalf("ExpiresAt = %v, want %v", inv.ExpiresAt, want)
+	}
+
+	if _, _, err := svc.Create(ctx, CreateParams{BrokerID: "b1", Email: "Ana <ana@example.com>"}); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("display name: got %v", err)
+	}
+	if _, _, err := svc.Create(ctx, CreateParams{BrokerID: "b1", Email: "c@example.com", Role: auth.RoleClient}); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("client role: got %v", err)
+	}
+}
+
+func TestAcceptBindsBrokerAndRole(t *testing.T) {
+	store := newMemStore()
+	clk := clock.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	svc := newTestService(store, clk)
+	ctx := context.Background()
+
+	_, token, err := svc.Create(ctx, CreateParams{BrokerID: "b1", InvitedBy: "admin", Email: "ana@example.com", Role: auth.RoleBrokerAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Accept(ctx, AcceptParams{Token: token, FullName: "Ana", Password: "short"}); !errors.Is(err, auth.ErrWeakPassword) {
+		t.Fatalf("weak password: got %v", err)
+	}
+	user, err := svc.Accept(ctx, AcceptParams{Token: token, FullName: " Ana Agent ", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "ana@example.com" || user.Role != auth.RoleBrokerAdmin || user.BrokerID == nil || *user.BrokerID != "b1" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if got := store.created[0]; got.FullName != "Ana Agent" || got.PasswordHash == "" || got.PasswordHash == "correct horse" {
+		t.Fatalf("unexpected user params %+v", got)
+	}
+
+	if _, err := svc.Accept(ctx, AcceptParams{Token: token, FullName: "Ana", Password: "correct horse"}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reused token: got %v", err)
+	}
+	if _, ok, _ := svc.InvitationEmail(ctx, "inv-1"); ok {
+		t.Fatal("accepted invitation still emailed")
+	}
+}
+
+func TestAcceptRejectsRevokedInvitation(t *testing.T) {
+	store := newMemStore()
+	svc := newTestService(store, clock.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	inv, token, err := svc.Create(ctx, CreateParams{BrokerID: "b1", Email: "ana@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, ok, err := svc.InvitationEmail(ctx, inv.ID)
+	if err != nil || !ok || email.Token != token || email.BrokerName != "Acme Realty" {
+		t.Fatalf("InvitationEmail = %+v, %v, %v", email, ok, err)
+	}
+	if err := svc.Revoke(ctx, "b1", inv.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Accept(ctx, AcceptParams{Token: token, FullName: "Ana", Password: "correct horse"}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked invitation: got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package invitation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/auth"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserCreator inserts a user inside a transaction; auth.PGRepository
+// satisfies it.
+type UserCreator interface {
+	CreateUserTx(ctx context.Context, tx pgx.Tx, params auth.CreateUserParams) (auth.User, error)
+}
+
+type PGRepository struct {
+	pool  *pgxpool.Pool
+	users UserCreator
+}
+
+func NewRepository(pool *pgxpool.Pool, users UserCreator) *PGRepository {
+	return &PGRepository{pool: pool, users: users}
+}
+
+const invitationColumns = `id::text, broker_id::text, email, role, invited_by::text, expires_at,
+		accepted_at, accepted_by::text, revoked_at, created_at`
+
+func scanInvitation(row pgx.Row) (Invitation, error) {
+	var inv Invitation
+	err := row.Scan(&inv.ID, &inv.BrokerID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt,
+		&inv.AcceptedAt, &inv.AcceptedBy, &inv.RevokedAt, &inv.CreatedAt)
+	return inv, err
+}
+
+func (r *PGRepository) Create(ctx context.Context, inv Invitation) (Invitation, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Invitation{}, fmt.Errorf("invitation: begin create: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var registered bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = $1 AND deleted_at IS NULL)
+	`, inv.Email).Scan(&registered); err != nil {
+		return Invitation{}, fmt.Errorf("invitation: check email: %w", err)
+	}
+	if registered {
+		return Invitation{}, ErrEmailRegistered
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE invitations SET revoked_at = get_tx_timestamp()
+		WHERE broker_id = $1 AND email = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`, inv.BrokerID, inv.Email); err != nil {
+		return Invitation{}, fmt.Errorf("invitation: revoke previous: %w", err)
+	}
+	created, err := scanInvitation(tx.QueryRow(ctx, `
+		INSERT INTO invitations (broker_id, email, role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+invitationColumns,
+		inv.BrokerID, inv.Email, inv.Role, inv.InvitedBy, inv.ExpiresAt))
+	if err != nil {
+		return Invitation{}, fmt.Errorf("invitation: insert: %w", err)
+	}
+
+	body, err := json.Marshal(InvitationCreatedPayload{
+		InvitationID: created.ID,
+		BrokerID:     created.BrokerID,
+		Role:         string(created.Role),
+		InvitedBy:    created.InvitedBy,
+	})
+	if err != nil {
+		return Invitation{}, fmt.Errorf("invitation: marshal outbox payload: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`, OutboxTopicInvitationCreated, body); err != nil {
+		return Invitation{}, fmt.Errorf("invitation: enqueue %s: %w", OutboxTopicInvitationCreated, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Invitation{}, fmt.Errorf("invitation: commit create: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PGRepository) List(ctx context.Context, brokerID string) ([]Invitation, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+invitationColumns+`
+		FROM invitations
+		WHERE broker_id = $1
+		ORDER BY created_at DESC, id
+	`, brokerID)
+	if err != nil {
+		return nil, fmt.Errorf("invitation: list: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Invitation, error) { return scanInvitation(row) })
+	if err != nil {
+		return nil, fmt.Errorf("invitation: scan: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PGRepository) Get(ctx context.Context, id string) (Invitation, error) {
+	inv, err := scanInvitation(r.pool.QueryRow(ctx, `SELECT `+invitationColumns+` FROM invitations WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+		return Invitation{}, ErrNotFound
+	}
+	if err != nil {
+		return Invitation{}, fmt.Errorf("invitation: get: %w", err)
+	}
+	return inv, nil
+}
+
+func (r *PGRepository) BrokerName(ctx context.Context, brokerID string) (string, error) {
+	var name string
+	if err := r.pool.QueryRow(ctx, `SELECT name FROM brokers WHERE id = $1`, brokerID).Scan(&name); err != nil {
+		return "", fmt.Errorf("invitation: broker name: %w", err)
+	}
+	return name, nil
+}
+
+func (r *PGRepository) Revoke(ctx context.Context, brokerID, id string) error {
+	var pending bool
+	err := r.pool.QueryRow(ctx, `
+		WITH target AS (
+			SELECT id, accepted_at IS NULL AND revoked_at IS NULL AS pending
+			FROM invitations
+			WHERE id = $1 AND broker_id = $2
+		), revoked AS (
+			UPDATE invitations SET revoked_at = get_tx_timestamp()
+			WHERE id IN (SELECT id FROM target WHERE pending)
+		)
+		SELECT pending FROM target
+	`, id, brokerID).Scan(&pending)
+	if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("invitation: revoke: %w", err)
+	}
+	if !pending {
+		return ErrNotPending
+	}
+	return nil
+}
+
+func (r *PGRepository) Accept(ctx context.Context, id string, user auth.CreateUserParams, now time.Time) (auth.User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return auth.User{}, fmt.Errorf("invitation: begin accept: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inv, err := scanInvitation(tx.QueryRow(ctx, `SELECT `+invitationColumns+` FROM invitations WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+		return auth.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return auth.User{}, fmt.Errorf("invitation: lock: %w", err)
+	}
+	if inv.Status(now) != StatusPending {
+		return auth.User{}, ErrInvalidToken
+	}
+
+	created, err := r.users.CreateUserTx(ctx, tx, user)
+	if err != nil {
+		return auth.User{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE invitations SET accepted_at = get_tx_timestamp(), accepted_by = $2 WHERE id = $1
+	`, id, created.ID); err != nil {
+		return auth.User{}, fmt.Errorf("invitation: mark accepted: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return auth.User{}, fmt.Errorf("invitation: commit accept: %w", err)
+	}
+	return created, nil
+}
+
+func isInvalidUUID(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "22P02"
+}
@@ -0,0 +1,54 @@
+package invitation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tokens signs invitation tokens with an HMAC over the invitation id and
+// expiry. The token is derived, not stored, so the email sender can rebuild
+// it from the invitation row.
+type Tokens struct {
+	secret []byte
+}
+
+func NewTokens(secret []byte) *Tokens {
+	return &Tokens{secret: secret}
+}
+
+// Token returns "<id>.<expires unix>.<signature>".
+func (t *Tokens) Token(id string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return id + "." + unix + "." + t.sign(id, unix)
+}
+
+// Verify returns the invitation id of a well-signed token that has not
+// expired at now.
+func (t *Tokens) Verify(token string, now time.Time) (string, error) {
+	id, rest, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	expires, signature, ok := strings.Cut(rest, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(t.sign(id, expires))) {
+		return "", ErrInvalidToken
+	}
+	return id, nil
+}
+
+func (t *Tokens) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte("invitation\n" + id + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
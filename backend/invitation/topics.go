@@ -0,0 +1,28 @@
+package invitation
+
+import "brokerflow/outbox"
+
+// OutboxTopicInvitationCreated is published when a broker admin invites
+// someone; the email notifier sends the invitation.
+const OutboxTopicInvitationCreated = "invitation.created"
+
+// InvitationCreatedPayload is published on invitation.created. It carries
+// no token: the notifier derives it from the invitation row.
+type InvitationCreatedPayload struct {
+	InvitationID string `json:"invitation_id"`
+	BrokerID     string `json:"broker_id"`
+	Role         string `json:"role"`
+	InvitedBy    string `json:"invited_by"`
+}
+
+// OutboxTopics declares the topics this package enqueues.
+func OutboxTopics() []outbox.Topic {
+	return []outbox.Topic{
+		{
+			Name:        OutboxTopicInvitationCreated,
+			Producer:    "invitation",
+			Description: "A broker admin invited an email address to join their brokerage; the invitee is emailed a registration link.",
+			Payload:     InvitationCreatedPayload{},
+		},
+	}
+}
@@ -41,8 +41,9 @@ BEGIN
 END;
 $$;
 
-DROP FUNCTION IF EXISTS get_tx_timestamp() CASCADE;
-
+-- get_tx_timestamp() is replaced in place, never dropped: migrations re-run
+-- on every boot, and a DROP ... CASCADE would strip the column defaults of
+-- every table that uses it.
 CREATE OR REPLACE FUNCTION get_tx_timestamp() RETURNS TIMESTAMPTZ AS $$
 BEGIN
     RETURN transaction_timestamp();
@@ -58,29 +59,6 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
-ALTER TABLE IF EXISTS brokers ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS users ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS users ALTER COLUMN updated_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS referrals ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS referral_requests ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS referral_requests ALTER COLUMN updated_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS referral_matches ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS agreements ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS agreements ALTER COLUMN updated_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS agreements ALTER COLUMN status_updated_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS agreements_region_audit ALTER COLUMN changed_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS timeline_events ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS timeline_events ALTER COLUMN ts SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS pii_contacts ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS audit_logs ALTER COLUMN ts SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS outbox ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS idempotency ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS edge_invocations ALTER COLUMN first_attempt_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS edge_invocations ALTER COLUMN last_attempt_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS invoices ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS disputes ALTER COLUMN created_at SET DEFAULT get_tx_timestamp();
-ALTER TABLE IF EXISTS disputes ALTER COLUMN updated_at SET DEFAULT get_tx_timestamp();
-
 CREATE TABLE IF NOT EXISTS brokers (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     name TEXT NOT NULL UNIQUE,
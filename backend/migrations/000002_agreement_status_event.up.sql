@@ -0,0 +1,6 @@
+-- 000002_agreement_status_event.up.sql
+-- StatusService records explicit transitions as AGREEMENT_STATUS_CHANGED
+-- timeline events; the baseline enum predates it. Must run outside an
+-- explicit transaction block on older servers, so no BEGIN/COMMIT here.
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AGREEMENT_STATUS_CHANGED';
@@ -0,0 +1,8 @@
+-- 000003_outbox_worker_heartbeats.up.sql
+-- Outbox delivery workers upsert a row per poll loop; /readyz reads the
+-- freshest beat to decide whether the outbox is being drained.
+
+CREATE TABLE IF NOT EXISTS outbox_worker_heartbeats (
+    worker_id TEXT PRIMARY KEY,
+    beat_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
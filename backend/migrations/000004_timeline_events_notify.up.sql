@@ -0,0 +1,19 @@
+-- 000004_timeline_events_notify.up.sql
+-- Announce every timeline insert so /api/agreements/{id}/events/stream can
+-- push it live. The payload is only the agreement id: subscribers re-read the
+-- table, which keeps NOTIFY under its 8000-byte limit and respects the same
+-- visibility rules as the REST endpoints.
+
+CREATE OR REPLACE FUNCTION timeline_events_notify()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('timeline_events', NEW.agreement_id::text);
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_timeline_events_notify ON timeline_events;
+
+CREATE TRIGGER trg_timeline_events_notify
+AFTER INSERT ON timeline_events
+FOR EACH ROW EXECUTE FUNCTION timeline_events_notify();
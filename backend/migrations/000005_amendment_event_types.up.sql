@@ -0,0 +1,8 @@
+-- 000005_amendment_event_types.up.sql
+-- Timeline event types for the amendment flow (see 000006). Kept apart from
+-- the table DDL because new enum values cannot be used in the transaction
+-- that adds them.
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AMENDMENT_PROPOSED';
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AMENDMENT_ACCEPTED';
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AMENDMENT_REJECTED';
@@ -0,0 +1,42 @@
+-- 000006_agreement_amendments.up.sql
+-- Either broker party may propose new fee/protect terms while an agreement is
+-- pending_signature; the counterparty accepts or rejects. At most one
+-- proposal is open per agreement, and the agreement cannot become effective
+-- while one is open.
+
+CREATE TABLE IF NOT EXISTS agreement_amendments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agreement_id UUID NOT NULL REFERENCES agreements(id),
+    proposed_by_user_id UUID NOT NULL REFERENCES users(id),
+    proposed_by_broker_id UUID NOT NULL REFERENCES brokers(id),
+    fee_rate NUMERIC(5,2) NOT NULL CHECK (fee_rate > 0 AND fee_rate <= 100),
+    protect_days INTEGER NOT NULL CHECK (protect_days > 0),
+    note TEXT,
+    status TEXT NOT NULL DEFAULT 'proposed' CHECK (status IN ('proposed','accepted','rejected')),
+    responded_by_user_id UUID REFERENCES users(id),
+    responded_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS agreement_amendments_one_open
+    ON agreement_amendments(agreement_id)
+    WHERE status = 'proposed';
+
+CREATE OR REPLACE FUNCTION guard_agreement_open_amendment()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    IF NEW.status = 'effective' AND OLD.status IS DISTINCT FROM NEW.status AND EXISTS (
+        SELECT 1 FROM agreement_amendments
+        WHERE agreement_id = NEW.id AND status = 'proposed'
+    ) THEN
+        RAISE EXCEPTION 'agreement % has an open amendment', NEW.id;
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_agreements_open_amendment ON agreements;
+
+CREATE TRIGGER trg_agreements_open_amendment
+BEFORE UPDATE ON agreements
+FOR EACH ROW EXECUTE FUNCTION guard_agreement_open_amendment();
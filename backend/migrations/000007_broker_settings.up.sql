@@ -0,0 +1,18 @@
+-- 000007_broker_settings.up.sql
+-- Per-broker referral policy. The referring broker's row supplies the terms
+-- of agreements created from an accepted match; brokers without a row use the
+-- application defaults (30%, 90 days).
+
+CREATE TABLE IF NOT EXISTS broker_settings (
+    broker_id UUID PRIMARY KEY REFERENCES brokers(id),
+    default_fee_rate NUMERIC(5,2) NOT NULL,
+    min_fee_rate NUMERIC(5,2) NOT NULL,
+    max_fee_rate NUMERIC(5,2) NOT NULL,
+    default_protect_days INTEGER NOT NULL,
+    min_protect_days INTEGER NOT NULL,
+    max_protect_days INTEGER NOT NULL,
+    updated_by_user_id UUID REFERENCES users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CHECK (min_fee_rate > 0 AND min_fee_rate <= default_fee_rate AND default_fee_rate <= max_fee_rate AND max_fee_rate <= 100),
+    CHECK (min_protect_days > 0 AND min_protect_days <= default_protect_days AND default_protect_days <= max_protect_days)
+);
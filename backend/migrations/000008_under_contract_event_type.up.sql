@@ -0,0 +1,5 @@
+-- 000008_under_contract_event_type.up.sql
+-- Deal lifecycle: OFFER_MADE -> UNDER_CONTRACT -> DEAL_CLOSED. The new value
+-- is added on its own so it is committed before 000009 refers to it.
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'UNDER_CONTRACT';
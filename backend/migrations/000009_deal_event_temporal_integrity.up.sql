@@ -0,0 +1,28 @@
+-- 000009_deal_event_temporal_integrity.up.sql
+-- UNDER_CONTRACT is a deal milestone like OFFER_MADE and DEAL_CLOSED, so it is
+-- held to the same rule: only on an effective (or later) agreement, never
+-- before effective_at.
+
+CREATE OR REPLACE FUNCTION check_temporal_integrity() RETURNS TRIGGER AS $$
+DECLARE
+    agreement_eff_time TIMESTAMPTZ;
+    agreement_state agreement_status;
+    event_timestamp TIMESTAMPTZ;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext(NEW.agreement_id::text));
+    event_timestamp := COALESCE(NEW.ts, get_tx_timestamp());
+
+    SELECT effective_at, status INTO agreement_eff_time, agreement_state
+    FROM agreements WHERE id = NEW.agreement_id FOR UPDATE;
+
+    IF NEW.type IN ('OFFER_MADE','UNDER_CONTRACT','ESIGN_COMPLETED','DEAL_CLOSED') THEN
+        IF agreement_state NOT IN ('effective','success','disputed') THEN
+            RAISE EXCEPTION 'Temporal violation: agreement % state % cannot accept event %', NEW.agreement_id, agreement_state, NEW.type;
+        END IF;
+        IF agreement_eff_time IS NULL OR event_timestamp < agreement_eff_time THEN
+            RAISE EXCEPTION 'Temporal violation: event precedes effective time for agreement %', NEW.agreement_id;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+-- 000010_protect_expiry_enum_values.up.sql
+-- Enum values for protect-period expiry, committed before 000011 uses them.
+
+ALTER TYPE agreement_status ADD VALUE IF NOT EXISTS 'expired';
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'PROTECT_EXPIRED';
@@ -0,0 +1,100 @@
+-- 000011_protect_period_expiry.up.sql
+-- An effective agreement whose protect period (effective_at + protect_days)
+-- ends without a DEAL_CLOSED event becomes 'expired'. Agreements with
+-- protect_days = 0 have no protect period and never expire. Commission claims
+-- (invoices) on an expired or lapsed agreement are rejected.
+
+-- expired keeps effective_at: the protect period it ran from stays visible.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_constraint
+        WHERE conname = 'chk_agreement_effective_at_pair'
+          AND conrelid = 'agreements'::regclass
+          AND pg_get_constraintdef(oid) LIKE '%expired%'
+    ) THEN
+        ALTER TABLE agreements DROP CONSTRAINT IF EXISTS chk_agreement_effective_at_pair;
+        ALTER TABLE agreements
+            ADD CONSTRAINT chk_agreement_effective_at_pair CHECK (
+                (status IN ('effective','success','disputed','expired') AND effective_at IS NOT NULL)
+                OR
+                (status IN ('draft','pending_signature','void','closed') AND effective_at IS NULL)
+            );
+    END IF;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION agreement_validate_transition(prev agreement_status, next agreement_status)
+RETURNS BOOLEAN LANGUAGE plpgsql AS $$
+BEGIN
+    IF prev = next THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'draft' AND next IN ('pending_signature', 'void') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'pending_signature' AND next IN ('effective', 'void') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'effective' AND next IN ('success', 'disputed', 'void', 'closed', 'expired') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'disputed' AND next IN ('void', 'closed') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'success' AND next = 'closed' THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev IN ('void', 'expired') AND next = 'closed' THEN
+        RETURN TRUE;
+    END IF;
+
+    RETURN FALSE;
+END;
+$$;
+
+CREATE INDEX IF NOT EXISTS agreements_effective_protect_idx
+    ON agreements(effective_at)
+    WHERE status = 'effective' AND protect_days > 0;
+
+CREATE OR REPLACE FUNCTION guard_commission_claim()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+DECLARE
+    ag RECORD;
+    deadline TIMESTAMPTZ;
+BEGIN
+    SELECT status, effective_at, protect_days INTO ag
+    FROM agreements WHERE id = NEW.agreement_id;
+
+    IF ag.status = 'expired' THEN
+        RAISE EXCEPTION 'commission claim rejected: protect period of agreement % has expired', NEW.agreement_id;
+    END IF;
+
+    IF ag.protect_days > 0 AND ag.effective_at IS NOT NULL THEN
+        deadline := ag.effective_at + make_interval(days => ag.protect_days);
+        IF get_tx_timestamp() > deadline AND NOT EXISTS (
+            SELECT 1 FROM timeline_events
+            WHERE agreement_id = NEW.agreement_id
+              AND type = 'DEAL_CLOSED'
+              AND ts <= deadline
+        ) THEN
+            RAISE EXCEPTION 'commission claim rejected: agreement % closed no deal within its protect period', NEW.agreement_id;
+        END IF;
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_invoices_commission_claim ON invoices;
+
+CREATE TRIGGER trg_invoices_commission_claim
+BEFORE INSERT ON invoices
+FOR EACH ROW EXECUTE FUNCTION guard_commission_claim();
@@ -0,0 +1,18 @@
+-- 000012_api_keys.up.sql
+-- API keys for server-to-server integrations (e-sign, CRM). A key acts as the
+-- user who created it, limited to its scopes. Only the SHA-256 of the key is
+-- stored; prefix is the non-secret leading part shown in listings.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL CHECK (length(btrim(name)) > 0),
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL CHECK (cardinality(scopes) > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id, created_at DESC);
@@ -0,0 +1,21 @@
+-- 000013_two_factor.up.sql
+-- Optional TOTP second factor. A row with enabled_at NULL is an enrollment
+-- awaiting its first code; last_used_step stops a code being replayed within
+-- its validity window. Recovery codes are stored as SHA-256 and used once.
+
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    UNIQUE (user_id, code_hash)
+);
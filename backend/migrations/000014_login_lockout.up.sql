@@ -0,0 +1,26 @@
+-- 000014_login_lockout.up.sql
+-- Brute-force protection for /auth/login. Every attempt is recorded with the
+-- caller's IP; account_lockouts keeps the running failure count and the
+-- current lock, whose length doubles with each consecutive lock. Locks and
+-- unlocks are also written to audit_logs.
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    email TEXT NOT NULL,
+    ip TEXT,
+    succeeded BOOLEAN NOT NULL,
+    reason TEXT,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_user_idx ON login_attempts (user_id, attempted_at DESC);
+CREATE INDEX IF NOT EXISTS login_attempts_ip_failed_idx ON login_attempts (ip, attempted_at DESC) WHERE NOT succeeded;
+
+CREATE TABLE IF NOT EXISTS account_lockouts (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    failed_count INTEGER NOT NULL DEFAULT 0 CHECK (failed_count >= 0),
+    lock_count INTEGER NOT NULL DEFAULT 0 CHECK (lock_count >= 0),
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
@@ -0,0 +1,55 @@
+-- 000015_account_erasure.up.sql
+-- DELETE /api/me soft-deletes the caller: users.deleted_at is set and the
+-- PII columns are overwritten in place, so agreements, timeline events and
+-- audit rows keep pointing at a valid (anonymous) user. The client contacts
+-- the user supplied are kept for a retention window and then hard-deleted by
+-- the purge job, which works through pii_erasures.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS pii_erasures (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    purge_after TIMESTAMPTZ NOT NULL,
+    purged_at TIMESTAMPTZ,
+    purged_contacts INTEGER NOT NULL DEFAULT 0 CHECK (purged_contacts >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS pii_erasures_due_idx ON pii_erasures (purge_after) WHERE purged_at IS NULL;
+
+-- pii_contacts is closed to the application by RLS, so deletion goes through
+-- a SECURITY DEFINER function like reads do (get_pii_contact). Each purged
+-- contact is recorded in audit_logs against its agreement.
+CREATE OR REPLACE FUNCTION purge_user_pii(p_user UUID)
+RETURNS INTEGER
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+    n INTEGER;
+BEGIN
+    WITH purged AS (
+        DELETE FROM pii_contacts c
+        USING agreements a, referral_requests rr
+        WHERE c.agreement_id = a.id
+          AND a.referral_id = rr.id
+          AND rr.created_by_user_id = p_user
+        RETURNING c.agreement_id
+    ), audited AS (
+        INSERT INTO audit_logs(agreement_id, actor_id, action, metadata, ts)
+        SELECT agreement_id, NULL, 'PII_PURGED',
+               jsonb_build_object('source', 'purge_user_pii', 'user_id', p_user),
+               get_tx_timestamp()
+        FROM purged
+        RETURNING 1
+    )
+    SELECT COUNT(*) INTO n FROM audited;
+
+    DELETE FROM pii_data d
+    USING referrals r
+    WHERE d.referral_id = r.id AND r.created_by_user_id = p_user;
+
+    RETURN n;
+END;
+$$;
@@ -0,0 +1,4 @@
+-- 000016_match_expired_state.up.sql
+-- Enum value for stale invitations, committed before 000017 uses it.
+
+ALTER TYPE referral_match_state ADD VALUE IF NOT EXISTS 'expired';
@@ -0,0 +1,57 @@
+-- 000017_match_expiry.up.sql
+-- Invitations expire. Each referral request carries a match TTL; when a match
+-- enters 'invited' (on insert, or when an expired invitation is re-sent) the
+-- trigger stamps invited_at and expires_at from it. The match expiry job
+-- moves invitations past expires_at to 'expired', after which the owner may
+-- invite the same candidate again.
+
+ALTER TABLE referral_requests
+    ADD COLUMN IF NOT EXISTS match_ttl_hours INTEGER NOT NULL DEFAULT 72;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_referral_match_ttl' AND conrelid = 'referral_requests'::regclass
+    ) THEN
+        ALTER TABLE referral_requests
+            ADD CONSTRAINT chk_referral_match_ttl CHECK (match_ttl_hours > 0 AND match_ttl_hours <= 720);
+    END IF;
+END;
+$$;
+
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS invited_at TIMESTAMPTZ;
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+-- Existing invitations get the default TTL from their creation time.
+UPDATE referral_matches m
+SET invited_at = m.created_at,
+    expires_at = m.created_at + make_interval(hours => rr.match_ttl_hours)
+FROM referral_requests rr
+WHERE rr.id = m.request_id AND m.state = 'invited' AND m.expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_referral_matches_invite_expiry
+    ON referral_matches (expires_at) WHERE state = 'invited';
+
+CREATE OR REPLACE FUNCTION referral_matches_set_expiry()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+DECLARE
+    ttl INTEGER;
+BEGIN
+    IF NEW.state <> 'invited' THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND OLD.state = 'invited' THEN
+        RETURN NEW;
+    END IF;
+    SELECT match_ttl_hours INTO ttl FROM referral_requests WHERE id = NEW.request_id;
+    NEW.invited_at := get_tx_timestamp();
+    NEW.expires_at := get_tx_timestamp() + make_interval(hours => COALESCE(ttl, 72));
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_referral_matches_set_expiry ON referral_matches;
+CREATE TRIGGER trg_referral_matches_set_expiry
+BEFORE INSERT OR UPDATE OF state ON referral_matches
+FOR EACH ROW EXECUTE FUNCTION referral_matches_set_expiry();
@@ -0,0 +1,24 @@
+-- 000018_match_decline_reason.up.sql
+-- Candidates may say why they declined: a reason from a fixed list plus an
+-- optional note ("other" requires one; enforced by the API). Owners see both
+-- in the matches list.
+
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS decline_reason TEXT;
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS decline_note TEXT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_referral_match_decline_reason' AND conrelid = 'referral_matches'::regclass
+    ) THEN
+        ALTER TABLE referral_matches
+            ADD CONSTRAINT chk_referral_match_decline_reason CHECK (
+                decline_reason IS NULL
+                OR (state = 'declined' AND decline_reason IN (
+                    'not_my_area','no_capacity','price_range','property_type','language','fee_terms','other'
+                ))
+            );
+    END IF;
+END;
+$$;
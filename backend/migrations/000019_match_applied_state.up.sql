@@ -0,0 +1,4 @@
+-- 000019_match_applied_state.up.sql
+-- Enum value for candidate-initiated matches, committed before 000020 uses it.
+
+ALTER TYPE referral_match_state ADD VALUE IF NOT EXISTS 'applied';
@@ -0,0 +1,17 @@
+-- 000020_marketplace.up.sql
+-- Referral marketplace. Agents opt in with the regions (and optionally
+-- languages) they serve; the feed then lists open referrals from other agents
+-- that overlap, without the creator's identity. Applying inserts a match in
+-- state 'applied'; the owner accepts an application by inviting the
+-- candidate, which moves the same row to 'invited'.
+
+CREATE TABLE IF NOT EXISTS marketplace_subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    regions TEXT[] NOT NULL CHECK (cardinality(regions) > 0),
+    languages TEXT[] NOT NULL DEFAULT '{}'::text[],
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_open_created
+    ON referral_requests (created_at DESC) WHERE status = 'open';
@@ -0,0 +1,18 @@
+-- 000021_agent_profiles.up.sql
+-- What an agent covers: served regions, languages, an optional price
+-- specialty, property types and license numbers. Terms are stored
+-- lower-cased. Profiles feed candidate scoring (agentprofile.Score), e.g. the
+-- score of a marketplace application.
+
+CREATE TABLE IF NOT EXISTS agent_profiles (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    regions TEXT[] NOT NULL DEFAULT '{}'::text[],
+    languages TEXT[] NOT NULL DEFAULT '{}'::text[],
+    price_min BIGINT CHECK (price_min > 0),
+    price_max BIGINT CHECK (price_max > 0),
+    property_types TEXT[] NOT NULL DEFAULT '{}'::text[],
+    licenses JSONB NOT NULL DEFAULT '[]'::jsonb CHECK (jsonb_typeof(licenses) = 'array'),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CHECK (price_min IS NULL OR price_max IS NULL OR price_min <= price_max)
+);
@@ -0,0 +1,22 @@
+-- 000022_reviews.up.sql
+-- After DEAL_CLOSED the referral owner and the receiving agent may each
+-- review the other once per agreement. Submitting a review recomputes the
+-- reviewee's users.rating (average, two decimals) and users.review_count in
+-- the same transaction, with the reviewee's row locked so concurrent reviews
+-- see each other.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS review_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agreement_id UUID NOT NULL REFERENCES agreements(id),
+    reviewer_id UUID NOT NULL REFERENCES users(id),
+    reviewee_id UUID NOT NULL REFERENCES users(id),
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    UNIQUE (agreement_id, reviewer_id),
+    CHECK (reviewer_id <> reviewee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_reviewee ON reviews (reviewee_id, created_at DESC);
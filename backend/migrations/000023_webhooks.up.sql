@@ -0,0 +1,26 @@
+-- 000023_webhooks.up.sql
+-- Partner webhooks. A broker registers callback URLs with the outbox topics
+-- they want; the outbox worker records one edge_invocations row per matching
+-- message and subscriber (route 'webhook:<id>', key = outbox id), and the
+-- webhook deliverer POSTs due rows, backing off exponentially on failure.
+-- Only messages concerning the broker (an agreement it is party to, or a
+-- referral created by or offered to one of its agents) are delivered.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    broker_id UUID NOT NULL REFERENCES brokers(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    topics TEXT[] NOT NULL CHECK (cardinality(topics) > 0),
+    secret TEXT NOT NULL,
+    created_by_user_id UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_broker ON webhooks (broker_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhooks_topics ON webhooks USING GIN (topics);
+
+ALTER TABLE edge_invocations ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE edge_invocations ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_edge_invocations_due
+    ON edge_invocations (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,15 @@
+-- 000024_email_preferences.up.sql
+-- Per-user opt-outs for transactional emails, one flag per template. A user
+-- without a row receives every kind. Sent emails are recorded in
+-- edge_invocations (route 'email:<user id>', key = outbox id) so a retried
+-- outbox message does not email the same person twice.
+
+CREATE TABLE IF NOT EXISTS email_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    match_invited BOOLEAN NOT NULL DEFAULT true,
+    agreement_ready_to_sign BOOLEAN NOT NULL DEFAULT true,
+    agreement_effective BOOLEAN NOT NULL DEFAULT true,
+    dispute_opened BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
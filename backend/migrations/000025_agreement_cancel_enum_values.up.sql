@@ -0,0 +1,5 @@
+-- 000025_agreement_cancel_enum_values.up.sql
+-- Enum values for agreement cancellation, committed before 000026 uses them.
+
+ALTER TYPE agreement_status ADD VALUE IF NOT EXISTS 'cancelled';
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AGREEMENT_CANCELLED';
@@ -0,0 +1,71 @@
+-- 000026_agreement_cancellation.up.sql
+-- Either broker party may cancel an agreement that is not yet effective
+-- (draft or pending_signature), giving a reason. The agreement becomes
+-- 'cancelled', which frees the referral for another agreement, and a
+-- referral that was 'matched' goes back to 'open'.
+
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS cancelled_by UUID REFERENCES users(id);
+
+-- cancelled never carried an effective_at: only unsigned agreements cancel.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_constraint
+        WHERE conname = 'chk_agreement_effective_at_pair'
+          AND conrelid = 'agreements'::regclass
+          AND pg_get_constraintdef(oid) LIKE '%cancelled%'
+    ) THEN
+        ALTER TABLE agreements DROP CONSTRAINT IF EXISTS chk_agreement_effective_at_pair;
+        ALTER TABLE agreements
+            ADD CONSTRAINT chk_agreement_effective_at_pair CHECK (
+                (status IN ('effective','success','disputed','expired') AND effective_at IS NOT NULL)
+                OR
+                (status IN ('draft','pending_signature','void','closed','cancelled') AND effective_at IS NULL)
+            );
+    END IF;
+END;
+$$;
+
+ALTER TABLE agreements DROP CONSTRAINT IF EXISTS chk_agreement_cancel_reason;
+ALTER TABLE agreements
+    ADD CONSTRAINT chk_agreement_cancel_reason CHECK (
+        status <> 'cancelled' OR (cancel_reason IS NOT NULL AND btrim(cancel_reason) <> '')
+    );
+
+CREATE OR REPLACE FUNCTION agreement_validate_transition(prev agreement_status, next agreement_status)
+RETURNS BOOLEAN LANGUAGE plpgsql AS $$
+BEGIN
+    IF prev = next THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'draft' AND next IN ('pending_signature', 'void', 'cancelled') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'pending_signature' AND next IN ('effective', 'void', 'cancelled') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'effective' AND next IN ('success', 'disputed', 'void', 'closed', 'expired') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'disputed' AND next IN ('void', 'closed') THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev = 'success' AND next = 'closed' THEN
+        RETURN TRUE;
+    END IF;
+
+    IF prev IN ('void', 'expired') AND next = 'closed' THEN
+        RETURN TRUE;
+    END IF;
+
+    RETURN FALSE;
+END;
+$$;
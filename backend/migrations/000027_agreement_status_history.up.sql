@@ -0,0 +1,27 @@
+-- 000027_agreement_status_history.up.sql
+-- Read model of the time each agreement spent in each status, projected from
+-- timeline_events by agreement.HistoryProjector. Rows are rebuilt per
+-- agreement from its timeline, so the table can be truncated together with
+-- projection_offsets to replay it. exited_at and duration stay NULL for the
+-- current status.
+
+CREATE TABLE IF NOT EXISTS agreement_status_history (
+    agreement_id UUID NOT NULL REFERENCES agreements(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    status TEXT NOT NULL,
+    entered_at TIMESTAMPTZ NOT NULL,
+    exited_at TIMESTAMPTZ,
+    duration INTERVAL GENERATED ALWAYS AS (exited_at - entered_at) STORED,
+    PRIMARY KEY (agreement_id, event_id),
+    CONSTRAINT chk_status_history_order CHECK (exited_at IS NULL OR exited_at >= entered_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_history_status_entered
+    ON agreement_status_history (status, entered_at);
+
+-- Last timeline_events.id each projection has consumed.
+CREATE TABLE IF NOT EXISTS projection_offsets (
+    name TEXT PRIMARY KEY,
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
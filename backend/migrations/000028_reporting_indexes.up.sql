@@ -0,0 +1,16 @@
+-- 000028_reporting_indexes.up.sql
+-- Indexes backing report.Repository. The summary filters referrals by
+-- creator and creation time, agreements by party brokerage and creation time,
+-- and probes disputes per agreement.
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_creator_created
+    ON referral_requests (created_by_user_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_from_broker_created
+    ON agreements (from_broker_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_to_broker_created
+    ON agreements (to_broker_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_agreement
+    ON disputes (agreement_id);
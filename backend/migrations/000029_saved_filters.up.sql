@@ -0,0 +1,26 @@
+-- 000029_saved_filters.up.sql
+-- Named filter sets for the referrals list, per user. Empty strings mean
+-- "any". With notify set, referral.created emails the owner when a new
+-- referral they can see (brokerage list or marketplace) matches the filter;
+-- new_referral_match lets them switch those emails off globally.
+
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT '',
+    region TEXT NOT NULL DEFAULT '',
+    deal_type TEXT NOT NULL DEFAULT '',
+    sort_key TEXT NOT NULL DEFAULT '',
+    sort_order TEXT NOT NULL DEFAULT '',
+    notify BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CONSTRAINT uq_saved_filters_user_name UNIQUE (user_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_filters_notify
+    ON saved_filters (deal_type, region)
+    WHERE notify;
+
+ALTER TABLE email_preferences
+    ADD COLUMN IF NOT EXISTS new_referral_match BOOLEAN NOT NULL DEFAULT true;
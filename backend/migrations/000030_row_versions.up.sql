@@ -0,0 +1,37 @@
+-- 000030_row_versions.up.sql
+-- Row versions for optimistic concurrency. The API exposes version as the
+-- ETag of referrals and agreements and requires If-Match on updates. A
+-- trigger bumps it whenever a column clients see changes, whichever code
+-- path writes the row; bookkeeping columns (updated_at, event_seq, ...) do
+-- not, so appending timeline events does not invalidate a client's ETag.
+
+ALTER TABLE referral_requests
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_row_version()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_referral_requests_version ON referral_requests;
+CREATE TRIGGER trg_referral_requests_version
+BEFORE UPDATE ON referral_requests
+FOR EACH ROW
+WHEN ((OLD.region, OLD.price_min, OLD.price_max, OLD.property_type, OLD.deal_type, OLD.languages,
+       OLD.sla_hours, OLD.match_ttl_hours, OLD.status, OLD.cancel_reason)
+      IS DISTINCT FROM
+      (NEW.region, NEW.price_min, NEW.price_max, NEW.property_type, NEW.deal_type, NEW.languages,
+       NEW.sla_hours, NEW.match_ttl_hours, NEW.status, NEW.cancel_reason))
+EXECUTE FUNCTION bump_row_version();
+
+DROP TRIGGER IF EXISTS trg_agreements_version ON agreements;
+CREATE TRIGGER trg_agreements_version
+BEFORE UPDATE ON agreements
+FOR EACH ROW
+WHEN ((OLD.status, OLD.effective_at, OLD.fee_rate, OLD.protect_days, OLD.from_broker_id, OLD.to_broker_id)
+      IS DISTINCT FROM
+      (NEW.status, NEW.effective_at, NEW.fee_rate, NEW.protect_days, NEW.from_broker_id, NEW.to_broker_id))
+EXECUTE FUNCTION bump_row_version();
@@ -0,0 +1,46 @@
+-- 000031_dispute_sla.up.sql
+-- Review deadlines for disputes. A dispute is opened under_review with a
+-- review_deadline; once it passes, the escalation job moves the dispute to
+-- escalated, assigns a broker_admin of one of the agreement's brokers as
+-- reviewer and sets the next deadline. Each further miss raises
+-- escalation_tier and hands the dispute to another admin, up to the
+-- configured number of tiers. Resolving clears the deadline.
+
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS review_deadline TIMESTAMPTZ;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS escalation_tier INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS assigned_reviewer_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Disputes opened before deadlines existed get the default review SLA.
+UPDATE disputes
+SET review_deadline = created_at + INTERVAL '72 hours'
+WHERE status = 'under_review' AND review_deadline IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_disputes_review_deadline
+    ON disputes (review_deadline)
+    WHERE status IN ('under_review', 'escalated') AND review_deadline IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_disputes_assigned_reviewer
+    ON disputes (assigned_reviewer_id)
+    WHERE status = 'escalated';
+
+CREATE OR REPLACE FUNCTION disputes_resolve_guard()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    IF OLD.status <> 'resolved' AND NEW.status = 'resolved' THEN
+        UPDATE agreements SET status = 'disputed'
+        WHERE id = NEW.agreement_id AND status <> 'disputed';
+
+        UPDATE invoices
+        SET is_invalidated = TRUE
+        WHERE agreement_id = NEW.agreement_id
+          AND status NOT IN ('paid','written_off');
+
+        NEW.resolved_at := get_tx_timestamp();
+        NEW.review_deadline := NULL;
+    END IF;
+
+    NEW.updated_at := get_tx_timestamp();
+    RETURN NEW;
+END;
+$$;
@@ -0,0 +1,28 @@
+-- 000032_referral_archive.up.sql
+-- Archived referrals. The owner archives a closed or cancelled referral to
+-- drop it from the default list, and a retention job archives cancelled
+-- referrals that have not changed for a while. Archiving hides the referral
+-- from GET /api/referrals unless includeArchived=true; nothing is deleted.
+
+ALTER TABLE referral_requests ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_unarchived
+    ON referral_requests (created_by_user_id, created_at DESC)
+    WHERE archived_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_cancelled_unarchived
+    ON referral_requests (updated_at)
+    WHERE status = 'cancelled' AND archived_at IS NULL;
+
+-- Archiving is visible to clients, so it bumps the row version like the
+-- other client-visible columns.
+DROP TRIGGER IF EXISTS trg_referral_requests_version ON referral_requests;
+CREATE TRIGGER trg_referral_requests_version
+BEFORE UPDATE ON referral_requests
+FOR EACH ROW
+WHEN ((OLD.region, OLD.price_min, OLD.price_max, OLD.property_type, OLD.deal_type, OLD.languages,
+       OLD.sla_hours, OLD.match_ttl_hours, OLD.status, OLD.cancel_reason, OLD.archived_at)
+      IS DISTINCT FROM
+      (NEW.region, NEW.price_min, NEW.price_max, NEW.property_type, NEW.deal_type, NEW.languages,
+       NEW.sla_hours, NEW.match_ttl_hours, NEW.status, NEW.cancel_reason, NEW.archived_at))
+EXECUTE FUNCTION bump_row_version();
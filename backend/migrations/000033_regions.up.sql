@@ -0,0 +1,240 @@
+-- 000033_regions.up.sql
+-- Canonical regions. Referrals, agent profiles and marketplace subscriptions
+-- name regions by code ("us-ny-brooklyn") instead of free text, so
+-- "Brooklyn" and "brooklyn, NY" resolve to the same region. Regions form a
+-- tree through parent_code; path lists a region's ancestors and itself, root
+-- first, and is kept by trigger. Aliases are alternative spellings in
+-- canonical form (see region_canonical) and should be unique across regions:
+-- an alias shared by two regions resolves to neither. boundary optionally
+-- holds a GeoJSON polygon; when PostGIS is installed it is mirrored into
+-- geom and region_expand also follows polygon containment.
+
+CREATE TABLE IF NOT EXISTS regions (
+    code TEXT PRIMARY KEY CHECK (code ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('country', 'state', 'county', 'city', 'district')),
+    parent_code TEXT REFERENCES regions(code),
+    path TEXT[] NOT NULL DEFAULT '{}'::text[],
+    aliases TEXT[] NOT NULL DEFAULT '{}'::text[],
+    boundary JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS idx_regions_parent ON regions (parent_code);
+CREATE INDEX IF NOT EXISTS idx_regions_path ON regions USING GIN (path);
+CREATE INDEX IF NOT EXISTS idx_regions_aliases ON regions USING GIN (aliases);
+
+-- Regions are reference data: a region's parent is fixed once inserted, so
+-- the paths of its descendants never go stale.
+CREATE OR REPLACE FUNCTION regions_set_path()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.parent_code IS DISTINCT FROM OLD.parent_code THEN
+        RAISE EXCEPTION 'region % cannot change parent', OLD.code;
+    END IF;
+    IF NEW.parent_code IS NULL THEN
+        NEW.path := ARRAY[NEW.code];
+    ELSE
+        SELECT path || NEW.code INTO NEW.path FROM regions WHERE code = NEW.parent_code;
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_regions_path ON regions;
+CREATE TRIGGER trg_regions_path
+BEFORE INSERT OR UPDATE ON regions
+FOR EACH ROW EXECUTE FUNCTION regions_set_path();
+
+-- region_canonical lower-cases value and joins its runs of letters and
+-- digits with hyphens; region.Canonical is the Go twin.
+CREATE OR REPLACE FUNCTION region_canonical(value TEXT)
+RETURNS TEXT LANGUAGE sql IMMUTABLE AS $$
+    SELECT trim(both '-' from regexp_replace(lower(value), '[^a-z0-9]+', '-', 'g'))
+$$;
+
+-- region_resolve maps a code or alias, in any spelling, to its region code,
+-- or NULL when it names no region or an ambiguous alias.
+CREATE OR REPLACE FUNCTION region_resolve(value TEXT)
+RETURNS TEXT LANGUAGE sql STABLE AS $$
+    SELECT coalesce(
+        (SELECT code FROM regions WHERE code = region_canonical(value)),
+        (SELECT min(code) FROM regions WHERE region_canonical(value) = ANY (aliases) HAVING count(*) = 1)
+    )
+$$;
+
+-- region_codes resolves each value; values naming no region (free text
+-- stored before regions existed) are kept lower-cased so they still match
+-- the same text elsewhere.
+CREATE OR REPLACE FUNCTION region_codes(vals TEXT[])
+RETURNS TEXT[] LANGUAGE sql STABLE AS $$
+    SELECT coalesce(array_agg(DISTINCT coalesce(region_resolve(v), lower(trim(v)))), '{}'::text[])
+    FROM unnest(vals) AS v
+$$;
+
+-- region_expand adds every ancestor and descendant of the resolved regions.
+-- regions_overlap is then true when some region of a contains, or lies
+-- within, some region of b.
+CREATE OR REPLACE FUNCTION region_expand(vals TEXT[])
+RETURNS TEXT[] LANGUAGE sql STABLE AS $$
+    SELECT coalesce(array_agg(DISTINCT c), '{}'::text[]) FROM (
+        SELECT unnest(region_codes(vals)) AS c
+        UNION
+        SELECT g.code
+        FROM regions x
+        JOIN regions g ON g.path @> ARRAY[x.code] OR x.path @> ARRAY[g.code]
+        WHERE x.code = ANY (region_codes(vals))
+    ) s
+$$;
+
+CREATE OR REPLACE FUNCTION regions_overlap(a TEXT[], b TEXT[])
+RETURNS BOOLEAN LANGUAGE sql STABLE AS $$
+    SELECT region_codes(a) && region_expand(b)
+$$;
+
+-- With PostGIS, regions with a boundary also contain the regions whose
+-- boundary they cover, wherever those sit in the tree. The extension is
+-- optional: without it (or the rights to create it) only the tree is used.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis') THEN
+        BEGIN
+            CREATE EXTENSION IF NOT EXISTS postgis;
+        EXCEPTION WHEN OTHERS THEN
+            RAISE NOTICE 'postgis unavailable, regions use the code hierarchy only: %', SQLERRM;
+            RETURN;
+        END;
+    END IF;
+
+    EXECUTE $ddl$
+        ALTER TABLE regions ADD COLUMN IF NOT EXISTS geom geometry(MultiPolygon, 4326)
+            GENERATED ALWAYS AS (ST_Multi(ST_SetSRID(ST_GeomFromGeoJSON(boundary::text), 4326))) STORED
+    $ddl$;
+    EXECUTE 'CREATE INDEX IF NOT EXISTS idx_regions_geom ON regions USING GIST (geom)';
+    EXECUTE $fn$
+        CREATE OR REPLACE FUNCTION region_expand(vals TEXT[])
+        RETURNS TEXT[] LANGUAGE sql STABLE AS $body$
+            SELECT coalesce(array_agg(DISTINCT c), '{}'::text[]) FROM (
+                SELECT unnest(region_codes(vals)) AS c
+                UNION
+                SELECT g.code
+                FROM regions x
+                JOIN regions g ON g.path @> ARRAY[x.code] OR x.path @> ARRAY[g.code]
+                WHERE x.code = ANY (region_codes(vals))
+                UNION
+                SELECT g.code
+                FROM regions x
+                JOIN regions g ON ST_Covers(g.geom, x.geom) OR ST_Covers(x.geom, g.geom)
+                WHERE x.code = ANY (region_codes(vals)) AND x.geom IS NOT NULL AND g.geom IS NOT NULL
+            ) s
+        $body$
+    $fn$;
+END;
+$$;
+
+INSERT INTO regions (code, name, kind, parent_code, aliases) VALUES
+    ('us', 'United States', 'country', NULL, ARRAY['usa', 'united-states'])
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO regions (code, name, kind, parent_code, aliases) VALUES
+    ('us-al', 'Alabama', 'state', 'us', ARRAY['al', 'alabama']),
+    ('us-ak', 'Alaska', 'state', 'us', ARRAY['ak', 'alaska']),
+    ('us-az', 'Arizona', 'state', 'us', ARRAY['az', 'arizona']),
+    ('us-ar', 'Arkansas', 'state', 'us', ARRAY['ar', 'arkansas']),
+    ('us-ca', 'California', 'state', 'us', ARRAY['ca', 'california']),
+    ('us-co', 'Colorado', 'state', 'us', ARRAY['co', 'colorado']),
+    ('us-ct', 'Connecticut', 'state', 'us', ARRAY['ct', 'connecticut']),
+    ('us-de', 'Delaware', 'state', 'us', ARRAY['de', 'delaware']),
+    ('us-dc', 'District of Columbia', 'state', 'us', ARRAY['dc', 'district-of-columbia']),
+    ('us-fl', 'Florida', 'state', 'us', ARRAY['fl', 'florida']),
+    ('us-ga', 'Georgia', 'state', 'us', ARRAY['ga', 'georgia']),
+    ('us-hi', 'Hawaii', 'state', 'us', ARRAY['hi', 'hawaii']),
+    ('us-id', 'Idaho', 'state', 'us', ARRAY['id', 'idaho']),
+    ('us-il', 'Illinois', 'state', 'us', ARRAY['il', 'illinois']),
+    ('us-in', 'Indiana', 'state', 'us', ARRAY['in', 'indiana']),
+    ('us-ia', 'Iowa', 'state', 'us', ARRAY['ia', 'iowa']),
+    ('us-ks', 'Kansas', 'state', 'us', ARRAY['ks', 'kansas']),
+    ('us-ky', 'Kentucky', 'state', 'us', ARRAY['ky', 'kentucky']),
+    ('us-la', 'Louisiana', 'state', 'us', ARRAY['la', 'louisiana']),
+    ('us-me', 'Maine', 'state', 'us', ARRAY['me', 'maine']),
+    ('us-md', 'Maryland', 'state', 'us', ARRAY['md', 'maryland']),
+    ('us-ma', 'Massachusetts', 'state', 'us', ARRAY['ma', 'massachusetts']),
+    ('us-mi', 'Michigan', 'state', 'us', ARRAY['mi', 'michigan']),
+    ('us-mn', 'Minnesota', 'state', 'us', ARRAY['mn', 'minnesota']),
+    ('us-ms', 'Mississippi', 'state', 'us', ARRAY['ms', 'mississippi']),
+    ('us-mo', 'Missouri', 'state', 'us', ARRAY['mo', 'missouri']),
+    ('us-mt', 'Montana', 'state', 'us', ARRAY['mt', 'montana']),
+    ('us-ne', 'Nebraska', 'state', 'us', ARRAY['ne', 'nebraska']),
+    ('us-nv', 'Nevada', 'state', 'us', ARRAY['nv', 'nevada']),
+    ('us-nh', 'New Hampshire', 'state', 'us', ARRAY['nh', 'new-hampshire']),
+    ('us-nj', 'New Jersey', 'state', 'us', ARRAY['nj', 'new-jersey']),
+    ('us-nm', 'New Mexico', 'state', 'us', ARRAY['nm', 'new-mexico']),
+    ('us-ny', 'New York', 'state', 'us', ARRAY['ny', 'new-york']),
+    ('us-nc', 'North Carolina', 'state', 'us', ARRAY['nc', 'north-carolina']),
+    ('us-nd', 'North Dakota', 'state', 'us', ARRAY['nd', 'north-dakota']),
+    ('us-oh', 'Ohio', 'state', 'us', ARRAY['oh', 'ohio']),
+    ('us-ok', 'Oklahoma', 'state', 'us', ARRAY['ok', 'oklahoma']),
+    ('us-or', 'Oregon', 'state', 'us', ARRAY['or', 'oregon']),
+    ('us-pa', 'Pennsylvania', 'state', 'us', ARRAY['pa', 'pennsylvania']),
+    ('us-ri', 'Rhode Island', 'state', 'us', ARRAY['ri', 'rhode-island']),
+    ('us-sc', 'South Carolina', 'state', 'us', ARRAY['sc', 'south-carolina']),
+    ('us-sd', 'South Dakota', 'state', 'us', ARRAY['sd', 'south-dakota']),
+    ('us-tn', 'Tennessee', 'state', 'us', ARRAY['tn', 'tennessee']),
+    ('us-tx', 'Texas', 'state', 'us', ARRAY['tx', 'texas']),
+    ('us-ut', 'Utah', 'state', 'us', ARRAY['ut', 'utah']),
+    ('us-vt', 'Vermont', 'state', 'us', ARRAY['vt', 'vermont']),
+    ('us-va', 'Virginia', 'state', 'us', ARRAY['va', 'virginia']),
+    ('us-wa', 'Washington', 'state', 'us', ARRAY['wa', 'washington']),
+    ('us-wv', 'West Virginia', 'state', 'us', ARRAY['wv', 'west-virginia']),
+    ('us-wi', 'Wisconsin', 'state', 'us', ARRAY['wi', 'wisconsin']),
+    ('us-wy', 'Wyoming', 'state', 'us', ARRAY['wy', 'wyoming'])
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO regions (code, name, kind, parent_code, aliases) VALUES
+    ('us-ny-new-york-city', 'New York City', 'city', 'us-ny', ARRAY['nyc', 'new-york-city', 'new-york-ny', 'new-york-city-ny']),
+    ('us-ca-los-angeles', 'Los Angeles', 'city', 'us-ca', ARRAY['los-angeles', 'la-ca', 'los-angeles-ca']),
+    ('us-ca-san-francisco', 'San Francisco', 'city', 'us-ca', ARRAY['san-francisco', 'sf', 'san-francisco-ca']),
+    ('us-ca-san-diego', 'San Diego', 'city', 'us-ca', ARRAY['san-diego', 'san-diego-ca']),
+    ('us-il-chicago', 'Chicago', 'city', 'us-il', ARRAY['chicago', 'chicago-il']),
+    ('us-tx-houston', 'Houston', 'city', 'us-tx', ARRAY['houston', 'houston-tx']),
+    ('us-tx-dallas', 'Dallas', 'city', 'us-tx', ARRAY['dallas', 'dallas-tx']),
+    ('us-tx-austin', 'Austin', 'city', 'us-tx', ARRAY['austin', 'austin-tx']),
+    ('us-az-phoenix', 'Phoenix', 'city', 'us-az', ARRAY['phoenix', 'phoenix-az']),
+    ('us-pa-philadelphia', 'Philadelphia', 'city', 'us-pa', ARRAY['philadelphia', 'philly', 'philadelphia-pa']),
+    ('us-fl-miami', 'Miami', 'city', 'us-fl', ARRAY['miami', 'miami-fl']),
+    ('us-ga-atlanta', 'Atlanta', 'city', 'us-ga', ARRAY['atlanta', 'atlanta-ga']),
+    ('us-ma-boston', 'Boston', 'city', 'us-ma', ARRAY['boston', 'boston-ma']),
+    ('us-wa-seattle', 'Seattle', 'city', 'us-wa', ARRAY['seattle', 'seattle-wa']),
+    ('us-co-denver', 'Denver', 'city', 'us-co', ARRAY['denver', 'denver-co'])
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO regions (code, name, kind, parent_code, aliases) VALUES
+    ('us-ny-manhattan', 'Manhattan', 'district', 'us-ny-new-york-city', ARRAY['manhattan', 'manhattan-ny', 'manhattan-nyc', 'new-york-county', 'new-york-county-ny']),
+    ('us-ny-brooklyn', 'Brooklyn', 'district', 'us-ny-new-york-city', ARRAY['brooklyn', 'brooklyn-ny', 'brooklyn-nyc', 'kings-county', 'kings-county-ny']),
+    ('us-ny-queens', 'Queens', 'district', 'us-ny-new-york-city', ARRAY['queens', 'queens-ny', 'queens-nyc', 'queens-county', 'queens-county-ny']),
+    ('us-ny-bronx', 'The Bronx', 'district', 'us-ny-new-york-city', ARRAY['bronx', 'the-bronx', 'bronx-ny', 'bronx-nyc', 'bronx-county', 'bronx-county-ny']),
+    ('us-ny-staten-island', 'Staten Island', 'district', 'us-ny-new-york-city', ARRAY['staten-island', 'staten-island-ny', 'staten-island-nyc', 'richmond-county', 'richmond-county-ny'])
+ON CONFLICT (code) DO NOTHING;
+
+-- Profiles and subscriptions are matched against on every feed and score,
+-- so resolve their free-text regions once. Referrals keep their stored
+-- values; queries resolve them through region_codes.
+UPDATE agent_profiles p
+SET regions = ARRAY(
+    SELECT c FROM (
+        SELECT coalesce(region_resolve(v), v) AS c, min(o) AS o
+        FROM unnest(p.regions) WITH ORDINALITY AS t(v, o)
+        GROUP BY 1
+    ) s ORDER BY o
+)
+WHERE EXISTS (SELECT 1 FROM unnest(p.regions) AS v WHERE region_resolve(v) IS DISTINCT FROM v AND region_resolve(v) IS NOT NULL);
+
+UPDATE marketplace_subscriptions m
+SET regions = ARRAY(
+    SELECT c FROM (
+        SELECT coalesce(region_resolve(v), v) AS c, min(o) AS o
+        FROM unnest(m.regions) WITH ORDINALITY AS t(v, o)
+        GROUP BY 1
+    ) s ORDER BY o
+)
+WHERE EXISTS (SELECT 1 FROM unnest(m.regions) AS v WHERE region_resolve(v) IS DISTINCT FROM v AND region_resolve(v) IS NOT NULL);
@@ -0,0 +1,49 @@
+-- 000034_currency.up.sql
+-- Currencies for referral prices and agreements. Prices stay whole units;
+-- currency says which. Existing rows are USD, as prices always implicitly
+-- were. An agreement is settled in its referral's currency, copied when the
+-- agreement is created; changing the referral's currency later does not
+-- move existing agreements. Which codes are supported is decided by the
+-- money package; the constraint only requires an ISO 4217 shape.
+
+ALTER TABLE referral_requests ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'chk_referral_requests_currency') THEN
+        ALTER TABLE referral_requests
+            ADD CONSTRAINT chk_referral_requests_currency CHECK (currency ~ '^[A-Z]{3}$');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'chk_agreements_currency') THEN
+        ALTER TABLE agreements
+            ADD CONSTRAINT chk_agreements_currency CHECK (currency ~ '^[A-Z]{3}$');
+    END IF;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION agreements_copy_currency()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    SELECT currency INTO NEW.currency FROM referral_requests WHERE id = NEW.referral_id;
+    NEW.currency := coalesce(NEW.currency, 'USD');
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_agreements_currency ON agreements;
+CREATE TRIGGER trg_agreements_currency
+BEFORE INSERT ON agreements
+FOR EACH ROW EXECUTE FUNCTION agreements_copy_currency();
+
+-- Currency is part of the price, so changing it bumps the row version.
+DROP TRIGGER IF EXISTS trg_referral_requests_version ON referral_requests;
+CREATE TRIGGER trg_referral_requests_version
+BEFORE UPDATE ON referral_requests
+FOR EACH ROW
+WHEN ((OLD.region, OLD.price_min, OLD.price_max, OLD.currency, OLD.property_type, OLD.deal_type, OLD.languages,
+       OLD.sla_hours, OLD.match_ttl_hours, OLD.status, OLD.cancel_reason, OLD.archived_at)
+      IS DISTINCT FROM
+      (NEW.region, NEW.price_min, NEW.price_max, NEW.currency, NEW.property_type, NEW.deal_type, NEW.languages,
+       NEW.sla_hours, NEW.match_ttl_hours, NEW.status, NEW.cancel_reason, NEW.archived_at))
+EXECUTE FUNCTION bump_row_version();
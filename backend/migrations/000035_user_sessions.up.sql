@@ -0,0 +1,20 @@
+-- 000035_user_sessions.up.sql
+-- Login sessions. Every token issued by /auth/login or /auth/login/2fa
+-- names its session in the sid claim; revoking the session invalidates the
+-- token before it expires. Sessions keep the device (User-Agent) and IP of
+-- the login and when the token was last used, throttled to once a minute.
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_active_idx
+    ON user_sessions (user_id, last_seen_at DESC)
+    WHERE revoked_at IS NULL;
@@ -0,0 +1,6 @@
+-- 000036_outbox_admin.up.sql
+-- Indexes for the outbox admin API: dead letters (status 'failed') are
+-- listed by last attempt, and delivered messages are purged by it.
+
+CREATE INDEX IF NOT EXISTS idx_outbox_failed ON outbox (last_attempt DESC) WHERE status = 'failed';
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered ON outbox (last_attempt) WHERE status = 'delivered';
@@ -0,0 +1,57 @@
+-- 000037_broker_offices.up.sql
+-- Offices of a brokerage. Agents are assigned to at most one office of their
+-- own broker; reports and lists can be filtered by office. With
+-- scope_admins set, broker admins assigned to the office see only the
+-- referrals of its agents instead of the whole brokerage.
+
+CREATE TABLE IF NOT EXISTS broker_offices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    broker_id UUID NOT NULL REFERENCES brokers(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (btrim(name) <> '' AND char_length(name) <= 200),
+    scope_admins BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_broker_offices_name
+    ON broker_offices (broker_id, lower(name));
+
+DROP TRIGGER IF EXISTS trg_broker_offices_updated_at ON broker_offices;
+
+CREATE TRIGGER trg_broker_offices_updated_at
+BEFORE UPDATE ON broker_offices
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS office_id UUID REFERENCES broker_offices(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_office
+    ON users (office_id)
+    WHERE office_id IS NOT NULL;
+
+-- An office assignment must stay within the user's brokerage. A user who
+-- moves to another broker leaves their office; assigning a foreign office
+-- is rejected.
+CREATE OR REPLACE FUNCTION users_office_same_broker()
+RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    IF NEW.office_id IS NULL OR EXISTS (
+        SELECT 1 FROM broker_offices o
+        WHERE o.id = NEW.office_id AND o.broker_id = NEW.broker_id
+    ) THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.office_id = OLD.office_id THEN
+        NEW.office_id := NULL;
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'office % does not belong to the broker of user %', NEW.office_id, NEW.id
+        USING ERRCODE = 'check_violation';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_users_office_same_broker ON users;
+
+CREATE TRIGGER trg_users_office_same_broker
+BEFORE INSERT OR UPDATE OF office_id, broker_id ON users
+FOR EACH ROW EXECUTE FUNCTION users_office_same_broker();
@@ -0,0 +1,33 @@
+-- 000038_files.up.sql
+-- Attachments of referrals, agreements and disputes. The content lives in
+-- the configured file storage under storage_key; this table holds what the
+-- API needs to list, authorize and serve it. Each file belongs to exactly
+-- one target and goes when the target does.
+
+CREATE TABLE IF NOT EXISTS files (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    referral_id UUID REFERENCES referral_requests(id) ON DELETE CASCADE,
+    agreement_id UUID REFERENCES agreements(id) ON DELETE CASCADE,
+    dispute_id UUID REFERENCES disputes(id) ON DELETE CASCADE,
+    uploaded_by UUID NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL CHECK (btrim(name) <> '' AND char_length(name) <= 255),
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    sha256 TEXT NOT NULL CHECK (sha256 ~ '^[0-9a-f]{64}$'),
+    storage_key TEXT NOT NULL UNIQUE,
+    scan_status TEXT NOT NULL CHECK (scan_status IN ('clean', 'unscanned')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CONSTRAINT chk_files_one_target CHECK (num_nonnulls(referral_id, agreement_id, dispute_id) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_files_referral
+    ON files (referral_id, created_at)
+    WHERE referral_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_files_agreement
+    ON files (agreement_id, created_at)
+    WHERE agreement_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_files_dispute
+    ON files (dispute_id, created_at)
+    WHERE dispute_id IS NOT NULL;
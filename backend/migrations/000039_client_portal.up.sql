@@ -0,0 +1,12 @@
+-- 000039_client_portal.up.sql
+-- The client a referral was created for. The referral's owner links a user
+-- with the client role, who then sees the referral, its agent and its deal
+-- milestones through /api/client/referrals. Deleting the client row
+-- unlinks them; a soft-deleted (erased) client can no longer sign in.
+
+ALTER TABLE referral_requests
+    ADD COLUMN IF NOT EXISTS client_user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_client
+    ON referral_requests (client_user_id, created_at DESC)
+    WHERE client_user_id IS NOT NULL;
@@ -0,0 +1,29 @@
+-- 000040_invitations.up.sql
+-- Invitations to join a brokerage. A broker admin invites an email address
+-- with a role; the invitee registers through the emailed link and the new
+-- account is bound to the brokerage with that role. The link's token is an
+-- HMAC over the id and expires_at, so it is not stored. Inviting an address
+-- again revokes its pending invitation, so each broker has at most one open
+-- invitation per address.
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    broker_id UUID NOT NULL REFERENCES brokers(id) ON DELETE CASCADE,
+    email TEXT NOT NULL CHECK (email = lower(btrim(email)) AND position('@' IN email) > 1),
+    role TEXT NOT NULL CHECK (role IN ('agent', 'broker_admin')),
+    invited_by UUID NOT NULL REFERENCES users(id),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_by UUID REFERENCES users(id),
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CONSTRAINT chk_invitations_accepted_pair CHECK ((accepted_at IS NULL) = (accepted_by IS NULL)),
+    CONSTRAINT chk_invitations_single_outcome CHECK (accepted_at IS NULL OR revoked_at IS NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invitations_one_open
+    ON invitations (broker_id, email)
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_invitations_broker
+    ON invitations (broker_id, created_at DESC);
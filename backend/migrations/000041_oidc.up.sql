@@ -0,0 +1,30 @@
+-- 000041_oidc.up.sql
+-- Single sign-on through OpenID Connect providers. user_identities links a
+-- provider's subject to a user; the first sign-in links by verified email or
+-- provisions the user from the provider's role rules. oidc_states holds the
+-- state, nonce and PKCE verifier of authorization requests in flight; a
+-- callback consumes its row, so each state is usable once.
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    last_login_at TIMESTAMPTZ,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user
+    ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_states_expires
+    ON oidc_states (expires_at);
@@ -0,0 +1,13 @@
+-- 000042_scim.up.sql
+-- SCIM provisioning. A brokerage's identity provider creates, updates and
+-- deactivates the brokerage's users through /scim/v2/Users. Deactivated
+-- users keep their rows and history but cannot sign in: auth treats them as
+-- missing. external_id is the identity provider's own id for the user,
+-- unique within a brokerage.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_broker_external_id
+    ON users (broker_id, external_id)
+    WHERE external_id IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,5 @@
+-- 000043_agreement_signed_event_type.up.sql
+-- Timeline event type for per-party signatures (see 000044), committed
+-- before any transaction uses it.
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AGREEMENT_SIGNED';
@@ -0,0 +1,36 @@
+-- 000044_agreement_signatures.up.sql
+-- Each broker party signs a pending_signature agreement separately: the
+-- referring broker (from_broker_id) first, then the receiving broker
+-- (to_broker_id). The agreement becomes effective with the second
+-- signature, so an effective_at requires both. Agreements that took effect
+-- before signatures were tracked count as signed at effective_at by an
+-- unknown signer. Accepting an amendment clears both signatures.
+
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS referrer_signed_at TIMESTAMPTZ;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS referrer_signed_by UUID REFERENCES users(id);
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS referee_signed_at TIMESTAMPTZ;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS referee_signed_by UUID REFERENCES users(id);
+
+UPDATE agreements
+SET referrer_signed_at = COALESCE(referrer_signed_at, effective_at),
+    referee_signed_at = COALESCE(referee_signed_at, effective_at)
+WHERE effective_at IS NOT NULL
+  AND (referrer_signed_at IS NULL OR referee_signed_at IS NULL);
+
+ALTER TABLE agreements DROP CONSTRAINT IF EXISTS chk_agreement_signed_before_effective;
+ALTER TABLE agreements
+    ADD CONSTRAINT chk_agreement_signed_before_effective CHECK (
+        effective_at IS NULL OR (referrer_signed_at IS NOT NULL AND referee_signed_at IS NOT NULL)
+    );
+
+-- Signatures are part of what clients see, so they move the ETag too.
+DROP TRIGGER IF EXISTS trg_agreements_version ON agreements;
+CREATE TRIGGER trg_agreements_version
+BEFORE UPDATE ON agreements
+FOR EACH ROW
+WHEN ((OLD.status, OLD.effective_at, OLD.fee_rate, OLD.protect_days, OLD.from_broker_id, OLD.to_broker_id,
+       OLD.referrer_signed_at, OLD.referee_signed_at)
+      IS DISTINCT FROM
+      (NEW.status, NEW.effective_at, NEW.fee_rate, NEW.protect_days, NEW.from_broker_id, NEW.to_broker_id,
+       NEW.referrer_signed_at, NEW.referee_signed_at))
+EXECUTE FUNCTION bump_row_version();
@@ -0,0 +1,5 @@
+-- 000045_match_countered_state.up.sql
+-- Enum value for invitations the candidate answered with other terms,
+-- committed before 000046 uses it.
+
+ALTER TYPE referral_match_state ADD VALUE IF NOT EXISTS 'countered';
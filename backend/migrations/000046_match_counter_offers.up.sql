@@ -0,0 +1,27 @@
+-- 000046_match_counter_offers.up.sql
+-- Counter-offers on invitations. The candidate answers an invitation with the
+-- fee rate and protection period they want, moving the match to 'countered'.
+-- The referral owner accepts, which makes the agreement on those terms, or
+-- rejects, which moves the match back to 'invited' (with a fresh TTL from
+-- trg_referral_matches_set_expiry) and clears the proposal. Accepted matches
+-- keep the terms they were accepted on.
+
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS counter_fee_rate NUMERIC(5,2);
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS counter_protect_days INTEGER;
+ALTER TABLE referral_matches ADD COLUMN IF NOT EXISTS countered_at TIMESTAMPTZ;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_referral_match_counter' AND conrelid = 'referral_matches'::regclass
+    ) THEN
+        ALTER TABLE referral_matches
+            ADD CONSTRAINT chk_referral_match_counter CHECK (
+                (counter_fee_rate IS NULL AND counter_protect_days IS NULL AND state <> 'countered')
+                OR (counter_fee_rate > 0 AND counter_fee_rate <= 100 AND counter_protect_days > 0
+                    AND countered_at IS NOT NULL AND state IN ('countered', 'accepted'))
+            );
+    END IF;
+END;
+$$;
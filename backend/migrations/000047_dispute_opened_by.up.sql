@@ -0,0 +1,26 @@
+-- 000047_dispute_opened_by.up.sql
+-- Either party to an agreement may open a dispute: the referral owner's side
+-- (the referrer) or the referee broker, which is owed the fee. Disputes
+-- record who opened them and on which side. Disputes opened before this
+-- migration were opened by the referral owner.
+
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS opened_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS opened_by_role TEXT NOT NULL DEFAULT 'referrer';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_disputes_opened_by_role' AND conrelid = 'disputes'::regclass
+    ) THEN
+        ALTER TABLE disputes
+            ADD CONSTRAINT chk_disputes_opened_by_role CHECK (opened_by_role IN ('referrer', 'referee'));
+    END IF;
+END;
+$$;
+
+UPDATE disputes d
+SET opened_by_user_id = rr.created_by_user_id
+FROM agreements a
+JOIN referral_requests rr ON rr.id = a.referral_id
+WHERE a.id = d.agreement_id AND d.opened_by_user_id IS NULL AND d.opened_by_role = 'referrer';
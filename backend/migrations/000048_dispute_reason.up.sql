@@ -0,0 +1,22 @@
+-- 000048_dispute_reason.up.sql
+-- Disputes say why they were opened: a reason from a fixed list plus a
+-- free-text detail for reviewers (both required by the API). Disputes opened
+-- before this migration have neither.
+
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS reason TEXT;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS detail TEXT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'chk_disputes_reason' AND conrelid = 'disputes'::regclass
+    ) THEN
+        ALTER TABLE disputes
+            ADD CONSTRAINT chk_disputes_reason CHECK (
+                reason IS NULL
+                OR reason IN ('non_payment', 'protect_period_violation', 'misrepresentation', 'other')
+            );
+    END IF;
+END;
+$$;
@@ -0,0 +1,17 @@
+-- 000049_agreement_list_indexes.up.sql
+-- Indexes backing the agreement list filters: by referral, by status and by
+-- created or effective date, each ordered by created_at as the list is.
+-- Counterparty filters use the party broker indexes from 000028.
+
+CREATE INDEX IF NOT EXISTS idx_agreements_referral_created
+    ON agreements (referral_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_status_created
+    ON agreements (status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_created
+    ON agreements (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_effective
+    ON agreements (effective_at)
+    WHERE effective_at IS NOT NULL;
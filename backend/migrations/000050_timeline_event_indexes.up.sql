@@ -0,0 +1,17 @@
+-- 000050_timeline_event_indexes.up.sql
+-- Composite indexes backing the GET /api/events filters, each ending in ts
+-- so the newest-first page reads in index order: by agreement, by type and
+-- by actor broker, plus ts alone for the unfiltered list.
+
+CREATE INDEX IF NOT EXISTS idx_timeline_events_agreement_ts
+    ON timeline_events (agreement_id, ts DESC);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_events_type_ts
+    ON timeline_events (type, ts DESC);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_events_actor_broker_ts
+    ON timeline_events (actor_broker_id, ts DESC)
+    WHERE actor_broker_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_timeline_events_ts
+    ON timeline_events (ts DESC);
@@ -0,0 +1,6 @@
+-- 000051_status_corrected_event_type.up.sql
+-- Timeline event type for admin status corrections, committed before any
+-- transaction uses it. The erroneous event stays in the timeline; the
+-- correction names it and sets the status it should have set.
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'AGREEMENT_STATUS_CORRECTED';
@@ -0,0 +1,31 @@
+-- 000052_worm_guards.up.sql
+-- Write-once guards. timeline_events rows are never updated or deleted and
+-- agreements rows are never deleted; corrections are appended instead. The
+-- guard functions now raise SQLSTATE BFW01 naming the table and operation,
+-- which db.WORMViolation turns into a *db.WORMError, and the triggers are
+-- re-created so a database that lost one regains it. db.CheckWORMGuards
+-- verifies them at startup. TRUNCATE is left unguarded for test resets.
+
+CREATE OR REPLACE FUNCTION prevent_event_mutation() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'timeline_events are immutable'
+        USING ERRCODE = 'BFW01', TABLE = TG_TABLE_NAME, DETAIL = TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_prevent_event_mutation ON timeline_events;
+CREATE TRIGGER trg_prevent_event_mutation
+BEFORE UPDATE OR DELETE ON timeline_events
+FOR EACH ROW EXECUTE FUNCTION prevent_event_mutation();
+
+CREATE OR REPLACE FUNCTION forbid_agreement_delete() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'agreements are non-deletable'
+        USING ERRCODE = 'BFW01', TABLE = TG_TABLE_NAME, DETAIL = TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS no_delete_agreements ON agreements;
+CREATE TRIGGER no_delete_agreements
+BEFORE DELETE ON agreements
+FOR EACH ROW EXECUTE FUNCTION forbid_agreement_delete();
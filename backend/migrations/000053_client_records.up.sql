@@ -0,0 +1,175 @@
+-- 000053_client_records.up.sql
+-- The end client a referral is for, as a record of the referring brokerage
+-- rather than a portal account (client_user_id, 000039). Like pii_contacts,
+-- client_records is closed to the application by RLS: records are written
+-- and read through the SECURITY DEFINER functions below, and reads by the
+-- counterparty are refused until an agreement on the referral is in effect.
+-- A record may be reused for the same brokerage's later referrals.
+
+CREATE TABLE IF NOT EXISTS client_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    broker_id UUID REFERENCES brokers(id),
+    created_by_user_id UUID NOT NULL REFERENCES users(id),
+    client_name TEXT NOT NULL CHECK (btrim(client_name) <> ''),
+    client_email TEXT,
+    client_phone TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT get_tx_timestamp(),
+    CONSTRAINT chk_client_records_contact CHECK (client_email IS NOT NULL OR client_phone IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_records_creator ON client_records (created_by_user_id);
+
+ALTER TABLE referral_requests
+    ADD COLUMN IF NOT EXISTS client_record_id UUID REFERENCES client_records(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS client_attached_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS client_attached_by UUID REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS idx_referral_requests_client_record
+    ON referral_requests (client_record_id)
+    WHERE client_record_id IS NOT NULL;
+
+ALTER TABLE client_records ENABLE ROW LEVEL SECURITY;
+REVOKE ALL ON client_records FROM PUBLIC;
+DROP POLICY IF EXISTS client_records_deny_all ON client_records;
+CREATE POLICY client_records_deny_all ON client_records USING (false) WITH CHECK (false);
+
+-- create_client_record stores a new record for p_actor's brokerage and
+-- returns its id. Go validates the fields and the referral it is for.
+CREATE OR REPLACE FUNCTION create_client_record(p_actor UUID, p_name TEXT, p_email TEXT, p_phone TEXT)
+RETURNS UUID
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+    rec_id UUID;
+BEGIN
+    INSERT INTO client_records (broker_id, created_by_user_id, client_name, client_email, client_phone)
+    SELECT u.broker_id, u.id, p_name, p_email, p_phone
+    FROM users u WHERE u.id = p_actor
+    RETURNING id INTO rec_id;
+    IF rec_id IS NULL THEN
+        RAISE EXCEPTION 'User % not found', p_actor;
+    END IF;
+    RETURN rec_id;
+END;
+$$;
+
+-- client_record_reusable reports whether p_actor may attach an existing
+-- record: one they created, or one of their brokerage. It reveals no PII.
+CREATE OR REPLACE FUNCTION client_record_reusable(p_record UUID, p_actor UUID)
+RETURNS BOOLEAN
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+    SELECT EXISTS (
+        SELECT 1
+        FROM client_records c
+        JOIN users u ON u.id = p_actor
+        WHERE c.id = p_record
+          AND (c.created_by_user_id = u.id OR (c.broker_id IS NOT NULL AND c.broker_id = u.broker_id))
+    );
+$$;
+
+-- get_client_record returns the client of p_referral_id to p_actor and
+-- audits the read. The referring side (the creator or a user of their
+-- brokerage) may always read it; a user of the other broker party only
+-- while an agreement on the referral is in effect.
+CREATE OR REPLACE FUNCTION get_client_record(p_referral_id UUID, p_actor UUID)
+RETURNS TABLE(client_name TEXT, client_email TEXT, client_phone TEXT)
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+    gate_agreement UUID;
+    referring BOOLEAN;
+BEGIN
+    SELECT (creator.id = actor.id OR (creator.broker_id IS NOT NULL AND creator.broker_id = actor.broker_id))
+    INTO referring
+    FROM referral_requests rr
+    JOIN users creator ON creator.id = rr.created_by_user_id
+    JOIN users actor ON actor.id = p_actor
+    WHERE rr.id = p_referral_id;
+
+    IF NOT COALESCE(referring, false) THEN
+        SELECT a.id INTO gate_agreement
+        FROM agreements a
+        JOIN users actor ON actor.id = p_actor
+        WHERE a.referral_id = p_referral_id
+          AND actor.broker_id IN (a.from_broker_id, a.to_broker_id)
+          AND a.status IN ('effective', 'success', 'disputed')
+          AND a.effective_at IS NOT NULL
+          AND a.effective_at <= get_tx_timestamp()
+        ORDER BY a.effective_at
+        LIMIT 1;
+        IF gate_agreement IS NULL THEN
+            RAISE EXCEPTION 'Client of referral % not visible before an agreement is in effect', p_referral_id;
+        END IF;
+    END IF;
+
+    PERFORM audit_pii_access(gate_agreement, p_actor,
+        jsonb_build_object('source', 'get_client_record', 'referral_id', p_referral_id));
+
+    RETURN QUERY
+    SELECT c.client_name, c.client_email, c.client_phone
+    FROM referral_requests rr
+    JOIN client_records c ON c.id = rr.client_record_id
+    WHERE rr.id = p_referral_id;
+END;
+$$;
+
+-- purge_user_pii (000015) now also deletes the client records an erased
+-- user created, which unlinks them from their referrals.
+CREATE OR REPLACE FUNCTION purge_user_pii(p_user UUID)
+RETURNS INTEGER
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+    n INTEGER;
+    records INTEGER;
+BEGIN
+    WITH purged AS (
+        DELETE FROM pii_contacts c
+        USING agreements a, referral_requests rr
+        WHERE c.agreement_id = a.id
+          AND a.referral_id = rr.id
+          AND rr.created_by_user_id = p_user
+        RETURNING c.agreement_id
+    ), audited AS (
+        INSERT INTO audit_logs(agreement_id, actor_id, action, metadata, ts)
+        SELECT agreement_id, NULL, 'PII_PURGED',
+               jsonb_build_object('source', 'purge_user_pii', 'user_id', p_user),
+               get_tx_timestamp()
+        FROM purged
+        RETURNING 1
+    )
+    SELECT COUNT(*) INTO n FROM audited;
+
+    WITH purged AS (
+        DELETE FROM client_records c
+        WHERE c.created_by_user_id = p_user
+        RETURNING c.id
+    ), audited AS (
+        INSERT INTO audit_logs(agreement_id, actor_id, action, metadata, ts)
+        SELECT NULL, NULL, 'PII_PURGED',
+               jsonb_build_object('source', 'purge_user_pii', 'user_id', p_user, 'client_record_id', id),
+               get_tx_timestamp()
+        FROM purged
+        RETURNING 1
+    )
+    SELECT COUNT(*) INTO records FROM audited;
+
+    DELETE FROM pii_data d
+    USING referrals r
+    WHERE d.referral_id = r.id AND r.created_by_user_id = p_user;
+
+    RETURN n + records;
+END;
+$$;
+
+ALTER TYPE event_type ADD VALUE IF NOT EXISTS 'CLIENT_RECORD_ATTACHED';
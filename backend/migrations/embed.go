@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations so binaries and tests apply
+// them wherever they run. Use them through db.Migrations.
+package migrations
+
+import "embed"
+
+// FS holds the *.sql files of this directory, named by version.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,85 @@
+package money
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used when no locale is requested.
+const DefaultLocale = "en-US"
+
+// Locale is a BCP 47 language tag reduced to what formatting uses.
+type Locale struct {
+	Language string
+	Region   string
+}
+
+// ParseLocale reads the language and region of a tag such as "fr-CA" or
+// "en_us". Unknown or empty tags give DefaultLocale.
+func ParseLocale(tag string) Locale {
+	parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return Locale{Language: "en", Region: "US"}
+	}
+	l := Locale{Language: strings.ToLower(parts[0])}
+	for _, p := range parts[1:] {
+		if len(p) == 2 {
+			l.Region = strings.ToUpper(p)
+			break
+		}
+	}
+	return l
+}
+
+// FromAcceptLanguage picks the first tag of an Accept-Language header,
+// ignoring quality weights.
+func FromAcceptLanguage(header string) Locale {
+	first, _, _ := strings.Cut(header, ",")
+	first, _, _ = strings.Cut(first, ";")
+	first = strings.TrimSpace(first)
+	if first == "" || first == "*" {
+		return ParseLocale(DefaultLocale)
+	}
+	return ParseLocale(first)
+}
+
+// Format writes m for l: English groups with commas and puts the symbol
+// first ("$1,250,000"), French groups with spaces and puts it last
+// ("1 250 000 $"). The symbol carries its country ("US$", "CA$") unless l's
+// region uses the currency; unsupported currencies show their code.
+func (m Money) Format(l Locale) string {
+	symbol := string(m.Currency)
+	if info, ok := currencies[m.Currency]; ok {
+		symbol = info.symbol
+		if l.Region != info.country {
+			symbol = info.country + info.symbol
+		}
+	}
+
+	sep := ","
+	if l.Language == "fr" {
+		sep = " "
+	}
+	digits := strconv.FormatInt(m.Amount, 10)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+
+	if l.Language == "fr" {
+		return sign + b.String() + " " + symbol
+	}
+	return sign + symbol + b.String()
+}
+
+// FormatRange writes a price band in l, such as "$800,000 – $1,200,000".
+func FormatRange(lo, hi Money, l Locale) string {
+	return lo.Format(l) + " – " + hi.Format(l)
+}
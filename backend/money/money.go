@@ -0,0 +1,72 @@
+// Package money holds the currency-aware amount type shared by referrals,
+// agreements and reports. Amounts are whole units of their currency, as
+// referral prices have always been.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Currency is an ISO 4217 code.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	CAD Currency = "CAD"
+)
+
+// DefaultCurrency applies to prices recorded before currencies existed and
+// to requests that do not name one.
+const DefaultCurrency = USD
+
+var ErrUnsupportedCurrency = errors.New("money: unsupported currency")
+
+// currencyInfo is what formatting needs to know about a currency. Supporting
+// a new currency means adding it here and giving it an exchange rate.
+type currencyInfo struct {
+	symbol string
+	// country is the ISO 3166 country whose locales write the bare symbol;
+	// other locales prefix it with the country, as in "US$".
+	country string
+}
+
+var currencies = map[Currency]currencyInfo{
+	USD: {symbol: "$", country: "US"},
+	CAD: {symbol: "$", country: "CA"},
+}
+
+// Currencies lists the supported currencies in code order.
+func Currencies() []Currency {
+	out := make([]Currency, 0, len(currencies))
+	for c := range currencies {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// ParseCurrency accepts a supported ISO 4217 code in any case.
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if _, ok := currencies[c]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedCurrency, s)
+	}
+	return c, nil
+}
+
+// Money is an amount in whole units of Currency.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
@@ -0,0 +1,76 @@
+package money_test
+
+import (
+	"errors"
+	"testing"
+
+	"brokerflow/money"
+)
+
+func TestParseCurrency(t *testing.T) {
+	if c, err := money.ParseCurrency(" cad "); err != nil || c != money.CAD {
+		t.Fatalf("expected CAD, got %q, %v", c, err)
+	}
+	for _, in := range []string{"", "EUR", "US"} {
+		if _, err := money.ParseCurrency(in); !errors.Is(err, money.ErrUnsupportedCurrency) {
+			t.Errorf("ParseCurrency(%q): expected ErrUnsupportedCurrency, got %v", in, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		m      money.Money
+		locale string
+		want   string
+	}{
+		{money.New(1250000, money.USD), "en-US", "$1,250,000"},
+		{money.New(1250000, money.CAD), "en-US", "CA$1,250,000"},
+		{money.New(1250000, money.CAD), "en-CA", "$1,250,000"},
+		{money.New(1250000, money.CAD), "fr-CA", "1\u00a0250\u00a0000\u00a0$"},
+		{money.New(-950, money.USD), "fr", "-950\u00a0US$"},
+		{money.New(100, "EUR"), "en-US", "EUR100"},
+	} {
+		if got := tc.m.Format(money.ParseLocale(tc.locale)); got != tc.want {
+			t.Errorf("%s in %s = %q, want %q", tc.m, tc.locale, got, tc.want)
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	for header, want := range map[string]money.Locale{
+		"fr-CA,fr;q=0.9,en;q=0.8": {Language: "fr", Region: "CA"},
+		"en_us":                   {Language: "en", Region: "US"},
+		"*":                       {Language: "en", Region: "US"},
+		"":                        {Language: "en", Region: "US"},
+	} {
+		if got := money.FromAcceptLanguage(header); got != want {
+			t.Errorf("FromAcceptLanguage(%q) = %+v, want %+v", header, got, want)
+		}
+	}
+}
+
+func TestRates_Convert(t *testing.T) {
+	rates, err := money.ParseRates("CAD=0.75")
+	if err != nil {
+		t.Fatalf("parse rates: %v", err)
+	}
+	got, err := rates.Convert(money.New(1000, money.CAD), money.USD)
+	if err != nil || got != money.New(750, money.USD) {
+		t.Fatalf("expected 750 USD, got %v, %v", got, err)
+	}
+	got, err = rates.Convert(money.New(750, money.USD), money.CAD)
+	if err != nil || got != money.New(1000, money.CAD) {
+		t.Fatalf("expected 1000 CAD, got %v, %v", got, err)
+	}
+
+	none, _ := money.ParseRates("")
+	if _, err := none.Convert(money.New(1, money.CAD), money.USD); !errors.Is(err, money.ErrNoRate) {
+		t.Fatalf("expected ErrNoRate, got %v", err)
+	}
+	for _, in := range []string{"CAD", "CAD=0", "CAD=abc", "EUR=1.1"} {
+		if _, err := money.ParseRates(in); err == nil {
+			t.Errorf("ParseRates(%q): expected an error", in)
+		}
+	}
+}
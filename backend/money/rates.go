@@ -0,0 +1,81 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoRate      = errors.New("money: no exchange rate")
+	ErrInvalidRate = errors.New("money: invalid exchange rate")
+)
+
+// Rates converts between currencies through a base currency. Each rate is
+// the value of one unit of a currency in the base currency.
+type Rates struct {
+	base  Currency
+	rates map[Currency]float64
+}
+
+// NewRates builds rates against base; base itself is always 1.
+func NewRates(base Currency, rates map[Currency]float64) (Rates, error) {
+	r := Rates{base: base, rates: map[Currency]float64{base: 1}}
+	for c, v := range rates {
+		if _, ok := currencies[c]; !ok {
+			return Rates{}, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, c)
+		}
+		if v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return Rates{}, fmt.Errorf("%w: %s=%v", ErrInvalidRate, c, v)
+		}
+		if c != base {
+			r.rates[c] = v
+		}
+	}
+	return r, nil
+}
+
+// ParseRates reads rates against DefaultCurrency from a list such as
+// "CAD=0.73", separated by commas.
+func ParseRates(s string) (Rates, error) {
+	rates := make(map[Currency]float64)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return Rates{}, fmt.Errorf("%w: %q is not CODE=RATE", ErrInvalidRate, item)
+		}
+		c, err := ParseCurrency(code)
+		if err != nil {
+			return Rates{}, err
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return Rates{}, fmt.Errorf("%w: %q", ErrInvalidRate, item)
+		}
+		rates[c] = v
+	}
+	return NewRates(DefaultCurrency, rates)
+}
+
+// Convert expresses m in to, rounding to the nearest whole unit. It fails
+// with ErrNoRate when either currency has no rate.
+func (r Rates) Convert(m Money, to Currency) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	from, ok := r.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, m.Currency)
+	}
+	into, ok := r.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+	return Money{Amount: int64(math.Round(float64(m.Amount) * from / into)), Currency: to}, nil
+}
@@ -0,0 +1,182 @@
+package observability
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStats is a snapshot of connection pool counters.
+type PoolStats struct {
+	AcquiredConns      int32
+	IdleConns          int32
+	ConstructingConns  int32
+	TotalConns         int32
+	MaxConns           int32
+	AcquireCount       int64
+	AcquireDuration    time.Duration
+	EmptyAcquireCount  int64
+	CanceledAcquires   int64
+	NewConns           int64
+	MaxLifetimeDestroy int64
+	MaxIdleDestroy     int64
+}
+
+// PoolStatsFunc reads pool statistics at scrape time.
+type PoolStatsFunc func() PoolStats
+
+// PgxPoolStats reads the statistics pgxpool keeps for pool.
+func PgxPoolStats(pool *pgxpool.Pool) PoolStatsFunc {
+	return func() PoolStats {
+		st := pool.Stat()
+		return PoolStats{
+			AcquiredConns:      st.AcquiredConns(),
+			IdleConns:          st.IdleConns(),
+			ConstructingConns:  st.ConstructingConns(),
+			TotalConns:         st.TotalConns(),
+			MaxConns:           st.MaxConns(),
+			AcquireCount:       st.AcquireCount(),
+			AcquireDuration:    st.AcquireDuration(),
+			EmptyAcquireCount:  st.EmptyAcquireCount(),
+			CanceledAcquires:   st.CanceledAcquireCount(),
+			NewConns:           st.NewConnsCount(),
+			MaxLifetimeDestroy: st.MaxLifetimeDestroyCount(),
+			MaxIdleDestroy:     st.MaxIdleDestroyCount(),
+		}
+	}
+}
+
+type poolCollector struct {
+	stats PoolStatsFunc
+
+	acquiredConns      *prometheus.Desc
+	idleConns          *prometheus.Desc
+	constructingConns  *prometheus.Desc
+	totalConns         *prometheus.Desc
+	maxConns           *prometheus.Desc
+	acquireCount       *prometheus.Desc
+	acquireDuration    *prometheus.Desc
+	emptyAcquireCount  *prometheus.Desc
+	canceledAcquires   *prometheus.Desc
+	newConns           *prometheus.Desc
+	maxLifetimeDestroy *prometheus.Desc
+	maxIdleDestroy     *prometheus.Desc
+}
+
+func newPoolCollector(stats PoolStatsFunc) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		stats:              stats,
+		acquiredConns:      desc("acquired_conns", "Connections currently checked out of the pool."),
+		idleConns:          desc("idle_conns", "Idle connections in the pool."),
+		constructingConns:  desc("constructing_conns", "Connections currently being established."),
+		totalConns:         desc("total_conns", "Total connections owned by the pool."),
+		maxConns:           desc("max_conns", "Configured maximum pool size."),
+		acquireCount:       desc("acquire_total", "Successful connection acquisitions."),
+		acquireDuration:    desc("acquire_duration_seconds_total", "Cumulative time spent waiting to acquire connections."),
+		emptyAcquireCount:  desc("empty_acquire_total", "Acquisitions that had to wait because the pool was empty."),
+		canceledAcquires:   desc("canceled_acquire_total", "Acquisitions canceled by their context."),
+		newConns:           desc("new_conns_total", "Connections opened by the pool."),
+		maxLifetimeDestroy: desc("max_lifetime_destroy_total", "Connections closed for exceeding the maximum lifetime."),
+		maxIdleDestroy:     desc("max_idle_destroy_total", "Connections closed for exceeding the maximum idle time."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.stats()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.acquiredConns, float64(st.AcquiredConns))
+	gauge(c.idleConns, float64(st.IdleConns))
+	gauge(c.constructingConns, float64(st.ConstructingConns))
+	gauge(c.totalConns, float64(st.TotalConns))
+	gauge(c.maxConns, float64(st.MaxConns))
+	counter(c.acquireCount, float64(st.AcquireCount))
+	counter(c.acquireDuration, st.AcquireDuration.Seconds())
+	counter(c.emptyAcquireCount, float64(st.EmptyAcquireCount))
+	counter(c.canceledAcquires, float64(st.CanceledAcquires))
+	counter(c.newConns, float64(st.NewConns))
+	counter(c.maxLifetimeDestroy, float64(st.MaxLifetimeDestroy))
+	counter(c.maxIdleDestroy, float64(st.MaxIdleDestroy))
+}
+
+// OutboxStats reports the pending outbox backlog.
+type OutboxStats struct {
+	Pending   int64
+	OldestAge time.Duration
+}
+
+// OutboxStatsFunc loads outbox statistics at scrape time.
+type OutboxStatsFunc func(ctx context.Context) (OutboxStats, error)
+
+// PoolOutboxStats queries the outbox table directly through pool.
+func PoolOutboxStats(pool *pgxpool.Pool) OutboxStatsFunc {
+	return func(ctx context.Context) (OutboxStats, error) {
+		const q = `
+			SELECT COUNT(*),
+			       COALESCE(EXTRACT(EPOCH FROM get_tx_timestamp() - MIN(created_at)), 0)::float8
+			FROM outbox
+			WHERE status = 'pending'
+		`
+		var (
+			st         OutboxStats
+			oldestSecs float64
+		)
+		if err := pool.QueryRow(ctx, q).Scan(&st.Pending, &oldestSecs); err != nil {
+			return OutboxStats{}, err
+		}
+		st.OldestAge = time.Duration(oldestSecs * float64(time.Second))
+		return st, nil
+	}
+}
+
+// RegisterOutbox exports the outbox backlog, evaluated on every scrape.
+func (m *Metrics) RegisterOutbox(stats OutboxStatsFunc) {
+	m.registry.MustRegister(&outboxCollector{
+		stats:   stats,
+		timeout: 2 * time.Second,
+		pending: prometheus.NewDesc(prometheus.BuildFQName(namespace, "outbox", "pending_messages"),
+			"Outbox messages waiting for delivery.", nil, nil),
+		oldest: prometheus.NewDesc(prometheus.BuildFQName(namespace, "outbox", "oldest_pending_age_seconds"),
+			"Age of the oldest pending outbox message.", nil, nil),
+	})
+}
+
+type outboxCollector struct {
+	stats   OutboxStatsFunc
+	timeout time.Duration
+	pending *prometheus.Desc
+	oldest  *prometheus.Desc
+}
+
+func (c *outboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.oldest
+}
+
+func (c *outboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	st, err := c.stats(ctx)
+	if err != nil {
+		log.Printf("observability: collect outbox stats: %v", err)
+		ch <- prometheus.NewInvalidMetric(c.pending, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(st.Pending))
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, st.OldestAge.Seconds())
+}
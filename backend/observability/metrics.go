@@ -0,0 +1,142 @@
+// Package observability owns the Prometheus registry and the collectors the
+// API exports on /metrics. Domain packages stay free of Prometheus types: they
+// expose small observer hooks which Metrics implements.
+package observability
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "brokerflow"
+
+// Metrics bundles the registry with the instruments updated at runtime.
+type Metrics struct {
+	registry         *prometheus.Registry
+	requestsTotal    *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	transitionsTotal *prometheus.CounterVec
+}
+
+// NewMetrics creates a registry preloaded with Go runtime and process
+// collectors plus the HTTP and agreement instruments.
+func NewMetrics() *Metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m := &Metrics{
+		registry: reg,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests processed, partitioned by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency, partitioned by route pattern and method.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"route", "method"}),
+		transitionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "agreement",
+			Name:      "status_transitions_total",
+			Help:      "Committed agreement status transitions.",
+		}, []string{"from", "to"}),
+	}
+	reg.MustRegister(m.requestsTotal, m.requestDuration, m.transitionsTotal)
+	return m
+}
+
+// Registry exposes the underlying registry for additional collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the registry in the Prometheus exposition format. A failing
+// collector (e.g. the outbox query during a database outage) is dropped from
+// the scrape instead of failing it.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		Registry:      m.registry,
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// RegisterPool exports connection pool statistics read through stats on
+// every scrape; use PgxPoolStats for a pgxpool.Pool.
+func (m *Metrics) RegisterPool(stats PoolStatsFunc) {
+	m.registry.MustRegister(newPoolCollector(stats))
+}
+
+// ObserveTransition implements agreement.TransitionObserver.
+func (m *Metrics) ObserveTransition(from, to string) {
+	if from == "" {
+		from = "none"
+	}
+	m.transitionsTotal.WithLabelValues(from, to).Inc()
+}
+
+// ObserveRequest records one completed HTTP request.
+func (m *Metrics) ObserveRequest(route, method string, status int, elapsed time.Duration) {
+	m.requestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+}
+
+// Middleware instruments every request passing through next. The route label
+// is the ServeMux pattern that matched (never the raw path) so label
+// cardinality stays bounded; ServeMux records it on the shared *http.Request.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveRequest(route, r.Method, sw.status, time.Since(start))
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming handlers working behind the middleware.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	m := NewMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/api/items/1", "/api/items/2", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("GET /api/items/{id}", "GET", "418")); got != 2 {
+		t.Fatalf("expected 2 requests for pattern, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("unmatched", "GET", "404")); got != 1 {
+		t.Fatalf("expected 1 unmatched request, got %v", got)
+	}
+}
+
+func TestObserveTransition(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveTransition("draft", "pending_signature")
+	m.ObserveTransition("", "draft")
+
+	if got := testutil.ToFloat64(m.transitionsTotal.WithLabelValues("draft", "pending_signature")); got != 1 {
+		t.Fatalf("expected 1 transition, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.transitionsTotal.WithLabelValues("none", "draft")); got != 1 {
+		t.Fatalf("expected unknown origin to be labelled none, got %v", got)
+	}
+}
+
+func TestHandler_ExportsOutboxBacklog(t *testing.T) {
+	m := NewMetrics()
+	m.RegisterOutbox(func(context.Context) (OutboxStats, error) {
+		return OutboxStats{Pending: 7, OldestAge: 90 * time.Second}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"brokerflow_outbox_pending_messages 7",
+		"brokerflow_outbox_oldest_pending_age_seconds 90",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics output", want)
+		}
+	}
+}
+
+func TestHandler_OutboxErrorDoesNotBreakScrape(t *testing.T) {
+	m := NewMetrics()
+	m.RegisterOutbox(func(context.Context) (OutboxStats, error) {
+		return OutboxStats{}, errors.New("db down")
+	})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Fatalf("expected runtime metrics despite outbox failure, status %d", rec.Code)
+	}
+}
+
+func TestHandler_ExportsPoolStats(t *testing.T) {
+	m := NewMetrics()
+	m.RegisterPool(func() PoolStats {
+		return PoolStats{AcquiredConns: 3, MaxConns: 20, NewConns: 11, MaxLifetimeDestroy: 4, AcquireDuration: 1500 * time.Millisecond}
+	})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"brokerflow_pgxpool_acquired_conns 3",
+		"brokerflow_pgxpool_max_conns 20",
+		"brokerflow_pgxpool_new_conns_total 11",
+		"brokerflow_pgxpool_max_lifetime_destroy_total 4",
+		"brokerflow_pgxpool_acquire_duration_seconds_total 1.5",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics output", want)
+		}
+	}
+}
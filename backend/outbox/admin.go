@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMessageNotFound is returned for an outbox ID with no row.
+var ErrMessageNotFound = errors.New("outbox: message not found")
+
+// StoredMessage is an outbox row with its delivery state, as operators see
+// it.
+type StoredMessage struct {
+	Message
+	Status      string
+	LastAttempt *time.Time
+}
+
+// AdminRepository gives operators control over messages the worker has
+// given up on. Dead letters are rows in StatusFailed: their handler failed
+// MaxAttempts times.
+type AdminRepository interface {
+	// ListDeadLetters pages through failed messages, most recently attempted
+	// first, optionally on one topic. Payloads are left out.
+	ListDeadLetters(ctx context.Context, topic string, page, pageSize int) ([]StoredMessage, int, error)
+	// GetMessage loads any message with its payload.
+	GetMessage(ctx context.Context, id string) (StoredMessage, error)
+	// Requeue makes the failed messages among ids pending again with a
+	// fresh attempt budget and returns their IDs. Messages in any other
+	// state are left alone.
+	Requeue(ctx context.Context, actorID string, ids []string) ([]string, error)
+	// PurgeDelivered deletes delivered messages last attempted more than
+	// olderThan ago and returns how many went.
+	PurgeDelivered(ctx context.Context, actorID string, olderThan time.Duration) (int64, error)
+}
+
+// PGAdminRepository implements AdminRepository on the outbox table.
+// Requeues and purges are written to audit_logs in the same transaction.
+type PGAdminRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAdminRepository(pool *pgxpool.Pool) *PGAdminRepository {
+	return &PGAdminRepository{pool: pool}
+}
+
+func (r *PGAdminRepository) ListDeadLetters(ctx context.Context, topic string, page, pageSize int) ([]StoredMessage, int, error) {
+	const query = `
+		SELECT id::text, topic, attempts, created_at, status, last_attempt, COUNT(*) OVER ()
+		FROM outbox
+		WHERE status = 'failed' AND ($1 = '' OR topic = $1)
+		ORDER BY last_attempt DESC NULLS LAST, id
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.pool.Query(ctx, query, topic, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("outbox: query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		msgs  []StoredMessage
+		total int
+	)
+	for rows.Next() {
+		var m StoredMessage
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Attempts, &m.CreatedAt, &m.Status, &m.LastAttempt, &total); err != nil {
+			return nil, 0, fmt.Errorf("outbox: scan dead letter: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("outbox: iterate dead letters: %w", err)
+	}
+	if len(msgs) == 0 && page > 1 {
+		// The window function counts nothing past the last page.
+		if err := r.pool.QueryRow(ctx, `
+			SELECT COUNT(*) FROM outbox WHERE status = 'failed' AND ($1 = '' OR topic = $1)
+		`, topic).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("outbox: count dead letters: %w", err)
+		}
+	}
+	return msgs, total, nil
+}
+
+func (r *PGAdminRepository) GetMessage(ctx context.Context, id string) (StoredMessage, error) {
+	const query = `
+		SELECT id::text, topic, COALESCE(payload, 'null'::jsonb), attempts, created_at, status, last_attempt
+		FROM outbox
+		WHERE id = $1::uuid
+	`
+	var m StoredMessage
+	err := r.pool.QueryRow(ctx, query, id).Scan(&m.ID, &m.Topic, &m.Payload, &m.Attempts, &m.CreatedAt, &m.Status, &m.LastAttempt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return StoredMessage{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return StoredMessage{}, fmt.Errorf("outbox: load message: %w", err)
+	}
+	return m, nil
+}
+
+func (r *PGAdminRepository) Requeue(ctx context.Context, actorID string, ids []string) ([]string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: begin requeue: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE outbox
+		SET status = 'pending', attempts = 0, last_attempt = NULL
+		WHERE id = ANY($1::uuid[]) AND status = 'failed'
+		RETURNING id::text
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: requeue: %w", err)
+	}
+	requeued, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("outbox: scan requeued: %w", err)
+	}
+	if len(requeued) > 0 {
+		if err := insertAuditLog(ctx, tx, actorID, "OUTBOX_REQUEUED", map[string]any{"ids": requeued}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("outbox: commit requeue: %w", err)
+	}
+	return requeued, nil
+}
+
+func (r *PGAdminRepository) PurgeDelivered(ctx context.Context, actorID string, olderThan time.Duration) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: begin purge: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM outbox
+		WHERE status = 'delivered' AND last_attempt < get_tx_timestamp() - $1::interval
+	`, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: purge: %w", err)
+	}
+	purged := tag.RowsAffected()
+	if purged > 0 {
+		if err := insertAuditLog(ctx, tx, actorID, "OUTBOX_PURGED", map[string]any{
+			"count":     purged,
+			"olderThan": olderThan.String(),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("outbox: commit purge: %w", err)
+	}
+	return purged, nil
+}
+
+func insertAuditLog(ctx context.Context, tx pgx.Tx, actorID, action string, metadata map[string]any) error {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("outbox: marshal audit metadata: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO audit_logs (actor_id, action, metadata)
+		VALUES (NULLIF($1, '')::uuid, $2, $3::jsonb)
+	`, actorID, action, body); err != nil {
+		return fmt.Errorf("outbox: write audit log: %w", err)
+	}
+	return nil
+}
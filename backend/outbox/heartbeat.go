@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HeartbeatRepository records liveness of outbox delivery workers so API
+// instances can tell whether anything is draining the table.
+type HeartbeatRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewHeartbeatRepository(pool *pgxpool.Pool) *HeartbeatRepository {
+	return &HeartbeatRepository{pool: pool}
+}
+
+// Beat upserts the heartbeat for workerID. Workers call it once per poll loop.
+func (r *HeartbeatRepository) Beat(ctx context.Context, workerID string) error {
+	const query = `
+		INSERT INTO outbox_worker_heartbeats (worker_id, beat_at)
+		VALUES ($1, get_tx_timestamp())
+		ON CONFLICT (worker_id) DO UPDATE SET beat_at = EXCLUDED.beat_at
+	`
+	if _, err := r.pool.Exec(ctx, query, workerID); err != nil {
+		return fmt.Errorf("outbox: record heartbeat: %w", err)
+	}
+	return nil
+}
+
+// LatestAge reports how long ago the most recent heartbeat from any worker
+// was recorded, measured on the database clock. ok is false when no worker
+// has ever reported.
+func (r *HeartbeatRepository) LatestAge(ctx context.Context) (age time.Duration, ok bool, err error) {
+	const query = `
+		SELECT EXTRACT(EPOCH FROM get_tx_timestamp() - MAX(beat_at))::float8
+		FROM outbox_worker_heartbeats
+	`
+	var secs *float64
+	if err := r.pool.QueryRow(ctx, query).Scan(&secs); err != nil {
+		return 0, false, fmt.Errorf("outbox: load heartbeat: %w", err)
+	}
+	if secs == nil {
+		return 0, false, nil
+	}
+	return time.Duration(*secs * float64(time.Second)), true, nil
+}
@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BusMessage is an outbox message addressed to a message bus.
+type BusMessage struct {
+	// ID is the outbox id. It is unchanged when a message is retried, so
+	// consumers and broker-side deduplication can key on it.
+	ID string
+	// Destination is the NATS subject or Kafka topic chosen by Routes.
+	Destination string
+	// Key groups related messages: the agreement_id of the payload, else its
+	// referral_id, else the outbox id. Kafka partitions by it, so messages
+	// about one agreement keep their relative order.
+	Key       string
+	Topic     string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Publisher sends messages to a bus. Publish must return nil only once the
+// bus has durably accepted the message; an error leaves the outbox message
+// pending, so delivery is at least once.
+type Publisher interface {
+	Publish(ctx context.Context, msg BusMessage) error
+}
+
+// Route maps outbox topics to a bus destination. Pattern is a topic name, a
+// prefix ending in ".*" (e.g. "agreement.*"), or "*" for every topic.
+type Route struct {
+	Pattern     string
+	Destination string
+}
+
+// Routes resolves the destination of a topic: an exact pattern wins over
+// the longest matching prefix, which wins over "*". With no routes at all
+// every topic is published under its own name.
+type Routes []Route
+
+// ParseRoutes parses comma-separated pattern=destination pairs, e.g.
+// "agreement.*=brokerflow.agreements,*=brokerflow.events". An empty
+// destination drops the matching topics.
+func ParseRoutes(s string) (Routes, error) {
+	var routes Routes
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, dest, ok := strings.Cut(part, "=")
+		pattern, dest = strings.TrimSpace(pattern), strings.TrimSpace(dest)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("outbox: invalid route %q, want pattern=destination", part)
+		}
+		if strings.Contains(pattern, "*") && pattern != "*" &&
+			(!strings.HasSuffix(pattern, ".*") || strings.Count(pattern, "*") > 1) {
+			return nil, fmt.Errorf("outbox: invalid route pattern %q", pattern)
+		}
+		routes = append(routes, Route{Pattern: pattern, Destination: dest})
+	}
+	return routes, nil
+}
+
+// Destination returns where topic is published, or false when it is not
+// published.
+func (rs Routes) Destination(topic string) (string, bool) {
+	if len(rs) == 0 {
+		return topic, true
+	}
+	best, bestLen := -1, -1
+	for i, r := range rs {
+		var n int
+		switch {
+		case r.Pattern == topic:
+			n = len(topic) + 1
+		case r.Pattern == "*":
+			n = 0
+		case strings.HasSuffix(r.Pattern, ".*") && strings.HasPrefix(topic, strings.TrimSuffix(r.Pattern, "*")):
+			n = len(r.Pattern) - 1
+		default:
+			continue
+		}
+		if n > bestLen {
+			best, bestLen = i, n
+		}
+	}
+	if best < 0 || rs[best].Destination == "" {
+		return "", false
+	}
+	return rs[best].Destination, true
+}
+
+// PublishHandler is a Handler that forwards messages to a Publisher
+// according to Routes. Unrouted topics are acknowledged without work.
+type PublishHandler struct {
+	publisher Publisher
+	routes    Routes
+}
+
+func NewPublishHandler(p Publisher, routes Routes) *PublishHandler {
+	return &PublishHandler{publisher: p, routes: routes}
+}
+
+func (h *PublishHandler) HandleOutbox(ctx context.Context, msg Message) error {
+	dest, ok := h.routes.Destination(msg.Topic)
+	if !ok {
+		return nil
+	}
+	err := h.publisher.Publish(ctx, BusMessage{
+		ID:          msg.ID,
+		Destination: dest,
+		Key:         MessageKey(msg),
+		Topic:       msg.Topic,
+		Payload:     msg.Payload,
+		CreatedAt:   msg.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("outbox: publish %s to %s: %w", msg.ID, dest, err)
+	}
+	return nil
+}
+
+// MessageKey derives the bus key of msg; see BusMessage.Key.
+func MessageKey(msg Message) string {
+	var ids struct {
+		AgreementID string `json:"agreement_id"`
+		ReferralID  string `json:"referral_id"`
+	}
+	// Payloads that are not objects have no ids and fall back to msg.ID.
+	_ = json.Unmarshal(msg.Payload, &ids)
+	switch {
+	case ids.AgreementID != "":
+		return ids.AgreementID
+	case ids.ReferralID != "":
+		return ids.ReferralID
+	}
+	return msg.ID
+}
@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" agreement.*=bf.agreements, match.accepted=bf.accepted,referral.created=,*=bf.events ")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cases := map[string]string{
+		"agreement.created":      "bf.agreements",
+		"match.accepted":         "bf.accepted",
+		"match.invited":          "bf.events",
+		"review.submitted":       "bf.events",
+		"agreementx.created":     "bf.events",
+		"agreement.status_moved": "bf.agreements",
+	}
+	for topic, want := range cases {
+		if got, ok := routes.Destination(topic); !ok || got != want {
+			t.Errorf("%s: got %q %v, want %q", topic, got, ok, want)
+		}
+	}
+	if _, ok := routes.Destination("referral.created"); ok {
+		t.Error("empty destination should drop the topic")
+	}
+
+	for _, bad := range []string{"agreement", "=x", "agree*=x", "a.*.*=x"} {
+		if _, err := ParseRoutes(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRoutes_DefaultsToTopicName(t *testing.T) {
+	if got, ok := Routes(nil).Destination("match.invited"); !ok || got != "match.invited" {
+		t.Fatalf("got %q %v", got, ok)
+	}
+	routes, _ := ParseRoutes("agreement.*=bf.agreements")
+	if _, ok := routes.Destination("match.invited"); ok {
+		t.Fatal("topics matching no route are not published once routes are configured")
+	}
+}
+
+type recordingPublisher struct{ got []BusMessage }
+
+func (p *recordingPublisher) Publish(_ context.Context, msg BusMessage) error {
+	p.got = append(p.got, msg)
+	return nil
+}
+
+func TestPublishHandler_KeysByAgreement(t *testing.T) {
+	pub := &recordingPublisher{}
+	h := NewPublishHandler(pub, nil)
+
+	msgs := []Message{
+		{ID: "m1", Topic: "agreement.created", Payload: json.RawMessage(`{"agreement_id":"ag1","referral_id":"r1"}`)},
+		{ID: "m2", Topic: "match.invited", Payload: json.RawMessage(`{"referral_id":"r1"}`)},
+		{ID: "m3", Topic: "other", Payload: json.RawMessage(`[1]`)},
+	}
+	for _, m := range msgs {
+		if err := h.HandleOutbox(context.Background(), m); err != nil {
+			t.Fatalf("handle %s: %v", m.ID, err)
+		}
+	}
+	want := []string{"ag1", "r1", "m3"}
+	for i, m := range pub.got {
+		if m.Key != want[i] || m.Destination != msgs[i].Topic || m.ID != msgs[i].ID {
+			t.Errorf("message %d: %+v", i, m)
+		}
+	}
+}
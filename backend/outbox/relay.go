@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RelayChannel is the NOTIFY channel handled messages are announced on.
+const RelayChannel = "outbox_relay"
+
+// relayNotice is the NOTIFY payload. It names the message rather than
+// carrying it, since payloads are limited to 8000 bytes.
+type relayNotice struct {
+	ID    string `json:"id"`
+	Topic string `json:"topic"`
+}
+
+// Notifying wraps next so each message it handles is announced on
+// RelayChannel, for processes that react to messages without draining the
+// outbox. A failed announcement is logged rather than retried: the message
+// itself was delivered.
+func Notifying(w db.Writer, next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, msg Message) error {
+		if err := next.HandleOutbox(ctx, msg); err != nil {
+			return err
+		}
+		payload, err := json.Marshal(relayNotice{ID: msg.ID, Topic: msg.Topic})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Exec(ctx, `SELECT pg_notify($1, $2)`, RelayChannel, string(payload)); err != nil {
+			log.Printf("outbox: announce %s: %v", msg.ID, err)
+		}
+		return nil
+	})
+}
+
+// Relay hands messages announced on RelayChannel to a handler, so a process
+// whose outbox is drained elsewhere still sees them. Delivery is at most
+// once: messages announced while the relay is reconnecting are missed, and
+// handler errors are only logged.
+type Relay struct {
+	pool     *pgxpool.Pool
+	messages *PGAdminRepository
+	handler  Handler
+	topics   map[string]bool
+}
+
+func NewRelay(pool *pgxpool.Pool, handler Handler) *Relay {
+	return &Relay{pool: pool, messages: NewAdminRepository(pool), handler: handler}
+}
+
+// WithTopics relays only messages on topics, sparing the lookup of the
+// others.
+func (r *Relay) WithTopics(topics ...string) *Relay {
+	r.topics = make(map[string]bool, len(topics))
+	for _, t := range topics {
+		r.topics[t] = true
+	}
+	return r
+}
+
+// Listen relays announcements until ctx is done, reconnecting with backoff
+// when the listening connection fails.
+func (r *Relay) Listen(ctx context.Context) error {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+	for {
+		err := r.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("outbox: relay stopped: %v (retrying in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+func (r *Relay) listenOnce(ctx context.Context) error {
+	pooled, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("outbox: acquire relay listener: %w", err)
+	}
+	// The session is left in LISTEN state, so it must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+RelayChannel); err != nil {
+		return fmt.Errorf("outbox: listen: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("outbox: wait for notification: %w", err)
+		}
+		if err := r.relay(ctx, n.Payload); err != nil && ctx.Err() == nil {
+			log.Printf("outbox: relay: %v", err)
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context, payload string) error {
+	var notice relayNotice
+	if err := json.Unmarshal([]byte(payload), &notice); err != nil {
+		return fmt.Errorf("decode notice %q: %w", payload, err)
+	}
+	if r.topics != nil && !r.topics[notice.Topic] {
+		return nil
+	}
+	msg, err := r.messages.GetMessage(ctx, notice.ID)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", notice.ID, err)
+	}
+	return r.handler.HandleOutbox(ctx, msg.Message)
+}
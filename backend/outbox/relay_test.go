@@ -0,0 +1,40 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"brokerflow/db/dbtest"
+)
+
+func TestNotifying_AnnouncesHandledMessages(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectExec("SELECT pg_notify($1, $2)").
+		WithArgs(RelayChannel, `{"id":"m1","topic":"match.accepted"}`)
+
+	h := Notifying(mock, HandlerFunc(func(context.Context, Message) error { return nil }))
+	if err := h.HandleOutbox(context.Background(), Message{ID: "m1", Topic: "match.accepted"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotifying_SkipsFailedMessages(t *testing.T) {
+	mock := dbtest.New(t)
+	failed := errors.New("down")
+
+	h := Notifying(mock, HandlerFunc(func(context.Context, Message) error { return failed }))
+	if err := h.HandleOutbox(context.Background(), Message{ID: "m1"}); !errors.Is(err, failed) {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+}
+
+func TestNotifying_DeliveredDespiteFailedAnnouncement(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectExec("pg_notify").WillReturnError(errors.New("conn closed"))
+
+	h := Notifying(mock, HandlerFunc(func(context.Context, Message) error { return nil }))
+	if err := h.HandleOutbox(context.Background(), Message{ID: "m1"}); err != nil {
+		t.Fatalf("expected the message to count as delivered, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TopicStats summarises what has been published on a topic.
+type TopicStats struct {
+	Topic           string
+	LastPublishedAt *time.Time
+	Total           int64
+	Pending         int64
+	Last24h         int64
+}
+
+// StatsReader loads per-topic publication statistics.
+type StatsReader interface {
+	TopicStats(ctx context.Context) (map[string]TopicStats, error)
+}
+
+// PGStatsRepository aggregates statistics straight from the outbox table.
+type PGStatsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewStatsRepository(pool *pgxpool.Pool) *PGStatsRepository {
+	return &PGStatsRepository{pool: pool}
+}
+
+func (r *PGStatsRepository) TopicStats(ctx context.Context) (map[string]TopicStats, error) {
+	const query = `
+		SELECT topic,
+		       MAX(created_at),
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'pending'),
+		       COUNT(*) FILTER (WHERE created_at >= get_tx_timestamp() - INTERVAL '24 hours')
+		FROM outbox
+		GROUP BY topic
+	`
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: query topic stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]TopicStats)
+	for rows.Next() {
+		var st TopicStats
+		if err := rows.Scan(&st.Topic, &st.LastPublishedAt, &st.Total, &st.Pending, &st.Last24h); err != nil {
+			return nil, fmt.Errorf("outbox: scan topic stats: %w", err)
+		}
+		stats[st.Topic] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: iterate topic stats: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,78 @@
+// Package outbox describes the event surface published through the
+// transactional outbox table. Producers declare the topics they write together
+// with a payload type and semantics; the API exposes the registry so
+// integrators can discover events without reading source.
+package outbox
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Topic documents one outbox topic.
+type Topic struct {
+	// Name is the value written to outbox.topic.
+	Name string
+	// Producer names the package or service that enqueues the topic.
+	Producer string
+	// Description explains when the message is published and what it means.
+	Description string
+	// Payload is a zero value of the payload type, used to derive its schema.
+	Payload any
+}
+
+// Registry holds the declared topics keyed by name.
+type Registry struct {
+	mu     sync.RWMutex
+	topics map[string]Topic
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{topics: make(map[string]Topic)}
+}
+
+// Register declares a topic. Names must be unique across producers.
+func (r *Registry) Register(t Topic) error {
+	if t.Name == "" {
+		return fmt.Errorf("outbox: topic name required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.topics[t.Name]; ok {
+		return fmt.Errorf("outbox: topic %q already registered by %s", t.Name, existing.Producer)
+	}
+	r.topics[t.Name] = t
+	return nil
+}
+
+// MustRegister declares topics and panics on conflicts. It is meant for
+// process wiring, where a duplicate topic is a programming error.
+func (r *Registry) MustRegister(topics ...Topic) {
+	for _, t := range topics {
+		if err := r.Register(t); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Lookup returns the topic declared under name.
+func (r *Registry) Lookup(name string) (Topic, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.topics[name]
+	return t, ok
+}
+
+// Topics returns every declared topic ordered by name.
+func (r *Registry) Topics() []Topic {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Topic, 0, len(r.topics))
+	for _, t := range r.topics {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,32 @@
+package outbox
+
+import "testing"
+
+func TestRegistry_RejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(Topic{Name: "a.created", Producer: "a"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Register(Topic{Name: "a.created", Producer: "b"}); err == nil {
+		t.Fatal("expected duplicate topic to be rejected")
+	}
+	if err := r.Register(Topic{Producer: "c"}); err == nil {
+		t.Fatal("expected unnamed topic to be rejected")
+	}
+}
+
+func TestRegistry_TopicsSortedByName(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Topic{Name: "z.done"}, Topic{Name: "a.created"}, Topic{Name: "m.changed"})
+
+	got := r.Topics()
+	want := []string{"a.created", "m.changed", "z.done"}
+	for i, name := range want {
+		if got[i].Name != name {
+			t.Fatalf("topic %d: expected %s, got %s", i, name, got[i].Name)
+		}
+	}
+	if _, ok := r.Lookup("m.changed"); !ok {
+		t.Fatal("expected lookup to find registered topic")
+	}
+}
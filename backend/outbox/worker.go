@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Delivery states written to outbox.status.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Message is one outbox row handed to a Handler.
+type Message struct {
+	ID        string
+	Topic     string
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// Handler consumes outbox messages. Returning an error leaves the message
+// pending for another attempt until the worker's MaxAttempts is reached.
+type Handler interface {
+	HandleOutbox(ctx context.Context, msg Message) error
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(ctx context.Context, msg Message) error
+
+func (f HandlerFunc) HandleOutbox(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Handlers hands each message to every handler in order. All of them run
+// even if one fails; the errors are joined, so a failure in one retries the
+// message for all and each must tolerate seeing it again.
+func Handlers(hs ...Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, msg Message) error {
+		var errs []error
+		for _, h := range hs {
+			if err := h.HandleOutbox(ctx, msg); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// WorkerConfig tunes a Worker. Zero values fall back to the defaults below.
+type WorkerConfig struct {
+	ID           string
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+}
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 50
+	defaultMaxAttempts  = 10
+)
+
+// Worker drains pending outbox rows in creation order. Rows are claimed with
+// FOR UPDATE SKIP LOCKED, so several workers may run against one database;
+// each message is handed to exactly one of them.
+type Worker struct {
+	pool       *pgxpool.Pool
+	handler    Handler
+	heartbeats *HeartbeatRepository
+	clock      clock.Clock
+	cfg        WorkerConfig
+}
+
+func NewWorker(pool *pgxpool.Pool, handler Handler, cfg WorkerConfig) *Worker {
+	if cfg.ID == "" {
+		cfg.ID = "outbox-worker"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	return &Worker{
+		pool:       pool,
+		handler:    handler,
+		heartbeats: NewHeartbeatRepository(pool),
+		clock:      clock.New(),
+		cfg:        cfg,
+	}
+}
+
+func (w *Worker) WithClock(c clock.Clock) *Worker {
+	w.clock = clock.OrReal(c)
+	return w
+}
+
+// Run polls until ctx is cancelled. A full batch is followed immediately by
+// the next one; otherwise the worker sleeps for PollInterval.
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		if err := w.heartbeats.Beat(ctx, w.cfg.ID); err != nil && ctx.Err() == nil {
+			log.Printf("outbox worker %s: %v", w.cfg.ID, err)
+		}
+		n, err := w.ProcessBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("outbox worker %s: %v", w.cfg.ID, err)
+		}
+		if n == w.cfg.BatchSize && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.clock.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+// ProcessBatch claims up to BatchSize pending messages, hands each to the
+// handler and records the outcome. It returns the number of messages claimed.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: begin batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	const claimSQL = `
+		SELECT id::text, topic, COALESCE(payload, 'null'::jsonb), attempts, created_at
+		FROM outbox
+		WHERE status = 'pending'
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(ctx, claimSQL, w.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: claim batch: %w", err)
+	}
+	msgs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Message, error) {
+		var m Message
+		err := row.Scan(&m.ID, &m.Topic, &m.Payload, &m.Attempts, &m.CreatedAt)
+		return m, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("outbox: scan batch: %w", err)
+	}
+
+	for _, msg := range msgs {
+		status := StatusDelivered
+		if herr := w.handler.HandleOutbox(ctx, msg); herr != nil {
+			log.Printf("outbox worker %s: deliver %s (%s): %v", w.cfg.ID, msg.ID, msg.Topic, herr)
+			status = StatusPending
+			if msg.Attempts+1 >= w.cfg.MaxAttempts {
+				status = StatusFailed
+			}
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE outbox
+			SET status = $2, attempts = attempts + 1, last_attempt = get_tx_timestamp()
+			WHERE id = $1::uuid
+		`, msg.ID, status); err != nil {
+			return 0, fmt.Errorf("outbox: record delivery of %s: %w", msg.ID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("outbox: commit batch: %w", err)
+	}
+	return len(msgs), nil
+}
@@ -0,0 +1,27 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHandlers_RunsAllAndJoinsErrors(t *testing.T) {
+	errFirst := errors.New("first")
+	var calls []string
+	h := Handlers(
+		HandlerFunc(func(context.Context, Message) error { calls = append(calls, "a"); return errFirst }),
+		HandlerFunc(func(context.Context, Message) error { calls = append(calls, "b"); return nil }),
+	)
+
+	err := h.HandleOutbox(context.Background(), Message{ID: "m1"})
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("expected joined error, got %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected both handlers to run, got %v", calls)
+	}
+	if err := Handlers().HandleOutbox(context.Background(), Message{}); err != nil {
+		t.Fatalf("expected nil for no handlers, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package privacy carries out the deferred part of account erasure: client
+// contacts supplied by an erased user are hard-deleted once the retention
+// window recorded in pii_erasures has passed.
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultPurgeBatchSize = 50
+
+// TxBeginner abstracts pgxpool.Pool for testability.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Purge describes one completed erasure.
+type Purge struct {
+	UserID   string
+	Contacts int
+	PurgedAt time.Time
+}
+
+// PurgeService deletes client contacts of erased users whose retention
+// window has ended.
+type PurgeService struct {
+	pool      TxBeginner
+	clock     clock.Clock
+	batchSize int
+}
+
+func NewPurgeService(pool TxBeginner) *PurgeService {
+	return &PurgeService{pool: pool, clock: clock.New(), batchSize: defaultPurgeBatchSize}
+}
+
+func (s *PurgeService) WithClock(c clock.Clock) *PurgeService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Run purges due erasures every interval until ctx is cancelled.
+func (s *PurgeService) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			purged, err := s.PurgeDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("pii purge: %v", err)
+				}
+				break
+			}
+			if len(purged) < s.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+// PurgeDue purges up to one batch of due erasures in a single transaction
+// via purge_user_pii, which also writes a PII_PURGED audit entry per
+// contact. Rows locked by another instance are skipped.
+func (s *PurgeService) PurgeDue(ctx context.Context) ([]Purge, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("privacy: begin purge: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        SELECT user_id::text
+        FROM pii_erasures
+        WHERE purged_at IS NULL AND purge_after <= get_tx_timestamp()
+        ORDER BY purge_after
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `, s.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("privacy: select due erasures: %w", err)
+	}
+	due, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("privacy: scan due erasures: %w", err)
+	}
+
+	out := make([]Purge, 0, len(due))
+	for _, userID := range due {
+		p := Purge{UserID: userID}
+		if err := tx.QueryRow(ctx, `SELECT purge_user_pii($1)`, userID).Scan(&p.Contacts); err != nil {
+			return nil, fmt.Errorf("privacy: purge pii of %s: %w", userID, err)
+		}
+		if err := tx.QueryRow(ctx, `
+            UPDATE pii_erasures
+            SET purged_at = get_tx_timestamp(), purged_contacts = $2
+            WHERE user_id = $1
+            RETURNING purged_at
+        `, userID, p.Contacts).Scan(&p.PurgedAt); err != nil {
+			return nil, fmt.Errorf("privacy: mark %s purged: %w", userID, err)
+		}
+		out = append(out, p)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("privacy: commit purge: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type failingPool struct {
+	begins int
+}
+
+func (f *failingPool) Begin(context.Context) (pgx.Tx, error) {
+	f.begins++
+	return nil, errors.New("database unavailable")
+}
+
+func TestPurgeRun_KeepsRunningAfterErrorsUntilCancelled(t *testing.T) {
+	pool := &failingPool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewPurgeService(pool).Run(ctx, time.Millisecond) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	if pool.begins < 2 {
+		t.Fatalf("expected repeated attempts after failures, got %d", pool.begins)
+	}
+}
+
+func TestPurgeDue_PropagatesBeginError(t *testing.T) {
+	if _, err := NewPurgeService(&failingPool{}).PurgeDue(context.Background()); err == nil {
+		t.Fatal("expected begin error")
+	}
+}
@@ -0,0 +1,169 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/tenancy"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultArchiveBatchSize = 100
+
+// DefaultArchiveAfter is how long a cancelled referral stays unchanged
+// before the retention job archives it.
+const DefaultArchiveAfter = 30 * 24 * time.Hour
+
+var ErrArchiveInvalidState = errors.New("referral: only closed or cancelled referrals can be archived")
+
+// ArchiveParams archives a referral within Scope.
+type ArchiveParams struct {
+	RequestID string
+	Scope     tenancy.Scope
+}
+
+// Archive hides a closed or cancelled referral from default lists and
+// enqueues referral.archived. Archiving an archived referral returns it
+// unchanged. Referrals outside params.Scope report ErrNotFound.
+func (s *Service) Archive(ctx context.Context, params ArchiveParams) (Request, error) {
+	if params.RequestID == "" {
+		return Request{}, fmt.Errorf("referral: archive missing request id")
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Request{}, fmt.Errorf("referral: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := s.repo.GetScopedForUpdate(ctx, tx, params.RequestID, params.Scope)
+	if err != nil {
+		return Request{}, err
+	}
+	if current.ArchivedAt != nil {
+		return current, nil
+	}
+	if current.Status != StatusClosed && current.Status != StatusCancelled {
+		return Request{}, ErrArchiveInvalidState
+	}
+
+	archived, err := s.repo.Archive(ctx, tx, params.RequestID)
+	if err != nil {
+		return Request{}, err
+	}
+
+	if s.outbox != nil {
+		payload := map[string]any{
+			"referral_id": archived.ID,
+			"status":      archived.Status,
+			"automatic":   false,
+		}
+		if err := s.outbox.Enqueue(ctx, tx, OutboxTopicReferralArchived, payload); err != nil {
+			return Request{}, fmt.Errorf("referral: enqueue archive outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Request{}, fmt.Errorf("referral: archive commit: %w", err)
+	}
+	return archived, nil
+}
+
+// ArchiveService archives cancelled referrals that have not changed for the
+// retention period, so abandoned requests leave their owners' lists without
+// anyone having to archive them by hand.
+type ArchiveService struct {
+	pool      TxBeginner
+	after     time.Duration
+	clock     clock.Clock
+	batchSize int
+}
+
+func NewArchiveService(pool TxBeginner) *ArchiveService {
+	return &ArchiveService{pool: pool, after: DefaultArchiveAfter, clock: clock.New(), batchSize: defaultArchiveBatchSize}
+}
+
+// WithRetention sets how long a cancelled referral is kept in lists; values
+// of zero or less keep the default.
+func (s *ArchiveService) WithRetention(after time.Duration) *ArchiveService {
+	if after > 0 {
+		s.after = after
+	}
+	return s
+}
+
+func (s *ArchiveService) WithClock(c clock.Clock) *ArchiveService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Run archives stale cancelled referrals every interval until ctx is
+// cancelled.
+func (s *ArchiveService) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			archived, err := s.ArchiveDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("referral archive: %v", err)
+				}
+				break
+			}
+			if len(archived) < s.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+// ArchiveDue archives up to one batch of referrals cancelled more than the
+// retention period ago in a single transaction and writes a
+// referral.archived outbox message for each. It returns their ids.
+func (s *ArchiveService) ArchiveDue(ctx context.Context) ([]string, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("referral: begin archive: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        UPDATE referral_requests
+        SET archived_at = get_tx_timestamp(), updated_at = get_tx_timestamp()
+        WHERE id IN (
+            SELECT id FROM referral_requests
+            WHERE status = $3 AND archived_at IS NULL
+              AND updated_at <= get_tx_timestamp() - make_interval(secs => $2)
+            ORDER BY updated_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id
+    `, s.batchSize, s.after.Seconds(), StatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("referral: archive cancelled referrals: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("referral: scan archived referrals: %w", err)
+	}
+	for _, id := range ids {
+		payload := map[string]any{"referral_id": id, "status": StatusCancelled, "automatic": true}
+		if err := (PGOutboxWriter{}).Enqueue(ctx, tx, OutboxTopicReferralArchived, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("referral: commit archive: %w", err)
+	}
+	return ids, nil
+}
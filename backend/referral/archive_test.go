@@ -0,0 +1,88 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestArchive_HidesClosedReferralsFromDefaultList(t *testing.T) {
+	ctx := context.Background()
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	repo := testsupport.NewReferrals(users)
+	svc := referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil)
+	owner := tenancy.Scope{UserID: "agent-1"}
+
+	created, err := svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.Archive(ctx, referral.ArchiveParams{RequestID: created.ID, Scope: owner}); !errors.Is(err, referral.ErrArchiveInvalidState) {
+		t.Fatalf("expected ErrArchiveInvalidState for an open referral, got %v", err)
+	}
+	if _, err := svc.Cancel(ctx, referral.CancelParams{RequestID: created.ID, ActorID: "agent-1", ActorRole: "agent"}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := svc.Archive(ctx, referral.ArchiveParams{RequestID: created.ID, Scope: tenancy.Scope{UserID: "someone-else"}}); !errors.Is(err, referral.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound outside the scope, got %v", err)
+	}
+
+	archived, err := svc.Archive(ctx, referral.ArchiveParams{RequestID: created.ID, Scope: owner})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if archived.ArchivedAt == nil {
+		t.Fatal("expected ArchivedAt to be set")
+	}
+	again, err := svc.Archive(ctx, referral.ArchiveParams{RequestID: created.ID, Scope: owner})
+	if err != nil || again.Version != archived.Version {
+		t.Fatalf("expected archiving twice to be a no-op, got %+v, %v", again, err)
+	}
+
+	list, err := svc.List(ctx, referral.Filters{Scope: owner})
+	if err != nil || list.Total != 0 {
+		t.Fatalf("expected the archived referral to be hidden, got %d (%v)", list.Total, err)
+	}
+	list, err = svc.List(ctx, referral.Filters{Scope: owner, IncludeArchived: true})
+	if err != nil || list.Total != 1 {
+		t.Fatalf("expected includeArchived to list it, got %d (%v)", list.Total, err)
+	}
+}
+
+type unavailablePool struct {
+	begins int
+}
+
+func (p *unavailablePool) Begin(context.Context) (pgx.Tx, error) {
+	p.begins++
+	return nil, errors.New("database unavailable")
+}
+
+func TestArchiveRun_KeepsRunningAfterErrorsUntilCancelled(t *testing.T) {
+	pool := &unavailablePool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- referral.NewArchiveService(pool).Run(ctx, time.Millisecond) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	if pool.begins < 2 {
+		t.Fatalf("expected repeated attempts after failures, got %d", pool.begins)
+	}
+}
@@ -0,0 +1,246 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"brokerflow/agreement"
+	"brokerflow/broker"
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CounterTerms are the fee rate (percent) and protection period a candidate
+// proposes instead of the referring broker's defaults.
+type CounterTerms struct {
+	FeeRate     float64
+	ProtectDays int
+}
+
+func (c CounterTerms) empty() bool { return c.FeeRate == 0 && c.ProtectDays == 0 }
+
+var (
+	ErrCounterInvalidTerms  = errors.New("referral: counter-offer needs a fee rate in (0, 100] and positive protect days")
+	ErrCounterNotCountering = errors.New("referral: counter terms only apply when countering")
+	ErrCounterOutOfBounds   = errors.New("referral: counter-offer is outside the referring broker's policy")
+	ErrCounterNotPending    = errors.New("referral: match has no counter-offer awaiting an answer")
+)
+
+// validateCounter checks c for a transition to state: terms are required when
+// countering and rejected otherwise.
+func validateCounter(state MatchState, c CounterTerms) error {
+	if state != MatchStateCountered {
+		if !c.empty() {
+			return ErrCounterNotCountering
+		}
+		return nil
+	}
+	if c.FeeRate <= 0 || c.FeeRate > 100 || c.ProtectDays <= 0 {
+		return ErrCounterInvalidTerms
+	}
+	return nil
+}
+
+// Counter moves a live invitation to countered. The terms must fall within
+// the policy of the referring broker, whose defaults they replace.
+func (r *PGMatchRepository) Counter(ctx context.Context, matchID string, terms CounterTerms) (Match, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: begin counter: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		state       string
+		lapsed      bool
+		ownerBroker *string
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT m.state::text, COALESCE(m.expires_at <= get_tx_timestamp(), false), u.broker_id::text
+		FROM referral_matches m
+		JOIN referral_requests rr ON rr.id = m.request_id
+		JOIN users u ON u.id = rr.created_by_user_id
+		WHERE m.id = $1
+		FOR UPDATE OF m
+	`, matchID).Scan(&state, &lapsed, &ownerBroker); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Match{}, ErrMatchNotFound
+		}
+		return Match{}, fmt.Errorf("referral: lock match for counter: %w", err)
+	}
+	switch {
+	case MatchState(state) == MatchStateExpired, MatchState(state) == MatchStateInvited && lapsed:
+		return Match{}, ErrMatchExpired
+	case MatchState(state) != MatchStateInvited:
+		return Match{}, ErrMatchInvalidTransition
+	}
+	if ownerBroker != nil {
+		policy, err := broker.LoadSettings(ctx, tx, *ownerBroker)
+		if err != nil {
+			return Match{}, fmt.Errorf("referral: load referral policy: %w", err)
+		}
+		if err := policy.Check(terms.FeeRate, terms.ProtectDays); err != nil {
+			return Match{}, fmt.Errorf("%w: %w", ErrCounterOutOfBounds, err)
+		}
+	}
+
+	m, err := scanMatch(tx.QueryRow(ctx, `
+		UPDATE referral_matches
+		SET state = 'countered'::referral_match_state,
+			counter_fee_rate = $2,
+			counter_protect_days = $3,
+			countered_at = get_tx_timestamp()
+		WHERE id = $1
+		RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
+	`, matchID, terms.FeeRate, terms.ProtectDays))
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: counter match: %w", err)
+	}
+	if err := enqueueMatchEvent(ctx, tx, m.ID, m.State); err != nil {
+		return Match{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Match{}, fmt.Errorf("referral: commit counter: %w", err)
+	}
+	return m, nil
+}
+
+// ResolveCounter answers a counter-offer on its own, without creating an
+// agreement; MatchService uses it to reject, and to accept when it has no
+// agreement repository.
+func (r *PGMatchRepository) ResolveCounter(ctx context.Context, matchID, ownerID string, outcome MatchState) (Match, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: begin resolve counter: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := resolveCounterLocked(ctx, tx, matchID, ownerID, outcome); err != nil {
+		return Match{}, err
+	}
+	m, err := scanMatch(tx.QueryRow(ctx, `
+		SELECT id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
+		FROM referral_matches
+		WHERE id = $1
+	`, matchID))
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: reload match: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Match{}, fmt.Errorf("referral: commit resolve counter: %w", err)
+	}
+	return m, nil
+}
+
+// resolveCounterLocked locks ownerID's countered match and moves it to
+// outcome: accepted keeps the terms, invited drops them and restarts the
+// invitation TTL. A match already accepted on a counter-offer is accepted
+// again so retries are idempotent. It returns the countered terms.
+func resolveCounterLocked(ctx context.Context, tx pgx.Tx, matchID, ownerID string, outcome MatchState) (CounterTerms, error) {
+	var (
+		state       string
+		feeRate     *float64
+		protectDays *int
+		owned       bool
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT m.state::text, m.counter_fee_rate, m.counter_protect_days, rr.created_by_user_id = $2
+		FROM referral_matches m
+		JOIN referral_requests rr ON rr.id = m.request_id
+		WHERE m.id = $1
+		FOR UPDATE OF m
+	`, matchID, ownerID).Scan(&state, &feeRate, &protectDays, &owned); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return CounterTerms{}, ErrMatchNotFound
+		}
+		return CounterTerms{}, fmt.Errorf("referral: lock match for counter answer: %w", err)
+	}
+	if !owned {
+		return CounterTerms{}, ErrReferralNotOwned
+	}
+	countered := feeRate != nil && protectDays != nil
+	if MatchState(state) == MatchStateAccepted && outcome == MatchStateAccepted && countered {
+		return CounterTerms{FeeRate: *feeRate, ProtectDays: *protectDays}, nil
+	}
+	if MatchState(state) != MatchStateCountered || !countered {
+		return CounterTerms{}, ErrCounterNotPending
+	}
+
+	query := `UPDATE referral_matches SET state = 'accepted'::referral_match_state WHERE id = $1`
+	if outcome == MatchStateInvited {
+		query = `
+			UPDATE referral_matches
+			SET state = 'invited'::referral_match_state,
+				counter_fee_rate = NULL,
+				counter_protect_days = NULL,
+				countered_at = NULL
+			WHERE id = $1
+		`
+	}
+	if _, err := tx.Exec(ctx, query, matchID); err != nil {
+		return CounterTerms{}, fmt.Errorf("referral: answer counter-offer: %w", err)
+	}
+	if err := enqueueMatchEvent(ctx, tx, matchID, outcome); err != nil {
+		return CounterTerms{}, err
+	}
+	return CounterTerms{FeeRate: *feeRate, ProtectDays: *protectDays}, nil
+}
+
+// RespondCounterParams is the referral owner's answer to a counter-offer.
+type RespondCounterParams struct {
+	MatchID     string
+	OwnerUserID string
+	Accept      bool
+}
+
+// RespondToCounter accepts or rejects a counter-offer for the referral
+// owner. Accepting creates the agreement on the countered terms in the same
+// transaction, as a candidate's acceptance does with the default terms;
+// rejecting reopens the invitation for the candidate to answer again.
+func (s *MatchService) RespondToCounter(ctx context.Context, params RespondCounterParams) (MatchUpdateResult, error) {
+	if !params.Accept || s.agRepo == nil || s.tx == nil {
+		outcome := MatchStateInvited
+		if params.Accept {
+			outcome = MatchStateAccepted
+		}
+		m, err := s.repo.ResolveCounter(ctx, params.MatchID, params.OwnerUserID, outcome)
+		if err != nil {
+			return MatchUpdateResult{}, err
+		}
+		return MatchUpdateResult{Match: m}, nil
+	}
+
+	match, err := s.repo.GetByID(ctx, params.MatchID)
+	if err != nil {
+		return MatchUpdateResult{}, err
+	}
+	var rec agreement.Record
+	err = db.Retry(ctx, db.DefaultRetryPolicy, func(ctx context.Context) error {
+		return s.tx.InTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+			terms, err := resolveCounterLocked(ctx, tx, match.ID, params.OwnerUserID, MatchStateAccepted)
+			if err != nil {
+				return err
+			}
+			rec, err = s.agRepo.CreateFromMatch(ctx, tx, agreement.MatchAcceptanceParams{
+				MatchID:          match.ID,
+				RequestID:        match.RequestID,
+				CandidateUserID:  match.CandidateAgentID,
+				AcceptedByUserID: params.OwnerUserID,
+				AcceptedAt:       s.clock.Now(),
+				Terms:            &agreement.MatchTerms{FeeRate: terms.FeeRate, ProtectDays: terms.ProtectDays},
+			})
+			return err
+		})
+	})
+	if err != nil {
+		return MatchUpdateResult{}, err
+	}
+
+	accepted, err := s.repo.GetByID(ctx, match.ID)
+	if err != nil {
+		return MatchUpdateResult{}, err
+	}
+	return MatchUpdateResult{Match: accepted, Agreement: &rec}, nil
+}
@@ -0,0 +1,78 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateCounter(t *testing.T) {
+	cases := []struct {
+		name    string
+		state   MatchState
+		in      CounterTerms
+		wantErr error
+	}{
+		{"counter", MatchStateCountered, CounterTerms{FeeRate: 25, ProtectDays: 60}, nil},
+		{"missing terms", MatchStateCountered, CounterTerms{}, ErrCounterInvalidTerms},
+		{"fee over 100", MatchStateCountered, CounterTerms{FeeRate: 120, ProtectDays: 60}, ErrCounterInvalidTerms},
+		{"no protect days", MatchStateCountered, CounterTerms{FeeRate: 25}, ErrCounterInvalidTerms},
+		{"terms on accept", MatchStateAccepted, CounterTerms{FeeRate: 25, ProtectDays: 60}, ErrCounterNotCountering},
+		{"plain decline", MatchStateDeclined, CounterTerms{}, nil},
+	}
+	for _, tc := range cases {
+		if err := validateCounter(tc.state, tc.in); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestMatchService_UpdateStateCounters(t *testing.T) {
+	repo := &stubMatchRepository{match: Match{ID: "m1", CandidateAgentID: "agent-2", State: MatchStateInvited}}
+	svc := NewMatchService(repo)
+
+	res, err := svc.UpdateState(context.Background(), UpdateMatchParams{
+		MatchID:     "m1",
+		CandidateID: "agent-2",
+		NewState:    MatchStateCountered,
+		Counter:     CounterTerms{FeeRate: 22.5, ProtectDays: 120},
+	})
+	if err != nil {
+		t.Fatalf("counter: %v", err)
+	}
+	if repo.counter != (CounterTerms{FeeRate: 22.5, ProtectDays: 120}) || res.Match.State != MatchStateCountered {
+		t.Fatalf("unexpected counter %+v, match %+v", repo.counter, res.Match)
+	}
+}
+
+func TestMatchService_UpdateStateWaitsOnCounter(t *testing.T) {
+	for _, params := range []UpdateMatchParams{
+		{NewState: MatchStateAccepted},
+		{NewState: MatchStateDeclined},
+		{NewState: MatchStateCountered, Counter: CounterTerms{FeeRate: 20, ProtectDays: 30}},
+	} {
+		repo := &stubMatchRepository{match: Match{ID: "m1", CandidateAgentID: "agent-2", State: MatchStateCountered}}
+		params.MatchID, params.CandidateID = "m1", "agent-2"
+		if _, err := NewMatchService(repo).UpdateState(context.Background(), params); !errors.Is(err, ErrMatchInvalidTransition) || repo.updated {
+			t.Errorf("%s while countered: got %v, updated %v", params.NewState, err, repo.updated)
+		}
+	}
+}
+
+func TestMatchService_RespondToCounterWithoutAgreements(t *testing.T) {
+	for _, tc := range []struct {
+		accept bool
+		want   MatchState
+	}{{true, MatchStateAccepted}, {false, MatchStateInvited}} {
+		repo := &stubMatchRepository{match: Match{ID: "m1", CandidateAgentID: "agent-2", State: MatchStateCountered}}
+		res, err := NewMatchService(repo).RespondToCounter(context.Background(), RespondCounterParams{
+			MatchID: "m1", OwnerUserID: "owner-1", Accept: tc.accept,
+		})
+		if err != nil {
+			t.Fatalf("accept=%v: %v", tc.accept, err)
+		}
+		if repo.ownerID != "owner-1" || res.Match.State != tc.want || res.Agreement != nil {
+			t.Fatalf("accept=%v: owner %q, result %+v", tc.accept, repo.ownerID, res)
+		}
+	}
+}
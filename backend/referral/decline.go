@@ -0,0 +1,73 @@
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DeclineReason is the structured reason a candidate gives for declining.
+type DeclineReason string
+
+const (
+	DeclineNotMyArea    DeclineReason = "not_my_area"
+	DeclineNoCapacity   DeclineReason = "no_capacity"
+	DeclinePriceRange   DeclineReason = "price_range"
+	DeclinePropertyType DeclineReason = "property_type"
+	DeclineLanguage     DeclineReason = "language"
+	DeclineFeeTerms     DeclineReason = "fee_terms"
+	DeclineReasonOther  DeclineReason = "other"
+)
+
+const maxDeclineNoteLength = 500
+
+// DeclineReasons lists the accepted decline reasons.
+func DeclineReasons() []DeclineReason {
+	return []DeclineReason{
+		DeclineNotMyArea, DeclineNoCapacity, DeclinePriceRange,
+		DeclinePropertyType, DeclineLanguage, DeclineFeeTerms, DeclineReasonOther,
+	}
+}
+
+var (
+	ErrInvalidDeclineReason = errors.New("referral: invalid decline reason")
+	ErrDeclineNoteRequired  = errors.New("referral: decline note required when reason is other")
+	ErrDeclineNoteTooLong   = fmt.Errorf("referral: decline note exceeds %d characters", maxDeclineNoteLength)
+	ErrDeclineNotDeclining  = errors.New("referral: decline reason only applies when declining")
+)
+
+// Decline carries the optional reason recorded with a declined match.
+type Decline struct {
+	Reason DeclineReason
+	Note   string
+}
+
+func (d Decline) empty() bool { return d.Reason == "" && d.Note == "" }
+
+// validateDecline normalizes d for a transition to state. A reason is
+// optional, but a note needs a reason and "other" needs a note.
+func validateDecline(state MatchState, d Decline) (Decline, error) {
+	d.Reason = DeclineReason(strings.ToLower(strings.TrimSpace(string(d.Reason))))
+	d.Note = strings.TrimSpace(d.Note)
+	if d.empty() {
+		return d, nil
+	}
+	if state != MatchStateDeclined {
+		return Decline{}, ErrDeclineNotDeclining
+	}
+	valid := false
+	for _, r := range DeclineReasons() {
+		valid = valid || r == d.Reason
+	}
+	if !valid {
+		return Decline{}, ErrInvalidDeclineReason
+	}
+	if d.Reason == DeclineReasonOther && d.Note == "" {
+		return Decline{}, ErrDeclineNoteRequired
+	}
+	if utf8.RuneCountInString(d.Note) > maxDeclineNoteLength {
+		return Decline{}, ErrDeclineNoteTooLong
+	}
+	return d, nil
+}
@@ -0,0 +1,50 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDecline(t *testing.T) {
+	cases := []struct {
+		name    string
+		state   MatchState
+		in      Decline
+		want    Decline
+		wantErr error
+	}{
+		{"no reason", MatchStateDeclined, Decline{}, Decline{}, nil},
+		{"reason normalized", MatchStateDeclined, Decline{Reason: " Price_Range ", Note: " too high "}, Decline{Reason: DeclinePriceRange, Note: "too high"}, nil},
+		{"unknown reason", MatchStateDeclined, Decline{Reason: "bored"}, Decline{}, ErrInvalidDeclineReason},
+		{"note without reason", MatchStateDeclined, Decline{Note: "why"}, Decline{}, ErrInvalidDeclineReason},
+		{"other needs note", MatchStateDeclined, Decline{Reason: DeclineReasonOther}, Decline{}, ErrDeclineNoteRequired},
+		{"note too long", MatchStateDeclined, Decline{Reason: DeclineNoCapacity, Note: strings.Repeat("x", maxDeclineNoteLength+1)}, Decline{}, ErrDeclineNoteTooLong},
+		{"reason on accept", MatchStateAccepted, Decline{Reason: DeclineNoCapacity}, Decline{}, ErrDeclineNotDeclining},
+	}
+	for _, tc := range cases {
+		got, err := validateDecline(tc.state, tc.in)
+		if !errors.Is(err, tc.wantErr) || got != tc.want {
+			t.Errorf("%s: got (%+v, %v), want (%+v, %v)", tc.name, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestMatchService_UpdateStateStoresDeclineReason(t *testing.T) {
+	repo := &stubMatchRepository{match: Match{ID: "m1", CandidateAgentID: "agent-2", State: MatchStateInvited}}
+	svc := NewMatchService(repo)
+
+	_, err := svc.UpdateState(context.Background(), UpdateMatchParams{
+		MatchID:     "m1",
+		CandidateID: "agent-2",
+		NewState:    MatchStateDeclined,
+		Decline:     Decline{Reason: "language", Note: " Spanish only "},
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if repo.decline != (Decline{Reason: DeclineLanguage, Note: "Spanish only"}) {
+		t.Fatalf("unexpected decline passed to repository: %+v", repo.decline)
+	}
+}
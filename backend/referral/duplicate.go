@@ -0,0 +1,48 @@
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/money"
+)
+
+// DefaultDuplicateWindow is how far back Create looks for a referral the new
+// one may duplicate.
+const DefaultDuplicateWindow = 24 * time.Hour
+
+var ErrDuplicate = errors.New("referral: suspected duplicate")
+
+// DuplicateError reports that the creator already has a similar referral:
+// created within the duplicate window, still live, with the same deal type
+// and currency and an overlapping region and price range. Existing is that referral; the
+// error matches ErrDuplicate.
+type DuplicateError struct {
+	Existing Request
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("referral: suspected duplicate of %s", e.Existing.ID)
+}
+
+func (e *DuplicateError) Is(target error) bool { return target == ErrDuplicate }
+
+// DuplicateQuery describes the referrals a new one would duplicate.
+type DuplicateQuery struct {
+	CreatorUserID string
+	Region        []string
+	DealType      string
+	PriceMin      int64
+	PriceMax      int64
+	Currency      money.Currency
+	// Since bounds created_at; older referrals are not duplicates.
+	Since time.Time
+}
+
+// WithDuplicateWindow sets how far back Create looks for duplicates; zero
+// or less turns the check off.
+func (s *Service) WithDuplicateWindow(window time.Duration) *Service {
+	s.duplicateWindow = window
+	return s
+}
@@ -0,0 +1,73 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/auth"
+	"brokerflow/clock"
+	"brokerflow/money"
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+)
+
+func TestCreate_RejectsLikelyDuplicateUnlessForced(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	users := testsupport.NewUsers()
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent})
+	repo := testsupport.NewReferrals(users).WithClock(clk)
+	svc := referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil).WithClock(clk)
+
+	params := referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Austin", "Round Rock"}, PriceMin: 100, PriceMax: 200, DealType: "buy", SLAHours: 24}
+	first, err := svc.Create(ctx, params)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	similar := params
+	similar.Region = []string{"Round Rock"}
+	similar.PriceMin, similar.PriceMax = 150, 300
+	_, err = svc.Create(ctx, similar)
+	var dup *referral.DuplicateError
+	if !errors.As(err, &dup) || !errors.Is(err, referral.ErrDuplicate) || dup.Existing.ID != first.ID {
+		t.Fatalf("expected a duplicate of %s, got %v", first.ID, err)
+	}
+
+	for name, p := range map[string]referral.CreateParams{
+		"other creator":  {CreatorUserID: "agent-2", Region: params.Region, PriceMin: 100, PriceMax: 200, DealType: "buy", SLAHours: 24},
+		"other region":   {CreatorUserID: "agent-1", Region: []string{"Dallas"}, PriceMin: 100, PriceMax: 200, DealType: "buy", SLAHours: 24},
+		"other deal":     {CreatorUserID: "agent-1", Region: params.Region, PriceMin: 100, PriceMax: 200, DealType: "sell", SLAHours: 24},
+		"other price":    {CreatorUserID: "agent-1", Region: params.Region, PriceMin: 500, PriceMax: 900, DealType: "buy", SLAHours: 24},
+		"other currency": {CreatorUserID: "agent-1", Region: params.Region, PriceMin: 100, PriceMax: 200, Currency: money.CAD, DealType: "buy", SLAHours: 24},
+	} {
+		if _, err := svc.Create(ctx, p); err != nil {
+			t.Fatalf("%s: expected no duplicate, got %v", name, err)
+		}
+	}
+
+	similar.Force = true
+	if _, err := svc.Create(ctx, similar); err != nil {
+		t.Fatalf("forced create: %v", err)
+	}
+
+	clk.Advance(referral.DefaultDuplicateWindow + time.Minute)
+	if _, err := svc.Create(ctx, params); err != nil {
+		t.Fatalf("expected no duplicate outside the window, got %v", err)
+	}
+}
+
+func TestCreate_DuplicateCheckCanBeDisabled(t *testing.T) {
+	ctx := context.Background()
+	repo := testsupport.NewReferrals(testsupport.NewUsers())
+	svc := referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil).WithDuplicateWindow(0)
+
+	params := referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, DealType: "buy", SLAHours: 24}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Create(ctx, params); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+}
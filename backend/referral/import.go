@@ -0,0 +1,313 @@
+package referral
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"brokerflow/money"
+	"brokerflow/region"
+)
+
+const (
+	// MaxImportRows caps the data rows accepted by one import.
+	MaxImportRows = 5000
+	// DefaultImportBatchSize is the number of rows committed per transaction.
+	DefaultImportBatchSize = 100
+)
+
+var (
+	ErrImportEmpty     = errors.New("referral: import has no rows")
+	ErrImportTooMany   = fmt.Errorf("referral: import exceeds %d rows", MaxImportRows)
+	ErrImportHeader    = errors.New("referral: invalid import header")
+	ErrImportMalformed = errors.New("referral: malformed CSV")
+)
+
+// ImportRowStatus is the outcome of one CSV row.
+type ImportRowStatus string
+
+const (
+	ImportRowCreated ImportRowStatus = "created"
+	// ImportRowInvalid rows failed validation and were never written.
+	ImportRowInvalid ImportRowStatus = "invalid"
+	// ImportRowFailed rows were valid but their batch did not commit.
+	ImportRowFailed ImportRowStatus = "failed"
+)
+
+// ImportRowResult reports one data row. Line is the row's line in the file,
+// counting the header as line 1. Request is set for created rows, Err
+// otherwise.
+type ImportRowResult struct {
+	Line    int
+	Status  ImportRowStatus
+	Request *Request
+	Err     error
+}
+
+type ImportSummary struct {
+	Rows    []ImportRowResult
+	Created int
+	Invalid int
+	Failed  int
+}
+
+// importColumns maps normalised header names to setters. List columns are
+// separated by semicolons.
+var importColumns = map[string]func(p *CreateParams, v string) error{
+	"region": func(p *CreateParams, v string) error {
+		p.Region = splitList(v)
+		return nil
+	},
+	"pricemin":     func(p *CreateParams, v string) error { return parseInt(&p.PriceMin, "price_min", v) },
+	"pricemax":     func(p *CreateParams, v string) error { return parseInt(&p.PriceMax, "price_max", v) },
+	"currency":     func(p *CreateParams, v string) error { p.Currency = money.Currency(v); return nil },
+	"propertytype": func(p *CreateParams, v string) error { p.PropertyType = v; return nil },
+	"dealtype":     func(p *CreateParams, v string) error { p.DealType = v; return nil },
+	"languages": func(p *CreateParams, v string) error {
+		p.Languages = splitList(v)
+		return nil
+	},
+	"slahours": func(p *CreateParams, v string) error {
+		var n int64
+		err := parseInt(&n, "sla_hours", v)
+		p.SLAHours = int(n)
+		return err
+	},
+	"matchttlhours": func(p *CreateParams, v string) error {
+		if v == "" {
+			return nil
+		}
+		var n int64
+		err := parseInt(&n, "match_ttl_hours", v)
+		p.MatchTTLHours = int(n)
+		return err
+	},
+}
+
+var requiredImportColumns = []string{"region", "price_min", "price_max", "sla_hours"}
+
+// ImportService creates referrals from a CSV export, for brokerages moving
+// off spreadsheets. Rows go through the same validation and side effects as
+// Service.Create.
+type ImportService struct {
+	svc       *Service
+	batchSize int
+}
+
+func NewImportService(svc *Service) *ImportService {
+	return &ImportService{svc: svc, batchSize: DefaultImportBatchSize}
+}
+
+func (s *ImportService) WithBatchSize(n int) *ImportService {
+	if n > 0 {
+		s.batchSize = n
+	}
+	return s
+}
+
+// Import parses the whole file before writing anything, so a malformed file
+// or header creates no referrals. Valid rows are then created in batches of
+// batchSize, each in one transaction: a batch that fails to commit is
+// reported as failed row by row and the import moves on to the next, so
+// re-importing only the failed rows is safe.
+func (s *ImportService) Import(ctx context.Context, creatorUserID string, r io.Reader) (ImportSummary, error) {
+	rows, err := parseImport(r, creatorUserID)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+
+	var summary ImportSummary
+	summary.Rows = make([]ImportRowResult, len(rows))
+	pending := make([]int, 0, len(rows))
+	for i, row := range rows {
+		summary.Rows[i].Line = row.line
+		if row.err == nil {
+			rows[i].params.Region, rows[i].err = s.svc.resolveRegions(ctx, row.params.Region)
+			if rows[i].err != nil && !errors.Is(rows[i].err, region.ErrUnknownRegion) && !errors.Is(rows[i].err, ErrRegionRequired) {
+				return ImportSummary{}, rows[i].err
+			}
+		}
+		if rows[i].err != nil {
+			summary.Rows[i].Status, summary.Rows[i].Err = ImportRowInvalid, rows[i].err
+			summary.Invalid++
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(pending); start += s.batchSize {
+		batch := pending[start:min(start+s.batchSize, len(pending))]
+		created, err := s.createBatch(ctx, rows, batch)
+		for j, i := range batch {
+			if err != nil {
+				summary.Rows[i].Status, summary.Rows[i].Err = ImportRowFailed, err
+				summary.Failed++
+				continue
+			}
+			summary.Rows[i].Status, summary.Rows[i].Request = ImportRowCreated, &created[j]
+			summary.Created++
+		}
+	}
+	return summary, nil
+}
+
+func (s *ImportService) createBatch(ctx context.Context, rows []importRow, batch []int) ([]Request, error) {
+	tx, err := s.svc.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("referral: begin import batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	created := make([]Request, 0, len(batch))
+	for _, i := range batch {
+		req, err := s.svc.createInTx(ctx, tx, rows[i].params)
+		if err != nil {
+			return nil, fmt.Errorf("referral: import line %d: %w", rows[i].line, err)
+		}
+		created = append(created, req)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("referral: commit import batch: %w", err)
+	}
+	return created, nil
+}
+
+type importRow struct {
+	line   int
+	params CreateParams
+	err    error
+}
+
+func parseImport(r io.Reader, creatorUserID string) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrImportEmpty
+	}
+	if err != nil {
+		return nil, importReadError(err)
+	}
+	columns, err := mapImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, importReadError(err)
+		}
+		if blankRecord(record) {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrImportTooMany
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line}
+		if len(record) != len(header) {
+			row.err = fmt.Errorf("referral: expected %d fields, got %d", len(header), len(record))
+			rows = append(rows, row)
+			continue
+		}
+		row.params.CreatorUserID = creatorUserID
+		for i, set := range columns {
+			if set == nil {
+				continue
+			}
+			if err := set(&row.params, strings.TrimSpace(record[i])); err != nil {
+				row.err = err
+				break
+			}
+		}
+		if row.err == nil {
+			row.params, row.err = validateCreate(row.params)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, ErrImportEmpty
+	}
+	return rows, nil
+}
+
+// importReadError tells CSV syntax errors apart from failures of the
+// underlying reader, such as an upload exceeding its size limit.
+func importReadError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: %v", ErrImportMalformed, err)
+	}
+	return fmt.Errorf("referral: read import: %w", err)
+}
+
+// mapImportHeader returns the setter for each column, nil for columns the
+// import ignores. Names match case-insensitively in snake_case or camelCase.
+func mapImportHeader(header []string) ([]func(*CreateParams, string) error, error) {
+	columns := make([]func(*CreateParams, string) error, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		key := normalizeColumn(name)
+		set, ok := importColumns[key]
+		if !ok {
+			continue
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrImportHeader, name)
+		}
+		seen[key] = true
+		columns[i] = set
+	}
+	for _, name := range requiredImportColumns {
+		if !seen[normalizeColumn(name)] {
+			return nil, fmt.Errorf("%w: missing column %q", ErrImportHeader, name)
+		}
+	}
+	return columns, nil
+}
+
+func normalizeColumn(name string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func parseInt(dst *int64, column, v string) error {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("referral: %s must be an integer", column)
+	}
+	*dst = n
+	return nil
+}
+
+func blankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
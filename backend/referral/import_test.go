@@ -0,0 +1,105 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// failingReferrals rejects inserts of one property type, standing in for a
+// constraint violation discovered only at write time.
+type failingReferrals struct {
+	*testsupport.Referrals
+	failOn string
+}
+
+func (f failingReferrals) Create(ctx context.Context, tx pgx.Tx, req referral.Request) (referral.Request, error) {
+	if req.PropertyType == f.failOn {
+		return referral.Request{}, errors.New("insert rejected")
+	}
+	return f.Referrals.Create(ctx, tx, req)
+}
+
+func TestImport_ReportsRowsAndCommitsBatches(t *testing.T) {
+	pool := &testsupport.TxBeginner{}
+	repo := failingReferrals{Referrals: testsupport.NewReferrals(testsupport.NewUsers()), failOn: "castle"}
+	svc := referral.NewImportService(referral.NewService(pool, repo, nil, nil)).WithBatchSize(2)
+
+	csv := "\ufeffRegion,priceMin,Price_Max,property_type,deal_type,languages,sla_hours,notes\n" +
+		"Austin;Dallas,100,200,condo,buy,en;es,24,first\n" +
+		"Austin,300,200,condo,buy,,24,price range reversed\n" +
+		"Austin,100,200,condo,buy,,abc,\n" +
+		"\n" +
+		"Austin,100,200,condo,sell,,48,\n" +
+		"Austin,100,200,castle,buy,,24,\n" +
+		"Austin,100,200,condo,buy,,24,\n"
+	summary, err := svc.Import(context.Background(), "agent-1", strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	want := []struct {
+		line   int
+		status referral.ImportRowStatus
+	}{
+		{2, referral.ImportRowCreated},
+		{3, referral.ImportRowInvalid},
+		{4, referral.ImportRowInvalid},
+		{6, referral.ImportRowCreated},
+		{7, referral.ImportRowFailed},
+		{8, referral.ImportRowFailed},
+	}
+	if len(summary.Rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), summary.Rows)
+	}
+	for i, w := range want {
+		if got := summary.Rows[i]; got.Line != w.line || got.Status != w.status {
+			t.Fatalf("row %d: expected line %d %s, got %+v", i, w.line, w.status, got)
+		}
+	}
+	if !errors.Is(summary.Rows[1].Err, referral.ErrInvalidPriceRange) {
+		t.Fatalf("expected a price range error, got %v", summary.Rows[1].Err)
+	}
+	first := summary.Rows[0].Request
+	if first == nil || len(first.Region) != 2 || len(first.Languages) != 2 || first.CreatorUserID != "agent-1" || first.MatchTTLHours != referral.DefaultMatchTTLHours {
+		t.Fatalf("unexpected first referral %+v", first)
+	}
+	if summary.Created != 2 || summary.Invalid != 2 || summary.Failed != 2 {
+		t.Fatalf("unexpected totals %+v", summary)
+	}
+	// Valid rows 2 and 6 share a batch; 7 and 8 roll back together.
+	if pool.Commits() != 1 || pool.Rollbacks() != 1 {
+		t.Fatalf("expected 1 commit and 1 rollback, got %d/%d", pool.Commits(), pool.Rollbacks())
+	}
+}
+
+func TestImport_RejectsFileBeforeWriting(t *testing.T) {
+	cases := []struct {
+		name string
+		csv  string
+		want error
+	}{
+		{"empty", "", referral.ErrImportEmpty},
+		{"header only", "region,price_min,price_max,sla_hours\n", referral.ErrImportEmpty},
+		{"missing column", "region,price_min,price_max\nAustin,1,2\n", referral.ErrImportHeader},
+		{"duplicate column", "region,price_min,priceMin,price_max,sla_hours\n", referral.ErrImportHeader},
+		{"bad quoting", "region,price_min,price_max,sla_hours\n\"Austin,1,2,24\nAustin,1,2,24\n", referral.ErrImportMalformed},
+	}
+	for _, tc := range cases {
+		pool := &testsupport.TxBeginner{}
+		svc := referral.NewImportService(referral.NewService(pool, testsupport.NewReferrals(testsupport.NewUsers()), nil, nil))
+		_, err := svc.Import(context.Background(), "agent-1", strings.NewReader(tc.csv))
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+		if pool.Commits() != 0 {
+			t.Fatalf("%s: nothing should be committed", tc.name)
+		}
+	}
+}
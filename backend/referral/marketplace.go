@@ -0,0 +1,314 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"brokerflow/money"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrMarketplaceNotSubscribed = errors.New("referral: not subscribed to the marketplace")
+	ErrMarketplaceRegions       = errors.New("referral: marketplace subscription needs at least one region")
+	ErrListingNotFound          = errors.New("referral: marketplace listing not found")
+)
+
+// MarketplaceSubscription is an agent's opt-in to the marketplace feed.
+// Regions and Languages are stored lower-cased; empty Languages matches
+// referrals in any language.
+type MarketplaceSubscription struct {
+	UserID    string
+	Regions   []string
+	Languages []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Listing is the anonymized marketplace view of an open referral: it carries
+// the referral's terms but not who created it.
+type Listing struct {
+	ID           string
+	Region       []string
+	PriceMin     int64
+	PriceMax     int64
+	Currency     money.Currency
+	PropertyType string
+	DealType     string
+	Languages    []string
+	SLAHours     int
+	CreatedAt    time.Time
+}
+
+type MarketplaceRepository interface {
+	Subscribe(ctx context.Context, sub MarketplaceSubscription) (MarketplaceSubscription, error)
+	Unsubscribe(ctx context.Context, userID string) error
+	// ListListings returns one page of listings visible to userID's
+	// subscription and the total across pages.
+	ListListings(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error)
+	// Apply records userID's application to a visible listing as an
+	// applied match with the given score.
+	Apply(ctx context.Context, userID, requestID string, score float64) (Match, error)
+}
+
+// ListingSearcher answers ListListings from a search index; see
+// MarketplaceService.WithSearch.
+type ListingSearcher interface {
+	SearchListings(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error)
+}
+
+// CandidateScorer rates how well a candidate fits a referral, from 0 to 1.
+type CandidateScorer interface {
+	ScoreCandidate(ctx context.Context, candidateID, requestID string) (float64, error)
+}
+
+type PGMarketplaceRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewMarketplaceRepository(pool *pgxpool.Pool) *PGMarketplaceRepository {
+	return &PGMarketplaceRepository{pool: pool}
+}
+
+// listingVisible restricts referral_requests r to the open referrals of other
+// agents overlapping subscription s: a subscription to a region sees
+// referrals in it or in any region it contains or lies within.
+const listingVisible = `
+	r.status = 'open'
+	AND r.created_by_user_id <> s.user_id
+	AND regions_overlap(r.region, s.regions)
+	AND (cardinality(s.languages) = 0 OR cardinality(r.languages) = 0
+		OR EXISTS (SELECT 1 FROM unnest(r.languages) AS lang WHERE lower(lang) = ANY (s.languages)))`
+
+func (r *PGMarketplaceRepository) Subscribe(ctx context.Context, sub MarketplaceSubscription) (MarketplaceSubscription, error) {
+	const query = `
+		INSERT INTO marketplace_subscriptions (user_id, regions, languages)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET regions = EXCLUDED.regions, languages = EXCLUDED.languages, updated_at = get_tx_timestamp()
+		RETURNING user_id, regions, languages, created_at, updated_at
+	`
+	var out MarketplaceSubscription
+	err := r.pool.QueryRow(ctx, query, sub.UserID, sub.Regions, sub.Languages).
+		Scan(&out.UserID, &out.Regions, &out.Languages, &out.CreatedAt, &out.UpdatedAt)
+	if err != nil {
+		return MarketplaceSubscription{}, fmt.Errorf("referral: save marketplace subscription: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PGMarketplaceRepository) Unsubscribe(ctx context.Context, userID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM marketplace_subscriptions WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("referral: delete marketplace subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMarketplaceNotSubscribed
+	}
+	return nil
+}
+
+// ListListings hides referrals the agent already has a live match on;
+// expired invitations do not count, so those referrals reappear.
+func (r *PGMarketplaceRepository) ListListings(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error) {
+	var subscribed bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM marketplace_subscriptions WHERE user_id = $1)`, userID).Scan(&subscribed); err != nil {
+		return nil, 0, fmt.Errorf("referral: check marketplace subscription: %w", err)
+	}
+	if !subscribed {
+		return nil, 0, ErrMarketplaceNotSubscribed
+	}
+
+	query := `
+		SELECT r.id, r.region, r.price_min, r.price_max, r.currency, r.property_type, r.deal_type, r.languages, r.sla_hours, r.created_at,
+			count(*) OVER ()
+		FROM referral_requests r
+		JOIN marketplace_subscriptions s ON s.user_id = $1
+		WHERE ` + listingVisible + `
+		  AND NOT EXISTS (
+			SELECT 1 FROM referral_matches m
+			WHERE m.request_id = r.id AND m.candidate_user_id = s.user_id AND m.state <> 'expired'
+		  )
+		ORDER BY r.created_at DESC, r.id
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.pool.Query(ctx, query, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("referral: list marketplace: %w", err)
+	}
+	defer rows.Close()
+
+	listings := make([]Listing, 0, pageSize)
+	total := 0
+	for rows.Next() {
+		var l Listing
+		if err := rows.Scan(&l.ID, &l.Region, &l.PriceMin, &l.PriceMax, &l.Currency, &l.PropertyType, &l.DealType, &l.Languages, &l.SLAHours, &l.CreatedAt, &total); err != nil {
+			return nil, 0, fmt.Errorf("referral: scan listing: %w", err)
+		}
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("referral: iterate listings: %w", err)
+	}
+	return listings, total, nil
+}
+
+// Apply inserts the applied match and its match.applied outbox message in one
+// transaction. An agent whose earlier invitation expired may apply again on
+// the same match row.
+func (r *PGMarketplaceRepository) Apply(ctx context.Context, userID, requestID string, score float64) (Match, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: begin apply: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var subscribed bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM marketplace_subscriptions WHERE user_id = $1)`, userID).Scan(&subscribed); err != nil {
+		return Match{}, fmt.Errorf("referral: check marketplace subscription: %w", err)
+	}
+	if !subscribed {
+		return Match{}, ErrMarketplaceNotSubscribed
+	}
+
+	query := `
+		INSERT INTO referral_matches (request_id, candidate_user_id, state, score)
+		SELECT r.id, s.user_id, 'applied'::referral_match_state, $3
+		FROM referral_requests r
+		JOIN marketplace_subscriptions s ON s.user_id = $2
+		WHERE r.id = $1 AND ` + listingVisible + `
+		ON CONFLICT (request_id, candidate_user_id) DO UPDATE
+		SET state = EXCLUDED.state, score = EXCLUDED.score
+		WHERE referral_matches.state = 'expired'
+		RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
+	`
+	match, err := scanMatch(tx.QueryRow(ctx, query, requestID, userID, score))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Match{}, fmt.Errorf("referral: apply: %w", err)
+		}
+		// Nothing inserted: either the listing is not visible to the agent
+		// or they already have a live match on it.
+		var matched bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM referral_matches WHERE request_id = $1 AND candidate_user_id = $2 AND state <> 'expired')`,
+			requestID, userID).Scan(&matched); err != nil {
+			return Match{}, fmt.Errorf("referral: check existing match: %w", err)
+		}
+		if matched {
+			return Match{}, ErrMatchDuplicate
+		}
+		return Match{}, ErrListingNotFound
+	}
+	if err := enqueueMatchEvent(ctx, tx, match.ID, match.State); err != nil {
+		return Match{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Match{}, fmt.Errorf("referral: commit apply: %w", err)
+	}
+	return match, nil
+}
+
+type MarketplaceService struct {
+	repo    MarketplaceRepository
+	scorer  CandidateScorer
+	regions RegionResolver
+	search  ListingSearcher
+}
+
+func NewMarketplaceService(repo MarketplaceRepository) *MarketplaceService {
+	return &MarketplaceService{repo: repo}
+}
+
+// WithScorer scores applications; without one they are stored with score 0.
+func (s *MarketplaceService) WithScorer(scorer CandidateScorer) *MarketplaceService {
+	s.scorer = scorer
+	return s
+}
+
+// WithRegions stores subscriptions as canonical region codes and rejects
+// unknown regions.
+func (s *MarketplaceService) WithRegions(regions RegionResolver) *MarketplaceService {
+	s.regions = regions
+	return s
+}
+
+// WithSearch lists through search first. When it fails for any reason but
+// ErrMarketplaceNotSubscribed, List logs the failure and falls back to the
+// repository, so an unavailable index only costs speed.
+func (s *MarketplaceService) WithSearch(search ListingSearcher) *MarketplaceService {
+	s.search = search
+	return s
+}
+
+// Subscribe opts userID in to the marketplace, replacing any earlier
+// regions and languages.
+func (s *MarketplaceService) Subscribe(ctx context.Context, userID string, regions, languages []string) (MarketplaceSubscription, error) {
+	sub := MarketplaceSubscription{
+		UserID:    userID,
+		Regions:   normalizeTerms(regions),
+		Languages: normalizeTerms(languages),
+	}
+	if len(sub.Regions) == 0 {
+		return MarketplaceSubscription{}, ErrMarketplaceRegions
+	}
+	if s.regions != nil {
+		codes, err := s.regions.Resolve(ctx, sub.Regions)
+		if err != nil {
+			return MarketplaceSubscription{}, err
+		}
+		sub.Regions = codes
+	}
+	return s.repo.Subscribe(ctx, sub)
+}
+
+func (s *MarketplaceService) Unsubscribe(ctx context.Context, userID string) error {
+	return s.repo.Unsubscribe(ctx, userID)
+}
+
+func (s *MarketplaceService) List(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	if s.search != nil {
+		listings, total, err := s.search.SearchListings(ctx, userID, page, pageSize)
+		if err == nil || errors.Is(err, ErrMarketplaceNotSubscribed) {
+			return listings, total, err
+		}
+		log.Printf("marketplace search: %v; listing from the database", err)
+	}
+	return s.repo.ListListings(ctx, userID, page, pageSize)
+}
+
+func (s *MarketplaceService) Apply(ctx context.Context, userID, requestID string) (Match, error) {
+	var score float64
+	if s.scorer != nil {
+		var err error
+		if score, err = s.scorer.ScoreCandidate(ctx, userID, requestID); err != nil {
+			return Match{}, fmt.Errorf("referral: score application: %w", err)
+		}
+	}
+	return s.repo.Apply(ctx, userID, requestID, score)
+}
+
+// normalizeTerms trims, lower-cases and de-duplicates terms, dropping blanks.
+func normalizeTerms(terms []string) []string {
+	out := make([]string, 0, len(terms))
+	seen := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fakeMarketplaceRepository struct {
+	saved          MarketplaceSubscription
+	page, pageSize int
+	score          float64
+}
+
+func (f *fakeMarketplaceRepository) Subscribe(_ context.Context, sub MarketplaceSubscription) (MarketplaceSubscription, error) {
+	f.saved = sub
+	return sub, nil
+}
+
+func (f *fakeMarketplaceRepository) Unsubscribe(context.Context, string) error { return nil }
+
+func (f *fakeMarketplaceRepository) ListListings(_ context.Context, _ string, page, pageSize int) ([]Listing, int, error) {
+	f.page, f.pageSize = page, pageSize
+	return nil, 0, nil
+}
+
+func (f *fakeMarketplaceRepository) Apply(_ context.Context, _, _ string, score float64) (Match, error) {
+	f.score = score
+	return Match{State: MatchStateApplied, Score: score}, nil
+}
+
+type fixedScorer float64
+
+func (s fixedScorer) ScoreCandidate(context.Context, string, string) (float64, error) {
+	return float64(s), nil
+}
+
+func TestMarketplaceService_SubscribeNormalizesTerms(t *testing.T) {
+	repo := &fakeMarketplaceRepository{}
+	svc := NewMarketplaceService(repo)
+
+	_, err := svc.Subscribe(context.Background(), "agent-1", []string{" Austin ", "austin", "", "Dallas"}, []string{"English", " SPANISH"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if !reflect.DeepEqual(repo.saved.Regions, []string{"austin", "dallas"}) {
+		t.Fatalf("unexpected regions %v", repo.saved.Regions)
+	}
+	if !reflect.DeepEqual(repo.saved.Languages, []string{"english", "spanish"}) {
+		t.Fatalf("unexpected languages %v", repo.saved.Languages)
+	}
+
+	if _, err := svc.Subscribe(context.Background(), "agent-1", []string{" "}, nil); !errors.Is(err, ErrMarketplaceRegions) {
+		t.Fatalf("expected ErrMarketplaceRegions, got %v", err)
+	}
+}
+
+func TestMarketplaceService_ListDefaultsPaging(t *testing.T) {
+	repo := &fakeMarketplaceRepository{}
+	svc := NewMarketplaceService(repo)
+
+	if _, _, err := svc.List(context.Background(), "agent-1", 0, 500); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if repo.page != 1 || repo.pageSize != 20 {
+		t.Fatalf("expected page 1 size 20, got %d/%d", repo.page, repo.pageSize)
+	}
+}
+
+func TestMarketplaceService_ApplyScoresCandidate(t *testing.T) {
+	repo := &fakeMarketplaceRepository{}
+	svc := NewMarketplaceService(repo).WithScorer(fixedScorer(0.8))
+
+	m, err := svc.Apply(context.Background(), "agent-1", "r1")
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if repo.score != 0.8 || m.Score != 0.8 {
+		t.Fatalf("expected score 0.8, got %v", repo.score)
+	}
+}
+
+func TestMatchService_ApplicationAwaitsInvitation(t *testing.T) {
+	repo := &stubMatchRepository{match: Match{ID: "m1", CandidateAgentID: "agent-2", State: MatchStateApplied}}
+	svc := NewMatchService(repo)
+
+	_, err := svc.UpdateState(context.Background(), UpdateMatchParams{MatchID: "m1", CandidateID: "agent-2", NewState: MatchStateAccepted})
+	if !errors.Is(err, ErrMatchInvalidTransition) {
+		t.Fatalf("expected ErrMatchInvalidTransition, got %v", err)
+	}
+	if repo.updated {
+		t.Fatal("applied match must not be updated by the candidate")
+	}
+}
+
+type searchFunc func(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error)
+
+func (f searchFunc) SearchListings(ctx context.Context, userID string, page, pageSize int) ([]Listing, int, error) {
+	return f(ctx, userID, page, pageSize)
+}
+
+func TestMarketplaceService_ListFallsBackWhenSearchFails(t *testing.T) {
+	repo := &fakeMarketplaceRepository{}
+	hit := []Listing{{ID: "r1"}}
+	var searchErr error
+	svc := NewMarketplaceService(repo).WithSearch(searchFunc(func(context.Context, string, int, int) ([]Listing, int, error) {
+		if searchErr != nil {
+			return nil, 0, searchErr
+		}
+		return hit, 1, nil
+	}))
+
+	got, total, err := svc.List(context.Background(), "agent-1", 1, 20)
+	if err != nil || total != 1 || len(got) != 1 || repo.page != 0 {
+		t.Fatalf("expected the search result without touching the repository, got %v %d %v (repo page %d)", got, total, err, repo.page)
+	}
+
+	searchErr = errors.New("search: index unavailable")
+	if _, _, err := svc.List(context.Background(), "agent-1", 2, 20); err != nil || repo.page != 2 {
+		t.Fatalf("expected the repository to answer, got %v (repo page %d)", err, repo.page)
+	}
+
+	repo.page = 0
+	searchErr = ErrMarketplaceNotSubscribed
+	if _, _, err := svc.List(context.Background(), "agent-1", 1, 20); !errors.Is(err, ErrMarketplaceNotSubscribed) || repo.page != 0 {
+		t.Fatalf("expected ErrMarketplaceNotSubscribed from search alone, got %v (repo page %d)", err, repo.page)
+	}
+}
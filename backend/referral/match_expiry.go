@@ -0,0 +1,102 @@
+package referral
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"brokerflow/clock"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultMatchExpiryBatchSize = 100
+
+// TxBeginner abstracts pgxpool.Pool for testability.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// MatchExpiryService moves invitations past their expires_at to expired.
+// Expired invitations no longer appear in the candidate's inbox and free the
+// candidate to be invited to the request again.
+type MatchExpiryService struct {
+	pool      TxBeginner
+	clock     clock.Clock
+	batchSize int
+}
+
+func NewMatchExpiryService(pool TxBeginner) *MatchExpiryService {
+	return &MatchExpiryService{pool: pool, clock: clock.New(), batchSize: defaultMatchExpiryBatchSize}
+}
+
+func (s *MatchExpiryService) WithClock(c clock.Clock) *MatchExpiryService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+// Run expires stale invitations every interval until ctx is cancelled.
+func (s *MatchExpiryService) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			expired, err := s.ExpireDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("match expiry: %v", err)
+				}
+				break
+			}
+			if len(expired) < s.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+// ExpireDue expires up to one batch of stale invitations in a single
+// transaction and writes a match.expired outbox message for each. Rows
+// locked by a concurrent accept or decline are skipped and retried later.
+func (s *MatchExpiryService) ExpireDue(ctx context.Context) ([]Match, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("referral: begin match expiry: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        UPDATE referral_matches
+        SET state = 'expired'::referral_match_state
+        WHERE id IN (
+            SELECT id FROM referral_matches
+            WHERE state = 'invited' AND expires_at <= get_tx_timestamp()
+            ORDER BY expires_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
+    `, s.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("referral: expire invitations: %w", err)
+	}
+	expired, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Match, error) {
+		return scanMatch(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("referral: scan expired invitations: %w", err)
+	}
+	for _, m := range expired {
+		if err := enqueueMatchEvent(ctx, tx, m.ID, m.State); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("referral: commit match expiry: %w", err)
+	}
+	return expired, nil
+}
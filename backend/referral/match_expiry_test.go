@@ -0,0 +1,131 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/tenancy"
+	"github.com/jackc/pgx/v5"
+)
+
+type failingPool struct {
+	begins int
+}
+
+func (f *failingPool) Begin(context.Context) (pgx.Tx, error) {
+	f.begins++
+	return nil, errors.New("database unavailable")
+}
+
+func TestMatchExpiryRun_KeepsRunningAfterErrorsUntilCancelled(t *testing.T) {
+	pool := &failingPool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewMatchExpiryService(pool).Run(ctx, time.Millisecond) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	if pool.begins < 2 {
+		t.Fatalf("expected repeated attempts after failures, got %d", pool.begins)
+	}
+}
+
+func TestMatchExpireDue_PropagatesBeginError(t *testing.T) {
+	if _, err := NewMatchExpiryService(&failingPool{}).ExpireDue(context.Background()); err == nil {
+		t.Fatal("expected begin error")
+	}
+}
+
+// stubMatchRepository serves a single match for MatchService.UpdateState
+// tests.
+type stubMatchRepository struct {
+	match   Match
+	updated bool
+	decline Decline
+	counter CounterTerms
+	ownerID string
+}
+
+func (s *stubMatchRepository) List(context.Context, string, tenancy.Scope) ([]Match, error) {
+	return nil, nil
+}
+
+func (s *stubMatchRepository) Create(context.Context, CreateMatchParams) (Match, error) {
+	return Match{}, nil
+}
+
+func (s *stubMatchRepository) CreateBulk(context.Context, BulkCreateMatchParams) ([]BulkMatchResult, error) {
+	return nil, nil
+}
+
+func (s *stubMatchRepository) ListForCandidate(context.Context, string) ([]Match, error) {
+	return nil, nil
+}
+
+func (s *stubMatchRepository) GetByID(context.Context, string) (Match, error) {
+	return s.match, nil
+}
+
+func (s *stubMatchRepository) UpdateState(_ context.Context, _ string, state MatchState, decline Decline) (Match, error) {
+	s.updated = true
+	s.decline = decline
+	m := s.match
+	m.State = state
+	return m, nil
+}
+
+func (s *stubMatchRepository) Counter(_ context.Context, _ string, terms CounterTerms) (Match, error) {
+	s.updated = true
+	s.counter = terms
+	m := s.match
+	m.State = MatchStateCountered
+	m.CounterFeeRate, m.CounterProtectDays = &terms.FeeRate, &terms.ProtectDays
+	return m, nil
+}
+
+func (s *stubMatchRepository) ResolveCounter(_ context.Context, _, ownerID string, outcome MatchState) (Match, error) {
+	s.updated = true
+	s.ownerID = ownerID
+	m := s.match
+	m.State = outcome
+	return m, nil
+}
+
+func TestMatchService_UpdateStateRejectsLapsedInvitations(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	cases := []struct {
+		name    string
+		match   Match
+		wantErr error
+	}{
+		{"expired", Match{State: MatchStateExpired, ExpiresAt: &past}, ErrMatchExpired},
+		{"invited past ttl", Match{State: MatchStateInvited, ExpiresAt: &past}, ErrMatchExpired},
+		{"invited within ttl", Match{State: MatchStateInvited, ExpiresAt: &future}, nil},
+		{"invited without ttl", Match{State: MatchStateInvited}, nil},
+	}
+	for _, tc := range cases {
+		tc.match.ID, tc.match.CandidateAgentID = "m1", "agent-2"
+		repo := &stubMatchRepository{match: tc.match}
+		svc := NewMatchService(repo).WithClock(clock.NewFake(now))
+
+		_, err := svc.UpdateState(context.Background(), UpdateMatchParams{MatchID: "m1", CandidateID: "agent-2", NewState: MatchStateDeclined})
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.wantErr, err)
+		}
+		if repo.updated != (tc.wantErr == nil) {
+			t.Errorf("%s: update called = %v", tc.name, repo.updated)
+		}
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"brokerflow/agreement"
+	"brokerflow/clock"
+	"brokerflow/db"
+	"brokerflow/tenancy"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -20,6 +23,14 @@ const (
 	MatchStateInvited  MatchState = "invited"
 	MatchStateAccepted MatchState = "accepted"
 	MatchStateDeclined MatchState = "declined"
+	// MatchStateExpired marks an invitation that passed its TTL unanswered.
+	MatchStateExpired MatchState = "expired"
+	// MatchStateApplied marks a candidate's application from the
+	// marketplace; the owner accepts it by inviting the candidate.
+	MatchStateApplied MatchState = "applied"
+	// MatchStateCountered marks an invitation the candidate answered with
+	// other terms; the owner accepts or rejects the counter-offer.
+	MatchStateCountered MatchState = "countered"
 )
 
 // Match represents a candidate agent associated with a referral request.
@@ -30,6 +41,36 @@ type Match struct {
 	State            MatchState
 	Score            float64
 	CreatedAt        time.Time
+	// ExpiresAt is when an invitation lapses; set while the match is or was
+	// invited.
+	ExpiresAt *time.Time
+	// DeclineReason and DeclineNote are set when the candidate gave a reason
+	// for declining.
+	DeclineReason *DeclineReason
+	DeclineNote   *string
+	// CounterFeeRate and CounterProtectDays are the terms the candidate
+	// countered with; kept once the owner accepts them.
+	CounterFeeRate     *float64
+	CounterProtectDays *int
+}
+
+func scanMatch(row pgx.Row) (Match, error) {
+	var m Match
+	err := row.Scan(&m.ID, &m.RequestID, &m.CandidateAgentID, &m.State, &m.Score, &m.CreatedAt,
+		&m.ExpiresAt, &m.DeclineReason, &m.DeclineNote, &m.CounterFeeRate, &m.CounterProtectDays)
+	return m, err
+}
+
+// lapsed reports whether an invitation is past its TTL at now, whether or
+// not the expiry job has marked it yet.
+func (m Match) lapsed(now time.Time) bool {
+	switch m.State {
+	case MatchStateExpired:
+		return true
+	case MatchStateInvited:
+		return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+	}
+	return false
 }
 
 // CreateMatchParams enumerates the required fields to insert a new match.
@@ -42,11 +83,19 @@ type CreateMatchParams struct {
 }
 
 type MatchRepository interface {
-	List(ctx context.Context, requestID, ownerID string) ([]Match, error)
+	List(ctx context.Context, requestID string, scope tenancy.Scope) ([]Match, error)
 	Create(ctx context.Context, params CreateMatchParams) (Match, error)
+	CreateBulk(ctx context.Context, params BulkCreateMatchParams) ([]BulkMatchResult, error)
 	ListForCandidate(ctx context.Context, candidateID string) ([]Match, error)
 	GetByID(ctx context.Context, matchID string) (Match, error)
-	UpdateState(ctx context.Context, matchID string, state MatchState) (Match, error)
+	// UpdateState moves a match to state; decline is stored with declined
+	// matches and cleared otherwise.
+	UpdateState(ctx context.Context, matchID string, state MatchState, decline Decline) (Match, error)
+	// Counter moves a live invitation to countered with terms.
+	Counter(ctx context.Context, matchID string, terms CounterTerms) (Match, error)
+	// ResolveCounter answers ownerID's countered match with outcome,
+	// accepted or invited.
+	ResolveCounter(ctx context.Context, matchID, ownerID string, outcome MatchState) (Match, error)
 }
 
 var (
@@ -56,19 +105,30 @@ var (
 	ErrMatchInvalidScore  = errors.New("referral: invalid match score")
 	ErrReferralNotOwned   = errors.New("referral: request not owned by user")
 	ErrCandidateMandatory = errors.New("referral: candidate user id required")
+	ErrCandidateNotFound  = errors.New("referral: candidate user not found")
+	ErrMatchExpired       = errors.New("referral: invitation has expired")
 )
 
 type PGMatchRepository struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	reader db.Reader
 }
 
 func NewMatchRepository(pool *pgxpool.Pool) *PGMatchRepository {
-	return &PGMatchRepository{pool: pool}
+	return &PGMatchRepository{pool: pool, reader: pool}
 }
 
-func (r *PGMatchRepository) List(ctx context.Context, requestID, ownerID string) ([]Match, error) {
+// WithReader lists matches by referral and by candidate from reader.
+func (r *PGMatchRepository) WithReader(reader db.Reader) *PGMatchRepository {
+	r.reader = reader
+	return r
+}
+
+// List returns the matches of a request visible within scope.
+func (r *PGMatchRepository) List(ctx context.Context, requestID string, scope tenancy.Scope) ([]Match, error) {
+	owned, arg := scope.OwnedBy("created_by_user_id", 2)
 	var exists bool
-	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM referral_requests WHERE id=$1 AND created_by_user_id=$2)`, requestID, ownerID).Scan(&exists); err != nil {
+	if err := r.reader.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM referral_requests WHERE id=$1 AND `+owned+`)`, requestID, arg).Scan(&exists); err != nil {
 		return nil, fmt.Errorf("referral: verify owner: %w", err)
 	}
 	if !exists {
@@ -76,13 +136,13 @@ func (r *PGMatchRepository) List(ctx context.Context, requestID, ownerID string)
 	}
 
 	const query = `
-		SELECT m.id, m.request_id, m.candidate_user_id, m.state::text, m.score, m.created_at
+		SELECT m.id, m.request_id, m.candidate_user_id, m.state::text, m.score, m.created_at, m.expires_at, m.decline_reason, m.decline_note, m.counter_fee_rate, m.counter_protect_days
 		FROM referral_matches m
 		WHERE m.request_id = $1
 		ORDER BY m.created_at DESC
 	`
 
-	rows, err := r.pool.Query(ctx, query, requestID)
+	rows, err := r.reader.Query(ctx, query, requestID)
 	if err != nil {
 		return nil, fmt.Errorf("referral: list matches: %w", err)
 	}
@@ -90,8 +150,8 @@ func (r *PGMatchRepository) List(ctx context.Context, requestID, ownerID string)
 
 	matches := make([]Match, 0, 8)
 	for rows.Next() {
-		var m Match
-		if err := rows.Scan(&m.ID, &m.RequestID, &m.CandidateAgentID, &m.State, &m.Score, &m.CreatedAt); err != nil {
+		m, err := scanMatch(rows)
+		if err != nil {
 			return nil, fmt.Errorf("referral: scan match: %w", err)
 		}
 		matches = append(matches, m)
@@ -102,39 +162,88 @@ func (r *PGMatchRepository) List(ctx context.Context, requestID, ownerID string)
 	return matches, nil
 }
 
-func (r *PGMatchRepository) Create(ctx context.Context, params CreateMatchParams) (Match, error) {
-	if params.CandidateAgentID == "" {
-		return Match{}, ErrCandidateMandatory
+// ListForRequests returns the matches of every request among requestIDs
+// that scope can see, newest first. Requests outside the scope contribute
+// no matches rather than failing the call.
+func (r *PGMatchRepository) ListForRequests(ctx context.Context, requestIDs []string, scope tenancy.Scope) ([]Match, error) {
+	if len(requestIDs) == 0 {
+		return nil, nil
 	}
-	if params.State == "" {
-		params.State = MatchStateInvited
+	owned, arg := scope.OwnedBy("rr.created_by_user_id", 2)
+	query := `
+		SELECT m.id, m.request_id, m.candidate_user_id, m.state::text, m.score, m.created_at, m.expires_at, m.decline_reason, m.decline_note, m.counter_fee_rate, m.counter_protect_days
+		FROM referral_matches m
+		JOIN referral_requests rr ON rr.id = m.request_id
+		WHERE m.request_id = ANY($1::uuid[]) AND ` + owned + `
+		ORDER BY m.created_at DESC
+	`
+
+	rows, err := r.reader.Query(ctx, query, requestIDs, arg)
+	if err != nil {
+		return nil, fmt.Errorf("referral: list matches for requests: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		m, err := scanMatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("referral: scan match: %w", err)
+		}
+		matches = append(matches, m)
 	}
-	if params.Score < 0 || params.Score > 1 {
-		return Match{}, ErrMatchInvalidScore
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("referral: iterate matches: %w", err)
 	}
-	if params.State != MatchStateInvited && params.State != MatchStateAccepted && params.State != MatchStateDeclined {
-		return Match{}, ErrMatchInvalidState
+	return matches, nil
+}
+
+// Create invites a candidate. A candidate whose earlier invitation expired,
+// or who applied from the marketplace, is invited on the same match row.
+func (r *PGMatchRepository) Create(ctx context.Context, params CreateMatchParams) (Match, error) {
+	c, err := validateMatch(BulkMatchCandidate{CandidateAgentID: params.CandidateAgentID, Score: params.Score, State: params.State})
+	if err != nil {
+		return Match{}, err
 	}
+	params.State = c.State
 
 	const query = `
 		INSERT INTO referral_matches (request_id, candidate_user_id, state, score)
 		SELECT $1, $2, $3::referral_match_state, $4
 		FROM referral_requests r
 		WHERE r.id = $1 AND r.created_by_user_id = $5
-		RETURNING id, request_id, candidate_user_id, state::text, score, created_at
+		ON CONFLICT (request_id, candidate_user_id) DO UPDATE
+		SET state = EXCLUDED.state, score = EXCLUDED.score
+		WHERE referral_matches.state IN ('expired', 'applied')
+		RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
 	`
 
-	var match Match
-	err := r.pool.QueryRow(ctx, query,
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: begin create match: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	match, err := scanMatch(tx.QueryRow(ctx, query,
 		params.RequestID,
 		params.CandidateAgentID,
 		params.State,
 		params.Score,
 		params.OwnerUserID,
-	).Scan(&match.ID, &match.RequestID, &match.CandidateAgentID, &match.State, &match.Score, &match.CreatedAt)
+	))
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			// Nothing inserted: either the request is not the caller's or
+			// the candidate already has a live match.
+			var owned bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM referral_requests WHERE id = $1 AND created_by_user_id = $2)`,
+				params.RequestID, params.OwnerUserID).Scan(&owned); err != nil {
+				return Match{}, fmt.Errorf("referral: verify owner: %w", err)
+			}
+			if owned {
+				return Match{}, ErrMatchDuplicate
+			}
 			return Match{}, ErrReferralNotOwned
 		}
 		var pgErr *pgconn.PgError
@@ -143,18 +252,26 @@ func (r *PGMatchRepository) Create(ctx context.Context, params CreateMatchParams
 		}
 		return Match{}, fmt.Errorf("referral: create match: %w", err)
 	}
+	if err := enqueueMatchEvent(ctx, tx, match.ID, match.State); err != nil {
+		return Match{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Match{}, fmt.Errorf("referral: commit create match: %w", err)
+	}
 	return match, nil
 }
 
 func (r *PGMatchRepository) ListForCandidate(ctx context.Context, candidateID string) ([]Match, error) {
 	const query = `
-		SELECT m.id, m.request_id, m.candidate_user_id, m.state::text, m.score, m.created_at
+		SELECT m.id, m.request_id, m.candidate_user_id, m.state::text, m.score, m.created_at, m.expires_at, m.decline_reason, m.decline_note, m.counter_fee_rate, m.counter_protect_days
 		FROM referral_matches m
 		WHERE m.candidate_user_id = $1
+		  AND m.state <> 'expired'
+		  AND NOT (m.state = 'invited' AND m.expires_at <= get_tx_timestamp())
 		ORDER BY m.created_at DESC
 	`
 
-	rows, err := r.pool.Query(ctx, query, candidateID)
+	rows, err := r.reader.Query(ctx, query, candidateID)
 	if err != nil {
 		return nil, fmt.Errorf("referral: list matches for candidate: %w", err)
 	}
@@ -162,8 +279,8 @@ func (r *PGMatchRepository) ListForCandidate(ctx context.Context, candidateID st
 
 	out := make([]Match, 0, 8)
 	for rows.Next() {
-		var m Match
-		if err := rows.Scan(&m.ID, &m.RequestID, &m.CandidateAgentID, &m.State, &m.Score, &m.CreatedAt); err != nil {
+		m, err := scanMatch(rows)
+		if err != nil {
 			return nil, fmt.Errorf("referral: scan candidate match: %w", err)
 		}
 		out = append(out, m)
@@ -176,12 +293,12 @@ func (r *PGMatchRepository) ListForCandidate(ctx context.Context, candidateID st
 
 func (r *PGMatchRepository) GetByID(ctx context.Context, matchID string) (Match, error) {
 	const query = `
-		SELECT id, request_id, candidate_user_id, state::text, score, created_at
+		SELECT id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
 		FROM referral_matches
 		WHERE id = $1
 	`
-	var m Match
-	if err := r.pool.QueryRow(ctx, query, matchID).Scan(&m.ID, &m.RequestID, &m.CandidateAgentID, &m.State, &m.Score, &m.CreatedAt); err != nil {
+	m, err := scanMatch(r.pool.QueryRow(ctx, query, matchID))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Match{}, ErrMatchNotFound
 		}
@@ -190,27 +307,75 @@ func (r *PGMatchRepository) GetByID(ctx context.Context, matchID string) (Match,
 	return m, nil
 }
 
-func (r *PGMatchRepository) UpdateState(ctx context.Context, matchID string, state MatchState) (Match, error) {
+func (r *PGMatchRepository) UpdateState(ctx context.Context, matchID string, state MatchState, decline Decline) (Match, error) {
 	const query = `
 		UPDATE referral_matches
-		SET state = $2::referral_match_state
+		SET state = $2::referral_match_state,
+			decline_reason = NULLIF($3, ''),
+			decline_note = NULLIF($4, ''),
+			counter_fee_rate = CASE WHEN $2 = 'accepted' THEN counter_fee_rate END,
+			counter_protect_days = CASE WHEN $2 = 'accepted' THEN counter_protect_days END,
+			countered_at = CASE WHEN $2 = 'accepted' THEN countered_at END
 		WHERE id = $1
-		RETURNING id, request_id, candidate_user_id, state::text, score, created_at
+		RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
 	`
-	var m Match
-	if err := r.pool.QueryRow(ctx, query, matchID, state).Scan(&m.ID, &m.RequestID, &m.CandidateAgentID, &m.State, &m.Score, &m.CreatedAt); err != nil {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Match{}, fmt.Errorf("referral: begin update match: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	m, err := scanMatch(tx.QueryRow(ctx, query, matchID, state, string(decline.Reason), decline.Note))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Match{}, ErrMatchNotFound
 		}
 		return Match{}, fmt.Errorf("referral: update match state: %w", err)
 	}
+	if err := enqueueMatchEvent(ctx, tx, m.ID, m.State); err != nil {
+		return Match{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Match{}, fmt.Errorf("referral: commit update match: %w", err)
+	}
 	return m, nil
 }
 
+// enqueueMatchEvent writes the match.* outbox message for state inside tx,
+// reading the match as the transaction sees it.
+func enqueueMatchEvent(ctx context.Context, tx pgx.Tx, matchID string, state MatchState) error {
+	topic, ok := matchTopic(state)
+	if !ok {
+		return nil
+	}
+	const query = `
+		INSERT INTO outbox (topic, payload)
+		SELECT $2, jsonb_build_object(
+			'match_id', m.id,
+			'referral_id', m.request_id,
+			'candidate_id', m.candidate_user_id,
+			'owner_id', rr.created_by_user_id,
+			'state', m.state,
+			'score', m.score,
+			'decline_reason', m.decline_reason,
+			'counter_fee_rate', m.counter_fee_rate,
+			'counter_protect_days', m.counter_protect_days
+		)
+		FROM referral_matches m
+		JOIN referral_requests rr ON rr.id = m.request_id
+		WHERE m.id = $1
+	`
+	if _, err := tx.Exec(ctx, query, matchID, topic); err != nil {
+		return fmt.Errorf("referral: enqueue %s: %w", topic, err)
+	}
+	return nil
+}
+
 type MatchService struct {
 	repo     MatchRepository
 	agRepo   agreementRepository
-	now      func() time.Time
+	tx       db.TxRunner
+	clock    clock.Clock
 	idGen    func() string
 	timeline referralTimeline
 	outbox   referralOutbox
@@ -231,7 +396,7 @@ type referralOutbox interface {
 func NewMatchService(repo MatchRepository) *MatchService {
 	return &MatchService{
 		repo:  repo,
-		now:   time.Now,
+		clock: clock.New(),
 		idGen: func() string { return uuid.NewString() },
 	}
 }
@@ -241,14 +406,29 @@ func (s *MatchService) WithAgreementRepository(repo agreementRepository) *MatchS
 	return s
 }
 
+// WithTxRunner sets the transaction runner acceptance uses to mark the match
+// accepted and create its agreement together. Without one, or without an
+// agreement repository, accepting only updates the match. The runner should
+// be serializable (see db.UnitOfWork.WithIsolation); acceptance retries the
+// conflicts that produces.
+func (s *MatchService) WithTxRunner(tx db.TxRunner) *MatchService {
+	s.tx = tx
+	return s
+}
+
+func (s *MatchService) WithClock(c clock.Clock) *MatchService {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
 func (s *MatchService) WithTimelineAndOutbox(timeline referralTimeline, out referralOutbox) *MatchService {
 	s.timeline = timeline
 	s.outbox = out
 	return s
 }
 
-func (s *MatchService) List(ctx context.Context, requestID, ownerID string) ([]Match, error) {
-	return s.repo.List(ctx, requestID, ownerID)
+func (s *MatchService) List(ctx context.Context, requestID string, scope tenancy.Scope) ([]Match, error) {
+	return s.repo.List(ctx, requestID, scope)
 }
 
 func (s *MatchService) Create(ctx context.Context, params CreateMatchParams) (Match, error) {
@@ -263,7 +443,11 @@ type UpdateMatchParams struct {
 	MatchID     string
 	CandidateID string
 	NewState    MatchState
-	Pool        *pgxpool.Pool
+	// Decline optionally explains a decline; it is rejected for other states.
+	Decline Decline
+	// Counter carries the terms of a counter-offer and is required for, and
+	// only accepted with, MatchStateCountered.
+	Counter CounterTerms
 }
 
 type MatchUpdateResult struct {
@@ -284,18 +468,40 @@ func (s *MatchService) UpdateState(ctx context.Context, params UpdateMatchParams
 	if match.CandidateAgentID != params.CandidateID {
 		return MatchUpdateResult{}, ErrMatchForbidden
 	}
-	if params.NewState != MatchStateAccepted && params.NewState != MatchStateDeclined {
+	if match.State == MatchStateApplied || match.State == MatchStateCountered {
+		// Applications wait for the owner's invitation, counter-offers for
+		// the owner's answer.
+		return MatchUpdateResult{}, ErrMatchInvalidTransition
+	}
+	if params.NewState != MatchStateAccepted && params.NewState != MatchStateDeclined && params.NewState != MatchStateCountered {
 		return MatchUpdateResult{}, ErrMatchInvalidTransition
 	}
+	decline, err := validateDecline(params.NewState, params.Decline)
+	if err != nil {
+		return MatchUpdateResult{}, err
+	}
+	if err := validateCounter(params.NewState, params.Counter); err != nil {
+		return MatchUpdateResult{}, err
+	}
+	if match.lapsed(s.clock.Now()) {
+		return MatchUpdateResult{}, ErrMatchExpired
+	}
 	if match.State == params.NewState {
 		return MatchUpdateResult{Match: match}, nil
 	}
 
-	if params.NewState == MatchStateAccepted && s.agRepo != nil && params.Pool != nil {
-		return s.acceptMatchAndCreateAgreement(ctx, params, match)
+	if params.NewState == MatchStateAccepted && s.agRepo != nil && s.tx != nil {
+		return s.acceptMatchAndCreateAgreement(ctx, match)
+	}
+	if params.NewState == MatchStateCountered {
+		countered, err := s.repo.Counter(ctx, match.ID, params.Counter)
+		if err != nil {
+			return MatchUpdateResult{}, err
+		}
+		return MatchUpdateResult{Match: countered}, nil
 	}
 
-	updated, err := s.repo.UpdateState(ctx, params.MatchID, params.NewState)
+	updated, err := s.repo.UpdateState(ctx, params.MatchID, params.NewState, decline)
 	if err != nil {
 		return MatchUpdateResult{}, err
 	}
@@ -303,96 +509,84 @@ func (s *MatchService) UpdateState(ctx context.Context, params UpdateMatchParams
 	return MatchUpdateResult{Match: updated}, nil
 }
 
-func (s *MatchService) acceptMatchAndCreateAgreement(ctx context.Context, params UpdateMatchParams, match Match) (MatchUpdateResult, error) {
-	// Allow idempotent acceptance.
-	if match.State == MatchStateAccepted && s.agRepo != nil {
-		tx, err := params.Pool.Begin(ctx)
-		if err != nil {
-			return MatchUpdateResult{}, fmt.Errorf("match: begin agreement tx: %w", err)
-		}
-		defer tx.Rollback(ctx)
-
-		rec, err := s.agRepo.CreateFromMatch(ctx, tx, agreement.MatchAcceptanceParams{
-			MatchID:          match.ID,
-			RequestID:        match.RequestID,
-			CandidateUserID:  match.CandidateAgentID,
-			AcceptedByUserID: match.CandidateAgentID,
-			AcceptedAt:       s.now(),
+func (s *MatchService) acceptMatchAndCreateAgreement(ctx context.Context, match Match) (MatchUpdateResult, error) {
+	// Two matches of one referral accepted at once would each see the
+	// referral still open. With a serializable runner the later commit fails
+	// instead, and the retry sees the agreement the first one created.
+	var rec agreement.Record
+	err := db.Retry(ctx, db.DefaultRetryPolicy, func(ctx context.Context) error {
+		return s.tx.InTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+			// Allow idempotent acceptance.
+			if match.State != MatchStateAccepted {
+				if err := acceptLocked(ctx, tx, match.ID); err != nil {
+					return err
+				}
+			}
+
+			var err error
+			rec, err = s.agRepo.CreateFromMatch(ctx, tx, agreement.MatchAcceptanceParams{
+				MatchID:          match.ID,
+				RequestID:        match.RequestID,
+				CandidateUserID:  match.CandidateAgentID,
+				AcceptedByUserID: match.CandidateAgentID,
+				AcceptedAt:       s.clock.Now(),
+			})
+			return err
 		})
-		if err != nil {
-			return MatchUpdateResult{}, err
-		}
-
-		if err := tx.Commit(ctx); err != nil {
-			return MatchUpdateResult{}, fmt.Errorf("match: commit agreement tx: %w", err)
-		}
-
-		refreshed, err := s.repo.GetByID(ctx, match.ID)
-		if err != nil {
-			return MatchUpdateResult{}, err
-		}
-		return MatchUpdateResult{
-			Match:     refreshed,
-			Agreement: &rec,
-		}, nil
+	})
+	if err != nil {
+		return MatchUpdateResult{}, err
 	}
 
-	tx, err := params.Pool.Begin(ctx)
+	accepted, err := s.repo.GetByID(ctx, match.ID)
 	if err != nil {
-		return MatchUpdateResult{}, fmt.Errorf("match: begin acceptance tx: %w", err)
+		return MatchUpdateResult{}, err
 	}
-	defer tx.Rollback(ctx)
+	return MatchUpdateResult{
+		Match:     accepted,
+		Agreement: &rec,
+	}, nil
+}
 
+// acceptLocked locks the match row and moves it to accepted, queueing the
+// match event, unless it was accepted in the meantime.
+func acceptLocked(ctx context.Context, tx pgx.Tx, matchID string) error {
 	const lockSQL = `
-SELECT state::text
+SELECT state::text, COALESCE(expires_at <= get_tx_timestamp(), false)
 FROM referral_matches
 WHERE id = $1
 FOR UPDATE
 `
-	var currentState string
-	if err := tx.QueryRow(ctx, lockSQL, match.ID).Scan(&currentState); err != nil {
+	var (
+		currentState string
+		lapsed       bool
+	)
+	if err := tx.QueryRow(ctx, lockSQL, matchID).Scan(&currentState, &lapsed); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return MatchUpdateResult{}, ErrMatchNotFound
+			return ErrMatchNotFound
 		}
-		return MatchUpdateResult{}, fmt.Errorf("match: lock for acceptance: %w", err)
+		return fmt.Errorf("match: lock for acceptance: %w", err)
 	}
 
 	switch MatchState(currentState) {
 	case MatchStateAccepted:
 		// Already accepted, continue.
+		return nil
+	case MatchStateExpired:
+		return ErrMatchExpired
 	case MatchStateInvited:
+		if lapsed {
+			return ErrMatchExpired
+		}
 		if _, err := tx.Exec(ctx, `
 UPDATE referral_matches
 SET state = 'accepted'::referral_match_state
 WHERE id = $1
-`, match.ID); err != nil {
-			return MatchUpdateResult{}, fmt.Errorf("match: mark accepted: %w", err)
+`, matchID); err != nil {
+			return fmt.Errorf("match: mark accepted: %w", err)
 		}
+		return enqueueMatchEvent(ctx, tx, matchID, MatchStateAccepted)
 	default:
-		return MatchUpdateResult{}, ErrMatchInvalidTransition
+		return ErrMatchInvalidTransition
 	}
-
-	rec, err := s.agRepo.CreateFromMatch(ctx, tx, agreement.MatchAcceptanceParams{
-		MatchID:          match.ID,
-		RequestID:        match.RequestID,
-		CandidateUserID:  match.CandidateAgentID,
-		AcceptedByUserID: match.CandidateAgentID,
-		AcceptedAt:       s.now(),
-	})
-	if err != nil {
-		return MatchUpdateResult{}, err
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return MatchUpdateResult{}, fmt.Errorf("match: commit acceptance: %w", err)
-	}
-
-	accepted, err := s.repo.GetByID(ctx, match.ID)
-	if err != nil {
-		return MatchUpdateResult{}, err
-	}
-	return MatchUpdateResult{
-		Match:     accepted,
-		Agreement: &rec,
-	}, nil
 }
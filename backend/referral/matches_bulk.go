@@ -0,0 +1,187 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxBulkMatches caps the candidates accepted by one bulk invitation.
+const MaxBulkMatches = 50
+
+var (
+	ErrBulkMatchesEmpty   = errors.New("referral: at least one candidate required")
+	ErrBulkMatchesTooMany = fmt.Errorf("referral: at most %d candidates per request", MaxBulkMatches)
+)
+
+// BulkMatchStatus is the outcome of one candidate in a bulk invitation.
+type BulkMatchStatus string
+
+const (
+	BulkMatchCreated   BulkMatchStatus = "created"
+	BulkMatchDuplicate BulkMatchStatus = "duplicate"
+	BulkMatchInvalid   BulkMatchStatus = "invalid"
+)
+
+// BulkMatchCandidate is one candidate of a bulk invitation.
+type BulkMatchCandidate struct {
+	CandidateAgentID string
+	Score            float64
+	State            MatchState
+}
+
+type BulkCreateMatchParams struct {
+	RequestID   string
+	OwnerUserID string
+	Candidates  []BulkMatchCandidate
+}
+
+// BulkMatchResult reports what happened to the candidate at the same index.
+// Match is set for created matches, Err for invalid candidates.
+type BulkMatchResult struct {
+	CandidateAgentID string
+	Status           BulkMatchStatus
+	Match            *Match
+	Err              error
+}
+
+// validateMatch applies the defaults and checks shared by single and bulk
+// match creation.
+func validateMatch(c BulkMatchCandidate) (BulkMatchCandidate, error) {
+	if c.CandidateAgentID == "" {
+		return c, ErrCandidateMandatory
+	}
+	if c.State == "" {
+		c.State = MatchStateInvited
+	}
+	if c.Score < 0 || c.Score > 1 {
+		return c, ErrMatchInvalidScore
+	}
+	if c.State != MatchStateInvited && c.State != MatchStateAccepted && c.State != MatchStateDeclined {
+		return c, ErrMatchInvalidState
+	}
+	return c, nil
+}
+
+// prepareBulkMatches validates every candidate up front, applying defaults
+// in place. It returns one result per candidate plus the indexes still to be
+// inserted; repeats of a candidate within the batch are reported as
+// duplicates of the first.
+func prepareBulkMatches(candidates []BulkMatchCandidate) ([]BulkMatchResult, []int, error) {
+	if len(candidates) == 0 {
+		return nil, nil, ErrBulkMatchesEmpty
+	}
+	if len(candidates) > MaxBulkMatches {
+		return nil, nil, ErrBulkMatchesTooMany
+	}
+	results := make([]BulkMatchResult, len(candidates))
+	pending := make([]int, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for i, c := range candidates {
+		results[i].CandidateAgentID = c.CandidateAgentID
+		valid, err := validateMatch(c)
+		if err == nil {
+			if _, perr := uuid.Parse(c.CandidateAgentID); perr != nil {
+				err = ErrCandidateNotFound
+			}
+		}
+		switch {
+		case err != nil:
+			results[i].Status, results[i].Err = BulkMatchInvalid, err
+		case seen[c.CandidateAgentID]:
+			results[i].Status = BulkMatchDuplicate
+		default:
+			seen[c.CandidateAgentID] = true
+			candidates[i] = valid
+			pending = append(pending, i)
+		}
+	}
+	return results, pending, nil
+}
+
+// CreateBulk invites several candidates in one transaction. Candidates that
+// already have a live match on the request are skipped as duplicates, so
+// retrying a batch is safe, while expired invitations are sent again; unknown or malformed candidates are reported as invalid
+// without failing the rest.
+func (r *PGMatchRepository) CreateBulk(ctx context.Context, params BulkCreateMatchParams) ([]BulkMatchResult, error) {
+	candidates := append([]BulkMatchCandidate(nil), params.Candidates...)
+	results, pending, err := prepareBulkMatches(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("referral: begin bulk create matches: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var one int
+	if err := tx.QueryRow(ctx, `
+		SELECT 1 FROM referral_requests
+		WHERE id = $1 AND created_by_user_id = $2
+		FOR UPDATE
+	`, params.RequestID, params.OwnerUserID).Scan(&one); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReferralNotOwned
+		}
+		return nil, fmt.Errorf("referral: verify owner: %w", err)
+	}
+
+	ids := make([]string, 0, len(pending))
+	for _, i := range pending {
+		ids = append(ids, candidates[i].CandidateAgentID)
+	}
+	rows, err := tx.Query(ctx, `SELECT id::text FROM users WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL AND deactivated_at IS NULL`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("referral: load candidates: %w", err)
+	}
+	found, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("referral: scan candidates: %w", err)
+	}
+	known := make(map[string]bool, len(found))
+	for _, id := range found {
+		known[id] = true
+	}
+
+	const insert = `
+		INSERT INTO referral_matches (request_id, candidate_user_id, state, score)
+		VALUES ($1, $2, $3::referral_match_state, $4)
+		ON CONFLICT (request_id, candidate_user_id) DO UPDATE
+		SET state = EXCLUDED.state, score = EXCLUDED.score
+		WHERE referral_matches.state IN ('expired', 'applied')
+		RETURNING id, request_id, candidate_user_id, state::text, score, created_at, expires_at, decline_reason, decline_note, counter_fee_rate, counter_protect_days
+	`
+	for _, i := range pending {
+		c := candidates[i]
+		if !known[c.CandidateAgentID] {
+			results[i].Status, results[i].Err = BulkMatchInvalid, ErrCandidateNotFound
+			continue
+		}
+		m, err := scanMatch(tx.QueryRow(ctx, insert, params.RequestID, c.CandidateAgentID, c.State, c.Score))
+		if errors.Is(err, pgx.ErrNoRows) {
+			results[i].Status = BulkMatchDuplicate
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("referral: bulk create match: %w", err)
+		}
+		if err := enqueueMatchEvent(ctx, tx, m.ID, m.State); err != nil {
+			return nil, err
+		}
+		results[i].Status, results[i].Match = BulkMatchCreated, &m
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("referral: commit bulk create matches: %w", err)
+	}
+	return results, nil
+}
+
+func (s *MatchService) CreateBulk(ctx context.Context, params BulkCreateMatchParams) ([]BulkMatchResult, error) {
+	return s.repo.CreateBulk(ctx, params)
+}
@@ -0,0 +1,54 @@
+package referral
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrepareBulkMatches(t *testing.T) {
+	const a, b = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	candidates := []BulkMatchCandidate{
+		{CandidateAgentID: a, Score: 0.5},
+		{CandidateAgentID: ""},
+		{CandidateAgentID: b, Score: 2},
+		{CandidateAgentID: a},
+		{CandidateAgentID: "not-a-uuid"},
+		{CandidateAgentID: b, State: MatchStateDeclined},
+	}
+	results, pending, err := prepareBulkMatches(candidates)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+
+	want := []struct {
+		status BulkMatchStatus
+		err    error
+	}{
+		{"", nil},
+		{BulkMatchInvalid, ErrCandidateMandatory},
+		{BulkMatchInvalid, ErrMatchInvalidScore},
+		{BulkMatchDuplicate, nil},
+		{BulkMatchInvalid, ErrCandidateNotFound},
+		{"", nil},
+	}
+	for i, w := range want {
+		if results[i].Status != w.status || !errors.Is(results[i].Err, w.err) {
+			t.Errorf("item %d: got (%q, %v), want (%q, %v)", i, results[i].Status, results[i].Err, w.status, w.err)
+		}
+	}
+	if len(pending) != 2 || pending[0] != 0 || pending[1] != 5 {
+		t.Fatalf("expected items 0 and 5 pending, got %v", pending)
+	}
+	if candidates[0].State != MatchStateInvited {
+		t.Fatalf("expected default state invited, got %q", candidates[0].State)
+	}
+}
+
+func TestPrepareBulkMatches_Limits(t *testing.T) {
+	if _, _, err := prepareBulkMatches(nil); !errors.Is(err, ErrBulkMatchesEmpty) {
+		t.Fatalf("expected ErrBulkMatchesEmpty, got %v", err)
+	}
+	if _, _, err := prepareBulkMatches(make([]BulkMatchCandidate, MaxBulkMatches+1)); !errors.Is(err, ErrBulkMatchesTooMany) {
+		t.Fatalf("expected ErrBulkMatchesTooMany, got %v", err)
+	}
+}
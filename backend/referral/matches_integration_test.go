@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"brokerflow/agreement"
+	"brokerflow/db"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -98,13 +100,13 @@ func TestMatchAcceptanceCreatesAgreement(t *testing.T) {
 	})
 
 	matchRepo := NewMatchRepository(pool)
-	service := NewMatchService(matchRepo).WithAgreementRepository(agreement.NewRepository())
+	service := NewMatchService(matchRepo).WithAgreementRepository(agreement.NewRepository()).
+		WithTxRunner(db.NewUnitOfWork(pool).WithIsolation(pgx.Serializable))
 
 	result, err := service.UpdateState(ctx, UpdateMatchParams{
 		MatchID:     matchID,
 		CandidateID: candidateUser,
 		NewState:    MatchStateAccepted,
-		Pool:        pool,
 	})
 	if err != nil {
 		t.Fatalf("accept match: %v", err)
@@ -152,7 +154,6 @@ func TestMatchAcceptanceCreatesAgreement(t *testing.T) {
 		MatchID:     matchID,
 		CandidateID: candidateUser,
 		NewState:    MatchStateAccepted,
-		Pool:        pool,
 	})
 	if err != nil {
 		t.Fatalf("idempotent accept: %v", err)
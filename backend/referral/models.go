@@ -1,6 +1,11 @@
 package referral
 
-import "time"
+import (
+	"time"
+
+	"brokerflow/money"
+	"brokerflow/tenancy"
+)
 
 type Status string
 
@@ -14,29 +19,50 @@ const (
 	StatusCancelled  Status = "cancelled"
 )
 
+// DefaultMatchTTLHours applies when a request does not set a match TTL;
+// MaxMatchTTLHours bounds it.
+const (
+	DefaultMatchTTLHours = 72
+	MaxMatchTTLHours     = 720
+)
+
 type Request struct {
 	ID            string
 	CreatorUserID string
 	Region        []string
 	PriceMin      int64
 	PriceMax      int64
-	PropertyType  string
-	DealType      string
-	Languages     []string
-	SLAHours      int
+	// Currency is what PriceMin and PriceMax are in.
+	Currency     money.Currency
+	PropertyType string
+	DealType     string
+	Languages    []string
+	SLAHours     int
+	// MatchTTLHours is how long an invitation on this request stays open.
+	MatchTTLHours int
 	Status        Status
 	CancelReason  *string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// Version increases whenever a client-visible field changes; the API
+	// exposes it as the referral's ETag.
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ArchivedAt is set once the referral is archived; archived referrals
+	// are left out of lists unless Filters.IncludeArchived is set.
+	ArchivedAt *time.Time
 }
 
 type Filters struct {
-	CreatorUserID string
-	Status        Status
-	Region        string
-	DealType      string
-	Page          int
-	PageSize      int
-	SortKey       string
-	SortOrder     string
+	// Scope limits results to the caller's own requests, or to every request
+	// created within their brokerage for broker admins.
+	Scope    tenancy.Scope
+	Status   Status
+	Region   string
+	DealType string
+	// IncludeArchived lists archived referrals too.
+	IncludeArchived bool
+	Page            int
+	PageSize        int
+	SortKey         string
+	SortOrder       string
 }
@@ -0,0 +1,24 @@
+package referral
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PGOutboxWriter implements OutboxWriter on the outbox table, inside the
+// caller's transaction.
+type PGOutboxWriter struct{}
+
+func (PGOutboxWriter) Enqueue(ctx context.Context, tx pgx.Tx, topic string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("referral: encode %s payload: %w", topic, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`, topic, body); err != nil {
+		return fmt.Errorf("referral: enqueue %s: %w", topic, err)
+	}
+	return nil
+}
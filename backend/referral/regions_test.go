@@ -0,0 +1,76 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"brokerflow/referral"
+	"brokerflow/region"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+)
+
+func newRegionResolver() *region.Service {
+	store := testsupport.NewRegions()
+	parent := func(code string) *string { return &code }
+	store.Add(region.Region{Code: "us-ny", Name: "New York", Kind: region.KindState, Aliases: []string{"ny", "new-york"}})
+	store.Add(region.Region{Code: "us-ny-brooklyn", Name: "Brooklyn", Kind: region.KindDistrict, ParentCode: parent("us-ny"), Aliases: []string{"brooklyn", "brooklyn-ny"}})
+	return region.NewService(store)
+}
+
+func TestCreate_StoresCanonicalRegionCodes(t *testing.T) {
+	ctx := context.Background()
+	repo := testsupport.NewReferrals(testsupport.NewUsers())
+	svc := referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil).WithRegions(newRegionResolver())
+
+	created, err := svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"brooklyn, NY", "Brooklyn"}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !slices.Equal(created.Region, []string{"us-ny-brooklyn"}) {
+		t.Fatalf("region = %v, want [us-ny-brooklyn]", created.Region)
+	}
+
+	_, err = svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Brooklyn", "Gotham"}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if !errors.Is(err, region.ErrUnknownRegion) {
+		t.Fatalf("expected ErrUnknownRegion, got %v", err)
+	}
+	_, err = svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{" - "}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if !errors.Is(err, referral.ErrRegionRequired) {
+		t.Fatalf("expected ErrRegionRequired for a blank region, got %v", err)
+	}
+
+	regions := []string{"NY"}
+	updated, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: tenancy.Scope{UserID: "agent-1"}, Region: &regions})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !slices.Equal(updated.Region, []string{"us-ny"}) {
+		t.Fatalf("updated region = %v, want [us-ny]", updated.Region)
+	}
+}
+
+func TestImport_UnknownRegionIsAnInvalidRow(t *testing.T) {
+	repo := testsupport.NewReferrals(testsupport.NewUsers())
+	svc := referral.NewImportService(referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil).WithRegions(newRegionResolver()))
+
+	csv := "region,price_min,price_max,sla_hours\n" +
+		"Brooklyn;New York,100,200,24\n" +
+		"Gotham,100,200,24\n"
+	summary, err := svc.Import(context.Background(), "agent-1", strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Created != 1 || summary.Invalid != 1 {
+		t.Fatalf("expected 1 created and 1 invalid, got %+v", summary)
+	}
+	if got := summary.Rows[0].Request.Region; !slices.Equal(got, []string{"us-ny-brooklyn", "us-ny"}) {
+		t.Fatalf("imported region = %v", got)
+	}
+	if !errors.Is(summary.Rows[1].Err, region.ErrUnknownRegion) {
+		t.Fatalf("expected row 3 to fail with ErrUnknownRegion, got %v", summary.Rows[1].Err)
+	}
+}
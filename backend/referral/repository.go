@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"strings"
 
+	"brokerflow/db"
+	"brokerflow/tenancy"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
@@ -16,25 +17,49 @@ var (
 
 type Repository interface {
 	Create(ctx context.Context, tx pgx.Tx, req Request) (Request, error)
-	List(ctx context.Context, filters Filters) ([]Request, int, error)
+	List(ctx context.Context, filters Filters) ([]Request, db.Page, error)
 	GetForUpdate(ctx context.Context, tx pgx.Tx, id string) (Request, error)
+	// GetScopedForUpdate is GetForUpdate limited to scope: requests outside
+	// it report ErrNotFound.
+	GetScopedForUpdate(ctx context.Context, tx pgx.Tx, id string, scope tenancy.Scope) (Request, error)
+	// Update writes the editable fields of req.
+	Update(ctx context.Context, tx pgx.Tx, req Request) (Request, error)
 	UpdateStatus(ctx context.Context, tx pgx.Tx, id string, status Status, cancelReason *string) (Request, error)
+	// Archive sets archived_at on the request.
+	Archive(ctx context.Context, tx pgx.Tx, id string) (Request, error)
+	// FindDuplicate returns the newest live request matching q, or
+	// ErrNotFound.
+	FindDuplicate(ctx context.Context, tx pgx.Tx, q DuplicateQuery) (Request, error)
 }
 
 type PGRepository struct {
-	pool *pgxpool.Pool
+	pool    db.Writer
+	reader  db.Reader
+	counter *db.Counter
 }
 
-func NewRepository(pool *pgxpool.Pool) *PGRepository {
-	return &PGRepository{pool: pool}
+func NewRepository(pool db.Writer) *PGRepository {
+	return &PGRepository{pool: pool, reader: pool}
+}
+
+// WithReader runs List, with its count, and GetMany on reader.
+func (r *PGRepository) WithReader(reader db.Reader) *PGRepository {
+	r.reader = reader
+	return r
+}
+
+// WithCounter totals lists through counter instead of an exact COUNT(*).
+func (r *PGRepository) WithCounter(counter *db.Counter) *PGRepository {
+	r.counter = counter
+	return r
 }
 
 func (r *PGRepository) Create(ctx context.Context, tx pgx.Tx, req Request) (Request, error) {
 	const query = `
-        INSERT INTO referral_requests (id, created_by_user_id, region, price_min, price_max, property_type,
-            deal_type, languages, sla_hours, status, cancel_reason)
-        VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-        RETURNING id, created_by_user_id, region, price_min, price_max, property_type, deal_type, languages, sla_hours, status, cancel_reason, created_at, updated_at
+        INSERT INTO referral_requests (id, created_by_user_id, region, price_min, price_max, currency, property_type,
+            deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason)
+        VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        RETURNING id, created_by_user_id, region, price_min, price_max, currency, property_type, deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason, version, created_at, updated_at, archived_at
     `
 
 	row := tx.QueryRow(ctx, query,
@@ -43,10 +68,12 @@ func (r *PGRepository) Create(ctx context.Context, tx pgx.Tx, req Request) (Requ
 		req.Region,
 		req.PriceMin,
 		req.PriceMax,
+		req.Currency,
 		req.PropertyType,
 		req.DealType,
 		req.Languages,
 		req.SLAHours,
+		req.MatchTTLHours,
 		req.Status,
 		req.CancelReason,
 	)
@@ -54,7 +81,7 @@ func (r *PGRepository) Create(ctx context.Context, tx pgx.Tx, req Request) (Requ
 	return scanRequest(row)
 }
 
-func (r *PGRepository) List(ctx context.Context, filters Filters) ([]Request, int, error) {
+func (r *PGRepository) List(ctx context.Context, filters Filters) ([]Request, db.Page, error) {
 	if filters.Page <= 0 {
 		filters.Page = 1
 	}
@@ -68,43 +95,37 @@ func (r *PGRepository) List(ctx context.Context, filters Filters) ([]Request, in
 		filters.SortOrder = "desc"
 	}
 
-	base := `SELECT id, created_by_user_id, region, price_min, price_max, property_type, deal_type, languages, sla_hours, status, cancel_reason, created_at, updated_at
-             FROM referral_requests`
-	where := []string{"1=1"}
-	args := []any{}
-
-	if filters.CreatorUserID != "" {
-		where = append(where, fmt.Sprintf("created_by_user_id=$%d", len(args)+1))
-		args = append(args, filters.CreatorUserID)
+	q := db.Select(requestColumns).From("referral_requests")
+	if filters.Scope != (tenancy.Scope{}) {
+		q.Where(filters.Scope.Owns("created_by_user_id"))
+	}
+	if !filters.IncludeArchived {
+		q.Where("archived_at IS NULL")
 	}
 	if filters.Status != "" {
-		where = append(where, fmt.Sprintf("status=$%d", len(args)+1))
-		args = append(args, filters.Status)
+		q.Where("status = ?", filters.Status)
 	}
 	if filters.Region != "" {
-		where = append(where, fmt.Sprintf("$%d = ANY(region)", len(args)+1))
-		args = append(args, filters.Region)
+		q.Where("? = ANY(region)", filters.Region)
 	}
 	if filters.DealType != "" {
-		where = append(where, fmt.Sprintf("deal_type=$%d", len(args)+1))
-		args = append(args, filters.DealType)
+		q.Where("deal_type = ?", filters.DealType)
 	}
 
-	whereClause := " WHERE " + strings.Join(where, " AND ")
-
-	sortKey := mapSortKey(filters.SortKey)
 	sortOrder := strings.ToUpper(filters.SortOrder)
 	if sortOrder != "ASC" && sortOrder != "DESC" {
 		sortOrder = "DESC"
 	}
+	q.OrderBy(mapSortKey(filters.SortKey) + " " + sortOrder)
 
-	limit := filters.PageSize
+	// One row past the page tells whether another page follows.
 	offset := (filters.Page - 1) * filters.PageSize
+	q.Limit(filters.PageSize + 1).Offset(offset)
 
-	query := fmt.Sprintf(`%s%s ORDER BY %s %s LIMIT %d OFFSET %d`, base, whereClause, sortKey, sortOrder, limit, offset)
-	rows, err := r.pool.Query(ctx, query, args...)
+	query, args := q.SQL()
+	rows, err := r.reader.Query(ctx, query, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("referral: query list: %w", err)
+		return nil, db.Page{}, fmt.Errorf("referral: query list: %w", err)
 	}
 	defer rows.Close()
 
@@ -112,23 +133,52 @@ func (r *PGRepository) List(ctx context.Context, filters Filters) ([]Request, in
 	for rows.Next() {
 		req, err := scanRequest(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, db.Page{}, err
 		}
 		list = append(list, req)
 	}
 
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM referral_requests%s", whereClause)
-	var total int
-	if err := r.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, fmt.Errorf("referral: count list: %w", err)
+	list, page, err := db.Paginate(ctx, r.counter, r.reader, q.Count(), list, offset, filters.PageSize)
+	if err != nil {
+		return nil, db.Page{}, fmt.Errorf("referral: count list: %w", err)
 	}
+	return list, page, nil
+}
 
-	return list, total, nil
+// GetMany returns the requests among ids that scope can see, archived ones
+// included, in no particular order. Ids outside the scope or unknown are
+// left out.
+func (r *PGRepository) GetMany(ctx context.Context, ids []string, scope tenancy.Scope) ([]Request, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query, args := db.Select(requestColumns).From("referral_requests").
+		Where("id = ANY(?::uuid[])", ids).
+		Where(scope.Owns("created_by_user_id")).
+		SQL()
+	rows, err := r.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("referral: get many: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Request
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("referral: scan request: %w", err)
+		}
+		list = append(list, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("referral: iterate requests: %w", err)
+	}
+	return list, nil
 }
 
 func (r *PGRepository) GetForUpdate(ctx context.Context, tx pgx.Tx, id string) (Request, error) {
 	const query = `
-		SELECT id, created_by_user_id, region, price_min, price_max, property_type, deal_type, languages, sla_hours, status, cancel_reason, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM referral_requests
 		WHERE id = $1
 		FOR UPDATE
@@ -145,6 +195,52 @@ func (r *PGRepository) GetForUpdate(ctx context.Context, tx pgx.Tx, id string) (
 	return req, nil
 }
 
+func (r *PGRepository) GetScopedForUpdate(ctx context.Context, tx pgx.Tx, id string, scope tenancy.Scope) (Request, error) {
+	clause, arg := scope.OwnedBy("created_by_user_id", 2)
+	query := `
+		SELECT ` + requestColumns + `
+		FROM referral_requests
+		WHERE id = $1 AND ` + clause + `
+		FOR UPDATE
+	`
+
+	row := tx.QueryRow(ctx, query, id, arg)
+	req, err := scanRequest(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Request{}, ErrNotFound
+		}
+		return Request{}, fmt.Errorf("referral: get scoped for update: %w", err)
+	}
+	return req, nil
+}
+
+func (r *PGRepository) Update(ctx context.Context, tx pgx.Tx, req Request) (Request, error) {
+	const query = `
+		UPDATE referral_requests
+		SET region = $2,
+		    price_min = $3,
+		    price_max = $4,
+		    currency = $10,
+		    property_type = $5,
+		    deal_type = $6,
+		    languages = $7,
+		    sla_hours = $8,
+		    match_ttl_hours = $9,
+		    updated_at = get_tx_timestamp()
+		WHERE id = $1
+		RETURNING id, created_by_user_id, region, price_min, price_max, currency, property_type, deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason, version, created_at, updated_at, archived_at
+	`
+
+	row := tx.QueryRow(ctx, query, req.ID, req.Region, req.PriceMin, req.PriceMax, req.PropertyType,
+		req.DealType, req.Languages, req.SLAHours, req.MatchTTLHours, req.Currency)
+	updated, err := scanRequest(row)
+	if err != nil {
+		return Request{}, fmt.Errorf("referral: update: %w", err)
+	}
+	return updated, nil
+}
+
 func (r *PGRepository) UpdateStatus(ctx context.Context, tx pgx.Tx, id string, status Status, cancelReason *string) (Request, error) {
 	const query = `
 		UPDATE referral_requests
@@ -152,7 +248,7 @@ func (r *PGRepository) UpdateStatus(ctx context.Context, tx pgx.Tx, id string, s
 		    cancel_reason = $3,
 		    updated_at = get_tx_timestamp()
 		WHERE id = $1
-		RETURNING id, created_by_user_id, region, price_min, price_max, property_type, deal_type, languages, sla_hours, status, cancel_reason, created_at, updated_at
+		RETURNING id, created_by_user_id, region, price_min, price_max, currency, property_type, deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason, version, created_at, updated_at, archived_at
 	`
 
 	row := tx.QueryRow(ctx, query, id, status, cancelReason)
@@ -163,6 +259,54 @@ func (r *PGRepository) UpdateStatus(ctx context.Context, tx pgx.Tx, id string, s
 	return req, nil
 }
 
+func (r *PGRepository) Archive(ctx context.Context, tx pgx.Tx, id string) (Request, error) {
+	const query = `
+		UPDATE referral_requests
+		SET archived_at = get_tx_timestamp(),
+		    updated_at = get_tx_timestamp()
+		WHERE id = $1
+		RETURNING id, created_by_user_id, region, price_min, price_max, currency, property_type, deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason, version, created_at, updated_at, archived_at
+	`
+
+	req, err := scanRequest(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		return Request{}, fmt.Errorf("referral: archive: %w", err)
+	}
+	return req, nil
+}
+
+// FindDuplicate matches requests by the same creator created since q.Since
+// that are neither cancelled nor archived, share the deal type and currency,
+// and overlap q in price range and in region, one containing or lying within the other.
+func (r *PGRepository) FindDuplicate(ctx context.Context, tx pgx.Tx, q DuplicateQuery) (Request, error) {
+	const query = `
+		SELECT ` + requestColumns + `
+		FROM referral_requests
+		WHERE created_by_user_id = $1
+		  AND created_at >= $2
+		  AND status <> 'cancelled'
+		  AND archived_at IS NULL
+		  AND deal_type = $3
+		  AND regions_overlap(region, $4)
+		  AND price_min <= $6 AND price_max >= $5
+		  AND currency = $7
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	req, err := scanRequest(tx.QueryRow(ctx, query, q.CreatorUserID, q.Since, q.DealType, q.Region, q.PriceMin, q.PriceMax, q.Currency))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Request{}, ErrNotFound
+		}
+		return Request{}, fmt.Errorf("referral: find duplicate: %w", err)
+	}
+	return req, nil
+}
+
+// requestColumns are the columns scanRequest reads, in order.
+const requestColumns = `id, created_by_user_id, region, price_min, price_max, currency, property_type, deal_type, languages, sla_hours, match_ttl_hours, status, cancel_reason, version, created_at, updated_at, archived_at`
+
 func scanRequest(row pgx.Row) (Request, error) {
 	var req Request
 	return req, row.Scan(
@@ -171,14 +315,18 @@ func scanRequest(row pgx.Row) (Request, error) {
 		&req.Region,
 		&req.PriceMin,
 		&req.PriceMax,
+		&req.Currency,
 		&req.PropertyType,
 		&req.DealType,
 		&req.Languages,
 		&req.SLAHours,
+		&req.MatchTTLHours,
 		&req.Status,
 		&req.CancelReason,
+		&req.Version,
 		&req.CreatedAt,
 		&req.UpdatedAt,
+		&req.ArchivedAt,
 	)
 }
 
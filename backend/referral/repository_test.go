@@ -0,0 +1,91 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/db/dbtest"
+	"brokerflow/money"
+	"brokerflow/tenancy"
+)
+
+var requestColumnNames = []string{"id", "created_by_user_id", "region", "price_min", "price_max", "currency", "property_type",
+	"deal_type", "languages", "sla_hours", "match_ttl_hours", "status", "cancel_reason", "version", "created_at", "updated_at", "archived_at"}
+
+var requestCreated = time.Date(2024, 10, 31, 15, 4, 5, 0, time.UTC)
+
+func requestRow(id string) []any {
+	return []any{id, "agent-1", []string{"us-tx-austin"}, int64(400000), int64(600000), "USD", "condo",
+		"buy", []string{"en"}, int32(48), int32(72), "open", nil, int32(1), requestCreated, requestCreated, nil}
+}
+
+func TestPGRepositoryCreate_BindsEveryColumn(t *testing.T) {
+	mock := dbtest.New(t)
+	reason := "client withdrew"
+	req := Request{ID: "", CreatorUserID: "agent-1", Region: []string{"us-tx-austin"}, PriceMin: 400000, PriceMax: 600000,
+		Currency: money.USD, PropertyType: "condo", DealType: "buy", Languages: []string{"en"}, SLAHours: 48, MatchTTLHours: 72,
+		Status: StatusOpen, CancelReason: &reason}
+	mock.ExpectQuery("INSERT INTO referral_requests").
+		WithArgs("", "agent-1", []string{"us-tx-austin"}, int64(400000), int64(600000), money.USD, "condo", "buy",
+			[]string{"en"}, 48, 72, StatusOpen, &reason).
+		WillReturnRows(dbtest.NewRows(requestColumnNames...).AddRow(requestRow("r1")...))
+
+	got, err := NewRepository(mock).Create(context.Background(), mock.Tx(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "r1" || got.Currency != money.USD || got.Status != StatusOpen || got.SLAHours != 48 || got.Version != 1 ||
+		len(got.Region) != 1 || got.CancelReason != nil || got.ArchivedAt != nil || !got.CreatedAt.Equal(requestCreated) {
+		t.Fatalf("unexpected request %+v", got)
+	}
+}
+
+func TestPGRepositoryGetForUpdate_MapsNoRowsToNotFound(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectQuery("FROM referral_requests WHERE id = $1 FOR UPDATE").WithArgs("missing")
+	broken := errors.New("deadlock detected")
+	mock.ExpectQuery("FOR UPDATE").WithArgs("r1", "agent-1").WillReturnError(broken)
+
+	repo, tx := NewRepository(mock), mock.Tx()
+	if _, err := repo.GetForUpdate(context.Background(), tx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.GetScopedForUpdate(context.Background(), tx, "r1", tenancy.User("agent-1")); !errors.Is(err, broken) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the query error wrapped, got %v", err)
+	}
+}
+
+func TestPGRepositoryFindDuplicate_BindsTheQuery(t *testing.T) {
+	mock := dbtest.New(t)
+	since := requestCreated.Add(-24 * time.Hour)
+	mock.ExpectQuery("AND regions_overlap(region, $4)").
+		WithArgs("agent-1", since, "buy", []string{"us-tx"}, int64(1), int64(2), money.CAD)
+
+	_, err := NewRepository(mock).FindDuplicate(context.Background(), mock.Tx(), DuplicateQuery{
+		CreatorUserID: "agent-1", Region: []string{"us-tx"}, DealType: "buy", PriceMin: 1, PriceMax: 2, Currency: money.CAD, Since: since,
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPGRepositoryList_PagesAndCounts(t *testing.T) {
+	mock := dbtest.New(t)
+	mock.ExpectQuery("FROM referral_requests WHERE created_by_user_id = $1 AND archived_at IS NULL AND status = $2 ORDER BY price_min ASC LIMIT $3 OFFSET $4").
+		WithArgs("agent-1", StatusOpen, 3, 2).
+		WillReturnRows(dbtest.NewRows(requestColumnNames...).AddRow(requestRow("r3")...).AddRow(requestRow("r4")...).AddRow(requestRow("r5")...))
+	mock.ExpectQuery("SELECT COUNT(*) FROM referral_requests").WithArgs("agent-1", StatusOpen).
+		WillReturnRows(dbtest.NewRows("count").AddRow(int64(9)))
+
+	list, page, err := NewRepository(mock).List(context.Background(), Filters{
+		Scope: tenancy.User("agent-1"), Status: StatusOpen, Page: 2, PageSize: 2, SortKey: "priceMin", SortOrder: "asc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "r3" || !page.HasMore || page.Total != 9 || !page.TotalExact {
+		t.Fatalf("unexpected page %+v of %+v", page, list)
+	}
+}
@@ -0,0 +1,221 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxSavedFilters caps the saved filters of one user.
+	MaxSavedFilters = 20
+	// MaxSavedFilterName bounds the length of a filter name, in characters.
+	MaxSavedFilterName = 80
+)
+
+var (
+	ErrSavedFilterNotFound  = errors.New("referral: saved filter not found")
+	ErrSavedFilterName      = fmt.Errorf("referral: saved filter name must be 1-%d characters", MaxSavedFilterName)
+	ErrSavedFilterNameTaken = errors.New("referral: saved filter name already used")
+	ErrSavedFilterInvalid   = errors.New("referral: invalid saved filter")
+	ErrTooManySavedFilters  = fmt.Errorf("referral: at most %d saved filters per user", MaxSavedFilters)
+)
+
+// SavedFilter is a named set of referrals list filters. Empty fields match
+// anything. With Notify set, the owner is emailed about new referrals they
+// can see that match Status, Region and DealType.
+type SavedFilter struct {
+	ID        string
+	UserID    string
+	Name      string
+	Status    Status
+	Region    string
+	DealType  string
+	SortKey   string
+	SortOrder string
+	Notify    bool
+	CreatedAt time.Time
+}
+
+// SavedFilterStore persists saved filters. Create reports
+// ErrSavedFilterNameTaken and ErrTooManySavedFilters; Delete reports
+// ErrSavedFilterNotFound for another user's filter.
+type SavedFilterStore interface {
+	ListSavedFilters(ctx context.Context, userID string) ([]SavedFilter, error)
+	CreateSavedFilter(ctx context.Context, f SavedFilter) (SavedFilter, error)
+	DeleteSavedFilter(ctx context.Context, userID, id string) error
+}
+
+type SavedFilterService struct {
+	store SavedFilterStore
+}
+
+func NewSavedFilterService(store SavedFilterStore) *SavedFilterService {
+	return &SavedFilterService{store: store}
+}
+
+func (s *SavedFilterService) List(ctx context.Context, userID string) ([]SavedFilter, error) {
+	return s.store.ListSavedFilters(ctx, userID)
+}
+
+func (s *SavedFilterService) Create(ctx context.Context, f SavedFilter) (SavedFilter, error) {
+	f, err := validateSavedFilter(f)
+	if err != nil {
+		return SavedFilter{}, err
+	}
+	return s.store.CreateSavedFilter(ctx, f)
+}
+
+func (s *SavedFilterService) Delete(ctx context.Context, userID, id string) error {
+	return s.store.DeleteSavedFilter(ctx, userID, id)
+}
+
+// validateSavedFilter trims the filter and checks it against what the
+// referrals list accepts, so a saved filter always replays as saved.
+func validateSavedFilter(f SavedFilter) (SavedFilter, error) {
+	f.Name = strings.TrimSpace(f.Name)
+	f.Region = strings.TrimSpace(f.Region)
+	f.DealType = strings.TrimSpace(f.DealType)
+	if n := len([]rune(f.Name)); n == 0 || n > MaxSavedFilterName {
+		return f, ErrSavedFilterName
+	}
+	switch f.Status {
+	case "", StatusOpen, StatusMatched, StatusSigned, StatusInProgress, StatusClosed, StatusDisputed, StatusCancelled:
+	default:
+		return f, fmt.Errorf("%w: unknown status %q", ErrSavedFilterInvalid, f.Status)
+	}
+	// mapSortKey falls back to created_at for keys it does not know.
+	if f.SortKey != "" && f.SortKey != "createdAt" && mapSortKey(f.SortKey) == "created_at" {
+		return f, fmt.Errorf("%w: unknown sort key %q", ErrSavedFilterInvalid, f.SortKey)
+	}
+	switch strings.ToLower(f.SortOrder) {
+	case "", "asc", "desc":
+		f.SortOrder = strings.ToLower(f.SortOrder)
+	default:
+		return f, fmt.Errorf("%w: sort order must be asc or desc", ErrSavedFilterInvalid)
+	}
+	return f, nil
+}
+
+type PGSavedFilterRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSavedFilterRepository(pool *pgxpool.Pool) *PGSavedFilterRepository {
+	return &PGSavedFilterRepository{pool: pool}
+}
+
+const savedFilterColumns = `id::text, user_id::text, name, status, region, deal_type, sort_key, sort_order, notify, created_at`
+
+func scanSavedFilter(row pgx.Row) (SavedFilter, error) {
+	var f SavedFilter
+	err := row.Scan(&f.ID, &f.UserID, &f.Name, &f.Status, &f.Region, &f.DealType, &f.SortKey, &f.SortOrder, &f.Notify, &f.CreatedAt)
+	return f, err
+}
+
+func (r *PGSavedFilterRepository) ListSavedFilters(ctx context.Context, userID string) ([]SavedFilter, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+savedFilterColumns+`
+		FROM saved_filters
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("referral: list saved filters: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (SavedFilter, error) { return scanSavedFilter(row) })
+	if err != nil {
+		return nil, fmt.Errorf("referral: scan saved filters: %w", err)
+	}
+	return out, nil
+}
+
+// CreateSavedFilter inserts the filter unless the user already has
+// MaxSavedFilters; the per-user row lock on users serialises concurrent
+// creates so the cap holds.
+func (r *PGSavedFilterRepository) CreateSavedFilter(ctx context.Context, f SavedFilter) (SavedFilter, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return SavedFilter{}, fmt.Errorf("referral: begin create saved filter: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var count int
+	if err := tx.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM saved_filters WHERE user_id = u.id)
+		FROM users u
+		WHERE u.id = $1
+		FOR UPDATE OF u
+	`, f.UserID).Scan(&count); err != nil {
+		return SavedFilter{}, fmt.Errorf("referral: count saved filters: %w", err)
+	}
+	if count >= MaxSavedFilters {
+		return SavedFilter{}, ErrTooManySavedFilters
+	}
+
+	created, err := scanSavedFilter(tx.QueryRow(ctx, `
+		INSERT INTO saved_filters (user_id, name, status, region, deal_type, sort_key, sort_order, notify)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+savedFilterColumns,
+		f.UserID, f.Name, f.Status, f.Region, f.DealType, f.SortKey, f.SortOrder, f.Notify))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return SavedFilter{}, ErrSavedFilterNameTaken
+		}
+		return SavedFilter{}, fmt.Errorf("referral: create saved filter: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return SavedFilter{}, fmt.Errorf("referral: commit saved filter: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PGSavedFilterRepository) DeleteSavedFilter(ctx context.Context, userID, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM saved_filters WHERE id::text = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("referral: delete saved filter: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSavedFilterNotFound
+	}
+	return nil
+}
+
+// ReferralWatchers returns the users to notify about a new referral: owners
+// of a notify filter matching it who can see it, either as a broker admin of
+// the creator's brokerage (their referrals list) or through a marketplace
+// subscription. The creator is never included.
+func (r *PGSavedFilterRepository) ReferralWatchers(ctx context.Context, referralID string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT DISTINCT sf.user_id::text
+		FROM referral_requests r
+		JOIN users creator ON creator.id = r.created_by_user_id
+		JOIN saved_filters sf ON sf.notify
+			AND (sf.status = '' OR sf.status = r.status)
+			AND (sf.region = '' OR sf.region = ANY (r.region))
+			AND (sf.deal_type = '' OR sf.deal_type = r.deal_type)
+		JOIN users u ON u.id = sf.user_id AND u.deleted_at IS NULL AND u.deactivated_at IS NULL
+		WHERE r.id::text = $1
+			AND sf.user_id <> r.created_by_user_id
+			AND (
+				(u.role = 'broker_admin' AND u.broker_id = creator.broker_id)
+				OR EXISTS (SELECT 1 FROM marketplace_subscriptions s WHERE s.user_id = sf.user_id AND `+listingVisible+`)
+			)
+		ORDER BY 1
+	`, referralID)
+	if err != nil {
+		return nil, fmt.Errorf("referral: load watchers: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("referral: scan watchers: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,34 @@
+package referral
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateSavedFilter(t *testing.T) {
+	f, err := validateSavedFilter(SavedFilter{Name: "  Austin buyers ", Region: " Austin", DealType: "buy", SortKey: "priceMin", SortOrder: "ASC", Notify: true})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if f.Name != "Austin buyers" || f.Region != "Austin" || f.SortOrder != "asc" {
+		t.Fatalf("expected trimmed, normalised filter, got %+v", f)
+	}
+
+	cases := []struct {
+		name   string
+		filter SavedFilter
+		want   error
+	}{
+		{"blank name", SavedFilter{Name: "  "}, ErrSavedFilterName},
+		{"long name", SavedFilter{Name: strings.Repeat("x", MaxSavedFilterName+1)}, ErrSavedFilterName},
+		{"status", SavedFilter{Name: "a", Status: "archived"}, ErrSavedFilterInvalid},
+		{"sort key", SavedFilter{Name: "a", SortKey: "score"}, ErrSavedFilterInvalid},
+		{"sort order", SavedFilter{Name: "a", SortOrder: "up"}, ErrSavedFilterInvalid},
+	}
+	for _, tc := range cases {
+		if _, err := validateSavedFilter(tc.filter); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
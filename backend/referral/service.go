@@ -1,6 +1,9 @@
 package referral
 
 import (
+	"brokerflow/clock"
+	"brokerflow/db"
+	"brokerflow/money"
 	"context"
 	"errors"
 	"fmt"
@@ -21,13 +24,22 @@ type OutboxWriter interface {
 }
 
 type Service struct {
-	pool          *pgxpool.Pool
+	pool          TxBeginner
 	repo          Repository
 	timeline      TimelineWriter
 	outbox        OutboxWriter
 	idGenerator   func() string
-	now           func() time.Time
+	clock         clock.Clock
 	defaultStatus Status
+	// duplicateWindow bounds the duplicate check in Create; zero disables it.
+	duplicateWindow time.Duration
+	regions         RegionResolver
+}
+
+// RegionResolver maps region codes and aliases to canonical region codes,
+// failing for values that name no region; region.Service implements it.
+type RegionResolver interface {
+	Resolve(ctx context.Context, values []string) ([]string, error)
 }
 
 type CreateParams struct {
@@ -35,29 +47,38 @@ type CreateParams struct {
 	Region        []string
 	PriceMin      int64
 	PriceMax      int64
-	PropertyType  string
-	DealType      string
-	Languages     []string
-	SLAHours      int
+	// Currency defaults to money.DefaultCurrency when empty.
+	Currency     money.Currency
+	PropertyType string
+	DealType     string
+	Languages    []string
+	SLAHours     int
+	// MatchTTLHours defaults to DefaultMatchTTLHours when zero.
+	MatchTTLHours int
+	// Force skips the duplicate check.
+	Force bool
 }
 
 type ListResult struct {
 	Items []Request
-	Total int
+	db.Page
 }
 
-func NewService(pool *pgxpool.Pool, repo Repository, timeline TimelineWriter, outbox OutboxWriter) *Service {
-	if repo == nil {
-		repo = NewRepository(pool)
+// NewService builds the referral service. A nil repo defaults to the
+// PostgreSQL repository when pool is a *pgxpool.Pool.
+func NewService(pool TxBeginner, repo Repository, timeline TimelineWriter, outbox OutboxWriter) *Service {
+	if p, ok := pool.(*pgxpool.Pool); ok && repo == nil {
+		repo = NewRepository(p)
 	}
 	return &Service{
-		pool:          pool,
-		repo:          repo,
-		timeline:      timeline,
-		outbox:        outbox,
-		idGenerator:   func() string { return uuid.NewString() },
-		now:           time.Now,
-		defaultStatus: StatusOpen,
+		pool:            pool,
+		repo:            repo,
+		timeline:        timeline,
+		outbox:          outbox,
+		idGenerator:     func() string { return uuid.NewString() },
+		clock:           clock.New(),
+		defaultStatus:   StatusOpen,
+		duplicateWindow: DefaultDuplicateWindow,
 	}
 }
 
@@ -66,23 +87,84 @@ func (s *Service) WithIDGenerator(gen func() string) *Service {
 	return s
 }
 
-func (s *Service) WithClock(now func() time.Time) *Service {
-	s.now = now
+func (s *Service) WithClock(c clock.Clock) *Service {
+	s.clock = clock.OrReal(c)
 	return s
 }
 
-func (s *Service) Create(ctx context.Context, params CreateParams) (Request, error) {
+// WithRegions makes Create, Update and imports store canonical region codes
+// and reject regions the resolver does not know. Without it regions are
+// stored as given.
+func (s *Service) WithRegions(regions RegionResolver) *Service {
+	s.regions = regions
+	return s
+}
+
+// resolveRegions canonicalizes values when a resolver is configured.
+func (s *Service) resolveRegions(ctx context.Context, values []string) ([]string, error) {
+	if s.regions == nil {
+		return values, nil
+	}
+	codes, err := s.regions.Resolve(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return nil, ErrRegionRequired
+	}
+	return codes, nil
+}
+
+var (
+	ErrCreatorRequired   = errors.New("referral: missing creator user id")
+	ErrRegionRequired    = errors.New("referral: region required")
+	ErrInvalidPriceRange = errors.New("referral: invalid price range")
+	ErrInvalidSLAHours   = errors.New("referral: invalid SLA hours")
+	ErrInvalidMatchTTL   = fmt.Errorf("referral: match TTL hours must be between 1 and %d", MaxMatchTTLHours)
+)
+
+// validateCreate applies defaults and checks shared by Create and
+// ImportService.
+func validateCreate(params CreateParams) (CreateParams, error) {
 	if params.CreatorUserID == "" {
-		return Request{}, fmt.Errorf("referral: missing creator user id")
+		return params, ErrCreatorRequired
 	}
 	if len(params.Region) == 0 {
-		return Request{}, fmt.Errorf("referral: region required")
+		return params, ErrRegionRequired
 	}
 	if params.PriceMin <= 0 || params.PriceMax <= 0 || params.PriceMin >= params.PriceMax {
-		return Request{}, fmt.Errorf("referral: invalid price range")
+		return params, ErrInvalidPriceRange
+	}
+	if params.Currency == "" {
+		params.Currency = money.DefaultCurrency
+	}
+	currency, err := money.ParseCurrency(string(params.Currency))
+	if err != nil {
+		return params, err
 	}
+	params.Currency = currency
 	if params.SLAHours <= 0 {
-		return Request{}, fmt.Errorf("referral: invalid SLA hours")
+		return params, ErrInvalidSLAHours
+	}
+	if params.MatchTTLHours == 0 {
+		params.MatchTTLHours = DefaultMatchTTLHours
+	}
+	if params.MatchTTLHours < 0 || params.MatchTTLHours > MaxMatchTTLHours {
+		return params, ErrInvalidMatchTTL
+	}
+	return params, nil
+}
+
+// Create opens a referral. Unless params.Force is set it fails with a
+// *DuplicateError when the creator opened a similar referral within the
+// duplicate window.
+func (s *Service) Create(ctx context.Context, params CreateParams) (Request, error) {
+	params, err := validateCreate(params)
+	if err != nil {
+		return Request{}, err
+	}
+	if params.Region, err = s.resolveRegions(ctx, params.Region); err != nil {
+		return Request{}, err
 	}
 
 	tx, err := s.pool.Begin(ctx)
@@ -91,16 +173,51 @@ func (s *Service) Create(ctx context.Context, params CreateParams) (Request, err
 	}
 	defer tx.Rollback(ctx)
 
+	if !params.Force && s.duplicateWindow > 0 {
+		existing, err := s.repo.FindDuplicate(ctx, tx, DuplicateQuery{
+			CreatorUserID: params.CreatorUserID,
+			Region:        params.Region,
+			DealType:      params.DealType,
+			PriceMin:      params.PriceMin,
+			PriceMax:      params.PriceMax,
+			Currency:      params.Currency,
+			Since:         s.clock.Now().Add(-s.duplicateWindow),
+		})
+		switch {
+		case err == nil:
+			return Request{}, &DuplicateError{Existing: existing}
+		case !errors.Is(err, ErrNotFound):
+			return Request{}, err
+		}
+	}
+
+	created, err := s.createInTx(ctx, tx, params)
+	if err != nil {
+		return Request{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Request{}, fmt.Errorf("referral: commit tx: %w", err)
+	}
+
+	return created, nil
+}
+
+// createInTx inserts validated params together with their timeline event and
+// outbox message.
+func (s *Service) createInTx(ctx context.Context, tx pgx.Tx, params CreateParams) (Request, error) {
 	req := Request{
 		ID:            s.idGenerator(),
 		CreatorUserID: params.CreatorUserID,
 		Region:        params.Region,
 		PriceMin:      params.PriceMin,
 		PriceMax:      params.PriceMax,
+		Currency:      params.Currency,
 		PropertyType:  params.PropertyType,
 		DealType:      params.DealType,
 		Languages:     params.Languages,
 		SLAHours:      params.SLAHours,
+		MatchTTLHours: params.MatchTTLHours,
 		Status:        s.defaultStatus,
 	}
 
@@ -124,24 +241,19 @@ func (s *Service) Create(ctx context.Context, params CreateParams) (Request, err
 			"referral_id": created.ID,
 			"status":      created.Status,
 		}
-		if err := s.outbox.Enqueue(ctx, tx, "referral.created", payload); err != nil {
+		if err := s.outbox.Enqueue(ctx, tx, OutboxTopicReferralCreated, payload); err != nil {
 			return Request{}, fmt.Errorf("referral: enqueue outbox: %w", err)
 		}
 	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return Request{}, fmt.Errorf("referral: commit tx: %w", err)
-	}
-
 	return created, nil
 }
 
 func (s *Service) List(ctx context.Context, filters Filters) (ListResult, error) {
-	items, total, err := s.repo.List(ctx, filters)
+	items, page, err := s.repo.List(ctx, filters)
 	if err != nil {
 		return ListResult{}, err
 	}
-	return ListResult{Items: items, Total: total}, nil
+	return ListResult{Items: items, Page: page}, nil
 }
 
 type CancelParams struct {
@@ -208,7 +320,7 @@ func (s *Service) Cancel(ctx context.Context, params CancelParams) (Request, err
 		if updated.CancelReason != nil {
 			payload["reason"] = *updated.CancelReason
 		}
-		if err := s.outbox.Enqueue(ctx, tx, "referral.cancelled", payload); err != nil {
+		if err := s.outbox.Enqueue(ctx, tx, OutboxTopicReferralCancelled, payload); err != nil {
 			return Request{}, fmt.Errorf("referral: enqueue cancel outbox: %w", err)
 		}
 	}
@@ -0,0 +1,160 @@
+package referral
+
+import "brokerflow/outbox"
+
+const (
+	// OutboxTopicReferralCreated is published when a referral request is opened.
+	OutboxTopicReferralCreated = "referral.created"
+	// OutboxTopicReferralUpdated is published when the owner edits an open
+	// referral.
+	OutboxTopicReferralUpdated = "referral.updated"
+	// OutboxTopicReferralCancelled is published when the owner cancels a referral.
+	OutboxTopicReferralCancelled = "referral.cancelled"
+	// OutboxTopicReferralArchived is published when a referral is archived,
+	// by its owner or by the retention job.
+	OutboxTopicReferralArchived = "referral.archived"
+	// OutboxTopicMatchInvited is published when an owner invites a candidate.
+	OutboxTopicMatchInvited = "match.invited"
+	// OutboxTopicMatchAccepted is published when a candidate accepts an invitation.
+	OutboxTopicMatchAccepted = "match.accepted"
+	// OutboxTopicMatchDeclined is published when a candidate declines an invitation.
+	OutboxTopicMatchDeclined = "match.declined"
+	// OutboxTopicMatchExpired is published when an invitation lapses unanswered.
+	OutboxTopicMatchExpired = "match.expired"
+	// OutboxTopicMatchApplied is published when an agent applies from the marketplace.
+	OutboxTopicMatchApplied = "match.applied"
+	// OutboxTopicMatchCountered is published when a candidate answers an
+	// invitation with a counter-offer.
+	OutboxTopicMatchCountered = "match.countered"
+)
+
+// ReferralCreatedPayload is published on referral.created.
+type ReferralCreatedPayload struct {
+	ReferralID string `json:"referral_id"`
+	Status     Status `json:"status"`
+}
+
+// ReferralUpdatedPayload is published on referral.updated; Version is the
+// referral's version after the edit.
+type ReferralUpdatedPayload struct {
+	ReferralID string `json:"referral_id"`
+	Status     Status `json:"status"`
+	Version    int    `json:"version"`
+}
+
+// ReferralCancelledPayload is published on referral.cancelled.
+type ReferralCancelledPayload struct {
+	ReferralID string  `json:"referral_id"`
+	Status     Status  `json:"status"`
+	Reason     *string `json:"reason,omitempty"`
+}
+
+// ReferralArchivedPayload is published on referral.archived. Automatic is
+// true when the retention job archived the referral.
+type ReferralArchivedPayload struct {
+	ReferralID string `json:"referral_id"`
+	Status     Status `json:"status"`
+	Automatic  bool   `json:"automatic"`
+}
+
+// MatchEventPayload is published on every match.* topic. OwnerID is the
+// referral owner, so consumers can notify both sides without a lookup.
+type MatchEventPayload struct {
+	MatchID     string     `json:"match_id"`
+	ReferralID  string     `json:"referral_id"`
+	CandidateID string     `json:"candidate_id"`
+	OwnerID     string     `json:"owner_id"`
+	State       MatchState `json:"state"`
+	Score       float64    `json:"score"`
+	// DeclineReason is set on match.declined when the candidate gave one.
+	DeclineReason *DeclineReason `json:"decline_reason,omitempty"`
+	// CounterFeeRate and CounterProtectDays are set on match.countered and
+	// on match.accepted when the owner accepted a counter-offer.
+	CounterFeeRate     *float64 `json:"counter_fee_rate,omitempty"`
+	CounterProtectDays *int     `json:"counter_protect_days,omitempty"`
+}
+
+// OutboxTopics declares the topics this package enqueues.
+func OutboxTopics() []outbox.Topic {
+	return []outbox.Topic{
+		{
+			Name:        OutboxTopicReferralCreated,
+			Producer:    "referral",
+			Description: "A referral request was opened and is available for matching.",
+			Payload:     ReferralCreatedPayload{},
+		},
+		{
+			Name:        OutboxTopicReferralUpdated,
+			Producer:    "referral",
+			Description: "The owner edited the terms of an open referral.",
+			Payload:     ReferralUpdatedPayload{},
+		},
+		{
+			Name:        OutboxTopicReferralCancelled,
+			Producer:    "referral",
+			Description: "The owner cancelled an open or matched referral; pending invitations are no longer actionable.",
+			Payload:     ReferralCancelledPayload{},
+		},
+		{
+			Name:        OutboxTopicReferralArchived,
+			Producer:    "referral",
+			Description: "A closed or cancelled referral was archived and left the default referral list.",
+			Payload:     ReferralArchivedPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchInvited,
+			Producer:    "referral",
+			Description: "A referral owner invited a candidate agent. Emitted once per match in the inserting transaction.",
+			Payload:     MatchEventPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchAccepted,
+			Producer:    "referral",
+			Description: "The candidate accepted an invitation, or the owner accepted the candidate's counter-offer; agreement.created follows in the same transaction.",
+			Payload:     MatchEventPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchDeclined,
+			Producer:    "referral",
+			Description: "The candidate declined an invitation.",
+			Payload:     MatchEventPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchExpired,
+			Producer:    "referral",
+			Description: "An invitation passed its TTL unanswered; the owner may invite the candidate again.",
+			Payload:     MatchEventPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchApplied,
+			Producer:    "referral",
+			Description: "An agent applied to an open referral from the marketplace; the owner may invite them.",
+			Payload:     MatchEventPayload{},
+		},
+		{
+			Name:        OutboxTopicMatchCountered,
+			Producer:    "referral",
+			Description: "The candidate answered an invitation with other terms; the owner accepts them or rejects them, which re-sends match.invited.",
+			Payload:     MatchEventPayload{},
+		},
+	}
+}
+
+// matchTopic maps a match state to the topic announcing it.
+func matchTopic(state MatchState) (string, bool) {
+	switch state {
+	case MatchStateInvited:
+		return OutboxTopicMatchInvited, true
+	case MatchStateAccepted:
+		return OutboxTopicMatchAccepted, true
+	case MatchStateDeclined:
+		return OutboxTopicMatchDeclined, true
+	case MatchStateExpired:
+		return OutboxTopicMatchExpired, true
+	case MatchStateApplied:
+		return OutboxTopicMatchApplied, true
+	case MatchStateCountered:
+		return OutboxTopicMatchCountered, true
+	}
+	return "", false
+}
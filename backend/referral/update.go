@@ -0,0 +1,165 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"brokerflow/money"
+	"brokerflow/tenancy"
+)
+
+var (
+	ErrVersionConflict    = errors.New("referral: version conflict")
+	ErrUpdateInvalidState = errors.New("referral: only open referrals can be edited")
+)
+
+// VersionConflictError reports an update made against a stale version.
+// Current is the referral as it is now; the error matches ErrVersionConflict.
+type VersionConflictError struct {
+	Current Request
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("referral: version conflict: current version is %d", e.Current.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool { return target == ErrVersionConflict }
+
+// UpdateParams edits the fields of an open referral. Nil fields keep their
+// value. IfVersion must equal the referral's current version; zero skips the
+// check (If-Match: *).
+type UpdateParams struct {
+	RequestID     string
+	Scope         tenancy.Scope
+	IfVersion     int
+	Region        *[]string
+	PriceMin      *int64
+	PriceMax      *int64
+	Currency      *money.Currency
+	PropertyType  *string
+	DealType      *string
+	Languages     *[]string
+	SLAHours      *int
+	MatchTTLHours *int
+}
+
+// Update applies params under the row lock, so of two editors holding the
+// same version only the first succeeds; the second gets a
+// VersionConflictError carrying the first one's result. Referrals outside
+// params.Scope report ErrNotFound.
+func (s *Service) Update(ctx context.Context, params UpdateParams) (Request, error) {
+	if params.RequestID == "" {
+		return Request{}, fmt.Errorf("referral: update missing request id")
+	}
+	if params.Region != nil && len(*params.Region) > 0 {
+		codes, err := s.resolveRegions(ctx, *params.Region)
+		if err != nil {
+			return Request{}, err
+		}
+		params.Region = &codes
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Request{}, fmt.Errorf("referral: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := s.repo.GetScopedForUpdate(ctx, tx, params.RequestID, params.Scope)
+	if err != nil {
+		return Request{}, err
+	}
+	if params.IfVersion != 0 && params.IfVersion != current.Version {
+		return Request{}, &VersionConflictError{Current: current}
+	}
+	if current.Status != StatusOpen {
+		return Request{}, ErrUpdateInvalidState
+	}
+
+	merged, err := validateCreate(mergeUpdate(current, params))
+	if err != nil {
+		return Request{}, err
+	}
+	next := current
+	next.Region = merged.Region
+	next.PriceMin, next.PriceMax, next.Currency = merged.PriceMin, merged.PriceMax, merged.Currency
+	next.PropertyType, next.DealType = merged.PropertyType, merged.DealType
+	next.Languages = merged.Languages
+	next.SLAHours, next.MatchTTLHours = merged.SLAHours, merged.MatchTTLHours
+
+	updated, err := s.repo.Update(ctx, tx, next)
+	if err != nil {
+		return Request{}, err
+	}
+
+	if s.timeline != nil {
+		payload := map[string]any{
+			"referral_id": updated.ID,
+			"version":     updated.Version,
+		}
+		if err := s.timeline.Append(ctx, tx, updated.ID, "REFERRAL_UPDATED", payload); err != nil {
+			return Request{}, fmt.Errorf("referral: append timeline: %w", err)
+		}
+	}
+	if s.outbox != nil {
+		payload := map[string]any{
+			"referral_id": updated.ID,
+			"status":      updated.Status,
+			"version":     updated.Version,
+		}
+		if err := s.outbox.Enqueue(ctx, tx, OutboxTopicReferralUpdated, payload); err != nil {
+			return Request{}, fmt.Errorf("referral: enqueue update outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Request{}, fmt.Errorf("referral: update commit: %w", err)
+	}
+	return updated, nil
+}
+
+// mergeUpdate overlays the set fields of params on current, as CreateParams
+// so the result goes through the same validation as a new referral.
+func mergeUpdate(current Request, params UpdateParams) CreateParams {
+	p := CreateParams{
+		CreatorUserID: current.CreatorUserID,
+		Region:        current.Region,
+		PriceMin:      current.PriceMin,
+		PriceMax:      current.PriceMax,
+		Currency:      current.Currency,
+		PropertyType:  current.PropertyType,
+		DealType:      current.DealType,
+		Languages:     current.Languages,
+		SLAHours:      current.SLAHours,
+		MatchTTLHours: current.MatchTTLHours,
+	}
+	if params.Region != nil {
+		p.Region = *params.Region
+	}
+	if params.PriceMin != nil {
+		p.PriceMin = *params.PriceMin
+	}
+	if params.PriceMax != nil {
+		p.PriceMax = *params.PriceMax
+	}
+	if params.Currency != nil {
+		p.Currency = *params.Currency
+	}
+	if params.PropertyType != nil {
+		p.PropertyType = *params.PropertyType
+	}
+	if params.DealType != nil {
+		p.DealType = *params.DealType
+	}
+	if params.Languages != nil {
+		p.Languages = *params.Languages
+	}
+	if params.SLAHours != nil {
+		p.SLAHours = *params.SLAHours
+	}
+	if params.MatchTTLHours != nil {
+		p.MatchTTLHours = *params.MatchTTLHours
+	}
+	return p
+}
@@ -0,0 +1,92 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"brokerflow/auth"
+	"brokerflow/money"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+)
+
+func TestUpdate_RejectsStaleVersionAndKeepsFirstWrite(t *testing.T) {
+	ctx := context.Background()
+	users := testsupport.NewUsers()
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", Role: auth.RoleAgent, BrokerID: &brokerID})
+	repo := testsupport.NewReferrals(users)
+	pool := &testsupport.TxBeginner{}
+	svc := referral.NewService(pool, repo, nil, nil)
+
+	created, err := svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Austin"}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	admin := tenancy.Scope{UserID: "admin-1", BrokerID: brokerID}
+
+	priceMax, sla := int64(300), 48
+	first, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: admin, IfVersion: created.Version, PriceMax: &priceMax})
+	if err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if first.Version != created.Version+1 || first.PriceMax != 300 || first.SLAHours != 24 {
+		t.Fatalf("unexpected first update result: %+v", first)
+	}
+
+	_, err = svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: tenancy.Scope{UserID: "agent-1"}, IfVersion: created.Version, SLAHours: &sla})
+	var conflict *referral.VersionConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, referral.ErrVersionConflict) {
+		t.Fatalf("expected a version conflict, got %v", err)
+	}
+	if conflict.Current.Version != first.Version || conflict.Current.PriceMax != 300 || conflict.Current.SLAHours != 24 {
+		t.Fatalf("expected the conflict to carry the first write, got %+v", conflict.Current)
+	}
+
+	if _, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: tenancy.Scope{UserID: "someone-else"}, PriceMax: &priceMax}); !errors.Is(err, referral.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound outside the scope, got %v", err)
+	}
+	priceMin := int64(500)
+	if _, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: admin, PriceMin: &priceMin}); !errors.Is(err, referral.ErrInvalidPriceRange) {
+		t.Fatalf("expected the merged price range to be validated, got %v", err)
+	}
+	if _, err := svc.Cancel(ctx, referral.CancelParams{RequestID: created.ID, ActorID: "agent-1", ActorRole: "agent"}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: admin, SLAHours: &sla}); !errors.Is(err, referral.ErrUpdateInvalidState) {
+		t.Fatalf("expected ErrUpdateInvalidState after cancel, got %v", err)
+	}
+	// Create, the first update and the cancel; refused updates commit nothing.
+	if pool.Commits() != 3 {
+		t.Fatalf("expected 3 commits, got %d", pool.Commits())
+	}
+}
+
+func TestUpdate_ValidatesAndChangesCurrency(t *testing.T) {
+	ctx := context.Background()
+	repo := testsupport.NewReferrals(testsupport.NewUsers())
+	svc := referral.NewService(&testsupport.TxBeginner{}, repo, nil, nil)
+
+	created, err := svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Toronto"}, PriceMin: 100, PriceMax: 200, SLAHours: 24})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.Currency != money.USD {
+		t.Fatalf("expected prices to default to USD, got %q", created.Currency)
+	}
+	if _, err := svc.Create(ctx, referral.CreateParams{CreatorUserID: "agent-1", Region: []string{"Toronto"}, PriceMin: 100, PriceMax: 200, Currency: "EUR", SLAHours: 24}); !errors.Is(err, money.ErrUnsupportedCurrency) {
+		t.Fatalf("expected ErrUnsupportedCurrency, got %v", err)
+	}
+
+	scope := tenancy.Scope{UserID: "agent-1"}
+	cad := money.Currency("cad")
+	updated, err := svc.Update(ctx, referral.UpdateParams{RequestID: created.ID, Scope: scope, Currency: &cad})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Currency != money.CAD || updated.Version != created.Version+1 {
+		t.Fatalf("expected CAD in a new version, got %+v", updated)
+	}
+}
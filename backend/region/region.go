@@ -0,0 +1,85 @@
+// Package region is the reference list of canonical regions that referrals,
+// agent profiles and marketplace subscriptions are tagged with. Codes are
+// lower-case and hyphenated ("us-ny-brooklyn"); each region may have a
+// parent, so a referral in Brooklyn falls within New York for matching.
+package region
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindCountry  Kind = "country"
+	KindState    Kind = "state"
+	KindCounty   Kind = "county"
+	KindCity     Kind = "city"
+	KindDistrict Kind = "district"
+)
+
+var (
+	ErrNotFound      = errors.New("region: not found")
+	ErrUnknownRegion = errors.New("region: unknown region")
+)
+
+// UnknownRegionsError lists the values Resolve could not map to a region:
+// ones naming no code or alias, and aliases shared by several regions. It
+// matches ErrUnknownRegion.
+type UnknownRegionsError struct {
+	Values []string
+}
+
+func (e *UnknownRegionsError) Error() string {
+	return fmt.Sprintf("region: unknown region %s", strings.Join(e.Values, ", "))
+}
+
+func (e *UnknownRegionsError) Is(target error) bool { return target == ErrUnknownRegion }
+
+type Region struct {
+	Code       string
+	Name       string
+	Kind       Kind
+	ParentCode *string
+	// Path lists the region's ancestors and the region itself, root first.
+	Path []string
+	// Aliases are other spellings that resolve to Code, in canonical form.
+	Aliases []string
+	// Boundary is an optional GeoJSON polygon or multipolygon.
+	Boundary  json.RawMessage
+	CreatedAt time.Time
+}
+
+// Within reports whether r is code or lies inside it.
+func (r Region) Within(code string) bool {
+	for _, c := range r.Path {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Canonical lower-cases s and joins its runs of ASCII letters and digits with
+// hyphens, so "Brooklyn, NY" becomes "brooklyn-ny". It matches the
+// region_canonical SQL function.
+func Canonical(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
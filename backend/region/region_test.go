@@ -0,0 +1,86 @@
+package region_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"brokerflow/region"
+	"brokerflow/testsupport"
+)
+
+func TestCanonical(t *testing.T) {
+	for in, want := range map[string]string{
+		"Brooklyn":          "brooklyn",
+		"brooklyn, NY":      "brooklyn-ny",
+		"  US-NY-Brooklyn ": "us-ny-brooklyn",
+		"St. Louis--MO":     "st-louis-mo",
+		"Montréal":          "montr-al",
+		" , ":               "",
+	} {
+		if got := region.Canonical(in); got != want {
+			t.Errorf("Canonical(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func newTestRegions() *testsupport.Regions {
+	parent := func(code string) *string { return &code }
+	store := testsupport.NewRegions()
+	store.Add(region.Region{Code: "us", Name: "United States", Kind: region.KindCountry})
+	store.Add(region.Region{Code: "us-ny", Name: "New York", Kind: region.KindState, ParentCode: parent("us"), Aliases: []string{"ny", "new-york"}})
+	store.Add(region.Region{Code: "us-ny-brooklyn", Name: "Brooklyn", Kind: region.KindDistrict, ParentCode: parent("us-ny"), Aliases: []string{"brooklyn", "brooklyn-ny"}})
+	store.Add(region.Region{Code: "us-or", Name: "Oregon", Kind: region.KindState, ParentCode: parent("us"), Aliases: []string{"or"}})
+	store.Add(region.Region{Code: "us-or-portland", Name: "Portland", Kind: region.KindCity, ParentCode: parent("us-or"), Aliases: []string{"portland"}})
+	store.Add(region.Region{Code: "us-me", Name: "Maine", Kind: region.KindState, ParentCode: parent("us"), Aliases: []string{"me"}})
+	store.Add(region.Region{Code: "us-me-portland", Name: "Portland", Kind: region.KindCity, ParentCode: parent("us-me"), Aliases: []string{"portland"}})
+	return store
+}
+
+func TestResolve_MapsSpellingsToOneCode(t *testing.T) {
+	svc := region.NewService(newTestRegions())
+
+	got, err := svc.Resolve(context.Background(), []string{"Brooklyn", "brooklyn, NY", "US-NY-Brooklyn", " ", "New York"})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if want := []string{"us-ny-brooklyn", "us-ny"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolve_ReportsUnknownAndAmbiguousValues(t *testing.T) {
+	svc := region.NewService(newTestRegions())
+
+	_, err := svc.Resolve(context.Background(), []string{"Brooklyn", "Portland", "Atlantis"})
+	var unknown *region.UnknownRegionsError
+	if !errors.As(err, &unknown) || !errors.Is(err, region.ErrUnknownRegion) {
+		t.Fatalf("expected UnknownRegionsError, got %v", err)
+	}
+	if want := []string{"Portland", "Atlantis"}; !slices.Equal(unknown.Values, want) {
+		t.Fatalf("unknown values = %v, want %v", unknown.Values, want)
+	}
+
+	if got, err := svc.Resolve(context.Background(), []string{"us-or-portland"}); err != nil || !slices.Equal(got, []string{"us-or-portland"}) {
+		t.Fatalf("expected a code to resolve despite the shared alias, got %v, %v", got, err)
+	}
+}
+
+func TestList_FiltersByAncestry(t *testing.T) {
+	svc := region.NewService(newTestRegions())
+	ctx := context.Background()
+
+	within, err := svc.List(ctx, region.Filter{Within: "US-NY"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(within) != 2 || !within[0].Within("us-ny") || !within[1].Within("us") {
+		t.Fatalf("expected New York and Brooklyn, got %+v", within)
+	}
+
+	children, err := svc.List(ctx, region.Filter{Parent: "us", Query: "or"})
+	if err != nil || len(children) != 1 || children[0].Code != "us-or" {
+		t.Fatalf("expected Oregon, got %+v, %v", children, err)
+	}
+}
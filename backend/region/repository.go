@@ -0,0 +1,97 @@
+package region
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository reads regions from the regions table.
+type Repository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+const regionColumns = `code, name, kind, parent_code, path, aliases, boundary, created_at`
+
+func scanRegion(row pgx.Row) (Region, error) {
+	var r Region
+	var boundary []byte
+	err := row.Scan(&r.Code, &r.Name, &r.Kind, &r.ParentCode, &r.Path, &r.Aliases, &boundary, &r.CreatedAt)
+	if boundary != nil {
+		r.Boundary = boundary
+	}
+	return r, err
+}
+
+func (r *Repository) Get(ctx context.Context, code string) (Region, error) {
+	reg, err := scanRegion(r.pool.QueryRow(ctx, `SELECT `+regionColumns+` FROM regions WHERE code = $1`, code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Region{}, ErrNotFound
+		}
+		return Region{}, fmt.Errorf("region: get %s: %w", code, err)
+	}
+	return reg, nil
+}
+
+// List returns the regions matching f ordered by name. Boundaries are left
+// out; Get returns them.
+func (r *Repository) List(ctx context.Context, f Filter) ([]Region, error) {
+	q := db.Select("code, name, kind, parent_code, path, aliases, NULL::jsonb, created_at").From("regions")
+	if f.Parent != "" {
+		q.Where("parent_code = ?", f.Parent)
+	}
+	if f.Within != "" {
+		q.Where("path @> ARRAY[?::text]", f.Within)
+	}
+	if f.Query != "" {
+		prefix := Canonical(f.Query) + "%"
+		q.Where("(code LIKE ? OR region_canonical(name) LIKE ? OR EXISTS (SELECT 1 FROM unnest(aliases) AS a WHERE a LIKE ?))", prefix, prefix, prefix)
+	}
+	query, args := q.OrderBy("name", "code").Limit(f.Limit).SQL()
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("region: list: %w", err)
+	}
+	regions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Region, error) { return scanRegion(row) })
+	if err != nil {
+		return nil, fmt.Errorf("region: scan regions: %w", err)
+	}
+	return regions, nil
+}
+
+// Resolve maps each canonical value to its region code with region_resolve;
+// values naming no region are left out.
+func (r *Repository) Resolve(ctx context.Context, values []string) (map[string]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT v, region_resolve(v)
+		FROM unnest($1::text[]) AS v
+		WHERE region_resolve(v) IS NOT NULL
+	`, values)
+	if err != nil {
+		return nil, fmt.Errorf("region: resolve: %w", err)
+	}
+	defer rows.Close()
+	codes := make(map[string]string, len(values))
+	for rows.Next() {
+		var value, code string
+		if err := rows.Scan(&value, &code); err != nil {
+			return nil, fmt.Errorf("region: scan resolved region: %w", err)
+		}
+		codes[value] = code
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("region: iterate resolved regions: %w", err)
+	}
+	return codes, nil
+}
@@ -0,0 +1,98 @@
+package region
+
+import (
+	"context"
+	"strings"
+)
+
+// Limits on List.
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 500
+)
+
+// Filter narrows List. Parent keeps direct children of a region, Within
+// keeps a region and everything inside it, and Query keeps regions whose
+// code, name or an alias starts with it.
+type Filter struct {
+	Parent string
+	Within string
+	Query  string
+	Limit  int
+}
+
+// Store abstracts persistence of regions.
+type Store interface {
+	Get(ctx context.Context, code string) (Region, error)
+	List(ctx context.Context, f Filter) ([]Region, error)
+	// Resolve maps canonical values to region codes, leaving out values
+	// that name no region.
+	Resolve(ctx context.Context, values []string) (map[string]string, error)
+}
+
+type Service struct {
+	store Store
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+func (s *Service) Get(ctx context.Context, code string) (Region, error) {
+	return s.store.Get(ctx, Canonical(code))
+}
+
+func (s *Service) List(ctx context.Context, f Filter) ([]Region, error) {
+	f.Parent = Canonical(f.Parent)
+	f.Within = Canonical(f.Within)
+	f.Query = strings.TrimSpace(f.Query)
+	if f.Limit <= 0 || f.Limit > MaxListLimit {
+		f.Limit = DefaultListLimit
+	}
+	return s.store.List(ctx, f)
+}
+
+// Resolve maps region codes and aliases, in any spelling, to canonical
+// codes in input order without duplicates. Blank values are dropped. It
+// returns an *UnknownRegionsError naming every value that resolves to no
+// single region.
+func (s *Service) Resolve(ctx context.Context, values []string) ([]string, error) {
+	canonical := make([]string, 0, len(values))
+	original := make(map[string]string, len(values))
+	for _, v := range values {
+		c := Canonical(v)
+		if c == "" {
+			continue
+		}
+		if _, ok := original[c]; !ok {
+			original[c] = strings.TrimSpace(v)
+			canonical = append(canonical, c)
+		}
+	}
+	if len(canonical) == 0 {
+		return nil, nil
+	}
+
+	codes, err := s.store.Resolve(ctx, canonical)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make([]string, 0, len(canonical))
+	seen := make(map[string]bool, len(canonical))
+	var unknown []string
+	for _, c := range canonical {
+		code, ok := codes[c]
+		if !ok {
+			unknown = append(unknown, original[c])
+			continue
+		}
+		if !seen[code] {
+			seen[code] = true
+			resolved = append(resolved, code)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &UnknownRegionsError{Values: unknown}
+	}
+	return resolved, nil
+}
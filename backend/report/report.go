@@ -0,0 +1,161 @@
+// Package report aggregates referral, match and agreement activity for the
+// reporting endpoints. Every figure is scoped like the list endpoints: agents
+// see their own referrals, broker admins their whole brokerage.
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/money"
+	"brokerflow/tenancy"
+)
+
+// MaxRange bounds the reporting window so a single request cannot scan the
+// whole history.
+const MaxRange = 5 * 366 * 24 * time.Hour
+
+// DefaultRange is the window used when from is omitted.
+const DefaultRange = 365 * 24 * time.Hour
+
+var (
+	ErrInvalidRange = errors.New("report: from must be before to")
+	ErrRangeTooLong = errors.New("report: range exceeds five years")
+)
+
+// MonthCount is the number of referrals created in the calendar month
+// (UTC) starting at Month.
+type MonthCount struct {
+	Month time.Time
+	Count int
+}
+
+// Counts are the raw figures the store aggregates; Summary derives the rates.
+type Counts struct {
+	ReferralsPerMonth []MonthCount
+	ReferralsCreated  int
+	// ReferralValue sums the midpoints of those referrals' price bands, one
+	// entry per currency in code order.
+	ReferralValue []money.Money
+	// MatchesAnswered counts invitations on those referrals that reached an
+	// outcome: accepted, declined or expired.
+	MatchesAnswered int
+	MatchesAccepted int
+	// AvgAcceptedScore is nil when no match was accepted.
+	AvgAcceptedScore *float64
+	// Agreements counts agreements created in the window that the scope is
+	// party to; Disputed those with at least one dispute.
+	Agreements int
+	Disputed   int
+	// MedianDaysToEffective is measured from creation to effective_at over
+	// the agreements that took effect; nil when none did.
+	MedianDaysToEffective *float64
+}
+
+// Summary is Counts over [From, To) with the derived rates. A rate is nil
+// when its denominator is zero.
+type Summary struct {
+	From time.Time
+	To   time.Time
+	Counts
+	AcceptanceRate *float64
+	DisputeRate    *float64
+	// TotalReferralValue is ReferralValue converted into the requested
+	// currency.
+	TotalReferralValue money.Money
+}
+
+type Params struct {
+	Scope tenancy.Scope
+	// From defaults to To minus DefaultRange.
+	From time.Time
+	// To is exclusive and defaults to now.
+	To time.Time
+	// Currency is what money totals are converted into; it defaults to
+	// money.DefaultCurrency.
+	Currency money.Currency
+}
+
+// Store runs the aggregate queries; *Repository implements it against
+// PostgreSQL.
+type Store interface {
+	Counts(ctx context.Context, scope tenancy.Scope, from, to time.Time) (Counts, error)
+}
+
+type Service struct {
+	repo  Store
+	clock clock.Clock
+	rates money.Rates
+}
+
+func NewService(repo Store) *Service {
+	rates, _ := money.NewRates(money.DefaultCurrency, nil)
+	return &Service{repo: repo, clock: clock.New(), rates: rates}
+}
+
+// WithRates sets the exchange rates totals are converted with. Without them
+// only reports whose figures are all in the requested currency succeed.
+func (s *Service) WithRates(rates money.Rates) *Service {
+	s.rates = rates
+	return s
+}
+
+// WithClock overrides the time source for the default window.
+func (s *Service) WithClock(c clock.Clock) *Service {
+	s.clock = clock.OrReal(c)
+	return s
+}
+
+func (s *Service) Summary(ctx context.Context, params Params) (Summary, error) {
+	to := params.To
+	if to.IsZero() {
+		to = s.clock.Now()
+	}
+	from := params.From
+	if from.IsZero() {
+		from = to.Add(-DefaultRange)
+	}
+	if !from.Before(to) {
+		return Summary{}, ErrInvalidRange
+	}
+	if to.Sub(from) > MaxRange {
+		return Summary{}, ErrRangeTooLong
+	}
+
+	currency := params.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	counts, err := s.repo.Counts(ctx, params.Scope, from, to)
+	if err != nil {
+		return Summary{}, err
+	}
+	total := money.New(0, currency)
+	for _, v := range counts.ReferralValue {
+		converted, err := s.rates.Convert(v, currency)
+		if err != nil {
+			return Summary{}, fmt.Errorf("report: referral value: %w", err)
+		}
+		total.Amount += converted.Amount
+	}
+	return Summary{
+		From:               from.UTC(),
+		To:                 to.UTC(),
+		Counts:             counts,
+		AcceptanceRate:     ratio(counts.MatchesAccepted, counts.MatchesAnswered),
+		DisputeRate:        ratio(counts.Disputed, counts.Agreements),
+		TotalReferralValue: total,
+	}, nil
+}
+
+func ratio(n, d int) *float64 {
+	if d == 0 {
+		return nil
+	}
+	r := float64(n) / float64(d)
+	return &r
+}
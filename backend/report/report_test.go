@@ -0,0 +1,87 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/money"
+	"brokerflow/tenancy"
+)
+
+type fakeStore struct {
+	counts   Counts
+	from, to time.Time
+	calls    int
+}
+
+func (f *fakeStore) Counts(_ context.Context, _ tenancy.Scope, from, to time.Time) (Counts, error) {
+	f.calls++
+	f.from, f.to = from, to
+	return f.counts, nil
+}
+
+func TestSummary_DefaultsWindowAndDerivesRates(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{counts: Counts{MatchesAnswered: 4, MatchesAccepted: 3, Agreements: 0}}
+	svc := NewService(store).WithClock(clock.NewFake(now))
+
+	got, err := svc.Summary(context.Background(), Params{Scope: tenancy.User("u1")})
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if !store.to.Equal(now) || !store.from.Equal(now.Add(-DefaultRange)) {
+		t.Fatalf("unexpected window [%s, %s)", store.from, store.to)
+	}
+	if got.AcceptanceRate == nil || *got.AcceptanceRate != 0.75 {
+		t.Fatalf("expected acceptance rate 0.75, got %v", got.AcceptanceRate)
+	}
+	if got.DisputeRate != nil {
+		t.Fatalf("expected no dispute rate without agreements, got %v", *got.DisputeRate)
+	}
+}
+
+func TestSummary_RejectsBadRanges(t *testing.T) {
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		from, to time.Time
+		want     error
+	}{
+		{"empty", day, day, ErrInvalidRange},
+		{"reversed", day, day.AddDate(0, 0, -1), ErrInvalidRange},
+		{"too long", day.AddDate(-6, 0, 0), day, ErrRangeTooLong},
+	}
+	for _, tc := range cases {
+		store := &fakeStore{}
+		_, err := NewService(store).Summary(context.Background(), Params{From: tc.from, To: tc.to})
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+		if store.calls != 0 {
+			t.Fatalf("%s: store should not be queried", tc.name)
+		}
+	}
+}
+
+func TestSummary_ConvertsReferralValue(t *testing.T) {
+	store := &fakeStore{counts: Counts{ReferralValue: []money.Money{money.New(1000, money.CAD), money.New(250, money.USD)}}}
+	rates, err := money.ParseRates("CAD=0.75")
+	if err != nil {
+		t.Fatalf("rates: %v", err)
+	}
+
+	got, err := NewService(store).WithRates(rates).Summary(context.Background(), Params{Currency: money.CAD})
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if got.TotalReferralValue != money.New(1333, money.CAD) {
+		t.Fatalf("expected 1333 CAD, got %v", got.TotalReferralValue)
+	}
+
+	if _, err := NewService(store).Summary(context.Background(), Params{}); !errors.Is(err, money.ErrNoRate) {
+		t.Fatalf("expected ErrNoRate without rates, got %v", err)
+	}
+}
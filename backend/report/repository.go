@@ -0,0 +1,125 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"brokerflow/db"
+	"brokerflow/money"
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	pool   *pgxpool.Pool
+	reader db.Reader
+}
+
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool, reader: pool}
+}
+
+// WithReader runs the read-only summary transaction of Counts on reader.
+func (r *Repository) WithReader(reader db.Reader) *Repository {
+	r.reader = reader
+	return r
+}
+
+// Counts runs the monthly series and the aggregates in one read-only
+// snapshot. Referral and match figures cover referrals the scope owns that
+// were created in the window; agreement figures cover agreements the scope is
+// party to that were created in it. Both predicates bind the scope as $1.
+func (r *Repository) Counts(ctx context.Context, scope tenancy.Scope, from, to time.Time) (Counts, error) {
+	owned, arg := scope.OwnedBy("rr.created_by_user_id", 1)
+	party, _ := scope.PartyTo("rr.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+
+	tx, err := r.reader.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return Counts{}, fmt.Errorf("report: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := db.ApplyDeadline(ctx, tx); err != nil {
+		return Counts{}, fmt.Errorf("report: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT date_trunc('month', rr.created_at, 'UTC'), COUNT(*)
+		FROM referral_requests rr
+		WHERE `+owned+` AND rr.created_at >= $2 AND rr.created_at < $3
+		GROUP BY 1
+		ORDER BY 1
+	`, arg, from, to)
+	if err != nil {
+		return Counts{}, fmt.Errorf("report: referrals per month: %w", err)
+	}
+	months, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (MonthCount, error) {
+		var m MonthCount
+		err := row.Scan(&m.Month, &m.Count)
+		m.Month = m.Month.UTC()
+		return m, err
+	})
+	if err != nil {
+		return Counts{}, fmt.Errorf("report: scan referrals per month: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT rr.currency, SUM((rr.price_min + rr.price_max) / 2)::bigint
+		FROM referral_requests rr
+		WHERE `+owned+` AND rr.created_at >= $2 AND rr.created_at < $3
+		GROUP BY 1
+		ORDER BY 1
+	`, arg, from, to)
+	if err != nil {
+		return Counts{}, fmt.Errorf("report: referral value: %w", err)
+	}
+	value, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (money.Money, error) {
+		var m money.Money
+		err := row.Scan(&m.Currency, &m.Amount)
+		return m, err
+	})
+	if err != nil {
+		return Counts{}, fmt.Errorf("report: scan referral value: %w", err)
+	}
+
+	c := Counts{ReferralsPerMonth: months, ReferralValue: value}
+	if err := tx.QueryRow(ctx, `
+		WITH refs AS (
+			SELECT rr.id
+			FROM referral_requests rr
+			WHERE `+owned+` AND rr.created_at >= $2 AND rr.created_at < $3
+		), answered AS (
+			SELECT m.state::text AS state, m.score
+			FROM referral_matches m
+			JOIN refs ON refs.id = m.request_id
+			WHERE m.state IN ('accepted', 'declined', 'expired')
+		), ags AS (
+			SELECT a.id, a.created_at, a.effective_at
+			FROM agreements a
+			JOIN referral_requests rr ON rr.id = a.referral_id
+			WHERE `+party+` AND a.created_at >= $2 AND a.created_at < $3
+		)
+		SELECT
+			(SELECT COUNT(*) FROM refs),
+			(SELECT COUNT(*) FROM answered),
+			(SELECT COUNT(*) FROM answered WHERE state = 'accepted'),
+			(SELECT AVG(score)::float8 FROM answered WHERE state = 'accepted'),
+			(SELECT COUNT(*) FROM ags),
+			(SELECT COUNT(*) FROM ags WHERE EXISTS (SELECT 1 FROM disputes d WHERE d.agreement_id = ags.id)),
+			(SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM effective_at - created_at) / 86400)
+			 FROM ags WHERE effective_at IS NOT NULL)
+	`, arg, from, to).Scan(
+		&c.ReferralsCreated,
+		&c.MatchesAnswered,
+		&c.MatchesAccepted,
+		&c.AvgAcceptedScore,
+		&c.Agreements,
+		&c.Disputed,
+		&c.MedianDaysToEffective,
+	); err != nil {
+		return Counts{}, fmt.Errorf("report: aggregates: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,168 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"brokerflow/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	pool   *pgxpool.Pool
+	reader db.Reader
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool, reader: pool}
+}
+
+// WithReader reads a reviewee's reviews and rating summary from reader.
+func (r *PGRepository) WithReader(reader db.Reader) *PGRepository {
+	r.reader = reader
+	return r
+}
+
+// Submit inserts the review, recomputes the reviewee's aggregate and enqueues
+// review.submitted in one transaction. The parties are the referral owner and
+// the accepted candidate from the receiving broker; the deal must have a
+// DEAL_CLOSED timeline event.
+func (r *PGRepository) Submit(ctx context.Context, params SubmitParams) (Review, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("review: begin submit: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	const partiesSQL = `
+		SELECT rr.created_by_user_id::text, m.candidate_user_id::text,
+			EXISTS (SELECT 1 FROM timeline_events e WHERE e.agreement_id = a.id AND e.type = 'DEAL_CLOSED')
+		FROM agreements a
+		JOIN referral_requests rr ON rr.id = a.referral_id
+		LEFT JOIN LATERAL (
+			SELECT m.candidate_user_id
+			FROM referral_matches m
+			JOIN users u ON u.id = m.candidate_user_id
+			WHERE m.request_id = a.referral_id AND m.state = 'accepted' AND u.broker_id = a.to_broker_id
+			ORDER BY m.created_at
+			LIMIT 1
+		) m ON true
+		WHERE a.id = $1
+	`
+	var (
+		owner     string
+		candidate *string
+		closed    bool
+	)
+	if err := tx.QueryRow(ctx, partiesSQL, params.AgreementID).Scan(&owner, &candidate, &closed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Review{}, ErrAgreementMissing
+		}
+		return Review{}, fmt.Errorf("review: load parties: %w", err)
+	}
+
+	var reviewee string
+	switch {
+	case params.ReviewerID == owner && candidate == nil:
+		return Review{}, ErrNoCounterparty
+	case params.ReviewerID == owner:
+		reviewee = *candidate
+	case candidate != nil && params.ReviewerID == *candidate:
+		reviewee = owner
+	default:
+		return Review{}, ErrNotParty
+	}
+	if !closed {
+		return Review{}, ErrDealNotClosed
+	}
+
+	// Serialize reviews of the same agent so each recomputation sees the
+	// others' rows.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, reviewee); err != nil {
+		return Review{}, fmt.Errorf("review: lock reviewee: %w", err)
+	}
+
+	rev := Review{AgreementID: params.AgreementID, ReviewerID: params.ReviewerID, RevieweeID: reviewee, Rating: params.Rating, Comment: params.Comment}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO reviews (agreement_id, reviewer_id, reviewee_id, rating, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (agreement_id, reviewer_id) DO NOTHING
+		RETURNING id, created_at
+	`, rev.AgreementID, rev.ReviewerID, rev.RevieweeID, rev.Rating, rev.Comment).Scan(&rev.ID, &rev.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Review{}, ErrAlreadyReviewed
+		}
+		return Review{}, fmt.Errorf("review: insert review: %w", err)
+	}
+
+	var summary Summary
+	if err := tx.QueryRow(ctx, `
+		UPDATE users u
+		SET rating = agg.avg_rating, review_count = agg.n
+		FROM (
+			SELECT COALESCE(round(avg(rating), 2), 0) AS avg_rating, count(*)::int AS n
+			FROM reviews WHERE reviewee_id = $1
+		) agg
+		WHERE u.id = $1
+		RETURNING u.rating::float8, u.review_count
+	`, reviewee).Scan(&summary.Rating, &summary.Count); err != nil {
+		return Review{}, fmt.Errorf("review: update rating: %w", err)
+	}
+
+	body, err := json.Marshal(ReviewSubmittedPayload{
+		ReviewID:      rev.ID,
+		AgreementID:   rev.AgreementID,
+		ReviewerID:    rev.ReviewerID,
+		RevieweeID:    rev.RevieweeID,
+		Rating:        rev.Rating,
+		AverageRating: summary.Rating,
+		ReviewCount:   summary.Count,
+	})
+	if err != nil {
+		return Review{}, fmt.Errorf("review: marshal outbox payload: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1, $2::jsonb)`, OutboxTopicReviewSubmitted, body); err != nil {
+		return Review{}, fmt.Errorf("review: enqueue %s: %w", OutboxTopicReviewSubmitted, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Review{}, fmt.Errorf("review: commit submit: %w", err)
+	}
+	return rev, nil
+}
+
+func (r *PGRepository) List(ctx context.Context, revieweeID string, page, pageSize int) ([]Review, Summary, error) {
+	summary := Summary{UserID: revieweeID}
+	if err := r.reader.QueryRow(ctx, `SELECT rating::float8, review_count FROM users WHERE id = $1 AND deleted_at IS NULL`, revieweeID).
+		Scan(&summary.Rating, &summary.Count); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, Summary{}, ErrAgentNotFound
+		}
+		return nil, Summary{}, fmt.Errorf("review: load summary: %w", err)
+	}
+
+	rows, err := r.reader.Query(ctx, `
+		SELECT id, agreement_id, reviewer_id, reviewee_id, rating, comment, created_at
+		FROM reviews
+		WHERE reviewee_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, revieweeID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, Summary{}, fmt.Errorf("review: list reviews: %w", err)
+	}
+	reviews, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Review, error) {
+		var rev Review
+		err := row.Scan(&rev.ID, &rev.AgreementID, &rev.ReviewerID, &rev.RevieweeID, &rev.Rating, &rev.Comment, &rev.CreatedAt)
+		return rev, err
+	})
+	if err != nil {
+		return nil, Summary{}, fmt.Errorf("review: scan reviews: %w", err)
+	}
+	return reviews, summary, nil
+}
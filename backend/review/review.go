@@ -0,0 +1,121 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"brokerflow/auth"
+)
+
+// Rating bounds and the longest comment accepted, in characters.
+const (
+	MinRating        = 1
+	MaxRating        = 5
+	MaxCommentLength = 2000
+)
+
+var (
+	ErrInvalidRating    = fmt.Errorf("review: rating must be between %d and %d", MinRating, MaxRating)
+	ErrCommentTooLong   = fmt.Errorf("review: comment exceeds %d characters", MaxCommentLength)
+	ErrAgreementMissing = errors.New("review: agreement not found")
+	ErrNotParty         = errors.New("review: only the referral owner and the receiving agent may review")
+	ErrDealNotClosed    = errors.New("review: deal has not closed")
+	ErrNoCounterparty   = errors.New("review: agreement has no receiving agent to review")
+	ErrAlreadyReviewed  = errors.New("review: already reviewed this agreement")
+	ErrAgentNotFound    = errors.New("review: agent not found")
+)
+
+// Review is one party's rating of the other after a closed deal.
+type Review struct {
+	ID          string
+	AgreementID string
+	ReviewerID  string
+	RevieweeID  string
+	Rating      int
+	Comment     *string
+	CreatedAt   time.Time
+}
+
+// Summary is the aggregate kept on the reviewee's users row.
+type Summary struct {
+	UserID string
+	Rating float64
+	Count  int
+}
+
+type SubmitParams struct {
+	AgreementID string
+	ReviewerID  string
+	Rating      int
+	Comment     *string
+}
+
+// validate trims the comment, dropping it when blank, and checks bounds.
+func (p SubmitParams) validate() (SubmitParams, error) {
+	if p.Rating < MinRating || p.Rating > MaxRating {
+		return p, ErrInvalidRating
+	}
+	if p.Comment != nil {
+		trimmed := strings.TrimSpace(*p.Comment)
+		if utf8.RuneCountInString(trimmed) > MaxCommentLength {
+			return p, ErrCommentTooLong
+		}
+		p.Comment = nil
+		if trimmed != "" {
+			p.Comment = &trimmed
+		}
+	}
+	return p, nil
+}
+
+type Repository interface {
+	Submit(ctx context.Context, params SubmitParams) (Review, error)
+	// List returns one page of the reviews of revieweeID, newest first, with
+	// the reviewee's aggregate.
+	List(ctx context.Context, revieweeID string, page, pageSize int) ([]Review, Summary, error)
+}
+
+type Service struct {
+	repo  Repository
+	users auth.UserInvalidator
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// WithUserInvalidator is told about each reviewee whose rating changed.
+func (s *Service) WithUserInvalidator(users auth.UserInvalidator) *Service {
+	s.users = users
+	return s
+}
+
+// Submit records a review of the other party to a closed deal.
+func (s *Service) Submit(ctx context.Context, params SubmitParams) (Review, error) {
+	params, err := params.validate()
+	if err != nil {
+		return Review{}, err
+	}
+	rev, err := s.repo.Submit(ctx, params)
+	if err != nil {
+		return Review{}, err
+	}
+	if s.users != nil {
+		s.users.InvalidateUser(ctx, rev.RevieweeID)
+	}
+	return rev, nil
+}
+
+func (s *Service) List(ctx context.Context, revieweeID string, page, pageSize int) ([]Review, Summary, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.repo.List(ctx, revieweeID, page, pageSize)
+}
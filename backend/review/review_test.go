@@ -0,0 +1,90 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeRepository struct {
+	submitted      SubmitParams
+	page, pageSize int
+}
+
+func (f *fakeRepository) Submit(_ context.Context, params SubmitParams) (Review, error) {
+	f.submitted = params
+	return Review{AgreementID: params.AgreementID, RevieweeID: "agent-2", Rating: params.Rating, Comment: params.Comment}, nil
+}
+
+func (f *fakeRepository) List(_ context.Context, _ string, page, pageSize int) ([]Review, Summary, error) {
+	f.page, f.pageSize = page, pageSize
+	return nil, Summary{}, nil
+}
+
+func TestService_SubmitValidates(t *testing.T) {
+	blank := "   "
+	long := strings.Repeat("é", MaxCommentLength+1)
+	cases := []struct {
+		name   string
+		params SubmitParams
+		want   error
+	}{
+		{"zero rating", SubmitParams{Rating: 0}, ErrInvalidRating},
+		{"six stars", SubmitParams{Rating: 6}, ErrInvalidRating},
+		{"long comment", SubmitParams{Rating: 4, Comment: &long}, ErrCommentTooLong},
+		{"blank comment", SubmitParams{Rating: 5, Comment: &blank}, nil},
+	}
+	for _, tc := range cases {
+		repo := &fakeRepository{}
+		_, err := NewService(repo).Submit(context.Background(), tc.params)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+		if err == nil && repo.submitted.Comment != nil {
+			t.Errorf("%s: blank comment should be dropped, got %q", tc.name, *repo.submitted.Comment)
+		}
+	}
+}
+
+func TestService_SubmitTrimsComment(t *testing.T) {
+	comment := "  Smooth closing, great communication. "
+	repo := &fakeRepository{}
+	if _, err := NewService(repo).Submit(context.Background(), SubmitParams{AgreementID: "ag1", ReviewerID: "u1", Rating: 5, Comment: &comment}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if repo.submitted.Comment == nil || *repo.submitted.Comment != "Smooth closing, great communication." {
+		t.Fatalf("unexpected comment %v", repo.submitted.Comment)
+	}
+}
+
+func TestService_ListDefaultsPaging(t *testing.T) {
+	repo := &fakeRepository{}
+	if _, _, err := NewService(repo).List(context.Background(), "u1", -1, 0); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if repo.page != 1 || repo.pageSize != 20 {
+		t.Fatalf("expected page 1 size 20, got %d/%d", repo.page, repo.pageSize)
+	}
+}
+
+type recordingInvalidator []string
+
+func (r *recordingInvalidator) InvalidateUser(_ context.Context, userID string) {
+	*r = append(*r, userID)
+}
+
+func TestService_SubmitInvalidatesReviewee(t *testing.T) {
+	var invalidated recordingInvalidator
+	svc := NewService(&fakeRepository{}).WithUserInvalidator(&invalidated)
+	if _, err := svc.Submit(context.Background(), SubmitParams{AgreementID: "ag1", ReviewerID: "u1", Rating: 4}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if len(invalidated) != 1 || invalidated[0] != "agent-2" {
+		t.Fatalf("expected the reviewee to be invalidated, got %v", invalidated)
+	}
+
+	if _, err := svc.Submit(context.Background(), SubmitParams{Rating: 0}); err == nil || len(invalidated) != 1 {
+		t.Fatalf("expected a rejected review to invalidate nothing, got %v", invalidated)
+	}
+}
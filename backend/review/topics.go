@@ -0,0 +1,31 @@
+package review
+
+import "brokerflow/outbox"
+
+// OutboxTopicReviewSubmitted is published when a party reviews the other
+// after a closed deal.
+const OutboxTopicReviewSubmitted = "review.submitted"
+
+// ReviewSubmittedPayload is published on review.submitted. ReviewCount and
+// AverageRating are the reviewee's aggregate after this review.
+type ReviewSubmittedPayload struct {
+	ReviewID      string  `json:"review_id"`
+	AgreementID   string  `json:"agreement_id"`
+	ReviewerID    string  `json:"reviewer_id"`
+	RevieweeID    string  `json:"reviewee_id"`
+	Rating        int     `json:"rating"`
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+}
+
+// OutboxTopics declares the topics this package enqueues.
+func OutboxTopics() []outbox.Topic {
+	return []outbox.Topic{
+		{
+			Name:        OutboxTopicReviewSubmitted,
+			Producer:    "review",
+			Description: "A party reviewed the other after DEAL_CLOSED; the reviewee's rating has been updated in the same transaction.",
+			Payload:     ReviewSubmittedPayload{},
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ParseFilter reads a SCIM filter expression. Identity providers look users
+// up before creating them, so only an equality test on userName or
+// externalId is supported; attribute names and the operator are
+// case-insensitive, as RFC 7644 requires. An empty expression is the zero
+// Filter.
+func ParseFilter(expr string) (Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Filter{}, nil
+	}
+	attr, rest, ok := strings.Cut(expr, " ")
+	if !ok {
+		return Filter{}, ErrInvalidFilter
+	}
+	op, value, ok := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return Filter{}, ErrInvalidFilter
+	}
+	var s string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(value)), &s); err != nil || s == "" {
+		return Filter{}, ErrInvalidFilter
+	}
+	switch strings.ToLower(attr) {
+	case "username":
+		return Filter{UserName: strings.ToLower(s)}, nil
+	case "externalid":
+		return Filter{ExternalID: s}, nil
+	}
+	return Filter{}, ErrInvalidFilter
+}
@@ -0,0 +1,229 @@
+package scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"brokerflow/auth"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool}
+}
+
+const userColumns = `id::text, broker_id::text, email, full_name, COALESCE(external_id, ''), role,
+		deactivated_at IS NULL, created_at, updated_at`
+
+func scanUser(row pgx.Row) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.BrokerID, &u.UserName, &u.FullName, &u.ExternalID, &u.Role,
+		&u.Active, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
+
+// userError maps missing rows, malformed ids and unique violations of
+// users queries to the package errors.
+func userError(op string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			if pgErr.ConstraintName == "idx_users_broker_external_id" {
+				return ErrExternalIDTaken
+			}
+			return ErrUserNameTaken
+		case "22P02":
+			return ErrNotFound
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("scim: %s user: %w", op, err)
+}
+
+func (r *PGRepository) List(ctx context.Context, brokerID string, filter Filter, offset, limit int) ([]User, int, error) {
+	const where = `
+		WHERE broker_id = $1 AND deleted_at IS NULL
+		  AND ($2 = '' OR lower(email) = $2)
+		  AND ($3 = '' OR external_id = $3)`
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`+where,
+		brokerID, filter.UserName, filter.ExternalID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("scim: count users: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, `SELECT `+userColumns+` FROM users`+where+`
+		ORDER BY created_at, id
+		OFFSET $4 LIMIT $5
+	`, brokerID, filter.UserName, filter.ExternalID, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("scim: list users: %w", err)
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scim: scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("scim: list users: %w", err)
+	}
+	return users, total, nil
+}
+
+func (r *PGRepository) Get(ctx context.Context, brokerID, id string) (User, error) {
+	u, err := scanUser(r.pool.QueryRow(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE id = $2 AND broker_id = $1 AND deleted_at IS NULL
+	`, brokerID, id))
+	if err != nil {
+		return User{}, userError("get", err)
+	}
+	return u, nil
+}
+
+func (r *PGRepository) Create(ctx context.Context, brokerID string, params CreateParams) (User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("scim: begin create: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		existingID     string
+		existingBroker *string
+		active         bool
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT id::text, broker_id::text, deactivated_at IS NULL FROM users
+		WHERE lower(email) = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, params.UserName).Scan(&existingID, &existingBroker, &active)
+	var u User
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		u, err = scanUser(tx.QueryRow(ctx, `
+			INSERT INTO users (email, full_name, password_hash, role, broker_id, external_id, deactivated_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), CASE WHEN $7 THEN NULL ELSE get_tx_timestamp() END)
+			RETURNING `+userColumns,
+			params.UserName, params.FullName, auth.UnusablePasswordHash, string(params.Role), brokerID, params.ExternalID, params.Active))
+	case err != nil:
+		return User{}, fmt.Errorf("scim: look up user: %w", err)
+	case active || existingBroker == nil || *existingBroker != brokerID:
+		return User{}, ErrUserNameTaken
+	default:
+		// A deactivated member provisioned again, e.g. after being
+		// unassigned from the app in the identity provider and reassigned.
+		u, err = scanUser(tx.QueryRow(ctx, `
+			UPDATE users
+			SET full_name = $2, role = $3, external_id = NULLIF($4, ''),
+				deactivated_at = CASE WHEN $5 THEN NULL ELSE deactivated_at END
+			WHERE id = $1
+			RETURNING `+userColumns,
+			existingID, params.FullName, string(params.Role), params.ExternalID, params.Active))
+	}
+	if err != nil {
+		return User{}, userError("create", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, fmt.Errorf("scim: commit create: %w", err)
+	}
+	return u, nil
+}
+
+func (r *PGRepository) Update(ctx context.Context, brokerID, id string, update Update) (User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("scim: begin update: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanUser(tx.QueryRow(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE id = $2 AND broker_id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, brokerID, id))
+	if err != nil {
+		return User{}, userError("lock", err)
+	}
+	var role *string
+	if update.Role != nil {
+		s := string(*update.Role)
+		role = &s
+	}
+	after, err := scanUser(tx.QueryRow(ctx, `
+		UPDATE users
+		SET full_name = COALESCE($2, full_name),
+			external_id = CASE WHEN $3::text IS NULL THEN external_id ELSE NULLIF($3, '') END,
+			role = COALESCE($4, role),
+			deactivated_at = CASE
+				WHEN $5::boolean IS NULL THEN deactivated_at
+				WHEN $5 THEN NULL
+				ELSE COALESCE(deactivated_at, get_tx_timestamp())
+			END
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, update.FullName, update.ExternalID, role, update.Active))
+	if err != nil {
+		return User{}, userError("update", err)
+	}
+	if (before.Active && !after.Active) || before.Role != after.Role {
+		if err := revokeSessions(ctx, tx, id); err != nil {
+			return User{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, fmt.Errorf("scim: commit update: %w", err)
+	}
+	return after, nil
+}
+
+func (r *PGRepository) Remove(ctx context.Context, brokerID, id string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("scim: begin remove: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET deactivated_at = COALESCE(deactivated_at, get_tx_timestamp()),
+			broker_id = NULL, office_id = NULL, external_id = NULL
+		WHERE id = $2 AND broker_id = $1 AND deleted_at IS NULL
+	`, brokerID, id)
+	if err != nil {
+		return userError("remove", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if err := revokeSessions(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("scim: commit remove: %w", err)
+	}
+	return nil
+}
+
+func revokeSessions(ctx context.Context, tx pgx.Tx, userID string) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_sessions SET revoked_at = get_tx_timestamp()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return fmt.Errorf("scim: revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package scim lets a brokerage's identity provider manage the brokerage's
+// users: the subset of SCIM 2.0 (RFC 7644) /Users that enterprise IT tools
+// use to create accounts, change names and roles, and deactivate people who
+// leave. Every call is scoped to one brokerage.
+package scim
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"brokerflow/auth"
+)
+
+// MaxCount caps the page size of List.
+const MaxCount = 200
+
+var (
+	ErrNotFound          = errors.New("scim: user not found")
+	ErrInvalidUserName   = errors.New("scim: userName must be an email address")
+	ErrInvalidRole       = errors.New("scim: role must be agent or broker_admin")
+	ErrInvalidFilter     = errors.New("scim: only userName eq and externalId eq filters are supported")
+	ErrUserNameTaken     = errors.New("scim: a user with this userName already exists")
+	ErrExternalIDTaken   = errors.New("scim: a user with this externalId already exists")
+	ErrUserNameImmutable = errors.New("scim: userName cannot be changed")
+)
+
+// User is a member of a brokerage as the identity provider sees it.
+// UserName is the email address the member signs in with.
+type User struct {
+	ID         string
+	BrokerID   string
+	UserName   string
+	FullName   string
+	ExternalID string
+	Role       auth.Role
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// CreateParams describes a user to provision.
+type CreateParams struct {
+	UserName   string
+	FullName   string
+	ExternalID string
+	// Role defaults to agent.
+	Role   auth.Role
+	Active bool
+}
+
+// Update changes the fields that are set. UserName cannot change; it is
+// only compared with the user's, since replacing a user resends it.
+type Update struct {
+	UserName   *string
+	FullName   *string
+	ExternalID *string
+	Role       *auth.Role
+	Active     *bool
+}
+
+// Filter narrows List to the user with this userName or externalId; the
+// zero Filter lists everyone.
+type Filter struct {
+	UserName   string
+	ExternalID string
+}
+
+// Store persists brokerage members.
+type Store interface {
+	// List returns a page of brokerID's users, deactivated ones included,
+	// oldest first, and how many match in total.
+	List(ctx context.Context, brokerID string, filter Filter, offset, limit int) ([]User, int, error)
+	Get(ctx context.Context, brokerID, id string) (User, error)
+	// Create adds a user to brokerID. An address held by a deactivated user
+	// of brokerID provisions that user again; any other existing account
+	// fails with ErrUserNameTaken.
+	Create(ctx context.Context, brokerID string, params CreateParams) (User, error)
+	// Update changes one of brokerID's users. Deactivating a user, or
+	// changing its role, revokes its sessions, whose tokens carry the role.
+	Update(ctx context.Context, brokerID, id string, update Update) (User, error)
+	// Remove deactivates the user and takes it out of brokerID.
+	Remove(ctx context.Context, brokerID, id string) error
+}
+
+type Service struct {
+	store Store
+	users auth.UserInvalidator
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// WithUserInvalidator is told about each user that changed, since auth
+// caches users and must stop serving deactivated ones.
+func (s *Service) WithUserInvalidator(users auth.UserInvalidator) *Service {
+	s.users = users
+	return s
+}
+
+func (s *Service) List(ctx context.Context, brokerID string, filter Filter, offset, limit int) ([]User, int, error) {
+	if limit <= 0 || limit > MaxCount {
+		limit = MaxCount
+	}
+	return s.store.List(ctx, brokerID, filter, max(offset, 0), limit)
+}
+
+func (s *Service) Get(ctx context.Context, brokerID, id string) (User, error) {
+	return s.store.Get(ctx, brokerID, id)
+}
+
+// Create provisions a user in brokerID. Provisioned users have no password;
+// they sign in through the brokerage's single sign-on.
+func (s *Service) Create(ctx context.Context, brokerID string, params CreateParams) (User, error) {
+	userName, err := normalizeUserName(params.UserName)
+	if err != nil {
+		return User{}, err
+	}
+	params.UserName = userName
+	if params.Role == "" {
+		params.Role = auth.RoleAgent
+	}
+	if !isMemberRole(params.Role) {
+		return User{}, ErrInvalidRole
+	}
+	params.FullName = strings.TrimSpace(params.FullName)
+	if params.FullName == "" {
+		params.FullName = userName[:strings.LastIndex(userName, "@")]
+	}
+	params.ExternalID = strings.TrimSpace(params.ExternalID)
+	user, err := s.store.Create(ctx, brokerID, params)
+	if err != nil {
+		return User{}, err
+	}
+	s.invalidate(ctx, user.ID)
+	return user, nil
+}
+
+func (s *Service) Update(ctx context.Context, brokerID, id string, update Update) (User, error) {
+	if update.Role != nil && !isMemberRole(*update.Role) {
+		return User{}, ErrInvalidRole
+	}
+	if update.UserName != nil {
+		current, err := s.store.Get(ctx, brokerID, id)
+		if err != nil {
+			return User{}, err
+		}
+		if !strings.EqualFold(strings.TrimSpace(*update.UserName), current.UserName) {
+			return User{}, ErrUserNameImmutable
+		}
+	}
+	if update.FullName != nil {
+		name := strings.TrimSpace(*update.FullName)
+		if name == "" {
+			update.FullName = nil
+		} else {
+			update.FullName = &name
+		}
+	}
+	if update.ExternalID != nil {
+		externalID := strings.TrimSpace(*update.ExternalID)
+		update.ExternalID = &externalID
+	}
+	user, err := s.store.Update(ctx, brokerID, id, update)
+	if err != nil {
+		return User{}, err
+	}
+	s.invalidate(ctx, id)
+	return user, nil
+}
+
+// Delete deprovisions the user: it is deactivated and leaves the brokerage,
+// and its userName cannot be provisioned again.
+func (s *Service) Delete(ctx context.Context, brokerID, id string) error {
+	if err := s.store.Remove(ctx, brokerID, id); err != nil {
+		return err
+	}
+	s.invalidate(ctx, id)
+	return nil
+}
+
+func (s *Service) invalidate(ctx context.Context, userID string) {
+	if s.users != nil {
+		s.users.InvalidateUser(ctx, userID)
+	}
+}
+
+func isMemberRole(role auth.Role) bool {
+	return role == auth.RoleAgent || role == auth.RoleBrokerAdmin
+}
+
+// normalizeUserName trims and lower-cases a bare address.
+func normalizeUserName(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return "", ErrInvalidUserName
+	}
+	return s, nil
+}
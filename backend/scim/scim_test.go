@@ -0,0 +1,126 @@
+package scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"brokerflow/auth"
+)
+
+type memStore struct {
+	users   map[string]User
+	created CreateParams
+	updated Update
+	removed string
+}
+
+func newMemStore() *memStore {
+	return &memStore{users: map[string]User{}}
+}
+
+func (m *memStore) List(_ context.Context, brokerID string, filter Filter, offset, limit int) ([]User, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memStore) Get(_ context.Context, brokerID, id string) (User, error) {
+	u, ok := m.users[id]
+	if !ok || u.BrokerID != brokerID {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *memStore) Create(_ context.Context, brokerID string, params CreateParams) (User, error) {
+	m.created = params
+	u := User{ID: fmt.Sprintf("user-%d", len(m.users)+1), BrokerID: brokerID, UserName: params.UserName,
+		FullName: params.FullName, ExternalID: params.ExternalID, Role: params.Role, Active: params.Active}
+	m.users[u.ID] = u
+	return u, nil
+}
+
+func (m *memStore) Update(ctx context.Context, brokerID, id string, update Update) (User, error) {
+	m.updated = update
+	return m.Get(ctx, brokerID, id)
+}
+
+func (m *memStore) Remove(_ context.Context, brokerID, id string) error {
+	m.removed = id
+	return nil
+}
+
+type invalidations []string
+
+func (i *invalidations) InvalidateUser(_ context.Context, userID string) { *i = append(*i, userID) }
+
+func TestServiceCreateNormalizes(t *testing.T) {
+	store := newMemStore()
+	var invalidated invalidations
+	svc := NewService(store).WithUserInvalidator(&invalidated)
+	ctx := context.Background()
+
+	u, err := svc.Create(ctx, "broker-1", CreateParams{UserName: " Ann@Acme.com ", ExternalID: " 00u1 ", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.created.UserName != "ann@acme.com" || store.created.FullName != "ann" || store.created.Role != auth.RoleAgent || store.created.ExternalID != "00u1" {
+		t.Fatalf("unexpected params %+v", store.created)
+	}
+	if len(invalidated) != 1 || invalidated[0] != u.ID {
+		t.Fatalf("invalidated %v", invalidated)
+	}
+
+	if _, err := svc.Create(ctx, "broker-1", CreateParams{UserName: "Ann <ann@acme.com>"}); !errors.Is(err, ErrInvalidUserName) {
+		t.Fatalf("display-name address: got %v", err)
+	}
+	if _, err := svc.Create(ctx, "broker-1", CreateParams{UserName: "bo@acme.com", Role: auth.RoleClient}); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("client role: got %v", err)
+	}
+}
+
+func TestServiceUpdateRejectsUserNameChange(t *testing.T) {
+	store := newMemStore()
+	store.users["user-1"] = User{ID: "user-1", BrokerID: "broker-1", UserName: "ann@acme.com"}
+	svc := NewService(store)
+	ctx := context.Background()
+
+	same := "ANN@acme.com"
+	blank := "  "
+	if _, err := svc.Update(ctx, "broker-1", "user-1", Update{UserName: &same, FullName: &blank}); err != nil {
+		t.Fatal(err)
+	}
+	if store.updated.FullName != nil {
+		t.Fatalf("blank name should leave the name alone, got %q", *store.updated.FullName)
+	}
+	other := "bo@acme.com"
+	if _, err := svc.Update(ctx, "broker-1", "user-1", Update{UserName: &other}); !errors.Is(err, ErrUserNameImmutable) {
+		t.Fatalf("rename: got %v", err)
+	}
+	if _, err := svc.Update(ctx, "broker-2", "user-1", Update{UserName: &same}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("other broker: got %v", err)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	cases := []struct {
+		expr string
+		want Filter
+	}{
+		{``, Filter{}},
+		{`userName eq "Ann@Acme.com"`, Filter{UserName: "ann@acme.com"}},
+		{`USERNAME EQ "ann@acme.com"`, Filter{UserName: "ann@acme.com"}},
+		{`externalId eq "00u\"1"`, Filter{ExternalID: `00u"1`}},
+	}
+	for _, tc := range cases {
+		got, err := ParseFilter(tc.expr)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseFilter(%q) = %+v, %v; want %+v", tc.expr, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{`userName co "ann"`, `emails eq "ann@acme.com"`, `userName eq ann`, `userName eq ""`, `userName`} {
+		if _, err := ParseFilter(bad); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseFilter(%q) = %v, want ErrInvalidFilter", bad, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Index names, before the client's prefix.
+const (
+	IndexReferrals  = "referrals"
+	IndexAgreements = "agreements"
+)
+
+// ReferralDocument is a referral as indexed. Region keeps the stored
+// values for display; RegionCodes are the same values resolved to region
+// codes (region_codes), which subscriptions are matched against.
+// Languages are lower-cased, as subscriptions store them.
+type ReferralDocument struct {
+	ID           string    `json:"id"`
+	Status       string    `json:"status"`
+	CreatedBy    string    `json:"created_by"`
+	Region       []string  `json:"region"`
+	RegionCodes  []string  `json:"region_codes"`
+	PriceMin     int64     `json:"price_min"`
+	PriceMax     int64     `json:"price_max"`
+	Currency     string    `json:"currency"`
+	PropertyType string    `json:"property_type"`
+	DealType     string    `json:"deal_type"`
+	Languages    []string  `json:"languages"`
+	SLAHours     int       `json:"sla_hours"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AgreementDocument is an agreement as indexed.
+type AgreementDocument struct {
+	ID               string     `json:"id"`
+	ReferralID       string     `json:"referral_id"`
+	ReferrerBrokerID string     `json:"referrer_broker_id"`
+	RefereeBrokerID  string     `json:"referee_broker_id"`
+	Status           string     `json:"status"`
+	FeeRate          float64    `json:"fee_rate"`
+	ProtectDays      int        `json:"protect_days"`
+	Currency         string     `json:"currency"`
+	EffectiveAt      *time.Time `json:"effective_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func field(typ string) map[string]string { return map[string]string{"type": typ} }
+
+// Mappings are the index mappings EnsureIndexes creates, keyed by index:
+// ids and enumerations are keywords so filters match them exactly.
+var Mappings = map[string]any{
+	IndexReferrals: map[string]any{"properties": map[string]any{
+		"id":            field("keyword"),
+		"status":        field("keyword"),
+		"created_by":    field("keyword"),
+		"region":        field("keyword"),
+		"region_codes":  field("keyword"),
+		"price_min":     field("long"),
+		"price_max":     field("long"),
+		"currency":      field("keyword"),
+		"property_type": field("keyword"),
+		"deal_type":     field("keyword"),
+		"languages":     field("keyword"),
+		"sla_hours":     field("integer"),
+		"created_at":    field("date"),
+		"updated_at":    field("date"),
+	}},
+	IndexAgreements: map[string]any{"properties": map[string]any{
+		"id":                 field("keyword"),
+		"referral_id":        field("keyword"),
+		"referrer_broker_id": field("keyword"),
+		"referee_broker_id":  field("keyword"),
+		"status":             field("keyword"),
+		"fee_rate":           field("double"),
+		"protect_days":       field("integer"),
+		"currency":           field("keyword"),
+		"effective_at":       field("date"),
+		"created_at":         field("date"),
+		"updated_at":         field("date"),
+	}},
+}
+
+// EnsureIndexes creates the referral and agreement indexes that are missing.
+func EnsureIndexes(ctx context.Context, c *Client) error {
+	for _, index := range []string{IndexReferrals, IndexAgreements} {
+		if err := c.EnsureIndex(ctx, index, Mappings[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscription is what a marketplace listing query needs to know about the
+// agent: the region codes their subscription overlaps (region_expand), its
+// languages, and the referrals they already hold a live match on.
+type Subscription struct {
+	Regions   []string
+	Languages []string
+	Matched   []string
+}
+
+// Source reads the rows documents are built from. The ok results are false
+// for rows that no longer exist.
+type Source interface {
+	Referral(ctx context.Context, id string) (doc ReferralDocument, ok bool, err error)
+	Agreement(ctx context.Context, id string) (doc AgreementDocument, ok bool, err error)
+	// ReferralIDs and AgreementIDs page through every id after the given
+	// one, in id order, for Reindex.
+	ReferralIDs(ctx context.Context, after string, limit int) ([]string, error)
+	AgreementIDs(ctx context.Context, after string, limit int) ([]string, error)
+	// Subscription returns ok=false when userID is not subscribed.
+	Subscription(ctx context.Context, userID string) (sub Subscription, ok bool, err error)
+}
+
+// PGSource implements Source on the database.
+type PGSource struct {
+	pool *pgxpool.Pool
+}
+
+func NewPGSource(pool *pgxpool.Pool) *PGSource {
+	return &PGSource{pool: pool}
+}
+
+func (s *PGSource) Referral(ctx context.Context, id string) (ReferralDocument, bool, error) {
+	const query = `
+		SELECT r.id::text, r.status, r.created_by_user_id::text, r.region, region_codes(r.region),
+			r.price_min, r.price_max, r.currency, r.property_type, r.deal_type,
+			ARRAY(SELECT DISTINCT lower(l) FROM unnest(r.languages) AS l), r.sla_hours, r.created_at, r.updated_at
+		FROM referral_requests r
+		WHERE r.id = $1::uuid
+	`
+	var d ReferralDocument
+	err := s.pool.QueryRow(ctx, query, id).Scan(&d.ID, &d.Status, &d.CreatedBy, &d.Region, &d.RegionCodes,
+		&d.PriceMin, &d.PriceMax, &d.Currency, &d.PropertyType, &d.DealType, &d.Languages, &d.SLAHours, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ReferralDocument{}, false, nil
+	}
+	if err != nil {
+		return ReferralDocument{}, false, fmt.Errorf("search: load referral: %w", err)
+	}
+	return d, true, nil
+}
+
+func (s *PGSource) Agreement(ctx context.Context, id string) (AgreementDocument, bool, error) {
+	const query = `
+		SELECT a.id::text, a.referral_id::text, a.from_broker_id::text, a.to_broker_id::text, a.status::text,
+			a.fee_rate, a.protect_days, a.currency, a.effective_at, a.created_at, a.updated_at
+		FROM agreements a
+		WHERE a.id = $1::uuid
+	`
+	var d AgreementDocument
+	err := s.pool.QueryRow(ctx, query, id).Scan(&d.ID, &d.ReferralID, &d.ReferrerBrokerID, &d.RefereeBrokerID, &d.Status,
+		&d.FeeRate, &d.ProtectDays, &d.Currency, &d.EffectiveAt, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return AgreementDocument{}, false, nil
+	}
+	if err != nil {
+		return AgreementDocument{}, false, fmt.Errorf("search: load agreement: %w", err)
+	}
+	return d, true, nil
+}
+
+func (s *PGSource) ReferralIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	return s.ids(ctx, "referral_requests", after, limit)
+}
+
+func (s *PGSource) AgreementIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	return s.ids(ctx, "agreements", after, limit)
+}
+
+// ids pages through the ids of table, referral_requests or agreements.
+func (s *PGSource) ids(ctx context.Context, table, after string, limit int) ([]string, error) {
+	if after == "" {
+		after = uuid.Nil.String()
+	}
+	rows, err := s.pool.Query(ctx, `SELECT id::text FROM `+table+` WHERE id > $1::uuid ORDER BY id LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search: list %s: %w", table, err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0, limit)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("search: scan %s id: %w", table, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search: iterate %s: %w", table, err)
+	}
+	return ids, nil
+}
+
+func (s *PGSource) Subscription(ctx context.Context, userID string) (Subscription, bool, error) {
+	const query = `
+		SELECT region_expand(s.regions), s.languages,
+			ARRAY(SELECT m.request_id::text FROM referral_matches m WHERE m.candidate_user_id = s.user_id AND m.state <> 'expired')
+		FROM marketplace_subscriptions s
+		WHERE s.user_id = $1
+	`
+	var sub Subscription
+	err := s.pool.QueryRow(ctx, query, userID).Scan(&sub.Regions, &sub.Languages, &sub.Matched)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Subscription{}, false, nil
+	}
+	if err != nil {
+		return Subscription{}, false, fmt.Errorf("search: load marketplace subscription: %w", err)
+	}
+	return sub, true, nil
+}
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"brokerflow/outbox"
+)
+
+// indexedPrefixes are the topic families whose messages can change a
+// referral or agreement document: match and dispute events move the
+// referral's status along with their own.
+var indexedPrefixes = []string{"referral.", "match.", "agreement.", "dispute."}
+
+// Indexed reports whether messages on topic are indexed.
+func Indexed(topic string) bool {
+	for _, p := range indexedPrefixes {
+		if strings.HasPrefix(topic, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// reindexBatch is how many ids Reindex reads per query.
+const reindexBatch = 500
+
+// Indexer is an outbox.Handler that refreshes the documents of the
+// referral and agreement a message names. It reloads them from Source
+// rather than trusting the payload, so redelivered or reordered messages
+// converge on the current rows; rows that are gone are deleted.
+type Indexer struct {
+	client *Client
+	source Source
+}
+
+func NewIndexer(client *Client, source Source) *Indexer {
+	return &Indexer{client: client, source: source}
+}
+
+// HandleOutbox refreshes the agreement named by agreement_id, then the
+// referral named by referral_id or, failing that, the agreement's. Other
+// topics and payloads without either id are ignored.
+func (ix *Indexer) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	if !Indexed(msg.Topic) {
+		return nil
+	}
+	var ids struct {
+		ReferralID  string `json:"referral_id"`
+		AgreementID string `json:"agreement_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &ids); err != nil {
+		return nil
+	}
+	if ids.AgreementID != "" {
+		referralID, err := ix.agreement(ctx, ids.AgreementID)
+		if err != nil {
+			return err
+		}
+		if ids.ReferralID == "" {
+			ids.ReferralID = referralID
+		}
+	}
+	if ids.ReferralID != "" {
+		return ix.referral(ctx, ids.ReferralID)
+	}
+	return nil
+}
+
+// Reindex rebuilds every referral and agreement document, for a new index
+// or after OpenSearch lost messages while it was down. It returns how many
+// documents it wrote.
+func (ix *Indexer) Reindex(ctx context.Context) (int, error) {
+	n := 0
+	for _, pass := range []struct {
+		ids     func(ctx context.Context, after string, limit int) ([]string, error)
+		refresh func(ctx context.Context, id string) error
+	}{
+		{ix.source.ReferralIDs, ix.referral},
+		{ix.source.AgreementIDs, func(ctx context.Context, id string) error {
+			_, err := ix.agreement(ctx, id)
+			return err
+		}},
+	} {
+		after := ""
+		for {
+			ids, err := pass.ids(ctx, after, reindexBatch)
+			if err != nil {
+				return n, err
+			}
+			for _, id := range ids {
+				if err := pass.refresh(ctx, id); err != nil {
+					return n, err
+				}
+				n++
+			}
+			if len(ids) < reindexBatch {
+				break
+			}
+			after = ids[len(ids)-1]
+		}
+	}
+	return n, nil
+}
+
+func (ix *Indexer) referral(ctx context.Context, id string) error {
+	doc, ok, err := ix.source.Referral(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ix.client.Delete(ctx, IndexReferrals, id)
+	}
+	return ix.client.Put(ctx, IndexReferrals, id, doc)
+}
+
+// agreement refreshes the agreement's document and returns its referral id,
+// empty when the agreement is gone.
+func (ix *Indexer) agreement(ctx context.Context, id string) (string, error) {
+	doc, ok, err := ix.source.Agreement(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ix.client.Delete(ctx, IndexAgreements, id)
+	}
+	return doc.ReferralID, ix.client.Put(ctx, IndexAgreements, id, doc)
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"context"
+
+	"brokerflow/money"
+	"brokerflow/referral"
+)
+
+// Listings answers marketplace listing queries from the referral index with
+// the visibility rules of referral.PGMarketplaceRepository: open referrals
+// of other agents overlapping the subscription's regions and languages,
+// minus those the agent already holds a live match on. It implements
+// referral.ListingSearcher.
+type Listings struct {
+	client *Client
+	source Source
+}
+
+func NewListings(client *Client, source Source) *Listings {
+	return &Listings{client: client, source: source}
+}
+
+type listingHits struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Source ReferralDocument `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchListings returns one page of listings, newest first, and the total
+// across pages; agents without a subscription get
+// referral.ErrMarketplaceNotSubscribed.
+func (l *Listings) SearchListings(ctx context.Context, userID string, page, pageSize int) ([]referral.Listing, int, error) {
+	sub, ok, err := l.source.Subscription(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, referral.ErrMarketplaceNotSubscribed
+	}
+
+	var res listingHits
+	if err := l.client.Search(ctx, IndexReferrals, listingQuery(userID, sub, page, pageSize), &res); err != nil {
+		return nil, 0, err
+	}
+	listings := make([]referral.Listing, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		d := hit.Source
+		listings = append(listings, referral.Listing{
+			ID:           d.ID,
+			Region:       d.Region,
+			PriceMin:     d.PriceMin,
+			PriceMax:     d.PriceMax,
+			Currency:     money.Currency(d.Currency),
+			PropertyType: d.PropertyType,
+			DealType:     d.DealType,
+			Languages:    d.Languages,
+			SLAHours:     d.SLAHours,
+			CreatedAt:    d.CreatedAt,
+		})
+	}
+	return listings, res.Hits.Total.Value, nil
+}
+
+// listingQuery is the OpenSearch query for one page of userID's listings.
+// A referral without languages matches every subscription, as in SQL; an
+// empty array is a missing field to OpenSearch.
+func listingQuery(userID string, sub Subscription, page, pageSize int) map[string]any {
+	filter := []any{
+		map[string]any{"term": map[string]any{"status": string(referral.StatusOpen)}},
+		map[string]any{"terms": map[string]any{"region_codes": nonNil(sub.Regions)}},
+	}
+	if len(sub.Languages) > 0 {
+		filter = append(filter, map[string]any{"bool": map[string]any{
+			"should": []any{
+				map[string]any{"terms": map[string]any{"languages": sub.Languages}},
+				map[string]any{"bool": map[string]any{"must_not": map[string]any{"exists": map[string]any{"field": "languages"}}}},
+			},
+			"minimum_should_match": 1,
+		}})
+	}
+	mustNot := []any{map[string]any{"term": map[string]any{"created_by": userID}}}
+	if len(sub.Matched) > 0 {
+		mustNot = append(mustNot, map[string]any{"terms": map[string]any{"id": sub.Matched}})
+	}
+	return map[string]any{
+		"from":             (page - 1) * pageSize,
+		"size":             pageSize,
+		"track_total_hits": true,
+		"sort":             []any{map[string]any{"created_at": "desc"}, map[string]any{"id": "asc"}},
+		"query":            map[string]any{"bool": map[string]any{"filter": filter, "must_not": mustNot}},
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,221 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"brokerflow/outbox"
+	"brokerflow/referral"
+)
+
+// fakeOpenSearch records each request as "METHOD path" with its body and
+// answers searches with hits; status, when set, answers everything.
+type fakeOpenSearch struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []map[string]any
+	hits     []ReferralDocument
+	status   int
+}
+
+func (f *fakeOpenSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	var body map[string]any
+	if b, _ := io.ReadAll(r.Body); len(b) > 0 {
+		json.Unmarshal(b, &body)
+	}
+	f.bodies = append(f.bodies, body)
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/_search") {
+		hits := make([]map[string]any, 0, len(f.hits))
+		for _, d := range f.hits {
+			hits = append(hits, map[string]any{"_source": d})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"total": map[string]any{"value": len(f.hits)}, "hits": hits}})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, `{}`)
+}
+
+func newFakeOpenSearch(t *testing.T) (*fakeOpenSearch, *Client) {
+	t.Helper()
+	f := &fakeOpenSearch{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c, err := NewClient(srv.URL, "test-")
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	return f, c
+}
+
+type fakeSource struct {
+	referrals  map[string]ReferralDocument
+	agreements map[string]AgreementDocument
+	subs       map[string]Subscription
+}
+
+func (s *fakeSource) Referral(_ context.Context, id string) (ReferralDocument, bool, error) {
+	d, ok := s.referrals[id]
+	return d, ok, nil
+}
+
+func (s *fakeSource) Agreement(_ context.Context, id string) (AgreementDocument, bool, error) {
+	d, ok := s.agreements[id]
+	return d, ok, nil
+}
+
+func (s *fakeSource) ReferralIDs(_ context.Context, after string, limit int) ([]string, error) {
+	return page(after, limit, s.referrals), nil
+}
+
+func (s *fakeSource) AgreementIDs(_ context.Context, after string, limit int) ([]string, error) {
+	return page(after, limit, s.agreements), nil
+}
+
+func page[T any](after string, limit int, rows map[string]T) []string {
+	var ids []string
+	for id := range rows {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids[:min(limit, len(ids))]
+}
+
+func (s *fakeSource) Subscription(_ context.Context, userID string) (Subscription, bool, error) {
+	sub, ok := s.subs[userID]
+	return sub, ok, nil
+}
+
+func TestIndexer_RefreshesAgreementAndItsReferral(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	src := &fakeSource{
+		referrals:  map[string]ReferralDocument{"r1": {ID: "r1", Status: "matched"}},
+		agreements: map[string]AgreementDocument{"a1": {ID: "a1", ReferralID: "r1", Status: "effective"}},
+	}
+	ix := NewIndexer(c, src)
+
+	msg := outbox.Message{Topic: "agreement.status_changed", Payload: json.RawMessage(`{"agreement_id":"a1"}`)}
+	if err := ix.HandleOutbox(context.Background(), msg); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	want := []string{"PUT /test-agreements/_doc/a1", "PUT /test-referrals/_doc/r1"}
+	if !slices.Equal(f.requests, want) {
+		t.Fatalf("got %v, want %v", f.requests, want)
+	}
+	if f.bodies[1]["status"] != "matched" {
+		t.Fatalf("expected the referral as stored, got %v", f.bodies[1])
+	}
+}
+
+func TestIndexer_DeletesMissingRowsAndSkipsOtherTopics(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	ix := NewIndexer(c, &fakeSource{})
+
+	if err := ix.HandleOutbox(context.Background(), outbox.Message{Topic: "review.submitted", Payload: json.RawMessage(`{"referral_id":"r1"}`)}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if err := ix.HandleOutbox(context.Background(), outbox.Message{Topic: "referral.archived", Payload: json.RawMessage(`{"referral_id":"r1"}`)}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if want := []string{"DELETE /test-referrals/_doc/r1"}; !slices.Equal(f.requests, want) {
+		t.Fatalf("got %v, want %v", f.requests, want)
+	}
+}
+
+func TestIndexer_ReportsUnavailableIndex(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	f.status = http.StatusServiceUnavailable
+	ix := NewIndexer(c, &fakeSource{referrals: map[string]ReferralDocument{"r1": {ID: "r1"}}})
+
+	err := ix.HandleOutbox(context.Background(), outbox.Message{Topic: "referral.updated", Payload: json.RawMessage(`{"referral_id":"r1"}`)})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, so the worker retries, got %v", err)
+	}
+}
+
+func TestIndexer_ReindexPagesThroughEveryRow(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	src := &fakeSource{referrals: map[string]ReferralDocument{}, agreements: map[string]AgreementDocument{"a1": {ID: "a1"}}}
+	for i := range reindexBatch + 1 {
+		id := fmt.Sprintf("r%04d", i)
+		src.referrals[id] = ReferralDocument{ID: id}
+	}
+
+	n, err := NewIndexer(c, src).Reindex(context.Background())
+	if err != nil || n != reindexBatch+2 || len(f.requests) != n {
+		t.Fatalf("got %d documents in %d requests, %v", n, len(f.requests), err)
+	}
+}
+
+func TestListings_QueriesTheSubscription(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.hits = []ReferralDocument{{ID: "r2", Region: []string{"Austin"}, Currency: "USD", PriceMin: 100, PriceMax: 200, CreatedAt: created}}
+	src := &fakeSource{subs: map[string]Subscription{
+		"agent-1": {Regions: []string{"us-tx", "us-tx-austin"}, Languages: []string{"spanish"}, Matched: []string{"r1"}},
+	}}
+	listings := NewListings(c, src)
+
+	got, total, err := listings.SearchListings(context.Background(), "agent-1", 2, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 1 || len(got) != 1 || got[0].ID != "r2" || got[0].Currency != "USD" || !got[0].CreatedAt.Equal(created) {
+		t.Fatalf("unexpected listings %+v (total %d)", got, total)
+	}
+	if want := []string{"POST /test-referrals/_search"}; !slices.Equal(f.requests, want) {
+		t.Fatalf("got %v, want %v", f.requests, want)
+	}
+	body, _ := json.Marshal(f.bodies[0])
+	for _, fragment := range []string{`"from":10`, `"size":10`, `"created_by":"agent-1"`, `"id":["r1"]`, `"region_codes":["us-tx","us-tx-austin"]`, `"languages":["spanish"]`, `"status":"open"`} {
+		if !strings.Contains(string(body), fragment) {
+			t.Errorf("expected %s in query %s", fragment, body)
+		}
+	}
+
+	if _, _, err := listings.SearchListings(context.Background(), "agent-2", 1, 10); !errors.Is(err, referral.ErrMarketplaceNotSubscribed) {
+		t.Fatalf("expected ErrMarketplaceNotSubscribed, got %v", err)
+	}
+}
+
+func TestClient_EnsureIndexCreatesMissingIndexes(t *testing.T) {
+	f, c := newFakeOpenSearch(t)
+	f.status = http.StatusNotFound
+	// Every answer is 404, so index creation reports a failure after trying.
+	if err := EnsureIndexes(context.Background(), c); err == nil {
+		t.Fatal("expected a failed creation to be reported")
+	}
+	if want := []string{"HEAD /test-referrals", "PUT /test-referrals"}; !slices.Equal(f.requests, want) {
+		t.Fatalf("got %v, want %v", f.requests, want)
+	}
+	if _, ok := f.bodies[1]["mappings"]; !ok {
+		t.Fatalf("expected mappings in %v", f.bodies[1])
+	}
+}
+
+func TestNewClient_RejectsBadURLs(t *testing.T) {
+	for _, raw := range []string{"", "search:9200", "ftp://search:9200"} {
+		if _, err := NewClient(raw, ""); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
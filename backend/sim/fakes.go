@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"context"
+	"time"
+
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/testsupport"
+)
+
+// Intervals are how often the background jobs of Fakes run. A zero
+// interval leaves that job out.
+type Intervals struct {
+	MatchExpiry       time.Duration
+	ProtectExpiry     time.Duration
+	DisputeEscalation time.Duration
+}
+
+// DefaultIntervals are cmd/api's defaults.
+func DefaultIntervals() Intervals {
+	return Intervals{MatchExpiry: 5 * time.Minute, ProtectExpiry: 15 * time.Minute, DisputeEscalation: 5 * time.Minute}
+}
+
+// Fakes are the testsupport stores on a Sim's clock and ids, with the
+// match expiry, protect expiry and dispute escalation jobs scheduled as
+// sweeps over them. Matches is paired with referral.MatchService on the
+// same clock.
+type Fakes struct {
+	Users      *testsupport.Users
+	Referrals  *testsupport.Referrals
+	Matches    *testsupport.Matches
+	Agreements *testsupport.Agreements
+	Disputes   *testsupport.Disputes
+
+	MatchService *referral.MatchService
+}
+
+// Fakes builds the stores and schedules their jobs at iv, with sla for
+// disputes.
+func (s *Sim) Fakes(iv Intervals, sla dispute.SLA) *Fakes {
+	users := testsupport.NewUsers().WithClock(s.Clock).WithIDGenerator(s.IDs.New)
+	referrals := testsupport.NewReferrals(users).WithClock(s.Clock)
+	matches := testsupport.NewMatches(referrals, users).WithClock(s.Clock).WithIDGenerator(s.IDs.New)
+	agreements := testsupport.NewAgreements(referrals, users).WithClock(s.Clock).WithIDGenerator(s.IDs.New)
+	f := &Fakes{
+		Users:        users,
+		Referrals:    referrals,
+		Matches:      matches,
+		Agreements:   agreements,
+		Disputes:     testsupport.NewDisputes(agreements).WithClock(s.Clock).WithIDGenerator(s.IDs.New).WithSLA(sla),
+		MatchService: referral.NewMatchService(matches).WithClock(s.Clock),
+	}
+	for _, job := range []struct {
+		name     string
+		interval time.Duration
+		run      func(ctx context.Context) error
+	}{
+		{"match expiry", iv.MatchExpiry, func(ctx context.Context) error { _, err := f.Matches.ExpireDue(ctx); return err }},
+		{"protect expiry", iv.ProtectExpiry, func(ctx context.Context) error { _, err := f.Agreements.ExpireDue(ctx); return err }},
+		{"dispute escalation", iv.DisputeEscalation, func(ctx context.Context) error { _, err := f.Disputes.EscalateDue(ctx); return err }},
+	} {
+		if job.interval > 0 {
+			s.Every(job.name, job.interval, job.run)
+		}
+	}
+	return f
+}
@@ -0,0 +1,147 @@
+// Package sim runs services and background jobs on virtual time, so tests
+// can replay hours or months of SLA, protect-period and invitation expiry
+// deterministically and in milliseconds.
+//
+// A Sim owns a clock.Fake and a seeded id generator for the services under
+// test. Jobs are either sweeps the Sim calls itself at their interval
+// (Every), in registration order when several are due at once, or
+// goroutines such as a service's Run loop that wait on the Sim's clock
+// (Go). Advance moves time forward one due instant at a time and lets
+// every job catch up before going further.
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"brokerflow/clock"
+
+	"github.com/google/uuid"
+)
+
+// Sim is one simulated run.
+type Sim struct {
+	Clock *clock.Fake
+	IDs   *IDs
+
+	sweeps []*sweep
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running int
+	mu      sync.Mutex
+	errs    []error
+}
+
+type sweep struct {
+	name     string
+	interval time.Duration
+	next     time.Time
+	run      func(ctx context.Context) error
+}
+
+// New starts a simulation at start whose ids derive from seed.
+func New(start time.Time, seed int64) *Sim {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Sim{Clock: clock.NewFake(start), IDs: NewIDs(seed), ctx: ctx, cancel: cancel}
+}
+
+// Every calls run every interval of virtual time, first at Now+interval,
+// synchronously from Advance.
+func (s *Sim) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		panic("sim: non-positive interval for " + name)
+	}
+	s.sweeps = append(s.sweeps, &sweep{name: name, interval: interval, next: s.Clock.Now().Add(interval), run: run})
+}
+
+// Go runs job in its own goroutine until Close. The job must run until its
+// context is cancelled, as the services' Run loops do, and wait on the
+// Sim's clock with After or a timer, one at a time, and on nothing else:
+// tickers stay armed while the job works, so Advance could not tell when
+// it is done. Go returns once the job first waits.
+func (s *Sim) Go(name string, job func(ctx context.Context) error) {
+	s.running++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := job(s.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			s.mu.Lock()
+			s.errs = append(s.errs, fmt.Errorf("%s: %w", name, err))
+			s.mu.Unlock()
+		}
+	}()
+	s.Clock.BlockUntil(s.running)
+}
+
+// Advance moves the clock forward by d. At each instant a job is due it
+// stops, fires the goroutine jobs' timers and waits for them to wait
+// again, then runs the due sweeps. It returns the sweeps' errors; a failed
+// sweep is still rescheduled.
+func (s *Sim) Advance(d time.Duration) error {
+	target := s.Clock.Now().Add(d)
+	var errs []error
+	for {
+		next, ok := s.next()
+		if !ok || next.After(target) {
+			break
+		}
+		s.Clock.Set(next)
+		s.Clock.BlockUntil(s.running)
+		for _, sw := range s.sweeps {
+			if sw.next.After(next) {
+				continue
+			}
+			sw.next = next.Add(sw.interval)
+			if err := sw.run(s.ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s at %s: %w", sw.name, next.Format(time.RFC3339), err))
+			}
+		}
+	}
+	s.Clock.Set(target)
+	s.Clock.BlockUntil(s.running)
+	return errors.Join(errs...)
+}
+
+// next is the earliest instant a sweep or a goroutine job is due.
+func (s *Sim) next() (time.Time, bool) {
+	next, ok := s.Clock.Next()
+	for _, sw := range s.sweeps {
+		if !ok || sw.next.Before(next) {
+			next, ok = sw.next, true
+		}
+	}
+	return next, ok
+}
+
+// Close stops the goroutine jobs and returns their errors.
+func (s *Sim) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// IDs makes UUIDs from a seed: the same seed gives the same sequence.
+type IDs struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewIDs(seed int64) *IDs {
+	return &IDs{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// New returns the next id. Its signature fits the services' and fakes'
+// WithIDGenerator.
+func (g *IDs) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return uuid.Must(uuid.NewRandomFromReader(g.rnd)).String()
+}
@@ -0,0 +1,213 @@
+package sim_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/sim"
+	"brokerflow/tenancy"
+)
+
+var start = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+type deal struct {
+	owner, candidate string
+	referral         string
+	match            string
+	agreement        string
+}
+
+// seed invites a candidate to a referral and puts an agreement on it,
+// effective with protectDays.
+func seed(t *testing.T, f *sim.Fakes, protectDays int) deal {
+	t.Helper()
+	ctx := context.Background()
+	referrer, referee := "broker-referrer", "broker-referee"
+	d := deal{
+		owner:     f.Users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &referrer}).ID,
+		candidate: f.Users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &referee}).ID,
+	}
+	req, err := f.Referrals.Create(ctx, nil, referral.Request{ID: "referral-1", CreatorUserID: d.owner, Status: referral.StatusOpen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.referral = req.ID
+	match, err := f.MatchService.Create(ctx, referral.CreateMatchParams{RequestID: req.ID, OwnerUserID: d.owner, CandidateAgentID: d.candidate, Score: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.match = match.ID
+	rec, err := f.Agreements.Create(ctx, d.owner, agreement.CreateParams{
+		RequestID: req.ID, ReferrerBrokerID: referrer, RefereeBrokerID: referee, FeeRate: 25, ProtectDays: protectDays,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.agreement = rec.ID
+	for _, next := range []string{agreement.StatusPendingSignature, agreement.StatusEffective} {
+		if _, err := f.Agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, ActorID: d.owner, NextStatus: next}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+func advance(t *testing.T, s *sim.Sim, d time.Duration) {
+	t.Helper()
+	if err := s.Advance(d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtectPeriodExpiry(t *testing.T) {
+	s := sim.New(start, 1)
+	f := s.Fakes(sim.DefaultIntervals(), dispute.DefaultSLA())
+	d := seed(t, f, 30)
+
+	// the sweep runs every 15 minutes, so expiry lands on the first run at
+	// or after the lapse
+	advance(t, s, 30*24*time.Hour-time.Minute)
+	if status, _ := f.Agreements.Status(d.agreement); status != agreement.StatusEffective {
+		t.Fatalf("status a minute before the lapse = %s, want effective", status)
+	}
+	advance(t, s, time.Minute)
+	rec, _ := f.Agreements.Get(d.agreement)
+	if rec.Status != agreement.StatusExpired {
+		t.Fatalf("status at the lapse = %s, want expired", rec.Status)
+	}
+	if want := start.AddDate(0, 0, 30); !rec.StatusUpdatedAt.Equal(want) {
+		t.Fatalf("expired at %s, want %s", rec.StatusUpdatedAt, want)
+	}
+}
+
+func TestMatchExpiry(t *testing.T) {
+	s := sim.New(start, 1)
+	f := s.Fakes(sim.DefaultIntervals(), dispute.DefaultSLA())
+	d := seed(t, f, 30)
+	ttl := referral.DefaultMatchTTLHours * time.Hour
+
+	advance(t, s, ttl-time.Second)
+	if match, _ := f.Matches.GetByID(context.Background(), d.match); match.State != referral.MatchStateInvited {
+		t.Fatalf("state before the TTL = %s, want invited", match.State)
+	}
+	advance(t, s, time.Second)
+	if match, _ := f.Matches.GetByID(context.Background(), d.match); match.State != referral.MatchStateExpired {
+		t.Fatalf("state after the TTL = %s, want expired", match.State)
+	}
+}
+
+func TestDisputeEscalationThroughTiers(t *testing.T) {
+	s := sim.New(start, 1)
+	sla := dispute.DefaultSLA()
+	f := s.Fakes(sim.DefaultIntervals(), sla)
+	d := seed(t, f, 90)
+	_, err := f.Disputes.Create(context.Background(), tenancy.User(d.owner), dispute.CreateParams{
+		AgreementID: d.agreement, Reason: dispute.ReasonOther, Detail: "Opened by a simulation.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tier := func() (int, *time.Time) {
+		t.Helper()
+		recs, err := f.Disputes.List(context.Background(), tenancy.User(d.owner), d.agreement)
+		if err != nil || len(recs) != 1 {
+			t.Fatalf("list = %v, %v", recs, err)
+		}
+		return recs[0].EscalationTier, recs[0].ReviewDeadline
+	}
+
+	advance(t, s, sla.Review)
+	if got, deadline := tier(); got != 1 || deadline == nil || !deadline.Equal(start.Add(sla.Review+sla.Escalation)) {
+		t.Fatalf("after review: tier %d, deadline %v", got, deadline)
+	}
+	for want := 2; want <= sla.MaxTier; want++ {
+		advance(t, s, sla.Escalation)
+		if got, _ := tier(); got != want {
+			t.Fatalf("tier = %d, want %d", got, want)
+		}
+	}
+	if _, deadline := tier(); deadline != nil {
+		t.Fatalf("top tier still has deadline %s", deadline)
+	}
+	advance(t, s, 30*24*time.Hour)
+	if got, _ := tier(); got != sla.MaxTier {
+		t.Fatalf("escalated past the top tier to %d", got)
+	}
+}
+
+func TestSameSeedReplaysTheSameRun(t *testing.T) {
+	run := func(n int64) []string {
+		s := sim.New(start, n)
+		f := s.Fakes(sim.DefaultIntervals(), dispute.DefaultSLA())
+		d := seed(t, f, 30)
+		advance(t, s, 45*24*time.Hour)
+		rec, _ := f.Agreements.Get(d.agreement)
+		return []string{d.owner, d.candidate, d.match, d.agreement, rec.Status, rec.StatusUpdatedAt.String()}
+	}
+	first, again, other := run(7), run(7), run(8)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("replay diverged at %d: %q != %q", i, first[i], again[i])
+		}
+	}
+	if first[0] == other[0] {
+		t.Fatalf("seeds 7 and 8 made the same id %s", first[0])
+	}
+}
+
+func TestGoJobsCatchUpAtEachInstant(t *testing.T) {
+	s := sim.New(start, 1)
+	var seen []time.Time
+	s.Go("ticker", func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case now := <-s.Clock.After(time.Hour):
+				seen = append(seen, now)
+			}
+		}
+	})
+	var swept []time.Time
+	s.Every("sweep", 90*time.Minute, func(context.Context) error {
+		// the job due at the same instant has already run
+		swept = append(swept, s.Clock.Now())
+		if want := int(s.Clock.Now().Sub(start) / time.Hour); len(seen) != want {
+			return fmt.Errorf("sweep at %s saw %d job runs, want %d", s.Clock.Now(), len(seen), want)
+		}
+		return nil
+	})
+	advance(t, s, 3*time.Hour+30*time.Minute)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 3 || !seen[2].Equal(start.Add(3*time.Hour)) {
+		t.Fatalf("job woke at %v", seen)
+	}
+	if len(swept) != 2 || !swept[1].Equal(start.Add(3*time.Hour)) {
+		t.Fatalf("sweep ran at %v", swept)
+	}
+	if !s.Clock.Now().Equal(start.Add(3*time.Hour + 30*time.Minute)) {
+		t.Fatalf("clock at %s", s.Clock.Now())
+	}
+}
+
+func TestAdvanceReportsSweepErrors(t *testing.T) {
+	s := sim.New(start, 1)
+	runs := 0
+	s.Every("flaky", time.Hour, func(context.Context) error {
+		runs++
+		return errors.New("boom")
+	})
+	if err := s.Advance(2 * time.Hour); err == nil || runs != 2 {
+		t.Fatalf("err = %v after %d runs, want an error and 2 runs", err, runs)
+	}
+}
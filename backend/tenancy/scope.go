@@ -0,0 +1,98 @@
+// Package tenancy scopes list and read queries to the rows a caller may see:
+// agents see the rows they own, broker admins see every row belonging to
+// their brokerage, or to one office of it.
+package tenancy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope identifies whose rows a query may return. BrokerID is set only for
+// broker admins; when empty the scope is the single user. OfficeID narrows a
+// broker admin's scope to the users of one office.
+type Scope struct {
+	UserID   string
+	BrokerID string
+	OfficeID string
+}
+
+// User restricts queries to rows owned by userID.
+func User(userID string) Scope {
+	return Scope{UserID: userID}
+}
+
+// Broker widens queries to rows owned by any user of brokerID.
+func Broker(userID, brokerID string) Scope {
+	return Scope{UserID: userID, BrokerID: brokerID}
+}
+
+// Office narrows a broker admin's queries to rows owned by users of
+// officeID, an office of brokerID.
+func Office(userID, brokerID, officeID string) Scope {
+	return Scope{UserID: userID, BrokerID: brokerID, OfficeID: officeID}
+}
+
+// InOffice returns the broker-wide scope s narrowed to officeID. Callers
+// check that the office belongs to s.BrokerID.
+func (s Scope) InOffice(officeID string) Scope {
+	s.OfficeID = officeID
+	return s
+}
+
+// Brokerwide reports whether the scope covers the whole brokerage.
+func (s Scope) Brokerwide() bool {
+	return s.BrokerID != "" && s.OfficeID == ""
+}
+
+// Officewide reports whether the scope covers one office of a brokerage.
+func (s Scope) Officewide() bool {
+	return s.BrokerID != "" && s.OfficeID != ""
+}
+
+// OwnedBy returns a predicate restricting userColumn, a reference to
+// users.id, to the scope, together with the value to bind as $arg.
+func (s Scope) OwnedBy(userColumn string, arg int) (string, any) {
+	return s.ownedBy(userColumn, fmt.Sprintf("$%d", arg))
+}
+
+// Owns is OwnedBy for db.Query: the predicate binds its value with a ?
+// placeholder.
+func (s Scope) Owns(userColumn string) (string, any) {
+	return s.ownedBy(userColumn, "?")
+}
+
+func (s Scope) ownedBy(userColumn, placeholder string) (string, any) {
+	if s.Officewide() {
+		return userColumn + " IN (SELECT id FROM users WHERE office_id = " + placeholder + ")", s.OfficeID
+	}
+	if s.Brokerwide() {
+		return userColumn + " IN (SELECT id FROM users WHERE broker_id = " + placeholder + ")", s.BrokerID
+	}
+	return userColumn + " = " + placeholder, s.UserID
+}
+
+// PartyTo is OwnedBy for rows with broker parties, such as agreements: a
+// broker-wide scope matches rows where the brokerage is any of brokerColumns,
+// whoever created them, while user and office scopes still match on
+// ownerColumn. The counterparty side of a row has no office, so an office
+// sees the rows its own users own.
+func (s Scope) PartyTo(ownerColumn string, arg int, brokerColumns ...string) (string, any) {
+	if !s.Brokerwide() || len(brokerColumns) == 0 {
+		return s.OwnedBy(ownerColumn, arg)
+	}
+	terms := make([]string, len(brokerColumns))
+	for i, col := range brokerColumns {
+		terms[i] = fmt.Sprintf("%s = $%d", col, arg)
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", s.BrokerID
+}
+
+// Parties is PartyTo for db.Query: the predicate binds its value with a
+// single ? placeholder, matching the brokerage against brokerColumns with IN.
+func (s Scope) Parties(ownerColumn string, brokerColumns ...string) (string, any) {
+	if !s.Brokerwide() || len(brokerColumns) == 0 {
+		return s.Owns(ownerColumn)
+	}
+	return "? IN (" + strings.Join(brokerColumns, ", ") + ")", s.BrokerID
+}
@@ -0,0 +1,67 @@
+package tenancy
+
+import "testing"
+
+func TestOwnedBy(t *testing.T) {
+	clause, arg := User("u1").OwnedBy("rr.created_by_user_id", 2)
+	if clause != "rr.created_by_user_id = $2" || arg != "u1" {
+		t.Fatalf("user scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Broker("u1", "b1").OwnedBy("created_by_user_id", 1)
+	if clause != "created_by_user_id IN (SELECT id FROM users WHERE broker_id = $1)" || arg != "b1" {
+		t.Fatalf("broker scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Office("u1", "b1", "o1").OwnedBy("created_by_user_id", 1)
+	if clause != "created_by_user_id IN (SELECT id FROM users WHERE office_id = $1)" || arg != "o1" {
+		t.Fatalf("office scope: got %q %v", clause, arg)
+	}
+}
+
+func TestPartyTo(t *testing.T) {
+	clause, arg := User("u1").PartyTo("r.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+	if clause != "r.created_by_user_id = $1" || arg != "u1" {
+		t.Fatalf("user scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Broker("u1", "b1").PartyTo("r.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+	if clause != "(a.from_broker_id = $1 OR a.to_broker_id = $1)" || arg != "b1" {
+		t.Fatalf("broker scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Broker("u1", "b1").InOffice("o1").PartyTo("r.created_by_user_id", 1, "a.from_broker_id", "a.to_broker_id")
+	if clause != "r.created_by_user_id IN (SELECT id FROM users WHERE office_id = $1)" || arg != "o1" {
+		t.Fatalf("office scope: got %q %v", clause, arg)
+	}
+}
+
+func TestBrokerwide(t *testing.T) {
+	if User("u1").Brokerwide() {
+		t.Fatal("user scope should not be brokerwide")
+	}
+	if !Broker("u1", "b1").Brokerwide() {
+		t.Fatal("broker scope should be brokerwide")
+	}
+	office := Office("u1", "b1", "o1")
+	if office.Brokerwide() || !office.Officewide() {
+		t.Fatal("office scope should be officewide, not brokerwide")
+	}
+}
+
+func TestOwnsAndParties(t *testing.T) {
+	clause, arg := User("u1").Owns("created_by_user_id")
+	if clause != "created_by_user_id = ?" || arg != "u1" {
+		t.Fatalf("user scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Broker("u1", "b1").Parties("r.created_by_user_id", "a.from_broker_id", "a.to_broker_id")
+	if clause != "? IN (a.from_broker_id, a.to_broker_id)" || arg != "b1" {
+		t.Fatalf("broker scope: got %q %v", clause, arg)
+	}
+
+	clause, arg = Office("u1", "b1", "o1").Parties("r.created_by_user_id", "a.from_broker_id", "a.to_broker_id")
+	if clause != "r.created_by_user_id IN (SELECT id FROM users WHERE office_id = ?)" || arg != "o1" {
+		t.Fatalf("office scope: got %q %v", clause, arg)
+	}
+}
@@ -12,8 +12,42 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Kinds of actor that talk SQL, as scenarios name them.
+const (
+	KindCreator      = "creator"
+	KindSigner       = "signer"
+	KindPIIReader    = "pii-reader"
+	KindEventWriter  = "event-writer"
+	KindOutboxWorker = "outbox-worker"
+	KindEdgeAdapter  = "edge-adapter"
+	KindDisputer     = "disputer"
+)
+
+// Kinds lists every SQL actor kind; see HTTPKinds for the others.
+func Kinds() []string {
+	return []string{KindCreator, KindSigner, KindPIIReader, KindEventWriter, KindOutboxWorker, KindEdgeAdapter, KindDisputer}
+}
+
+// Pace bounds the pause an actor takes between iterations; each pause is
+// uniform in [Min, Max]. The zero Pace keeps the actor's own default.
+type Pace struct {
+	Min, Max time.Duration
+}
+
+func (p Pace) sleep(def Pace) {
+	if p == (Pace{}) {
+		p = def
+	}
+	d := p.Min
+	if p.Max > p.Min {
+		d += time.Duration(rand.Int63n(int64(p.Max-p.Min) + 1))
+	}
+	time.Sleep(d)
+}
+
 // Creator tries to create competing pending_signature agreements for the same referral concurrently.
-func Creator(ctx context.Context, pool *pgxpool.Pool, referralID, fromBroker, toBroker string, stop <-chan struct{}) error {
+// Inserts that reach a verdict, created or refused as a duplicate, are timed as OpCreatorInsert.
+func Creator(ctx context.Context, pool *pgxpool.Pool, referralID, fromBroker, toBroker string, lat *Latencies, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -22,16 +56,22 @@ func Creator(ctx context.Context, pool *pgxpool.Pool, referralID, fromBroker, to
 			return nil
 		default:
 		}
+		start := time.Now()
 		_, err := pool.Exec(ctx, `INSERT INTO agreements (referral_id, from_broker_id, to_broker_id, status)
                                    VALUES ($1,$2,$3,'pending_signature')`, referralID, fromBroker, toBroker)
-		if err != nil {
+		elapsed := time.Since(start)
+		if err == nil {
+			lat.Observe(OpCreatorInsert, elapsed)
+		} else {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
 				switch pgErr.Code {
 				case "23505":
 					// unique constraint under contention, ignore
-				case "57P01", "57P02", "57P03":
-					// backend terminated by chaos; brief backoff and retry
+					lat.Observe(OpCreatorInsert, elapsed)
+				case "57P01", "57P02", "57P03", "40001", "40P01":
+					// backend terminated, or serialization failure or deadlock
+					// forced by chaos; brief backoff and retry
 					time.Sleep(50 * time.Millisecond)
 					continue
 				default:
@@ -43,12 +83,13 @@ func Creator(ctx context.Context, pool *pgxpool.Pool, referralID, fromBroker, to
 				return fmt.Errorf("creator insert: %w", err)
 			}
 		}
-		time.Sleep(time.Duration(10+rand.Intn(20)) * time.Millisecond)
+		pace.sleep(Pace{Min: 10 * time.Millisecond, Max: 30 * time.Millisecond})
 	}
 }
 
 // Signer flips agreements from pending_signature to effective, idempotently.
-func Signer(ctx context.Context, pool *pgxpool.Pool, referralID string, stop <-chan struct{}) error {
+// Transactions that flip one are timed, begin to end, as OpSignerTransition.
+func Signer(ctx context.Context, pool *pgxpool.Pool, referralID string, lat *Latencies, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -57,11 +98,13 @@ func Signer(ctx context.Context, pool *pgxpool.Pool, referralID string, stop <-c
 			return nil
 		default:
 		}
+		start := time.Now()
 		tx, err := pool.Begin(ctx)
 		if err != nil {
 			return err
 		}
 		var (
+			flipped    bool
 			agID       string
 			fromBroker sql.NullString
 			toBroker   sql.NullString
@@ -69,7 +112,7 @@ func Signer(ctx context.Context, pool *pgxpool.Pool, referralID string, stop <-c
 		err = tx.QueryRow(ctx, `SELECT id, from_broker_id::text, to_broker_id::text FROM agreements WHERE referral_id=$1 AND status='pending_signature' LIMIT 1 FOR UPDATE`, referralID).
 			Scan(&agID, &fromBroker, &toBroker)
 		if err == nil {
-			_, err = tx.Exec(ctx, `UPDATE agreements SET status='effective', effective_at = COALESCE(effective_at, get_tx_timestamp()) WHERE id=$1`, agID)
+			_, err = tx.Exec(ctx, `UPDATE agreements SET status='effective', effective_at = COALESCE(effective_at, get_tx_timestamp()), referrer_signed_at = COALESCE(referrer_signed_at, get_tx_timestamp()), referee_signed_at = COALESCE(referee_signed_at, get_tx_timestamp()) WHERE id=$1`, agID)
 			if err == nil {
 				brokerID := fromBroker.String
 				if brokerID == "" {
@@ -81,15 +124,20 @@ func Signer(ctx context.Context, pool *pgxpool.Pool, referralID string, stop <-c
 				// append an ESIGN_COMPLETED timeline event
 				_, _ = tx.Exec(ctx, `INSERT INTO timeline_events (agreement_id, type, payload) VALUES ($1,'ESIGN_COMPLETED','{}'::jsonb)`, agID)
 				_, _ = tx.Exec(ctx, `INSERT INTO outbox (topic, payload) VALUES ('agreement.effective', jsonb_build_object('agreement_id',$1))`, agID)
+				flipped = true
 			}
 		}
 		_ = tx.Rollback(ctx)
-		time.Sleep(time.Duration(20+rand.Intn(40)) * time.Millisecond)
+		if flipped {
+			lat.since(OpSignerTransition, start)
+		}
+		pace.sleep(Pace{Min: 20 * time.Millisecond, Max: 60 * time.Millisecond})
 	}
 }
 
 // PIIReader invokes get_pii_contact under different timings and attempts direct SELECT to ensure RLS blocks it.
-func PIIReader(ctx context.Context, pool *pgxpool.Pool, agreementID, actorID string, stop <-chan struct{}) error {
+// Accessor calls that succeed are timed as OpPIIRead.
+func PIIReader(ctx context.Context, pool *pgxpool.Pool, agreementID, actorID string, lat *Latencies, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -101,13 +149,16 @@ func PIIReader(ctx context.Context, pool *pgxpool.Pool, agreementID, actorID str
 		// attempt direct SELECT (should fail or return zero due to RLS deny-all)
 		_, _ = pool.Exec(ctx, `SELECT * FROM pii_contacts WHERE agreement_id=$1`, agreementID)
 		// call SECURITY DEFINER accessor (may error if not yet effective)
-		_, _ = pool.Exec(ctx, `SELECT * FROM get_pii_contact($1,$2)`, agreementID, actorID)
-		time.Sleep(time.Duration(30+rand.Intn(50)) * time.Millisecond)
+		start := time.Now()
+		if _, err := pool.Exec(ctx, `SELECT * FROM get_pii_contact($1,$2)`, agreementID, actorID); err == nil {
+			lat.since(OpPIIRead, start)
+		}
+		pace.sleep(Pace{Min: 30 * time.Millisecond, Max: 80 * time.Millisecond})
 	}
 }
 
 // EventWriter appends various events including correction payload checks.
-func EventWriter(ctx context.Context, pool *pgxpool.Pool, agreementID string, stop <-chan struct{}) error {
+func EventWriter(ctx context.Context, pool *pgxpool.Pool, agreementID string, pace Pace, stop <-chan struct{}) error {
 	var (
 		fromBroker sql.NullString
 		toBroker   sql.NullString
@@ -143,12 +194,13 @@ func EventWriter(ctx context.Context, pool *pgxpool.Pool, agreementID string, st
 			continue
 		}
 		_ = tx.Commit(ctx)
-		time.Sleep(time.Duration(15+rand.Intn(35)) * time.Millisecond)
+		pace.sleep(Pace{Min: 15 * time.Millisecond, Max: 50 * time.Millisecond})
 	}
 }
 
 // OutboxWorker consumes pending outbox messages with SKIP LOCKED and marks processed or dead after retries.
-func OutboxWorker(ctx context.Context, pool *pgxpool.Pool, stop <-chan struct{}) error {
+// Batches that drained at least one message are timed, begin to commit, as OpOutboxDrain.
+func OutboxWorker(ctx context.Context, pool *pgxpool.Pool, lat *Latencies, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -157,6 +209,7 @@ func OutboxWorker(ctx context.Context, pool *pgxpool.Pool, stop <-chan struct{})
 			return nil
 		default:
 		}
+		start := time.Now()
 		tx, err := pool.Begin(ctx)
 		if err != nil {
 			return err
@@ -182,13 +235,15 @@ func OutboxWorker(ctx context.Context, pool *pgxpool.Pool, stop <-chan struct{})
 			}
 			_, _ = tx.Exec(ctx, `UPDATE outbox SET status='processed', last_attempt=get_tx_timestamp() WHERE id=$1`, id)
 		}
-		_ = tx.Commit(ctx)
-		time.Sleep(100 * time.Millisecond)
+		if err := tx.Commit(ctx); err == nil && len(ids) > 0 {
+			lat.since(OpOutboxDrain, start)
+		}
+		pace.sleep(Pace{Min: 100 * time.Millisecond, Max: 100 * time.Millisecond})
 	}
 }
 
 // EdgeAdapter registers idempotency then simulates external call; only the first registrar performs the effect.
-func EdgeAdapter(ctx context.Context, pool *pgxpool.Pool, key, route string, stop <-chan struct{}) error {
+func EdgeAdapter(ctx context.Context, pool *pgxpool.Pool, key, route string, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -202,12 +257,12 @@ func EdgeAdapter(ctx context.Context, pool *pgxpool.Pool, key, route string, sto
 			// first registrant completes
 			_, _ = pool.Exec(ctx, `UPDATE edge_invocations SET status='completed', last_attempt_at=get_tx_timestamp(), response_code=200 WHERE key=$1 AND route=$2`, key, route)
 		}
-		time.Sleep(80 * time.Millisecond)
+		pace.sleep(Pace{Min: 80 * time.Millisecond, Max: 80 * time.Millisecond})
 	}
 }
 
 // Disputer transitions disputes and checks invoice linkage via triggers.
-func Disputer(ctx context.Context, pool *pgxpool.Pool, agreementID string, stop <-chan struct{}) error {
+func Disputer(ctx context.Context, pool *pgxpool.Pool, agreementID string, pace Pace, stop <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -221,6 +276,6 @@ func Disputer(ctx context.Context, pool *pgxpool.Pool, agreementID string, stop
 		if dispID != "" {
 			_, _ = pool.Exec(ctx, `UPDATE disputes SET status='resolved' WHERE id=$1`, dispID)
 		}
-		time.Sleep(200 * time.Millisecond)
+		pace.sleep(Pace{Min: 200 * time.Millisecond, Max: 200 * time.Millisecond})
 	}
 }
@@ -0,0 +1,304 @@
+package actors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Kinds of actor that drive cmd/api over HTTP instead of talking SQL.
+const (
+	KindAPISession = "api-session"
+	KindAPIDeal    = "api-deal"
+)
+
+// HTTPKinds lists the actor kinds that need a running cmd/api.
+func HTTPKinds() []string {
+	return []string{KindAPISession, KindAPIDeal}
+}
+
+// errUnavailable marks answers an HTTP actor retries instead of failing
+// on: 5xx and transport errors, which chaos provokes by design.
+var errUnavailable = errors.New("api unavailable")
+
+// StatusError is an answer an HTTP actor did not expect: a 4xx where the
+// flow should have gone through, which points at a handler or middleware
+// regression rather than at chaos.
+type StatusError struct {
+	Method, Path string
+	Status       int
+	Body         string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected %d: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// apiPassword satisfies the default registration password policy.
+const apiPassword = "Stress-Passw0rd!"
+
+var apiUsers atomic.Int64
+
+type apiClient struct {
+	base string
+	http *http.Client
+}
+
+func newAPIClient(base string) *apiClient {
+	return &apiClient{base: base, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// call sends body as JSON with token as the bearer and decodes the answer
+// into out when its status is one of want. It returns the status and
+// response headers.
+func (c *apiClient) call(ctx context.Context, method, path, token string, body, out any, want []int, header ...string) (int, http.Header, error) {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, nil, ctx.Err()
+		}
+		return 0, nil, fmt.Errorf("%w: %s %s: %v", errUnavailable, method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if !slices.Contains(want, resp.StatusCode) {
+		err := &StatusError{Method: method, Path: path, Status: resp.StatusCode, Body: string(raw)}
+		if resp.StatusCode >= 500 {
+			return resp.StatusCode, resp.Header, fmt.Errorf("%w: %v", errUnavailable, err)
+		}
+		return resp.StatusCode, resp.Header, err
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return resp.StatusCode, resp.Header, fmt.Errorf("%s %s: decode %s: %w", method, path, raw, err)
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
+type apiUser struct {
+	ID    string `json:"id"`
+	Token string `json:"-"`
+}
+
+// signUp registers a fresh agent, attaches them to brokerID unless empty,
+// as a broker admin's onboarding would, and logs them in.
+func (c *apiClient) signUp(ctx context.Context, pool *pgxpool.Pool, brokerID string) (apiUser, error) {
+	email := fmt.Sprintf("stress-api-%d-%d@example.com", apiUsers.Add(1), rand.Int63())
+	var registered struct {
+		User apiUser `json:"user"`
+	}
+	if _, _, err := c.call(ctx, http.MethodPost, "/auth/register", "", map[string]any{
+		"email": email, "password": apiPassword, "full_name": "Stress API Agent", "role": "agent",
+	}, &registered, []int{http.StatusCreated}); err != nil {
+		return apiUser{}, err
+	}
+	if brokerID != "" {
+		if _, err := pool.Exec(ctx, `UPDATE users SET broker_id = $1 WHERE id = $2`, brokerID, registered.User.ID); err != nil {
+			return apiUser{}, fmt.Errorf("%w: attach broker: %v", errUnavailable, err)
+		}
+	}
+	var login struct {
+		Token string  `json:"token"`
+		User  apiUser `json:"user"`
+	}
+	if _, _, err := c.call(ctx, http.MethodPost, "/auth/login", "", map[string]string{"email": email, "password": apiPassword}, &login, []int{http.StatusOK}); err != nil {
+		return apiUser{}, err
+	}
+	if login.Token == "" || login.User.ID != registered.User.ID {
+		return apiUser{}, fmt.Errorf("login of %s returned token %q for user %q", email, login.Token, login.User.ID)
+	}
+	return apiUser{ID: registered.User.ID, Token: login.Token}, nil
+}
+
+// runHTTP repeats step until stop, retrying unavailable answers after a
+// short backoff and failing on anything else.
+func runHTTP(ctx context.Context, kind string, pace, def Pace, stop <-chan struct{}, step func() error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return nil
+		default:
+		}
+		if err := step(); err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case errors.Is(err, errUnavailable):
+				time.Sleep(50 * time.Millisecond)
+				continue
+			default:
+				return fmt.Errorf("%s: %w", kind, err)
+			}
+		}
+		pace.sleep(def)
+	}
+}
+
+// APISession registers, logs in and reads /api/me as a fresh user each
+// iteration, and checks that a request without a token is refused.
+func APISession(ctx context.Context, baseURL string, pace Pace, stop <-chan struct{}) error {
+	c := newAPIClient(baseURL)
+	return runHTTP(ctx, KindAPISession, pace, Pace{Min: 50 * time.Millisecond, Max: 150 * time.Millisecond}, stop, func() error {
+		user, err := c.signUp(ctx, nil, "")
+		if err != nil {
+			return err
+		}
+		var me apiUser
+		if _, _, err := c.call(ctx, http.MethodGet, "/api/me", user.Token, nil, &me, []int{http.StatusOK}); err != nil {
+			return err
+		}
+		if me.ID != user.ID {
+			return fmt.Errorf("/api/me answered user %s for %s", me.ID, user.ID)
+		}
+		_, _, err = c.call(ctx, http.MethodGet, "/api/me", "", nil, nil, []int{http.StatusUnauthorized})
+		return err
+	})
+}
+
+// APIDeal runs the referral flow through the API: an agent of fromBroker
+// creates a referral and invites two agents of toBroker, who accept at
+// once. Exactly one acceptance may create the agreement; both parties sign
+// it and the referrer moves it to success. Each iteration uses a new
+// referral.
+func APIDeal(ctx context.Context, pool *pgxpool.Pool, baseURL, fromBroker, toBroker string, pace Pace, stop <-chan struct{}) error {
+	c := newAPIClient(baseURL)
+	var (
+		referrer   apiUser
+		candidates [2]apiUser
+	)
+	return runHTTP(ctx, KindAPIDeal, pace, Pace{Min: 20 * time.Millisecond, Max: 80 * time.Millisecond}, stop, func() error {
+		// sign the users up once, retrying like any other step
+		var err error
+		if referrer.Token == "" {
+			if referrer, err = c.signUp(ctx, pool, fromBroker); err != nil {
+				return err
+			}
+		}
+		for i := range candidates {
+			if candidates[i].Token == "" {
+				if candidates[i], err = c.signUp(ctx, pool, toBroker); err != nil {
+					return err
+				}
+			}
+		}
+		return apiDeal(ctx, c, referrer, candidates)
+	})
+}
+
+func apiDeal(ctx context.Context, c *apiClient, referrer apiUser, candidates [2]apiUser) error {
+	var referral struct {
+		ID string `json:"id"`
+	}
+	// force skips the duplicate check, which would refuse the same
+	// referral twice in a row
+	if _, _, err := c.call(ctx, http.MethodPost, "/api/referrals?force=true", referrer.Token, map[string]any{
+		"region": []string{"us-tx"}, "priceMin": 300000 + rand.Intn(1000)*100, "priceMax": 900000,
+		"propertyType": "condo", "dealType": "buy", "slaHours": 48,
+	}, &referral, []int{http.StatusCreated}); err != nil {
+		return err
+	}
+
+	var invites [2]string
+	for i, cand := range candidates {
+		var match struct {
+			ID string `json:"id"`
+		}
+		if _, _, err := c.call(ctx, http.MethodPost, "/api/referrals/"+referral.ID+"/matches", referrer.Token,
+			map[string]any{"candidateAgentId": cand.ID, "score": 0.5}, &match, []int{http.StatusCreated}); err != nil {
+			return err
+		}
+		invites[i] = match.ID
+	}
+
+	// both candidates accept at once; the loser is refused with a conflict
+	// or, once the referral is matched, as an invalid transition
+	type accepted struct {
+		status    int
+		agreement string
+		err       error
+	}
+	var (
+		wg      sync.WaitGroup
+		answers [2]accepted
+	)
+	for i, cand := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var match struct {
+				Agreement *struct {
+					ID string `json:"id"`
+				} `json:"agreement"`
+			}
+			status, _, err := c.call(ctx, http.MethodPatch, "/api/referrals/"+referral.ID+"/matches/"+invites[i], cand.Token,
+				map[string]string{"state": "accepted"}, &match, []int{http.StatusOK, http.StatusBadRequest, http.StatusConflict})
+			answers[i] = accepted{status: status, err: err}
+			if status == http.StatusOK && match.Agreement != nil {
+				answers[i].agreement = match.Agreement.ID
+			}
+		}()
+	}
+	wg.Wait()
+	winner := -1
+	for i, a := range answers {
+		if a.err != nil {
+			return a.err
+		}
+		if a.agreement == "" {
+			continue
+		}
+		if winner >= 0 {
+			return fmt.Errorf("referral %s: both acceptances created an agreement (%s, %s)", referral.ID, answers[winner].agreement, a.agreement)
+		}
+		winner = i
+	}
+	if winner < 0 {
+		return fmt.Errorf("referral %s: no acceptance created an agreement (answers %d, %d)", referral.ID, answers[0].status, answers[1].status)
+	}
+	agreementID := answers[winner].agreement
+
+	var etag string
+	for _, signer := range []apiUser{referrer, candidates[winner]} {
+		_, header, err := c.call(ctx, http.MethodPost, "/api/agreements/"+agreementID+"/sign", signer.Token, nil, nil, []int{http.StatusOK})
+		if err != nil {
+			return err
+		}
+		etag = header.Get("ETag")
+	}
+	_, _, err := c.call(ctx, http.MethodPatch, "/api/agreements", referrer.Token,
+		map[string]string{"agreementId": agreementID, "nextStatus": "success"}, nil, []int{http.StatusOK}, "If-Match", etag)
+	return err
+}
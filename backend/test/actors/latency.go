@@ -0,0 +1,225 @@
+package actors
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Operations whose latency the actors record.
+const (
+	OpCreatorInsert    = "creator.insert"
+	OpSignerTransition = "signer.transition"
+	OpOutboxDrain      = "outbox.drain"
+	OpPIIRead          = "pii.read"
+)
+
+// Ops lists every recorded operation.
+func Ops() []string {
+	return []string{OpCreatorInsert, OpSignerTransition, OpOutboxDrain, OpPIIRead}
+}
+
+// subBuckets is how many linear buckets split each power of two, bounding
+// the relative error of a recorded value to 1/subBuckets, as in an HDR
+// histogram with one significant digit of precision.
+const (
+	subBucketBits = 4
+	subBuckets    = 1 << subBucketBits
+	buckets       = (64 - subBucketBits + 1) * subBuckets
+)
+
+// Histogram counts durations in log-linear buckets: exact below
+// subBuckets nanoseconds, within 1/subBuckets above, in constant memory.
+// It is safe for concurrent use.
+type Histogram struct {
+	mu       sync.Mutex
+	counts   [buckets]uint64
+	count    uint64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+func bucketOf(d time.Duration) int {
+	v := uint64(max(d, 0))
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketHigh is the largest duration counted in bucket i.
+func bucketHigh(i int) time.Duration {
+	if i < subBuckets {
+		return time.Duration(i)
+	}
+	shift := i/subBuckets - 1
+	sub := uint64(i%subBuckets + subBuckets)
+	return time.Duration((sub+1)<<shift - 1)
+}
+
+// Record counts one duration.
+func (h *Histogram) Record(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[bucketOf(d)]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	h.max = max(h.max, d)
+	h.count++
+	h.sum += d
+}
+
+// Count is how many durations were recorded.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Quantile returns the duration at or below which q of the recorded
+// durations fall, rounded up to its bucket and capped at the maximum
+// recorded; 0 when nothing was recorded.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.count) + 0.5)
+	rank = min(max(rank, 1), h.count)
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return min(bucketHigh(i), h.max)
+		}
+	}
+	return h.max
+}
+
+// Stats is a histogram's summary.
+type Stats struct {
+	Op                 string
+	Count              uint64
+	Min, Mean          time.Duration
+	P50, P90, P99, Max time.Duration
+}
+
+// Stats summarizes h as the latency of op.
+func (h *Histogram) Stats(op string) Stats {
+	s := Stats{Op: op, P50: h.Quantile(0.5), P90: h.Quantile(0.9), P99: h.Quantile(0.99)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.Count, s.Min, s.Max = h.count, h.min, h.max
+	if h.count > 0 {
+		s.Mean = h.sum / time.Duration(h.count)
+	}
+	return s
+}
+
+// Latencies holds one histogram per operation. A nil *Latencies records
+// nothing, so actors run without one.
+type Latencies struct {
+	mu  sync.Mutex
+	ops map[string]*Histogram
+}
+
+func NewLatencies() *Latencies {
+	return &Latencies{ops: map[string]*Histogram{}}
+}
+
+// Observe records that op took d.
+func (l *Latencies) Observe(op string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	h, ok := l.ops[op]
+	if !ok {
+		h = &Histogram{}
+		l.ops[op] = h
+	}
+	l.mu.Unlock()
+	h.Record(d)
+}
+
+// since observes the time elapsed since start.
+func (l *Latencies) since(op string, start time.Time) {
+	l.Observe(op, time.Since(start))
+}
+
+// Report summarizes every observed operation, in name order.
+func (l *Latencies) Report() []Stats {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report := make([]Stats, 0, len(l.ops))
+	for op, h := range l.ops {
+		report = append(report, h.Stats(op))
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Op < report[j].Op })
+	return report
+}
+
+// WriteReport writes report as an aligned table.
+func WriteReport(w io.Writer, report []Stats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\tmin\tmean\tp50\tp90\tp99\tmax\t")
+	for _, s := range report {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", s.Op, s.Count,
+			round(s.Min), round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// ParseBudgets parses p99 budgets written op=duration, comma separated,
+// such as "signer.transition=250ms,creator.insert=50ms".
+func ParseBudgets(s string) (map[string]time.Duration, error) {
+	budgets := map[string]time.Duration{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op, limit, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("latency budget %q: want op=duration", part)
+		}
+		op = strings.TrimSpace(op)
+		if !slices.Contains(Ops(), op) {
+			return nil, fmt.Errorf("latency budget %q: unknown op (want one of %s)", part, strings.Join(Ops(), ", "))
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(limit))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("latency budget %q: want a positive duration", part)
+		}
+		budgets[op] = d
+	}
+	return budgets, nil
+}
+
+// OverBudget lists the operations in report whose p99 exceeds their
+// budget. Operations without a budget, or never observed, pass.
+func OverBudget(report []Stats, budgets map[string]time.Duration) []string {
+	var over []string
+	for _, s := range report {
+		if limit, ok := budgets[s.Op]; ok && s.Count > 0 && s.P99 > limit {
+			over = append(over, fmt.Sprintf("%s p99 %s over budget %s (%d samples)", s.Op, round(s.P99), limit, s.Count))
+		}
+	}
+	return over
+}
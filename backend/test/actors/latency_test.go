@@ -0,0 +1,87 @@
+package actors
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBucketsAreContiguousAndBounded(t *testing.T) {
+	prev := -1
+	for _, d := range []time.Duration{0, 1, 15, 16, 17, 31, 32, 33, 1000, time.Millisecond, time.Second, time.Hour} {
+		i := bucketOf(d)
+		if i < prev {
+			t.Fatalf("bucket of %s went back to %d from %d", d, i, prev)
+		}
+		prev = i
+		high := bucketHigh(i)
+		if high < d || float64(high-d) > float64(d)/subBuckets {
+			t.Fatalf("%s lands in bucket %d topping out at %s, beyond 1/%d", d, i, high, subBuckets)
+		}
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h Histogram
+	var wg sync.WaitGroup
+	for i := 1; i <= 1000; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Record(time.Duration(i) * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	s := h.Stats("op")
+	if s.Count != 1000 || s.Min != time.Millisecond || s.Max != time.Second {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	for _, c := range []struct {
+		got, want time.Duration
+	}{{s.P50, 500 * time.Millisecond}, {s.P90, 900 * time.Millisecond}, {s.P99, 990 * time.Millisecond}, {s.Mean, 500500 * time.Microsecond}} {
+		if c.got < c.want || float64(c.got-c.want) > float64(c.want)/subBuckets {
+			t.Errorf("got %s, want %s within 1/%d", c.got, c.want, subBuckets)
+		}
+	}
+	var empty Histogram
+	if empty.Quantile(0.99) != 0 {
+		t.Fatal("expected 0 from an empty histogram")
+	}
+}
+
+func TestLatenciesReportAndBudgets(t *testing.T) {
+	var none *Latencies
+	none.Observe(OpSignerTransition, time.Second)
+	if none.Report() != nil {
+		t.Fatal("expected a nil Latencies to record nothing")
+	}
+
+	lat := NewLatencies()
+	for range 100 {
+		lat.Observe(OpSignerTransition, 300*time.Millisecond)
+		lat.Observe(OpCreatorInsert, 5*time.Millisecond)
+	}
+	report := lat.Report()
+	if len(report) != 2 || report[0].Op != OpCreatorInsert || report[1].Op != OpSignerTransition {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	var out strings.Builder
+	if err := WriteReport(&out, report); err != nil || !strings.Contains(out.String(), OpSignerTransition) {
+		t.Fatalf("unexpected table (%v):\n%s", err, out.String())
+	}
+
+	budgets, err := ParseBudgets(" signer.transition=250ms, creator.insert = 50ms,pii.read=1s")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	over := OverBudget(report, budgets)
+	if len(over) != 1 || !strings.HasPrefix(over[0], OpSignerTransition) {
+		t.Fatalf("expected only the signer over budget, got %v", over)
+	}
+	for _, bad := range []string{"signer.transition", "signer.commit=1s", "outbox.drain=-1s", "pii.read=soon"} {
+		if _, err := ParseBudgets(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
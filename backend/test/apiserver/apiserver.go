@@ -0,0 +1,160 @@
+// Package apiserver runs cmd/api as a child process for the stress test,
+// so HTTP actors go through the real binary: its configuration, router,
+// middleware and services, against the database under test.
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server is a running cmd/api.
+type Server struct {
+	// URL is the base URL the server listens on, without a trailing slash.
+	URL string
+
+	cmd  *exec.Cmd
+	dir  string
+	out  *syncBuffer
+	done chan struct{}
+	err  error // set before done closes
+}
+
+// Start builds cmd/api from the module at root and runs it against dsn on
+// a free local port, returning once /healthz answers. env adds variables
+// to the inherited environment, after DATABASE_URL and PORT.
+func Start(ctx context.Context, root, dsn string, env ...string) (*Server, error) {
+	dir, err := os.MkdirTemp("", "stress-api-")
+	if err != nil {
+		return nil, fmt.Errorf("apiserver: %w", err)
+	}
+	bin := filepath.Join(dir, "api")
+	build := exec.CommandContext(ctx, "go", "build", "-o", bin, "./cmd/api")
+	build.Dir = root
+	if out, err := build.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("apiserver: build cmd/api: %w\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("apiserver: %w", err)
+	}
+	s := &Server{
+		URL:  "http://127.0.0.1:" + strconv.Itoa(port),
+		dir:  dir,
+		out:  &syncBuffer{},
+		done: make(chan struct{}),
+	}
+	s.cmd = exec.Command(bin)
+	s.cmd.Env = append(append(os.Environ(), "DATABASE_URL="+dsn, "PORT="+strconv.Itoa(port)), env...)
+	s.cmd.Stdout, s.cmd.Stderr = s.out, s.out
+	if err := s.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("apiserver: start: %w", err)
+	}
+	go func() {
+		s.err = s.cmd.Wait()
+		close(s.done)
+	}()
+
+	if err := s.waitHealthy(ctx); err != nil {
+		s.Stop()
+		return nil, fmt.Errorf("apiserver: %w\n%s", err, s.Log())
+	}
+	return s, nil
+}
+
+func (s *Server) waitHealthy(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	for {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/healthz", nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-s.done:
+			return fmt.Errorf("cmd/api exited before becoming healthy: %v", s.err)
+		case <-ctx.Done():
+			return fmt.Errorf("cmd/api not healthy: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Exited reports whether the server stopped on its own, and why.
+func (s *Server) Exited() (bool, error) {
+	select {
+	case <-s.done:
+		return true, s.err
+	default:
+		return false, nil
+	}
+}
+
+// Stop interrupts the server, kills it if it has not exited after ten
+// seconds, and removes the binary.
+func (s *Server) Stop() {
+	if exited, _ := s.Exited(); !exited {
+		s.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-s.done:
+		case <-time.After(10 * time.Second):
+			s.cmd.Process.Kill()
+			<-s.done
+		}
+	}
+	os.RemoveAll(s.dir)
+}
+
+// Log returns what the server wrote to stdout and stderr so far.
+func (s *Server) Log() string {
+	return s.out.String()
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	addr, ok := l.Addr().(*net.TCPAddr)
+	if !ok {
+		return 0, errors.New("no TCP port")
+	}
+	return addr.Port, nil
+}
+
+// syncBuffer is a bytes.Buffer the server's two output streams share.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,316 @@
+package test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/test/infra"
+	"brokerflow/timeline"
+)
+
+var (
+	flBenchPoolSize  = flag.Int("bench-pool-size", 0, "benchmarks: max connections of the pool under test; 0 keeps the pgxpool default")
+	flBenchReferrals = flag.Int("bench-referrals", 5000, "benchmarks: referral requests seeded for the list benchmarks")
+	flBenchEvents    = flag.Int("bench-events", 2000, "benchmarks: timeline events seeded on the paged agreement")
+)
+
+// benchDB starts or reuses Postgres as TestACNConcurrency does, applies the
+// migrations and returns a pool of -bench-pool-size connections.
+func benchDB(b *testing.B) *pgxpool.Pool {
+	b.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var (
+		pgC    = &infra.PGContainer{}
+		dsn    string
+		shared bool
+		err    error
+	)
+	switch {
+	case *flDSN != "":
+		dsn, shared = *flDSN, true
+	case os.Getenv("STRESS_TEST_PG_DSN") != "":
+		dsn, shared = os.Getenv("STRESS_TEST_PG_DSN"), true
+	case dockerAvailable(ctx):
+		if pgC, dsn, err = infra.StartPostgres16(ctx, ""); err != nil {
+			b.Fatalf("start postgres: %v", err)
+		}
+	default:
+		if dsn, err = infra.InitLocalDatabase(ctx); err != nil {
+			b.Skipf("skipping benchmark: %v (set -dsn or STRESS_TEST_PG_DSN to force execution)", err)
+		}
+	}
+	b.Cleanup(func() { pgC.Terminate(context.Background()) })
+
+	migrated, teardown, err := infra.ApplyMigrations(ctx, dsn, shared)
+	if err != nil {
+		b.Fatalf("apply migrations: %v", err)
+	}
+	b.Cleanup(func() {
+		if err := teardown(context.Background()); err != nil {
+			b.Logf("teardown warning: %v", err)
+		}
+	})
+	b.Cleanup(migrated.Close)
+	if *flBenchPoolSize <= 0 {
+		return migrated
+	}
+
+	cfg := migrated.Config()
+	cfg.MaxConns = int32(*flBenchPoolSize)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		b.Fatalf("open pool of %d: %v", *flBenchPoolSize, err)
+	}
+	b.Cleanup(pool.Close)
+	return pool
+}
+
+// benchParties are two brokerages with an agent each: the owner refers,
+// the candidate receives.
+type benchParties struct {
+	fromBroker, toBroker string
+	owner, candidate     string
+}
+
+func seedParties(b *testing.B, ctx context.Context, pool *pgxpool.Pool) benchParties {
+	b.Helper()
+	var p benchParties
+	for _, broker := range []*string{&p.fromBroker, &p.toBroker} {
+		fein := fmt.Sprintf("%02d-%07d", rand.Intn(90)+10, rand.Intn(10_000_000))
+		if err := pool.QueryRow(ctx, `INSERT INTO brokers (name, fein, verified) VALUES ($1, $2, true) RETURNING id`,
+			fmt.Sprintf("Bench %d", rand.Int63()), fein).Scan(broker); err != nil {
+			b.Fatalf("seed broker: %v", err)
+		}
+	}
+	for _, u := range []struct {
+		id     *string
+		broker string
+	}{{&p.owner, p.fromBroker}, {&p.candidate, p.toBroker}} {
+		if err := pool.QueryRow(ctx, `INSERT INTO users (email, full_name, broker_id) VALUES ($1, 'Bench Agent', $2) RETURNING id`,
+			fmt.Sprintf("bench-%d@example.com", rand.Int63()), u.broker).Scan(u.id); err != nil {
+			b.Fatalf("seed user: %v", err)
+		}
+	}
+	return p
+}
+
+// seedInvitations creates n open referrals of p.owner, each with the
+// candidate invited, and returns the match ids.
+func seedInvitations(b *testing.B, ctx context.Context, pool *pgxpool.Pool, p benchParties, n int) []string {
+	b.Helper()
+	rows, err := pool.Query(ctx, `
+        WITH rr AS (
+            INSERT INTO referral_requests (created_by_user_id, region, price_min, price_max, property_type, deal_type, sla_hours, status)
+            SELECT $1, ARRAY['us-tx'], 100000, 200000, 'condo', 'buy', 48, 'open'
+            FROM generate_series(1, $3)
+            RETURNING id
+        )
+        INSERT INTO referral_matches (request_id, candidate_user_id, state, score, invited_at)
+        SELECT id, $2, 'invited', 0.5, now() FROM rr
+        RETURNING id::text
+    `, p.owner, p.candidate, n)
+	if err != nil {
+		b.Fatalf("seed invitations: %v", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		b.Fatalf("seed invitations: %v", err)
+	}
+	return ids
+}
+
+// BenchmarkMatchAccept measures MatchService.UpdateState accepting an
+// invitation, which marks the match accepted and creates the agreement in
+// one serializable transaction, wired as cmd/api wires it. Every iteration
+// accepts a fresh invitation; parallel runs contend for the pool.
+func BenchmarkMatchAccept(b *testing.B) {
+	pool := benchDB(b)
+	ctx := context.Background()
+	p := seedParties(b, ctx, pool)
+	svc := referral.NewMatchService(referral.NewMatchRepository(pool)).
+		WithAgreementRepository(agreement.NewRepository()).
+		WithTxRunner(db.NewUnitOfWork(pool).WithIsolation(pgx.Serializable))
+
+	accept := func(matchID string) error {
+		res, err := svc.UpdateState(ctx, referral.UpdateMatchParams{
+			MatchID: matchID, CandidateID: p.candidate, NewState: referral.MatchStateAccepted,
+		})
+		if err != nil {
+			return fmt.Errorf("accept %s: %w", matchID, err)
+		}
+		if res.Agreement == nil {
+			return fmt.Errorf("accept %s: no agreement", matchID)
+		}
+		return nil
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		ids := seedInvitations(b, ctx, pool, p, b.N)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := accept(ids[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		ids := make(chan string, b.N)
+		for _, id := range seedInvitations(b, ctx, pool, p, b.N) {
+			ids <- id
+		}
+		close(ids)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			// Fatal may only be called from the benchmark goroutine
+			for pb.Next() {
+				if err := accept(<-ids); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkReferralList measures the referral list query under the filter
+// combinations the API offers, over -bench-referrals requests spread across
+// regions, deal types and statuses and split between two agents of one
+// brokerage.
+func BenchmarkReferralList(b *testing.B) {
+	pool := benchDB(b)
+	ctx := context.Background()
+	p := seedParties(b, ctx, pool)
+	var colleague string
+	if err := pool.QueryRow(ctx, `INSERT INTO users (email, full_name, broker_id) VALUES ($1, 'Bench Colleague', $2) RETURNING id`,
+		fmt.Sprintf("bench-%d@example.com", rand.Int63()), p.fromBroker).Scan(&colleague); err != nil {
+		b.Fatalf("seed user: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `
+        INSERT INTO referral_requests (created_by_user_id, region, price_min, price_max, property_type, deal_type, sla_hours, status, archived_at)
+        SELECT CASE WHEN i % 2 = 0 THEN $1::uuid ELSE $2::uuid END,
+               ARRAY[(ARRAY['us-tx', 'us-ca', 'us-ny', 'us-fl'])[i % 4 + 1]],
+               100000 + i * 10, 900000 + i * 10, 'condo',
+               (ARRAY['buy', 'sell'])[i / 2 % 2 + 1], 48,
+               (ARRAY['open', 'open', 'matched', 'closed', 'cancelled'])[i % 5 + 1],
+               CASE WHEN i % 10 = 0 THEN now() END
+        FROM generate_series(1, $3) AS i
+    `, p.owner, colleague, *flBenchReferrals); err != nil {
+		b.Fatalf("seed referrals: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE referral_requests`); err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+
+	svc := referral.NewService(pool, referral.NewRepository(pool), nil, referral.PGOutboxWriter{})
+	own := tenancy.User(p.owner)
+	brokerwide := tenancy.Broker(p.owner, p.fromBroker)
+	for _, bc := range []struct {
+		name    string
+		filters referral.Filters
+	}{
+		{"own", referral.Filters{Scope: own}},
+		{"brokerwide", referral.Filters{Scope: brokerwide}},
+		{"status", referral.Filters{Scope: own, Status: referral.StatusOpen}},
+		{"region", referral.Filters{Scope: own, Region: "us-ca"}},
+		{"region+dealType+status", referral.Filters{Scope: brokerwide, Region: "us-tx", DealType: "buy", Status: referral.StatusOpen}},
+		{"archived", referral.Filters{Scope: brokerwide, IncludeArchived: true}},
+		{"sortPrice", referral.Filters{Scope: brokerwide, SortKey: "priceMin", SortOrder: "desc"}},
+		{"deepPage", referral.Filters{Scope: brokerwide, Page: 50, PageSize: 20}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.List(ctx, bc.filters); err != nil {
+					b.Fatalf("list: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTimelinePage measures paging an agreement's timeline with
+// -bench-events events, by page number as GET /api/timeline does and by
+// cursor as the event stream does.
+func BenchmarkTimelinePage(b *testing.B) {
+	pool := benchDB(b)
+	ctx := context.Background()
+	p := seedParties(b, ctx, pool)
+	var agreementID string
+	if err := pool.QueryRow(ctx, `
+        WITH rr AS (
+            INSERT INTO referral_requests (created_by_user_id, region, price_min, price_max, property_type, deal_type, sla_hours, status)
+            VALUES ($1, ARRAY['us-tx'], 100000, 200000, 'condo', 'buy', 48, 'matched')
+            RETURNING id
+        )
+        INSERT INTO agreements (referral_id, from_broker_id, to_broker_id, fee_rate, protect_days, status, effective_at)
+        SELECT id, $2, $3, 30, 90, 'effective', now() FROM rr
+        RETURNING id
+    `, p.owner, p.fromBroker, p.toBroker).Scan(&agreementID); err != nil {
+		b.Fatalf("seed agreement: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `
+        INSERT INTO timeline_events (agreement_id, seq, type, payload, actor_id, actor_broker_id, ts)
+        SELECT $1, i,
+               (ARRAY[$4, $5, $6])[i % 3 + 1]::event_type,
+               jsonb_build_object('n', i), $2, $3,
+               now() - make_interval(mins => $7 - i)
+        FROM generate_series(1, $7) AS i
+    `, agreementID, p.owner, p.fromBroker,
+		timeline.TypeAgreementStatusChanged, timeline.TypeOfferMade, timeline.TypeAgreementSigned, *flBenchEvents); err != nil {
+		b.Fatalf("seed timeline: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE timeline_events`); err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+
+	repo := timeline.NewRepository(pool)
+	scope := tenancy.User(p.owner)
+	lastPage := max(1, *flBenchEvents/100)
+	for _, bc := range []struct {
+		name    string
+		filters timeline.ListFilters
+	}{
+		{"first", timeline.ListFilters{Scope: scope, AgreementID: agreementID, PageSize: 100}},
+		{"middle", timeline.ListFilters{Scope: scope, AgreementID: agreementID, Page: max(1, lastPage/2), PageSize: 100}},
+		{"last", timeline.ListFilters{Scope: scope, AgreementID: agreementID, Page: lastPage, PageSize: 100}},
+		{"types", timeline.ListFilters{Scope: scope, AgreementID: agreementID, Types: []string{timeline.TypeOfferMade}, PageSize: 100}},
+		{"since", timeline.ListFilters{Scope: scope, AgreementID: agreementID, Since: time.Now().Add(-time.Hour), PageSize: 100}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := repo.List(ctx, bc.filters); err != nil {
+					b.Fatalf("list: %v", err)
+				}
+			}
+		})
+	}
+	b.Run("cursor", func(b *testing.B) {
+		// one iteration reads the whole timeline a page at a time
+		for i := 0; i < b.N; i++ {
+			var after int64
+			for {
+				events, err := repo.ListAfter(ctx, agreementID, after, 100)
+				if err != nil {
+					b.Fatalf("list after %d: %v", after, err)
+				}
+				if len(events) == 0 {
+					break
+				}
+				after = events[len(events)-1].ID
+			}
+		}
+	})
+}
@@ -2,12 +2,54 @@ package chaos
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Mode names a failure the stress run injects.
+type Mode string
+
+const (
+	ModeTerminate     Mode = "terminate"     // TerminateRandomBackend
+	ModeLatency       Mode = "latency"       // Latency
+	ModeSerialization Mode = "serialization" // SerializationFailures
+	ModeExhaustPool   Mode = "exhaust-pool"  // ExhaustPool
+	ModePause         Mode = "pause"         // PauseDatabase
+)
+
+// Modes lists every mode; "all" selects them.
+func Modes() []Mode {
+	return []Mode{ModeTerminate, ModeLatency, ModeSerialization, ModeExhaustPool, ModePause}
+}
+
+// ParseModes reads a comma-separated list of modes; "all" selects every
+// mode and an empty list none.
+func ParseModes(s string) (map[Mode]bool, error) {
+	selected := map[Mode]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "all":
+			for _, m := range Modes() {
+				selected[m] = true
+			}
+		default:
+			m := Mode(name)
+			if !slices.Contains(Modes(), m) {
+				return nil, fmt.Errorf("chaos: unknown mode %q", name)
+			}
+			selected[m] = true
+		}
+	}
+	return selected, nil
+}
+
 // Randomly terminates a backend connection belonging to our test application.
 func TerminateRandomBackend(ctx context.Context, pool *pgxpool.Pool, appLike string, stop <-chan struct{}) {
 	ticker := time.NewTicker(2 * time.Second)
@@ -0,0 +1,39 @@
+package chaos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModes(t *testing.T) {
+	modes, err := ParseModes(" latency,serialization ,")
+	if err != nil || len(modes) != 2 || !modes[ModeLatency] || !modes[ModeSerialization] {
+		t.Fatalf("expected latency and serialization, got %v, %v", modes, err)
+	}
+	if modes, err := ParseModes("all"); err != nil || len(modes) != len(Modes()) {
+		t.Fatalf("expected every mode, got %v, %v", modes, err)
+	}
+	if modes, err := ParseModes(""); err != nil || len(modes) != 0 {
+		t.Fatalf("expected no modes, got %v, %v", modes, err)
+	}
+	if _, err := ParseModes("terminate,flood"); err == nil {
+		t.Fatal("expected an unknown mode to be refused")
+	}
+}
+
+func TestFaultSQL_QuotesAndRemoves(t *testing.T) {
+	sql := faultSQL("chaos_latency", []string{"agreements", `odd"name`}, "PERFORM 1;")
+	for _, want := range []string{
+		`CREATE OR REPLACE FUNCTION "chaos_latency"() RETURNS trigger`,
+		`CREATE TRIGGER "chaos_latency" BEFORE INSERT OR UPDATE OR DELETE ON "agreements" FOR EACH STATEMENT`,
+		`ON "odd""name"`,
+		"PERFORM 1;",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in:\n%s", want, sql)
+		}
+	}
+	if got := dropFaultSQL("chaos_latency"); got != `DROP FUNCTION IF EXISTS "chaos_latency"() CASCADE` {
+		t.Fatalf("unexpected drop: %s", got)
+	}
+}
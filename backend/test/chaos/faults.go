@@ -0,0 +1,93 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultTables are the tables the stress actors write concurrently.
+var DefaultTables = []string{"agreements", "timeline_events", "outbox"}
+
+// Latency slows writes server-side: a statement-level trigger on each table
+// runs pg_sleep before INSERT, UPDATE and DELETE, so the writing
+// transaction holds its locks and connection for longer, the way a slow
+// disk or a busy primary would.
+type Latency struct {
+	Tables []string
+	// Probability is the chance that a statement is delayed.
+	Probability float64
+	// Max bounds the delay; each delayed statement sleeps a uniform random
+	// duration up to it.
+	Max time.Duration
+}
+
+// DefaultLatency delays a fifth of writes by up to 200ms.
+func DefaultLatency() Latency {
+	return Latency{Tables: DefaultTables, Probability: 0.2, Max: 200 * time.Millisecond}
+}
+
+// Install creates the trigger and returns a function removing it again.
+func (l Latency) Install(ctx context.Context, pool *pgxpool.Pool) (func(context.Context) error, error) {
+	body := fmt.Sprintf(`IF random() < %g THEN PERFORM pg_sleep(random() * %g); END IF;`, l.Probability, l.Max.Seconds())
+	return installFault(ctx, pool, "chaos_latency", l.Tables, body)
+}
+
+// SerializationFailures makes writes fail with SQLSTATE 40001, as
+// serializable transactions do under contention, so callers' retry paths
+// run even when the actors never actually conflict.
+type SerializationFailures struct {
+	Tables []string
+	// Rate is the chance that a statement fails.
+	Rate float64
+}
+
+// DefaultSerializationFailures fails one write in twenty.
+func DefaultSerializationFailures() SerializationFailures {
+	return SerializationFailures{Tables: DefaultTables, Rate: 0.05}
+}
+
+// Install creates the trigger and returns a function removing it again.
+func (s SerializationFailures) Install(ctx context.Context, pool *pgxpool.Pool) (func(context.Context) error, error) {
+	body := fmt.Sprintf(`IF random() < %g THEN
+        RAISE EXCEPTION 'chaos: could not serialize access due to concurrent update' USING ERRCODE = '40001';
+    END IF;`, s.Rate)
+	return installFault(ctx, pool, "chaos_serialization_failure", s.Tables, body)
+}
+
+// installFault creates the trigger function name running body and attaches
+// it to tables before every write statement. The returned function drops
+// the function and, with it, its triggers.
+func installFault(ctx context.Context, pool *pgxpool.Pool, name string, tables []string, body string) (func(context.Context) error, error) {
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("chaos: %s: no tables", name)
+	}
+	remove := func(ctx context.Context) error {
+		_, err := pool.Exec(ctx, dropFaultSQL(name))
+		return err
+	}
+	if _, err := pool.Exec(ctx, faultSQL(name, tables, body)); err != nil {
+		return nil, fmt.Errorf("chaos: install %s: %w", name, err)
+	}
+	return remove, nil
+}
+
+func faultSQL(name string, tables []string, body string) string {
+	fn := pgx.Identifier{name}.Sanitize()
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE OR REPLACE FUNCTION %s() RETURNS trigger LANGUAGE plpgsql AS $chaos$\nBEGIN\n    %s\n    RETURN NULL;\nEND\n$chaos$;\n", fn, body)
+	for _, table := range tables {
+		t := pgx.Identifier{table}.Sanitize()
+		fmt.Fprintf(&b, "DROP TRIGGER IF EXISTS %s ON %s;\n", fn, t)
+		fmt.Fprintf(&b, "CREATE TRIGGER %s BEFORE INSERT OR UPDATE OR DELETE ON %s FOR EACH STATEMENT EXECUTE FUNCTION %s();\n", fn, t, fn)
+	}
+	return b.String()
+}
+
+func dropFaultSQL(name string) string {
+	return fmt.Sprintf("DROP FUNCTION IF EXISTS %s() CASCADE", pgx.Identifier{name}.Sanitize())
+}
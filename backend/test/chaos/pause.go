@@ -0,0 +1,85 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os/exec"
+	"time"
+)
+
+// Pauser freezes and thaws the database server. While it is paused,
+// connections stay open but nothing answers, as in a network partition or
+// a failover that has not been detected yet.
+type Pauser interface {
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+}
+
+// DockerContainer pauses a container with docker pause and unpause.
+type DockerContainer string
+
+func (c DockerContainer) Pause(ctx context.Context) error {
+	return docker(ctx, "pause", string(c))
+}
+
+func (c DockerContainer) Resume(ctx context.Context) error {
+	return docker(ctx, "unpause", string(c))
+}
+
+func docker(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker %s: %w: %s", args[0], err, out)
+	}
+	return nil
+}
+
+// Pause configures PauseDatabase.
+type Pause struct {
+	// Every is how often a pause may start; Probability is the chance it does.
+	Every       time.Duration
+	Probability float64
+	// For bounds how long a pause lasts; each lasts a uniform random
+	// duration up to it.
+	For time.Duration
+}
+
+// DefaultPause freezes the database for up to 5s, about every 30s.
+func DefaultPause() Pause {
+	return Pause{Every: 15 * time.Second, Probability: 0.5, For: 5 * time.Second}
+}
+
+// PauseDatabase pauses and resumes p until ctx is done or stop is closed.
+// It always resumes before returning, so the oracles and teardown that run
+// afterwards reach the database.
+func PauseDatabase(ctx context.Context, p Pauser, cfg Pause, stop <-chan struct{}) {
+	ticker := time.NewTicker(cfg.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+			if rand.Float64() >= cfg.Probability {
+				continue
+			}
+			if err := p.Pause(ctx); err != nil {
+				log.Printf("chaos: pause: %v", err)
+				continue
+			}
+			select {
+			case <-ctx.Done():
+			case <-stop:
+			case <-time.After(time.Duration(rand.Int63n(int64(cfg.For)) + 1)):
+			}
+			// ctx may be done already; resuming must not depend on it.
+			if err := p.Resume(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("chaos: resume: %v", err)
+			}
+		}
+	}
+}
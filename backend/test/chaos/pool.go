@@ -0,0 +1,69 @@
+package chaos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolExhaustion periodically checks out connections and holds them busy
+// in pg_sleep, so actors queue for the pool and back-pressure paths run.
+type PoolExhaustion struct {
+	// Every is the pause between bursts.
+	Every time.Duration
+	// Hold is how long each burst keeps its connections.
+	Hold time.Duration
+	// Conns is how many connections a burst takes; zero means the pool's
+	// maximum, leaving the actors none until it ends.
+	Conns int
+}
+
+// DefaultPoolExhaustion drains the whole pool for 3s every 10s.
+func DefaultPoolExhaustion() PoolExhaustion {
+	return PoolExhaustion{Every: 10 * time.Second, Hold: 3 * time.Second}
+}
+
+// ExhaustPool runs bursts until ctx is done or stop is closed. A burst
+// takes the connections that free up within Hold and releases them all
+// when Hold has passed.
+func ExhaustPool(ctx context.Context, pool *pgxpool.Pool, cfg PoolExhaustion, stop <-chan struct{}) {
+	ticker := time.NewTicker(cfg.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+			burst(ctx, pool, cfg)
+		}
+	}
+}
+
+func burst(ctx context.Context, pool *pgxpool.Pool, cfg PoolExhaustion) {
+	n := cfg.Conns
+	if n <= 0 {
+		n = int(pool.Config().MaxConns)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Hold)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := pool.Acquire(ctx)
+			if err != nil {
+				return
+			}
+			defer conn.Release()
+			// Sleep server-side as well, so the backend shows as active;
+			// the deadline cancels the statement when Hold is over.
+			_, _ = conn.Exec(ctx, `SELECT pg_sleep($1)`, cfg.Hold.Seconds())
+		}()
+	}
+	wg.Wait()
+}
@@ -24,7 +24,7 @@ func All() []Oracle {
 			Name: "O2_temporal_order",
 			SQL: `SELECT e.* FROM timeline_events e
                   JOIN agreements a ON a.id = e.agreement_id
-                  WHERE e.type IN ('OFFER_MADE','ESIGN_COMPLETED','DEAL_CLOSED')
+                  WHERE e.type IN ('OFFER_MADE','UNDER_CONTRACT','ESIGN_COMPLETED','DEAL_CLOSED')
                   AND (a.status NOT IN ('effective','success','disputed') OR e.ts < a.effective_at)`,
 		},
 		{
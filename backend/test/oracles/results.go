@@ -0,0 +1,208 @@
+package oracles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Result is one oracle's outcome at one tick.
+type Result struct {
+	Oracle string `json:"oracle"`
+	// Violations counts the rows the oracle returned.
+	Violations int64 `json:"violations"`
+	// Sample is the first violating row, if any.
+	Sample   string        `json:"sample,omitempty"`
+	Duration time.Duration `json:"durationNs"`
+	// Error is set when the oracle query itself failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Tick is every oracle's result at one point of a run.
+type Tick struct {
+	RunID   string    `json:"runId"`
+	At      time.Time `json:"at"`
+	Results []Result  `json:"results"`
+}
+
+// Failed lists the oracles with violations or errors at t.
+func (t Tick) Failed() []Result {
+	var failed []Result
+	for _, r := range t.Results {
+		if r.Violations > 0 || r.Error != "" {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Evaluate runs every oracle, unlike Run not stopping at the first
+// failure, and counts the violating rows of each. A failing query is
+// reported in its Result; only a cancelled ctx ends Evaluate early.
+func Evaluate(ctx context.Context, pool *pgxpool.Pool, runID string) (Tick, error) {
+	tick := Tick{RunID: runID, At: time.Now().UTC()}
+	for _, o := range All() {
+		res := Result{Oracle: o.Name}
+		start := time.Now()
+		err := evaluate(ctx, pool, o, &res)
+		res.Duration = time.Since(start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Tick{}, ctx.Err()
+			}
+			res.Error = err.Error()
+		}
+		tick.Results = append(tick.Results, res)
+	}
+	return tick, nil
+}
+
+func evaluate(ctx context.Context, pool *pgxpool.Pool, o Oracle, res *Result) error {
+	rows, err := pool.Query(ctx, o.SQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if res.Violations == 0 {
+			vals, err := rows.Values()
+			if err != nil {
+				return err
+			}
+			res.Sample = fmt.Sprintf("%v", vals)
+		}
+		res.Violations++
+	}
+	return rows.Err()
+}
+
+// Recorder stores ticks as a run goes.
+type Recorder interface {
+	Record(ctx context.Context, t Tick) error
+}
+
+// JSONLines writes each tick as one JSON line, for CI to keep as an
+// artifact and graph.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+func (j *JSONLines) Record(_ context.Context, t Tick) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(b, '\n'))
+	return err
+}
+
+// Table stores ticks in the oracle_results table of the database under
+// test, one row per oracle and tick, which it creates if missing. The
+// table is test tooling and not part of the migrations.
+type Table struct {
+	pool *pgxpool.Pool
+}
+
+const createResultsTable = `
+CREATE TABLE IF NOT EXISTS oracle_results (
+    run_id      text        NOT NULL,
+    tick_at     timestamptz NOT NULL,
+    oracle      text        NOT NULL,
+    violations  bigint      NOT NULL,
+    sample      text,
+    duration_ms double precision NOT NULL,
+    error       text,
+    PRIMARY KEY (run_id, tick_at, oracle)
+)`
+
+// NewTable makes sure oracle_results exists.
+func NewTable(ctx context.Context, pool *pgxpool.Pool) (*Table, error) {
+	if _, err := pool.Exec(ctx, createResultsTable); err != nil {
+		return nil, fmt.Errorf("oracles: create oracle_results: %w", err)
+	}
+	return &Table{pool: pool}, nil
+}
+
+func (tb *Table) Record(ctx context.Context, t Tick) error {
+	var b pgx.Batch
+	for _, r := range t.Results {
+		b.Queue(`INSERT INTO oracle_results (run_id, tick_at, oracle, violations, sample, duration_ms, error)
+                 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''))`,
+			t.RunID, t.At, r.Oracle, r.Violations, r.Sample, float64(r.Duration)/float64(time.Millisecond), r.Error)
+	}
+	if err := tb.pool.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("oracles: record tick: %w", err)
+	}
+	return nil
+}
+
+// Summary aggregates a run's ticks per oracle.
+type Summary struct {
+	Ticks   int
+	Oracles []OracleSummary
+}
+
+// OracleSummary is one oracle over a run.
+type OracleSummary struct {
+	Oracle string
+	// FailingTicks counts ticks with violations or an error.
+	FailingTicks int
+	// MaxViolations is the most violating rows seen at one tick.
+	MaxViolations int64
+	// FirstFailure is when the oracle first failed; zero if it never did.
+	FirstFailure time.Time
+	MaxDuration  time.Duration
+}
+
+// Summarize folds ticks into a Summary, oracles in name order.
+func Summarize(ticks []Tick) Summary {
+	by := map[string]*OracleSummary{}
+	for _, t := range ticks {
+		for _, r := range t.Results {
+			s, ok := by[r.Oracle]
+			if !ok {
+				s = &OracleSummary{Oracle: r.Oracle}
+				by[r.Oracle] = s
+			}
+			if r.Violations > 0 || r.Error != "" {
+				s.FailingTicks++
+				if s.FirstFailure.IsZero() {
+					s.FirstFailure = t.At
+				}
+			}
+			s.MaxViolations = max(s.MaxViolations, r.Violations)
+			s.MaxDuration = max(s.MaxDuration, r.Duration)
+		}
+	}
+	sum := Summary{Ticks: len(ticks)}
+	for _, s := range by {
+		sum.Oracles = append(sum.Oracles, *s)
+	}
+	sort.Slice(sum.Oracles, func(i, j int) bool { return sum.Oracles[i].Oracle < sum.Oracles[j].Oracle })
+	return sum
+}
+
+// Failed lists the oracles that failed at least once.
+func (s Summary) Failed() []OracleSummary {
+	var failed []OracleSummary
+	for _, o := range s.Oracles {
+		if o.FailingTicks > 0 {
+			failed = append(failed, o)
+		}
+	}
+	return failed
+}
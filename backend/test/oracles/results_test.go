@@ -0,0 +1,67 @@
+package oracles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTickFailed(t *testing.T) {
+	tick := Tick{Results: []Result{
+		{Oracle: "O1"},
+		{Oracle: "O2", Violations: 3, Sample: "[a b]"},
+		{Oracle: "O3", Error: "timeout"},
+	}}
+	failed := tick.Failed()
+	if len(failed) != 2 || failed[0].Oracle != "O2" || failed[1].Oracle != "O3" {
+		t.Fatalf("unexpected failures: %+v", failed)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ticks := []Tick{
+		{At: start, Results: []Result{{Oracle: "O2", Duration: time.Millisecond}, {Oracle: "O1", Duration: 5 * time.Millisecond}}},
+		{At: start.Add(2 * time.Second), Results: []Result{{Oracle: "O2", Violations: 4}, {Oracle: "O1"}}},
+		{At: start.Add(4 * time.Second), Results: []Result{{Oracle: "O2", Violations: 1}, {Oracle: "O1"}}},
+	}
+	sum := Summarize(ticks)
+	if sum.Ticks != 3 || len(sum.Oracles) != 2 || sum.Oracles[0].Oracle != "O1" {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if o := sum.Oracles[0]; o.FailingTicks != 0 || !o.FirstFailure.IsZero() || o.MaxDuration != 5*time.Millisecond {
+		t.Fatalf("unexpected O1 summary: %+v", o)
+	}
+	failed := sum.Failed()
+	if len(failed) != 1 {
+		t.Fatalf("expected only O2 to fail, got %+v", failed)
+	}
+	if o := failed[0]; o.Oracle != "O2" || o.FailingTicks != 2 || o.MaxViolations != 4 || !o.FirstFailure.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("unexpected O2 summary: %+v", o)
+	}
+}
+
+func TestJSONLinesWritesOneTickPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewJSONLines(&buf)
+	for i := range 2 {
+		tick := Tick{RunID: "run", At: time.Unix(int64(i), 0).UTC(), Results: []Result{{Oracle: "O1", Violations: int64(i), Duration: time.Second}}}
+		if err := rec.Record(context.Background(), tick); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var got Tick
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.RunID != "run" || len(got.Results) != 1 || got.Results[0].Violations != 1 || got.Results[0].Duration != time.Second {
+		t.Fatalf("unexpected tick: %+v", got)
+	}
+}
@@ -0,0 +1,215 @@
+package property
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/db"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database is a System over the services cmd/api runs, on a migrated
+// database. Each Database seeds its own brokers, users and referrals, and
+// its snapshots cover only those, so runs can share a database.
+type Database struct {
+	pool       *pgxpool.Pool
+	crud       *agreement.CRUDService
+	status     *agreement.StatusService
+	signatures *agreement.SignatureService
+	matches    *referral.MatchService
+	disputes   *dispute.Service
+
+	referrerBroker, refereeBroker string
+	owner, candidate              string
+	referrals                     [Referrals]string
+	invitations                   [Referrals]string
+}
+
+// NewDatabase seeds an owner with Referrals open referrals, each inviting
+// the same candidate from another brokerage. Close removes the rows again.
+func NewDatabase(ctx context.Context, pool *pgxpool.Pool) (*Database, error) {
+	d := &Database{
+		pool:       pool,
+		crud:       agreement.NewCRUDService(pool),
+		status:     agreement.NewStatusService(pool),
+		signatures: agreement.NewSignatureService(pool),
+		matches: referral.NewMatchService(referral.NewMatchRepository(pool)).
+			WithAgreementRepository(agreement.NewRepository()).
+			WithTxRunner(db.NewUnitOfWork(pool).WithIsolation(pgx.Serializable)),
+		disputes: dispute.NewService(dispute.NewRepository(pool)),
+	}
+	stamp := time.Now().UnixNano()
+	insert := func(dst *string, query string, args ...any) error {
+		if err := pool.QueryRow(ctx, query, args...).Scan(dst); err != nil {
+			return fmt.Errorf("property: seed: %w", err)
+		}
+		return nil
+	}
+	for _, b := range []struct {
+		dst    *string
+		name   string
+		prefix int
+	}{{&d.referrerBroker, "Referrer", 55}, {&d.refereeBroker, "Referee", 66}} {
+		if err := insert(b.dst, `INSERT INTO brokers (name, fein, verified) VALUES ($1, $2, true) RETURNING id`,
+			fmt.Sprintf("Property %s %d", b.name, stamp), fmt.Sprintf("%d-%07d", b.prefix, stamp%10000000)); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range []struct {
+		dst      *string
+		name     string
+		brokerID string
+	}{{&d.owner, "owner", d.referrerBroker}, {&d.candidate, "candidate", d.refereeBroker}} {
+		if err := insert(u.dst, `INSERT INTO users (email, full_name, broker_id) VALUES ($1, $2, $3) RETURNING id`,
+			fmt.Sprintf("property-%s+%d@example.com", u.name, stamp), "Property "+u.name, u.brokerID); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.referrals {
+		if err := insert(&d.referrals[i], `
+        INSERT INTO referral_requests (created_by_user_id, region, price_min, price_max, property_type, deal_type, languages, sla_hours, status)
+        VALUES ($1, ARRAY['us-ea'], 200000, 300000, 'condo', 'buy', ARRAY['English'], 48, 'open')
+        RETURNING id`, d.owner); err != nil {
+			return nil, err
+		}
+		if err := insert(&d.invitations[i], `
+        INSERT INTO referral_matches (request_id, candidate_user_id, state, score)
+        VALUES ($1, $2, 'invited', 0.5)
+        RETURNING id`, d.referrals[i], d.candidate); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Close deletes what the run wrote, best effort: rows the schema guards
+// against deletion stay behind.
+func (d *Database) Close(ctx context.Context) {
+	referrals := d.referrals[:]
+	d.pool.Exec(ctx, `DELETE FROM disputes WHERE agreement_id IN (SELECT id FROM agreements WHERE referral_id = ANY($1::uuid[]))`, referrals)
+	d.pool.Exec(ctx, `DELETE FROM agreements WHERE referral_id = ANY($1::uuid[])`, referrals)
+	d.pool.Exec(ctx, `DELETE FROM referral_matches WHERE request_id = ANY($1::uuid[])`, referrals)
+	d.pool.Exec(ctx, `DELETE FROM referral_requests WHERE id = ANY($1::uuid[])`, referrals)
+}
+
+func (d *Database) CreateAgreement(ctx context.Context, i int) (string, error) {
+	rec, err := d.crud.Create(ctx, d.owner, agreement.CreateParams{
+		RequestID:        d.referrals[i],
+		ReferrerBrokerID: d.referrerBroker,
+		RefereeBrokerID:  d.refereeBroker,
+		FeeRate:          25,
+		ProtectDays:      90,
+	})
+	return rec.ID, err
+}
+
+func (d *Database) Transition(ctx context.Context, agreementID, next string) error {
+	_, err := d.status.Transition(ctx, agreement.TransitionParams{AgreementID: agreementID, ActorID: d.owner, NextStatus: next})
+	return err
+}
+
+func (d *Database) Sign(ctx context.Context, agreementID string, referee bool) error {
+	signer := d.owner
+	if referee {
+		signer = d.candidate
+	}
+	_, err := d.signatures.Sign(ctx, agreementID, signer)
+	return err
+}
+
+func (d *Database) UpdateMatch(ctx context.Context, i int, state referral.MatchState) error {
+	_, err := d.matches.UpdateState(ctx, matchUpdate(d.invitations[i], d.candidate, state))
+	return err
+}
+
+func (d *Database) OpenDispute(ctx context.Context, agreementID string, referee bool) (string, error) {
+	opener := d.owner
+	if referee {
+		opener = d.candidate
+	}
+	rec, err := d.disputes.Create(ctx, tenancy.User(opener), disputeParams(agreementID))
+	return rec.ID, err
+}
+
+func (d *Database) ResolveDispute(ctx context.Context, disputeID string) error {
+	_, err := d.disputes.Resolve(ctx, d.owner, disputeID)
+	return err
+}
+
+func (d *Database) Snapshot(ctx context.Context) (Snapshot, error) {
+	s := Snapshot{Agreements: map[string]Agreement{}, Events: map[string][]Event{}, Disputes: map[string]dispute.Status{}}
+	referrals := d.referrals[:]
+
+	rows, err := d.pool.Query(ctx, `
+        SELECT id::text, referral_id::text, status::text, version, effective_at
+        FROM agreements WHERE referral_id = ANY($1::uuid[])`, referrals)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("property: snapshot agreements: %w", err)
+	}
+	for rows.Next() {
+		var (
+			id string
+			ag Agreement
+		)
+		if err := rows.Scan(&id, &ag.RequestID, &ag.Status, &ag.Version, &ag.EffectiveAt); err != nil {
+			rows.Close()
+			return Snapshot{}, fmt.Errorf("property: snapshot agreements: %w", err)
+		}
+		s.Agreements[id] = ag
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("property: snapshot agreements: %w", err)
+	}
+
+	rows, err = d.pool.Query(ctx, `
+        SELECT e.agreement_id::text, e.seq, e.type, e.ts
+        FROM timeline_events e JOIN agreements a ON a.id = e.agreement_id
+        WHERE a.referral_id = ANY($1::uuid[])
+        ORDER BY e.agreement_id, e.seq`, referrals)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("property: snapshot timeline: %w", err)
+	}
+	for rows.Next() {
+		var (
+			id string
+			e  Event
+		)
+		if err := rows.Scan(&id, &e.Seq, &e.Type, &e.TS); err != nil {
+			rows.Close()
+			return Snapshot{}, fmt.Errorf("property: snapshot timeline: %w", err)
+		}
+		s.Events[id] = append(s.Events[id], e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("property: snapshot timeline: %w", err)
+	}
+
+	rows, err = d.pool.Query(ctx, `
+        SELECT d.id::text, d.status::text
+        FROM disputes d JOIN agreements a ON a.id = d.agreement_id
+        WHERE a.referral_id = ANY($1::uuid[])`, referrals)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("property: snapshot disputes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id     string
+			status dispute.Status
+		)
+		if err := rows.Scan(&id, &status); err != nil {
+			return Snapshot{}, fmt.Errorf("property: snapshot disputes: %w", err)
+		}
+		s.Disputes[id] = status
+	}
+	return s, rows.Err()
+}
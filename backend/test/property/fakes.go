@@ -0,0 +1,144 @@
+package property
+
+import (
+	"context"
+	"fmt"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+	"brokerflow/testsupport"
+)
+
+// Fakes is a System over the testsupport in-memory stores and the real
+// match and dispute services. The fakes keep no timeline and need no
+// signatures, so Sign does nothing and Snapshot has no Events.
+type Fakes struct {
+	agreements *testsupport.Agreements
+	disputes   *dispute.Service
+	store      *testsupport.Disputes
+	matches    *referral.MatchService
+
+	owner, candidate string
+	referrals        [Referrals]string
+	invitations      [Referrals]string
+}
+
+const (
+	fakeReferrerBroker = "broker-referrer"
+	fakeRefereeBroker  = "broker-referee"
+)
+
+// NewFakes seeds an owner with Referrals open referrals, each inviting the
+// same candidate from another brokerage.
+func NewFakes(ctx context.Context) (*Fakes, error) {
+	users := testsupport.NewUsers()
+	referrerBroker, refereeBroker := fakeReferrerBroker, fakeRefereeBroker
+	owner := users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &referrerBroker})
+	candidate := users.Add(auth.User{Role: auth.RoleAgent, BrokerID: &refereeBroker})
+
+	referrals := testsupport.NewReferrals(users)
+	agreements := testsupport.NewAgreements(referrals, users)
+	store := testsupport.NewDisputes(agreements)
+	matchRepo := testsupport.NewMatches(referrals, users)
+	f := &Fakes{
+		agreements: agreements,
+		disputes:   dispute.NewService(store),
+		store:      store,
+		matches:    referral.NewMatchService(matchRepo),
+		owner:      owner.ID,
+		candidate:  candidate.ID,
+	}
+	for i := range f.referrals {
+		req, err := referrals.Create(ctx, nil, referral.Request{
+			ID:            fmt.Sprintf("referral-%d", i),
+			CreatorUserID: owner.ID,
+			Status:        referral.StatusOpen,
+		})
+		if err != nil {
+			return nil, err
+		}
+		match, err := matchRepo.Create(ctx, referral.CreateMatchParams{
+			RequestID:        req.ID,
+			OwnerUserID:      owner.ID,
+			CandidateAgentID: candidate.ID,
+			Score:            0.5,
+		})
+		if err != nil {
+			return nil, err
+		}
+		f.referrals[i], f.invitations[i] = req.ID, match.ID
+	}
+	return f, nil
+}
+
+func (f *Fakes) CreateAgreement(ctx context.Context, i int) (string, error) {
+	rec, err := f.agreements.Create(ctx, f.owner, agreement.CreateParams{
+		RequestID:        f.referrals[i],
+		ReferrerBrokerID: fakeReferrerBroker,
+		RefereeBrokerID:  fakeRefereeBroker,
+		FeeRate:          25,
+		ProtectDays:      90,
+	})
+	return rec.ID, err
+}
+
+func (f *Fakes) Transition(ctx context.Context, agreementID, next string) error {
+	_, err := f.agreements.Transition(ctx, agreement.TransitionParams{AgreementID: agreementID, ActorID: f.owner, NextStatus: next})
+	return err
+}
+
+func (f *Fakes) Sign(context.Context, string, bool) error { return nil }
+
+func (f *Fakes) UpdateMatch(ctx context.Context, i int, state referral.MatchState) error {
+	_, err := f.matches.UpdateState(ctx, matchUpdate(f.invitations[i], f.candidate, state))
+	return err
+}
+
+func (f *Fakes) OpenDispute(ctx context.Context, agreementID string, referee bool) (string, error) {
+	opener := f.owner
+	if referee {
+		opener = f.candidate
+	}
+	rec, err := f.disputes.Create(ctx, tenancy.User(opener), disputeParams(agreementID))
+	return rec.ID, err
+}
+
+func (f *Fakes) ResolveDispute(ctx context.Context, disputeID string) error {
+	_, err := f.disputes.Resolve(ctx, f.owner, disputeID)
+	return err
+}
+
+func (f *Fakes) Snapshot(ctx context.Context) (Snapshot, error) {
+	s := Snapshot{Agreements: map[string]Agreement{}, Disputes: map[string]dispute.Status{}}
+	recs, _, err := f.agreements.List(ctx, agreement.ListFilters{Scope: tenancy.User(f.owner), PageSize: 100})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, rec := range recs {
+		s.Agreements[rec.ID] = Agreement{RequestID: rec.RequestID, Status: rec.Status, Version: rec.Version, EffectiveAt: rec.EffectiveAt}
+	}
+	disputes, err := f.store.List(ctx, tenancy.User(f.owner), "")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, d := range disputes {
+		s.Disputes[d.ID] = d.Status
+	}
+	return s, nil
+}
+
+// matchUpdate answers an invitation, with counter terms when countering.
+func matchUpdate(matchID, candidateID string, state referral.MatchState) referral.UpdateMatchParams {
+	params := referral.UpdateMatchParams{MatchID: matchID, CandidateID: candidateID, NewState: state}
+	if state == referral.MatchStateCountered {
+		params.Counter = referral.CounterTerms{FeeRate: 20, ProtectDays: 60}
+	}
+	return params
+}
+
+func disputeParams(agreementID string) dispute.CreateParams {
+	return dispute.CreateParams{AgreementID: agreementID, Reason: dispute.ReasonOther, Detail: "Opened by a property test."}
+}
@@ -0,0 +1,330 @@
+// Package property runs random sequences of agreement transitions,
+// signatures, match updates and dispute actions against a System and
+// checks, after every step, invariants equivalent to the stress oracles
+// O1–O3 (see test/oracles): one live agreement per referral, deal events
+// only while an agreement is in effect, and an append-only timeline.
+//
+// Sequences come from testing/quick; a failure logs the sequence that
+// broke an invariant and the op it broke at.
+package property
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+)
+
+// Referrals is how many referrals a System seeds; ops pick one by index.
+const Referrals = 2
+
+// System is what a sequence runs against: the testsupport fakes or the
+// database-backed services. Referrals are addressed by index, agreements
+// and disputes by the ids the System returned.
+type System interface {
+	// CreateAgreement has the referral's owner create an agreement with the
+	// candidate's broker as referee.
+	CreateAgreement(ctx context.Context, referral int) (string, error)
+	Transition(ctx context.Context, agreementID, next string) error
+	// Sign signs for the referrer, or for the referee when referee is set.
+	Sign(ctx context.Context, agreementID string, referee bool) error
+	// UpdateMatch answers the candidate's invitation on the referral.
+	UpdateMatch(ctx context.Context, referral int, state referral.MatchState) error
+	// OpenDispute opens a dispute as the owner, or as the candidate when
+	// referee is set.
+	OpenDispute(ctx context.Context, agreementID string, referee bool) (string, error)
+	ResolveDispute(ctx context.Context, disputeID string) error
+	Snapshot(ctx context.Context) (Snapshot, error)
+}
+
+// Snapshot is the state the invariants are checked on.
+type Snapshot struct {
+	Agreements map[string]Agreement
+	// Events holds each agreement's timeline in seq order; Systems without
+	// a timeline leave it nil.
+	Events   map[string][]Event
+	Disputes map[string]dispute.Status
+}
+
+// Agreement is the part of an agreement the invariants look at.
+type Agreement struct {
+	RequestID   string
+	Status      string
+	Version     int
+	EffectiveAt *time.Time
+}
+
+// Event is a timeline event of an agreement.
+type Event struct {
+	Seq  int64
+	Type string
+	TS   time.Time
+}
+
+// OpKind names an action of a sequence.
+type OpKind int
+
+const (
+	OpCreate OpKind = iota
+	OpTransition
+	OpSign
+	OpMatch
+	OpDispute
+	OpResolve
+)
+
+func (k OpKind) String() string {
+	return [...]string{"create", "transition", "sign", "match", "dispute", "resolve"}[k]
+}
+
+// Op is one action. Target picks the referral, agreement or dispute by
+// index, modulo how many exist when it runs.
+type Op struct {
+	Kind   OpKind
+	Target int
+	// Status is the next status of OpTransition.
+	Status string
+	// State is the answer of OpMatch.
+	State referral.MatchState
+	// Referee flips OpSign and OpDispute to the referee's side.
+	Referee bool
+}
+
+func (o Op) String() string {
+	switch o.Kind {
+	case OpTransition:
+		return fmt.Sprintf("transition(#%d -> %s)", o.Target, o.Status)
+	case OpMatch:
+		return fmt.Sprintf("match(referral %d -> %s)", o.Target, o.State)
+	case OpSign, OpDispute:
+		return fmt.Sprintf("%s(#%d, referee=%t)", o.Kind, o.Target, o.Referee)
+	}
+	return fmt.Sprintf("%s(#%d)", o.Kind, o.Target)
+}
+
+// Ops is a generated sequence.
+type Ops []Op
+
+func (ops Ops) String() string {
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		parts[i] = op.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// statuses are the transition targets generated: every status of the
+// machine, so invalid transitions are exercised as much as valid ones.
+var statuses = []string{
+	agreement.StatusDraft, agreement.StatusPendingSignature, agreement.StatusEffective,
+	agreement.StatusSuccess, agreement.StatusDisputed, agreement.StatusVoid,
+	agreement.StatusClosed, agreement.StatusExpired,
+}
+
+// kinds weights the generated ops towards transitions, which most
+// sequences need several of to get past pending_signature.
+var kinds = []OpKind{OpCreate, OpTransition, OpTransition, OpTransition, OpSign, OpSign, OpMatch, OpDispute, OpResolve}
+
+var matchAnswers = []referral.MatchState{referral.MatchStateAccepted, referral.MatchStateDeclined, referral.MatchStateCountered}
+
+// Generate implements quick.Generator with sequences of up to size ops.
+func (Ops) Generate(r *rand.Rand, size int) reflect.Value {
+	ops := make(Ops, r.Intn(size+1))
+	for i := range ops {
+		ops[i] = Op{
+			Kind:    kinds[r.Intn(len(kinds))],
+			Target:  r.Intn(4),
+			Status:  statuses[r.Intn(len(statuses))],
+			State:   matchAnswers[r.Intn(len(matchAnswers))],
+			Referee: r.Intn(2) == 0,
+		}
+	}
+	return reflect.ValueOf(ops)
+}
+
+// refusals are the errors a System may answer an op with: the domain
+// saying no. Anything else fails the run.
+var refusals = []error{
+	agreement.ErrInvalidTransition,
+	agreement.ErrActiveAgreementExists,
+	agreement.ErrSignaturesMissing,
+	agreement.ErrNotSignable,
+	agreement.ErrAlreadySigned,
+	agreement.ErrSignatureOutOfOrder,
+	referral.ErrMatchInvalidTransition,
+	referral.ErrMatchExpired,
+	referral.ErrMatchDuplicate,
+	dispute.ErrBadStatus,
+	dispute.ErrForbidden,
+}
+
+func refused(err error) bool {
+	return slices.ContainsFunc(refusals, func(target error) bool { return errors.Is(err, target) })
+}
+
+// Run applies ops to sys in order and checks the invariants after each
+// one. It returns the first invariant broken, or an error an op should not
+// have produced, naming the op.
+func Run(ctx context.Context, sys System, ops Ops) error {
+	machine := agreement.DefaultStateMachine()
+	prev, err := sys.Snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("initial snapshot: %w", err)
+	}
+	if err := Check(machine, Snapshot{}, prev); err != nil {
+		return fmt.Errorf("initial state: %w", err)
+	}
+	var agreements, disputes []string
+	for i, op := range ops {
+		if err := apply(ctx, sys, op, &agreements, &disputes); err != nil && !refused(err) {
+			return fmt.Errorf("op %d %s: %w", i, op, err)
+		}
+		next, err := sys.Snapshot(ctx)
+		if err != nil {
+			return fmt.Errorf("op %d %s: snapshot: %w", i, op, err)
+		}
+		if err := Check(machine, prev, next); err != nil {
+			return fmt.Errorf("after op %d %s: %w", i, op, err)
+		}
+		// Agreements created as a side effect, by accepting a match, are
+		// targets too. At most one appears per op.
+		for id := range next.Agreements {
+			if !slices.Contains(agreements, id) {
+				agreements = append(agreements, id)
+			}
+		}
+		prev = next
+	}
+	return nil
+}
+
+func apply(ctx context.Context, sys System, op Op, agreements, disputes *[]string) error {
+	pick := func(ids []string) (string, bool) {
+		if len(ids) == 0 {
+			return "", false
+		}
+		return ids[op.Target%len(ids)], true
+	}
+	switch op.Kind {
+	case OpCreate:
+		id, err := sys.CreateAgreement(ctx, op.Target%Referrals)
+		if err == nil {
+			*agreements = append(*agreements, id)
+		}
+		return err
+	case OpMatch:
+		return sys.UpdateMatch(ctx, op.Target%Referrals, op.State)
+	case OpResolve:
+		if id, ok := pick(*disputes); ok {
+			return sys.ResolveDispute(ctx, id)
+		}
+		return nil
+	}
+	id, ok := pick(*agreements)
+	if !ok {
+		return nil
+	}
+	switch op.Kind {
+	case OpTransition:
+		return sys.Transition(ctx, id, op.Status)
+	case OpSign:
+		return sys.Sign(ctx, id, op.Referee)
+	case OpDispute:
+		disputeID, err := sys.OpenDispute(ctx, id, op.Referee)
+		if err == nil {
+			*disputes = append(*disputes, disputeID)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown op %d", op.Kind)
+}
+
+// liveStatuses may hold a referral, at most one agreement at a time (O1).
+var liveStatuses = []string{agreement.StatusPendingSignature, agreement.StatusEffective}
+
+// inEffect are the statuses with an effective_at; transitions out of them
+// clear it.
+var inEffect = []string{agreement.StatusEffective, agreement.StatusSuccess, agreement.StatusDisputed, agreement.StatusExpired}
+
+// dealEvents may only be recorded on an agreement in effect (O2).
+var dealEvents = []string{"OFFER_MADE", "UNDER_CONTRACT", "ESIGN_COMPLETED", "DEAL_CLOSED"}
+
+// Check verifies next against the invariants, and against prev for the
+// ones about change:
+//
+//   - O1: a referral has at most one pending_signature or effective
+//     agreement;
+//   - status changes follow machine, bump the version, and versions never
+//     go back;
+//   - an agreement has effective_at exactly while in effect, and keeps it
+//     until it leaves;
+//   - O2: deal events are recorded only while the agreement is in effect,
+//     and not before effective_at;
+//   - O3: each timeline only grows, with strictly increasing seq;
+//   - a resolved dispute stays resolved.
+func Check(machine *agreement.StateMachine, prev, next Snapshot) error {
+	live := map[string]string{}
+	for id, ag := range next.Agreements {
+		if slices.Contains(liveStatuses, ag.Status) {
+			if other, ok := live[ag.RequestID]; ok {
+				return fmt.Errorf("O1: referral %s has live agreements %s and %s", ag.RequestID, other, id)
+			}
+			live[ag.RequestID] = id
+		}
+		if (ag.EffectiveAt != nil) != slices.Contains(inEffect, ag.Status) {
+			return fmt.Errorf("agreement %s is %s with effective_at %v", id, ag.Status, ag.EffectiveAt)
+		}
+
+		before, existed := prev.Agreements[id]
+		if !existed {
+			continue
+		}
+		switch {
+		case ag.Version < before.Version:
+			return fmt.Errorf("agreement %s: version went back from %d to %d", id, before.Version, ag.Version)
+		case ag.Status != before.Status && !machine.Can(before.Status, ag.Status):
+			return fmt.Errorf("agreement %s: moved %s -> %s, which the state machine forbids", id, before.Status, ag.Status)
+		case ag.Status != before.Status && ag.Version == before.Version:
+			return fmt.Errorf("agreement %s: moved %s -> %s without a new version", id, before.Status, ag.Status)
+		case before.EffectiveAt != nil && ag.EffectiveAt != nil && !ag.EffectiveAt.Equal(*before.EffectiveAt):
+			return fmt.Errorf("agreement %s: effective_at moved from %v to %v", id, before.EffectiveAt, ag.EffectiveAt)
+		}
+	}
+
+	for id, events := range next.Events {
+		old := prev.Events[id]
+		if len(events) < len(old) {
+			return fmt.Errorf("O3: agreement %s lost timeline events", id)
+		}
+		for i, e := range events {
+			if i < len(old) && e != old[i] {
+				return fmt.Errorf("O3: agreement %s: event %d changed from %+v to %+v", id, i, old[i], e)
+			}
+			if i > 0 && e.Seq <= events[i-1].Seq {
+				return fmt.Errorf("O3: agreement %s: seq %d follows %d", id, e.Seq, events[i-1].Seq)
+			}
+			if i < len(old) || !slices.Contains(dealEvents, e.Type) {
+				continue
+			}
+			ag := next.Agreements[id]
+			if ag.EffectiveAt == nil || e.TS.Before(*ag.EffectiveAt) {
+				return fmt.Errorf("O2: agreement %s got %s at %v while %s (effective_at %v)", id, e.Type, e.TS, ag.Status, ag.EffectiveAt)
+			}
+		}
+	}
+
+	for id, status := range prev.Disputes {
+		if status == dispute.StatusResolved && next.Disputes[id] != dispute.StatusResolved {
+			return fmt.Errorf("dispute %s reopened as %q", id, next.Disputes[id])
+		}
+	}
+	return nil
+}
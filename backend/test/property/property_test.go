@@ -0,0 +1,119 @@
+package property
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/dispute"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestFakesKeepInvariants(t *testing.T) {
+	ctx := context.Background()
+	prop := func(ops Ops) bool {
+		sys, err := NewFakes(ctx)
+		if err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		if err := Run(ctx, sys, ops); err != nil {
+			t.Logf("%v\nsequence: %s", err, ops)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 300, MaxCountScale: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatabaseKeepsInvariants(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect pool: %v", err)
+	}
+	defer pool.Close()
+
+	prop := func(ops Ops) bool {
+		sys, err := NewDatabase(ctx, pool)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		defer sys.Close(context.WithoutCancel(ctx))
+		if err := Run(ctx, sys, ops); err != nil {
+			t.Logf("%v\nsequence: %s", err, ops)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 25}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheckCatchesViolations makes sure the invariants are not vacuous.
+func TestCheckCatchesViolations(t *testing.T) {
+	machine := agreement.DefaultStateMachine()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := at.Add(-time.Minute)
+	draft := Agreement{RequestID: "r1", Status: agreement.StatusDraft, Version: 1}
+	effective := Agreement{RequestID: "r1", Status: agreement.StatusEffective, Version: 3, EffectiveAt: &at}
+	snap := func(ags map[string]Agreement, events map[string][]Event, disputes map[string]dispute.Status) Snapshot {
+		return Snapshot{Agreements: ags, Events: events, Disputes: disputes}
+	}
+
+	cases := map[string]struct{ prev, next Snapshot }{
+		"two live agreements": {next: snap(map[string]Agreement{
+			"a": effective, "b": {RequestID: "r1", Status: agreement.StatusPendingSignature, Version: 2},
+		}, nil, nil)},
+		"skipped signature": {
+			prev: snap(map[string]Agreement{"a": draft}, nil, nil),
+			next: snap(map[string]Agreement{"a": effective}, nil, nil),
+		},
+		"same version": {
+			prev: snap(map[string]Agreement{"a": draft}, nil, nil),
+			next: snap(map[string]Agreement{"a": {RequestID: "r1", Status: agreement.StatusPendingSignature, Version: 1}}, nil, nil),
+		},
+		"effective without effective_at": {next: snap(map[string]Agreement{
+			"a": {RequestID: "r1", Status: agreement.StatusEffective, Version: 3},
+		}, nil, nil)},
+		"deal event before effective": {
+			prev: snap(map[string]Agreement{"a": effective}, nil, nil),
+			next: snap(map[string]Agreement{"a": effective}, map[string][]Event{"a": {{Seq: 1, Type: "OFFER_MADE", TS: before}}}, nil),
+		},
+		"deal event on a draft": {
+			next: snap(map[string]Agreement{"a": draft}, map[string][]Event{"a": {{Seq: 1, Type: "DEAL_CLOSED", TS: at}}}, nil),
+		},
+		"seq going back": {
+			next: snap(map[string]Agreement{"a": draft}, map[string][]Event{"a": {{Seq: 2, Type: "AGREEMENT_CREATED"}, {Seq: 1, Type: "AGREEMENT_SIGNED"}}}, nil),
+		},
+		"rewritten event": {
+			prev: snap(map[string]Agreement{"a": draft}, map[string][]Event{"a": {{Seq: 1, Type: "AGREEMENT_CREATED"}}}, nil),
+			next: snap(map[string]Agreement{"a": draft}, map[string][]Event{"a": {{Seq: 1, Type: "AGREEMENT_SIGNED"}}}, nil),
+		},
+		"reopened dispute": {
+			prev: snap(nil, nil, map[string]dispute.Status{"d": dispute.StatusResolved}),
+			next: snap(nil, nil, map[string]dispute.Status{"d": dispute.StatusUnderReview}),
+		},
+	}
+	for name, c := range cases {
+		if err := Check(machine, c.prev, c.next); err == nil {
+			t.Errorf("%s: expected a violation", name)
+		}
+	}
+
+	ok := snap(map[string]Agreement{"a": effective}, map[string][]Event{"a": {{Seq: 1, Type: "ESIGN_COMPLETED", TS: at}}}, nil)
+	if err := Check(machine, snap(map[string]Agreement{"a": {RequestID: "r1", Status: agreement.StatusPendingSignature, Version: 2}}, nil, nil), ok); err != nil {
+		t.Fatalf("expected signing to pass, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package scenario describes stress runs: which actors run, how many of
+// each, how fast, for how long and under which chaos modes. Scenarios are
+// JSON; the ones in scenarios/ are built in and can be named instead of a
+// path.
+package scenario
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/test/actors"
+	"brokerflow/test/chaos"
+)
+
+//go:embed scenarios/*.json
+var builtin embed.FS
+
+// Scenario is one stress run.
+type Scenario struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Duration    Duration `json:"duration"`
+	// Chaos lists chaos modes (see chaos.ParseModes); omitted, the stress
+	// test's -chaos default applies.
+	Chaos  []string `json:"chaos,omitempty"`
+	Actors []Actor  `json:"actors"`
+}
+
+// Actor runs Count actors of one kind (see actors.Kinds and
+// actors.HTTPKinds).
+type Actor struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	// Pace overrides the pause between the actor's iterations.
+	Pace *Pace `json:"pace,omitempty"`
+}
+
+// Pace is the JSON form of actors.Pace.
+type Pace struct {
+	Min Duration `json:"min"`
+	Max Duration `json:"max"`
+}
+
+// ActorPace returns the pace to run a with; the zero actors.Pace keeps the
+// actor's default.
+func (a Actor) ActorPace() actors.Pace {
+	if a.Pace == nil {
+		return actors.Pace{}
+	}
+	return actors.Pace{Min: time.Duration(a.Pace.Min), Max: time.Duration(a.Pace.Max)}
+}
+
+// Duration is a time.Duration written as a string such as "90s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"90s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default is the actor mix the stress test ran before scenarios existed:
+// concurrency creators and signers racing on one referral, and one of each
+// other actor, with terminated backends.
+func Default(concurrency int, duration time.Duration) Scenario {
+	s := Scenario{
+		Name:     "default",
+		Duration: Duration(duration),
+		Chaos:    []string{string(chaos.ModeTerminate)},
+	}
+	for _, kind := range actors.Kinds() {
+		count := 1
+		if kind == actors.KindCreator || kind == actors.KindSigner {
+			count = concurrency
+		}
+		s.Actors = append(s.Actors, Actor{Kind: kind, Count: count})
+	}
+	return s
+}
+
+// Builtin lists the names of the built-in scenarios.
+func Builtin() []string {
+	entries, _ := fs.ReadDir(builtin, "scenarios")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return names
+}
+
+// Load reads the built-in scenario called name, or else the file at name.
+func Load(name string) (Scenario, error) {
+	b, err := builtin.ReadFile(path.Join("scenarios", name+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Scenario{}, fmt.Errorf("scenario %q: no such file or built-in scenario (built in: %s)", name, strings.Join(Builtin(), ", "))
+		}
+		return Scenario{}, err
+	}
+	s, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		return Scenario{}, fmt.Errorf("scenario %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// Parse decodes and validates a scenario. Unknown fields are rejected, so
+// a misspelt setting does not silently fall back to a default.
+func Parse(r io.Reader) (Scenario, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var s Scenario
+	if err := dec.Decode(&s); err != nil {
+		return Scenario{}, err
+	}
+	return s, s.Validate()
+}
+
+// NeedsAPI reports whether s runs any HTTP actor.
+func (s Scenario) NeedsAPI() bool {
+	return slices.ContainsFunc(s.Actors, func(a Actor) bool {
+		return a.Count > 0 && slices.Contains(actors.HTTPKinds(), a.Kind)
+	})
+}
+
+// Validate checks that s runs for a while, with known actors and chaos
+// modes, and at least one actor.
+func (s Scenario) Validate() error {
+	var problems []string
+	if s.Duration <= 0 {
+		problems = append(problems, "duration must be positive")
+	}
+	if _, err := chaos.ParseModes(strings.Join(s.Chaos, ",")); err != nil {
+		problems = append(problems, err.Error())
+	}
+	kinds := append(actors.Kinds(), actors.HTTPKinds()...)
+	total := 0
+	for i, a := range s.Actors {
+		switch {
+		case !slices.Contains(kinds, a.Kind):
+			problems = append(problems, fmt.Sprintf("actors[%d]: unknown kind %q (want one of %s)", i, a.Kind, strings.Join(kinds, ", ")))
+		case a.Count < 0:
+			problems = append(problems, fmt.Sprintf("actors[%d]: count must not be negative", i))
+		case a.Pace != nil && (a.Pace.Min < 0 || a.Pace.Max < a.Pace.Min):
+			problems = append(problems, fmt.Sprintf("actors[%d]: pace needs 0 <= min <= max", i))
+		}
+		total += a.Count
+	}
+	if total == 0 {
+		problems = append(problems, "no actors to run")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/test/actors"
+)
+
+func TestBuiltinScenariosLoad(t *testing.T) {
+	names := Builtin()
+	for _, want := range []string{"signing-storm", "dispute-heavy", "outbox-backlog", "api-traffic"} {
+		s, err := Load(want)
+		if err != nil {
+			t.Fatalf("load %s: %v", want, err)
+		}
+		if s.Name != want {
+			t.Errorf("%s: file names itself %q", want, s.Name)
+		}
+		if !slices.Contains(names, want) {
+			t.Errorf("expected %s among %v", want, names)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mine.json")
+	body := `{"name": "mine", "duration": "45s", "actors": [{"kind": "signer", "count": 3, "pace": {"min": "5ms", "max": "10ms"}}]}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if time.Duration(s.Duration) != 45*time.Second || s.Chaos != nil || len(s.Actors) != 1 {
+		t.Fatalf("unexpected scenario: %+v", s)
+	}
+	if got := s.Actors[0].ActorPace(); got != (actors.Pace{Min: 5 * time.Millisecond, Max: 10 * time.Millisecond}) {
+		t.Fatalf("unexpected pace: %+v", got)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "built in:") {
+		t.Fatalf("expected a missing scenario to list the built-in ones, got %v", err)
+	}
+}
+
+func TestNeedsAPI(t *testing.T) {
+	if Default(2, time.Minute).NeedsAPI() {
+		t.Fatal("expected the default scenario to run SQL actors only")
+	}
+	s, err := Load("api-traffic")
+	if err != nil || !s.NeedsAPI() {
+		t.Fatalf("expected api-traffic to need cmd/api (%v)", err)
+	}
+	s.Actors = []Actor{{Kind: actors.KindAPIDeal, Count: 0}, {Kind: actors.KindSigner, Count: 1}}
+	if s.NeedsAPI() {
+		t.Fatal("expected HTTP actors with no count not to need cmd/api")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown field":    `{"name": "x", "duration": "1m", "actors": [{"kind": "signer", "count": 1, "rate": 5}]}`,
+		"numeric duration": `{"name": "x", "duration": 60, "actors": [{"kind": "signer", "count": 1}]}`,
+		"no duration":      `{"name": "x", "actors": [{"kind": "signer", "count": 1}]}`,
+		"unknown kind":     `{"name": "x", "duration": "1m", "actors": [{"kind": "spammer", "count": 1}]}`,
+		"negative count":   `{"name": "x", "duration": "1m", "actors": [{"kind": "signer", "count": -1}]}`,
+		"no actors":        `{"name": "x", "duration": "1m", "actors": [{"kind": "signer", "count": 0}]}`,
+		"inverted pace":    `{"name": "x", "duration": "1m", "actors": [{"kind": "signer", "count": 1, "pace": {"min": "2s", "max": "1s"}}]}`,
+		"unknown chaos":    `{"name": "x", "duration": "1m", "chaos": ["flood"], "actors": [{"kind": "signer", "count": 1}]}`,
+	} {
+		if _, err := Parse(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDefaultMatchesTheOriginalMix(t *testing.T) {
+	s := Default(8, 90*time.Second)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("default scenario invalid: %v", err)
+	}
+	counts := map[string]int{}
+	for _, a := range s.Actors {
+		counts[a.Kind] += a.Count
+	}
+	if counts[actors.KindCreator] != 8 || counts[actors.KindSigner] != 8 || counts[actors.KindDisputer] != 1 || len(counts) != len(actors.Kinds()) {
+		t.Fatalf("unexpected default mix: %v", counts)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,16 +18,26 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"brokerflow/test/actors"
+	"brokerflow/test/apiserver"
 	"brokerflow/test/chaos"
 	"brokerflow/test/infra"
 	"brokerflow/test/oracles"
+	"brokerflow/test/scenario"
 )
 
 var (
-	flDuration    = flag.Duration("duration", 90*time.Second, "how long to run stress")
-	flConcurrency = flag.Int("concurrency", 8, "number of concurrent actors")
+	flDuration    = flag.Duration("duration", 90*time.Second, "how long to run stress; overrides the scenario's duration when set")
+	flConcurrency = flag.Int("concurrency", 8, "number of concurrent creators and signers in the default scenario")
+	flScenario    = flag.String("scenario", "", "built-in scenario (signing-storm, dispute-heavy, outbox-backlog) or path to a scenario JSON file; empty runs the default actor mix")
 	flSeed        = flag.Int64("seed", time.Now().UnixNano(), "random seed")
 	flDSN         = flag.String("dsn", "", "existing Postgres DSN to reuse (avoids Docker)")
+	flChaos       = flag.String("chaos", string(chaos.ModeTerminate), "comma-separated failure modes to inject: terminate, latency, serialization, exhaust-pool, pause, or all; overrides the scenario's modes when set")
+	flOracleMode  = flag.String("oracle-mode", "fail", "fail: stop at the first oracle violation; record: keep running, record every tick and fail at the end")
+	flOracleOut   = flag.String("oracle-out", "", "record mode: append each tick's oracle results as a JSON line to this file")
+	flOracleTable = flag.Bool("oracle-table", false, "record mode: also store each tick's results in the oracle_results table")
+	flLatencyP99  = flag.String("latency-p99", "", "p99 latency budgets as op=duration, comma separated (ops: creator.insert, signer.transition, outbox.drain, pii.read); a run over budget fails")
+	flAPIURL      = flag.String("api-url", os.Getenv("STRESS_TEST_API_URL"), "base URL of a cmd/api serving the database under test, for HTTP actors; empty starts one when the scenario has HTTP actors")
+	flPGContainer = flag.String("pg-container", os.Getenv("STRESS_TEST_PG_CONTAINER"), "Docker container running Postgres, paused by the pause mode")
 )
 
 func seedRNG(seed int64) { rand.Seed(seed) }
@@ -34,14 +46,47 @@ func TestACNConcurrency(t *testing.T) {
 	flag.Parse()
 	seed := *flSeed
 	seedRNG(seed)
+	sc := scenario.Default(*flConcurrency, *flDuration)
+	if *flScenario != "" {
+		var err error
+		if sc, err = scenario.Load(*flScenario); err != nil {
+			t.Fatalf("-scenario: %v", err)
+		}
+	}
+	duration := time.Duration(sc.Duration)
+	if flagSet("duration") {
+		duration = *flDuration
+	}
+	chaosModes := strings.Join(sc.Chaos, ",")
+	if flagSet("chaos") || sc.Chaos == nil {
+		chaosModes = *flChaos
+	}
+	modes, err := chaos.ParseModes(chaosModes)
+	if err != nil {
+		t.Fatalf("-chaos: %v", err)
+	}
+	t.Logf("scenario %s for %s, chaos %q (seed=%d)", sc.Name, duration, chaosModes, seed)
+	record := *flOracleMode == "record"
+	if !record && *flOracleMode != "fail" {
+		t.Fatalf("-oracle-mode: want fail or record, got %q", *flOracleMode)
+	}
+	if !record && (*flOracleOut != "" || *flOracleTable) {
+		t.Fatalf("-oracle-out and -oracle-table need -oracle-mode=record")
+	}
+	budgets, err := actors.ParseBudgets(*flLatencyP99)
+	if err != nil {
+		t.Fatalf("-latency-p99: %v", err)
+	}
+	if modes[chaos.ModePause] && *flPGContainer == "" {
+		t.Fatalf("-chaos=pause needs -pg-container or STRESS_TEST_PG_CONTAINER")
+	}
 
 	var (
 		pgC        *infra.PGContainer
 		dsn        string
-		err        error
 		usedShared bool
 	)
-	ctx, cancel := context.WithTimeout(context.Background(), *flDuration+60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), duration+60*time.Second)
 	defer cancel()
 
 	switch {
@@ -84,35 +129,104 @@ func TestACNConcurrency(t *testing.T) {
 	// seed minimal data
 	seedData := mustSeed(t, ctx, pool)
 
+	// server-side faults stay installed for the whole run
+	for _, fault := range []struct {
+		mode    chaos.Mode
+		install func(context.Context, *pgxpool.Pool) (func(context.Context) error, error)
+	}{
+		{chaos.ModeLatency, chaos.DefaultLatency().Install},
+		{chaos.ModeSerialization, chaos.DefaultSerializationFailures().Install},
+	} {
+		if !modes[fault.mode] {
+			continue
+		}
+		remove, err := fault.install(ctx, pool)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		defer func() {
+			if err := remove(context.Background()); err != nil {
+				t.Logf("chaos %s cleanup warning: %v", fault.mode, err)
+			}
+		}()
+	}
+
+	// HTTP actors drive a cmd/api on the same database
+	apiURL := *flAPIURL
+	if apiURL == "" && sc.NeedsAPI() {
+		api, err := apiserver.Start(ctx, "..", dsn)
+		if err != nil {
+			t.Fatalf("start cmd/api: %v", err)
+		}
+		defer api.Stop()
+		defer func() {
+			if exited, err := api.Exited(); exited {
+				t.Errorf("cmd/api exited during the run: %v", err)
+			}
+			if t.Failed() {
+				t.Logf("cmd/api output:\n%s", api.Log())
+			}
+		}()
+		apiURL = api.URL
+	}
+
 	// run actors
 	g, ctx2 := errgroup.WithContext(ctx)
 	stop := make(chan struct{})
+	lat := actors.NewLatencies()
+
+	for _, spec := range sc.Actors {
+		run := actorFunc(spec.Kind, pool, seedData, apiURL, lat, stop)
+		if run == nil {
+			t.Fatalf("scenario %s: no actor of kind %q", sc.Name, spec.Kind)
+		}
+		for range spec.Count {
+			g.Go(func() error { return run(ctx2, spec.ActorPace()) })
+		}
+	}
+	// chaos: kill random backends, drain the pool, freeze the server
+	if modes[chaos.ModeTerminate] {
+		go chaos.TerminateRandomBackend(ctx2, pool, "", stop)
+	}
+	if modes[chaos.ModeExhaustPool] {
+		go chaos.ExhaustPool(ctx2, pool, chaos.DefaultPoolExhaustion(), stop)
+	}
+	if modes[chaos.ModePause] {
+		// wait for the last resume on every exit, t.Fatalf included, so
+		// teardown reaches the database
+		var paused sync.WaitGroup
+		pauseCtx, stopPausing := context.WithCancel(ctx2)
+		defer paused.Wait()
+		defer stopPausing()
+		paused.Add(1)
+		go func() {
+			defer paused.Done()
+			chaos.PauseDatabase(pauseCtx, chaos.DockerContainer(*flPGContainer), chaos.DefaultPause(), stop)
+		}()
+	}
 
-	// creators and signers battling over same referral
-	for i := 0; i < *flConcurrency; i++ {
-		g.Go(func() error {
-			return actors.Creator(ctx2, pool, seedData.referralRequest, seedData.fromBroker, seedData.toBroker, stop)
-		})
-		g.Go(func() error { return actors.Signer(ctx2, pool, seedData.referralRequest, stop) })
-	}
-
-	// pii reader
-	g.Go(func() error { return actors.PIIReader(ctx2, pool, seedData.agreementID, seedData.userID, stop) })
-	// event writer
-	g.Go(func() error { return actors.EventWriter(ctx2, pool, seedData.agreementID, stop) })
-	// outbox worker
-	g.Go(func() error { return actors.OutboxWorker(ctx2, pool, stop) })
-	// edge adapter
-	g.Go(func() error {
-		return actors.EdgeAdapter(ctx2, pool, fmt.Sprintf("edge-%s", seedData.agreementID), "/thirdparty/notify", stop)
-	})
-	// disputer
-	g.Go(func() error { return actors.Disputer(ctx2, pool, seedData.agreementID, stop) })
-	// chaos: kill random backend
-	go chaos.TerminateRandomBackend(ctx2, pool, "", stop)
+	// in record mode, every tick goes to the recorders instead of failing
+	runID := fmt.Sprintf("%s-%d", sc.Name, seed)
+	var recorders []oracles.Recorder
+	if *flOracleOut != "" {
+		f, err := os.OpenFile(*flOracleOut, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatalf("-oracle-out: %v", err)
+		}
+		defer f.Close()
+		recorders = append(recorders, oracles.NewJSONLines(f))
+	}
+	if *flOracleTable {
+		table, err := oracles.NewTable(ctx, pool)
+		if err != nil {
+			t.Fatalf("-oracle-table: %v", err)
+		}
+		recorders = append(recorders, table)
+	}
+	var ticks []oracles.Tick
 
 	// schedule oracle checks until duration reached
-	deadline := time.Now().Add(*flDuration)
+	deadline := time.Now().Add(duration)
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -123,6 +237,24 @@ loop:
 		case <-ctx.Done():
 			break loop
 		case <-ticker.C:
+			if record {
+				tick, err := oracles.Evaluate(ctx2, pool, runID)
+				if err != nil {
+					break loop
+				}
+				ticks = append(ticks, tick)
+				for _, rec := range recorders {
+					if err := rec.Record(ctx2, tick); err != nil {
+						t.Logf("record oracle tick: %v", err)
+					}
+				}
+				if f := tick.Failed(); len(f) > 0 && !failed {
+					failed = true
+					t.Logf("Oracle %s failed first at %s. First row: %s (seed=%d)", f[0].Oracle, tick.At.Format(time.RFC3339), f[0].Sample, seed)
+					dumpRecent(t, ctx2, pool)
+				}
+				continue
+			}
 			name, row, err := oracles.Run(ctx2, pool)
 			if err != nil {
 				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -144,6 +276,75 @@ loop:
 			t.Fatalf("actors errored: %v", err)
 		}
 	}
+	var report strings.Builder
+	latencies := lat.Report()
+	if err := actors.WriteReport(&report, latencies); err == nil {
+		t.Logf("latencies (seed=%d):\n%s", seed, report.String())
+	}
+	for _, over := range actors.OverBudget(latencies, budgets) {
+		t.Errorf("latency: %s", over)
+	}
+	if record {
+		summary := oracles.Summarize(ticks)
+		for _, o := range summary.Failed() {
+			t.Errorf("Oracle %s failed on %d of %d ticks, first at %s, up to %d rows (run %s)",
+				o.Oracle, o.FailingTicks, summary.Ticks, o.FirstFailure.Format(time.RFC3339), o.MaxViolations, runID)
+		}
+	}
+}
+
+// actorFunc binds the actor of kind to the seeded rows: creators and
+// signers battle over the same referral, the others work on the seeded
+// agreement. HTTP actors call the cmd/api at apiURL with agents of the
+// seeded brokers. Timed actors record into lat. It returns nil for an
+// unknown kind.
+func actorFunc(kind string, pool *pgxpool.Pool, seedData seedIDs, apiURL string, lat *actors.Latencies, stop <-chan struct{}) func(context.Context, actors.Pace) error {
+	switch kind {
+	case actors.KindCreator:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.Creator(ctx, pool, seedData.referralRequest, seedData.fromBroker, seedData.toBroker, lat, pace, stop)
+		}
+	case actors.KindSigner:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.Signer(ctx, pool, seedData.referralRequest, lat, pace, stop)
+		}
+	case actors.KindPIIReader:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.PIIReader(ctx, pool, seedData.agreementID, seedData.userID, lat, pace, stop)
+		}
+	case actors.KindEventWriter:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.EventWriter(ctx, pool, seedData.agreementID, pace, stop)
+		}
+	case actors.KindOutboxWorker:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.OutboxWorker(ctx, pool, lat, pace, stop)
+		}
+	case actors.KindEdgeAdapter:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.EdgeAdapter(ctx, pool, fmt.Sprintf("edge-%s", seedData.agreementID), "/thirdparty/notify", pace, stop)
+		}
+	case actors.KindDisputer:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.Disputer(ctx, pool, seedData.agreementID, pace, stop)
+		}
+	case actors.KindAPISession:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.APISession(ctx, apiURL, pace, stop)
+		}
+	case actors.KindAPIDeal:
+		return func(ctx context.Context, pace actors.Pace) error {
+			return actors.APIDeal(ctx, pool, apiURL, seedData.fromBroker, seedData.toBroker, pace, stop)
+		}
+	}
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
 }
 
 func dockerAvailable(ctx context.Context) bool {
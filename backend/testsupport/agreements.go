@@ -0,0 +1,311 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/clock"
+	"brokerflow/db"
+
+	"github.com/google/uuid"
+)
+
+var lifecycle = agreement.DefaultStateMachine()
+
+var activeStatuses = []string{"draft", "pending_signature", "effective"}
+
+// Agreements stands in for agreement.CRUDService and agreement.StatusService,
+// whose SQL runs inside the services rather than behind a repository. It
+// keeps their rules: only the referral owner creates, for their own
+// brokerage, one active agreement per referral, and transitions follow the
+// database's state machine.
+type Agreements struct {
+	mu        sync.Mutex
+	records   []agreementRow
+	referrals *Referrals
+	users     *Users
+	brokers   *Brokers
+	clock     clock.Clock
+	newID     func() string
+}
+
+type agreementRow struct {
+	rec    agreement.Record
+	status string
+}
+
+// NewAgreements builds an empty store over referrals, whose owners it checks
+// and scopes by, and users, which resolves broker-wide scopes.
+func NewAgreements(referrals *Referrals, users *Users) *Agreements {
+	return &Agreements{referrals: referrals, users: users, clock: clock.New(), newID: uuid.NewString}
+}
+
+// WithClock overrides the time source for timestamps and effective_at.
+func (a *Agreements) WithClock(c clock.Clock) *Agreements {
+	a.clock = clock.OrReal(c)
+	return a
+}
+
+// WithIDGenerator overrides how ids of new agreements are made.
+func (a *Agreements) WithIDGenerator(gen func() string) *Agreements {
+	a.newID = gen
+	return a
+}
+
+// WithBrokers makes Create enforce the referring broker's policy as stored
+// in brokers; without it any non-negative terms are accepted.
+func (a *Agreements) WithBrokers(brokers *Brokers) *Agreements {
+	a.brokers = brokers
+	return a
+}
+
+// Add stores rec in status, bypassing Create's checks, and returns it.
+func (a *Agreements) Add(rec agreement.Record, status string) agreement.Record {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.addLocked(rec, status)
+}
+
+func (a *Agreements) addLocked(rec agreement.Record, status string) agreement.Record {
+	if rec.ID == "" {
+		rec.ID = a.newID()
+	}
+	if rec.Version == 0 {
+		rec.Version = 1
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = a.clock.Now()
+		rec.UpdatedAt = rec.CreatedAt
+	}
+	rec.Status = status
+	if rec.StatusUpdatedAt.IsZero() {
+		rec.StatusUpdatedAt = rec.CreatedAt
+	}
+	a.records = append(a.records, agreementRow{rec: rec, status: status})
+	return rec
+}
+
+// Status returns the agreement's current status.
+func (a *Agreements) Status(id string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if i := a.indexLocked(id); i >= 0 {
+		return a.records[i].status, true
+	}
+	return "", false
+}
+
+func (a *Agreements) Create(_ context.Context, userID string, params agreement.CreateParams) (agreement.Record, error) {
+	if params.RequestID == "" {
+		return agreement.Record{}, fmt.Errorf("%w: request id required", agreement.ErrInvalidParams)
+	}
+	if params.ReferrerBrokerID == "" || params.RefereeBrokerID == "" {
+		return agreement.Record{}, fmt.Errorf("%w: broker ids required", agreement.ErrInvalidParams)
+	}
+	if params.FeeRate < 0 {
+		return agreement.Record{}, fmt.Errorf("%w: invalid fee rate", agreement.ErrInvalidParams)
+	}
+	if params.ProtectDays < 0 {
+		return agreement.Record{}, fmt.Errorf("%w: invalid protect days", agreement.ErrInvalidParams)
+	}
+	owner, currency := a.referrals.Owner(params.RequestID), a.referrals.currency(params.RequestID)
+	if owner == "" {
+		return agreement.Record{}, agreement.ErrReferralNotFound
+	}
+	if owner != userID {
+		return agreement.Record{}, agreement.ErrNotOwner
+	}
+	if broker := a.users.BrokerOf(owner); broker == "" || broker != params.ReferrerBrokerID {
+		return agreement.Record{}, agreement.ErrReferrerBrokerMismatch
+	}
+	if a.brokers != nil {
+		if err := a.brokers.policy(params.ReferrerBrokerID).Check(params.FeeRate, params.ProtectDays); err != nil {
+			return agreement.Record{}, err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, row := range a.records {
+		if row.rec.RequestID == params.RequestID && slices.Contains(activeStatuses, row.status) {
+			return agreement.Record{}, agreement.ErrActiveAgreementExists
+		}
+	}
+	return a.addLocked(agreement.Record{
+		RequestID:        params.RequestID,
+		ReferrerBrokerID: params.ReferrerBrokerID,
+		RefereeBrokerID:  params.RefereeBrokerID,
+		FeeRate:          params.FeeRate,
+		ProtectDays:      params.ProtectDays,
+		Currency:         currency,
+	}, "draft"), nil
+}
+
+// List returns the agreements in scope matching filters, newest first, paged
+// like the service.
+func (a *Agreements) List(_ context.Context, filters agreement.ListFilters) ([]agreement.Record, db.Page, error) {
+	if err := filters.Validate(); err != nil {
+		return nil, db.Page{}, err
+	}
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+
+	a.mu.Lock()
+	rows := slices.Clone(a.records)
+	a.mu.Unlock()
+
+	matched := []agreement.Record{}
+	for _, row := range rows {
+		owner := a.referrals.Owner(row.rec.RequestID)
+		if a.users.partyTo(filters.Scope, owner, row.rec.ReferrerBrokerID, row.rec.RefereeBrokerID) && listed(row, filters) {
+			rec := row.rec
+			rec.Status = row.status
+			matched = append(matched, rec)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+
+	total := len(matched)
+	start := min((filters.Page-1)*filters.PageSize, total)
+	end := min(start+filters.PageSize, total)
+	return matched[start:end], db.Page{Total: total, TotalExact: true, HasMore: end < total}, nil
+}
+
+// listed applies ListFilters' status, party, referral and date filters.
+func listed(row agreementRow, f agreement.ListFilters) bool {
+	rec := row.rec
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, row.status) {
+		return false
+	}
+	if f.CounterpartyBrokerID != "" && f.CounterpartyBrokerID != rec.ReferrerBrokerID && f.CounterpartyBrokerID != rec.RefereeBrokerID {
+		return false
+	}
+	if f.ReferralID != "" && f.ReferralID != rec.RequestID {
+		return false
+	}
+	within := func(at *time.Time, from, to time.Time) bool {
+		if from.IsZero() && to.IsZero() {
+			return true
+		}
+		return at != nil && !at.Before(from) && (to.IsZero() || at.Before(to))
+	}
+	return within(&rec.CreatedAt, f.CreatedFrom, f.CreatedTo) && within(rec.EffectiveAt, f.EffectiveFrom, f.EffectiveTo)
+}
+
+// ParticipantUserIDs returns the referral owner and every user of either
+// broker party.
+func (a *Agreements) ParticipantUserIDs(_ context.Context, agreementID string) ([]string, error) {
+	rec, ok := a.Get(agreementID)
+	if !ok {
+		return nil, nil
+	}
+
+	ids := []string{a.referrals.Owner(rec.RequestID)}
+	for _, brokerID := range []string{rec.ReferrerBrokerID, rec.RefereeBrokerID} {
+		for _, id := range a.users.BrokerUsers(brokerID) {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (a *Agreements) Transition(_ context.Context, params agreement.TransitionParams) (int, error) {
+	if params.NextStatus == agreement.StatusCancelled {
+		return 0, agreement.ErrCancelViaTransition
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := a.indexLocked(params.AgreementID)
+	if i < 0 {
+		return 0, agreement.ErrAgreementNotFound
+	}
+	row := &a.records[i]
+	if params.IfVersion != 0 && params.IfVersion != row.rec.Version {
+		return 0, &agreement.VersionConflictError{Status: row.status, Version: row.rec.Version}
+	}
+	if _, err := lifecycle.Effect(row.status, params.NextStatus); err != nil {
+		return 0, err
+	}
+
+	now := a.clock.Now()
+	switch params.NextStatus {
+	case "effective", "success", "disputed", "expired":
+		if row.rec.EffectiveAt == nil {
+			row.rec.EffectiveAt = &now
+		}
+	default:
+		row.rec.EffectiveAt = nil
+	}
+	// Like the agreements trigger, only a change bumps the version.
+	if row.status != params.NextStatus {
+		row.rec.Version++
+	}
+	row.status = params.NextStatus
+	row.rec.Status, row.rec.StatusUpdatedAt, row.rec.StatusUpdatedBy = params.NextStatus, now, nil
+	if params.ActorID != "" {
+		actor := params.ActorID
+		row.rec.StatusUpdatedBy = &actor
+	}
+	row.rec.UpdatedAt = now
+	if next, ok := (agreement.ReferralProjector{}).ForStatus(params.NextStatus); ok {
+		a.referrals.project(row.rec.RequestID, next)
+	}
+	return row.rec.Version, nil
+}
+
+// ExpireDue moves effective agreements whose protect period has lapsed by
+// the clock to expired, like agreement.ExpiryService.ExpireDue, oldest
+// effective_at first. The fake keeps no timeline, so a closed deal does not
+// hold expiry off: move such agreements to success first.
+func (a *Agreements) ExpireDue(context.Context) ([]agreement.ExpiredAgreement, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.clock.Now()
+	var due []int
+	for i, row := range a.records {
+		if row.status == agreement.StatusEffective && row.rec.ProtectDays > 0 && row.rec.EffectiveAt != nil &&
+			!row.rec.EffectiveAt.AddDate(0, 0, row.rec.ProtectDays).After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return a.records[due[i]].rec.EffectiveAt.Before(*a.records[due[j]].rec.EffectiveAt)
+	})
+	out := make([]agreement.ExpiredAgreement, 0, len(due))
+	for _, i := range due {
+		row := &a.records[i]
+		row.status = agreement.StatusExpired
+		row.rec.Status, row.rec.StatusUpdatedAt, row.rec.StatusUpdatedBy, row.rec.UpdatedAt = row.status, now, nil, now
+		row.rec.Version++
+		if next, ok := (agreement.ReferralProjector{}).ForStatus(row.status); ok {
+			a.referrals.project(row.rec.RequestID, next)
+		}
+		out = append(out, agreement.ExpiredAgreement{AgreementID: row.rec.ID, EffectiveAt: *row.rec.EffectiveAt, ProtectDays: row.rec.ProtectDays, ExpiredAt: now})
+	}
+	return out, nil
+}
+
+// Get returns the agreement as stored, in its current status.
+func (a *Agreements) Get(id string) (agreement.Record, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if i := a.indexLocked(id); i >= 0 {
+		return a.records[i].rec, true
+	}
+	return agreement.Record{}, false
+}
+
+func (a *Agreements) indexLocked(id string) int {
+	return slices.IndexFunc(a.records, func(row agreementRow) bool { return row.rec.ID == id })
+}
@@ -0,0 +1,114 @@
+package testsupport
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"brokerflow/broker"
+	"brokerflow/clock"
+)
+
+// Brokers implements broker.ProfileReader and broker.SettingsStore.
+type Brokers struct {
+	mu       sync.Mutex
+	profiles map[string]broker.Profile
+	settings map[string]broker.Settings
+	clock    clock.Clock
+}
+
+var (
+	_ broker.ProfileReader = (*Brokers)(nil)
+	_ broker.SettingsStore = (*Brokers)(nil)
+)
+
+func NewBrokers() *Brokers {
+	return &Brokers{
+		profiles: make(map[string]broker.Profile),
+		settings: make(map[string]broker.Settings),
+		clock:    clock.New(),
+	}
+}
+
+// WithClock overrides the time source for CreatedAt and settings' UpdatedAt.
+func (b *Brokers) WithClock(c clock.Clock) *Brokers {
+	b.clock = clock.OrReal(c)
+	return b
+}
+
+// Add stores profile, stamping CreatedAt when it is zero.
+func (b *Brokers) Add(profile broker.Profile) broker.Profile {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = b.clock.Now()
+	}
+	b.profiles[profile.ID] = profile
+	return profile
+}
+
+func (b *Brokers) GetByID(_ context.Context, id string) (broker.Profile, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.profiles[id]
+	if !ok {
+		return broker.Profile{}, broker.ErrNotFound
+	}
+	return p, nil
+}
+
+// List returns up to limit profiles ordered by name, capping limit at 100
+// like the repository.
+func (b *Brokers) List(_ context.Context, limit int) ([]broker.Profile, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]broker.Profile, 0, len(b.profiles))
+	for _, p := range b.profiles {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// GetSettings returns the stored policy, or the defaults for a broker that
+// has not saved one.
+func (b *Brokers) GetSettings(ctx context.Context, brokerID string) (broker.Settings, error) {
+	if _, err := b.GetByID(ctx, brokerID); err != nil {
+		return broker.Settings{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.settings[brokerID]; ok {
+		return s, nil
+	}
+	return broker.DefaultSettings(brokerID), nil
+}
+
+// policy is brokerID's stored policy or the defaults, without requiring the
+// broker to exist, like broker.LoadSettings.
+func (b *Brokers) policy(brokerID string) broker.Settings {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.settings[brokerID]; ok {
+		return s
+	}
+	return broker.DefaultSettings(brokerID)
+}
+
+func (b *Brokers) SaveSettings(ctx context.Context, s broker.Settings, _ string) (broker.Settings, error) {
+	if _, err := b.GetByID(ctx, s.BrokerID); err != nil {
+		return broker.Settings{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	s.UpdatedAt = &now
+	b.settings[s.BrokerID] = s
+	return s, nil
+}
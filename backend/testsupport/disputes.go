@@ -0,0 +1,209 @@
+package testsupport
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"brokerflow/agreement"
+	"brokerflow/clock"
+	"brokerflow/dispute"
+	"brokerflow/tenancy"
+
+	"github.com/google/uuid"
+)
+
+// Disputes implements dispute.Store over an Agreements fake, which decides
+// who owns an agreement's referral and which brokers are party to it.
+type Disputes struct {
+	mu         sync.Mutex
+	records    map[string]dispute.Record
+	agreements *Agreements
+	clock      clock.Clock
+	sla        dispute.SLA
+	newID      func() string
+}
+
+var _ dispute.Store = (*Disputes)(nil)
+
+func NewDisputes(agreements *Agreements) *Disputes {
+	return &Disputes{records: make(map[string]dispute.Record), agreements: agreements, clock: clock.New(), sla: dispute.DefaultSLA(), newID: uuid.NewString}
+}
+
+// WithSLA sets the review deadline given to new and escalated disputes.
+func (d *Disputes) WithSLA(sla dispute.SLA) *Disputes {
+	d.sla = sla
+	return d
+}
+
+// WithClock overrides the time source for timestamps and ResolvedAt.
+func (d *Disputes) WithClock(c clock.Clock) *Disputes {
+	d.clock = clock.OrReal(c)
+	return d
+}
+
+// WithIDGenerator overrides how ids of new disputes are made.
+func (d *Disputes) WithIDGenerator(gen func() string) *Disputes {
+	d.newID = gen
+	return d
+}
+
+func (d *Disputes) List(_ context.Context, scope tenancy.Scope, agreementID string) ([]dispute.Record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]dispute.Record, 0, 8)
+	for _, rec := range d.records {
+		if agreementID != "" && rec.AgreementID != agreementID {
+			continue
+		}
+		if d.visible(scope, rec.AgreementID) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Create opens a dispute for either party; like the repository it answers
+// ErrForbidden both for unknown agreements and for callers party to neither
+// side.
+func (d *Disputes) Create(_ context.Context, scope tenancy.Scope, p dispute.CreateParams) (dispute.Record, error) {
+	agreementID := p.AgreementID
+	if !d.visible(scope, agreementID) {
+		return dispute.Record{}, dispute.ErrForbidden
+	}
+	role := dispute.RoleReferrer
+	if ag, _ := d.agreements.Get(agreementID); d.owner(agreementID) != scope.UserID && d.refereeMember(scope.UserID, ag) {
+		role = dispute.RoleReferee
+	}
+	opener := scope.UserID
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.clock.Now()
+	deadline := now.Add(d.sla.Review)
+	rec := dispute.Record{
+		ID:             d.newID(),
+		AgreementID:    agreementID,
+		Status:         dispute.StatusUnderReview,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ReviewDeadline: &deadline,
+		OpenedBy:       &opener,
+		OpenedByRole:   role,
+		Reason:         p.Reason,
+		Detail:         p.Detail,
+	}
+	d.records[rec.ID] = rec
+	if ag, ok := d.agreements.Get(agreementID); ok {
+		d.agreements.referrals.project(ag.RequestID, (agreement.ReferralProjector{}).ForDisputeOpened())
+	}
+	return rec, nil
+}
+
+// Escalate moves a dispute up a tier with reviewerID assigned, as the
+// escalation job does when its deadline passes.
+func (d *Disputes) Escalate(disputeID, reviewerID string) (dispute.Record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rec, ok := d.records[disputeID]
+	if !ok {
+		return dispute.Record{}, dispute.ErrNotFound
+	}
+	if rec.Status == dispute.StatusResolved {
+		return dispute.Record{}, dispute.ErrBadStatus
+	}
+	return d.escalateLocked(rec, &reviewerID), nil
+}
+
+// EscalateDue escalates the open disputes whose review deadline has passed
+// by the clock, like dispute.EscalationService.EscalateDue, earliest
+// deadline first. The fake has no broker admins to pick from, so each
+// keeps its assigned reviewer.
+func (d *Disputes) EscalateDue(context.Context) ([]dispute.Escalation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.clock.Now()
+	var due []dispute.Record
+	for _, rec := range d.records {
+		if rec.Status != dispute.StatusResolved && rec.ReviewDeadline != nil && !rec.ReviewDeadline.After(now) {
+			due = append(due, rec)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].ReviewDeadline.Equal(*due[j].ReviewDeadline) {
+			return due[i].ReviewDeadline.Before(*due[j].ReviewDeadline)
+		}
+		return due[i].ID < due[j].ID
+	})
+	out := make([]dispute.Escalation, 0, len(due))
+	for _, rec := range due {
+		rec = d.escalateLocked(rec, rec.AssignedReviewerID)
+		out = append(out, dispute.Escalation{DisputeID: rec.ID, AgreementID: rec.AgreementID, Tier: rec.EscalationTier,
+			ReviewerID: rec.AssignedReviewerID, ReviewDeadline: rec.ReviewDeadline})
+	}
+	return out, nil
+}
+
+func (d *Disputes) escalateLocked(rec dispute.Record, reviewerID *string) dispute.Record {
+	now := d.clock.Now()
+	rec.Status = dispute.StatusEscalated
+	rec.EscalationTier++
+	rec.EscalatedAt = &now
+	rec.UpdatedAt = now
+	rec.AssignedReviewerID = reviewerID
+	rec.ReviewDeadline = nil
+	if rec.EscalationTier < d.sla.MaxTier {
+		deadline := now.Add(d.sla.Escalation)
+		rec.ReviewDeadline = &deadline
+	}
+	d.records[rec.ID] = rec
+	return rec
+}
+
+// Resolve answers ErrForbidden unless actorID owns the referral or is the
+// dispute's assigned reviewer.
+func (d *Disputes) Resolve(_ context.Context, actorID, disputeID string) (dispute.Record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rec, ok := d.records[disputeID]
+	if !ok || actorID == "" {
+		return dispute.Record{}, dispute.ErrForbidden
+	}
+	reviewer := rec.AssignedReviewerID != nil && *rec.AssignedReviewerID == actorID
+	if d.owner(rec.AgreementID) != actorID && !reviewer {
+		return dispute.Record{}, dispute.ErrForbidden
+	}
+	if rec.Status == dispute.StatusResolved {
+		return dispute.Record{}, dispute.ErrBadStatus
+	}
+	now := d.clock.Now()
+	rec.Status = dispute.StatusResolved
+	rec.UpdatedAt = now
+	rec.ResolvedAt = &now
+	rec.ReviewDeadline = nil
+	d.records[disputeID] = rec
+	return rec, nil
+}
+
+func (d *Disputes) owner(agreementID string) string {
+	rec, ok := d.agreements.Get(agreementID)
+	if !ok {
+		return ""
+	}
+	return d.agreements.referrals.Owner(rec.RequestID)
+}
+
+// visible mirrors the repository's party predicate: the referrer side as
+// tenancy.Scope.PartyTo sees it, plus every user of the referee broker.
+func (d *Disputes) visible(scope tenancy.Scope, agreementID string) bool {
+	rec, ok := d.agreements.Get(agreementID)
+	if !ok {
+		return false
+	}
+	return d.agreements.users.partyTo(scope, d.agreements.referrals.Owner(rec.RequestID), rec.ReferrerBrokerID, rec.RefereeBrokerID) ||
+		d.refereeMember(scope.UserID, rec)
+}
+
+func (d *Disputes) refereeMember(userID string, rec agreement.Record) bool {
+	return rec.RefereeBrokerID != "" && d.agreements.users.BrokerOf(userID) == rec.RefereeBrokerID
+}
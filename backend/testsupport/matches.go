@@ -0,0 +1,272 @@
+package testsupport
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+
+	"github.com/google/uuid"
+)
+
+// Matches implements referral.MatchRepository. Pair it with
+// referral.NewMatchService without an agreement repository: accepting then
+// only updates the match, and the owner creates the agreement.
+type Matches struct {
+	mu        sync.Mutex
+	matches   []referral.Match
+	referrals *Referrals
+	users     *Users
+	clock     clock.Clock
+	newID     func() string
+}
+
+var _ referral.MatchRepository = (*Matches)(nil)
+
+// inviteTTL is the default match TTL the referral_matches trigger applies.
+const inviteTTL = referral.DefaultMatchTTLHours * time.Hour
+
+// NewMatches builds an empty store over referrals, whose owners it checks,
+// and users, which resolves broker-wide scopes.
+func NewMatches(referrals *Referrals, users *Users) *Matches {
+	return &Matches{referrals: referrals, users: users, clock: clock.New(), newID: uuid.NewString}
+}
+
+// WithClock overrides the time source for CreatedAt and invitation expiry.
+func (m *Matches) WithClock(c clock.Clock) *Matches {
+	m.clock = clock.OrReal(c)
+	return m
+}
+
+// WithIDGenerator overrides how ids of new matches are made.
+func (m *Matches) WithIDGenerator(gen func() string) *Matches {
+	m.newID = gen
+	return m
+}
+
+// ExpireDue moves invitations past their expiry by the clock to expired,
+// like referral.MatchExpiryService.ExpireDue, earliest expiry first.
+func (m *Matches) ExpireDue(context.Context) ([]referral.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	var expired []referral.Match
+	for i := range m.matches {
+		match := &m.matches[i]
+		if match.State == referral.MatchStateInvited && match.ExpiresAt != nil && !match.ExpiresAt.After(now) {
+			match.State = referral.MatchStateExpired
+			expired = append(expired, *match)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(*expired[j].ExpiresAt) })
+	return expired, nil
+}
+
+func (m *Matches) List(_ context.Context, requestID string, scope tenancy.Scope) ([]referral.Match, error) {
+	owner := m.referrals.Owner(requestID)
+	if owner == "" || !m.users.owns(scope, owner) {
+		return nil, referral.ErrReferralNotOwned
+	}
+	return m.filter(func(match referral.Match) bool { return match.RequestID == requestID }), nil
+}
+
+// Create invites a candidate, reusing an expired or applied match of the
+// same candidate like the repository's upsert.
+func (m *Matches) Create(_ context.Context, params referral.CreateMatchParams) (referral.Match, error) {
+	if params.CandidateAgentID == "" {
+		return referral.Match{}, referral.ErrCandidateMandatory
+	}
+	if params.State == "" {
+		params.State = referral.MatchStateInvited
+	}
+	if params.Score < 0 || params.Score > 1 {
+		return referral.Match{}, referral.ErrMatchInvalidScore
+	}
+	switch params.State {
+	case referral.MatchStateInvited, referral.MatchStateAccepted, referral.MatchStateDeclined:
+	default:
+		return referral.Match{}, referral.ErrMatchInvalidState
+	}
+	if m.referrals.Owner(params.RequestID) != params.OwnerUserID || params.OwnerUserID == "" {
+		return referral.Match{}, referral.ErrReferralNotOwned
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.matches, func(match referral.Match) bool {
+		return match.RequestID == params.RequestID && match.CandidateAgentID == params.CandidateAgentID
+	})
+	if i >= 0 {
+		if s := m.matches[i].State; s != referral.MatchStateExpired && s != referral.MatchStateApplied {
+			return referral.Match{}, referral.ErrMatchDuplicate
+		}
+		m.matches[i].State, m.matches[i].Score = params.State, params.Score
+		m.stampLocked(&m.matches[i])
+		return m.matches[i], nil
+	}
+	match := referral.Match{
+		ID:               m.newID(),
+		RequestID:        params.RequestID,
+		CandidateAgentID: params.CandidateAgentID,
+		State:            params.State,
+		Score:            params.Score,
+		CreatedAt:        m.clock.Now(),
+	}
+	m.stampLocked(&match)
+	m.matches = append(m.matches, match)
+	return match, nil
+}
+
+// stampLocked sets the expiry of a match entering invited, as the
+// referral_matches trigger does.
+func (m *Matches) stampLocked(match *referral.Match) {
+	if match.State == referral.MatchStateInvited {
+		expires := m.clock.Now().Add(inviteTTL)
+		match.ExpiresAt = &expires
+	}
+}
+
+func (m *Matches) CreateBulk(ctx context.Context, params referral.BulkCreateMatchParams) ([]referral.BulkMatchResult, error) {
+	if len(params.Candidates) == 0 {
+		return nil, referral.ErrBulkMatchesEmpty
+	}
+	if len(params.Candidates) > referral.MaxBulkMatches {
+		return nil, referral.ErrBulkMatchesTooMany
+	}
+	results := make([]referral.BulkMatchResult, 0, len(params.Candidates))
+	for _, c := range params.Candidates {
+		match, err := m.Create(ctx, referral.CreateMatchParams{
+			RequestID:        params.RequestID,
+			OwnerUserID:      params.OwnerUserID,
+			CandidateAgentID: c.CandidateAgentID,
+			Score:            c.Score,
+			State:            c.State,
+		})
+		res := referral.BulkMatchResult{CandidateAgentID: c.CandidateAgentID}
+		switch err {
+		case nil:
+			res.Status, res.Match = referral.BulkMatchCreated, &match
+		case referral.ErrMatchDuplicate:
+			res.Status = referral.BulkMatchDuplicate
+		case referral.ErrReferralNotOwned:
+			return nil, err
+		default:
+			res.Status, res.Err = referral.BulkMatchInvalid, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// ListForCandidate leaves out expired invitations, marked or not.
+func (m *Matches) ListForCandidate(_ context.Context, candidateID string) ([]referral.Match, error) {
+	now := m.clock.Now()
+	return m.filter(func(match referral.Match) bool {
+		if match.CandidateAgentID != candidateID || match.State == referral.MatchStateExpired {
+			return false
+		}
+		return match.State != referral.MatchStateInvited || match.ExpiresAt == nil || now.Before(*match.ExpiresAt)
+	}), nil
+}
+
+func (m *Matches) GetByID(_ context.Context, matchID string) (referral.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.indexLocked(matchID); i >= 0 {
+		return m.matches[i], nil
+	}
+	return referral.Match{}, referral.ErrMatchNotFound
+}
+
+func (m *Matches) UpdateState(_ context.Context, matchID string, state referral.MatchState, decline referral.Decline) (referral.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexLocked(matchID)
+	if i < 0 {
+		return referral.Match{}, referral.ErrMatchNotFound
+	}
+	match := &m.matches[i]
+	match.State, match.DeclineReason, match.DeclineNote = state, nil, nil
+	if decline.Reason != "" {
+		reason := decline.Reason
+		match.DeclineReason = &reason
+	}
+	if decline.Note != "" {
+		note := decline.Note
+		match.DeclineNote = &note
+	}
+	if state != referral.MatchStateAccepted {
+		match.CounterFeeRate, match.CounterProtectDays = nil, nil
+	}
+	return *match, nil
+}
+
+// Counter does not apply the referring broker's policy bounds.
+func (m *Matches) Counter(_ context.Context, matchID string, terms referral.CounterTerms) (referral.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexLocked(matchID)
+	if i < 0 {
+		return referral.Match{}, referral.ErrMatchNotFound
+	}
+	match := &m.matches[i]
+	switch {
+	case match.State == referral.MatchStateExpired,
+		match.State == referral.MatchStateInvited && match.ExpiresAt != nil && !m.clock.Now().Before(*match.ExpiresAt):
+		return referral.Match{}, referral.ErrMatchExpired
+	case match.State != referral.MatchStateInvited:
+		return referral.Match{}, referral.ErrMatchInvalidTransition
+	}
+	match.State = referral.MatchStateCountered
+	match.CounterFeeRate, match.CounterProtectDays = &terms.FeeRate, &terms.ProtectDays
+	return *match, nil
+}
+
+func (m *Matches) ResolveCounter(_ context.Context, matchID, ownerID string, outcome referral.MatchState) (referral.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexLocked(matchID)
+	if i < 0 {
+		return referral.Match{}, referral.ErrMatchNotFound
+	}
+	match := &m.matches[i]
+	if m.referrals.Owner(match.RequestID) != ownerID {
+		return referral.Match{}, referral.ErrReferralNotOwned
+	}
+	countered := match.CounterFeeRate != nil && match.CounterProtectDays != nil
+	if match.State == referral.MatchStateAccepted && outcome == referral.MatchStateAccepted && countered {
+		return *match, nil
+	}
+	if match.State != referral.MatchStateCountered || !countered {
+		return referral.Match{}, referral.ErrCounterNotPending
+	}
+	match.State = outcome
+	if outcome == referral.MatchStateInvited {
+		match.CounterFeeRate, match.CounterProtectDays = nil, nil
+		m.stampLocked(match)
+	}
+	return *match, nil
+}
+
+// filter returns the matches keep selects, newest first.
+func (m *Matches) filter(keep func(referral.Match) bool) []referral.Match {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []referral.Match{}
+	for _, match := range m.matches {
+		if keep(match) {
+			out = append(out, match)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+func (m *Matches) indexLocked(id string) int {
+	return slices.IndexFunc(m.matches, func(match referral.Match) bool { return match.ID == id })
+}
@@ -0,0 +1,153 @@
+package testsupport
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"brokerflow/broker"
+	"brokerflow/clock"
+
+	"github.com/google/uuid"
+)
+
+// Offices implements broker.OfficeStore over a Users fake, which records
+// each user's office the way users.office_id does.
+type Offices struct {
+	mu      sync.Mutex
+	offices map[string]broker.Office
+	users   *Users
+	clock   clock.Clock
+}
+
+var _ broker.OfficeStore = (*Offices)(nil)
+
+func NewOffices(users *Users) *Offices {
+	return &Offices{offices: make(map[string]broker.Office), users: users, clock: clock.New()}
+}
+
+// WithClock overrides the time source for CreatedAt/UpdatedAt.
+func (o *Offices) WithClock(c clock.Clock) *Offices {
+	o.clock = clock.OrReal(c)
+	return o
+}
+
+func (o *Offices) CreateOffice(_ context.Context, params broker.OfficeParams) (broker.Office, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.nameTakenLocked(params.BrokerID, "", params.Name) {
+		return broker.Office{}, broker.ErrOfficeNameTaken
+	}
+	now := o.clock.Now()
+	office := broker.Office{
+		ID:          uuid.NewString(),
+		BrokerID:    params.BrokerID,
+		Name:        params.Name,
+		ScopeAdmins: params.ScopeAdmins,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	o.offices[office.ID] = office
+	return office, nil
+}
+
+// ListOffices orders by name case-insensitively, like the repository.
+func (o *Offices) ListOffices(_ context.Context, brokerID string) ([]broker.Office, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make([]broker.Office, 0, len(o.offices))
+	for _, office := range o.offices {
+		if office.BrokerID == brokerID {
+			out = append(out, o.withAgents(office))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
+	return out, nil
+}
+
+func (o *Offices) GetOffice(_ context.Context, brokerID, id string) (broker.Office, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	office, ok := o.offices[id]
+	if !ok || office.BrokerID != brokerID {
+		return broker.Office{}, broker.ErrOfficeNotFound
+	}
+	return o.withAgents(office), nil
+}
+
+func (o *Offices) UpdateOffice(_ context.Context, brokerID, id string, update broker.OfficeUpdate) (broker.Office, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	office, ok := o.offices[id]
+	if !ok || office.BrokerID != brokerID {
+		return broker.Office{}, broker.ErrOfficeNotFound
+	}
+	if update.Name != nil {
+		if o.nameTakenLocked(brokerID, id, *update.Name) {
+			return broker.Office{}, broker.ErrOfficeNameTaken
+		}
+		office.Name = *update.Name
+	}
+	if update.ScopeAdmins != nil {
+		office.ScopeAdmins = *update.ScopeAdmins
+	}
+	office.UpdatedAt = o.clock.Now()
+	o.offices[id] = office
+	return o.withAgents(office), nil
+}
+
+// DeleteOffice unassigns the office's users like ON DELETE SET NULL.
+func (o *Offices) DeleteOffice(_ context.Context, brokerID, id string) ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	office, ok := o.offices[id]
+	if !ok || office.BrokerID != brokerID {
+		return nil, broker.ErrOfficeNotFound
+	}
+	members := o.membersLocked(id)
+	delete(o.offices, id)
+	for _, userID := range members {
+		o.users.setOffice(userID, "")
+	}
+	return members, nil
+}
+
+func (o *Offices) AssignOffice(_ context.Context, brokerID, userID, officeID string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if officeID != "" {
+		if office, ok := o.offices[officeID]; !ok || office.BrokerID != brokerID {
+			return broker.ErrOfficeNotFound
+		}
+	}
+	if o.users.BrokerOf(userID) != brokerID {
+		return broker.ErrUserNotInBroker
+	}
+	o.users.setOffice(userID, officeID)
+	return nil
+}
+
+func (o *Offices) nameTakenLocked(brokerID, exceptID, name string) bool {
+	for id, office := range o.offices {
+		if id != exceptID && office.BrokerID == brokerID && strings.EqualFold(office.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Offices) membersLocked(officeID string) []string {
+	var ids []string
+	for _, userID := range o.users.BrokerUsers(o.offices[officeID].BrokerID) {
+		if o.users.OfficeOf(userID) == officeID {
+			ids = append(ids, userID)
+		}
+	}
+	return ids
+}
+
+func (o *Offices) withAgents(office broker.Office) broker.Office {
+	office.Agents = len(o.membersLocked(office.ID))
+	return office
+}
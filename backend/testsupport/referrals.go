@@ -0,0 +1,238 @@
+package testsupport
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"brokerflow/agreement"
+	"brokerflow/clock"
+	"brokerflow/db"
+	"brokerflow/money"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Referrals implements referral.Repository. Pair it with a TxBeginner to run
+// referral.Service without a database.
+type Referrals struct {
+	mu       sync.Mutex
+	requests map[string]referral.Request
+	users    *Users
+	clock    clock.Clock
+}
+
+var _ referral.Repository = (*Referrals)(nil)
+
+// NewReferrals builds an empty store; users resolves broker-wide scopes.
+func NewReferrals(users *Users) *Referrals {
+	return &Referrals{requests: make(map[string]referral.Request), users: users, clock: clock.New()}
+}
+
+// WithClock overrides the time source for CreatedAt/UpdatedAt.
+func (r *Referrals) WithClock(c clock.Clock) *Referrals {
+	r.clock = clock.OrReal(c)
+	return r
+}
+
+func (r *Referrals) Create(_ context.Context, _ pgx.Tx, req referral.Request) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	req.CreatedAt, req.UpdatedAt = now, now
+	req.Version = 1
+	r.requests[req.ID] = req
+	return req, nil
+}
+
+// List applies the repository's filters, defaults and paging. Sorting is by
+// created_at only.
+func (r *Referrals) List(_ context.Context, filters referral.Filters) ([]referral.Request, db.Page, error) {
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	matched := []referral.Request{}
+	for _, req := range r.requests {
+		if filters.Scope != (tenancy.Scope{}) && !r.users.owns(filters.Scope, req.CreatorUserID) {
+			continue
+		}
+		if !filters.IncludeArchived && req.ArchivedAt != nil {
+			continue
+		}
+		if filters.Status != "" && req.Status != filters.Status {
+			continue
+		}
+		if filters.Region != "" && !slices.Contains(req.Region, filters.Region) {
+			continue
+		}
+		if filters.DealType != "" && req.DealType != filters.DealType {
+			continue
+		}
+		matched = append(matched, req)
+	}
+	asc := strings.EqualFold(filters.SortOrder, "asc")
+	sort.Slice(matched, func(i, j int) bool {
+		if asc {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	start := min((filters.Page-1)*filters.PageSize, total)
+	end := min(start+filters.PageSize, total)
+	return matched[start:end], db.Page{Total: total, TotalExact: true, HasMore: end < total}, nil
+}
+
+func (r *Referrals) GetForUpdate(_ context.Context, _ pgx.Tx, id string) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.requests[id]
+	if !ok {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	return req, nil
+}
+
+func (r *Referrals) GetScopedForUpdate(_ context.Context, _ pgx.Tx, id string, scope tenancy.Scope) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.requests[id]
+	if !ok || !r.users.owns(scope, req.CreatorUserID) {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	return req, nil
+}
+
+// Update writes the editable fields and bumps Version when any changed, as
+// the referral_requests trigger does.
+func (r *Referrals) Update(_ context.Context, _ pgx.Tx, req referral.Request) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.requests[req.ID]
+	if !ok {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	next := cur
+	next.Region, next.Languages = req.Region, req.Languages
+	next.PriceMin, next.PriceMax, next.Currency = req.PriceMin, req.PriceMax, req.Currency
+	next.PropertyType, next.DealType = req.PropertyType, req.DealType
+	next.SLAHours, next.MatchTTLHours = req.SLAHours, req.MatchTTLHours
+	if !slices.Equal(next.Region, cur.Region) || !slices.Equal(next.Languages, cur.Languages) ||
+		next.PriceMin != cur.PriceMin || next.PriceMax != cur.PriceMax || next.Currency != cur.Currency ||
+		next.PropertyType != cur.PropertyType || next.DealType != cur.DealType ||
+		next.SLAHours != cur.SLAHours || next.MatchTTLHours != cur.MatchTTLHours {
+		next.Version++
+	}
+	next.UpdatedAt = r.clock.Now()
+	r.requests[req.ID] = next
+	return next, nil
+}
+
+func (r *Referrals) UpdateStatus(_ context.Context, _ pgx.Tx, id string, status referral.Status, cancelReason *string) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.requests[id]
+	if !ok {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	if req.Status != status || !equalPtr(req.CancelReason, cancelReason) {
+		req.Version++
+	}
+	req.Status = status
+	req.CancelReason = cancelReason
+	req.UpdatedAt = r.clock.Now()
+	r.requests[id] = req
+	return req, nil
+}
+
+// Archive sets ArchivedAt and bumps Version, as the referral_requests
+// trigger does.
+func (r *Referrals) Archive(_ context.Context, _ pgx.Tx, id string) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.requests[id]
+	if !ok {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	now := r.clock.Now()
+	if req.ArchivedAt == nil {
+		req.Version++
+	}
+	req.ArchivedAt = &now
+	req.UpdatedAt = now
+	r.requests[id] = req
+	return req, nil
+}
+
+func (r *Referrals) FindDuplicate(_ context.Context, _ pgx.Tx, q referral.DuplicateQuery) (referral.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found referral.Request
+	ok := false
+	for _, req := range r.requests {
+		if req.CreatorUserID != q.CreatorUserID || req.CreatedAt.Before(q.Since) ||
+			req.Status == referral.StatusCancelled || req.ArchivedAt != nil ||
+			req.DealType != q.DealType || req.Currency != q.Currency || req.PriceMin > q.PriceMax || req.PriceMax < q.PriceMin ||
+			!slices.ContainsFunc(req.Region, func(region string) bool { return slices.Contains(q.Region, region) }) {
+			continue
+		}
+		if !ok || req.CreatedAt.After(found.CreatedAt) {
+			found, ok = req, true
+		}
+	}
+	if !ok {
+		return referral.Request{}, referral.ErrNotFound
+	}
+	return found, nil
+}
+
+// project moves referral id to next when agreement.ReferralProjector
+// allows it, as the agreement write paths do in their transaction.
+func (r *Referrals) project(id, next string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.requests[id]
+	if !ok || !(agreement.ReferralProjector{}).Advances(string(req.Status), next) {
+		return
+	}
+	req.Status = referral.Status(next)
+	req.Version++
+	req.UpdatedAt = r.clock.Now()
+	r.requests[id] = req
+}
+
+// Owner returns the referral's creator, or "" when it does not exist.
+func (r *Referrals) Owner(id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[id].CreatorUserID
+}
+
+// currency is the referral's price currency, which agreements on it copy
+// like the agreements_copy_currency trigger.
+func (r *Referrals) currency(id string) money.Currency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.requests[id].Currency; c != "" {
+		return c
+	}
+	return money.DefaultCurrency
+}
+
+func equalPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
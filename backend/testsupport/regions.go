@@ -0,0 +1,102 @@
+package testsupport
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"brokerflow/region"
+)
+
+// Regions implements region.Store. Pair it with region.NewService to
+// resolve region codes without a database; boundaries are ignored.
+type Regions struct {
+	mu      sync.Mutex
+	regions map[string]region.Region
+}
+
+var _ region.Store = (*Regions)(nil)
+
+func NewRegions() *Regions {
+	return &Regions{regions: make(map[string]region.Region)}
+}
+
+// Add stores r with its Path derived from its parent, which must have been
+// added first, as the regions table requires.
+func (s *Regions) Add(r region.Region) region.Region {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.Path = []string{r.Code}
+	if r.ParentCode != nil {
+		r.Path = append(slices.Clone(s.regions[*r.ParentCode].Path), r.Code)
+	}
+	s.regions[r.Code] = r
+	return r
+}
+
+func (s *Regions) Get(_ context.Context, code string) (region.Region, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.regions[code]
+	if !ok {
+		return region.Region{}, region.ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *Regions) List(_ context.Context, f region.Filter) ([]region.Region, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := region.Canonical(f.Query)
+	var out []region.Region
+	for _, r := range s.regions {
+		if f.Parent != "" && (r.ParentCode == nil || *r.ParentCode != f.Parent) {
+			continue
+		}
+		if f.Within != "" && !r.Within(f.Within) {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(r.Code, prefix) && !strings.HasPrefix(region.Canonical(r.Name), prefix) &&
+			!slices.ContainsFunc(r.Aliases, func(a string) bool { return strings.HasPrefix(a, prefix) }) {
+			continue
+		}
+		r.Boundary = nil
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Code < out[j].Code
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// Resolve follows region_resolve: a code wins, otherwise an alias held by
+// exactly one region.
+func (s *Regions) Resolve(_ context.Context, values []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make(map[string]string, len(values))
+	for _, v := range values {
+		if _, ok := s.regions[v]; ok {
+			codes[v] = v
+			continue
+		}
+		var matches []string
+		for code, r := range s.regions {
+			if slices.Contains(r.Aliases, v) {
+				matches = append(matches, code)
+			}
+		}
+		if len(matches) == 1 {
+			codes[v] = matches[0]
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,284 @@
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"brokerflow/agreement"
+	"brokerflow/auth"
+	"brokerflow/clock"
+	"brokerflow/dispute"
+	"brokerflow/referral"
+	"brokerflow/tenancy"
+)
+
+func TestReferralServiceOverFakes(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	brokerID := "b1"
+	users.Add(auth.User{ID: "agent-1", BrokerID: &brokerID})
+	users.Add(auth.User{ID: "agent-2", BrokerID: &brokerID})
+	pool := &TxBeginner{}
+	svc := referral.NewService(pool, NewReferrals(users), nil, nil)
+
+	params := referral.CreateParams{Region: []string{"Austin"}, PriceMin: 1, PriceMax: 2, SLAHours: 24}
+	params.CreatorUserID = "agent-1"
+	created, err := svc.Create(ctx, params)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	params.CreatorUserID = "agent-2"
+	if _, err := svc.Create(ctx, params); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if pool.Commits() != 2 || pool.Rollbacks() != 0 {
+		t.Fatalf("expected 2 commits and no rollbacks, got %d/%d", pool.Commits(), pool.Rollbacks())
+	}
+
+	own, err := svc.List(ctx, referral.Filters{Scope: tenancy.User("agent-1")})
+	if err != nil || own.Total != 1 || own.Items[0].ID != created.ID {
+		t.Fatalf("user scope: %+v, %v", own, err)
+	}
+	all, err := svc.List(ctx, referral.Filters{Scope: tenancy.Broker("agent-1", brokerID)})
+	if err != nil || all.Total != 2 {
+		t.Fatalf("broker scope: %+v, %v", all, err)
+	}
+
+	cancelled, err := svc.Cancel(ctx, referral.CancelParams{RequestID: created.ID, ActorID: "agent-1", ActorRole: "agent"})
+	if err != nil || cancelled.Status != referral.StatusCancelled {
+		t.Fatalf("cancel: %+v, %v", cancelled, err)
+	}
+	if _, err := svc.Cancel(ctx, referral.CancelParams{RequestID: "missing", ActorID: "agent-1", ActorRole: "agent"}); !errors.Is(err, referral.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAgreementsKeepServiceRules(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	brokerID := "b1"
+	users.Add(auth.User{ID: "owner", BrokerID: &brokerID})
+	referrals := NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	agreements := NewAgreements(referrals, users)
+	params := agreement.CreateParams{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}
+
+	if _, err := agreements.Create(ctx, "stranger", params); err == nil {
+		t.Fatalf("expected non-owners to be refused")
+	}
+	other := params
+	other.ReferrerBrokerID = "b3"
+	if _, err := agreements.Create(ctx, "owner", other); !errors.Is(err, agreement.ErrReferrerBrokerMismatch) {
+		t.Fatalf("expected ErrReferrerBrokerMismatch, got %v", err)
+	}
+	rec, err := agreements.Create(ctx, "owner", params)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := agreements.Create(ctx, "owner", params); !errors.Is(err, agreement.ErrActiveAgreementExists) {
+		t.Fatalf("expected ErrActiveAgreementExists, got %v", err)
+	}
+
+	if _, err := agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, NextStatus: "effective"}); err == nil {
+		t.Fatalf("expected draft -> effective to be refused")
+	}
+	var conflict *agreement.VersionConflictError
+	if _, err := agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, NextStatus: "pending_signature", IfVersion: rec.Version + 1}); !errors.As(err, &conflict) || conflict.Version != rec.Version {
+		t.Fatalf("expected a version conflict at %d, got %v", rec.Version, err)
+	}
+	version := rec.Version
+	for _, next := range []string{"pending_signature", "void"} {
+		v, err := agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, NextStatus: next, IfVersion: version})
+		if err != nil {
+			t.Fatalf("transition to %s: %v", next, err)
+		}
+		if v != version+1 {
+			t.Fatalf("transition to %s: expected version %d, got %d", next, version+1, v)
+		}
+		version = v
+	}
+	if _, err := agreements.Create(ctx, "owner", params); err != nil {
+		t.Fatalf("expected a void agreement to free the referral, got %v", err)
+	}
+}
+
+func TestAgreementsProjectReferralStatus(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	referrals := NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner", Status: referral.StatusMatched})
+	agreements := NewAgreements(referrals, users)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "pending_signature")
+	status := func() referral.Status {
+		req, _ := referrals.GetForUpdate(ctx, nil, "ref-1")
+		return req.Status
+	}
+
+	if _, err := agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, NextStatus: "effective"}); err != nil {
+		t.Fatalf("transition to effective: %v", err)
+	}
+	if got := status(); got != referral.StatusSigned {
+		t.Fatalf("expected effective to sign the referral, got %s", got)
+	}
+	if _, err := NewDisputes(agreements).Create(ctx, tenancy.User("owner"), nonPayment(rec.ID)); err != nil {
+		t.Fatalf("open dispute: %v", err)
+	}
+	if got := status(); got != referral.StatusDisputed {
+		t.Fatalf("expected a dispute to mark the referral disputed, got %s", got)
+	}
+	if _, err := agreements.Transition(ctx, agreement.TransitionParams{AgreementID: rec.ID, NextStatus: "closed"}); err != nil {
+		t.Fatalf("transition to closed: %v", err)
+	}
+	if got := status(); got != referral.StatusDisputed {
+		t.Fatalf("expected the referral to stay disputed, got %s", got)
+	}
+}
+
+func TestDisputesOwnerOnly(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	referrals := NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	agreements := NewAgreements(referrals, users)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "effective")
+	disputes := NewDisputes(agreements)
+	var _ dispute.Store = disputes
+
+	if _, err := disputes.Create(ctx, tenancy.User("stranger"), nonPayment(rec.ID)); !errors.Is(err, dispute.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	d, err := disputes.Create(ctx, tenancy.User("owner"), nonPayment(rec.ID))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	list, _ := disputes.List(ctx, tenancy.Broker("admin", "b2"), "")
+	if len(list) != 1 {
+		t.Fatalf("expected the party broker to see the dispute, got %d", len(list))
+	}
+	if _, err := disputes.Resolve(ctx, "owner", d.ID); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if _, err := disputes.Resolve(ctx, "owner", d.ID); !errors.Is(err, dispute.ErrBadStatus) {
+		t.Fatalf("expected ErrBadStatus, got %v", err)
+	}
+}
+
+func TestDisputesRefereeBrokerOpens(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	referrals := NewReferrals(users)
+	b2, b3 := "b2", "b3"
+	referee := users.Add(auth.User{ID: "referee", BrokerID: &b2})
+	outsider := users.Add(auth.User{ID: "outsider", BrokerID: &b3})
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	agreements := NewAgreements(referrals, users)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "effective")
+	disputes := NewDisputes(agreements)
+
+	if _, err := disputes.Create(ctx, tenancy.User(outsider.ID), nonPayment(rec.ID)); !errors.Is(err, dispute.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for a third brokerage, got %v", err)
+	}
+	d, err := disputes.Create(ctx, tenancy.User(referee.ID), nonPayment(rec.ID))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if d.OpenedByRole != dispute.RoleReferee || d.OpenedBy == nil || *d.OpenedBy != referee.ID {
+		t.Fatalf("expected a referee dispute, got %+v", d)
+	}
+	for _, scope := range []tenancy.Scope{tenancy.User(referee.ID), tenancy.User("owner")} {
+		if list, _ := disputes.List(ctx, scope, ""); len(list) != 1 {
+			t.Fatalf("expected %+v to see the dispute, got %d", scope, len(list))
+		}
+	}
+	if list, _ := disputes.List(ctx, tenancy.User(outsider.ID), ""); len(list) != 0 {
+		t.Fatalf("expected a third brokerage to see nothing, got %d", len(list))
+	}
+
+	owned, err := disputes.Create(ctx, tenancy.User("owner"), nonPayment(rec.ID))
+	if err != nil || owned.OpenedByRole != dispute.RoleReferrer {
+		t.Fatalf("expected the owner to open a referrer dispute, got %+v, %v", owned, err)
+	}
+}
+
+func TestDisputesAssignedReviewerResolves(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	referrals := NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	agreements := NewAgreements(referrals, users)
+	rec := agreements.Add(agreement.Record{RequestID: "ref-1", ReferrerBrokerID: "b1", RefereeBrokerID: "b2"}, "effective")
+	disputes := NewDisputes(agreements)
+
+	d, err := disputes.Create(ctx, tenancy.User("owner"), nonPayment(rec.ID))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if d.ReviewDeadline == nil {
+		t.Fatal("expected a review deadline on a new dispute")
+	}
+	if _, err := disputes.Resolve(ctx, "admin", d.ID); !errors.Is(err, dispute.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden before escalation, got %v", err)
+	}
+	escalated, err := disputes.Escalate(d.ID, "admin")
+	if err != nil {
+		t.Fatalf("escalate: %v", err)
+	}
+	if escalated.Status != dispute.StatusEscalated || escalated.EscalationTier != 1 {
+		t.Fatalf("unexpected escalated dispute: %+v", escalated)
+	}
+	resolved, err := disputes.Resolve(ctx, "admin", d.ID)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved.ReviewDeadline != nil {
+		t.Fatalf("expected the deadline cleared on resolve, got %v", resolved.ReviewDeadline)
+	}
+}
+
+func nonPayment(agreementID string) dispute.CreateParams {
+	return dispute.CreateParams{AgreementID: agreementID, Reason: dispute.ReasonNonPayment, Detail: "Fee unpaid 30 days after closing"}
+}
+
+func TestMatchesInviteExpireAndReinvite(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsers()
+	referrals := NewReferrals(users)
+	referrals.Create(ctx, nil, referral.Request{ID: "ref-1", CreatorUserID: "owner"})
+	now := clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	matches := NewMatches(referrals, users).WithClock(now)
+	invite := referral.CreateMatchParams{RequestID: "ref-1", OwnerUserID: "owner", CandidateAgentID: "agent"}
+
+	if _, err := matches.Create(ctx, referral.CreateMatchParams{RequestID: "ref-1", OwnerUserID: "other", CandidateAgentID: "agent"}); !errors.Is(err, referral.ErrReferralNotOwned) {
+		t.Fatalf("expected ErrReferralNotOwned, got %v", err)
+	}
+	m, err := matches.Create(ctx, invite)
+	if err != nil || m.State != referral.MatchStateInvited || m.ExpiresAt == nil || !m.ExpiresAt.Equal(now.Now().Add(inviteTTL)) {
+		t.Fatalf("expected a stamped invitation, got %+v, %v", m, err)
+	}
+	if _, err := matches.Create(ctx, invite); !errors.Is(err, referral.ErrMatchDuplicate) {
+		t.Fatalf("expected ErrMatchDuplicate, got %v", err)
+	}
+	if _, err := matches.List(ctx, "ref-1", tenancy.User("agent")); !errors.Is(err, referral.ErrReferralNotOwned) {
+		t.Fatalf("expected the candidate not to list the owner's matches, got %v", err)
+	}
+
+	now.Advance(inviteTTL)
+	if inbox, _ := matches.ListForCandidate(ctx, "agent"); len(inbox) != 0 {
+		t.Fatalf("expected the lapsed invitation hidden, got %+v", inbox)
+	}
+	if _, err := matches.Counter(ctx, m.ID, referral.CounterTerms{FeeRate: 20, ProtectDays: 30}); !errors.Is(err, referral.ErrMatchExpired) {
+		t.Fatalf("expected ErrMatchExpired, got %v", err)
+	}
+	if _, err := matches.UpdateState(ctx, m.ID, referral.MatchStateExpired, referral.Decline{}); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	again, err := matches.Create(ctx, invite)
+	if err != nil || again.ID != m.ID || again.State != referral.MatchStateInvited {
+		t.Fatalf("expected the expired match re-invited in place, got %+v, %v", again, err)
+	}
+	if inbox, _ := matches.ListForCandidate(ctx, "agent"); len(inbox) != 1 {
+		t.Fatalf("expected the new invitation listed, got %+v", inbox)
+	}
+}
@@ -0,0 +1,120 @@
+// Package testsupport provides in-memory implementations of the domain
+// repositories so services and HTTP handlers can be exercised without
+// PostgreSQL. The fakes keep the observable contract of their PostgreSQL
+// counterparts (sentinel errors, tenancy scoping, ordering) but not their
+// SQL: a service that issues statements on the transaction itself still
+// needs a database.
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUnsupported is returned by Tx for any statement; the in-memory
+// repositories ignore the transaction they are handed.
+var ErrUnsupported = errors.New("testsupport: statements are not supported on the in-memory transaction")
+
+// TxBeginner stands in for pgxpool.Pool where a service only needs Begin,
+// such as referral.Service over Referrals. It counts how transactions end.
+type TxBeginner struct {
+	// BeginErr, when set, is returned by Begin.
+	BeginErr error
+
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+func (b *TxBeginner) Begin(context.Context) (pgx.Tx, error) {
+	if b.BeginErr != nil {
+		return nil, b.BeginErr
+	}
+	return &Tx{owner: b}, nil
+}
+
+// Commits reports how many transactions were committed.
+func (b *TxBeginner) Commits() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.commits
+}
+
+// Rollbacks reports how many transactions were rolled back without a
+// commit; the deferred Rollback after a commit is not counted.
+func (b *TxBeginner) Rollbacks() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rollbacks
+}
+
+// Tx is the pgx.Tx handed out by TxBeginner.
+type Tx struct {
+	owner  *TxBeginner
+	closed bool
+}
+
+var _ pgx.Tx = (*Tx)(nil)
+
+func (t *Tx) Begin(context.Context) (pgx.Tx, error) { return nil, ErrUnsupported }
+
+func (t *Tx) Commit(context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	t.owner.mu.Lock()
+	t.owner.commits++
+	t.owner.mu.Unlock()
+	return nil
+}
+
+func (t *Tx) Rollback(context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	t.owner.mu.Lock()
+	t.owner.rollbacks++
+	t.owner.mu.Unlock()
+	return nil
+}
+
+func (t *Tx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func (t *Tx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults { return errBatch{} }
+
+func (t *Tx) LargeObjects() pgx.LargeObjects { return pgx.LargeObjects{} }
+
+func (t *Tx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *Tx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, ErrUnsupported
+}
+
+func (t *Tx) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *Tx) QueryRow(context.Context, string, ...any) pgx.Row { return errRow{} }
+
+func (t *Tx) Conn() *pgx.Conn { return nil }
+
+type errRow struct{}
+
+func (errRow) Scan(...any) error { return ErrUnsupported }
+
+type errBatch struct{}
+
+func (errBatch) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, ErrUnsupported }
+func (errBatch) Query() (pgx.Rows, error)         { return nil, ErrUnsupported }
+func (errBatch) QueryRow() pgx.Row                { return errRow{} }
+func (errBatch) Close() error                     { return ErrUnsupported }
@@ -0,0 +1,163 @@
+package testsupport
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"brokerflow/auth"
+	"brokerflow/clock"
+	"brokerflow/tenancy"
+
+	"github.com/google/uuid"
+)
+
+// Users implements auth.Repository. It also answers which brokerage a user
+// belongs to, which the other fakes need to apply broker-wide scopes.
+type Users struct {
+	mu    sync.Mutex
+	users map[string]auth.User
+	clock clock.Clock
+	newID func() string
+}
+
+var _ auth.Repository = (*Users)(nil)
+
+func NewUsers() *Users {
+	return &Users{users: make(map[string]auth.User), clock: clock.New(), newID: uuid.NewString}
+}
+
+// WithClock overrides the time source for CreatedAt/UpdatedAt.
+func (u *Users) WithClock(c clock.Clock) *Users {
+	u.clock = clock.OrReal(c)
+	return u
+}
+
+// WithIDGenerator overrides how ids of new users are made.
+func (u *Users) WithIDGenerator(gen func() string) *Users {
+	u.newID = gen
+	return u
+}
+
+// Add stores user as is, generating an id when it has none, and returns it.
+func (u *Users) Add(user auth.User) auth.User {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if user.ID == "" {
+		user.ID = u.newID()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = u.clock.Now()
+		user.UpdatedAt = user.CreatedAt
+	}
+	u.users[user.ID] = user
+	return user
+}
+
+func (u *Users) CreateUser(_ context.Context, params auth.CreateUserParams) (auth.User, error) {
+	u.mu.Lock()
+	for _, existing := range u.users {
+		if strings.EqualFold(existing.Email, params.Email) {
+			u.mu.Unlock()
+			return auth.User{}, auth.ErrDuplicateEmail
+		}
+	}
+	u.mu.Unlock()
+	return u.Add(auth.User{
+		Email:        params.Email,
+		FullName:     params.FullName,
+		PasswordHash: params.PasswordHash,
+		Role:         params.Role,
+	}), nil
+}
+
+func (u *Users) GetUserByEmail(_ context.Context, email string) (auth.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, user := range u.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return auth.User{}, auth.ErrUserNotFound
+}
+
+func (u *Users) GetUserByID(_ context.Context, userID string) (auth.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[userID]
+	if !ok {
+		return auth.User{}, auth.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// BrokerOf returns the user's brokerage, or "" when it has none.
+func (u *Users) BrokerOf(userID string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if user, ok := u.users[userID]; ok && user.BrokerID != nil {
+		return *user.BrokerID
+	}
+	return ""
+}
+
+// BrokerUsers returns the ids of every user of brokerID.
+func (u *Users) BrokerUsers(brokerID string) []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var ids []string
+	for id, user := range u.users {
+		if user.BrokerID != nil && *user.BrokerID == brokerID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// OfficeOf returns the user's office, or "" when they have none.
+func (u *Users) OfficeOf(userID string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if user, ok := u.users[userID]; ok && user.OfficeID != nil {
+		return *user.OfficeID
+	}
+	return ""
+}
+
+// setOffice assigns userID to officeID, or to no office when it is empty.
+func (u *Users) setOffice(userID, officeID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user := u.users[userID]
+	user.OfficeID = nil
+	if officeID != "" {
+		user.OfficeID = &officeID
+	}
+	user.UpdatedAt = u.clock.Now()
+	u.users[userID] = user
+}
+
+// owns mirrors tenancy.Scope.OwnedBy: a row owned by ownerID is in scope.
+func (u *Users) owns(scope tenancy.Scope, ownerID string) bool {
+	if scope.Officewide() {
+		return u.OfficeOf(ownerID) == scope.OfficeID
+	}
+	if scope.Brokerwide() {
+		return u.BrokerOf(ownerID) == scope.BrokerID
+	}
+	return ownerID == scope.UserID
+}
+
+// partyTo mirrors tenancy.Scope.PartyTo for rows with broker parties.
+func (u *Users) partyTo(scope tenancy.Scope, ownerID string, brokerIDs ...string) bool {
+	if !scope.Brokerwide() {
+		return u.owns(scope, ownerID)
+	}
+	for _, id := range brokerIDs {
+		if id == scope.BrokerID {
+			return true
+		}
+	}
+	return false
+}
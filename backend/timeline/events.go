@@ -0,0 +1,225 @@
+package timeline
+
+import (
+	"sort"
+	"time"
+)
+
+// Event types written by the Go services. The event_type enum holds a few
+// more values (ESIGN_REQUESTED, PII_VIEWED, ...) that nothing writes today;
+// their payloads are decoded as-is.
+const (
+	TypeAgreementCreated       = "AGREEMENT_CREATED"
+	TypeAgreementStatusChanged = "AGREEMENT_STATUS_CHANGED"
+	TypeAgreementCancelled     = "AGREEMENT_CANCELLED"
+	TypeAgreementSigned        = "AGREEMENT_SIGNED"
+	TypeEsignCompleted         = "ESIGN_COMPLETED"
+	TypeOfferMade              = "OFFER_MADE"
+	TypeUnderContract          = "UNDER_CONTRACT"
+	TypeDealClosed             = "DEAL_CLOSED"
+	TypeProtectExpired         = "PROTECT_EXPIRED"
+	TypeAmendmentProposed      = "AMENDMENT_PROPOSED"
+	TypeAmendmentAccepted      = "AMENDMENT_ACCEPTED"
+	TypeAmendmentRejected      = "AMENDMENT_REJECTED"
+	TypeStatusCorrected        = "AGREEMENT_STATUS_CORRECTED"
+	TypeClientRecordAttached   = "CLIENT_RECORD_ATTACHED"
+)
+
+// AgreementCreatedPayload is written with AGREEMENT_CREATED. Agreements
+// created directly carry the referral and terms; agreements created by
+// accepting a match carry the match fields instead.
+type AgreementCreatedPayload struct {
+	ReferralID       string     `json:"referral_id,omitempty"`
+	FeeRate          float64    `json:"fee_rate,omitempty"`
+	ProtectDays      int        `json:"protect_days,omitempty"`
+	Source           string     `json:"source,omitempty"`
+	MatchID          string     `json:"match_id,omitempty"`
+	AcceptedAt       *time.Time `json:"accepted_at,omitempty"`
+	AcceptedByUserID string     `json:"accepted_by_user_id,omitempty"`
+	ReferralOwnerID  string     `json:"referral_owner_id,omitempty"`
+}
+
+// StatusChangedPayload is written with AGREEMENT_STATUS_CHANGED. Callers of
+// the transition may attach extra fields, which are kept.
+type StatusChangedPayload struct {
+	PreviousStatus string `json:"previous_status"`
+	NextStatus     string `json:"next_status"`
+	ActorID        string `json:"actor_id,omitempty"`
+}
+
+// CancelledPayload is written with AGREEMENT_CANCELLED.
+type CancelledPayload struct {
+	PreviousStatus   string `json:"previous_status"`
+	Reason           string `json:"reason"`
+	ReferralReopened bool   `json:"referral_reopened"`
+}
+
+// SignedPayload is written with AGREEMENT_SIGNED; the signer is the
+// event's actor.
+type SignedPayload struct {
+	Party    string    `json:"party" doc:"referrer or referee"`
+	BrokerID string    `json:"broker_id"`
+	SignedAt time.Time `json:"signed_at"`
+}
+
+// EsignCompletedPayload is written with ESIGN_COMPLETED. The e-sign webhook
+// may attach extra fields, which are kept.
+type EsignCompletedPayload struct {
+	AgreementID string    `json:"agreement_id"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// ProtectExpiredPayload is written with PROTECT_EXPIRED. Version 1 named
+// the previous status previous_state.
+type ProtectExpiredPayload struct {
+	EffectiveAt    time.Time `json:"effective_at"`
+	ProtectDays    int       `json:"protect_days"`
+	ProtectEnds    time.Time `json:"protect_ends"`
+	PreviousStatus string    `json:"previous_status"`
+}
+
+// StatusCorrectedPayload is written with AGREEMENT_STATUS_CORRECTED. It
+// compensates for an erroneous status change, which stays in the timeline:
+// the agreement takes NextStatus as if that event had set it instead. The
+// corrector is the event's actor.
+type StatusCorrectedPayload struct {
+	CorrectsEventID int64  `json:"corrects_event_id"`
+	CorrectsType    string `json:"corrects_type"`
+	PreviousStatus  string `json:"previous_status"`
+	NextStatus      string `json:"next_status"`
+	Justification   string `json:"justification"`
+}
+
+// ClientRecordAttachedPayload is written with CLIENT_RECORD_ATTACHED to the
+// live agreements of a referral when its client record is attached. The
+// client's details stay in client_records; the attacher is the event's
+// actor.
+type ClientRecordAttachedPayload struct {
+	ReferralID     string `json:"referral_id"`
+	ClientRecordID string `json:"client_record_id"`
+	Reused         bool   `json:"reused"`
+}
+
+// AmendmentPayload is written with the AMENDMENT_* events.
+type AmendmentPayload struct {
+	AmendmentID string  `json:"amendment_id"`
+	FeeRate     float64 `json:"fee_rate"`
+	ProtectDays int     `json:"protect_days"`
+	Status      string  `json:"status"`
+	Note        *string `json:"note,omitempty"`
+}
+
+// Upgrade rewrites a payload of one version into the next.
+type Upgrade func(payload map[string]any) (map[string]any, error)
+
+// Definition documents one event type and the payload it is written with.
+type Definition struct {
+	// Type is the event_type enum value.
+	Type string
+	// Description explains when the event is appended.
+	Description string
+	// Payload is a zero value of the current payload type; its JSON schema
+	// is checked on write. Nil accepts any object.
+	Payload any
+	// Version is the payload_version written today, 1 when zero.
+	Version int
+	// Upgrades[i] turns a version i+1 payload into version i+2; there is one
+	// per version bump.
+	Upgrades []Upgrade
+}
+
+// CurrentVersion is the payload_version written today.
+func (d Definition) CurrentVersion() int {
+	if d.Version < 1 {
+		return 1
+	}
+	return d.Version
+}
+
+var definitions = map[string]Definition{}
+
+func init() {
+	for _, d := range []Definition{
+		{
+			Type:        TypeAgreementCreated,
+			Description: "An agreement was created, directly or by accepting a referral match.",
+			Payload:     AgreementCreatedPayload{},
+		},
+		{
+			Type:        TypeAgreementStatusChanged,
+			Description: "The agreement moved along its status machine.",
+			Payload:     StatusChangedPayload{},
+		},
+		{
+			Type:        TypeAgreementCancelled,
+			Description: "A broker party cancelled an unsigned agreement.",
+			Payload:     CancelledPayload{},
+		},
+		{
+			Type:        TypeAgreementSigned,
+			Description: "One broker party signed an agreement pending signature.",
+			Payload:     SignedPayload{},
+		},
+		{
+			Type:        TypeEsignCompleted,
+			Description: "Both parties signed, reported by the e-sign provider or with the second signature; the agreement became effective.",
+			Payload:     EsignCompletedPayload{},
+		},
+		{Type: TypeOfferMade, Description: "Deal milestone; the payload is supplied by the recording broker."},
+		{Type: TypeUnderContract, Description: "Deal milestone; the payload is supplied by the recording broker."},
+		{Type: TypeDealClosed, Description: "Deal milestone; the payload is supplied by the recording broker."},
+		{
+			Type:        TypeProtectExpired,
+			Description: "The protect period of an effective agreement ended.",
+			Payload:     ProtectExpiredPayload{},
+			Version:     2,
+			Upgrades:    []Upgrade{renameField("previous_state", "previous_status")},
+		},
+		{Type: TypeAmendmentProposed, Description: "A party proposed new terms.", Payload: AmendmentPayload{}},
+		{Type: TypeAmendmentAccepted, Description: "The counterparty accepted proposed terms.", Payload: AmendmentPayload{}},
+		{Type: TypeAmendmentRejected, Description: "The counterparty rejected proposed terms.", Payload: AmendmentPayload{}},
+		{
+			Type:        TypeStatusCorrected,
+			Description: "A broker admin overrode the status set by an erroneous event, which stays in the timeline, giving a justification.",
+			Payload:     StatusCorrectedPayload{},
+		},
+		{
+			Type:        TypeClientRecordAttached,
+			Description: "The referring side attached the referral's client record; its details are not part of the event.",
+			Payload:     ClientRecordAttachedPayload{},
+		},
+	} {
+		if len(d.Upgrades) != d.CurrentVersion()-1 {
+			panic("timeline: " + d.Type + " needs one upgrade per version bump")
+		}
+		definitions[d.Type] = d
+	}
+}
+
+// Lookup returns the definition of eventType.
+func Lookup(eventType string) (Definition, bool) {
+	d, ok := definitions[eventType]
+	return d, ok
+}
+
+// Definitions returns every defined event type ordered by type.
+func Definitions() []Definition {
+	out := make([]Definition, 0, len(definitions))
+	for _, d := range definitions {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+func renameField(from, to string) Upgrade {
+	return func(payload map[string]any) (map[string]any, error) {
+		if v, ok := payload[from]; ok {
+			if _, taken := payload[to]; !taken {
+				payload[to] = v
+			}
+			delete(payload, from)
+		}
+		return payload, nil
+	}
+}
@@ -0,0 +1,66 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"brokerflow/tenancy"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidFilter = errors.New("timeline: invalid filter")
+
+// ListFilters narrows Repository.List. Scope decides which agreements'
+// events are visible at all; the rest are optional and ANDed.
+type ListFilters struct {
+	Scope       tenancy.Scope
+	AgreementID string
+	// Types keeps events of any of the given types.
+	Types         []string
+	ActorBrokerID string
+	// Since and Until bound the event time, Since inclusive and Until
+	// exclusive.
+	Since    time.Time
+	Until    time.Time
+	Page     int
+	PageSize int
+}
+
+// Validate rejects types without a Definition (ErrUnknownType), and ids
+// that are not UUIDs and empty time ranges (ErrInvalidFilter).
+func (f ListFilters) Validate() error {
+	for _, t := range f.Types {
+		if _, ok := Lookup(t); !ok {
+			return fmt.Errorf("%w %q", ErrUnknownType, t)
+		}
+	}
+	if _, err := uuid.Parse(f.AgreementID); f.AgreementID != "" && err != nil {
+		return fmt.Errorf("%w: invalid agreement id", ErrInvalidFilter)
+	}
+	if _, err := uuid.Parse(f.ActorBrokerID); f.ActorBrokerID != "" && err != nil {
+		return fmt.Errorf("%w: invalid actor broker id", ErrInvalidFilter)
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return fmt.Errorf("%w: until must be after since", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// ParseTypes reads type filters, each of which may be a comma-separated
+// list. Duplicates are dropped; no filters means every type. Unknown types
+// are left for Validate.
+func ParseTypes(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if t := strings.TrimSpace(part); t != "" && !slices.Contains(out, t) {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
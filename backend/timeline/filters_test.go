@@ -0,0 +1,49 @@
+package timeline
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTypes(t *testing.T) {
+	got := ParseTypes([]string{"OFFER_MADE, DEAL_CLOSED", "", "OFFER_MADE"})
+	if !slices.Equal(got, []string{TypeOfferMade, TypeDealClosed}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := ParseTypes(nil); got != nil {
+		t.Fatalf("expected no types, got %v", got)
+	}
+}
+
+func TestListFilters_Validate(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	valid := ListFilters{
+		AgreementID:   "6f1c1c3e-2f0a-4a36-9b7e-0d7a8f6c2b11",
+		Types:         []string{TypeAgreementCreated, TypeProtectExpired},
+		ActorBrokerID: "9b2e4c1a-5d3f-4e6a-8b7c-1d2e3f4a5b6c",
+		Since:         day,
+		Until:         day.AddDate(0, 0, 1),
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := (ListFilters{}).Validate(); err != nil {
+		t.Fatalf("expected no filters to be valid, got %v", err)
+	}
+
+	// ESIGN_REQUESTED is in the event_type enum, but nothing writes it.
+	if err := (ListFilters{Types: []string{"ESIGN_REQUESTED"}}).Validate(); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType, got %v", err)
+	}
+	for name, f := range map[string]ListFilters{
+		"agreement id":    {AgreementID: "a-1"},
+		"actor broker id": {ActorBrokerID: "b-1"},
+		"empty range":     {Since: day, Until: day},
+	} {
+		if err := f.Validate(); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: expected ErrInvalidFilter, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package timeline fans timeline_events inserts out to live subscribers.
+// PostgreSQL announces each insert on the timeline_events channel (see
+// migration 000004); a single listener connection per process feeds a Hub,
+// and subscribers re-read the table from their last delivered id, so a
+// dropped or coalesced notification never loses an event.
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotifyChannel is the LISTEN/NOTIFY channel the insert trigger publishes on.
+// The payload is the agreement id of the inserted event.
+const NotifyChannel = "timeline_events"
+
+// Hub tracks subscribers per agreement and wakes them on new events.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+// NewHub returns a Hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscription is a wake-up signal for one agreement. Signals coalesce: a
+// receiver that was busy sees a single pending wake-up, not one per event.
+type Subscription struct {
+	hub         *Hub
+	agreementID string
+	ch          chan struct{}
+	once        sync.Once
+}
+
+// C delivers a value whenever new events may be available.
+func (s *Subscription) C() <-chan struct{} {
+	return s.ch
+}
+
+// Close detaches the subscription from the hub. It is safe to call twice.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		defer s.hub.mu.Unlock()
+		set := s.hub.subs[s.agreementID]
+		delete(set, s)
+		if len(set) == 0 {
+			delete(s.hub.subs, s.agreementID)
+		}
+	})
+}
+
+// Subscribe registers interest in events for agreementID.
+func (h *Hub) Subscribe(agreementID string) *Subscription {
+	sub := &Subscription{hub: h, agreementID: agreementID, ch: make(chan struct{}, 1)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	set, ok := h.subs[agreementID]
+	if !ok {
+		set = make(map[*Subscription]struct{})
+		h.subs[agreementID] = set
+	}
+	set[sub] = struct{}{}
+	return sub
+}
+
+// Publish wakes every subscriber of agreementID without blocking.
+func (h *Hub) Publish(agreementID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[agreementID] {
+		wake(sub)
+	}
+}
+
+// PublishAll wakes every subscriber. The listener calls it after reconnecting
+// because notifications sent while it was away are lost.
+func (h *Hub) PublishAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, set := range h.subs {
+		for sub := range set {
+			wake(sub)
+		}
+	}
+}
+
+func wake(sub *Subscription) {
+	select {
+	case sub.ch <- struct{}{}:
+	default:
+	}
+}
+
+// Listen holds a dedicated connection on NotifyChannel and publishes every
+// notification to the hub until ctx is cancelled. Connection failures are
+// retried with backoff up to maxBackoff.
+func (h *Hub) Listen(ctx context.Context, pool *pgxpool.Pool) error {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+	for {
+		err := h.listenOnce(ctx, pool)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("timeline: listener stopped: %v (retrying in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+func (h *Hub) listenOnce(ctx context.Context, pool *pgxpool.Pool) error {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("timeline: acquire listener: %w", err)
+	}
+	// The session is left in LISTEN state, so it must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+NotifyChannel); err != nil {
+		return fmt.Errorf("timeline: listen: %w", err)
+	}
+	h.PublishAll()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("timeline: wait for notification: %w", err)
+		}
+		h.Publish(n.Payload)
+	}
+}
@@ -0,0 +1,47 @@
+package timeline
+
+import "testing"
+
+func TestHub_PublishWakesOnlyMatchingSubscribers(t *testing.T) {
+	h := NewHub()
+	a := h.Subscribe("ag-1")
+	b := h.Subscribe("ag-2")
+	defer a.Close()
+	defer b.Close()
+
+	h.Publish("ag-1")
+	h.Publish("ag-1")
+
+	select {
+	case <-a.C():
+	default:
+		t.Fatal("expected ag-1 subscriber to be woken")
+	}
+	select {
+	case <-a.C():
+		t.Fatal("expected repeated wake-ups to coalesce")
+	default:
+	}
+	select {
+	case <-b.C():
+		t.Fatal("did not expect ag-2 subscriber to be woken")
+	default:
+	}
+}
+
+func TestHub_CloseDetaches(t *testing.T) {
+	h := NewHub()
+	sub := h.Subscribe("ag-1")
+	sub.Close()
+	sub.Close()
+
+	h.PublishAll()
+	select {
+	case <-sub.C():
+		t.Fatal("closed subscription should not be woken")
+	default:
+	}
+	if len(h.subs) != 0 {
+		t.Fatalf("expected empty hub, got %d agreements", len(h.subs))
+	}
+}
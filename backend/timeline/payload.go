@@ -0,0 +1,242 @@
+package timeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownType    = errors.New("timeline: unknown event type")
+	ErrInvalidPayload = errors.New("timeline: payload does not match its schema")
+	ErrFutureVersion  = errors.New("timeline: payload version is newer than this build")
+)
+
+// Schema is the JSON Schema subset checked on write: typed properties, the
+// required ones, and date-time strings. Properties not listed are allowed,
+// since several producers pass caller-supplied fields through.
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Nullable   bool               `json:"nullable,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+}
+
+// Schema derives the payload schema from d.Payload; it is nil when the
+// definition accepts any object.
+func (d Definition) Schema() *Schema {
+	if d.Payload == nil {
+		return nil
+	}
+	return schemaOf(reflect.TypeOf(d.Payload))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		s = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			s.Properties[name] = schemaOf(field.Type)
+			if !omitempty && field.Type.Kind() != reflect.Pointer {
+				s.Required = append(s.Required, name)
+			}
+		}
+		sort.Strings(s.Required)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array"}
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object"}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number"}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	default:
+		s = &Schema{}
+	}
+	s.Nullable = nullable
+	return s
+}
+
+func jsonName(field reflect.StructField) (name string, omitempty bool) {
+	parts := strings.Split(field.Tag.Get("json"), ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+// Validate checks a decoded JSON value against the schema.
+func (s *Schema) Validate(v any) error {
+	return s.validate("payload", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", path)
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, prop := range s.Properties {
+			if val, ok := obj[name]; ok {
+				if err := prop.validate(path+"."+name, val); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		if _, ok := v.([]any); !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s must be an RFC 3339 timestamp", path)
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+// Encode validates payload against the current definition of eventType and
+// returns the JSON to store together with its payload_version. A nil payload
+// is stored as an empty object.
+func Encode(eventType string, payload any) ([]byte, int, error) {
+	def, ok := Lookup(eventType)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrUnknownType, eventType)
+	}
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("timeline: marshal %s payload: %w", eventType, err)
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, 0, fmt.Errorf("timeline: marshal %s payload: %w", eventType, err)
+	}
+	schema := def.Schema()
+	if schema == nil {
+		schema = &Schema{Type: "object"}
+	}
+	if err := schema.Validate(doc); err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", ErrInvalidPayload, eventType, err)
+	}
+	return body, def.CurrentVersion(), nil
+}
+
+// Decode parses a stored payload and upgrades it from version to the current
+// payload version of eventType. Types without a definition are returned as
+// stored; an empty or null payload decodes to nil.
+func Decode(eventType string, version int, raw []byte) (map[string]any, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("timeline: decode %s payload: %w", eventType, err)
+	}
+	def, ok := Lookup(eventType)
+	if !ok {
+		return payload, nil
+	}
+	if version < 1 {
+		version = 1
+	}
+	if version > def.CurrentVersion() {
+		return nil, fmt.Errorf("%w: %s v%d", ErrFutureVersion, eventType, version)
+	}
+	for v := version; v < def.CurrentVersion(); v++ {
+		upgraded, err := def.Upgrades[v-1](payload)
+		if err != nil {
+			return nil, fmt.Errorf("timeline: upgrade %s payload from v%d: %w", eventType, v, err)
+		}
+		payload = upgraded
+	}
+	return payload, nil
+}
+
+// DecodeInto decodes like Decode and unmarshals the upgraded payload into
+// dst, typically the event type's payload struct.
+func DecodeInto(eventType string, version int, raw []byte, dst any) error {
+	payload, err := Decode(eventType, version, raw)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("timeline: decode %s payload: %w", eventType, err)
+	}
+	if err := json.Unmarshal(body, dst); err != nil {
+		return fmt.Errorf("timeline: decode %s payload: %w", eventType, err)
+	}
+	return nil
+}
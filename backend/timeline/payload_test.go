@@ -0,0 +1,119 @@
+package timeline
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncode_ValidatesAgainstCurrentSchema(t *testing.T) {
+	body, version, err := Encode(TypeAgreementStatusChanged, map[string]any{
+		"previous_status": "draft",
+		"next_status":     "pending_signature",
+		"note":            "extra fields are kept",
+	})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("expected version 1, got %d", version)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil || got["note"] != "extra fields are kept" {
+		t.Fatalf("unexpected body %s (%v)", body, err)
+	}
+
+	cases := map[string]map[string]any{
+		"missing required": {"previous_status": "draft"},
+		"wrong type":       {"previous_status": "draft", "next_status": 3},
+		"null":             {"previous_status": "draft", "next_status": nil},
+	}
+	for name, payload := range cases {
+		if _, _, err := Encode(TypeAgreementStatusChanged, payload); !errors.Is(err, ErrInvalidPayload) {
+			t.Fatalf("%s: expected ErrInvalidPayload, got %v", name, err)
+		}
+	}
+}
+
+func TestEncode_TypedPayloadsAndFormats(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, version, err := Encode(TypeProtectExpired, ProtectExpiredPayload{EffectiveAt: at, ProtectDays: 30, ProtectEnds: at.AddDate(0, 0, 30), PreviousStatus: "effective"}); err != nil || version != 2 {
+		t.Fatalf("expected a valid v2 payload, got version %d, %v", version, err)
+	}
+	bad := map[string]any{"effective_at": "yesterday", "protect_days": 30, "protect_ends": at, "previous_status": "effective"}
+	if _, _, err := Encode(TypeProtectExpired, bad); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected a non-RFC 3339 timestamp to be refused, got %v", err)
+	}
+	fractional := map[string]any{"effective_at": at, "protect_days": 1.5, "protect_ends": at, "previous_status": "effective"}
+	if _, _, err := Encode(TypeProtectExpired, fractional); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected a fractional integer to be refused, got %v", err)
+	}
+}
+
+func TestEncode_DealEventsAcceptAnyObject(t *testing.T) {
+	body, _, err := Encode(TypeOfferMade, nil)
+	if err != nil || string(body) != "{}" {
+		t.Fatalf("expected an empty object, got %s, %v", body, err)
+	}
+	if _, _, err := Encode(TypeDealClosed, map[string]any{"price": 525000, "notes": []string{"cash"}}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if _, _, err := Encode(TypeDealClosed, []string{"not", "an", "object"}); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected non-objects to be refused, got %v", err)
+	}
+}
+
+func TestEncode_UnknownType(t *testing.T) {
+	if _, _, err := Encode("PII_VIEWED", nil); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestDecode_UpgradesOldVersions(t *testing.T) {
+	v1 := []byte(`{"effective_at":"2026-03-01T12:00:00Z","protect_days":30,"protect_ends":"2026-03-31T12:00:00Z","previous_state":"effective"}`)
+	payload, err := Decode(TypeProtectExpired, 1, v1)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload["previous_status"] != "effective" {
+		t.Fatalf("expected previous_state to be renamed, got %v", payload)
+	}
+	if _, ok := payload["previous_state"]; ok {
+		t.Fatalf("expected previous_state to be dropped, got %v", payload)
+	}
+
+	var typed ProtectExpiredPayload
+	if err := DecodeInto(TypeProtectExpired, 0, v1, &typed); err != nil {
+		t.Fatalf("decode into: %v", err)
+	}
+	if typed.PreviousStatus != "effective" || typed.ProtectDays != 30 {
+		t.Fatalf("unexpected typed payload %+v", typed)
+	}
+}
+
+func TestDecode_EdgeCases(t *testing.T) {
+	if payload, err := Decode(TypeOfferMade, 1, nil); err != nil || payload != nil {
+		t.Fatalf("expected nil for an empty payload, got %v, %v", payload, err)
+	}
+	if payload, err := Decode("CLIENT_CONTACTED", 7, []byte(`{"by":"phone"}`)); err != nil || payload["by"] != "phone" {
+		t.Fatalf("expected undefined types to pass through, got %v, %v", payload, err)
+	}
+	if _, err := Decode(TypeProtectExpired, 3, []byte(`{}`)); !errors.Is(err, ErrFutureVersion) {
+		t.Fatalf("expected ErrFutureVersion, got %v", err)
+	}
+	if _, err := Decode(TypeOfferMade, 1, []byte(`not json`)); err == nil {
+		t.Fatalf("expected malformed payloads to fail")
+	}
+}
+
+func TestDefinitions_SchemasDerive(t *testing.T) {
+	for _, d := range Definitions() {
+		if d.Payload == nil {
+			continue
+		}
+		if s := d.Schema(); s == nil || s.Type != "object" || len(s.Properties) == 0 {
+			t.Fatalf("%s: unexpected schema %+v", d.Type, s)
+		}
+	}
+}
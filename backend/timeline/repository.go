@@ -0,0 +1,213 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"brokerflow/db"
+	"brokerflow/tenancy"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Event is a timeline_events row as delivered to stream subscribers.
+type Event struct {
+	ID          int64
+	AgreementID string
+	Seq         int64
+	Type        string
+	At          time.Time
+	Payload     []byte
+	// PayloadVersion is the payload_version the payload was written with;
+	// pass it to Decode.
+	PayloadVersion int
+	ActorBroker    *string
+}
+
+// Repository reads timeline events and checks who may follow an agreement.
+type Repository struct {
+	pool    *pgxpool.Pool
+	reader  db.Reader
+	counter *db.Counter
+}
+
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool, reader: pool}
+}
+
+// WithReader runs List and ListForAgreements on reader. The stream keeps
+// reading the primary so it never misses an event it was notified of.
+func (r *Repository) WithReader(reader db.Reader) *Repository {
+	r.reader = reader
+	return r
+}
+
+// WithCounter totals List through counter instead of an exact COUNT(*).
+func (r *Repository) WithCounter(counter *db.Counter) *Repository {
+	r.counter = counter
+	return r
+}
+
+// CanView reports whether userID may read the agreement's timeline: the
+// owner of the underlying referral, or any user of either broker party.
+func (r *Repository) CanView(ctx context.Context, agreementID, userID string) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM agreements a
+			JOIN referral_requests rr ON rr.id = a.referral_id
+			LEFT JOIN users u ON u.id = $2::uuid
+			WHERE a.id = $1::uuid
+			  AND (rr.created_by_user_id = $2::uuid
+			       OR u.broker_id IN (a.from_broker_id, a.to_broker_id))
+		)
+	`
+	var ok bool
+	if err := r.pool.QueryRow(ctx, query, agreementID, userID).Scan(&ok); err != nil {
+		return false, fmt.Errorf("timeline: check access: %w", err)
+	}
+	return ok, nil
+}
+
+// eventColumns are the timeline_events columns scanned into an Event, in
+// order, for a query aliasing the table e.
+const eventColumns = `e.id, e.agreement_id::text, COALESCE(e.seq, 0), e.type::text, e.ts, e.payload, e.payload_version, e.actor_broker_id::text`
+
+// viewer matches agreements a whose timeline the scope's user may read, as
+// CanView decides: scope's parties (see tenancy.Scope.Parties) and any user
+// of either broker party.
+const viewer = `EXISTS (SELECT 1 FROM users m WHERE m.id = ?::uuid AND m.broker_id IN (a.from_broker_id, a.to_broker_id))`
+
+// List returns the events of agreements visible within filters.Scope that
+// match filters, newest first, paged with a total from the repository's
+// counter. Invalid filters fail with the errors of ListFilters.Validate.
+func (r *Repository) List(ctx context.Context, filters ListFilters) ([]Event, db.Page, error) {
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize <= 0 || filters.PageSize > 100 {
+		filters.PageSize = 20
+	}
+	if err := filters.Validate(); err != nil {
+		return nil, db.Page{}, err
+	}
+
+	party, partyArg := filters.Scope.Parties("rr.created_by_user_id", "a.from_broker_id", "a.to_broker_id")
+	q := db.Select(eventColumns).
+		From("timeline_events e JOIN agreements a ON a.id = e.agreement_id JOIN referral_requests rr ON rr.id = a.referral_id").
+		Where("("+party+" OR "+viewer+")", partyArg, filters.Scope.UserID)
+	if filters.AgreementID != "" {
+		q.Where("e.agreement_id = ?::uuid", filters.AgreementID)
+	}
+	if len(filters.Types) > 0 {
+		q.Where("e.type::text = ANY(?)", filters.Types)
+	}
+	if filters.ActorBrokerID != "" {
+		q.Where("e.actor_broker_id = ?::uuid", filters.ActorBrokerID)
+	}
+	if !filters.Since.IsZero() {
+		q.Where("e.ts >= ?", filters.Since)
+	}
+	if !filters.Until.IsZero() {
+		q.Where("e.ts < ?", filters.Until)
+	}
+
+	// One row past the page tells whether another page follows.
+	offset := (filters.Page - 1) * filters.PageSize
+	q.OrderBy("e.ts DESC", "e.id DESC").Limit(filters.PageSize + 1).Offset(offset)
+
+	query, args := q.SQL()
+	rows, err := r.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, db.Page{}, fmt.Errorf("timeline: list events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0, filters.PageSize+1)
+	for rows.Next() {
+		var ev Event
+		if err := rows.Scan(&ev.ID, &ev.AgreementID, &ev.Seq, &ev.Type, &ev.At, &ev.Payload, &ev.PayloadVersion, &ev.ActorBroker); err != nil {
+			return nil, db.Page{}, fmt.Errorf("timeline: scan event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, db.Page{}, fmt.Errorf("timeline: iterate events: %w", err)
+	}
+	return db.Paginate(ctx, r.counter, r.reader, q.Count(), events, offset, filters.PageSize)
+}
+
+// ListForAgreements returns every event of the agreements among
+// agreementIDs whose timeline scope's user may read, as List decides,
+// oldest first within each agreement.
+func (r *Repository) ListForAgreements(ctx context.Context, scope tenancy.Scope, agreementIDs []string) ([]Event, error) {
+	if len(agreementIDs) == 0 {
+		return nil, nil
+	}
+	party, partyArg := scope.Parties("rr.created_by_user_id", "a.from_broker_id", "a.to_broker_id")
+	query, args := db.Select(eventColumns).
+		From("timeline_events e JOIN agreements a ON a.id = e.agreement_id JOIN referral_requests rr ON rr.id = a.referral_id").
+		Where("("+party+" OR "+viewer+")", partyArg, scope.UserID).
+		Where("e.agreement_id = ANY(?::uuid[])", agreementIDs).
+		OrderBy("e.agreement_id", "e.id").
+		SQL()
+	rows, err := r.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("timeline: list events for agreements: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var ev Event
+		if err := rows.Scan(&ev.ID, &ev.AgreementID, &ev.Seq, &ev.Type, &ev.At, &ev.Payload, &ev.PayloadVersion, &ev.ActorBroker); err != nil {
+			return nil, fmt.Errorf("timeline: scan event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("timeline: iterate events: %w", err)
+	}
+	return events, nil
+}
+
+// ListAfter returns up to limit events of the agreement with id > afterID,
+// oldest first.
+func (r *Repository) ListAfter(ctx context.Context, agreementID string, afterID int64, limit int) ([]Event, error) {
+	const query = `
+		SELECT id, agreement_id::text, COALESCE(seq, 0), type::text, ts, payload, payload_version, actor_broker_id::text
+		FROM timeline_events
+		WHERE agreement_id = $1::uuid AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+	rows, err := r.pool.Query(ctx, query, agreementID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("timeline: list events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0, limit)
+	for rows.Next() {
+		var ev Event
+		if err := rows.Scan(&ev.ID, &ev.AgreementID, &ev.Seq, &ev.Type, &ev.At, &ev.Payload, &ev.PayloadVersion, &ev.ActorBroker); err != nil {
+			return nil, fmt.Errorf("timeline: scan event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("timeline: iterate events: %w", err)
+	}
+	return events, nil
+}
+
+// LatestID returns the id of the agreement's newest event, or 0 when it has
+// none. New stream subscribers start after it.
+func (r *Repository) LatestID(ctx context.Context, agreementID string) (int64, error) {
+	var id int64
+	if err := r.pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM timeline_events WHERE agreement_id = $1::uuid`, agreementID).Scan(&id); err != nil {
+		return 0, fmt.Errorf("timeline: latest event: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,267 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"brokerflow/clock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultDeliveryBatchSize = 20
+	// DefaultMaxAttempts is how many times a delivery is tried before it is
+	// marked failed; with the backoff below the last try is about four hours
+	// after the first.
+	DefaultMaxAttempts = 10
+	deliveryTimeout    = 10 * time.Second
+	// deliveryLease keeps a claimed row from being claimed again while its
+	// request is in flight; it must exceed deliveryTimeout.
+	deliveryLease    = time.Minute
+	backoffBase      = 30 * time.Second
+	backoffCap       = 6 * time.Hour
+	maxErrorLength   = 500
+	maxResponseDrain = 64 << 10
+)
+
+var errNonPublicAddress = errors.New("webhook: refusing to connect to a non-public address")
+
+// Backoff is the delay before retrying after the given number of failed
+// attempts: 30s, 1m, 2m, ... capped at six hours.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := backoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= backoffCap {
+			return backoffCap
+		}
+	}
+	return d
+}
+
+// Deliverer POSTs pending webhook deliveries whose next attempt is due.
+// Rows are claimed with FOR UPDATE SKIP LOCKED and leased for the duration
+// of the request, so several instances may run it.
+type Deliverer struct {
+	pool        *pgxpool.Pool
+	client      *http.Client
+	clock       clock.Clock
+	batchSize   int
+	maxAttempts int
+}
+
+func NewDeliverer(pool *pgxpool.Pool) *Deliverer {
+	return &Deliverer{
+		pool:        pool,
+		client:      newPublicClient(),
+		clock:       clock.New(),
+		batchSize:   defaultDeliveryBatchSize,
+		maxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// WithClient replaces the HTTP client. The default refuses to connect to
+// loopback, private and link-local addresses and does not follow redirects.
+func (d *Deliverer) WithClient(c *http.Client) *Deliverer {
+	d.client = c
+	return d
+}
+
+func (d *Deliverer) WithClock(c clock.Clock) *Deliverer {
+	d.clock = clock.OrReal(c)
+	return d
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled.
+func (d *Deliverer) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			n, err := d.DeliverDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("webhook delivery: %v", err)
+				}
+				break
+			}
+			if n < d.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-d.clock.After(interval):
+		}
+	}
+}
+
+// due is a claimed delivery with what is needed to send it.
+type due struct {
+	route    string
+	key      string
+	attempts int
+	url      string
+	secret   string
+	envelope Envelope
+}
+
+// DeliverDue claims up to one batch of due deliveries, sends them and
+// records the outcome of each. It returns the number claimed.
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	rows, err := d.pool.Query(ctx, `
+		UPDATE edge_invocations ei
+		SET next_attempt_at = get_tx_timestamp() + make_interval(secs => $2)
+		FROM (
+			SELECT e.route, e.key, w.url, w.secret, o.topic, COALESCE(o.payload, 'null'::jsonb) AS payload, o.created_at
+			FROM edge_invocations e
+			JOIN webhooks w ON e.route = $3 || w.id::text
+			JOIN outbox o ON o.id::text = e.key
+			WHERE e.status = 'pending' AND e.route LIKE $3 || '%' AND e.next_attempt_at <= get_tx_timestamp()
+			ORDER BY e.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF e SKIP LOCKED
+		) claimed
+		WHERE ei.route = claimed.route AND ei.key = claimed.key
+		RETURNING ei.route, ei.key, ei.attempts, claimed.url, claimed.secret, claimed.topic, claimed.payload, claimed.created_at
+	`, d.batchSize, deliveryLease.Seconds(), routePrefix)
+	if err != nil {
+		return 0, fmt.Errorf("webhook: claim deliveries: %w", err)
+	}
+	batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var x due
+		err := row.Scan(&x.route, &x.key, &x.attempts, &x.url, &x.secret, &x.envelope.Topic, &x.envelope.Payload, &x.envelope.CreatedAt)
+		x.envelope.ID = x.key
+		return x, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("webhook: scan deliveries: %w", err)
+	}
+
+	for _, x := range batch {
+		code, sendErr := d.send(ctx, x)
+		if err := d.record(ctx, x, code, sendErr); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// send POSTs the envelope and returns the response status. Any status
+// outside 2xx is an error.
+func (d *Deliverer) send(ctx context.Context, x due) (int, error) {
+	body, err := json.Marshal(x.envelope)
+	if err != nil {
+		return 0, fmt.Errorf("encode envelope: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, x.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTopic, x.envelope.Topic)
+	req.Header.Set(HeaderDelivery, x.envelope.ID)
+	req.Header.Set(HeaderSignature, Sign(x.secret, d.clock.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseDrain))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Deliverer) record(ctx context.Context, x due, code int, sendErr error) error {
+	var (
+		status   = StatusCompleted
+		retryIn  *float64
+		errText  *string
+		respCode *int
+	)
+	if code != 0 {
+		respCode = &code
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		if len(msg) > maxErrorLength {
+			msg = msg[:maxErrorLength]
+		}
+		errText = &msg
+		status = StatusFailed
+		if x.attempts+1 < d.maxAttempts {
+			status = StatusPending
+			secs := Backoff(x.attempts + 1).Seconds()
+			retryIn = &secs
+		}
+	}
+	if _, err := d.pool.Exec(ctx, `
+		UPDATE edge_invocations
+		SET status = $3,
+			attempts = attempts + 1,
+			first_attempt_at = CASE WHEN attempts = 0 THEN get_tx_timestamp() ELSE first_attempt_at END,
+			last_attempt_at = get_tx_timestamp(),
+			response_code = $4,
+			error = $5,
+			next_attempt_at = get_tx_timestamp() + make_interval(secs => $6)
+		WHERE route = $1 AND key = $2
+	`, x.route, x.key, status, respCode, errText, retryIn); err != nil {
+		return fmt.Errorf("webhook: record delivery %s %s: %w", x.route, x.key, err)
+	}
+	return nil
+}
+
+// newPublicClient returns a client that only dials public addresses, so a
+// registered URL cannot be used to reach internal services, and that
+// reports redirects as responses instead of following them.
+func newPublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return checkPublicAddress(address)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   deliveryTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkPublicAddress rejects a resolved host:port that is not a public
+// unicast address.
+func checkPublicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", errNonPublicAddress, host)
+	}
+	return nil
+}
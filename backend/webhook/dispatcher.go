@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"brokerflow/outbox"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// routePrefix namespaces webhook rows in edge_invocations.route.
+const routePrefix = "webhook:"
+
+// subjects are the ids an outbox payload may carry that tie it to brokers.
+// Declared payloads have agreement_id or referral_id, and match payloads add
+// candidate_id; invitation payloads have neither and reach no webhook.
+type subjects struct {
+	AgreementID string `json:"agreement_id"`
+	ReferralID  string `json:"referral_id"`
+	CandidateID string `json:"candidate_id"`
+}
+
+// Dispatcher implements outbox.Handler. For each message it records a
+// pending delivery for every webhook subscribed to the topic whose broker
+// the message concerns: the two brokers of an agreement, or the brokers of
+// a referral's creator and of the match candidate. Recording is idempotent,
+// so a message retried by the outbox worker is not delivered twice.
+type Dispatcher struct {
+	pool *pgxpool.Pool
+}
+
+func NewDispatcher(pool *pgxpool.Pool) *Dispatcher {
+	return &Dispatcher{pool: pool}
+}
+
+func (d *Dispatcher) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	var subj subjects
+	if len(msg.Payload) > 0 && json.Valid(msg.Payload) {
+		// Payloads that are not objects carry no subjects and reach nobody.
+		_ = json.Unmarshal(msg.Payload, &subj)
+	}
+	if subj.AgreementID == "" && subj.ReferralID == "" {
+		return nil
+	}
+
+	_, err := d.pool.Exec(ctx, `
+		WITH brokers AS (
+			SELECT a.from_broker_id AS broker_id FROM agreements a WHERE a.id::text = $2
+			UNION
+			SELECT a.to_broker_id FROM agreements a WHERE a.id::text = $2
+			UNION
+			SELECT u.broker_id FROM referral_requests rr
+			JOIN users u ON u.id = rr.created_by_user_id
+			WHERE $2 = '' AND rr.id::text = $3
+			UNION
+			SELECT u.broker_id FROM users u WHERE $2 = '' AND u.id::text = $4
+		)
+		INSERT INTO edge_invocations (key, route, status, attempts, next_attempt_at)
+		SELECT $1, $5 || w.id::text, 'pending', 0, get_tx_timestamp()
+		FROM webhooks w
+		WHERE w.broker_id IN (SELECT broker_id FROM brokers WHERE broker_id IS NOT NULL)
+		  AND $6 = ANY (w.topics)
+		ON CONFLICT (route, key) DO NOTHING
+	`, msg.ID, subj.AgreementID, subj.ReferralID, subj.CandidateID, routePrefix, msg.Topic)
+	if err != nil {
+		return fmt.Errorf("webhook: record deliveries for %s: %w", msg.ID, err)
+	}
+	return nil
+}
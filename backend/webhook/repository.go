@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRepository(pool *pgxpool.Pool) *PGRepository {
+	return &PGRepository{pool: pool}
+}
+
+func (r *PGRepository) Create(ctx context.Context, params CreateParams, secret string) (Webhook, error) {
+	w := Webhook{BrokerID: params.BrokerID, URL: params.URL, Topics: params.Topics, Secret: secret, CreatedBy: params.CreatedBy}
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO webhooks (broker_id, url, topics, secret, created_by_user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, get_tx_timestamp())
+		RETURNING id, created_at
+	`, params.BrokerID, params.URL, params.Topics, secret, params.CreatedBy).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("webhook: insert: %w", err)
+	}
+	return w, nil
+}
+
+func (r *PGRepository) List(ctx context.Context, brokerID string) ([]Webhook, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, broker_id, url, topics, created_by_user_id, created_at
+		FROM webhooks
+		WHERE broker_id = $1
+		ORDER BY created_at, id
+	`, brokerID)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: list: %w", err)
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Webhook, error) {
+		var w Webhook
+		err := row.Scan(&w.ID, &w.BrokerID, &w.URL, &w.Topics, &w.CreatedBy, &w.CreatedAt)
+		return w, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webhook: scan list: %w", err)
+	}
+	return hooks, nil
+}
+
+func (r *PGRepository) Delete(ctx context.Context, brokerID, id string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("webhook: begin delete: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND broker_id = $2`, id, brokerID)
+	if err != nil {
+		return fmt.Errorf("webhook: delete: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE edge_invocations
+		SET status = 'cancelled', next_attempt_at = NULL
+		WHERE route = $1 AND status = 'pending'
+	`, routePrefix+id); err != nil {
+		return fmt.Errorf("webhook: cancel pending deliveries: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("webhook: commit delete: %w", err)
+	}
+	return nil
+}
+
+func (r *PGRepository) Deliveries(ctx context.Context, brokerID, id string, page, pageSize int) ([]Delivery, int, error) {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND broker_id = $2)`, id, brokerID).Scan(&exists); err != nil {
+		return nil, 0, fmt.Errorf("webhook: load webhook: %w", err)
+	}
+	if !exists {
+		return nil, 0, ErrNotFound
+	}
+
+	route := routePrefix + id
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM edge_invocations WHERE route = $1`, route).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("webhook: count deliveries: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT e.key, COALESCE(o.topic, ''), e.status, e.attempts, e.response_code, e.error,
+			e.first_attempt_at, e.last_attempt_at, e.next_attempt_at
+		FROM edge_invocations e
+		LEFT JOIN outbox o ON o.id::text = e.key
+		WHERE e.route = $1
+		ORDER BY e.first_attempt_at DESC, e.key
+		LIMIT $2 OFFSET $3
+	`, route, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("webhook: list deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delivery, error) {
+		d := Delivery{WebhookID: id}
+		err := row.Scan(&d.MessageID, &d.Topic, &d.Status, &d.Attempts, &d.ResponseCode, &d.Error,
+			&d.FirstAttempt, &d.LastAttempt, &d.NextAttemptAt)
+		return d, err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("webhook: scan deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
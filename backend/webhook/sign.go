@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Request headers set on every delivery.
+const (
+	HeaderTopic     = "BrokerFlow-Topic"
+	HeaderDelivery  = "BrokerFlow-Delivery"
+	HeaderSignature = "BrokerFlow-Signature"
+)
+
+// Envelope is the JSON body POSTed to a webhook. ID is the outbox message
+// id and stays the same across retries, so partners can deduplicate on it.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	CreatedAt time.Time       `json:"created_at"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Sign returns the BrokerFlow-Signature header value for body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by secret>".
+// Including the timestamp lets receivers reject replays.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+// Package webhook delivers outbox messages to partner systems. Brokers
+// register callback URLs and the topics they want; the outbox worker fans
+// each matching message out into edge_invocations through Dispatcher, and
+// Deliverer POSTs the due rows with an HMAC signature, retrying with
+// exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"brokerflow/outbox"
+)
+
+// MaxTopics bounds the topics one webhook may subscribe to.
+const MaxTopics = 50
+
+var (
+	ErrNotFound      = errors.New("webhook: not found")
+	ErrInvalidURL    = errors.New("webhook: url must be an absolute https URL")
+	ErrNoTopics      = errors.New("webhook: at least one topic is required")
+	ErrTooManyTopics = fmt.Errorf("webhook: at most %d topics", MaxTopics)
+	ErrUnknownTopic  = errors.New("webhook: unknown topic")
+)
+
+// Webhook is a broker's subscription. Secret is only populated when the
+// webhook is created; it is the HMAC key partners verify signatures with.
+type Webhook struct {
+	ID        string
+	BrokerID  string
+	URL       string
+	Topics    []string
+	Secret    string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Delivery is one webhook's attempt record for one outbox message.
+type Delivery struct {
+	WebhookID     string
+	MessageID     string
+	Topic         string
+	Status        string
+	Attempts      int
+	ResponseCode  *int
+	Error         *string
+	FirstAttempt  time.Time
+	LastAttempt   time.Time
+	NextAttemptAt *time.Time
+}
+
+// Delivery states written to edge_invocations.status.
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// CreateParams registers a webhook for BrokerID.
+type CreateParams struct {
+	BrokerID  string
+	URL       string
+	Topics    []string
+	CreatedBy string
+}
+
+type Repository interface {
+	Create(ctx context.Context, params CreateParams, secret string) (Webhook, error)
+	List(ctx context.Context, brokerID string) ([]Webhook, error)
+	// Delete removes the webhook and cancels its pending deliveries.
+	Delete(ctx context.Context, brokerID, id string) error
+	// Deliveries returns one page of the webhook's delivery records, newest
+	// first, and the total.
+	Deliveries(ctx context.Context, brokerID, id string, page, pageSize int) ([]Delivery, int, error)
+}
+
+// TopicLookup reports whether a topic is declared; *outbox.Registry
+// satisfies it.
+type TopicLookup interface {
+	Lookup(name string) (outbox.Topic, bool)
+}
+
+type Service struct {
+	repo   Repository
+	topics TopicLookup
+}
+
+func NewService(repo Repository, topics TopicLookup) *Service {
+	return &Service{repo: repo, topics: topics}
+}
+
+// Create validates params and registers the webhook with a fresh secret.
+func (s *Service) Create(ctx context.Context, params CreateParams) (Webhook, error) {
+	params, err := s.validate(params)
+	if err != nil {
+		return Webhook{}, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return Webhook{}, err
+	}
+	return s.repo.Create(ctx, params, secret)
+}
+
+func (s *Service) List(ctx context.Context, brokerID string) ([]Webhook, error) {
+	return s.repo.List(ctx, brokerID)
+}
+
+func (s *Service) Delete(ctx context.Context, brokerID, id string) error {
+	return s.repo.Delete(ctx, brokerID, id)
+}
+
+func (s *Service) Deliveries(ctx context.Context, brokerID, id string, page, pageSize int) ([]Delivery, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.repo.Deliveries(ctx, brokerID, id, page, pageSize)
+}
+
+// validate trims the URL and dedups topics, preserving their order.
+func (s *Service) validate(params CreateParams) (CreateParams, error) {
+	params.URL = strings.TrimSpace(params.URL)
+	u, err := url.Parse(params.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return params, ErrInvalidURL
+	}
+
+	seen := make(map[string]bool, len(params.Topics))
+	topics := make([]string, 0, len(params.Topics))
+	for _, t := range params.Topics {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if _, ok := s.topics.Lookup(t); !ok {
+			return params, fmt.Errorf("%w: %s", ErrUnknownTopic, t)
+		}
+		seen[t] = true
+		topics = append(topics, t)
+	}
+	switch {
+	case len(topics) == 0:
+		return params, ErrNoTopics
+	case len(topics) > MaxTopics:
+		return params, ErrTooManyTopics
+	}
+	params.Topics = topics
+	return params, nil
+}
+
+func newSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("webhook: generate secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
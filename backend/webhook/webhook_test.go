@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brokerflow/clock"
+	"brokerflow/outbox"
+)
+
+type stubRepo struct {
+	created CreateParams
+	secret  string
+}
+
+func (s *stubRepo) Create(_ context.Context, params CreateParams, secret string) (Webhook, error) {
+	s.created, s.secret = params, secret
+	return Webhook{ID: "w1", BrokerID: params.BrokerID, URL: params.URL, Topics: params.Topics, Secret: secret}, nil
+}
+
+func (s *stubRepo) List(context.Context, string) ([]Webhook, error) { return nil, nil }
+func (s *stubRepo) Delete(context.Context, string, string) error    { return nil }
+func (s *stubRepo) Deliveries(context.Context, string, string, int, int) ([]Delivery, int, error) {
+	return nil, 0, nil
+}
+
+func testRegistry() *outbox.Registry {
+	reg := outbox.NewRegistry()
+	reg.MustRegister(outbox.Topic{Name: "match.accepted"}, outbox.Topic{Name: "agreement.created"})
+	return reg
+}
+
+func TestService_CreateValidates(t *testing.T) {
+	cases := []struct {
+		name   string
+		params CreateParams
+		want   error
+	}{
+		{"http url", CreateParams{URL: "http://partner.example/hook", Topics: []string{"match.accepted"}}, ErrInvalidURL},
+		{"relative url", CreateParams{URL: "/hook", Topics: []string{"match.accepted"}}, ErrInvalidURL},
+		{"credentials in url", CreateParams{URL: "https://u:p@partner.example/hook", Topics: []string{"match.accepted"}}, ErrInvalidURL},
+		{"no topics", CreateParams{URL: "https://partner.example/hook", Topics: []string{" "}}, ErrNoTopics},
+		{"unknown topic", CreateParams{URL: "https://partner.example/hook", Topics: []string{"nope"}}, ErrUnknownTopic},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService(&stubRepo{}, testRegistry())
+			if _, err := svc.Create(context.Background(), tc.params); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestService_CreateGeneratesSecret(t *testing.T) {
+	repo := &stubRepo{}
+	svc := NewService(repo, testRegistry())
+
+	w, err := svc.Create(context.Background(), CreateParams{
+		BrokerID: "b1",
+		URL:      " https://partner.example/hook ",
+		Topics:   []string{"match.accepted", "agreement.created", "match.accepted"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if repo.created.URL != "https://partner.example/hook" {
+		t.Fatalf("expected trimmed url, got %q", repo.created.URL)
+	}
+	if got := strings.Join(repo.created.Topics, ","); got != "match.accepted,agreement.created" {
+		t.Fatalf("expected deduped topics, got %s", got)
+	}
+	if !strings.HasPrefix(w.Secret, "whsec_") || len(w.Secret) != len("whsec_")+64 {
+		t.Fatalf("unexpected secret %q", w.Secret)
+	}
+}
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"m1"}`)
+
+	got := Sign("whsec_test", ts, body)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		20: 6 * time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestDeliverer_SendSignsEnvelope(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var (
+		gotHeaders http.Header
+		gotBody    []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewDeliverer(nil).WithClient(srv.Client()).WithClock(clock.NewFake(now))
+	x := due{url: srv.URL, secret: "whsec_test", envelope: Envelope{
+		ID: "m1", Topic: "match.accepted", CreatedAt: now, Payload: json.RawMessage(`{"match_id":"x"}`),
+	}}
+
+	code, err := d.send(context.Background(), x)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("send: code %d, err %v", code, err)
+	}
+	if gotHeaders.Get(HeaderTopic) != "match.accepted" || gotHeaders.Get(HeaderDelivery) != "m1" {
+		t.Fatalf("unexpected headers %v", gotHeaders)
+	}
+	if sig := gotHeaders.Get(HeaderSignature); sig != Sign("whsec_test", now, gotBody) {
+		t.Fatalf("signature %q does not match body", sig)
+	}
+	var env Envelope
+	if err := json.Unmarshal(gotBody, &env); err != nil || env.ID != "m1" || string(env.Payload) != `{"match_id":"x"}` {
+		t.Fatalf("unexpected body %s (%v)", gotBody, err)
+	}
+}
+
+func TestDeliverer_SendRejectsNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d := NewDeliverer(nil).WithClient(srv.Client())
+	code, err := d.send(context.Background(), due{url: srv.URL, envelope: Envelope{ID: "m1"}})
+	if err == nil || code != http.StatusBadGateway {
+		t.Fatalf("expected error with 502, got %d, %v", code, err)
+	}
+}
+
+func TestDeliverer_DefaultClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewDeliverer(nil).send(context.Background(), due{url: srv.URL, envelope: Envelope{ID: "m1"}})
+	if !errors.Is(err, errNonPublicAddress) {
+		t.Fatalf("expected non-public address error, got %v", err)
+	}
+}
+
+func TestCheckPublicAddress(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:443", "10.1.2.3:443", "192.168.0.1:80", "169.254.169.254:80", "[::1]:443", "0.0.0.0:443"} {
+		if err := checkPublicAddress(addr); err == nil {
+			t.Errorf("expected %s to be refused", addr)
+		}
+	}
+	if err := checkPublicAddress("93.184.216.34:443"); err != nil {
+		t.Errorf("expected public address to pass, got %v", err)
+	}
+}